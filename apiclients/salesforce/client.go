@@ -5,15 +5,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/requestid"
 	"github.com/rorycl/reconciler/internal/token"
 
 	"golang.org/x/oauth2"
@@ -27,19 +32,37 @@ const SalesforceAPIVersionNumber = "v65.0"
 // can be updated in one operation.
 const maxBatchUpdateCount = 200
 
+// maxGraphNodes is the maximum number of nodes in a Composite Graph, limiting the
+// records that may be updated atomically to maxGraphNodes*maxBatchUpdateCount.
+const maxGraphNodes = 500
+
+// calls records the successful calls of all Clients for ConnectionStatus.
+var calls apistatus.Recorder
+
 // Client is a wrapper for making authenticated calls to the Salesforce API.
 type Client struct {
 	httpClient  *http.Client
 	instanceURL string
+	tokenExpiry time.Time
 	apiVersion  string
 	config      config.Config
+	counter     apistatus.Counter
 	log         *slog.Logger
 }
 
 // NewClient is provided a valid (refreshed where necessary) token and returns a
-// Salesforce client.
+// Salesforce client. An error is returned if the token's instance is not in the
+// configured Salesforce environment. The client logs the request id carried by ctx, if
+// any.
 func NewClient(ctx context.Context, cfg *config.Config, logger *slog.Logger, et *token.ExtendedToken) (*Client, error) {
 
+	logger = requestid.Logger(ctx, logger)
+
+	if err := cfg.Salesforce.CheckInstanceURL(et.InstanceURL); err != nil {
+		logger.Error(fmt.Sprintf("NewClient: %v", err))
+		return nil, err
+	}
+
 	// Use a StaticTokenSource to stop automatic refresh.
 	ts := oauth2.StaticTokenSource(et.Token)
 	oauthClient := oauth2.NewClient(ctx, ts)
@@ -47,6 +70,7 @@ func NewClient(ctx context.Context, cfg *config.Config, logger *slog.Logger, et
 	return &Client{
 		httpClient:  oauthClient,
 		instanceURL: et.InstanceURL,
+		tokenExpiry: et.Expiry(),
 		apiVersion:  SalesforceAPIVersionNumber,
 		config:      *cfg,
 		log:         logger,
@@ -56,20 +80,86 @@ func NewClient(ctx context.Context, cfg *config.Config, logger *slog.Logger, et
 // GetOpportunities fetches records from Salesforce using a configurable SOQL query.
 func (c *Client) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]Donation, error) {
 
-	var conditions []string
-	conditions = append(conditions, fmt.Sprintf("CloseDate >= %s", fromDate.Format("2006-01-02")))
-	if !ifModifiedSince.IsZero() {
-		conditions = append(conditions, fmt.Sprintf("LastModifiedDate > %s", ifModifiedSince.UTC().Format(time.RFC3339)))
-	}
-	whereClause := strings.Join(conditions, " AND ")
-
-	// Replace the placeholder in the query template with the generated WHERE clause.
-	finalSOQL := strings.Replace(c.config.Salesforce.Query, "{{.WhereClause}}", whereClause, 1)
+	finalSOQL := c.soql(fromDate, ifModifiedSince)
 	c.log.Debug(fmt.Sprintf("GetOpportunities sql: %s", finalSOQL))
 
 	// Dump the final query for debugging purposes.
 	// _ = os.WriteFile("salesforce_query.log", []byte(finalSOQL), 0644)
 
+	return c.queryDonations(ctx, "GetOpportunities", finalSOQL)
+}
+
+// GetOpportunitiesByID fetches the records with the provided IDs from Salesforce using
+// the configurable SOQL query. Records that no longer exist are not returned.
+func (c *Client) GetOpportunitiesByID(ctx context.Context, ids []string) ([]Donation, error) {
+
+	if err := IDsValid(ids...); err != nil {
+		return nil, err
+	}
+	var records []Donation
+	for chunk := range slices.Chunk(ids, maxBatchUpdateCount) {
+		whereClause := fmt.Sprintf("Id IN ('%s')", strings.Join(chunk, "','"))
+		finalSOQL := strings.Replace(c.config.Salesforce.Query, "{{.WhereClause}}", whereClause, 1)
+		c.log.Debug(fmt.Sprintf("GetOpportunitiesByID sql: %s", finalSOQL))
+
+		donations, err := c.queryDonations(ctx, "GetOpportunitiesByID", finalSOQL)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, donations...)
+	}
+	return records, nil
+}
+
+// GetDeletedOpportunityIDs returns those of the provided IDs which are deleted records
+// in the Salesforce recycle bin, using the queryAll resource which, unlike query,
+// includes deleted records. Records purged from the recycle bin are not found.
+func (c *Client) GetDeletedOpportunityIDs(ctx context.Context, ids []string) ([]string, error) {
+
+	if err := IDsValid(ids...); err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for chunk := range slices.Chunk(ids, maxBatchUpdateCount) {
+		finalSOQL := fmt.Sprintf(
+			"SELECT Id FROM %s WHERE IsDeleted = true AND Id IN ('%s')",
+			c.config.Salesforce.LinkingObject,
+			strings.Join(chunk, "','"),
+		)
+		c.log.Debug(fmt.Sprintf("GetDeletedOpportunityIDs sql: %s", finalSOQL))
+
+		requestURL := fmt.Sprintf("%s/services/data/%s/queryAll?q=%s", c.instanceURL, c.apiVersion, url.QueryEscape(finalSOQL))
+		req, err := c.newRequest(ctx, "GET", requestURL, nil)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("GetDeletedOpportunityIDs: newRequest error: %v", err))
+			return nil, fmt.Errorf("newRequest error: %w", err)
+		}
+
+		// A chunk returns fewer records than the 2000 record batch size, so there is
+		// only one page of results.
+		var response struct {
+			Records []struct {
+				ID string `json:"Id"`
+			} `json:"records"`
+		}
+		if _, err := c.do(req, &response); err != nil {
+			c.log.Error(fmt.Sprintf("GetDeletedOpportunityIDs soql do error: %v", err))
+			return nil, fmt.Errorf("soql do error: %w", err)
+		}
+		for _, r := range response.Records {
+			deleted = append(deleted, r.ID)
+		}
+	}
+	c.log.Info("GetDeletedOpportunityIDs completed successfully", "records", len(deleted))
+	return deleted, nil
+}
+
+// queryDonations runs the SOQL query, retrieving all pages of results. The caller is
+// used for logging. Pages are requested in the configured batch size, the progress of
+// the query is reported to any progress func carried by ctx, and ErrTooManyRecords is
+// returned if the query matches more than the configured maximum number of records.
+func (c *Client) queryDonations(ctx context.Context, caller, finalSOQL string) ([]Donation, error) {
+
 	// Salesforce sobject queries provide at most 2000 records in a batch. Subsequent
 	// query paths are represented by response.NextRecordsURL. If there are no more
 	// records to retrieve this URL will be empty and response.Done will be true.
@@ -79,29 +169,45 @@ func (c *Client) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince
 	// requestURL is the initial url.
 	requestURL := fmt.Sprintf("%s/services/data/%s/query?q=%s", c.instanceURL, c.apiVersion, url.QueryEscape(finalSOQL))
 	var records []Donation
-	var pageNo int
+	var pageNo, totalSize int
 	for {
 		pageNo++
-		c.log.Debug(fmt.Sprintf("GetOpportunities: page %d: url %s", pageNo, requestURL))
+		c.log.Debug(fmt.Sprintf("%s: page %d: url %s", caller, pageNo, requestURL))
 
 		req, err := c.newRequest(ctx, "GET", requestURL, nil)
 		if err != nil {
-			c.log.Error(fmt.Sprintf("GetOpportunities: newRequest error pageNo %d: %v", pageNo, err))
+			c.log.Error(fmt.Sprintf("%s: newRequest error pageNo %d: %v", caller, pageNo, err))
 			return nil, fmt.Errorf("newRequest error pageNo %d: %w", pageNo, err)
 		}
 
+		if batchSize := c.config.Salesforce.QueryBatchSize; batchSize > 0 {
+			req.Header.Set("Sforce-Query-Options", fmt.Sprintf("batchSize=%d", batchSize))
+		}
+
 		var response SOQLResponse
 		if _, err := c.do(req, &response); err != nil {
-			c.log.Error(fmt.Sprintf("GetOpportunities soql do error pageNo %d: %v", pageNo, err))
+			c.log.Error(fmt.Sprintf("%s soql do error pageNo %d: %v", caller, pageNo, err))
 			return nil, fmt.Errorf("soql do error pageNo %d: %w", pageNo, err)
 		}
+
+		// Refuse runaway result sets, checking the total size reported with the first
+		// page before retrieving any further pages.
+		if pageNo == 1 {
+			totalSize = response.TotalSize
+		}
+		total := max(totalSize, len(records)+len(response.Donations))
+		if maxRecords := c.config.Salesforce.MaxRecords; maxRecords > 0 && total > maxRecords {
+			c.log.Error(fmt.Sprintf("%s: %d records exceed the maximum of %d", caller, total, maxRecords))
+			return nil, ErrTooManyRecords{Total: total, Max: maxRecords}
+		}
 		records = append(records, response.Donations...)
+		progress(ctx, QueryProgress{Caller: caller, Page: pageNo, Fetched: len(records), Total: total})
 		if response.Done || response.NextRecordsURL == "" {
 			break
 		}
 		requestURL, err = url.JoinPath(c.instanceURL, response.NextRecordsURL)
 		if err != nil {
-			c.log.Error(fmt.Sprintf("GetOpportunities: url construction error for page %d: (%s) %v", pageNo+1, response.NextRecordsURL, err))
+			c.log.Error(fmt.Sprintf("%s: url construction error for page %d: (%s) %v", caller, pageNo+1, response.NextRecordsURL, err))
 			return nil, fmt.Errorf("url construction error for page %d: (%s) %w", pageNo+1, response.NextRecordsURL, err)
 		}
 
@@ -109,33 +215,117 @@ func (c *Client) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince
 	return records, nil
 }
 
-// BatchUpdateOpportunityRefs performs a update using the Salesforce sObject Collections
-// API (which is a synchronous API) for up to 200 records at a time. See
-// https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_sobject_describe.htm.
+// PreviewOpportunities runs the configured SOQL query from fromDate, returning at most
+// limit records from a single request. It is used to check the query and its field
+// mappings.
+func (c *Client) PreviewOpportunities(ctx context.Context, fromDate time.Time, limit int) ([]Donation, error) {
+
+	finalSOQL := fmt.Sprintf("%s LIMIT %d", c.soql(fromDate, time.Time{}), limit)
+	c.log.Debug(fmt.Sprintf("PreviewOpportunities sql: %s", finalSOQL))
+
+	requestURL := fmt.Sprintf("%s/services/data/%s/query?q=%s", c.instanceURL, c.apiVersion, url.QueryEscape(finalSOQL))
+	req, err := c.newRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("PreviewOpportunities: newRequest error: %v", err))
+		return nil, fmt.Errorf("newRequest error: %w", err)
+	}
+
+	var response SOQLResponse
+	if _, err := c.do(req, &response); err != nil {
+		c.log.Error(fmt.Sprintf("PreviewOpportunities soql do error: %v", err))
+		return nil, fmt.Errorf("soql do error: %w", err)
+	}
+	return response.Donations, nil
+}
+
+// soql returns the configured SOQL query with a WHERE clause limiting records to those
+// with a close date, in the configured close date field, on or after fromDate and, if
+// ifModifiedSince is not zero, modified after ifModifiedSince.
+func (c *Client) soql(fromDate, ifModifiedSince time.Time) string {
+	var conditions []string
+	closeDate := c.config.Salesforce.CoreField(config.CoreFieldCloseDate)
+	conditions = append(conditions, fmt.Sprintf("%s >= %s", closeDate, fromDate.Format("2006-01-02")))
+	if !ifModifiedSince.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("LastModifiedDate > %s", ifModifiedSince.UTC().Format(time.RFC3339)))
+	}
+	whereClause := strings.Join(conditions, " AND ")
+
+	// Replace the placeholder in the query template with the generated WHERE clause.
+	return strings.Replace(c.config.Salesforce.Query, "{{.WhereClause}}", whereClause, 1)
+}
+
+// BatchUpdateOpportunityRefs updates the payout references of Salesforce opportunity
+// records using the Salesforce sObject Collections API (which is a synchronous API),
+// which updates up to 200 records at a time. See
+// https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_sobjects_collections_update.htm.
 //
 // The method replaces the data in the stated salesforce LinkingFieldName for salesforce
 // opportunity records on a per-IDRef basis, updating each Salesforce record ID with the
 // provided `reference`.
 //
-// Note that setting `allOrNone` to true makes the SOQL update atomic and the
-// transaction will fail in it's entirety if any single opportunity record cannot be
-// updated. See
+// Note that setting `allOrNone` to true makes the update atomic and the transaction
+// will fail in it's entirety if any single opportunity record cannot be updated. See
 // https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_allornone.htm
 // for more information about "allOrNone Parameters in Composite and Collections
 // Requests".
+//
+// More than 200 records are updated in chunks of 200. If allOrNone is set the chunks
+// are sent as the nodes of a single Composite Graph, which Salesforce rolls back in its
+// entirety if any node fails, so that no record is updated unless all are. Otherwise
+// each chunk is updated in turn, and the failures of all chunks are reported.
 func (c *Client) BatchUpdateOpportunityRefs(
 	ctx context.Context,
 	idRefs []IDRef,
 	allOrNone bool) (CollectionsUpdateResponse, error) {
 
-	urlTpl := "%s/services/data/%s/composite/sobjects"
+	if len(idRefs) <= maxBatchUpdateCount {
+		response, err := c.collectionsUpdate(ctx, idRefs, allOrNone)
+		if err != nil {
+			return response, err
+		}
+		c.log.Info("BatchUpdateOpportunityRefs completed successfully", "records", len(idRefs))
+		return response, nil
+	}
 
-	if len(idRefs) > maxBatchUpdateCount {
-		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: cannot update more than %d records in a single batch", maxBatchUpdateCount))
-		return nil, fmt.Errorf("cannot update more than %d records in a single batch", maxBatchUpdateCount)
+	if allOrNone {
+		response, err := c.graphUpdate(ctx, idRefs)
+		if err != nil {
+			return response, err
+		}
+		c.log.Info("BatchUpdateOpportunityRefs graph update completed successfully", "records", len(idRefs))
+		return response, nil
 	}
 
-	// Build a slice of records.
+	var response CollectionsUpdateResponse
+	var failures []string
+	for chunk := range slices.Chunk(idRefs, maxBatchUpdateCount) {
+		chunkResponse, err := c.collectionsUpdate(ctx, chunk, false)
+		response = append(response, chunkResponse...)
+		if f, ok := errors.AsType[errUpdateFailures](err); ok {
+			failures = append(failures, f...)
+			continue
+		}
+		if err != nil {
+			return response, err
+		}
+	}
+	if len(failures) > 0 {
+		return response, errUpdateFailures(failures)
+	}
+	c.log.Info("BatchUpdateOpportunityRefs completed successfully", "records", len(idRefs))
+	return response, nil
+}
+
+// errUpdateFailures reports the records which failed to update.
+type errUpdateFailures []string
+
+func (e errUpdateFailures) Error() string {
+	return fmt.Sprintf("one or more donations failed to update:\n- %s", strings.Join(e, "\n- "))
+}
+
+// updateRecords returns the sObject Collections records updating the linking field of
+// each record of idRefs.
+func (c *Client) updateRecords(idRefs []IDRef) []map[string]any {
 	donationsForUpdate := make([]map[string]any, len(idRefs))
 	for i, record := range idRefs {
 		donationsForUpdate[i] = map[string]any{
@@ -146,11 +336,44 @@ func (c *Client) BatchUpdateOpportunityRefs(
 			},
 		}
 	}
+	return donationsForUpdate
+}
+
+// updateFailures returns an errUpdateFailures error for the failed records of
+// response, or nil if all the records were updated.
+func (c *Client) updateFailures(response CollectionsUpdateResponse) error {
+	var errorMessages []string
+	for _, result := range response {
+		if !result.Success {
+			var errors []string
+			for _, e := range result.Errors {
+				errors = append(errors, fmt.Sprintf("%s (%s)", e.Message, e.ErrorCode))
+				msg := fmt.Sprintf("BatchUpdateOpportunityRefs: failed to update donation %s: %s", result.ID, strings.Join(errors, ", "))
+				errorMessages = append(errorMessages, msg)
+			}
+		}
+	}
+	if len(errorMessages) > 0 {
+		c.log.Error("BatchUpdateOpportunityRefs: one or more donations failed to update")
+		return errUpdateFailures(errorMessages)
+	}
+	return nil
+}
+
+// collectionsUpdate updates up to 200 records in a single sObject Collections request.
+func (c *Client) collectionsUpdate(ctx context.Context, idRefs []IDRef, allOrNone bool) (CollectionsUpdateResponse, error) {
+
+	urlTpl := "%s/services/data/%s/composite/sobjects"
+
+	if len(idRefs) > maxBatchUpdateCount {
+		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: cannot update more than %d records in a single batch", maxBatchUpdateCount))
+		return nil, fmt.Errorf("cannot update more than %d records in a single batch", maxBatchUpdateCount)
+	}
 
 	// Wrap records in the required request body structure.
 	payload := CollectionsUpdateRequest{
 		AllOrNone: allOrNone,
-		Records:   donationsForUpdate,
+		Records:   c.updateRecords(idRefs),
 	}
 
 	body, err := json.Marshal(payload)
@@ -176,25 +399,76 @@ func (c *Client) BatchUpdateOpportunityRefs(
 	}
 
 	// Check for errors within the response array.
-	var errorMessages []string
-	for _, result := range response {
-		if !result.Success {
-			var errors []string
-			for _, e := range result.Errors {
-				errors = append(errors, fmt.Sprintf("%s (%s)", e.Message, e.ErrorCode))
-				msg := fmt.Sprintf("BatchUpdateOpportunityRefs: failed to update donation %s: %s", result.ID, strings.Join(errors, ", "))
-				errorMessages = append(errorMessages, msg)
-			}
-		}
+	return response, c.updateFailures(response)
+}
+
+// graphUpdate updates the records of idRefs atomically, sending each chunk of 200
+// records as an sObject Collections node of a single Composite Graph. If the graph
+// fails no record is updated, and the records of the nodes which succeeded before
+// being rolled back are reported as failed with the PROCESSING_HALTED error code. See
+// https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_composite_graph.htm.
+func (c *Client) graphUpdate(ctx context.Context, idRefs []IDRef) (CollectionsUpdateResponse, error) {
+
+	urlTpl := "%s/services/data/%s/composite/graph"
+
+	chunks := slices.Collect(slices.Chunk(idRefs, maxBatchUpdateCount))
+	if len(chunks) > maxGraphNodes {
+		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: cannot update more than %d records atomically", maxGraphNodes*maxBatchUpdateCount))
+		return nil, fmt.Errorf("cannot update more than %d records atomically", maxGraphNodes*maxBatchUpdateCount)
 	}
 
-	if len(errorMessages) > 0 {
-		c.log.Error("BatchUpdateOpportunityRefs: one or more donations failed to update")
-		return response, fmt.Errorf("one or more donations failed to update:\n- %s",
-			strings.Join(errorMessages, "\n- "))
+	graph := Graph{GraphID: "refs"}
+	for i, chunk := range chunks {
+		graph.CompositeRequest = append(graph.CompositeRequest, CompositeSubrequest{
+			Method:      "PATCH",
+			URL:         fmt.Sprintf("/services/data/%s/composite/sobjects", c.apiVersion),
+			ReferenceID: fmt.Sprintf("chunk%d", i+1),
+			Body: CollectionsUpdateRequest{
+				AllOrNone: true,
+				Records:   c.updateRecords(chunk),
+			},
+		})
 	}
 
-	c.log.Info("BatchUpdateOpportunityRefs completed successfully", "records", len(idRefs))
+	body, err := json.Marshal(GraphRequest{Graphs: []Graph{graph}})
+	if err != nil {
+		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: failed to marshal graph request: %v", err))
+		return nil, fmt.Errorf("failed to marshal graph request: %w", err)
+	}
+
+	requestURL := fmt.Sprintf(urlTpl, c.instanceURL, c.apiVersion)
+	c.log.Debug(fmt.Sprintf("BatchUpdateOpportunityRefs: graph requestURL %s with %d nodes", requestURL, len(chunks)))
+
+	req, err := c.newRequest(ctx, "POST", requestURL, body)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: new graph request error: %v", err))
+		return nil, fmt.Errorf("new graph request error: %w", err)
+	}
+
+	var graphResponse GraphResponse
+	if _, err := c.do(req, &graphResponse); err != nil {
+		c.log.Error(fmt.Sprintf("BatchUpdateOpportunityRefs: graph response error: %v", err))
+		return nil, err
+	}
+	if len(graphResponse.Graphs) != 1 {
+		return nil, fmt.Errorf("graph response has %d graphs, expected 1", len(graphResponse.Graphs))
+	}
+	result := graphResponse.Graphs[0]
+	nodes := result.GraphResponse.CompositeResponse
+	if len(nodes) != len(chunks) {
+		return nil, fmt.Errorf("graph response has %d nodes, expected %d", len(nodes), len(chunks))
+	}
+
+	var response CollectionsUpdateResponse
+	for i, node := range nodes {
+		response = append(response, node.saveResults(chunks[i], result.IsSuccessful)...)
+	}
+	if err := c.updateFailures(response); err != nil {
+		return response, err
+	}
+	if !result.IsSuccessful {
+		return response, errors.New("the graph update failed and was rolled back")
+	}
 	return response, nil
 }
 
@@ -217,6 +491,7 @@ func (c *Client) newRequest(ctx context.Context, method, url string, body []byte
 
 // do is a helper to execute an HTTP request and decode the JSON response.
 func (c *Client) do(req *http.Request, v any) (*http.Response, error) {
+	c.counter.Call()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
@@ -234,7 +509,7 @@ func (c *Client) do(req *http.Request, v any) (*http.Response, error) {
 	// _ = os.WriteFile("/tmp/salesforce_response.json", body, 0644)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp, body)
 	}
 
 	if v != nil {
@@ -245,7 +520,7 @@ func (c *Client) do(req *http.Request, v any) (*http.Response, error) {
 			return resp, json.Unmarshal(body, v)
 		}
 		// unmarshal
-		unmarshaller := SOQLUnmarshaller{Mapper: c.config.Salesforce.FieldMappings}
+		unmarshaller := NewSOQLUnmarshaller(c.config.Salesforce)
 		data, err := unmarshaller.UnmarshalSOQLResponse(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -254,5 +529,77 @@ func (c *Client) do(req *http.Request, v any) (*http.Response, error) {
 		*soqlResponsePtr = *data
 	}
 
+	calls.Record(time.Now(), rateLimits(resp.Header)...)
 	return resp, nil
 }
+
+// apiError returns the error of an unsuccessful response with the given body, which
+// Salesforce reports as a json list of messages and error codes. Exceeding the daily
+// api request limit is reported as a 403 with the REQUEST_LIMIT_EXCEEDED code.
+func apiError(resp *http.Response, body []byte) error {
+	apiErr := apistatus.ErrRemoteAPI{
+		API:        "salesforce",
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+	var sfErrs []struct {
+		Message   string `json:"message"`
+		ErrorCode string `json:"errorCode"`
+	}
+	if json.Unmarshal(body, &sfErrs) == nil && len(sfErrs) > 0 {
+		apiErr.Code = sfErrs[0].ErrorCode
+		msgs := make([]string, len(sfErrs))
+		for i, e := range sfErrs {
+			msgs[i] = e.Message
+		}
+		apiErr.Message = strings.Join(msgs, "; ")
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || apiErr.Code == "REQUEST_LIMIT_EXCEEDED" {
+		return apistatus.ErrRateLimited{
+			API:        "salesforce",
+			Limit:      apiErr.Message,
+			RetryAfter: apistatus.RetryAfter(resp.Header, time.Now()),
+		}
+	}
+	return apiErr
+}
+
+// rateLimits returns the daily api request limit reported in the Sforce-Limit-Info
+// header of a response, such as "api-usage=25/15000".
+func rateLimits(h http.Header) []apistatus.Limit {
+	for field := range strings.SplitSeq(h.Get("Sforce-Limit-Info"), ",") {
+		usage, ok := strings.CutPrefix(strings.TrimSpace(field), "api-usage=")
+		if !ok {
+			continue
+		}
+		used, max, ok := strings.Cut(usage, "/")
+		if !ok {
+			continue
+		}
+		u, errU := strconv.Atoi(used)
+		m, errM := strconv.Atoi(max)
+		if errU != nil || errM != nil {
+			continue
+		}
+		return []apistatus.Limit{{Period: "day", Remaining: m - u, Max: m}}
+	}
+	return nil
+}
+
+// APICalls returns the number of requests made by the client.
+func (c *Client) APICalls() int {
+	return c.counter.Calls()
+}
+
+// RecordsSkipped returns the number of records of recordType retrieved by the client
+// but skipped, which is always 0 as the Salesforce query selects only the wanted
+// records.
+func (c *Client) RecordsSkipped(recordType string) int {
+	return c.counter.Skipped(recordType)
+}
+
+// ConnectionStatus returns the status of the Salesforce connection, being the
+// instance and token expiry of this client and the last successful call of any client.
+func (c *Client) ConnectionStatus() apistatus.Status {
+	return calls.Status(c.instanceURL, c.tokenExpiry)
+}
@@ -4,16 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/rorycl/reconciler/config"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/apistatus"
 )
 
 // setup creates a test environment for running API client tests. It
@@ -204,6 +209,67 @@ func TestGetOpportunities_TwoBatch(t *testing.T) {
 	}
 }
 
+// TestGetOpportunities_Paging tests the query batch size header, the progress
+// reported for each page and the maximum records limit.
+func TestGetOpportunities_Paging(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	endpointPath := fmt.Sprintf("/services/data/%s/query", client.apiVersion)
+	var pages [][]byte
+	for _, j := range []string{"salesforce_batch1.json", "salesforce_batch2.json"} {
+		b, err := os.ReadFile(filepath.Join("testdata", j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, bytes.ReplaceAll(b, []byte("REPLACE-ME"), []byte(endpointPath)))
+	}
+
+	var callCount int
+	mux.HandleFunc(endpointPath, func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Sforce-Query-Options"), "batchSize=200"; got != want {
+			t.Errorf("query options header got %q want %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(pages[callCount%len(pages)])
+		callCount++
+	})
+
+	client.config.Salesforce.QueryBatchSize = 200
+
+	var got []QueryProgress
+	ctx := WithProgress(t.Context(), func(p QueryProgress) {
+		got = append(got, p)
+	})
+	donations, err := client.GetOpportunities(ctx, time.Now(), time.Time{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(donations), 3; got != want {
+		t.Errorf("expected %d donations, got %d", want, got)
+	}
+	want := []QueryProgress{
+		{Caller: "GetOpportunities", Page: 1, Fetched: 2, Total: 2},
+		{Caller: "GetOpportunities", Page: 2, Fetched: 3, Total: 3},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("progress mismatch (-want +got):\n%s", diff)
+	}
+
+	// The records retrieved exceed the maximum on the second page.
+	callCount = 0
+	client.config.Salesforce.MaxRecords = 2
+	_, err = client.GetOpportunities(t.Context(), time.Now(), time.Time{})
+	e, ok := errors.AsType[ErrTooManyRecords](err)
+	if !ok {
+		t.Fatalf("expected ErrTooManyRecords, got %v", err)
+	}
+	if got, want := e, (ErrTooManyRecords{Total: 3, Max: 2}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
 // TestBatchUpdateOpportunityRefs_Succeed tests batch PATCH updates to
 // update donations.
 func TestBatchUpdateOpportunityRefs_Succeed(t *testing.T) {
@@ -282,3 +348,213 @@ func TestBatchUpdateOpportunityRefs_Fail(t *testing.T) {
 	}
 
 }
+
+// TestBatchUpdateOpportunityRefs_Chunked tests updating more than 200 records, in
+// chunks of collection updates or atomically in a composite graph.
+func TestBatchUpdateOpportunityRefs_Chunked(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	idRefs := make([]IDRef, 450)
+	for i := range idRefs {
+		idRefs[i] = IDRef{ID: fmt.Sprintf("id-%03d", i), Ref: "ref-abc"}
+	}
+	errorID := "id-300"
+
+	// saveResults returns the results of a collection update, failing errorID.
+	saveResults := func(records []map[string]any) CollectionsUpdateResponse {
+		results := make(CollectionsUpdateResponse, len(records))
+		for i, r := range records {
+			results[i] = SaveResult{ID: r["id"].(string), Success: r["id"] != errorID}
+			if !results[i].Success {
+				results[i].Errors = []ErrorDetail{{Message: "simulated error", ErrorCode: "FIELD_CUSTOM_VALIDATION_EXCEPTION"}}
+			}
+		}
+		return results
+	}
+
+	var collectionCalls, graphCalls int
+	mux.HandleFunc(fmt.Sprintf("PATCH /services/data/%s/composite/sobjects", client.apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		collectionCalls++
+		var payload CollectionsUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		if len(payload.Records) > maxBatchUpdateCount {
+			t.Errorf("collection update of %d records", len(payload.Records))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(saveResults(payload.Records))
+	})
+	mux.HandleFunc(fmt.Sprintf("POST /services/data/%s/composite/graph", client.apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		graphCalls++
+		var payload struct {
+			Graphs []struct {
+				GraphID          string `json:"graphId"`
+				CompositeRequest []struct {
+					ReferenceID string                   `json:"referenceId"`
+					Body        CollectionsUpdateRequest `json:"body"`
+				} `json:"compositeRequest"`
+			} `json:"graphs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		var nodes []map[string]any
+		ok := true
+		for _, node := range payload.Graphs[0].CompositeRequest {
+			if !node.Body.AllOrNone {
+				t.Errorf("node %s is not all or none", node.ReferenceID)
+			}
+			results := saveResults(node.Body.Records)
+			if slices.ContainsFunc(results, func(r SaveResult) bool { return !r.Success }) {
+				ok = false
+			}
+			nodes = append(nodes, map[string]any{"body": results, "httpStatusCode": 200, "referenceId": node.ReferenceID})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"graphs": []map[string]any{{
+			"graphId":       payload.Graphs[0].GraphID,
+			"graphResponse": map[string]any{"compositeResponse": nodes},
+			"isSuccessful":  ok,
+		}}})
+	})
+
+	// Without allOrNone the chunks are updated in turn, failing only errorID.
+	response, err := client.BatchUpdateOpportunityRefs(t.Context(), idRefs, false)
+	if err == nil || !strings.Contains(err.Error(), "failed to update donation id-300") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if got, want := collectionCalls, 3; got != want {
+		t.Errorf("collection calls got %d want %d", got, want)
+	}
+	failed := slices.IndexFunc(response, func(r SaveResult) bool { return !r.Success })
+	if got, want := len(response), 450; got != want {
+		t.Fatalf("results got %d want %d", got, want)
+	}
+	if got, want := response[failed].ID, errorID; got != want {
+		t.Errorf("failed id got %s want %s", got, want)
+	}
+
+	// With allOrNone the graph fails as a whole.
+	response, err = client.BatchUpdateOpportunityRefs(t.Context(), idRefs, true)
+	if err == nil {
+		t.Fatal("expected a graph error")
+	}
+	if got, want := graphCalls, 1; got != want {
+		t.Errorf("graph calls got %d want %d", got, want)
+	}
+	if slices.ContainsFunc(response, func(r SaveResult) bool { return r.Success }) {
+		t.Error("expected all records to be reported as failed")
+	}
+	if got, want := response[0].Errors[0].ErrorCode, "PROCESSING_HALTED"; got != want {
+		t.Errorf("rolled back error code got %s want %s", got, want)
+	}
+
+	// Without the failing record the graph succeeds.
+	errorID = ""
+	response, err = client.BatchUpdateOpportunityRefs(t.Context(), idRefs, true)
+	if err != nil {
+		t.Fatalf("unexpected graph error: %v", err)
+	}
+	if got, want := len(response), 450; got != want {
+		t.Errorf("results got %d want %d", got, want)
+	}
+	if got, want := response[449].ID, "id-449"; got != want {
+		t.Errorf("last result id got %s want %s", got, want)
+	}
+}
+
+// TestGetDeletedOpportunityIDs tests that deleted records are queried with the
+// queryAll resource.
+func TestGetDeletedOpportunityIDs(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+	client.config.Salesforce.LinkingObject = "Opportunity"
+
+	mux.HandleFunc(fmt.Sprintf("/services/data/%s/queryAll", client.apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("q")
+		if !strings.Contains(q, "FROM Opportunity WHERE IsDeleted = true AND Id IN ('006000000000001AAA','006000000000002AAA')") {
+			t.Errorf("unexpected query %s", q)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"totalSize": 1, "done": true, "records": [
+			{"attributes": {"type": "Opportunity"}, "Id": "006000000000002AAA"}
+		]}`))
+	})
+
+	deleted, err := client.GetDeletedOpportunityIDs(context.Background(), []string{"006000000000001AAA", "006000000000002AAA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0] != "006000000000002AAA" {
+		t.Errorf("unexpected deleted ids %v", deleted)
+	}
+
+	if _, err := client.GetDeletedOpportunityIDs(context.Background(), []string{"sf-opp-001"}); err == nil {
+		t.Error("expected an error for an invalid id")
+	}
+}
+
+// TestRateLimits tests reading the api usage from the Sforce-Limit-Info header.
+func TestRateLimits(t *testing.T) {
+
+	tests := []struct {
+		header string
+		want   []apistatus.Limit
+	}{
+		{"api-usage=25/15000", []apistatus.Limit{{Period: "day", Remaining: 14975, Max: 15000}}},
+		{"per-app-api-usage=3/100(appName=x), api-usage=100/5000", []apistatus.Limit{{Period: "day", Remaining: 4900, Max: 5000}}},
+		{"", nil},
+		{"api-usage=lots", nil},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("Sforce-Limit-Info", tt.header)
+		}
+		if diff := cmp.Diff(tt.want, rateLimits(h)); diff != "" {
+			t.Errorf("%q limits mismatch (-want +got):\n%s", tt.header, diff)
+		}
+	}
+}
+
+// TestAPIError tests decoding the errors reported by Salesforce.
+func TestAPIError(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{
+			name:   "error list",
+			status: http.StatusBadRequest,
+			body:   `[{"message":"No such column 'Foo__c'","errorCode":"INVALID_FIELD"}]`,
+			want:   apistatus.ErrRemoteAPI{API: "salesforce", StatusCode: 400, Code: "INVALID_FIELD", Message: "No such column 'Foo__c'"},
+		},
+		{
+			name:   "plain body",
+			status: http.StatusBadGateway,
+			body:   "upstream unavailable\n",
+			want:   apistatus.ErrRemoteAPI{API: "salesforce", StatusCode: 502, Message: "upstream unavailable"},
+		},
+		{
+			name:   "request limit",
+			status: http.StatusForbidden,
+			body:   `[{"message":"TotalRequests Limit exceeded.","errorCode":"REQUEST_LIMIT_EXCEEDED"}]`,
+			want:   apistatus.ErrRateLimited{API: "salesforce", Limit: "TotalRequests Limit exceeded."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if diff := cmp.Diff(tt.want, apiError(resp, []byte(tt.body))); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
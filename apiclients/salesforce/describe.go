@@ -0,0 +1,173 @@
+package salesforce
+
+// describe.go provides Salesforce sObject descriptions, used to check the configured
+// field mappings against the fields available in the connected Salesforce org.
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rorycl/reconciler/config"
+)
+
+// ObjectDescription is the part of a Salesforce sObject describe response used by this
+// client. See
+// https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_sobject_describe.htm.
+type ObjectDescription struct {
+	Name   string             `json:"name"`
+	Label  string             `json:"label"`
+	Fields []FieldDescription `json:"fields"`
+}
+
+// FieldDescription describes a field on a Salesforce sObject. Lookup fields such as
+// AccountId have a RelationshipName (such as "Account") and the objects they refer to
+// in ReferenceTo.
+type FieldDescription struct {
+	Name             string   `json:"name"`
+	Label            string   `json:"label"`
+	Type             string   `json:"type"`
+	Updateable       bool     `json:"updateable"`
+	RelationshipName string   `json:"relationshipName"`
+	ReferenceTo      []string `json:"referenceTo"`
+}
+
+// field returns the description of the named field, matched case insensitively.
+func (od ObjectDescription) field(name string) (FieldDescription, bool) {
+	for _, f := range od.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f, true
+		}
+	}
+	return FieldDescription{}, false
+}
+
+// relationship returns the description of the lookup field with the relationship name,
+// matched case insensitively.
+func (od ObjectDescription) relationship(name string) (FieldDescription, bool) {
+	for _, f := range od.Fields {
+		if f.RelationshipName != "" && strings.EqualFold(f.RelationshipName, name) {
+			return f, true
+		}
+	}
+	return FieldDescription{}, false
+}
+
+// DescribeObject retrieves the description of a Salesforce sObject, such as
+// "Opportunity".
+func (c *Client) DescribeObject(ctx context.Context, object string) (ObjectDescription, error) {
+
+	var description ObjectDescription
+
+	requestURL := fmt.Sprintf("%s/services/data/%s/sobjects/%s/describe", c.instanceURL, c.apiVersion, object)
+	c.log.Debug(fmt.Sprintf("DescribeObject: requestURL %s", requestURL))
+
+	req, err := c.newRequest(ctx, "GET", requestURL, nil)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("DescribeObject: newRequest error: %v", err))
+		return description, fmt.Errorf("newRequest error: %w", err)
+	}
+	if _, err := c.do(req, &description); err != nil {
+		c.log.Error(fmt.Sprintf("DescribeObject %s error: %v", object, err))
+		return description, fmt.Errorf("describe %s error: %w", object, err)
+	}
+	return description, nil
+}
+
+// ValidateFieldMappings checks that the fields in the configured field mappings exist
+// on the object queried by the configured SOQL query, following relationship paths
+// such as "Account.Name" to the related object. The core fields are checked to exist on
+// the queried object, and the linking field to exist and be updateable on the linking
+// object.
+//
+// A list of problems is returned, which is empty if the configuration is valid. An
+// error is only returned if the objects could not be described.
+func (c *Client) ValidateFieldMappings(ctx context.Context) ([]string, error) {
+
+	sc := c.config.Salesforce
+	descriptions := map[string]ObjectDescription{}
+	describe := func(object string) (ObjectDescription, error) {
+		key := strings.ToLower(object)
+		if od, ok := descriptions[key]; ok {
+			return od, nil
+		}
+		od, err := c.DescribeObject(ctx, object)
+		if err != nil {
+			return od, err
+		}
+		descriptions[key] = od
+		return od, nil
+	}
+
+	var problems []string
+
+	object := sc.QueryObject()
+	for _, field := range slices.Sorted(maps.Keys(sc.FieldMappings)) {
+		problem, err := checkFieldPath(object, field, describe)
+		if err != nil {
+			return nil, err
+		}
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("field mapping %s (%s): %s", field, sc.FieldMappings[field], problem))
+		}
+	}
+
+	od, err := describe(object)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range config.CoreFieldNames {
+		field := sc.CoreField(name)
+		if _, ok := od.field(field); !ok {
+			problems = append(problems, fmt.Sprintf("core field %s %s not found on %s", name, field, object))
+		}
+	}
+
+	od, err = describe(sc.LinkingObject)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := od.field(sc.LinkingFieldName)
+	switch {
+	case !ok:
+		problems = append(problems, fmt.Sprintf("linking field %s not found on %s", sc.LinkingFieldName, sc.LinkingObject))
+	case !f.Updateable:
+		problems = append(problems, fmt.Sprintf("linking field %s on %s is not updateable", sc.LinkingFieldName, sc.LinkingObject))
+	}
+
+	for _, p := range problems {
+		c.log.Warn(fmt.Sprintf("ValidateFieldMappings: %s", p))
+	}
+	return problems, nil
+}
+
+// checkFieldPath checks that a field path such as "StageName" or "Account.Owner.Name"
+// exists from object, returning a description of the problem if not.
+func checkFieldPath(object, path string, describe func(string) (ObjectDescription, error)) (string, error) {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		od, err := describe(object)
+		if err != nil {
+			return "", err
+		}
+		if i == len(parts)-1 {
+			if _, ok := od.field(part); !ok {
+				return fmt.Sprintf("field %s not found on %s", part, object), nil
+			}
+			return "", nil
+		}
+		f, ok := od.relationship(part)
+		if !ok {
+			return fmt.Sprintf("relationship %s not found on %s", part, object), nil
+		}
+		if len(f.ReferenceTo) == 0 {
+			return fmt.Sprintf("relationship %s on %s has no related object", part, object), nil
+		}
+		// Polymorphic relationships, such as Owner, are checked against the first
+		// related object.
+		object = f.ReferenceTo[0]
+	}
+	return "", nil
+}
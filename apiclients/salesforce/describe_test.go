@@ -0,0 +1,138 @@
+package salesforce
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// serveDescribe registers describe endpoints on mux for the testdata
+// describe_<object>.json files, counting the calls made.
+func serveDescribe(t *testing.T, mux *http.ServeMux, client *Client, calls map[string]int) {
+	t.Helper()
+	for _, object := range []string{"Opportunity", "Account"} {
+		content, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("describe_%s.json", strings.ToLower(object))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := fmt.Sprintf("/services/data/%s/sobjects/%s/describe", client.apiVersion, object)
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			calls[object]++
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(content)
+		})
+	}
+}
+
+func TestValidateFieldMappings(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	calls := map[string]int{}
+	serveDescribe(t, mux, client, calls)
+
+	client.config.Salesforce.Query = "SELECT Id, Name, StageName, Account.Name FROM Opportunity"
+	client.config.Salesforce.LinkingObject = "Opportunity"
+	client.config.Salesforce.LinkingFieldName = "Payout_Reference__c"
+
+	tests := []struct {
+		name     string
+		mappings map[string]string
+		linking  string
+		problems []string
+	}{
+		{
+			name:     "valid",
+			mappings: map[string]string{"StageName": "Stage", "account.name": "Account"},
+			linking:  "Payout_Reference__c",
+			problems: nil,
+		},
+		{
+			name:     "invalid",
+			mappings: map[string]string{"Stage": "Stage", "Account.Bogus": "Bogus", "RecordType.Name": "RecordType"},
+			linking:  "Id",
+			problems: []string{
+				"field mapping Account.Bogus (Bogus): field Bogus not found on Account",
+				"field mapping RecordType.Name (RecordType): relationship RecordType not found on Opportunity",
+				"field mapping Stage (Stage): field Stage not found on Opportunity",
+				"linking field Id on Opportunity is not updateable",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(calls)
+			client.config.Salesforce.FieldMappings = tt.mappings
+			client.config.Salesforce.LinkingFieldName = tt.linking
+			problems, err := client.ValidateFieldMappings(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.problems, problems); diff != "" {
+				t.Errorf("problems mismatch (-want +got):\n%s", diff)
+			}
+			if got := calls["Opportunity"]; got != 1 {
+				t.Errorf("expected Opportunity to be described once, got %d", got)
+			}
+		})
+	}
+
+	// Core fields of other objects are checked on the queried object.
+	client.config.Salesforce.FieldMappings = nil
+	client.config.Salesforce.LinkingFieldName = "Payout_Reference__c"
+	client.config.Salesforce.CoreFields = map[string]string{"Amount": "npsp__Amount__c"}
+	problems, err := client.ValidateFieldMappings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"core field Amount npsp__Amount__c not found on Opportunity"}, problems); diff != "" {
+		t.Errorf("core field problems mismatch (-want +got):\n%s", diff)
+	}
+	client.config.Salesforce.CoreFields = nil
+
+	// Objects that cannot be described are an error.
+	client.config.Salesforce.FieldMappings = map[string]string{"CreatedBy.Name": "CreatedBy"}
+	if _, err := client.ValidateFieldMappings(context.Background()); err == nil {
+		t.Error("expected an error describing User")
+	}
+}
+
+func TestPreviewOpportunities(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	client.config.Salesforce.Query = "SELECT Id, Name FROM Opportunity WHERE {{.WhereClause}}"
+
+	content, err := os.ReadFile(filepath.Join("testdata", "salesforce_batch2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var query string
+	mux.HandleFunc(fmt.Sprintf("/services/data/%s/query", client.apiVersion), func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(content)
+	})
+
+	donations, err := client.PreviewOpportunities(context.Background(), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := query, "SELECT Id, Name FROM Opportunity WHERE CloseDate >= 2025-04-01 LIMIT 5"; got != want {
+		t.Errorf("query got %q want %q", got, want)
+	}
+	if len(donations) == 0 {
+		t.Error("expected donations")
+	}
+}
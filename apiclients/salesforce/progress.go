@@ -0,0 +1,43 @@
+package salesforce
+
+import (
+	"context"
+	"fmt"
+)
+
+// QueryProgress reports the progress of a paged SOQL query after each page is
+// retrieved. Total is the number of records matched by the query as reported by
+// Salesforce with the first page, or the number fetched if more.
+type QueryProgress struct {
+	Caller  string
+	Page    int
+	Fetched int
+	Total   int
+}
+
+// ErrTooManyRecords is returned when a query matches more records than the configured
+// salesforce.max_records.
+type ErrTooManyRecords struct {
+	Total int
+	Max   int
+}
+
+func (e ErrTooManyRecords) Error() string {
+	return fmt.Sprintf("the query matched %d records, more than the maximum of %d", e.Total, e.Max)
+}
+
+// progressKey is the context key of the progress func.
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx carrying fn, which is called with the progress of
+// the queries made with the context, such as those of a data refresh.
+func WithProgress(ctx context.Context, fn func(QueryProgress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progress reports p to the progress func carried by ctx, if any.
+func progress(ctx context.Context, p QueryProgress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(QueryProgress)); ok && fn != nil {
+		fn(p)
+	}
+}
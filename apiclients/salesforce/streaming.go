@@ -0,0 +1,247 @@
+package salesforce
+
+// streaming.go provides a Change Data Capture subscriber using the Salesforce CometD
+// (Bayeux) streaming API, allowing changes to records in Salesforce to be applied
+// between full refreshes. See
+// https://developer.salesforce.com/docs/atlas.en-us.change_data_capture.meta/change_data_capture/cdc_subscribe_cometd.htm.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+)
+
+// Change event types. Gap events report changes that could not be described in full,
+// and the records should be retrieved. Overflow gap events do not report record IDs.
+const (
+	ChangeCreate      = "CREATE"
+	ChangeUpdate      = "UPDATE"
+	ChangeDelete      = "DELETE"
+	ChangeUndelete    = "UNDELETE"
+	ChangeGapOverflow = "GAP_OVERFLOW"
+)
+
+// Replay ids for starting a subscription with new events only, or with all the events
+// retained by Salesforce (presently for 72 hours).
+const (
+	ReplayNew int64 = -1
+	ReplayAll int64 = -2
+)
+
+// ChangeEvent is a Change Data Capture event for one or more records of an object.
+type ChangeEvent struct {
+	ReplayID   int64
+	EntityName string
+	ChangeType string
+	RecordIDs  []string
+	CommitTime time.Time
+}
+
+// bayeuxMessage is a Bayeux protocol message.
+type bayeuxMessage struct {
+	Channel                  string          `json:"channel"`
+	ClientID                 string          `json:"clientId,omitempty"`
+	Version                  string          `json:"version,omitempty"`
+	SupportedConnectionTypes []string        `json:"supportedConnectionTypes,omitempty"`
+	ConnectionType           string          `json:"connectionType,omitempty"`
+	Subscription             string          `json:"subscription,omitempty"`
+	Successful               bool            `json:"successful,omitempty"`
+	Error                    string          `json:"error,omitempty"`
+	Advice                   *bayeuxAdvice   `json:"advice,omitempty"`
+	Ext                      map[string]any  `json:"ext,omitempty"`
+	Data                     json.RawMessage `json:"data,omitempty"`
+}
+
+// bayeuxAdvice is the server advice on how to reconnect, which is one of "retry",
+// "handshake" or "none".
+type bayeuxAdvice struct {
+	Reconnect string `json:"reconnect"`
+	Interval  int    `json:"interval"`
+}
+
+// changeEventData is the data of a change event message.
+type changeEventData struct {
+	Event struct {
+		ReplayID int64 `json:"replayId"`
+	} `json:"event"`
+	Payload struct {
+		ChangeEventHeader struct {
+			EntityName      string   `json:"entityName"`
+			ChangeType      string   `json:"changeType"`
+			RecordIDs       []string `json:"recordIds"`
+			CommitTimestamp int64    `json:"commitTimestamp"`
+		} `json:"ChangeEventHeader"`
+	} `json:"payload"`
+}
+
+// changeEventChannel returns the Change Data Capture channel for an object, such as
+// /data/OpportunityChangeEvent or /data/Donation__ChangeEvent for custom objects.
+func changeEventChannel(object string) string {
+	if name, ok := strings.CutSuffix(object, "__c"); ok {
+		return fmt.Sprintf("/data/%s__ChangeEvent", name)
+	}
+	return fmt.Sprintf("/data/%sChangeEvent", object)
+}
+
+// SubscribeChanges subscribes to the Change Data Capture events for the object queried
+// by the configured SOQL query, calling handle for each event until ctx is cancelled,
+// handle returns an error or the subscription fails. Events after replayID are
+// received; see ReplayNew and ReplayAll. Change Data Capture must be enabled for the
+// object in Salesforce.
+//
+// If the Salesforce session expires the subscription is made again from the last
+// event received.
+func (c *Client) SubscribeChanges(ctx context.Context, replayID int64, handle func(ChangeEvent) error) error {
+
+	channel := changeEventChannel(c.config.Salesforce.QueryObject())
+	streamURL := fmt.Sprintf("%s/cometd/%s", c.instanceURL, strings.TrimPrefix(c.apiVersion, "v"))
+
+	// CometD sessions rely on cookies set by the server.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return fmt.Errorf("cookie jar error: %w", err)
+	}
+	hc := *c.httpClient
+	hc.Jar = jar
+
+	for {
+		clientID, err := c.bayeuxSubscribe(ctx, &hc, streamURL, channel, replayID)
+		if err != nil {
+			return err
+		}
+		c.log.Info("SubscribeChanges: subscribed", "channel", channel, "replayId", replayID)
+
+		rehandshake := false
+		for !rehandshake {
+			msgs, err := c.bayeux(ctx, &hc, streamURL, bayeuxMessage{
+				Channel:        "/meta/connect",
+				ClientID:       clientID,
+				ConnectionType: "long-polling",
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("connect error: %w", err)
+			}
+			for _, m := range msgs {
+				switch m.Channel {
+				case "/meta/connect":
+					if m.Successful {
+						continue
+					}
+					if m.Advice == nil || m.Advice.Reconnect != "handshake" {
+						return fmt.Errorf("connect failed: %s", m.Error)
+					}
+					c.log.Info(fmt.Sprintf("SubscribeChanges: reconnecting after %s", m.Error))
+					rehandshake = true
+				case channel:
+					event, err := parseChangeEvent(m.Data)
+					if err != nil {
+						return err
+					}
+					if err := handle(event); err != nil {
+						return err
+					}
+					replayID = event.ReplayID
+				}
+			}
+		}
+	}
+}
+
+// bayeuxSubscribe performs a Bayeux handshake and subscribes to channel from replayID,
+// returning the Bayeux client id.
+func (c *Client) bayeuxSubscribe(ctx context.Context, hc *http.Client, streamURL, channel string, replayID int64) (string, error) {
+
+	msgs, err := c.bayeux(ctx, hc, streamURL, bayeuxMessage{
+		Channel:                  "/meta/handshake",
+		Version:                  "1.0",
+		SupportedConnectionTypes: []string{"long-polling"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("handshake error: %w", err)
+	}
+	if len(msgs) == 0 || !msgs[0].Successful {
+		return "", fmt.Errorf("handshake failed: %v", msgs)
+	}
+	clientID := msgs[0].ClientID
+
+	msgs, err = c.bayeux(ctx, hc, streamURL, bayeuxMessage{
+		Channel:      "/meta/subscribe",
+		ClientID:     clientID,
+		Subscription: channel,
+		Ext:          map[string]any{"replay": map[string]int64{channel: replayID}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("subscribe error: %w", err)
+	}
+	for _, m := range msgs {
+		if m.Channel == "/meta/subscribe" && !m.Successful {
+			return "", fmt.Errorf("subscribe to %s failed: %s", channel, m.Error)
+		}
+	}
+	return clientID, nil
+}
+
+// bayeux posts a Bayeux message to the streaming endpoint and returns the response
+// messages.
+func (c *Client) bayeux(ctx context.Context, hc *http.Client, streamURL string, msg bayeuxMessage) ([]bayeuxMessage, error) {
+
+	body, err := json.Marshal([]bayeuxMessage{msg})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s message: %w", msg.Channel, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", streamURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var msgs []bayeuxMessage
+	if err := json.Unmarshal(respBody, &msgs); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", msg.Channel, err)
+	}
+	return msgs, nil
+}
+
+// parseChangeEvent parses the data of a change event message.
+func parseChangeEvent(data json.RawMessage) (ChangeEvent, error) {
+	var ced changeEventData
+	if err := json.Unmarshal(data, &ced); err != nil {
+		return ChangeEvent{}, fmt.Errorf("failed to decode change event: %w", err)
+	}
+	header := ced.Payload.ChangeEventHeader
+	if header.ChangeType == "" {
+		return ChangeEvent{}, errors.New("change event has no change type")
+	}
+	return ChangeEvent{
+		ReplayID:   ced.Event.ReplayID,
+		EntityName: header.EntityName,
+		ChangeType: header.ChangeType,
+		RecordIDs:  header.RecordIDs,
+		CommitTime: time.UnixMilli(header.CommitTimestamp).UTC(),
+	}, nil
+}
@@ -0,0 +1,127 @@
+package salesforce
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestChangeEventChannel(t *testing.T) {
+	for object, want := range map[string]string{
+		"Opportunity": "/data/OpportunityChangeEvent",
+		"Donation__c": "/data/Donation__ChangeEvent",
+	} {
+		if got := changeEventChannel(object); got != want {
+			t.Errorf("%s channel got %q want %q", object, got, want)
+		}
+	}
+}
+
+// TestSubscribeChanges runs a subscription against a fake CometD server, which expires
+// the Bayeux session after the first event to check that the subscription is resumed
+// from the last event received.
+func TestSubscribeChanges(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	client.config.Salesforce.Query = "SELECT Id FROM Opportunity WHERE {{.WhereClause}}"
+	channel := "/data/OpportunityChangeEvent"
+
+	event := func(replayID int64, changeType string, ids ...string) map[string]any {
+		return map[string]any{
+			"channel": channel,
+			"data": map[string]any{
+				"event": map[string]any{"replayId": replayID},
+				"payload": map[string]any{
+					"ChangeEventHeader": map[string]any{
+						"entityName":      "Opportunity",
+						"changeType":      changeType,
+						"recordIds":       ids,
+						"commitTimestamp": 1760000000000,
+					},
+				},
+			},
+		}
+	}
+
+	var handshakes, connects int
+	var replays []int64
+	mux.HandleFunc(fmt.Sprintf("/cometd/%s", client.apiVersion[1:]), func(w http.ResponseWriter, r *http.Request) {
+		var msgs []bayeuxMessage
+		if err := json.NewDecoder(r.Body).Decode(&msgs); err != nil || len(msgs) != 1 {
+			t.Fatalf("invalid bayeux request: %v", err)
+		}
+		msg := msgs[0]
+		var response []any
+		switch msg.Channel {
+		case "/meta/handshake":
+			handshakes++
+			http.SetCookie(w, &http.Cookie{Name: "BAYEUX_BROWSER", Value: "b1"})
+			response = append(response, map[string]any{"channel": msg.Channel, "successful": true, "clientId": fmt.Sprintf("c%d", handshakes)})
+		case "/meta/subscribe":
+			replay := msg.Ext["replay"].(map[string]any)[channel].(float64)
+			replays = append(replays, int64(replay))
+			response = append(response, map[string]any{"channel": msg.Channel, "successful": true, "subscription": channel})
+		case "/meta/connect":
+			if _, err := r.Cookie("BAYEUX_BROWSER"); err != nil {
+				t.Error("expected bayeux cookie on connect")
+			}
+			connects++
+			switch connects {
+			case 1:
+				response = append(response, event(11, ChangeUpdate, "0065A00000AAAAAQA1", "0065A00000AAAABQA1"))
+				response = append(response, map[string]any{"channel": msg.Channel, "successful": true})
+			case 2:
+				response = append(response, map[string]any{
+					"channel": msg.Channel, "successful": false, "error": "403::Unknown client",
+					"advice": map[string]any{"reconnect": "handshake"},
+				})
+			default:
+				response = append(response, event(12, ChangeDelete, "0065A00000AAAAAQA1"))
+				response = append(response, map[string]any{"channel": msg.Channel, "successful": true})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(response)
+	})
+
+	errDone := errors.New("done")
+	var events []ChangeEvent
+	err := client.SubscribeChanges(context.Background(), ReplayNew, func(ev ChangeEvent) error {
+		events = append(events, ev)
+		if len(events) == 2 {
+			return errDone
+		}
+		return nil
+	})
+	if !errors.Is(err, errDone) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if got, want := replays, []int64{ReplayNew, 11}; !slices.Equal(got, want) {
+		t.Errorf("replays got %v want %v", got, want)
+	}
+	if got, want := len(events[0].RecordIDs), 2; got != want {
+		t.Errorf("record ids got %d want %d", got, want)
+	}
+	if got, want := events[1].ChangeType, ChangeDelete; got != want {
+		t.Errorf("change type got %q want %q", got, want)
+	}
+	if got, want := events[1].CommitTime.Year(), 2025; got != want {
+		t.Errorf("commit year got %d want %d", got, want)
+	}
+
+	// A subscription failure is an error.
+	mux.HandleFunc("/cometd/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	client.apiVersion = "vfail"
+	if err := client.SubscribeChanges(context.Background(), ReplayNew, func(ChangeEvent) error { return nil }); err == nil {
+		t.Error("expected subscription error")
+	}
+}
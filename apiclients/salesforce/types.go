@@ -4,51 +4,99 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 // SOQLStrictMapping determines if checking is made of the SOQL Additional Fields in
 // unmarshalAndMapRecord.
 var SOQLStrictMapping = false
 
-// SalesforceDate is a custom date type.
+// SalesforceDate is a custom date type. Invalid holds a value which could not be
+// parsed, in which case the time is zero.
 type SalesforceDate struct {
 	time.Time
+	Invalid string
 }
 
 // SalesforceTime is a custom type to handle Salesforce's specific datetime format.
+// Invalid holds a value which could not be parsed, in which case the time is zero.
 type SalesforceTime struct {
 	time.Time
+	Invalid string
+}
+
+// salesforceTimeLayouts are the layouts of the datetimes returned by Salesforce, in
+// the order they are tried. The REST API returns "2025-07-14T02:25:51.000+0000", while
+// formula fields and some custom objects return RFC3339 times such as
+// "2025-07-14T02:25:51Z". Fractional seconds are accepted by each layout.
+var salesforceTimeLayouts = []string{
+	"2006-01-02T15:04:05.000-0700",
+	"2006-01-02T15:04:05Z0700",
+	time.RFC3339,
+}
+
+// parseSalesforceTime parses a Salesforce datetime in any of the salesforceTimeLayouts
+// or, as used by change events, a number of milliseconds since the Unix epoch.
+func parseSalesforceTime(s string) (time.Time, error) {
+	for _, layout := range salesforceTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid salesforce datetime format: %s", s)
+}
+
+// parseSalesforceDate parses a Salesforce date, or the date of a datetime where a
+// datetime field is mapped to the close date.
+func parseSalesforceDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := parseSalesforceTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid salesforce date format: %s", s)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
 }
 
 // FlattenedName flattens an obj.Name string to a string.
 type FlattenedName string
 
-// UnmarshalJSON implements the json.Unmarshaler interface.
+// UnmarshalJSON implements the json.Unmarshaler interface. A value which cannot be
+// parsed is recorded in Invalid rather than failing the unmarshalling of the record.
 func (sd *SalesforceDate) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), `"`)
 	if s == "null" || s == "" {
 		return nil
 	}
-	t, err := time.Parse("2006-01-02", s)
+	t, err := parseSalesforceDate(s)
 	if err != nil {
-		return err
+		sd.Invalid = s
+		return nil
 	}
 	sd.Time = t
 	return nil
 }
 
-// UnmarshalJSON implements the json.Unmarshaler interface.
+// UnmarshalJSON implements the json.Unmarshaler interface. A value which cannot be
+// parsed is recorded in Invalid rather than failing the unmarshalling of the record.
 func (st *SalesforceTime) UnmarshalJSON(b []byte) error {
 	s := strings.Trim(string(b), `"`)
 	if s == "null" || s == "" {
 		return nil
 	}
-	// Handles Salesforce's custom format: "2025-07-14T02:25:51.000+0000"
-	t, err := time.Parse("2006-01-02T15:04:05.000-0700", s)
+	t, err := parseSalesforceTime(s)
 	if err != nil {
-		return err
+		st.Invalid = s
+		return nil
 	}
 	st.Time = t
 	return nil
@@ -86,7 +134,7 @@ type SOQLResponse struct {
 type CoreFields struct {
 	ID               string         `json:"Id"`
 	Name             string         `json:"Name"`
-	Amount           float64        `json:"Amount"`
+	Amount           money.Amount   `json:"Amount"`
 	CloseDate        SalesforceDate `json:"CloseDate"`
 	CreatedDate      SalesforceTime `json:"CreatedDate"`
 	LastModifiedDate SalesforceTime `json:"LastModifiedDate"`
@@ -102,12 +150,59 @@ type Donation struct {
 	AdditionalFields map[string]any
 }
 
+// DateWarning reports a date of a donation which is missing or could not be parsed, and
+// so is the zero time. Field is the json name of the date and Value the value which
+// could not be parsed, or empty if the date is missing.
+type DateWarning struct {
+	ID    string
+	Field string
+	Value string
+}
+
+// DateWarnings returns the warnings for the dates of the donation. The close date is
+// required.
+func (d Donation) DateWarnings() []DateWarning {
+	var warnings []DateWarning
+	if d.CloseDate.Invalid != "" || d.CloseDate.IsZero() {
+		warnings = append(warnings, DateWarning{ID: d.ID, Field: "CloseDate", Value: d.CloseDate.Invalid})
+	}
+	for _, dt := range []struct {
+		field string
+		value SalesforceTime
+	}{
+		{"CreatedDate", d.CreatedDate},
+		{"LastModifiedDate", d.LastModifiedDate},
+	} {
+		if dt.value.Invalid != "" {
+			warnings = append(warnings, DateWarning{ID: d.ID, Field: dt.field, Value: dt.value.Invalid})
+		}
+	}
+	return warnings
+}
+
 // SOQLUnmarshaller is a configurable struct for managing the custom
 // unmarshalling of a SOQL response. The Mapper provides a map of
 // fields (other than CoreFields) to store in each Donation's
-// AdditionalFields.
+// AdditionalFields. The optional CoreFields map the fields of the
+// queried object, such as npsp__Amount__c, to the CoreFields json names,
+// such as Amount, for objects other than Opportunity.
 type SOQLUnmarshaller struct {
-	Mapper map[string]string
+	Mapper     map[string]string
+	CoreFields map[string]string
+}
+
+// NewSOQLUnmarshaller returns a SOQLUnmarshaller for the records of the
+// configured query, mapping the configured core fields and linking field
+// to the CoreFields.
+func NewSOQLUnmarshaller(sc config.SalesforceConfig) *SOQLUnmarshaller {
+	coreFields := map[string]string{}
+	for _, name := range config.CoreFieldNames {
+		coreFields[sc.CoreField(name)] = name
+	}
+	if sc.LinkingFieldName != "" {
+		coreFields[sc.LinkingFieldName] = "Payout_Reference__c"
+	}
+	return &SOQLUnmarshaller{Mapper: sc.FieldMappings, CoreFields: coreFields}
 }
 
 // ErrUnmarshallFieldNotFoundError reports an error from trying to
@@ -159,15 +254,22 @@ func (su *SOQLUnmarshaller) UnmarshalSOQLResponse(data []byte) (*SOQLResponse, e
 func (su *SOQLUnmarshaller) unmarshalAndMapRecord(data []byte) (Donation, error) {
 	var donation Donation
 
-	if err := json.Unmarshal(data, &donation.CoreFields); err != nil {
-		return donation, fmt.Errorf("failed to unmarshal core fields: %v", err)
-	}
-
 	var allFields map[string]json.RawMessage
 	if err := json.Unmarshal(data, &allFields); err != nil {
 		return donation, fmt.Errorf("failed to unmarshal into generic map: %v", err)
 	}
 
+	if len(su.CoreFields) > 0 {
+		allFields = su.renameCoreFields(allFields)
+		var err error
+		if data, err = json.Marshal(allFields); err != nil {
+			return donation, fmt.Errorf("failed to marshal renamed core fields: %v", err)
+		}
+	}
+	if err := json.Unmarshal(data, &donation.CoreFields); err != nil {
+		return donation, fmt.Errorf("failed to unmarshal core fields: %v", err)
+	}
+
 	// Delete core fields and unneeded fields from allFields. Retain the top-level
 	// "attributes" for reference if needed.
 	delete(allFields, "Id")
@@ -192,18 +294,13 @@ func (su *SOQLUnmarshaller) unmarshalAndMapRecord(data []byte) (Donation, error)
 					continue
 				}
 				newKey := strings.Join([]string{enTitle(key), enTitle(subKey)}, ".")
-				if replacementKey, ok := su.Mapper[newKey]; ok {
-					newKey = replacementKey
-				}
+				newKey = su.mappedKey(key+"."+subKey, newKey)
 				var v any
 				_ = json.Unmarshal(subValue, &v)
 				donation.AdditionalFields[newKey] = v
 			}
 		} else {
-			newKey := enTitle(key)
-			if replacementKey, ok := su.Mapper[newKey]; ok {
-				newKey = replacementKey
-			}
+			newKey := su.mappedKey(key, enTitle(key))
 			var v any
 			_ = json.Unmarshal(rawValue, &v)
 			donation.AdditionalFields[newKey] = v
@@ -226,6 +323,45 @@ func (su *SOQLUnmarshaller) unmarshalAndMapRecord(data []byte) (Donation, error)
 	return donation, nil
 }
 
+// renameCoreFields returns the fields of a record with the fields named in CoreFields
+// renamed to their CoreFields json names. Salesforce field names are case insensitive.
+// A field of the record already having a CoreFields json name is replaced by the
+// renamed field.
+func (su *SOQLUnmarshaller) renameCoreFields(fields map[string]json.RawMessage) map[string]json.RawMessage {
+	names := map[string]string{}
+	for field, name := range su.CoreFields {
+		names[strings.ToLower(field)] = name
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		if name, ok := names[strings.ToLower(key)]; ok {
+			renamed[name] = value
+		}
+	}
+	for key, value := range fields {
+		if _, ok := names[strings.ToLower(key)]; ok {
+			continue
+		}
+		if _, ok := renamed[key]; !ok {
+			renamed[key] = value
+		}
+	}
+	return renamed
+}
+
+// mappedKey returns the Mapper name of the field key, as returned by Salesforce, or
+// of its title cased form. Otherwise title is returned. Matching the returned key first
+// supports namespaced fields such as npsp__Payment_Method__c.
+func (su *SOQLUnmarshaller) mappedKey(key, title string) string {
+	if name, ok := su.Mapper[key]; ok {
+		return name
+	}
+	if name, ok := su.Mapper[title]; ok {
+		return name
+	}
+	return title
+}
+
 // CollectionsUpdateRequest is the structure for the sObject Collections
 // API request body.
 type CollectionsUpdateRequest struct {
@@ -245,6 +381,94 @@ type SaveResult struct {
 	Errors  []ErrorDetail `json:"errors"`
 }
 
+// GraphRequest is the structure for the Composite Graph API request body.
+type GraphRequest struct {
+	Graphs []Graph `json:"graphs"`
+}
+
+// Graph is a graph of composite subrequests, which succeed or fail together.
+type Graph struct {
+	GraphID          string                `json:"graphId"`
+	CompositeRequest []CompositeSubrequest `json:"compositeRequest"`
+}
+
+// CompositeSubrequest is a node of a Graph.
+type CompositeSubrequest struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	ReferenceID string `json:"referenceId"`
+	Body        any    `json:"body,omitempty"`
+}
+
+// GraphResponse is the response from the Composite Graph API.
+type GraphResponse struct {
+	Graphs []struct {
+		GraphID       string `json:"graphId"`
+		GraphResponse struct {
+			CompositeResponse []CompositeSubresponse `json:"compositeResponse"`
+		} `json:"graphResponse"`
+		IsSuccessful bool `json:"isSuccessful"`
+	} `json:"graphs"`
+}
+
+// CompositeSubresponse is the response to a CompositeSubrequest. The Body of an
+// sObject Collections update is a slice of SaveResult objects or, if the node failed as
+// a whole, a slice of errors.
+type CompositeSubresponse struct {
+	Body           json.RawMessage `json:"body"`
+	HTTPStatusCode int             `json:"httpStatusCode"`
+	ReferenceID    string          `json:"referenceId"`
+}
+
+// saveResults returns the SaveResult of each record of the idRefs updated by the node.
+// If the node failed as a whole each record is reported with the node's errors, and if
+// the graph was not successful the records reported as updated are instead reported as
+// rolled back.
+func (n CompositeSubresponse) saveResults(idRefs []IDRef, graphSucceeded bool) CollectionsUpdateResponse {
+	var entries []struct {
+		SaveResult
+		ErrorDetail
+	}
+	_ = json.Unmarshal(n.Body, &entries) // an unexpected body fails each record
+
+	results := make(CollectionsUpdateResponse, len(idRefs))
+	nodeFailed := len(entries) != len(idRefs)
+	var nodeErrors []ErrorDetail
+	for _, e := range entries {
+		if e.ErrorDetail.ErrorCode != "" {
+			nodeFailed = true
+			nodeErrors = append(nodeErrors, e.ErrorDetail)
+		}
+	}
+	if nodeFailed && len(nodeErrors) == 0 {
+		nodeErrors = []ErrorDetail{{
+			StatusCode: strconv.Itoa(n.HTTPStatusCode),
+			Message:    fmt.Sprintf("unexpected response to graph node %s", n.ReferenceID),
+			ErrorCode:  "UNKNOWN_EXCEPTION",
+		}}
+	}
+
+	for i, idRef := range idRefs {
+		switch {
+		case nodeFailed:
+			results[i] = SaveResult{ID: idRef.ID, Errors: nodeErrors}
+		default:
+			results[i] = entries[i].SaveResult
+			if results[i].ID == "" {
+				results[i].ID = idRef.ID
+			}
+		}
+		if results[i].Success && !graphSucceeded {
+			results[i].Success = false
+			results[i].Errors = []ErrorDetail{{
+				Message:   "rolled back as the graph update failed",
+				ErrorCode: "PROCESSING_HALTED",
+			}}
+		}
+	}
+	return results
+}
+
 // ErrorDetail provides specific information about a failure.
 type ErrorDetail struct {
 	StatusCode string   `json:"statusCode"`
@@ -1,12 +1,15 @@
 package salesforce
 
 import (
+	"encoding/json"
 	"errors"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 func TestTypesOK(t *testing.T) {
@@ -64,10 +67,10 @@ func TestTypesExtended(t *testing.T) {
 		CoreFields: CoreFields{
 			ID:               "006gL00000EsB99QAF",
 			Name:             "Express Logistics Standby Generator",
-			Amount:           220000,
-			CloseDate:        SalesforceDate{time.Date(2025, time.August, 18, 0, 0, 0, 0, time.UTC)},
-			CreatedDate:      SalesforceTime{time.Date(2025, time.November, 27, 10, 21, 45, 0, time.Local)},
-			LastModifiedDate: SalesforceTime{time.Date(2025, time.December, 20, 20, 21, 50, 0, time.Local)},
+			Amount:           money.FromFloat(220000),
+			CloseDate:        SalesforceDate{Time: time.Date(2025, time.August, 18, 0, 0, 0, 0, time.UTC)},
+			CreatedDate:      SalesforceTime{Time: time.Date(2025, time.November, 27, 10, 21, 45, 0, time.Local)},
+			LastModifiedDate: SalesforceTime{Time: time.Date(2025, time.December, 20, 20, 21, 50, 0, time.Local)},
 			CreatedBy:        FlattenedName("OrgFarm EPIC"),
 			LastModifiedBy:   FlattenedName("Test User"),
 			PayoutReference:  ptrStr("ENTH-20251112"),
@@ -117,3 +120,114 @@ func TestTypesFail(t *testing.T) {
 		t.Fatalf("expected fieldMapping error not triggered")
 	}
 }
+
+func TestSalesforceDates(t *testing.T) {
+	dates := []struct {
+		json    string
+		want    time.Time
+		invalid string
+	}{
+		{`"2025-08-18"`, time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC), ""},
+		{`"2025-08-18T23:30:00.000+0000"`, time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC), ""},
+		{`"2025-08-18T23:30:00Z"`, time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC), ""},
+		{`null`, time.Time{}, ""},
+		{`"18/08/2025"`, time.Time{}, "18/08/2025"},
+	}
+	for _, tt := range dates {
+		var got SalesforceDate
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tt.want) || got.Invalid != tt.invalid {
+			t.Errorf("date %s got %s %q want %s %q", tt.json, got.Time, got.Invalid, tt.want, tt.invalid)
+		}
+	}
+
+	times := []struct {
+		json    string
+		want    time.Time
+		invalid string
+	}{
+		{`"2025-07-14T02:25:51.000+0000"`, time.Date(2025, 7, 14, 2, 25, 51, 0, time.UTC), ""},
+		{`"2025-07-14T03:25:51.000+0100"`, time.Date(2025, 7, 14, 2, 25, 51, 0, time.UTC), ""},
+		{`"2025-07-14T02:25:51Z"`, time.Date(2025, 7, 14, 2, 25, 51, 0, time.UTC), ""},
+		{`"2025-07-14T02:25:51.5Z"`, time.Date(2025, 7, 14, 2, 25, 51, 500000000, time.UTC), ""},
+		{`"2025-07-14T03:25:51+01:00"`, time.Date(2025, 7, 14, 2, 25, 51, 0, time.UTC), ""},
+		{`1752459951000`, time.Date(2025, 7, 14, 2, 25, 51, 0, time.UTC), ""},
+		{`""`, time.Time{}, ""},
+		{`"2025-07-14 02:25"`, time.Time{}, "2025-07-14 02:25"},
+	}
+	for _, tt := range times {
+		var got SalesforceTime
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tt.want) || got.Invalid != tt.invalid {
+			t.Errorf("time %s got %s %q want %s %q", tt.json, got.Time, got.Invalid, tt.want, tt.invalid)
+		}
+	}
+
+	// An invalid date no longer fails the unmarshalling of the record, but is reported
+	// as a warning.
+	data := `{"records":[{"Id":"006A","CloseDate":"soon","LastModifiedDate":"2025-07-14T02:25:51Z"}]}`
+	response, err := (&SOQLUnmarshaller{}).UnmarshalSOQLResponse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DateWarning{{ID: "006A", Field: "CloseDate", Value: "soon"}}
+	if diff := cmp.Diff(want, response.Donations[0].DateWarnings()); diff != "" {
+		t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTypesCoreFields(t *testing.T) {
+	b := []byte(`{"totalSize": 1, "done": true, "records": [{
+		"attributes": {"type": "npsp__Payment__c"},
+		"Id": "a01gL00000EsB99QAF",
+		"Name": "PMT-000123",
+		"npsp__Amount__c": 25.5,
+		"npsp__Payment_Date__c": "2025-08-18",
+		"Gift_Reference__c": "ENTH-20251112",
+		"npsp__Payment_Method__c": "Card",
+		"npsp__Opportunity__r": {"attributes": {"type": "Opportunity"}, "Name": "Spring Appeal"},
+		"Amount": 99
+	}]}`)
+
+	sc := config.SalesforceConfig{
+		CoreFields: map[string]string{
+			"Amount":    "npsp__Amount__c",
+			"CloseDate": "npsp__payment_date__c",
+		},
+		FieldMappings: map[string]string{
+			"npsp__Payment_Method__c":   "Method",
+			"npsp__Opportunity__r.Name": "Opportunity",
+		},
+		LinkingFieldName: "Gift_Reference__c",
+	}
+	sr, err := NewSOQLUnmarshaller(sc).UnmarshalSOQLResponse(b)
+	if err != nil {
+		t.Fatalf("UnmarshalSOQLResponse error: %v", err)
+	}
+	if got, want := len(sr.Donations), 1; got != want {
+		t.Fatalf("got %d records, want %d", got, want)
+	}
+
+	ref := "ENTH-20251112"
+	want := Donation{
+		CoreFields: CoreFields{
+			ID:              "a01gL00000EsB99QAF",
+			Name:            "PMT-000123",
+			Amount:          money.FromFloat(25.5),
+			CloseDate:       SalesforceDate{Time: time.Date(2025, time.August, 18, 0, 0, 0, 0, time.UTC)},
+			PayoutReference: &ref,
+		},
+		AdditionalFields: map[string]any{
+			"Method":          "Card",
+			"Opportunity":     "Spring Appeal",
+			"Attributes.Type": "npsp__Payment__c",
+		},
+	}
+	if diff := cmp.Diff(want, sr.Donations[0]); diff != "" {
+		t.Errorf("unexpected record diff:\n%v", diff)
+	}
+}
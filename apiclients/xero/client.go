@@ -12,9 +12,12 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/requestid"
 	"github.com/rorycl/reconciler/internal/token"
 
 	"golang.org/x/oauth2"
@@ -27,17 +30,30 @@ const baseURL = "https://api.xero.com/api.xro/2.0"
 // tenants.
 var connectionsURL = "https://api.xero.com/connections"
 
+// calls records the successful calls of all Clients for ConnectionStatus.
+var calls apistatus.Recorder
+
+// Xero reports the calls remaining in its per minute and per day rate limits in the
+// response headers. See https://developer.xero.com/documentation/guides/oauth2/limits/
+const (
+	minuteLimit = 60
+	dayLimit    = 5000
+)
+
 // Client is a wrapper for making authenticated calls to the Xero API.
 type Client struct {
 	httpClient     *http.Client
 	tenantID       string
+	tokenExpiry    time.Time
 	baseURL        string
 	accountsRegexp *regexp.Regexp
+	counter        apistatus.Counter
 	log            *slog.Logger
 }
 
 // NewClient is provided a valid (refreshed where necessary) token and returns a
-// Xero client. The provided token should be refreshed before provision.
+// Xero client. The provided token should be refreshed before provision. The client
+// logs the request id carried by ctx, if any.
 func NewClient(
 	ctx context.Context,
 	logger *slog.Logger,
@@ -45,6 +61,8 @@ func NewClient(
 	et *token.ExtendedToken,
 ) (*Client, error) {
 
+	logger = requestid.Logger(ctx, logger)
+
 	// Use a StaticTokenSource to stop automatic refresh.
 	ts := oauth2.StaticTokenSource(et.Token)
 	oauthClient := oauth2.NewClient(ctx, ts)
@@ -64,6 +82,7 @@ func NewClient(
 	return &Client{
 		httpClient:     oauthClient,
 		tenantID:       et.TenantID,
+		tokenExpiry:    et.Expiry(),
 		baseURL:        baseURL,
 		accountsRegexp: accountsRegexp,
 		log:            logger,
@@ -140,10 +159,117 @@ func (c *Client) GetBankTransactions(
 		}
 	}
 	c.log.Info(fmt.Sprintf("GetBankTransactions: total %d filtered transactions", len(accountsFilteredTransactions)))
+	c.counter.Skip("bank-transaction", len(allTransactions)-len(accountsFilteredTransactions))
 
 	return accountsFilteredTransactions, nil
 }
 
+// GetOverpayments fetches the authorised or paid overpayments received from Xero dated
+// on or after fromDate. The results are then filtered by those overpayments having at
+// least one line item which matches the account code regexp, the others being counted
+// as skipped bank transactions.
+func (c *Client) GetOverpayments(
+	ctx context.Context,
+	fromDate time.Time,
+	ifModifiedSince time.Time,
+	accountsRegexp *regexp.Regexp,
+) ([]Overpayment, error) {
+	return getCredits(ctx, c, "Overpayments", "RECEIVE-OVERPAYMENT", fromDate, ifModifiedSince, accountsRegexp,
+		func(r OverpaymentsResponse) []Overpayment { return r.Overpayments },
+		func(op Overpayment) []LineItem { return op.LineItems },
+	)
+}
+
+// GetPrepayments fetches the authorised or paid prepayments received from Xero dated on
+// or after fromDate. The results are then filtered by those prepayments having at
+// least one line item which matches the account code regexp, the others being counted
+// as skipped bank transactions.
+func (c *Client) GetPrepayments(
+	ctx context.Context,
+	fromDate time.Time,
+	ifModifiedSince time.Time,
+	accountsRegexp *regexp.Regexp,
+) ([]Prepayment, error) {
+	return getCredits(ctx, c, "Prepayments", "RECEIVE-PREPAYMENT", fromDate, ifModifiedSince, accountsRegexp,
+		func(r PrepaymentsResponse) []Prepayment { return r.Prepayments },
+		func(pp Prepayment) []LineItem { return pp.LineItems },
+	)
+}
+
+// getCredits fetches the pages of the overpayments or prepayments, being the endpoint,
+// of creditType, provided by the records of each response R.
+func getCredits[T, R any](
+	ctx context.Context,
+	c *Client,
+	endpoint string,
+	creditType string,
+	fromDate time.Time,
+	ifModifiedSince time.Time,
+	accountsRegexp *regexp.Regexp,
+	records func(R) []T,
+	lineItems func(T) []LineItem,
+) ([]T, error) {
+
+	var all []T
+	page := 1
+
+	for {
+		var conditions []string
+		conditions = append(conditions, fmt.Sprintf(`Type=="%s"`, creditType), `(Status=="AUTHORISED" OR Status=="PAID")`)
+		conditions = append(conditions, fmt.Sprintf(`Date >= DateTime(%d, %d, %d)`, fromDate.Year(), fromDate.Month(), fromDate.Day()))
+		whereClause := strings.Join(conditions, " AND ")
+
+		params := url.Values{}
+		params.Add("where", whereClause)
+		params.Add("page", fmt.Sprintf("%d", page))
+		requestURL := fmt.Sprintf("%s/%s?%s", c.baseURL, endpoint, params.Encode())
+
+		c.log.Debug(fmt.Sprintf("Get%s request %v", endpoint, requestURL))
+
+		req, err := c.newRequest(ctx, "GET", requestURL, ifModifiedSince, nil)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("Get%s: request error: %v", endpoint, err))
+			return nil, err
+		}
+
+		var response R
+		resp, err := do(c, req, &response)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("Get%s: failed to execute request for page %d: %v", endpoint, page, err))
+			return nil, fmt.Errorf("failed to execute request for page %d: %w", page, err)
+		}
+
+		// A 304 Not Modified response means no new data since the `If-Modified-Since` time.
+		if resp.StatusCode == http.StatusNotModified {
+			break
+		}
+
+		pageRecords := records(response)
+		if len(pageRecords) == 0 {
+			break
+		}
+		all = append(all, pageRecords...)
+		page++
+	}
+
+	c.log.Info(fmt.Sprintf("Get%s: retrieved %d records", endpoint, len(all)))
+
+	if accountsRegexp == nil {
+		return all, nil
+	}
+
+	var filtered []T
+	for _, rec := range all {
+		if lineItemHasWantedAccount(lineItems(rec), accountsRegexp) {
+			filtered = append(filtered, rec)
+		}
+	}
+	c.log.Info(fmt.Sprintf("Get%s: total %d filtered records", endpoint, len(filtered)))
+	c.counter.Skip("bank-transaction", len(all)-len(filtered))
+
+	return filtered, nil
+}
+
 // GetInvoices fetches invoices from Xero, applying appropriate filters. The results are
 // then filtered by those invoices having at least one line item which matches the
 // account code regexp.
@@ -209,6 +335,7 @@ func (c *Client) GetInvoices(
 		}
 	}
 	c.log.Info(fmt.Sprintf("Invoices: total %d filtered invoices", len(accountsFilteredInvoices)))
+	c.counter.Skip("invoice", len(allInvoices)-len(accountsFilteredInvoices))
 
 	return accountsFilteredInvoices, nil
 }
@@ -239,6 +366,77 @@ func (c *Client) GetAccounts(ctx context.Context, ifModifiedSince time.Time) ([]
 	return response.Accounts, nil
 }
 
+// GetContacts fetches all contacts from Xero, or only those modified since
+// ifModifiedSince if it is not zero. Archived contacts are included since they may
+// still be referenced by older invoices and bank transactions.
+func (c *Client) GetContacts(ctx context.Context, ifModifiedSince time.Time) ([]Contact, error) {
+
+	var allContacts []Contact
+	page := 1
+
+	for {
+		params := url.Values{}
+		params.Add("includeArchived", "true")
+		params.Add("page", fmt.Sprintf("%d", page))
+		requestURL := fmt.Sprintf("%s/Contacts?%s", c.baseURL, params.Encode())
+
+		c.log.Debug(fmt.Sprintf("GetContacts request %v", requestURL))
+
+		req, err := c.newRequest(ctx, "GET", requestURL, ifModifiedSince, nil)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("GetContacts: request error: %v", err))
+			return nil, err
+		}
+
+		var response ContactsResponse
+		resp, err := do(c, req, &response)
+		if err != nil {
+			c.log.Error(fmt.Sprintf("GetContacts: failed to execute request for page %d: %v", page, err))
+			return nil, fmt.Errorf("failed to execute request for page %d: %w", page, err)
+		}
+
+		if resp.StatusCode == http.StatusNotModified {
+			break
+		}
+		if len(response.Contacts) == 0 {
+			break
+		}
+
+		allContacts = append(allContacts, response.Contacts...)
+		page++
+	}
+
+	c.log.Info(fmt.Sprintf("GetContacts: retrieved %d contacts", len(allContacts)))
+	return allContacts, nil
+}
+
+// GetInvoiceByID fetches a single invoice by its UUID. Unlike the paged /Invoices
+// listing, the response always includes the invoice line items.
+func (c *Client) GetInvoiceByID(ctx context.Context, uuid string) (Invoice, error) {
+	requestURL := fmt.Sprintf("%s/Invoices/%s", c.baseURL, uuid)
+	req, err := c.newRequest(ctx, "GET", requestURL, time.Time{}, nil)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	var response InvoiceResponse
+	if _, err := do(c, req, &response); err != nil {
+		c.log.Error(fmt.Sprintf("GetInvoiceByID: failed to retrieve record: %v", err))
+		return Invoice{}, err
+	}
+
+	if len(response.Invoices) == 0 {
+		c.log.Error(fmt.Sprintf("GetInvoiceByID: failed to retrieve record %s", uuid))
+		return Invoice{}, apistatus.ErrRemoteAPI{
+			API:        "xero",
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("invoice with UUID %s not found", uuid),
+		}
+	}
+	c.log.Info("GetInvoiceByID successful")
+	return response.Invoices[0], nil
+}
+
 // GetBankTransactionByID fetches a single bank transaction by its UUID.
 func (c *Client) GetBankTransactionByID(ctx context.Context, uuid string) (BankTransaction, error) {
 	requestURL := fmt.Sprintf("%s/BankTransactions/%s", c.baseURL, uuid)
@@ -255,7 +453,11 @@ func (c *Client) GetBankTransactionByID(ctx context.Context, uuid string) (BankT
 
 	if len(response.BankTransactions) == 0 {
 		c.log.Error(fmt.Sprintf("GetBankTransactionByID: failed to retrieve record %s", uuid))
-		return BankTransaction{}, fmt.Errorf("bank transaction with UUID %s not found", uuid)
+		return BankTransaction{}, apistatus.ErrRemoteAPI{
+			API:        "xero",
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("bank transaction with UUID %s not found", uuid),
+		}
 	}
 	c.log.Info("GetBankTransactionByID successful")
 	return response.BankTransactions[0], nil
@@ -293,6 +495,40 @@ func (c *Client) UpdateBankTransactionReference(ctx context.Context, tx BankTran
 	return response.BankTransactions[0], nil
 }
 
+// UpdateInvoiceReference performs a POST request to update the reference of the
+// invoice with invoiceID. Only the reference is sent, leaving the other fields of the
+// invoice unchanged. It returns the full, updated invoice from the Xero API response.
+func (c *Client) UpdateInvoiceReference(ctx context.Context, invoiceID, reference string) (Invoice, error) {
+	payload := map[string][]map[string]string{
+		"Invoices": {{"InvoiceID": invoiceID, "Reference": reference}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("UpdateInvoiceReference: failed to marshal update payload: %v", err))
+		return Invoice{}, fmt.Errorf("failed to marshal update payload: %w", err)
+	}
+
+	requestURL := fmt.Sprintf("%s/Invoices/%s", c.baseURL, invoiceID)
+	req, err := c.newRequest(ctx, "POST", requestURL, time.Time{}, body)
+	if err != nil {
+		c.log.Error(fmt.Sprintf("UpdateInvoiceReference: new request error: %v", err))
+		return Invoice{}, err
+	}
+
+	var response InvoiceResponse
+	if _, err := do(c, req, &response); err != nil {
+		c.log.Error(fmt.Sprintf("UpdateInvoiceReference: request error: %v", err))
+		return Invoice{}, err
+	}
+
+	if len(response.Invoices) == 0 {
+		c.log.Error("UpdateInvoiceReference: update response did not contain an invoice")
+		return Invoice{}, fmt.Errorf("update response did not contain an invoice")
+	}
+	c.log.Info("UpdateInvoiceReference: successful")
+	return response.Invoices[0], nil
+}
+
 // GET https://api.xero.com/api.xro/2.0/Organisation
 // Retrieve organisation info, primarily for the short code.
 func (c *Client) GetOrganisation(ctx context.Context) (Organisation, error) {
@@ -347,6 +583,7 @@ func (c *Client) newRequest(ctx context.Context, method, url string, ifModifiedS
 // response. A nil `v` is supported for API calls not providing a
 // response, such as DELETE calls.
 func do[T any](c *Client, req *http.Request, v *T) (*http.Response, error) {
+	c.counter.Call()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
@@ -365,7 +602,7 @@ func do[T any](c *Client, req *http.Request, v *T) (*http.Response, error) {
 			return resp, nil
 		}
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, apiError(resp, body)
 	}
 
 	if v != nil { // v might be nil for a DELETE request, for example.
@@ -374,9 +611,84 @@ func do[T any](c *Client, req *http.Request, v *T) (*http.Response, error) {
 		}
 	}
 
+	calls.Record(time.Now(), rateLimits(resp.Header)...)
 	return resp, nil
 }
 
+// apiError returns the error of an unsuccessful response with the given body. Xero
+// reports rate limiting with a 429 status, naming the limit exceeded in the
+// X-Rate-Limit-Problem header, and other errors as json with a type and message, and
+// possibly validation errors for each element of the request.
+func apiError(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return apistatus.ErrRateLimited{
+			API:        "xero",
+			Limit:      resp.Header.Get("X-Rate-Limit-Problem"),
+			RetryAfter: apistatus.RetryAfter(resp.Header, time.Now()),
+		}
+	}
+	apiErr := apistatus.ErrRemoteAPI{
+		API:        "xero",
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+	var xeroErr struct {
+		Type     string
+		Message  string
+		Elements []struct {
+			ValidationErrors []struct {
+				Message string
+			}
+		}
+	}
+	if json.Unmarshal(body, &xeroErr) != nil || xeroErr.Message == "" {
+		return apiErr
+	}
+	apiErr.Code = xeroErr.Type
+	apiErr.Message = xeroErr.Message
+	for _, el := range xeroErr.Elements {
+		for _, v := range el.ValidationErrors {
+			apiErr.Message += "; " + v.Message
+		}
+	}
+	return apiErr
+}
+
+// rateLimits returns the rate limits reported in the headers of a response.
+func rateLimits(h http.Header) []apistatus.Limit {
+	var limits []apistatus.Limit
+	for _, l := range []struct {
+		header, period string
+		max            int
+	}{
+		{"X-MinLimit-Remaining", "minute", minuteLimit},
+		{"X-DayLimit-Remaining", "day", dayLimit},
+	} {
+		if remaining, err := strconv.Atoi(h.Get(l.header)); err == nil {
+			limits = append(limits, apistatus.Limit{Period: l.period, Remaining: remaining, Max: l.max})
+		}
+	}
+	return limits
+}
+
+// APICalls returns the number of requests made by the client.
+func (c *Client) APICalls() int {
+	return c.counter.Calls()
+}
+
+// RecordsSkipped returns the number of records of recordType, "invoice" or
+// "bank-transaction", retrieved by the client but skipped as none of their line items
+// are of a wanted account.
+func (c *Client) RecordsSkipped(recordType string) int {
+	return c.counter.Skipped(recordType)
+}
+
+// ConnectionStatus returns the status of the Xero connection, being the tenant and
+// token expiry of this client and the last successful call of any client.
+func (c *Client) ConnectionStatus() apistatus.Status {
+	return calls.Status(c.tenantID, c.tokenExpiry)
+}
+
 // getTenantID fetches the list of connections and returns the first TenantID found.
 // Todo: check suitability of choosing the first connection.
 func getTenantID(ctx context.Context, client *http.Client) (string, error) {
@@ -394,7 +706,8 @@ func getTenantID(ctx context.Context, client *http.Client) (string, error) {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("error getting connections (status %d)", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("error getting connections: %w", apiError(resp, body))
 	}
 
 	var connections []Connection
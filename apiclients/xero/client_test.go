@@ -2,15 +2,20 @@ package xero
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/rorycl/reconciler/internal/apistatus"
 )
 
 // setup creates a test environment for running API client tests. It returns a request
@@ -186,6 +191,63 @@ func TestGetBankTransactions_PaginationAndTermination(t *testing.T) {
 	}
 }
 
+// TestGetOverpaymentsAndPrepayments verifies the Overpayments and Prepayments API
+// pagination and termination, the filtering of the records by account code and their
+// conversion to bank transactions.
+func TestGetOverpaymentsAndPrepayments(t *testing.T) {
+
+	donationAccounts := regexp.MustCompile("^5")
+
+	var client *Client
+	overpayments, err := testPagination(
+		t,
+		"/Overpayments",
+		"overpayments.json",
+		`{"Overpayments": []}`,
+		func(c *Client) ([]Overpayment, error) {
+			client = c
+			return c.GetOverpayments(context.Background(), time.Now(), time.Time{}, donationAccounts)
+		},
+	)
+	if err != nil {
+		t.Fatalf("testPagination returned an unexpected error: %v", err)
+	}
+	if got, want := len(overpayments), 1; got != want {
+		t.Fatalf("expected %d overpayments, got %d", want, got)
+	}
+	if got, want := client.RecordsSkipped("bank-transaction"), 1; got != want {
+		t.Errorf("expected %d skipped overpayments, got %d", want, got)
+	}
+	bt := overpayments[0].BankTransaction()
+	if bt.BankTransactionID != "b3b4a1e2-5c1f-4d2e-8f3a-6a7b8c9d0e11" || bt.Type != "RECEIVE-OVERPAYMENT" ||
+		bt.Contact != "Wilson Periodicals" || bt.ContactID != "bc446de5-971e-48b5-8efd-1745149844ef" ||
+		bt.Date.Format(time.DateOnly) != "2025-11-14" || len(bt.LineItems) != 1 {
+		t.Errorf("unexpected overpayment bank transaction %+v", bt)
+	}
+	if got, want := overpayments[0].RemainingCredit.String(), "20.00"; got != want {
+		t.Errorf("expected remaining credit %s, got %s", want, got)
+	}
+
+	prepayments, err := testPagination(
+		t,
+		"/Prepayments",
+		"prepayments.json",
+		`{"Prepayments": []}`,
+		func(c *Client) ([]Prepayment, error) {
+			return c.GetPrepayments(context.Background(), time.Now(), time.Time{}, donationAccounts)
+		},
+	)
+	if err != nil {
+		t.Fatalf("testPagination returned an unexpected error: %v", err)
+	}
+	if got, want := len(prepayments), 1; got != want {
+		t.Fatalf("expected %d prepayments, got %d", want, got)
+	}
+	if bt := prepayments[0].BankTransaction(); bt.Type != "RECEIVE-PREPAYMENT" || bt.Reference != "Gala 2026 table" {
+		t.Errorf("unexpected prepayment bank transaction %+v", bt)
+	}
+}
+
 // TestGetAccounts_PaginationAndTermination verifies Accounts  API
 // pagination and termination.
 func TestGetAccounts_PaginationAndTermination(t *testing.T) {
@@ -211,6 +273,33 @@ func TestGetAccounts_PaginationAndTermination(t *testing.T) {
 	}
 }
 
+// TestGetContacts_PaginationAndTermination verifies Contacts API pagination and
+// termination.
+func TestGetContacts_PaginationAndTermination(t *testing.T) {
+
+	getContactsFunc := func(client *Client) ([]Contact, error) {
+		return client.GetContacts(context.Background(), time.Time{})
+	}
+
+	contacts, err := testPagination(
+		t,
+		"/Contacts",        // endpoint
+		"contacts.json",    // json file to serve
+		`{"Contacts": []}`, // empty response
+		getContactsFunc,    // the api function to call
+	)
+	if err != nil {
+		t.Fatalf("testPagination returned an unexpected error: %v", err)
+	}
+
+	if got, want := len(contacts), 45; got != want {
+		t.Errorf("expected %d contacts, got %d", want, got)
+	}
+	if contacts[0].ContactID == "" || contacts[0].Name == "" || contacts[0].Updated.IsZero() {
+		t.Errorf("expected contact fields to be populated, got %#v", contacts[0])
+	}
+}
+
 // TestGetInvoices_NotModified tests the client's handling of an HTTP 304 Not Modified
 // status. The client should make one request and immediately stop processing,
 // returning an empty slice of invoices without an error.
@@ -267,8 +356,39 @@ func TestGetInvoices_APIError(t *testing.T) {
 	if !strings.Contains(err.Error(), "status 500") {
 		t.Errorf("error message should contain status code 500, but was: %q", err.Error())
 	}
-	if !strings.Contains(err.Error(), apiErrorBody) {
-		t.Errorf("error message should contain API response body, but was: %q", err.Error())
+	if !strings.Contains(err.Error(), "An internal error occurred") {
+		t.Errorf("error message should contain the API error message, but was: %q", err.Error())
+	}
+	apiErr, ok := errors.AsType[apistatus.ErrRemoteAPI](err)
+	if !ok {
+		t.Fatalf("expected an ErrRemoteAPI, got %T", err)
+	}
+	if got, want := apiErr.StatusCode, http.StatusInternalServerError; got != want {
+		t.Errorf("status code got %d want %d", got, want)
+	}
+}
+
+// TestGetInvoices_RateLimited verifies that a 429 response is reported as rate limiting.
+func TestGetInvoices_RateLimited(t *testing.T) {
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	mux.HandleFunc("/Invoices", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "45")
+		w.Header().Set("X-Rate-Limit-Problem", "minute")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	_, err := client.GetInvoices(context.Background(), time.Now(), time.Time{}, regexp.MustCompile("^[0-9]+"))
+	rateErr, ok := errors.AsType[apistatus.ErrRateLimited](err)
+	if !ok {
+		t.Fatalf("expected an ErrRateLimited, got %v", err)
+	}
+	if got, want := rateErr.RetryAfter, 45*time.Second; got != want {
+		t.Errorf("retry after got %s want %s", got, want)
+	}
+	if got, want := rateErr.Limit, "minute"; got != want {
+		t.Errorf("limit got %q want %q", got, want)
 	}
 }
 
@@ -312,3 +432,126 @@ func TestLineItemHasWantedAccount(t *testing.T) {
 		t.Error("unexpected true return from lineItemHasWantedAccount")
 	}
 }
+
+// TestUpdateInvoiceReference verifies that only the invoice reference is posted and
+// the updated invoice returned.
+func TestUpdateInvoiceReference(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	jsonContent, err := os.ReadFile(filepath.Join("testdata", "invoices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoiceID := "2175c381-d323-4e20-8c94-7680ea7f85d3"
+	mux.HandleFunc("/Invoices/"+invoiceID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("expected method POST, got %s", r.Method)
+		}
+		var payload map[string][]map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("could not decode payload: %v", err)
+		}
+		want := map[string][]map[string]string{
+			"Invoices": {{"InvoiceID": invoiceID, "Reference": "INV-2025-101"}},
+		}
+		if !reflect.DeepEqual(payload, want) {
+			t.Errorf("got payload %v want %v", payload, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonContent)
+	})
+
+	invoice, err := client.UpdateInvoiceReference(context.Background(), invoiceID, "INV-2025-101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := invoice.InvoiceID, invoiceID; got != want {
+		t.Errorf("got invoice id %s want %s", got, want)
+	}
+}
+
+// TestGetInvoiceByID verifies that a single invoice is retrieved with its line items,
+// and that an unknown invoice is reported as an error.
+func TestGetInvoiceByID(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	jsonContent, err := os.ReadFile(filepath.Join("testdata", "invoice.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invoiceID := "7ea31cd8-045c-4871-8cda-c0420953a39c"
+	mux.HandleFunc("/Invoices/"+invoiceID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected method GET, got %s", r.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(jsonContent)
+	})
+	mux.HandleFunc("/Invoices/unknown", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"Type": null, "Title": "Resource Not Found"}`, http.StatusNotFound)
+	})
+
+	invoice, err := client.GetInvoiceByID(context.Background(), invoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := invoice.InvoiceNumber, "INV-0003"; got != want {
+		t.Errorf("got invoice number %s want %s", got, want)
+	}
+	if got, want := invoice.ContactID, "a871a956-05b5-4e2a-9419-7aeb478ca647"; got != want {
+		t.Errorf("got contact id %s want %s", got, want)
+	}
+	if got, want := len(invoice.LineItems), 1; got != want {
+		t.Fatalf("got %d line items want %d", got, want)
+	}
+	if got, want := invoice.LineItems[0].AccountCode, "200"; got != want {
+		t.Errorf("got line item account code %s want %s", got, want)
+	}
+	if invoice.Updated.IsZero() {
+		t.Error("expected the invoice updated time to be set")
+	}
+
+	if _, err := client.GetInvoiceByID(context.Background(), "unknown"); err == nil {
+		t.Error("expected an error for an unknown invoice")
+	}
+}
+
+// TestConnectionStatus verifies that successful calls record the rate limit headroom.
+func TestConnectionStatus(t *testing.T) {
+
+	mux, client, teardown := setup(t)
+	defer teardown()
+
+	jsonContent, err := os.ReadFile(filepath.Join("testdata", "organisations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.HandleFunc("/Organisation", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-MinLimit-Remaining", "57")
+		w.Header().Set("X-DayLimit-Remaining", "4321")
+		_, _ = w.Write(jsonContent)
+	})
+
+	before := time.Now()
+	if _, err := client.GetOrganisation(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status := client.ConnectionStatus()
+	if got, want := status.Account, "fake-tenant-id"; got != want {
+		t.Errorf("got account %s want %s", got, want)
+	}
+	if status.LastCall.Before(before) {
+		t.Errorf("last call %v not recorded", status.LastCall)
+	}
+	want := []apistatus.Limit{{Period: "minute", Remaining: 57, Max: 60}, {Period: "day", Remaining: 4321, Max: 5000}}
+	if !reflect.DeepEqual(status.Limits, want) {
+		t.Errorf("got limits %v want %v", status.Limits, want)
+	}
+}
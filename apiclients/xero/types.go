@@ -3,15 +3,30 @@ package xero
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
 )
 
-// xeroDateRegex is used to extract the milliseconds timestamp from Xero's custom date format.
-// Beware of inconsistent `\/` date escaping.
-var xeroDateRegex = regexp.MustCompile(`Date\((-?\d+)(?:[+-]\d+)?\)`)
+// xeroDateRegex is used to extract the milliseconds timestamp from Xero's custom date
+// format, the .NET json date, such as `/Date(1301880520783+0000)/`. The timestamp is
+// UTC, the optional offset only recording the timezone of the Xero organisation, and
+// may be negative for dates before 1970. Beware of inconsistent `\/` date escaping.
+var xeroDateRegex = regexp.MustCompile(`^\\?/?Date\((-?\d+)(?:[+-]\d{4})?\)\\?/?$`)
+
+// xeroDateLayouts are the layouts of the other date formats returned by Xero, such as
+// the DateString "2025-04-10T00:00:00" of invoices, in the order they are tried. Times
+// without an offset are UTC, and fractional seconds are accepted by each layout.
+var xeroDateLayouts = []string{
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	time.DateOnly,
+}
 
 // parseXeroDate converts a Xero /Date(1234...)/ string into a time.Time object.
 func parseXeroDate(xeroDate string) (time.Time, error) {
@@ -24,9 +39,17 @@ func parseXeroDate(xeroDate string) (time.Time, error) {
 	if err != nil {
 		return time.Time{}, fmt.Errorf("could not parse timestamp %q from xero date: %w", xeroDate, err)
 	}
+	return time.UnixMilli(timestamp).UTC(), nil
+}
 
-	// Xero provides milliseconds, time.Unix needs seconds.
-	return time.Unix(timestamp/1000, 0).UTC(), nil
+// parseXeroDateTime parses a date in any of the formats returned by Xero.
+func parseXeroDateTime(s string) (time.Time, error) {
+	for _, layout := range xeroDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return parseXeroDate(s)
 }
 
 // FlattenedName flattens an obj.Name string to a string.
@@ -52,30 +75,56 @@ func (fn *FlattenedName) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// XeroDateTime is a custom date type.
+// XeroDateTime is a custom date type. Invalid holds a value which could not be parsed,
+// in which case the time is zero.
 type XeroDateTime struct {
 	time.Time
+	Invalid string
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface, marshalling a Xero date into
-// a time.Time. The stated format falls back to a `/Date(1234...)/` date parser on
-// failure.
+// a time.Time. The layout formats are tried before the `/Date(1234...)/` date format. A
+// value which cannot be parsed is recorded in Invalid rather than failing the
+// unmarshalling of the whole response, so that it can be reported by DateWarnings.
 func (xdt *XeroDateTime) UnmarshalJSON(b []byte) error {
-	s := strings.Trim(string(b), `"`)
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		s = strings.Trim(string(b), `"`)
+	}
 	if s == "null" || s == "" {
 		return nil
 	}
-	t, err := time.Parse("2006-01-02T15:04:05", s)
+	t, err := parseXeroDateTime(s)
 	if err != nil {
-		t, err = parseXeroDate(s) // Xero custom format
-		if err != nil {
-			return err
-		}
+		xdt.Invalid = s
+		return nil
 	}
 	xdt.Time = t
 	return nil
 }
 
+// DateWarning reports a date of a record which is missing or could not be parsed, and
+// so is the zero time. Field is the json name of the date and Value the value which
+// could not be parsed, or empty if the date is missing.
+type DateWarning struct {
+	ID    string
+	Field string
+	Value string
+}
+
+// dateWarnings returns the warnings for the dates of the record with id, keyed by json
+// field name. Missing dates are only reported for the required fields.
+func dateWarnings(id string, dates map[string]XeroDateTime, required ...string) []DateWarning {
+	var warnings []DateWarning
+	for _, field := range slices.Sorted(maps.Keys(dates)) {
+		d := dates[field]
+		if d.Invalid != "" || (d.IsZero() && slices.Contains(required, field)) {
+			warnings = append(warnings, DateWarning{ID: id, Field: field, Value: d.Invalid})
+		}
+	}
+	return warnings
+}
+
 // Connection represents an organisation as it appears in the /connections endpoint.
 type Connection struct {
 	ID         string `json:"id"`
@@ -114,11 +163,14 @@ type BankTransaction struct {
 	Date              XeroDateTime `json:"DateString"`
 	Updated           XeroDateTime `json:"UpdatedDateUTC"`
 	Status            string       `json:"Status"`
-	Total             float64      `json:"Total"`
+	Total             money.Amount `json:"Total"`
+	CurrencyCode      string       `json:"CurrencyCode"`
+	CurrencyRate      float64      `json:"CurrencyRate"` // units of CurrencyCode per base currency unit
 	IsReconciled      bool         `json:"IsReconciled"`
 	LineItems         []LineItem   `json:"LineItems"`
 	// Fields promoted from the Contact and BankAccount json objects.
 	Contact       string `json:"-"`
+	ContactID     string `json:"-"`
 	BankAccountID string `json:"-"`
 	BankAccount   string `json:"-"`
 }
@@ -136,7 +188,8 @@ func (bt *BankTransaction) UnmarshalJSON(data []byte) error {
 		Name      string `json:"Name"`
 	}
 	type contactHelper struct {
-		Name string `json:"Name"`
+		ContactID string `json:"ContactID"`
+		Name      string `json:"Name"`
 	}
 
 	// Define an anonymous struct that includes all the fields of BankTransaction
@@ -159,19 +212,153 @@ func (bt *BankTransaction) UnmarshalJSON(data []byte) error {
 	bt.BankAccountID = helper.BankAccount.AccountID
 	bt.BankAccount = helper.BankAccount.Name
 	bt.Contact = helper.Contact.Name
+	bt.ContactID = helper.Contact.ContactID
 
 	return nil
 }
 
+// DateWarnings returns the warnings for the dates of the bank transaction.
+func (bt BankTransaction) DateWarnings() []DateWarning {
+	return dateWarnings(bt.BankTransactionID, map[string]XeroDateTime{
+		"DateString":     bt.Date,
+		"UpdatedDateUTC": bt.Updated,
+	}, "DateString")
+}
+
+// OverpaymentsResponse is the top-level structure of the /Overpayments API response.
+type OverpaymentsResponse struct {
+	Overpayments []Overpayment `json:"Overpayments"`
+}
+
+// Overpayment represents a payment received in excess of the amount due, such as a
+// donation paid to the wrong account. RemainingCredit is the amount not yet allocated
+// to invoices.
+type Overpayment struct {
+	OverpaymentID   string        `json:"OverpaymentID"`
+	Type            string        `json:"Type"`
+	Reference       string        `json:"Reference,omitempty"`
+	Contact         FlattenedName `json:"Contact"`
+	Date            XeroDateTime  `json:"DateString"`
+	Updated         XeroDateTime  `json:"UpdatedDateUTC"`
+	Status          string        `json:"Status"`
+	Total           money.Amount  `json:"Total"`
+	RemainingCredit money.Amount  `json:"RemainingCredit"`
+	CurrencyCode    string        `json:"CurrencyCode"`
+	CurrencyRate    float64       `json:"CurrencyRate"` // units of CurrencyCode per base currency unit
+	LineItems       []LineItem    `json:"LineItems"`
+	// Field promoted from the Contact json object.
+	ContactID string `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for an Overpayment,
+// additionally extracting the ContactID from the Contact json object.
+func (op *Overpayment) UnmarshalJSON(data []byte) error {
+	type Alias Overpayment
+	if err := json.Unmarshal(data, (*Alias)(op)); err != nil {
+		return err
+	}
+	contactID, err := unmarshalContactID(data)
+	op.ContactID = contactID
+	return err
+}
+
+// BankTransaction returns the overpayment as a bank transaction of its type,
+// RECEIVE-OVERPAYMENT, so that it is reconciled with the bank transactions.
+func (op Overpayment) BankTransaction() BankTransaction {
+	return BankTransaction{
+		BankTransactionID: op.OverpaymentID,
+		Type:              op.Type,
+		Reference:         op.Reference,
+		Date:              op.Date,
+		Updated:           op.Updated,
+		Status:            op.Status,
+		Total:             op.Total,
+		CurrencyCode:      op.CurrencyCode,
+		CurrencyRate:      op.CurrencyRate,
+		LineItems:         op.LineItems,
+		Contact:           string(op.Contact),
+		ContactID:         op.ContactID,
+	}
+}
+
+// PrepaymentsResponse is the top-level structure of the /Prepayments API response.
+type PrepaymentsResponse struct {
+	Prepayments []Prepayment `json:"Prepayments"`
+}
+
+// Prepayment represents a payment received before it is due, such as a donation
+// pledged for a later event. RemainingCredit is the amount not yet allocated to
+// invoices.
+type Prepayment struct {
+	PrepaymentID    string        `json:"PrepaymentID"`
+	Type            string        `json:"Type"`
+	Reference       string        `json:"Reference,omitempty"`
+	Contact         FlattenedName `json:"Contact"`
+	Date            XeroDateTime  `json:"DateString"`
+	Updated         XeroDateTime  `json:"UpdatedDateUTC"`
+	Status          string        `json:"Status"`
+	Total           money.Amount  `json:"Total"`
+	RemainingCredit money.Amount  `json:"RemainingCredit"`
+	CurrencyCode    string        `json:"CurrencyCode"`
+	CurrencyRate    float64       `json:"CurrencyRate"` // units of CurrencyCode per base currency unit
+	LineItems       []LineItem    `json:"LineItems"`
+	// Field promoted from the Contact json object.
+	ContactID string `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for a Prepayment,
+// additionally extracting the ContactID from the Contact json object.
+func (pp *Prepayment) UnmarshalJSON(data []byte) error {
+	type Alias Prepayment
+	if err := json.Unmarshal(data, (*Alias)(pp)); err != nil {
+		return err
+	}
+	contactID, err := unmarshalContactID(data)
+	pp.ContactID = contactID
+	return err
+}
+
+// BankTransaction returns the prepayment as a bank transaction of its type,
+// RECEIVE-PREPAYMENT, so that it is reconciled with the bank transactions.
+func (pp Prepayment) BankTransaction() BankTransaction {
+	return BankTransaction{
+		BankTransactionID: pp.PrepaymentID,
+		Type:              pp.Type,
+		Reference:         pp.Reference,
+		Date:              pp.Date,
+		Updated:           pp.Updated,
+		Status:            pp.Status,
+		Total:             pp.Total,
+		CurrencyCode:      pp.CurrencyCode,
+		CurrencyRate:      pp.CurrencyRate,
+		LineItems:         pp.LineItems,
+		Contact:           string(pp.Contact),
+		ContactID:         pp.ContactID,
+	}
+}
+
+// unmarshalContactID returns the ContactID of the Contact json object of a record.
+func unmarshalContactID(data []byte) (string, error) {
+	var contact struct {
+		Contact struct {
+			ContactID string `json:"ContactID"`
+		} `json:"Contact"`
+	}
+	if err := json.Unmarshal(data, &contact); err != nil {
+		return "", err
+	}
+	return contact.Contact.ContactID, nil
+}
+
 // LineItem represents a single line in a transaction or invoice, crucial for splits.
 type LineItem struct {
-	Description string  `json:"Description"`
-	UnitAmount  float64 `json:"UnitAmount"`
-	AccountCode string  `json:"AccountCode"`
-	LineItemID  string  `json:"LineItemID"`
-	Quantity    float64 `json:"Quantity"`
-	TaxAmount   float64 `json:"TaxAmount"`
-	LineAmount  float64 `json:"LineAmount"`
+	Description string       `json:"Description"`
+	UnitAmount  money.Amount `json:"UnitAmount"`
+	AccountCode string       `json:"AccountCode"`
+	LineItemID  string       `json:"LineItemID"`
+	Quantity    float64      `json:"Quantity"`
+	TaxAmount   money.Amount `json:"TaxAmount"`
+	LineAmount  money.Amount `json:"LineAmount"`
 }
 
 // InvoiceResponse is the top-level structure of the /Invoices API response.
@@ -189,9 +376,43 @@ type Invoice struct {
 	Updated       XeroDateTime  `json:"UpdatedDateUTC"`
 	Status        string        `json:"Status"`
 	Reference     string        `json:"Reference,omitempty"`
-	Total         float64       `json:"Total"`
-	AmountPaid    float64       `json:"AmountPaid"`
+	Total         money.Amount  `json:"Total"`
+	AmountPaid    money.Amount  `json:"AmountPaid"`
+	CurrencyCode  string        `json:"CurrencyCode"`
+	CurrencyRate  float64       `json:"CurrencyRate"` // units of CurrencyCode per base currency unit
 	LineItems     []LineItem    `json:"LineItems"`
+	// Field promoted from the Contact json object.
+	ContactID string `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for an Invoice, additionally
+// extracting the ContactID from the Contact json object.
+func (inv *Invoice) UnmarshalJSON(data []byte) error {
+
+	// type alias to stop recursion.
+	type Alias Invoice
+
+	var contact struct {
+		Contact struct {
+			ContactID string `json:"ContactID"`
+		} `json:"Contact"`
+	}
+	if err := json.Unmarshal(data, (*Alias)(inv)); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &contact); err != nil {
+		return err
+	}
+	inv.ContactID = contact.Contact.ContactID
+	return nil
+}
+
+// DateWarnings returns the warnings for the dates of the invoice.
+func (inv Invoice) DateWarnings() []DateWarning {
+	return dateWarnings(inv.InvoiceID, map[string]XeroDateTime{
+		"DateString":     inv.Date,
+		"UpdatedDateUTC": inv.Updated,
+	}, "DateString")
 }
 
 // AccountResponse is the top-level structure of the /Accounts API response.
@@ -222,7 +443,9 @@ type Account struct {
 }
 
 // UnmarshalJSON provides custom JSON decoding for the Account type.
-// It parses Xero's specific date formats into standard time.Time objects.
+// It parses Xero's specific date formats into standard time.Time objects. An
+// UpdatedDateUTC which cannot be parsed leaves Updated as the zero time, and is
+// reported by DateWarnings.
 func (acc *Account) UnmarshalJSON(data []byte) error {
 	type accountAlias Account
 	alias := &accountAlias{}
@@ -232,12 +455,40 @@ func (acc *Account) UnmarshalJSON(data []byte) error {
 	}
 	*acc = Account(*alias)
 
-	var err error
 	if acc.UpdatedDateUTC != "" {
-		acc.Updated, err = parseXeroDate(acc.UpdatedDateUTC)
-		if err != nil {
-			return fmt.Errorf("failed to parse Account.UpdatedDateUTC: %w", err)
-		}
+		acc.Updated, _ = parseXeroDateTime(acc.UpdatedDateUTC)
 	}
 	return nil
 }
+
+// DateWarnings returns the warnings for the dates of the account.
+func (acc Account) DateWarnings() []DateWarning {
+	if acc.UpdatedDateUTC != "" && acc.Updated.IsZero() {
+		return []DateWarning{{ID: acc.AccountID, Field: "UpdatedDateUTC", Value: acc.UpdatedDateUTC}}
+	}
+	return nil
+}
+
+// ContactsResponse is the top-level structure of the /Contacts API response.
+type ContactsResponse struct {
+	Contacts []Contact `json:"Contacts"`
+}
+
+// Contact represents a Xero contact, such as a donor or payment platform. This is a
+// partial marshalling of the available data only.
+type Contact struct {
+	ContactID     string       `json:"ContactID"`
+	ContactStatus string       `json:"ContactStatus"`
+	Name          string       `json:"Name"`
+	FirstName     string       `json:"FirstName"`
+	LastName      string       `json:"LastName"`
+	EmailAddress  string       `json:"EmailAddress"`
+	IsSupplier    bool         `json:"IsSupplier"`
+	IsCustomer    bool         `json:"IsCustomer"`
+	Updated       XeroDateTime `json:"UpdatedDateUTC"`
+}
+
+// DateWarnings returns the warnings for the dates of the contact.
+func (c Contact) DateWarnings() []DateWarning {
+	return dateWarnings(c.ContactID, map[string]XeroDateTime{"UpdatedDateUTC": c.Updated})
+}
@@ -2,25 +2,100 @@ package xero
 
 import (
 	"encoding/json"
-	"fmt"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestParseXeroDate(t *testing.T) {
-	dateTimes := []string{
-		`/Date(1301880520783+0000)/`,
-		`/Date(1767010099219)/`,
-		`\/Date(1770855011934)\/`,
+	tests := []struct {
+		date string
+		want time.Time
+	}{
+		{`/Date(1301880520783+0000)/`, time.Date(2011, 4, 4, 1, 28, 40, 783000000, time.UTC)},
+		{`/Date(1301880520783+1300)/`, time.Date(2011, 4, 4, 1, 28, 40, 783000000, time.UTC)},
+		{`/Date(1767010099219)/`, time.Date(2025, 12, 29, 12, 8, 19, 219000000, time.UTC)},
+		{`\/Date(1770855011934)\/`, time.Date(2026, 2, 12, 0, 10, 11, 934000000, time.UTC)},
+		{`/Date(-86400000)/`, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
 	}
-	for _, dt := range dateTimes {
-		parsedDate, err := parseXeroDate(dt)
+	for _, tt := range tests {
+		got, err := parseXeroDate(tt.date)
 		if err != nil {
-			t.Errorf("date string %q could not be parsed", dt)
-		} else {
-			fmt.Println(parsedDate)
+			t.Errorf("date string %q could not be parsed: %v", tt.date, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("date string %q got %s want %s", tt.date, got, tt.want)
 		}
 	}
+	for _, invalid := range []string{`/Date()/`, `Date(12x)`, `/Date(1301880520783+00)/`} {
+		if _, err := parseXeroDate(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestXeroDateTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    time.Time
+		invalid string
+	}{
+		{"date string", `"2025-04-10T00:00:00"`, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), ""},
+		{"fractional", `"2025-04-10T09:30:15.123"`, time.Date(2025, 4, 10, 9, 30, 15, 123000000, time.UTC), ""},
+		{"rfc3339", `"2025-04-10T09:30:15+01:00"`, time.Date(2025, 4, 10, 8, 30, 15, 0, time.UTC), ""},
+		{"date only", `"2025-04-10"`, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), ""},
+		{"dotnet", `"/Date(1744243200000+0000)/"`, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), ""},
+		{"dotnet escaped", `"\/Date(1744243200000)\/"`, time.Date(2025, 4, 10, 0, 0, 0, 0, time.UTC), ""},
+		{"null", `null`, time.Time{}, ""},
+		{"empty", `""`, time.Time{}, ""},
+		{"invalid", `"10/04/2025"`, time.Time{}, "10/04/2025"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got XeroDateTime
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Invalid != tt.invalid {
+				t.Errorf("got %s %q want %s %q", got.Time, got.Invalid, tt.want, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestDateWarnings(t *testing.T) {
+	var inv Invoice
+	data := `{"InvoiceID":"inv-1","DateString":"","UpdatedDateUTC":"yesterday"}`
+	if err := json.Unmarshal([]byte(data), &inv); err != nil {
+		t.Fatal(err)
+	}
+	want := []DateWarning{
+		{ID: "inv-1", Field: "DateString"},
+		{ID: "inv-1", Field: "UpdatedDateUTC", Value: "yesterday"},
+	}
+	if diff := cmp.Diff(want, inv.DateWarnings()); diff != "" {
+		t.Errorf("invoice warnings mismatch (-want +got):\n%s", diff)
+	}
+
+	var contact Contact
+	if err := json.Unmarshal([]byte(`{"ContactID":"c-1"}`), &contact); err != nil {
+		t.Fatal(err)
+	}
+	if got := contact.DateWarnings(); len(got) != 0 {
+		t.Errorf("unexpected contact warnings %v", got)
+	}
+
+	var acc Account
+	if err := json.Unmarshal([]byte(`{"AccountID":"a-1","UpdatedDateUTC":"/Date(x)/"}`), &acc); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := acc.DateWarnings(), []DateWarning{{ID: "a-1", Field: "UpdatedDateUTC", Value: "/Date(x)/"}}; !cmp.Equal(got, want) {
+		t.Errorf("account warnings got %v want %v", got, want)
+	}
 }
 
 func TestAccountsType(t *testing.T) {
@@ -49,6 +124,9 @@ func TestBankTransactionsType(t *testing.T) {
 	if got, want := len(bt.BankTransactions), 29; got != want {
 		t.Errorf("got %d bank transactions, want %d", got, want)
 	}
+	if got, want := bt.BankTransactions[0].CurrencyCode, "GBP"; got != want {
+		t.Errorf("got currency code %q, want %q", got, want)
+	}
 }
 
 func TestInvoicesType(t *testing.T) {
@@ -63,6 +141,15 @@ func TestInvoicesType(t *testing.T) {
 	if got, want := len(i.Invoices), 88; got != want {
 		t.Errorf("got %d invoices, want %d", got, want)
 	}
+	if got, want := string(i.Invoices[0].Contact), "PowerDirect"; got != want {
+		t.Errorf("got contact %q, want %q", got, want)
+	}
+	if got, want := i.Invoices[0].ContactID, "dec56ceb-65e9-43b3-ac98-7fe09eb37e31"; got != want {
+		t.Errorf("got contact id %q, want %q", got, want)
+	}
+	if got, want := i.Invoices[0].CurrencyRate, 1.0; got != want {
+		t.Errorf("got currency rate %f, want %f", got, want)
+	}
 }
 
 func TestOrganisationsType(t *testing.T) {
@@ -78,3 +165,17 @@ func TestOrganisationsType(t *testing.T) {
 		t.Errorf("got %d organisations, want %d", got, want)
 	}
 }
+
+func TestContactsType(t *testing.T) {
+	b, err := os.ReadFile("testdata/contacts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c ContactsResponse
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(c.Contacts), 45; got != want {
+		t.Errorf("got %d contacts, want %d", got, want)
+	}
+}
@@ -9,12 +9,16 @@ import (
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net/http"
 	"path/filepath"
 
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/db"
 	"github.com/rorycl/reconciler/domain"
 	"github.com/rorycl/reconciler/internal/filewatcher"
+	"github.com/rorycl/reconciler/internal/logfile"
+	"github.com/rorycl/reconciler/internal/mockapi"
+	"github.com/rorycl/reconciler/internal/money"
 	mounts "github.com/rorycl/reconciler/internal/mounts"
 	"github.com/rorycl/reconciler/web"
 )
@@ -22,21 +26,32 @@ import (
 // App encapsulates a reconciler and associated infrastructure elements for use by
 // command programs.
 type App struct {
+	configFile string
 	cfg        *config.Config
 	log        *slog.Logger
+	logLevel   *slog.LevelVar // the log file level, reloaded from the config file
+	db         *db.DB
 	reconciler *domain.Reconciler
 	staticFS   fs.FS
 	templateFS fs.FS
 	sqlFS      fs.FS
 
+	// reopen makes a new App for a profile of the config file, with the same arguments
+	// as this App. stop stops the file watchers of this App.
+	reopen func(profile string) (*App, error)
+	stop   context.CancelFunc
+
 	// development fields
 	inDevelopment bool
 	watcher       <-chan error
+	sqlWatcher    <-chan error
 }
 
-// NewApp initialises a new App.
+// NewApp initialises a new App using the named profile of the config file, or the
+// default profile if profile is empty.
 func NewApp(
 	configFile string,
+	profile string,
 	logger *slog.Logger,
 	inDevelopment bool,
 	staticPath string,
@@ -63,13 +78,39 @@ func NewApp(
 		}
 	}
 
+	// reopen makes an App with these arguments for another profile.
+	reopen := func(profile string) (*App, error) {
+		return NewApp(configFile, profile, logger, inDevelopment, staticPath, templatePath, sqlPath, databasePath)
+	}
+
 	// Load the configuration file.
-	cfg, err := config.Load(configFile)
+	cfg, err := config.LoadProfile(configFile, profile)
 	if err != nil {
 		return nil, fmt.Errorf("could not load configuration file: %w", err)
 	}
 	accountCodes := cfg.DonationAccountCodesRegex()
 
+	// The database of the profile is used in development mode. In production each
+	// profile has its own in-memory database, so that the data of one profile is never
+	// seen in another.
+	dbPath := databasePath
+	if inDevelopment && cfg.Database.Path != "" {
+		dbPath = cfg.Database.Path
+	}
+	if !inDevelopment {
+		dbPath = db.MemoryPath(cfg.Profile)
+	}
+
+	// Write the log to the log file, if configured, as well as to the console.
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.Logging.Level)
+	if cfg.Logging.Enabled() {
+		logger, err = withLogFile(logger, cfg.Logging, logLevel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Mount the filesystems.
 	staticFS, err := mounts.NewFileMount("static", web.StaticEmbeddedFS, staticPath)
 	if err != nil {
@@ -79,60 +120,147 @@ func NewApp(
 	if err != nil {
 		return nil, fmt.Errorf("could not mount template file system: %w", err)
 	}
-	sqlFS, err := mounts.NewFileMount("sql", db.SQLEmbeddedFS, sqlPath)
+	// The sql directory given in development replaces the embedded sql files; the
+	// configured override directory shadows individual embedded files.
+	var sqlFS *mounts.FileMount
+	if sqlPath != "" {
+		sqlFS, err = mounts.NewFileMount("sql", db.SQLEmbeddedFS, sqlPath)
+	} else {
+		sqlFS, err = mounts.NewOverlayMount("sql", db.SQLEmbeddedFS, cfg.Database.SQLOverrideDir)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("could not mount template file system: %w", err)
+		return nil, fmt.Errorf("could not mount sql file system: %w", err)
+	}
+	for _, name := range sqlFS.Overrides() {
+		logger.Info(fmt.Sprintf("using sql override %s", filepath.Join(cfg.Database.SQLOverrideDir, name)))
 	}
 
 	// Initialise the database connection.
-	dbCon, err := db.NewConnection(databasePath, sqlFS, accountCodes, logger)
+	dbCon, err := db.NewConnection(dbPath, sqlFS, accountCodes, logger)
 	if err != nil {
 		return nil, fmt.Errorf("could not initialise database: %w", err)
 	}
+	if cfg.Database.ExplainQueries {
+		if err := dbCon.LogQueryPlans(context.Background()); err != nil {
+			return nil, fmt.Errorf("could not explain queries: %w", err)
+		}
+	}
+	dbCon.SetSlowQueryThreshold(cfg.Database.SlowQueryThreshold)
+	dbCon.SetStatementTimeout(cfg.Database.StatementTimeout)
+	dbCon.SetCacheTTL(cfg.Database.CacheTTL)
+	if rc := cfg.Reconciliation; rc != (config.ReconciliationConfig{}) {
+		amount, err := money.ParseFloat(rc.ToleranceAmount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reconciliation tolerance: %w", err)
+		}
+		tolerance := db.Tolerance{
+			Amount:  amount,
+			Percent: rc.TolerancePercent,
+		}
+		if err := dbCon.ToleranceUpsert(context.Background(), tolerance); err != nil {
+			return nil, fmt.Errorf("could not set reconciliation tolerance: %w", err)
+		}
+	}
 
 	// Construct the reconciler
 	reconciler := domain.NewReconciler(dbCon, logger)
 
 	app := &App{
+		configFile:    configFile,
 		cfg:           cfg,
 		log:           logger,
+		logLevel:      logLevel,
+		db:            dbCon,
 		inDevelopment: inDevelopment,
 		reconciler:    reconciler,
 		staticFS:      staticFS,
 		templateFS:    templateFS,
 		sqlFS:         sqlFS,
+		reopen:        reopen,
+		stop:          func() {},
 	}
 
 	// Register filewatchers if in development, for automatic reloading.
 	if inDevelopment {
+		var ctx context.Context
+		ctx, app.stop = context.WithCancel(context.Background())
 		watcher, err := filewatcher.NewFileChangeNotifier(
-			context.Background(),
+			ctx,
 			map[string][]string{
 				filepath.Join(staticPath, "css"): {".css"},
 				filepath.Join(staticPath, "js"):  {".js"},
 				templatePath:                     {".html"},
-				sqlPath:                          {".sql"},
 			},
 		)
 		if err != nil {
 			return nil, fmt.Errorf("file watcher error: %v", err)
 		}
 		app.watcher = watcher.Update()
+
+		// SQL changes are watched separately as they require the database statements
+		// to be prepared again.
+		sqlWatcher, err := filewatcher.NewFileChangeNotifier(
+			ctx,
+			map[string][]string{sqlPath: {".sql"}},
+		)
+		if err != nil {
+			return nil, fmt.Errorf("sql file watcher error: %v", err)
+		}
+		app.sqlWatcher = sqlWatcher.Update()
 	}
 
 	return app, nil
 
 }
 
-// RunWebServer configures and launches the web server.
+// RunWebServer configures and launches the web server. When another profile is chosen
+// in the web interface the server is run again with an App for that profile.
 func (a *App) RunWebServer() error {
+	for {
+		next, err := a.runWebServer()
+		if next == nil {
+			return err
+		}
+		a = next
+	}
+}
+
+// runWebServer runs the web server until it fails, or until it is shut down to switch
+// profile, in which case the App of the new profile is returned.
+func (a *App) runWebServer() (*App, error) {
+
+	// In mock mode the api calls are served with canned data, which requires the
+	// configured OAuth2 login pages to be replaced.
+	var mockAPIs *mockapi.Server
+	if a.cfg.MockAPIs {
+		var err error
+		mockAPIs, err = mockapi.New()
+		if err != nil {
+			return nil, fmt.Errorf("could not initialise mock apis: %w", err)
+		}
+		mockAPIs.Configure(a.cfg)
+	}
 
 	// Configure and launch the web server. This uses the default xero and salesforce
 	// Clients.
 	webApp, err := web.New(a.cfg, a.reconciler, a.log, a.staticFS, a.templateFS, nil, nil)
 	if err != nil {
 		a.log.Error(fmt.Sprintf("app web server init error: %v", err))
-		return fmt.Errorf("could not initialise web server: %w", err)
+		return nil, fmt.Errorf("could not initialise web server: %w", err)
+	}
+	if mockAPIs != nil {
+		webApp.SetMockAPIs(mockAPIs)
+	}
+
+	// Reload the settings which are safe to change, such as the data start date, when
+	// the config file is written.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloader := config.NewReloader(a.configFile, a.cfg, a.log)
+	reloader.Subscribe(webApp)
+	reloader.Subscribe(config.SubscriberFunc(a.settingsChanged))
+	if err := reloader.Watch(ctx); err != nil {
+		a.log.Warn(fmt.Sprintf("config changes will not be reloaded: %v", err))
 	}
 
 	// If inDevelopment mode, set the webapp in development, and run the file watcher in
@@ -155,9 +283,75 @@ func (a *App) RunWebServer() error {
 				webApp.RestartRoutes()
 			}
 		}()
+
+		go func() {
+			for err := range a.sqlWatcher {
+				if err != nil {
+					a.log.Error(fmt.Sprintf("sql file watcher error: %v", err))
+					a.log.Error("sql file watching has stopped")
+					return
+				}
+				a.log.Info("sql file watcher detected a file change; reloading sql statements")
+				webApp.ReloadSQL()
+			}
+		}()
+	}
+
+	// Offer the other profiles of the config file. Switching shuts the server down.
+	switched := make(chan string, 1)
+	if profiles := a.cfg.ProfileNames(); len(profiles) > 0 {
+		webApp.SetProfiles(profiles, a.cfg.Profile, func(profile string) {
+			switched <- profile
+			if err := webApp.Shutdown(context.Background()); err != nil {
+				a.log.Error(fmt.Sprintf("web server shutdown error: %v", err))
+			}
+		})
 	}
 
 	// Start the server.
-	return webApp.StartServer()
+	err = webApp.StartServer()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return nil, err
+	}
+	var profile string
+	select {
+	case profile = <-switched:
+	default:
+		return nil, err
+	}
+
+	// Close this App and open one for the new profile.
+	a.stop()
+	if err := a.reconciler.Close(); err != nil {
+		a.log.Warn(fmt.Sprintf("database close error: %v", err))
+	}
+	next, err := a.reopen(profile)
+	if err != nil {
+		return nil, fmt.Errorf("could not switch to profile %s: %w", profile, err)
+	}
+	next.log.Info(fmt.Sprintf("switched to profile %s", profile))
+	return next, nil
+}
+
+// settingsChanged applies the settings reloaded from the config file to the database
+// and the log file.
+func (a *App) settingsChanged(s config.Settings) {
+	a.db.SetAccountCodes(s.AccountCodes)
+	a.logLevel.Set(s.LogLevel)
+}
 
+// withLogFile returns a logger writing to both logger and the configured log file, with
+// the messages of the log file limited to level. The log file is left open for the
+// life of the program.
+func withLogFile(logger *slog.Logger, cfg config.LogConfig, level slog.Leveler) (*slog.Logger, error) {
+	file, err := logfile.Open(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log file: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var fileHandler slog.Handler = slog.NewJSONHandler(file, opts)
+	if cfg.Format == "text" {
+		fileHandler = slog.NewTextHandler(file, opts)
+	}
+	return slog.New(slog.NewMultiHandler(logger.Handler(), fileHandler)), nil
 }
@@ -1,12 +1,21 @@
 package app
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 func TestAppInit(t *testing.T) {
@@ -81,6 +90,7 @@ func TestAppInit(t *testing.T) {
 			programLevel.Set(tt.logLevel)
 			_, err := NewApp(
 				tt.configFile,
+				"",
 				logger,
 				tt.inDevelopment,
 				tt.staticPath,
@@ -98,3 +108,95 @@ func TestAppInit(t *testing.T) {
 		})
 	}
 }
+
+// TestProfileDatabases tests that each profile has its own in-memory database in
+// production, so that the data of one profile is not seen in another.
+func TestProfileDatabases(t *testing.T) {
+
+	example, err := os.ReadFile("../config/config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	profiles := "\nprofiles:\n  a:\n    organisation_name: \"Profile A\"\n  b:\n    organisation_name: \"Profile B\"\n"
+	if err := os.WriteFile(configFile, append(example, profiles...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	a, err := NewApp(configFile, "a", logger, false, "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.db.Close() })
+	ctx := context.Background()
+	date := time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC)
+	lines := []db.StatementLine{{LineRef: "L-A", Date: date, Amount: money.FromFloat(10)}}
+	if _, err := a.db.StatementLinesUpsert(ctx, "Current Account", lines); err != nil {
+		t.Fatal(err)
+	}
+
+	// Profile A is still open, so its database is not dropped.
+	b, err := a.reopen("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.db.Close() })
+	got, err := b.db.StatementLinesGet(ctx, date, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d statement lines of profile a in profile b want 0", len(got))
+	}
+	got, err = a.db.StatementLinesGet(ctx, date, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("got %d statement lines in profile a want 1", len(got))
+	}
+}
+
+// TestWithLogFile tests writing the log to both the console and the log file.
+func TestWithLogFile(t *testing.T) {
+
+	console := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(console, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	path := filepath.Join(t.TempDir(), "reconciler.log")
+	cfg := config.LogConfig{File: path, Level: slog.LevelInfo, Format: "json", MaxSizeMB: 1, MaxBackups: 1}
+	level := new(slog.LevelVar)
+	level.Set(cfg.Level)
+	logger, err := withLogFile(logger, cfg, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("debug message")
+	logger.Info("info message", "request_id", "abc")
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), `"msg":"info message","request_id":"abc"`) {
+		t.Errorf("log file does not contain the json info message: %s", got)
+	}
+	if strings.Contains(string(got), "debug message") {
+		t.Errorf("log file contains the debug message: %s", got)
+	}
+	if !strings.Contains(console.String(), "debug message") {
+		t.Errorf("console does not contain the debug message: %s", console)
+	}
+
+	// The log file level may be changed, as when reloaded from the config file.
+	level.Set(slog.LevelDebug)
+	logger.Debug("reloaded debug message")
+	got, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "reloaded debug message") {
+		t.Errorf("log file does not contain the reloaded debug message: %s", got)
+	}
+}
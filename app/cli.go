@@ -0,0 +1,525 @@
+package app
+
+// cli.go provides the command line subcommands, which allow the reconciler to be used
+// from scripts and scheduled jobs without a browser. As the database is in memory,
+// each command first syncs the records from Xero and Salesforce. OAuth2 tokens are
+// saved to a token file by the login command so that they can be reused.
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+	"github.com/rorycl/reconciler/internal/token"
+	"github.com/rorycl/reconciler/internal/tui"
+	"github.com/rorycl/reconciler/reports"
+	"golang.org/x/oauth2"
+)
+
+// loginPollInterval is the interval at which the token store is checked for a new
+// token during a command line login.
+const loginPollInterval = 500 * time.Millisecond
+
+// ListOptions are the filters for the list command.
+type ListOptions struct {
+	Kind   string // invoices, transactions or donations
+	Status string // All, Reconciled or NotReconciled; All, Linked or NotLinked for donations
+	From   time.Time
+	To     time.Time
+	Search string
+	Limit  int // -1 for no limit
+	CSV    bool
+}
+
+// ExportOptions are the options for the export command.
+type ExportOptions struct {
+	Kind   string // report or gift-aid
+	From   time.Time
+	To     time.Time
+	Format string // pdf for reports; ods or csv for gift-aid
+}
+
+// SeedOptions are the options for the seed command. Unset dates default to the data
+// start date and today.
+type SeedOptions struct {
+	From             time.Time
+	To               time.Time
+	Invoices         int
+	BankTransactions int
+	Donations        int
+	LinkedPercent    int
+	Seed             uint64
+}
+
+// profileTokenFile returns the token file of the profile of the App. The tokens of a
+// profile are saved alongside those of the base configuration, with the profile name
+// added before the file extension, as each profile connects to its own organisations.
+func (a *App) profileTokenFile(tokenFile string) string {
+	if a.cfg.Profile == "" {
+		return tokenFile
+	}
+	ext := filepath.Ext(tokenFile)
+	return strings.TrimSuffix(tokenFile, ext) + "-" + a.cfg.Profile + ext
+}
+
+// commandService returns a tuiService for the command line subcommands, with tokens
+// saved in the profile token file of tokenFile.
+func (a *App) commandService(tokenFile string) (*tuiService, *token.FileStore, error) {
+	store, err := token.NewFileStore(a.profileTokenFile(tokenFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	svc, err := newTUIService(a.cfg, a.log, a.reconciler, store)
+	if err != nil {
+		return nil, nil, err
+	}
+	return svc, store, nil
+}
+
+// periodDates returns from and to, defaulting to the data start date and today.
+func (a *App) periodDates(from, to time.Time) (time.Time, time.Time) {
+	if from.IsZero() {
+		from = a.cfg.DataStartDate
+	}
+	if to.IsZero() {
+		to = a.cfg.Today()
+	}
+	return from, to
+}
+
+// syncService returns a tuiService after syncing the records from Xero and Salesforce.
+func (a *App) syncService(ctx context.Context, tokenFile string) (*tuiService, string, error) {
+	svc, store, err := a.commandService(tokenFile)
+	if err != nil {
+		return nil, "", err
+	}
+	msg, err := svc.Refresh(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("sync failed: %w (try the login command)", err)
+	}
+	// Refreshed tokens are saved to the token file.
+	if err := store.Err(); err != nil {
+		return nil, "", err
+	}
+	return svc, msg, nil
+}
+
+// Login runs the OAuth2 login for provider ("xero" or "salesforce"), saving the token
+// to tokenFile. A web server is run on the configured listen address to handle the
+// login, which must be completed in a browser.
+func (a *App) Login(ctx context.Context, w io.Writer, tokenFile, provider string) error {
+
+	var typer token.TokenType
+	switch provider {
+	case "xero":
+		typer = token.XeroToken
+	case "salesforce":
+		typer = token.SalesforceToken
+	default:
+		return fmt.Errorf("invalid login provider %q, expected 'xero' or 'salesforce'", provider)
+	}
+
+	svc, store, err := a.commandService(tokenFile)
+	if err != nil {
+		return err
+	}
+	// Remove any existing token so that the new login is detected.
+	store.Remove(ctx, typer.SessionName())
+
+	server := &http.Server{
+		Addr:              a.cfg.Web.ListenAddress,
+		Handler:           svc.loginHandler(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	defer func() {
+		_ = server.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(w, "Open http://%s/%s/init in a browser to log in to %s.\n", a.cfg.Web.ListenAddress, typer, typer)
+
+	ticker := time.NewTicker(loginPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-serverErr:
+			return fmt.Errorf("login server error: %w", err)
+		case <-ticker.C:
+			if _, ok := store.ExtendedToken(ctx, typer); !ok {
+				continue
+			}
+			if err := store.Err(); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Logged in to %s. The token is saved in %s.\n", typer, store.Path())
+			return nil
+		}
+	}
+}
+
+// Logout revokes the saved token of provider ("xero" or "salesforce") with the
+// provider and removes it from tokenFile. The token is removed even if the provider
+// cannot revoke it, in which case the error is reported after removal.
+func (a *App) Logout(ctx context.Context, w io.Writer, tokenFile, provider string) error {
+
+	var typer token.TokenType
+	var oauthCfg *oauth2.Config
+	switch provider {
+	case "xero":
+		typer, oauthCfg = token.XeroToken, a.cfg.Xero.OAuth2Config
+	case "salesforce":
+		typer, oauthCfg = token.SalesforceToken, a.cfg.Salesforce.OAuth2Config
+	default:
+		return fmt.Errorf("invalid logout provider %q, expected 'xero' or 'salesforce'", provider)
+	}
+
+	store, err := token.NewFileStore(a.profileTokenFile(tokenFile))
+	if err != nil {
+		return err
+	}
+	et, ok := store.ExtendedToken(ctx, typer)
+	if !ok {
+		fmt.Fprintf(w, "Not logged in to %s.\n", typer)
+		return nil
+	}
+	revokeErr := et.Revoke(ctx, oauthCfg)
+	store.Remove(ctx, typer.SessionName())
+	if err := store.Err(); err != nil {
+		return err
+	}
+	if revokeErr != nil {
+		return fmt.Errorf("the %s token was removed from %s but could not be revoked: %w", typer, store.Path(), revokeErr)
+	}
+	fmt.Fprintf(w, "Logged out of %s. The token is revoked and removed from %s.\n", typer, store.Path())
+	return nil
+}
+
+// Sync retrieves the Xero and Salesforce records, reporting the number retrieved. This
+// checks that the saved tokens are valid and refreshes them if necessary.
+func (a *App) Sync(ctx context.Context, w io.Writer, tokenFile string) error {
+	_, msg, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, msg)
+	return nil
+}
+
+// List writes the invoices, bank transactions or donations matching opts to w as a
+// table or as csv.
+func (a *App) List(ctx context.Context, w io.Writer, tokenFile string, opts ListOptions) error {
+
+	switch opts.Kind {
+	case "invoices", "transactions", "donations":
+	default:
+		return fmt.Errorf("invalid list type %q, expected 'invoices', 'transactions' or 'donations'", opts.Kind)
+	}
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	from, to := a.periodDates(opts.From, opts.To)
+
+	var header []string
+	var rows [][]string
+
+	switch opts.Kind {
+	case "invoices":
+		status := cmp.Or(opts.Status, "All")
+		invoices, err := a.reconciler.InvoicesGet(ctx, status, from, to, opts.Search, "", db.SortOrder{}, opts.Limit, 0)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return svc.userError(err)
+		}
+		header = []string{"ID", "Number", "Date", "Contact", "Status", "Total", "Donations", "CRMS", "Reconciled"}
+		for _, i := range invoices {
+			rows = append(rows, []string{
+				i.InvoiceID, i.InvoiceNumber, i.Date.Format("2006-01-02"), i.Contact, i.Status,
+				i.Total.String(), i.DonationTotal.String(), i.CRMSTotal.String(), strconv.FormatBool(i.IsReconciled),
+			})
+		}
+	case "transactions":
+		status := cmp.Or(opts.Status, "All")
+		transactions, err := a.reconciler.TransactionsGet(ctx, status, from, to, opts.Search, "", db.SortOrder{}, opts.Limit, 0)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return svc.userError(err)
+		}
+		header = []string{"ID", "Reference", "Date", "Contact", "Status", "Total", "Donations", "CRMS", "Reconciled"}
+		for _, bt := range transactions {
+			rows = append(rows, []string{
+				bt.ID, bt.Reference, bt.Date.Format("2006-01-02"), bt.Contact, bt.Status,
+				bt.Total.String(), bt.DonationTotal.String(), bt.CRMSTotal.String(), strconv.FormatBool(bt.IsReconciled),
+			})
+		}
+	case "donations":
+		status := cmp.Or(opts.Status, "All")
+		donations, err := a.reconciler.DonationsGet(ctx, from, to, status, "", opts.Search, "", db.SortOrder{}, opts.Limit, 0)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return svc.userError(err)
+		}
+		header = []string{"ID", "Name", "Close Date", "Amount", "Payout Reference", "Linked", "Link Type", "Link ID"}
+		for _, d := range donations {
+			var payout string
+			if d.PayoutReference != nil {
+				payout = fmt.Sprint(d.PayoutReference)
+			}
+			rows = append(rows, []string{
+				d.ID, d.Name, d.CloseDateStr, d.Amount.String(), payout,
+				strconv.FormatBool(d.IsLinked), d.LinkTyper, d.LinkID,
+			})
+		}
+	}
+
+	if opts.CSV {
+		cw := csvsafe.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// Link links the donations to the invoice or bank transaction (recordType is
+// "invoice" or "bank-transaction") with recordID in Salesforce.
+func (a *App) Link(ctx context.Context, w io.Writer, tokenFile, recordType, recordID string, donationIDs []string) error {
+
+	if recordType != "invoice" && recordType != "bank-transaction" {
+		return fmt.Errorf("invalid record type %q, expected 'invoice' or 'bank-transaction'", recordType)
+	}
+	if err := salesforce.IDsValid(donationIDs...); err != nil {
+		return err
+	}
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	if err := svc.Link(ctx, tui.Item{Type: recordType, ID: recordID}, donationIDs); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Linked %d donations to %s %s.\n", len(donationIDs), recordType, recordID)
+	return nil
+}
+
+// Unlink removes the invoice or bank transaction links of the donations in Salesforce.
+func (a *App) Unlink(ctx context.Context, w io.Writer, tokenFile string, donationIDs []string) error {
+
+	if err := salesforce.IDsValid(donationIDs...); err != nil {
+		return err
+	}
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	if err := svc.Unlink(ctx, donationIDs); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Unlinked %d donations.\n", len(donationIDs))
+	return nil
+}
+
+// Export writes the period reconciliation report or the Gift Aid claim to w.
+func (a *App) Export(ctx context.Context, w io.Writer, tokenFile string, opts ExportOptions) error {
+
+	switch {
+	case opts.Kind == "report" && cmp.Or(opts.Format, "pdf") == "pdf":
+	case opts.Kind == "gift-aid" && (opts.Format == "" || opts.Format == "ods" || opts.Format == "csv"):
+		if !a.cfg.GiftAid.Enabled() {
+			return errors.New("gift aid claims are not configured")
+		}
+	default:
+		return fmt.Errorf("invalid export %q with format %q, expected 'report' (pdf) or 'gift-aid' (ods or csv)", opts.Kind, opts.Format)
+	}
+
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	from, to := a.periodDates(opts.From, opts.To)
+
+	if opts.Kind == "report" {
+		report, err := a.reconciler.PeriodReportGet(ctx, from, to)
+		if err != nil {
+			return svc.userError(err)
+		}
+		return reports.WritePeriodPDF(w, report)
+	}
+
+	g := a.cfg.GiftAid
+	claim, err := a.reconciler.GiftAidClaimGet(ctx, from, to, g.AccountCodesAsRegex(), domain.GiftAidFields{
+		Eligible:  g.EligibleField,
+		Title:     g.TitleField,
+		FirstName: g.FirstNameField,
+		LastName:  g.LastNameField,
+		House:     g.HouseField,
+		Postcode:  g.PostcodeField,
+	})
+	if err != nil {
+		return svc.userError(err)
+	}
+	if opts.Format == "csv" {
+		return reports.WriteGiftAidCSV(w, claim)
+	}
+	return reports.WriteGiftAidODS(w, claim)
+}
+
+// Snapshot writes a read-only snapshot of the synced reconciler database to path,
+// which must not exist.
+func (a *App) Snapshot(ctx context.Context, w io.Writer, tokenFile, path string) error {
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	if err := a.reconciler.SnapshotExport(ctx, path); err != nil {
+		return svc.userError(err)
+	}
+	fmt.Fprintf(w, "Wrote the snapshot to %s.\n", path)
+	return nil
+}
+
+// LinksExport writes the donation links of the synced reconciler database to w as a
+// json link mappings document.
+func (a *App) LinksExport(ctx context.Context, w io.Writer, tokenFile string) error {
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	if err := a.reconciler.LinkMappingsExport(ctx, w); err != nil {
+		return svc.userError(err)
+	}
+	return nil
+}
+
+// LinksImport imports the donation links of the json link mappings document at
+// linksPath into the synced reconciler database, and writes the database to a new
+// snapshot at snapshotPath, as the database is in memory. The snapshot may then be
+// imported by the web app.
+func (a *App) LinksImport(ctx context.Context, w io.Writer, tokenFile, linksPath, snapshotPath string) error {
+	f, err := os.Open(linksPath)
+	if err != nil {
+		return fmt.Errorf("could not open the links file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := os.Stat(snapshotPath); err == nil {
+		return fmt.Errorf("snapshot file %q already exists", snapshotPath)
+	}
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	imported, total, err := a.reconciler.LinkMappingsImport(ctx, f)
+	if err != nil {
+		return svc.userError(err)
+	}
+	if err := a.reconciler.SnapshotExport(ctx, snapshotPath); err != nil {
+		return svc.userError(err)
+	}
+	fmt.Fprintf(w, "Imported %d of %d links.\n", imported, total)
+	fmt.Fprintf(w, "Wrote the snapshot to %s.\n", snapshotPath)
+	return nil
+}
+
+// Verify re-fetches the Salesforce donations and Xero records of the donation links of
+// the synced reconciler database, or of a random sample of sample links if sample is
+// positive, and writes the links no longer matching the remote records to w.
+func (a *App) Verify(ctx context.Context, w io.Writer, tokenFile string, sample int) error {
+	svc, _, err := a.syncService(ctx, tokenFile)
+	if err != nil {
+		return err
+	}
+	sfClient, err := svc.salesforceClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create salesforce client: %w", err)
+	}
+	xeroClient, err := svc.xeroClient(ctx)
+	if err != nil {
+		return err
+	}
+	results, err := a.reconciler.LinksVerify(ctx, sfClient, xeroClient, sample)
+	if err != nil {
+		return svc.userError(err)
+	}
+	if len(results.Mismatches) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DONATION\tNAME\tTYPE\tRECORD\tREFERENCE\tSOURCE\tREMOTE")
+		for _, m := range results.Mismatches {
+			remote := m.Remote
+			if m.Missing {
+				remote = "(not found)"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				m.DonationID, m.DonationName, m.RecordType, m.RecordID, m.Reference, m.Source, remote)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "Checked %d links of %d donations and %d records, finding %d mismatches.\n",
+		results.LinksNo, results.DonationsNo, results.RecordsNo, len(results.Mismatches))
+	if results.UncheckedNo > 0 {
+		fmt.Fprintf(w, "%d records could not be checked.\n", results.UncheckedNo)
+	}
+	return nil
+}
+
+// Seed generates demonstration records in the database, without connecting to Xero or
+// Salesforce, and writes them to a new snapshot at path. The invoice and bank
+// transaction line items use the configured donation account prefixes.
+func (a *App) Seed(ctx context.Context, w io.Writer, path string, opts SeedOptions) error {
+	from, to := a.periodDates(opts.From, opts.To)
+	var accountCodes []string
+	for _, prefix := range a.cfg.DonationAccountPrefixes {
+		accountCodes = append(accountCodes, prefix+"00")
+	}
+	result, err := a.reconciler.SeedDemoData(ctx, db.SeedOptions{
+		From:             from,
+		To:               to,
+		Invoices:         opts.Invoices,
+		BankTransactions: opts.BankTransactions,
+		Donations:        opts.Donations,
+		LinkedPercent:    opts.LinkedPercent,
+		AccountCodes:     accountCodes,
+		Seed:             opts.Seed,
+	})
+	if err != nil {
+		return fmt.Errorf("could not seed the database: %w", err)
+	}
+	if err := a.reconciler.SnapshotExport(ctx, path); err != nil {
+		return fmt.Errorf("could not write the seeded database: %w", err)
+	}
+	fmt.Fprintf(w, "Generated %d invoices, %d bank transactions and %d donations (%d linked) from %s to %s.\n",
+		result.Invoices, result.BankTransactions, result.Donations, result.LinkedDonations,
+		from.Format(time.DateOnly), to.Format(time.DateOnly))
+	fmt.Fprintf(w, "Wrote the snapshot to %s.\n", path)
+	return nil
+}
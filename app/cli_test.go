@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestCommandErrors(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a, err := NewApp("../config/config.example.yaml", "", logger, false, "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	tokenFile := filepath.Join(t.TempDir(), "tokens.json")
+
+	tests := []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{
+			name: "login provider",
+			run:  func() error { return a.Login(ctx, io.Discard, tokenFile, "other") },
+			want: "invalid login provider",
+		},
+		{
+			name: "logout provider",
+			run:  func() error { return a.Logout(ctx, io.Discard, tokenFile, "other") },
+			want: "invalid logout provider",
+		},
+		{
+			name: "sync without tokens",
+			run:  func() error { return a.Sync(ctx, io.Discard, tokenFile) },
+			want: "not connected to xero",
+		},
+		{
+			name: "list type",
+			run:  func() error { return a.List(ctx, io.Discard, tokenFile, ListOptions{Kind: "other"}) },
+			want: "invalid list type",
+		},
+		{
+			name: "link record type",
+			run: func() error {
+				return a.Link(ctx, io.Discard, tokenFile, "other", "x", []string{"006000000000001"})
+			},
+			want: "invalid record type",
+		},
+		{
+			name: "unlink donation id",
+			run:  func() error { return a.Unlink(ctx, io.Discard, tokenFile, []string{"x"}) },
+			want: "invalid",
+		},
+		{
+			name: "links import file",
+			run: func() error {
+				return a.LinksImport(ctx, io.Discard, tokenFile, filepath.Join(t.TempDir(), "missing.json"), "snapshot.db")
+			},
+			want: "could not open the links file",
+		},
+		{
+			name: "export format",
+			run: func() error {
+				return a.Export(ctx, io.Discard, tokenFile, ExportOptions{Kind: "report", Format: "ods"})
+			},
+			want: "invalid export",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+
+	var revoked string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revoked = r.URL.Path + " " + r.PostFormValue("token")
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a, err := NewApp("../config/config.example.yaml", "", logger, false, "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.cfg.Xero.OAuth2Config.Endpoint.TokenURL = server.URL + "/connect/token"
+	ctx := context.Background()
+	tokenFile := filepath.Join(t.TempDir(), "tokens.json")
+
+	store, err := token.NewFileStore(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Put(ctx, token.XeroToken.SessionName(), &token.ExtendedToken{
+		Type:  token.XeroToken,
+		Token: &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"},
+	})
+
+	var out strings.Builder
+	if err := a.Logout(ctx, &out, tokenFile, "xero"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := revoked, "/connect/revocation refresh"; got != want {
+		t.Errorf("revocation got %q want %q", got, want)
+	}
+	if !strings.HasPrefix(out.String(), "Logged out of xero.") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	reloaded, err := token.NewFileStore(tokenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.ExtendedToken(ctx, token.XeroToken); ok {
+		t.Error("xero token not removed from the token file")
+	}
+
+	out.Reset()
+	if err := a.Logout(ctx, &out, tokenFile, "xero"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Not logged in to xero.\n"; got != want {
+		t.Errorf("output got %q want %q", got, want)
+	}
+}
+
+func TestProfileTokenFile(t *testing.T) {
+
+	a := &App{cfg: &config.Config{}}
+	if got, want := a.profileTokenFile("/home/a/tokens.json"), "/home/a/tokens.json"; got != want {
+		t.Errorf("token file got %q want %q", got, want)
+	}
+	a.cfg.Profile = "trading"
+	if got, want := a.profileTokenFile("/home/a/tokens.json"), "/home/a/tokens-trading.json"; got != want {
+		t.Errorf("profile token file got %q want %q", got, want)
+	}
+	if got, want := a.profileTokenFile("tokens"), "tokens-trading"; got != want {
+		t.Errorf("profile token file without extension got %q want %q", got, want)
+	}
+}
+
+func TestSeed(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a, err := NewApp("../config/config.example.yaml", "", logger, false, "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "demo.db")
+
+	opts := SeedOptions{
+		From:             time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		To:               time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+		Invoices:         20,
+		BankTransactions: 10,
+		Donations:        100,
+		LinkedPercent:    50,
+		Seed:             3,
+	}
+	var out strings.Builder
+	if err := a.Seed(ctx, &out, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Generated 20 invoices") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("snapshot not written: %v", err)
+	}
+	if err := a.Seed(ctx, io.Discard, path, opts); err == nil {
+		t.Error("expected an error seeding over an existing file")
+	}
+}
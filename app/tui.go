@@ -0,0 +1,455 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+	"github.com/rorycl/reconciler/internal/tui"
+)
+
+// tuiRefreshWindow is the duration window given for a remote platform to update its
+// records, as for the web app.
+const tuiRefreshWindow = -10 * time.Second
+
+// missingTransactionReference indicates a bank transaction without a reference, which
+// cannot be linked.
+const missingTransactionReference = "missing reference"
+
+// RunTUI runs the terminal interface. A small web server is run on the configured
+// listen address solely to handle the Xero and Salesforce OAuth2 logins, which need a
+// browser. When the app is run on a remote machine, the listen port can be forwarded
+// over SSH.
+func (a *App) RunTUI() error {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc, err := newTUIService(a.cfg, a.log, a.reconciler, token.NewMemoryStore())
+	if err != nil {
+		return fmt.Errorf("could not initialise terminal interface: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              a.cfg.Web.ListenAddress,
+		Handler:           svc.loginHandler(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+			cancel()
+		}
+	}()
+	defer func() {
+		_ = server.Shutdown(context.Background())
+	}()
+
+	err = tui.Run(ctx, svc, os.Stdin, os.Stdout)
+	select {
+	case sErr := <-serverErr:
+		return fmt.Errorf("login server error: %w", sErr)
+	default:
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// tuiService provides a tui.Service using the domain.Reconciler. OAuth2 tokens are
+// held in the store rather than in a web session. The service is also used by the
+// command line subcommands.
+type tuiService struct {
+	cfg        *config.Config
+	log        *slog.Logger
+	reconciler *domain.Reconciler
+	store      token.Store
+	xeroLogin  *token.TokenWebClient
+	sfLogin    *token.TokenWebClient
+
+	mu            sync.Mutex
+	xeroRefreshed time.Time
+	sfRefreshed   time.Time
+}
+
+// newTUIService creates a new tuiService holding tokens in store. The mock apis are
+// only available to the web server, so a mock configuration is refused.
+func newTUIService(cfg *config.Config, logger *slog.Logger, reconciler *domain.Reconciler, store token.Store) (*tuiService, error) {
+	if cfg.MockAPIs {
+		return nil, errors.New("mock_apis is only supported by the web server")
+	}
+	xeroLogin, err := token.NewTokenWebClient(token.XeroToken, cfg.Xero.OAuth2Config, store)
+	if err != nil {
+		return nil, fmt.Errorf("could not make xero oauth2 client: %w", err)
+	}
+	xeroLogin.SetTenantID(cfg.Xero.TenantID)
+	sfLogin, err := token.NewTokenWebClient(token.SalesforceToken, cfg.Salesforce.OAuth2Config, store)
+	if err != nil {
+		return nil, fmt.Errorf("could not make salesforce oauth2 client: %w", err)
+	}
+	return &tuiService{
+		cfg:        cfg,
+		log:        logger,
+		reconciler: reconciler,
+		store:      store,
+		xeroLogin:  xeroLogin,
+		sfLogin:    sfLogin,
+	}, nil
+}
+
+// userError logs err and returns an error with the user-facing message of a domain
+// error, if it has one.
+func (s *tuiService) userError(err error) error {
+	if err == nil {
+		return nil
+	}
+	s.log.Error(fmt.Sprintf("terminal interface error: %v", err))
+	if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+		return errors.New(e.Msg)
+	}
+	if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+		return errors.New(e.Msg)
+	}
+	return err
+}
+
+// loginHandler provides the OAuth2 login init and callback routes.
+func (s *tuiService) loginHandler() http.Handler {
+
+	handle := func(h func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := h(w, r); err != nil {
+				s.log.Error(fmt.Sprintf("login error: %v", err))
+				msg := "login failed"
+				if e, ok := errors.AsType[token.ErrTokenWebClient](err); ok {
+					msg = e.Msg
+				}
+				http.Error(w, msg, http.StatusInternalServerError)
+			}
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /xero/init", handle(s.xeroLogin.InitiateWebLogin()))
+	mux.Handle("GET "+s.cfg.Web.XeroCallBack, handle(s.xeroLogin.WebLoginCallBack("/")))
+	mux.Handle("GET /salesforce/init", handle(s.sfLogin.InitiateWebLogin()))
+	mux.Handle("GET "+s.cfg.Web.SalesforceCallBack, handle(s.sfLogin.WebLoginCallBack("/")))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		status := s.Status(r.Context())
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Xero connected: %t\nSalesforce connected: %t\n\nReturn to the terminal to continue.\n",
+			status.XeroConnected,
+			status.SalesforceConnected,
+		)
+	})
+	return mux
+}
+
+// validToken returns a valid token of typer, refreshing it if necessary.
+func (s *tuiService) validToken(ctx context.Context, typer token.TokenType) (*token.ExtendedToken, error) {
+	et, ok := s.store.ExtendedToken(ctx, typer)
+	if !ok {
+		return nil, fmt.Errorf("not connected to %s", typer)
+	}
+	var oauthCfg = s.cfg.Xero.OAuth2Config
+	if typer == token.SalesforceToken {
+		oauthCfg = s.cfg.Salesforce.OAuth2Config
+	}
+	if _, err := et.ReuseOrRefresh(ctx, oauthCfg); err != nil {
+		s.store.Remove(ctx, typer.SessionName())
+		return nil, fmt.Errorf("%s connection expired: %w", typer, err)
+	}
+	s.store.Put(ctx, typer.SessionName(), et)
+	return et, nil
+}
+
+// Status reports the api connection status.
+func (s *tuiService) Status(ctx context.Context) tui.Status {
+	_, xeroOK := s.store.ExtendedToken(ctx, token.XeroToken)
+	_, sfOK := s.store.ExtendedToken(ctx, token.SalesforceToken)
+	return tui.Status{
+		XeroConnected:       xeroOK,
+		SalesforceConnected: sfOK,
+		XeroURL:             fmt.Sprintf("http://%s/xero/init", s.cfg.Web.ListenAddress),
+		SalesforceURL:       fmt.Sprintf("http://%s/salesforce/init", s.cfg.Web.ListenAddress),
+	}
+}
+
+// salesforceClient returns a connected Salesforce client.
+func (s *tuiService) salesforceClient(ctx context.Context) (domain.SalesforceClient, error) {
+	sfToken, err := s.validToken(ctx, token.SalesforceToken)
+	if err != nil {
+		return nil, err
+	}
+	return salesforce.NewClient(ctx, s.cfg, s.log, sfToken)
+}
+
+// xeroClient returns a connected Xero client.
+func (s *tuiService) xeroClient(ctx context.Context) (domain.XeroClient, error) {
+	xeroToken, err := s.validToken(ctx, token.XeroToken)
+	if err != nil {
+		return nil, err
+	}
+	accountsRegexp := s.reconciler.DonationAccountsRegexp(s.cfg.DonationAccountCodesAsRegex())
+	xeroClient, err := xero.NewClient(ctx, s.log, accountsRegexp, xeroToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create xero client: %w", err)
+	}
+	// Store the token again with the tenant ID found by the client, if it was not
+	// already known.
+	s.store.Put(ctx, token.XeroToken.SessionName(), xeroToken)
+	return xeroClient, nil
+}
+
+// Refresh refreshes the Xero and Salesforce records. The first refresh is a full
+// refresh; later ones only retrieve records modified since the last refresh.
+func (s *tuiService) Refresh(ctx context.Context) (string, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accountsRegexp := s.reconciler.DonationAccountsRegexp(s.cfg.DonationAccountCodesAsRegex())
+	xeroClient, err := s.xeroClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	sfClient, err := s.salesforceClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to create salesforce client: %w", err)
+	}
+
+	sinceWindow := func(t time.Time) time.Time {
+		if t.IsZero() {
+			return t
+		}
+		return t.Add(tuiRefreshWindow)
+	}
+
+	xeroStart := time.Now()
+	xeroResults, err := s.reconciler.XeroRecordsRefresh(
+		ctx,
+		xeroClient,
+		s.cfg.DataStartDate,
+		sinceWindow(s.xeroRefreshed),
+		accountsRegexp,
+		s.xeroRefreshed.IsZero(),
+	)
+	if err != nil {
+		return "", s.userError(err)
+	}
+	s.xeroRefreshed = xeroStart
+
+	// Check the Salesforce field mappings on the first refresh.
+	var mappingsMsg string
+	if s.sfRefreshed.IsZero() {
+		problems, err := sfClient.ValidateFieldMappings(ctx)
+		if err != nil {
+			s.log.Error(fmt.Sprintf("salesforce field mapping check error: %v", err))
+		}
+		if len(problems) > 0 {
+			mappingsMsg = fmt.Sprintf(" Salesforce field mapping problems: %s.", strings.Join(problems, "; "))
+		}
+	}
+
+	sfStart := time.Now()
+	sfResults, err := s.reconciler.SalesforceRecordsRefresh(ctx, sfClient, s.cfg.DataStartDate, sinceWindow(s.sfRefreshed))
+	if err != nil {
+		return "", s.userError(err)
+	}
+	s.sfRefreshed = sfStart
+
+	var warningsMsg string
+	for _, msg := range []string{
+		domain.RejectedRecordsMessage(xeroResults.RejectedNo + sfResults.RejectedNo),
+		domain.SyncWarningsMessage(slices.Concat(xeroResults.Warnings, sfResults.Warnings)),
+	} {
+		if msg != "" {
+			warningsMsg += " " + msg
+		}
+	}
+	return fmt.Sprintf("Retrieved %d invoices, %d bank transactions and %d donations.%s%s",
+		xeroResults.InvoicesNo,
+		xeroResults.TransactionsNo,
+		sfResults.RecordsNo,
+		mappingsMsg,
+		warningsMsg,
+	), nil
+}
+
+// Items returns all the unreconciled invoices and bank transactions since the data
+// start date, ordered by type and date.
+func (s *tuiService) Items(ctx context.Context) ([]tui.Item, error) {
+
+	from := s.cfg.DataStartDate
+	to := s.cfg.Today().AddDate(1, 0, 0)
+	const noLimit = -1
+
+	invoices, err := s.reconciler.InvoicesGet(ctx, "NotReconciled", from, to, "", "", db.SortOrder{}, noLimit, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, s.userError(err)
+	}
+	transactions, err := s.reconciler.TransactionsGet(ctx, "NotReconciled", from, to, "", "", db.SortOrder{}, noLimit, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, s.userError(err)
+	}
+
+	items := make([]tui.Item, 0, len(invoices)+len(transactions))
+	for _, i := range invoices {
+		items = append(items, tui.Item{
+			Type:        "invoice",
+			ID:          i.InvoiceID,
+			Reference:   i.InvoiceNumber,
+			Date:        i.Date,
+			Contact:     i.Contact,
+			Total:       i.Total,
+			Outstanding: i.DonationTotal - i.CRMSTotal,
+		})
+	}
+	for _, bt := range transactions {
+		items = append(items, tui.Item{
+			Type:        "bank-transaction",
+			ID:          bt.ID,
+			Reference:   bt.Reference,
+			Date:        bt.Date,
+			Contact:     bt.Contact,
+			Total:       bt.Total,
+			Outstanding: bt.DonationTotal - bt.CRMSTotal,
+		})
+	}
+	return items, nil
+}
+
+// Detail returns the line items of an invoice or bank transaction together with the
+// unlinked donations near its date and the donations linked to it.
+func (s *tuiService) Detail(ctx context.Context, item tui.Item) (tui.Detail, error) {
+
+	detail := tui.Detail{Item: item}
+
+	var lineItems []domain.ViewLineItem
+	var err error
+	switch item.Type {
+	case "invoice":
+		_, lineItems, err = s.reconciler.InvoiceDetailGet(ctx, item.ID)
+	case "bank-transaction":
+		_, lineItems, err = s.reconciler.TransactionDetailGet(ctx, item.ID)
+	default:
+		err = fmt.Errorf("invalid record type %q", item.Type)
+	}
+	if err != nil {
+		return detail, s.userError(err)
+	}
+	for _, li := range lineItems {
+		detail.Lines = append(detail.Lines, tui.Line{
+			AccountCode:    li.AccountCode,
+			Description:    li.Description,
+			LineAmount:     li.LineAmount,
+			DonationAmount: li.DonationAmount,
+		})
+	}
+
+	// Search for unlinked donations from six weeks before to two weeks after the
+	// record date, as for the web app.
+	from := item.Date.AddDate(0, 0, -6*7)
+	to := item.Date.AddDate(0, 0, 2*7)
+	donations, err := s.reconciler.DonationsGet(ctx, from, to, "NotLinked", "", "", "", db.SortOrder{}, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return detail, s.userError(err)
+	}
+	for _, d := range donations {
+		detail.Candidates = append(detail.Candidates, tui.Donation{
+			ID:        d.ID,
+			Name:      d.Name,
+			Amount:    d.Amount,
+			CloseDate: d.CloseDateStr,
+		})
+	}
+
+	// Retrieve the donations already linked to the record, as for the web app unlink
+	// view.
+	dfk, _, err := s.reconciler.InvoiceOrBankTransactionInfoGet(ctx, item.Type, item.ID)
+	if err != nil {
+		return detail, s.userError(err)
+	}
+	if dfk == "" || dfk == missingTransactionReference {
+		return detail, nil
+	}
+	linked, err := s.reconciler.DonationsGet(ctx, s.cfg.DataStartDate, s.cfg.Today().AddDate(1, 0, 0), "Linked", dfk, "", "", db.SortOrder{}, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return detail, s.userError(err)
+	}
+	for _, d := range linked {
+		detail.Linked = append(detail.Linked, tui.Donation{
+			ID:        d.ID,
+			Name:      d.Name,
+			Amount:    d.Amount,
+			CloseDate: d.CloseDateStr,
+		})
+	}
+	return detail, nil
+}
+
+// Link links the donations to the invoice or bank transaction in Salesforce and
+// updates the local records.
+func (s *tuiService) Link(ctx context.Context, item tui.Item, donationIDs []string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dfk, _, err := s.reconciler.InvoiceOrBankTransactionInfoGet(ctx, item.Type, item.ID)
+	if err != nil {
+		return s.userError(err)
+	}
+	if dfk == "" || dfk == missingTransactionReference {
+		return fmt.Errorf("%s %s has no reference and cannot be linked", item.Type, item.ID)
+	}
+	return s.linkUnlink(ctx, dfk, donationIDs)
+}
+
+// Unlink removes the invoice or bank transaction links of the donations in Salesforce
+// and updates the local records.
+func (s *tuiService) Unlink(ctx context.Context, donationIDs []string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.linkUnlink(ctx, "", donationIDs)
+}
+
+// linkUnlink sets the Salesforce linking field of the donations to dfk, with an empty
+// dfk unlinking the donations. The caller must hold s.mu.
+func (s *tuiService) linkUnlink(ctx context.Context, dfk string, donationIDs []string) error {
+
+	sfClient, err := s.salesforceClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	idRefs := make([]salesforce.IDRef, len(donationIDs))
+	for i, id := range donationIDs {
+		idRefs[i] = salesforce.IDRef{ID: id, Ref: dfk}
+	}
+	err = s.reconciler.DonationsLinkUnlink(
+		ctx,
+		sfClient,
+		idRefs,
+		s.cfg.DataStartDate,
+		s.sfRefreshed.Add(tuiRefreshWindow),
+	)
+	return s.userError(err)
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestTUIServiceLogin(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a, err := NewApp("../config/config.example.yaml", "", logger, false, "", "", "", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := newTUIService(a.cfg, a.log, a.reconciler, token.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if svc.Status(ctx).Connected() {
+		t.Fatal("expected not to be connected")
+	}
+	if got, want := svc.Status(ctx).XeroURL, "http://localhost:8080/xero/init"; got != want {
+		t.Errorf("xero url got %q want %q", got, want)
+	}
+
+	handler := svc.loginHandler()
+
+	// The login init endpoints redirect to the platform authorization urls.
+	for _, path := range []string{"/xero/init", "/salesforce/init"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("%s status got %d want %d", path, rr.Code, http.StatusSeeOther)
+		}
+		if loc := rr.Header().Get("Location"); !strings.Contains(loc, "code_challenge") {
+			t.Errorf("%s unexpected redirect location %q", path, loc)
+		}
+	}
+
+	// A callback without a matching state fails.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, a.cfg.Web.XeroCallBack+"?state=x&code=y", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("callback status got %d want %d", rr.Code, http.StatusInternalServerError)
+	}
+
+	// Tokens in the store are reported as connected.
+	svc.store.Put(ctx, token.XeroToken.SessionName(), &token.ExtendedToken{Type: token.XeroToken, Token: &oauth2.Token{AccessToken: "x"}})
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rr.Body.String(); !strings.Contains(body, "Xero connected: true") || !strings.Contains(body, "Salesforce connected: false") {
+		t.Errorf("unexpected status page %q", body)
+	}
+}
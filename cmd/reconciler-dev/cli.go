@@ -33,7 +33,7 @@ type WebRunner interface {
 }
 
 // AppMaker instantiates a concrete implementation of WebRunner.
-type AppMaker func(configFile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error)
+type AppMaker func(configFile, profile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error)
 
 // BuildCLI creates a cli app to run the capabilities provided by
 // a WebRunner dependency. A verifier func can be provided to guard app running.
@@ -69,7 +69,11 @@ func BuildCLI(apper AppMaker, verifier func() error) *cli.Command {
 		Name:     "database",
 		Aliases:  []string{"d"},
 		Required: true,
-		Usage:    "':memory:' or path to database file",
+		Usage:    "':memory:' or path to database file; replaced by the database path of the profile, if set",
+	}
+	profileFlag := &cli.StringFlag{
+		Name:  "profile",
+		Usage: "the profile of the config file to use (default: the config default_profile, if any)",
 	}
 
 	fileArg := &cli.StringArg{
@@ -84,7 +88,7 @@ func BuildCLI(apper AppMaker, verifier func() error) *cli.Command {
 
 		// Attach the flags.
 		Flags: []cli.Flag{
-			logLevelFlag, staticFlag, tplFlag, sqlFlag, dbFlag,
+			logLevelFlag, staticFlag, tplFlag, sqlFlag, dbFlag, profileFlag,
 		},
 
 		// Attach the arguments.
@@ -136,6 +140,7 @@ func BuildCLI(apper AppMaker, verifier func() error) *cli.Command {
 
 			app, err := apper(
 				configFile,
+				c.String("profile"),
 				debugLevel,
 				true,                     // inDevelopment
 				c.String("staticPath"),   // staticPath
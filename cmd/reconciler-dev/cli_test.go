@@ -16,7 +16,7 @@ type MockWebRunner struct{}
 func (m *MockWebRunner) RunWebServer() error { return nil }
 
 // MockAppMaker generates a WebRunner
-func MockAppMaker(configFile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error) {
+func MockAppMaker(configFile, profile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error) {
 	return &MockWebRunner{}, nil
 }
 
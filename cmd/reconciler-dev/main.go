@@ -15,6 +15,7 @@ import (
 // appInitialiser converts an app.NewApp to a cli WebRunner interface.
 func appInitialiser(
 	configFile string,
+	profile string,
 	logLevel slog.Level,
 	inDevelopment bool,
 	staticPath, templatePath, sqlPath, databasePath string,
@@ -27,7 +28,7 @@ func appInitialiser(
 		Level:           charmlog.Level(logLevel),
 	})
 	logger := slog.New(charmLogger)
-	return app.NewApp(configFile, logger, inDevelopment, staticPath, templatePath, sqlPath, databasePath)
+	return app.NewApp(configFile, profile, logger, inDevelopment, staticPath, templatePath, sqlPath, databasePath)
 }
 
 // run is the entry point.
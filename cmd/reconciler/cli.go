@@ -3,9 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/rorycl/reconciler/app"
+	"github.com/rorycl/reconciler/config"
 	"github.com/urfave/cli/v3"
 )
 
@@ -19,13 +24,27 @@ licence and other documentation at https://github.com/rorycl/reconciler.`
 )
 
 // WebRunner is an interface to the central coordinator for the project (concretely
-// provided by App in app.go) to allow for testing.
+// provided by App in app.go) to allow for testing. The methods taking a tokenFile run
+// the subcommands.
 type WebRunner interface {
 	RunWebServer() error
+	RunTUI() error
+	Login(ctx context.Context, w io.Writer, tokenFile, provider string) error
+	Logout(ctx context.Context, w io.Writer, tokenFile, provider string) error
+	Sync(ctx context.Context, w io.Writer, tokenFile string) error
+	List(ctx context.Context, w io.Writer, tokenFile string, opts app.ListOptions) error
+	Link(ctx context.Context, w io.Writer, tokenFile, recordType, recordID string, donationIDs []string) error
+	Unlink(ctx context.Context, w io.Writer, tokenFile string, donationIDs []string) error
+	Export(ctx context.Context, w io.Writer, tokenFile string, opts app.ExportOptions) error
+	Snapshot(ctx context.Context, w io.Writer, tokenFile, path string) error
+	LinksExport(ctx context.Context, w io.Writer, tokenFile string) error
+	LinksImport(ctx context.Context, w io.Writer, tokenFile, linksPath, snapshotPath string) error
+	Verify(ctx context.Context, w io.Writer, tokenFile string, sample int) error
+	Seed(ctx context.Context, w io.Writer, path string, opts app.SeedOptions) error
 }
 
 // AppMaker instantiates a concrete implementation of WebRunner.
-type AppMaker func(configFile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error)
+type AppMaker func(configFile, profile string, logOutput io.Writer, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error)
 
 // BuildCLI creates a cli app to run the capabilities provided by
 // a WebRunner dependency.
@@ -39,9 +58,22 @@ func BuildCLI(apper AppMaker) *cli.Command {
 		Value:   "Error",
 		Usage:   "slog logger debug level",
 	}
+	tuiFlag := &cli.BoolFlag{
+		Name:  "tui",
+		Usage: "run the terminal interface instead of the web app (console logging is discarded)",
+	}
 	fileArg := &cli.StringArg{
 		Name: "configFile",
 	}
+	tokensFlag := &cli.StringFlag{
+		Name:  "tokens",
+		Value: defaultTokenFile(),
+		Usage: "file for saving the OAuth2 tokens used by the subcommands; the tokens of a profile are saved with the profile name added to the file name",
+	}
+	profileFlag := &cli.StringFlag{
+		Name:  "profile",
+		Usage: "the profile of the config file to use (default: the config default_profile, if any)",
+	}
 
 	cmd := &cli.Command{
 		Name:        "reconciler",
@@ -52,6 +84,9 @@ func BuildCLI(apper AppMaker) *cli.Command {
 		// Attach the flags.
 		Flags: []cli.Flag{
 			logLevelFlag,
+			tuiFlag,
+			tokensFlag,
+			profileFlag,
 		},
 
 		// Attach the arguments.
@@ -62,15 +97,6 @@ func BuildCLI(apper AppMaker) *cli.Command {
 		// Before runs verification before "Action" is run
 		Before: func(ctx context.Context, c *cli.Command) (context.Context, error) {
 
-			configFile = c.Args().Get(0)
-			if configFile == "" {
-				return ctx, fmt.Errorf("error: config file not provided")
-			}
-			// Validate config file exists.
-			if _, err := os.Stat(configFile); err != nil {
-				return ctx, fmt.Errorf("error: could not stat config file %q: %w", configFile, err)
-			}
-
 			// Validate log level.
 			switch c.String("logLevel") {
 			case "Error", "Warn", "Info", "Debug":
@@ -79,37 +105,36 @@ func BuildCLI(apper AppMaker) *cli.Command {
 
 			}
 
+			// Subcommands check their own config file argument.
+			if c.Command(c.Args().First()) != nil {
+				return ctx, nil
+			}
+			configFile = c.Args().Get(0)
+			if err := checkConfigFile(configFile); err != nil {
+				return ctx, err
+			}
+
 			return ctx, nil
 		},
 		Action: func(ctx context.Context, c *cli.Command) error {
 
-			debugLevel := func(s string) slog.Level {
-				switch s {
-				case "Warn":
-					return slog.LevelWarn
-				case "Info":
-					return slog.LevelInfo
-				case "Debug":
-					return slog.LevelDebug
-				default:
-					return slog.LevelError
-				}
-			}(c.String("logLevel"))
-
-			app, err := apper(
-				configFile,
-				debugLevel,
-				false,      // not inDevelopment
-				"",         // staticPath : use embedded
-				"",         // templatePath : use embedded
-				"",         // sqlPath : use embedded
-				":memory:", // force use of memory database in production
-			)
+			// Logging to the terminal would overwrite the terminal interface.
+			var logOutput io.Writer = os.Stdout
+			if c.Bool("tui") {
+				logOutput = io.Discard
+			}
+
+			app, err := newRunner(apper, configFile, c.String("profile"), logOutput, c.String("logLevel"))
 			if err != nil {
 				return err
 			}
+			if c.Bool("tui") {
+				return app.RunTUI()
+			}
 			return app.RunWebServer()
 		},
+
+		Commands: buildSubcommands(apper),
 	}
 
 	// custom help template.
@@ -117,3 +142,334 @@ func BuildCLI(apper AppMaker) *cli.Command {
 
 	return cmd
 }
+
+// checkConfigFile checks that the config file argument was provided and exists.
+func checkConfigFile(configFile string) error {
+	if configFile == "" {
+		return fmt.Errorf("error: config file not provided")
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		return fmt.Errorf("error: could not stat config file %q: %w", configFile, err)
+	}
+	return nil
+}
+
+// defaultTokenFile returns the default token file path in the user's configuration
+// directory, or the current directory if there is none.
+func defaultTokenFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "reconciler-tokens.json"
+	}
+	return filepath.Join(dir, "reconciler", "tokens.json")
+}
+
+// newRunner makes a production WebRunner for the profile using an in-memory database.
+func newRunner(apper AppMaker, configFile, profile string, logOutput io.Writer, logLevel string) (WebRunner, error) {
+
+	debugLevel := func(s string) slog.Level {
+		switch s {
+		case "Warn":
+			return slog.LevelWarn
+		case "Info":
+			return slog.LevelInfo
+		case "Debug":
+			return slog.LevelDebug
+		default:
+			return slog.LevelError
+		}
+	}(logLevel)
+
+	return apper(
+		configFile,
+		profile,
+		logOutput,
+		debugLevel,
+		false,      // not inDevelopment
+		"",         // staticPath : use embedded
+		"",         // templatePath : use embedded
+		"",         // sqlPath : use embedded
+		":memory:", // force use of memory database in production
+	)
+}
+
+// parseDate parses an optional date flag in YYYY-MM-DD format.
+func parseDate(c *cli.Command, name string) (time.Time, error) {
+	s := c.String(name)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error: invalid --%s date %q, expected YYYY-MM-DD", name, s)
+	}
+	return t, nil
+}
+
+// buildSubcommands returns the subcommands, which run the reconciler without a browser
+// for scripts and scheduled jobs. Each takes the config file as its first argument
+// and syncs the records from Xero and Salesforce before running, apart from login,
+// logout, seed and config check.
+// Logging is to stderr so that output can be redirected.
+func buildSubcommands(apper AppMaker) []*cli.Command {
+
+	// subcommand wraps the actions of the subcommands, checking the config file and
+	// the number of further arguments, and making the runner.
+	subcommand := func(minArgs int, action func(context.Context, *cli.Command, WebRunner, []string) error) cli.ActionFunc {
+		return func(ctx context.Context, c *cli.Command) error {
+			configFile := c.Args().Get(0)
+			if err := checkConfigFile(configFile); err != nil {
+				return err
+			}
+			args := c.Args().Tail()
+			if len(args) < minArgs {
+				return fmt.Errorf("error: expected %s", c.ArgsUsage)
+			}
+			runner, err := newRunner(apper, configFile, c.String("profile"), os.Stderr, c.String("logLevel"))
+			if err != nil {
+				return err
+			}
+			return action(ctx, c, runner, args)
+		}
+	}
+
+	dateFlags := []cli.Flag{
+		&cli.StringFlag{Name: "from", Usage: "start date as YYYY-MM-DD (default: the config data start date)"},
+		&cli.StringFlag{Name: "to", Usage: "end date as YYYY-MM-DD (default: today)"},
+	}
+
+	return []*cli.Command{
+		{
+			Name:      "login",
+			Usage:     "log in to xero or salesforce in a browser, saving the token for the other subcommands",
+			ArgsUsage: "<yamlfile> <xero|salesforce>",
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Login(ctx, c.Root().Writer, c.String("tokens"), args[0])
+			}),
+		},
+		{
+			Name:      "logout",
+			Usage:     "revoke the saved xero or salesforce token and remove it",
+			ArgsUsage: "<yamlfile> <xero|salesforce>",
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Logout(ctx, c.Root().Writer, c.String("tokens"), args[0])
+			}),
+		},
+		{
+			Name:      "sync",
+			Usage:     "retrieve the xero and salesforce records, checking the saved tokens",
+			ArgsUsage: "<yamlfile>",
+			Action: subcommand(0, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Sync(ctx, c.Root().Writer, c.String("tokens"))
+			}),
+		},
+		{
+			Name:      "list",
+			Usage:     "list invoices, bank transactions or donations",
+			ArgsUsage: "<yamlfile> <invoices|transactions|donations>",
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{Name: "status", Value: "All", Usage: "All, Reconciled or NotReconciled; for donations All, Linked or NotLinked"},
+				&cli.StringFlag{Name: "search", Usage: "search term"},
+				&cli.IntFlag{Name: "limit", Value: -1, Usage: "maximum number of records (-1 for all)"},
+				&cli.BoolFlag{Name: "csv", Usage: "output csv rather than a table"},
+			}, dateFlags...),
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				from, err := parseDate(c, "from")
+				if err != nil {
+					return err
+				}
+				to, err := parseDate(c, "to")
+				if err != nil {
+					return err
+				}
+				return runner.List(ctx, c.Root().Writer, c.String("tokens"), app.ListOptions{
+					Kind:   args[0],
+					Status: c.String("status"),
+					From:   from,
+					To:     to,
+					Search: c.String("search"),
+					Limit:  c.Int("limit"),
+					CSV:    c.Bool("csv"),
+				})
+			}),
+		},
+		{
+			Name:      "link",
+			Usage:     "link salesforce donations to a xero invoice or bank transaction",
+			ArgsUsage: "<yamlfile> <invoice|bank-transaction> <id> <donationID>...",
+			Action: subcommand(3, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Link(ctx, c.Root().Writer, c.String("tokens"), args[0], args[1], args[2:])
+			}),
+		},
+		{
+			Name:      "unlink",
+			Usage:     "unlink salesforce donations",
+			ArgsUsage: "<yamlfile> <donationID>...",
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Unlink(ctx, c.Root().Writer, c.String("tokens"), args)
+			}),
+		},
+		{
+			Name:      "export",
+			Usage:     "export the period reconciliation report (pdf) or gift aid claim (ods or csv)",
+			ArgsUsage: "<yamlfile> <report|gift-aid>",
+			Flags: append([]cli.Flag{
+				&cli.StringFlag{Name: "format", Usage: "pdf for reports; ods (default) or csv for gift aid claims"},
+				&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "output file (default: stdout)"},
+			}, dateFlags...),
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				from, err := parseDate(c, "from")
+				if err != nil {
+					return err
+				}
+				to, err := parseDate(c, "to")
+				if err != nil {
+					return err
+				}
+				opts := app.ExportOptions{Kind: args[0], From: from, To: to, Format: c.String("format")}
+				output := c.String("output")
+				if output == "" {
+					return runner.Export(ctx, c.Root().Writer, c.String("tokens"), opts)
+				}
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("could not create output file: %w", err)
+				}
+				if err := runner.Export(ctx, f, c.String("tokens"), opts); err != nil {
+					_ = f.Close()
+					return err
+				}
+				return f.Close()
+			}),
+		},
+		{
+			Name:      "snapshot",
+			Usage:     "write a read-only snapshot of the reconciler database to a new file",
+			ArgsUsage: "<yamlfile> <file>",
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				return runner.Snapshot(ctx, c.Root().Writer, c.String("tokens"), args[0])
+			}),
+		},
+		{
+			Name:  "links",
+			Usage: "export or import the donation links as json",
+			Commands: []*cli.Command{
+				{
+					Name:      "export",
+					Usage:     "export the donation links as a json document",
+					ArgsUsage: "<yamlfile>",
+					Flags: []cli.Flag{
+						&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "output file (default: stdout)"},
+					},
+					Action: subcommand(0, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+						output := c.String("output")
+						if output == "" {
+							return runner.LinksExport(ctx, c.Root().Writer, c.String("tokens"))
+						}
+						f, err := os.Create(output)
+						if err != nil {
+							return fmt.Errorf("could not create output file: %w", err)
+						}
+						if err := runner.LinksExport(ctx, f, c.String("tokens")); err != nil {
+							_ = f.Close()
+							return err
+						}
+						return f.Close()
+					}),
+				},
+				{
+					Name:      "import",
+					Usage:     "import a json document of donation links into the synced database, writing it to a new snapshot file",
+					ArgsUsage: "<yamlfile> <linksfile> <snapshotfile>",
+					Action: subcommand(2, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+						return runner.LinksImport(ctx, c.Root().Writer, c.String("tokens"), args[0], args[1])
+					}),
+				},
+			},
+		},
+		{
+			Name:      "verify",
+			Usage:     "report the donation links no longer matching the Salesforce donations and Xero records",
+			ArgsUsage: "<yamlfile>",
+			Flags: []cli.Flag{
+				&cli.IntFlag{Name: "sample", Value: 0, Usage: "number of links to verify, chosen at random (default: all)"},
+			},
+			Action: subcommand(0, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				if c.Int("sample") < 0 {
+					return fmt.Errorf("error: --sample should not be negative")
+				}
+				return runner.Verify(ctx, c.Root().Writer, c.String("tokens"), c.Int("sample"))
+			}),
+		},
+		{
+			Name:      "seed",
+			Usage:     "generate demonstration records without xero or salesforce, writing them to a new snapshot file",
+			ArgsUsage: "<yamlfile> <file>",
+			Flags: append([]cli.Flag{
+				&cli.IntFlag{Name: "invoices", Value: 200, Usage: "number of invoices"},
+				&cli.IntFlag{Name: "transactions", Value: 100, Usage: "number of bank transactions"},
+				&cli.IntFlag{Name: "donations", Value: 1000, Usage: "number of donations"},
+				&cli.IntFlag{Name: "linked", Value: 60, Usage: "percentage of invoices and bank transactions with linked donations"},
+				&cli.Uint64Flag{Name: "seed", Value: 1, Usage: "random seed; the same seed and options generate the same records"},
+			}, dateFlags...),
+			Action: subcommand(1, func(ctx context.Context, c *cli.Command, runner WebRunner, args []string) error {
+				from, err := parseDate(c, "from")
+				if err != nil {
+					return err
+				}
+				to, err := parseDate(c, "to")
+				if err != nil {
+					return err
+				}
+				if c.Int("linked") < 0 || c.Int("linked") > 100 {
+					return fmt.Errorf("error: --linked should be a percentage between 0 and 100")
+				}
+				return runner.Seed(ctx, c.Root().Writer, args[0], app.SeedOptions{
+					From:             from,
+					To:               to,
+					Invoices:         c.Int("invoices"),
+					BankTransactions: c.Int("transactions"),
+					Donations:        c.Int("donations"),
+					LinkedPercent:    c.Int("linked"),
+					Seed:             c.Uint64("seed"),
+				})
+			}),
+		},
+		{
+			Name:  "config",
+			Usage: "check the config file",
+			Commands: []*cli.Command{
+				{
+					Name:      "check",
+					Usage:     "check the config file, reporting all its problems, without connecting to xero or salesforce; each profile is checked unless --profile is given",
+					ArgsUsage: "<yamlfile>",
+					Action: func(ctx context.Context, c *cli.Command) error {
+						configFile := c.Args().Get(0)
+						if err := checkConfigFile(configFile); err != nil {
+							return err
+						}
+						cfg, err := config.LoadProfile(configFile, c.String("profile"))
+						if err != nil {
+							return fmt.Errorf("%s: %w", configFile, err)
+						}
+						if _, err := fmt.Fprintf(c.Root().Writer, "%s: ok\n", configFile); err != nil {
+							return err
+						}
+						if c.String("profile") != "" {
+							return nil
+						}
+						for _, profile := range cfg.ProfileNames() {
+							if _, err := config.LoadProfile(configFile, profile); err != nil {
+								return fmt.Errorf("%s (profile %s): %w", configFile, profile, err)
+							}
+							if _, err := fmt.Fprintf(c.Root().Writer, "%s (profile %s): ok\n", configFile, profile); err != nil {
+								return err
+							}
+						}
+						return nil
+					},
+				},
+			},
+		},
+	}
+}
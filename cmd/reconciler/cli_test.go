@@ -8,15 +8,53 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/rorycl/reconciler/app"
 )
 
 // TestRunner implements the App WebRunner interface for testing.
 type MockWebRunner struct{}
 
 func (m *MockWebRunner) RunWebServer() error { return nil }
+func (m *MockWebRunner) RunTUI() error       { return nil }
+func (m *MockWebRunner) Login(ctx context.Context, w io.Writer, tokenFile, provider string) error {
+	return nil
+}
+func (m *MockWebRunner) Logout(ctx context.Context, w io.Writer, tokenFile, provider string) error {
+	return nil
+}
+func (m *MockWebRunner) Sync(ctx context.Context, w io.Writer, tokenFile string) error { return nil }
+func (m *MockWebRunner) List(ctx context.Context, w io.Writer, tokenFile string, opts app.ListOptions) error {
+	return nil
+}
+func (m *MockWebRunner) Link(ctx context.Context, w io.Writer, tokenFile, recordType, recordID string, donationIDs []string) error {
+	return nil
+}
+func (m *MockWebRunner) Unlink(ctx context.Context, w io.Writer, tokenFile string, donationIDs []string) error {
+	return nil
+}
+func (m *MockWebRunner) Export(ctx context.Context, w io.Writer, tokenFile string, opts app.ExportOptions) error {
+	return nil
+}
+
+func (m *MockWebRunner) Snapshot(ctx context.Context, w io.Writer, tokenFile, path string) error {
+	return nil
+}
+func (m *MockWebRunner) LinksExport(ctx context.Context, w io.Writer, tokenFile string) error {
+	return nil
+}
+func (m *MockWebRunner) LinksImport(ctx context.Context, w io.Writer, tokenFile, linksPath, snapshotPath string) error {
+	return nil
+}
+func (m *MockWebRunner) Verify(ctx context.Context, w io.Writer, tokenFile string, sample int) error {
+	return nil
+}
+func (m *MockWebRunner) Seed(ctx context.Context, w io.Writer, path string, opts app.SeedOptions) error {
+	return nil
+}
 
 // MockAppMaker generates a WebRunner
-func MockAppMaker(configFile string, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error) {
+func MockAppMaker(configFile, profile string, logOutput io.Writer, logLevel slog.Level, inDevelopment bool, staticPath, templatePath, sqlPath, databasePath string) (WebRunner, error) {
 	return &MockWebRunner{}, nil
 }
 
@@ -42,6 +80,10 @@ func TestParseCLI(t *testing.T) {
 			name: "all options valid",
 			args: []string{"program", "-l", "Error", validConfig},
 		},
+		{
+			name: "terminal interface",
+			args: []string{"program", "--tui", validConfig},
+		},
 		{
 			name: "defaults valid",
 			args: []string{"program", validConfig},
@@ -66,6 +108,127 @@ func TestParseCLI(t *testing.T) {
 			args:            []string{"program", "-l", "Whatever", "../../config/config.example.yaml"},
 			wantErrContains: "expected a debug level",
 		},
+		{
+			name: "sync",
+			args: []string{"program", "-l", "Info", "sync", validConfig},
+		},
+		{
+			name: "sync with token file",
+			args: []string{"program", "sync", "--tokens", filepath.Join(tmpDir, "tokens.json"), validConfig},
+		},
+		{
+			name:            "sync missing config file",
+			args:            []string{"program", "sync"},
+			wantErrContains: "config file not provided",
+		},
+		{
+			name:            "sync invalid debug",
+			args:            []string{"program", "-l", "Whatever", "sync", validConfig},
+			wantErrContains: "expected a debug level",
+		},
+		{
+			name: "login",
+			args: []string{"program", "login", validConfig, "xero"},
+		},
+		{
+			name: "logout",
+			args: []string{"program", "logout", validConfig, "salesforce"},
+		},
+		{
+			name:            "logout without provider",
+			args:            []string{"program", "logout", validConfig},
+			wantErrContains: "expected <yamlfile> <xero|salesforce>",
+		},
+		{
+			name:            "login without provider",
+			args:            []string{"program", "login", validConfig},
+			wantErrContains: "expected <yamlfile> <xero|salesforce>",
+		},
+		{
+			name: "list",
+			args: []string{"program", "list", "--status", "NotReconciled", "--from", "2025-04-01", "--csv", validConfig, "invoices"},
+		},
+		{
+			name:            "list invalid date",
+			args:            []string{"program", "list", "--from", "01/04/2025", validConfig, "invoices"},
+			wantErrContains: "invalid --from date",
+		},
+		{
+			name: "link",
+			args: []string{"program", "link", validConfig, "invoice", "inv-1", "006000000000001", "006000000000002"},
+		},
+		{
+			name:            "link without donations",
+			args:            []string{"program", "link", validConfig, "invoice", "inv-1"},
+			wantErrContains: "expected <yamlfile> <invoice|bank-transaction> <id> <donationID>...",
+		},
+		{
+			name: "unlink",
+			args: []string{"program", "unlink", validConfig, "006000000000001"},
+		},
+		{
+			name: "export",
+			args: []string{"program", "export", "--format", "csv", "-o", filepath.Join(tmpDir, "claim.csv"), validConfig, "gift-aid"},
+		},
+		{
+			name: "snapshot",
+			args: []string{"program", "snapshot", validConfig, filepath.Join(tmpDir, "snapshot.db")},
+		},
+		{
+			name:            "snapshot missing file",
+			args:            []string{"program", "snapshot", validConfig},
+			wantErrContains: "expected <yamlfile> <file>",
+		},
+		{
+			name: "links export",
+			args: []string{"program", "links", "export", "-o", filepath.Join(tmpDir, "links.json"), validConfig},
+		},
+		{
+			name: "links import",
+			args: []string{"program", "links", "import", validConfig, "links.json", filepath.Join(tmpDir, "links.db")},
+		},
+		{
+			name:            "links import missing snapshot file",
+			args:            []string{"program", "links", "import", validConfig, "links.json"},
+			wantErrContains: "expected <yamlfile> <linksfile> <snapshotfile>",
+		},
+		{
+			name: "verify sample",
+			args: []string{"program", "verify", "--sample", "20", validConfig},
+		},
+		{
+			name:            "verify negative sample",
+			args:            []string{"program", "verify", "--sample", "-1", validConfig},
+			wantErrContains: "--sample should not be negative",
+		},
+		{
+			name: "seed",
+			args: []string{"program", "seed", "--invoices", "50", "--from", "2025-04-01", "--seed", "7", validConfig, filepath.Join(tmpDir, "demo.db")},
+		},
+		{
+			name:            "seed invalid linked percentage",
+			args:            []string{"program", "seed", "--linked", "120", validConfig, filepath.Join(tmpDir, "demo.db")},
+			wantErrContains: "--linked should be a percentage",
+		},
+		{
+			name: "config check",
+			args: []string{"program", "config", "check", "../../config/config.example.yaml"},
+		},
+		{
+			name:            "config check invalid",
+			args:            []string{"program", "config", "check", validConfig},
+			wantErrContains: "field fake not found",
+		},
+		{
+			name:            "config check unknown profile",
+			args:            []string{"program", "--profile", "charity", "config", "check", "../../config/config.example.yaml"},
+			wantErrContains: `profile "charity" is not one of`,
+		},
+		{
+			name:            "config check missing config file",
+			args:            []string{"program", "config", "check"},
+			wantErrContains: "config file not provided",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
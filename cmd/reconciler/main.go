@@ -5,6 +5,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -14,14 +15,16 @@ import (
 // appInitialiser converts an app.NewApp to a cli WebRunner interface.
 func appInitialiser(
 	configFile string,
+	profile string,
+	logOutput io.Writer,
 	logLevel slog.Level,
 	inDevelopment bool,
 	staticPath, templatePath, sqlPath, databasePath string,
 ) (WebRunner, error) {
 
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})
+	handler := slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: logLevel})
 	logger := slog.New(handler)
-	return app.NewApp(configFile, logger, inDevelopment, staticPath, templatePath, sqlPath, databasePath)
+	return app.NewApp(configFile, profile, logger, inDevelopment, staticPath, templatePath, sqlPath, databasePath)
 }
 
 func run(args []string) {
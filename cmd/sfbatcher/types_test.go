@@ -37,7 +37,8 @@ func TestValueStorer(t *testing.T) {
 func TestSFClientMaker(t *testing.T) {
 
 	et := &token.ExtendedToken{
-		Type: token.SalesforceToken,
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example--uat.sandbox.my.salesforce.com",
 	}
 	cfg, err := config.Load("../../config/config.example.yaml")
 	if err != nil {
@@ -49,6 +50,12 @@ func TestSFClientMaker(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// The example configuration is for a sandbox, so production orgs are refused.
+	et.InstanceURL = "https://example.my.salesforce.com"
+	if _, err = sfClientMaker(t.Context(), cfg, slog.Default(), et); err == nil {
+		t.Error("expected an error for a production instance")
+	}
+
 	// self-evident.
 	// if _, ok := sf.(sfClienter); !ok {
 	// 	t.Errorf("got type %T from sfClientMaker call", sf)
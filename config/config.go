@@ -3,15 +3,22 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"net/url"
 	"os"
 	"regexp"
 	"slices"
 	"strings"
 	"time"
+	_ "time/tzdata" // the timezones are embedded for systems without a zoneinfo database
 
+	"github.com/rorycl/reconciler/internal/i18n"
+	"github.com/rorycl/reconciler/internal/money"
 	"golang.org/x/oauth2"
 	"gopkg.in/yaml.v2"
 )
@@ -30,11 +37,34 @@ type Config struct {
 	DataStartDateStr        string   `yaml:"data_date_start"`
 	DonationAccountPrefixes []string `yaml:"donation_account_prefixes"`
 
+	// Timezone is the IANA timezone of the organisation, such as "Europe/London",
+	// defaulting to UTC. Today's date, such as the end of a report period or of the
+	// "this-fy" date range, is the date in this timezone, and times are shown in it.
+	// Dates are stored as UTC dates and times as UTC times.
+	Timezone string         `yaml:"timezone"`
+	Location *time.Location // Parsed from Timezone
+
+	// MockAPIs serves canned Xero and Salesforce responses in place of the platforms,
+	// for demonstrations without credentials. See the mockapi package.
+	MockAPIs bool `yaml:"mock_apis"`
+
+	// Profiles are the named organisation profiles, each replacing some of the settings
+	// above and below, such as the credentials, for one organisation. DefaultProfile is
+	// used if no profile is selected, and Profile is the profile loaded, if any.
+	Profiles       map[string]ProfileConfig `yaml:"profiles"`
+	DefaultProfile string                   `yaml:"default_profile"`
+	Profile        string                   `yaml:"-"`
+
 	// subsections
-	Web           WebConfig        `yaml:"web"`
-	Xero          XeroConfig       `yaml:"xero"`
-	Salesforce    SalesforceConfig `yaml:"salesforce"`
-	DataStartDate time.Time        // Parsed from DataStartDateStr
+	Web            WebConfig            `yaml:"web"`
+	Xero           XeroConfig           `yaml:"xero"`
+	Salesforce     SalesforceConfig     `yaml:"salesforce"`
+	GiftAid        GiftAidConfig        `yaml:"gift_aid"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Backups        BackupConfig         `yaml:"backups"`
+	Reconciliation ReconciliationConfig `yaml:"reconciliation"`
+	Logging        LogConfig            `yaml:"logging"`
+	DataStartDate  time.Time            // Parsed from DataStartDateStr
 }
 
 // WebConfig holds settings specific to the web server.
@@ -47,32 +77,273 @@ type WebConfig struct {
 	// full addresses to callbacks
 	XeroCallBackAddr       string
 	SalesforceCallBackAddr string
+	// Optional security header settings
+	SecurityHeaders SecurityHeadersConfig `yaml:"security_headers"`
+	// Optional default locale of the web templates, such as "en-GB", which users may
+	// override for their session
+	Locale string `yaml:"locale"`
+	// Optional default theme, ThemeLight or ThemeDark, which users may toggle for their
+	// session
+	Theme string `yaml:"theme"`
+	// Optional limit on the time taken to serve a request, including any data refresh
+	RequestTimeoutStr string        `yaml:"request_timeout"`
+	RequestTimeout    time.Duration // Parsed from RequestTimeoutStr
+	// Optional default page length of the listing pages, being one of PageLengths,
+	// which users may change for their session
+	PageLength int `yaml:"page_length"`
+	// Optional names of the team members to whom unreconciled records may be assigned.
+	// Users choose which of them they are for their session
+	Users []string `yaml:"users"`
+}
+
+// PageLengths are the page lengths of the listing pages. The first is the default
+// web.page_length.
+var PageLengths = []int{15, 25, 50, 100}
+
+// DefaultRequestTimeout is the default web.request_timeout. It is shorter than the
+// server write timeout, so that a timed out request can still report an error.
+const DefaultRequestTimeout = 4 * time.Minute
+
+// DefaultStatementTimeout is the default database.statement_timeout.
+const DefaultStatementTimeout = 30 * time.Second
+
+// DefaultCacheTTL is the default database.cache_ttl.
+const DefaultCacheTTL = time.Minute
+
+// Web themes.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// SecurityHeadersConfig holds the security headers set on each web response. Empty
+// values are set to the defaults below. The string "{nonce}" in the content security
+// policy is replaced with a per-request nonce, which templates can use for inline
+// scripts.
+type SecurityHeadersConfig struct {
+	Disabled              bool   `yaml:"disabled"`
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	FrameOptions          string `yaml:"frame_options"`
+	ReferrerPolicy        string `yaml:"referrer_policy"`
+	PermissionsPolicy     string `yaml:"permissions_policy"`
 }
 
-// XeroConfig holds Xero-specific settings.
+// Security header defaults.
+const (
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; object-src 'none'; " +
+		"base-uri 'self'; form-action 'self'; frame-ancestors 'none'"
+	DefaultFrameOptions      = "DENY"
+	DefaultReferrerPolicy    = "same-origin"
+	DefaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=()"
+)
+
+// XeroConfig holds Xero-specific settings. The TenantID, if set, is the Xero
+// organisation connected to, in place of the first organisation authorised by the
+// login.
 type XeroConfig struct {
 	ClientID     string   `yaml:"client_id"`
 	ClientSecret string   `yaml:"client_secret"`
+	TenantID     string   `yaml:"tenant_id"`
 	Scopes       []string `yaml:"-"`
 	OAuth2Config *oauth2.Config
+	// WriteInvoiceReferences allows the payout reference of linked donations to be
+	// written to the Reference field of Xero invoices, which requires the invoices
+	// write scope.
+	WriteInvoiceReferences bool `yaml:"write_invoice_references"`
 }
 
 // SalesforceConfig holds Salesforce-specific settings.
 type SalesforceConfig struct {
 	LoginDomain  string   `yaml:"login_domain"`
+	Environment  string   `yaml:"environment"` // sandbox or production
 	ClientID     string   `yaml:"client_id"`
 	ClientSecret string   `yaml:"client_secret"`
 	Scopes       []string `yaml:"-"`
 	OAuth2Config *oauth2.Config
-	// SOQL settings.
+	// SOQL settings. The CoreFields name the fields of the queried object holding the
+	// name, amount and close date of each donation, keyed by CoreFieldNames, and
+	// default to those of Opportunity. The payout reference is read from and written
+	// to the LinkingFieldName of the LinkingObject, which is the queried object.
 	Query            string            `yaml:"query"`
+	CoreFields       map[string]string `yaml:"core_fields"`
 	FieldMappings    map[string]string `yaml:"field_mappings"`
 	LinkingObject    string            `yaml:"linking_object"`
 	LinkingFieldName string            `yaml:"linking_field_name"`
+	// Optional Change Data Capture subscription.
+	SubscribeChanges bool `yaml:"subscribe_changes"`
+	// Query paging settings. QueryBatchSize is the number of records requested in each
+	// page of a query, between 200 and 2000. MaxRecords is the maximum number of
+	// records a query may match.
+	QueryBatchSize int `yaml:"query_batch_size"`
+	MaxRecords     int `yaml:"max_records"`
+	// OutboxRetryInterval is how often the link updates queued while Salesforce was
+	// unreachable are retried.
+	OutboxRetryIntervalStr string        `yaml:"outbox_retry_interval"`
+	OutboxRetryInterval    time.Duration // Parsed from OutboxRetryIntervalStr
+}
+
+// DefaultOutboxRetryInterval is the default salesforce.outbox_retry_interval.
+const DefaultOutboxRetryInterval = 5 * time.Minute
+
+// GiftAidConfig holds the optional settings for Gift Aid claim exports for UK
+// charities. The fields are Salesforce donation fields, named as in the
+// salesforce.field_mappings or as selected in salesforce.query if not mapped. Gift
+// Aid exports are enabled if the EligibleField is set.
+type GiftAidConfig struct {
+	// AccountPrefixes are the prefixes of the account codes recording income eligible
+	// for Gift Aid, defaulting to the donation_account_prefixes.
+	AccountPrefixes []string `yaml:"account_prefixes"`
+	EligibleField   string   `yaml:"eligible_field"`
+	TitleField      string   `yaml:"title_field"`
+	FirstNameField  string   `yaml:"first_name_field"`
+	LastNameField   string   `yaml:"last_name_field"`
+	HouseField      string   `yaml:"house_field"`
+	PostcodeField   string   `yaml:"postcode_field"`
+}
+
+// DatabaseConfig holds the optional database instrumentation settings. If
+// ExplainQueries is set the query plans of the sql statements are logged at startup.
+// Queries taking longer than the slow query threshold are logged and listed at
+// /debug/queries. The Path, usually set by a profile, replaces the database file given
+// on the command line in development mode; the database is always in memory otherwise.
+// Files in the SQLOverrideDir shadow the embedded sql files of the same name. The
+// listing and report query results are cached for the CacheTTL; zero disables caching.
+type DatabaseConfig struct {
+	Path                  string        `yaml:"path"`
+	SQLOverrideDir        string        `yaml:"sql_override_dir"`
+	ExplainQueries        bool          `yaml:"explain_queries"`
+	SlowQueryThresholdStr string        `yaml:"slow_query_threshold"`
+	SlowQueryThreshold    time.Duration // Parsed from SlowQueryThresholdStr
+	StatementTimeoutStr   string        `yaml:"statement_timeout"`
+	StatementTimeout      time.Duration // Parsed from StatementTimeoutStr
+	CacheTTLStr           string        `yaml:"cache_ttl"`
+	CacheTTL              time.Duration // Parsed from CacheTTLStr
+}
+
+// BackupConfig holds the optional database backup settings. Backups are enabled if
+// the Directory is set, and are made every Interval if set, and before each data
+// refresh if BeforeRefresh is set. The most recent Keep backups are retained.
+type BackupConfig struct {
+	Directory     string        `yaml:"directory"`
+	Keep          int           `yaml:"keep"`
+	IntervalStr   string        `yaml:"interval"`
+	BeforeRefresh bool          `yaml:"before_refresh"`
+	Interval      time.Duration // Parsed from IntervalStr
+}
+
+// ReconciliationConfig holds the initial reconciliation tolerance, which may be
+// changed at /settings/reconciliation. A record is reconciled if its donation total
+// and the total of its linked donations differ by less than the larger of the
+// ToleranceAmount and the TolerancePercent of the donation total.
+type ReconciliationConfig struct {
+	ToleranceAmount  float64 `yaml:"tolerance_amount"`
+	TolerancePercent float64 `yaml:"tolerance_percent"`
+}
+
+// LogConfig holds the optional log file settings. The log file is written if File is
+// set, in addition to the console log, with messages of at least Level in the json or
+// text Format. The file is rotated when it reaches MaxSizeMB, keeping MaxBackups
+// rotated files.
+type LogConfig struct {
+	File       string     `yaml:"file"`
+	LevelStr   string     `yaml:"level"`
+	Format     string     `yaml:"format"`
+	MaxSizeMB  int        `yaml:"max_size_mb"`
+	MaxBackups int        `yaml:"max_backups"`
+	Level      slog.Level // Parsed from LevelStr
+}
+
+// The log file defaults.
+const (
+	DefaultLogLevel      = slog.LevelInfo
+	DefaultLogFormat     = "json"
+	DefaultLogMaxSizeMB  = 10
+	DefaultLogMaxBackups = 5
+)
+
+// DefaultBackupsKept is the default number of backups retained.
+const DefaultBackupsKept = 10
+
+// minBackupInterval is the minimum interval between scheduled backups.
+const minBackupInterval = time.Minute
+
+// ErrInvalid reports all the problems found in a configuration file, such as missing
+// settings, invalid values and unknown keys.
+type ErrInvalid struct {
+	Problems []string
+}
+
+func (e ErrInvalid) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  %s", strings.Join(e.Problems, "\n  "))
+}
+
+// problems collects the problems found validating a configuration, so that these may
+// be reported together.
+type problems []string
+
+// add adds a problem described by format and args.
+func (p *problems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// addErr adds err as a problem if it is not nil.
+func (p *problems) addErr(err error) {
+	if err != nil {
+		*p = append(*p, err.Error())
+	}
+}
+
+// err returns the problems as an ErrInvalid, or nil if there are none.
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return ErrInvalid{Problems: p}
 }
 
-// Load loads and validates the configuration from the given file path.
+// envVar matches the "${NAME}" and "${NAME:-default}" environment variable references
+// of a configuration file.
+var envVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces the environment variable references in the configuration file
+// contents with their values, or with their defaults if the variables are not set.
+// Secrets such as salesforce.client_secret may so be kept out of the file. References
+// to unset variables without a default are reported as problems. Comment lines are
+// left as they are.
+func interpolate(contents []byte, p *problems) []byte {
+	var out []byte
+	for line := range bytes.Lines(contents) {
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			out = append(out, line...)
+			continue
+		}
+		out = append(out, envVar.ReplaceAllFunc(line, func(ref []byte) []byte {
+			m := envVar.FindSubmatch(ref)
+			if v, ok := os.LookupEnv(string(m[1])); ok {
+				return []byte(v)
+			}
+			if bytes.Contains(ref, []byte(":-")) {
+				return m[2]
+			}
+			p.add("environment variable %s is not set", m[1])
+			return nil
+		})...)
+	}
+	return out
+}
+
+// Load loads and validates the configuration from the given file path, using the
+// default profile if there is one. See LoadProfile.
 func Load(filePath string) (*Config, error) {
+	return LoadProfile(filePath, "")
+}
+
+// LoadProfile loads and validates the configuration of the named profile from the
+// given file path, or of the default profile if profile is empty. Environment variable
+// references in the file are interpolated before it is parsed. The problems found with
+// the configuration are reported together as an ErrInvalid.
+func LoadProfile(filePath, profile string) (*Config, error) {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("config file does not exist: %s", filePath)
 	}
@@ -82,13 +353,24 @@ func Load(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	var p problems
+	configFile = interpolate(configFile, &p)
+
+	// Unknown keys and values of the wrong type are reported with the other problems,
+	// the remainder of the file having been parsed.
 	var cfg Config
-	err = yaml.Unmarshal(configFile, &cfg)
-	if err != nil {
+	err = yaml.UnmarshalStrict(configFile, &cfg)
+	if e, ok := errors.AsType[*yaml.TypeError](err); ok {
+		p = append(p, e.Errors...)
+	} else if err != nil {
 		return nil, fmt.Errorf("unable to parse YAML config file: %w", err)
 	}
 
-	if err := validateAndPrepare(&cfg); err != nil {
+	cfg.applyProfile(profile, &p)
+	if e, ok := errors.AsType[ErrInvalid](validateAndPrepare(&cfg)); ok {
+		p = append(p, e.Problems...)
+	}
+	if err := p.err(); err != nil {
 		return nil, err
 	}
 
@@ -97,38 +379,47 @@ func Load(filePath string) (*Config, error) {
 
 // validateAndPrepare checks for required fields and sets up derived values.
 func validateAndPrepare(c *Config) error {
+	var p problems
+	var err error
+
 	// General
 	if c.Organisation == "" {
-		return errors.New("organisation_name is missing")
+		p.add("organisation_name is missing")
 	}
 	if c.DataStartDateStr == "" {
-		return errors.New("date_range_start is missing")
-	}
-	parsedDate, err := time.Parse("2006-01-02", c.DataStartDateStr)
-	if err != nil {
-		return fmt.Errorf("invalid date_range_start format: %w", err)
+		p.add("data_date_start is missing")
+	} else if parsedDate, err := time.Parse("2006-01-02", c.DataStartDateStr); err != nil {
+		p.add("data_date_start %q is not a date such as '2025-04-01'", c.DataStartDateStr)
+	} else {
+		c.DataStartDate = parsedDate
 	}
-	c.DataStartDate = parsedDate
 	if len(c.DonationAccountPrefixes) < 1 {
-		return errors.New("at least one donation_account_prefix should be supplied")
+		p.add("at least one donation_account_prefixes entry should be supplied")
+	} else if r := c.DonationAccountCodesAsRegex(); r == nil {
+		// check the accounts regexp compiles.
+		p.add("donation_account_prefixes regexp did not compile: %v", c.DonationAccountCodesRegex())
 	}
-	// check the accounts regexp compiles.
-	if r := c.DonationAccountCodesAsRegex(); r == nil {
-		return fmt.Errorf("accounts regexp did not compile: %v", c.DonationAccountCodesRegex())
+
+	// Timezone, defaulting to UTC.
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	if c.Location, err = time.LoadLocation(c.Timezone); err != nil {
+		p.add("timezone %q is not a timezone such as 'Europe/London'", c.Timezone)
 	}
 
 	// Web
-	if c.Web.ListenAddress == "" {
-		return errors.New("web.listen_address is missing")
-	}
-	if !strings.Contains(c.Web.ListenAddress, "127.0.0.1") && !strings.Contains(c.Web.ListenAddress, "localhost") {
-		return errors.New("web.listen_address must be 127.0.0.1 or localhost")
+	switch {
+	case c.Web.ListenAddress == "":
+		p.add("web.listen_address is missing")
+	case !strings.Contains(c.Web.ListenAddress, "127.0.0.1") && !strings.Contains(c.Web.ListenAddress, "localhost"):
+		p.add("web.listen_address %q must be 127.0.0.1 or localhost", c.Web.ListenAddress)
 	}
 	if c.Web.XeroCallBack == "" {
-		return errors.New("web.xero_oauth2_callback is missing")
+		p.add("web.xero_oauth2_callback is missing")
 	}
 	if c.Web.SalesforceCallBack == "" {
-		return errors.New("web.salesforce_oauth2_callback is missing")
+		p.add("web.salesforce_oauth2_callback is missing")
 	}
 
 	// The full callback addresses are local (http rather than https) addresses.
@@ -137,23 +428,81 @@ func validateAndPrepare(c *Config) error {
 		c.Web.XeroCallBack,
 	)
 	if err != nil {
-		return fmt.Errorf("could not create full xero callback address: %w", err)
+		p.add("could not create full xero callback address: %v", err)
 	}
 	c.Web.SalesforceCallBackAddr, err = url.JoinPath(
 		fmt.Sprintf("http://%s", c.Web.ListenAddress),
 		c.Web.SalesforceCallBack,
 	)
 	if err != nil {
-		return fmt.Errorf("could not create full xero callback address: %w", err)
+		p.add("could not create full salesforce callback address: %v", err)
+	}
+
+	// Locale, defaulting to the i18n package default.
+	if c.Web.Locale == "" {
+		c.Web.Locale = i18n.DefaultLocale
+	}
+	if !i18n.Supported(c.Web.Locale) {
+		p.add("web.locale %q is not a supported locale", c.Web.Locale)
+	}
+
+	// Theme, defaulting to light.
+	switch c.Web.Theme {
+	case "":
+		c.Web.Theme = ThemeLight
+	case ThemeLight, ThemeDark:
+	default:
+		p.add("web.theme %q should be %q or %q", c.Web.Theme, ThemeLight, ThemeDark)
+	}
+
+	// Page length of the listing pages, defaulting to the first page length.
+	if c.Web.PageLength == 0 {
+		c.Web.PageLength = PageLengths[0]
+	}
+	if !slices.Contains(PageLengths, c.Web.PageLength) {
+		p.add("web.page_length %d should be one of %v", c.Web.PageLength, PageLengths)
+	}
+
+	// Team members, whose names are trimmed and must be unique.
+	for i, u := range c.Web.Users {
+		c.Web.Users[i] = strings.TrimSpace(u)
+		switch {
+		case c.Web.Users[i] == "":
+			p.add("web.users may not contain an empty name")
+		case slices.Contains(c.Web.Users[:i], c.Web.Users[i]):
+			p.add("web.users name %q is repeated", c.Web.Users[i])
+		}
+	}
+
+	// Request timeout.
+	c.Web.RequestTimeout, err = positiveDuration("web.request_timeout", c.Web.RequestTimeoutStr, DefaultRequestTimeout)
+	p.addErr(err)
+
+	// Security headers defaults.
+	sh := &c.Web.SecurityHeaders
+	if sh.ContentSecurityPolicy == "" {
+		sh.ContentSecurityPolicy = DefaultContentSecurityPolicy
+	}
+	if sh.FrameOptions == "" {
+		sh.FrameOptions = DefaultFrameOptions
+	}
+	if sh.ReferrerPolicy == "" {
+		sh.ReferrerPolicy = DefaultReferrerPolicy
+	}
+	if sh.PermissionsPolicy == "" {
+		sh.PermissionsPolicy = DefaultPermissionsPolicy
 	}
 
 	// Xero
 	xc := &c.Xero
+	if c.MockAPIs {
+		xc.ClientID = cmp.Or(xc.ClientID, "mock")
+	}
 	if xc.ClientID == "" {
-		return errors.New("xero.client_id is missing")
+		p.add("xero.client_id is missing")
 	}
 	if xc.ClientSecret != "" {
-		return errors.New("xero.client_secret should not be provided for Xero PKCE connections")
+		p.add("xero.client_secret should not be provided for Xero PKCE connections")
 	}
 	// Restrictive read-only scopes (for apps created from March 2, 2026).
 	// See https://developer.xero.com/documentation/guides/oauth2/scopes/#organisation-scopes
@@ -163,12 +512,15 @@ func validateAndPrepare(c *Config) error {
 		"accounting.settings.read",
 		"offline_access",
 	}
+	if xc.WriteInvoiceReferences {
+		xc.Scopes[0] = "accounting.invoices"
+	}
 
 	if len(xc.Scopes) < 1 {
-		return errors.New("xero.scopes not defined")
+		p.add("xero.scopes not defined")
 	}
 	if !slices.Contains(xc.Scopes, "offline_access") {
-		return errors.New("xero.scopes does not contain 'offline_access' scope")
+		p.add("xero.scopes does not contain 'offline_access' scope")
 	}
 
 	xc.OAuth2Config = &oauth2.Config{
@@ -184,27 +536,47 @@ func validateAndPrepare(c *Config) error {
 
 	// Salesforce
 	sc := &c.Salesforce
+	if c.MockAPIs {
+		sc.ClientID = cmp.Or(sc.ClientID, "mock")
+		sc.ClientSecret = cmp.Or(sc.ClientSecret, "mock")
+	}
 	if sc.ClientID == "" {
-		return errors.New("salesforce.client_id is missing")
+		p.add("salesforce.client_id is missing")
 	}
 	if sc.ClientSecret == "" {
-		return errors.New("salesforce.client_secret is missing")
+		p.add("salesforce.client_secret is missing")
 	}
 	if sc.LoginDomain == "" {
-		return errors.New("salesforce.login_domain is missing")
-	}
-	if sc.Query == "" {
-		return errors.New("salesforce.query is missing")
-	}
-	if strings.Contains(strings.ToLower(sc.Query), "where") {
-		return errors.New("salesforce.query may not provide a WHERE clause which is added by the program")
-	}
-	sc.Query += "\n  WHERE {{.WhereClause}}"
-	if sc.LinkingObject == "" {
-		return errors.New("salesforce.linking_object is missing")
+		p.add("salesforce.login_domain is missing")
+	} else {
+		p.addErr(sc.setEnvironment())
+	}
+	// The query checks depend on each other, so only the first problem is reported.
+	switch {
+	case sc.Query == "":
+		p.add("salesforce.query is missing")
+	case strings.Contains(strings.ToLower(sc.Query), "where"):
+		p.add("salesforce.query may not provide a WHERE clause which is added by the program")
+	case sc.QueryObject() == "":
+		p.add("salesforce.query has no FROM object")
+	default:
+		p.addErr(sc.validateFieldMappings())
+		p.addErr(sc.validateCoreFields())
+		sc.Query += "\n  WHERE {{.WhereClause}}"
+	}
+	p.addErr(sc.validateQueryPaging())
+	sc.OutboxRetryInterval, err = positiveDuration("salesforce.outbox_retry_interval", sc.OutboxRetryIntervalStr, DefaultOutboxRetryInterval)
+	p.addErr(err)
+	// The queried records are those linked, so the linking object defaults to, and
+	// must be, the queried object.
+	switch object := sc.QueryObject(); {
+	case sc.LinkingObject == "":
+		sc.LinkingObject = object
+	case object != "" && !strings.EqualFold(sc.LinkingObject, object):
+		p.add("salesforce.linking_object %q must be the queried object %q", sc.LinkingObject, object)
 	}
 	if sc.LinkingFieldName == "" {
-		return errors.New("salesforce.linking_field_name is missing")
+		p.add("salesforce.linking_field_name is missing")
 	}
 	// Required salesforce scopes.
 	sc.Scopes = []string{
@@ -212,10 +584,10 @@ func validateAndPrepare(c *Config) error {
 		"refresh_token",
 	}
 	if !slices.Contains(sc.Scopes, "api") {
-		return errors.New("salesforce.scopes does not contain 'api' scope")
+		p.add("salesforce.scopes does not contain 'api' scope")
 	}
 	if !slices.Contains(sc.Scopes, "refresh_token") {
-		return errors.New("salesforce.scopes does not contain 'refresh_token' scope")
+		p.add("salesforce.scopes does not contain 'refresh_token' scope")
 	}
 	sc.OAuth2Config = &oauth2.Config{
 		ClientID:     sc.ClientID,
@@ -228,6 +600,149 @@ func validateAndPrepare(c *Config) error {
 		Scopes: sc.Scopes,
 	}
 
+	// Gift Aid
+	if c.GiftAid.Enabled() {
+		if len(c.GiftAid.AccountPrefixes) == 0 {
+			c.GiftAid.AccountPrefixes = c.DonationAccountPrefixes
+		}
+		p.addErr(c.GiftAid.validateFields(sc))
+		if r := c.GiftAid.AccountCodesAsRegex(); r == nil {
+			p.add("gift_aid accounts regexp did not compile: %v", c.GiftAid.AccountCodesRegex())
+		}
+	}
+
+	// Database
+	p.addErr(c.Database.validate())
+	p.addErr(c.Backups.validate())
+	p.addErr(c.Reconciliation.validate())
+	p.addErr(c.Logging.validate())
+
+	return p.err()
+}
+
+// validate checks the sql override directory and parses the slow query threshold, the
+// statement timeout and the cache ttl.
+func (d *DatabaseConfig) validate() error {
+	if d.SQLOverrideDir != "" {
+		s, err := os.Stat(d.SQLOverrideDir)
+		if err != nil || !s.IsDir() {
+			return fmt.Errorf("database.sql_override_dir %q is not a directory", d.SQLOverrideDir)
+		}
+	}
+	var err error
+	d.StatementTimeout, err = positiveDuration("database.statement_timeout", d.StatementTimeoutStr, DefaultStatementTimeout)
+	if err != nil {
+		return err
+	}
+	d.CacheTTL = DefaultCacheTTL
+	if d.CacheTTLStr != "" {
+		ttl, err := time.ParseDuration(d.CacheTTLStr)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("database.cache_ttl %q is not a duration such as '1m', or '0s' to disable caching", d.CacheTTLStr)
+		}
+		d.CacheTTL = ttl
+	}
+	if d.SlowQueryThresholdStr == "" {
+		d.SlowQueryThreshold = 0
+		return nil
+	}
+	threshold, err := time.ParseDuration(d.SlowQueryThresholdStr)
+	if err != nil || threshold <= 0 {
+		return fmt.Errorf("database.slow_query_threshold %q is not a positive duration such as '200ms'", d.SlowQueryThresholdStr)
+	}
+	d.SlowQueryThreshold = threshold
+	return nil
+}
+
+// positiveDuration parses the duration s of the setting name, returning def if s is
+// empty.
+func positiveDuration(name, s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s %q is not a positive duration such as '30s'", name, s)
+	}
+	return d, nil
+}
+
+// Enabled reports if the log file is configured.
+func (l LogConfig) Enabled() bool {
+	return l.File != ""
+}
+
+// validate sets the log file defaults and parses the log level.
+func (l *LogConfig) validate() error {
+	if !l.Enabled() {
+		return nil
+	}
+	l.Level = DefaultLogLevel
+	if l.LevelStr != "" {
+		if err := l.Level.UnmarshalText([]byte(l.LevelStr)); err != nil {
+			return fmt.Errorf("logging.level %q is not one of 'error', 'warn', 'info' or 'debug'", l.LevelStr)
+		}
+	}
+	switch l.Format {
+	case "":
+		l.Format = DefaultLogFormat
+	case "json", "text":
+	default:
+		return fmt.Errorf("logging.format %q is not 'json' or 'text'", l.Format)
+	}
+	switch {
+	case l.MaxSizeMB < 0:
+		return fmt.Errorf("logging.max_size_mb %d may not be negative", l.MaxSizeMB)
+	case l.MaxSizeMB == 0:
+		l.MaxSizeMB = DefaultLogMaxSizeMB
+	}
+	switch {
+	case l.MaxBackups < 0:
+		return fmt.Errorf("logging.max_backups %d may not be negative", l.MaxBackups)
+	case l.MaxBackups == 0:
+		l.MaxBackups = DefaultLogMaxBackups
+	}
+	return nil
+}
+
+// Enabled reports if backups are configured.
+func (b BackupConfig) Enabled() bool {
+	return b.Directory != ""
+}
+
+// validate sets the backup defaults and parses the backup interval.
+func (b *BackupConfig) validate() error {
+	if !b.Enabled() {
+		return nil
+	}
+	if b.Keep < 0 {
+		return fmt.Errorf("backups.keep %d may not be negative", b.Keep)
+	}
+	if b.Keep == 0 {
+		b.Keep = DefaultBackupsKept
+	}
+	b.Interval = 0
+	if b.IntervalStr != "" {
+		interval, err := time.ParseDuration(b.IntervalStr)
+		if err != nil || interval < minBackupInterval {
+			return fmt.Errorf("backups.interval %q is not a duration of at least %s, such as '24h'", b.IntervalStr, minBackupInterval)
+		}
+		b.Interval = interval
+	}
+	return nil
+}
+
+// validate checks the reconciliation tolerance.
+func (r ReconciliationConfig) validate() error {
+	if r.ToleranceAmount < 0 {
+		return fmt.Errorf("reconciliation.tolerance_amount %g may not be negative", r.ToleranceAmount)
+	}
+	if _, err := money.ParseFloat(r.ToleranceAmount); err != nil {
+		return fmt.Errorf("reconciliation.tolerance_amount: %w", err)
+	}
+	if r.TolerancePercent < 0 || r.TolerancePercent > 100 {
+		return fmt.Errorf("reconciliation.tolerance_percent %g must be between 0 and 100", r.TolerancePercent)
+	}
 	return nil
 }
 
@@ -243,3 +758,263 @@ func (c *Config) DonationAccountCodesAsRegex() *regexp.Regexp {
 	r, _ := regexp.Compile(c.DonationAccountCodesRegex())
 	return r
 }
+
+// Salesforce environments.
+const (
+	SalesforceSandbox    = "sandbox"
+	SalesforceProduction = "production"
+)
+
+// salesforceSandboxHost matches the hosts of Salesforce sandbox login domains and
+// instances, such as test.salesforce.com, example--uat.sandbox.my.salesforce.com and
+// the legacy cs42.salesforce.com instances.
+var salesforceSandboxHost = regexp.MustCompile(`(?i)(^test\.salesforce\.com$|\.sandbox\.|--|^cs\d+\.)`)
+
+// setEnvironment checks the Salesforce environment against the login domain. If the
+// environment is not set, it is set to sandbox for sandbox login domains and
+// otherwise to production.
+func (s *SalesforceConfig) setEnvironment() error {
+	sandboxDomain := salesforceSandboxHost.MatchString(s.LoginDomain)
+	switch s.Environment {
+	case "":
+		s.Environment = SalesforceProduction
+		if sandboxDomain {
+			s.Environment = SalesforceSandbox
+		}
+	case SalesforceSandbox:
+		if strings.EqualFold(s.LoginDomain, "login.salesforce.com") {
+			return fmt.Errorf("salesforce.environment is sandbox but salesforce.login_domain is %s", s.LoginDomain)
+		}
+	case SalesforceProduction:
+		if sandboxDomain {
+			return fmt.Errorf("salesforce.environment is production but salesforce.login_domain %s is a sandbox domain", s.LoginDomain)
+		}
+	default:
+		return fmt.Errorf("salesforce.environment %q should be %q or %q", s.Environment, SalesforceSandbox, SalesforceProduction)
+	}
+	return nil
+}
+
+// IsSandbox reports if the Salesforce environment is a sandbox.
+func (s SalesforceConfig) IsSandbox() bool {
+	return s.Environment == SalesforceSandbox
+}
+
+// CheckInstanceURL checks that a Salesforce instance url, as provided with an OAuth2
+// token, is in the configured environment. This prevents a sandbox configuration,
+// for example with a My Domain login domain, being used with a production org. No
+// check is made if the environment is not set.
+func (s SalesforceConfig) CheckInstanceURL(instanceURL string) error {
+	if s.Environment == "" {
+		return nil
+	}
+	u, err := url.Parse(instanceURL)
+	if err != nil {
+		return fmt.Errorf("invalid salesforce instance url %q: %w", instanceURL, err)
+	}
+	if sandbox := salesforceSandboxHost.MatchString(u.Hostname()); sandbox != s.IsSandbox() {
+		return fmt.Errorf("salesforce instance %s is not in the configured %s environment", u.Hostname(), s.Environment)
+	}
+	return nil
+}
+
+// soqlSelectFrom parses the field list and object from a SOQL "SELECT ... FROM object"
+// query.
+var soqlSelectFrom = regexp.MustCompile(`(?is)^\s*SELECT\s+(.+?)\s+FROM\s+(\w+)`)
+
+// QueryFields returns the fields selected by the SOQL query.
+func (s SalesforceConfig) QueryFields() []string {
+	m := soqlSelectFrom.FindStringSubmatch(s.Query)
+	if m == nil {
+		return nil
+	}
+	var fields []string
+	for f := range strings.SplitSeq(m[1], ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// QueryObject returns the Salesforce object queried by the SOQL query, such as
+// "Opportunity".
+func (s SalesforceConfig) QueryObject() string {
+	m := soqlSelectFrom.FindStringSubmatch(s.Query)
+	if m == nil {
+		return ""
+	}
+	return m[2]
+}
+
+// The core fields of the donation records.
+const (
+	CoreFieldName      = "Name"
+	CoreFieldAmount    = "Amount"
+	CoreFieldCloseDate = "CloseDate"
+)
+
+// CoreFieldNames are the keys of the salesforce.core_fields, which default to the
+// Opportunity fields of the same name.
+var CoreFieldNames = []string{CoreFieldName, CoreFieldAmount, CoreFieldCloseDate}
+
+// CoreField returns the field of the queried object holding the core field name, which
+// is one of CoreFieldNames.
+func (s SalesforceConfig) CoreField(name string) string {
+	if f := s.CoreFields[name]; f != "" {
+		return f
+	}
+	return name
+}
+
+// validateCoreFields sets the core field defaults and checks that each core field is a
+// field of the queried object selected by the query. The linking field, holding the
+// payout reference, must also be selected.
+func (s *SalesforceConfig) validateCoreFields() error {
+	for _, name := range slices.Sorted(maps.Keys(s.CoreFields)) {
+		if !slices.Contains(CoreFieldNames, name) {
+			return fmt.Errorf("salesforce.core_fields %q should be one of %v", name, CoreFieldNames)
+		}
+	}
+	selected := map[string]bool{}
+	for _, f := range s.QueryFields() {
+		selected[strings.ToLower(f)] = true
+	}
+	fields := map[string]string{}
+	for _, name := range CoreFieldNames {
+		field := s.CoreField(name)
+		if strings.Contains(field, ".") {
+			return fmt.Errorf("salesforce.core_fields %s field %q must be a field of the queried object", name, field)
+		}
+		if !selected[strings.ToLower(field)] {
+			return fmt.Errorf("salesforce.core_fields %s field %q is not selected in salesforce.query", name, field)
+		}
+		fields[name] = field
+	}
+	s.CoreFields = fields
+	if s.LinkingFieldName != "" && !selected[strings.ToLower(s.LinkingFieldName)] {
+		return fmt.Errorf("salesforce.linking_field_name %q is not selected in salesforce.query", s.LinkingFieldName)
+	}
+	return nil
+}
+
+// Salesforce query paging defaults and limits.
+const (
+	DefaultQueryBatchSize = 2000
+	minQueryBatchSize     = 200
+	DefaultMaxRecords     = 100000
+)
+
+// validateQueryPaging sets the query batch size and maximum records defaults and checks
+// the batch size is within the limits accepted by Salesforce.
+func (s *SalesforceConfig) validateQueryPaging() error {
+	if s.QueryBatchSize == 0 {
+		s.QueryBatchSize = DefaultQueryBatchSize
+	}
+	if s.QueryBatchSize < minQueryBatchSize || s.QueryBatchSize > DefaultQueryBatchSize {
+		return fmt.Errorf("salesforce.query_batch_size %d must be between %d and %d", s.QueryBatchSize, minQueryBatchSize, DefaultQueryBatchSize)
+	}
+	if s.MaxRecords == 0 {
+		s.MaxRecords = DefaultMaxRecords
+	}
+	if s.MaxRecords < 0 {
+		return fmt.Errorf("salesforce.max_records %d may not be negative", s.MaxRecords)
+	}
+	return nil
+}
+
+// validateFieldMappings checks that each field mapping refers to a field selected by the
+// query and that the mapped names are not empty or duplicated. Checking that the fields
+// exist on the Salesforce object requires an API connection; see
+// salesforce.Client.ValidateFieldMappings.
+func (s SalesforceConfig) validateFieldMappings() error {
+	selected := map[string]bool{}
+	for _, f := range s.QueryFields() {
+		selected[strings.ToLower(f)] = true
+	}
+	mapped := map[string]string{}
+	for _, field := range slices.Sorted(maps.Keys(s.FieldMappings)) {
+		name := s.FieldMappings[field]
+		if !selected[strings.ToLower(field)] {
+			return fmt.Errorf("salesforce.field_mappings field %q is not selected in salesforce.query", field)
+		}
+		if name == "" {
+			return fmt.Errorf("salesforce.field_mappings field %q has no mapped name", field)
+		}
+		if other, ok := mapped[name]; ok {
+			return fmt.Errorf("salesforce.field_mappings fields %q and %q are both mapped to %q", other, field, name)
+		}
+		mapped[name] = field
+	}
+	return nil
+}
+
+// Enabled reports if Gift Aid claim exports are configured.
+func (g GiftAidConfig) Enabled() bool {
+	return g.EligibleField != ""
+}
+
+// AccountCodesRegex returns the Gift Aid account prefixes as a string suitable for a
+// regex expression for SQLite.
+func (g GiftAidConfig) AccountCodesRegex() string {
+	return fmt.Sprintf("^(%s)", strings.Join(g.AccountPrefixes, "|"))
+}
+
+// AccountCodesAsRegex returns the Gift Aid account prefixes as a compiled regex. A nil
+// regexp is an error.
+func (g GiftAidConfig) AccountCodesAsRegex() *regexp.Regexp {
+	r, _ := regexp.Compile(g.AccountCodesRegex())
+	return r
+}
+
+// validateFields checks that the donor name, house and postcode fields required by
+// HMRC are set, and that each field is a mapped or selected Salesforce field.
+func (g GiftAidConfig) validateFields(sc *SalesforceConfig) error {
+	known := map[string]bool{}
+	for _, f := range sc.QueryFields() {
+		known[strings.ToLower(f)] = true
+	}
+	for _, name := range sc.FieldMappings {
+		known[strings.ToLower(name)] = true
+	}
+	fields := []struct {
+		key      string
+		value    string
+		required bool
+	}{
+		{"eligible_field", g.EligibleField, true},
+		{"title_field", g.TitleField, false},
+		{"first_name_field", g.FirstNameField, true},
+		{"last_name_field", g.LastNameField, true},
+		{"house_field", g.HouseField, true},
+		{"postcode_field", g.PostcodeField, true},
+	}
+	for _, f := range fields {
+		if f.value == "" {
+			if f.required {
+				return fmt.Errorf("gift_aid.%s is missing", f.key)
+			}
+			continue
+		}
+		if !known[strings.ToLower(f.value)] {
+			return fmt.Errorf("gift_aid.%s %q is not a salesforce.field_mappings name or salesforce.query field", f.key, f.value)
+		}
+	}
+	return nil
+}
+
+// Today returns today's date in the organisation timezone. See Date.
+func (c *Config) Today() time.Time {
+	return Date(time.Now(), c.Location)
+}
+
+// Date returns the date of t in the timezone loc, or in UTC if loc is nil, as a UTC
+// date, being midnight UTC of that day. Dates are compared, formatted and stored as
+// UTC dates, so that a date is the same day wherever it is used.
+func Date(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
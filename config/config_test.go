@@ -1,6 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -37,6 +44,382 @@ func TestConfig(t *testing.T) {
 	}
 	config.Salesforce.Query = q
 
+	if got, want := config.Salesforce.QueryObject(), "Opportunity"; got != want {
+		t.Errorf("query object got %q want %q", got, want)
+	}
+	if got, want := len(config.Salesforce.QueryFields()), 12; got != want {
+		t.Errorf("query fields got %d want %d", got, want)
+	}
+
+}
+
+func TestConfigSalesforceEnvironment(t *testing.T) {
+
+	tests := []struct {
+		domain, env string
+		wantEnv     string
+		isErr       bool
+	}{
+		{"test.salesforce.com", "", SalesforceSandbox, false},
+		{"example--uat.sandbox.my.salesforce.com", "", SalesforceSandbox, false},
+		{"login.salesforce.com", "", SalesforceProduction, false},
+		{"example.my.salesforce.com", "sandbox", SalesforceSandbox, false},
+		{"login.salesforce.com", "sandbox", "", true},
+		{"test.salesforce.com", "production", "", true},
+		{"login.salesforce.com", "staging", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain+"_"+tt.env, func(t *testing.T) {
+			sc := SalesforceConfig{LoginDomain: tt.domain, Environment: tt.env}
+			err := sc.setEnvironment()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err == nil && sc.Environment != tt.wantEnv {
+				t.Errorf("environment got %q want %q", sc.Environment, tt.wantEnv)
+			}
+		})
+	}
+
+	sandbox := SalesforceConfig{Environment: SalesforceSandbox}
+	production := SalesforceConfig{Environment: SalesforceProduction}
+	for _, tt := range []struct {
+		sc    SalesforceConfig
+		url   string
+		isErr bool
+	}{
+		{sandbox, "https://example--uat.sandbox.my.salesforce.com", false},
+		{sandbox, "https://example.my.salesforce.com", true},
+		{production, "https://example.my.salesforce.com", false},
+		{production, "https://cs42.salesforce.com", true},
+		{SalesforceConfig{}, "https://example.my.salesforce.com", false},
+	} {
+		if err := tt.sc.CheckInstanceURL(tt.url); (err != nil) != tt.isErr {
+			t.Errorf("%s %s: error got %v want error %t", tt.sc.Environment, tt.url, err, tt.isErr)
+		}
+	}
+}
+
+func TestConfigFieldMappings(t *testing.T) {
+
+	query := "SELECT Id, Name, StageName, Account.Name FROM Opportunity"
+	tests := []struct {
+		name     string
+		mappings map[string]string
+		isErr    bool
+	}{
+		{"ok", map[string]string{"StageName": "Stage", "account.name": "Account"}, false},
+		{"not selected", map[string]string{"RecordType.Name": "RecordType"}, true},
+		{"empty name", map[string]string{"StageName": ""}, true},
+		{"duplicate name", map[string]string{"StageName": "X", "Account.Name": "X"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := SalesforceConfig{Query: query, FieldMappings: tt.mappings}
+			err := sc.validateFieldMappings()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Errorf("error got %v want error %t", err, want)
+			}
+		})
+	}
+}
+
+func TestConfigCoreFields(t *testing.T) {
+
+	query := "SELECT Id, Name, npsp__Amount__c, npsp__Payment_Date__c, Payout_Reference__c, " +
+		"Account.Name FROM npsp__Payment__c"
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   map[string]string
+		isErr  bool
+	}{
+		{
+			"ok",
+			map[string]string{"Amount": "npsp__Amount__c", "CloseDate": "npsp__Payment_Date__c"},
+			map[string]string{"Name": "Name", "Amount": "npsp__Amount__c", "CloseDate": "npsp__Payment_Date__c"},
+			false,
+		},
+		{"default not selected", nil, nil, true},
+		{"unknown core field", map[string]string{"Stage": "StageName"}, nil, true},
+		{"related field", map[string]string{"Name": "Account.Name", "Amount": "npsp__Amount__c", "CloseDate": "npsp__Payment_Date__c"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := SalesforceConfig{Query: query, CoreFields: tt.fields, LinkingFieldName: "Payout_Reference__c"}
+			err := sc.validateCoreFields()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.want, sc.CoreFields); diff != "" {
+				t.Errorf("core fields diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	sc := SalesforceConfig{Query: "SELECT Id, Name, Amount, CloseDate FROM Opportunity", LinkingFieldName: "Payout_Reference__c"}
+	if err := sc.validateCoreFields(); err == nil {
+		t.Error("expected error for an unselected linking field")
+	}
+}
+
+func TestConfigQueryPaging(t *testing.T) {
+	tests := []struct {
+		name      string
+		sc        SalesforceConfig
+		batchSize int
+		max       int
+		isErr     bool
+	}{
+		{"defaults", SalesforceConfig{}, DefaultQueryBatchSize, DefaultMaxRecords, false},
+		{"set", SalesforceConfig{QueryBatchSize: 500, MaxRecords: 20000}, 500, 20000, false},
+		{"batch size too small", SalesforceConfig{QueryBatchSize: 100}, 0, 0, true},
+		{"batch size too large", SalesforceConfig{QueryBatchSize: 2001}, 0, 0, true},
+		{"negative max records", SalesforceConfig{MaxRecords: -1}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sc.validateQueryPaging()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := tt.sc.QueryBatchSize, tt.batchSize; got != want {
+				t.Errorf("batch size got %d want %d", got, want)
+			}
+			if got, want := tt.sc.MaxRecords, tt.max; got != want {
+				t.Errorf("max records got %d want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigGiftAid(t *testing.T) {
+
+	sc := &SalesforceConfig{
+		Query: "SELECT Id, Name, Gift_Aid__c, npsp__Primary_Contact__r.Salutation, " +
+			"npsp__Primary_Contact__r.FirstName, npsp__Primary_Contact__r.LastName, " +
+			"npsp__Primary_Contact__r.MailingStreet, npsp__Primary_Contact__r.MailingPostalCode FROM Opportunity",
+		FieldMappings: map[string]string{
+			"npsp__Primary_Contact__r.FirstName":         "FirstName",
+			"npsp__Primary_Contact__r.LastName":          "LastName",
+			"npsp__Primary_Contact__r.MailingStreet":     "Street",
+			"npsp__Primary_Contact__r.MailingPostalCode": "Postcode",
+		},
+	}
+	valid := GiftAidConfig{
+		EligibleField:  "Gift_Aid__c",
+		FirstNameField: "FirstName",
+		LastNameField:  "LastName",
+		HouseField:     "Street",
+		PostcodeField:  "Postcode",
+	}
+
+	tests := []struct {
+		name  string
+		edit  func(g *GiftAidConfig)
+		isErr bool
+	}{
+		{"ok", func(g *GiftAidConfig) {}, false},
+		{"ok unmapped title", func(g *GiftAidConfig) { g.TitleField = "npsp__Primary_Contact__r.Salutation" }, false},
+		{"missing postcode", func(g *GiftAidConfig) { g.PostcodeField = "" }, true},
+		{"unknown field", func(g *GiftAidConfig) { g.HouseField = "House" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := valid
+			tt.edit(&g)
+			err := g.validateFields(sc)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Errorf("error got %v want error %t", err, want)
+			}
+		})
+	}
+
+	if (GiftAidConfig{}).Enabled() {
+		t.Error("expected gift aid to be disabled without an eligible field")
+	}
+	g := GiftAidConfig{AccountPrefixes: []string{"55", "57"}}
+	if got, want := g.AccountCodesRegex(), "^(55|57)"; got != want {
+		t.Errorf("account codes regex got %q want %q", got, want)
+	}
+}
+
+func TestConfigDatabase(t *testing.T) {
+	tests := []struct {
+		threshold string
+		want      time.Duration
+		isErr     bool
+	}{
+		{"", 0, false},
+		{"250ms", 250 * time.Millisecond, false},
+		{"1s", time.Second, false},
+		{"fast", 0, true},
+		{"-1s", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.threshold, func(t *testing.T) {
+			d := DatabaseConfig{SlowQueryThresholdStr: tt.threshold}
+			err := d.validate()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if got, want := d.SlowQueryThreshold, tt.want; got != want {
+				t.Errorf("threshold got %s want %s", got, want)
+			}
+		})
+	}
+
+	d := DatabaseConfig{SQLOverrideDir: t.TempDir()}
+	if err := d.validate(); err != nil {
+		t.Errorf("unexpected sql override dir error: %v", err)
+	}
+	d = DatabaseConfig{SQLOverrideDir: filepath.Join(t.TempDir(), "missing")}
+	if err := d.validate(); err == nil {
+		t.Error("expected an error for a missing sql override dir")
+	}
+
+	for ttl, want := range map[string]time.Duration{"": DefaultCacheTTL, "30s": 30 * time.Second, "0s": 0} {
+		d = DatabaseConfig{CacheTTLStr: ttl}
+		if err := d.validate(); err != nil {
+			t.Fatalf("cache ttl %q error: %v", ttl, err)
+		}
+		if got := d.CacheTTL; got != want {
+			t.Errorf("cache ttl %q got %s want %s", ttl, got, want)
+		}
+	}
+	d = DatabaseConfig{CacheTTLStr: "-1m"}
+	if err := d.validate(); err == nil {
+		t.Error("expected an error for a negative cache ttl")
+	}
+}
+
+func TestConfigTimeouts(t *testing.T) {
+
+	d := DatabaseConfig{}
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.StatementTimeout, DefaultStatementTimeout; got != want {
+		t.Errorf("default statement timeout got %s want %s", got, want)
+	}
+	d = DatabaseConfig{StatementTimeoutStr: "5s"}
+	if err := d.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.StatementTimeout, 5*time.Second; got != want {
+		t.Errorf("statement timeout got %s want %s", got, want)
+	}
+	d = DatabaseConfig{StatementTimeoutStr: "0s"}
+	if err := d.validate(); err == nil {
+		t.Error("expected an error for a zero statement timeout")
+	}
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filePath, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Web.RequestTimeout, DefaultRequestTimeout; got != want {
+		t.Errorf("default request timeout got %s want %s", got, want)
+	}
+
+	configured := bytes.Replace(example, []byte(`# request_timeout: "4m"`), []byte(`request_timeout: "soon"`), 1)
+	if err := os.WriteFile(filePath, configured, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(filePath); err == nil {
+		t.Error("expected an error for an invalid request timeout")
+	}
+}
+
+func TestConfigBackups(t *testing.T) {
+	tests := []struct {
+		name     string
+		backups  BackupConfig
+		keep     int
+		interval time.Duration
+		isErr    bool
+	}{
+		{"disabled", BackupConfig{IntervalStr: "bad"}, 0, 0, false},
+		{"defaults", BackupConfig{Directory: "backups"}, DefaultBackupsKept, 0, false},
+		{"scheduled", BackupConfig{Directory: "backups", Keep: 3, IntervalStr: "24h"}, 3, 24 * time.Hour, false},
+		{"negative keep", BackupConfig{Directory: "backups", Keep: -1}, 0, 0, true},
+		{"short interval", BackupConfig{Directory: "backups", IntervalStr: "1s"}, 0, 0, true},
+		{"invalid interval", BackupConfig{Directory: "backups", IntervalStr: "daily"}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.backups
+			err := b.validate()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := b.Keep, tt.keep; got != want {
+				t.Errorf("keep got %d want %d", got, want)
+			}
+			if got, want := b.Interval, tt.interval; got != want {
+				t.Errorf("interval got %s want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigLogging(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LogConfig
+		want    LogConfig
+		isErr   bool
+	}{
+		{"disabled", LogConfig{LevelStr: "loud"}, LogConfig{LevelStr: "loud"}, false},
+		{
+			"defaults",
+			LogConfig{File: "r.log"},
+			LogConfig{File: "r.log", Level: slog.LevelInfo, Format: "json", MaxSizeMB: DefaultLogMaxSizeMB, MaxBackups: DefaultLogMaxBackups},
+			false,
+		},
+		{
+			"set",
+			LogConfig{File: "r.log", LevelStr: "debug", Format: "text", MaxSizeMB: 1, MaxBackups: 2},
+			LogConfig{File: "r.log", LevelStr: "debug", Level: slog.LevelDebug, Format: "text", MaxSizeMB: 1, MaxBackups: 2},
+			false,
+		},
+		{"invalid level", LogConfig{File: "r.log", LevelStr: "loud"}, LogConfig{}, true},
+		{"invalid format", LogConfig{File: "r.log", Format: "xml"}, LogConfig{}, true},
+		{"negative size", LogConfig{File: "r.log", MaxSizeMB: -1}, LogConfig{}, true},
+		{"negative backups", LogConfig{File: "r.log", MaxBackups: -1}, LogConfig{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := tt.logging
+			err := l.validate()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err != nil {
+				return
+			}
+			if l != tt.want {
+				t.Errorf("got %+v want %+v", l, tt.want)
+			}
+		})
+	}
 }
 
 /*
@@ -57,6 +440,10 @@ func TestConfigDumpFile(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
 	want := &Config{
 		Organisation:     "My Organisation",
 		DataStartDateStr: "2025-04-01",
@@ -65,12 +452,24 @@ func TestConfigDumpFile(t *testing.T) {
 			"55",
 			"57",
 		},
+		Timezone: "Europe/London",
+		Location: london,
 		Web: WebConfig{
 			ListenAddress:          "localhost:8080",
 			XeroCallBack:           "/xero/callback",
 			SalesforceCallBack:     "/salesforce/callback",
 			XeroCallBackAddr:       "http://localhost:8080/xero/callback",
 			SalesforceCallBackAddr: "http://localhost:8080/salesforce/callback",
+			SecurityHeaders: SecurityHeadersConfig{
+				ContentSecurityPolicy: DefaultContentSecurityPolicy,
+				FrameOptions:          DefaultFrameOptions,
+				ReferrerPolicy:        DefaultReferrerPolicy,
+				PermissionsPolicy:     DefaultPermissionsPolicy,
+			},
+			Locale:         "en-GB",
+			Theme:          ThemeLight,
+			RequestTimeout: DefaultRequestTimeout,
+			PageLength:     15,
 		},
 		Xero: XeroConfig{
 			ClientID:     "XERO_CLIENT_ID",
@@ -101,6 +500,7 @@ func TestConfigDumpFile(t *testing.T) {
 		},
 		Salesforce: SalesforceConfig{
 			LoginDomain:  "test.salesforce.com",
+			Environment:  "sandbox",
 			ClientID:     "SALESFORCE_CONSUMER_KEY",
 			ClientSecret: "SALESFORCE_CONSUMER_SECRET",
 			Scopes: []string{ // p1
@@ -123,24 +523,89 @@ func TestConfigDumpFile(t *testing.T) {
 				},
 			},
 			Query: "SELECT\n  Id, Name, Amount, CloseDate, LastModifiedDate, Payout_Reference__c,\n  StageName, RecordType.Name, Account.Name,\n  CreatedBy.Name, CreatedDate, LastModifiedBy.Name\nFROM Opportunity\n  WHERE {{.WhereClause}}",
+			CoreFields: map[string]string{
+				"Amount":    "Amount",
+				"CloseDate": "CloseDate",
+				"Name":      "Name",
+			},
 			FieldMappings: map[string]string{
 				"Account.Name":        "Account",
 				"CreatedBy.Name":      "CreatedBy",
 				"LastModifiedBy.Name": "ModifiedBy",
 				"StageName":           "Stage",
 			},
-			LinkingObject:    "Opportunity",
-			LinkingFieldName: "Payout_Reference__c",
+			LinkingObject:          "Opportunity",
+			LinkingFieldName:       "Payout_Reference__c",
+			QueryBatchSize:         2000,
+			MaxRecords:             100000,
+			OutboxRetryIntervalStr: "5m",
+			OutboxRetryInterval:    DefaultOutboxRetryInterval,
 		},
+		Database:      DatabaseConfig{StatementTimeout: DefaultStatementTimeout, CacheTTL: DefaultCacheTTL},
 		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
 	}
 
-	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(Config{}, oauth2.Config{})); diff != "" {
+	sameLocation := cmp.Comparer(func(a, b *time.Location) bool { return a.String() == b.String() })
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(Config{}, oauth2.Config{}), sameLocation); diff != "" {
 		t.Errorf("unexpected diff:\n%s", diff)
 	}
 
 }
 
+func TestConfigTimezone(t *testing.T) {
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	for _, tt := range []struct {
+		timezone string
+		want     string
+		wantErr  bool
+	}{
+		{`timezone: "Europe/London"`, "Europe/London", false},
+		{`timezone: ""`, "UTC", false},
+		{`timezone: "Europe/Nowhere"`, "", true},
+	} {
+		configured := bytes.Replace(example, []byte(`timezone: "Europe/London"`), []byte(tt.timezone), 1)
+		if err := os.WriteFile(filePath, configured, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		config, err := Load(filePath)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.timezone)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := config.Location.String(); got != tt.want {
+			t.Errorf("%s: location got %s want %s", tt.timezone, got, tt.want)
+		}
+	}
+
+	// Late in the evening of 31 March in New York is already 1 April in UTC, and early
+	// on 1 April in London is still 31 March in UTC.
+	newYork, _ := time.LoadLocation("America/New_York")
+	london, _ := time.LoadLocation("Europe/London")
+	for _, tt := range []struct {
+		t    time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{time.Date(2025, 4, 1, 2, 0, 0, 0, time.UTC), newYork, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 31, 23, 30, 0, 0, time.UTC), london, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 31, 23, 30, 0, 0, time.UTC), nil, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+	} {
+		if got := Date(tt.t, tt.loc); !got.Equal(tt.want) || got.Location() != time.UTC {
+			t.Errorf("Date(%s, %s) got %s want %s", tt.t, tt.loc, got, tt.want)
+		}
+	}
+}
+
 func TestConfigRegexp(t *testing.T) {
 
 	c := &Config{
@@ -157,3 +622,357 @@ func TestConfigRegexp(t *testing.T) {
 		t.Error("expected c.DonationAccountCodesARegex error")
 	}
 }
+
+func TestConfigReconciliation(t *testing.T) {
+	tests := []struct {
+		name  string
+		r     ReconciliationConfig
+		isErr bool
+	}{
+		{"none", ReconciliationConfig{}, false},
+		{"amount and percent", ReconciliationConfig{ToleranceAmount: 0.5, TolerancePercent: 2.5}, false},
+		{"negative amount", ReconciliationConfig{ToleranceAmount: -1}, true},
+		{"amount too large", ReconciliationConfig{ToleranceAmount: 1e20}, true},
+		{"negative percent", ReconciliationConfig{TolerancePercent: -1}, true},
+		{"percent too large", ReconciliationConfig{TolerancePercent: 101}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.validate()
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Errorf("error got %v want error %t", err, want)
+			}
+		})
+	}
+}
+
+func TestConfigXeroWriteInvoiceReferences(t *testing.T) {
+
+	config, err := Load("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(config.Xero.Scopes, "accounting.invoices") {
+		t.Errorf("unexpected invoices write scope in %v", config.Xero.Scopes)
+	}
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	example = bytes.Replace(example, []byte("write_invoice_references: false"), []byte("write_invoice_references: true"), 1)
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filePath, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err = Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(config.Xero.OAuth2Config.Scopes, "accounting.invoices") {
+		t.Errorf("expected invoices write scope in %v", config.Xero.OAuth2Config.Scopes)
+	}
+	if slices.Contains(config.Xero.Scopes, "accounting.invoices.read") {
+		t.Errorf("unexpected invoices read scope in %v", config.Xero.Scopes)
+	}
+}
+
+func TestConfigLocale(t *testing.T) {
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		locale string
+		want   string
+		isErr  bool
+	}{
+		{"", "en-GB", false},
+		{"fr-FR", "fr-FR", false},
+		{"xx-XX", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			configured := example
+			if tt.locale != "" {
+				configured = bytes.Replace(example, []byte(`# locale: "en-GB"`), []byte(`locale: "`+tt.locale+`"`), 1)
+			}
+			filePath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(filePath, configured, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := Load(filePath)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err == nil && config.Web.Locale != tt.want {
+				t.Errorf("locale got %q want %q", config.Web.Locale, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigTheme(t *testing.T) {
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		theme string
+		want  string
+		isErr bool
+	}{
+		{"", ThemeLight, false},
+		{"dark", ThemeDark, false},
+		{"sepia", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.theme, func(t *testing.T) {
+			configured := example
+			if tt.theme != "" {
+				configured = bytes.Replace(example, []byte(`# theme: "light"`), []byte(`theme: "`+tt.theme+`"`), 1)
+			}
+			filePath := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(filePath, configured, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := Load(filePath)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if err == nil && config.Web.Theme != tt.want {
+				t.Errorf("theme got %q want %q", config.Web.Theme, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigMockAPIs(t *testing.T) {
+
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, credential := range []string{`"XERO_CLIENT_ID"`, `"SALESFORCE_CONSUMER_KEY"`, `"SALESFORCE_CONSUMER_SECRET"`} {
+		example = bytes.Replace(example, []byte(credential), []byte(`""`), 1)
+	}
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filePath, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(filePath); err == nil {
+		t.Fatal("expected missing credentials error")
+	}
+
+	example = bytes.Replace(example, []byte("mock_apis: false"), []byte("mock_apis: true"), 1)
+	if err := os.WriteFile(filePath, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.MockAPIs {
+		t.Error("mock apis not set")
+	}
+	if got, want := config.Salesforce.OAuth2Config.ClientSecret, "mock"; got != want {
+		t.Errorf("salesforce client secret got %q want %q", got, want)
+	}
+}
+
+// writeConfig writes the example configuration, with each of the old, new pairs of
+// replacements applied, to a temporary file.
+func writeConfig(t *testing.T, replacements ...string) string {
+	t.Helper()
+	example, err := os.ReadFile("config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r := range slices.Chunk(replacements, 2) {
+		if !bytes.Contains(example, []byte(r[0])) {
+			t.Fatalf("example config does not contain %q", r[0])
+		}
+		example = bytes.Replace(example, []byte(r[0]), []byte(r[1]), 1)
+	}
+	filePath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filePath, example, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestConfigProblems(t *testing.T) {
+
+	filePath := writeConfig(t,
+		`organisation_name: "My Organisation"`, `organisation_name: ""`,
+		`data_date_start: "2025-04-01"`, `data_date_start: "01/04/2025"`,
+		`# theme: "light"`, `theme: "blue"`,
+		`write_invoice_references: false`, `write_invoice_references: false
+  tenant: "unknown"`,
+		`  client_secret: "SALESFORCE_CONSUMER_SECRET"`, `  client_secret: ""`,
+	)
+	_, err := Load(filePath)
+	e, ok := errors.AsType[ErrInvalid](err)
+	if !ok {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+	want := []string{
+		"field tenant not found in type config.XeroConfig",
+		"organisation_name is missing",
+		"data_date_start \"01/04/2025\" is not a date such as '2025-04-01'",
+		"web.theme \"blue\" should be \"light\" or \"dark\"",
+		"salesforce.client_secret is missing",
+	}
+	if got := len(e.Problems); got != len(want) {
+		t.Fatalf("got %d problems want %d:\n%s", got, len(want), e)
+	}
+	for i, w := range want {
+		if !strings.Contains(e.Problems[i], w) {
+			t.Errorf("problem %d got %q want %q", i, e.Problems[i], w)
+		}
+	}
+}
+
+func TestConfigEnvironment(t *testing.T) {
+
+	t.Setenv("RECONCILER_SF_SECRET", "from-the-environment")
+	filePath := writeConfig(t,
+		`"SALESFORCE_CONSUMER_SECRET"`, `"${RECONCILER_SF_SECRET}"`,
+		`"SALESFORCE_CONSUMER_KEY"`, `"${RECONCILER_SF_KEY_UNSET:-default-key}"`,
+	)
+	config, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Salesforce.ClientSecret, "from-the-environment"; got != want {
+		t.Errorf("client secret got %q want %q", got, want)
+	}
+	if got, want := config.Salesforce.ClientID, "default-key"; got != want {
+		t.Errorf("client id got %q want %q", got, want)
+	}
+
+	filePath = writeConfig(t,
+		`"SALESFORCE_CONSUMER_SECRET"`, `"${RECONCILER_SF_SECRET_UNSET}"`,
+		`# locale: "en-GB"`, `# locale: "${RECONCILER_LOCALE_UNSET}"`,
+	)
+	_, err = Load(filePath)
+	e, ok := errors.AsType[ErrInvalid](err)
+	if !ok {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+	if want := []string{"environment variable RECONCILER_SF_SECRET_UNSET is not set", "salesforce.client_secret is missing"}; !slices.Equal(e.Problems, want) {
+		t.Errorf("problems got %q want %q", e.Problems, want)
+	}
+}
+
+func TestConfigPageLength(t *testing.T) {
+
+	config, err := Load(writeConfig(t, `# page_length: 15`, `page_length: 50`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Web.PageLength, 50; got != want {
+		t.Errorf("page length got %d want %d", got, want)
+	}
+	if _, err := Load(writeConfig(t, `# page_length: 15`, `page_length: 40`)); err == nil {
+		t.Error("expected an error for an invalid page length")
+	}
+}
+
+func TestConfigUsers(t *testing.T) {
+
+	config, err := Load(writeConfig(t, "  # users:\n", "  users: [\" Alex \", \"Sam\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Web.Users, []string{"Alex", "Sam"}; !slices.Equal(got, want) {
+		t.Errorf("users got %q want %q", got, want)
+	}
+	for _, users := range []string{`["Alex", "Alex"]`, `["Alex", " "]`} {
+		if _, err := Load(writeConfig(t, "  # users:\n", "  users: "+users+"\n")); err == nil {
+			t.Errorf("expected an error for users %s", users)
+		}
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+
+	profiles := `default_profile: "main"
+profiles:
+  main:
+    database_path: "main.db"
+  trading:
+    organisation_name: "Trading"
+    donation_account_prefixes:
+      - "41"
+    xero:
+      tenant_id: "trading-tenant"
+    salesforce:
+      login_domain: "login.salesforce.com"
+      client_id: "trading-key"
+#`
+	filePath := writeConfig(t, `# default_profile: "main"`, profiles)
+
+	tests := []struct {
+		profile      string
+		want         string
+		organisation string
+		accountCodes string
+		databasePath string
+		tenantID     string
+		environment  string
+		clientID     string
+	}{
+		{"", "main", "My Organisation", "^(53|55|57)", "main.db", "", "sandbox", "SALESFORCE_CONSUMER_KEY"},
+		{"main", "main", "My Organisation", "^(53|55|57)", "main.db", "", "sandbox", "SALESFORCE_CONSUMER_KEY"},
+		{"trading", "trading", "Trading", "^(41)", "", "trading-tenant", "production", "trading-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			cfg, err := LoadProfile(filePath, tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Profile; got != tt.want {
+				t.Errorf("profile got %q want %q", got, tt.want)
+			}
+			if got := cfg.Organisation; got != tt.organisation {
+				t.Errorf("organisation got %q want %q", got, tt.organisation)
+			}
+			if got := cfg.DonationAccountCodesRegex(); got != tt.accountCodes {
+				t.Errorf("account codes got %q want %q", got, tt.accountCodes)
+			}
+			if got := cfg.Database.Path; got != tt.databasePath {
+				t.Errorf("database path got %q want %q", got, tt.databasePath)
+			}
+			if got := cfg.Xero.TenantID; got != tt.tenantID {
+				t.Errorf("tenant id got %q want %q", got, tt.tenantID)
+			}
+			// The environment of the profile login domain is not inherited.
+			if got := cfg.Salesforce.Environment; got != tt.environment {
+				t.Errorf("environment got %q want %q", got, tt.environment)
+			}
+			if got := cfg.Salesforce.ClientID; got != tt.clientID {
+				t.Errorf("client id got %q want %q", got, tt.clientID)
+			}
+		})
+	}
+	cfg, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.ProfileNames(), []string{"main", "trading"}; !slices.Equal(got, want) {
+		t.Errorf("profile names got %q want %q", got, want)
+	}
+
+	// Unknown and invalid profile names are problems.
+	if _, err := LoadProfile(filePath, "charity"); err == nil || !strings.Contains(err.Error(), `profile "charity" is not one of`) {
+		t.Errorf("expected an unknown profile error, got %v", err)
+	}
+	invalid := writeConfig(t, `# default_profile: "main"`, "profiles:\n  Main:\n    database_path: \"main.db\"\n#")
+	if _, err := Load(invalid); err == nil || !strings.Contains(err.Error(), `"Main" should only contain`) {
+		t.Errorf("expected an invalid profile name error, got %v", err)
+	}
+}
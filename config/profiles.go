@@ -0,0 +1,84 @@
+package config
+
+// profiles.go provides named organisation profiles, so that one installation can
+// reconcile the records of several organisations, each with its own Xero organisation,
+// Salesforce org and database. The settings of a profile replace the base settings of
+// the configuration file.
+
+import (
+	"cmp"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// ProfileConfig holds the settings of a named profile. Settings which are not set are
+// taken from the base configuration.
+type ProfileConfig struct {
+	Organisation            string                  `yaml:"organisation_name"`
+	DataStartDateStr        string                  `yaml:"data_date_start"`
+	DonationAccountPrefixes []string                `yaml:"donation_account_prefixes"`
+	DatabasePath            string                  `yaml:"database_path"`
+	Xero                    ProfileXeroConfig       `yaml:"xero"`
+	Salesforce              ProfileSalesforceConfig `yaml:"salesforce"`
+}
+
+// ProfileXeroConfig holds the Xero settings of a profile.
+type ProfileXeroConfig struct {
+	ClientID string `yaml:"client_id"`
+	TenantID string `yaml:"tenant_id"`
+}
+
+// ProfileSalesforceConfig holds the Salesforce settings of a profile.
+type ProfileSalesforceConfig struct {
+	LoginDomain  string `yaml:"login_domain"`
+	Environment  string `yaml:"environment"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// validProfileName matches the names of profiles, which are used in file names such as
+// those of the saved tokens.
+var validProfileName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ProfileNames returns the sorted names of the profiles.
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// applyProfile replaces the base settings with those of the named profile, or of the
+// default profile if name is empty. No settings are replaced if neither is set.
+func (c *Config) applyProfile(name string, p *problems) {
+	for _, n := range c.ProfileNames() {
+		if !validProfileName.MatchString(n) {
+			p.add("profiles name %q should only contain lower case letters, digits and hyphens", n)
+		}
+	}
+	name = cmp.Or(name, c.DefaultProfile)
+	if name == "" {
+		return
+	}
+	pc, ok := c.Profiles[name]
+	if !ok {
+		p.add("profile %q is not one of the profiles %q", name, c.ProfileNames())
+		return
+	}
+	c.Profile = name
+
+	c.Organisation = cmp.Or(pc.Organisation, c.Organisation)
+	c.DataStartDateStr = cmp.Or(pc.DataStartDateStr, c.DataStartDateStr)
+	if len(pc.DonationAccountPrefixes) > 0 {
+		c.DonationAccountPrefixes = pc.DonationAccountPrefixes
+	}
+	c.Database.Path = cmp.Or(pc.DatabasePath, c.Database.Path)
+	c.Xero.ClientID = cmp.Or(pc.Xero.ClientID, c.Xero.ClientID)
+	c.Xero.TenantID = cmp.Or(pc.Xero.TenantID, c.Xero.TenantID)
+	// The environment of another login domain is not inherited.
+	if pc.Salesforce.LoginDomain != "" {
+		c.Salesforce.LoginDomain = pc.Salesforce.LoginDomain
+		c.Salesforce.Environment = pc.Salesforce.Environment
+	}
+	c.Salesforce.Environment = cmp.Or(pc.Salesforce.Environment, c.Salesforce.Environment)
+	c.Salesforce.ClientID = cmp.Or(pc.Salesforce.ClientID, c.Salesforce.ClientID)
+	c.Salesforce.ClientSecret = cmp.Or(pc.Salesforce.ClientSecret, c.Salesforce.ClientSecret)
+}
@@ -0,0 +1,143 @@
+package config
+
+// reload.go reloads the settings which are safe to change while the program is
+// running, such as the data start date, when the configuration file is written. Other
+// settings, notably the credentials and the web server address, require a restart.
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/filewatcher"
+)
+
+// Settings are the settings which may be reloaded while the program is running.
+// AccountCodes and AccountsRegexp are derived from the DonationAccountPrefixes. The
+// LogLevel is the level of the log file, if configured.
+type Settings struct {
+	DataStartDate           time.Time
+	DonationAccountPrefixes []string
+	AccountCodes            string
+	AccountsRegexp          *regexp.Regexp
+	PageLength              int
+	LogLevel                slog.Level
+}
+
+// Settings returns the reloadable settings of the configuration.
+func (c *Config) Settings() Settings {
+	return Settings{
+		DataStartDate:           c.DataStartDate,
+		DonationAccountPrefixes: slices.Clone(c.DonationAccountPrefixes),
+		AccountCodes:            c.DonationAccountCodesRegex(),
+		AccountsRegexp:          c.DonationAccountCodesAsRegex(),
+		PageLength:              c.Web.PageLength,
+		LogLevel:                c.Logging.Level,
+	}
+}
+
+// equal reports if the settings are the same. The derived settings are not compared.
+func (s Settings) equal(o Settings) bool {
+	return s.DataStartDate.Equal(o.DataStartDate) &&
+		slices.Equal(s.DonationAccountPrefixes, o.DonationAccountPrefixes) &&
+		s.PageLength == o.PageLength &&
+		s.LogLevel == o.LogLevel
+}
+
+// Subscriber is a component which is notified when the settings are reloaded.
+type Subscriber interface {
+	SettingsChanged(s Settings)
+}
+
+// SubscriberFunc is a func which may be used as a Subscriber.
+type SubscriberFunc func(s Settings)
+
+// SettingsChanged calls f(s).
+func (f SubscriberFunc) SettingsChanged(s Settings) {
+	f(s)
+}
+
+// Reloader reloads the settings from the configuration file, notifying its
+// subscribers if these have changed.
+type Reloader struct {
+	filePath string
+	profile  string
+	log      *slog.Logger
+
+	mu          sync.Mutex
+	current     Settings
+	subscribers []Subscriber
+}
+
+// NewReloader returns a Reloader for the configuration file at filePath, which was
+// loaded as cfg. The settings of the profile of cfg, if any, are reloaded.
+func NewReloader(filePath string, cfg *Config, logger *slog.Logger) *Reloader {
+	return &Reloader{
+		filePath: filePath,
+		profile:  cfg.Profile,
+		log:      logger,
+		current:  cfg.Settings(),
+	}
+}
+
+// Subscribe registers s to be notified of changes to the settings.
+func (r *Reloader) Subscribe(s Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, s)
+}
+
+// Reload loads the configuration file, notifying the subscribers if the settings have
+// changed. The current settings are kept if the file is invalid.
+func (r *Reloader) Reload() error {
+	cfg, err := LoadProfile(r.filePath, r.profile)
+	if err != nil {
+		return err
+	}
+	settings := cfg.Settings()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if settings.equal(r.current) {
+		return nil
+	}
+	r.current = settings
+	for _, s := range r.subscribers {
+		s.SettingsChanged(settings)
+	}
+	r.log.Info(fmt.Sprintf("config settings reloaded from %s", r.filePath))
+	return nil
+}
+
+// Watch reloads the configuration file each time it is written, until ctx is
+// cancelled. Files in the same directory with the same suffix are also watched, the
+// reload being ignored if the settings have not changed.
+func (r *Reloader) Watch(ctx context.Context) error {
+	watcher, err := filewatcher.NewFileChangeNotifier(
+		ctx,
+		map[string][]string{filepath.Dir(r.filePath): {filepath.Ext(r.filePath)}},
+	)
+	if err != nil {
+		return fmt.Errorf("config file watcher error: %w", err)
+	}
+	go func() {
+		for err := range watcher.Update() {
+			if err != nil {
+				if ctx.Err() == nil {
+					r.log.Error(fmt.Sprintf("config file watcher error: %v", err))
+					r.log.Error("config file watching has stopped")
+				}
+				return
+			}
+			if err := r.Reload(); err != nil {
+				r.log.Error(fmt.Sprintf("config reload error; the current settings are kept: %v", err))
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+
+	filePath := writeConfig(t)
+	cfg, err := Load(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloader := NewReloader(filePath, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	changes := make(chan Settings, 10)
+	reloader.Subscribe(SubscriberFunc(func(s Settings) {
+		changes <- s
+	}))
+
+	// An unchanged file does not notify the subscribers.
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unexpected notification of unchanged settings")
+	}
+
+	rewrite := func(old, new string) {
+		t.Helper()
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = bytes.Replace(contents, []byte(old), []byte(new), 1)
+		if err := os.WriteFile(filePath, contents, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rewrite(`data_date_start: "2025-04-01"`, `data_date_start: "2024-04-01"`)
+	rewrite(`  - "57"`, `  - "59"`)
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-changes:
+		if got, want := s.DataStartDate, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+			t.Errorf("data start date got %s want %s", got, want)
+		}
+		if got, want := s.AccountCodes, "^(53|55|59)"; got != want {
+			t.Errorf("account codes got %q want %q", got, want)
+		}
+		if !s.AccountsRegexp.MatchString("5901") || s.AccountsRegexp.MatchString("5701") {
+			t.Errorf("unexpected accounts regexp %s", s.AccountsRegexp)
+		}
+	default:
+		t.Fatal("expected a notification of the changed settings")
+	}
+
+	// An invalid file is reported, keeping the current settings.
+	rewrite(`data_date_start: "2024-04-01"`, `data_date_start: "April"`)
+	if err := reloader.Reload(); err == nil {
+		t.Error("expected an error for an invalid config file")
+	}
+	if len(changes) != 0 {
+		t.Fatalf("unexpected notification of invalid settings")
+	}
+
+	// Writes to the file are watched.
+	if err := reloader.Watch(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	rewrite(`data_date_start: "April"`, `data_date_start: "2023-04-01"`)
+	select {
+	case s := <-changes:
+		if got, want := s.DataStartDate, time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+			t.Errorf("watched data start date got %s want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the watched config file to be reloaded")
+	}
+}
@@ -0,0 +1,143 @@
+package db
+
+// accounts.go deals with the selection of the Xero accounts treated as donation
+// accounts. Without a selection the donation accounts are those with codes starting
+// with the configured donation account prefixes, set with SetAccountCodes. The codes
+// matching either are kept in the donation_accounts table for the sql statements.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Account is a Xero account with its donation account selection, which is nil if no
+// selection has been made.
+type Account struct {
+	ID              string `db:"id"`
+	Code            string `db:"code"`
+	Name            string `db:"name"`
+	Type            string `db:"type"`
+	Status          string `db:"status"`
+	DonationAccount *bool  `db:"donation_account"`
+}
+
+// AccountsGet retrieves the Xero accounts by code.
+func (db *DB) AccountsGet(ctx context.Context) ([]Account, error) {
+	return db.accountsGet(ctx, false)
+}
+
+// accountsGet retrieves the Xero accounts, or only the selected donation accounts if
+// donationOnly is set.
+func (db *DB) accountsGet(ctx context.Context, donationOnly bool) ([]Account, error) {
+
+	stmt := db.accountsGetStmt
+
+	namedArgs := map[string]any{
+		"DonationOnly": donationOnly,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("accounts verify arguments error: %v", err))
+		return nil, fmt.Errorf("accounts verify arguments error: %w", err)
+	}
+
+	var accounts []Account
+	err := stmt.SelectContext(ctx, &accounts, namedArgs)
+	db.logQuery(ctx, "accounts", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("accounts select error: %v", err))
+		return nil, fmt.Errorf("accounts select error: %w", err)
+	}
+	return accounts, nil
+}
+
+// DonationAccountsSet selects the accounts with codes as the donation accounts,
+// replacing the configured donation account prefixes in the sql statements. A nil
+// codes clears the selection so that the configured prefixes apply again.
+func (db *DB) DonationAccountsSet(ctx context.Context, codes []string) error {
+
+	stmt := db.accountsDonationUpdateStmt
+
+	encoded, err := json.Marshal(codes)
+	if err != nil {
+		return fmt.Errorf("donation accounts encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"Codes": string(encoded),
+		"Reset": codes == nil,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation accounts update verify arguments error: %v", err))
+		return fmt.Errorf("donation accounts update verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to update donation accounts: %v", err))
+		return fmt.Errorf("failed to update donation accounts: %w", err)
+	}
+	if err := db.loadDonationAccounts(ctx); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
+}
+
+// DonationAccountsSelected returns the codes of the accounts selected as donation
+// accounts, which is empty if the configured donation account prefixes apply.
+func (db *DB) DonationAccountsSelected() []string {
+	if codes := db.selectedCodes.Load(); codes != nil {
+		return slices.Clone(*codes)
+	}
+	return nil
+}
+
+// loadDonationAccounts loads the codes of the accounts selected as donation accounts.
+func (db *DB) loadDonationAccounts(ctx context.Context) error {
+	accounts, err := db.accountsGet(ctx, true)
+	if err != nil {
+		return err
+	}
+	codes := make([]string, len(accounts))
+	for i, a := range accounts {
+		codes[i] = a.Code
+	}
+	db.selectedCodes.Store(&codes)
+	if len(codes) == 0 {
+		db.log.Info(fmt.Sprintf("donation accounts set by the account codes %s", *db.accountCodes.Load()))
+	} else {
+		db.log.Info(fmt.Sprintf("donation accounts set to the selected accounts %s", strings.Join(codes, ", ")))
+	}
+	return nil
+}
+
+// donationAccountsSync updates the donation account codes matched by the sql
+// statements to the codes of the accounts and line items matching the donation account
+// codes regular expression. It is run after the accounts, line items or the regular
+// expression change.
+func (db *DB) donationAccountsSync(ctx context.Context) error {
+
+	namedArgs := map[string]any{
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	for _, stmt := range []*parameterizedStmt{db.donationAccountsDeleteStmt, db.donationAccountsInsertStmt} {
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("donation accounts sync verify arguments error: %v", err))
+			return fmt.Errorf("donation accounts sync verify arguments error: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to sync donation accounts: %v", err))
+			return fmt.Errorf("failed to sync donation accounts: %w", err)
+		}
+	}
+	return nil
+}
+
+// AccountCodesRegex returns a regular expression matching exactly the account codes.
+func AccountCodesRegex(codes []string) string {
+	quoted := make([]string, len(codes))
+	for i, c := range codes {
+		quoted[i] = regexp.QuoteMeta(c)
+	}
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
@@ -0,0 +1,124 @@
+package db
+
+// tests for the donation accounts selection
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDonationAccounts(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	listed := func(t *testing.T) []string {
+		t.Helper()
+		invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, i := range invoices {
+			ids = append(ids, i.InvoiceID)
+		}
+		return ids
+	}
+
+	if got := testDB.DonationAccountsSelected(); len(got) != 0 {
+		t.Fatalf("unexpected selection %v", got)
+	}
+	if ids := listed(t); !slices.Contains(ids, "inv-001") || slices.Contains(ids, "inv-arb-02") {
+		t.Fatalf("unexpected listing by the account prefixes %v", ids)
+	}
+
+	// The selected accounts replace the account prefixes.
+	if err := testDB.DonationAccountsSet(ctx, []string{"5701", "9999"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testDB.DonationAccountsSelected(), []string{"5701", "9999"}; !slices.Equal(got, want) {
+		t.Errorf("selection got %v want %v", got, want)
+	}
+	if ids := listed(t); slices.Contains(ids, "inv-001") || !slices.Contains(ids, "inv-arb-02") {
+		t.Errorf("unexpected listing by the selected accounts %v", ids)
+	}
+	accounts, err := testDB.AccountsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 5 {
+		t.Fatalf("got %d accounts want 5", len(accounts))
+	}
+	for _, a := range accounts {
+		want := a.Code == "5701" || a.Code == "9999"
+		if a.DonationAccount == nil || *a.DonationAccount != want {
+			t.Errorf("account %s donation account %v want %t", a.Code, a.DonationAccount, want)
+		}
+	}
+
+	// Clearing the selection restores the account prefixes.
+	if err := testDB.DonationAccountsSet(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := testDB.DonationAccountsSelected(); len(got) != 0 {
+		t.Errorf("unexpected selection after reset %v", got)
+	}
+	if ids := listed(t); !slices.Contains(ids, "inv-001") || slices.Contains(ids, "inv-arb-02") {
+		t.Errorf("unexpected listing after reset %v", ids)
+	}
+}
+
+func TestAccountCodesRegex(t *testing.T) {
+	if got, want := AccountCodesRegex([]string{"5501", "57.1"}), `^(5501|57\.1)$`; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
+
+func TestDonationAccountCodes(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	codes := func(t *testing.T) []string {
+		t.Helper()
+		var codes []string
+		if err := testDB.SelectContext(ctx, &codes, "SELECT code FROM donation_accounts ORDER BY code"); err != nil {
+			t.Fatal(err)
+		}
+		return codes
+	}
+	dateFrom, dateTo := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	totals := func(t *testing.T) []AccountTotal {
+		t.Helper()
+		totals, err := testDB.AccountTotalsGet(ctx, dateFrom, dateTo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return totals
+	}
+
+	if got, want := codes(t), []string{"5301", "5501", "5701"}; !slices.Equal(got, want) {
+		t.Errorf("codes got %v want %v", got, want)
+	}
+	withCodes := totals(t)
+
+	// The account codes regular expression is used while the table is empty.
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM donation_accounts"); err != nil {
+		t.Fatal(err)
+	}
+	testDB.InvalidateCache()
+	if got := totals(t); !slices.Equal(got, withCodes) {
+		t.Errorf("totals by the regular expression %v differ from those by the codes %v", got, withCodes)
+	}
+
+	// Changes to the account codes regular expression update the codes.
+	testDB.SetAccountCodes("^(55|99)")
+	if got, want := codes(t), []string{"5501", "9999"}; !slices.Equal(got, want) {
+		t.Errorf("codes after setting the account codes got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+// annotations.go deals with the notes and flags users add to invoices, bank
+// transactions and donations.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxAnnotationLength is the maximum length of a note in characters.
+const maxAnnotationLength = 1000
+
+// Annotation is a note on an invoice, bank transaction or donation. A flagged note
+// marks the record for attention.
+type Annotation struct {
+	ID         int64     `db:"id"`
+	RecordType string    `db:"record_type"`
+	RecordID   string    `db:"record_id"`
+	Note       string    `db:"note"`
+	Flag       bool      `db:"flag"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// AnnotationsGet retrieves the notes and flags of a record, flags first. The
+// recordType is "invoice", "bank-transaction" or "donation".
+func (db *DB) AnnotationsGet(ctx context.Context, recordType, recordID string) ([]Annotation, error) {
+
+	stmt := db.annotationsGetStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("annotations verify arguments error: %v", err))
+		return nil, fmt.Errorf("annotations verify arguments error: %w", err)
+	}
+
+	var annotations []Annotation
+	err := stmt.SelectContext(ctx, &annotations, namedArgs)
+	db.logQuery(ctx, "annotations", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("annotations select error: %v", err))
+		return nil, fmt.Errorf("annotations select error: %w", err)
+	}
+	return annotations, nil
+}
+
+// AnnotationInsert adds a note, which is a flag if flag is set, to a record. An
+// ErrValidation is returned for an empty or overlong note, and ErrNotFound if the
+// record does not exist.
+func (db *DB) AnnotationInsert(ctx context.Context, recordType, recordID, note string, flag bool) error {
+
+	note = strings.TrimSpace(note)
+	switch {
+	case note == "":
+		return ErrValidation{"note", "may not be empty"}
+	case utf8.RuneCountInString(note) > maxAnnotationLength:
+		return ErrValidation{"note", fmt.Sprintf("may not be longer than %d characters", maxAnnotationLength)}
+	}
+	stmt := db.annotationInsertStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+		"Note":       note,
+		"Flag":       flag,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("annotation insert verify arguments error: %v", err))
+		return fmt.Errorf("annotation insert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert annotation: %v", err))
+		return fmt.Errorf("failed to insert annotation: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound{strings.ReplaceAll(recordType, "-", " "), recordID}
+	}
+	db.log.Info(fmt.Sprintf("annotated %s %s (flag %t)", recordType, recordID, flag))
+	return nil
+}
+
+// AnnotationDelete deletes a note or flag from a record.
+func (db *DB) AnnotationDelete(ctx context.Context, id int64, recordType, recordID string) error {
+
+	stmt := db.annotationDeleteStmt
+
+	namedArgs := map[string]any{
+		"ID":         id,
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("annotation delete verify arguments error: %v", err))
+		return fmt.Errorf("annotation delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete annotation %d: %v", id, err))
+		return fmt.Errorf("failed to delete annotation %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted annotation %d from %s %s", id, recordType, recordID))
+	return nil
+}
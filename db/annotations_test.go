@@ -0,0 +1,121 @@
+package db
+
+// tests for notes and flags
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnnotations(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if err := testDB.AnnotationInsert(ctx, "invoice", "inv-001", "checked against the remittance", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AnnotationInsert(ctx, "invoice", "inv-001", " query with fundraising team ", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AnnotationInsert(ctx, "donation", "sf-opp-017", "donor asked for a receipt", false); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []struct {
+		name       string
+		recordType string
+		recordID   string
+		note       string
+		notFound   bool
+	}{
+		{"empty note", "invoice", "inv-001", "  ", false},
+		{"long note", "invoice", "inv-001", strings.Repeat("x", maxAnnotationLength+1), false},
+		{"no record", "bank-transaction", "bt-none", "a note", true},
+		{"bad record type", "contact", "inv-001", "a note", true},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testDB.AnnotationInsert(ctx, tt.recordType, tt.recordID, tt.note, false)
+			_, isValidation := errors.AsType[ErrValidation](err)
+			_, isNotFound := errors.AsType[ErrNotFound](err)
+			if isValidation == tt.notFound || isNotFound != tt.notFound {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+
+	annotations, err := testDB.AnnotationsGet(ctx, "invoice", "inv-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(annotations), 2; got != want {
+		t.Fatalf("got %d annotations want %d", got, want)
+	}
+	if a := annotations[0]; !a.Flag || a.Note != "query with fundraising team" {
+		t.Errorf("expected the flag first, got %+v", a)
+	}
+
+	// The listings count the notes and show if any is a flag.
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range invoices {
+		want := Invoice{}
+		if i.InvoiceID == "inv-001" {
+			want = Invoice{Annotations: 2, Flagged: true}
+		}
+		if i.Annotations != want.Annotations || i.Flagged != want.Flagged {
+			t.Errorf("invoice %s got %d annotations flagged %t", i.InvoiceID, i.Annotations, i.Flagged)
+		}
+	}
+	donations, err := testDB.DonationsGet(ctx, dateFrom, dateTo, "All", "", "", "", SortOrder{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, d := range donations {
+		if d.ID == "sf-opp-017" {
+			found = true
+			if d.Annotations != 1 || d.Flagged {
+				t.Errorf("donation %s got %d annotations flagged %t", d.ID, d.Annotations, d.Flagged)
+			}
+		}
+	}
+	if !found {
+		t.Error("donation sf-opp-017 not listed")
+	}
+
+	// Notes are searchable.
+	results, err := testDB.Search(ctx, "fundraising", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].RecordID != "inv-001" {
+		t.Errorf("note search got %+v", results)
+	}
+
+	// Deleting requires the record to match.
+	if err := testDB.AnnotationDelete(ctx, annotations[0].ID, "invoice", "inv-002"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AnnotationDelete(ctx, annotations[0].ID, "invoice", "inv-001"); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err = testDB.AnnotationsGet(ctx, "invoice", "inv-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 1 || annotations[0].Flag {
+		t.Errorf("after delete got %+v", annotations)
+	}
+	if _, err := testDB.Search(ctx, "fundraising", 50); err == nil {
+		t.Error("expected no search results for the deleted flag")
+	}
+}
@@ -0,0 +1,102 @@
+package db
+
+// assignments.go deals with the assignment of unreconciled invoices, bank transactions
+// and donations to the team members reconciling them.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Assignment is the assignment of an invoice, bank transaction or donation to a team
+// member.
+type Assignment struct {
+	RecordType string     `db:"record_type"`
+	RecordID   string     `db:"record_id"`
+	AssignedTo string     `db:"assigned_to"`
+	AssignedAt *time.Time `db:"assigned_at"`
+}
+
+// AssignmentGet retrieves the assignment of a record, which has an empty AssignedTo if
+// the record is not assigned. The recordType is "invoice", "bank-transaction" or
+// "donation".
+func (db *DB) AssignmentGet(ctx context.Context, recordType, recordID string) (Assignment, error) {
+
+	stmt := db.assignmentGetStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("assignment verify arguments error: %v", err))
+		return Assignment{}, fmt.Errorf("assignment verify arguments error: %w", err)
+	}
+
+	var assignments []Assignment
+	err := stmt.SelectContext(ctx, &assignments, namedArgs)
+	db.logQuery(ctx, "assignment", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("assignment select error: %v", err))
+		return Assignment{}, fmt.Errorf("assignment select error: %w", err)
+	}
+	if len(assignments) == 0 {
+		return Assignment{RecordType: recordType, RecordID: recordID}, nil
+	}
+	return assignments[0], nil
+}
+
+// AssignmentUpsert assigns a record to the team member assignedTo, replacing any
+// earlier assignment, or unassigns the record if assignedTo is empty. ErrNotFound is
+// returned if the record to assign does not exist.
+func (db *DB) AssignmentUpsert(ctx context.Context, recordType, recordID, assignedTo string) error {
+
+	assignedTo = strings.TrimSpace(assignedTo)
+	if assignedTo == "" {
+		return db.assignmentDelete(ctx, recordType, recordID)
+	}
+	stmt := db.assignmentUpsertStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+		"AssignedTo": assignedTo,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("assignment upsert verify arguments error: %v", err))
+		return fmt.Errorf("assignment upsert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to assign %s %s: %v", recordType, recordID, err))
+		return fmt.Errorf("failed to assign %s %s: %w", recordType, recordID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound{strings.ReplaceAll(recordType, "-", " "), recordID}
+	}
+	db.log.Info(fmt.Sprintf("assigned %s %s to %s", recordType, recordID, assignedTo))
+	return nil
+}
+
+// assignmentDelete unassigns a record.
+func (db *DB) assignmentDelete(ctx context.Context, recordType, recordID string) error {
+
+	stmt := db.assignmentDeleteStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("assignment delete verify arguments error: %v", err))
+		return fmt.Errorf("assignment delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to unassign %s %s: %v", recordType, recordID, err))
+		return fmt.Errorf("failed to unassign %s %s: %w", recordType, recordID, err)
+	}
+	db.log.Info(fmt.Sprintf("unassigned %s %s", recordType, recordID))
+	return nil
+}
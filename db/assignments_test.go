@@ -0,0 +1,77 @@
+package db
+
+// tests for the assignment of records to team members
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAssignments(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if err := testDB.AssignmentUpsert(ctx, "invoice", "inv-001", "Alex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AssignmentUpsert(ctx, "donation", "sf-opp-017", "Alex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AssignmentUpsert(ctx, "bank-transaction", "bt-none", "Alex"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a not found error for a missing record, got %v", err)
+	}
+	assignment, err := testDB.AssignmentGet(ctx, "invoice", "inv-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assignment.AssignedTo != "Alex" || assignment.AssignedAt == nil {
+		t.Errorf("assignment got %+v", assignment)
+	}
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "Alex", SortOrder{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || invoices[0].InvoiceID != "inv-001" || invoices[0].AssignedTo != "Alex" {
+		t.Errorf("assigned invoices got %+v", invoices)
+	}
+	donations, err := testDB.DonationsGet(ctx, dateFrom, dateTo, "All", "", "", "Alex", SortOrder{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(donations) != 1 || donations[0].ID != "sf-opp-017" || donations[0].AssignedTo != "Alex" {
+		t.Errorf("assigned donations got %+v", donations)
+	}
+	if _, err := testDB.BankTransactionsGet(ctx, "All", dateFrom, dateTo, "", "Alex", SortOrder{}, 100, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no assigned bank transactions, got %v", err)
+	}
+
+	// Reassigning replaces the assignee, and an empty assignee unassigns the record.
+	if err := testDB.AssignmentUpsert(ctx, "invoice", "inv-001", "Sam"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "Alex", SortOrder{}, 100, 0); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no invoices assigned to Alex after reassignment, got %v", err)
+	}
+	if err := testDB.AssignmentUpsert(ctx, "invoice", "inv-001", " "); err != nil {
+		t.Fatal(err)
+	}
+	if assignment, err := testDB.AssignmentGet(ctx, "invoice", "inv-001"); err != nil || assignment.AssignedTo != "" {
+		t.Errorf("unassigned invoice assignment got %+v %v", assignment, err)
+	}
+	invoices, err = testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range invoices {
+		if i.AssignedTo != "" {
+			t.Errorf("invoice %s is assigned to %q after unassignment", i.InvoiceID, i.AssignedTo)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package db
+
+// benchmarks of the listing queries over a large seeded database, for load testing the
+// schema indexes. Run with:
+//
+//	go test ./db -run '^$' -bench . -benchtime 3x -timeout 60m
+//
+// With benchOptions, the indexes on the line item parents, dates and references cut
+// seeding, which upserts records as a sync does, from about 240s to 41s, as replacing
+// line items and updating the search index no longer scan the line items. The listings
+// take about 0.2s to 0.6s with or without them, as most of their time is spent totalling
+// the donations of every payout reference.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+)
+
+// benchOptions sets the volume of the benchmark database, at about the scale of several
+// years of data for a large charity.
+var benchOptions = SeedOptions{
+	From:             time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC),
+	To:               time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+	Invoices:         10_000,
+	BankTransactions: 10_000,
+	Donations:        100_000,
+	LinkedPercent:    60,
+	AccountCodes:     []string{"5300", "5500", "5700"},
+	Seed:             1,
+}
+
+// benchDB is shared by the benchmarks, as seeding it takes some time.
+var benchDB = sync.OnceValues(func() (*DB, error) {
+	sqlFS, err := mounts.NewFileMount("sql", SQLEmbeddedFS, "sql")
+	if err != nil {
+		return nil, err
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := NewConnection("file:benchdb?mode=memory&cache=shared", sqlFS, "^(53|55|57)", logger)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.SeedDemoData(context.Background(), benchOptions); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+})
+
+func setupBenchDB(b *testing.B) *DB {
+	b.Helper()
+	db, err := benchDB()
+	if err != nil {
+		b.Fatalf("benchmark database error: %v", err)
+	}
+	return db
+}
+
+func BenchmarkInvoicesGet(b *testing.B) {
+	db := setupBenchDB(b)
+	ctx := context.Background()
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, status := range []string{"All", "NotReconciled"} {
+		b.Run(status, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := db.InvoicesGet(ctx, status, from, to, "", "", SortOrder{}, 30, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBankTransactionsGet(b *testing.B) {
+	db := setupBenchDB(b)
+	ctx := context.Background()
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, status := range []string{"All", "NotReconciled"} {
+		b.Run(status, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := db.BankTransactionsGet(ctx, status, from, to, "", "", SortOrder{}, 30, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDonationsGet(b *testing.B) {
+	db := setupBenchDB(b)
+	ctx := context.Background()
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	for _, status := range []string{"All", "Linked", "NotLinked"} {
+		b.Run(status, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := db.DonationsGet(ctx, from, to, status, "", "", "", SortOrder{}, 30, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSeedDemoData benchmarks seeding a new database, which upserts the records as
+// a sync does.
+func BenchmarkSeedDemoData(b *testing.B) {
+	sqlFS, err := mounts.NewFileMount("sql", SQLEmbeddedFS, "sql")
+	if err != nil {
+		b.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	i := 0
+	for b.Loop() {
+		i++
+		db, err := NewConnection(fmt.Sprintf("file:seeddb%d?mode=memory&cache=shared", i), sqlFS, "^(53|55|57)", logger)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := db.SeedDemoData(context.Background(), benchOptions); err != nil {
+			b.Fatal(err)
+		}
+		_ = db.Close()
+	}
+}
@@ -0,0 +1,181 @@
+package db
+
+// cache.go caches the results of the expensive aggregate queries behind the report
+// pages and the summary rows of the listing pages, so that moving between pages does
+// not repeat full scans of the data. Cached results expire after the cache ttl and
+// are discarded whenever data is written through the prepared statements. The count of
+// writes also versions the data for http caching.
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is how long cached query results are kept unless set by SetCacheTTL.
+const DefaultCacheTTL = time.Minute
+
+// CacheStats reports the use of the query cache.
+type CacheStats struct {
+	TTL     time.Duration
+	Entries int
+	Hits    int64
+	Misses  int64
+}
+
+// queryCache holds query results by query name and arguments. The generation is
+// advanced by each write, so that results read before a write are not stored after
+// it. The epoch distinguishes the generations of different connections. Nothing is
+// cached while a transaction is open, as its writes may yet be rolled back.
+type queryCache struct {
+	ttl        atomic.Int64 // nanoseconds; zero disables the cache
+	txs        atomic.Int64 // the number of open transactions
+	generation atomic.Uint64
+	modified   atomic.Int64 // unix nanoseconds of the last write
+	epoch      int64
+	hits       atomic.Int64
+	misses     atomic.Int64
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached query result.
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+// newQueryCache returns a cache keeping results for ttl.
+func newQueryCache(ttl time.Duration) *queryCache {
+	now := time.Now()
+	c := &queryCache{entries: map[string]cacheEntry{}, epoch: now.UnixNano()}
+	c.ttl.Store(int64(max(ttl, 0)))
+	c.modified.Store(now.UnixNano())
+	return c
+}
+
+// get returns the unexpired value cached for key.
+func (c *queryCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// put caches value for key, unless the cache has been invalidated since generation.
+func (c *queryCache) put(key string, generation uint64, value any) {
+	ttl := time.Duration(c.ttl.Load())
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation.Load() != generation {
+		return
+	}
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// invalidate discards the cached results.
+func (c *queryCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation.Add(1)
+	c.modified.Store(time.Now().UnixNano())
+	clear(c.entries)
+}
+
+// txBegin notes the beginning of a transaction, while which results are not cached.
+func (c *queryCache) txBegin() {
+	c.txs.Add(1)
+}
+
+// txEnd notes the end of a transaction, discarding the cached results as the
+// transaction may have committed writes.
+func (c *queryCache) txEnd() {
+	c.txs.Add(-1)
+	c.invalidate()
+}
+
+// cacheKey makes the cache key of the named query with args, in argument name order.
+func cacheKey(name string, args map[string]any) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range slices.Sorted(maps.Keys(args)) {
+		fmt.Fprintf(&b, "|%s=%v", k, args[k])
+	}
+	return b.String()
+}
+
+// cachedQuery returns a copy of the cached result of the named query with args, or
+// runs query, caching its result if it succeeds. Errors, including sql.ErrNoRows, are
+// not cached.
+func cachedQuery[S ~[]E, E any](db *DB, name string, args map[string]any, query func() (S, error)) (S, error) {
+	c := db.cache
+	if c == nil || c.ttl.Load() <= 0 || c.txs.Load() > 0 {
+		return query()
+	}
+	key := cacheKey(name, args)
+	if v, ok := c.get(key); ok {
+		c.hits.Add(1)
+		return slices.Clone(v.(S)), nil
+	}
+	c.misses.Add(1)
+	generation := c.generation.Load()
+	result, err := query()
+	if err != nil {
+		return result, err
+	}
+	c.put(key, generation, slices.Clone(result))
+	return result, nil
+}
+
+// SetCacheTTL sets how long the results of the aggregate queries are cached. A zero
+// ttl disables the cache.
+func (db *DB) SetCacheTTL(ttl time.Duration) {
+	db.cache.ttl.Store(int64(max(ttl, 0)))
+	db.cache.invalidate()
+}
+
+// InvalidateCache discards the cached query results, such as after the database has
+// been changed other than through its prepared statements.
+func (db *DB) InvalidateCache() {
+	db.cache.invalidate()
+}
+
+// DataVersion returns an identifier of the current state of the data, which changes
+// with every write, and the time of the last write, or of the connection if there
+// have been no writes. The identifier is unique across connections.
+func (db *DB) DataVersion() (string, time.Time) {
+	c := db.cache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version := fmt.Sprintf("%x.%d", c.epoch, c.generation.Load())
+	return version, time.Unix(0, c.modified.Load())
+}
+
+// CacheStats returns the query cache statistics.
+func (db *DB) CacheStats() CacheStats {
+	c := db.cache
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return CacheStats{
+		TTL:     time.Duration(c.ttl.Load()),
+		Entries: entries,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
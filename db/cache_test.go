@@ -0,0 +1,98 @@
+package db
+
+// tests for the query cache
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestCacheKey(t *testing.T) {
+	a := cacheKey("invoices", map[string]any{"DateFrom": "2025-04-01", "HereLimit": 10, "TextSearch": ""})
+	b := cacheKey("invoices", map[string]any{"TextSearch": "", "HereLimit": 10, "DateFrom": "2025-04-01"})
+	if a != b {
+		t.Errorf("keys differ by argument order: %q %q", a, b)
+	}
+	if c := cacheKey("invoices", map[string]any{"DateFrom": "2025-04-01", "HereLimit": 20, "TextSearch": ""}); c == a {
+		t.Errorf("keys for different arguments are the same: %q", c)
+	}
+	if d := cacheKey("bank transactions", map[string]any{"DateFrom": "2025-04-01", "HereLimit": 10, "TextSearch": ""}); d == a {
+		t.Errorf("keys for different queries are the same: %q", d)
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	testDB.SetLogLevel(slog.LevelError)
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	first, err := testDB.AccountTotalsGet(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changes to a returned result do not change the cached result.
+	first[0].Total = 0
+	second, err := testDB.AccountTotalsGet(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second[0].Total == 0 {
+		t.Error("cached result was changed by the caller")
+	}
+	stats := testDB.CacheStats()
+	if got, want := stats.Hits, int64(1); got != want {
+		t.Errorf("hits got %d want %d", got, want)
+	}
+	if got, want := stats.Entries, 1; got != want {
+		t.Errorf("entries got %d want %d", got, want)
+	}
+
+	// A write discards the cached results and changes the data version.
+	version, modified := testDB.DataVersion()
+	if err := testDB.ToleranceUpsert(ctx, Tolerance{Amount: money.FromFloat(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if v, m := testDB.DataVersion(); v == version || m.Before(modified) {
+		t.Errorf("data version %q %s not changed from %q %s by write", v, m, version, modified)
+	}
+	if got := testDB.CacheStats().Entries; got != 0 {
+		t.Errorf("entries after write got %d want 0", got)
+	}
+	if _, err := testDB.AccountTotalsGet(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testDB.CacheStats().Misses, int64(2); got != want {
+		t.Errorf("misses got %d want %d", got, want)
+	}
+
+	// Results expire after the ttl.
+	testDB.SetCacheTTL(time.Millisecond)
+	if _, err := testDB.AccountTotalsGet(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := testDB.AccountTotalsGet(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testDB.CacheStats().Misses, int64(4); got != want {
+		t.Errorf("misses after expiry got %d want %d", got, want)
+	}
+
+	// A zero ttl disables the cache.
+	testDB.SetCacheTTL(0)
+	if _, err := testDB.AccountTotalsGet(ctx, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if got := testDB.CacheStats(); got.Entries != 0 || got.Misses != 4 {
+		t.Errorf("disabled cache stats got %+v", got)
+	}
+}
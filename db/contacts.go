@@ -0,0 +1,294 @@
+package db
+
+// contacts.go deals with Xero contacts and the invoices and bank transactions
+// associated with them, and the aliases merging the inconsistent names of a contact,
+// such as "Stripe Payments UK" and "STRIPE", into a canonical contact.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// ContactsUpsert upserts Xero contact records.
+func (db *DB) ContactsUpsert(ctx context.Context, contacts []xero.Contact) error {
+	if len(contacts) == 0 {
+		db.log.Info("no contacts received for upsert")
+		return nil
+	}
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	stmt := db.contactUpsertStmt
+
+	for _, con := range contacts {
+		namedArgs := map[string]any{
+			"ContactID":     con.ContactID,
+			"Name":          con.Name,
+			"FirstName":     con.FirstName,
+			"LastName":      con.LastName,
+			"EmailAddress":  con.EmailAddress,
+			"ContactStatus": con.ContactStatus,
+			"IsCustomer":    con.IsCustomer,
+			"IsSupplier":    con.IsSupplier,
+			"Updated":       sqlTime(con.Updated.Time),
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("contacts upsert verify arguments error: %v", err))
+			return fmt.Errorf("contacts upsert verify arguments error: %w", err)
+		}
+		_, err := stmt.ExecContext(ctx, namedArgs)
+		if err != nil {
+			db.log.Error(fmt.Sprintf("failed to upsert contact %s: %v", con.ContactID, err))
+			return fmt.Errorf("failed to upsert contact %s: %w", con.ContactID, err)
+		}
+	}
+	db.log.Info(fmt.Sprintf("successfully upserted %d contacts", len(contacts)))
+	return tx.commit()
+}
+
+// Contact is a Xero contact as returned by ContactGet.
+type Contact struct {
+	ID            string `db:"id"`
+	Name          string `db:"name"`
+	FirstName     string `db:"first_name"`
+	LastName      string `db:"last_name"`
+	EmailAddress  string `db:"email_address"`
+	ContactStatus string `db:"contact_status"`
+	IsCustomer    bool   `db:"is_customer"`
+	IsSupplier    bool   `db:"is_supplier"`
+}
+
+// ContactGet retrieves a single contact, returning ErrNotFound if it does not exist.
+func (db *DB) ContactGet(ctx context.Context, contactID string) (Contact, error) {
+
+	db.log.Info(fmt.Sprintf("ContactGet for %s", contactID))
+
+	stmt := db.contactGetStmt
+
+	var contact Contact
+	namedArgs := map[string]any{
+		"ContactID": contactID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("ContactGet verify args error %v", err))
+		return contact, err
+	}
+
+	var contacts []Contact
+	err := stmt.SelectContext(ctx, &contacts, namedArgs)
+	db.logQuery(ctx, "contact", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("ContactGet select error %v", err))
+		return contact, fmt.Errorf("contact select error: %w", err)
+	}
+	if len(contacts) == 0 {
+		return contact, ErrNotFound{"contact", contactID}
+	}
+	return contacts[0], nil
+}
+
+// ContactRecord is an invoice or bank transaction for a contact, as returned by
+// ContactRecordsGet. The Typer is either "invoice" or "bank-transaction" and the
+// Reference is the invoice number or bank transaction reference.
+type ContactRecord struct {
+	Typer         string       `db:"typer"`
+	ID            string       `db:"id"`
+	Reference     *string      `db:"reference"`
+	Date          time.Time    `db:"date"`
+	Status        string       `db:"status"`
+	Contact       string       `db:"contact"`
+	Total         money.Amount `db:"total"`
+	DonationTotal money.Amount `db:"donation_total"`
+	CRMSTotal     money.Amount `db:"crms_total"`
+	IsReconciled  bool         `db:"is_reconciled"`
+}
+
+// ContactRecordsGet retrieves the invoices and bank transactions for a contact, and for
+// the contact names merged into it as aliases, most recent first, with summed up line
+// item and donation values.
+func (db *DB) ContactRecordsGet(ctx context.Context, contactID string) ([]ContactRecord, error) {
+
+	db.log.Info(fmt.Sprintf("ContactRecordsGet for %s", contactID))
+
+	stmt := db.contactRecordsGetStmt
+
+	namedArgs := map[string]any{
+		"ContactID":    contactID,
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("ContactRecordsGet verify args error %v", err))
+		return nil, fmt.Errorf("contact records verify arguments error: %w", err)
+	}
+
+	var records []ContactRecord
+	err := stmt.SelectContext(ctx, &records, namedArgs)
+	db.logQuery(ctx, "contact records", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("ContactRecordsGet select error %v", err))
+		return nil, fmt.Errorf("contact records select error: %w", err)
+	}
+	if len(records) == 0 {
+		db.log.Info("ContactRecordsGet: no rows found")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("ContactRecordsGet: %d rows found", len(records)))
+	return records, nil
+}
+
+// ContactAlias is a contact name merged into a canonical contact, as returned by
+// ContactAliasesGet. The Alias is the normalised name, Name the name as entered, and
+// Records the number of invoices and bank transactions with the alias.
+type ContactAlias struct {
+	Alias       string    `db:"alias"`
+	Name        string    `db:"name"`
+	ContactID   string    `db:"contact_id"`
+	ContactName string    `db:"contact_name"`
+	Records     int       `db:"records"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// ContactAliasesGet retrieves the aliases merged into the contact with contactID, or
+// all aliases if contactID is empty.
+func (db *DB) ContactAliasesGet(ctx context.Context, contactID string) ([]ContactAlias, error) {
+
+	stmt := db.contactAliasesGetStmt
+
+	namedArgs := map[string]any{
+		"ContactID": contactID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("contact aliases verify arguments error: %v", err))
+		return nil, fmt.Errorf("contact aliases verify arguments error: %w", err)
+	}
+
+	var aliases []ContactAlias
+	err := stmt.SelectContext(ctx, &aliases, namedArgs)
+	db.logQuery(ctx, "contact aliases", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("contact aliases select error: %v", err))
+		return nil, fmt.Errorf("contact aliases select error: %w", err)
+	}
+	return aliases, nil
+}
+
+// ContactAliasUpsert merges the contact name into the contact with contactID, replacing
+// any earlier merge of the name, and applies the account rules so that the rules
+// matching the contact also match the name. An ErrValidation is returned for an empty
+// name, a name of the contact itself, a contact which does not exist or is itself an
+// alias, or a name which is the canonical contact of other aliases.
+func (db *DB) ContactAliasUpsert(ctx context.Context, contactID, name string) error {
+
+	name = strings.TrimSpace(name)
+	alias := contactKey(name)
+	if alias == "" {
+		return ErrValidation{"alias", "may not be empty"}
+	}
+	contact, err := db.ContactGet(ctx, contactID)
+	if errors.As(err, new(ErrNotFound)) {
+		return ErrValidation{"contact", fmt.Sprintf("%q does not exist", contactID)}
+	}
+	if err != nil {
+		return err
+	}
+	contactAlias := contactKey(contact.Name)
+	if alias == contactAlias {
+		return ErrValidation{"alias", fmt.Sprintf("%q is the name of the contact", name)}
+	}
+	aliases, err := db.ContactAliasesGet(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, a := range aliases {
+		switch {
+		case a.Alias == contactAlias:
+			return ErrValidation{"contact", fmt.Sprintf("%q is itself an alias of %s", contact.Name, a.ContactName)}
+		case contactKey(a.ContactName) == alias:
+			return ErrValidation{"alias", fmt.Sprintf("%q is the contact of other aliases", name)}
+		}
+	}
+
+	stmt := db.contactAliasUpsertStmt
+
+	namedArgs := map[string]any{
+		"Alias":     alias,
+		"Name":      name,
+		"ContactID": contactID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("contact alias upsert verify arguments error: %v", err))
+		return fmt.Errorf("contact alias upsert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to upsert contact alias %q: %v", alias, err))
+		return fmt.Errorf("failed to upsert contact alias %q: %w", alias, err)
+	}
+	db.log.Info(fmt.Sprintf("merged contact name %q into contact %s", name, contactID))
+	return db.AccountRulesApply(ctx)
+}
+
+// ContactAliasDelete removes the contact alias with the normalised name alias, and
+// applies the account rules to the records of the name.
+func (db *DB) ContactAliasDelete(ctx context.Context, alias string) error {
+
+	stmt := db.contactAliasDeleteStmt
+
+	namedArgs := map[string]any{
+		"Alias": alias,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("contact alias delete verify arguments error: %v", err))
+		return fmt.Errorf("contact alias delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete contact alias %q: %v", alias, err))
+		return fmt.Errorf("failed to delete contact alias %q: %w", alias, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted contact alias %q", alias))
+	return db.AccountRulesApply(ctx)
+}
+
+// ContactAliasCandidate is a contact name of invoices or bank transactions which may
+// be an alias of a contact, as returned by ContactAliasCandidatesGet.
+type ContactAliasCandidate struct {
+	Alias string `db:"alias"`
+	Name  string `db:"name"`
+}
+
+// ContactAliasCandidatesGet retrieves the contact names of invoices and bank
+// transactions which are not yet aliases and whose normalised name starts with the
+// first word of the normalised name of the contact with contactID, such as "Stripe
+// Payments UK" for "Stripe".
+func (db *DB) ContactAliasCandidatesGet(ctx context.Context, contactID string) ([]ContactAliasCandidate, error) {
+
+	stmt := db.contactAliasCandidatesStmt
+
+	namedArgs := map[string]any{
+		"ContactID": contactID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("contact alias candidates verify arguments error: %v", err))
+		return nil, fmt.Errorf("contact alias candidates verify arguments error: %w", err)
+	}
+
+	var candidates []ContactAliasCandidate
+	err := stmt.SelectContext(ctx, &candidates, namedArgs)
+	db.logQuery(ctx, "contact alias candidates", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("contact alias candidates select error: %v", err))
+		return nil, fmt.Errorf("contact alias candidates select error: %w", err)
+	}
+	return candidates, nil
+}
@@ -0,0 +1,234 @@
+package db
+
+// tests for contact queries
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rorycl/reconciler/apiclients/xero"
+)
+
+func Test_ContactsUpsert(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	contacts := []xero.Contact{
+		{
+			ContactID:     "con-test-01",
+			ContactStatus: "ACTIVE",
+			Name:          "Test Donor",
+			FirstName:     "Test",
+			LastName:      "Donor",
+			EmailAddress:  "test@example.com",
+			IsCustomer:    true,
+			Updated:       xero.XeroDateTime{Time: time.Now()},
+		},
+	}
+
+	// Upsert twice.
+	for range 2 {
+		if err := testDB.ContactsUpsert(ctx, contacts); err != nil {
+			t.Fatalf("unexpected contacts error: %v", err)
+		}
+	}
+
+	contact, err := testDB.ContactGet(ctx, "con-test-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Contact{
+		ID:            "con-test-01",
+		Name:          "Test Donor",
+		FirstName:     "Test",
+		LastName:      "Donor",
+		EmailAddress:  "test@example.com",
+		ContactStatus: "ACTIVE",
+		IsCustomer:    true,
+	}
+	if diff := cmp.Diff(want, contact); diff != "" {
+		t.Errorf("contact diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := testDB.ContactGet(ctx, "xxxxxxxxxxxx"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	} else if e, ok := errors.AsType[ErrNotFound](err); !ok || e.Kind != "contact" {
+		t.Errorf("expected a contact ErrNotFound, got %v", err)
+	}
+}
+
+func Test_ContactRecordsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	type result struct {
+		Typer        string
+		ID           string
+		IsReconciled bool
+	}
+
+	tests := []struct {
+		contactID string
+		err       error
+		results   []result
+	}{
+		{
+			contactID: "con-jg",
+			results: []result{
+				{"bank-transaction", "bt-unrec-04", false},
+				{"bank-transaction", "bt-unrec-01", false},
+				{"bank-transaction", "bt-001", true},
+				{"bank-transaction", "bt-prev-fy-01", true},
+			},
+		},
+		{
+			contactID: "con-excorp",
+			results: []result{
+				{"invoice", "inv-001", false},
+			},
+		},
+		{
+			contactID: "xxxxxxxxxxxx",
+			err:       sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contactID, func(t *testing.T) {
+			records, err := testDB.ContactRecordsGet(ctx, tt.contactID)
+			if err != tt.err {
+				t.Fatalf("got err %v want %v", err, tt.err)
+			}
+			got := []result{}
+			for _, r := range records {
+				got = append(got, result{r.Typer, r.ID, r.IsReconciled})
+			}
+			if tt.results == nil {
+				tt.results = []result{}
+			}
+			if diff := cmp.Diff(tt.results, got); diff != "" {
+				t.Errorf("unexpected records (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContactAliases(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	recordIDs := func(t *testing.T, contactID string) map[string]string {
+		t.Helper()
+		records, err := testDB.ContactRecordsGet(ctx, contactID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[string]string{}
+		for _, r := range records {
+			ids[r.ID] = r.Contact
+		}
+		return ids
+	}
+	accountCodes := func(t *testing.T, transactionID string) []string {
+		t.Helper()
+		var codes []string
+		err := testDB.SelectContext(ctx, &codes, "SELECT account_code FROM bank_transaction_line_items WHERE transaction_id = ? ORDER BY id", transactionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return codes
+	}
+
+	// A rule for the JustGiving contact applies to the Enthuse bank transaction once
+	// Enthuse is merged into JustGiving.
+	if err := testDB.AccountRuleInsert(ctx, "contact", "^justgiving$", "5301"); err != nil {
+		t.Fatal(err)
+	}
+	before := accountCodes(t, "bt-unrec-03")
+	if err := testDB.ContactAliasUpsert(ctx, "con-jg", " ENTHUSE Ltd. "); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recordIDs(t, "con-jg")["bt-unrec-03"], "Enthuse"; got != want {
+		t.Errorf("merged record contact got %q want %q", got, want)
+	}
+	if got := accountCodes(t, "bt-unrec-03"); got[0] != "5301" {
+		t.Errorf("expected the contact rule to apply to the alias, got account codes %v", got)
+	}
+	aliases, err := testDB.ContactAliasesGet(ctx, "con-jg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ContactAlias{{Alias: "enthuse", Name: "ENTHUSE Ltd.", ContactID: "con-jg", ContactName: "JustGiving", Records: 1}}
+	if diff := cmp.Diff(want, aliases, cmpopts.IgnoreFields(ContactAlias{}, "CreatedAt")); diff != "" {
+		t.Errorf("unexpected aliases (-want +got):\n%s", diff)
+	}
+
+	// Stripe is merged into JustGiving, so may not have aliases of its own.
+	if err := testDB.ContactAliasUpsert(ctx, "con-jg", "Stripe"); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []struct{ contactID, name string }{
+		{"con-jg", " "},
+		{"con-jg", "Justgiving Limited"},
+		{"con-none", "Enthuse"},
+		{"con-stripe", "Stripe Payments UK"},
+		{"con-excorp", "JustGiving"},
+	}
+	for _, tt := range invalid {
+		if err := testDB.ContactAliasUpsert(ctx, tt.contactID, tt.name); !errors.As(err, new(ErrValidation)) {
+			t.Errorf("expected a validation error merging %q into %s, got %v", tt.name, tt.contactID, err)
+		}
+	}
+
+	if err := testDB.ContactAliasDelete(ctx, "enthuse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := recordIDs(t, "con-jg")["bt-unrec-03"]; ok {
+		t.Error("unexpected record of a removed alias")
+	}
+	if diff := cmp.Diff(before, accountCodes(t, "bt-unrec-03")); diff != "" {
+		t.Errorf("account codes not restored (-want +got):\n%s", diff)
+	}
+}
+
+func TestContactAliasCandidates(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	_, err := testDB.ExecContext(ctx, "UPDATE bank_transactions SET contact = 'STRIPE PAYMENTS (UK) LTD' WHERE id = 'bt-unrec-06'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := testDB.ContactAliasCandidatesGet(ctx, "con-stripe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ContactAliasCandidate{{Alias: "stripe payments uk", Name: "STRIPE PAYMENTS (UK) LTD"}}
+	if diff := cmp.Diff(want, candidates); diff != "" {
+		t.Errorf("unexpected candidates (-want +got):\n%s", diff)
+	}
+
+	if err := testDB.ContactAliasUpsert(ctx, "con-stripe", candidates[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err = testDB.ContactAliasCandidatesGet(ctx, "con-stripe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 0 {
+		t.Errorf("unexpected candidates after merging %+v", candidates)
+	}
+}
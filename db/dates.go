@@ -0,0 +1,19 @@
+package db
+
+// dates.go standardises the dates and times passed to the sql statements. Dates are
+// passed and stored as the "2006-01-02" calendar day and times as UTC times, since the
+// sqlite date functions convert times with an offset to UTC, which would otherwise move
+// records near midnight onto another day, and so across the boundary of a financial
+// year. A date stored without a time also falls within a period ending on that day.
+
+import "time"
+
+// sqlDate formats the calendar day of t as a date parameter.
+func sqlDate(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// sqlTime formats t in UTC as a time parameter.
+func sqlTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
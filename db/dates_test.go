@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestDatesStoredAsUTC tests that a donation closing at midnight in a timezone ahead of
+// UTC is stored and found on its own day rather than the day before.
+func TestDatesStoredAsUTC(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeDate := time.Date(2025, 6, 1, 0, 0, 0, 0, london)
+	modified := time.Date(2025, 6, 1, 0, 30, 0, 0, london)
+	donation := salesforce.Donation{
+		CoreFields: salesforce.CoreFields{
+			ID:               "tz-opp-001",
+			Name:             "A donation at midnight",
+			Amount:           money.FromFloat(10),
+			CloseDate:        salesforce.SalesforceDate{Time: closeDate},
+			CreatedDate:      salesforce.SalesforceTime{Time: modified},
+			LastModifiedDate: salesforce.SalesforceTime{Time: modified},
+			PayoutReference:  ptrStr("TZ-REF-001"),
+		},
+	}
+	if err := testDB.UpsertDonations(ctx, []salesforce.Donation{donation}); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	donations, err := testDB.DonationsGet(ctx, day, day, "All", "", "", "", SortOrder{}, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(donations, func(d Donation) bool { return d.ID == "tz-opp-001" })
+	if i < 0 {
+		t.Fatalf("the donation was not found on %s", sqlDate(day))
+	}
+	d := donations[i]
+	if got := d.CloseDate.UTC(); !got.Equal(day) {
+		t.Errorf("close date got %s want %s", got, day)
+	}
+	if got := d.ModifiedDate.UTC(); !got.Equal(modified) {
+		t.Errorf("modified date got %s want %s", got, modified.UTC())
+	}
+}
@@ -14,14 +14,21 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
+	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmoiron/sqlx" // helper library
-	_ "modernc.org/sqlite"    // pure go sqlite driver
+	"github.com/rorycl/reconciler/internal/requestid"
+	_ "modernc.org/sqlite" // pure go sqlite driver
 )
 
 //go:embed sql
@@ -31,11 +38,142 @@ var SQLEmbeddedFS embed.FS
 var testingMode = false
 
 // parameterizedStmt describes an sql file parsed into an sqlx NamedStmt expecting the
-// provided args.
+// args parameters. The embedded NamedStmt includes all the optional blocks of the sql
+// file; variants omitting blocks are prepared on demand by named.
 type parameterizedStmt struct {
 	sqlFile string
 	args    []string
 	*sqlx.NamedStmt
+
+	tpl      *ParameterizedSQLTemplate
+	prepare  func(query string) (*sqlx.NamedStmt, error)
+	mu       sync.Mutex
+	variants map[string]*sqlx.NamedStmt // keyed by the included optional parameters
+
+	monitor *queryMonitor // nil unless slow query recording is enabled
+	writes  *writeLock
+	cache   *queryCache   // invalidated by writes
+	timeout time.Duration // the statement timeout, if not zero
+	log     *slog.Logger
+}
+
+// SelectContext runs the statement variant for args, scanning the rows into dest, within
+// the transaction of ctx, if any.
+func (p *parameterizedStmt) SelectContext(ctx context.Context, dest any, args map[string]any) error {
+	named, err := p.named(args)
+	if err != nil {
+		return err
+	}
+	if tx := txFrom(ctx); tx != nil {
+		named = tx.NamedStmtContext(ctx, named)
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	err = retryLocked(ctx, func() error {
+		// Rows scanned before the statement failed are discarded.
+		reflect.ValueOf(dest).Elem().SetZero()
+		return named.SelectContext(ctx, dest, args)
+	})
+	p.observe(ctx, start, args, err)
+	return err
+}
+
+// ExecContext executes the statement with args, holding the write lock of the database,
+// which the transaction of ctx, if any, already holds.
+func (p *parameterizedStmt) ExecContext(ctx context.Context, args map[string]any) (sql.Result, error) {
+	if p.writes != nil {
+		unlock, err := p.writes.lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	named := p.NamedStmt
+	if tx := txFrom(ctx); tx != nil {
+		named = tx.NamedStmtContext(ctx, named)
+	}
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	var result sql.Result
+	err := retryLocked(ctx, func() error {
+		var err error
+		result, err = named.ExecContext(ctx, args)
+		return err
+	})
+	p.observe(ctx, start, args, err)
+	if p.cache != nil {
+		p.cache.invalidate()
+	}
+	return result, err
+}
+
+// withTimeout returns ctx limited by the statement timeout, if set.
+func (p *parameterizedStmt) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
+// observe records and logs the query started at start if it was slow, and counts
+// errors from the database being locked.
+func (p *parameterizedStmt) observe(ctx context.Context, start time.Time, args map[string]any, err error) {
+	if p.writes != nil {
+		p.writes.observe(err)
+	}
+	if p.monitor == nil {
+		return
+	}
+	if sq, slow := p.monitor.observe(p.sqlFile, start, args, err); slow {
+		requestid.Logger(ctx, p.log).Warn(fmt.Sprintf("slow query %s took %s: %s", sq.SQLFile, sq.Duration, sq.ArgsString()))
+	}
+}
+
+// named returns the named statement for args, omitting the optional blocks for empty
+// arguments. Variant statements are prepared on first use and then reused.
+func (p *parameterizedStmt) named(args map[string]any) (*sqlx.NamedStmt, error) {
+	if p.tpl == nil || len(p.tpl.Optional) == 0 {
+		return p.NamedStmt, nil
+	}
+	var included []string
+	for _, param := range p.tpl.Optional {
+		if argPresent(args[param]) {
+			included = append(included, param)
+		}
+	}
+	if len(included) == len(p.tpl.Optional) {
+		return p.NamedStmt, nil
+	}
+
+	key := strings.Join(included, ",")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stmt, ok := p.variants[key]; ok {
+		return stmt, nil
+	}
+	query := p.tpl.Render(func(param string) bool { return argPresent(args[param]) })
+	stmt, err := p.prepare(string(query))
+	if err != nil {
+		return nil, fmt.Errorf("could not prepare statement %q variant [%s]: %w", p.sqlFile, key, err)
+	}
+	if p.variants == nil {
+		p.variants = map[string]*sqlx.NamedStmt{}
+	}
+	p.variants[key] = stmt
+	return stmt, nil
+}
+
+// close closes the statement and its variants.
+func (p *parameterizedStmt) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	errs := []error{p.Close()}
+	for _, v := range p.variants {
+		errs = append(errs, v.Close())
+	}
+	return errors.Join(errs...)
 }
 
 // verifyArgs determines if the number of arguments provided to a parameterizedStmt is
@@ -65,11 +203,35 @@ func (p *parameterizedStmt) verifyArgs(args map[string]any) error {
 type DB struct {
 	*sqlx.DB
 	Path         string
-	accountCodes string
-	sqlFS        fs.FS
-	log          *slog.Logger
+	accountCodes *atomic.Pointer[string] // set by SetAccountCodes
+	// selectedCodes are the codes of the accounts selected as donation accounts,
+	// which replace accountCodes unless empty
+	selectedCodes *atomic.Pointer[[]string]
+	sqlFS         fs.FS
+	log           *slog.Logger
+	logLevel      *slog.LevelVar // the minimum level logged, set by SetLogLevel
+
+	// prepared records the prepared statements, so that they can be closed when
+	// reloaded.
+	prepared []*parameterizedStmt
+
+	// monitor records slow queries, if enabled.
+	monitor *queryMonitor
+
+	// writes serialises the writes of the prepared statements.
+	writes *writeLock
+
+	// cache holds the results of the aggregate queries until the next write.
+	cache *queryCache
+
+	// statementTimeout limits the time taken by each prepared statement, if not zero.
+	statementTimeout time.Duration
+
+	// testData records that the test data was loaded by NewConnectionInTestMode.
+	testData bool
 
 	// Prepared statements.
+	orgGetStmt        *parameterizedStmt
 	orgUpsertStmt     *parameterizedStmt
 	accountUpsertStmt *parameterizedStmt
 
@@ -85,13 +247,134 @@ type DB struct {
 	bankTransactionLIDeleteStmt *parameterizedStmt
 	bankTransactionLIInsertStmt *parameterizedStmt
 
-	donationsGetStmt   *parameterizedStmt
-	donationUpsertStmt *parameterizedStmt
+	lineItemsExportStmt *parameterizedStmt
+
+	donationsGetStmt         *parameterizedStmt
+	donationUpsertStmt       *parameterizedStmt
+	donationDeleteStmt       *parameterizedStmt
+	donationImportUpsertStmt *parameterizedStmt
+
+	sfInstanceGetStmt    *parameterizedStmt
+	sfInstanceUpsertStmt *parameterizedStmt
+
+	linkSuggestionsGetStmt *parameterizedStmt
+
+	accountTotalsGetStmt    *parameterizedStmt
+	giftAidDonationsGetStmt *parameterizedStmt
+	agingItemsGetStmt       *parameterizedStmt
+	accountMonthsGetStmt    *parameterizedStmt
+	payoutGroupsGetStmt     *parameterizedStmt
+
+	payoutItemInsertStmt  *parameterizedStmt
+	payoutItemsDeleteStmt *parameterizedStmt
+	payoutItemsGetStmt    *parameterizedStmt
+
+	statementLineUpsertStmt *parameterizedStmt
+	statementLinesGetStmt   *parameterizedStmt
+	statementMatchesGetStmt *parameterizedStmt
+
+	importBatchInsertStmt  *parameterizedStmt
+	importRowInsertStmt    *parameterizedStmt
+	importBatchesGetStmt   *parameterizedStmt
+	importRowsGetStmt      *parameterizedStmt
+	importBatchResolveStmt *parameterizedStmt
+
+	importErrorUpsertStmt *parameterizedStmt
+	importErrorsGetStmt   *parameterizedStmt
+	importErrorDeleteStmt *parameterizedStmt
+
+	contactUpsertStmt     *parameterizedStmt
+	contactGetStmt        *parameterizedStmt
+	contactRecordsGetStmt *parameterizedStmt
+
+	contactAliasesGetStmt      *parameterizedStmt
+	contactAliasUpsertStmt     *parameterizedStmt
+	contactAliasDeleteStmt     *parameterizedStmt
+	contactAliasCandidatesStmt *parameterizedStmt
+
+	toleranceGetStmt    *parameterizedStmt
+	toleranceUpsertStmt *parameterizedStmt
+
+	donationLinksDeleteStmt *parameterizedStmt
+	donationLinksInsertStmt *parameterizedStmt
+	donationSplitsGetStmt   *parameterizedStmt
+	donationSplitUpsertStmt *parameterizedStmt
+	donationSplitDeleteStmt *parameterizedStmt
+
+	savedSearchesGetStmt   *parameterizedStmt
+	savedSearchUpsertStmt  *parameterizedStmt
+	savedSearchDefaultStmt *parameterizedStmt
+	savedSearchDeleteStmt  *parameterizedStmt
+
+	searchStmt *parameterizedStmt
+
+	pendingActionsGetStmt   *parameterizedStmt
+	pendingActionGetStmt    *parameterizedStmt
+	pendingActionInsertStmt *parameterizedStmt
+	pendingActionUpdateStmt *parameterizedStmt
+	donationRefsGetStmt     *parameterizedStmt
+
+	outboundMutationGetStmt    *parameterizedStmt
+	outboundMutationUpsertStmt *parameterizedStmt
+
+	donationIDsGetStmt       *parameterizedStmt
+	donationOrphansGetStmt   *parameterizedStmt
+	donationOrphanUpsertStmt *parameterizedStmt
+	donationOrphansClearStmt *parameterizedStmt
+
+	salesforceOutboxInsertStmt  *parameterizedStmt
+	salesforceOutboxClearStmt   *parameterizedStmt
+	salesforceOutboxActionsStmt *parameterizedStmt
+
+	annotationsGetStmt   *parameterizedStmt
+	annotationInsertStmt *parameterizedStmt
+	annotationDeleteStmt *parameterizedStmt
+
+	assignmentGetStmt    *parameterizedStmt
+	assignmentUpsertStmt *parameterizedStmt
+	assignmentDeleteStmt *parameterizedStmt
+
+	accountRulesGetStmt                   *parameterizedStmt
+	accountRuleInsertStmt                 *parameterizedStmt
+	accountRuleDeleteStmt                 *parameterizedStmt
+	accountRulesApplyInvoicesStmt         *parameterizedStmt
+	accountRulesApplyBankTransactionsStmt *parameterizedStmt
+
+	accountsGetStmt            *parameterizedStmt
+	accountsDonationUpdateStmt *parameterizedStmt
+	donationAccountsDeleteStmt *parameterizedStmt
+	donationAccountsInsertStmt *parameterizedStmt
+
+	periodLocksGetStmt      *parameterizedStmt
+	periodLockInsertStmt    *parameterizedStmt
+	periodLockDeleteStmt    *parameterizedStmt
+	periodLocksCoveringStmt *parameterizedStmt
+	auditEventInsertStmt    *parameterizedStmt
+	auditEventsGetStmt      *parameterizedStmt
+
+	periodSnapshotInsertStmt    *parameterizedStmt
+	periodSnapshotsGetStmt      *parameterizedStmt
+	periodSnapshotDonationsStmt *parameterizedStmt
+
+	syncRunInsertStmt       *parameterizedStmt
+	syncRunsGetStmt         *parameterizedStmt
+	syncRecordCountsGetStmt *parameterizedStmt
+
+	linkMappingsGetStmt    *parameterizedStmt
+	linkMappingsImportStmt *parameterizedStmt
+	linkChecksGetStmt      *parameterizedStmt
+}
+
+// MemoryPath returns the path of the shared cache in-memory database called name. The
+// connections to the path of a name share one database, which is separate from those
+// of other names and dropped once its last connection closes.
+func MemoryPath(name string) string {
+	return fmt.Sprintf("file:memdb-%s?mode=memory&cache=shared", url.PathEscape(name))
 }
 
 // NewConnection creates a new connection to an SQLite database at the given path. The
-// directory containing the SQL files are either mounted at either the provided sqlDir
-// or at the embedded path (SQLEmbeddedFS) which is the default. The accountCodes
+// sqlFS holds the SQL files, usually the embedded files (SQLEmbeddedFS) with any
+// configured overrides, or a directory on disk in development. The accountCodes
 // are passed to the sql statements to ensure that only bank transactions and invoices
 // containing line items starting with those codes are returned.
 func NewConnection(
@@ -101,38 +384,28 @@ func NewConnection(
 	logger *slog.Logger,
 ) (*DB, error) {
 
-	// DataSource is the default setting for file-based databases.
-	dataSource := fmt.Sprintf("%s?_dataSource=foreign_keys(1)&_dataSource=journal_mode(WAL)", dbPath)
+	// DataSource is the default setting for file-based databases. Each connection waits
+	// for the busy timeout for locks held by other connections.
+	pragmas := fmt.Sprintf("_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)", busyTimeout.Milliseconds())
+	dataSource := fmt.Sprintf("%s?%s", dbPath, pragmas)
 
 	// For in-memory databases, force the dataSource path if the mode is not explicitly
-	// set.
+	// set. Named in-memory databases, such as those of MemoryPath, are separate
+	// databases.
 	if strings.Contains(dbPath, ":memory:") {
-		dataSource = "file:memdb1?mode=memory&cache=shared&_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"
+		dataSource = "file:memdb1?mode=memory&cache=shared&" + pragmas
 	}
 	if !strings.Contains(dbPath, ":memory:") && strings.Contains(dbPath, "mode=memory") {
-		dataSource = dbPath
+		dataSource = dbPath + "&" + pragmas
 	}
 
 	dbDB, err := sql.Open("sqlite", dataSource)
 	if err != nil {
 		return nil, err
 	}
+	configurePool(dbDB, strings.Contains(dataSource, "mode=memory"))
 
-	// Set connection pool settings.
-	if strings.Contains(dataSource, ":memory:") {
-		// Ensure at least one connection is always kept open.
-		dbDB.SetMaxIdleConns(1)
-		// Ensure the connection doesn't expire.
-		dbDB.SetConnMaxLifetime(0)
-		// Enable if locking issues.
-		// dbDB.SetMaxOpenConns(1)
-	} else {
-		// For file-based connections.
-		dbDB.SetMaxIdleConns(2)
-		dbDB.SetMaxOpenConns(10)
-	}
-
-	// RegisterFunctions registers the custom REXEXP function. This can
+	// RegisterFunctions registers the custom REXEXP and FOLD functions. This can
 	// occur per call to "New" as it is a singleton using sync.Once.
 	RegisterFunctions()
 
@@ -140,21 +413,32 @@ func NewConnection(
 		return nil, err
 	}
 
-	// Logger setup.
+	// Logger setup. The messages of the module are further limited to the level set by
+	// SetLogLevel, which initially allows all messages.
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(
 			os.Stdout,
 			&slog.HandlerOptions{Level: slog.LevelDebug},
 		))
 	}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(slog.LevelDebug)
+	logger = slog.New(levelHandler{Handler: logger.Handler(), level: logLevel})
+
+	codes := new(atomic.Pointer[string])
+	codes.Store(&accountCodes)
 
 	// Wrap the standard library *sql.DB with sqlx.
 	db := &DB{
-		Path:         dbPath,
-		DB:           sqlx.NewDb(dbDB, "sqlite"),
-		accountCodes: accountCodes,
-		sqlFS:        sqlFS,
-		log:          logger,
+		Path:          dbPath,
+		DB:            sqlx.NewDb(dbDB, "sqlite"),
+		accountCodes:  codes,
+		selectedCodes: new(atomic.Pointer[[]string]),
+		sqlFS:         sqlFS,
+		log:           logger,
+		logLevel:      logLevel,
+		writes:        newWriteLock(),
+		cache:         newQueryCache(DefaultCacheTTL),
 	}
 
 	// Return early in testing mode, so that prepared statments and schema loading can
@@ -182,6 +466,14 @@ func NewConnection(
 		return nil, fmt.Errorf("accounts smoke test select error: %v", err)
 	}
 
+	// Load the donation accounts selection and the donation account codes.
+	if err := db.loadDonationAccounts(context.Background()); err != nil {
+		return nil, fmt.Errorf("donation accounts load error: %w", err)
+	}
+	if err := db.donationAccountsSync(context.Background()); err != nil {
+		return nil, fmt.Errorf("donation accounts sync error: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -231,16 +523,75 @@ func NewConnectionInTestMode(
 		testDB.log.Error(fmt.Sprintf("Failed to load data for test database: %v", err))
 		return nil, fmt.Errorf("failed to load data for test database: %w", err)
 	}
+	if err := testDB.loadDonationAccounts(context.Background()); err != nil {
+		return nil, fmt.Errorf("donation accounts load error: %w", err)
+	}
+	if err := testDB.donationAccountsSync(context.Background()); err != nil {
+		return nil, fmt.Errorf("donation accounts sync error: %w", err)
+	}
+	testDB.testData = true
 
 	return testDB, nil
 
 }
 
-// SetLogLevel adjusts the logging level of the db module.
+// TestData reports if the database holds the test data loaded by
+// NewConnectionInTestMode.
+func (db *DB) TestData() bool {
+	return db.testData
+}
+
+// SetStatementTimeout cancels prepared statements which take longer than timeout. A
+// zero timeout leaves statements limited only by the context of the caller.
+func (db *DB) SetStatementTimeout(timeout time.Duration) {
+	db.statementTimeout = max(timeout, 0)
+	for _, stmt := range db.prepared {
+		stmt.timeout = db.statementTimeout
+	}
+}
+
+// SetAccountCodes sets the account codes regular expression passed to the sql
+// statements, such as when the donation account prefixes are reloaded from the
+// configuration file.
+func (db *DB) SetAccountCodes(accountCodes string) {
+	db.accountCodes.Store(&accountCodes)
+	db.log.Info(fmt.Sprintf("account codes set to %s", accountCodes))
+	if err := db.donationAccountsSync(context.Background()); err != nil {
+		db.log.Error(fmt.Sprintf("donation accounts sync error after setting account codes: %v", err))
+	}
+}
+
+// donationAccountCodes returns the account codes regular expression, matching the
+// codes of the selected donation accounts if any have been selected.
+func (db *DB) donationAccountCodes() string {
+	if codes := db.selectedCodes.Load(); codes != nil && len(*codes) > 0 {
+		return AccountCodesRegex(*codes)
+	}
+	return *db.accountCodes.Load()
+}
+
+// SetLogLevel sets the minimum level of the messages logged by the db module, which are
+// written to the logger provided to NewConnection.
 func (db *DB) SetLogLevel(lvl slog.Level) {
-	opts := &slog.HandlerOptions{Level: lvl}
-	handler := slog.NewTextHandler(os.Stdout, opts)
-	db.log = slog.New(handler)
+	db.logLevel.Set(lvl)
+}
+
+// levelHandler is a slog.Handler limiting the messages of its Handler to a minimum level.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h levelHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	return lvl >= h.level.Level() && h.Handler.Enabled(ctx, lvl)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
 }
 
 // prepareNamedStatements prepares all the named statements for this database connection.
@@ -248,6 +599,10 @@ func (db *DB) prepareNamedStatements() error {
 	var err error
 
 	// Organisation.
+	db.orgGetStmt, err = db.prepNamedStatement(db.sqlFS, "organisation.sql")
+	if err != nil {
+		return fmt.Errorf("organisation statement error: %w", err)
+	}
 	db.orgUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "organisation_upsert.sql")
 	if err != nil {
 		return fmt.Errorf("organisation upsert statement error: %w", err)
@@ -302,6 +657,12 @@ func (db *DB) prepareNamedStatements() error {
 		return fmt.Errorf("get bankTransaction line item insert statement error: %w", err)
 	}
 
+	// Line item exports.
+	db.lineItemsExportStmt, err = db.prepNamedStatement(db.sqlFS, "line_items_export.sql")
+	if err != nil {
+		return fmt.Errorf("line items export statement error: %w", err)
+	}
+
 	// Donations.
 	db.donationsGetStmt, err = db.prepNamedStatement(db.sqlFS, "donations.sql")
 	if err != nil {
@@ -311,6 +672,396 @@ func (db *DB) prepareNamedStatements() error {
 	if err != nil {
 		return fmt.Errorf("donation upsert statement error: %w", err)
 	}
+	db.donationDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "donation_delete.sql")
+	if err != nil {
+		return fmt.Errorf("donation delete statement error: %w", err)
+	}
+	db.donationImportUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "donation_import_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("donation import upsert statement error: %w", err)
+	}
+	db.sfInstanceGetStmt, err = db.prepNamedStatement(db.sqlFS, "salesforce_instance.sql")
+	if err != nil {
+		return fmt.Errorf("salesforce instance statement error: %w", err)
+	}
+	db.sfInstanceUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "salesforce_instance_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("salesforce instance upsert statement error: %w", err)
+	}
+
+	// Link suggestions.
+	db.linkSuggestionsGetStmt, err = db.prepNamedStatement(db.sqlFS, "link_suggestions.sql")
+	if err != nil {
+		return fmt.Errorf("link suggestions statement error: %w", err)
+	}
+
+	// Reports.
+	db.accountTotalsGetStmt, err = db.prepNamedStatement(db.sqlFS, "report_account_totals.sql")
+	if err != nil {
+		return fmt.Errorf("account totals statement error: %w", err)
+	}
+	db.giftAidDonationsGetStmt, err = db.prepNamedStatement(db.sqlFS, "gift_aid_donations.sql")
+	if err != nil {
+		return fmt.Errorf("gift aid donations statement error: %w", err)
+	}
+	db.agingItemsGetStmt, err = db.prepNamedStatement(db.sqlFS, "aging_items.sql")
+	if err != nil {
+		return fmt.Errorf("aging items statement error: %w", err)
+	}
+	db.accountMonthsGetStmt, err = db.prepNamedStatement(db.sqlFS, "report_account_months.sql")
+	if err != nil {
+		return fmt.Errorf("account months statement error: %w", err)
+	}
+	db.payoutGroupsGetStmt, err = db.prepNamedStatement(db.sqlFS, "payout_groups.sql")
+	if err != nil {
+		return fmt.Errorf("payout groups statement error: %w", err)
+	}
+
+	// Payout report items.
+	db.payoutItemInsertStmt, err = db.prepNamedStatement(db.sqlFS, "payout_item_insert.sql")
+	if err != nil {
+		return fmt.Errorf("payout item insert statement error: %w", err)
+	}
+	db.payoutItemsDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "payout_items_delete.sql")
+	if err != nil {
+		return fmt.Errorf("payout items delete statement error: %w", err)
+	}
+	db.payoutItemsGetStmt, err = db.prepNamedStatement(db.sqlFS, "payout_items.sql")
+	if err != nil {
+		return fmt.Errorf("payout items statement error: %w", err)
+	}
+
+	// Bank statement lines.
+	db.statementLineUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "statement_line_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("statement line upsert statement error: %w", err)
+	}
+	db.statementLinesGetStmt, err = db.prepNamedStatement(db.sqlFS, "statement_lines.sql")
+	if err != nil {
+		return fmt.Errorf("statement lines statement error: %w", err)
+	}
+	db.statementMatchesGetStmt, err = db.prepNamedStatement(db.sqlFS, "statement_matches.sql")
+	if err != nil {
+		return fmt.Errorf("statement matches statement error: %w", err)
+	}
+
+	// Staged imports.
+	db.importBatchInsertStmt, err = db.prepNamedStatement(db.sqlFS, "import_batch_insert.sql")
+	if err != nil {
+		return fmt.Errorf("import batch insert statement error: %w", err)
+	}
+	db.importRowInsertStmt, err = db.prepNamedStatement(db.sqlFS, "import_row_insert.sql")
+	if err != nil {
+		return fmt.Errorf("import row insert statement error: %w", err)
+	}
+	db.importBatchesGetStmt, err = db.prepNamedStatement(db.sqlFS, "import_batches.sql")
+	if err != nil {
+		return fmt.Errorf("import batches statement error: %w", err)
+	}
+	db.importRowsGetStmt, err = db.prepNamedStatement(db.sqlFS, "import_rows.sql")
+	if err != nil {
+		return fmt.Errorf("import rows statement error: %w", err)
+	}
+	db.importBatchResolveStmt, err = db.prepNamedStatement(db.sqlFS, "import_batch_resolve.sql")
+	if err != nil {
+		return fmt.Errorf("import batch resolve statement error: %w", err)
+	}
+
+	// Import errors.
+	db.importErrorUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "import_error_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("import error upsert statement error: %w", err)
+	}
+	db.importErrorsGetStmt, err = db.prepNamedStatement(db.sqlFS, "import_errors.sql")
+	if err != nil {
+		return fmt.Errorf("import errors statement error: %w", err)
+	}
+	db.importErrorDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "import_error_delete.sql")
+	if err != nil {
+		return fmt.Errorf("import error delete statement error: %w", err)
+	}
+
+	// Contacts.
+	db.contactUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "contact_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("contact upsert statement error: %w", err)
+	}
+	db.contactGetStmt, err = db.prepNamedStatement(db.sqlFS, "contact.sql")
+	if err != nil {
+		return fmt.Errorf("contact statement error: %w", err)
+	}
+	db.contactRecordsGetStmt, err = db.prepNamedStatement(db.sqlFS, "contact_records.sql")
+	if err != nil {
+		return fmt.Errorf("contact records statement error: %w", err)
+	}
+	db.contactAliasesGetStmt, err = db.prepNamedStatement(db.sqlFS, "contact_aliases.sql")
+	if err != nil {
+		return fmt.Errorf("contact aliases statement error: %w", err)
+	}
+	db.contactAliasUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "contact_alias_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("contact alias upsert statement error: %w", err)
+	}
+	db.contactAliasDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "contact_alias_delete.sql")
+	if err != nil {
+		return fmt.Errorf("contact alias delete statement error: %w", err)
+	}
+	db.contactAliasCandidatesStmt, err = db.prepNamedStatement(db.sqlFS, "contact_alias_candidates.sql")
+	if err != nil {
+		return fmt.Errorf("contact alias candidates statement error: %w", err)
+	}
+
+	// Reconciliation tolerance.
+	db.toleranceGetStmt, err = db.prepNamedStatement(db.sqlFS, "tolerance.sql")
+	if err != nil {
+		return fmt.Errorf("tolerance statement error: %w", err)
+	}
+	db.toleranceUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "tolerance_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("tolerance upsert statement error: %w", err)
+	}
+
+	// Donation links and splits.
+	db.donationLinksDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "donation_links_delete.sql")
+	if err != nil {
+		return fmt.Errorf("donation links delete statement error: %w", err)
+	}
+	db.donationLinksInsertStmt, err = db.prepNamedStatement(db.sqlFS, "donation_links_insert.sql")
+	if err != nil {
+		return fmt.Errorf("donation links insert statement error: %w", err)
+	}
+	db.donationSplitsGetStmt, err = db.prepNamedStatement(db.sqlFS, "donation_splits.sql")
+	if err != nil {
+		return fmt.Errorf("donation splits statement error: %w", err)
+	}
+	db.donationSplitUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "donation_split_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("donation split upsert statement error: %w", err)
+	}
+	db.donationSplitDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "donation_split_delete.sql")
+	if err != nil {
+		return fmt.Errorf("donation split delete statement error: %w", err)
+	}
+	db.savedSearchesGetStmt, err = db.prepNamedStatement(db.sqlFS, "saved_searches.sql")
+	if err != nil {
+		return fmt.Errorf("saved searches statement error: %w", err)
+	}
+	db.savedSearchUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "saved_search_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("saved search upsert statement error: %w", err)
+	}
+	db.savedSearchDefaultStmt, err = db.prepNamedStatement(db.sqlFS, "saved_search_default.sql")
+	if err != nil {
+		return fmt.Errorf("saved search default statement error: %w", err)
+	}
+	db.savedSearchDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "saved_search_delete.sql")
+	if err != nil {
+		return fmt.Errorf("saved search delete statement error: %w", err)
+	}
+	db.searchStmt, err = db.prepNamedStatement(db.sqlFS, "search.sql")
+	if err != nil {
+		return fmt.Errorf("search statement error: %w", err)
+	}
+
+	// Pending link actions.
+	db.pendingActionsGetStmt, err = db.prepNamedStatement(db.sqlFS, "pending_actions.sql")
+	if err != nil {
+		return fmt.Errorf("pending actions statement error: %w", err)
+	}
+	db.pendingActionGetStmt, err = db.prepNamedStatement(db.sqlFS, "pending_action.sql")
+	if err != nil {
+		return fmt.Errorf("pending action statement error: %w", err)
+	}
+	db.pendingActionInsertStmt, err = db.prepNamedStatement(db.sqlFS, "pending_action_insert.sql")
+	if err != nil {
+		return fmt.Errorf("pending action insert statement error: %w", err)
+	}
+	db.pendingActionUpdateStmt, err = db.prepNamedStatement(db.sqlFS, "pending_action_update.sql")
+	if err != nil {
+		return fmt.Errorf("pending action update statement error: %w", err)
+	}
+	db.donationRefsGetStmt, err = db.prepNamedStatement(db.sqlFS, "donation_refs.sql")
+	if err != nil {
+		return fmt.Errorf("donation refs statement error: %w", err)
+	}
+
+	// Outbound mutations.
+	db.outboundMutationGetStmt, err = db.prepNamedStatement(db.sqlFS, "outbound_mutation.sql")
+	if err != nil {
+		return fmt.Errorf("outbound mutation statement error: %w", err)
+	}
+	db.outboundMutationUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "outbound_mutation_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("outbound mutation upsert statement error: %w", err)
+	}
+
+	// Orphaned donations.
+	db.donationIDsGetStmt, err = db.prepNamedStatement(db.sqlFS, "donation_ids.sql")
+	if err != nil {
+		return fmt.Errorf("donation ids statement error: %w", err)
+	}
+	db.donationOrphansGetStmt, err = db.prepNamedStatement(db.sqlFS, "donation_orphans.sql")
+	if err != nil {
+		return fmt.Errorf("donation orphans statement error: %w", err)
+	}
+	db.donationOrphanUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "donation_orphan_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("donation orphan upsert statement error: %w", err)
+	}
+	db.donationOrphansClearStmt, err = db.prepNamedStatement(db.sqlFS, "donation_orphans_clear.sql")
+	if err != nil {
+		return fmt.Errorf("donation orphans clear statement error: %w", err)
+	}
+
+	// Queued Salesforce updates.
+	db.salesforceOutboxInsertStmt, err = db.prepNamedStatement(db.sqlFS, "salesforce_outbox_insert.sql")
+	if err != nil {
+		return fmt.Errorf("salesforce outbox insert statement error: %w", err)
+	}
+	db.salesforceOutboxClearStmt, err = db.prepNamedStatement(db.sqlFS, "salesforce_outbox_clear.sql")
+	if err != nil {
+		return fmt.Errorf("salesforce outbox clear statement error: %w", err)
+	}
+	db.salesforceOutboxActionsStmt, err = db.prepNamedStatement(db.sqlFS, "salesforce_outbox_actions.sql")
+	if err != nil {
+		return fmt.Errorf("salesforce outbox actions statement error: %w", err)
+	}
+
+	// Notes and flags.
+	db.annotationsGetStmt, err = db.prepNamedStatement(db.sqlFS, "annotations.sql")
+	if err != nil {
+		return fmt.Errorf("annotations statement error: %w", err)
+	}
+	db.annotationInsertStmt, err = db.prepNamedStatement(db.sqlFS, "annotation_insert.sql")
+	if err != nil {
+		return fmt.Errorf("annotation insert statement error: %w", err)
+	}
+	db.annotationDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "annotation_delete.sql")
+	if err != nil {
+		return fmt.Errorf("annotation delete statement error: %w", err)
+	}
+
+	// Assignments.
+	db.assignmentGetStmt, err = db.prepNamedStatement(db.sqlFS, "assignment.sql")
+	if err != nil {
+		return fmt.Errorf("assignment statement error: %w", err)
+	}
+	db.assignmentUpsertStmt, err = db.prepNamedStatement(db.sqlFS, "assignment_upsert.sql")
+	if err != nil {
+		return fmt.Errorf("assignment upsert statement error: %w", err)
+	}
+	db.assignmentDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "assignment_delete.sql")
+	if err != nil {
+		return fmt.Errorf("assignment delete statement error: %w", err)
+	}
+
+	// Account rules.
+	db.accountRulesGetStmt, err = db.prepNamedStatement(db.sqlFS, "account_rules.sql")
+	if err != nil {
+		return fmt.Errorf("account rules statement error: %w", err)
+	}
+	db.accountRuleInsertStmt, err = db.prepNamedStatement(db.sqlFS, "account_rule_insert.sql")
+	if err != nil {
+		return fmt.Errorf("account rule insert statement error: %w", err)
+	}
+	db.accountRuleDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "account_rule_delete.sql")
+	if err != nil {
+		return fmt.Errorf("account rule delete statement error: %w", err)
+	}
+	db.accountRulesApplyInvoicesStmt, err = db.prepNamedStatement(db.sqlFS, "account_rules_apply_invoices.sql")
+	if err != nil {
+		return fmt.Errorf("account rules apply invoices statement error: %w", err)
+	}
+	db.accountRulesApplyBankTransactionsStmt, err = db.prepNamedStatement(db.sqlFS, "account_rules_apply_bank_transactions.sql")
+	if err != nil {
+		return fmt.Errorf("account rules apply bank transactions statement error: %w", err)
+	}
+
+	// Donation accounts.
+	db.accountsGetStmt, err = db.prepNamedStatement(db.sqlFS, "accounts.sql")
+	if err != nil {
+		return fmt.Errorf("accounts statement error: %w", err)
+	}
+	db.accountsDonationUpdateStmt, err = db.prepNamedStatement(db.sqlFS, "accounts_donation_update.sql")
+	if err != nil {
+		return fmt.Errorf("accounts donation update statement error: %w", err)
+	}
+	db.donationAccountsDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "donation_accounts_delete.sql")
+	if err != nil {
+		return fmt.Errorf("donation accounts delete statement error: %w", err)
+	}
+	db.donationAccountsInsertStmt, err = db.prepNamedStatement(db.sqlFS, "donation_accounts_insert.sql")
+	if err != nil {
+		return fmt.Errorf("donation accounts insert statement error: %w", err)
+	}
+
+	// Period locks and their audit events.
+	db.periodLocksGetStmt, err = db.prepNamedStatement(db.sqlFS, "period_locks.sql")
+	if err != nil {
+		return fmt.Errorf("period locks statement error: %w", err)
+	}
+	db.periodLockInsertStmt, err = db.prepNamedStatement(db.sqlFS, "period_lock_insert.sql")
+	if err != nil {
+		return fmt.Errorf("period lock insert statement error: %w", err)
+	}
+	db.periodLockDeleteStmt, err = db.prepNamedStatement(db.sqlFS, "period_lock_delete.sql")
+	if err != nil {
+		return fmt.Errorf("period lock delete statement error: %w", err)
+	}
+	db.periodLocksCoveringStmt, err = db.prepNamedStatement(db.sqlFS, "period_locks_covering.sql")
+	if err != nil {
+		return fmt.Errorf("period locks covering statement error: %w", err)
+	}
+	db.auditEventInsertStmt, err = db.prepNamedStatement(db.sqlFS, "audit_event_insert.sql")
+	if err != nil {
+		return fmt.Errorf("audit event insert statement error: %w", err)
+	}
+	db.auditEventsGetStmt, err = db.prepNamedStatement(db.sqlFS, "audit_events.sql")
+	if err != nil {
+		return fmt.Errorf("audit events statement error: %w", err)
+	}
+
+	// Period snapshots.
+	db.periodSnapshotInsertStmt, err = db.prepNamedStatement(db.sqlFS, "period_snapshot_insert.sql")
+	if err != nil {
+		return fmt.Errorf("period snapshot insert statement error: %w", err)
+	}
+	db.periodSnapshotsGetStmt, err = db.prepNamedStatement(db.sqlFS, "period_snapshots.sql")
+	if err != nil {
+		return fmt.Errorf("period snapshots statement error: %w", err)
+	}
+	db.periodSnapshotDonationsStmt, err = db.prepNamedStatement(db.sqlFS, "period_snapshot_donations.sql")
+	if err != nil {
+		return fmt.Errorf("period snapshot donations statement error: %w", err)
+	}
+
+	// Sync runs.
+	db.syncRunInsertStmt, err = db.prepNamedStatement(db.sqlFS, "sync_run_insert.sql")
+	if err != nil {
+		return fmt.Errorf("sync run insert statement error: %w", err)
+	}
+	db.syncRunsGetStmt, err = db.prepNamedStatement(db.sqlFS, "sync_runs.sql")
+	if err != nil {
+		return fmt.Errorf("sync runs statement error: %w", err)
+	}
+	db.syncRecordCountsGetStmt, err = db.prepNamedStatement(db.sqlFS, "sync_record_counts.sql")
+	if err != nil {
+		return fmt.Errorf("sync record counts statement error: %w", err)
+	}
+
+	// Link mappings.
+	db.linkMappingsGetStmt, err = db.prepNamedStatement(db.sqlFS, "link_mappings.sql")
+	if err != nil {
+		return fmt.Errorf("link mappings statement error: %w", err)
+	}
+	db.linkMappingsImportStmt, err = db.prepNamedStatement(db.sqlFS, "link_mappings_import.sql")
+	if err != nil {
+		return fmt.Errorf("link mappings import statement error: %w", err)
+	}
+	db.linkChecksGetStmt, err = db.prepNamedStatement(db.sqlFS, "link_checks.sql")
+	if err != nil {
+		return fmt.Errorf("link checks statement error: %w", err)
+	}
 
 	return nil
 }
@@ -322,16 +1073,69 @@ func (db *DB) prepNamedStatement(fileFS fs.FS, filePath string) (*parameterizedS
 		return nil, fmt.Errorf("could not parameterize %q: %w", filePath, err)
 	}
 
-	pQuery, err := db.PrepareNamed(string(query.Body))
+	// The default statement includes all the optional blocks.
+	pQuery, err := db.PrepareNamed(string(query.Render(func(string) bool { return true })))
 	if err != nil {
 		db.log.Error(fmt.Sprintf("could not prepare statement %q: %v", filePath, err))
 		return nil, fmt.Errorf("could not prepare statement %q: %w", filePath, err)
 	}
-	return &parameterizedStmt{
-		filePath,
-		query.Parameters,
-		pQuery,
-	}, nil
+	stmt := &parameterizedStmt{
+		sqlFile:   filePath,
+		args:      query.Parameters,
+		NamedStmt: pQuery,
+		tpl:       query,
+		prepare:   db.PrepareNamed,
+		monitor:   db.monitor,
+		writes:    db.writes,
+		cache:     db.cache,
+		timeout:   db.statementTimeout,
+		log:       db.log,
+	}
+	db.prepared = append(db.prepared, stmt)
+	return stmt, nil
+}
+
+// ReloadStatements re-reads the sql files and prepares the named statements again, so
+// that changes to the sql can be tested without restarting in development mode. If
+// any statement fails to prepare the existing statements are kept.
+//
+// Queries must not be run while the statements are reloaded.
+func (db *DB) ReloadStatements() error {
+
+	// Prepare the statements on a copy of the connection.
+	fresh := &DB{
+		DB:               db.DB,
+		Path:             db.Path,
+		accountCodes:     db.accountCodes,
+		selectedCodes:    db.selectedCodes,
+		sqlFS:            db.sqlFS,
+		log:              db.log,
+		logLevel:         db.logLevel,
+		monitor:          db.monitor,
+		writes:           db.writes,
+		cache:            db.cache,
+		statementTimeout: db.statementTimeout,
+	}
+	if err := fresh.prepareNamedStatements(); err != nil {
+		for _, stmt := range fresh.prepared {
+			_ = stmt.close()
+		}
+		return fmt.Errorf("could not reload statements: %w", err)
+	}
+
+	old := db.prepared
+	*db = *fresh
+	db.cache.invalidate()
+
+	var errs []error
+	for _, stmt := range old {
+		errs = append(errs, stmt.close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		db.log.Error(fmt.Sprintf("could not close reloaded statements: %v", err))
+	}
+	db.log.Info(fmt.Sprintf("reloaded %d sql statements", len(db.prepared)))
+	return nil
 }
 
 // InitSchema creates the necessary tables if they don't already exist. The schema file
@@ -352,13 +1156,18 @@ func (db *DB) InitSchema(fileFS fs.FS, filePath string) error {
 	return nil
 }
 
-// logQuery is for helping debug SQL issues.
-func (db *DB) logQuery(name string, stmt *parameterizedStmt, args map[string]any, err error) {
-	db.log.Debug(
+// logQuery is for helping debug SQL issues. The request id carried by ctx, if any, is
+// logged with the query.
+func (db *DB) logQuery(ctx context.Context, name string, stmt *parameterizedStmt, args map[string]any, err error) {
+	query := stmt.QueryString
+	if named, nErr := stmt.named(args); nErr == nil {
+		query = named.QueryString
+	}
+	requestid.Logger(ctx, db.log).Debug(
 		fmt.Sprintf(
 			"sql: %s\n---\nquery:\n%q\n---\nargs: %#v\nerror: %v\n",
 			name,
-			stmt.QueryString,
+			query,
 			args,
 			err,
 		),
@@ -1,10 +1,16 @@
 package db
 
 import (
+	"bytes"
+	"context"
+	"io/fs"
 	"log/slog"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
+	"github.com/rorycl/reconciler/internal/money"
 	mounts "github.com/rorycl/reconciler/internal/mounts"
 )
 
@@ -14,7 +20,7 @@ func ptrStr(s string) *string { return &s }
 
 // func ptrBool(b bool) *bool { return &b }
 
-func ptrFloat64(f float64) *float64 { return &f }
+func ptrAmount(f float64) *money.Amount { return new(money.FromFloat(f)) }
 
 // setupTestDB sets up a test database connection.
 func setupTestDB(t *testing.T) (*DB, func()) {
@@ -48,3 +54,173 @@ func setupTestDB(t *testing.T) (*DB, func()) {
 
 	return testDB, closeDBFunc
 }
+
+func TestParameterizedStmtNamed(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	defer closeDB()
+
+	stmt := testDB.donationsGetStmt
+	args := func(search, reference, assignee string) map[string]any {
+		return map[string]any{"TextSearch": search, "PayoutReference": reference, "AssignedTo": assignee}
+	}
+
+	// With all the optional arguments the default statement is used.
+	named, err := stmt.named(args("x", "y", "z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if named != stmt.NamedStmt {
+		t.Error("expected the default statement with all arguments present")
+	}
+
+	// Variants omit the blocks and are reused.
+	named, err = stmt.named(args("", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(named.QueryString, "v.TextSearch)") || strings.Contains(named.QueryString, "v.PayoutReference)") || strings.Contains(named.QueryString, "= v.AssignedTo") {
+		t.Errorf("expected optional blocks to be omitted:\n%s", named.QueryString)
+	}
+	again, err := stmt.named(args("", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != named {
+		t.Error("expected the variant statement to be reused")
+	}
+	other, err := stmt.named(args("x", "", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == named || !strings.Contains(other.QueryString, "FOLD(v.TextSearch))") {
+		t.Errorf("unexpected search variant:\n%s", other.QueryString)
+	}
+}
+
+func TestReloadStatements(t *testing.T) {
+
+	// Copy the sql files to a map file system so that they can be changed.
+	sqlFS := fstest.MapFS{}
+	sub, err := fs.Sub(SQLEmbeddedFS, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.WalkDir(sub, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(sub, path)
+		sqlFS[path] = &fstest.MapFile{Data: b}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDB, err := NewConnectionInTestMode("file:reloadtest?mode=memory&cache=shared", sqlFS, "^(53|55|57)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = testDB.Close()
+	}()
+	testDB.SetLogLevel(slog.LevelError)
+
+	count := len(testDB.prepared)
+	old := testDB.invoicesGetStmt
+
+	// A change to the sql is picked up on reload.
+	body := string(sqlFS["invoices.sql"].Data)
+	sqlFS["invoices.sql"].Data = []byte(strings.Replace(body, "    END ASC\n", "    END DESC -- reloaded\n", 1))
+	if err := testDB.ReloadStatements(); err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if testDB.invoicesGetStmt == old {
+		t.Fatal("expected a new invoices statement")
+	}
+	if !strings.Contains(testDB.invoicesGetStmt.QueryString, "END DESC -- reloaded") {
+		t.Error("expected the reloaded invoices statement to include the change")
+	}
+	if got, want := len(testDB.prepared), count; got != want {
+		t.Errorf("prepared statements got %d want %d", got, want)
+	}
+	invoices, err := testDB.InvoicesGet(context.Background(), "All", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatalf("invoices get error after reload: %v", err)
+	}
+	if len(invoices) > 1 && invoices[0].Date.Before(invoices[len(invoices)-1].Date) {
+		t.Error("expected invoices in descending date order after reload")
+	}
+
+	// Statements are kept if the reload fails.
+	current := testDB.invoicesGetStmt
+	sqlFS["invoices.sql"].Data = []byte("SELECT nonsense FROM")
+	if err := testDB.ReloadStatements(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if testDB.invoicesGetStmt != current {
+		t.Error("expected the statements to be kept after a failed reload")
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	testDB.SetLogLevel(slog.LevelError)
+
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	testDB.SetStatementTimeout(time.Nanosecond)
+	if _, err := testDB.InvoicesGet(context.Background(), "All", from, to, "", "", SortOrder{}, -1, 0); err == nil {
+		t.Error("expected the statement to time out")
+	}
+
+	// A cancelled context, such as that of a disconnected browser, also stops queries.
+	testDB.SetStatementTimeout(time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := testDB.InvoicesGet(ctx, "All", from, to, "", "", SortOrder{}, -1, 0); err == nil {
+		t.Error("expected the cancelled query to fail")
+	}
+
+	if _, err := testDB.InvoicesGet(context.Background(), "All", from, to, "", "", SortOrder{}, -1, 0); err != nil {
+		t.Errorf("unexpected error within the timeout: %v", err)
+	}
+}
+
+// TestSetLogLevel tests that the log level limits the messages written to the logger
+// provided to the connection.
+func TestSetLogLevel(t *testing.T) {
+
+	sqlFS, err := mounts.NewFileMount("sql", SQLEmbeddedFS, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	testDB, err := NewConnectionInTestMode("file:logleveltest?mode=memory&cache=shared", sqlFS, "^(53|55|57)", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = testDB.Close()
+	}()
+
+	testDB.SetLogLevel(slog.LevelWarn)
+	buf.Reset()
+	if err := testDB.ReloadStatements(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected info message %q", buf.String())
+	}
+
+	testDB.SetLogLevel(slog.LevelInfo)
+	if err := testDB.ReloadStatements(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "reloaded") {
+		t.Errorf("expected reload message, got %q", buf.String())
+	}
+}
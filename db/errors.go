@@ -0,0 +1,34 @@
+package db
+
+// errors.go sets out the typed errors of the db package, so that callers can tell a
+// missing record or an invalid argument from a database failure.
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ErrNotFound reports that the record of Kind with ID does not exist. It matches
+// sql.ErrNoRows with errors.Is.
+type ErrNotFound struct {
+	Kind string // such as "invoice" or "contact"
+	ID   string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Kind, e.ID)
+}
+
+func (e ErrNotFound) Unwrap() error {
+	return sql.ErrNoRows
+}
+
+// ErrValidation reports an invalid argument.
+type ErrValidation struct {
+	Field string
+	Msg   string
+}
+
+func (e ErrValidation) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Msg)
+}
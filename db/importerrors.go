@@ -0,0 +1,129 @@
+package db
+
+// importerrors.go records the Xero and Salesforce records which failed validation on
+// retrieval, or which could not be upserted, so that these are quarantined for review
+// rather than stored as garbage rows or failing the whole refresh.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ImportError is a Xero or Salesforce record which failed validation or could not be
+// upserted. The Source is "xero" or "salesforce" and the RecordType, for example,
+// "invoice" or "donation". The Payload is the json encoded record and the Error its
+// validation problems or upsert error.
+type ImportError struct {
+	ID         int64     `db:"id"`
+	Source     string    `db:"source"`
+	RecordType string    `db:"record_type"`
+	RecordID   string    `db:"record_id"`
+	Error      string    `db:"error"`
+	Payload    string    `db:"payload"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// ImportErrorsUpsert records the import errors, replacing the earlier errors of
+// records with the same ids.
+func (db *DB) ImportErrorsUpsert(ctx context.Context, importErrors []ImportError) error {
+
+	if len(importErrors) == 0 {
+		return nil
+	}
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("importErrorsUpsert: could not begin transaction: %v", err))
+		return fmt.Errorf("importErrorsUpsert: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	stmt := db.importErrorUpsertStmt
+	for _, ie := range importErrors {
+		namedArgs := map[string]any{
+			"Source":     ie.Source,
+			"RecordType": ie.RecordType,
+			"RecordID":   ie.RecordID,
+			"Error":      ie.Error,
+			"Payload":    ie.Payload,
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("import error upsert verify arguments error: %v", err))
+			return fmt.Errorf("import error upsert verify arguments error: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to upsert import error for %s %s %q: %v", ie.Source, ie.RecordType, ie.RecordID, err))
+			return fmt.Errorf("failed to upsert import error for %s %s %q: %w", ie.Source, ie.RecordType, ie.RecordID, err)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("recorded %d import errors", len(importErrors)))
+	return tx.commit()
+}
+
+// importErrorsSelect selects the import error with id, or the most recent import errors
+// if id is 0.
+func (db *DB) importErrorsSelect(ctx context.Context, id int64) ([]ImportError, error) {
+
+	stmt := db.importErrorsGetStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import errors verify arguments error: %v", err))
+		return nil, fmt.Errorf("import errors verify arguments error: %w", err)
+	}
+
+	var importErrors []ImportError
+	err := stmt.SelectContext(ctx, &importErrors, namedArgs)
+	db.logQuery(ctx, "import errors", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("import errors select error: %v", err))
+		return nil, fmt.Errorf("import errors select error: %w", err)
+	}
+	return importErrors, nil
+}
+
+// ImportErrorsGet retrieves the 200 most recent import errors, most recent first.
+func (db *DB) ImportErrorsGet(ctx context.Context) ([]ImportError, error) {
+	return db.importErrorsSelect(ctx, 0)
+}
+
+// ImportErrorGet retrieves an import error by id. ErrNotFound, which matches
+// sql.ErrNoRows, is returned if the import error does not exist.
+func (db *DB) ImportErrorGet(ctx context.Context, id int64) (ImportError, error) {
+	importErrors, err := db.importErrorsSelect(ctx, id)
+	if err != nil {
+		return ImportError{}, err
+	}
+	if len(importErrors) == 0 {
+		return ImportError{}, ErrNotFound{"import error", strconv.FormatInt(id, 10)}
+	}
+	return importErrors[0], nil
+}
+
+// ImportErrorDelete deletes the import error with id, once its record has been stored
+// or the error discarded.
+func (db *DB) ImportErrorDelete(ctx context.Context, id int64) error {
+
+	stmt := db.importErrorDeleteStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import error delete verify arguments error: %v", err))
+		return fmt.Errorf("import error delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete import error %d: %v", id, err))
+		return fmt.Errorf("failed to delete import error %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted import error %d", id))
+	return nil
+}
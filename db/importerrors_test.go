@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestImportErrors(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	importErrors := []ImportError{
+		{Source: "xero", RecordType: "invoice", RecordID: "inv-bad", Error: "no date", Payload: `{"InvoiceID":"inv-bad"}`},
+		{Source: "salesforce", RecordType: "donation", Error: "no Id", Payload: `{}`},
+		{Source: "salesforce", RecordType: "donation", Error: "no Id", Payload: `{}`},
+	}
+	if err := testDB.ImportErrorsUpsert(ctx, importErrors); err != nil {
+		t.Fatal(err)
+	}
+
+	// A record failing validation again replaces its earlier error, while records
+	// without ids are each recorded.
+	again := ImportError{Source: "xero", RecordType: "invoice", RecordID: "inv-bad", Error: "date out of range", Payload: `{}`}
+	if err := testDB.ImportErrorsUpsert(ctx, []ImportError{again}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.ImportErrorsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d import errors want 3: %+v", len(got), got)
+	}
+	var replaced *ImportError
+	for i, ie := range got {
+		if ie.RecordID == "inv-bad" {
+			replaced = &got[i]
+		}
+	}
+	if replaced == nil || replaced.Error != "date out of range" || replaced.CreatedAt.IsZero() {
+		t.Errorf("unexpected replaced import error %+v", replaced)
+	}
+
+	ie, err := testDB.ImportErrorGet(ctx, replaced.ID)
+	if err != nil || ie.RecordID != "inv-bad" {
+		t.Errorf("ImportErrorGet got %+v, %v", ie, err)
+	}
+	if _, err := testDB.ImportErrorGet(ctx, 999); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	if err := testDB.ImportErrorDelete(ctx, replaced.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.ImportErrorGet(ctx, replaced.ID); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected the deleted import error to be not found, got %v", err)
+	}
+
+	invalid := ImportError{Source: "stripe", RecordType: "invoice", RecordID: "inv-1", Error: "x", Payload: "{}"}
+	if err := testDB.ImportErrorsUpsert(ctx, []ImportError{invalid}); err == nil {
+		t.Error("expected an error for an invalid source")
+	}
+}
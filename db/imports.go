@@ -0,0 +1,196 @@
+package db
+
+// imports.go stages the rows of imports for review, so that only the accepted rows of
+// an import are committed to the live tables, and only once the import is confirmed.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// The kinds of import.
+const (
+	ImportDonations      = "donations"
+	ImportPayoutItems    = "payout-items"
+	ImportStatementLines = "statement-lines"
+)
+
+// ImportBatch is a staged import. The Target is where the rows are committed, being
+// the source tag of imported donations, the reference of the payout of a payout report
+// or the bank account of a bank statement. The Status is staged, committed or
+// discarded.
+type ImportBatch struct {
+	ID         int64      `db:"id"`
+	Kind       string     `db:"kind"`
+	Target     string     `db:"target"`
+	FileName   string     `db:"file_name"`
+	Status     string     `db:"status"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ResolvedAt *time.Time `db:"resolved_at"`
+	Accepted   int        `db:"accepted"`
+	Rejected   int        `db:"rejected"`
+}
+
+// ImportRow is a row of an import batch. Vals is the json encoded row as read. An
+// accepted row has the json encoded record to commit as its Payload, while a rejected
+// row has its validation Error.
+type ImportRow struct {
+	BatchID  int64   `db:"batch_id"`
+	RowNo    int     `db:"row_no"`
+	RecordID string  `db:"record_id"`
+	Vals     string  `db:"vals"`
+	Payload  *string `db:"payload"`
+	Error    *string `db:"error"`
+}
+
+// ImportBatchCreate stages the rows of an import of kind for target, returning the id
+// of the batch.
+func (db *DB) ImportBatchCreate(ctx context.Context, kind, target, fileName string, rows []ImportRow) (int64, error) {
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("importBatchCreate: could not begin transaction: %v", err))
+		return 0, fmt.Errorf("importBatchCreate: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	batchStmt := db.importBatchInsertStmt
+	namedArgs := map[string]any{
+		"Kind":     kind,
+		"Target":   target,
+		"FileName": fileName,
+	}
+	if err := batchStmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import batch insert verify arguments error: %v", err))
+		return 0, fmt.Errorf("import batch insert verify arguments error: %w", err)
+	}
+	result, err := batchStmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert import batch: %v", err))
+		return 0, fmt.Errorf("failed to insert import batch: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("import batch id error: %w", err)
+	}
+
+	rowStmt := db.importRowInsertStmt
+	for _, row := range rows {
+		namedArgs := map[string]any{
+			"BatchID":  id,
+			"RowNo":    row.RowNo,
+			"RecordID": row.RecordID,
+			"Vals":     row.Vals,
+			"Payload":  row.Payload,
+			"Error":    row.Error,
+		}
+		if err := rowStmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("import row insert verify arguments error: %v", err))
+			return 0, fmt.Errorf("import row insert verify arguments error: %w", err)
+		}
+		if _, err := rowStmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to insert import row %d: %v", row.RowNo, err))
+			return 0, fmt.Errorf("failed to insert import row %d: %w", row.RowNo, err)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("import batch %d staged: %d %s rows for %s", id, len(rows), kind, target))
+	return id, tx.commit()
+}
+
+// importBatchesSelect selects the batch with id, or the most recent batches if id is 0.
+func (db *DB) importBatchesSelect(ctx context.Context, id int64) ([]ImportBatch, error) {
+
+	stmt := db.importBatchesGetStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import batches verify arguments error: %v", err))
+		return nil, fmt.Errorf("import batches verify arguments error: %w", err)
+	}
+
+	var batches []ImportBatch
+	err := stmt.SelectContext(ctx, &batches, namedArgs)
+	db.logQuery(ctx, "import batches", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("import batches select error: %v", err))
+		return nil, fmt.Errorf("import batches select error: %w", err)
+	}
+	return batches, nil
+}
+
+// ImportBatchesGet retrieves the 50 most recent import batches, most recent first.
+func (db *DB) ImportBatchesGet(ctx context.Context) ([]ImportBatch, error) {
+	return db.importBatchesSelect(ctx, 0)
+}
+
+// ImportBatchGet retrieves an import batch by id. ErrNotFound, which matches
+// sql.ErrNoRows, is returned if the batch does not exist.
+func (db *DB) ImportBatchGet(ctx context.Context, id int64) (ImportBatch, error) {
+	batches, err := db.importBatchesSelect(ctx, id)
+	if err != nil {
+		return ImportBatch{}, err
+	}
+	if len(batches) == 0 {
+		return ImportBatch{}, ErrNotFound{"import batch", strconv.FormatInt(id, 10)}
+	}
+	return batches[0], nil
+}
+
+// ImportRowsGet retrieves the rows of the import batch with id in row order.
+func (db *DB) ImportRowsGet(ctx context.Context, id int64) ([]ImportRow, error) {
+
+	stmt := db.importRowsGetStmt
+
+	namedArgs := map[string]any{
+		"BatchID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import rows verify arguments error: %v", err))
+		return nil, fmt.Errorf("import rows verify arguments error: %w", err)
+	}
+
+	var rows []ImportRow
+	err := stmt.SelectContext(ctx, &rows, namedArgs)
+	db.logQuery(ctx, "import rows", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("import rows select error: %v", err))
+		return nil, fmt.Errorf("import rows select error: %w", err)
+	}
+	return rows, nil
+}
+
+// ImportBatchResolve marks the staged import batch with id as committed or discarded,
+// reporting false if the batch was not staged.
+func (db *DB) ImportBatchResolve(ctx context.Context, id int64, status string) (bool, error) {
+
+	stmt := db.importBatchResolveStmt
+
+	namedArgs := map[string]any{
+		"ID":     id,
+		"Status": status,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("import batch resolve verify arguments error: %v", err))
+		return false, fmt.Errorf("import batch resolve verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to resolve import batch %d: %v", id, err))
+		return false, fmt.Errorf("failed to resolve import batch %d: %w", id, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("import batch resolve rows affected error: %w", err)
+	}
+	if n > 0 {
+		db.log.Info(fmt.Sprintf("import batch %d %s", id, status))
+	}
+	return n > 0, nil
+}
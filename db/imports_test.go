@@ -0,0 +1,72 @@
+package db
+
+// tests for staged imports
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestImportBatches(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	rejected := "invalid amount"
+	rows := []ImportRow{
+		{RowNo: 2, RecordID: "1001", Vals: `["1001","20.00"]`, Payload: ptrStr(`{"ID":"crm-1001"}`)},
+		{RowNo: 3, RecordID: "1002", Vals: `["1002","ten"]`, Error: &rejected},
+	}
+	id, err := testDB.ImportBatchCreate(ctx, ImportDonations, "crm", "export.csv", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch, err := testDB.ImportBatchGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportBatch{ID: id, Kind: ImportDonations, Target: "crm", FileName: "export.csv", Status: "staged", Accepted: 1, Rejected: 1}
+	if diff := cmp.Diff(want, batch, cmpopts.IgnoreFields(ImportBatch{}, "CreatedAt")); diff != "" {
+		t.Errorf("batch mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err := testDB.ImportRowsGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rows {
+		rows[i].BatchID = id
+	}
+	if diff := cmp.Diff(rows, got); diff != "" {
+		t.Errorf("rows mismatch (-want +got):\n%s", diff)
+	}
+
+	// A batch may only be resolved once.
+	for i, want := range []bool{true, false} {
+		ok, err := testDB.ImportBatchResolve(ctx, id, "committed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("resolve %d got %t want %t", i, ok, want)
+		}
+	}
+
+	batches, err := testDB.ImportBatchesGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].Status != "committed" || batches[0].ResolvedAt == nil {
+		t.Errorf("unexpected batches %+v", batches)
+	}
+
+	if _, err := testDB.ImportBatchGet(ctx, id+1); !errors.As(err, &ErrNotFound{}) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
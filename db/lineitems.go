@@ -0,0 +1,70 @@
+package db
+
+// lineitems.go retrieves the line items of sets of invoices and bank transactions, with
+// the fields of their records, for the line item exports of the listings.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// LineItemExport is a line item of an invoice or bank transaction with the fields of
+// its record. RecordType is "invoice" or "bank-transaction", and Number the invoice
+// number, which bank transactions do not have. DonationAccount reports if the line
+// item is coded to a donation account.
+type LineItemExport struct {
+	RecordType      string       `db:"record_type"`
+	RecordID        string       `db:"record_id"`
+	Number          string       `db:"number"`
+	Reference       string       `db:"reference"`
+	Date            time.Time    `db:"date"`
+	Contact         string       `db:"contact"`
+	Status          string       `db:"status"`
+	CurrencyCode    string       `db:"currency_code"`
+	Total           money.Amount `db:"total"`
+	AccountCode     string       `db:"account_code"`
+	AccountName     string       `db:"account_name"`
+	Description     string       `db:"description"`
+	Quantity        float64      `db:"quantity"`
+	UnitAmount      money.Amount `db:"unit_amount"`
+	TaxAmount       money.Amount `db:"tax_amount"`
+	LineAmount      money.Amount `db:"line_amount"`
+	DonationAccount bool         `db:"donation_account"`
+}
+
+// LineItemsExportGet retrieves the line items of the invoices, or bank transactions if
+// recordType is "bank-transaction", with the given ids, in the order of the ids.
+// Records without line items are left out.
+func (db *DB) LineItemsExportGet(ctx context.Context, recordType string, recordIDs []string) ([]LineItemExport, error) {
+
+	db.log.Info(fmt.Sprintf("LineItemsExportGet for %d %s records", len(recordIDs), recordType))
+
+	stmt := db.lineItemsExportStmt
+
+	ids, err := json.Marshal(recordIDs)
+	if err != nil {
+		return nil, fmt.Errorf("line items export id encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"RecordType":   recordType,
+		"RecordIDs":    string(ids),
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("line items export verify arguments error: %v", err))
+		return nil, fmt.Errorf("line items export verify arguments error: %w", err)
+	}
+
+	var items []LineItemExport
+	err = stmt.SelectContext(ctx, &items, namedArgs)
+	db.logQuery(ctx, "line items export", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("line items export select error: %v", err))
+		return nil, fmt.Errorf("line items export select error: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestLineItemsExportGet tests retrieving the line items of invoices and bank
+// transactions for export.
+func TestLineItemsExportGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := t.Context()
+
+	// The line items are in the order of the records, and records without line items,
+	// or missing, are left out.
+	items, err := testDB.LineItemsExportGet(ctx, "invoice", []string{"inv-002", "inv-001", "inv-missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type line struct {
+		record, account, accountName string
+		amount                       money.Amount
+		donation                     bool
+	}
+	var got []line
+	for _, li := range items {
+		if li.RecordType != "invoice" {
+			t.Errorf("record type got %q want invoice", li.RecordType)
+		}
+		got = append(got, line{li.RecordID, li.AccountCode, li.AccountName, li.LineAmount, li.DonationAccount})
+	}
+	want := []line{
+		{"inv-002", "5301", "Fundraising Dinners", money.FromFloat(200), true},
+		{"inv-002", "429", "Platform Fees", money.FromFloat(-3.50), false},
+		{"inv-001", "5501", "General Giving", money.FromFloat(500), true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d line items want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line item %d got %+v want %+v", i, got[i], want[i])
+		}
+	}
+	if got, want := items[0].Number, "INV-2025-102"; got != want {
+		t.Errorf("invoice number got %q want %q", got, want)
+	}
+
+	items, err = testDB.LineItemsExportGet(ctx, "bank-transaction", []string{"bt-001", "inv-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(items), 3; got != want {
+		t.Fatalf("got %d bank transaction line items want %d", got, want)
+	}
+	for _, li := range items {
+		if li.RecordID != "bt-001" || li.Reference != "JG-PAYOUT-2025-04-15" || li.Number != "" {
+			t.Errorf("unexpected bank transaction line item %+v", li)
+		}
+	}
+}
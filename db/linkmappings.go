@@ -0,0 +1,134 @@
+package db
+
+// linkmappings.go provides the export of the donation links as link mappings, and
+// their import into another database, so that the reconciliation work recorded in the
+// links, such as donation splits, survives rebuilding the database from scratch. The
+// links may also be retrieved to be checked against the remote records.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// LinkMapping is a donation link between a donation and an invoice or bank
+// transaction. PayoutReference is the matched payout reference of a link made from the
+// payout reference of the donation, and Amount the amount of a donation split; each is
+// nil otherwise.
+type LinkMapping struct {
+	DonationID      string        `db:"donation_id" json:"donation_id"`
+	RecordType      string        `db:"record_type" json:"record_type"`
+	RecordID        string        `db:"record_id" json:"record_id"`
+	PayoutReference *string       `db:"payout_reference" json:"payout_reference,omitempty"`
+	Amount          *money.Amount `db:"amount" json:"amount,omitempty"`
+	LinkedBy        string        `db:"linked_by" json:"linked_by,omitempty"`
+	LinkedAt        time.Time     `db:"linked_at" json:"linked_at"`
+}
+
+// LinkMappingsGet retrieves all the donation links as link mappings, ordered by
+// donation, record type and record id.
+func (db *DB) LinkMappingsGet(ctx context.Context) ([]LinkMapping, error) {
+
+	stmt := db.linkMappingsGetStmt
+
+	namedArgs := map[string]any{
+		"DonationID": "",
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("link mappings verify arguments error: %v", err))
+		return nil, fmt.Errorf("link mappings verify arguments error: %w", err)
+	}
+
+	var mappings []LinkMapping
+	err := stmt.SelectContext(ctx, &mappings, namedArgs)
+	db.logQuery(ctx, "link mappings", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("link mappings select error: %v", err))
+		return nil, fmt.Errorf("link mappings select error: %w", err)
+	}
+	return mappings, nil
+}
+
+// LinkCheck is a donation link of a Salesforce donation to an existing invoice or bank
+// transaction, to be checked against the remote records. Reference is the matched
+// payout reference of the link, empty for a donation split.
+type LinkCheck struct {
+	DonationID   string `db:"donation_id"`
+	DonationName string `db:"donation_name"`
+	RecordType   string `db:"record_type"`
+	RecordID     string `db:"record_id"`
+	Reference    string `db:"reference"`
+}
+
+// LinkChecksGet retrieves the donation links of Salesforce donations to existing
+// invoices and bank transactions, ordered by donation, or a random sample of limit
+// links if limit is not -1.
+func (db *DB) LinkChecksGet(ctx context.Context, limit int) ([]LinkCheck, error) {
+
+	stmt := db.linkChecksGetStmt
+
+	namedArgs := map[string]any{
+		"HereLimit": limit,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("link checks verify arguments error: %v", err))
+		return nil, fmt.Errorf("link checks verify arguments error: %w", err)
+	}
+
+	var checks []LinkCheck
+	err := stmt.SelectContext(ctx, &checks, namedArgs)
+	db.logQuery(ctx, "link checks", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("link checks select error: %v", err))
+		return nil, fmt.Errorf("link checks select error: %w", err)
+	}
+	return checks, nil
+}
+
+// LinkMappingsImport records the link mappings as donation links in a single
+// statement, replacing any existing link of each donation to the same record, and
+// returns the number imported. Mappings of donations or records which do not exist,
+// or with an amount which is not positive, are not imported. An ErrValidation is
+// returned for a mapping missing its donation or record, or with an unknown record
+// type.
+func (db *DB) LinkMappingsImport(ctx context.Context, mappings []LinkMapping) (int, error) {
+
+	for i, m := range mappings {
+		switch {
+		case m.DonationID == "" || m.RecordID == "":
+			return 0, ErrValidation{fmt.Sprintf("link mapping %d", i+1), "must have a donation id and record id"}
+		case m.RecordType != "invoice" && m.RecordType != "bank-transaction":
+			return 0, ErrValidation{fmt.Sprintf("link mapping %d", i+1), fmt.Sprintf("record type %q is not invoice or bank-transaction", m.RecordType)}
+		}
+	}
+	if len(mappings) == 0 {
+		return 0, nil
+	}
+	stmt := db.linkMappingsImportStmt
+
+	encoded, err := json.Marshal(mappings)
+	if err != nil {
+		return 0, fmt.Errorf("link mappings import encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"Mappings": string(encoded),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("link mappings import verify arguments error: %v", err))
+		return 0, fmt.Errorf("link mappings import verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to import link mappings: %v", err))
+		return 0, fmt.Errorf("failed to import link mappings: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("link mappings import count error: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("imported %d of %d link mappings", n, len(mappings)))
+	return int(n), nil
+}
@@ -0,0 +1,115 @@
+package db
+
+// tests for the export and import of link mappings
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestLinkMappings(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-018", "invoice", "inv-unrec-04", money.FromFloat(10)); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := testDB.LinkMappingsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) == 0 {
+		t.Fatal("expected link mappings")
+	}
+	var split *LinkMapping
+	for i, m := range exported {
+		if m.DonationID == "sf-opp-018" && m.RecordID == "inv-unrec-04" {
+			split = &exported[i]
+		}
+	}
+	if split == nil || split.Amount == nil || *split.Amount != money.FromFloat(10) || split.PayoutReference != nil {
+		t.Fatalf("unexpected split mapping %+v", split)
+	}
+
+	// Clear the links and import the exported mappings, with one of a missing record.
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM donation_links"); err != nil {
+		t.Fatal(err)
+	}
+	missing := LinkMapping{DonationID: "sf-opp-001", RecordType: "invoice", RecordID: "inv-missing"}
+	n, err := testDB.LinkMappingsImport(ctx, append(exported, missing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, len(exported); got != want {
+		t.Errorf("imported %d want %d", got, want)
+	}
+	imported, err := testDB.LinkMappingsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(exported, imported); diff != "" {
+		t.Errorf("imported mappings differ (-want +got):\n%s", diff)
+	}
+
+	// Importing again replaces the links.
+	if n, err := testDB.LinkMappingsImport(ctx, exported); err != nil || n != len(exported) {
+		t.Errorf("reimport got %d, %v want %d", n, err, len(exported))
+	}
+
+	invalid := []LinkMapping{
+		{RecordType: "invoice", RecordID: "inv-001"},
+		{DonationID: "sf-opp-001", RecordType: "payment", RecordID: "inv-001"},
+	}
+	for _, m := range invalid {
+		if _, err := testDB.LinkMappingsImport(ctx, []LinkMapping{m}); !errors.As(err, new(ErrValidation)) {
+			t.Errorf("expected a validation error for %+v, got %v", m, err)
+		}
+	}
+}
+
+func TestLinkChecks(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if _, _, err := testDB.DonationLinksSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-018", "invoice", "inv-unrec-04", money.FromFloat(10)); err != nil {
+		t.Fatal(err)
+	}
+	checks, err := testDB.LinkChecksGet(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) < 3 {
+		t.Fatalf("got %d link checks, expected at least 3", len(checks))
+	}
+	var split, linked bool
+	for _, c := range checks {
+		switch {
+		case c.DonationID == "sf-opp-018" && c.RecordID == "inv-unrec-04":
+			split = c.Reference == ""
+		case c.DonationID == "sf-opp-001" && c.RecordID == "inv-001":
+			linked = c.Reference == "INV-2025-101" && c.DonationName != ""
+		}
+	}
+	if !split || !linked {
+		t.Errorf("unexpected link checks (split %t linked %t): %+v", split, linked, checks)
+	}
+
+	sample, err := testDB.LinkChecksGet(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sample), 2; got != want {
+		t.Errorf("sample got %d links want %d", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+// links.go maintains the donation links between donations and the invoices and bank
+// transactions they are reconciled against.
+
+import (
+	"context"
+	"fmt"
+)
+
+// DonationLinksSync brings the donation links made from payout references up to date
+// with the donations, invoices and bank transactions, returning the number of links
+// removed and added. Links are removed when the payout reference of the donation no
+// longer matches the reference recorded for the link, and donations without links are
+// linked to the invoices and bank transactions matching their payout reference. Links
+// are kept when the Xero reference of the linked record is edited.
+func (db *DB) DonationLinksSync(ctx context.Context) (removed, added int, err error) {
+
+	namedArgs := map[string]any{
+		"DonationID": "",
+	}
+
+	for _, s := range []struct {
+		name  string
+		stmt  *parameterizedStmt
+		count *int
+	}{
+		{"delete", db.donationLinksDeleteStmt, &removed},
+		{"insert", db.donationLinksInsertStmt, &added},
+	} {
+		if err := s.stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("donation links %s verify arguments error: %v", s.name, err))
+			return removed, added, fmt.Errorf("donation links %s verify arguments error: %w", s.name, err)
+		}
+		result, err := s.stmt.ExecContext(ctx, namedArgs)
+		if err != nil {
+			db.log.Error(fmt.Sprintf("donation links %s error: %v", s.name, err))
+			return removed, added, fmt.Errorf("donation links %s error: %w", s.name, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			*s.count = int(n)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("donation links sync: removed %d added %d", removed, added))
+	return removed, added, nil
+}
@@ -0,0 +1,122 @@
+package db
+
+// tests for donation links
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestDonationLinksSync(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	// crmsTotals records the crms totals of all invoices and bank transactions.
+	crmsTotals := func() map[string]money.Amount {
+		t.Helper()
+		totals := map[string]money.Amount{}
+		invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range invoices {
+			totals[i.InvoiceID] = i.CRMSTotal
+		}
+		transactions, err := testDB.BankTransactionsGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range transactions {
+			totals[b.ID] = b.CRMSTotal
+		}
+		return totals
+	}
+
+	sync := func(wantRemoved, wantAdded int) {
+		t.Helper()
+		removed, added, err := testDB.DonationLinksSync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if removed != wantRemoved || added != wantAdded {
+			t.Errorf("sync got removed %d added %d want %d %d", removed, added, wantRemoved, wantAdded)
+		}
+	}
+
+	crmsTotal := func(invoiceID string) money.Amount {
+		t.Helper()
+		invoice, _, err := testDB.InvoiceWRGet(ctx, invoiceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invoice.CRMSTotal
+	}
+
+	// Linking the donations by payout reference does not change the totals.
+	before := crmsTotals()
+	if len(before) == 0 {
+		t.Fatal("no invoices or bank transactions found")
+	}
+	sync(0, 18)
+	after := crmsTotals()
+	for id, total := range before {
+		if after[id] != total {
+			t.Errorf("%s crms total got %s want %s after linking", id, after[id], total)
+		}
+	}
+	sync(0, 0)
+
+	// Links survive an edit of the Xero reference. inv-001 is linked to sf-opp-001
+	// (500.00) and sf-opp-odd-01 (50.00).
+	if _, err := testDB.ExecContext(ctx, "UPDATE invoices SET invoice_number = 'INV-2025-101-X' WHERE id = 'inv-001'"); err != nil {
+		t.Fatal(err)
+	}
+	sync(0, 0)
+	if got, want := crmsTotal("inv-001"), money.FromFloat(550); got != want {
+		t.Errorf("crms total after reference edit got %s want %s", got, want)
+	}
+	donations, err := testDB.DonationsGet(ctx, dateFrom, dateTo, "Linked", "INV-2025-101-X", "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(donations), 2; got != want {
+		t.Fatalf("got %d donations linked to the edited reference want %d", got, want)
+	}
+	for _, d := range donations {
+		if d.LinkID != "inv-001" || d.LinkedBy != "Salesforce" || d.LinkedAt == nil {
+			t.Errorf("unexpected link metadata %s %q %v", d.LinkID, d.LinkedBy, d.LinkedAt)
+		}
+	}
+
+	// Unlinking a donation in Salesforce removes its link.
+	if _, err := testDB.ExecContext(ctx, "UPDATE donations SET payout_reference_dfk = NULL WHERE id = 'sf-opp-odd-01'"); err != nil {
+		t.Fatal(err)
+	}
+	sync(1, 0)
+	if got, want := crmsTotal("inv-001"), money.FromFloat(500); got != want {
+		t.Errorf("crms total after unlinking got %s want %s", got, want)
+	}
+
+	// Splits are not removed by the sync, and a donation with links is not linked by
+	// its payout reference.
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-odd-02", "invoice", "inv-unrec-04", money.FromFloat(50)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.ExecContext(ctx, "UPDATE donations SET payout_reference_dfk = 'INV-2025-104' WHERE id = 'sf-opp-odd-02'"); err != nil {
+		t.Fatal(err)
+	}
+	sync(0, 0)
+	if got, want := crmsTotal("inv-unrec-04"), money.FromFloat(50); got != want {
+		t.Errorf("crms total of split got %s want %s", got, want)
+	}
+	if got, want := crmsTotal("inv-unrec-02"), money.Amount(0); got != want {
+		t.Errorf("crms total of unlinked reference got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,233 @@
+package db
+
+// locks.go deals with the period locks which close periods, such as a financial year
+// whose accounts have been finalised, to links, unlinks and write-backs of the records
+// dated in them, and with the audit events recording the locking and unlocking of
+// periods and the overrides of the locks for corrections.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PeriodLock closes the period from DateFrom to DateTo inclusive.
+type PeriodLock struct {
+	ID       int64     `db:"id"`
+	DateFrom time.Time `db:"date_from"`
+	DateTo   time.Time `db:"date_to"`
+	Reason   string    `db:"reason"`
+	LockedAt time.Time `db:"locked_at"`
+}
+
+// String describes the period of the lock.
+func (l PeriodLock) String() string {
+	return fmt.Sprintf("%s to %s", l.DateFrom.Format("2006-01-02"), l.DateTo.Format("2006-01-02"))
+}
+
+// The audit events.
+const (
+	AuditLock     = "lock"
+	AuditUnlock   = "unlock"
+	AuditOverride = "override"
+)
+
+// AuditEvent records the locking or unlocking of a period, or an override of a period
+// lock. PeriodLockID is nil if the event is not for a single lock.
+type AuditEvent struct {
+	ID           int64     `db:"id"`
+	Event        string    `db:"event"`
+	PeriodLockID *int64    `db:"period_lock_id"`
+	Description  string    `db:"description"`
+	Reason       string    `db:"reason"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// PeriodLocksGet retrieves the period locks, latest period first.
+func (db *DB) PeriodLocksGet(ctx context.Context) ([]PeriodLock, error) {
+	return db.periodLocksGet(ctx, 0)
+}
+
+// PeriodLockGet retrieves the period lock with id, returning ErrNotFound if it does
+// not exist.
+func (db *DB) PeriodLockGet(ctx context.Context, id int64) (PeriodLock, error) {
+	locks, err := db.periodLocksGet(ctx, id)
+	if err != nil {
+		return PeriodLock{}, err
+	}
+	if len(locks) == 0 {
+		return PeriodLock{}, ErrNotFound{"period lock", fmt.Sprint(id)}
+	}
+	return locks[0], nil
+}
+
+// periodLocksGet retrieves the period lock with id, or all period locks if id is 0.
+func (db *DB) periodLocksGet(ctx context.Context, id int64) ([]PeriodLock, error) {
+
+	stmt := db.periodLocksGetStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period locks verify arguments error: %v", err))
+		return nil, fmt.Errorf("period locks verify arguments error: %w", err)
+	}
+
+	var locks []PeriodLock
+	err := stmt.SelectContext(ctx, &locks, namedArgs)
+	db.logQuery(ctx, "period locks", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("period locks select error: %v", err))
+		return nil, fmt.Errorf("period locks select error: %w", err)
+	}
+	return locks, nil
+}
+
+// PeriodLockInsert closes the period from dateFrom to dateTo inclusive, returning the
+// id of the lock. An ErrValidation is returned if either date is missing or dateTo is
+// before dateFrom.
+func (db *DB) PeriodLockInsert(ctx context.Context, dateFrom, dateTo time.Time, reason string) (int64, error) {
+
+	switch {
+	case dateFrom.IsZero() || dateTo.IsZero():
+		return 0, ErrValidation{"period lock dates", "must both be provided"}
+	case dateTo.Before(dateFrom):
+		return 0, ErrValidation{"period lock end date", "may not be before the start date"}
+	}
+	stmt := db.periodLockInsertStmt
+
+	namedArgs := map[string]any{
+		"DateFrom": sqlDate(dateFrom),
+		"DateTo":   sqlDate(dateTo),
+		"Reason":   strings.TrimSpace(reason),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period lock insert verify arguments error: %v", err))
+		return 0, fmt.Errorf("period lock insert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert period lock: %v", err))
+		return 0, fmt.Errorf("failed to insert period lock: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("period lock id error: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("locked the period %s to %s", namedArgs["DateFrom"], namedArgs["DateTo"]))
+	return id, nil
+}
+
+// PeriodLockDelete deletes a period lock, opening its period again.
+func (db *DB) PeriodLockDelete(ctx context.Context, id int64) error {
+
+	stmt := db.periodLockDeleteStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period lock delete verify arguments error: %v", err))
+		return fmt.Errorf("period lock delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete period lock %d: %v", id, err))
+		return fmt.Errorf("failed to delete period lock %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted period lock %d", id))
+	return nil
+}
+
+// PeriodLocksCovering retrieves the period locks covering the dates of the donations
+// with donationIDs, the invoices and bank transactions with recordIDs, and those with
+// an invoice number or reference in refs. Empty ids and refs are ignored.
+func (db *DB) PeriodLocksCovering(ctx context.Context, donationIDs, recordIDs, refs []string) ([]PeriodLock, error) {
+
+	stmt := db.periodLocksCoveringStmt
+
+	namedArgs := map[string]any{}
+	for name, values := range map[string][]string{"DonationIDs": donationIDs, "RecordIDs": recordIDs, "Refs": refs} {
+		nonEmpty := []string{}
+		for _, v := range values {
+			if v != "" {
+				nonEmpty = append(nonEmpty, v)
+			}
+		}
+		encoded, err := json.Marshal(nonEmpty)
+		if err != nil {
+			return nil, fmt.Errorf("period locks covering encoding error: %w", err)
+		}
+		namedArgs[name] = string(encoded)
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period locks covering verify arguments error: %v", err))
+		return nil, fmt.Errorf("period locks covering verify arguments error: %w", err)
+	}
+
+	var locks []PeriodLock
+	err := stmt.SelectContext(ctx, &locks, namedArgs)
+	db.logQuery(ctx, "period locks covering", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("period locks covering select error: %v", err))
+		return nil, fmt.Errorf("period locks covering select error: %w", err)
+	}
+	return locks, nil
+}
+
+// AuditEventInsert records an audit event of the period lock with periodLockID, which
+// is 0 if the event is not for a single lock. An ErrValidation is returned for an
+// unknown event or an empty description.
+func (db *DB) AuditEventInsert(ctx context.Context, event string, periodLockID int64, description, reason string) error {
+
+	switch {
+	case event != AuditLock && event != AuditUnlock && event != AuditOverride:
+		return ErrValidation{"audit event", fmt.Sprintf("%q is not lock, unlock or override", event)}
+	case strings.TrimSpace(description) == "":
+		return ErrValidation{"audit event description", "may not be empty"}
+	}
+	stmt := db.auditEventInsertStmt
+
+	namedArgs := map[string]any{
+		"Event":        event,
+		"PeriodLockID": periodLockID,
+		"Description":  description,
+		"Reason":       strings.TrimSpace(reason),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("audit event insert verify arguments error: %v", err))
+		return fmt.Errorf("audit event insert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert audit event: %v", err))
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("audit %s: %s", event, description))
+	return nil
+}
+
+// AuditEventsGet retrieves the latest limit audit events, latest first. A limit of -1
+// returns all events.
+func (db *DB) AuditEventsGet(ctx context.Context, limit int) ([]AuditEvent, error) {
+
+	stmt := db.auditEventsGetStmt
+
+	namedArgs := map[string]any{
+		"HereLimit": limit,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("audit events verify arguments error: %v", err))
+		return nil, fmt.Errorf("audit events verify arguments error: %w", err)
+	}
+
+	var events []AuditEvent
+	err := stmt.SelectContext(ctx, &events, namedArgs)
+	db.logQuery(ctx, "audit events", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("audit events select error: %v", err))
+		return nil, fmt.Errorf("audit events select error: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,135 @@
+package db
+
+// tests for period locks and audit events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPeriodLocks(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	day := func(d string) time.Time {
+		t, _ := time.Parse("2006-01-02", d)
+		return t
+	}
+
+	invalid := []struct {
+		name     string
+		from, to time.Time
+	}{
+		{"no start", time.Time{}, day("2025-04-12")},
+		{"no end", day("2025-04-09"), time.Time{}},
+		{"end before start", day("2025-04-12"), day("2025-04-09")},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testDB.PeriodLockInsert(ctx, tt.from, tt.to, "")
+			if _, ok := errors.AsType[ErrValidation](err); !ok {
+				t.Errorf("expected a validation error, got %v", err)
+			}
+		})
+	}
+
+	id, err := testDB.PeriodLockInsert(ctx, day("2025-04-09"), day("2025-04-12"), " year end ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := testDB.PeriodLockGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lock.String(), "2025-04-09 to 2025-04-12"; got != want || lock.Reason != "year end" {
+		t.Errorf("got lock %q reason %q want %q", got, lock.Reason, want)
+	}
+	if _, err := testDB.PeriodLockGet(ctx, id+1); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// sf-opp-001 closed 2025-04-08, sf-opp-002 2025-04-11, inv-001 (INV-2025-101) is
+	// dated 2025-04-10 and bt-001 (JG-PAYOUT-2025-04-15) 2025-04-15.
+	covering := []struct {
+		name        string
+		donationIDs []string
+		recordIDs   []string
+		refs        []string
+		locked      bool
+	}{
+		{"donation before", []string{"sf-opp-001"}, nil, nil, false},
+		{"donation within", []string{"sf-opp-001", "sf-opp-002"}, nil, nil, true},
+		{"invoice within", nil, []string{"inv-001"}, nil, true},
+		{"invoice number within", nil, nil, []string{"INV-2025-101"}, true},
+		{"bank transaction after", nil, []string{"bt-001"}, []string{"JG-PAYOUT-2025-04-15", ""}, false},
+		{"nothing", nil, nil, nil, false},
+	}
+	for _, tt := range covering {
+		t.Run(tt.name, func(t *testing.T) {
+			locks, err := testDB.PeriodLocksCovering(ctx, tt.donationIDs, tt.recordIDs, tt.refs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(locks) > 0; got != tt.locked {
+				t.Errorf("got locked %t want %t (%v)", got, tt.locked, locks)
+			}
+		})
+	}
+
+	if err := testDB.PeriodLockDelete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	locks, err := testDB.PeriodLocksGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("expected no locks, got %v", locks)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if err := testDB.AuditEventInsert(ctx, AuditLock, 1, "locked 2025-04-01 to 2025-04-30", "year end"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AuditEventInsert(ctx, AuditOverride, 0, "linked sf-opp-001 to INV-2025-101", " correction "); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AuditEventInsert(ctx, "delete", 1, "a description", ""); !errors.As(err, new(ErrValidation)) {
+		t.Errorf("expected a validation error for an unknown event, got %v", err)
+	}
+	if err := testDB.AuditEventInsert(ctx, AuditUnlock, 1, " ", ""); !errors.As(err, new(ErrValidation)) {
+		t.Errorf("expected a validation error for no description, got %v", err)
+	}
+
+	events, err := testDB.AuditEventsGet(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("got %d events want %d", got, want)
+	}
+	if e := events[0]; e.Event != AuditOverride || e.PeriodLockID != nil || e.Reason != "correction" {
+		t.Errorf("unexpected latest event %+v", e)
+	}
+	if e := events[1]; e.PeriodLockID == nil || *e.PeriodLockID != 1 {
+		t.Errorf("unexpected first event %+v", e)
+	}
+
+	events, err = testDB.AuditEventsGet(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("got %d events with a limit of 1", len(events))
+	}
+}
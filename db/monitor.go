@@ -0,0 +1,183 @@
+package db
+
+// monitor.go provides optional query instrumentation: the logging of the query plans
+// of the prepared statements, and the recording of queries slower than a threshold.
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slowQueryHistory is the number of recent slow queries retained.
+const slowQueryHistory = 50
+
+// SlowQuery records a query which took longer than the slow query threshold. Args
+// are the bound arguments, with values which may identify people redacted.
+type SlowQuery struct {
+	Time     time.Time
+	SQLFile  string
+	Duration time.Duration
+	Args     map[string]string
+	Err      string
+}
+
+// ArgsString returns the arguments in name order, for display.
+func (s SlowQuery) ArgsString() string {
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(s.Args)) {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, s.Args[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// queryMonitor records slow queries.
+type queryMonitor struct {
+	threshold time.Duration
+	mu        sync.Mutex
+	recent    []SlowQuery // oldest first
+}
+
+// unredactedArgs are the parameters whose string values are shown in slow query
+// records. Other string values are redacted, apart from dates.
+var unredactedArgs = []string{"AccountCodes", "ReconciliationStatus", "LinkageStatus", "Typer"}
+
+// dateArg matches date and datetime argument values.
+var dateArg = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([T ][\d:.]+Z?)?$`)
+
+// redactArgs returns printable argument values, redacting strings which may contain
+// personal data such as names, email addresses or search terms.
+func redactArgs(args map[string]any) map[string]string {
+	out := make(map[string]string, len(args))
+	for k, v := range args {
+		switch a := v.(type) {
+		case nil:
+			out[k] = "null"
+		case string:
+			switch {
+			case a == "", slices.Contains(unredactedArgs, k), dateArg.MatchString(a):
+				out[k] = fmt.Sprintf("%q", a)
+			default:
+				out[k] = fmt.Sprintf("[redacted %d chars]", len([]rune(a)))
+			}
+		case bool, int, int64, float64:
+			out[k] = fmt.Sprint(a)
+		default:
+			out[k] = fmt.Sprintf("[redacted %T]", v)
+		}
+	}
+	return out
+}
+
+// observe records the query if it took longer than the threshold, returning true if
+// it did.
+func (m *queryMonitor) observe(sqlFile string, start time.Time, args map[string]any, err error) (SlowQuery, bool) {
+	d := time.Since(start)
+	if d < m.threshold {
+		return SlowQuery{}, false
+	}
+	sq := SlowQuery{
+		Time:     start,
+		SQLFile:  sqlFile,
+		Duration: d,
+		Args:     redactArgs(args),
+	}
+	if err != nil {
+		sq.Err = err.Error()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recent = append(m.recent, sq)
+	if len(m.recent) > slowQueryHistory {
+		m.recent = m.recent[len(m.recent)-slowQueryHistory:]
+	}
+	return sq, true
+}
+
+// SetSlowQueryThreshold enables the recording and logging of queries taking longer
+// than threshold. A zero threshold disables slow query recording.
+func (db *DB) SetSlowQueryThreshold(threshold time.Duration) {
+	if threshold <= 0 {
+		db.monitor = nil
+	} else {
+		db.monitor = &queryMonitor{threshold: threshold}
+	}
+	for _, stmt := range db.prepared {
+		stmt.monitor = db.monitor
+		stmt.log = db.log
+	}
+}
+
+// SlowQueries returns the recent slow queries, newest first, and the threshold. The
+// threshold is zero if slow query recording is not enabled.
+func (db *DB) SlowQueries() ([]SlowQuery, time.Duration) {
+	m := db.monitor
+	if m == nil {
+		return nil, 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recent := slices.Clone(m.recent)
+	slices.Reverse(recent)
+	return recent, m.threshold
+}
+
+// QueryPlan is the sqlite query plan for a prepared statement.
+type QueryPlan struct {
+	SQLFile string
+	Steps   []string
+}
+
+// QueryPlans returns the sqlite query plans of the prepared statements, explained
+// with null arguments.
+func (db *DB) QueryPlans(ctx context.Context) ([]QueryPlan, error) {
+	type planRow struct {
+		ID      int    `db:"id"`
+		Parent  int    `db:"parent"`
+		NotUsed int    `db:"notused"`
+		Detail  string `db:"detail"`
+	}
+	var plans []QueryPlan
+	for _, stmt := range db.prepared {
+		// The query string is compiled with positional bind variables.
+		args := make([]any, len(stmt.Params))
+		rows, err := db.QueryxContext(ctx, "EXPLAIN QUERY PLAN "+stmt.QueryString, args...)
+		if err != nil {
+			return nil, fmt.Errorf("could not explain %q: %w", stmt.sqlFile, err)
+		}
+		plan := QueryPlan{SQLFile: stmt.sqlFile}
+		depth := map[int]int{}
+		for rows.Next() {
+			var r planRow
+			if err := rows.StructScan(&r); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("could not scan plan for %q: %w", stmt.sqlFile, err)
+			}
+			depth[r.ID] = depth[r.Parent] + 1
+			plan.Steps = append(plan.Steps, strings.Repeat("  ", depth[r.ID]-1)+r.Detail)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// LogQueryPlans logs the sqlite query plans of the prepared statements, to help
+// identify queries which scan tables rather than using indexes.
+func (db *DB) LogQueryPlans(ctx context.Context) error {
+	plans, err := db.QueryPlans(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range plans {
+		db.log.Info(fmt.Sprintf("query plan %s:\n%s", p.SQLFile, strings.Join(p.Steps, "\n")))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs(map[string]any{
+		"TextSearch":           "Smith",
+		"ReconciliationStatus": "NotReconciled",
+		"DateFrom":             "2025-04-01",
+		"HereLimit":            -1,
+		"PayoutReference":      nil,
+		"Empty":                "",
+		"Amount":               12.5,
+	})
+	want := map[string]string{
+		"TextSearch":           "[redacted 5 chars]",
+		"ReconciliationStatus": `"NotReconciled"`,
+		"DateFrom":             `"2025-04-01"`,
+		"HereLimit":            "-1",
+		"PayoutReference":      "null",
+		"Empty":                `""`,
+		"Amount":               "12.5",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("redacted args mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSlowQueries(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	defer closeDB()
+	testDB.SetLogLevel(slog.LevelError)
+	testDB.SetCacheTTL(0) // each query is run
+
+	ctx := context.Background()
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	// Queries are not recorded until a threshold is set.
+	if _, err := testDB.InvoicesGet(ctx, "All", from, to, "Smith", "", SortOrder{}, -1, 0); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal(err)
+	}
+	if queries, threshold := testDB.SlowQueries(); len(queries) != 0 || threshold != 0 {
+		t.Fatalf("got %d queries threshold %s with recording disabled", len(queries), threshold)
+	}
+
+	// All queries exceed a nanosecond threshold.
+	testDB.SetSlowQueryThreshold(time.Nanosecond)
+	for range slowQueryHistory + 2 {
+		if _, err := testDB.InvoicesGet(ctx, "All", from, to, "Smith", "", SortOrder{}, -1, 0); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			t.Fatal(err)
+		}
+	}
+	queries, threshold := testDB.SlowQueries()
+	if got, want := threshold, time.Nanosecond; got != want {
+		t.Errorf("threshold got %s want %s", got, want)
+	}
+	if got, want := len(queries), slowQueryHistory; got != want {
+		t.Fatalf("slow queries got %d want %d", got, want)
+	}
+	q := queries[0]
+	if got, want := q.SQLFile, "invoices.sql"; got != want {
+		t.Errorf("sql file got %q want %q", got, want)
+	}
+	if got, want := q.Args["TextSearch"], "[redacted 5 chars]"; got != want {
+		t.Errorf("search arg got %q want %q", got, want)
+	}
+	if strings.Contains(q.ArgsString(), "Smith") {
+		t.Errorf("args string %q not redacted", q.ArgsString())
+	}
+	if queries[0].Time.Before(queries[len(queries)-1].Time) {
+		t.Error("expected the newest query first")
+	}
+
+	// The threshold survives a statement reload.
+	if err := testDB.ReloadStatements(); err != nil {
+		t.Fatal(err)
+	}
+	if testDB.invoicesGetStmt.monitor == nil {
+		t.Error("expected the reloaded statements to record slow queries")
+	}
+
+	testDB.SetSlowQueryThreshold(0)
+	if queries, _ := testDB.SlowQueries(); queries != nil {
+		t.Errorf("got %d queries after disabling recording", len(queries))
+	}
+}
+
+func TestQueryPlans(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	defer closeDB()
+
+	plans, err := testDB.QueryPlans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(plans), len(testDB.prepared); got != want {
+		t.Fatalf("plans got %d want %d", got, want)
+	}
+	for _, p := range plans {
+		if len(p.Steps) == 0 {
+			t.Errorf("%s has no query plan steps", p.SQLFile)
+		}
+	}
+}
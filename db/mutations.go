@@ -0,0 +1,76 @@
+package db
+
+// mutations.go records the outbound mutations of Xero and Salesforce records, so that
+// a retried mutation which has already been made is not made again.
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OutboundMutation is an update made to Xero or Salesforce, recorded by the hash of the
+// update as its Key. The Status is started, succeeded or failed, and the Result is the
+// json encoded result of a succeeded mutation.
+type OutboundMutation struct {
+	Key         string     `db:"key"`
+	Target      string     `db:"target"`
+	Operation   string     `db:"operation"`
+	Status      string     `db:"status"`
+	Result      string     `db:"result"`
+	Attempts    int        `db:"attempts"`
+	CreatedAt   time.Time  `db:"created_at"`
+	CompletedAt *time.Time `db:"completed_at"`
+}
+
+// OutboundMutationGet retrieves an outbound mutation by key. ErrNotFound, which matches
+// sql.ErrNoRows, is returned if the mutation has not been recorded.
+func (db *DB) OutboundMutationGet(ctx context.Context, key string) (OutboundMutation, error) {
+
+	stmt := db.outboundMutationGetStmt
+
+	namedArgs := map[string]any{
+		"Key": key,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("outbound mutation verify arguments error: %v", err))
+		return OutboundMutation{}, fmt.Errorf("outbound mutation verify arguments error: %w", err)
+	}
+
+	var mutations []OutboundMutation
+	err := stmt.SelectContext(ctx, &mutations, namedArgs)
+	db.logQuery(ctx, "outbound mutation", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("outbound mutation select error: %v", err))
+		return OutboundMutation{}, fmt.Errorf("outbound mutation select error: %w", err)
+	}
+	if len(mutations) == 0 {
+		return OutboundMutation{}, ErrNotFound{"outbound mutation", key}
+	}
+	return mutations[0], nil
+}
+
+// OutboundMutationSave records the status of an outbound mutation, with the result of a
+// succeeded mutation. The attempts of a mutation are counted each time it is started.
+func (db *DB) OutboundMutationSave(ctx context.Context, m OutboundMutation) error {
+
+	stmt := db.outboundMutationUpsertStmt
+
+	namedArgs := map[string]any{
+		"Key":       m.Key,
+		"Target":    m.Target,
+		"Operation": m.Operation,
+		"Status":    m.Status,
+		"Result":    m.Result,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("outbound mutation upsert verify arguments error: %v", err))
+		return fmt.Errorf("outbound mutation upsert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to record outbound mutation %s: %v", m.Key, err))
+		return fmt.Errorf("failed to record outbound mutation %s: %w", m.Key, err)
+	}
+	db.log.Debug(fmt.Sprintf("outbound mutation %s %s %s", m.Target, m.Operation, m.Status))
+	return nil
+}
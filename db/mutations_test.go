@@ -0,0 +1,50 @@
+package db
+
+// tests for outbound mutations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestOutboundMutations(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if _, err := testDB.OutboundMutationGet(ctx, "k1"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected ErrNoRows, got %v", err)
+	}
+
+	m := OutboundMutation{Key: "k1", Target: "salesforce", Operation: "update refs", Status: "started"}
+	for _, status := range []string{"started", "failed", "started", "succeeded"} {
+		m.Status = status
+		if status == "succeeded" {
+			m.Result = `{"records":2}`
+		}
+		if err := testDB.OutboundMutationSave(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.OutboundMutationGet(ctx, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "succeeded" || got.Result != `{"records":2}` {
+		t.Errorf("unexpected mutation %+v", got)
+	}
+	if got, want := got.Attempts, 2; got != want {
+		t.Errorf("attempts got %d want %d", got, want)
+	}
+	if got.CompletedAt == nil {
+		t.Error("expected a completion time")
+	}
+
+	if err := testDB.OutboundMutationSave(ctx, OutboundMutation{Key: "k2", Target: "hubspot", Operation: "x", Status: "started"}); err == nil {
+		t.Error("expected an error for an unknown target")
+	}
+}
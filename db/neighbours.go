@@ -0,0 +1,74 @@
+package db
+
+// neighbours.go finds the unreconciled invoices and bank transactions either side of a
+// record in a listing, so that the detail pages can step through the unreconciled
+// records of the listing's filter. The listing queries are cached, so stepping through
+// a listing queries it once.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Neighbours are the IDs of the records before and after a record in a listing, which
+// are empty at the start or end of the listing. Position is the 1-based position of
+// the record of Count records, and is 0 if the record is not in the listing, such as
+// after it is reconciled, when Next is the first record of the listing.
+type Neighbours struct {
+	Previous string
+	Next     string
+	Position int
+	Count    int
+}
+
+// neighbours returns the Neighbours of id in ids.
+func neighbours(ids []string, id string) Neighbours {
+	n := Neighbours{Count: len(ids)}
+	i := slices.Index(ids, id)
+	if i < 0 {
+		if len(ids) > 0 {
+			n.Next = ids[0]
+		}
+		return n
+	}
+	n.Position = i + 1
+	if i > 0 {
+		n.Previous = ids[i-1]
+	}
+	if i < len(ids)-1 {
+		n.Next = ids[i+1]
+	}
+	return n
+}
+
+// InvoiceNeighbours returns the unreconciled invoices either side of the invoice with
+// id in the invoices listing with the dates, search, assignee and sort order provided.
+func (db *DB) InvoiceNeighbours(ctx context.Context, id string, dateFrom, dateTo time.Time, search, assignedTo string, sort SortOrder) (Neighbours, error) {
+	invoices, err := db.InvoicesGet(ctx, "NotReconciled", dateFrom, dateTo, search, assignedTo, sort, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Neighbours{}, err
+	}
+	ids := make([]string, len(invoices))
+	for i, inv := range invoices {
+		ids[i] = inv.InvoiceID
+	}
+	return neighbours(ids, id), nil
+}
+
+// BankTransactionNeighbours returns the unreconciled bank transactions either side of
+// the bank transaction with id in the bank transactions listing with the dates,
+// search, assignee and sort order provided.
+func (db *DB) BankTransactionNeighbours(ctx context.Context, id string, dateFrom, dateTo time.Time, search, assignedTo string, sort SortOrder) (Neighbours, error) {
+	transactions, err := db.BankTransactionsGet(ctx, "NotReconciled", dateFrom, dateTo, search, assignedTo, sort, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Neighbours{}, err
+	}
+	ids := make([]string, len(transactions))
+	for i, bt := range transactions {
+		ids[i] = bt.ID
+	}
+	return neighbours(ids, id), nil
+}
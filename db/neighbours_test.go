@@ -0,0 +1,73 @@
+package db
+
+// tests for the neighbours of a record in a listing
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNeighbours(t *testing.T) {
+	ids := []string{"a", "b", "c"}
+	tests := []struct {
+		id   string
+		want Neighbours
+	}{
+		{"a", Neighbours{Next: "b", Position: 1, Count: 3}},
+		{"b", Neighbours{Previous: "a", Next: "c", Position: 2, Count: 3}},
+		{"c", Neighbours{Previous: "b", Position: 3, Count: 3}},
+		{"x", Neighbours{Next: "a", Count: 3}},
+	}
+	for _, tt := range tests {
+		if got := neighbours(ids, tt.id); got != tt.want {
+			t.Errorf("neighbours of %s got %+v want %+v", tt.id, got, tt.want)
+		}
+	}
+	if got := neighbours(nil, "a"); got != (Neighbours{}) {
+		t.Errorf("neighbours of an empty listing got %+v", got)
+	}
+}
+
+func TestInvoiceNeighbours(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	invoices, err := testDB.InvoicesGet(ctx, "NotReconciled", dateFrom, dateTo, "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) < 3 {
+		t.Fatalf("expected at least 3 unreconciled invoices, got %d", len(invoices))
+	}
+
+	n, err := testDB.InvoiceNeighbours(ctx, invoices[1].InvoiceID, dateFrom, dateTo, "", "", SortOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Neighbours{Previous: invoices[0].InvoiceID, Next: invoices[2].InvoiceID, Position: 2, Count: len(invoices)}
+	if n != want {
+		t.Errorf("got %+v want %+v", n, want)
+	}
+
+	// The neighbours follow the sort order of the listing.
+	n, err = testDB.InvoiceNeighbours(ctx, invoices[1].InvoiceID, dateFrom, dateTo, "", "", SortOrder{Column: "date", Direction: "desc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Position != len(invoices)-1 {
+		t.Errorf("descending position got %d want %d", n.Position, len(invoices)-1)
+	}
+
+	// A search with no matches has no neighbours.
+	n, err = testDB.BankTransactionNeighbours(ctx, "bt-001", dateFrom, dateTo, "no-such-transaction", "", SortOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != (Neighbours{}) {
+		t.Errorf("unexpected bank transaction neighbours %+v", n)
+	}
+}
@@ -0,0 +1,128 @@
+package db
+
+// orphans.go records the donations which are no longer in Salesforce, having been
+// deleted or merged there, so that they can be reviewed and removed.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// DonationOrphan is a donation flagged as no longer in Salesforce. The Reason is
+// "deleted" for donations in the Salesforce recycle bin and otherwise "missing". Links
+// is the number of invoices and bank transactions the donation is linked to.
+type DonationOrphan struct {
+	ID              string       `db:"id"`
+	Name            string       `db:"name"`
+	Amount          money.Amount `db:"amount"`
+	CloseDate       *time.Time   `db:"close_date"`
+	PayoutReference string       `db:"payout_reference"`
+	Reason          string       `db:"reason"`
+	DetectedAt      time.Time    `db:"detected_at"`
+	Links           int          `db:"links"`
+}
+
+// DonationIDsGet retrieves the ids of the donations closing on or after dateFrom.
+func (db *DB) DonationIDsGet(ctx context.Context, dateFrom time.Time) ([]string, error) {
+
+	stmt := db.donationIDsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom": sqlDate(dateFrom),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation ids verify arguments error: %v", err))
+		return nil, fmt.Errorf("donation ids verify arguments error: %w", err)
+	}
+
+	var ids []string
+	err := stmt.SelectContext(ctx, &ids, namedArgs)
+	db.logQuery(ctx, "donation ids", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("donation ids select error: %v", err))
+		return nil, fmt.Errorf("donation ids select error: %w", err)
+	}
+	return ids, nil
+}
+
+// DonationOrphansRecord flags the orphaned donations, keyed by id with the reason as
+// the value, clearing the flags of any other donations.
+func (db *DB) DonationOrphansRecord(ctx context.Context, orphans map[string]string) error {
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("donationOrphansRecord: could not begin transaction: %v", err))
+		return fmt.Errorf("donationOrphansRecord: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	ids := make([]string, 0, len(orphans))
+	for id := range orphans {
+		ids = append(ids, id)
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("donation orphans id encoding error: %w", err)
+	}
+
+	clearStmt := db.donationOrphansClearStmt
+	namedArgs := map[string]any{
+		"DonationIDs": string(idsJSON),
+	}
+	if err := clearStmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation orphans clear verify arguments error: %v", err))
+		return fmt.Errorf("donation orphans clear verify arguments error: %w", err)
+	}
+	if _, err := clearStmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to clear donation orphans: %v", err))
+		return fmt.Errorf("failed to clear donation orphans: %w", err)
+	}
+
+	upsertStmt := db.donationOrphanUpsertStmt
+	for id, reason := range orphans {
+		namedArgs := map[string]any{
+			"DonationID": id,
+			"Reason":     reason,
+		}
+		if err := upsertStmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("donation orphan upsert verify arguments error: %v", err))
+			return fmt.Errorf("donation orphan upsert verify arguments error: %w", err)
+		}
+		if _, err := upsertStmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to flag donation %s as orphaned: %v", id, err))
+			return fmt.Errorf("failed to flag donation %s as orphaned: %w", id, err)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("donationOrphansRecord: %d orphaned donations", len(orphans)))
+	return tx.commit()
+}
+
+// DonationOrphansGet retrieves the donations flagged as orphaned.
+func (db *DB) DonationOrphansGet(ctx context.Context) ([]DonationOrphan, error) {
+
+	stmt := db.donationOrphansGetStmt
+
+	namedArgs := map[string]any{
+		"Reason": "all",
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation orphans verify arguments error: %v", err))
+		return nil, fmt.Errorf("donation orphans verify arguments error: %w", err)
+	}
+
+	var orphans []DonationOrphan
+	err := stmt.SelectContext(ctx, &orphans, namedArgs)
+	db.logQuery(ctx, "donation orphans", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("donation orphans select error: %v", err))
+		return nil, fmt.Errorf("donation orphans select error: %w", err)
+	}
+	return orphans, nil
+}
@@ -0,0 +1,86 @@
+package db
+
+// tests for orphaned donations
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDonationOrphans(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if _, _, err := testDB.DonationLinksSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := testDB.DonationIDsGet(ctx, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(ids, "sf-opp-001") || !slices.IsSorted(ids) {
+		t.Fatalf("unexpected donation ids %v", ids)
+	}
+	later, err := testDB.DonationIDsGet(ctx, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(later) != 0 {
+		t.Errorf("expected no donation ids, got %v", later)
+	}
+
+	err = testDB.DonationOrphansRecord(ctx, map[string]string{
+		"sf-opp-001":  "deleted",
+		"sf-opp-002":  "missing",
+		"sf-opp-none": "missing", // not a local donation
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphans, err := testDB.DonationOrphansGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, o := range orphans {
+		got = append(got, o.ID+" "+o.Reason)
+		if o.Name == "" || o.CloseDate == nil || o.DetectedAt.IsZero() {
+			t.Errorf("orphan %s fields not populated %+v", o.ID, o)
+		}
+	}
+	slices.Sort(got)
+	if want := []string{"sf-opp-001 deleted", "sf-opp-002 missing"}; !slices.Equal(got, want) {
+		t.Errorf("got orphans %v want %v", got, want)
+	}
+
+	// Donations no longer orphaned are cleared.
+	if err := testDB.DonationOrphansRecord(ctx, map[string]string{"sf-opp-002": "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	orphans, err = testDB.DonationOrphansGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0].ID != "sf-opp-002" || orphans[0].Reason != "deleted" {
+		t.Errorf("unexpected orphans %+v", orphans)
+	}
+	if orphans[0].Links != 1 {
+		t.Errorf("got %d links want 1", orphans[0].Links)
+	}
+
+	// Removed donations are not listed.
+	if _, err := testDB.DeleteDonations(ctx, []string{"sf-opp-002"}); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, err = testDB.DonationOrphansGet(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("expected no orphans, got %+v", orphans)
+	}
+}
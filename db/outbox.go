@@ -0,0 +1,83 @@
+package db
+
+// outbox.go queues the Salesforce updates of link actions made while Salesforce was
+// unreachable, so that they can be retried later and the affected donations shown as
+// awaiting a remote update.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// SalesforceOutboxQueue queues the payout reference updates idRefs of the pending
+// action with id, replacing any updates of the same donations already queued for the
+// action.
+func (db *DB) SalesforceOutboxQueue(ctx context.Context, id int64, idRefs []salesforce.IDRef) error {
+
+	stmt := db.salesforceOutboxInsertStmt
+
+	refsJSON, err := json.Marshal(idRefs)
+	if err != nil {
+		return fmt.Errorf("salesforce outbox encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"ID":     id,
+		"IDRefs": string(refsJSON),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("salesforce outbox insert verify arguments error: %v", err))
+		return fmt.Errorf("salesforce outbox insert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to queue salesforce updates of pending action %d: %v", id, err))
+		return fmt.Errorf("failed to queue salesforce updates of pending action %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("pending action %d queued %d salesforce updates", id, len(idRefs)))
+	return nil
+}
+
+// SalesforceOutboxClear removes the queued Salesforce updates of the pending action
+// with id.
+func (db *DB) SalesforceOutboxClear(ctx context.Context, id int64) error {
+
+	stmt := db.salesforceOutboxClearStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("salesforce outbox clear verify arguments error: %v", err))
+		return fmt.Errorf("salesforce outbox clear verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to clear salesforce updates of pending action %d: %v", id, err))
+		return fmt.Errorf("failed to clear salesforce updates of pending action %d: %w", id, err)
+	}
+	return nil
+}
+
+// SalesforceOutboxActions retrieves the ids of up to limit open pending actions with
+// queued Salesforce updates, oldest first.
+func (db *DB) SalesforceOutboxActions(ctx context.Context, limit int) ([]int64, error) {
+
+	stmt := db.salesforceOutboxActionsStmt
+
+	namedArgs := map[string]any{
+		"HereLimit": limit,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("salesforce outbox actions verify arguments error: %v", err))
+		return nil, fmt.Errorf("salesforce outbox actions verify arguments error: %w", err)
+	}
+	var ids []int64
+	err := stmt.SelectContext(ctx, &ids, namedArgs)
+	db.logQuery(ctx, "salesforce outbox actions", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("salesforce outbox actions select error: %v", err))
+		return nil, fmt.Errorf("salesforce outbox actions select error: %w", err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,80 @@
+package db
+
+// tests for the queued Salesforce updates
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+func TestSalesforceOutbox(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	id, err := testDB.PendingActionCreate(ctx, "link", "link 2 donations to INV-2025-102", `{"action":"link"}`, "salesforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idRefs := []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}, {ID: "sf-opp-002", Ref: "INV-2025-102"}}
+	for range 2 { // queueing again replaces the queued updates
+		if err := testDB.SalesforceOutboxQueue(ctx, id, idRefs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pendingDonations := func() []string {
+		t.Helper()
+		from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+		donations, err := testDB.DonationsGet(ctx, from, to, "All", "", "", "", SortOrder{}, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, d := range donations {
+			if d.RemotePending {
+				ids = append(ids, d.ID)
+			}
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got, want := pendingDonations(), []string{"sf-opp-001", "sf-opp-002"}; !slices.Equal(got, want) {
+		t.Errorf("pending donations got %v want %v", got, want)
+	}
+	action, err := testDB.PendingActionGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !action.Queued {
+		t.Error("expected the action to be queued")
+	}
+	ids, err := testDB.SalesforceOutboxActions(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids, []int64{id}; !slices.Equal(got, want) {
+		t.Errorf("outbox actions got %v want %v", got, want)
+	}
+
+	// Closed actions are not retried.
+	if err := testDB.PendingActionUpdate(ctx, id, "local", "done", ""); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := testDB.SalesforceOutboxActions(ctx, 10); err != nil || len(ids) != 0 {
+		t.Errorf("outbox actions of closed action got %v %v", ids, err)
+	}
+
+	if err := testDB.SalesforceOutboxClear(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if got := pendingDonations(); len(got) != 0 {
+		t.Errorf("expected no pending donations after clearing, got %v", got)
+	}
+}
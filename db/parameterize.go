@@ -1,18 +1,23 @@
 package db
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
 	"regexp"
+	"slices"
 	"strings"
 )
 
 // ParameterizedSQLTemplate is a struct holding a parsed template with parameters
-// extracted and arguments replaced by the '?' symbol.
+// extracted and arguments replaced by the '?' symbol. Optional lists the parameters
+// controlling optional blocks, if any, in order of first appearance. The Body retains
+// the optional block markers; use Render to produce a query.
 type ParameterizedSQLTemplate struct {
 	Body       []byte
 	Parameters []string
+	Optional   []string
 }
 
 // String provides a printable representation.
@@ -24,6 +29,89 @@ Body:   %s
 	return fmt.Sprintf(tpl, strings.Join(p.Parameters, ", "), string(p.Body))
 }
 
+// regexpIf and regexpEndIf match the lines marking the start and end of optional
+// blocks, such as
+//
+//	-- IF :TextSearch
+//	AND LOWER(i.contact) REGEXP LOWER(v.TextSearch)
+//	-- END IF
+//
+// The block is included in the query only if the TextSearch argument is not empty.
+// As the markers are comments the file can still be run as is, with all the blocks
+// included. Blocks may be nested.
+var (
+	regexpIf    = regexp.MustCompile(`^\s*-- IF :([A-Za-z0-9_]+)\s*$`)
+	regexpEndIf = regexp.MustCompile(`^\s*-- END IF\s*$`)
+)
+
+// parseOptionalBlocks checks the optional block markers in body, returning the
+// parameters controlling the blocks. Each block parameter must be a declared
+// parameter.
+func parseOptionalBlocks(body []byte, parameters []string) ([]string, error) {
+	var optional, stack []string
+	for i, line := range bytes.SplitAfter(body, []byte("\n")) {
+		if m := regexpIf.FindSubmatch(line); m != nil {
+			param := string(m[1])
+			if !slices.Contains(parameters, param) {
+				return nil, fmt.Errorf("line %d: optional block parameter %q is not a declared parameter", i+1, param)
+			}
+			if !slices.Contains(optional, param) {
+				optional = append(optional, param)
+			}
+			stack = append(stack, param)
+			continue
+		}
+		if regexpEndIf.Match(line) {
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: END IF without IF", i+1)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("optional block for %q is not closed with END IF", stack[len(stack)-1])
+	}
+	return optional, nil
+}
+
+// Render returns the query with the optional blocks for which include reports false
+// removed. The block markers are always removed, since sqlx would otherwise treat the
+// colon-prefixed names in them as query parameters.
+func (p ParameterizedSQLTemplate) Render(include func(param string) bool) []byte {
+	if len(p.Optional) == 0 {
+		return p.Body
+	}
+	var out bytes.Buffer
+	var stack []bool // inclusion state of the enclosing blocks
+	included := func() bool { return !slices.Contains(stack, false) }
+	for _, line := range bytes.SplitAfter(p.Body, []byte("\n")) {
+		if m := regexpIf.FindSubmatch(line); m != nil {
+			stack = append(stack, include(string(m[1])))
+			continue
+		}
+		if regexpEndIf.Match(line) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if included() {
+			out.Write(line)
+		}
+	}
+	return out.Bytes()
+}
+
+// argPresent reports if an argument value should include its optional blocks: nil
+// values and empty strings exclude them.
+func argPresent(v any) bool {
+	switch a := v.(type) {
+	case nil:
+		return false
+	case string:
+		return a != ""
+	}
+	return true
+}
+
 // regexpParam matches lines such as
 //
 //	,date('2026-03-31') AS DateTo    /* @param */
@@ -68,6 +156,9 @@ var (
 //	}
 //
 // Multiple definitions in a template are handled, as shown in the test.
+//
+// Optional blocks, which are removed from the query when their parameter argument is
+// empty, are marked with `-- IF :Param` and `-- END IF` lines; see regexpIf.
 func parameterize(tpl []byte) (*ParameterizedSQLTemplate, error) {
 
 	matches := regexpParam.FindAllSubmatch(tpl, -1)
@@ -86,6 +177,12 @@ func parameterize(tpl []byte) (*ParameterizedSQLTemplate, error) {
 
 	// Use $ quoted parameter names such as `$DateFrom`.
 	pst.Body = regexpParam.ReplaceAll(tpl, []byte(`:${param}${as}${param}`))
+
+	optional, err := parseOptionalBlocks(pst.Body, pst.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("parameterize: %w", err)
+	}
+	pst.Optional = optional
 	return pst, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -107,3 +108,99 @@ Body:   body
 		t.Errorf("got:\n%s\nwant:\n%s", got, want)
 	}
 }
+
+func TestParameterizeOptionalBlocks(t *testing.T) {
+
+	input := `WITH variables AS (
+    SELECT
+        'abc' AS TextSearch   /* @param */
+        ,'' AS Reference      /* @param */
+)
+SELECT * FROM t, variables v
+WHERE
+    1 = 1
+    -- IF :TextSearch
+    AND t.name REGEXP v.TextSearch
+    -- IF :Reference
+    AND t.ref = v.Reference
+    -- END IF
+    -- END IF
+;
+`
+	pst, err := parameterize([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"TextSearch", "Reference"}, pst.Optional); diff != "" {
+		t.Errorf("Optional mismatch (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		name    string
+		include map[string]bool
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "all",
+			include: map[string]bool{"TextSearch": true, "Reference": true},
+			want:    []string{"t.name REGEXP", "t.ref = v.Reference"},
+		},
+		{
+			name:    "outer only",
+			include: map[string]bool{"TextSearch": true},
+			want:    []string{"t.name REGEXP"},
+			notWant: []string{"t.ref = v.Reference"},
+		},
+		{
+			name:    "nested omitted with outer",
+			include: map[string]bool{"Reference": true},
+			notWant: []string{"t.name REGEXP", "t.ref = v.Reference"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := string(pst.Render(func(p string) bool { return tt.include[p] }))
+			if strings.Contains(body, "-- IF") || strings.Contains(body, "-- END IF") {
+				t.Errorf("block markers not removed:\n%s", body)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(body, w) {
+					t.Errorf("expected %q in:\n%s", w, body)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(body, w) {
+					t.Errorf("did not expect %q in:\n%s", w, body)
+				}
+			}
+		})
+	}
+
+	// Block errors.
+	for _, bad := range []string{
+		"'a' AS A /* @param */\n-- IF :B\nx\n-- END IF\n",
+		"'a' AS A /* @param */\n-- IF :A\nx\n",
+		"'a' AS A /* @param */\nx\n-- END IF\n",
+	} {
+		if _, err := parameterize([]byte(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestArgPresent(t *testing.T) {
+	for _, tt := range []struct {
+		v    any
+		want bool
+	}{
+		{nil, false},
+		{"", false},
+		{"x", true},
+		{0, true},
+	} {
+		if got := argPresent(tt.v); got != tt.want {
+			t.Errorf("argPresent(%#v) got %t want %t", tt.v, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+// payouts.go deals with the items of the payout reports of donation platforms, which
+// explain the composition of payouts received as bank transactions.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// PayoutItem is a donation itemised in the payout report of a donation platform,
+// held against the reference of the bank transaction of the payout. The donation
+// fields are set, as returned by PayoutItemsGet, if a donation counted against the
+// payout reference has the gross amount of the item.
+type PayoutItem struct {
+	ID               int64        `db:"id"`
+	PayoutReference  string       `db:"payout_reference"`
+	Platform         string       `db:"platform"`
+	PlatformPayoutID string       `db:"platform_payout_id"`
+	ItemRef          string       `db:"item_ref"`
+	Date             time.Time    `db:"date"`
+	Name             string       `db:"name"`
+	Description      string       `db:"description"`
+	Gross            money.Amount `db:"gross"`
+	Fee              money.Amount `db:"fee"`
+	Net              money.Amount `db:"net"`
+	ImportedAt       time.Time    `db:"imported_at"`
+	DonationID       *string      `db:"donation_id"`
+	DonationName     *string      `db:"donation_name"`
+}
+
+// PayoutItemsReplace replaces the report items of the payout with reference with
+// items, returning the number of items recorded.
+func (db *DB) PayoutItemsReplace(ctx context.Context, reference string, items []PayoutItem) (int, error) {
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("payoutItemsReplace: could not begin transaction: %v", err))
+		return 0, fmt.Errorf("payoutItemsReplace: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	deleteStmt := db.payoutItemsDeleteStmt
+	namedArgs := map[string]any{
+		"PayoutReference": reference,
+	}
+	if err := deleteStmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("payout items delete verify arguments error: %v", err))
+		return 0, fmt.Errorf("payout items delete verify arguments error: %w", err)
+	}
+	if _, err := deleteStmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete payout items of %s: %v", reference, err))
+		return 0, fmt.Errorf("failed to delete payout items of %s: %w", reference, err)
+	}
+
+	insertStmt := db.payoutItemInsertStmt
+	for _, item := range items {
+		namedArgs := map[string]any{
+			"PayoutReference":  reference,
+			"Platform":         item.Platform,
+			"PlatformPayoutID": item.PlatformPayoutID,
+			"ItemRef":          item.ItemRef,
+			"Date":             sqlDate(item.Date),
+			"Name":             item.Name,
+			"Description":      item.Description,
+			"Gross":            item.Gross,
+			"Fee":              item.Fee,
+			"Net":              item.Net,
+		}
+		if err := insertStmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("payout item insert verify arguments error: %v", err))
+			return 0, fmt.Errorf("payout item insert verify arguments error: %w", err)
+		}
+		if _, err := insertStmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to insert payout item %s: %v", item.ItemRef, err))
+			return 0, fmt.Errorf("failed to insert payout item %s: %w", item.ItemRef, err)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("payoutItemsReplace: %d items for payout %s", len(items), reference))
+	return len(items), tx.commit()
+}
+
+// PayoutItemsGet retrieves the report items of the payout with reference in date
+// order, each with any donation matched to it.
+func (db *DB) PayoutItemsGet(ctx context.Context, reference string) ([]PayoutItem, error) {
+
+	stmt := db.payoutItemsGetStmt
+
+	namedArgs := map[string]any{
+		"PayoutReference": reference,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("payout items verify arguments error: %v", err))
+		return nil, fmt.Errorf("payout items verify arguments error: %w", err)
+	}
+
+	var items []PayoutItem
+	err := stmt.SelectContext(ctx, &items, namedArgs)
+	db.logQuery(ctx, "payout items", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("payout items select error: %v", err))
+		return nil, fmt.Errorf("payout items select error: %w", err)
+	}
+	return items, nil
+}
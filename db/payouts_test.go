@@ -0,0 +1,84 @@
+package db
+
+// tests for payout report items
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestPayoutItems(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	// JG-PAYOUT-2025-04-15 has linked donations including two of 20.00 on 13/04 and one
+	// of 55.00 on 15/04.
+	reference := "JG-PAYOUT-2025-04-15"
+	item := func(ref string, day int, gross float64) PayoutItem {
+		return PayoutItem{
+			Platform: "justgiving",
+			ItemRef:  ref,
+			Date:     time.Date(2025, 4, day, 9, 0, 0, 0, time.UTC),
+			Name:     "Donor " + ref,
+			Gross:    money.FromFloat(gross),
+			Fee:      money.FromFloat(gross * 0.05),
+			Net:      money.FromFloat(gross * 0.95),
+		}
+	}
+
+	// A first import is replaced by the second.
+	if _, err := testDB.PayoutItemsReplace(ctx, reference, []PayoutItem{item("JG-0", 12, 999)}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := testDB.PayoutItemsReplace(ctx, reference, []PayoutItem{
+		item("JG-1", 13, 20),
+		item("JG-2", 13, 20),
+		item("JG-3", 15, 55),
+		item("JG-4", 15, 75), // not in Salesforce
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("got %d items recorded want 4", n)
+	}
+
+	items, err := testDB.PayoutItemsGet(ctx, reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(items), 4; got != want {
+		t.Fatalf("got %d items want %d", got, want)
+	}
+	matched := map[string]string{}
+	for _, i := range items {
+		if i.PayoutReference != reference {
+			t.Errorf("item %s has reference %s", i.ItemRef, i.PayoutReference)
+		}
+		if i.DonationID != nil {
+			if prev, ok := matched[*i.DonationID]; ok {
+				t.Errorf("donation %s matched to %s and %s", *i.DonationID, prev, i.ItemRef)
+			}
+			matched[*i.DonationID] = i.ItemRef
+		}
+	}
+	if items[0].ItemRef != "JG-1" || items[0].DonationID == nil {
+		t.Errorf("expected JG-1 to be matched, got %+v", items[0])
+	}
+	if items[2].DonationID == nil || *items[2].DonationID != "sf-opp-013" {
+		t.Errorf("expected JG-3 to be matched to sf-opp-013, got %+v", items[2])
+	}
+	if items[3].DonationID != nil {
+		t.Errorf("expected JG-4 to be unmatched, got %s", *items[3].DonationID)
+	}
+
+	items, err = testDB.PayoutItemsGet(ctx, "no-such-payout")
+	if err != nil || len(items) != 0 {
+		t.Errorf("expected no items, got %d and err %v", len(items), err)
+	}
+}
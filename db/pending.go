@@ -0,0 +1,179 @@
+package db
+
+// pending.go records the progress of link actions, so that actions which fail part way
+// through may be retried or reversed.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// PendingAction is a link action with its progress. The Payload is the json encoded
+// action. Queued actions have Salesforce updates awaiting Salesforce becoming
+// reachable.
+type PendingAction struct {
+	ID          int64     `db:"id"`
+	Action      string    `db:"action"`
+	Description string    `db:"description"`
+	Payload     string    `db:"payload"`
+	Step        string    `db:"step"`
+	Status      string    `db:"status"`
+	Attempts    int       `db:"attempts"`
+	LastError   string    `db:"last_error"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+	Queued      bool      `db:"queued"`
+}
+
+// PendingActionCreate records a new pending action at the first step, returning its
+// id.
+func (db *DB) PendingActionCreate(ctx context.Context, action, description, payload, step string) (int64, error) {
+
+	stmt := db.pendingActionInsertStmt
+
+	namedArgs := map[string]any{
+		"Action":      action,
+		"Description": description,
+		"Payload":     payload,
+		"Step":        step,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("pending action insert verify arguments error: %v", err))
+		return 0, fmt.Errorf("pending action insert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert pending action: %v", err))
+		return 0, fmt.Errorf("failed to insert pending action: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("pending action id error: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("pending action %d recorded: %s", id, description))
+	return id, nil
+}
+
+// PendingActionUpdate records the step and status of a pending action. The lastError
+// is recorded for failed actions.
+func (db *DB) PendingActionUpdate(ctx context.Context, id int64, step, status, lastError string) error {
+
+	stmt := db.pendingActionUpdateStmt
+
+	namedArgs := map[string]any{
+		"ID":        id,
+		"Step":      step,
+		"Status":    status,
+		"LastError": lastError,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("pending action update verify arguments error: %v", err))
+		return fmt.Errorf("pending action update verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to update pending action %d: %v", id, err))
+		return fmt.Errorf("failed to update pending action %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("pending action %d %s at step %s", id, status, step))
+	return nil
+}
+
+// PendingActionsGet retrieves the pending actions, most recent first. If all is false
+// only the open actions, those which are pending or failed, are returned.
+func (db *DB) PendingActionsGet(ctx context.Context, all bool) ([]PendingAction, error) {
+
+	stmt := db.pendingActionsGetStmt
+
+	status := "open"
+	if all {
+		status = "all"
+	}
+	namedArgs := map[string]any{
+		"Status": status,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("pending actions verify arguments error: %v", err))
+		return nil, fmt.Errorf("pending actions verify arguments error: %w", err)
+	}
+
+	var actions []PendingAction
+	err := stmt.SelectContext(ctx, &actions, namedArgs)
+	db.logQuery(ctx, "pending actions", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("pending actions select error: %v", err))
+		return nil, fmt.Errorf("pending actions select error: %w", err)
+	}
+	return actions, nil
+}
+
+// PendingActionGet retrieves a pending action by id. ErrNotFound, which matches
+// sql.ErrNoRows, is returned if the action does not exist.
+func (db *DB) PendingActionGet(ctx context.Context, id int64) (PendingAction, error) {
+
+	stmt := db.pendingActionGetStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("pending action verify arguments error: %v", err))
+		return PendingAction{}, fmt.Errorf("pending action verify arguments error: %w", err)
+	}
+
+	var actions []PendingAction
+	err := stmt.SelectContext(ctx, &actions, namedArgs)
+	db.logQuery(ctx, "pending action", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("pending action select error: %v", err))
+		return PendingAction{}, fmt.Errorf("pending action select error: %w", err)
+	}
+	if len(actions) == 0 {
+		return PendingAction{}, ErrNotFound{"pending action", strconv.FormatInt(id, 10)}
+	}
+	return actions[0], nil
+}
+
+// DonationRef is the payout reference of a donation, with the fields used to detect
+// changes made to the donation in Salesforce since it was last refreshed.
+type DonationRef struct {
+	ID           string       `db:"id"`
+	Name         string       `db:"name"`
+	Amount       money.Amount `db:"amount"`
+	CloseDate    *time.Time   `db:"close_date"`
+	Ref          string       `db:"ref"`
+	LastModified *time.Time   `db:"last_modified_date"`
+}
+
+// DonationRefsGet retrieves the current payout references of the donations with the
+// given ids, being the references needed to reverse a link action. Donations without
+// a payout reference have an empty Ref.
+func (db *DB) DonationRefsGet(ctx context.Context, donationIDs []string) ([]DonationRef, error) {
+
+	stmt := db.donationRefsGetStmt
+
+	ids, err := json.Marshal(donationIDs)
+	if err != nil {
+		return nil, fmt.Errorf("donation refs id encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"DonationIDs": string(ids),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation refs verify arguments error: %v", err))
+		return nil, fmt.Errorf("donation refs verify arguments error: %w", err)
+	}
+
+	var refs []DonationRef
+	err = stmt.SelectContext(ctx, &refs, namedArgs)
+	db.logQuery(ctx, "donation refs", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("donation refs select error: %v", err))
+		return nil, fmt.Errorf("donation refs select error: %w", err)
+	}
+	return refs, nil
+}
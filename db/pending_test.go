@@ -0,0 +1,109 @@
+package db
+
+// tests for pending link actions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPendingActions(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	id, err := testDB.PendingActionCreate(ctx, "link", "link 2 donations to invoice INV-2025-101", `{"action":"link"}`, "salesforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.PendingActionCreate(ctx, "relink", "invalid", "{}", "salesforce"); err == nil {
+		t.Error("expected an invalid action to fail")
+	}
+
+	// A failure is counted and its error recorded.
+	if err := testDB.PendingActionUpdate(ctx, id, "xero", "failed", "xero unavailable"); err != nil {
+		t.Fatal(err)
+	}
+	action, err := testDB.PendingActionGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action.Step != "xero" || action.Status != "failed" || action.Attempts != 1 || action.LastError != "xero unavailable" {
+		t.Errorf("unexpected failed action %+v", action)
+	}
+	actions, err := testDB.PendingActionsGet(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].ID != id {
+		t.Errorf("expected one open action, got %+v", actions)
+	}
+
+	// A retry keeps the last error until the action is done.
+	if err := testDB.PendingActionUpdate(ctx, id, "local", "pending", ""); err != nil {
+		t.Fatal(err)
+	}
+	if action, err = testDB.PendingActionGet(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if action.Attempts != 1 || action.LastError != "xero unavailable" {
+		t.Errorf("unexpected retried action %+v", action)
+	}
+	if err := testDB.PendingActionUpdate(ctx, id, "local", "done", ""); err != nil {
+		t.Fatal(err)
+	}
+	if action, err = testDB.PendingActionGet(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if action.Status != "done" || action.LastError != "" {
+		t.Errorf("unexpected done action %+v", action)
+	}
+
+	actions, err = testDB.PendingActionsGet(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("expected no open actions, got %d", len(actions))
+	}
+	actions, err = testDB.PendingActionsGet(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 {
+		t.Errorf("expected one action, got %d", len(actions))
+	}
+
+	if _, err := testDB.PendingActionGet(ctx, id+1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+}
+
+func TestDonationRefsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if _, err := testDB.ExecContext(ctx, "UPDATE donations SET payout_reference_dfk = NULL WHERE id = 'sf-opp-odd-01'"); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := testDB.DonationRefsGet(ctx, []string{"sf-opp-odd-01", "sf-opp-001", "sf-opp-none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range refs {
+		got = append(got, r.ID+" "+r.Ref)
+		if r.Name == "" || r.Amount == 0 || r.CloseDate == nil {
+			t.Errorf("donation ref %s fields not populated %+v", r.ID, r)
+		}
+	}
+	if want := []string{"sf-opp-001 INV-2025-101", "sf-opp-odd-01 "}; !slices.Equal(got, want) {
+		t.Errorf("got refs %v want %v", got, want)
+	}
+}
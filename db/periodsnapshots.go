@@ -0,0 +1,183 @@
+package db
+
+// periodsnapshots.go records the reconciliation state of a period when it is locked,
+// so that any changes made to the period after its close can be shown.
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// PeriodSnapshotRecord is a record reconciled when a period snapshot was taken. Hash is
+// a hash of the values reconciled, which changes if any of them change.
+type PeriodSnapshotRecord struct {
+	RecordType    string       `json:"type"` // "invoice" or "bank-transaction"
+	ID            string       `json:"id"`
+	Ref           string       `json:"ref"` // the invoice number or reference
+	DonationTotal money.Amount `json:"donation_total"`
+	Hash          string       `json:"hash"`
+}
+
+// PeriodSnapshotRecords are the reconciled records of a snapshot, stored as json.
+type PeriodSnapshotRecords []PeriodSnapshotRecord
+
+// Value implements driver.Valuer, storing the records as a json array.
+func (r PeriodSnapshotRecords) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner, reading the records from a json array.
+func (r *PeriodSnapshotRecords) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into period snapshot records", src)
+	}
+}
+
+// PeriodSnapshot is the reconciliation state of the period of a period lock. The
+// counts and totals are of the invoices and bank transactions dated in the period.
+type PeriodSnapshot struct {
+	ID                         int64                 `db:"id"`
+	PeriodLockID               int64                 `db:"period_lock_id"`
+	DateFrom                   time.Time             `db:"date_from"`
+	DateTo                     time.Time             `db:"date_to"`
+	Invoices                   int                   `db:"invoices"`
+	InvoicesReconciled         int                   `db:"invoices_reconciled"`
+	BankTransactions           int                   `db:"bank_transactions"`
+	BankTransactionsReconciled int                   `db:"bank_transactions_reconciled"`
+	DonationTotal              money.Amount          `db:"donation_total"`
+	ReconciledTotal            money.Amount          `db:"reconciled_total"`
+	Records                    PeriodSnapshotRecords `db:"records"`
+	CreatedAt                  time.Time             `db:"created_at"`
+}
+
+// PayoutDonation is a donation counted against a payout reference, with the amount
+// counted.
+type PayoutDonation struct {
+	Ref        string       `db:"ref"`
+	DonationID string       `db:"donation_id"`
+	Amount     money.Amount `db:"amount"`
+}
+
+// PeriodSnapshotInsert records a period snapshot, returning its id.
+func (db *DB) PeriodSnapshotInsert(ctx context.Context, s PeriodSnapshot) (int64, error) {
+
+	stmt := db.periodSnapshotInsertStmt
+
+	namedArgs := map[string]any{
+		"PeriodLockID":               s.PeriodLockID,
+		"DateFrom":                   sqlDate(s.DateFrom),
+		"DateTo":                     sqlDate(s.DateTo),
+		"Invoices":                   s.Invoices,
+		"InvoicesReconciled":         s.InvoicesReconciled,
+		"BankTransactions":           s.BankTransactions,
+		"BankTransactionsReconciled": s.BankTransactionsReconciled,
+		"DonationTotal":              s.DonationTotal,
+		"ReconciledTotal":            s.ReconciledTotal,
+		"Records":                    s.Records,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period snapshot insert verify arguments error: %v", err))
+		return 0, fmt.Errorf("period snapshot insert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert period snapshot: %v", err))
+		return 0, fmt.Errorf("failed to insert period snapshot: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("period snapshot id error: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("recorded period snapshot %d of %d records", id, len(s.Records)))
+	return id, nil
+}
+
+// PeriodSnapshotsGet retrieves the period snapshots, latest first.
+func (db *DB) PeriodSnapshotsGet(ctx context.Context) ([]PeriodSnapshot, error) {
+	return db.periodSnapshotsGet(ctx, 0)
+}
+
+// PeriodSnapshotGet retrieves the period snapshot with id, returning ErrNotFound if it
+// does not exist.
+func (db *DB) PeriodSnapshotGet(ctx context.Context, id int64) (PeriodSnapshot, error) {
+	snapshots, err := db.periodSnapshotsGet(ctx, id)
+	if err != nil {
+		return PeriodSnapshot{}, err
+	}
+	if len(snapshots) == 0 {
+		return PeriodSnapshot{}, ErrNotFound{"period snapshot", fmt.Sprint(id)}
+	}
+	return snapshots[0], nil
+}
+
+// periodSnapshotsGet retrieves the period snapshot with id, or all period snapshots if
+// id is 0.
+func (db *DB) periodSnapshotsGet(ctx context.Context, id int64) ([]PeriodSnapshot, error) {
+
+	stmt := db.periodSnapshotsGetStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("period snapshots verify arguments error: %v", err))
+		return nil, fmt.Errorf("period snapshots verify arguments error: %w", err)
+	}
+
+	var snapshots []PeriodSnapshot
+	err := stmt.SelectContext(ctx, &snapshots, namedArgs)
+	db.logQuery(ctx, "period snapshots", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("period snapshots select error: %v", err))
+		return nil, fmt.Errorf("period snapshots select error: %w", err)
+	}
+	return snapshots, nil
+}
+
+// PayoutDonationsGet retrieves the donations counted against each of the payout
+// references refs, ordered by reference and donation id.
+func (db *DB) PayoutDonationsGet(ctx context.Context, refs []string) ([]PayoutDonation, error) {
+
+	stmt := db.periodSnapshotDonationsStmt
+
+	encoded, err := json.Marshal(refs)
+	if err != nil {
+		return nil, fmt.Errorf("payout donations encoding error: %w", err)
+	}
+	namedArgs := map[string]any{
+		"Refs": string(encoded),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("payout donations verify arguments error: %v", err))
+		return nil, fmt.Errorf("payout donations verify arguments error: %w", err)
+	}
+
+	var donations []PayoutDonation
+	err = stmt.SelectContext(ctx, &donations, namedArgs)
+	db.logQuery(ctx, "payout donations", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("payout donations select error: %v", err))
+		return nil, fmt.Errorf("payout donations select error: %w", err)
+	}
+	return donations, nil
+}
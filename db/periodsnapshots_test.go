@@ -0,0 +1,77 @@
+package db
+
+// tests for period snapshots
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestPeriodSnapshots(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	want := PeriodSnapshot{
+		PeriodLockID:               1,
+		DateFrom:                   time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:                     time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC),
+		Invoices:                   6,
+		InvoicesReconciled:         2,
+		BankTransactions:           3,
+		BankTransactionsReconciled: 1,
+		DonationTotal:              money.FromFloat(1250.50),
+		ReconciledTotal:            money.FromFloat(700),
+		Records: PeriodSnapshotRecords{
+			{RecordType: "invoice", ID: "inv-001", Ref: "INV-2025-101", DonationTotal: money.FromFloat(500), Hash: "a1"},
+			{RecordType: "bank-transaction", ID: "bt-001", Ref: "JG-PAYOUT-2025-04-15", DonationTotal: money.FromFloat(200), Hash: "b2"},
+		},
+	}
+	id, err := testDB.PeriodSnapshotInsert(ctx, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.ID = id
+
+	got, err := testDB.PeriodSnapshotGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(PeriodSnapshot{}, "CreatedAt")); diff != "" {
+		t.Errorf("unexpected snapshot (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.PeriodSnapshotGet(ctx, id+1); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// An empty snapshot is recorded with no records.
+	if _, err := testDB.PeriodSnapshotInsert(ctx, PeriodSnapshot{PeriodLockID: 2, DateFrom: want.DateFrom, DateTo: want.DateTo}); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := testDB.PeriodSnapshotsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].PeriodLockID != 2 || len(snapshots[0].Records) != 0 {
+		t.Errorf("unexpected snapshots %+v", snapshots)
+	}
+
+	donations, err := testDB.PayoutDonationsGet(ctx, []string{"INV-2025-101", "INV-NONE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDonations := []PayoutDonation{
+		{Ref: "INV-2025-101", DonationID: "sf-opp-001", Amount: money.FromFloat(500)},
+		{Ref: "INV-2025-101", DonationID: "sf-opp-odd-01", Amount: money.FromFloat(50)},
+	}
+	if diff := cmp.Diff(wantDonations, donations); diff != "" {
+		t.Errorf("unexpected payout donations (-want +got):\n%s", diff)
+	}
+}
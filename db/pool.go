@@ -0,0 +1,159 @@
+package db
+
+// pool.go configures the sqlite connection pool for concurrent use by the web server.
+// Sqlite allows a single writer at a time, so writes through the prepared statements
+// are serialised by a lock, and readers wait for the busy timeout rather than failing
+// with SQLITE_BUSY while a write is committed. The connections of a shared cache
+// in-memory database fail at once with SQLITE_LOCKED on a table locked by another
+// connection, so their statements are retried for the busy timeout. Contention is
+// recorded for PoolStats.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// busyTimeout is how long a connection waits for a lock held by another connection
+// before failing with SQLITE_BUSY.
+const busyTimeout = 5 * time.Second
+
+// writeLockTimeout is how long a write waits for the write lock before failing with
+// ErrWriteLockTimeout.
+const writeLockTimeout = time.Minute
+
+// ErrWriteLockTimeout is returned by a write which could not take the write lock, such
+// as one made within a transaction with a context other than that of the transaction.
+var ErrWriteLockTimeout = errors.New("timed out waiting for the database write lock")
+
+// PoolStats reports the use of the connection pool and the contention for writes.
+type PoolStats struct {
+	sql.DBStats
+
+	Writes        int64         // writes made under the write lock
+	WriteWaits    int64         // writes which waited for another write to finish
+	WriteWaitTime time.Duration // the total time spent waiting for the write lock
+	BusyErrors    int64         // queries failing as the database was locked
+}
+
+// writeLock serialises the writes of a database, counting the writes which had to
+// wait for it. A transaction holds the lock until it ends, and writes made with a
+// context carrying that transaction take the lock without waiting. Other writes wait
+// for at most the timeout, so that a write made within a transaction with another
+// context fails rather than waiting forever for the transaction to end.
+type writeLock struct {
+	sem        chan struct{}
+	holder     atomic.Pointer[txState] // the transaction holding the lock, if any
+	timeout    time.Duration
+	writes     atomic.Int64
+	waits      atomic.Int64
+	waitTime   atomic.Int64 // nanoseconds
+	busyErrors atomic.Int64
+}
+
+// newWriteLock returns a new writeLock.
+func newWriteLock() *writeLock {
+	return &writeLock{sem: make(chan struct{}, 1), timeout: writeLockTimeout}
+}
+
+// lock takes the write lock, returning the func to release it. The lock is not taken
+// again if ctx carries the transaction holding it. An error is returned if ctx is done
+// or the lock is not taken within the timeout.
+func (l *writeLock) lock(ctx context.Context) (func(), error) {
+	if st, ok := ctx.Value(txKey{}).(*txState); ok && !st.done.Load() && l.holder.Load() == st {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		start := time.Now()
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, fmt.Errorf("%w after %s", ErrWriteLockTimeout, l.timeout)
+		}
+		l.waits.Add(1)
+		l.waitTime.Add(int64(time.Since(start)))
+	}
+	l.writes.Add(1)
+	return func() { <-l.sem }, nil
+}
+
+// observe counts err if the database was locked.
+func (l *writeLock) observe(err error) {
+	if isBusy(err) {
+		l.busyErrors.Add(1)
+	}
+}
+
+// isBusy reports if err is a sqlite SQLITE_BUSY or SQLITE_LOCKED error, including
+// their extended codes.
+func isBusy(err error) bool {
+	e, ok := errors.AsType[*sqlite.Error](err)
+	if !ok {
+		return false
+	}
+	code := e.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// isLocked reports if err is a sqlite SQLITE_LOCKED error, including its extended codes.
+func isLocked(err error) bool {
+	e, ok := errors.AsType[*sqlite.Error](err)
+	return ok && e.Code()&0xff == sqlite3.SQLITE_LOCKED
+}
+
+// retryLocked runs fn, running it again while it fails with SQLITE_LOCKED, for at most
+// the busy timeout or until ctx is done.
+func retryLocked(ctx context.Context, fn func() error) error {
+	deadline := time.Now().Add(busyTimeout)
+	wait := time.Millisecond
+	for {
+		err := fn()
+		if !isLocked(err) || time.Now().After(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, 50*time.Millisecond)
+	}
+}
+
+// configurePool sets the connection pool limits. The number of open connections is not
+// limited, as reads continue on other connections while a transaction holds one, and
+// could otherwise exhaust the pool under concurrent syncs. In-memory
+// databases keep their idle connections, as the database is dropped when the last
+// connection closes.
+func configurePool(dbDB *sql.DB, inMemory bool) {
+	dbDB.SetMaxIdleConns(4)
+	if inMemory {
+		dbDB.SetConnMaxLifetime(0)
+		dbDB.SetConnMaxIdleTime(0)
+		return
+	}
+	dbDB.SetConnMaxIdleTime(5 * time.Minute)
+}
+
+// PoolStats returns the connection pool and write contention statistics.
+func (db *DB) PoolStats() PoolStats {
+	return PoolStats{
+		DBStats:       db.Stats(),
+		Writes:        db.writes.writes.Load(),
+		WriteWaits:    db.writes.waits.Load(),
+		WriteWaitTime: time.Duration(db.writes.waitTime.Load()),
+		BusyErrors:    db.writes.busyErrors.Load(),
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+// tests of concurrent use of a file database
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+)
+
+func TestConcurrentWrites(t *testing.T) {
+
+	sqlFS, err := mounts.NewFileMount("sql", SQLEmbeddedFS, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testDB, err := NewConnection(filepath.Join(t.TempDir(), "reconciler.db"), sqlFS, "^(53|55|57)", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = testDB.Close() })
+
+	ctx := context.Background()
+	opts := SeedOptions{
+		From:             time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		To:               time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Invoices:         50,
+		BankTransactions: 50,
+		Donations:        500,
+		LinkedPercent:    60,
+		AccountCodes:     []string{"5300"},
+		Seed:             1,
+	}
+	if _, err := testDB.SeedDemoData(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers, tolerance updates and syncs of a few records run together.
+	errs := make(chan error, 64)
+	var wg sync.WaitGroup
+	for g := range 6 {
+		wg.Go(func() {
+			for i := range 8 {
+				var err error
+				switch (g + i) % 3 {
+				case 0:
+					_, err = testDB.InvoicesGet(ctx, "All", opts.From, opts.To, "", "", SortOrder{}, 30, 0)
+				case 1:
+					err = testDB.ToleranceUpsert(ctx, Tolerance{Percent: float64(i)})
+				default:
+					o := opts
+					o.Invoices, o.BankTransactions, o.Donations = 5, 5, 20
+					o.Seed = uint64(100*g + i)
+					_, err = testDB.SeedDemoData(ctx, o)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent query error: %v", err)
+	}
+
+	stats := testDB.PoolStats()
+	if stats.Writes == 0 {
+		t.Error("expected writes to be counted")
+	}
+	if stats.BusyErrors != 0 {
+		t.Errorf("got %d busy errors", stats.BusyErrors)
+	}
+}
@@ -1,26 +1,97 @@
 package db
 
 // This regregexp.go registers a regexpFunc function as set out in the package docs for
-// modernc.org/sqlite.RegisterFunction and modernc.org/sqlite.FunctionImpl.
+// modernc.org/sqlite.RegisterFunction and modernc.org/sqlite.FunctionImpl, together
+// with a FOLD function removing diacritics so that searches for "muller" match
+// "Müller", and a CONTACTKEY function normalising contact names for the contact
+// aliases.
 
 import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"regexp"
+	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
+	"golang.org/x/text/unicode/norm"
 	"modernc.org/sqlite"
 )
 
 var registerOnce sync.Once
 
-// RegisterFunctions registers the custom Go function with the sqlite
+// foldReplacer replaces the letters which do not decompose into a base letter and a
+// diacritic.
+var foldReplacer = strings.NewReplacer(
+	"ß", "ss", "ẞ", "SS",
+	"æ", "ae", "Æ", "AE",
+	"œ", "oe", "Œ", "OE",
+	"ø", "o", "Ø", "O",
+	"ł", "l", "Ł", "L",
+	"đ", "d", "Đ", "D",
+	"ð", "d", "Ð", "D",
+	"þ", "th", "Þ", "TH",
+	"ı", "i",
+)
+
+// fold removes the diacritics from s, such as folding "Müller" to "Muller" and
+// "Søren" to "Soren". Case is kept, so that searches fold case separately.
+func fold(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(s))
+	return norm.NFC.String(foldReplacer.Replace(s))
+}
+
+// contactSuffixes are the company suffixes dropped from contact names by contactKey.
+var contactSuffixes = map[string]bool{
+	"ltd": true, "limited": true, "plc": true, "llp": true, "llc": true,
+	"inc": true, "corp": true, "co": true, "company": true, "gmbh": true,
+}
+
+// contactKey normalises a contact name for comparison with the contact aliases, folding
+// diacritics and case, treating punctuation as spaces and dropping company suffixes,
+// so that "Stripe Payments (UK) Ltd." and "stripe payments uk" have the same key. A
+// name of only company suffixes keeps them.
+func contactKey(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(fold(s)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var kept []string
+	for _, w := range words {
+		if !contactSuffixes[w] {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) == 0 {
+		kept = words
+	}
+	return strings.Join(kept, " ")
+}
+
+// RegisterFunctions registers the custom Go functions with the sqlite
 // driver. Refer to the sqlite `func_test.go` test for further examples.
 func RegisterFunctions() {
 	registerOnce.Do(func() {
 		sqlite.MustRegisterDeterministicScalarFunction(
-			// Register the function "REGEXP" globally for all connections.
+			// Register the function "REGEXP" globally for all connections,
+			// returning NULL for a NULL subject, such as the account code of a
+			// missing line item.
 			"REGEXP",
 			2,
 			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
@@ -35,6 +106,8 @@ func RegisterFunctions() {
 				}
 
 				switch arg1 := args[1].(type) {
+				case nil:
+					return nil, nil
 				case string:
 					s2 = arg1
 				default:
@@ -49,5 +122,37 @@ func RegisterFunctions() {
 				return matched, nil
 			},
 		)
+		sqlite.MustRegisterDeterministicScalarFunction(
+			// Register the function "FOLD" globally for all connections, returning
+			// NULL for NULL.
+			"FOLD",
+			1,
+			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				switch arg0 := args[0].(type) {
+				case nil:
+					return nil, nil
+				case string:
+					return fold(arg0), nil
+				default:
+					return nil, errors.New("expected argv[0] to be text")
+				}
+			},
+		)
+		sqlite.MustRegisterDeterministicScalarFunction(
+			// Register the function "CONTACTKEY" globally for all connections,
+			// returning an empty string for NULL.
+			"CONTACTKEY",
+			1,
+			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				switch arg0 := args[0].(type) {
+				case nil:
+					return "", nil
+				case string:
+					return contactKey(arg0), nil
+				default:
+					return nil, errors.New("expected argv[0] to be text")
+				}
+			},
+		)
 	})
 }
@@ -55,4 +55,83 @@ func TestRegexpFunctionSucceed(t *testing.T) {
 	if err != nil {
 		t.Errorf("unexpected regexp error after registration: %v", err)
 	}
+
+	// A NULL subject does not match.
+	var matched sql.NullBool
+	if err := testDB.QueryRow("select NULL REGEXP '^[A-Z]'").Scan(&matched); err != nil {
+		t.Fatalf("unexpected regexp error for a NULL subject: %v", err)
+	}
+	if matched.Valid {
+		t.Errorf("regexp of a NULL subject got %v want NULL", matched.Bool)
+	}
+}
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Example Corp", "Example Corp"},
+		{"Müller", "Muller"},
+		{"Mu\u0308ller", "Muller"},
+		{"Søren Kierkegaard", "Soren Kierkegaard"},
+		{"Straße", "Strasse"},
+		{"Łódź", "Lodz"},
+		{"Œuvres Françaises", "OEuvres Francaises"},
+	}
+	for _, tt := range tests {
+		if got := fold(tt.text); got != tt.want {
+			t.Errorf("fold(%q) got %q want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestFoldFunction shows the FOLD function used with REGEXP for case insensitive
+// matching ignoring diacritics.
+func TestFoldFunction(t *testing.T) {
+	RegisterFunctions()
+	testDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = testDB.Close()
+	})
+
+	var matched bool
+	err = testDB.QueryRow("SELECT FOLD('Jürgen Müller') REGEXP ('(?i)' || FOLD('MÜLLER'))").Scan(&matched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("expected a match")
+	}
+	var folded sql.NullString
+	if err := testDB.QueryRow("SELECT FOLD(NULL)").Scan(&folded); err != nil {
+		t.Fatal(err)
+	}
+	if folded.Valid {
+		t.Errorf("expected NULL, got %q", folded.String)
+	}
+}
+
+// TestContactKey tests the normalisation of contact names for the contact aliases.
+func TestContactKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"STRIPE", "stripe"},
+		{"Stripe Payments (UK) Ltd.", "stripe payments uk"},
+		{"  Söderberg & Co  ", "soderberg"},
+		{"JustGiving-Limited", "justgiving"},
+		{"Ltd", "ltd"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := contactKey(tt.name); got != tt.want {
+			t.Errorf("contactKey(%q) got %q want %q", tt.name, got, tt.want)
+		}
+	}
 }
@@ -0,0 +1,291 @@
+package db
+
+// reports.go deals with the queries used for period-end reporting.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// AccountTotal is the total of the donation line items for an account code in a
+// period, as returned by AccountTotalsGet. A line item is reconciled if its invoice or
+// bank transaction is reconciled.
+type AccountTotal struct {
+	AccountCode       string       `db:"account_code"`
+	AccountName       string       `db:"account_name"`
+	RecordCount       int          `db:"record_count"`
+	Total             money.Amount `db:"total"`
+	ReconciledTotal   money.Amount `db:"reconciled_total"`
+	UnreconciledTotal money.Amount `db:"unreconciled_total"`
+}
+
+// AccountTotalsGet retrieves the donation totals for each donation account code for
+// invoices and bank transactions dated between dateFrom and dateTo.
+func (db *DB) AccountTotalsGet(ctx context.Context, dateFrom, dateTo time.Time) ([]AccountTotal, error) {
+
+	db.log.Info(fmt.Sprintf("AccountTotalsGet %s %s", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02")))
+
+	stmt := db.accountTotalsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("accountTotalsGet verify args error: %v", err))
+		return nil, fmt.Errorf("account totals get verify arguments error: %w", err)
+	}
+
+	totals, err := cachedQuery(db, "account totals", namedArgs, func() ([]AccountTotal, error) {
+		var totals []AccountTotal
+		err := stmt.SelectContext(ctx, &totals, namedArgs)
+		db.logQuery(ctx, "account totals", stmt, namedArgs, err)
+		return totals, err
+	})
+	if err != nil {
+		db.log.Error(fmt.Sprintf("account totals select error: %v", err))
+		return nil, fmt.Errorf("account totals select error with named args %v: %w", namedArgs, err)
+	}
+	if len(totals) == 0 {
+		db.log.Info("AccountTotalsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("AccountTotalsGet : retrieved %d records", len(totals)))
+	return totals, nil
+}
+
+// GiftAidDonation is a donation linked to an invoice or bank transaction with Gift Aid
+// income, as returned by GiftAidDonationsGet. The AdditionalFields hold the donor
+// details and Gift Aid eligibility.
+type GiftAidDonation struct {
+	ID               string       `db:"id"`
+	Name             string       `db:"name"`
+	Amount           money.Amount `db:"amount"`
+	CloseDate        time.Time    `db:"close_date"`
+	PayoutReference  string       `db:"payout_reference_dfk"`
+	AdditionalFields string       `db:"additional_fields_json"`
+}
+
+// GiftAidDonationsGet retrieves the donations with a close date between dateFrom and
+// dateTo which are linked to invoices or bank transactions with line items in the
+// account codes matched by the accountCodes regular expression.
+func (db *DB) GiftAidDonationsGet(ctx context.Context, dateFrom, dateTo time.Time, accountCodes string) ([]GiftAidDonation, error) {
+
+	db.log.Info(fmt.Sprintf("GiftAidDonationsGet %s %s accounts %s", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02"), accountCodes))
+
+	stmt := db.giftAidDonationsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": accountCodes,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("giftAidDonationsGet verify args error: %v", err))
+		return nil, fmt.Errorf("gift aid donations get verify arguments error: %w", err)
+	}
+
+	donations, err := cachedQuery(db, "gift aid donations", namedArgs, func() ([]GiftAidDonation, error) {
+		var donations []GiftAidDonation
+		err := stmt.SelectContext(ctx, &donations, namedArgs)
+		db.logQuery(ctx, "gift aid donations", stmt, namedArgs, err)
+		return donations, err
+	})
+	if err != nil {
+		db.log.Error(fmt.Sprintf("gift aid donations select error: %v", err))
+		return nil, fmt.Errorf("gift aid donations select error with named args %v: %w", namedArgs, err)
+	}
+	if len(donations) == 0 {
+		db.log.Info("GiftAidDonationsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("GiftAidDonationsGet : retrieved %d records", len(donations)))
+	return donations, nil
+}
+
+// AgingItem is an unlinked donation or an unreconciled invoice or bank transaction, as
+// returned by AgingItemsGet. ItemType is one of "donation", "invoice" or
+// "bank-transaction". For invoices and bank transactions the Amount is the donation
+// total less the linked donations total. Bucket numbers the age buckets of 0-30, 31-60,
+// 61-90 and over 90 days from 0 to 3.
+type AgingItem struct {
+	ItemType  string       `db:"item_type"`
+	ID        string       `db:"id"`
+	Reference string       `db:"reference"`
+	Name      string       `db:"name"`
+	Date      time.Time    `db:"date"`
+	Amount    money.Amount `db:"amount"`
+	AgeDays   int          `db:"age_days"`
+	Bucket    int          `db:"bucket"`
+}
+
+// AgingItemsGet retrieves the unlinked donations and unreconciled invoices and bank
+// transactions dated between dateFrom and asAt, oldest first, with their age at asAt.
+func (db *DB) AgingItemsGet(ctx context.Context, dateFrom, asAt time.Time) ([]AgingItem, error) {
+
+	db.log.Info(fmt.Sprintf("AgingItemsGet %s %s", dateFrom.Format("2006-01-02"), asAt.Format("2006-01-02")))
+
+	stmt := db.agingItemsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"AsAt":         sqlDate(asAt),
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("agingItemsGet verify args error: %v", err))
+		return nil, fmt.Errorf("aging items get verify arguments error: %w", err)
+	}
+
+	items, err := cachedQuery(db, "aging items", namedArgs, func() ([]AgingItem, error) {
+		var items []AgingItem
+		err := stmt.SelectContext(ctx, &items, namedArgs)
+		db.logQuery(ctx, "aging items", stmt, namedArgs, err)
+		return items, err
+	})
+	if err != nil {
+		db.log.Error(fmt.Sprintf("aging items select error: %v", err))
+		return nil, fmt.Errorf("aging items select error with named args %v: %w", namedArgs, err)
+	}
+	if len(items) == 0 {
+		db.log.Info("AgingItemsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("AgingItemsGet : retrieved %d records", len(items)))
+	return items, nil
+}
+
+// AccountMonth is the total of the donation line items for an account code in a month,
+// as returned by AccountMonthsGet. Month is formatted as "2006-01". The LinkedTotal is
+// the share of the linked donations of each invoice or bank transaction allocated to
+// the account code in proportion to its line items, so that it may be compared with
+// the Total. A record is reconciled if its donation total matches its linked donations.
+type AccountMonth struct {
+	AccountCode     string       `db:"account_code"`
+	AccountName     string       `db:"account_name"`
+	Month           string       `db:"month"`
+	RecordCount     int          `db:"record_count"`
+	Total           money.Amount `db:"total"`
+	LinkedTotal     money.Amount `db:"linked_total"`
+	ReconciledCount int          `db:"reconciled_count"`
+	ReconciledTotal money.Amount `db:"reconciled_total"`
+}
+
+// Difference returns the total less the linked donations total.
+func (m AccountMonth) Difference() money.Amount {
+	return m.Total - m.LinkedTotal
+}
+
+// AccountMonthsGet retrieves the donation totals and linked donation totals for each
+// donation account code and month for invoices and bank transactions dated between
+// dateFrom and dateTo, ordered by account code and month.
+func (db *DB) AccountMonthsGet(ctx context.Context, dateFrom, dateTo time.Time) ([]AccountMonth, error) {
+
+	db.log.Info(fmt.Sprintf("AccountMonthsGet %s %s", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02")))
+
+	stmt := db.accountMonthsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("accountMonthsGet verify args error: %v", err))
+		return nil, fmt.Errorf("account months get verify arguments error: %w", err)
+	}
+
+	months, err := cachedQuery(db, "account months", namedArgs, func() ([]AccountMonth, error) {
+		var months []AccountMonth
+		err := stmt.SelectContext(ctx, &months, namedArgs)
+		db.logQuery(ctx, "account months", stmt, namedArgs, err)
+		return months, err
+	})
+	if err != nil {
+		db.log.Error(fmt.Sprintf("account months select error: %v", err))
+		return nil, fmt.Errorf("account months select error with named args %v: %w", namedArgs, err)
+	}
+	if len(months) == 0 {
+		db.log.Info("AccountMonthsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("AccountMonthsGet : retrieved %d records", len(months)))
+	return months, nil
+}
+
+// PayoutGroup is the donations of a payout reference with the invoices and bank
+// transactions sharing the reference, as returned by PayoutGroupsGet. The RecordTotal is
+// the donation line item total of the records. Date, formatted as "2006-01-02", is the
+// date of the first record, or the last close date of the donations if there are no
+// records. Matched is set if the totals agree within the reconciliation tolerance.
+type PayoutGroup struct {
+	Reference     string       `db:"reference"`
+	Date          string       `db:"date"`
+	DonationCount int          `db:"donation_count"`
+	DonationTotal money.Amount `db:"donation_total"`
+	RecordCount   int          `db:"record_count"`
+	RecordTotal   money.Amount `db:"record_total"`
+	Matched       bool         `db:"is_matched"`
+}
+
+// Status reports if the payout reference is only found in Salesforce ("salesforce"),
+// only found in Xero ("xero"), found in both with differing totals ("differs") or
+// matched ("matched").
+func (p PayoutGroup) Status() string {
+	switch {
+	case p.RecordCount == 0:
+		return "salesforce"
+	case p.DonationCount == 0:
+		return "xero"
+	case !p.Matched:
+		return "differs"
+	}
+	return "matched"
+}
+
+// Difference returns the record total less the donation total.
+func (p PayoutGroup) Difference() money.Amount {
+	return p.RecordTotal - p.DonationTotal
+}
+
+// PayoutGroupsGet retrieves the donations grouped by payout reference, with the
+// invoices and bank transactions sharing each reference, for the references with
+// donations closing or records dated between dateFrom and dateTo.
+func (db *DB) PayoutGroupsGet(ctx context.Context, dateFrom, dateTo time.Time) ([]PayoutGroup, error) {
+
+	db.log.Info(fmt.Sprintf("PayoutGroupsGet %s %s", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02")))
+
+	stmt := db.payoutGroupsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": db.donationAccountCodes(),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("payoutGroupsGet verify args error: %v", err))
+		return nil, fmt.Errorf("payout groups get verify arguments error: %w", err)
+	}
+
+	groups, err := cachedQuery(db, "payout groups", namedArgs, func() ([]PayoutGroup, error) {
+		var groups []PayoutGroup
+		err := stmt.SelectContext(ctx, &groups, namedArgs)
+		db.logQuery(ctx, "payout groups", stmt, namedArgs, err)
+		return groups, err
+	})
+	if err != nil {
+		db.log.Error(fmt.Sprintf("payout groups select error: %v", err))
+		return nil, fmt.Errorf("payout groups select error with named args %v: %w", namedArgs, err)
+	}
+	if len(groups) == 0 {
+		db.log.Info("PayoutGroupsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("PayoutGroupsGet : retrieved %d records", len(groups)))
+	return groups, nil
+}
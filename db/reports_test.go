@@ -0,0 +1,237 @@
+package db
+
+// tests for reporting queries
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestAccountTotalsGet tests retrieving the donation totals by account code.
+func TestAccountTotalsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		dateFrom time.Time
+		dateTo   time.Time
+		err      error
+		totals   []AccountTotal
+	}{
+		{
+			name:     "financial year",
+			dateFrom: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+			dateTo:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			totals: []AccountTotal{
+				{"5301", "Fundraising Dinners", 3, money.FromFloat(1450), money.FromFloat(200), money.FromFloat(1250)},
+				{"5501", "General Giving", 11, money.FromFloat(5165), money.FromFloat(200), money.FromFloat(4965)},
+				{"5701", "Spring Campaign 2025", 3, money.FromFloat(745), money.FromFloat(155), money.FromFloat(590)},
+			},
+		},
+		{
+			name:     "no records out of date range",
+			dateFrom: time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+			dateTo:   time.Date(2028, 3, 31, 0, 0, 0, 0, time.UTC),
+			err:      sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals, err := testDB.AccountTotalsGet(ctx, tt.dateFrom, tt.dateTo)
+			if err != tt.err {
+				t.Fatalf("got err %v want %v", err, tt.err)
+			}
+			if diff := cmp.Diff(tt.totals, totals); diff != "" {
+				t.Errorf("unexpected totals (-want +got):\n%s", diff)
+			}
+			for _, at := range totals {
+				if got, want := at.ReconciledTotal+at.UnreconciledTotal, at.Total; got != want {
+					t.Errorf("%s reconciled and unreconciled totals %.2f do not sum to %.2f", at.AccountCode, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestGiftAidDonationsGet tests retrieving the donations linked to Gift Aid income.
+func TestGiftAidDonationsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	donations, err := testDB.GiftAidDonationsGet(ctx, dateFrom, dateTo, "^(55)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(donations), 16; got != want {
+		t.Fatalf("got %d donations want %d", got, want)
+	}
+	first, last := donations[0], donations[len(donations)-1]
+	if got, want := first.ID, "sf-opp-001"; got != want {
+		t.Errorf("first donation got %s want %s", got, want)
+	}
+	if got, want := first.PayoutReference, "INV-2025-101"; got != want {
+		t.Errorf("first donation reference got %s want %s", got, want)
+	}
+	if got, want := last.ID, "sf-opp-odd-01"; got != want {
+		t.Errorf("last donation got %s want %s", got, want)
+	}
+
+	_, err = testDB.GiftAidDonationsGet(ctx, dateFrom, dateTo, "^(99)")
+	if err != sql.ErrNoRows {
+		t.Errorf("got err %v want %v", err, sql.ErrNoRows)
+	}
+}
+
+// TestAgingItemsGet tests retrieving unlinked donations and unreconciled invoices and
+// bank transactions with their ages.
+func TestAgingItemsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	asAt := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	items, err := testDB.AgingItemsGet(ctx, dateFrom, asAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(items), 18; got != want {
+		t.Fatalf("got %d items want %d", got, want)
+	}
+	first := items[0]
+	if first.ID != "inv-001" || first.AgeDays != 81 || first.Bucket != 2 || first.Amount != money.FromFloat(-50) {
+		t.Errorf("unexpected first item %+v", first)
+	}
+
+	buckets := map[int]int{}
+	var donationsTotal money.Amount
+	for _, i := range items {
+		buckets[i.Bucket]++
+		if i.ItemType == "donation" {
+			donationsTotal += i.Amount
+		}
+	}
+	if got, want := buckets, map[int]int{1: 4, 2: 14}; !maps.Equal(got, want) {
+		t.Errorf("buckets got %v want %v", got, want)
+	}
+	if got, want := donationsTotal, money.FromFloat(325); got != want {
+		t.Errorf("unlinked donations total got %s want %s", got, want)
+	}
+
+	_, err = testDB.AgingItemsGet(ctx, dateFrom, dateFrom.AddDate(0, 0, -1))
+	if err != sql.ErrNoRows {
+		t.Errorf("got err %v want %v", err, sql.ErrNoRows)
+	}
+}
+
+// TestAccountMonthsGet tests retrieving the donation and linked donation totals by
+// account code and month, which must sum to the account code totals.
+func TestAccountMonthsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	months, err := testDB.AccountMonthsGet(ctx, dateFrom, dateTo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []AccountMonth{
+		{"5301", "Fundraising Dinners", "2025-04", 3, money.FromFloat(1450), money.FromFloat(200), 1, money.FromFloat(200)},
+		{"5501", "General Giving", "2025-04", 8, money.FromFloat(2365), money.FromFloat(1000), 1, money.FromFloat(200)},
+		{"5501", "General Giving", "2025-05", 3, money.FromFloat(2800), 0, 0, 0},
+		{"5701", "Spring Campaign 2025", "2025-04", 2, money.FromFloat(405), money.FromFloat(155), 1, money.FromFloat(155)},
+		{"5701", "Spring Campaign 2025", "2025-05", 1, money.FromFloat(340), 0, 0, 0},
+	}
+	if diff := cmp.Diff(want, months); diff != "" {
+		t.Errorf("unexpected months (-want +got):\n%s", diff)
+	}
+
+	totals, err := testDB.AccountTotalsGet(ctx, dateFrom, dateTo)
+	if err != nil {
+		t.Fatalf("unexpected account totals error: %v", err)
+	}
+	sums := map[string]money.Amount{}
+	for _, m := range months {
+		sums[m.AccountCode] += m.Total
+	}
+	for _, at := range totals {
+		if got, want := sums[at.AccountCode], at.Total; got != want {
+			t.Errorf("%s monthly totals %s do not sum to %s", at.AccountCode, got, want)
+		}
+	}
+
+	_, err = testDB.AccountMonthsGet(ctx, dateTo.AddDate(1, 0, 0), dateTo.AddDate(2, 0, 0))
+	if err != sql.ErrNoRows {
+		t.Errorf("got err %v want %v", err, sql.ErrNoRows)
+	}
+}
+
+func TestPayoutGroupsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	groups, err := testDB.PayoutGroupsGet(ctx, dateFrom, dateTo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []PayoutGroup{
+		{"INV-2025-101", "2025-04-10", 2, money.FromFloat(550), 1, money.FromFloat(500), false},
+		{"INV-2025-102", "2025-04-12", 1, money.FromFloat(200), 1, money.FromFloat(200), true},
+	}
+	if diff := cmp.Diff(want, groups[:2]); diff != "" {
+		t.Errorf("unexpected groups (-want +got):\n%s", diff)
+	}
+
+	// Add a donation with a payout reference not found in Xero.
+	_, err = testDB.ExecContext(ctx, `INSERT INTO donations (id, name, amount, close_date, payout_reference_dfk)
+		VALUES ('sf-opp-sfonly', 'Salesforce only', 25, '2025-06-01', 'SF-ONLY-REF')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDB.InvalidateCache()
+	groups, err = testDB.PayoutGroupsGet(ctx, dateFrom, dateTo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	statuses := map[string]int{}
+	for _, g := range groups {
+		statuses[g.Status()]++
+		if g.Reference == "SF-ONLY-REF" && (g.Status() != "salesforce" || g.Difference() != -money.FromFloat(25)) {
+			t.Errorf("unexpected salesforce only group %+v", g)
+		}
+	}
+	if diff := cmp.Diff(map[string]int{"matched": 2, "differs": 2, "xero": 12, "salesforce": 1}, statuses); diff != "" {
+		t.Errorf("unexpected statuses (-want +got):\n%s", diff)
+	}
+
+	_, err = testDB.PayoutGroupsGet(ctx, dateTo.AddDate(1, 0, 0), dateTo.AddDate(2, 0, 0))
+	if err != sql.ErrNoRows {
+		t.Errorf("got err %v want %v", err, sql.ErrNoRows)
+	}
+}
@@ -0,0 +1,140 @@
+package db
+
+// rules.go deals with the account rules which classify invoice and bank transaction
+// line items by their description or contact, such as treating the line items of
+// "PayPal Giving Fund" bank transactions as account 5501. The rule's account code
+// replaces the Xero account code of a matching line item, so that the donation account
+// codes, the matcher and the reports treat the line item as the rule's account. Donations
+// have no account code and are not classified by the rules.
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AccountRule sets the account code of line items with a Field, either "description"
+// or "contact", matching the regular expression Pattern case insensitively. The first
+// matching rule by ID applies. LineItems is the number of line items the rule
+// classifies.
+type AccountRule struct {
+	ID          int64     `db:"id"`
+	Field       string    `db:"field"`
+	Pattern     string    `db:"pattern"`
+	AccountCode string    `db:"account_code"`
+	CreatedAt   time.Time `db:"created_at"`
+	LineItems   int       `db:"line_items"`
+}
+
+// AccountRulesGet retrieves the account rules in the order they are applied.
+func (db *DB) AccountRulesGet(ctx context.Context) ([]AccountRule, error) {
+
+	stmt := db.accountRulesGetStmt
+
+	namedArgs := map[string]any{
+		"ID": 0,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("account rules verify arguments error: %v", err))
+		return nil, fmt.Errorf("account rules verify arguments error: %w", err)
+	}
+
+	var rules []AccountRule
+	err := stmt.SelectContext(ctx, &rules, namedArgs)
+	db.logQuery(ctx, "account rules", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("account rules select error: %v", err))
+		return nil, fmt.Errorf("account rules select error: %w", err)
+	}
+	return rules, nil
+}
+
+// AccountRuleInsert adds a rule setting the account code of line items with a field
+// matching pattern, and applies the rules to the existing line items. An ErrValidation
+// is returned for an unknown field, an invalid pattern or an empty account code.
+func (db *DB) AccountRuleInsert(ctx context.Context, field, pattern, accountCode string) error {
+
+	pattern, accountCode = strings.TrimSpace(pattern), strings.TrimSpace(accountCode)
+	switch {
+	case field != "description" && field != "contact":
+		return ErrValidation{"rule field", fmt.Sprintf("%q is not description or contact", field)}
+	case pattern == "":
+		return ErrValidation{"rule pattern", "may not be empty"}
+	case accountCode == "":
+		return ErrValidation{"rule account code", "may not be empty"}
+	}
+	if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+		return ErrValidation{"rule pattern", fmt.Sprintf("is not a valid regular expression: %v", err)}
+	}
+	stmt := db.accountRuleInsertStmt
+
+	namedArgs := map[string]any{
+		"Field":       field,
+		"Pattern":     pattern,
+		"AccountCode": accountCode,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("account rule insert verify arguments error: %v", err))
+		return fmt.Errorf("account rule insert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert account rule: %v", err))
+		return fmt.Errorf("failed to insert account rule: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("added account rule %s %q as account %s", field, pattern, accountCode))
+	return db.AccountRulesApply(ctx)
+}
+
+// AccountRuleDelete deletes an account rule and applies the remaining rules to the
+// existing line items, restoring the Xero account code of line items no longer
+// matching a rule.
+func (db *DB) AccountRuleDelete(ctx context.Context, id int64) error {
+
+	stmt := db.accountRuleDeleteStmt
+
+	namedArgs := map[string]any{
+		"ID": id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("account rule delete verify arguments error: %v", err))
+		return fmt.Errorf("account rule delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete account rule %d: %v", id, err))
+		return fmt.Errorf("failed to delete account rule %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted account rule %d", id))
+	return db.AccountRulesApply(ctx)
+}
+
+// AccountRulesApply applies the account rules to the line items of all invoices and
+// bank transactions.
+func (db *DB) AccountRulesApply(ctx context.Context) error {
+	if err := db.accountRulesApply(ctx, db.accountRulesApplyInvoicesStmt, "InvoiceID", ""); err != nil {
+		return err
+	}
+	if err := db.accountRulesApply(ctx, db.accountRulesApplyBankTransactionsStmt, "BankTransactionID", ""); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
+}
+
+// accountRulesApply runs an account rules apply statement for the line items of the
+// record with id held in the argument named arg, or of all records if id is empty.
+func (db *DB) accountRulesApply(ctx context.Context, stmt *parameterizedStmt, arg, id string) error {
+
+	namedArgs := map[string]any{
+		arg: id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("account rules apply verify arguments error: %v", err))
+		return fmt.Errorf("account rules apply verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to apply account rules to %s %q: %v", arg, id, err))
+		return fmt.Errorf("failed to apply account rules to %s %q: %w", arg, id, err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package db
+
+// tests for the account rules
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestAccountRules(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	listed := func(t *testing.T) []string {
+		t.Helper()
+		invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{}, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, i := range invoices {
+			ids = append(ids, i.InvoiceID)
+		}
+		return ids
+	}
+	lineItemCode := func(t *testing.T, invoiceID string) string {
+		t.Helper()
+		_, lineItems, err := testDB.InvoiceWRGet(ctx, invoiceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lineItems) != 1 || lineItems[0].AccountCode == nil {
+			t.Fatalf("unexpected line items %+v", lineItems)
+		}
+		return *lineItems[0].AccountCode
+	}
+
+	// The arbitrary invoices have no donation account line items.
+	if ids := listed(t); slices.Contains(ids, "inv-arb-01") || slices.Contains(ids, "inv-arb-02") {
+		t.Fatalf("arbitrary invoices listed before the rules %v", ids)
+	}
+
+	invalid := []struct {
+		name        string
+		field       string
+		pattern     string
+		accountCode string
+	}{
+		{"bad field", "reference", "x", "5501"},
+		{"empty pattern", "contact", " ", "5501"},
+		{"bad pattern", "contact", "(unclosed", "5501"},
+		{"empty account code", "description", "x", ""},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testDB.AccountRuleInsert(ctx, tt.field, tt.pattern, tt.accountCode)
+			if _, ok := errors.AsType[ErrValidation](err); !ok {
+				t.Errorf("expected a validation error, got %v", err)
+			}
+		})
+	}
+
+	// The first matching rule applies, matching case insensitively.
+	if err := testDB.AccountRuleInsert(ctx, "description", "^another arbitrary", "5701"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.AccountRuleInsert(ctx, "contact", "future invoices", "5501"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lineItemCode(t, "inv-arb-01"), "5501"; got != want {
+		t.Errorf("inv-arb-01 account code got %s want %s", got, want)
+	}
+	if got, want := lineItemCode(t, "inv-arb-02"), "5701"; got != want {
+		t.Errorf("inv-arb-02 account code got %s want %s", got, want)
+	}
+	if ids := listed(t); !slices.Contains(ids, "inv-arb-02") {
+		t.Errorf("classified invoice not listed %v", ids)
+	}
+
+	rules, err := testDB.AccountRulesGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].LineItems != 1 || rules[1].LineItems != 1 {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+
+	// Upserted line items are classified.
+	invoices := []xero.Invoice{
+		{
+			Type:          "ACCREC",
+			InvoiceID:     "inv-rule-01",
+			InvoiceNumber: "INV-RULE-01",
+			Contact:       "Future Invoices Inc.",
+			Date:          xero.XeroDateTime{Time: time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC)},
+			Updated:       xero.XeroDateTime{Time: time.Now()},
+			Status:        "PAID",
+			Total:         money.FromFloat(3),
+			LineItems: []xero.LineItem{
+				{
+					Description: "A third entry",
+					UnitAmount:  money.FromFloat(3),
+					AccountCode: "9999",
+					LineItemID:  "inv-rule-01-a",
+					Quantity:    1,
+					LineAmount:  money.FromFloat(3),
+				},
+			},
+		},
+	}
+	if err := testDB.InvoicesUpsert(ctx, invoices); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lineItemCode(t, "inv-rule-01"), "5501"; got != want {
+		t.Errorf("upserted account code got %s want %s", got, want)
+	}
+
+	// Deleting a rule applies the next matching rule, and deleting the last rule
+	// restores the Xero account codes.
+	if err := testDB.AccountRuleDelete(ctx, rules[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := lineItemCode(t, "inv-arb-02"), "5501"; got != want {
+		t.Errorf("inv-arb-02 account code after delete got %s want %s", got, want)
+	}
+	if err := testDB.AccountRuleDelete(ctx, rules[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"inv-arb-01", "inv-arb-02", "inv-rule-01"} {
+		if got, want := lineItemCode(t, id), "9999"; got != want {
+			t.Errorf("%s account code after delete got %s want %s", id, got, want)
+		}
+	}
+	if ids := listed(t); slices.Contains(ids, "inv-arb-02") {
+		t.Errorf("unclassified invoice listed %v", ids)
+	}
+}
@@ -11,30 +11,39 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/internal/money"
 	"time"
 )
 
 // Donation is the concrete type of each row returned by
 // DonationsGet
 type Donation struct {
-	ID              string     `db:"id"`
-	Name            string     `db:"name"`
-	Amount          float64    `db:"amount"`
-	CloseDate       *time.Time `db:"close_date"`
-	PayoutReference *string    `db:"payout_reference_dfk"`
-	CreatedDate     *time.Time `db:"created_date"`
-	CreatedName     *string    `db:"created_by"`
-	ModifiedDate    *time.Time `db:"last_modified_date"`
-	ModifiedName    *string    `db:"last_modified_by"`
-	IsLinked        bool       `db:"is_linked"`
-	LinkID          string     `db:"link_id"`
-	LinkTyper       string     `db:"link_typer"`
-	RowCount        int        `db:"row_count"`
+	ID              string       `db:"id"`
+	Name            string       `db:"name"`
+	Amount          money.Amount `db:"amount"`
+	CloseDate       *time.Time   `db:"close_date"`
+	PayoutReference *string      `db:"payout_reference_dfk"`
+	CreatedDate     *time.Time   `db:"created_date"`
+	CreatedName     *string      `db:"created_by"`
+	ModifiedDate    *time.Time   `db:"last_modified_date"`
+	ModifiedName    *string      `db:"last_modified_by"`
+	IsLinked        bool         `db:"is_linked"`
+	LinkID          string       `db:"link_id"`
+	LinkTyper       string       `db:"link_typer"`
+	LinkedBy        string       `db:"linked_by"`
+	LinkedAt        *time.Time   `db:"linked_at"`
+	RemotePending   bool         `db:"remote_pending"` // a reference update is queued for Salesforce
+	Annotations     int          `db:"annotations"`    // the number of notes
+	Flagged         bool         `db:"flagged"`        // a note is a flag
+	AssignedTo      string       `db:"assigned_to"`    // the assignee, if any
+	RowCount        int          `db:"row_count"`
+	SumAmount       money.Amount `db:"sum_amount"` // total of the full filtered set
 }
 
 // DonationsGet retrieves donations from the database with the specified
-// filters.
-func (db *DB) DonationsGet(ctx context.Context, dateFrom, dateTo time.Time, linkageStatus, payoutReference, search string, limit, offset int) ([]Donation, error) {
+// filters, in the given sort order. Only the donations assigned to assignedTo are
+// retrieved if it is not empty.
+func (db *DB) DonationsGet(ctx context.Context, dateFrom, dateTo time.Time, linkageStatus, payoutReference, search, assignedTo string, sort SortOrder, limit, offset int) ([]Donation, error) {
 
 	db.log.Info(fmt.Sprintf("DonationsGet %s %s linkage %s <%s> %q", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02"), linkageStatus, payoutReference, search))
 
@@ -50,14 +59,21 @@ func (db *DB) DonationsGet(ctx context.Context, dateFrom, dateTo time.Time, link
 			linkageStatus,
 		)
 	}
+	if err := sort.Validate(); err != nil {
+		return nil, err
+	}
+	sortColumn, sortDirection := sort.args()
 
 	// Args uses sqlx's named query capability.
 	namedArgs := map[string]any{
-		"DateFrom":        dateFrom.Format("2006-01-02"),
-		"DateTo":          dateTo.Format("2006-01-02"),
+		"DateFrom":        sqlDate(dateFrom),
+		"DateTo":          sqlDate(dateTo),
 		"LinkageStatus":   linkageStatus,
 		"PayoutReference": payoutReference,
 		"TextSearch":      search,
+		"AssignedTo":      assignedTo,
+		"SortColumn":      sortColumn,
+		"SortDirection":   sortDirection,
 		"HereLimit":       limit,
 		"HereOffset":      offset,
 	}
@@ -67,9 +83,12 @@ func (db *DB) DonationsGet(ctx context.Context, dateFrom, dateTo time.Time, link
 	}
 
 	// Use sqlx to scan results into the provided slice.
-	var donations []Donation
-	err := stmt.SelectContext(ctx, &donations, namedArgs)
-	db.logQuery("donations", stmt, namedArgs, err)
+	donations, err := cachedQuery(db, "donations", namedArgs, func() ([]Donation, error) {
+		var donations []Donation
+		err := stmt.SelectContext(ctx, &donations, namedArgs)
+		db.logQuery(ctx, "donations", stmt, namedArgs, err)
+		return donations, err
+	})
 	if err != nil {
 		db.log.Error(fmt.Sprintf("donations select error with named args %v", err))
 		return nil, fmt.Errorf("donations select error with named args %v\nlook for colons in sql\nerror: %w", namedArgs, err)
@@ -90,13 +109,13 @@ func (db *DB) UpsertDonations(ctx context.Context, donations []salesforce.Donati
 		return nil
 	}
 
-	tx, err := db.Begin()
+	ctx, tx, err := db.beginTx(ctx)
 	if err != nil {
 		db.log.Error(fmt.Sprintf("upsertDonations: could not begin transaction: %v", err))
 		return fmt.Errorf("upsertDonations: could not begin transaction: %w", err)
 	}
 	defer func() {
-		_ = tx.Rollback() // no-op after commit.
+		_ = tx.rollback() // no-op after commit.
 	}()
 
 	stmt := db.donationUpsertStmt
@@ -119,11 +138,11 @@ func (db *DB) UpsertDonations(ctx context.Context, donations []salesforce.Donati
 			"ID":                   dnt.ID,
 			"Name":                 dnt.Name,
 			"Amount":               dnt.Amount,
-			"CloseDate":            dnt.CloseDate.Time,
+			"CloseDate":            sqlDate(dnt.CloseDate.Time),
 			"PayoutReference":      dnt.PayoutReference,
-			"CreatedDate":          dnt.CreatedDate.Time,
+			"CreatedDate":          dnt.CreatedDate.UTC(),
 			"CreatedBy":            dnt.CreatedBy,
-			"LastModifiedDate":     dnt.LastModifiedDate.Time,
+			"LastModifiedDate":     dnt.LastModifiedDate.UTC(),
 			"LastModifiedBy":       dnt.LastModifiedBy,
 			"AdditionalFieldsJSON": string(additionalFieldsJSON),
 		}
@@ -140,5 +159,152 @@ func (db *DB) UpsertDonations(ctx context.Context, donations []salesforce.Donati
 	}
 
 	db.log.Info(fmt.Sprintf("upsertDonations: upserted %d donations successfully", len(donations)))
-	return tx.Commit()
+	return tx.commit()
+}
+
+// DeleteDonations deletes the donations with the provided ids, returning the number of
+// donations deleted.
+func (db *DB) DeleteDonations(ctx context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("deleteDonations: could not begin transaction: %v", err))
+		return 0, fmt.Errorf("deleteDonations: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	stmt := db.donationDeleteStmt
+
+	var deleted int
+	for _, id := range ids {
+		namedArgs := map[string]any{
+			"ID": id,
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("deleteDonations verify arguments err: %v", err))
+			return 0, fmt.Errorf("deleteDonations verify arguments error: %w", err)
+		}
+		result, err := stmt.ExecContext(ctx, namedArgs)
+		if err != nil {
+			db.log.Error(fmt.Sprintf("deleteDonations: failed to delete donation %s: %v", id, err))
+			return 0, fmt.Errorf("deleteDonations: failed to delete donation %s: %w", id, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			deleted += int(n)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("deleteDonations: deleted %d donations", deleted))
+	return deleted, tx.commit()
+}
+
+// ImportDonations upserts donations imported from a CRM other than Salesforce, tagging
+// them with source. It returns the number of donations upserted, which excludes the
+// donations whose ids are held by donations of another source.
+func (db *DB) ImportDonations(ctx context.Context, source string, donations []salesforce.Donation) (int, error) {
+	if len(donations) == 0 {
+		return 0, nil
+	}
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("importDonations: could not begin transaction: %v", err))
+		return 0, fmt.Errorf("importDonations: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	stmt := db.donationImportUpsertStmt
+
+	var upserted int
+	for _, dnt := range donations {
+		additionalFieldsJSON, err := json.Marshal(dnt.AdditionalFields)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal additional fields for donation %s: %w", dnt.ID, err)
+		}
+
+		namedArgs := map[string]any{
+			"ID":                   dnt.ID,
+			"Name":                 dnt.Name,
+			"Amount":               dnt.Amount,
+			"CloseDate":            sqlDate(dnt.CloseDate.Time),
+			"PayoutReference":      dnt.PayoutReference,
+			"CreatedDate":          dnt.CreatedDate.UTC(),
+			"CreatedBy":            dnt.CreatedBy,
+			"LastModifiedDate":     dnt.LastModifiedDate.UTC(),
+			"LastModifiedBy":       dnt.LastModifiedBy,
+			"AdditionalFieldsJSON": string(additionalFieldsJSON),
+			"Source":               source,
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("importDonations verify arguments err: %v", err))
+			return 0, fmt.Errorf("importDonations verify arguments error: %w", err)
+		}
+		result, err := stmt.ExecContext(ctx, namedArgs)
+		db.logQuery(ctx, "importDonations", stmt, namedArgs, err)
+		if err != nil {
+			db.log.Error(fmt.Sprintf("importDonations: failed to upsert donation %s: %v", dnt.ID, err))
+			return 0, fmt.Errorf("importDonations: failed to upsert donation %s: %w", dnt.ID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			upserted += int(n)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("importDonations: upserted %d of %d %s donations", upserted, len(donations), source))
+	return upserted, tx.commit()
+}
+
+// SalesforceInstanceURLUpsert records the Salesforce instance url, which is used for
+// deep links to Salesforce records.
+func (db *DB) SalesforceInstanceURLUpsert(ctx context.Context, instanceURL string) error {
+
+	stmt := db.sfInstanceUpsertStmt
+
+	namedArgs := map[string]any{
+		"ID":          1,
+		"InstanceURL": instanceURL,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("salesforce instance upsert verify arguments error: %v", err))
+		return fmt.Errorf("salesforce instance upsert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to upsert salesforce instance url: %v", err))
+		return fmt.Errorf("failed to upsert salesforce instance url: %w", err)
+	}
+	return nil
+}
+
+// SalesforceInstanceURLGet retrieves the Salesforce instance url, returning
+// sql.ErrNoRows if it has not been recorded.
+func (db *DB) SalesforceInstanceURLGet(ctx context.Context) (string, error) {
+
+	stmt := db.sfInstanceGetStmt
+
+	namedArgs := map[string]any{
+		"ID": 1,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("salesforce instance verify arguments error: %v", err))
+		return "", fmt.Errorf("salesforce instance verify arguments error: %w", err)
+	}
+
+	var urls []string
+	err := stmt.SelectContext(ctx, &urls, namedArgs)
+	db.logQuery(ctx, "salesforce instance", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("salesforce instance select error: %v", err))
+		return "", fmt.Errorf("salesforce instance select error: %w", err)
+	}
+	if len(urls) == 0 || urls[0] == "" {
+		return "", sql.ErrNoRows
+	}
+	return urls[0], nil
 }
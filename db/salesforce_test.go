@@ -11,10 +11,14 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 // Test06 DonationsGet(ctx context.Context, dateFrom, dateTo time.Time, linkageStatus, payoutReference, search string, limit, offset int) ([]Donation, error)
 // Test09 UpsertDonations(ctx context.Context, donations []salesforce.Donation) error
+// Test10 SalesforceInstanceURLUpsert(ctx context.Context, instanceURL string) error
+// Test10 SalesforceInstanceURLGet(ctx context.Context) (string, error)
+// Test11 ImportDonations(ctx context.Context, source string, donations []salesforce.Donation) (int, error)
 
 // Test06_DonationsQuery tests searching the donation SQL records.
 func Test06_DonationsQuery(t *testing.T) {
@@ -50,7 +54,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-01",
 				Name:            "Data Entry Error Donation",
-				Amount:          50,
+				Amount:          money.FromFloat(50),
 				CloseDate:       ptrTime(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: ptrStr("INV-2025-101"),
 				CreatedDate:     nil,
@@ -61,6 +65,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				LinkID:          "inv-001",
 				LinkTyper:       "invoice",
 				RowCount:        21,
+				SumAmount:       money.FromFloat(1680),
 			},
 		},
 		{
@@ -87,7 +92,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-01",
 				Name:            "Data Entry Error Donation",
-				Amount:          50,
+				Amount:          money.FromFloat(50),
 				CloseDate:       ptrTime(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: ptrStr("INV-2025-101"),
 				CreatedDate:     nil,
@@ -98,6 +103,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				LinkID:          "inv-001",
 				LinkTyper:       "invoice",
 				RowCount:        17,
+				SumAmount:       money.FromFloat(1355),
 			},
 		},
 		{
@@ -113,7 +119,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-01",
 				Name:            "Data Entry Error Donation",
-				Amount:          50,
+				Amount:          money.FromFloat(50),
 				CloseDate:       ptrTime(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: ptrStr("INV-2025-101"),
 				CreatedDate:     nil,
@@ -124,6 +130,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				LinkID:          "inv-001",
 				LinkTyper:       "invoice",
 				RowCount:        17, // for pagination
+				SumAmount:       money.FromFloat(1355),
 			},
 		},
 		{
@@ -139,7 +146,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-01",
 				Name:            "Data Entry Error Donation",
-				Amount:          50,
+				Amount:          money.FromFloat(50),
 				CloseDate:       ptrTime(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: ptrStr("INV-2025-101"),
 				CreatedDate:     nil,
@@ -150,6 +157,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				LinkID:          "inv-001",
 				LinkTyper:       "invoice",
 				RowCount:        1,
+				SumAmount:       money.FromFloat(50),
 			},
 		},
 		{
@@ -165,7 +173,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-02",
 				Name:            "Unlinked Donation",
-				Amount:          75,
+				Amount:          money.FromFloat(75),
 				CloseDate:       ptrTime(time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: nil,
 				CreatedDate:     nil,
@@ -174,6 +182,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				ModifiedName:    nil,
 				IsLinked:        false,
 				RowCount:        4,
+				SumAmount:       money.FromFloat(325),
 			},
 		},
 		{
@@ -189,7 +198,7 @@ func Test06_DonationsQuery(t *testing.T) {
 			lastRecord: Donation{
 				ID:              "sf-opp-odd-02",
 				Name:            "Unlinked Donation",
-				Amount:          75,
+				Amount:          money.FromFloat(75),
 				CloseDate:       ptrTime(time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)),
 				PayoutReference: nil,
 				CreatedDate:     nil,
@@ -198,6 +207,7 @@ func Test06_DonationsQuery(t *testing.T) {
 				ModifiedName:    nil,
 				IsLinked:        false,
 				RowCount:        1,
+				SumAmount:       money.FromFloat(75),
 			},
 		},
 	}
@@ -205,7 +215,7 @@ func Test06_DonationsQuery(t *testing.T) {
 	for ii, tt := range tests {
 		t.Run(fmt.Sprintf("%d_%s", ii, tt.name), func(t *testing.T) {
 
-			donations, err := testDB.DonationsGet(ctx, tt.dateFrom, tt.dateTo, tt.linkageStatus, tt.payoutReference, tt.searchString, tt.limit, tt.offset)
+			donations, err := testDB.DonationsGet(ctx, tt.dateFrom, tt.dateTo, tt.linkageStatus, tt.payoutReference, tt.searchString, "", SortOrder{}, tt.limit, tt.offset)
 			if err != nil {
 				if tt.err == nil {
 					t.Fatalf("got unexpected donations error: %v", err)
@@ -243,7 +253,7 @@ func Test09_UpsertDonations(t *testing.T) {
 			CoreFields: salesforce.CoreFields{
 				ID:               "fb8b156f",
 				Name:             "A test donation",
-				Amount:           0.99,
+				Amount:           money.FromFloat(0.99),
 				CloseDate:        salesforce.SalesforceDate{Time: time.Now().Add(48 * time.Hour)},
 				CreatedDate:      salesforce.SalesforceTime{Time: time.Now()},
 				LastModifiedDate: salesforce.SalesforceTime{Time: time.Now()},
@@ -260,7 +270,7 @@ func Test09_UpsertDonations(t *testing.T) {
 			CoreFields: salesforce.CoreFields{
 				ID:               "57144a9d",
 				Name:             "Another test donation",
-				Amount:           0.98,
+				Amount:           money.FromFloat(0.98),
 				CloseDate:        salesforce.SalesforceDate{Time: time.Now().Add(12 * time.Hour)},
 				CreatedDate:      salesforce.SalesforceTime{Time: time.Now()},
 				LastModifiedDate: salesforce.SalesforceTime{Time: time.Now()},
@@ -285,20 +295,94 @@ func Test09_UpsertDonations(t *testing.T) {
 		t.Fatalf("was unable to upsert donations for a second time: %v", err)
 	}
 
-	result, err := testDB.ExecContext(
-		ctx,
-		"DELETE FROM donations WHERE id IN (?, ?);",
-		"fb8b156f", "57144a9d",
-	)
+	rowNo, err := testDB.DeleteDonations(ctx, []string{"fb8b156f", "57144a9d", "not-a-donation"})
 	if err != nil {
 		t.Fatalf("unable to delete donation records: %v", err)
 	}
-	rowNo, err := result.RowsAffected()
+	if got, want := rowNo, 2; got != want {
+		t.Errorf("got %d deleted rows, want %d", got, want)
+	}
+
+}
+
+// Test10_SalesforceInstanceURL tests recording and retrieving the Salesforce instance
+// url.
+func Test10_SalesforceInstanceURL(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	if _, err := testDB.SalesforceInstanceURLGet(ctx); err != sql.ErrNoRows {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+
+	for _, u := range []string{"https://one.my.salesforce.com", "https://two.my.salesforce.com"} {
+		if err := testDB.SalesforceInstanceURLUpsert(ctx, u); err != nil {
+			t.Fatalf("unable to upsert instance url: %v", err)
+		}
+		got, err := testDB.SalesforceInstanceURLGet(ctx)
+		if err != nil {
+			t.Fatalf("unable to get instance url: %v", err)
+		}
+		if got != u {
+			t.Errorf("got instance url %q want %q", got, u)
+		}
+	}
+
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM system"); err != nil {
+		t.Fatalf("unable to delete system record: %v", err)
+	}
+}
+
+// Test11_ImportDonations tests upserting imported donations, which may not replace the
+// donations of another source and are not listed for the orphan check.
+func Test11_ImportDonations(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	donation := func(id string) salesforce.Donation {
+		return salesforce.Donation{
+			CoreFields: salesforce.CoreFields{
+				ID:               id,
+				Name:             "An imported donation",
+				Amount:           money.FromFloat(25),
+				CloseDate:        salesforce.SalesforceDate{Time: time.Date(2025, 4, 14, 0, 0, 0, 0, time.UTC)},
+				CreatedDate:      salesforce.SalesforceTime{Time: time.Now()},
+				LastModifiedDate: salesforce.SalesforceTime{Time: time.Now()},
+			},
+		}
+	}
+	donations := []salesforce.Donation{donation("crm-1001"), donation("sf-opp-001")}
+
+	for i := range 2 {
+		n, err := testDB.ImportDonations(ctx, "crm", donations)
+		if err != nil {
+			t.Fatalf("import %d error: %v", i, err)
+		}
+		if got, want := n, 1; got != want {
+			t.Errorf("import %d got %d upserted donations want %d", i, got, want)
+		}
+	}
+
+	var sources []string
+	err := testDB.SelectContext(ctx, &sources, "SELECT source FROM donations WHERE id IN ('crm-1001', 'sf-opp-001') ORDER BY id")
 	if err != nil {
-		t.Fatalf("could not get rows affected: %v", err)
+		t.Fatal(err)
 	}
-	if got, want := int(rowNo), 2; got != want {
-		t.Errorf("got %d deleted rows, want %d", got, want)
+	if diff := cmp.Diff([]string{"crm", "salesforce"}, sources); diff != "" {
+		t.Errorf("sources mismatch (-want +got):\n%s", diff)
 	}
 
+	ids, err := testDB.DonationIDsGet(ctx, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if id == "crm-1001" {
+			t.Error("imported donation listed for the orphan check")
+		}
+	}
 }
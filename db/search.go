@@ -0,0 +1,73 @@
+package db
+
+// search.go provides full-text search of the invoices, bank transactions and donations
+// using the sqlite FTS5 search_index table, which is maintained by triggers.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// SearchResult is an invoice, bank transaction or donation matching a full-text
+// search. RecordType is "invoice", "bank-transaction" or "donation". Amount is in the
+// base currency and a lower Rank is more relevant.
+type SearchResult struct {
+	RecordType string       `db:"record_type"`
+	RecordID   string       `db:"record_id"`
+	Reference  string       `db:"reference"`
+	Name       string       `db:"name"`
+	Date       time.Time    `db:"date"`
+	Amount     money.Amount `db:"amount"`
+	Snippet    string       `db:"snippet"`
+	Rank       float64      `db:"rank"`
+}
+
+// searchQuery converts free text into an FTS5 query matching records containing all
+// of the words, each as a prefix. Words are quoted so that FTS5 syntax characters in
+// the text are searched for rather than interpreted, and have their diacritics removed
+// in the same way as the indexed text.
+func searchQuery(text string) string {
+	words := strings.Fields(fold(text))
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"*`
+	}
+	return strings.Join(words, " ")
+}
+
+// Search retrieves up to limit invoices, bank transactions and donations matching all
+// of the words in text, most relevant first. sql.ErrNoRows is returned if there are no
+// matches.
+func (db *DB) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+
+	query := searchQuery(text)
+	if query == "" {
+		return nil, sql.ErrNoRows
+	}
+	stmt := db.searchStmt
+
+	namedArgs := map[string]any{
+		"Query":     query,
+		"HereLimit": limit,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("search verify arguments error: %v", err))
+		return nil, fmt.Errorf("search verify arguments error: %w", err)
+	}
+
+	var results []SearchResult
+	err := stmt.SelectContext(ctx, &results, namedArgs)
+	db.logQuery(ctx, "search", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("search select error: %v", err))
+		return nil, fmt.Errorf("search select error: %w", err)
+	}
+	if len(results) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return results, nil
+}
@@ -0,0 +1,122 @@
+package db
+
+// tests for full-text search
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSearchQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"  spring  campaign ", `"spring"* "campaign"*`},
+		{`jg-payout "q1`, `"jg-payout"* """q1"*`},
+		{"NOT OR", `"NOT"* "OR"*`},
+		{"Müller Søren", `"Muller"* "Soren"*`},
+	}
+	for _, tt := range tests {
+		if got := searchQuery(tt.text); got != tt.want {
+			t.Errorf("searchQuery(%q) got %q want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	ids := func(text string) []string {
+		t.Helper()
+		results, err := testDB.Search(ctx, text, 50)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, r := range results {
+			ids = append(ids, r.RecordType+" "+r.RecordID)
+		}
+		return ids
+	}
+
+	// Line item descriptions, which the listing searches do not cover.
+	if diff := cmp.Diff([]string{"bank-transaction bt-001"}, ids("spring camp")); diff != "" {
+		t.Errorf("description search mismatch (-want +got):\n%s", diff)
+	}
+
+	// Invoice numbers, payout references, contact names and donation names.
+	if diff := cmp.Diff(
+		[]string{"donation sf-opp-001", "donation sf-opp-odd-01", "invoice inv-001"},
+		ids("INV-2025-101"),
+		cmpopts.SortSlices(func(a, b string) bool { return a < b }),
+	); diff != "" {
+		t.Errorf("reference search mismatch (-want +got):\n%s", diff)
+	}
+	if got := ids("example corp"); len(got) < 2 {
+		t.Errorf("name search got %v", got)
+	}
+	if got := ids("no-such-words"); got != nil {
+		t.Errorf("expected no results, got %v", got)
+	}
+
+	// Contact names match ignoring case and diacritics, in both the index and the
+	// listing text search.
+	if _, err := testDB.ExecContext(ctx, "UPDATE invoices SET contact = 'Jørgen Müller' WHERE id = 'inv-001'"); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"muller", "MÜLLER jorgen", "Jörgen"} {
+		if diff := cmp.Diff([]string{"invoice inv-001"}, ids(text)); diff != "" {
+			t.Errorf("%q search mismatch (-want +got):\n%s", text, diff)
+		}
+	}
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	for _, text := range []string{"jorgen muller", "JØRGEN MÜ"} {
+		invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, text, "", SortOrder{}, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(invoices) != 1 || invoices[0].InvoiceID != "inv-001" {
+			t.Errorf("%q listing search got %d invoices", text, len(invoices))
+		}
+	}
+
+	// The index follows edits of the indexed records.
+	if _, err := testDB.ExecContext(ctx, "UPDATE invoice_line_items SET description = 'Winter appeal' WHERE id = 'inv-li-001'"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"invoice inv-001"}, ids("winter")); diff != "" {
+		t.Errorf("edited description search mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM invoice_line_items WHERE id = 'inv-li-001'"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("Q1 2025"); len(got) != 1 || got[0] != "donation sf-opp-001" {
+		t.Errorf("search after deleting line item got %v", got)
+	}
+	if _, err := testDB.ExecContext(ctx, "UPDATE donations SET name = 'Renamed Gift' WHERE id = 'sf-opp-001'"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"donation sf-opp-001"}, ids("renamed")); diff != "" {
+		t.Errorf("renamed donation search mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM donations WHERE id = 'sf-opp-001'"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("renamed"); got != nil {
+		t.Errorf("expected no results after delete, got %v", got)
+	}
+}
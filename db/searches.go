@@ -0,0 +1,122 @@
+package db
+
+// searches.go deals with the saved searches, or filter presets, of the invoices, bank
+// transactions and donations listing pages.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SavedSearchPages are the listing pages which may have saved searches.
+var SavedSearchPages = []string{"invoices", "bank-transactions", "donations"}
+
+// ErrInvalidSavedSearch reports a saved search which could not be recorded because it
+// has no name or is not for one of the SavedSearchPages.
+var ErrInvalidSavedSearch = errors.New("invalid saved search")
+
+// SavedSearch is a named set of filters for a listing page, held as url query
+// parameters. The default saved search of a page is used when the page is visited
+// without filters.
+type SavedSearch struct {
+	ID        int64  `db:"id"`
+	Page      string `db:"page"`
+	Name      string `db:"name"`
+	Params    string `db:"params"`
+	IsDefault bool   `db:"is_default"`
+}
+
+// SavedSearchesGet retrieves the saved searches for a listing page, with the default
+// first.
+func (db *DB) SavedSearchesGet(ctx context.Context, page string) ([]SavedSearch, error) {
+
+	stmt := db.savedSearchesGetStmt
+
+	namedArgs := map[string]any{
+		"Page": page,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("saved searches verify arguments error: %v", err))
+		return nil, fmt.Errorf("saved searches verify arguments error: %w", err)
+	}
+
+	var searches []SavedSearch
+	err := stmt.SelectContext(ctx, &searches, namedArgs)
+	db.logQuery(ctx, "saved searches", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("saved searches select error: %v", err))
+		return nil, fmt.Errorf("saved searches select error: %w", err)
+	}
+	return searches, nil
+}
+
+// SavedSearchUpsert records a saved search for a listing page, replacing the params of
+// any saved search of the same name. If isDefault is true the saved search becomes the
+// only default for the page. ErrInvalidSavedSearch is returned if the name is empty or
+// the page may not have saved searches.
+func (db *DB) SavedSearchUpsert(ctx context.Context, page, name, params string, isDefault bool) error {
+
+	name = strings.TrimSpace(name)
+	if name == "" || !slices.Contains(SavedSearchPages, page) {
+		return fmt.Errorf("%w: %q for page %q", ErrInvalidSavedSearch, name, page)
+	}
+
+	stmt := db.savedSearchUpsertStmt
+
+	namedArgs := map[string]any{
+		"Page":      page,
+		"Name":      name,
+		"Params":    params,
+		"IsDefault": isDefault,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("saved search upsert verify arguments error: %v", err))
+		return fmt.Errorf("saved search upsert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to upsert saved search: %v", err))
+		return fmt.Errorf("failed to upsert saved search: %w", err)
+	}
+
+	if isDefault {
+		stmt := db.savedSearchDefaultStmt
+		namedArgs := map[string]any{
+			"Page": page,
+			"Name": name,
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("saved search default verify arguments error: %v", err))
+			return fmt.Errorf("saved search default verify arguments error: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to set default saved search: %v", err))
+			return fmt.Errorf("failed to set default saved search: %w", err)
+		}
+	}
+	db.log.Info(fmt.Sprintf("saved search %q recorded for %s", name, page))
+	return nil
+}
+
+// SavedSearchDelete deletes a saved search from a listing page.
+func (db *DB) SavedSearchDelete(ctx context.Context, page string, id int64) error {
+
+	stmt := db.savedSearchDeleteStmt
+
+	namedArgs := map[string]any{
+		"Page": page,
+		"ID":   id,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("saved search delete verify arguments error: %v", err))
+		return fmt.Errorf("saved search delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete saved search %d: %v", id, err))
+		return fmt.Errorf("failed to delete saved search %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted saved search %d from %s", id, page))
+	return nil
+}
@@ -0,0 +1,74 @@
+package db
+
+// tests for saved searches
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSavedSearches(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	upsert := func(page, name, params string, isDefault bool) {
+		t.Helper()
+		if err := testDB.SavedSearchUpsert(ctx, page, name, params, isDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upsert("invoices", "Unreconciled", "status=NotReconciled", true)
+	upsert("invoices", "All this year", "status=All&date-from=2025-04-01", false)
+	upsert("donations", "Unlinked", "status=NotLinked", true)
+	// Replacing the default clears the earlier default, and the params are updated.
+	upsert("invoices", "All this year", "status=All&date-from=2025-04-06", true)
+
+	for _, tt := range []struct {
+		name       string
+		page       string
+		searchName string
+	}{
+		{"no name", "invoices", " "},
+		{"bad page", "contacts", "Contacts"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testDB.SavedSearchUpsert(ctx, tt.page, tt.searchName, "status=All", false)
+			if !errors.Is(err, ErrInvalidSavedSearch) {
+				t.Errorf("expected ErrInvalidSavedSearch, got %v", err)
+			}
+		})
+	}
+
+	searches, err := testDB.SavedSearchesGet(ctx, "invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(searches), 2; got != want {
+		t.Fatalf("got %d saved searches want %d", got, want)
+	}
+	first, second := searches[0], searches[1]
+	if first.Name != "All this year" || !first.IsDefault || first.Params != "status=All&date-from=2025-04-06" {
+		t.Errorf("unexpected default saved search %+v", first)
+	}
+	if second.Name != "Unreconciled" || second.IsDefault {
+		t.Errorf("unexpected saved search %+v", second)
+	}
+
+	// Deleting requires the matching page.
+	if err := testDB.SavedSearchDelete(ctx, "donations", first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.SavedSearchDelete(ctx, "invoices", second.ID); err != nil {
+		t.Fatal(err)
+	}
+	searches, err = testDB.SavedSearchesGet(ctx, "invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(searches) != 1 || searches[0].ID != first.ID {
+		t.Errorf("unexpected saved searches after delete %+v", searches)
+	}
+}
@@ -0,0 +1,345 @@
+package db
+
+// seed.go generates fake but realistic Xero and Salesforce records for demonstrations,
+// load testing and front-end development, without connections to either platform.
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// SeedOptions are the options for SeedDemoData.
+type SeedOptions struct {
+	From, To time.Time // the date range of the records
+
+	// The number of records of each type to generate. Donations are first made for
+	// the linked invoices and bank transactions, and the rest are unlinked.
+	Invoices         int
+	BankTransactions int
+	Donations        int
+
+	// LinkedPercent is the percentage of invoices and bank transactions with linked
+	// donations.
+	LinkedPercent int
+
+	// AccountCodes are the donation account codes, which should be matched by the
+	// database account codes regular expression.
+	AccountCodes []string
+
+	// Seed seeds the random generator, so that the same options generate the same
+	// records.
+	Seed uint64
+}
+
+// SeedResult reports the number of records generated by SeedDemoData.
+type SeedResult struct {
+	Invoices         int
+	BankTransactions int
+	Donations        int
+	LinkedDonations  int
+}
+
+// seedOtherAccountCode is the account code of the income line items which are not
+// donations.
+const seedOtherAccountCode = "200"
+
+var (
+	seedFirstNames    = []string{"Amelia", "Oliver", "Isla", "George", "Ava", "Noah", "Mia", "Arthur", "Freya", "Leo", "Grace", "Oscar", "Priya", "Tomasz", "Aisha", "Kwame", "Siobhan", "Hamish"}
+	seedLastNames     = []string{"Smith", "Jones", "Taylor", "Brown", "Williams", "Patel", "Khan", "Evans", "Wilson", "Davies", "O'Brien", "Nowak", "Campbell", "Okafor", "Hughes", "Walker"}
+	seedOrganisations = []string{"Riverside Trust", "Hilltop Foundation", "Northgate Rotary Club", "St Mary's School PTA", "Greenfield Parish Council", "Harbour Lights Ltd", "Meadowbank Community Fund", "The Clarke Family Trust"}
+	seedPlatforms     = []string{"JUSTGIVING", "ENTHUSE", "PAYPAL", "STRIPE", "GOCARDLESS"}
+	seedDescriptions  = []string{"Donation", "Gift", "Sponsorship", "Appeal donation", "Event sponsorship", "Grant instalment"}
+)
+
+// seeder generates the seed records with a seeded random generator.
+type seeder struct {
+	rnd  *rand.Rand
+	opts SeedOptions
+}
+
+// uuid returns a random version 4 uuid.
+func (s *seeder) uuid() string {
+	hi, lo := s.rnd.Uint64(), s.rnd.Uint64()
+	hi = hi&^0xf000 | 0x4000     // version 4
+	lo = lo&^(0xc<<60) | 0x8<<60 // variant 10
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+}
+
+// salesforceID returns a random 18 character opportunity id.
+func (s *seeder) salesforceID() string {
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	b := []byte("006")
+	for len(b) < 18 {
+		b = append(b, chars[s.rnd.IntN(len(chars))])
+	}
+	return string(b)
+}
+
+// pick returns a random item of items.
+func pick[T any](s *seeder, items []T) T {
+	return items[s.rnd.IntN(len(items))]
+}
+
+// date returns a random date in the seed date range.
+func (s *seeder) date() time.Time {
+	days := int(s.opts.To.Sub(s.opts.From).Hours()/24) + 1
+	return s.opts.From.AddDate(0, 0, s.rnd.IntN(days))
+}
+
+// amount returns a random amount in whole pounds between lo and hi, usually a round
+// number as donations tend to be.
+func (s *seeder) amount(lo, hi int) money.Amount {
+	pounds := lo + s.rnd.IntN(hi-lo+1)
+	if s.rnd.IntN(4) > 0 {
+		pounds = max(lo, pounds/5*5)
+	}
+	return money.Amount(pounds * 100)
+}
+
+// split splits total into n positive parts, in whole pounds if possible.
+func (s *seeder) split(total money.Amount, n int) []money.Amount {
+	unit := money.Amount(1)
+	if total%100 == 0 && int(total/100) >= n {
+		unit = 100
+	}
+	units := int64(total / unit)
+	n = max(1, min(n, int(units)))
+	cuts := map[int64]bool{}
+	for len(cuts) < n-1 {
+		cuts[1+s.rnd.Int64N(units-1)] = true
+	}
+	var parts []money.Amount
+	var last int64
+	for _, c := range append(slices.Sorted(maps.Keys(cuts)), units) {
+		parts = append(parts, money.Amount(c-last)*unit)
+		last = c
+	}
+	return parts
+}
+
+// lineItems returns one to three donation line items, and sometimes a line item of
+// other income.
+func (s *seeder) lineItems(lo, hi int) ([]xero.LineItem, money.Amount) {
+	var items []xero.LineItem
+	var donations money.Amount
+	for range 1 + s.rnd.IntN(3) {
+		a := s.amount(lo, hi)
+		items = append(items, xero.LineItem{
+			LineItemID:  s.uuid(),
+			Description: pick(s, seedDescriptions),
+			AccountCode: pick(s, s.opts.AccountCodes),
+			Quantity:    1,
+			UnitAmount:  a,
+			LineAmount:  a,
+		})
+		donations += a
+	}
+	if s.rnd.IntN(5) == 0 {
+		a := s.amount(5, 60)
+		items = append(items, xero.LineItem{
+			LineItemID:  s.uuid(),
+			Description: "Merchandise",
+			AccountCode: seedOtherAccountCode,
+			Quantity:    1,
+			UnitAmount:  a,
+			LineAmount:  a,
+		})
+	}
+	return items, donations
+}
+
+// total returns the total of the line items.
+func total(items []xero.LineItem) money.Amount {
+	var t money.Amount
+	for _, li := range items {
+		t += li.LineAmount
+	}
+	return t
+}
+
+// donation returns an unlinked donation closing on closeDate.
+func (s *seeder) donation(amount money.Amount, closeDate time.Time) salesforce.Donation {
+	donor := pick(s, seedFirstNames) + " " + pick(s, seedLastNames)
+	created := closeDate.Add(time.Duration(9+s.rnd.IntN(8)) * time.Hour)
+	return salesforce.Donation{
+		CoreFields: salesforce.CoreFields{
+			ID:               s.salesforceID(),
+			Name:             fmt.Sprintf("%s %s", donor, closeDate.Format("2006-01-02")),
+			Amount:           amount,
+			CloseDate:        salesforce.SalesforceDate{Time: closeDate},
+			CreatedDate:      salesforce.SalesforceTime{Time: created},
+			LastModifiedDate: salesforce.SalesforceTime{Time: created},
+			CreatedBy:        "Demo User",
+			LastModifiedBy:   "Demo User",
+		},
+		AdditionalFields: map[string]any{},
+	}
+}
+
+// SeedDemoData generates invoices, bank transactions and donations with the options
+// and upserts them with a fake Xero organisation and its accounts and contacts. The
+// generated records are linked by payout reference as they would be in use, the
+// linked donations adding up to the donation totals of their records.
+func (db *DB) SeedDemoData(ctx context.Context, opts SeedOptions) (SeedResult, error) {
+
+	var result SeedResult
+	switch {
+	case opts.From.IsZero() || opts.To.Before(opts.From):
+		return result, ErrValidation{"seed dates", "the date range is invalid"}
+	case opts.Invoices < 0 || opts.BankTransactions < 0 || opts.Donations < 0:
+		return result, ErrValidation{"seed record numbers", "may not be negative"}
+	case opts.LinkedPercent < 0 || opts.LinkedPercent > 100:
+		return result, ErrValidation{"seed linked percent", "should be between 0 and 100"}
+	case len(opts.AccountCodes) == 0:
+		return result, ErrValidation{"seed account codes", "no donation account codes provided"}
+	}
+	s := &seeder{rnd: rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x5eed)), opts: opts}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	org := xero.Organisation{
+		Name:             "Demo Charity",
+		LegalName:        "Demo Charity CIO",
+		OrganisationType: "CHARITY",
+		Timezone:         "GMTSTANDARDTIME",
+		ShortCode:        "!demo1",
+		OrganisationID:   s.uuid(),
+	}
+	if err := db.OrganisationUpsert(ctx, org); err != nil {
+		return result, err
+	}
+
+	accounts := []xero.Account{{AccountID: s.uuid(), Code: seedOtherAccountCode, Name: "Sales", Type: "REVENUE", Status: "ACTIVE"}}
+	for _, code := range opts.AccountCodes {
+		accounts = append(accounts, xero.Account{AccountID: s.uuid(), Code: code, Name: "Donations " + code, Type: "REVENUE", Status: "ACTIVE"})
+	}
+	if err := db.AccountsUpsert(ctx, accounts); err != nil {
+		return result, err
+	}
+
+	var contacts, platforms []xero.Contact
+	for _, name := range seedPlatforms {
+		platforms = append(platforms, xero.Contact{ContactID: s.uuid(), ContactStatus: "ACTIVE", Name: name, IsSupplier: true})
+	}
+	for _, name := range seedOrganisations {
+		contacts = append(contacts, xero.Contact{ContactID: s.uuid(), ContactStatus: "ACTIVE", Name: name, IsCustomer: true})
+	}
+	for range 24 {
+		first, last := pick(s, seedFirstNames), pick(s, seedLastNames)
+		contacts = append(contacts, xero.Contact{
+			ContactID:     s.uuid(),
+			ContactStatus: "ACTIVE",
+			Name:          first + " " + last,
+			FirstName:     first,
+			LastName:      last,
+			IsCustomer:    true,
+		})
+	}
+	if err := db.ContactsUpsert(ctx, append(slices.Clone(contacts), platforms...)); err != nil {
+		return result, err
+	}
+
+	var donations []salesforce.Donation
+	// link adds donations adding up to amount with the reference, if there are
+	// donations left to generate.
+	link := func(reference string, amount money.Amount, date time.Time, parts int) {
+		if s.rnd.IntN(100) >= opts.LinkedPercent {
+			return
+		}
+		for _, a := range s.split(amount, parts) {
+			if len(donations) >= opts.Donations {
+				return
+			}
+			d := s.donation(a, date.AddDate(0, 0, -s.rnd.IntN(14)))
+			d.PayoutReference = &reference
+			donations = append(donations, d)
+			result.LinkedDonations++
+		}
+	}
+
+	var invoices []xero.Invoice
+	for i := range opts.Invoices {
+		contact := pick(s, contacts)
+		items, donationTotal := s.lineItems(10, 500)
+		date := s.date()
+		inv := xero.Invoice{
+			InvoiceID:     s.uuid(),
+			Type:          "ACCREC",
+			InvoiceNumber: fmt.Sprintf("INV-%05d", i+1),
+			Contact:       xero.FlattenedName(contact.Name),
+			ContactID:     contact.ContactID,
+			Date:          xero.XeroDateTime{Time: date},
+			Updated:       xero.XeroDateTime{Time: now},
+			Status:        "PAID",
+			Total:         total(items),
+			CurrencyCode:  "GBP",
+			CurrencyRate:  1,
+			LineItems:     items,
+		}
+		switch n := s.rnd.IntN(10); {
+		case n == 0:
+			inv.Status = "VOIDED"
+		case n < 3:
+			inv.Status = "AUTHORISED"
+		default:
+			inv.AmountPaid = inv.Total
+		}
+		invoices = append(invoices, inv)
+		if inv.Status != "VOIDED" {
+			link(inv.InvoiceNumber, donationTotal, date, 1+s.rnd.IntN(2))
+		}
+	}
+	if err := db.InvoicesUpsert(ctx, invoices); err != nil {
+		return result, err
+	}
+
+	bankAccountID := s.uuid()
+	var transactions []xero.BankTransaction
+	for range opts.BankTransactions {
+		platform := pick(s, platforms)
+		items, donationTotal := s.lineItems(50, 2500)
+		date := s.date()
+		tx := xero.BankTransaction{
+			BankTransactionID: s.uuid(),
+			Type:              "RECEIVE",
+			Reference:         fmt.Sprintf("%s-%s-%04d", platform.Name, date.Format("20060102"), s.rnd.IntN(10000)),
+			Date:              xero.XeroDateTime{Time: date},
+			Updated:           xero.XeroDateTime{Time: now},
+			Status:            "AUTHORISED",
+			Total:             total(items),
+			CurrencyCode:      "GBP",
+			CurrencyRate:      1,
+			IsReconciled:      s.rnd.IntN(4) > 0,
+			LineItems:         items,
+			Contact:           platform.Name,
+			ContactID:         platform.ContactID,
+			BankAccountID:     bankAccountID,
+			BankAccount:       "Demo Current Account",
+		}
+		transactions = append(transactions, tx)
+		link(tx.Reference, donationTotal, date, 2+s.rnd.IntN(8))
+	}
+	if err := db.BankTransactionsUpsert(ctx, transactions); err != nil {
+		return result, err
+	}
+
+	for len(donations) < opts.Donations {
+		donations = append(donations, s.donation(s.amount(5, 250), s.date()))
+	}
+	if err := db.UpsertDonations(ctx, donations); err != nil {
+		return result, err
+	}
+
+	result.Invoices = len(invoices)
+	result.BankTransactions = len(transactions)
+	result.Donations = len(donations)
+	db.log.Info(fmt.Sprintf("seeded %d invoices, %d bank transactions and %d donations", result.Invoices, result.BankTransactions, result.Donations))
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package db
+
+// tests for the generation of demonstration data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSeedDemoData(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	// The seed records are dated after the test data.
+	opts := SeedOptions{
+		From:             time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:               time.Date(2030, 7, 31, 0, 0, 0, 0, time.UTC),
+		Invoices:         40,
+		BankTransactions: 20,
+		Donations:        500,
+		LinkedPercent:    60,
+		AccountCodes:     []string{"5300", "5500"},
+		Seed:             1,
+	}
+	from, to := opts.From.AddDate(0, -1, 0), opts.To
+
+	if _, err := testDB.SeedDemoData(ctx, SeedOptions{From: opts.From, To: opts.To}); err == nil {
+		t.Error("expected an error without account codes")
+	}
+
+	result, err := testDB.SeedDemoData(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Invoices != opts.Invoices || result.BankTransactions != opts.BankTransactions || result.Donations != opts.Donations {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.LinkedDonations == 0 || result.LinkedDonations == result.Donations {
+		t.Errorf("unexpected linked donations %d of %d", result.LinkedDonations, result.Donations)
+	}
+
+	// Seeding again with the same seed generates the same records.
+	if _, err := testDB.SeedDemoData(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	invoices, err := testDB.InvoicesGet(ctx, "All", from, to, "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Voided invoices are not listed.
+	if got := len(invoices); got == 0 || got > opts.Invoices {
+		t.Errorf("invoices got %d want up to %d", got, opts.Invoices)
+	}
+	var linked int
+	for _, inv := range invoices {
+		if inv.CRMSTotal == 0 {
+			continue
+		}
+		linked++
+		if !inv.IsReconciled {
+			t.Errorf("linked invoice %s is not reconciled: donation total %s linked %s", inv.InvoiceNumber, inv.DonationTotal, inv.CRMSTotal)
+		}
+	}
+	if linked == 0 {
+		t.Error("no linked invoices")
+	}
+
+	transactions, err := testDB.BankTransactionsGet(ctx, "Reconciled", from, to, "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) == 0 {
+		t.Error("no reconciled bank transactions")
+	}
+
+	donations, err := testDB.DonationsGet(ctx, from, to, "Linked", "", "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(donations), result.LinkedDonations; got != want {
+		t.Errorf("linked donations got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+// snapshot.go provides the export of the database to a read-only snapshot file, and
+// the import of a snapshot to replace the database contents. Snapshots allow a backup
+// to be taken before a large bulk operation, or a copy of the data to be given to
+// support.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidSnapshot reports that a file is not a reconciler database snapshot.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// snapshotMode is the file mode of exported snapshots, which are read-only and may
+// contain personal data.
+const snapshotMode = 0o400
+
+// ExportSnapshot writes a consistent copy of the database to path, which must not
+// exist, using sqlite's VACUUM INTO.
+func (db *DB) ExportSnapshot(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot file %q already exists", path)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		db.log.Error(fmt.Sprintf("snapshot export to %q error: %v", path, err))
+		return fmt.Errorf("snapshot export error: %w", err)
+	}
+	if err := os.Chmod(path, snapshotMode); err != nil {
+		return fmt.Errorf("snapshot permissions error: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("exported snapshot to %q", path))
+	return nil
+}
+
+// ImportSnapshot replaces the contents of the database tables with those of the
+// snapshot at path, in a single transaction. The snapshot is opened read-only and
+// must contain each table of the database with at least its columns.
+func (db *DB) ImportSnapshot(ctx context.Context, path string) error {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("snapshot path error: %w", err)
+	}
+	// Attaching a missing file would create an empty database.
+	if _, err := os.Stat(abs); err != nil {
+		return fmt.Errorf("snapshot file error: %w", err)
+	}
+	uri := (&url.URL{Scheme: "file", Path: abs, RawQuery: "mode=ro"}).String()
+
+	// Attached databases are per-connection, so a single connection is used
+	// throughout.
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return fmt.Errorf("snapshot connection error: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS snapshot", uri); err != nil {
+		return fmt.Errorf("%w: could not attach %q: %v", ErrInvalidSnapshot, path, err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "DETACH DATABASE snapshot"); err != nil {
+			db.log.Error(fmt.Sprintf("snapshot detach error: %v", err))
+		}
+	}()
+
+	// Virtual tables, such as the search index, and their shadow tables are not copied
+	// as they are maintained by triggers on the copied tables.
+	const tablesQuery = "SELECT name FROM pragma_table_list WHERE schema = ? AND type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	var tables, snapshotTables []string
+	if err := conn.SelectContext(ctx, &tables, tablesQuery, "main"); err != nil {
+		return fmt.Errorf("snapshot tables error: %w", err)
+	}
+	if err := conn.SelectContext(ctx, &snapshotTables, tablesQuery, "snapshot"); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	for _, t := range tables {
+		if !slices.Contains(snapshotTables, t) {
+			return fmt.Errorf("%w: table %q is missing", ErrInvalidSnapshot, t)
+		}
+	}
+
+	rows, err := db.copySnapshotTables(ctx, conn, tables)
+	if err != nil {
+		return err
+	}
+	db.cache.invalidate()
+	db.log.Info(fmt.Sprintf("imported %d rows from snapshot %q", rows, path))
+
+	// The snapshot may select other donation accounts.
+	if err := db.loadDonationAccounts(ctx); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
+}
+
+// copySnapshotTables replaces the contents of tables with those of the attached
+// snapshot on conn, returning the number of rows copied.
+func (db *DB) copySnapshotTables(ctx context.Context, conn *sqlx.Conn, tables []string) (int64, error) {
+
+	// The import replaces every table, so the prepared statement writes wait for it.
+	unlock, err := db.writes.lock(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot lock error: %w", err)
+	}
+	defer unlock()
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot transaction error: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Foreign keys are checked on commit, so that tables can be loaded in any order.
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return 0, fmt.Errorf("snapshot foreign keys error: %w", err)
+	}
+	var rows int64
+	for _, t := range tables {
+		var columns []string
+		if err := tx.SelectContext(ctx, &columns, "SELECT name FROM pragma_table_info(?, 'main')", t); err != nil {
+			return 0, fmt.Errorf("snapshot columns error for %q: %w", t, err)
+		}
+		var cols string
+		for i, c := range columns {
+			if i > 0 {
+				cols += ", "
+			}
+			cols += fmt.Sprintf("%q", c)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM main.%q", t)); err != nil {
+			return 0, fmt.Errorf("snapshot delete error for %q: %w", t, err)
+		}
+		result, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO main.%q (%s) SELECT %s FROM snapshot.%q", t, cols, cols, t))
+		if err != nil {
+			return 0, fmt.Errorf("%w: could not copy table %q: %v", ErrInvalidSnapshot, t, err)
+		}
+		n, _ := result.RowsAffected()
+		rows += n
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("snapshot commit error: %w", err)
+	}
+	return rows, nil
+}
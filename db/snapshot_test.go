@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotExportImport(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := testDB.GetContext(ctx, &n, "SELECT count(*) FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	invoices, donations := count("invoices"), count("donations")
+	if invoices == 0 || donations == 0 {
+		t.Fatal("expected test data")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.db")
+	if err := testDB.ExportSnapshot(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode().Perm(), os.FileMode(snapshotMode); got != want {
+		t.Errorf("snapshot mode got %s want %s", got, want)
+	}
+	if err := testDB.ExportSnapshot(ctx, path); err == nil {
+		t.Error("expected an error exporting to an existing file")
+	}
+
+	// Changes made after the export are reverted by the import.
+	if _, err := testDB.ExecContext(ctx, "DELETE FROM donations"); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.ImportSnapshot(ctx, path); err != nil {
+		t.Fatalf("import error: %v", err)
+	}
+	if got, want := count("invoices"), invoices; got != want {
+		t.Errorf("invoices got %d want %d", got, want)
+	}
+	if got, want := count("donations"), donations; got != want {
+		t.Errorf("donations got %d want %d", got, want)
+	}
+	// The search index is rebuilt by its triggers.
+	if got, want := count("search_index"), invoices+count("bank_transactions")+donations; got != want {
+		t.Errorf("search index rows got %d want %d", got, want)
+	}
+
+	// Files which are not snapshots are rejected, leaving the data unchanged.
+	notSnapshot := filepath.Join(dir, "not-a-snapshot.db")
+	if err := os.WriteFile(notSnapshot, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.ImportSnapshot(ctx, notSnapshot); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("got error %v want ErrInvalidSnapshot", err)
+	}
+	if err := testDB.ImportSnapshot(ctx, filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("expected an error importing a missing file")
+	}
+	if got, want := count("donations"), donations; got != want {
+		t.Errorf("donations after failed import got %d want %d", got, want)
+	}
+}
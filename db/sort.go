@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+)
+
+// SortColumns are the columns by which the invoice, bank transaction and donation
+// listings may be sorted. For donations "contact" is the donation name and "status"
+// whether the donation is linked.
+var SortColumns = []string{"date", "amount", "contact", "status"}
+
+// SortDirections are the directions in which the listings may be sorted.
+var SortDirections = []string{"asc", "desc"}
+
+// SortOrder is the column and direction by which a listing is sorted. Empty fields
+// sort by date in ascending order.
+type SortOrder struct {
+	Column    string
+	Direction string
+}
+
+// Validate checks the column and direction against SortColumns and SortDirections.
+func (s SortOrder) Validate() error {
+	if s.Column != "" && !slices.Contains(SortColumns, s.Column) {
+		return ErrValidation{"sort column", fmt.Sprintf("must be one of %v, got %q", SortColumns, s.Column)}
+	}
+	if s.Direction != "" && !slices.Contains(SortDirections, s.Direction) {
+		return ErrValidation{"sort direction", fmt.Sprintf("must be one of %v, got %q", SortDirections, s.Direction)}
+	}
+	return nil
+}
+
+// args returns the SortColumn and SortDirection statement arguments, with the
+// defaults for empty fields.
+func (s SortOrder) args() (column, direction string) {
+	column, direction = s.Column, s.Direction
+	if column == "" {
+		column = SortColumns[0]
+	}
+	if direction == "" {
+		direction = SortDirections[0]
+	}
+	return column, direction
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortOrderValidate(t *testing.T) {
+	tests := []struct {
+		sort    SortOrder
+		wantErr bool
+	}{
+		{SortOrder{}, false},
+		{SortOrder{Column: "amount", Direction: "desc"}, false},
+		{SortOrder{Column: "contact"}, false},
+		{SortOrder{Column: "total; DROP TABLE invoices"}, true},
+		{SortOrder{Column: "date", Direction: "sideways"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.sort.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: got error %v want error %t", tt.sort, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListingsSort(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	dateFrom, dateTo := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	invoices, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{"amount", "desc"}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(invoices, func(a, b Invoice) int { return int(b.BaseTotal - a.BaseTotal) }) {
+		t.Error("invoices are not sorted by amount descending")
+	}
+
+	transactions, err := testDB.BankTransactionsGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{"contact", "asc"}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(transactions, func(a, b BankTransaction) int {
+		return strings.Compare(strings.ToLower(a.Contact), strings.ToLower(b.Contact))
+	}) {
+		t.Error("bank transactions are not sorted by contact")
+	}
+
+	donations, err := testDB.DonationsGet(ctx, dateFrom, dateTo, "All", "", "", "", SortOrder{"date", "desc"}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSortedFunc(donations, func(a, b Donation) int { return b.CloseDate.Compare(*a.CloseDate) }) {
+		t.Error("donations are not sorted by date descending")
+	}
+
+	if _, err := testDB.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", SortOrder{Column: "id"}, -1, 0); err == nil {
+		t.Error("expected an error for an invalid sort column")
+	}
+}
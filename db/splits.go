@@ -0,0 +1,112 @@
+package db
+
+// splits.go deals with donations split across several invoices or bank transactions.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// ErrInvalidSplit reports a donation split which could not be recorded because the
+// donation or record does not exist, the amount is not positive, or the amount would
+// take the total allocated from the donation above the donation amount.
+var ErrInvalidSplit = errors.New("invalid donation split")
+
+// DonationSplit is part of a donation allocated to an invoice or bank transaction,
+// being a donation link with an amount. Allocated is the total allocated from the
+// donation across all of its links.
+type DonationSplit struct {
+	ID             int64        `db:"id"`
+	DonationID     string       `db:"donation_id"`
+	DonationName   string       `db:"donation_name"`
+	DonationAmount money.Amount `db:"donation_amount"`
+	RecordType     string       `db:"record_type"`
+	RecordID       string       `db:"record_id"`
+	Amount         money.Amount `db:"amount"`
+	Allocated      money.Amount `db:"allocated"`
+	LinkedBy       string       `db:"linked_by"`
+	LinkedAt       time.Time    `db:"linked_at"`
+}
+
+// DonationSplitsGet retrieves the donation splits allocated to an invoice or bank
+// transaction. The recordType is either "invoice" or "bank-transaction".
+func (db *DB) DonationSplitsGet(ctx context.Context, recordType, recordID string) ([]DonationSplit, error) {
+
+	stmt := db.donationSplitsGetStmt
+
+	namedArgs := map[string]any{
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation splits verify arguments error: %v", err))
+		return nil, fmt.Errorf("donation splits verify arguments error: %w", err)
+	}
+
+	var splits []DonationSplit
+	err := stmt.SelectContext(ctx, &splits, namedArgs)
+	db.logQuery(ctx, "donation splits", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("donation splits select error: %v", err))
+		return nil, fmt.Errorf("donation splits select error: %w", err)
+	}
+	return splits, nil
+}
+
+// DonationSplitUpsert allocates amount of a donation to an invoice or bank
+// transaction, replacing any existing allocation of the donation to that record.
+// ErrInvalidSplit is returned if the split could not be recorded.
+func (db *DB) DonationSplitUpsert(ctx context.Context, donationID, recordType, recordID string, amount money.Amount) error {
+
+	if amount <= 0 {
+		return fmt.Errorf("%w: amount %s is not positive", ErrInvalidSplit, amount)
+	}
+	stmt := db.donationSplitUpsertStmt
+
+	namedArgs := map[string]any{
+		"DonationID": donationID,
+		"RecordType": recordType,
+		"RecordID":   recordID,
+		"Amount":     amount,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation split upsert verify arguments error: %v", err))
+		return fmt.Errorf("donation split upsert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to upsert donation split: %v", err))
+		return fmt.Errorf("failed to upsert donation split: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s of donation %s to %s %s", ErrInvalidSplit, amount, donationID, recordType, recordID)
+	}
+	db.log.Info(fmt.Sprintf("donation %s split %s to %s %s", donationID, amount, recordType, recordID))
+	return nil
+}
+
+// DonationSplitDelete deletes a donation split from an invoice or bank transaction.
+func (db *DB) DonationSplitDelete(ctx context.Context, id int64, recordType, recordID string) error {
+
+	stmt := db.donationSplitDeleteStmt
+
+	namedArgs := map[string]any{
+		"ID":         id,
+		"RecordType": recordType,
+		"RecordID":   recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("donation split delete verify arguments error: %v", err))
+		return fmt.Errorf("donation split delete verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to delete donation split %d: %v", id, err))
+		return fmt.Errorf("failed to delete donation split %d: %w", id, err)
+	}
+	db.log.Info(fmt.Sprintf("deleted donation split %d from %s %s", id, recordType, recordID))
+	return nil
+}
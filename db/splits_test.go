@@ -0,0 +1,104 @@
+package db
+
+// tests for donation splits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestDonationSplits(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	// sf-opp-017 is an unlinked donation of 150.00, split between inv-unrec-04 with a
+	// donation total of 50.00 and inv-unrec-02 with a donation total of 250.00.
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-017", "invoice", "inv-unrec-04", money.FromFloat(50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-017", "invoice", "inv-unrec-02", money.FromFloat(90)); err != nil {
+		t.Fatal(err)
+	}
+	// Replace the allocation to inv-unrec-02.
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-017", "invoice", "inv-unrec-02", money.FromFloat(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []struct {
+		name       string
+		donationID string
+		recordType string
+		recordID   string
+		amount     float64
+	}{
+		{"over allocated", "sf-opp-017", "bank-transaction", "bt-001", 0.01},
+		{"zero amount", "sf-opp-018", "invoice", "inv-unrec-02", 0},
+		{"no donation", "sf-opp-none", "invoice", "inv-unrec-02", 1},
+		{"no record", "sf-opp-018", "invoice", "inv-none", 1},
+		{"bad record type", "sf-opp-018", "contact", "inv-unrec-02", 1},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testDB.DonationSplitUpsert(ctx, tt.donationID, tt.recordType, tt.recordID, money.FromFloat(tt.amount))
+			if !errors.Is(err, ErrInvalidSplit) {
+				t.Errorf("expected ErrInvalidSplit, got %v", err)
+			}
+		})
+	}
+
+	splits, err := testDB.DonationSplitsGet(ctx, "invoice", "inv-unrec-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(splits), 1; got != want {
+		t.Fatalf("got %d splits want %d", got, want)
+	}
+	split := splits[0]
+	if split.DonationID != "sf-opp-017" || split.Amount != money.FromFloat(100) || split.Allocated != money.FromFloat(150) || split.DonationAmount != money.FromFloat(150) {
+		t.Errorf("unexpected split %+v", split)
+	}
+
+	invoice, _, err := testDB.InvoiceWRGet(ctx, "inv-unrec-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !invoice.IsReconciled || invoice.CRMSTotal != money.FromFloat(50) {
+		t.Errorf("inv-unrec-04 got reconciled %t crms total %s", invoice.IsReconciled, invoice.CRMSTotal)
+	}
+	invoice, _, err = testDB.InvoiceWRGet(ctx, "inv-unrec-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.IsReconciled || invoice.CRMSTotal != money.FromFloat(100) {
+		t.Errorf("inv-unrec-02 got reconciled %t crms total %s", invoice.IsReconciled, invoice.CRMSTotal)
+	}
+
+	// The split donation is linked.
+	dateFrom, dateTo := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	donations, err := testDB.DonationsGet(ctx, dateFrom, dateTo, "NotLinked", "", "", "", SortOrder{}, -1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range donations {
+		if d.ID == "sf-opp-017" {
+			t.Error("split donation sf-opp-017 is not linked")
+		}
+	}
+
+	if err := testDB.DonationSplitDelete(ctx, split.ID, "invoice", "inv-unrec-02"); err != nil {
+		t.Fatal(err)
+	}
+	splits, err = testDB.DonationSplitsGet(ctx, "invoice", "inv-unrec-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(splits) != 0 {
+		t.Errorf("expected no splits after delete, got %d", len(splits))
+	}
+}
@@ -0,0 +1,19 @@
+/*
+ Reconciler app SQL
+ account_rule_delete.sql
+ Delete an account rule. The line items it classified keep the rule's
+ account code until the rules are applied again.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+DELETE FROM
+    account_rules
+WHERE
+    id = (SELECT ID FROM variables)
+;
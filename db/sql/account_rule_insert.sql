@@ -0,0 +1,29 @@
+/*
+ Reconciler app SQL
+ account_rule_insert.sql
+ Add an account rule matching the line item description or contact of
+ invoices and bank transactions.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'contact'            AS Field       /* @param */
+        ,'PayPal Giving Fund' AS Pattern     /* @param */
+        ,'5501'               AS AccountCode /* @param */
+)
+
+INSERT INTO account_rules (
+    field
+    ,pattern
+    ,account_code
+)
+SELECT
+    v.Field
+    ,v.Pattern
+    ,v.AccountCode
+FROM
+    variables v
+;
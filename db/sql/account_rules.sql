@@ -0,0 +1,34 @@
+/*
+ Reconciler app SQL
+ account_rules.sql
+ The account rules in the order they are applied, with the number of
+ invoice and bank transaction line items each rule classifies. An ID
+ of 0 selects all rules, otherwise the rule with the ID.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS ID /* @param */
+)
+SELECT
+    r.id
+    ,r.field
+    ,r.pattern
+    ,r.account_code
+    ,r.created_at
+    ,(SELECT count(*) FROM invoice_line_items li WHERE li.account_rule_id = r.id)
+     + (SELECT count(*) FROM bank_transaction_line_items li WHERE li.account_rule_id = r.id)
+     AS line_items
+FROM
+    account_rules r
+    JOIN variables v
+WHERE
+    v.ID = 0
+    OR
+    r.id = v.ID
+ORDER BY
+    r.id ASC
+;
@@ -0,0 +1,63 @@
+/*
+ Reconciler app SQL
+ account_rules_apply_invoices.sql
+ Apply the account rules to the line items of an invoice, or of all
+ invoices if InvoiceID is empty.
+
+ The first rule by id with a pattern matching the line item description
+ or the invoice contact sets the account_code of the line item,
+ otherwise the account code from Xero, held in xero_account_code, is
+ restored. Patterns match case insensitively. A contact merged into a
+ canonical contact as an alias also matches the canonical contact name.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'inv-unrec-03' AS InvoiceID /* @param */
+)
+,matches AS (
+    SELECT
+        li.id AS line_item_id
+        ,(
+            SELECT
+                r.id
+            FROM
+                account_rules r
+            WHERE
+                (r.field = 'description'
+                    AND COALESCE(li.description, '') REGEXP ('(?i)' || r.pattern))
+                OR
+                (r.field = 'contact'
+                    AND (
+                        COALESCE(i.contact, '') REGEXP ('(?i)' || r.pattern)
+                        OR
+                        COALESCE(ac.name, i.contact, '') REGEXP ('(?i)' || r.pattern)
+                    ))
+            ORDER BY
+                r.id
+            LIMIT 1
+        ) AS rule_id
+    FROM
+        invoice_line_items li
+        JOIN invoices i ON (i.id = li.invoice_id)
+        LEFT JOIN contact_aliases ca ON (ca.alias = CONTACTKEY(i.contact))
+        LEFT JOIN contacts ac ON (ac.id = ca.contact_id)
+        JOIN variables v
+    WHERE
+        v.InvoiceID IN ('', li.invoice_id)
+)
+UPDATE
+    invoice_line_items
+SET
+    xero_account_code = COALESCE(invoice_line_items.xero_account_code, invoice_line_items.account_code)
+    ,account_rule_id  = m.rule_id
+    ,account_code     = COALESCE(r.account_code, invoice_line_items.xero_account_code, invoice_line_items.account_code)
+FROM
+    matches m
+    LEFT JOIN account_rules r ON (r.id = m.rule_id)
+WHERE
+    invoice_line_items.id = m.line_item_id
+;
@@ -0,0 +1,32 @@
+/*
+ Reconciler app SQL
+ accounts.sql
+ The Xero accounts by code with their donation account selection. A
+ DonationOnly of 1 selects only the accounts selected as donation
+ accounts.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS DonationOnly /* @param */
+)
+SELECT
+    a.id
+    ,COALESCE(a.code, '') AS code
+    ,COALESCE(a.name, '') AS name
+    ,COALESCE(a.type, '') AS type
+    ,COALESCE(a.status, '') AS status
+    ,a.donation_account
+FROM
+    accounts a
+    JOIN variables v
+WHERE
+    a.code IS NOT NULL
+    AND a.code <> ''
+    AND (v.DonationOnly = 0 OR a.donation_account = 1)
+ORDER BY
+    a.code ASC
+;
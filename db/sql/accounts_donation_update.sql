@@ -0,0 +1,27 @@
+/*
+ Reconciler app SQL
+ accounts_donation_update.sql
+ Select the accounts with the codes in the json array Codes as the
+ donation accounts, excluding all other accounts. A Reset of 1 clears
+ the selection so that the configured donation account prefixes apply.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '["5501", "5701"]' AS Codes /* @param */
+        ,0                  AS Reset /* @param */
+)
+UPDATE
+    accounts
+SET
+    donation_account = CASE
+        WHEN (SELECT Reset FROM variables) = 1 THEN NULL
+        WHEN code IN (
+            SELECT j.value FROM variables v, json_each(v.Codes) j
+        ) THEN 1
+        ELSE 0
+    END
+;
@@ -0,0 +1,154 @@
+/*
+ Reconciler app SQL
+ aging_items.sql
+ Unlinked donations and unreconciled invoices and bank transactions dated
+ between DateFrom and AsAt, with their age in days at AsAt and an age bucket
+ numbered 0 to 3 for 0-30, 31-60, 61-90 and over 90 days.
+
+ Donations are unlinked if they have no links and their payout reference
+ matches no invoice or bank transaction. The amount of an unreconciled invoice
+ or bank transaction is its donation total less the linked donations total.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note totals match if they differ by less than half a penny, as sums of REAL
+ amounts are inexact, or by less than the reconciliation tolerance once
+ donations are linked.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2025-08-31') AS AsAt      /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+
+,crms_donation_totals AS (
+    SELECT
+        payout_reference_dfk
+        ,SUM(amount) AS total_crms_amount
+    FROM donation_payouts
+    WHERE
+        payout_reference_dfk IS NOT NULL
+    GROUP BY
+        payout_reference_dfk
+)
+
+,records AS (
+    SELECT
+        'invoice' AS item_type
+        ,i.id
+        ,COALESCE(i.invoice_number, '') AS reference
+        ,COALESCE(i.contact, '') AS name
+        ,i.date
+        ,SUM(li.line_amount) AS donation_total
+    FROM
+        invoices i
+        JOIN invoice_line_items li ON (li.invoice_id = i.id)
+        ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        date(i.date) BETWEEN v.DateFrom AND v.AsAt
+    GROUP BY
+        i.id
+
+    UNION ALL
+
+    SELECT
+        'bank-transaction' AS item_type
+        ,b.id
+        ,COALESCE(b.reference, '') AS reference
+        ,COALESCE(b.contact, '') AS name
+        ,b.date
+        ,SUM(li.line_amount) AS donation_total
+    FROM
+        bank_transactions b
+        JOIN bank_transaction_line_items li ON (li.transaction_id = b.id)
+        ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        date(b.date) BETWEEN v.DateFrom AND v.AsAt
+    GROUP BY
+        b.id
+)
+
+,items AS (
+    SELECT
+        r.item_type
+        ,r.id
+        ,r.reference
+        ,r.name
+        ,r.date
+        ,r.donation_total - COALESCE(c.total_crms_amount, 0) AS amount
+    FROM
+        records r
+        LEFT OUTER JOIN crms_donation_totals c ON (c.payout_reference_dfk = r.reference)
+        CROSS JOIN reconciliation_tolerance t
+    WHERE
+        -- the tolerance only applies once donations are linked
+        ABS(r.donation_total - COALESCE(c.total_crms_amount, 0))
+            >= 0.005 + IIF(COALESCE(c.total_crms_amount, 0) = 0, 0,
+                MAX(t.amount, ABS(r.donation_total) * t.percent / 100))
+
+    UNION ALL
+
+    SELECT
+        'donation' AS item_type
+        ,d.id
+        ,COALESCE(d.payout_reference_dfk, '') AS reference
+        ,COALESCE(d.name, '') AS name
+        ,d.close_date AS date
+        ,d.amount
+    FROM
+        donations d
+        ,variables v
+    WHERE
+        date(d.close_date) BETWEEN v.DateFrom AND v.AsAt
+        AND
+        NOT EXISTS (
+            SELECT 1 FROM donation_link_records lr WHERE lr.donation_id = d.id
+        )
+        AND
+        NOT EXISTS (
+            SELECT 1 FROM invoices i WHERE i.invoice_number = d.payout_reference_dfk
+        )
+        AND
+        NOT EXISTS (
+            SELECT 1 FROM bank_transactions b WHERE b.reference = d.payout_reference_dfk
+        )
+)
+
+SELECT
+    x.*
+    ,CASE
+        WHEN x.age_days <= 30 THEN 0
+        WHEN x.age_days <= 60 THEN 1
+        WHEN x.age_days <= 90 THEN 2
+        ELSE 3
+     END AS bucket
+FROM (
+    SELECT
+        i.*
+        ,CAST(julianday(v.AsAt) - julianday(date(i.date)) AS INTEGER) AS age_days
+    FROM
+        items i
+        ,variables v
+) x
+ORDER BY
+    x.age_days DESC
+    ,x.item_type
+    ,x.id
+;
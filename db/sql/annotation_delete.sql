@@ -0,0 +1,22 @@
+/*
+ Reconciler app SQL
+ annotation_delete.sql
+ Delete a note or flag from an invoice, bank transaction or donation.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1              AS ID         /* @param */
+        ,'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+DELETE FROM
+    annotations
+WHERE
+    (id, record_type, record_id) = (
+        SELECT ID, RecordType, RecordID FROM variables
+    )
+;
@@ -0,0 +1,42 @@
+/*
+ Reconciler app SQL
+ annotation_insert.sql
+ Add a note or flag to an invoice, bank transaction or donation.
+
+ No row is inserted if the record does not exist.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'                      AS RecordType /* @param */
+        ,'inv-unrec-04'                 AS RecordID   /* @param */
+        ,'query with fundraising team'  AS Note       /* @param */
+        ,1                              AS Flag       /* @param */
+)
+
+INSERT INTO annotations (
+    record_type
+    ,record_id
+    ,note
+    ,flag
+)
+SELECT
+    v.RecordType
+    ,v.RecordID
+    ,v.Note
+    ,v.Flag
+FROM
+    variables v
+WHERE
+    (v.RecordType = 'invoice'
+        AND EXISTS (SELECT 1 FROM invoices i WHERE i.id = v.RecordID))
+    OR
+    (v.RecordType = 'bank-transaction'
+        AND EXISTS (SELECT 1 FROM bank_transactions b WHERE b.id = v.RecordID))
+    OR
+    (v.RecordType = 'donation'
+        AND EXISTS (SELECT 1 FROM donations d WHERE d.id = v.RecordID))
+;
@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ annotations.sql
+ The notes and flags of an invoice, bank transaction or donation, flags
+ first and then the most recent first.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+
+SELECT
+    a.id
+    ,a.record_type
+    ,a.record_id
+    ,a.note
+    ,a.flag
+    ,a.created_at
+FROM
+    annotations a
+    ,variables v
+WHERE
+    a.record_type = v.RecordType
+    AND
+    a.record_id = v.RecordID
+ORDER BY
+    a.flag DESC
+    ,a.created_at DESC
+    ,a.id DESC
+;
@@ -0,0 +1,28 @@
+/*
+ Reconciler app SQL
+ assignment.sql
+ The assignment of an invoice, bank transaction or donation, if any.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+
+SELECT
+    a.record_type
+    ,a.record_id
+    ,a.assigned_to
+    ,a.assigned_at
+FROM
+    assignments a
+    ,variables v
+WHERE
+    a.record_type = v.RecordType
+    AND
+    a.record_id = v.RecordID
+;
@@ -0,0 +1,21 @@
+/*
+ Reconciler app SQL
+ assignment_delete.sql
+ Unassign an invoice, bank transaction or donation.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+DELETE FROM
+    assignments
+WHERE
+    (record_type, record_id) = (
+        SELECT RecordType, RecordID FROM variables
+    )
+;
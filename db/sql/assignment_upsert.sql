@@ -0,0 +1,43 @@
+/*
+ Reconciler app SQL
+ assignment_upsert.sql
+ Assign an invoice, bank transaction or donation to a team member,
+ replacing any earlier assignment.
+
+ No row is inserted if the record does not exist.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+        ,'Alex'         AS AssignedTo /* @param */
+)
+
+INSERT INTO assignments (
+    record_type
+    ,record_id
+    ,assigned_to
+)
+SELECT
+    v.RecordType
+    ,v.RecordID
+    ,v.AssignedTo
+FROM
+    variables v
+WHERE
+    (v.RecordType = 'invoice'
+        AND EXISTS (SELECT 1 FROM invoices i WHERE i.id = v.RecordID))
+    OR
+    (v.RecordType = 'bank-transaction'
+        AND EXISTS (SELECT 1 FROM bank_transactions b WHERE b.id = v.RecordID))
+    OR
+    (v.RecordType = 'donation'
+        AND EXISTS (SELECT 1 FROM donations d WHERE d.id = v.RecordID))
+ON CONFLICT (record_type, record_id) DO UPDATE SET
+    assigned_to  = excluded.assigned_to
+    ,assigned_at = CURRENT_TIMESTAMP
+;
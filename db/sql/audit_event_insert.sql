@@ -0,0 +1,32 @@
+/*
+ Reconciler app SQL
+ audit_event_insert.sql
+ Record an audit event of a period lock. A PeriodLockID of 0 is recorded
+ as null.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'override'                         AS Event        /* @param */
+        ,1                                  AS PeriodLockID /* @param */
+        ,'link 1 donations to INV-2025-106' AS Description  /* @param */
+        ,'correction agreed with auditors'  AS Reason       /* @param */
+)
+
+INSERT INTO audit_events (
+    event
+    ,period_lock_id
+    ,description
+    ,reason
+)
+SELECT
+    v.Event
+    ,NULLIF(v.PeriodLockID, 0)
+    ,v.Description
+    ,v.Reason
+FROM
+    variables v
+;
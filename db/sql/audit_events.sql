@@ -0,0 +1,29 @@
+/*
+ Reconciler app SQL
+ audit_events.sql
+ The latest audit events of the period locks, being the locking and
+ unlocking of periods and the overrides of locks for corrections. A
+ HereLimit of -1 returns all events.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         50 AS HereLimit /* @param */
+)
+SELECT
+    a.id
+    ,a.event
+    ,a.period_lock_id
+    ,a.description
+    ,a.reason
+    ,a.created_at
+FROM
+    audit_events a
+ORDER BY
+    a.id DESC
+LIMIT
+    (SELECT HereLimit FROM variables)
+;
@@ -10,17 +10,21 @@
 WITH variables AS (
     SELECT
          'bt-prev-fy-01' AS BankTransactionID /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
         ,'^(53|55|57).*' AS AccountCodes      /* @param */
 )
 
 SELECT
-    *
-    ,CASE WHEN donation_total = crms_total THEN
+    x.*
+    -- the tolerance only applies once donations are linked
+    ,CASE WHEN ABS(donation_total - crms_total)
+        < 0.005 + IIF(crms_total = 0, 0, MAX(t.amount, ABS(donation_total) * t.percent / 100)) THEN
         1
      ELSE
         0
      END AS is_reconciled
     ,donation_total - crms_total AS total_outstanding
+    ,donation_total - crms_total AS variance
 FROM (
     SELECT
         b.id
@@ -29,11 +33,18 @@ FROM (
         ,b.type
         ,b.status
         ,b.contact
+        ,COALESCE(b.contact_id, '') AS contact_id
         ,b.bank_account_id
         ,b.total
+        ,COALESCE(b.currency_code, '') AS currency_code
+        ,COALESCE(NULLIF(b.currency_rate, 0), 1) AS currency_rate
+        ,ROUND(b.total / COALESCE(NULLIF(b.currency_rate, 0), 1), 2) AS base_total
         ,COALESCE(
                 sum(li.line_amount)
-                FILTER (WHERE li.account_code REGEXP variables.AccountCodes)
+                FILTER (WHERE (
+                    li.account_code IN (SELECT code FROM donation_accounts)
+                    OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+                ))
                 OVER (PARTITION BY b.id)
          , 0) AS donation_total
         ,COALESCE(rds.donation_sum, 0) AS crms_total
@@ -46,7 +57,10 @@ FROM (
         ,li.tax_amount AS li_tax_amount
         ,li.line_amount AS li_line_amount
         ,CASE WHEN
-            li.account_code REGEXP variables.AccountCodes
+            (
+                li.account_code IN (SELECT code FROM donation_accounts)
+                OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+            )
         THEN
             li.line_amount
          ELSE
@@ -64,11 +78,12 @@ FROM (
                 payout_reference_dfk
                 ,sum(amount) AS donation_sum
             FROM
-                donations
+                donation_payouts
             GROUP BY
                 payout_reference_dfk
         ) rds ON (rds.payout_reference_dfk = b.reference)
     WHERE
         b.id = variables.BankTransactionID
 ) x
+CROSS JOIN reconciliation_tolerance t
 ;
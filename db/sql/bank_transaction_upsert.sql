@@ -13,10 +13,13 @@ WITH variables AS (
          ,'RECONCILED'                 AS Status               /* @param */
          ,'JG-PAYOUT-2025-04-15b'      AS Reference            /* @param */
          ,338.50                       AS Total                /* @param */
+         ,'GBP'                        AS CurrencyCode         /* @param */
+         ,1.0                          AS CurrencyRate         /* @param */
          ,false                        AS IsReconciled         /* @param */
          ,date('2025-04-15T14:00:01Z') AS Date                 /* @param */
          ,date('2026-01-01')           AS Updated              /* @param */
          ,'Admin User'                 AS Contact              /* @param */
+         ,'con-001'                    AS ContactID            /* @param */
          ,'Current Account'            AS BankAccount          /* @param */
          ,'b07f-7404f143aa1c'          AS BankAccountID        /* @param */
 )
@@ -26,10 +29,13 @@ INSERT INTO bank_transactions (
     ,status
     ,reference
     ,total
+    ,currency_code
+    ,currency_rate
     ,is_reconciled
     ,date
     ,updated_at
     ,contact
+    ,contact_id
     ,bank_account
     ,bank_account_id
 )
@@ -39,10 +45,13 @@ SELECT
     ,v.Status
     ,v.Reference
     ,v.Total
+    ,v.CurrencyCode
+    ,v.CurrencyRate
     ,v.IsReconciled
     ,v.Date
     ,v.Updated
     ,v.Contact
+    ,v.ContactID
     ,v.BankAccount
     ,v.BankAccountID
 FROM
@@ -55,10 +64,13 @@ ON CONFLICT (id) DO UPDATE SET
     ,status          = excluded.status
     ,reference       = excluded.reference
     ,total           = excluded.total
+    ,currency_code   = excluded.currency_code
+    ,currency_rate   = excluded.currency_rate
     ,is_reconciled   = excluded.is_reconciled
     ,date            = excluded.date
     ,updated_at      = excluded.updated_at
     ,contact         = excluded.contact
+    ,contact_id      = excluded.contact_id
     ,bank_account    = excluded.bank_account
     ,bank_account_id = excluded.bank_account_id
 ;
@@ -5,16 +5,31 @@
 
  Note @param comments declare a template value for middleware replacement.
  Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note IF and END IF comment lines mark blocks omitted for empty arguments.
+ Note TextSearch matches case insensitively, ignoring diacritics.
+ Note SortColumn and SortDirection are validated by the caller, with the
+ date column and ascending order as the default.
+ Note the sum columns total the full filtered set, ahead of the limit and
+ offset, in the base currency.
+ Note totals match if they differ by less than half a penny, as sums of REAL
+ amounts are inexact, or by less than the reconciliation tolerance once
+ donations are linked.
 */
 
 WITH variables AS (
     SELECT
         date('2024-04-01') AS DateFrom   /* @param */
         ,date('2027-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
         ,'^(53|55|57).*' AS AccountCodes /* @param */
         -- All | Reconciled | NotReconciled
         ,'All' AS ReconciliationStatus   /* @param */
         ,'' AS TextSearch     /* @param */
+        -- the assignee, or empty for any
+        ,'' AS AssignedTo     /* @param */
+        -- date | amount | contact | status, and asc | desc
+        ,'date' AS SortColumn            /* @param */
+        ,'asc' AS SortDirection          /* @param */
         ,10 AS HereLimit                 /* @param */
         ,0 AS HereOffset                 /* @param */
 )
@@ -39,7 +54,10 @@ WITH variables AS (
     JOIN bank_transactions b ON (b.id = li.transaction_id)
     ,variables
     WHERE
-        account_code REGEXP variables.AccountCodes
+        (
+            account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND account_code REGEXP variables.AccountCodes)
+        )
         AND
         b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
         AND
@@ -52,7 +70,7 @@ crms_donation_totals AS (
     SELECT
         payout_reference_dfk
         ,SUM(amount) AS total_crms_amount
-    FROM donations
+    FROM donation_payouts
     JOIN variables
     WHERE
         payout_reference_dfk IS NOT NULL
@@ -62,6 +80,21 @@ crms_donation_totals AS (
         payout_reference_dfk
 )
 
+,bank_transaction_reconciliation AS (
+    SELECT
+        bdt.transaction_id
+        ,bdt.total_donation_amount AS donation_total
+        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+        -- the tolerance only applies once donations are linked
+        ,ABS(bdt.total_donation_amount - COALESCE(cdt.total_crms_amount, 0))
+            < 0.005 + IIF(COALESCE(cdt.total_crms_amount, 0) = 0, 0,
+                MAX(t.amount, ABS(bdt.total_donation_amount) * t.percent / 100)) AS is_reconciled
+    FROM bank_transaction_donation_totals bdt
+    JOIN bank_transactions b ON b.id = bdt.transaction_id
+    LEFT JOIN crms_donation_totals cdt ON b.reference = cdt.payout_reference_dfk
+    CROSS JOIN reconciliation_tolerance t
+)
+
 ,reconciliation_data AS (
     SELECT
         b.id
@@ -71,13 +104,25 @@ crms_donation_totals AS (
         ,b.contact
         ,b.status
         ,b.total
-        ,COALESCE(bdt.total_donation_amount, 0) AS donation_total
-        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+        ,COALESCE(b.currency_code, '') AS currency_code
+        ,COALESCE(NULLIF(b.currency_rate, 0), 1) AS currency_rate
+        ,ROUND(b.total / COALESCE(NULLIF(b.currency_rate, 0), 1), 2) AS base_total
+        ,br.donation_total
+        ,br.crms_total
+        ,br.donation_total - br.crms_total AS variance
+        ,br.is_reconciled
         ,COUNT(*) OVER () AS row_count
+        -- the number of notes on the record, and if any is a flag
+        ,(SELECT COUNT(*) FROM annotations a
+            WHERE a.record_type = 'bank-transaction' AND a.record_id = b.id) AS annotations
+        ,EXISTS (SELECT 1 FROM annotations a
+            WHERE a.record_type = 'bank-transaction' AND a.record_id = b.id AND a.flag) AS flagged
+        -- the team member to whom the record is assigned
+        ,COALESCE(asg.assigned_to, '') AS assigned_to
     FROM bank_transactions b
     JOIN variables v ON b.date BETWEEN v.DateFrom AND v.DateTo
-    LEFT JOIN bank_transaction_donation_totals bdt ON b.id = bdt.transaction_id
-    LEFT JOIN crms_donation_totals cdt ON b.reference = cdt.payout_reference_dfk
+    JOIN bank_transaction_reconciliation br ON b.id = br.transaction_id
+    LEFT JOIN assignments asg ON (asg.record_type = 'bank-transaction' AND asg.record_id = b.id)
     LEFT JOIN bank_transaction_unique_refs uref ON b.reference = uref.reference
     WHERE
         b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
@@ -89,28 +134,52 @@ crms_donation_totals AS (
             (
                 v.ReconciliationStatus = 'Reconciled'
                  AND
-                 COALESCE(bdt.total_donation_amount, 0) = COALESCE(cdt.total_crms_amount, 0)
+                 br.is_reconciled
             )
             OR
             (
                 v.ReconciliationStatus = 'NotReconciled'
                  AND
-                 COALESCE(bdt.total_donation_amount, 0) <> COALESCE(cdt.total_crms_amount, 0)
+                 NOT br.is_reconciled
             )
         )
+        -- IF :TextSearch
+        AND
+        FOLD(CONCAT(b.reference, ' ', b.contact)) REGEXP ('(?i)' || FOLD(v.TextSearch))
+        -- END IF
+        -- IF :AssignedTo
         AND
-        bdt.transaction_id IS NOT NULL
-        AND CASE
-            WHEN v.TextSearch = '' THEN true
-            ELSE LOWER(CONCAT(b.reference, ' ', b.contact)) REGEXP LOWER(v.TextSearch)
-            END
-    ORDER BY
-        b.date ASC
+        asg.assigned_to = v.AssignedTo
+        -- END IF
 )
 SELECT
     r.*
-    ,donation_total = crms_total AS is_reconciled
+    -- totals of the full filtered set, summed before the limit is applied
+    ,SUM(r.base_total) OVER () AS sum_total
+    ,SUM(r.donation_total) OVER () AS sum_donation_total
+    ,SUM(r.crms_total) OVER () AS sum_crms_total
+    ,SUM(r.variance) OVER () AS sum_variance
 FROM reconciliation_data r
+JOIN variables v
+ORDER BY
+    CASE WHEN v.SortDirection = 'asc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN r.base_total
+            WHEN 'contact' THEN LOWER(r.contact)
+            WHEN 'status' THEN r.status
+            ELSE r.date
+        END
+    END ASC
+    ,CASE WHEN v.SortDirection = 'desc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN r.base_total
+            WHEN 'contact' THEN LOWER(r.contact)
+            WHEN 'status' THEN r.status
+            ELSE r.date
+        END
+    END DESC
+    ,r.date ASC
+    ,r.id ASC
 LIMIT
     (SELECT variables.HereLimit FROM variables)
 OFFSET
@@ -0,0 +1,28 @@
+/*
+ Reconciler app SQL
+ contact.sql
+ Detail view of a Xero contact.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'con-jg' AS ContactID /* @param */
+)
+SELECT
+    c.id
+    ,COALESCE(c.name, '') AS name
+    ,COALESCE(c.first_name, '') AS first_name
+    ,COALESCE(c.last_name, '') AS last_name
+    ,COALESCE(c.email_address, '') AS email_address
+    ,COALESCE(c.contact_status, '') AS contact_status
+    ,COALESCE(c.is_customer, 0) AS is_customer
+    ,COALESCE(c.is_supplier, 0) AS is_supplier
+FROM
+    contacts c
+    ,variables v
+WHERE
+    c.id = v.ContactID
+;
@@ -0,0 +1,64 @@
+/*
+ Reconciler app SQL
+ contact_alias_candidates.sql
+ The contact names of invoices and bank transactions which may be
+ aliases of a contact, having a normalised name starting with the first
+ word of the contact's normalised name, such as "Stripe Payments UK" for
+ the contact "Stripe". Names already merged into a contact and names of
+ contacts with aliases of their own are excluded.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'con-stripe' AS ContactID /* @param */
+)
+
+,target AS (
+    SELECT
+        CONTACTKEY(c.name) AS contact_key
+        ,substr(CONTACTKEY(c.name) || ' ', 1, instr(CONTACTKEY(c.name) || ' ', ' ') - 1) AS first_word
+    FROM
+        contacts c
+        JOIN variables v ON (c.id = v.ContactID)
+)
+
+,names AS (
+    SELECT contact AS name FROM invoices WHERE COALESCE(contact, '') <> ''
+    UNION
+    SELECT contact AS name FROM bank_transactions WHERE COALESCE(contact, '') <> ''
+)
+
+,keyed AS (
+    SELECT
+        n.name
+        ,CONTACTKEY(n.name) AS alias
+    FROM
+        names n
+)
+
+SELECT
+    k.alias
+    ,MIN(k.name) AS name
+FROM
+    keyed k
+    JOIN target t ON (
+        k.alias <> t.contact_key
+        AND
+        t.first_word <> ''
+        AND
+        (k.alias = t.first_word OR k.alias LIKE t.first_word || ' %')
+    )
+WHERE
+    k.alias NOT IN (SELECT alias FROM contact_aliases)
+    AND
+    k.alias NOT IN (
+        SELECT CONTACTKEY(c.name) FROM contacts c JOIN contact_aliases a ON (a.contact_id = c.id)
+    )
+GROUP BY
+    k.alias
+ORDER BY
+    k.alias
+;
@@ -0,0 +1,19 @@
+/*
+ Reconciler app SQL
+ contact_alias_delete.sql
+ Remove a contact alias, so that the contact name is no longer merged
+ into its canonical contact.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'stripe payments uk' AS Alias /* @param */
+)
+DELETE FROM
+    contact_aliases
+WHERE
+    alias = (SELECT Alias FROM variables)
+;
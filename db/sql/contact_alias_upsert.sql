@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ contact_alias_upsert.sql
+ Merge the contact name with the normalised name Alias into the
+ canonical contact ContactID, replacing any earlier merge of the alias.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'stripe payments uk' AS Alias     /* @param */
+        ,'Stripe Payments UK' AS Name      /* @param */
+        ,'con-stripe'         AS ContactID /* @param */
+)
+
+INSERT INTO contact_aliases (
+    alias
+    ,name
+    ,contact_id
+)
+SELECT
+    v.Alias
+    ,v.Name
+    ,v.ContactID
+FROM
+    variables v
+WHERE
+    true
+ON CONFLICT (alias) DO UPDATE SET
+    name        = excluded.name
+    ,contact_id = excluded.contact_id
+    ,created_at = CURRENT_TIMESTAMP
+;
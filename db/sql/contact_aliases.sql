@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ contact_aliases.sql
+ The contact aliases merged into a canonical contact, or all aliases for
+ an empty ContactID, with the name of the canonical contact and the
+ number of invoices and bank transactions with a contact name having the
+ alias as its normalised name.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'con-stripe' AS ContactID /* @param */
+)
+SELECT
+    a.alias
+    ,a.name
+    ,a.contact_id
+    ,COALESCE(c.name, '') AS contact_name
+    ,(SELECT count(*) FROM invoices i WHERE CONTACTKEY(i.contact) = a.alias)
+     + (SELECT count(*) FROM bank_transactions b WHERE CONTACTKEY(b.contact) = a.alias)
+     AS records
+    ,a.created_at
+FROM
+    contact_aliases a
+    JOIN variables v
+    LEFT JOIN contacts c ON (c.id = a.contact_id)
+WHERE
+    v.ContactID IN ('', a.contact_id)
+ORDER BY
+    a.contact_id
+    ,a.alias
+;
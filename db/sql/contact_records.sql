@@ -0,0 +1,120 @@
+/*
+ Reconciler app SQL
+ contact_records.sql
+ List of invoices and bank transactions for a Xero contact with
+ reconciliation status, most recent first. The records of the contact
+ names merged into the contact as aliases are included, with the
+ contact name of each record.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'con-jg'        AS ContactID    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+
+,crms_donation_totals AS (
+    SELECT
+        payout_reference_dfk
+        ,SUM(amount) AS total_crms_amount
+    FROM donation_payouts
+    WHERE
+        payout_reference_dfk IS NOT NULL
+    GROUP BY
+        payout_reference_dfk
+)
+
+,invoice_donation_totals AS (
+    SELECT
+        li.invoice_id
+        ,SUM(li.line_amount) AS total_donation_amount
+    FROM invoice_line_items li
+    ,variables
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+        )
+    GROUP BY
+        li.invoice_id
+)
+
+,bank_transaction_donation_totals AS (
+    SELECT
+        li.transaction_id
+        ,SUM(li.line_amount) AS total_donation_amount
+    FROM bank_transaction_line_items li
+    ,variables
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+        )
+    GROUP BY
+        li.transaction_id
+)
+
+,contact_alias_keys AS (
+    SELECT
+        a.alias
+    FROM contact_aliases a
+    JOIN variables v ON a.contact_id = v.ContactID
+)
+,contact_records AS (
+    SELECT
+        'invoice' AS typer
+        ,i.id
+        ,i.invoice_number AS reference
+        ,i.date
+        ,i.status
+        ,COALESCE(i.contact, '') AS contact
+        ,i.total
+        ,COALESCE(idt.total_donation_amount, 0) AS donation_total
+        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+    FROM invoices i
+    JOIN variables v ON (
+        i.contact_id = v.ContactID
+        OR CONTACTKEY(i.contact) IN (SELECT alias FROM contact_alias_keys)
+    )
+    LEFT JOIN invoice_donation_totals idt ON i.id = idt.invoice_id
+    LEFT JOIN crms_donation_totals cdt ON i.invoice_number = cdt.payout_reference_dfk
+    WHERE
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+
+    UNION ALL
+
+    SELECT
+        'bank-transaction' AS typer
+        ,b.id
+        ,b.reference
+        ,b.date
+        ,b.status
+        ,COALESCE(b.contact, '') AS contact
+        ,b.total
+        ,COALESCE(bdt.total_donation_amount, 0) AS donation_total
+        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+    FROM bank_transactions b
+    JOIN variables v ON (
+        b.contact_id = v.ContactID
+        OR CONTACTKEY(b.contact) IN (SELECT alias FROM contact_alias_keys)
+    )
+    LEFT JOIN bank_transaction_donation_totals bdt ON b.id = bdt.transaction_id
+    LEFT JOIN crms_donation_totals cdt ON b.reference = cdt.payout_reference_dfk
+    WHERE
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+)
+SELECT
+    r.*
+    -- the tolerance only applies once donations are linked
+    ,ABS(donation_total - crms_total)
+        < 0.005 + IIF(crms_total = 0, 0, MAX(t.amount, ABS(donation_total) * t.percent / 100)) AS is_reconciled
+FROM contact_records r
+CROSS JOIN reconciliation_tolerance t
+ORDER BY
+    r.date DESC
+    ,r.reference
+;
@@ -0,0 +1,58 @@
+/*
+ Reconciler app SQL
+ contact_upsert.sql
+ Upsert a Xero Contact into the database.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'con-001'             AS ContactID     /* @param */
+         ,'Example Corp Ltd'   AS Name          /* @param */
+         ,''                   AS FirstName     /* @param */
+         ,''                   AS LastName      /* @param */
+         ,'info@example.co'    AS EmailAddress  /* @param */
+         ,'ACTIVE'             AS ContactStatus /* @param */
+         ,1                    AS IsCustomer    /* @param */
+         ,0                    AS IsSupplier    /* @param */
+         ,'2026-01-02'         AS Updated       /* @param */
+)
+
+INSERT INTO contacts (
+    id
+    ,name
+    ,first_name
+    ,last_name
+    ,email_address
+    ,contact_status
+    ,is_customer
+    ,is_supplier
+    ,updated_at
+)
+SELECT
+    v.ContactID
+    ,v.Name
+    ,v.FirstName
+    ,v.LastName
+    ,v.EmailAddress
+    ,v.ContactStatus
+    ,v.IsCustomer
+    ,v.IsSupplier
+    ,v.Updated
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (id) DO UPDATE SET
+    name            = excluded.name
+    ,first_name     = excluded.first_name
+    ,last_name      = excluded.last_name
+    ,email_address  = excluded.email_address
+    ,contact_status = excluded.contact_status
+    ,is_customer    = excluded.is_customer
+    ,is_supplier    = excluded.is_supplier
+    ,updated_at     = excluded.updated_at
+;
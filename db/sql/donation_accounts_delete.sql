@@ -0,0 +1,19 @@
+/*
+ Reconciler app SQL
+ donation_accounts_delete.sql
+ Remove the donation account codes no longer matching the donation
+ account codes regular expression.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '^(53|55|57).*' AS AccountCodes /* @param */
+)
+DELETE FROM
+    donation_accounts
+WHERE
+    NOT code REGEXP (SELECT AccountCodes FROM variables)
+;
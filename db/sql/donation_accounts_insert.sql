@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ donation_accounts_insert.sql
+ Add the codes of the accounts and line items matching the donation
+ account codes regular expression to the donation account codes. The
+ expression is evaluated once for each distinct code.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '^(53|55|57).*' AS AccountCodes /* @param */
+)
+,codes AS (
+    SELECT code FROM accounts
+    UNION
+    SELECT account_code FROM invoice_line_items
+    UNION
+    SELECT account_code FROM bank_transaction_line_items
+)
+INSERT OR IGNORE INTO donation_accounts (
+    code
+)
+SELECT
+    c.code
+FROM
+    codes c
+    JOIN variables v
+WHERE
+    c.code IS NOT NULL
+    AND c.code <> ''
+    AND c.code REGEXP v.AccountCodes
+;
@@ -0,0 +1,20 @@
+/*
+ Reconciler app SQL
+ donation_delete.sql
+ Delete a donation by id, such as after it is deleted in Salesforce.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '0065A00000AAAAAQA1' AS ID /* @param */
+)
+DELETE FROM
+    donations
+WHERE
+    id = (
+        SELECT ID from variables
+    )
+;
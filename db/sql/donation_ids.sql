@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ donation_ids.sql
+ The ids of the donations closing on or after DateFrom, which are
+ compared with the Salesforce records to find orphaned donations.
+ Imported donations are excluded.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '2025-04-01' AS DateFrom /* @param */
+)
+SELECT
+    d.id
+FROM
+    donations d
+    JOIN variables v
+WHERE
+    d.close_date >= v.DateFrom
+    AND d.source = 'salesforce'
+ORDER BY
+    d.id
+;
@@ -0,0 +1,65 @@
+/*
+ Reconciler app SQL
+ donation_import_upsert.sql
+ Upsert a donation imported from a CRM other than Salesforce, tagged with
+ the source of the import. A donation of another source is not updated.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+        'crm-1001'              AS ID                   /* @param */
+        ,'Imported Donor'       AS Name                 /* @param */
+        ,'25.00'                AS Amount               /* @param */
+        ,datetime('2025-04-14') AS CloseDate            /* @param */
+        ,'JG-PAYOUT-2025-04-15' AS PayoutReference      /* @param */
+        ,datetime('2025-05-01') AS CreatedDate          /* @param */
+        ,'User1'                AS CreatedBy            /* @param */
+        ,datetime('2025-05-01') AS LastModifiedDate     /* @param */
+        ,'User1'                AS LastModifiedBy       /* @param */
+        ,''                     AS AdditionalFieldsJSON /* @param */
+        ,'crm'                  AS Source               /* @param */
+)
+INSERT INTO donations (
+    id
+    ,name
+    ,amount
+    ,close_date
+    ,payout_reference_dfk
+    ,created_date
+    ,created_by
+    ,last_modified_date
+    ,last_modified_by
+    ,additional_fields_json
+    ,source
+)
+SELECT
+    v.ID
+    ,v.Name
+    ,v.Amount
+    ,v.CloseDate
+    ,v.PayoutReference
+    ,v.CreatedDate
+    ,v.CreatedBy
+    ,v.LastModifiedDate
+    ,v.LastModifiedBy
+    ,v.AdditionalFieldsJSON
+    ,v.Source
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (id) DO UPDATE SET
+    name                    = excluded.name
+    ,amount                 = excluded.amount
+    ,close_date             = excluded.close_date
+    ,payout_reference_dfk   = excluded.payout_reference_dfk
+    ,last_modified_date     = excluded.last_modified_date
+    ,last_modified_by       = excluded.last_modified_by
+    ,additional_fields_json = excluded.additional_fields_json
+WHERE
+    donations.source = excluded.source
+;
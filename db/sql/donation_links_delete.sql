@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ donation_links_delete.sql
+ Delete the donation links made from a payout reference which no longer
+ matches the payout reference of the donation, such as after the donation is
+ unlinked or relinked in Salesforce, or the donation is deleted. Splits are not
+ affected.
+
+ An empty DonationID applies to all donations.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '' AS DonationID /* @param */
+)
+DELETE FROM
+    donation_links
+WHERE
+    payout_reference IS NOT NULL
+    AND
+    (SELECT DonationID FROM variables) IN ('', donation_id)
+    AND
+    NOT EXISTS (
+        SELECT 1
+        FROM
+            donations d
+        WHERE
+            d.id = donation_links.donation_id
+            AND
+            d.payout_reference_dfk = donation_links.payout_reference
+    )
+;
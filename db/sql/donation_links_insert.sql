@@ -0,0 +1,65 @@
+/*
+ Reconciler app SQL
+ donation_links_insert.sql
+ Link donations without links to the invoices and bank transactions matching
+ their payout reference, recording the reference and the Salesforce user and
+ time of the last modification of the donation as the link metadata.
+
+ An empty DonationID applies to all donations.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '' AS DonationID /* @param */
+)
+
+,records AS (
+    SELECT
+        'invoice' AS record_type
+        ,i.id AS record_id
+        ,i.invoice_number AS ref
+    FROM
+        invoices i
+    WHERE
+        COALESCE(i.invoice_number, '') <> ''
+    UNION ALL
+    SELECT
+        'bank-transaction' AS record_type
+        ,b.id AS record_id
+        ,b.reference AS ref
+    FROM
+        bank_transactions b
+    WHERE
+        COALESCE(b.reference, '') <> ''
+)
+
+INSERT INTO donation_links (
+    donation_id
+    ,record_type
+    ,record_id
+    ,payout_reference
+    ,linked_by
+    ,linked_at
+)
+SELECT
+    d.id
+    ,r.record_type
+    ,r.record_id
+    ,d.payout_reference_dfk
+    ,COALESCE(d.last_modified_by, 'Salesforce')
+    ,COALESCE(d.last_modified_date, CURRENT_TIMESTAMP)
+FROM
+    donations d
+    JOIN records r ON (r.ref = d.payout_reference_dfk)
+    ,variables v
+WHERE
+    v.DonationID IN ('', d.id)
+    AND
+    NOT EXISTS (
+        SELECT 1 FROM donation_links l WHERE l.donation_id = d.id
+    )
+ON CONFLICT (donation_id, record_type, record_id) DO NOTHING
+;
@@ -0,0 +1,29 @@
+/*
+ Reconciler app SQL
+ donation_orphan_upsert.sql
+ Flag a donation as orphaned, keeping the time it was first detected.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'sf-opp-001' AS DonationID /* @param */
+        ,'deleted'    AS Reason     /* @param */
+)
+INSERT INTO donation_orphans (
+    donation_id
+    ,reason
+)
+SELECT
+    v.DonationID
+    ,v.Reason
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (donation_id) DO UPDATE SET
+    reason = excluded.reason
+;
@@ -0,0 +1,39 @@
+/*
+ Reconciler app SQL
+ donation_orphans.sql
+ The donations flagged as orphaned, having been deleted in Salesforce
+ or no longer being found by the Salesforce query, with the number of
+ invoices and bank transactions each is linked to. With a Reason of
+ all every orphan is returned.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'all' AS Reason /* @param */
+)
+SELECT
+    d.id
+    ,d.name
+    ,d.amount
+    ,d.close_date
+    ,coalesce(d.payout_reference_dfk, '') AS payout_reference
+    ,o.reason
+    ,o.detected_at
+    ,(
+        SELECT count(*) FROM donation_link_records lr WHERE lr.donation_id = d.id
+    ) AS links
+FROM
+    donation_orphans o
+    JOIN donations d ON (d.id = o.donation_id)
+    JOIN variables v
+WHERE
+    v.Reason = 'all'
+    OR
+    o.reason = v.Reason
+ORDER BY
+    d.close_date DESC
+    ,d.id
+;
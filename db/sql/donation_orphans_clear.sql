@@ -0,0 +1,21 @@
+/*
+ Reconciler app SQL
+ donation_orphans_clear.sql
+ Clear the orphan flags of donations other than those with the ids
+ given as a json array, such as those found again in Salesforce.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '["sf-opp-001"]' AS DonationIDs /* @param */
+)
+DELETE FROM
+    donation_orphans
+WHERE
+    donation_id NOT IN (
+        SELECT j.value FROM variables v, json_each(v.DonationIDs) j
+    )
+;
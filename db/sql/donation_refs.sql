@@ -0,0 +1,31 @@
+/*
+ Reconciler app SQL
+ donation_refs.sql
+ The payout references of donations, for the donation ids given as a
+ json array, with the fields compared with the Salesforce records to
+ detect remote changes. Donations without a payout reference have an
+ empty ref.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '["sf-opp-001"]' AS DonationIDs /* @param */
+)
+SELECT
+    d.id
+    ,d.name
+    ,d.amount
+    ,d.close_date
+    ,coalesce(d.payout_reference_dfk, '') AS ref
+    ,d.last_modified_date
+FROM
+    donations d
+    JOIN variables v
+WHERE
+    d.id IN (SELECT j.value FROM json_each(v.DonationIDs) j)
+ORDER BY
+    d.id
+;
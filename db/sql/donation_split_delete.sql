@@ -0,0 +1,24 @@
+/*
+ Reconciler app SQL
+ donation_split_delete.sql
+ Delete a donation split from an invoice or bank transaction.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1              AS ID         /* @param */
+        ,'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+DELETE FROM
+    donation_links
+WHERE
+    (id, record_type, record_id) = (
+        SELECT ID, RecordType, RecordID FROM variables
+    )
+    AND
+    amount IS NOT NULL
+;
@@ -0,0 +1,65 @@
+/*
+ Reconciler app SQL
+ donation_split_upsert.sql
+ Allocate part of a donation to an invoice or bank transaction, replacing any
+ existing allocation or link of the donation to that record.
+
+ No row is inserted if the donation or record does not exist, or if the
+ allocation would take the total allocated from the donation above the
+ donation amount, counting links without an amount as the whole donation.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '0065A00000AAAAAQA1' AS DonationID /* @param */
+        ,'invoice'            AS RecordType /* @param */
+        ,'inv-unrec-04'       AS RecordID   /* @param */
+        ,10.0                 AS Amount     /* @param */
+)
+
+INSERT INTO donation_links (
+    donation_id
+    ,record_type
+    ,record_id
+    ,amount
+    ,linked_by
+)
+SELECT
+    v.DonationID
+    ,v.RecordType
+    ,v.RecordID
+    ,v.Amount
+    ,'Reconciler'
+FROM
+    variables v
+    JOIN donations d ON (d.id = v.DonationID)
+WHERE
+    v.Amount > 0
+    AND
+    (
+        (v.RecordType = 'invoice'
+            AND EXISTS (SELECT 1 FROM invoices i WHERE i.id = v.RecordID))
+        OR
+        (v.RecordType = 'bank-transaction'
+            AND EXISTS (SELECT 1 FROM bank_transactions b WHERE b.id = v.RecordID))
+    )
+    AND
+    v.Amount + COALESCE((
+        SELECT
+            SUM(COALESCE(s.amount, d.amount))
+        FROM
+            donation_links s
+        WHERE
+            s.donation_id = v.DonationID
+            AND
+            NOT (s.record_type = v.RecordType AND s.record_id = v.RecordID)
+    ), 0) < d.amount + 0.005
+ON CONFLICT (donation_id, record_type, record_id) DO UPDATE SET
+    payout_reference = NULL
+    ,amount          = excluded.amount
+    ,linked_by       = excluded.linked_by
+    ,linked_at       = CURRENT_TIMESTAMP
+;
@@ -0,0 +1,54 @@
+/*
+ Reconciler app SQL
+ donation_splits.sql
+ Donation splits allocated to an invoice or bank transaction, with the total
+ allocated from each donation across all its links. Splits are the donation
+ links with an amount.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'      AS RecordType /* @param */
+        ,'inv-unrec-04' AS RecordID   /* @param */
+)
+
+,allocations AS (
+    SELECT
+        l.donation_id
+        ,SUM(COALESCE(l.amount, d.amount)) AS allocated
+    FROM
+        donation_links l
+        JOIN donations d ON (d.id = l.donation_id)
+    GROUP BY
+        l.donation_id
+)
+
+SELECT
+    s.id
+    ,s.donation_id
+    ,d.name AS donation_name
+    ,d.amount AS donation_amount
+    ,s.record_type
+    ,s.record_id
+    ,s.amount
+    ,a.allocated
+    ,s.linked_by
+    ,s.linked_at
+FROM
+    donation_links s
+    JOIN donations d ON (d.id = s.donation_id)
+    JOIN allocations a ON (a.donation_id = s.donation_id)
+    ,variables v
+WHERE
+    s.record_type = v.RecordType
+    AND
+    s.record_id = v.RecordID
+    AND
+    s.amount IS NOT NULL
+ORDER BY
+    s.linked_at ASC
+    ,s.id ASC
+;
@@ -8,6 +8,10 @@
 
  Note @param comments declare a template value for middleware replacement.
  Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note IF and END IF comment lines mark blocks omitted for empty arguments.
+ Note TextSearch matches case insensitively, ignoring diacritics.
+ Note SortColumn and SortDirection are validated by the caller, with the
+ date column and ascending order as the default.
 */
 
 WITH variables AS (
@@ -18,6 +22,11 @@ WITH variables AS (
         ,'All' AS LinkageStatus        /* @param */
         ,'' AS PayoutReference         /* @param */
         ,'' AS TextSearch              /* @param */
+        -- the assignee, or empty for any
+        ,'' AS AssignedTo              /* @param */
+        -- date | amount | contact | status, and asc | desc
+        ,'date' AS SortColumn          /* @param */
+        ,'asc' AS SortDirection        /* @param */
         ,30 AS HereLimit               /* @param */
         ,0 AS HereOffset               /* @param */
 )
@@ -76,6 +85,19 @@ WITH variables AS (
         b.reference
 )
 
+/* Donations with links to invoices or bank transactions, which survive
+ * edits of the Xero reference, are linked by the first of their links.
+ */
+,linked_donations AS (
+    SELECT
+        donation_id
+        ,MIN(id) AS link_row_id
+    FROM
+        donation_link_records
+    GROUP BY
+        donation_id
+)
+
 ,main AS (
     SELECT
         s.id
@@ -89,13 +111,26 @@ WITH variables AS (
         ,s.last_modified_by
         ,COUNT(*) OVER () AS row_count
         ,CASE
-            WHEN lit.ref IS NOT NULL THEN
+            WHEN lit.ref IS NOT NULL OR ld.donation_id IS NOT NULL THEN
                 TRUE
             ELSE
                 FALSE
          END AS is_linked
-        ,COALESCE(lit.ref_id, '') AS link_id
-        ,COALESCE(lit.ref_typer, '') AS link_typer
+        ,COALESCE(dl.record_id, lit.ref_id, '') AS link_id
+        ,COALESCE(dl.record_type, lit.ref_typer, '') AS link_typer
+        ,COALESCE(dl.linked_by, '') AS linked_by
+        ,dl.linked_at
+        -- a payout reference update is queued for Salesforce
+        ,EXISTS (
+            SELECT 1 FROM salesforce_outbox o WHERE o.donation_id = s.id
+        ) AS remote_pending
+        -- the number of notes on the donation, and if any is a flag
+        ,(SELECT COUNT(*) FROM annotations a
+            WHERE a.record_type = 'donation' AND a.record_id = s.id) AS annotations
+        ,EXISTS (SELECT 1 FROM annotations a
+            WHERE a.record_type = 'donation' AND a.record_id = s.id AND a.flag) AS flagged
+        -- the team member to whom the donation is assigned
+        ,COALESCE(asg.assigned_to, '') AS assigned_to
 
         /* see www.sqlitetutorial.net/sqlite-json-functions/sqlite-json_extract-function/ */
         -- s.additional_fields_json  TEXT -- A JSON blob for all other fields
@@ -103,6 +138,11 @@ WITH variables AS (
         LEFT OUTER JOIN linked_invoices_or_transactions lit ON (
             lit.ref = s.payout_reference_dfk
         )
+        LEFT OUTER JOIN linked_donations ld ON (ld.donation_id = s.id)
+        LEFT OUTER JOIN donation_links dl ON (dl.id = ld.link_row_id)
+        LEFT OUTER JOIN assignments asg ON (
+            asg.record_type = 'donation' AND asg.record_id = s.id
+        )
         , variables v
     WHERE
         s.close_date BETWEEN v.DateFrom AND v.DateTo
@@ -121,33 +161,57 @@ WITH variables AS (
         (
             (v.LinkageStatus = 'All')
             OR
-            (v.LinkageStatus = 'Linked' AND  lit.ref IS NOT NULL)
+            (v.LinkageStatus = 'Linked' AND (lit.ref IS NOT NULL OR ld.donation_id IS NOT NULL))
             OR
-            (v.LinkageStatus = 'NotLinked' AND lit.ref IS NULL)
+            (v.LinkageStatus = 'NotLinked' AND lit.ref IS NULL AND ld.donation_id IS NULL)
         )
+        -- IF :TextSearch
         AND
-        CASE
-            WHEN v.TextSearch = '' OR v.TextSearch IS NULL THEN
-                TRUE
-            ELSE
-                -- Todo searching the additional fields like this is very crude.
-                -- LOWER(CONCAT(s.name, ' ', s.payout_reference_dfk)) REGEXP LOWER(v.TextSearch)
-                LOWER(CONCAT(s.name, ' ', s.payout_reference_dfk, ' ', s.additional_fields_json)) REGEXP LOWER(v.TextSearch)
-        END
+        -- Todo searching the additional fields like this is very crude.
+        FOLD(CONCAT(s.name, ' ', s.payout_reference_dfk, ' ', s.additional_fields_json)) REGEXP ('(?i)' || FOLD(v.TextSearch))
+        -- END IF
+        -- IF :PayoutReference
         AND
-        CASE
-            WHEN v.PayoutReference = '' OR v.PayoutReference IS NULL THEN
-                TRUE
-            ELSE
-                LOWER(s.payout_reference_dfk) = LOWER(v.PayoutReference)
-        END
-    ORDER BY
-        s.close_date ASC
+        (
+            LOWER(s.payout_reference_dfk) = LOWER(v.PayoutReference)
+            OR
+            EXISTS (
+                SELECT 1 FROM donation_link_records lr
+                WHERE lr.donation_id = s.id AND LOWER(lr.ref) = LOWER(v.PayoutReference)
+            )
+        )
+        -- END IF
+        -- IF :AssignedTo
+        AND
+        asg.assigned_to = v.AssignedTo
+        -- END IF
 )
 
 SELECT
     m.*
+    -- total of the full filtered set, summed before the limit is applied
+    ,SUM(m.amount) OVER () AS sum_amount
 FROM main m
+JOIN variables v
+ORDER BY
+    CASE WHEN v.SortDirection = 'asc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN m.amount
+            WHEN 'contact' THEN LOWER(m.name)
+            WHEN 'status' THEN m.is_linked
+            ELSE m.close_date
+        END
+    END ASC
+    ,CASE WHEN v.SortDirection = 'desc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN m.amount
+            WHEN 'contact' THEN LOWER(m.name)
+            WHEN 'status' THEN m.is_linked
+            ELSE m.close_date
+        END
+    END DESC
+    ,m.close_date ASC
+    ,m.id ASC
 LIMIT
     (SELECT variables.HereLimit FROM variables)
 OFFSET
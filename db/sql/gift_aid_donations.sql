@@ -0,0 +1,69 @@
+/*
+ Reconciler app SQL
+ gift_aid_donations.sql
+ Donations in a period linked to an invoice or bank transaction with a
+ line item in one of the Gift Aid account codes, with the additional
+ fields holding donor details and Gift Aid eligibility.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2026-03-31') AS DateTo    /* @param */
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+
+/* References of the invoices and bank transactions with Gift Aid line
+ * items, within the 60 day window used elsewhere for linking donations.
+ */
+,gift_aid_refs AS (
+    SELECT
+        i.invoice_number AS ref
+    FROM invoices i
+    JOIN invoice_line_items li ON (li.invoice_id = i.id)
+    ,variables v
+    WHERE
+        i.invoice_number IS NOT NULL
+        AND
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        i.date BETWEEN date(v.DateFrom, '-60 day') AND date(v.DateTo, '+60 day')
+        AND
+        li.account_code REGEXP v.AccountCodes
+
+    UNION
+
+    SELECT
+        b.reference AS ref
+    FROM bank_transactions b
+    JOIN bank_transaction_line_items li ON (li.transaction_id = b.id)
+    ,variables v
+    WHERE
+        b.reference IS NOT NULL
+        AND
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        b.date BETWEEN date(v.DateFrom, '-60 day') AND date(v.DateTo, '+60 day')
+        AND
+        li.account_code REGEXP v.AccountCodes
+)
+
+SELECT
+    d.id
+    ,d.name
+    ,d.amount
+    ,d.close_date
+    ,d.payout_reference_dfk
+    ,COALESCE(d.additional_fields_json, '') AS additional_fields_json
+FROM donations d
+JOIN gift_aid_refs g ON (g.ref = d.payout_reference_dfk)
+,variables v
+WHERE
+    d.close_date BETWEEN v.DateFrom AND v.DateTo
+ORDER BY
+    d.close_date ASC
+    ,d.id ASC
+;
@@ -0,0 +1,29 @@
+/*
+ Reconciler app SQL
+ import_batch_insert.sql
+ Record a new staged import batch.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'donations'  AS Kind     /* @param */
+        ,'crm'        AS Target   /* @param */
+        ,'export.csv' AS FileName /* @param */
+)
+INSERT INTO import_batches (
+    kind
+    ,target
+    ,file_name
+    ,status
+)
+SELECT
+    v.Kind
+    ,v.Target
+    ,v.FileName
+    ,'staged'
+FROM
+    variables v
+;
@@ -0,0 +1,27 @@
+/*
+ Reconciler app SQL
+ import_batch_resolve.sql
+ Mark a staged import batch as committed or discarded. Batches which
+ are no longer staged are unchanged.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1           AS ID     /* @param */
+        ,'committed' AS Status /* @param */
+)
+UPDATE
+    import_batches
+SET
+    status       = v.Status
+    ,resolved_at = CURRENT_TIMESTAMP
+FROM
+    variables v
+WHERE
+    import_batches.id = v.ID
+    AND
+    import_batches.status = 'staged'
+;
@@ -0,0 +1,36 @@
+/*
+ Reconciler app SQL
+ import_batches.sql
+ The import batches with the number of their accepted and rejected
+ rows, most recent first. An ID of 0 selects the 50 most recent
+ batches, otherwise the batch with the ID.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS ID /* @param */
+)
+SELECT
+    b.id
+    ,b.kind
+    ,b.target
+    ,b.file_name
+    ,b.status
+    ,b.created_at
+    ,b.resolved_at
+    ,(SELECT count(*) FROM import_rows r WHERE r.batch_id = b.id AND r.error IS NULL) AS accepted
+    ,(SELECT count(*) FROM import_rows r WHERE r.batch_id = b.id AND r.error IS NOT NULL) AS rejected
+FROM
+    import_batches b
+    JOIN variables v
+WHERE
+    v.ID = 0
+    OR
+    b.id = v.ID
+ORDER BY
+    b.id DESC
+LIMIT 50
+;
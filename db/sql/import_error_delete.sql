@@ -0,0 +1,19 @@
+/*
+ Reconciler app SQL
+ import_error_delete.sql
+ Delete an import error, after the record has been stored or the error
+ discarded.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+DELETE FROM
+    import_errors
+WHERE
+    id = (SELECT ID FROM variables)
+;
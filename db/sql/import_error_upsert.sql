@@ -0,0 +1,40 @@
+/*
+ Reconciler app SQL
+ import_error_upsert.sql
+ Record a Xero or Salesforce record which failed validation, replacing
+ the earlier error of a record with the same id.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'xero'          AS Source     /* @param */
+        ,'invoice'       AS RecordType /* @param */
+        ,'inv-001'       AS RecordID   /* @param */
+        ,'no InvoiceID'  AS Error      /* @param */
+        ,'{}'            AS Payload    /* @param */
+)
+INSERT INTO import_errors (
+    source
+    ,record_type
+    ,record_id
+    ,error
+    ,payload
+)
+SELECT
+    v.Source
+    ,v.RecordType
+    ,v.RecordID
+    ,v.Error
+    ,v.Payload
+FROM
+    variables v
+WHERE
+    true
+ON CONFLICT (source, record_type, record_id) WHERE record_id <> '' DO UPDATE SET
+    error       = excluded.error
+    ,payload    = excluded.payload
+    ,created_at = CURRENT_TIMESTAMP
+;
@@ -0,0 +1,34 @@
+/*
+ Reconciler app SQL
+ import_errors.sql
+ The import error with ID or, if ID is 0, the 200 most recent import
+ errors, most recent first.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS ID /* @param */
+)
+SELECT
+    e.id
+    ,e.source
+    ,e.record_type
+    ,e.record_id
+    ,e.error
+    ,e.payload
+    ,e.created_at
+FROM
+    import_errors e
+    JOIN variables v
+WHERE
+    v.ID = 0
+    OR
+    e.id = v.ID
+ORDER BY
+    e.created_at DESC
+    ,e.id DESC
+LIMIT 200
+;
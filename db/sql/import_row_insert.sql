@@ -0,0 +1,37 @@
+/*
+ Reconciler app SQL
+ import_row_insert.sql
+ Record a row of a staged import batch. Accepted rows have a payload
+ and rejected rows an error, an empty error being recorded as null.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1                AS BatchID  /* @param */
+        ,2                AS RowNo    /* @param */
+        ,'1001'           AS RecordID /* @param */
+        ,'["1001"]'       AS Vals     /* @param */
+        ,'{}'             AS Payload  /* @param */
+        ,''               AS Error    /* @param */
+)
+INSERT INTO import_rows (
+    batch_id
+    ,row_no
+    ,record_id
+    ,vals
+    ,payload
+    ,error
+)
+SELECT
+    v.BatchID
+    ,v.RowNo
+    ,v.RecordID
+    ,v.Vals
+    ,v.Payload
+    ,nullif(v.Error, '')
+FROM
+    variables v
+;
@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ import_rows.sql
+ The rows of an import batch in row order.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS BatchID /* @param */
+)
+SELECT
+    r.batch_id
+    ,r.row_no
+    ,r.record_id
+    ,r.vals
+    ,r.payload
+    ,r.error
+FROM
+    import_rows r
+    JOIN variables v ON r.batch_id = v.BatchID
+ORDER BY
+    r.row_no
+;
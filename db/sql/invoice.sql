@@ -10,29 +10,41 @@
 WITH variables AS (
     SELECT
          'inv-unrec-04'  AS InvoiceID    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
         ,'^(53|55|57).*' AS AccountCodes /* @param */
 )
 SELECT
-    *
-    ,CASE WHEN donation_total = crms_total THEN
+    x.*
+    -- the tolerance only applies once donations are linked
+    ,CASE WHEN ABS(donation_total - crms_total)
+        < 0.005 + IIF(crms_total = 0, 0, MAX(t.amount, ABS(donation_total) * t.percent / 100)) THEN
         1
      ELSE
         0
      END AS is_reconciled
     ,total - crms_total AS total_outstanding
+    ,donation_total - crms_total AS variance
 FROM (
     SELECT
         i.id
         ,i.invoice_number
         ,i.date
+        ,i.updated_at
         ,i.type
         ,i.status
         ,i.reference
         ,i.contact
+        ,COALESCE(i.contact_id, '') AS contact_id
         ,i.total
+        ,COALESCE(i.currency_code, '') AS currency_code
+        ,COALESCE(NULLIF(i.currency_rate, 0), 1) AS currency_rate
+        ,ROUND(i.total / COALESCE(NULLIF(i.currency_rate, 0), 1), 2) AS base_total
         ,COALESCE(
             SUM(li.line_amount)
-            FILTER (WHERE li.account_code REGEXP variables.AccountCodes)
+            FILTER (WHERE (
+                li.account_code IN (SELECT code FROM donation_accounts)
+                OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+            ))
             OVER (PARTITION BY i.id)
          , 0) AS donation_total
         ,COALESCE(rds.donation_sum, 0) AS crms_total
@@ -45,7 +57,10 @@ FROM (
         ,li.tax_amount AS li_tax_amount
         ,li.line_amount AS li_line_amount
         ,CASE WHEN
-            li.account_code REGEXP variables.AccountCodes
+            (
+                li.account_code IN (SELECT code FROM donation_accounts)
+                OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP variables.AccountCodes)
+            )
         THEN
             li.line_amount
          ELSE
@@ -63,11 +78,12 @@ FROM (
                 payout_reference_dfk
                 ,sum(amount) AS donation_sum
             FROM
-                donations
+                donation_payouts
             GROUP BY
                 payout_reference_dfk
         ) rds ON (rds.payout_reference_dfk = i.invoice_number)
     WHERE
         variables.InvoiceID = i.id
 ) x
+CROSS JOIN reconciliation_tolerance t
 ;
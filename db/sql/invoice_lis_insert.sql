@@ -27,6 +27,7 @@ INSERT INTO invoice_line_items (
     ,line_amount
     ,account_code
     ,tax_amount
+    ,xero_account_code
 )
 SELECT
     v.LineItemID
@@ -37,6 +38,7 @@ SELECT
     ,v.LineAmount
     ,v.AccountCode
     ,v.TaxAmount
+    ,v.AccountCode
 FROM
     variables v
 ;
@@ -16,9 +16,12 @@ WITH variables AS (
          ,'Example Ref'      AS Reference     /* @param */
          ,499.99             AS Total         /* @param */
          ,498.98             AS AmountPaid    /* @param */
+         ,'GBP'              AS CurrencyCode  /* @param */
+         ,1.0                AS CurrencyRate  /* @param */
          ,date('2025-09-01') AS Date          /* @param */
          ,date('2026-01-01') AS Updated       /* @param */
-         ,'Test User'        AS Contact /* @param */
+         ,'Test User'        AS Contact       /* @param */
+         ,'con-001'          AS ContactID     /* @param */
 )
 INSERT INTO invoices (
 	id
@@ -28,9 +31,12 @@ INSERT INTO invoices (
     ,reference
     ,total
     ,amount_paid
+    ,currency_code
+    ,currency_rate
     ,date
     ,updated_at
     ,contact
+    ,contact_id
 )
 SELECT
     v.InvoiceID
@@ -40,9 +46,12 @@ SELECT
     ,v.Reference
     ,v.Total
     ,v.AmountPaid
+    ,v.CurrencyCode
+    ,v.CurrencyRate
     ,v.Date
     ,v.Updated
     ,v.Contact
+    ,v.ContactID
 FROM
     variables v
 -- sqlite.org/lang_upsert.html PARSING AMBIGUITY
@@ -55,7 +64,10 @@ ON CONFLICT (id) DO UPDATE SET
     ,reference      = excluded.reference
     ,total          = excluded.total
     ,amount_paid    = excluded.amount_paid
+    ,currency_code  = excluded.currency_code
+    ,currency_rate  = excluded.currency_rate
     ,date           = excluded.date
     ,updated_at     = excluded.updated_at
     ,contact        = excluded.contact
+    ,contact_id     = excluded.contact_id
 ;
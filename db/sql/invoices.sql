@@ -5,16 +5,31 @@
 
  Note @param comments declare a template value for middleware replacement.
  Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note IF and END IF comment lines mark blocks omitted for empty arguments.
+ Note TextSearch matches case insensitively, ignoring diacritics.
+ Note SortColumn and SortDirection are validated by the caller, with the
+ date column and ascending order as the default.
+ Note the sum columns total the full filtered set, ahead of the limit and
+ offset, in the base currency.
+ Note totals match if they differ by less than half a penny, as sums of REAL
+ amounts are inexact, or by less than the reconciliation tolerance once
+ donations are linked.
 */
 
 WITH variables AS (
     SELECT
         date('2025-04-01') AS DateFrom   /* @param */
         ,date('2026-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
         ,'^(53|55|57).*' AS AccountCodes /* @param */
         -- All | Reconciled | NotReconciled
         ,'NotReconciled' AS ReconciliationStatus /* @param */
         ,'INV-2025.*Ex.*Corp' AS TextSearch      /* @param */
+        -- the assignee, or empty for any
+        ,'' AS AssignedTo                        /* @param */
+        -- date | amount | contact | status, and asc | desc
+        ,'date' AS SortColumn                    /* @param */
+        ,'asc' AS SortDirection                  /* @param */
         ,10 AS HereLimit                         /* @param */
         ,0 AS HereOffset                         /* @param */
 )
@@ -27,7 +42,10 @@ WITH variables AS (
     JOIN invoices i ON (i.id = li.invoice_id)
     ,variables
     WHERE
-        account_code REGEXP variables.AccountCodes
+        (
+            account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND account_code REGEXP variables.AccountCodes)
+        )
         AND
         i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
         AND
@@ -40,7 +58,7 @@ crms_donation_totals AS (
     SELECT
         payout_reference_dfk
         ,SUM(amount) AS total_crms_amount
-    FROM donations
+    FROM donation_payouts
     JOIN variables
     WHERE
         payout_reference_dfk IS NOT NULL
@@ -50,6 +68,21 @@ crms_donation_totals AS (
         payout_reference_dfk
 )
 
+,invoice_reconciliation AS (
+    SELECT
+        idt.invoice_id
+        ,idt.total_donation_amount AS donation_total
+        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+        -- the tolerance only applies once donations are linked
+        ,ABS(idt.total_donation_amount - COALESCE(cdt.total_crms_amount, 0))
+            < 0.005 + IIF(COALESCE(cdt.total_crms_amount, 0) = 0, 0,
+                MAX(t.amount, ABS(idt.total_donation_amount) * t.percent / 100)) AS is_reconciled
+    FROM invoice_donation_totals idt
+    JOIN invoices i ON i.id = idt.invoice_id
+    LEFT JOIN crms_donation_totals cdt ON i.invoice_number = cdt.payout_reference_dfk
+    CROSS JOIN reconciliation_tolerance t
+)
+
 ,reconciliation_data AS (
     SELECT
         i.id
@@ -58,13 +91,25 @@ crms_donation_totals AS (
         ,i.contact
         ,i.status
         ,i.total
-        ,COALESCE(idt.total_donation_amount, 0) AS donation_total
-        ,COALESCE(cdt.total_crms_amount, 0) AS crms_total
+        ,COALESCE(i.currency_code, '') AS currency_code
+        ,COALESCE(NULLIF(i.currency_rate, 0), 1) AS currency_rate
+        ,ROUND(i.total / COALESCE(NULLIF(i.currency_rate, 0), 1), 2) AS base_total
+        ,ir.donation_total
+        ,ir.crms_total
+        ,ir.donation_total - ir.crms_total AS variance
+        ,ir.is_reconciled
         ,COUNT(*) OVER () AS row_count
+        -- the number of notes on the record, and if any is a flag
+        ,(SELECT COUNT(*) FROM annotations a
+            WHERE a.record_type = 'invoice' AND a.record_id = i.id) AS annotations
+        ,EXISTS (SELECT 1 FROM annotations a
+            WHERE a.record_type = 'invoice' AND a.record_id = i.id AND a.flag) AS flagged
+        -- the team member to whom the record is assigned
+        ,COALESCE(asg.assigned_to, '') AS assigned_to
     FROM invoices i
     JOIN variables v ON i.date BETWEEN v.DateFrom AND v.DateTo
-    LEFT JOIN invoice_donation_totals idt ON i.id = idt.invoice_id
-    LEFT JOIN crms_donation_totals cdt ON i.invoice_number = cdt.payout_reference_dfk
+    JOIN invoice_reconciliation ir ON i.id = ir.invoice_id
+    LEFT JOIN assignments asg ON (asg.record_type = 'invoice' AND asg.record_id = i.id)
     WHERE
         i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
         AND
@@ -75,28 +120,52 @@ crms_donation_totals AS (
             (
                 v.ReconciliationStatus = 'Reconciled'
                  AND
-                 COALESCE(idt.total_donation_amount, 0) = COALESCE(cdt.total_crms_amount, 0)
+                 ir.is_reconciled
             )
             OR
             (
                 v.ReconciliationStatus = 'NotReconciled'
                  AND
-                 COALESCE(idt.total_donation_amount, 0) <> COALESCE(cdt.total_crms_amount, 0)
+                 NOT ir.is_reconciled
             )
         )
+        -- IF :TextSearch
+        AND
+        FOLD(CONCAT(i.invoice_number, ' ', i.reference, ' ', i.contact)) REGEXP ('(?i)' || FOLD(v.TextSearch))
+        -- END IF
+        -- IF :AssignedTo
         AND
-        idt.invoice_id IS NOT NULL
-        AND CASE
-            WHEN v.TextSearch = '' THEN true
-            ELSE LOWER(CONCAT(i.invoice_number, ' ', i.reference, ' ', i.contact)) REGEXP LOWER(v.TextSearch)
-            END
-    ORDER BY
-        i.date ASC
+        asg.assigned_to = v.AssignedTo
+        -- END IF
 )
 SELECT
     r.*
-    ,donation_total = crms_total AS is_reconciled
+    -- totals of the full filtered set, summed before the limit is applied
+    ,SUM(r.base_total) OVER () AS sum_total
+    ,SUM(r.donation_total) OVER () AS sum_donation_total
+    ,SUM(r.crms_total) OVER () AS sum_crms_total
+    ,SUM(r.variance) OVER () AS sum_variance
 FROM reconciliation_data r
+JOIN variables v
+ORDER BY
+    CASE WHEN v.SortDirection = 'asc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN r.base_total
+            WHEN 'contact' THEN LOWER(r.contact)
+            WHEN 'status' THEN r.status
+            ELSE r.date
+        END
+    END ASC
+    ,CASE WHEN v.SortDirection = 'desc' THEN
+        CASE v.SortColumn
+            WHEN 'amount' THEN r.base_total
+            WHEN 'contact' THEN LOWER(r.contact)
+            WHEN 'status' THEN r.status
+            ELSE r.date
+        END
+    END DESC
+    ,r.date ASC
+    ,r.id ASC
 LIMIT
     (SELECT variables.HereLimit FROM variables)
 OFFSET
@@ -0,0 +1,114 @@
+/*
+ Reconciler app SQL
+ line_items_export.sql
+ The line items of the invoices or bank transactions with the ids given
+ as a json array, with the fields of their records, in the order of the
+ ids and then of the line items, for the line item exports. RecordType
+ is "invoice" or "bank-transaction". Records without line items are
+ left out.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoice'       AS RecordType   /* @param */
+        ,'["inv-001"]'   AS RecordIDs    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+,records AS (
+    SELECT
+        j.key AS position
+        ,j.value AS id
+    FROM
+        variables v
+        ,json_each(v.RecordIDs) j
+)
+,items AS (
+    SELECT
+        r.position
+        ,li.rowid AS line_no
+        ,'invoice' AS record_type
+        ,i.id AS record_id
+        ,COALESCE(i.invoice_number, '') AS number
+        ,COALESCE(i.reference, '') AS reference
+        ,i.date
+        ,COALESCE(i.contact, '') AS contact
+        ,COALESCE(i.status, '') AS status
+        ,COALESCE(i.currency_code, '') AS currency_code
+        ,COALESCE(i.total, 0) AS total
+        ,li.account_code
+        ,li.description
+        ,li.quantity
+        ,li.unit_amount
+        ,li.tax_amount
+        ,li.line_amount
+    FROM
+        records r
+        JOIN invoices i ON (i.id = r.id)
+        JOIN invoice_line_items li ON (li.invoice_id = i.id)
+    WHERE
+        (SELECT RecordType FROM variables) = 'invoice'
+    UNION ALL
+    SELECT
+        r.position
+        ,li.rowid AS line_no
+        ,'bank-transaction' AS record_type
+        ,b.id AS record_id
+        ,'' AS number
+        ,COALESCE(b.reference, '') AS reference
+        ,b.date
+        ,COALESCE(b.contact, '') AS contact
+        ,COALESCE(b.status, '') AS status
+        ,COALESCE(b.currency_code, '') AS currency_code
+        ,COALESCE(b.total, 0) AS total
+        ,li.account_code
+        ,li.description
+        ,li.quantity
+        ,li.unit_amount
+        ,li.tax_amount
+        ,li.line_amount
+    FROM
+        records r
+        JOIN bank_transactions b ON (b.id = r.id)
+        JOIN bank_transaction_line_items li ON (li.transaction_id = b.id)
+    WHERE
+        (SELECT RecordType FROM variables) = 'bank-transaction'
+)
+SELECT
+    it.record_type
+    ,it.record_id
+    ,it.number
+    ,it.reference
+    ,it.date
+    ,it.contact
+    ,it.status
+    ,it.currency_code
+    ,it.total
+    ,COALESCE(it.account_code, '') AS account_code
+    ,COALESCE(a.name, '') AS account_name
+    ,COALESCE(it.description, '') AS description
+    ,COALESCE(it.quantity, 0) AS quantity
+    ,COALESCE(it.unit_amount, 0) AS unit_amount
+    ,COALESCE(it.tax_amount, 0) AS tax_amount
+    ,COALESCE(it.line_amount, 0) AS line_amount
+    ,CASE WHEN
+        (
+            it.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND it.account_code REGEXP v.AccountCodes)
+        )
+    THEN
+        1
+     ELSE
+        0
+     END AS donation_account
+FROM
+    items it
+    JOIN variables v
+    LEFT OUTER JOIN accounts a ON (a.code = it.account_code)
+ORDER BY
+    it.position
+    ,it.line_no
+;
@@ -0,0 +1,38 @@
+/*
+ Reconciler app SQL
+ link_checks.sql
+ The donation links of Salesforce donations to existing invoices and bank
+ transactions, to be checked against the remote records. Reference is the
+ matched payout reference of the link, empty for a split.
+
+ A HereLimit of -1 returns all the links, ordered by donation; otherwise
+ a random sample of HereLimit links is returned.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         -1 AS HereLimit /* @param */
+)
+SELECT
+    lr.donation_id
+    ,COALESCE(d.name, '') AS donation_name
+    ,lr.record_type
+    ,lr.record_id
+    ,COALESCE(lr.payout_reference, '') AS reference
+FROM
+    donation_link_records lr
+    JOIN donations d ON (d.id = lr.donation_id)
+    ,variables v
+WHERE
+    d.source = 'salesforce'
+ORDER BY
+    CASE WHEN v.HereLimit < 0 THEN 0 ELSE random() END
+    ,lr.donation_id
+    ,lr.record_type
+    ,lr.record_id
+LIMIT
+    (SELECT HereLimit FROM variables)
+;
@@ -0,0 +1,37 @@
+/*
+ Reconciler app SQL
+ link_mappings.sql
+ The donation links between donations and the invoices and bank
+ transactions they are reconciled against, for export as link mappings.
+ Links are included whether or not the linked records exist, so that the
+ links of records not yet synced are not lost.
+
+ An empty DonationID selects the links of all donations.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '' AS DonationID /* @param */
+)
+
+SELECT
+    l.donation_id
+    ,l.record_type
+    ,l.record_id
+    ,l.payout_reference
+    ,l.amount
+    ,COALESCE(l.linked_by, '') AS linked_by
+    ,l.linked_at
+FROM
+    donation_links l
+    ,variables v
+WHERE
+    v.DonationID IN ('', l.donation_id)
+ORDER BY
+    l.donation_id
+    ,l.record_type
+    ,l.record_id
+;
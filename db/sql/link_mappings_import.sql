@@ -0,0 +1,71 @@
+/*
+ Reconciler app SQL
+ link_mappings_import.sql
+ Import link mappings as donation links, replacing any existing link of
+ each donation to the same record. The mappings are given as a json array
+ of objects with the donation_id, record_type, record_id,
+ payout_reference, amount, linked_by and linked_at keys.
+
+ Mappings of donations, invoices or bank transactions which do not exist
+ are not imported.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '[{"donation_id":"sf-opp-001","record_type":"invoice","record_id":"inv-001"}]' AS Mappings /* @param */
+)
+
+,mappings AS (
+    SELECT
+        json_extract(j.value, '$.donation_id') AS donation_id
+        ,json_extract(j.value, '$.record_type') AS record_type
+        ,json_extract(j.value, '$.record_id') AS record_id
+        ,json_extract(j.value, '$.payout_reference') AS payout_reference
+        ,json_extract(j.value, '$.amount') AS amount
+        ,NULLIF(json_extract(j.value, '$.linked_by'), '') AS linked_by
+        ,datetime(json_extract(j.value, '$.linked_at')) AS linked_at
+    FROM
+        variables v
+        ,json_each(v.Mappings) j
+)
+
+INSERT INTO donation_links (
+    donation_id
+    ,record_type
+    ,record_id
+    ,payout_reference
+    ,amount
+    ,linked_by
+    ,linked_at
+)
+SELECT
+    m.donation_id
+    ,m.record_type
+    ,m.record_id
+    ,m.payout_reference
+    ,m.amount
+    ,COALESCE(m.linked_by, 'Reconciler')
+    ,COALESCE(m.linked_at, CURRENT_TIMESTAMP)
+FROM
+    mappings m
+WHERE
+    EXISTS (SELECT 1 FROM donations d WHERE d.id = m.donation_id)
+    AND
+    (
+        (m.record_type = 'invoice'
+            AND EXISTS (SELECT 1 FROM invoices i WHERE i.id = m.record_id))
+        OR
+        (m.record_type = 'bank-transaction'
+            AND EXISTS (SELECT 1 FROM bank_transactions b WHERE b.id = m.record_id))
+    )
+    AND
+    (m.amount IS NULL OR m.amount > 0)
+ON CONFLICT (donation_id, record_type, record_id) DO UPDATE SET
+    payout_reference = excluded.payout_reference
+    ,amount          = excluded.amount
+    ,linked_by       = excluded.linked_by
+    ,linked_at       = excluded.linked_at
+;
@@ -0,0 +1,196 @@
+/*
+ Reconciler app SQL
+ link_suggestions.sql
+ Suggested donation links for unreconciled invoices and bank transactions.
+
+ Candidate donations are unlinked donations with a close date from six weeks
+ before to two weeks after the invoice or bank transaction date, and with an
+ amount no greater than the amount outstanding. Each candidate is scored
+ between 0 and 1 using the amount match (weighted 0.6) and date proximity
+ (weighted 0.4).
+
+ The record contact is the name of the canonical contact for a contact
+ merged into another as an alias.
+
+ A RecordID restricts the suggestions to those for the invoice or bank
+ transaction with that id, being its candidate donations.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note IF and END IF comment lines mark blocks omitted for empty arguments.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2026-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+        ,0.5 AS MinScore                 /* @param */
+        -- an invoice or bank transaction id, or empty for all
+        ,'' AS RecordID                  /* @param */
+)
+
+,invoice_donation_totals AS (
+    SELECT
+        'invoice' AS typer
+        ,i.id AS record_id
+        ,i.invoice_number AS record_ref
+        ,i.date AS record_date
+        ,COALESCE(
+            (SELECT c.name FROM contact_aliases ca JOIN contacts c ON (c.id = ca.contact_id)
+             WHERE ca.alias = CONTACTKEY(i.contact)),
+            i.contact
+        ) AS record_contact
+        ,SUM(li.line_amount) AS donation_total
+    FROM invoices i
+    JOIN invoice_line_items li ON (li.invoice_id = i.id)
+    ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        i.date BETWEEN v.DateFrom AND v.DateTo
+        -- IF :RecordID
+        AND
+        i.id = v.RecordID
+        -- END IF
+    GROUP BY
+        i.id
+)
+
+,bank_transaction_dupe_refs AS (
+    SELECT
+        b.reference
+    FROM
+        bank_transactions b
+    GROUP BY
+        b.reference
+    HAVING
+        COUNT(*) > 1
+)
+
+,bank_transaction_donation_totals AS (
+    SELECT
+        'bank-transaction' AS typer
+        ,b.id AS record_id
+        ,b.reference AS record_ref
+        ,b.date AS record_date
+        ,COALESCE(
+            (SELECT c.name FROM contact_aliases ca JOIN contacts c ON (c.id = ca.contact_id)
+             WHERE ca.alias = CONTACTKEY(b.contact)),
+            b.contact
+        ) AS record_contact
+        ,SUM(li.line_amount) AS donation_total
+    FROM bank_transactions b
+    JOIN bank_transaction_line_items li ON (li.transaction_id = b.id)
+    ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        b.date BETWEEN v.DateFrom AND v.DateTo
+        AND
+        COALESCE(b.reference, '') <> ''
+        AND
+        b.reference NOT IN (SELECT reference FROM bank_transaction_dupe_refs)
+        -- IF :RecordID
+        AND
+        b.id = v.RecordID
+        -- END IF
+    GROUP BY
+        b.id
+)
+
+,records AS (
+    SELECT * FROM invoice_donation_totals
+    UNION ALL
+    SELECT * FROM bank_transaction_donation_totals
+)
+
+,crms_donation_totals AS (
+    SELECT
+        payout_reference_dfk
+        ,SUM(amount) AS total_crms_amount
+    FROM donation_payouts
+    WHERE
+        COALESCE(payout_reference_dfk, '') <> ''
+    GROUP BY
+        payout_reference_dfk
+)
+
+,outstanding AS (
+    SELECT
+        r.*
+        ,ROUND(r.donation_total - COALESCE(c.total_crms_amount, 0), 2) AS outstanding
+    FROM records r
+    LEFT JOIN crms_donation_totals c ON (c.payout_reference_dfk = r.record_ref)
+    WHERE
+        ROUND(r.donation_total - COALESCE(c.total_crms_amount, 0), 2) > 0
+)
+
+,candidates AS (
+    SELECT
+        o.*
+        ,d.id AS donation_id
+        ,d.name AS donation_name
+        ,d.amount AS donation_amount
+        ,d.close_date AS donation_close_date
+        ,ABS(julianday(date(o.record_date)) - julianday(date(d.close_date))) AS day_diff
+    FROM outstanding o
+    JOIN donations d ON (
+        COALESCE(d.payout_reference_dfk, '') = ''
+        AND
+        NOT EXISTS (
+            SELECT 1 FROM donation_links l WHERE l.donation_id = d.id
+        )
+        AND
+        d.amount > 0
+        AND
+        ROUND(d.amount, 2) <= o.outstanding
+        AND
+        date(d.close_date) BETWEEN date(o.record_date, '-42 day') AND date(o.record_date, '+14 day')
+    )
+)
+
+,scored AS (
+    SELECT
+        c.*
+        ,ROUND(
+            0.6 * CASE
+                WHEN ROUND(c.donation_amount, 2) = c.outstanding THEN 1.0
+                ELSE c.donation_amount / c.outstanding
+            END
+            + 0.4 * (1.0 - MIN(c.day_diff, 42) / 42.0)
+        , 2) AS score
+    FROM candidates c
+)
+
+SELECT
+    s.typer
+    ,s.record_id
+    ,s.record_ref
+    ,s.record_date
+    ,s.record_contact
+    ,s.outstanding
+    ,s.donation_id
+    ,s.donation_name
+    ,s.donation_amount
+    ,s.donation_close_date
+    ,s.score
+FROM scored s
+JOIN variables v ON (s.score >= v.MinScore)
+ORDER BY
+    s.record_date ASC
+    ,s.record_id ASC
+    ,s.score DESC
+    ,s.donation_id ASC
+;
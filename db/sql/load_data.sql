@@ -15,6 +15,7 @@ DELETE FROM invoices;
 DELETE FROM bank_transaction_line_items;
 DELETE FROM bank_transactions;
 DELETE FROM accounts;
+DELETE FROM contacts;
 
 PRAGMA foreign_keys=OFF;
 BEGIN TRANSACTION;
@@ -203,5 +204,18 @@ INSERT INTO "donations" (id, name, amount, close_date, payout_reference_dfk) VAL
 INSERT INTO "donations" (id, name, amount, close_date, payout_reference_dfk) VALUES
 ('sf-opp-odd-02', 'Unlinked Donation', 75.00, datetime('2025-04-30'), null);
 
+-- -----------------------------------------------------------------------------
+-- Contacts
+-- Xero contacts for a donor and payment platforms, associated with the
+-- invoices and bank transactions above by name.
+-- -----------------------------------------------------------------------------
+INSERT INTO "contacts" (id, name, email_address, contact_status, is_customer, is_supplier, updated_at) VALUES
+('con-excorp', 'Example Corp Ltd', 'accounts@example.co', 'ACTIVE', 1, 0, '2025-04-01T09:00:00Z'),
+('con-jg', 'JustGiving', 'payouts@justgiving.co', 'ACTIVE', 1, 0, '2025-04-01T09:00:00Z'),
+('con-stripe', 'Stripe', 'payouts@stripe.co', 'ACTIVE', 1, 0, '2025-04-01T09:00:00Z');
+
+UPDATE "invoices" SET contact_id = (SELECT id FROM contacts c WHERE c.name = invoices.contact);
+UPDATE "bank_transactions" SET contact_id = (SELECT id FROM contacts c WHERE c.name = bank_transactions.contact);
+
 COMMIT;
 PRAGMA foreign_keys=ON;
@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ organisation.sql
+ The Xero organisation record, if it has been retrieved.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+SELECT
+    COALESCE(o.name, '') AS name
+    ,COALESCE(o.legal_name, '') AS legal_name
+    ,COALESCE(o.organisation_type, '') AS organisation_type
+    ,COALESCE(o.timezone, '') AS timezone
+    ,COALESCE(o.shortcode, '') AS shortcode
+    ,COALESCE(o.organisation_id, '') AS organisation_id
+FROM
+    organisation o
+    ,variables v
+WHERE
+    o.id = v.ID
+;
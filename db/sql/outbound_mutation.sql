@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ outbound_mutation.sql
+ An outbound mutation by key.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'abc' AS Key /* @param */
+)
+SELECT
+    m.key
+    ,m.target
+    ,m.operation
+    ,m.status
+    ,coalesce(m.result, '') AS result
+    ,m.attempts
+    ,m.created_at
+    ,m.completed_at
+FROM
+    outbound_mutations m
+    JOIN variables v ON (m.key = v.Key)
+;
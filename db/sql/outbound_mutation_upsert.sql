@@ -0,0 +1,46 @@
+/*
+ Reconciler app SQL
+ outbound_mutation_upsert.sql
+ Record the status of an outbound mutation. A mutation started again
+ has its attempts incremented, and the completion time is set once it
+ has succeeded or failed.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'abc'         AS Key       /* @param */
+        ,'salesforce'  AS Target    /* @param */
+        ,'update refs' AS Operation /* @param */
+        ,'started'     AS Status    /* @param */
+        ,''            AS Result    /* @param */
+)
+INSERT INTO outbound_mutations (
+    key
+    ,target
+    ,operation
+    ,status
+    ,result
+)
+SELECT
+    v.Key
+    ,v.Target
+    ,v.Operation
+    ,v.Status
+    ,nullif(v.Result, '')
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (key) DO UPDATE SET
+    status        = excluded.status
+    ,result       = excluded.result
+    ,attempts     = attempts + (excluded.status = 'started')
+    ,completed_at = CASE
+        WHEN excluded.status = 'started' THEN NULL
+        ELSE CURRENT_TIMESTAMP
+    END
+;
@@ -0,0 +1,131 @@
+/*
+ Reconciler app SQL
+ payout_groups.sql
+ The donations grouped by payout reference, with the count and total of
+ the donations of each reference alongside the count and donation total
+ of the invoices and bank transactions sharing the reference, so that
+ references found only in Salesforce or only in Xero can be spotted.
+ References are included if any of their donations close, or any of
+ their records are dated, in the period; the counts and totals are for
+ all the donations and records of the reference.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note totals match if they differ by less than half a penny, as sums of REAL
+ amounts are inexact, or by less than the reconciliation tolerance once
+ donations are linked.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2026-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+
+,donation_groups AS (
+    SELECT
+        d.payout_reference_dfk AS reference
+        ,COUNT(*) AS donation_count
+        ,SUM(d.amount) AS donation_total
+        ,MAX(date(d.close_date) BETWEEN v.DateFrom AND v.DateTo) AS in_period
+        ,MAX(date(d.close_date)) AS last_close_date
+    FROM
+        donations d
+        JOIN variables v
+    WHERE
+        COALESCE(d.payout_reference_dfk, '') <> ''
+    GROUP BY
+        d.payout_reference_dfk
+)
+
+/* The invoices and bank transactions with donation line items, with their
+ * donation totals.
+ */
+,records AS (
+    SELECT
+        i.invoice_number AS reference
+        ,i.date
+        ,SUM(li.line_amount) AS donation_total
+    FROM
+        invoices i
+        JOIN invoice_line_items li ON (li.invoice_id = i.id)
+        ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        COALESCE(i.invoice_number, '') <> ''
+    GROUP BY
+        i.id
+
+    UNION ALL
+
+    SELECT
+        b.reference
+        ,b.date
+        ,SUM(li.line_amount) AS donation_total
+    FROM
+        bank_transactions b
+        JOIN bank_transaction_line_items li ON (li.transaction_id = b.id)
+        ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        COALESCE(b.reference, '') <> ''
+    GROUP BY
+        b.id
+)
+
+,record_groups AS (
+    SELECT
+        r.reference
+        ,COUNT(*) AS record_count
+        ,SUM(r.donation_total) AS record_total
+        ,MAX(date(r.date) BETWEEN v.DateFrom AND v.DateTo) AS in_period
+        ,MIN(date(r.date)) AS first_record_date
+    FROM
+        records r
+        JOIN variables v
+    GROUP BY
+        r.reference
+)
+
+,references_in_period AS (
+    SELECT reference FROM donation_groups WHERE in_period
+    UNION
+    SELECT reference FROM record_groups WHERE in_period
+)
+
+SELECT
+    p.reference
+    -- the date of the first record of the reference, else its last donation
+    ,COALESCE(rg.first_record_date, dg.last_close_date) AS date
+    ,COALESCE(dg.donation_count, 0) AS donation_count
+    ,ROUND(COALESCE(dg.donation_total, 0), 2) AS donation_total
+    ,COALESCE(rg.record_count, 0) AS record_count
+    ,ROUND(COALESCE(rg.record_total, 0), 2) AS record_total
+    ,dg.reference IS NOT NULL AND rg.reference IS NOT NULL
+        -- the tolerance only applies once donations are found
+        AND ABS(rg.record_total - dg.donation_total)
+            < 0.005 + IIF(dg.donation_total = 0, 0,
+                MAX(t.amount, ABS(rg.record_total) * t.percent / 100)) AS is_matched
+FROM
+    references_in_period p
+    CROSS JOIN reconciliation_tolerance t
+    LEFT JOIN donation_groups dg ON (dg.reference = p.reference)
+    LEFT JOIN record_groups rg ON (rg.reference = p.reference)
+ORDER BY
+    date ASC
+    ,p.reference ASC
+;
@@ -0,0 +1,62 @@
+/*
+ Reconciler app SQL
+ payout_item_insert.sql
+ Insert an item of a platform payout report, replacing an item with the
+ same reference in the same payout.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'JG-PAYOUT-2025-04-15' AS PayoutReference  /* @param */
+        ,'justgiving'           AS Platform         /* @param */
+        ,'JG-PAYOUT-2025-04-15' AS PlatformPayoutID /* @param */
+        ,'JG-1001'              AS ItemRef          /* @param */
+        ,'2025-04-15'           AS Date             /* @param */
+        ,'Jane Smith'           AS Name             /* @param */
+        ,''                     AS Description      /* @param */
+        ,20.00                  AS Gross            /* @param */
+        ,0.50                   AS Fee              /* @param */
+        ,19.50                  AS Net              /* @param */
+)
+INSERT INTO payout_items (
+    payout_reference
+    ,platform
+    ,platform_payout_id
+    ,item_ref
+    ,date
+    ,name
+    ,description
+    ,gross
+    ,fee
+    ,net
+)
+SELECT
+    v.PayoutReference
+    ,v.Platform
+    ,NULLIF(v.PlatformPayoutID, '')
+    ,v.ItemRef
+    ,v.Date
+    ,v.Name
+    ,v.Description
+    ,v.Gross
+    ,v.Fee
+    ,v.Net
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (payout_reference, item_ref) DO UPDATE SET
+    platform = excluded.platform
+    ,platform_payout_id = excluded.platform_payout_id
+    ,date = excluded.date
+    ,name = excluded.name
+    ,description = excluded.description
+    ,gross = excluded.gross
+    ,fee = excluded.fee
+    ,net = excluded.net
+    ,imported_at = CURRENT_TIMESTAMP
+;
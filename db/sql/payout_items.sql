@@ -0,0 +1,65 @@
+/*
+ Reconciler app SQL
+ payout_items.sql
+ The imported report items of a payout, each matched to a donation
+ counted against the payout reference with the same amount.
+
+ Items and donations of the same amount are paired in date order, so
+ that each donation is matched to at most one item. Items without a
+ donation are those missing from Salesforce or not yet linked.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'JG-PAYOUT-2025-04-15' AS PayoutReference /* @param */
+)
+
+,items AS (
+    SELECT
+        p.*
+        ,ROW_NUMBER() OVER (
+            PARTITION BY ROUND(p.gross, 2) ORDER BY p.date, p.item_ref
+        ) AS amount_rank
+    FROM payout_items p
+    JOIN variables v ON (p.payout_reference = v.PayoutReference)
+)
+
+,donations_counted AS (
+    SELECT
+        dp.id
+        ,COALESCE(d.name, '') AS name
+        ,dp.amount
+        ,ROW_NUMBER() OVER (
+            PARTITION BY ROUND(dp.amount, 2) ORDER BY dp.close_date, dp.id
+        ) AS amount_rank
+    FROM donation_payouts dp
+    JOIN donations d ON (d.id = dp.id)
+    JOIN variables v ON (dp.payout_reference_dfk = v.PayoutReference)
+)
+
+SELECT
+    i.id
+    ,i.payout_reference
+    ,i.platform
+    ,COALESCE(i.platform_payout_id, '') AS platform_payout_id
+    ,i.item_ref
+    ,i.date
+    ,COALESCE(i.name, '') AS name
+    ,COALESCE(i.description, '') AS description
+    ,i.gross
+    ,i.fee
+    ,i.net
+    ,i.imported_at
+    ,dc.id AS donation_id
+    ,dc.name AS donation_name
+FROM items i
+LEFT JOIN donations_counted dc ON (
+    ROUND(dc.amount, 2) = ROUND(i.gross, 2) AND dc.amount_rank = i.amount_rank
+)
+ORDER BY
+    i.date ASC
+    ,i.item_ref ASC
+;
@@ -0,0 +1,18 @@
+/*
+ Reconciler app SQL
+ payout_items_delete.sql
+ Delete the imported report items of a payout.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'JG-PAYOUT-2025-04-15' AS PayoutReference /* @param */
+)
+DELETE FROM
+    payout_items
+WHERE
+    payout_reference = (SELECT PayoutReference FROM variables)
+;
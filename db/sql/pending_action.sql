@@ -0,0 +1,31 @@
+/*
+ Reconciler app SQL
+ pending_action.sql
+ A pending link action by id.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+SELECT
+    p.id
+    ,p.action
+    ,p.description
+    ,p.payload
+    ,p.step
+    ,p.status
+    ,p.attempts
+    ,coalesce(p.last_error, '') AS last_error
+    ,p.created_at
+    ,p.updated_at
+    ,EXISTS (
+        SELECT 1 FROM salesforce_outbox o WHERE o.pending_action_id = p.id
+    ) AS queued
+FROM
+    pending_actions p
+    JOIN variables v ON (p.id = v.ID)
+;
@@ -0,0 +1,32 @@
+/*
+ Reconciler app SQL
+ pending_action_insert.sql
+ Record a new pending link action.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'link'             AS Action      /* @param */
+        ,'link 2 donations' AS Description /* @param */
+        ,'{}'               AS Payload     /* @param */
+        ,'salesforce'       AS Step        /* @param */
+)
+INSERT INTO pending_actions (
+    action
+    ,description
+    ,payload
+    ,step
+    ,status
+)
+SELECT
+    v.Action
+    ,v.Description
+    ,v.Payload
+    ,v.Step
+    ,'pending'
+FROM
+    variables v
+;
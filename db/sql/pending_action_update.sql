@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ pending_action_update.sql
+ Record the progress of a pending link action. The attempts are
+ incremented for each failure, and the last error is kept until the
+ action is done or compensated.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1              AS ID        /* @param */
+        ,'salesforce'   AS Step      /* @param */
+        ,'failed'       AS Status    /* @param */
+        ,'update error' AS LastError /* @param */
+)
+UPDATE
+    pending_actions
+SET
+    step        = v.Step
+    ,status     = v.Status
+    ,attempts   = attempts + (v.Status = 'failed')
+    ,last_error = CASE
+        WHEN v.Status = 'failed' THEN v.LastError
+        WHEN v.Status IN ('done', 'compensated') THEN NULL
+        ELSE last_error
+    END
+    ,updated_at = CURRENT_TIMESTAMP
+FROM
+    variables v
+WHERE
+    pending_actions.id = v.ID
+;
@@ -0,0 +1,40 @@
+/*
+ Reconciler app SQL
+ pending_actions.sql
+ The pending link actions, most recent first. With a Status of open
+ only the actions which are pending or failed are returned, otherwise
+ all actions are returned. Queued actions have Salesforce updates
+ awaiting Salesforce becoming reachable.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'open' AS Status /* @param */
+)
+SELECT
+    p.id
+    ,p.action
+    ,p.description
+    ,p.payload
+    ,p.step
+    ,p.status
+    ,p.attempts
+    ,coalesce(p.last_error, '') AS last_error
+    ,p.created_at
+    ,p.updated_at
+    ,EXISTS (
+        SELECT 1 FROM salesforce_outbox o WHERE o.pending_action_id = p.id
+    ) AS queued
+FROM
+    pending_actions p
+    JOIN variables v
+WHERE
+    v.Status = 'all'
+    OR
+    p.status IN ('pending', 'failed')
+ORDER BY
+    p.id DESC
+;
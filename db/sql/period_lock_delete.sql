@@ -0,0 +1,18 @@
+/*
+ Reconciler app SQL
+ period_lock_delete.sql
+ Delete a period lock, opening the period again.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+DELETE FROM
+    period_locks
+WHERE
+    id = (SELECT ID FROM variables)
+;
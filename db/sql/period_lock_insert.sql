@@ -0,0 +1,28 @@
+/*
+ Reconciler app SQL
+ period_lock_insert.sql
+ Close the period from DateFrom to DateTo inclusive.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '2024-04-01'           AS DateFrom /* @param */
+        ,'2025-03-31'           AS DateTo   /* @param */
+        ,'year end accounts'    AS Reason   /* @param */
+)
+
+INSERT INTO period_locks (
+    date_from
+    ,date_to
+    ,reason
+)
+SELECT
+    v.DateFrom
+    ,v.DateTo
+    ,v.Reason
+FROM
+    variables v
+;
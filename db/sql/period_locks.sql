@@ -0,0 +1,32 @@
+/*
+ Reconciler app SQL
+ period_locks.sql
+ The period locks closing periods to links, unlinks and write-backs,
+ latest period first. An ID of 0 selects all locks, otherwise the lock
+ with the ID.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS ID /* @param */
+)
+SELECT
+    l.id
+    ,l.date_from
+    ,l.date_to
+    ,l.reason
+    ,l.locked_at
+FROM
+    period_locks l
+    JOIN variables v
+WHERE
+    v.ID = 0
+    OR
+    l.id = v.ID
+ORDER BY
+    l.date_from DESC
+    ,l.id DESC
+;
@@ -0,0 +1,69 @@
+/*
+ Reconciler app SQL
+ period_locks_covering.sql
+ The period locks covering the dates of the records affected by a link,
+ unlink or write-back, being the donations with the DonationIDs, the
+ invoices and bank transactions with the RecordIDs, and those with an
+ invoice number or reference in Refs. Each argument is a json array.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '["sf-opp-001"]'   AS DonationIDs /* @param */
+        ,'["inv-unrec-04"]' AS RecordIDs   /* @param */
+        ,'["INV-2025-106"]' AS Refs        /* @param */
+)
+
+,record_dates AS (
+    SELECT
+        date(d.close_date) AS date
+    FROM
+        donations d
+        JOIN variables v
+    WHERE
+        d.id IN (SELECT j.value FROM json_each(v.DonationIDs) j)
+    UNION
+    SELECT
+        date(i.date)
+    FROM
+        invoices i
+        JOIN variables v
+    WHERE
+        i.id IN (SELECT j.value FROM json_each(v.RecordIDs) j)
+        OR
+        i.invoice_number IN (SELECT j.value FROM json_each(v.Refs) j)
+    UNION
+    SELECT
+        date(b.date)
+    FROM
+        bank_transactions b
+        JOIN variables v
+    WHERE
+        b.id IN (SELECT j.value FROM json_each(v.RecordIDs) j)
+        OR
+        b.reference IN (SELECT j.value FROM json_each(v.Refs) j)
+)
+
+SELECT
+    l.id
+    ,l.date_from
+    ,l.date_to
+    ,l.reason
+    ,l.locked_at
+FROM
+    period_locks l
+WHERE
+    EXISTS (
+        SELECT 1
+        FROM
+            record_dates r
+        WHERE
+            r.date BETWEEN date(l.date_from) AND date(l.date_to)
+    )
+ORDER BY
+    l.date_from DESC
+    ,l.id DESC
+;
@@ -0,0 +1,28 @@
+/*
+ Reconciler app SQL
+ period_snapshot_donations.sql
+ The donations counted against each of the payout references in Refs, a
+ json array, with the amount counted, for recording in and comparing with
+ period snapshots.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '["INV-2025-101"]' AS Refs /* @param */
+)
+SELECT
+    p.payout_reference_dfk AS ref
+    ,p.id AS donation_id
+    ,p.amount
+FROM
+    donation_payouts p
+    JOIN variables v
+WHERE
+    p.payout_reference_dfk IN (SELECT j.value FROM json_each(v.Refs) j)
+ORDER BY
+    p.payout_reference_dfk
+    ,p.id
+;
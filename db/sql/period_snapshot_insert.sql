@@ -0,0 +1,50 @@
+/*
+ Reconciler app SQL
+ period_snapshot_insert.sql
+ Record the reconciliation state of the period of a period lock when it
+ is locked. Records is a json array of the reconciled records.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1              AS PeriodLockID               /* @param */
+        ,'2024-04-01'   AS DateFrom                   /* @param */
+        ,'2025-03-31'   AS DateTo                     /* @param */
+        ,12             AS Invoices                   /* @param */
+        ,10             AS InvoicesReconciled         /* @param */
+        ,8              AS BankTransactions           /* @param */
+        ,7              AS BankTransactionsReconciled /* @param */
+        ,4520.50        AS DonationTotal              /* @param */
+        ,4100.00        AS ReconciledTotal            /* @param */
+        ,'[]'           AS Records                    /* @param */
+)
+
+INSERT INTO period_snapshots (
+    period_lock_id
+    ,date_from
+    ,date_to
+    ,invoices
+    ,invoices_reconciled
+    ,bank_transactions
+    ,bank_transactions_reconciled
+    ,donation_total
+    ,reconciled_total
+    ,records
+)
+SELECT
+    v.PeriodLockID
+    ,v.DateFrom
+    ,v.DateTo
+    ,v.Invoices
+    ,v.InvoicesReconciled
+    ,v.BankTransactions
+    ,v.BankTransactionsReconciled
+    ,v.DonationTotal
+    ,v.ReconciledTotal
+    ,v.Records
+FROM
+    variables v
+;
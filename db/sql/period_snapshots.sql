@@ -0,0 +1,39 @@
+/*
+ Reconciler app SQL
+ period_snapshots.sql
+ The snapshots of the reconciliation state of periods when they were
+ locked, latest first. An ID of 0 selects all snapshots, otherwise the
+ snapshot with the ID.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         0 AS ID /* @param */
+)
+SELECT
+    s.id
+    ,s.period_lock_id
+    ,s.date_from
+    ,s.date_to
+    ,s.invoices
+    ,s.invoices_reconciled
+    ,s.bank_transactions
+    ,s.bank_transactions_reconciled
+    ,s.donation_total
+    ,s.reconciled_total
+    ,s.records
+    ,s.created_at
+FROM
+    period_snapshots s
+    JOIN variables v
+WHERE
+    v.ID = 0
+    OR
+    s.id = v.ID
+ORDER BY
+    s.created_at DESC
+    ,s.id DESC
+;
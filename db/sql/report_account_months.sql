@@ -0,0 +1,130 @@
+/*
+ Reconciler app SQL
+ report_account_months.sql
+ Donation totals by account code and month for a period, compared with the
+ totals of the CRM donations linked to the invoices and bank transactions of
+ each line item.
+
+ The linked donations of a record are shared between its line items in
+ proportion to their amounts, so that a record with line items in several
+ account codes contributes to each.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2026-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+)
+
+,line_items AS (
+    SELECT
+        'invoice' AS record_type
+        ,i.id AS record_id
+        ,i.invoice_number AS ref
+        ,strftime('%Y-%m', i.date) AS month
+        ,li.account_code
+        ,li.line_amount
+    FROM invoice_line_items li
+    JOIN invoices i ON (i.id = li.invoice_id)
+    ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        i.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        i.date BETWEEN v.DateFrom AND v.DateTo
+
+    UNION ALL
+
+    SELECT
+        'bank-transaction' AS record_type
+        ,b.id AS record_id
+        ,b.reference AS ref
+        ,strftime('%Y-%m', b.date) AS month
+        ,li.account_code
+        ,li.line_amount
+    FROM bank_transaction_line_items li
+    JOIN bank_transactions b ON (b.id = li.transaction_id)
+    ,variables v
+    WHERE
+        (
+            li.account_code IN (SELECT code FROM donation_accounts)
+            OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+        )
+        AND
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        b.date BETWEEN v.DateFrom AND v.DateTo
+)
+
+,record_totals AS (
+    SELECT
+        record_type
+        ,record_id
+        ,ref
+        ,SUM(line_amount) AS donation_total
+    FROM line_items
+    GROUP BY
+        record_type, record_id
+)
+
+,crms_donation_totals AS (
+    SELECT
+        payout_reference_dfk
+        ,SUM(amount) AS total_crms_amount
+    FROM donation_payouts
+    JOIN variables
+    WHERE
+        payout_reference_dfk IS NOT NULL
+        AND
+        close_date BETWEEN date(variables.DateFrom,'-60 day') AND date(variables.DateTo, '+60 day')
+    GROUP BY
+        payout_reference_dfk
+)
+
+,record_reconciliation AS (
+    SELECT
+        rt.record_type
+        ,rt.record_id
+        ,rt.donation_total
+        ,COALESCE(cdt.total_crms_amount, 0) AS linked_total
+        -- the tolerance only applies once donations are linked
+        ,ABS(rt.donation_total - COALESCE(cdt.total_crms_amount, 0))
+            < 0.005 + IIF(COALESCE(cdt.total_crms_amount, 0) = 0, 0,
+                MAX(t.amount, ABS(rt.donation_total) * t.percent / 100)) AS is_reconciled
+    FROM record_totals rt
+    CROSS JOIN reconciliation_tolerance t
+    LEFT JOIN crms_donation_totals cdt ON (cdt.payout_reference_dfk = rt.ref)
+)
+
+SELECT
+    li.account_code
+    ,COALESCE(MAX(a.name), '') AS account_name
+    ,li.month
+    ,COUNT(DISTINCT li.record_type || li.record_id) AS record_count
+    ,ROUND(SUM(li.line_amount), 2) AS total
+    ,ROUND(SUM(
+        CASE WHEN rr.donation_total = 0 THEN 0
+        ELSE rr.linked_total * li.line_amount / rr.donation_total END
+    ), 2) AS linked_total
+    ,COUNT(DISTINCT CASE WHEN rr.is_reconciled THEN li.record_type || li.record_id END) AS reconciled_count
+    ,ROUND(SUM(CASE WHEN rr.is_reconciled THEN li.line_amount ELSE 0 END), 2) AS reconciled_total
+FROM line_items li
+JOIN record_reconciliation rr ON (
+    rr.record_type = li.record_type AND rr.record_id = li.record_id
+)
+LEFT JOIN accounts a ON (a.code = li.account_code)
+GROUP BY
+    li.account_code, li.month
+ORDER BY
+    li.account_code ASC
+    ,li.month ASC
+;
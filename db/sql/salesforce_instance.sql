@@ -0,0 +1,21 @@
+/*
+ Reconciler app SQL
+ salesforce_instance.sql
+ The Salesforce instance url, if it has been recorded.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+SELECT
+    COALESCE(s.sf_instance_url, '') AS sf_instance_url
+FROM
+    system s
+    ,variables v
+WHERE
+    s.id = v.ID
+;
@@ -0,0 +1,30 @@
+/*
+ Reconciler app SQL
+ salesforce_instance_upsert.sql
+ Upsert the Salesforce instance url, used for deep links to Salesforce records.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1                            AS ID          /* @param */
+         ,'example.my.salesforce.com' AS InstanceURL /* @param */
+)
+
+INSERT INTO system (
+    id
+    ,sf_instance_url
+)
+SELECT
+    v.ID
+    ,v.InstanceURL
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (id) DO UPDATE SET
+    sf_instance_url = excluded.sf_instance_url
+;
@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ salesforce_outbox_actions.sql
+ The ids of the open pending link actions with queued Salesforce
+ updates, oldest first, up to HereLimit actions.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         100 AS HereLimit /* @param */
+)
+SELECT DISTINCT
+    o.pending_action_id AS id
+FROM
+    salesforce_outbox o
+    JOIN pending_actions p ON (p.id = o.pending_action_id)
+WHERE
+    p.status IN ('pending', 'failed')
+ORDER BY
+    o.pending_action_id ASC
+LIMIT
+    (SELECT variables.HereLimit FROM variables)
+;
@@ -0,0 +1,18 @@
+/*
+ Reconciler app SQL
+ salesforce_outbox_clear.sql
+ Remove the queued Salesforce updates of a pending link action.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+DELETE FROM
+    salesforce_outbox
+WHERE
+    pending_action_id = (SELECT ID FROM variables)
+;
@@ -0,0 +1,33 @@
+/*
+ Reconciler app SQL
+ salesforce_outbox_insert.sql
+ Queue the payout reference updates of a pending link action for which
+ Salesforce was unreachable. The updates are given as a json array of
+ objects with ID and Ref keys.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1                                    AS ID     /* @param */
+        ,'[{"ID":"sf-opp-001","Ref":"INV-1"}]' AS IDRefs /* @param */
+)
+INSERT INTO salesforce_outbox (
+    pending_action_id
+    ,donation_id
+    ,payout_reference
+)
+SELECT
+    v.ID
+    ,json_extract(j.value, '$.ID')
+    ,COALESCE(json_extract(j.value, '$.Ref'), '')
+FROM
+    variables v
+    ,json_each(v.IDRefs) j
+WHERE
+    true
+ON CONFLICT (pending_action_id, donation_id) DO UPDATE SET
+    payout_reference = excluded.payout_reference
+;
@@ -0,0 +1,21 @@
+/*
+ Reconciler app SQL
+ saved_search_default.sql
+ Make the named saved search the only default for its page.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoices'     AS Page /* @param */
+        ,'Unreconciled' AS Name /* @param */
+)
+UPDATE
+    saved_searches
+SET
+    is_default = (name = (SELECT Name FROM variables))
+WHERE
+    page = (SELECT Page FROM variables)
+;
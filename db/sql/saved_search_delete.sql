@@ -0,0 +1,21 @@
+/*
+ Reconciler app SQL
+ saved_search_delete.sql
+ Delete a saved search from a listing page.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoices' AS Page /* @param */
+        ,1          AS ID   /* @param */
+)
+DELETE FROM
+    saved_searches
+WHERE
+    (page, id) = (
+        SELECT Page, ID FROM variables
+    )
+;
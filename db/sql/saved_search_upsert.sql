@@ -0,0 +1,39 @@
+/*
+ Reconciler app SQL
+ saved_search_upsert.sql
+ Upsert a saved search for a listing page, replacing the params of a
+ saved search of the same name. If the saved search is the default,
+ the default of any other saved search for the page is cleared.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoices'             AS Page      /* @param */
+        ,'Unreconciled'         AS Name      /* @param */
+        ,'status=NotReconciled' AS Params    /* @param */
+        ,1                      AS IsDefault /* @param */
+)
+
+INSERT INTO saved_searches (
+    page
+    ,name
+    ,params
+    ,is_default
+)
+SELECT
+    v.Page
+    ,v.Name
+    ,v.Params
+    ,v.IsDefault
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (page, name) DO UPDATE SET
+    params      = excluded.params
+    ,is_default = excluded.is_default
+;
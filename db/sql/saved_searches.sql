@@ -0,0 +1,26 @@
+/*
+ Reconciler app SQL
+ saved_searches.sql
+ The saved searches for a listing page, with the default first.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'invoices' AS Page /* @param */
+)
+SELECT
+    s.id
+    ,s.page
+    ,s.name
+    ,s.params
+    ,s.is_default
+FROM
+    saved_searches s
+    JOIN variables v ON (s.page = v.Page)
+ORDER BY
+    s.is_default DESC
+    ,s.name
+;
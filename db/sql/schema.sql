@@ -9,11 +9,30 @@ CREATE TABLE IF NOT EXISTS system (
     -- data refresh metadata
     ,sf_data_refreshed DATETIME
     ,xero_data_refreshed DATETIME
+
+    -- salesforce instance url for deep links to records
+    ,sf_instance_url TEXT
+
+    -- reconciliation tolerance, as an amount and a percentage of the donation total
+    ,tolerance_amount  REAL DEFAULT 0
+    ,tolerance_percent REAL DEFAULT 0
 );
 
 -- Ensure only one row for applicaton state.
 CREATE UNIQUE INDEX IF NOT EXISTS idx_single_row ON system ((1));
 
+-- reconciliation_tolerance always has a single row, which is zero if no tolerance has
+-- been set. Donation and CRMS totals are reconciled if they differ by less than the
+-- larger of the amount and percentage tolerances. The tolerance does not apply to a
+-- record with no linked donations, whose donation total must be zero.
+CREATE VIEW IF NOT EXISTS reconciliation_tolerance AS
+SELECT
+    COALESCE(MAX(tolerance_amount), 0) AS amount
+    ,COALESCE(MAX(tolerance_percent), 0) AS percent
+FROM
+    system
+;
+
 -- organisation holds selected Xero organisation information.
 CREATE TABLE IF NOT EXISTS organisation (
     id                        INTEGER PRIMARY KEY -- Enforce only one row with id=1 for desktop
@@ -37,15 +56,27 @@ CREATE TABLE IF NOT EXISTS bank_transactions (
     ,status              TEXT
     ,reference           TEXT
     ,total               REAL
+    ,currency_code       TEXT
+    ,currency_rate       REAL DEFAULT 1 -- units of currency_code per base currency unit
     ,date                DATETIME
     ,updated_at          DATETIME
     ,contact             TEXT
+    ,contact_id          TEXT
     ,bank_account        TEXT
     ,bank_account_id     TEXT
     /* reconciliation status relating to donations */
     ,is_reconciled       INTEGER DEFAULT 0 -- INTEGER 0 for false 1 for true
 );
 
+-- The listings filter bank transactions by date, and match donations to them by
+-- reference.
+CREATE INDEX IF NOT EXISTS idx_bank_transactions_date
+    ON bank_transactions (date);
+CREATE INDEX IF NOT EXISTS idx_bank_transactions_reference
+    ON bank_transactions (reference);
+CREATE INDEX IF NOT EXISTS idx_bank_transactions_contact
+    ON bank_transactions (contact);
+
 -- Xero bank transaction line items.
 CREATE TABLE IF NOT EXISTS bank_transaction_line_items (
     id              TEXT PRIMARY KEY
@@ -56,9 +87,17 @@ CREATE TABLE IF NOT EXISTS bank_transaction_line_items (
     ,line_amount     REAL
     ,account_code    TEXT -- consider linking to accounts
     ,tax_amount      REAL
+    -- the account code from Xero, which account_code replaces if a rule matches
+    ,xero_account_code TEXT
+    ,account_rule_id   INTEGER
     ,FOREIGN KEY(transaction_id) REFERENCES bank_transactions(id) ON DELETE CASCADE
 );
 
+CREATE INDEX IF NOT EXISTS idx_bank_transaction_line_items_transaction
+    ON bank_transaction_line_items (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_bank_transaction_line_items_account_code
+    ON bank_transaction_line_items (account_code);
+
 -- Xero invoices.
 CREATE TABLE IF NOT EXISTS invoices (
     id                  TEXT PRIMARY KEY
@@ -68,13 +107,23 @@ CREATE TABLE IF NOT EXISTS invoices (
     ,reference           TEXT
     ,total               REAL
     ,amount_paid         REAL
+    ,currency_code       TEXT
+    ,currency_rate       REAL DEFAULT 1 -- units of currency_code per base currency unit
     ,date                DATETIME
     ,updated_at          DATETIME
     ,contact             TEXT
+    ,contact_id          TEXT
     /* reconciliation status relating to donations */
     ,is_reconciled       INTEGER DEFAULT 0 -- INTEGER 0 for false 1 for true
 );
 
+CREATE INDEX IF NOT EXISTS idx_invoices_date
+    ON invoices (date);
+CREATE INDEX IF NOT EXISTS idx_invoices_invoice_number
+    ON invoices (invoice_number);
+CREATE INDEX IF NOT EXISTS idx_invoices_contact
+    ON invoices (contact);
+
 -- Xero invoice line items.
 CREATE TABLE IF NOT EXISTS invoice_line_items (
     id              TEXT PRIMARY KEY
@@ -85,9 +134,34 @@ CREATE TABLE IF NOT EXISTS invoice_line_items (
     ,line_amount     REAL
     ,account_code    TEXT -- consider linking to accounts
     ,tax_amount      REAL
+    -- the account code from Xero, which account_code replaces if a rule matches
+    ,xero_account_code TEXT
+    ,account_rule_id   INTEGER
     ,FOREIGN KEY(invoice_id) REFERENCES invoices(id) ON DELETE CASCADE
 );
 
+-- Line items are replaced by invoice on each sync, and the search index triggers
+-- gather the descriptions of each invoice.
+CREATE INDEX IF NOT EXISTS idx_invoice_line_items_invoice
+    ON invoice_line_items (invoice_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_line_items_account_code
+    ON invoice_line_items (account_code);
+
+-- account_rules classify line items by a regular expression matched against
+-- the line item description or the contact of its invoice or bank
+-- transaction, such as treating "PayPal Giving Fund" as account 5501. The
+-- first matching rule by id sets the account_code of the line item, with
+-- the Xero account code kept in xero_account_code, so that the donation
+-- account matching and the reports use the rule's account code. Rules are
+-- evaluated as line items are upserted and when the rules change.
+CREATE TABLE IF NOT EXISTS account_rules (
+    id            INTEGER PRIMARY KEY
+    ,field        TEXT NOT NULL CHECK (field IN ('description', 'contact'))
+    ,pattern      TEXT NOT NULL CHECK (pattern <> '')
+    ,account_code TEXT NOT NULL CHECK (account_code <> '')
+    ,created_at   DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Xero accounts.
 CREATE TABLE IF NOT EXISTS accounts (
    id             TEXT PRIMARY KEY
@@ -100,8 +174,56 @@ CREATE TABLE IF NOT EXISTS accounts (
    ,system_account TEXT
    ,currency_code  TEXT
    ,updated_at     DATETIME
+   -- 1 if selected as a donation account on the accounts settings page, 0 if
+   -- not, and NULL if no selection has been made, when the configured donation
+   -- account prefixes apply
+   ,donation_account INTEGER
+);
+
+-- donation_accounts are the account codes of the accounts and line items
+-- matching the donation account codes regular expression, maintained as the
+-- records and settings change, so that the queries match the codes without
+-- evaluating the regular expression for every line item. The queries fall
+-- back to the regular expression while the table is empty.
+CREATE TABLE IF NOT EXISTS donation_accounts (
+    code TEXT PRIMARY KEY
 );
 
+-- Xero contacts, such as donors and payment platforms.
+CREATE TABLE IF NOT EXISTS contacts (
+    id              TEXT PRIMARY KEY
+    ,name           TEXT
+    ,first_name     TEXT
+    ,last_name      TEXT
+    ,email_address  TEXT
+    ,contact_status TEXT
+    ,is_customer    INTEGER DEFAULT 0 -- INTEGER 0 for false 1 for true
+    ,is_supplier    INTEGER DEFAULT 0
+    ,updated_at     DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_contacts_name
+    ON contacts (name);
+
+-- contact_aliases merge the names under which a contact appears into a
+-- canonical contact, as payment platforms appear under inconsistent names
+-- such as "Stripe Payments UK" and "STRIPE". The alias is a contact name
+-- normalised by the CONTACTKEY function, which folds the case,
+-- diacritics, punctuation and company suffixes of the name, and name is
+-- the name as entered. The records of an alias are listed with those of
+-- the canonical contact, and the account rules matching the canonical
+-- contact's name also match the alias. There is no foreign key to the
+-- contacts, as for the donation links.
+CREATE TABLE IF NOT EXISTS contact_aliases (
+    alias       TEXT PRIMARY KEY CHECK (alias <> '')
+    ,name       TEXT NOT NULL
+    ,contact_id TEXT NOT NULL
+    ,created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_contact_aliases_contact
+    ON contact_aliases (contact_id);
+
 -- Salesforce opportunities are also known as "donations" when a charity
 -- is using the Salesforce non-profit success pack (NPSP).
 CREATE TABLE IF NOT EXISTS donations (
@@ -115,4 +237,573 @@ CREATE TABLE IF NOT EXISTS donations (
     ,last_modified_date      DATETIME
     ,last_modified_by        TEXT
     ,additional_fields_json  TEXT -- JSON blob for ancillary fields
+    ,source                  TEXT NOT NULL DEFAULT 'salesforce' -- or the tag of an import
+);
+
+-- Donations are listed by close date and matched to invoices and bank transactions by
+-- payout reference.
+CREATE INDEX IF NOT EXISTS idx_donations_close_date
+    ON donations (close_date);
+CREATE INDEX IF NOT EXISTS idx_donations_payout_reference
+    ON donations (payout_reference_dfk);
+
+-- Donation links join donations to the invoices and bank transactions
+-- they are reconciled against. Links are made from the payout reference
+-- of a donation matching the invoice number or bank transaction
+-- reference, recording the matched payout_reference, so that they
+-- survive later edits of the Xero reference. Links with an amount are
+-- splits, allocating part of a donation so that it may be split across
+-- several payouts; a link without an amount is for the whole donation.
+-- There is no foreign key to donations as the snapshot import reloads
+-- tables in name order; links of deleted donations are ignored by the
+-- joins below.
+CREATE TABLE IF NOT EXISTS donation_links (
+    id                  INTEGER PRIMARY KEY
+    ,donation_id         TEXT NOT NULL
+    ,record_type         TEXT NOT NULL CHECK (record_type IN ('invoice', 'bank-transaction'))
+    ,record_id           TEXT NOT NULL
+    ,payout_reference    TEXT -- the matched payout reference, null for splits
+    ,amount              REAL CHECK (amount IS NULL OR amount > 0)
+    ,linked_by           TEXT
+    ,linked_at           DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,UNIQUE (donation_id, record_type, record_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_donation_links_record
+    ON donation_links (record_type, record_id);
+
+-- donation_link_records are the donation links to invoices and bank
+-- transactions which exist, with the current invoice number or bank
+-- transaction reference of the linked record as ref.
+CREATE VIEW IF NOT EXISTS donation_link_records AS
+SELECT
+    l.*
+    ,COALESCE(i.invoice_number, b.reference) AS ref
+FROM
+    donation_links l
+    LEFT OUTER JOIN invoices i
+        ON (l.record_type = 'invoice' AND i.id = l.record_id)
+    LEFT OUTER JOIN bank_transactions b
+        ON (l.record_type = 'bank-transaction' AND b.id = l.record_id)
+WHERE
+    i.id IS NOT NULL OR b.id IS NOT NULL
+;
+
+-- donation_payouts provides the donation amounts counted against each
+-- payout reference. Donations without links are counted by their
+-- payout reference and linked donations by the current reference of
+-- each linked record. A donation is counted at most once for each
+-- reference, even if linked to both an invoice and a bank transaction
+-- sharing the reference.
+CREATE VIEW IF NOT EXISTS donation_payouts AS
+SELECT
+    d.id
+    ,d.payout_reference_dfk
+    ,d.amount
+    ,d.close_date
+FROM
+    donations d
+WHERE
+    NOT EXISTS (
+        SELECT 1 FROM donation_links l WHERE l.donation_id = d.id
+    )
+UNION ALL
+SELECT
+    d.id
+    ,lr.ref AS payout_reference_dfk
+    ,MIN(d.amount, SUM(COALESCE(lr.amount, d.amount))) AS amount
+    ,d.close_date
+FROM
+    donation_link_records lr
+    JOIN donations d ON (d.id = lr.donation_id)
+GROUP BY
+    d.id
+    ,lr.ref
+;
+
+-- saved_searches holds named filter presets for the invoices, bank
+-- transactions and donations listing pages. The params are the url
+-- query parameters of the filter, such as status, dates and search
+-- string. Each page has at most one default preset, which is used when
+-- the page is visited without filters.
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id          INTEGER PRIMARY KEY
+    ,page       TEXT NOT NULL CHECK (page IN ('invoices', 'bank-transactions', 'donations'))
+    ,name       TEXT NOT NULL CHECK (name <> '')
+    ,params     TEXT NOT NULL
+    ,is_default BOOLEAN NOT NULL DEFAULT false
+    ,created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,UNIQUE (page, name)
+);
+
+-- pending_actions records the progress of link actions, which update
+-- Salesforce, optionally Xero, and then the local records in turn. The
+-- payload holds the action as json, including the previous references
+-- needed to reverse it. An action is pending while it runs or if it was
+-- interrupted, failed at step if a step failed, and is otherwise done or
+-- compensated, having had its completed steps reversed. Open actions,
+-- which are pending or failed, may be retried from step or compensated.
+CREATE TABLE IF NOT EXISTS pending_actions (
+    id           INTEGER PRIMARY KEY
+    ,action      TEXT NOT NULL CHECK (action IN ('link', 'unlink'))
+    ,description TEXT NOT NULL
+    ,payload     TEXT NOT NULL
+    ,step        TEXT NOT NULL
+    ,status      TEXT NOT NULL CHECK (status IN ('pending', 'failed', 'done', 'compensated'))
+    ,attempts    INTEGER NOT NULL DEFAULT 0 -- the number of failed attempts
+    ,last_error  TEXT
+    ,created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_actions_status
+    ON pending_actions (status);
+
+-- outbound_mutations records the updates made to Xero and Salesforce by
+-- the hash of the update, which is scoped to the pending action making
+-- it. An update is started before it is sent and succeeded or failed
+-- once its result is known, so that a retried update which had already
+-- succeeded, or which was interrupted after being sent, is not sent
+-- again. The result of a succeeded update is kept as json.
+CREATE TABLE IF NOT EXISTS outbound_mutations (
+    key           TEXT PRIMARY KEY
+    ,target       TEXT NOT NULL CHECK (target IN ('xero', 'salesforce'))
+    ,operation    TEXT NOT NULL
+    ,status       TEXT NOT NULL CHECK (status IN ('started', 'succeeded', 'failed'))
+    ,result       TEXT
+    ,attempts     INTEGER NOT NULL DEFAULT 1
+    ,created_at   DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,completed_at DATETIME
+);
+
+-- salesforce_outbox queues the payout reference updates of link actions
+-- which could not be sent as Salesforce was unreachable. The updates are
+-- held by donation so that the affected donations can be shown as
+-- awaiting a remote update, and are removed once the salesforce step of
+-- the action has been retried successfully or the action is closed.
+CREATE TABLE IF NOT EXISTS salesforce_outbox (
+    id                 INTEGER PRIMARY KEY
+    ,pending_action_id INTEGER NOT NULL REFERENCES pending_actions(id)
+    ,donation_id       TEXT NOT NULL
+    ,payout_reference  TEXT NOT NULL -- empty for an unlink
+    ,queued_at         DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,UNIQUE (pending_action_id, donation_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_salesforce_outbox_donation
+    ON salesforce_outbox (donation_id);
+
+-- donation_orphans flags donations which are no longer in Salesforce,
+-- found by comparing the local donation ids with Salesforce. The reason
+-- is deleted for donations in the Salesforce recycle bin, and missing
+-- for those otherwise not found, such as purged or merged records, or
+-- records no longer matched by the Salesforce query. As for the
+-- donation links there is no foreign key to donations; flags of removed
+-- donations are ignored by the joins.
+CREATE TABLE IF NOT EXISTS donation_orphans (
+    donation_id  TEXT PRIMARY KEY
+    ,reason      TEXT NOT NULL CHECK (reason IN ('deleted', 'missing'))
+    ,detected_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- payout_items are the donations itemised in the payout reports of
+-- donation platforms such as Stripe and JustGiving, imported from CSV
+-- to explain the composition of a payout where the Salesforce donations
+-- are incomplete. Items are held against the reference of the bank
+-- transaction of the payout, and are replaced when the report for the
+-- payout is imported again. The fee is the positive amount deducted
+-- from the gross amount, leaving the net amount paid out.
+CREATE TABLE IF NOT EXISTS payout_items (
+    id                  INTEGER PRIMARY KEY
+    ,payout_reference   TEXT NOT NULL
+    ,platform           TEXT NOT NULL CHECK (platform IN ('stripe', 'justgiving'))
+    ,platform_payout_id TEXT
+    ,item_ref           TEXT NOT NULL
+    ,date               DATETIME
+    ,name               TEXT
+    ,description        TEXT
+    ,gross              REAL NOT NULL
+    ,fee                REAL NOT NULL DEFAULT 0
+    ,net                REAL NOT NULL
+    ,imported_at        DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,UNIQUE (payout_reference, item_ref)
+);
+
+-- statement_lines are the lines of bank statements imported from CSV,
+-- compared with the Xero bank transactions and the donations counted
+-- against them so that money received which never reached Xero, or
+-- reached it incorrectly, can be found. Lines are held against the name
+-- of the bank account of the statement and identified by the line_ref,
+-- the id of the line in the statement or else one derived from its
+-- contents, so that importing an overlapping statement updates the lines
+-- imported before. Amounts are positive for money received.
+CREATE TABLE IF NOT EXISTS statement_lines (
+    id            INTEGER PRIMARY KEY
+    ,bank_account TEXT NOT NULL
+    ,line_ref     TEXT NOT NULL
+    ,date         DATETIME NOT NULL
+    ,amount       REAL NOT NULL
+    ,description  TEXT
+    ,reference    TEXT
+    ,imported_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,UNIQUE (bank_account, line_ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_statement_lines_date
+    ON statement_lines (date);
+
+-- import_batches stage the rows of an import, such as a donations export
+-- from another CRM, a platform payout report or a bank statement, for
+-- review before they are committed to the live tables. The target
+-- identifies where the rows are committed, being the source tag of
+-- imported donations, the reference of the payout of a payout report or
+-- the bank account of a statement. A batch is staged until it is
+-- committed or discarded.
+CREATE TABLE IF NOT EXISTS import_batches (
+    id           INTEGER PRIMARY KEY
+    ,kind        TEXT NOT NULL CHECK (kind IN ('donations', 'payout-items', 'statement-lines'))
+    ,target      TEXT NOT NULL
+    ,file_name   TEXT NOT NULL
+    ,status      TEXT NOT NULL DEFAULT 'staged' CHECK (status IN ('staged', 'committed', 'discarded'))
+    ,created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,resolved_at DATETIME
+);
+
+-- import_rows are the rows of an import batch. The values are the json
+-- encoded row as read. Accepted rows have a payload, the json encoded
+-- record to be committed, while rejected rows have the validation error
+-- instead. As for the donation links there is no foreign key to the
+-- batches.
+CREATE TABLE IF NOT EXISTS import_rows (
+    batch_id   INTEGER NOT NULL
+    ,row_no    INTEGER NOT NULL
+    ,record_id TEXT NOT NULL DEFAULT ''
+    ,vals      TEXT NOT NULL
+    ,payload   TEXT
+    ,error     TEXT
+    ,PRIMARY KEY (batch_id, row_no)
+);
+
+-- import_errors are the records retrieved from Xero or Salesforce which
+-- failed validation, such as for a missing id or a date outside the
+-- range of sane dates, and so were quarantined rather than upserted. The
+-- payload is the json encoded record and the error the validation
+-- problems. A record which fails validation again replaces its earlier
+-- error, records without an id being recorded each time.
+CREATE TABLE IF NOT EXISTS import_errors (
+    id           INTEGER PRIMARY KEY
+    ,source      TEXT NOT NULL CHECK (source IN ('xero', 'salesforce'))
+    ,record_type TEXT NOT NULL CHECK (record_type IN ('account', 'contact', 'invoice', 'bank-transaction', 'donation'))
+    ,record_id   TEXT NOT NULL DEFAULT ''
+    ,error       TEXT NOT NULL
+    ,payload     TEXT NOT NULL
+    ,created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_import_errors_record
+    ON import_errors (source, record_type, record_id) WHERE record_id <> '';
+
+-- annotations are the notes and flags users add to invoices, bank
+-- transactions and donations, such as "query with fundraising team".
+-- A flag is a note marking the record for attention, shown prominently
+-- in the listings until it is removed. As for the donation links there
+-- is no foreign key to the annotated records; annotations of removed
+-- records are ignored by the joins.
+CREATE TABLE IF NOT EXISTS annotations (
+    id           INTEGER PRIMARY KEY
+    ,record_type TEXT NOT NULL CHECK (record_type IN ('invoice', 'bank-transaction', 'donation'))
+    ,record_id   TEXT NOT NULL
+    ,note        TEXT NOT NULL CHECK (note <> '')
+    ,flag        BOOLEAN NOT NULL DEFAULT false
+    ,created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_record
+    ON annotations (record_type, record_id);
+
+-- assignments record the team member to whom an unreconciled invoice,
+-- bank transaction or donation is assigned, so that a small team can
+-- split the reconciliation work. A record has at most one assignee, and
+-- unassigning a record removes its row. As for the annotations there is
+-- no foreign key to the assigned records.
+CREATE TABLE IF NOT EXISTS assignments (
+    record_type  TEXT NOT NULL CHECK (record_type IN ('invoice', 'bank-transaction', 'donation'))
+    ,record_id   TEXT NOT NULL
+    ,assigned_to TEXT NOT NULL CHECK (assigned_to <> '')
+    ,assigned_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    ,PRIMARY KEY (record_type, record_id)
+);
+
+-- period_locks close the periods from date_from to date_to inclusive,
+-- such as a financial year whose accounts have been finalised. Links,
+-- unlinks and write-backs for records dated in a closed period are
+-- refused unless overridden with a reason, recorded in audit_events.
+CREATE TABLE IF NOT EXISTS period_locks (
+    id         INTEGER PRIMARY KEY
+    ,date_from DATE NOT NULL
+    ,date_to   DATE NOT NULL CHECK (date_to >= date_from)
+    ,reason    TEXT NOT NULL DEFAULT ''
+    ,locked_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- audit_events record the locking and unlocking of periods and the
+-- overrides of period locks for corrections. The period lock id is kept
+-- after the lock is removed; the description records what was done.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id              INTEGER PRIMARY KEY
+    ,event          TEXT NOT NULL CHECK (event IN ('lock', 'unlock', 'override'))
+    ,period_lock_id INTEGER
+    ,description    TEXT NOT NULL
+    ,reason         TEXT NOT NULL DEFAULT ''
+    ,created_at     DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- period_snapshots record the reconciliation state of a period when it
+-- is locked, so that changes made after the close can be shown. The
+-- counts and totals are of the invoices and bank transactions dated in
+-- the period, and records is a json array of the reconciled records
+-- with a hash of their reconciled values. The snapshot is kept after its
+-- period lock is removed.
+CREATE TABLE IF NOT EXISTS period_snapshots (
+    id                            INTEGER PRIMARY KEY
+    ,period_lock_id               INTEGER NOT NULL
+    ,date_from                    DATE NOT NULL
+    ,date_to                      DATE NOT NULL
+    ,invoices                     INTEGER NOT NULL DEFAULT 0
+    ,invoices_reconciled          INTEGER NOT NULL DEFAULT 0
+    ,bank_transactions            INTEGER NOT NULL DEFAULT 0
+    ,bank_transactions_reconciled INTEGER NOT NULL DEFAULT 0
+    ,donation_total               REAL NOT NULL DEFAULT 0
+    ,reconciled_total             REAL NOT NULL DEFAULT 0
+    ,records                      TEXT NOT NULL DEFAULT '[]'
+    ,created_at                   DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- sync_runs record each refresh of the Xero or Salesforce records, with
+-- the number of api requests made and, in entities, a json array of the
+-- numbers of each type of record fetched, inserted, updated, skipped and
+-- failed, so that problems such as a refresh retrieving no records are
+-- noticeable. The error is that which stopped the refresh, if any.
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id            INTEGER PRIMARY KEY
+    ,source       TEXT NOT NULL CHECK (source IN ('xero', 'salesforce'))
+    ,full_refresh BOOLEAN NOT NULL DEFAULT false
+    ,started_at   DATETIME NOT NULL
+    ,finished_at  DATETIME NOT NULL
+    ,api_calls    INTEGER NOT NULL DEFAULT 0
+    ,entities     TEXT NOT NULL DEFAULT '[]'
+    ,error        TEXT NOT NULL DEFAULT ''
+);
+
+-- search_index is the full-text index of invoices, bank transactions and
+-- donations, covering invoice numbers, references, contact names, line
+-- item descriptions and donation names. Rows are keyed by the rowid of
+-- the indexed record, multiplied by four and offset by 0 for invoices,
+-- 1 for bank transactions and 2 for donations, so that the triggers
+-- below can maintain the index without scanning it. The indexed text has
+-- its diacritics removed by the FOLD function, as the tokenizer does not
+-- fold letters such as ø and ß, and search queries are folded in the same
+-- way.
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5 (
+    record_type UNINDEXED
+    ,record_id  UNINDEXED
+    ,reference
+    ,name
+    ,description
+    ,tokenize = 'unicode61 remove_diacritics 2'
 );
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_insert
+AFTER INSERT ON invoices
+BEGIN
+    INSERT INTO search_index (rowid, record_type, record_id, reference, name, description)
+    SELECT
+        NEW.rowid * 4
+        ,'invoice'
+        ,NEW.id
+        ,FOLD(concat_ws(' ', NEW.invoice_number, NEW.reference))
+        ,FOLD(NEW.contact)
+        ,FOLD((SELECT group_concat(li.description, ' ') FROM invoice_line_items li WHERE li.invoice_id = NEW.id))
+    ;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_update
+AFTER UPDATE OF invoice_number, reference, contact ON invoices
+BEGIN
+    UPDATE search_index SET
+        reference = FOLD(concat_ws(' ', NEW.invoice_number, NEW.reference))
+        ,name     = FOLD(NEW.contact)
+    WHERE rowid = NEW.rowid * 4;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_delete
+AFTER DELETE ON invoices
+BEGIN
+    DELETE FROM search_index WHERE rowid = OLD.rowid * 4;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_li_insert
+AFTER INSERT ON invoice_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM invoice_line_items li WHERE li.invoice_id = NEW.invoice_id))
+    WHERE rowid = (SELECT i.rowid * 4 FROM invoices i WHERE i.id = NEW.invoice_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_li_update
+AFTER UPDATE OF description ON invoice_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM invoice_line_items li WHERE li.invoice_id = NEW.invoice_id))
+    WHERE rowid = (SELECT i.rowid * 4 FROM invoices i WHERE i.id = NEW.invoice_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_invoice_li_delete
+AFTER DELETE ON invoice_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM invoice_line_items li WHERE li.invoice_id = OLD.invoice_id))
+    WHERE rowid = (SELECT i.rowid * 4 FROM invoices i WHERE i.id = OLD.invoice_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_insert
+AFTER INSERT ON bank_transactions
+BEGIN
+    INSERT INTO search_index (rowid, record_type, record_id, reference, name, description)
+    SELECT
+        NEW.rowid * 4 + 1
+        ,'bank-transaction'
+        ,NEW.id
+        ,FOLD(NEW.reference)
+        ,FOLD(NEW.contact)
+        ,FOLD((SELECT group_concat(li.description, ' ') FROM bank_transaction_line_items li WHERE li.transaction_id = NEW.id))
+    ;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_update
+AFTER UPDATE OF reference, contact ON bank_transactions
+BEGIN
+    UPDATE search_index SET
+        reference = FOLD(NEW.reference)
+        ,name     = FOLD(NEW.contact)
+    WHERE rowid = NEW.rowid * 4 + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_delete
+AFTER DELETE ON bank_transactions
+BEGIN
+    DELETE FROM search_index WHERE rowid = OLD.rowid * 4 + 1;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_li_insert
+AFTER INSERT ON bank_transaction_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM bank_transaction_line_items li WHERE li.transaction_id = NEW.transaction_id))
+    WHERE rowid = (SELECT b.rowid * 4 + 1 FROM bank_transactions b WHERE b.id = NEW.transaction_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_li_update
+AFTER UPDATE OF description ON bank_transaction_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM bank_transaction_line_items li WHERE li.transaction_id = NEW.transaction_id))
+    WHERE rowid = (SELECT b.rowid * 4 + 1 FROM bank_transactions b WHERE b.id = NEW.transaction_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_bank_transaction_li_delete
+AFTER DELETE ON bank_transaction_line_items
+BEGIN
+    UPDATE search_index SET
+        description = FOLD((SELECT group_concat(li.description, ' ') FROM bank_transaction_line_items li WHERE li.transaction_id = OLD.transaction_id))
+    WHERE rowid = (SELECT b.rowid * 4 + 1 FROM bank_transactions b WHERE b.id = OLD.transaction_id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_donation_insert
+AFTER INSERT ON donations
+BEGIN
+    INSERT INTO search_index (rowid, record_type, record_id, reference, name, description)
+    VALUES (NEW.rowid * 4 + 2, 'donation', NEW.id, FOLD(NEW.payout_reference_dfk), FOLD(NEW.name), NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_donation_update
+AFTER UPDATE OF name, payout_reference_dfk ON donations
+BEGIN
+    UPDATE search_index SET
+        reference = FOLD(NEW.payout_reference_dfk)
+        ,name     = FOLD(NEW.name)
+    WHERE rowid = NEW.rowid * 4 + 2;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_index_donation_delete
+AFTER DELETE ON donations
+BEGIN
+    DELETE FROM search_index WHERE rowid = OLD.rowid * 4 + 2;
+END;
+
+-- Index records held before the search index was added.
+INSERT INTO search_index (rowid, record_type, record_id, reference, name, description)
+SELECT
+    i.rowid * 4
+    ,'invoice'
+    ,i.id
+    ,FOLD(concat_ws(' ', i.invoice_number, i.reference))
+    ,FOLD(i.contact)
+    ,FOLD((SELECT group_concat(li.description, ' ') FROM invoice_line_items li WHERE li.invoice_id = i.id))
+FROM
+    invoices i
+WHERE
+    NOT EXISTS (SELECT 1 FROM search_index)
+UNION ALL
+SELECT
+    b.rowid * 4 + 1
+    ,'bank-transaction'
+    ,b.id
+    ,FOLD(b.reference)
+    ,FOLD(b.contact)
+    ,FOLD((SELECT group_concat(li.description, ' ') FROM bank_transaction_line_items li WHERE li.transaction_id = b.id))
+FROM
+    bank_transactions b
+WHERE
+    NOT EXISTS (SELECT 1 FROM search_index)
+UNION ALL
+SELECT
+    d.rowid * 4 + 2
+    ,'donation'
+    ,d.id
+    ,FOLD(d.payout_reference_dfk)
+    ,FOLD(d.name)
+    ,NULL
+FROM
+    donations d
+WHERE
+    NOT EXISTS (SELECT 1 FROM search_index)
+;
+
+-- annotation_index is the full-text index of the annotations, searched
+-- alongside the search_index. Rows are keyed by the annotation rowid.
+CREATE VIRTUAL TABLE IF NOT EXISTS annotation_index USING fts5 (
+    record_type UNINDEXED
+    ,record_id  UNINDEXED
+    ,note
+    ,tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS annotation_index_insert
+AFTER INSERT ON annotations
+BEGIN
+    INSERT INTO annotation_index (rowid, record_type, record_id, note)
+    VALUES (NEW.rowid, NEW.record_type, NEW.record_id, FOLD(NEW.note));
+END;
+
+CREATE TRIGGER IF NOT EXISTS annotation_index_update
+AFTER UPDATE OF note ON annotations
+BEGIN
+    UPDATE annotation_index SET note = FOLD(NEW.note) WHERE rowid = NEW.rowid;
+END;
+
+CREATE TRIGGER IF NOT EXISTS annotation_index_delete
+AFTER DELETE ON annotations
+BEGIN
+    DELETE FROM annotation_index WHERE rowid = OLD.rowid;
+END;
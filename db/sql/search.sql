@@ -0,0 +1,97 @@
+/*
+ Reconciler app SQL
+ search.sql
+ Full-text search of invoices, bank transactions and donations, ranked
+ by relevance. Matches in references are weighted above those in names
+ and notes, which are weighted above those in line item descriptions.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         '"gift"*' AS Query     /* @param */
+        ,50        AS HereLimit /* @param */
+),
+index_matches AS (
+    SELECT
+        s.record_type
+        ,s.record_id
+        ,snippet(search_index, -1, '', '', '…', 12) AS snippet
+        ,bm25(search_index, 0, 0, 10.0, 5.0, 1.0) AS rank
+    FROM
+        search_index s
+        JOIN variables v
+    WHERE
+        search_index MATCH v.Query
+    UNION ALL
+    SELECT
+        a.record_type
+        ,a.record_id
+        ,snippet(annotation_index, -1, '', '', '…', 12)
+        ,bm25(annotation_index, 0, 0, 5.0)
+    FROM
+        annotation_index a
+        JOIN variables v
+    WHERE
+        annotation_index MATCH v.Query
+),
+-- the best match of each record, as a record may match in several notes
+matches AS (
+    SELECT
+        record_type
+        ,record_id
+        ,snippet
+        ,MIN(rank) AS rank
+    FROM
+        index_matches
+    GROUP BY
+        record_type
+        ,record_id
+    ORDER BY
+        rank
+    LIMIT
+        (SELECT variables.HereLimit FROM variables)
+)
+SELECT
+    m.record_type
+    ,m.record_id
+    ,COALESCE(i.invoice_number, '') AS reference
+    ,COALESCE(i.contact, '') AS name
+    ,i.date
+    ,ROUND(i.total / COALESCE(NULLIF(i.currency_rate, 0), 1), 2) AS amount
+    ,m.snippet
+    ,m.rank
+FROM
+    matches m
+    JOIN invoices i ON (m.record_type = 'invoice' AND i.id = m.record_id)
+UNION ALL
+SELECT
+    m.record_type
+    ,m.record_id
+    ,COALESCE(b.reference, '')
+    ,COALESCE(b.contact, '')
+    ,b.date
+    ,ROUND(b.total / COALESCE(NULLIF(b.currency_rate, 0), 1), 2)
+    ,m.snippet
+    ,m.rank
+FROM
+    matches m
+    JOIN bank_transactions b ON (m.record_type = 'bank-transaction' AND b.id = m.record_id)
+UNION ALL
+SELECT
+    m.record_type
+    ,m.record_id
+    ,COALESCE(d.payout_reference_dfk, '')
+    ,COALESCE(d.name, '')
+    ,d.close_date
+    ,d.amount
+    ,m.snippet
+    ,m.rank
+FROM
+    matches m
+    JOIN donations d ON (m.record_type = 'donation' AND d.id = m.record_id)
+ORDER BY
+    rank
+;
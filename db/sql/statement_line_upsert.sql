@@ -0,0 +1,46 @@
+/*
+ Reconciler app SQL
+ statement_line_upsert.sql
+ Insert a bank statement line, updating the line with the same reference
+ in the statement of the same bank account.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'Current Account'          AS BankAccount /* @param */
+        ,'2025-04-15/337.25/1'      AS LineRef     /* @param */
+        ,'2025-04-15'               AS Date        /* @param */
+        ,337.25                     AS Amount      /* @param */
+        ,'JUSTGIVING PAYOUT'        AS Description /* @param */
+        ,'JG-PAYOUT-2025-04-15'     AS Reference   /* @param */
+)
+INSERT INTO statement_lines (
+    bank_account
+    ,line_ref
+    ,date
+    ,amount
+    ,description
+    ,reference
+)
+SELECT
+    v.BankAccount
+    ,v.LineRef
+    ,v.Date
+    ,v.Amount
+    ,v.Description
+    ,NULLIF(v.Reference, '')
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (bank_account, line_ref) DO UPDATE SET
+    date = excluded.date
+    ,amount = excluded.amount
+    ,description = excluded.description
+    ,reference = excluded.reference
+    ,imported_at = CURRENT_TIMESTAMP
+;
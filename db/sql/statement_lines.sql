@@ -0,0 +1,35 @@
+/*
+ Reconciler app SQL
+ statement_lines.sql
+ The imported bank statement lines of money received in the period, in
+ date order.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         date('2025-04-01') AS DateFrom /* @param */
+        ,date('2026-03-31') AS DateTo   /* @param */
+)
+SELECT
+    s.id
+    ,s.bank_account
+    ,s.line_ref
+    ,s.date
+    ,s.amount
+    ,COALESCE(s.description, '') AS description
+    ,COALESCE(s.reference, '') AS reference
+    ,s.imported_at
+FROM
+    statement_lines s
+    ,variables v
+WHERE
+    date(s.date) BETWEEN v.DateFrom AND v.DateTo
+    AND
+    s.amount > 0
+ORDER BY
+    s.date ASC
+    ,s.id ASC
+;
@@ -0,0 +1,123 @@
+/*
+ Reconciler app SQL
+ statement_matches.sql
+ The imported bank statement lines of money received in the period, each
+ with the Xero bank transaction matching it and the donation totals of the
+ transaction in Xero and Salesforce, so that money received which never
+ reached Xero, or reached it without its donations, can be found.
+
+ A line is matched to a bank transaction of the same base amount dated
+ within DayWindow days of the line, preferring a transaction of the same
+ bank account and then the nearest in date. Lines for identical amounts
+ received on nearby days may be matched to the same transaction.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+ Note totals match if they differ by less than half a penny, as sums of REAL
+ amounts are inexact, or by less than the reconciliation tolerance once
+ donations are linked.
+*/
+
+WITH variables AS (
+    SELECT
+        date('2025-04-01') AS DateFrom   /* @param */
+        ,date('2026-03-31') AS DateTo    /* @param */
+        -- the donation account codes, only used if donation_accounts is empty
+        ,'^(53|55|57).*' AS AccountCodes /* @param */
+        ,5 AS DayWindow                  /* @param */
+)
+
+,lines AS (
+    SELECT
+        s.*
+    FROM
+        statement_lines s
+        ,variables v
+    WHERE
+        date(s.date) BETWEEN v.DateFrom AND v.DateTo
+        AND
+        s.amount > 0
+)
+
+,candidates AS (
+    SELECT
+        l.id AS line_id
+        ,b.id AS transaction_id
+        ,ROW_NUMBER() OVER (
+            PARTITION BY l.id
+            ORDER BY
+                LOWER(COALESCE(b.bank_account, '')) = LOWER(l.bank_account) DESC
+                ,ABS(julianday(date(b.date)) - julianday(date(l.date))) ASC
+                ,b.id ASC
+        ) AS rn
+    FROM
+        lines l
+        JOIN bank_transactions b
+            ON ABS(b.total / COALESCE(NULLIF(b.currency_rate, 0), 1) - l.amount) < 0.005
+        ,variables v
+    WHERE
+        b.status NOT IN ('DRAFT', 'DELETED', 'VOIDED')
+        AND
+        ABS(julianday(date(b.date)) - julianday(date(l.date))) <= v.DayWindow
+)
+
+,transaction_donation_totals AS (
+    SELECT
+        li.transaction_id
+        ,SUM(li.line_amount) AS donation_total
+    FROM
+        bank_transaction_line_items li
+        JOIN candidates c ON (c.transaction_id = li.transaction_id AND c.rn = 1)
+        ,variables v
+    WHERE
+        li.account_code IN (SELECT code FROM donation_accounts)
+        OR (NOT EXISTS (SELECT 1 FROM donation_accounts) AND li.account_code REGEXP v.AccountCodes)
+    GROUP BY
+        li.transaction_id
+)
+
+,crms_donation_totals AS (
+    SELECT
+        payout_reference_dfk
+        ,SUM(amount) AS crms_total
+    FROM
+        donation_payouts
+    WHERE
+        payout_reference_dfk IN (
+            SELECT b.reference
+            FROM candidates c JOIN bank_transactions b ON (b.id = c.transaction_id)
+            WHERE c.rn = 1
+        )
+    GROUP BY
+        payout_reference_dfk
+)
+
+SELECT
+    l.id
+    ,l.bank_account
+    ,l.line_ref
+    ,l.date
+    ,l.amount
+    ,COALESCE(l.description, '') AS description
+    ,COALESCE(l.reference, '') AS reference
+    ,b.id AS transaction_id
+    ,NULLIF(b.reference, '') AS transaction_reference
+    ,b.date AS transaction_date
+    ,ROUND(COALESCE(tdt.donation_total, 0), 2) AS donation_total
+    ,ROUND(COALESCE(cdt.crms_total, 0), 2) AS crms_total
+    ,b.id IS NOT NULL
+        -- the tolerance only applies once donations are linked
+        AND ABS(COALESCE(tdt.donation_total, 0) - COALESCE(cdt.crms_total, 0))
+            < 0.005 + IIF(COALESCE(cdt.crms_total, 0) = 0, 0,
+                MAX(t.amount, ABS(COALESCE(tdt.donation_total, 0)) * t.percent / 100)) AS is_reconciled
+FROM
+    lines l
+    CROSS JOIN reconciliation_tolerance t
+    LEFT JOIN candidates c ON (c.line_id = l.id AND c.rn = 1)
+    LEFT JOIN bank_transactions b ON (b.id = c.transaction_id)
+    LEFT JOIN transaction_donation_totals tdt ON (tdt.transaction_id = b.id)
+    LEFT JOIN crms_donation_totals cdt ON (cdt.payout_reference_dfk = b.reference)
+ORDER BY
+    l.date ASC
+    ,l.id ASC
+;
@@ -0,0 +1,40 @@
+/*
+ Reconciler app SQL
+ sync_record_counts.sql
+ The number of stored records of each type refreshed from Xero and
+ Salesforce, compared before and after a refresh to count the records
+ inserted. Source limits the counts to the records of that system.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'xero' AS Source /* @param */
+)
+SELECT
+    'account' AS record_type
+    ,(SELECT count(*) FROM accounts) AS records
+FROM variables v WHERE v.Source = 'xero'
+UNION ALL
+SELECT
+    'contact'
+    ,(SELECT count(*) FROM contacts)
+FROM variables v WHERE v.Source = 'xero'
+UNION ALL
+SELECT
+    'invoice'
+    ,(SELECT count(*) FROM invoices)
+FROM variables v WHERE v.Source = 'xero'
+UNION ALL
+SELECT
+    'bank-transaction'
+    ,(SELECT count(*) FROM bank_transactions)
+FROM variables v WHERE v.Source = 'xero'
+UNION ALL
+SELECT
+    'donation'
+    ,(SELECT count(*) FROM donations)
+FROM variables v WHERE v.Source = 'salesforce'
+;
@@ -0,0 +1,40 @@
+/*
+ Reconciler app SQL
+ sync_run_insert.sql
+ Record a refresh of the Xero or Salesforce records. Entities is a json
+ array of the counts of each type of record refreshed.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         'xero'       AS Source      /* @param */
+        ,0            AS FullRefresh /* @param */
+        ,'2025-06-01' AS StartedAt   /* @param */
+        ,'2025-06-01' AS FinishedAt  /* @param */
+        ,0            AS APICalls    /* @param */
+        ,'[]'         AS Entities    /* @param */
+        ,''           AS Error       /* @param */
+)
+INSERT INTO sync_runs (
+    source
+    ,full_refresh
+    ,started_at
+    ,finished_at
+    ,api_calls
+    ,entities
+    ,error
+)
+SELECT
+    v.Source
+    ,v.FullRefresh
+    ,v.StartedAt
+    ,v.FinishedAt
+    ,v.APICalls
+    ,v.Entities
+    ,v.Error
+FROM
+    variables v
+;
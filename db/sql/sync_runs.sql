@@ -0,0 +1,30 @@
+/*
+ Reconciler app SQL
+ sync_runs.sql
+ The most recent refreshes of the Xero and Salesforce records, latest
+ first.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         100 AS MaxRuns /* @param */
+)
+SELECT
+    s.id
+    ,s.source
+    ,s.full_refresh
+    ,s.started_at
+    ,s.finished_at
+    ,s.api_calls
+    ,s.entities
+    ,s.error
+FROM
+    sync_runs s
+ORDER BY
+    s.started_at DESC
+    ,s.id DESC
+LIMIT (SELECT MaxRuns FROM variables)
+;
@@ -0,0 +1,20 @@
+/*
+ Reconciler app SQL
+ tolerance.sql
+ The reconciliation tolerance, which is zero if it has not been set.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1 AS ID /* @param */
+)
+SELECT
+    t.amount
+    ,t.percent
+FROM
+    reconciliation_tolerance t
+    ,variables v
+;
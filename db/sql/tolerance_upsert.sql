@@ -0,0 +1,34 @@
+/*
+ Reconciler app SQL
+ tolerance_upsert.sql
+ Upsert the reconciliation tolerance amount and percentage.
+
+ Note @param comments declare a template value for middleware replacement.
+ Note do _not_ use colons in sql or comments as it breaks the sqlx parser.
+*/
+
+WITH variables AS (
+    SELECT
+         1    AS ID      /* @param */
+         ,0.5 AS Amount  /* @param */
+         ,1.0 AS Percent /* @param */
+)
+
+INSERT INTO system (
+    id
+    ,tolerance_amount
+    ,tolerance_percent
+)
+SELECT
+    v.ID
+    ,v.Amount
+    ,v.Percent
+FROM
+    variables v
+-- sqlite.org/lang_upsert.html PARSING AMBIGUITY
+WHERE
+    true
+ON CONFLICT (id) DO UPDATE SET
+    tolerance_amount   = excluded.tolerance_amount
+    ,tolerance_percent = excluded.tolerance_percent
+;
@@ -0,0 +1,146 @@
+package db
+
+// statements.go deals with the lines of bank statements imported from CSV, which are
+// compared with the Xero bank transactions to find money received which never reached
+// Xero.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// StatementLine is a line of a bank statement of the BankAccount, identified in the
+// statement by the LineRef. The Amount is positive for money received.
+type StatementLine struct {
+	ID          int64        `db:"id"`
+	BankAccount string       `db:"bank_account"`
+	LineRef     string       `db:"line_ref"`
+	Date        time.Time    `db:"date"`
+	Amount      money.Amount `db:"amount"`
+	Description string       `db:"description"`
+	Reference   string       `db:"reference"`
+	ImportedAt  time.Time    `db:"imported_at"`
+}
+
+// StatementLinesUpsert upserts the statement lines of the bank account, updating the
+// lines with the same line references imported before, and returns the number of lines
+// recorded.
+func (db *DB) StatementLinesUpsert(ctx context.Context, bankAccount string, lines []StatementLine) (int, error) {
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("statementLinesUpsert: could not begin transaction: %v", err))
+		return 0, fmt.Errorf("statementLinesUpsert: could not begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	stmt := db.statementLineUpsertStmt
+	for _, line := range lines {
+		namedArgs := map[string]any{
+			"BankAccount": bankAccount,
+			"LineRef":     line.LineRef,
+			"Date":        sqlDate(line.Date),
+			"Amount":      line.Amount,
+			"Description": line.Description,
+			"Reference":   line.Reference,
+		}
+		if err := stmt.verifyArgs(namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("statement line upsert verify arguments error: %v", err))
+			return 0, fmt.Errorf("statement line upsert verify arguments error: %w", err)
+		}
+		if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+			db.log.Error(fmt.Sprintf("failed to upsert statement line %s: %v", line.LineRef, err))
+			return 0, fmt.Errorf("failed to upsert statement line %s: %w", line.LineRef, err)
+		}
+	}
+
+	db.log.Info(fmt.Sprintf("statementLinesUpsert: %d lines for bank account %s", len(lines), bankAccount))
+	return len(lines), tx.commit()
+}
+
+// StatementLinesGet retrieves the statement lines of money received dated between
+// dateFrom and dateTo, in date order.
+func (db *DB) StatementLinesGet(ctx context.Context, dateFrom, dateTo time.Time) ([]StatementLine, error) {
+
+	stmt := db.statementLinesGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom": sqlDate(dateFrom),
+		"DateTo":   sqlDate(dateTo),
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("statement lines verify arguments error: %v", err))
+		return nil, fmt.Errorf("statement lines verify arguments error: %w", err)
+	}
+
+	var lines []StatementLine
+	err := stmt.SelectContext(ctx, &lines, namedArgs)
+	db.logQuery(ctx, "statement lines", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("statement lines select error: %v", err))
+		return nil, fmt.Errorf("statement lines select error: %w", err)
+	}
+	return lines, nil
+}
+
+// StatementMatch is a statement line of money received with the Xero bank transaction
+// matched to it by amount and date, as returned by StatementMatchesGet. The transaction
+// fields are nil if no bank transaction matches the line. The DonationTotal is the
+// donation line item total of the transaction and the CRMSTotal the total of the
+// donations counted against its reference. Reconciled is set if a transaction matches
+// and its totals agree within the reconciliation tolerance.
+type StatementMatch struct {
+	StatementLine
+	TransactionID        *string      `db:"transaction_id"`
+	TransactionReference *string      `db:"transaction_reference"`
+	TransactionDate      *time.Time   `db:"transaction_date"`
+	DonationTotal        money.Amount `db:"donation_total"`
+	CRMSTotal            money.Amount `db:"crms_total"`
+	Reconciled           bool         `db:"is_reconciled"`
+}
+
+// Status reports if the statement line has no matching Xero bank transaction
+// ("missing"), has a transaction whose donations differ from those recorded in the CRM
+// ("differs") or is matched and reconciled ("matched").
+func (s StatementMatch) Status() string {
+	switch {
+	case s.TransactionID == nil:
+		return "missing"
+	case !s.Reconciled:
+		return "differs"
+	}
+	return "matched"
+}
+
+// StatementMatchesGet retrieves the statement lines of money received dated between
+// dateFrom and dateTo, in date order, each with the Xero bank transaction dated within
+// dayWindow days of the line which matches it.
+func (db *DB) StatementMatchesGet(ctx context.Context, dateFrom, dateTo time.Time, dayWindow int) ([]StatementMatch, error) {
+
+	stmt := db.statementMatchesGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": db.donationAccountCodes(),
+		"DayWindow":    dayWindow,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("statement matches verify arguments error: %v", err))
+		return nil, fmt.Errorf("statement matches verify arguments error: %w", err)
+	}
+
+	var matches []StatementMatch
+	err := stmt.SelectContext(ctx, &matches, namedArgs)
+	db.logQuery(ctx, "statement matches", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("statement matches select error: %v", err))
+		return nil, fmt.Errorf("statement matches select error: %w", err)
+	}
+	return matches, nil
+}
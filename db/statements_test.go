@@ -0,0 +1,138 @@
+package db
+
+// tests for bank statement lines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestStatementLines(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	line := func(ref string, day int, amount float64) StatementLine {
+		return StatementLine{
+			LineRef:     ref,
+			Date:        time.Date(2025, 4, day, 0, 0, 0, 0, time.UTC),
+			Amount:      money.FromFloat(amount),
+			Description: "Line " + ref,
+		}
+	}
+
+	n, err := testDB.StatementLinesUpsert(ctx, "Current Account", []StatementLine{
+		line("L-1", 15, 337.25),
+		line("L-2", 16, -12.00), // a payment, which is not listed
+		line("L-3", 21, 80.00),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d lines recorded want 3", n)
+	}
+
+	// Importing an overlapping statement updates the lines imported before.
+	updated := line("L-3", 22, 85.00)
+	updated.Reference = "CHQ 1001"
+	if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", []StatementLine{updated}); err != nil {
+		t.Fatal(err)
+	}
+	// The same reference in another bank account is a different line.
+	if _, err := testDB.StatementLinesUpsert(ctx, "Deposit Account", []StatementLine{line("L-1", 30, 10.00)}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := testDB.StatementLinesGet(ctx, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(lines), 3; got != want {
+		t.Fatalf("got %d lines want %d: %+v", got, want, lines)
+	}
+	if lines[0].LineRef != "L-1" || lines[0].Amount != money.FromFloat(337.25) || lines[0].BankAccount != "Current Account" {
+		t.Errorf("unexpected first line %+v", lines[0])
+	}
+	if lines[1].Amount != money.FromFloat(85.00) || lines[1].Reference != "CHQ 1001" || lines[1].Date.Day() != 22 {
+		t.Errorf("expected the updated line, got %+v", lines[1])
+	}
+	if lines[2].BankAccount != "Deposit Account" {
+		t.Errorf("expected the deposit account line, got %+v", lines[2])
+	}
+
+	lines, err = testDB.StatementLinesGet(ctx, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 5, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("got %d lines want none", len(lines))
+	}
+}
+
+func TestStatementMatches(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	// bt-001 of 337.25 on 15/04 has donations matching its donation line items, while
+	// bt-002 of 490.00 on 20/04 has donation line items of 500.00 but no donations.
+	line := func(ref string, day int, amount float64) StatementLine {
+		return StatementLine{
+			LineRef: ref,
+			Date:    time.Date(2025, 4, day, 0, 0, 0, 0, time.UTC),
+			Amount:  money.FromFloat(amount),
+		}
+	}
+	if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", []StatementLine{
+		line("L-1", 16, 337.25),
+		line("L-2", 20, 490.00),
+		line("L-3", 21, 42.00),  // never reached Xero
+		line("L-4", 25, 337.25), // too long after bt-001
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := testDB.StatementMatchesGet(ctx, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(matches), 4; got != want {
+		t.Fatalf("got %d matches want %d", got, want)
+	}
+
+	tests := []struct {
+		lineRef       string
+		transactionID string
+		status        string
+	}{
+		{"L-1", "bt-001", "matched"},
+		{"L-2", "bt-002", "differs"},
+		{"L-3", "", "missing"},
+		{"L-4", "", "missing"},
+	}
+	for i, tt := range tests {
+		m := matches[i]
+		if m.LineRef != tt.lineRef {
+			t.Fatalf("match %d got line %s want %s", i, m.LineRef, tt.lineRef)
+		}
+		var transactionID string
+		if m.TransactionID != nil {
+			transactionID = *m.TransactionID
+		}
+		if transactionID != tt.transactionID {
+			t.Errorf("line %s got transaction %q want %q", m.LineRef, transactionID, tt.transactionID)
+		}
+		if got := m.Status(); got != tt.status {
+			t.Errorf("line %s got status %s want %s (%+v)", m.LineRef, got, tt.status, m)
+		}
+	}
+	if got, want := matches[1].DonationTotal, money.FromFloat(500); got != want {
+		t.Errorf("bt-002 donation total got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+// suggestions.go deals with suggested links between Salesforce donations and Xero
+// invoices or bank transactions.
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// LinkSuggestion is a suggested link between an unlinked donation and an unreconciled
+// invoice or bank transaction, as returned by LinkSuggestionsGet. The Typer is either
+// "invoice" or "bank-transaction" and RecordRef is the invoice number or bank
+// transaction reference used as the DFK on linking.
+type LinkSuggestion struct {
+	Typer             string       `db:"typer"`
+	RecordID          string       `db:"record_id"`
+	RecordRef         string       `db:"record_ref"`
+	RecordDate        time.Time    `db:"record_date"`
+	RecordContact     *string      `db:"record_contact"`
+	Outstanding       money.Amount `db:"outstanding"`
+	DonationID        string       `db:"donation_id"`
+	DonationName      string       `db:"donation_name"`
+	DonationAmount    money.Amount `db:"donation_amount"`
+	DonationCloseDate *time.Time   `db:"donation_close_date"`
+	Score             float64      `db:"score"`
+}
+
+// LinkSuggestionsGet retrieves the suggested links for unreconciled invoices and bank
+// transactions dated between dateFrom and dateTo, with a score of at least minScore.
+func (db *DB) LinkSuggestionsGet(ctx context.Context, dateFrom, dateTo time.Time, minScore float64) ([]LinkSuggestion, error) {
+
+	db.log.Info(fmt.Sprintf("LinkSuggestionsGet %s %s min score %.2f", dateFrom.Format("2006-01-02"), dateTo.Format("2006-01-02"), minScore))
+	return db.linkSuggestionsGet(ctx, dateFrom, dateTo, minScore, "")
+}
+
+// DonationCandidatesGet retrieves the candidate donations for linking to the invoice or
+// bank transaction of typer with id, if it is unreconciled. The candidates are the
+// unlinked donations closed from six weeks before to two weeks after the record's date
+// with an amount no greater than its amount outstanding, with the best matches by
+// amount and date first. sql.ErrNoRows is returned if there are no candidates.
+func (db *DB) DonationCandidatesGet(ctx context.Context, typer, id string) ([]LinkSuggestion, error) {
+
+	db.log.Info(fmt.Sprintf("DonationCandidatesGet %s %s", typer, id))
+
+	suggestions, err := db.linkSuggestionsGet(ctx, time.Time{}, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC), 0, id)
+	if err != nil {
+		return nil, err
+	}
+	candidates := slices.DeleteFunc(suggestions, func(s LinkSuggestion) bool {
+		return s.Typer != typer
+	})
+	if len(candidates) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return candidates, nil
+}
+
+// linkSuggestionsGet retrieves the suggested links for the unreconciled invoices and
+// bank transactions dated between dateFrom and dateTo, or only for the record with
+// recordID if it is not empty, with a score of at least minScore.
+func (db *DB) linkSuggestionsGet(ctx context.Context, dateFrom, dateTo time.Time, minScore float64, recordID string) ([]LinkSuggestion, error) {
+
+	stmt := db.linkSuggestionsGetStmt
+
+	namedArgs := map[string]any{
+		"DateFrom":     sqlDate(dateFrom),
+		"DateTo":       sqlDate(dateTo),
+		"AccountCodes": db.donationAccountCodes(),
+		"MinScore":     minScore,
+		"RecordID":     recordID,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("linkSuggestionsGet verify args error: %v", err))
+		return nil, fmt.Errorf("link suggestions get verify arguments error: %w", err)
+	}
+
+	var suggestions []LinkSuggestion
+	err := stmt.SelectContext(ctx, &suggestions, namedArgs)
+	db.logQuery(ctx, "link suggestions", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("link suggestions select error: %v", err))
+		return nil, fmt.Errorf("link suggestions select error with named args %v: %w", namedArgs, err)
+	}
+	if len(suggestions) == 0 {
+		db.log.Info("linkSuggestionsGet : no rows")
+		return nil, sql.ErrNoRows
+	}
+	db.log.Info(fmt.Sprintf("linkSuggestionsGet : retrieved %d records", len(suggestions)))
+	return suggestions, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+// tests for link suggestion queries
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// LinkSuggestionsGet(ctx context.Context, dateFrom, dateTo time.Time, minScore float64) ([]LinkSuggestion, error)
+
+// TestLinkSuggestionsGet tests retrieving link suggestions.
+func TestLinkSuggestionsGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	type result struct {
+		Typer      string
+		RecordID   string
+		RecordRef  string
+		DonationID string
+		Score      float64
+	}
+
+	tests := []struct {
+		name     string
+		dateFrom time.Time
+		dateTo   time.Time
+		minScore float64
+		err      error
+		results  []result
+	}{
+		{
+			name:     "high scoring suggestions",
+			dateFrom: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+			dateTo:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			minScore: 0.85,
+			results: []result{
+				{"invoice", "inv-unrec-04", "INV-2025-106", "sf-opp-018", 0.92},
+				{"invoice", "inv-unrec-04", "INV-2025-106", "sf-opp-019", 0.90},
+				{"bank-transaction", "bt-unrec-04", "JG-PAYOUT-2025-04-29", "sf-opp-017", 0.88},
+			},
+		},
+		{
+			name:     "all suggestions",
+			dateFrom: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+			dateTo:   time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+			minScore: 0,
+			results:  nil, // only the count is checked
+		},
+		{
+			name:     "no suggestions out of date range",
+			dateFrom: time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+			dateTo:   time.Date(2028, 3, 31, 0, 0, 0, 0, time.UTC),
+			minScore: 0,
+			err:      sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := testDB.LinkSuggestionsGet(ctx, tt.dateFrom, tt.dateTo, tt.minScore)
+			if err != tt.err {
+				t.Fatalf("got err %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if tt.results == nil {
+				if got, want := len(suggestions), 48; got != want {
+					t.Errorf("got %d suggestions want %d", got, want)
+				}
+				return
+			}
+			got := []result{}
+			for _, s := range suggestions {
+				got = append(got, result{s.Typer, s.RecordID, s.RecordRef, s.DonationID, s.Score})
+			}
+			if diff := cmp.Diff(tt.results, got); diff != "" {
+				t.Errorf("unexpected suggestions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// TestDonationCandidatesGet tests retrieving the candidate donations of a record.
+func TestDonationCandidatesGet(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		typer string
+		id    string
+		err   error
+	}{
+		{"invoice", "invoice", "inv-unrec-04", nil},
+		{"bank transaction", "bank-transaction", "bt-unrec-04", nil},
+		{"wrong type", "bank-transaction", "inv-unrec-04", sql.ErrNoRows},
+		{"unknown record", "invoice", "inv-unknown", sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := testDB.DonationCandidatesGet(ctx, tt.typer, tt.id)
+			if err != tt.err {
+				t.Fatalf("got err %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			for i, c := range candidates {
+				if c.Typer != tt.typer || c.RecordID != tt.id {
+					t.Errorf("candidate %d for %s %s", i, c.Typer, c.RecordID)
+				}
+				if i > 0 && c.Score > candidates[i-1].Score {
+					t.Errorf("candidate %d score %.2f above previous %.2f", i, c.Score, candidates[i-1].Score)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package db
+
+// syncruns.go records the statistics of each refresh of the Xero and Salesforce
+// records, so that problems such as a refresh retrieving no records are noticeable.
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SyncRunEntity counts the records of a type, such as "invoice", refreshed by a sync
+// run. Fetched is the number retrieved, of which Inserted were new, Updated replaced
+// stored records, Skipped were not wanted, such as Xero invoices without a donation
+// account line item, and Failed were recorded as import errors.
+type SyncRunEntity struct {
+	RecordType string `json:"type"`
+	Fetched    int    `json:"fetched"`
+	Inserted   int    `json:"inserted"`
+	Updated    int    `json:"updated"`
+	Skipped    int    `json:"skipped"`
+	Failed     int    `json:"failed"`
+}
+
+// SyncRunEntities are the record counts of a sync run, stored as json.
+type SyncRunEntities []SyncRunEntity
+
+// Value implements driver.Valuer, storing the entities as a json array.
+func (e SyncRunEntities) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner, reading the entities from a json array.
+func (e *SyncRunEntities) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	default:
+		return fmt.Errorf("cannot scan %T into sync run entities", src)
+	}
+}
+
+// SyncRun is a refresh of the records of Source, "xero" or "salesforce". APICalls is
+// the number of api requests made and Error the error which stopped the refresh, if
+// any.
+type SyncRun struct {
+	ID          int64           `db:"id"`
+	Source      string          `db:"source"`
+	FullRefresh bool            `db:"full_refresh"`
+	StartedAt   time.Time       `db:"started_at"`
+	FinishedAt  time.Time       `db:"finished_at"`
+	APICalls    int             `db:"api_calls"`
+	Entities    SyncRunEntities `db:"entities"`
+	Error       string          `db:"error"`
+}
+
+// Duration is the time taken by the sync run, to the nearest millisecond.
+func (s SyncRun) Duration() time.Duration {
+	return s.FinishedAt.Sub(s.StartedAt).Round(time.Millisecond)
+}
+
+// maxSyncRuns is the number of the most recent sync runs retrieved by SyncRunsGet.
+const maxSyncRuns = 100
+
+// SyncRunInsert records a sync run, returning its id.
+func (db *DB) SyncRunInsert(ctx context.Context, s SyncRun) (int64, error) {
+
+	stmt := db.syncRunInsertStmt
+
+	namedArgs := map[string]any{
+		"Source":      s.Source,
+		"FullRefresh": s.FullRefresh,
+		"StartedAt":   sqlTime(s.StartedAt),
+		"FinishedAt":  sqlTime(s.FinishedAt),
+		"APICalls":    s.APICalls,
+		"Entities":    s.Entities,
+		"Error":       s.Error,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("sync run insert verify arguments error: %v", err))
+		return 0, fmt.Errorf("sync run insert verify arguments error: %w", err)
+	}
+	result, err := stmt.ExecContext(ctx, namedArgs)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("failed to insert sync run: %v", err))
+		return 0, fmt.Errorf("failed to insert sync run: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("sync run id error: %w", err)
+	}
+	return id, nil
+}
+
+// SyncRunsGet retrieves the 100 most recent sync runs, latest first.
+func (db *DB) SyncRunsGet(ctx context.Context) ([]SyncRun, error) {
+
+	stmt := db.syncRunsGetStmt
+
+	namedArgs := map[string]any{
+		"MaxRuns": maxSyncRuns,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("sync runs verify arguments error: %v", err))
+		return nil, fmt.Errorf("sync runs verify arguments error: %w", err)
+	}
+
+	var runs []SyncRun
+	err := stmt.SelectContext(ctx, &runs, namedArgs)
+	db.logQuery(ctx, "sync runs", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("sync runs select error: %v", err))
+		return nil, fmt.Errorf("sync runs select error: %w", err)
+	}
+	return runs, nil
+}
+
+// SyncRecordCountsGet returns the number of stored records of each type refreshed from
+// source, "xero" or "salesforce", keyed by record type.
+func (db *DB) SyncRecordCountsGet(ctx context.Context, source string) (map[string]int, error) {
+
+	stmt := db.syncRecordCountsGetStmt
+
+	namedArgs := map[string]any{
+		"Source": source,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("sync record counts verify arguments error: %v", err))
+		return nil, fmt.Errorf("sync record counts verify arguments error: %w", err)
+	}
+
+	var rows []struct {
+		RecordType string `db:"record_type"`
+		Records    int    `db:"records"`
+	}
+	err := stmt.SelectContext(ctx, &rows, namedArgs)
+	db.logQuery(ctx, "sync record counts", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("sync record counts select error: %v", err))
+		return nil, fmt.Errorf("sync record counts select error: %w", err)
+	}
+	counts := map[string]int{}
+	for _, r := range rows {
+		counts[r.RecordType] = r.Records
+	}
+	return counts, nil
+}
@@ -0,0 +1,72 @@
+package db
+
+// tests for sync runs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSyncRuns(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	started := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	runs := []SyncRun{
+		{
+			Source:      "xero",
+			FullRefresh: true,
+			StartedAt:   started,
+			FinishedAt:  started.Add(12 * time.Second),
+			APICalls:    7,
+			Entities: SyncRunEntities{
+				{RecordType: "invoice", Fetched: 10, Inserted: 2, Updated: 7, Skipped: 4, Failed: 1},
+			},
+		},
+		{
+			Source:     "salesforce",
+			StartedAt:  started.Add(time.Minute),
+			FinishedAt: started.Add(time.Minute + time.Second),
+			APICalls:   1,
+			Error:      "salesforce GetOpportunities error",
+		},
+	}
+	for _, run := range runs {
+		if _, err := testDB.SyncRunInsert(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.SyncRunsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SyncRun{runs[1], runs[0]}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(SyncRun{}, "ID"), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("sync runs mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := got[1].Duration(), 12*time.Second; got != want {
+		t.Errorf("duration got %s want %s", got, want)
+	}
+
+	counts, err := testDB.SyncRecordCountsGet(ctx, "xero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 4 || counts["invoice"] == 0 || counts["bank-transaction"] == 0 {
+		t.Errorf("unexpected xero record counts %v", counts)
+	}
+	counts, err = testDB.SyncRecordCountsGet(ctx, "salesforce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts["donation"] == 0 {
+		t.Errorf("unexpected salesforce record counts %v", counts)
+	}
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// Tolerance is the allowed difference between the donation total of an invoice or
+// bank transaction and the total of its linked CRM donations for the record to be
+// reconciled. The larger of Amount and Percent of the donation total applies.
+type Tolerance struct {
+	Amount  money.Amount `db:"amount"`
+	Percent float64      `db:"percent"`
+}
+
+// Validate checks that the tolerance is not negative and the percentage is at most
+// 100.
+func (t Tolerance) Validate() error {
+	switch {
+	case t.Amount < 0:
+		return ErrValidation{"tolerance amount", "must not be negative"}
+	case t.Percent < 0 || t.Percent > 100:
+		return ErrValidation{"tolerance percentage", "must be between 0 and 100"}
+	}
+	return nil
+}
+
+// ToleranceUpsert records the reconciliation tolerance.
+func (db *DB) ToleranceUpsert(ctx context.Context, tolerance Tolerance) error {
+
+	if err := tolerance.Validate(); err != nil {
+		return err
+	}
+	stmt := db.toleranceUpsertStmt
+
+	namedArgs := map[string]any{
+		"ID":      1,
+		"Amount":  tolerance.Amount,
+		"Percent": tolerance.Percent,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("tolerance upsert verify arguments error: %v", err))
+		return fmt.Errorf("tolerance upsert verify arguments error: %w", err)
+	}
+	if _, err := stmt.ExecContext(ctx, namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("failed to upsert tolerance: %v", err))
+		return fmt.Errorf("failed to upsert tolerance: %w", err)
+	}
+	db.log.Info(fmt.Sprintf("reconciliation tolerance set to %s or %g%%", tolerance.Amount, tolerance.Percent))
+	return nil
+}
+
+// ToleranceGet retrieves the reconciliation tolerance, which is zero if it has not
+// been recorded.
+func (db *DB) ToleranceGet(ctx context.Context) (Tolerance, error) {
+
+	stmt := db.toleranceGetStmt
+
+	namedArgs := map[string]any{
+		"ID": 1,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("tolerance verify arguments error: %v", err))
+		return Tolerance{}, fmt.Errorf("tolerance verify arguments error: %w", err)
+	}
+
+	var tolerances []Tolerance
+	err := stmt.SelectContext(ctx, &tolerances, namedArgs)
+	db.logQuery(ctx, "tolerance", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("tolerance select error: %v", err))
+		return Tolerance{}, fmt.Errorf("tolerance select error: %w", err)
+	}
+	if len(tolerances) == 0 {
+		return Tolerance{}, nil
+	}
+	return tolerances[0], nil
+}
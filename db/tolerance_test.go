@@ -0,0 +1,105 @@
+package db
+
+// tests for the reconciliation tolerance
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestTolerance(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	tolerance, err := testDB.ToleranceGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tolerance, (Tolerance{}); got != want {
+		t.Errorf("default tolerance got %+v want %+v", got, want)
+	}
+
+	// inv-001 has a donation total of 500.00 and linked donations of 550.00.
+	tests := []struct {
+		name       string
+		tolerance  Tolerance
+		reconciled bool
+	}{
+		{"none", Tolerance{}, false},
+		{"amount below variance", Tolerance{Amount: money.FromFloat(49.99)}, false},
+		{"amount", Tolerance{Amount: money.FromFloat(50)}, true},
+		{"percent below variance", Tolerance{Percent: 9.9}, false},
+		{"percent", Tolerance{Percent: 10}, true},
+		{"larger of amount and percent", Tolerance{Amount: money.FromFloat(1), Percent: 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := testDB.ToleranceUpsert(ctx, tt.tolerance); err != nil {
+				t.Fatal(err)
+			}
+			got, err := testDB.ToleranceGet(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.tolerance {
+				t.Errorf("tolerance got %+v want %+v", got, tt.tolerance)
+			}
+			invoice, _, err := testDB.InvoiceWRGet(ctx, "inv-001")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if invoice.IsReconciled != tt.reconciled {
+				t.Errorf("invoice reconciled got %t want %t", invoice.IsReconciled, tt.reconciled)
+			}
+			if got, want := invoice.Variance, money.FromFloat(-50); got != want {
+				t.Errorf("variance got %s want %s", got, want)
+			}
+		})
+	}
+
+	if err := testDB.ToleranceUpsert(ctx, Tolerance{Percent: 101}); err == nil {
+		t.Error("expected invalid tolerance error")
+	}
+}
+
+// TestToleranceNothingLinked tests that the tolerance does not reconcile a record with
+// no linked donations, however small its donation total.
+func TestToleranceNothingLinked(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	// inv-unrec-04 has a donation total of 50.00 and no linked donations.
+	if err := testDB.ToleranceUpsert(ctx, Tolerance{Amount: money.FromFloat(60)}); err != nil {
+		t.Fatal(err)
+	}
+	invoice, _, err := testDB.InvoiceWRGet(ctx, "inv-unrec-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.IsReconciled {
+		t.Error("invoice with no linked donations is reconciled")
+	}
+
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	listed := func(status string) bool {
+		invoices, err := testDB.InvoicesGet(ctx, status, from, to, "", "", SortOrder{}, -1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.ContainsFunc(invoices, func(i Invoice) bool { return i.InvoiceID == "inv-unrec-04" })
+	}
+	if listed("Reconciled") {
+		t.Error("invoice with no linked donations is listed as reconciled")
+	}
+	if !listed("NotReconciled") {
+		t.Error("invoice with no linked donations is not listed as not reconciled")
+	}
+}
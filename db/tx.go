@@ -0,0 +1,142 @@
+package db
+
+// tx.go runs groups of database operations atomically. The transaction is carried in
+// the context, so that the prepared statements run within it when given the context,
+// and the methods of the DB may be composed into a single transaction by their
+// callers without passing the transaction itself. A transaction begun within another
+// is a savepoint of the outer transaction, so that it may be rolled back on its own.
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txKey is the context key of the transaction state of beginTx.
+type txKey struct{}
+
+// txState is the transaction carried by a context. Done is set once the transaction
+// has ended, after which statements run with the context run outside it.
+type txState struct {
+	tx         *sqlx.Tx
+	savepoints atomic.Int64
+	done       atomic.Bool
+}
+
+// txFrom returns the open transaction carried by ctx, or nil.
+func txFrom(ctx context.Context) *sqlx.Tx {
+	st, ok := ctx.Value(txKey{}).(*txState)
+	if !ok || st.done.Load() {
+		return nil
+	}
+	return st.tx
+}
+
+// txScope is a transaction begun by beginTx, or a savepoint if it was begun within
+// another transaction. Rollback is a no-op after commit.
+type txScope struct {
+	db        *DB
+	state     *txState
+	savepoint string // empty for the outermost transaction
+	unlock    func()
+	ended     bool
+}
+
+// beginTx begins a transaction, or a savepoint of the transaction carried by ctx,
+// returning the context carrying the transaction. The outermost transaction holds the
+// write lock until it ends.
+func (db *DB) beginTx(ctx context.Context) (context.Context, *txScope, error) {
+
+	if st, ok := ctx.Value(txKey{}).(*txState); ok && !st.done.Load() {
+		name := fmt.Sprintf("sp%d", st.savepoints.Add(1))
+		if _, err := st.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+			return ctx, nil, fmt.Errorf("could not begin savepoint: %w", err)
+		}
+		return ctx, &txScope{db: db, state: st, savepoint: name}, nil
+	}
+
+	unlock, err := db.writes.lock(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		unlock()
+		return ctx, nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+
+	// Results read within the transaction are not cached, and those cached before it
+	// are discarded once it ends.
+	db.cache.txBegin()
+	st := &txState{tx: tx}
+	db.writes.holder.Store(st)
+	return context.WithValue(ctx, txKey{}, st), &txScope{db: db, state: st, unlock: unlock}, nil
+}
+
+// commit commits the transaction, or releases the savepoint.
+func (t *txScope) commit() error {
+	if t.ended {
+		return nil
+	}
+	t.ended = true
+	if t.savepoint != "" {
+		_, err := t.state.tx.Exec("RELEASE " + t.savepoint)
+		return err
+	}
+	defer t.end()
+	return t.state.tx.Commit()
+}
+
+// rollback rolls back the transaction, or the writes made since the savepoint.
+func (t *txScope) rollback() error {
+	if t.ended {
+		return nil
+	}
+	t.ended = true
+	if t.savepoint != "" {
+		if _, err := t.state.tx.Exec("ROLLBACK TO " + t.savepoint); err != nil {
+			return err
+		}
+		_, err := t.state.tx.Exec("RELEASE " + t.savepoint)
+		return err
+	}
+	defer t.end()
+	return t.state.tx.Rollback()
+}
+
+// end marks the outermost transaction as ended, releasing the write lock.
+func (t *txScope) end() {
+	t.state.done.Store(true)
+	t.db.writes.holder.Store(nil)
+	t.db.cache.txEnd()
+	t.unlock()
+}
+
+// WithTx runs fn in a transaction, committing it if fn returns nil and otherwise
+// rolling it back and returning the error of fn. The statements run by fn with the
+// context it is passed run within the transaction, and a WithTx nested within fn is a
+// savepoint of the outer transaction. The write lock is held until the transaction
+// ends, so fn should not wait on remote services. A write made by fn with any other
+// context waits for the lock, failing with ErrWriteLockTimeout.
+func (db *DB) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+
+	ctx, tx, err := db.beginTx(ctx)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("withTx: %v", err))
+		return err
+	}
+	defer func() {
+		_ = tx.rollback() // no-op after commit.
+	}()
+
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	if err := tx.commit(); err != nil {
+		db.log.Error(fmt.Sprintf("withTx: commit error: %v", err))
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package db
+
+// tests for transactions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestWithTx(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	line := func(ref string) []StatementLine {
+		return []StatementLine{{
+			LineRef: ref,
+			Date:    time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC),
+			Amount:  money.FromFloat(10),
+		}}
+	}
+	refs := func(ctx context.Context) []string {
+		t.Helper()
+		lines, err := testDB.StatementLinesGet(ctx, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.LineRef)
+		}
+		return got
+	}
+
+	// The writes of a failing transaction are rolled back, although they are read
+	// within it.
+	errFail := errors.New("fail")
+	err := testDB.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", line("L-1")); err != nil {
+			return err
+		}
+		if got := refs(ctx); len(got) != 1 {
+			t.Errorf("got lines %v within transaction want 1", got)
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("got error %v want %v", err, errFail)
+	}
+	if got := refs(ctx); len(got) != 0 {
+		t.Fatalf("got lines %v after rollback want none", got)
+	}
+
+	// A failing nested transaction is rolled back to its savepoint, while the outer
+	// transaction commits.
+	err = testDB.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", line("L-2")); err != nil {
+			return err
+		}
+		err := testDB.WithTx(ctx, func(ctx context.Context) error {
+			if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", line("L-3")); err != nil {
+				return err
+			}
+			return errFail
+		})
+		if !errors.Is(err, errFail) {
+			t.Errorf("got nested error %v want %v", err, errFail)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := refs(ctx); len(got) != 1 || got[0] != "L-2" {
+		t.Fatalf("got lines %v after commit want [L-2]", got)
+	}
+
+	// The write lock is released once the transaction ends.
+	if _, err := testDB.StatementLinesUpsert(ctx, "Current Account", line("L-4")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithTxOtherContext(t *testing.T) {
+
+	testDB, closeDB := setupTestDB(t)
+	t.Cleanup(closeDB)
+	ctx := context.Background()
+	testDB.writes.timeout = 50 * time.Millisecond
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	line := func(ref string) []StatementLine {
+		return []StatementLine{{
+			LineRef: ref,
+			Date:    time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC),
+			Amount:  money.FromFloat(10),
+		}}
+	}
+
+	errFail := errors.New("fail")
+	read := make(chan int, 1)
+	err := testDB.WithTx(ctx, func(txCtx context.Context) error {
+		if _, err := testDB.StatementLinesUpsert(txCtx, "Current Account", line("L-1")); err != nil {
+			return err
+		}
+
+		// The write lock is taken again with the context of the transaction holding it.
+		unlock, err := testDB.writes.lock(txCtx)
+		if err != nil {
+			t.Fatalf("lock with the transaction context: %v", err)
+		}
+		unlock()
+
+		// A write with another context fails rather than waiting for the transaction.
+		_, err = testDB.StatementLinesUpsert(ctx, "Current Account", line("L-2"))
+		if !errors.Is(err, ErrWriteLockTimeout) {
+			t.Errorf("got error %v writing with another context want %v", err, ErrWriteLockTimeout)
+		}
+
+		// A read with another context does not see the uncommitted write.
+		go func() {
+			lines, err := testDB.StatementLinesGet(ctx, from, to)
+			if err != nil {
+				t.Errorf("read with another context: %v", err)
+			}
+			read <- len(lines)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("got error %v want %v", err, errFail)
+	}
+	if got := <-read; got != 0 {
+		t.Errorf("got %d lines read outside the rolled back transaction want 0", got)
+	}
+}
@@ -7,18 +7,19 @@ import (
 	"database/sql"
 	"fmt"
 	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
 	"time"
 )
 
 // OrganisationUpsert upserts Xero account records.
 func (db *DB) OrganisationUpsert(ctx context.Context, org xero.Organisation) error {
 
-	tx, err := db.Begin()
+	ctx, tx, err := db.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = tx.Rollback() // no-op after commit.
+		_ = tx.rollback() // no-op after commit.
 	}()
 
 	stmt := db.orgUpsertStmt
@@ -43,7 +44,45 @@ func (db *DB) OrganisationUpsert(ctx context.Context, org xero.Organisation) err
 		return fmt.Errorf("failed to upsert organisation %s: %w", org.OrganisationID, err)
 	}
 	db.log.Info("successfully upserted organisation")
-	return tx.Commit()
+	return tx.commit()
+}
+
+// Organisation is the Xero organisation record as returned by OrganisationGet.
+type Organisation struct {
+	Name             string `db:"name"`
+	LegalName        string `db:"legal_name"`
+	OrganisationType string `db:"organisation_type"`
+	Timezone         string `db:"timezone"`
+	ShortCode        string `db:"shortcode"`
+	OrganisationID   string `db:"organisation_id"`
+}
+
+// OrganisationGet retrieves the Xero organisation record, returning sql.ErrNoRows if
+// it has not yet been retrieved from Xero.
+func (db *DB) OrganisationGet(ctx context.Context) (Organisation, error) {
+
+	stmt := db.orgGetStmt
+
+	var org Organisation
+	namedArgs := map[string]any{
+		"ID": 1,
+	}
+	if err := stmt.verifyArgs(namedArgs); err != nil {
+		db.log.Error(fmt.Sprintf("organisation get verify arguments error: %v", err))
+		return org, fmt.Errorf("organisation get verify arguments error: %w", err)
+	}
+
+	var orgs []Organisation
+	err := stmt.SelectContext(ctx, &orgs, namedArgs)
+	db.logQuery(ctx, "organisation", stmt, namedArgs, err)
+	if err != nil {
+		db.log.Error(fmt.Sprintf("organisation select error: %v", err))
+		return org, fmt.Errorf("organisation select error: %w", err)
+	}
+	if len(orgs) == 0 {
+		return org, sql.ErrNoRows
+	}
+	return orgs[0], nil
 }
 
 // AccountsUpsert upserts Xero account records.
@@ -52,12 +91,12 @@ func (db *DB) AccountsUpsert(ctx context.Context, accounts []xero.Account) error
 		db.log.Info("no accounts received for upsert")
 		return nil
 	}
-	tx, err := db.Begin()
+	ctx, tx, err := db.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = tx.Rollback() // no-op after commit.
+		_ = tx.rollback() // no-op after commit.
 	}()
 
 	stmt := db.accountUpsertStmt
@@ -73,7 +112,7 @@ func (db *DB) AccountsUpsert(ctx context.Context, accounts []xero.Account) error
 			"Status":        acc.Status,
 			"SystemAccount": acc.SystemAccount,
 			"CurrencyCode":  acc.CurrencyCode,
-			"Updated":       acc.Updated.Format("2006-01-02T15:04:05Z"),
+			"Updated":       sqlTime(acc.Updated),
 		}
 		if err := stmt.verifyArgs(namedArgs); err != nil {
 			db.log.Error(fmt.Sprintf("accounts upsert verify arguments error: %v", err))
@@ -86,33 +125,49 @@ func (db *DB) AccountsUpsert(ctx context.Context, accounts []xero.Account) error
 		}
 	}
 	db.log.Info(fmt.Sprintf("successfully upserted %d accounts", len(accounts)))
-	return tx.Commit()
+	if err := tx.commit(); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
 }
 
 // Invoice is the concrete type of each row returned by InvoicesGet.
 type Invoice struct {
-	InvoiceID     string    `db:"id"`
-	InvoiceNumber string    `db:"invoice_number"`
-	Date          time.Time `db:"date"`
-	Contact       string    `db:"contact"`
-	Status        string    `db:"status"`
-	Total         float64   `db:"total"`
-	DonationTotal float64   `db:"donation_total"`
-	CRMSTotal     float64   `db:"crms_total"`
-	IsReconciled  bool      `db:"is_reconciled"`
-	RowCount      int       `db:"row_count"`
+	InvoiceID     string       `db:"id"`
+	InvoiceNumber string       `db:"invoice_number"`
+	Date          time.Time    `db:"date"`
+	Contact       string       `db:"contact"`
+	Status        string       `db:"status"`
+	Total         money.Amount `db:"total"`
+	CurrencyCode  string       `db:"currency_code"`
+	CurrencyRate  float64      `db:"currency_rate"`
+	BaseTotal     money.Amount `db:"base_total"` // Total in the base currency
+	DonationTotal money.Amount `db:"donation_total"`
+	CRMSTotal     money.Amount `db:"crms_total"`
+	Variance      money.Amount `db:"variance"` // DonationTotal less CRMSTotal
+	IsReconciled  bool         `db:"is_reconciled"`
+	RowCount      int          `db:"row_count"`
+	Annotations   int          `db:"annotations"` // the number of notes
+	Flagged       bool         `db:"flagged"`     // a note is a flag
+	AssignedTo    string       `db:"assigned_to"` // the assignee, if any
+	// Totals of the full filtered set, in the base currency.
+	SumTotal         money.Amount `db:"sum_total"`
+	SumDonationTotal money.Amount `db:"sum_donation_total"`
+	SumCRMSTotal     money.Amount `db:"sum_crms_total"`
+	SumVariance      money.Amount `db:"sum_variance"`
 	// Reference      string     `db:"Reference,omitempty"`
 	// AmountPaid     float64    `json:"AmountPaid"`
 }
 
 // InvoicesGet gets invoices with summed up line item and donation
-// values. It isn't necessary to run this query in a transaction.
-func (db *DB) InvoicesGet(ctx context.Context, reconciliationStatus string, dateFrom, dateTo time.Time, search string, limit, offset int) ([]Invoice, error) {
+// values, in the given sort order, restricted to those assigned to assignedTo if it is
+// not empty. It isn't necessary to run this query in a transaction.
+func (db *DB) InvoicesGet(ctx context.Context, reconciliationStatus string, dateFrom, dateTo time.Time, search, assignedTo string, sort SortOrder, limit, offset int) ([]Invoice, error) {
 
 	db.log.Info(fmt.Sprintf("InvoicesGet %s from %s to %s search %s limit %d offset %d",
 		reconciliationStatus,
-		dateFrom.Format("2006-01-02"),
-		dateTo.Format("2006-01-02"),
+		sqlDate(dateFrom),
+		sqlDate(dateTo),
 		search,
 		limit,
 		offset,
@@ -131,14 +186,22 @@ func (db *DB) InvoicesGet(ctx context.Context, reconciliationStatus string, date
 			reconciliationStatus,
 		)
 	}
+	if err := sort.Validate(); err != nil {
+		db.log.Error(fmt.Sprintf("invoicesGet sort error: %v", err))
+		return nil, err
+	}
+	sortColumn, sortDirection := sort.args()
 
 	// namedArgs uses sqlx's named query capability.
 	namedArgs := map[string]any{
-		"DateFrom":             dateFrom.Format("2006-01-02"),
-		"DateTo":               dateTo.Format("2006-01-02"),
-		"AccountCodes":         db.accountCodes,
+		"DateFrom":             sqlDate(dateFrom),
+		"DateTo":               sqlDate(dateTo),
+		"AccountCodes":         db.donationAccountCodes(),
 		"ReconciliationStatus": reconciliationStatus,
 		"TextSearch":           search,
+		"AssignedTo":           assignedTo,
+		"SortColumn":           sortColumn,
+		"SortDirection":        sortDirection,
 		"HereLimit":            limit,
 		"HereOffset":           offset,
 	}
@@ -148,9 +211,12 @@ func (db *DB) InvoicesGet(ctx context.Context, reconciliationStatus string, date
 	}
 
 	// Scan results into the provided slice.
-	var invoices []Invoice
-	err := stmt.SelectContext(ctx, &invoices, namedArgs)
-	db.logQuery("invoices", stmt, namedArgs, err)
+	invoices, err := cachedQuery(db, "invoices", namedArgs, func() ([]Invoice, error) {
+		var invoices []Invoice
+		err := stmt.SelectContext(ctx, &invoices, namedArgs)
+		db.logQuery(ctx, "invoices", stmt, namedArgs, err)
+		return invoices, err
+	})
 	if err != nil {
 		db.log.Error(fmt.Sprintf("invoicesGet select error: %v", err))
 		return nil, fmt.Errorf("invoices select error: %w", err)
@@ -165,6 +231,15 @@ func (db *DB) InvoicesGet(ctx context.Context, reconciliationStatus string, date
 	return invoices, nil
 }
 
+// currencyRate returns the Xero currency rate, which is 1 for records in the base
+// currency and may be omitted.
+func currencyRate(rate float64) float64 {
+	if rate == 0 {
+		return 1
+	}
+	return rate
+}
+
 // InvoicesUpsert performs a upserts for a slice of Invoices. It replaces all line items
 // for each invoice in the set to ensure consistency.
 func (db *DB) InvoicesUpsert(ctx context.Context, invoices []xero.Invoice) error {
@@ -174,12 +249,12 @@ func (db *DB) InvoicesUpsert(ctx context.Context, invoices []xero.Invoice) error
 	}
 
 	// Start transaction.
-	tx, err := db.Begin()
+	ctx, tx, err := db.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		_ = tx.Rollback() // no-op after commit.
+		_ = tx.rollback() // no-op after commit.
 	}()
 
 	for _, inv := range invoices {
@@ -209,9 +284,12 @@ func (db *DB) InvoicesUpsert(ctx context.Context, invoices []xero.Invoice) error
 			"Reference":     inv.Reference,
 			"Total":         inv.Total,
 			"AmountPaid":    inv.AmountPaid,
-			"Date":          inv.Date.Format("2006-01-02"),
-			"Updated":       inv.Updated.Format("2006-01-02T15:04:05Z"),
+			"CurrencyCode":  inv.CurrencyCode,
+			"CurrencyRate":  currencyRate(inv.CurrencyRate),
+			"Date":          sqlDate(inv.Date.Time),
+			"Updated":       sqlTime(inv.Updated.Time),
 			"Contact":       inv.Contact,
+			"ContactID":     inv.ContactID,
 		}
 		if err := stmt.verifyArgs(namedArgs); err != nil {
 			db.log.Error(fmt.Sprintf("invoicesUpsert verify arguments error: %v", err))
@@ -245,39 +323,60 @@ func (db *DB) InvoicesUpsert(ctx context.Context, invoices []xero.Invoice) error
 				return fmt.Errorf("failed to upsert line item %s invoice %s: %w", line.LineItemID, inv.InvoiceID, err)
 			}
 		}
+
+		// Classify the line items by the account rules.
+		if err := db.accountRulesApply(ctx, db.accountRulesApplyInvoicesStmt, "InvoiceID", inv.InvoiceID); err != nil {
+			return err
+		}
 	}
 
 	db.log.Info(fmt.Sprintf("successfully upserted %d invoices", len(invoices)))
 
-	return tx.Commit()
+	if err := tx.commit(); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
 }
 
 // BankTransaction is the concrete type of each row returned by
 // BankTransactionsGet.
 type BankTransaction struct {
-	ID            string    `db:"id"`
-	Reference     string    `db:"reference"`
-	RefDupe       bool      `db:"ref_dupe"` // duplicated references
-	Date          time.Time `db:"date"`
-	Contact       string    `db:"contact"`
-	BankAccountID string    `db:"bank_account_id"`
-	Status        string    `db:"status"`
-	Total         float64   `db:"total"`
-	DonationTotal float64   `db:"donation_total"`
-	CRMSTotal     float64   `db:"crms_total"`
-	IsReconciled  bool      `db:"is_reconciled"`
-	RowCount      int       `db:"row_count"`
+	ID            string       `db:"id"`
+	Reference     string       `db:"reference"`
+	RefDupe       bool         `db:"ref_dupe"` // duplicated references
+	Date          time.Time    `db:"date"`
+	Contact       string       `db:"contact"`
+	BankAccountID string       `db:"bank_account_id"`
+	Status        string       `db:"status"`
+	Total         money.Amount `db:"total"`
+	CurrencyCode  string       `db:"currency_code"`
+	CurrencyRate  float64      `db:"currency_rate"`
+	BaseTotal     money.Amount `db:"base_total"` // Total in the base currency
+	DonationTotal money.Amount `db:"donation_total"`
+	CRMSTotal     money.Amount `db:"crms_total"`
+	Variance      money.Amount `db:"variance"` // DonationTotal less CRMSTotal
+	IsReconciled  bool         `db:"is_reconciled"`
+	RowCount      int          `db:"row_count"`
+	Annotations   int          `db:"annotations"` // the number of notes
+	Flagged       bool         `db:"flagged"`     // a note is a flag
+	AssignedTo    string       `db:"assigned_to"` // the assignee, if any
+	// Totals of the full filtered set, in the base currency.
+	SumTotal         money.Amount `db:"sum_total"`
+	SumDonationTotal money.Amount `db:"sum_donation_total"`
+	SumCRMSTotal     money.Amount `db:"sum_crms_total"`
+	SumVariance      money.Amount `db:"sum_variance"`
 	// AmountPaid     float64    `json:"AmountPaid"`
 }
 
 // BankTransactionsGet gets bank transactions with summed up line item
-// and donation values. It isn't necessary to run this query in a transaction.
-func (db *DB) BankTransactionsGet(ctx context.Context, reconciliationStatus string, dateFrom, dateTo time.Time, search string, limit, offset int) ([]BankTransaction, error) {
+// and donation values, in the given sort order, restricted to those assigned to
+// assignedTo if it is not empty. It isn't necessary to run this query in a transaction.
+func (db *DB) BankTransactionsGet(ctx context.Context, reconciliationStatus string, dateFrom, dateTo time.Time, search, assignedTo string, sort SortOrder, limit, offset int) ([]BankTransaction, error) {
 
 	db.log.Info(fmt.Sprintf("BankTransactionGet %s from %s to %s search %s limit %d offset %d",
 		reconciliationStatus,
-		dateFrom.Format("2006-01-02"),
-		dateTo.Format("2006-01-02"),
+		sqlDate(dateFrom),
+		sqlDate(dateTo),
 		search,
 		limit,
 		offset,
@@ -296,14 +395,22 @@ func (db *DB) BankTransactionsGet(ctx context.Context, reconciliationStatus stri
 			reconciliationStatus,
 		)
 	}
+	if err := sort.Validate(); err != nil {
+		db.log.Error(fmt.Sprintf("bankTransactionGet sort error: %v", err))
+		return nil, err
+	}
+	sortColumn, sortDirection := sort.args()
 
 	// Args uses sqlx's named query capability.
 	namedArgs := map[string]any{
-		"DateFrom":             dateFrom.Format("2006-01-02"),
-		"DateTo":               dateTo.Format("2006-01-02"),
-		"AccountCodes":         db.accountCodes,
+		"DateFrom":             sqlDate(dateFrom),
+		"DateTo":               sqlDate(dateTo),
+		"AccountCodes":         db.donationAccountCodes(),
 		"ReconciliationStatus": reconciliationStatus,
 		"TextSearch":           search,
+		"AssignedTo":           assignedTo,
+		"SortColumn":           sortColumn,
+		"SortDirection":        sortDirection,
 		"HereLimit":            limit,
 		"HereOffset":           offset,
 	}
@@ -313,8 +420,12 @@ func (db *DB) BankTransactionsGet(ctx context.Context, reconciliationStatus stri
 	}
 
 	// Use sqlx to scan results into the provided slice.
-	var transactions []BankTransaction
-	err := stmt.SelectContext(ctx, &transactions, namedArgs)
+	transactions, err := cachedQuery(db, "bank transactions", namedArgs, func() ([]BankTransaction, error) {
+		var transactions []BankTransaction
+		err := stmt.SelectContext(ctx, &transactions, namedArgs)
+		db.logQuery(ctx, "bank transactions", stmt, namedArgs, err)
+		return transactions, err
+	})
 	if err != nil {
 		db.log.Error(fmt.Sprintf("bank transactions select error: %v", err))
 		return nil, fmt.Errorf("bank transactions select error: %w", err)
@@ -340,13 +451,13 @@ func (db *DB) BankTransactionsUpsert(ctx context.Context, transactions []xero.Ba
 	}
 
 	// Start transaction.
-	tx, err := db.Begin()
+	ctx, tx, err := db.beginTx(ctx)
 	if err != nil {
 		db.log.Error(fmt.Sprintf("bankTransactionUpsert: transaction start error: %v", err))
 		return err
 	}
 	defer func() {
-		_ = tx.Rollback() // no-op after commit.
+		_ = tx.rollback() // no-op after commit.
 	}()
 
 	for _, tr := range transactions {
@@ -374,10 +485,13 @@ func (db *DB) BankTransactionsUpsert(ctx context.Context, transactions []xero.Ba
 			"Status":            tr.Status,
 			"Reference":         tr.Reference,
 			"Total":             tr.Total,
+			"CurrencyCode":      tr.CurrencyCode,
+			"CurrencyRate":      currencyRate(tr.CurrencyRate),
 			"IsReconciled":      tr.IsReconciled,
-			"Date":              tr.Date.Format("2006-01-02"),
-			"Updated":           tr.Updated.Format("2006-01-02T15:04:05Z"),
+			"Date":              sqlDate(tr.Date.Time),
+			"Updated":           sqlTime(tr.Updated.Time),
 			"Contact":           tr.Contact,
+			"ContactID":         tr.ContactID,
 			"BankAccount":       tr.BankAccount,
 			"BankAccountID":     tr.BankAccountID,
 		}
@@ -412,44 +526,58 @@ func (db *DB) BankTransactionsUpsert(ctx context.Context, transactions []xero.Ba
 				return fmt.Errorf("failed to insert line item %s for transaction %s: %w", line.LineItemID, tr.BankTransactionID, err)
 			}
 		}
+
+		// Classify the line items by the account rules.
+		if err := db.accountRulesApply(ctx, db.accountRulesApplyBankTransactionsStmt, "BankTransactionID", tr.BankTransactionID); err != nil {
+			return err
+		}
 	}
 
 	db.log.Info(fmt.Sprintf("successfully upserted %d bank transaction records", len(transactions)))
 
-	return tx.Commit()
+	if err := tx.commit(); err != nil {
+		return err
+	}
+	return db.donationAccountsSync(ctx)
 }
 
 // WRInvoice is the invoice component of a wide rows invoice with line
 // items query.
 type WRInvoice struct {
-	ID               string    `db:"id"`
-	InvoiceNumber    string    `db:"invoice_number"`
-	Date             time.Time `db:"date"`
-	Type             *string   `db:"type"`
-	Status           string    `db:"status"`
-	Reference        *string   `db:"reference"`
-	Contact          string    `db:"contact"`
-	Total            float64   `db:"total"`
-	DonationTotal    float64   `db:"donation_total"`
-	CRMSTotal        float64   `db:"crms_total"`
-	TotalOutstanding float64   `db:"total_outstanding"`
-	IsReconciled     bool      `db:"is_reconciled"`
+	ID               string       `db:"id"`
+	InvoiceNumber    string       `db:"invoice_number"`
+	Date             time.Time    `db:"date"`
+	Updated          *time.Time   `db:"updated_at"` // the Xero UpdatedDateUTC at the last refresh
+	Type             *string      `db:"type"`
+	Status           string       `db:"status"`
+	Reference        *string      `db:"reference"`
+	Contact          string       `db:"contact"`
+	ContactID        string       `db:"contact_id"`
+	Total            money.Amount `db:"total"`
+	CurrencyCode     string       `db:"currency_code"`
+	CurrencyRate     float64      `db:"currency_rate"`
+	BaseTotal        money.Amount `db:"base_total"` // Total in the base currency
+	DonationTotal    money.Amount `db:"donation_total"`
+	CRMSTotal        money.Amount `db:"crms_total"`
+	TotalOutstanding money.Amount `db:"total_outstanding"`
+	Variance         money.Amount `db:"variance"` // DonationTotal less CRMSTotal
+	IsReconciled     bool         `db:"is_reconciled"`
 }
 
 // WRLineItem is the line item component of a wide rows invoice with
 // line items query. All values could be null.
 type WRLineItem struct {
-	AccountCode    *string  `db:"li_account_code"`
-	AccountName    *string  `db:"account_name"`
-	Description    *string  `db:"li_description"`
-	TaxAmount      *float64 `db:"li_tax_amount"`
-	LineAmount     *float64 `db:"li_line_amount"`
-	DonationAmount *float64 `db:"li_donation_amount"`
+	AccountCode    *string       `db:"li_account_code"`
+	AccountName    *string       `db:"account_name"`
+	Description    *string       `db:"li_description"`
+	TaxAmount      *money.Amount `db:"li_tax_amount"`
+	LineAmount     *money.Amount `db:"li_line_amount"`
+	DonationAmount *money.Amount `db:"li_donation_amount"`
 }
 
 // InvoiceWRGet (a wide rows query) retrieves a single invoice from
 // the database with it's constituent line items. This query returns
-// rows for each line item.
+// rows for each line item. ErrNotFound is returned for a missing invoice.
 func (db *DB) InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []WRLineItem, error) {
 
 	db.log.Info(fmt.Sprintf("InvoiceWRGet for %s", invoiceID))
@@ -472,7 +600,7 @@ func (db *DB) InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []
 
 	// Args uses sqlx's named query capability.
 	namedArgs := map[string]any{
-		"AccountCodes": db.accountCodes,
+		"AccountCodes": db.donationAccountCodes(),
 		"InvoiceID":    invoiceID,
 	}
 	if err := stmt.verifyArgs(namedArgs); err != nil {
@@ -483,7 +611,7 @@ func (db *DB) InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []
 	// Use sqlx to scan results into the provided slice.
 	var iwli invoicesWLI
 	err := stmt.SelectContext(ctx, &iwli, namedArgs)
-	db.logQuery("invoiceWLI", stmt, namedArgs, err)
+	db.logQuery(ctx, "invoiceWLI", stmt, namedArgs, err)
 	if err != nil {
 		db.log.Error(fmt.Sprintf("InvoiceWRGet select error %v", err))
 		return invoice, nil, fmt.Errorf("invoice select error: %v", err)
@@ -491,7 +619,7 @@ func (db *DB) InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []
 
 	// Return early if no errors were returned.
 	if len(iwli) == 0 {
-		return invoice, nil, sql.ErrNoRows
+		return invoice, nil, ErrNotFound{"invoice", invoiceID}
 	}
 
 	// Return invoice and child line items.
@@ -508,23 +636,28 @@ func (db *DB) InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []
 // WRTransaction is the bank transaction component of a wide rows bank
 // transaction with line items query.
 type WRTransaction struct {
-	ID               string    `db:"id"`
-	Reference        *string   `db:"reference"`
-	Date             time.Time `db:"date"`
-	Type             *string   `db:"type"`
-	Status           string    `db:"status"`
-	Contact          string    `db:"contact"`
-	BankAccountID    string    `db:"bank_account_id"`
-	Total            float64   `db:"total"`
-	DonationTotal    float64   `db:"donation_total"`
-	CRMSTotal        float64   `db:"crms_total"`
-	TotalOutstanding float64   `db:"total_outstanding"`
-	IsReconciled     bool      `db:"is_reconciled"`
+	ID               string       `db:"id"`
+	Reference        *string      `db:"reference"`
+	Date             time.Time    `db:"date"`
+	Type             *string      `db:"type"`
+	Status           string       `db:"status"`
+	Contact          string       `db:"contact"`
+	ContactID        string       `db:"contact_id"`
+	BankAccountID    string       `db:"bank_account_id"`
+	Total            money.Amount `db:"total"`
+	CurrencyCode     string       `db:"currency_code"`
+	CurrencyRate     float64      `db:"currency_rate"`
+	BaseTotal        money.Amount `db:"base_total"` // Total in the base currency
+	DonationTotal    money.Amount `db:"donation_total"`
+	CRMSTotal        money.Amount `db:"crms_total"`
+	TotalOutstanding money.Amount `db:"total_outstanding"`
+	Variance         money.Amount `db:"variance"` // DonationTotal less CRMSTotal
+	IsReconciled     bool         `db:"is_reconciled"`
 }
 
 // BankTransactionWRGet (a wide rows query) retrieves a single bank transaction
 // (transaction) from the database with it's constituent line items. This query returns
-// rows for each line item.
+// rows for each line item. ErrNotFound is returned for a missing transaction.
 func (db *DB) BankTransactionWRGet(ctx context.Context, transactionID string) (WRTransaction, []WRLineItem, error) {
 
 	db.log.Info(fmt.Sprintf("BankTransactionWRGet for %s", transactionID))
@@ -547,7 +680,7 @@ func (db *DB) BankTransactionWRGet(ctx context.Context, transactionID string) (W
 
 	// Args uses sqlx's named query capability.
 	namedArgs := map[string]any{
-		"AccountCodes":      db.accountCodes,
+		"AccountCodes":      db.donationAccountCodes(),
 		"BankTransactionID": transactionID,
 	}
 	if err := stmt.verifyArgs(namedArgs); err != nil {
@@ -565,7 +698,7 @@ func (db *DB) BankTransactionWRGet(ctx context.Context, transactionID string) (W
 
 	// Return early if no errors were returned.
 	if len(twli) == 0 {
-		return transaction, nil, sql.ErrNoRows
+		return transaction, nil, ErrNotFound{"bank transaction", transactionID}
 	}
 
 	// Return transaction and child line items.
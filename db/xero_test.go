@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 // Index:
@@ -25,6 +26,7 @@ import (
 // Test BankTransactionsUpsert(ctx context.Context, transactions []xero.BankTransaction) error
 // Test InvoiceWRGet(ctx context.Context, invoiceID string) (WRInvoice, []WRLineItem, error)
 // Test BankTransactionWRGet(ctx context.Context, transactionID string) (WRTransaction, []WRLineItem, error)
+// Test OrganisationGet(ctx context.Context) (Organisation, error)
 
 func Test_OrganisationUpsert(t *testing.T) {
 
@@ -43,11 +45,23 @@ func Test_OrganisationUpsert(t *testing.T) {
 		OrganisationID:        "709b07f5-100b-11f1-aab3-7404f143aa1c",
 	}
 
+	if _, err := testDB.OrganisationGet(ctx); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows before upsert, got %v", err)
+	}
+
 	err := testDB.OrganisationUpsert(ctx, org)
 	if err != nil {
 		t.Errorf("unexpected organisation error: %v", err)
 	}
 
+	got, err := testDB.OrganisationGet(ctx)
+	if err != nil {
+		t.Fatalf("unexpected organisation get error: %v", err)
+	}
+	if got.ShortCode != org.ShortCode || got.Name != org.Name {
+		t.Errorf("organisation got %#v", got)
+	}
+
 	var count int
 	err = testDB.GetContext(ctx, &count, "SELECT COUNT(*) FROM organisation")
 	if err != nil || count != 1 {
@@ -157,16 +171,23 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               -1,
 			RecordsNo:            7,
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-unrec-06",
-				InvoiceNumber: "INV-2025-108",
-				Date:          time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
-				Contact:       "Major Donor Pledge",
-				Status:        "PAID",
-				Total:         2000,
-				DonationTotal: 2000,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      7,
+				InvoiceID:        "inv-unrec-06",
+				InvoiceNumber:    "INV-2025-108",
+				Date:             time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
+				Contact:          "Major Donor Pledge",
+				Status:           "PAID",
+				Total:            money.FromFloat(2000),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(2000),
+				DonationTotal:    money.FromFloat(2000),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(2000),
+				IsReconciled:     false,
+				RowCount:         7,
+				SumTotal:         money.FromFloat(4850),
+				SumDonationTotal: money.FromFloat(4850),
+				SumCRMSTotal:     money.FromFloat(550),
+				SumVariance:      money.FromFloat(4300),
 			},
 		},
 		{
@@ -179,16 +200,22 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            1,
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-002",
-				InvoiceNumber: "INV-2025-102",
-				Date:          time.Date(2025, time.April, 12, 11, 0, 0, 0, time.UTC),
-				Contact:       "Generous Individual",
-				Status:        "PAID",
-				Total:         196.5,
-				DonationTotal: 200,
-				CRMSTotal:     200,
-				IsReconciled:  true,
-				RowCount:      1,
+				InvoiceID:        "inv-002",
+				InvoiceNumber:    "INV-2025-102",
+				Date:             time.Date(2025, time.April, 12, 11, 0, 0, 0, time.UTC),
+				Contact:          "Generous Individual",
+				Status:           "PAID",
+				Total:            money.FromFloat(196.5),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(196.5),
+				DonationTotal:    money.FromFloat(200),
+				CRMSTotal:        money.FromFloat(200),
+				Variance:         0,
+				IsReconciled:     true,
+				RowCount:         1,
+				SumTotal:         money.FromFloat(196.5),
+				SumDonationTotal: money.FromFloat(200),
+				SumCRMSTotal:     money.FromFloat(200),
 			},
 		},
 		{
@@ -213,16 +240,23 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            8,
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-unrec-06",
-				InvoiceNumber: "INV-2025-108",
-				Date:          time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
-				Contact:       "Major Donor Pledge",
-				Status:        "PAID",
-				Total:         2000,
-				DonationTotal: 2000,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      8,
+				InvoiceID:        "inv-unrec-06",
+				InvoiceNumber:    "INV-2025-108",
+				Date:             time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
+				Contact:          "Major Donor Pledge",
+				Status:           "PAID",
+				Total:            money.FromFloat(2000),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(2000),
+				DonationTotal:    money.FromFloat(2000),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(2000),
+				IsReconciled:     false,
+				RowCount:         8,
+				SumTotal:         money.FromFloat(5046.5),
+				SumDonationTotal: money.FromFloat(5050),
+				SumCRMSTotal:     money.FromFloat(750),
+				SumVariance:      money.FromFloat(4300),
 			},
 		},
 		{
@@ -235,16 +269,23 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               4,
 			RecordsNo:            4, // number of records
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-unrec-06",
-				InvoiceNumber: "INV-2025-108",
-				Date:          time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
-				Contact:       "Major Donor Pledge",
-				Status:        "PAID",
-				Total:         2000,
-				DonationTotal: 2000,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      8, // the full row count for pagination
+				InvoiceID:        "inv-unrec-06",
+				InvoiceNumber:    "INV-2025-108",
+				Date:             time.Date(2025, time.May, 5, 15, 0, 0, 0, time.UTC),
+				Contact:          "Major Donor Pledge",
+				Status:           "PAID",
+				Total:            money.FromFloat(2000),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(2000),
+				DonationTotal:    money.FromFloat(2000),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(2000),
+				IsReconciled:     false,
+				RowCount:         8, // the full row count for pagination
+				SumTotal:         money.FromFloat(5046.5),
+				SumDonationTotal: money.FromFloat(5050),
+				SumCRMSTotal:     money.FromFloat(750),
+				SumVariance:      money.FromFloat(4300),
 			},
 		},
 		{
@@ -257,16 +298,23 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            1,
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-001",
-				InvoiceNumber: "INV-2025-101",
-				Date:          time.Date(2025, time.April, 10, 10, 0, 0, 0, time.UTC),
-				Contact:       "Example Corp Ltd",
-				Status:        "PAID",
-				Total:         500,
-				DonationTotal: 500,
-				CRMSTotal:     550,
-				IsReconciled:  false,
-				RowCount:      1,
+				InvoiceID:        "inv-001",
+				InvoiceNumber:    "INV-2025-101",
+				Date:             time.Date(2025, time.April, 10, 10, 0, 0, 0, time.UTC),
+				Contact:          "Example Corp Ltd",
+				Status:           "PAID",
+				Total:            money.FromFloat(500),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(500),
+				DonationTotal:    money.FromFloat(500),
+				CRMSTotal:        money.FromFloat(550),
+				Variance:         money.FromFloat(-50),
+				IsReconciled:     false,
+				RowCount:         1,
+				SumTotal:         money.FromFloat(500),
+				SumDonationTotal: money.FromFloat(500),
+				SumCRMSTotal:     money.FromFloat(550),
+				SumVariance:      money.FromFloat(-50),
 			},
 		},
 		{
@@ -279,16 +327,23 @@ func Test_InvoicesQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            1,
 			lastInvoice: Invoice{
-				InvoiceID:     "inv-001",
-				InvoiceNumber: "INV-2025-101",
-				Date:          time.Date(2025, time.April, 10, 10, 0, 0, 0, time.UTC),
-				Contact:       "Example Corp Ltd",
-				Status:        "PAID",
-				Total:         500,
-				DonationTotal: 500,
-				CRMSTotal:     550,
-				IsReconciled:  false,
-				RowCount:      1,
+				InvoiceID:        "inv-001",
+				InvoiceNumber:    "INV-2025-101",
+				Date:             time.Date(2025, time.April, 10, 10, 0, 0, 0, time.UTC),
+				Contact:          "Example Corp Ltd",
+				Status:           "PAID",
+				Total:            money.FromFloat(500),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(500),
+				DonationTotal:    money.FromFloat(500),
+				CRMSTotal:        money.FromFloat(550),
+				Variance:         money.FromFloat(-50),
+				IsReconciled:     false,
+				RowCount:         1,
+				SumTotal:         money.FromFloat(500),
+				SumDonationTotal: money.FromFloat(500),
+				SumCRMSTotal:     money.FromFloat(550),
+				SumVariance:      money.FromFloat(-50),
 			},
 		},
 	}
@@ -296,7 +351,7 @@ func Test_InvoicesQuery(t *testing.T) {
 	for ii, tt := range tests {
 		t.Run(fmt.Sprintf("%d_%s", ii, tt.name), func(t *testing.T) {
 
-			invoices, err := testDB.InvoicesGet(ctx, tt.reconciliationStatus, tt.dateFrom, tt.dateTo, tt.searchString, tt.limit, tt.offset)
+			invoices, err := testDB.InvoicesGet(ctx, tt.reconciliationStatus, tt.dateFrom, tt.dateTo, tt.searchString, "", SortOrder{}, tt.limit, tt.offset)
 			if err != nil {
 				if err != tt.err {
 					t.Fatalf("got invoices error: %v", err)
@@ -333,26 +388,28 @@ func Test_InvoicesUpsert(t *testing.T) {
 			Updated:       xero.XeroDateTime{Time: time.Now()},
 			Status:        "PAID",
 			Reference:     "A reference",
-			Total:         212.20,
-			AmountPaid:    212.20,
+			Total:         money.FromFloat(212.20),
+			AmountPaid:    money.FromFloat(212.20),
+			CurrencyCode:  "USD",
+			CurrencyRate:  1.25,
 			LineItems: []xero.LineItem{
 				{
 					Description: "A line item",
-					UnitAmount:  210.20,
+					UnitAmount:  money.FromFloat(210.20),
 					AccountCode: "5501", // general giving
 					LineItemID:  "9fe6d963-fa41-a",
 					Quantity:    1,
 					TaxAmount:   0,
-					LineAmount:  210.20,
+					LineAmount:  money.FromFloat(210.20),
 				},
 				{
 					Description: "Second line item",
-					UnitAmount:  2.0,
+					UnitAmount:  money.FromFloat(2.0),
 					AccountCode: "429", // fees
 					LineItemID:  "9fe6d963-fa41-b",
 					Quantity:    1,
 					TaxAmount:   0,
-					LineAmount:  2.0,
+					LineAmount:  money.FromFloat(2.0),
 				},
 			},
 		},
@@ -375,6 +432,18 @@ func Test_InvoicesUpsert(t *testing.T) {
 		t.Errorf("Expected to find 2 line items after invoice upsert, but got count %d, err: %v", count, err)
 	}
 
+	// The total is converted to the base currency at the invoice currency rate.
+	invoice, _, err := testDB.InvoiceWRGet(ctx, "9fe6d963-fa41")
+	if err != nil {
+		t.Fatalf("unexpected invoice get error: %v", err)
+	}
+	if got, want := invoice.CurrencyCode, "USD"; got != want {
+		t.Errorf("currency code got %q want %q", got, want)
+	}
+	if got, want := invoice.BaseTotal, money.FromFloat(169.76); got != want {
+		t.Errorf("base total got %.2f want %.2f", got, want)
+	}
+
 	_, err = testDB.ExecContext(ctx, "DELETE FROM invoices WHERE id = ?", "9fe6d963-fa41")
 	if err != nil {
 		t.Errorf("unexpected error in invoice deletion: %v", err)
@@ -418,16 +487,23 @@ func Test_BankTransactionsQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            7,
 			lastTransaction: BankTransaction{
-				ID:            "bt-unrec-06",
-				Reference:     "STRIPE-PAYOUT-2025-05-04",
-				Date:          time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
-				Contact:       "Stripe",
-				Status:        "RECONCILED",
-				Total:         332.5,
-				DonationTotal: 340,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      7, // for pagination
+				ID:               "bt-unrec-06",
+				Reference:        "STRIPE-PAYOUT-2025-05-04",
+				Date:             time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
+				Contact:          "Stripe",
+				Status:           "RECONCILED",
+				Total:            money.FromFloat(332.5),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(332.5),
+				DonationTotal:    money.FromFloat(340),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(340),
+				IsReconciled:     false,
+				RowCount:         7, // for pagination
+				SumTotal:         money.FromFloat(1923.25),
+				SumDonationTotal: money.FromFloat(1955),
+				SumCRMSTotal:     money.FromFloat(250),
+				SumVariance:      money.FromFloat(1705),
 			},
 		},
 		{
@@ -440,16 +516,22 @@ func Test_BankTransactionsQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            1,
 			lastTransaction: BankTransaction{
-				ID:            "bt-001",
-				Reference:     "JG-PAYOUT-2025-04-15",
-				Date:          time.Date(2025, time.April, 15, 14, 0, 0, 0, time.UTC),
-				Contact:       "JustGiving",
-				Status:        "RECONCILED",
-				Total:         337.25,
-				DonationTotal: 355.0,
-				CRMSTotal:     355.0,
-				IsReconciled:  true,
-				RowCount:      1,
+				ID:               "bt-001",
+				Reference:        "JG-PAYOUT-2025-04-15",
+				Date:             time.Date(2025, time.April, 15, 14, 0, 0, 0, time.UTC),
+				Contact:          "JustGiving",
+				Status:           "RECONCILED",
+				Total:            money.FromFloat(337.25),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(337.25),
+				DonationTotal:    money.FromFloat(355.0),
+				CRMSTotal:        money.FromFloat(355.0),
+				Variance:         0,
+				IsReconciled:     true,
+				RowCount:         1,
+				SumTotal:         money.FromFloat(337.25),
+				SumDonationTotal: money.FromFloat(355),
+				SumCRMSTotal:     money.FromFloat(355),
 			},
 		},
 		{
@@ -462,16 +544,23 @@ func Test_BankTransactionsQuery(t *testing.T) {
 			offset:               0,
 			RecordsNo:            8,
 			lastTransaction: BankTransaction{
-				ID:            "bt-unrec-06",
-				Reference:     "STRIPE-PAYOUT-2025-05-04",
-				Date:          time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
-				Contact:       "Stripe",
-				Status:        "RECONCILED",
-				Total:         332.5,
-				DonationTotal: 340,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      8,
+				ID:               "bt-unrec-06",
+				Reference:        "STRIPE-PAYOUT-2025-05-04",
+				Date:             time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
+				Contact:          "Stripe",
+				Status:           "RECONCILED",
+				Total:            money.FromFloat(332.5),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(332.5),
+				DonationTotal:    money.FromFloat(340),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(340),
+				IsReconciled:     false,
+				RowCount:         8,
+				SumTotal:         money.FromFloat(2260.5),
+				SumDonationTotal: money.FromFloat(2310),
+				SumCRMSTotal:     money.FromFloat(605),
+				SumVariance:      money.FromFloat(1705),
 			},
 		},
 		{
@@ -484,16 +573,23 @@ func Test_BankTransactionsQuery(t *testing.T) {
 			offset:               7,
 			RecordsNo:            1, // number of returned records
 			lastTransaction: BankTransaction{
-				ID:            "bt-unrec-06",
-				Reference:     "STRIPE-PAYOUT-2025-05-04",
-				Date:          time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
-				Contact:       "Stripe",
-				Status:        "RECONCILED",
-				Total:         332.5,
-				DonationTotal: 340,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      8, // for pagination
+				ID:               "bt-unrec-06",
+				Reference:        "STRIPE-PAYOUT-2025-05-04",
+				Date:             time.Date(2025, time.May, 4, 9, 0, 0, 0, time.UTC),
+				Contact:          "Stripe",
+				Status:           "RECONCILED",
+				Total:            money.FromFloat(332.5),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(332.5),
+				DonationTotal:    money.FromFloat(340),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(340),
+				IsReconciled:     false,
+				RowCount:         8, // for pagination
+				SumTotal:         money.FromFloat(2260.5),
+				SumDonationTotal: money.FromFloat(2310),
+				SumCRMSTotal:     money.FromFloat(605),
+				SumVariance:      money.FromFloat(1705),
 			},
 		},
 		{
@@ -517,16 +613,22 @@ func Test_BankTransactionsQuery(t *testing.T) {
 			searchString:         "ENTH.*04-28", // a regex which is a lower() to lower() match so (sort of) an iregex
 			RecordsNo:            1,
 			lastTransaction: BankTransaction{
-				ID:            "bt-unrec-03",
-				Reference:     "ENTHUSE-PAYOUT-2025-04-28",
-				Date:          time.Date(2025, time.April, 28, 10, 0, 0, 0, time.UTC),
-				Contact:       "Enthuse",
-				Status:        "RECONCILED",
-				Total:         112,
-				DonationTotal: 115,
-				CRMSTotal:     0,
-				IsReconciled:  false,
-				RowCount:      1,
+				ID:               "bt-unrec-03",
+				Reference:        "ENTHUSE-PAYOUT-2025-04-28",
+				Date:             time.Date(2025, time.April, 28, 10, 0, 0, 0, time.UTC),
+				Contact:          "Enthuse",
+				Status:           "RECONCILED",
+				Total:            money.FromFloat(112),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(112),
+				DonationTotal:    money.FromFloat(115),
+				CRMSTotal:        0,
+				Variance:         money.FromFloat(115),
+				IsReconciled:     false,
+				RowCount:         1,
+				SumTotal:         money.FromFloat(112),
+				SumDonationTotal: money.FromFloat(115),
+				SumVariance:      money.FromFloat(115),
 			},
 		},
 	}
@@ -534,7 +636,7 @@ func Test_BankTransactionsQuery(t *testing.T) {
 	for ii, tt := range tests {
 		t.Run(fmt.Sprintf("%d_%s", ii, tt.name), func(t *testing.T) {
 
-			transactions, err := testDB.BankTransactionsGet(ctx, tt.reconciliationStatus, tt.dateFrom, tt.dateTo, tt.searchString, tt.limit, tt.offset)
+			transactions, err := testDB.BankTransactionsGet(ctx, tt.reconciliationStatus, tt.dateFrom, tt.dateTo, tt.searchString, "", SortOrder{}, tt.limit, tt.offset)
 			if err != nil {
 				if err != tt.err {
 					t.Fatalf("got bank transactions error: %v", err)
@@ -572,17 +674,17 @@ func Test_BankTransactionsUpsert(t *testing.T) {
 			Status:            "AUTHORISED", // or DELETED
 			Date:              xero.XeroDateTime{Time: time.Now()},
 			Updated:           xero.XeroDateTime{Time: time.Now()},
-			Total:             20.00,
+			Total:             money.FromFloat(20.00),
 			BankAccount:       "current",
 			LineItems: []xero.LineItem{
 				{
 					Description: "bank transaction line item",
 					AccountCode: "9999",
 					LineItemID:  "5f117b7b",
-					UnitAmount:  20.00,
+					UnitAmount:  money.FromFloat(20.00),
 					Quantity:    1,
 					TaxAmount:   0,
-					LineAmount:  20.00,
+					LineAmount:  money.FromFloat(20.00),
 				},
 			},
 		},
@@ -641,10 +743,13 @@ func Test_InvoiceWithLineItemsQuery(t *testing.T) {
 				Status:           "PAID",
 				Reference:        nil,
 				Contact:          "Generous Individual",
-				Total:            196.5,
-				DonationTotal:    200,
-				CRMSTotal:        200,
-				TotalOutstanding: -3.5,
+				Total:            money.FromFloat(196.5),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(196.5),
+				DonationTotal:    money.FromFloat(200),
+				CRMSTotal:        money.FromFloat(200),
+				Variance:         0,
+				TotalOutstanding: money.FromFloat(-3.5),
 				IsReconciled:     true,
 			},
 			lineItems: []WRLineItem{
@@ -652,15 +757,15 @@ func Test_InvoiceWithLineItemsQuery(t *testing.T) {
 					AccountCode:    ptrStr("5301"),
 					AccountName:    ptrStr("Fundraising Dinners"),
 					Description:    ptrStr("Pledged donation via Stripe"),
-					LineAmount:     ptrFloat64(200),
-					DonationAmount: ptrFloat64(200),
+					LineAmount:     ptrAmount(200),
+					DonationAmount: ptrAmount(200),
 				},
 				{
 					AccountCode:    ptrStr("429"),
 					AccountName:    ptrStr("Platform Fees"),
 					Description:    ptrStr("Stripe processing fee"),
-					LineAmount:     ptrFloat64(-3.5),
-					DonationAmount: ptrFloat64(0),
+					LineAmount:     ptrAmount(-3.5),
+					DonationAmount: ptrAmount(0),
 				},
 			},
 		},
@@ -675,10 +780,13 @@ func Test_InvoiceWithLineItemsQuery(t *testing.T) {
 				Status:           "PAID",
 				Reference:        nil,
 				Contact:          "Small Pledge",
-				Total:            50,
-				DonationTotal:    50,
+				Total:            money.FromFloat(50),
+				CurrencyRate:     1,
+				BaseTotal:        money.FromFloat(50),
+				DonationTotal:    money.FromFloat(50),
 				CRMSTotal:        0,
-				TotalOutstanding: 50,
+				Variance:         money.FromFloat(50),
+				TotalOutstanding: money.FromFloat(50),
 				IsReconciled:     false,
 			},
 			lineItems: []WRLineItem{
@@ -686,8 +794,8 @@ func Test_InvoiceWithLineItemsQuery(t *testing.T) {
 					AccountCode:    ptrStr("5501"),
 					AccountName:    ptrStr("General Giving"),
 					Description:    ptrStr("Donation"),
-					LineAmount:     ptrFloat64(50),
-					DonationAmount: ptrFloat64(50),
+					LineAmount:     ptrAmount(50),
+					DonationAmount: ptrAmount(50),
 				},
 			},
 		},
@@ -701,7 +809,7 @@ func Test_InvoiceWithLineItemsQuery(t *testing.T) {
 		t.Run(fmt.Sprintf("test_%d", ii), func(t *testing.T) {
 			// Run query
 			invoice, lineItems, err := testDB.InvoiceWRGet(ctx, tt.invoiceID)
-			if err != nil && !errors.Is(tt.err, err) {
+			if err != nil && !errors.Is(err, tt.err) {
 				t.Fatalf("query execute error: %v", err)
 				return
 			}
@@ -743,10 +851,14 @@ func Test_BankTransactionsWithLineItemsQuery(t *testing.T) {
 				Type:          nil,
 				Status:        "RECONCILED",
 				Contact:       "JustGiving",
+				ContactID:     "con-jg",
 				BankAccountID: "7404f143aa1c",
-				Total:         190,
-				DonationTotal: 200,
-				CRMSTotal:     200,
+				Total:         money.FromFloat(190),
+				CurrencyRate:  1,
+				BaseTotal:     money.FromFloat(190),
+				DonationTotal: money.FromFloat(200),
+				CRMSTotal:     money.FromFloat(200),
+				Variance:      0,
 				IsReconciled:  true,
 			},
 			lineItems: []WRLineItem{
@@ -755,16 +867,16 @@ func Test_BankTransactionsWithLineItemsQuery(t *testing.T) {
 					AccountName:    ptrStr("General Giving"),
 					Description:    ptrStr("Donation Payout"),
 					TaxAmount:      nil,
-					LineAmount:     ptrFloat64(200),
-					DonationAmount: ptrFloat64(200),
+					LineAmount:     ptrAmount(200),
+					DonationAmount: ptrAmount(200),
 				},
 				{
 					AccountCode:    ptrStr("429"),
 					AccountName:    ptrStr("Platform Fees"),
 					Description:    ptrStr("Fee"),
 					TaxAmount:      nil,
-					LineAmount:     ptrFloat64(-10),
-					DonationAmount: ptrFloat64(0),
+					LineAmount:     ptrAmount(-10),
+					DonationAmount: ptrAmount(0),
 				},
 			},
 		},
@@ -774,7 +886,7 @@ func Test_BankTransactionsWithLineItemsQuery(t *testing.T) {
 		t.Run(fmt.Sprintf("test_%d", ii), func(t *testing.T) {
 			// Run query
 			transaction, lineItems, err := testDB.BankTransactionWRGet(ctx, tt.transactionID)
-			if err != nil && !errors.Is(tt.err, err) {
+			if err != nil && !errors.Is(err, tt.err) {
 				t.Fatalf("query execute error: %v", err)
 				return
 			}
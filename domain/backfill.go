@@ -0,0 +1,209 @@
+package domain
+
+// backfill.go retrieves the records of one type over a historical date range, such as
+// before the configured data start date, for when an organisation extends how far back
+// it reconciles.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+)
+
+// BackfillRecordTypes are the types of record which may be backfilled.
+var BackfillRecordTypes = []string{"bank-transaction", "invoice", "donation"}
+
+// BackfillResults reports the number of records of RecordType retrieved and upserted
+// by a Backfill, the number recorded as import errors in RejectedNo and the records
+// with missing or unreadable dates in Warnings.
+type BackfillResults struct {
+	RecordType string
+	RecordsNo  int
+	RejectedNo int
+	Warnings   []SyncWarning
+}
+
+// Backfill retrieves the records of recordType dated from dateFrom up to and including
+// dateTo, irrespective of the data start date and when they were last modified, and
+// upserts them. Bank transactions and invoices require the Xero client and donations
+// the Salesforce client; the other client may be nil. The backfill is recorded as a
+// sync run, and waits for any running refresh of the same source to finish.
+func (r *Reconciler) Backfill(
+	ctx context.Context,
+	xeroClient XeroClient,
+	sfClient SalesforceClient,
+	recordType string,
+	dateFrom, dateTo time.Time,
+	accountsRegexp *regexp.Regexp,
+) (results *BackfillResults, err error) {
+
+	if !slices.Contains(BackfillRecordTypes, recordType) {
+		return nil, ErrUsage{Detail: "backfill record type error", Msg: fmt.Sprintf("Records of type %q cannot be backfilled", recordType)}
+	}
+	if dateFrom.IsZero() || dateTo.IsZero() || dateTo.Before(dateFrom) {
+		return nil, ErrUsage{Detail: "backfill date range error", Msg: "The backfill requires a start date on or before its end date"}
+	}
+	source, client := "xero", any(xeroClient)
+	if recordType == "donation" {
+		source, client = "salesforce", any(sfClient)
+	}
+	if (source == "xero" && xeroClient == nil) || (source == "salesforce" && sfClient == nil) {
+		return nil, ErrUsage{Detail: "backfill client error", Msg: fmt.Sprintf("Please connect to %s to backfill its records", sourceName(source))}
+	}
+
+	// The backfill does not upsert records during a refresh of the same source.
+	res, _, err := r.syncs.run(ctx, source, "", func(ctx context.Context) (any, error) {
+		return r.backfill(ctx, xeroClient, sfClient, source, client, recordType, dateFrom, dateTo, accountsRegexp)
+	})
+	results, _ = res.(*BackfillResults)
+	if results == nil {
+		results = &BackfillResults{RecordType: recordType}
+	}
+	return results, err
+}
+
+// backfill retrieves and upserts the records of recordType from source for Backfill.
+func (r *Reconciler) backfill(
+	ctx context.Context,
+	xeroClient XeroClient,
+	sfClient SalesforceClient,
+	source string,
+	client any,
+	recordType string,
+	dateFrom, dateTo time.Time,
+	accountsRegexp *regexp.Regexp,
+) (results *BackfillResults, err error) {
+
+	results = &BackfillResults{RecordType: recordType}
+	run := r.syncRunStart(ctx, source, false, client)
+	defer func() {
+		run.finish(ctx, err)
+	}()
+
+	// Records dated on dateTo are included.
+	before := dateTo.AddDate(0, 0, 1)
+
+	switch recordType {
+	case "bank-transaction":
+		transactions, err := xeroBankTransactionsGet(ctx, xeroClient, dateFrom, time.Time{}, accountsRegexp)
+		if err != nil {
+			return results, err
+		}
+		transactions = datedBefore(transactions, before, func(bt xero.BankTransaction) time.Time { return bt.Date.Time })
+		fetched := len(transactions)
+		transactions, rejected, err := validRecords(ctx, r, source, recordType, transactions, validateBankTransaction)
+		results.RejectedNo += rejected
+		if err != nil {
+			return results, err
+		}
+		transactions, failed, err := upsertRecords(ctx, r, source, recordType, transactions, func(bt xero.BankTransaction) string { return bt.BankTransactionID }, r.db.BankTransactionsUpsert)
+		results.RejectedNo += failed
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "xero BankTransactionsUpsert error",
+				Err:    err,
+				Msg:    "A problem was encountered upserting the Xero bank transactions",
+			}
+		}
+		results.RecordsNo = len(transactions)
+		run.entity(ctx, recordType, fetched, len(transactions), rejected+failed)
+		results.Warnings = xeroDateWarnings("bank transaction", transactions)
+
+	case "invoice":
+		invoices, err := xeroClient.GetInvoices(ctx, dateFrom, time.Time{}, accountsRegexp)
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "xero GetInvoices error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the Xero invoices",
+			}
+		}
+		invoices = datedBefore(invoices, before, func(inv xero.Invoice) time.Time { return inv.Date.Time })
+		fetched := len(invoices)
+		invoices, rejected, err := validRecords(ctx, r, source, recordType, invoices, validateInvoice)
+		results.RejectedNo += rejected
+		if err != nil {
+			return results, err
+		}
+		invoices, failed, err := upsertRecords(ctx, r, source, recordType, invoices, func(inv xero.Invoice) string { return inv.InvoiceID }, r.db.InvoicesUpsert)
+		results.RejectedNo += failed
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "xero InvoicesUpsert error",
+				Err:    err,
+				Msg:    "A problem was encountered upserting the Xero invoices",
+			}
+		}
+		results.RecordsNo = len(invoices)
+		run.entity(ctx, recordType, fetched, len(invoices), rejected+failed)
+		results.Warnings = xeroDateWarnings("invoice", invoices)
+
+	case "donation":
+		donations, err := sfClient.GetOpportunities(ctx, dateFrom, time.Time{})
+		if e, ok := errors.AsType[salesforce.ErrTooManyRecords](err); ok {
+			return results, ErrUsage{
+				Detail: "salesforce GetOpportunities error",
+				Msg:    fmt.Sprintf("The Salesforce query matched %d records, more than the maximum of %d set by salesforce.max_records", e.Total, e.Max),
+			}
+		}
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "salesforce GetOpportunities error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the Salesforce records",
+			}
+		}
+		donations = datedBefore(donations, before, func(d salesforce.Donation) time.Time { return d.CloseDate.Time })
+		fetched := len(donations)
+		donations, rejected, err := validRecords(ctx, r, source, recordType, donations, validateDonation)
+		results.RejectedNo += rejected
+		if err != nil {
+			return results, err
+		}
+		donations, failed, err := upsertRecords(ctx, r, source, recordType, donations, func(d salesforce.Donation) string { return d.ID }, r.db.UpsertDonations)
+		results.RejectedNo += failed
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "salesforce UpsertDonations error",
+				Err:    err,
+				Msg:    "A problem was encountered upserting the Salesforce records",
+			}
+		}
+		results.RecordsNo = len(donations)
+		run.entity(ctx, recordType, fetched, len(donations), rejected+failed)
+		results.Warnings = salesforceDateWarnings(donations)
+	}
+	r.log.Info("backfilled records", "record", recordType, "from", dateFrom.Format(time.DateOnly), "to", dateTo.Format(time.DateOnly), "records", results.RecordsNo)
+	r.logSyncWarnings(results.Warnings)
+
+	if err := r.donationLinksSync(ctx); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// datedBefore returns the records dated before the provided time. Records without a
+// readable date are retained, to be reported as date warnings.
+func datedBefore[T any](records []T, before time.Time, date func(T) time.Time) []T {
+	var dated []T
+	for _, rec := range records {
+		if d := date(rec); d.IsZero() || d.Before(before) {
+			dated = append(dated, rec)
+		}
+	}
+	return dated
+}
+
+// sourceName is the user-facing name of source, "xero" or "salesforce".
+func sourceName(source string) string {
+	if source == "salesforce" {
+		return "Salesforce"
+	}
+	return "Xero"
+}
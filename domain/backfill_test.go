@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// mockBackfillXeroClient returns invoices dated in January and March 2024, recording
+// the dates it was called with.
+type mockBackfillXeroClient struct {
+	mockXeroClient
+	fromDate, ifModifiedSince time.Time
+}
+
+func (m *mockBackfillXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
+	m.fromDate, m.ifModifiedSince = fromDate, ifModifiedSince
+	invoice := func(id string, date time.Time) xero.Invoice {
+		return xero.Invoice{
+			InvoiceID: id,
+			Type:      "ACCREC",
+			Status:    "PAID",
+			Date:      xero.XeroDateTime{Time: date},
+			Total:     money.FromFloat(10),
+			LineItems: []xero.LineItem{{LineItemID: "li-" + id, AccountCode: "5301", Quantity: 1, UnitAmount: money.FromFloat(10), LineAmount: money.FromFloat(10)}},
+		}
+	}
+	return []xero.Invoice{
+		invoice("inv-jan", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)),
+		invoice("inv-mar", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)),
+	}, nil
+}
+
+// TestBackfill tests backfilling the invoices of a historical date range.
+func TestBackfill(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dateFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	xeroClient := &mockBackfillXeroClient{mockXeroClient: mockXeroClient{log: logger}}
+	results, err := reconciler.Backfill(ctx, xeroClient, nil, "invoice", dateFrom, dateTo, regexp.MustCompile("."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !xeroClient.fromDate.Equal(dateFrom) || !xeroClient.ifModifiedSince.IsZero() {
+		t.Errorf("got from date %v and modified since %v want %v and zero", xeroClient.fromDate, xeroClient.ifModifiedSince, dateFrom)
+	}
+	if results.RecordsNo != 1 || results.RejectedNo != 0 {
+		t.Errorf("got %d records and %d rejected want 1 and 0", results.RecordsNo, results.RejectedNo)
+	}
+	var ids []string
+	if err := testDB.Select(&ids, "SELECT id FROM invoices WHERE id IN ('inv-jan', 'inv-mar')"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "inv-jan" {
+		t.Errorf("got backfilled invoices %v want [inv-jan]", ids)
+	}
+
+	runs, err := reconciler.SyncRunsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Source != "xero" || len(runs[0].Entities) != 1 || runs[0].Entities[0].Inserted != 1 {
+		t.Errorf("unexpected sync runs %+v", runs)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		recordType string
+		dateFrom   time.Time
+	}{
+		{"unknown type", "contact", dateFrom},
+		{"dates reversed", "invoice", dateTo.AddDate(0, 0, 1)},
+		{"no start date", "invoice", time.Time{}},
+		{"no salesforce client", "donation", dateFrom},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reconciler.Backfill(ctx, xeroClient, nil, tt.recordType, tt.dateFrom, dateTo, regexp.MustCompile("."))
+			if !errors.As(err, new(ErrUsage)) {
+				t.Errorf("expected a usage error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package domain
+
+// changes.go applies Salesforce change events to the local donation records, keeping
+// them current between refreshes.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// SalesforceChangeResults reports the outcome of applying a Salesforce change event.
+type SalesforceChangeResults struct {
+	ChangeType string
+	UpsertedNo int
+	DeletedNo  int
+}
+
+// SalesforceChangesSubscribe subscribes to Salesforce change events for new changes,
+// applying each change to the local donation records until ctx is cancelled or the
+// subscription fails. Updated records closing before dataStartDate are not stored. If
+// onChange is not nil, it is called with the results of each change applied.
+//
+// The Salesforce client must implement SalesforceSubscriber.
+func (r *Reconciler) SalesforceChangesSubscribe(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+	onChange func(SalesforceChangeResults),
+) error {
+
+	subscriber, ok := sfClient.(SalesforceSubscriber)
+	if !ok {
+		return ErrUsage{
+			Detail: "salesforce client does not support subscriptions",
+			Msg:    "Salesforce change subscriptions are not available",
+		}
+	}
+
+	err := subscriber.SubscribeChanges(ctx, salesforce.ReplayNew, func(event salesforce.ChangeEvent) error {
+		results, err := r.salesforceChangeApply(ctx, sfClient, dataStartDate, event)
+		if err != nil {
+			return err
+		}
+		if onChange != nil {
+			onChange(results)
+		}
+		return nil
+	})
+	if err == nil || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if _, ok := errors.AsType[ErrSystem](err); ok {
+		return err
+	}
+	return ErrSystem{
+		Detail: "salesforce SubscribeChanges error",
+		Err:    err,
+		Msg:    "The Salesforce change subscription failed",
+	}
+}
+
+// salesforceChangeApply applies a single Salesforce change event, once any running
+// Salesforce refresh has finished. Deleted records are deleted, and other changed
+// records are retrieved and upserted.
+func (r *Reconciler) salesforceChangeApply(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+	event salesforce.ChangeEvent,
+) (SalesforceChangeResults, error) {
+
+	res, _, err := r.syncs.run(ctx, "salesforce", "", func(ctx context.Context) (any, error) {
+		return r.salesforceChangeUpsert(ctx, sfClient, dataStartDate, event)
+	})
+	results, ok := res.(SalesforceChangeResults)
+	if !ok {
+		results = SalesforceChangeResults{ChangeType: event.ChangeType}
+	}
+	return results, err
+}
+
+// salesforceChangeUpsert applies a single Salesforce change event for
+// salesforceChangeApply.
+func (r *Reconciler) salesforceChangeUpsert(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+	event salesforce.ChangeEvent,
+) (SalesforceChangeResults, error) {
+
+	results := SalesforceChangeResults{ChangeType: event.ChangeType}
+
+	switch event.ChangeType {
+	case salesforce.ChangeGapOverflow:
+		r.log.Warn("salesforce change overflow; a refresh is needed to retrieve the changed records")
+		return results, nil
+	case salesforce.ChangeDelete:
+		deleted, err := r.db.DeleteDonations(ctx, event.RecordIDs)
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "salesforce DeleteDonations error",
+				Err:    err,
+				Msg:    "A problem was encountered deleting the Salesforce records",
+			}
+		}
+		results.DeletedNo = deleted
+		r.log.Info("deleted changed donations", "records", deleted)
+		return results, r.donationLinksSync(ctx)
+	}
+
+	donations, err := sfClient.GetOpportunitiesByID(ctx, event.RecordIDs)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "salesforce GetOpportunitiesByID error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the changed Salesforce records",
+		}
+	}
+	donations, _, err = validRecords(ctx, r, "salesforce", "donation", donations, validateDonation)
+	if err != nil {
+		return results, err
+	}
+	var current []salesforce.Donation
+	for _, d := range donations {
+		if !d.CloseDate.Before(dataStartDate) {
+			current = append(current, d)
+		}
+	}
+	if err := r.db.UpsertDonations(ctx, current); err != nil {
+		return results, ErrSystem{
+			Detail: "salesforce UpsertDonations error",
+			Err:    err,
+			Msg:    "A problem was encountered upserting the changed Salesforce records",
+		}
+	}
+	results.UpsertedNo = len(current)
+	r.log.Info("retrieved and upserted changed donations", "change", event.ChangeType, "records", results.UpsertedNo)
+	return results, r.donationLinksSync(ctx)
+}
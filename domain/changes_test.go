@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// mockSalesforceSubscriber is a mock Salesforce client which provides a fixed set of
+// change events to a subscriber.
+type mockSalesforceSubscriber struct {
+	mockSalesforceClient
+	events []salesforce.ChangeEvent
+}
+
+func (m *mockSalesforceSubscriber) SubscribeChanges(ctx context.Context, replayID int64, handle func(salesforce.ChangeEvent) error) error {
+	for _, e := range m.events {
+		if err := handle(e); err != nil {
+			return err
+		}
+	}
+	return context.Canceled
+}
+
+func TestSalesforceChangesSubscribe(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.Default()
+	reconciler := NewReconciler(testDB, logger)
+
+	donationCount := func() int {
+		var n int
+		if err := testDB.Get(&n, "SELECT count(*) FROM donations WHERE id IN ('sf-new-1', 'sf-new-2')"); err != nil && err != sql.ErrNoRows {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	client := &mockSalesforceSubscriber{
+		mockSalesforceClient: mockSalesforceClient{log: logger},
+		events: []salesforce.ChangeEvent{
+			{ReplayID: 1, ChangeType: salesforce.ChangeCreate, RecordIDs: []string{"sf-new-1", "sf-new-2"}},
+			{ReplayID: 2, ChangeType: salesforce.ChangeGapOverflow},
+			{ReplayID: 3, ChangeType: salesforce.ChangeDelete, RecordIDs: []string{"sf-new-2"}},
+		},
+	}
+
+	var results []SalesforceChangeResults
+	err := reconciler.SalesforceChangesSubscribe(ctx, client, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), func(r SalesforceChangeResults) {
+		results = append(results, r)
+	})
+	if err != nil {
+		t.Fatalf("unexpected subscription error: %v", err)
+	}
+	if got, want := len(results), 3; got != want {
+		t.Fatalf("got %d results want %d", got, want)
+	}
+	if got, want := results[0].UpsertedNo, 2; got != want {
+		t.Errorf("upserted got %d want %d", got, want)
+	}
+	if got, want := results[2].DeletedNo, 1; got != want {
+		t.Errorf("deleted got %d want %d", got, want)
+	}
+	if got, want := donationCount(), 1; got != want {
+		t.Errorf("donations got %d want %d", got, want)
+	}
+
+	// Changed records closing before the data start date are not stored.
+	client.events = []salesforce.ChangeEvent{{ReplayID: 4, ChangeType: salesforce.ChangeUpdate, RecordIDs: []string{"sf-new-2"}}}
+	results = nil
+	err = reconciler.SalesforceChangesSubscribe(ctx, client, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), func(r SalesforceChangeResults) {
+		results = append(results, r)
+	})
+	if err != nil {
+		t.Fatalf("unexpected subscription error: %v", err)
+	}
+	if got, want := results[0].UpsertedNo, 0; got != want {
+		t.Errorf("upserted got %d want %d", got, want)
+	}
+
+	// Clients without subscriptions are a usage error.
+	err = reconciler.SalesforceChangesSubscribe(ctx, &mockSalesforceClient{log: logger}, time.Time{}, nil)
+	if _, ok := err.(ErrUsage); !ok {
+		t.Errorf("expected ErrUsage, got %T %v", err, err)
+	}
+}
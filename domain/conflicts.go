@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/apistatus"
+)
+
+// FieldChange is a field of a record whose remote value differs from the value held
+// locally.
+type FieldChange struct {
+	Field  string
+	Local  string
+	Remote string
+}
+
+// Conflict is a Xero invoice or Salesforce donation changed remotely since it was last
+// refreshed. Changes lists the differing fields shown on the detail pages, and may be
+// empty if only other fields were changed.
+type Conflict struct {
+	RecordType     string // "invoice" or "donation"
+	ID             string
+	Name           string
+	LocalModified  time.Time
+	RemoteModified time.Time
+	ModifiedBy     string
+	Deleted        bool
+	Changes        []FieldChange
+}
+
+// linkActionConflicts checks that the donations, and the invoice if its reference is to
+// be updated, have not been changed remotely since they were last refreshed. The
+// invoice is only checked if the xeroClient can retrieve a single invoice. Records
+// without a local modification time cannot be checked.
+func (r *Reconciler) linkActionConflicts(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	action LinkAction,
+	donations []db.DonationRef,
+	invoice *db.WRInvoice,
+) ([]Conflict, error) {
+
+	// If Salesforce cannot be reached the donations are checked before the queued
+	// updates are sent.
+	conflicts, err := donationConflicts(ctx, sfClient, donations)
+	if err != nil && !apistatus.Unavailable(err) {
+		return nil, err
+	}
+	if err != nil {
+		r.log.Warn(fmt.Sprintf("salesforce unreachable, donation changes not checked: %v", err))
+	}
+
+	getter, ok := xeroClient.(XeroInvoiceGetter)
+	if action.InvoiceID == "" || invoice == nil || invoice.Updated == nil || !ok {
+		return conflicts, nil
+	}
+	remote, err := getter.GetInvoiceByID(ctx, action.InvoiceID)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "GetInvoiceByID error",
+			Err:    err,
+			Msg:    "A problem was encountered checking the Xero invoice for changes",
+		}
+	}
+	if c, ok := invoiceConflict(*invoice, remote); ok {
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, nil
+}
+
+// modifiedSince reports if remote is later than local, ignoring sub-second
+// differences from the storage of the local time.
+func modifiedSince(local, remote time.Time) bool {
+	return remote.Truncate(time.Second).After(local.Truncate(time.Second))
+}
+
+// donationConflicts checks that the donations have not been changed in Salesforce
+// since they were last refreshed. Donations without a local modification time cannot
+// be checked.
+func donationConflicts(ctx context.Context, sfClient SalesforceClient, donations []db.DonationRef) ([]Conflict, error) {
+
+	local := map[string]db.DonationRef{}
+	var ids []string
+	for _, d := range donations {
+		if d.LastModified == nil {
+			continue
+		}
+		local[d.ID] = d
+		ids = append(ids, d.ID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	remote, err := sfClient.GetOpportunitiesByID(ctx, ids)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "GetOpportunitiesByID error",
+			Err:    err,
+			Msg:    "A problem was encountered checking the salesforce records for changes",
+		}
+	}
+	var conflicts []Conflict
+	found := map[string]bool{}
+	for _, rd := range remote {
+		found[rd.ID] = true
+		if c, ok := donationConflict(local[rd.ID], rd); ok {
+			conflicts = append(conflicts, c)
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			d := local[id]
+			conflicts = append(conflicts, Conflict{
+				RecordType:    "donation",
+				ID:            id,
+				Name:          d.Name,
+				LocalModified: *d.LastModified,
+				Deleted:       true,
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+// donationConflict reports a conflict if the remote donation was modified after the
+// local copy.
+func donationConflict(local db.DonationRef, remote salesforce.Donation) (Conflict, bool) {
+
+	if local.LastModified == nil || !modifiedSince(*local.LastModified, remote.LastModifiedDate.Time) {
+		return Conflict{}, false
+	}
+	c := Conflict{
+		RecordType:     "donation",
+		ID:             local.ID,
+		Name:           local.Name,
+		LocalModified:  *local.LastModified,
+		RemoteModified: remote.LastModifiedDate.Time,
+		ModifiedBy:     string(remote.LastModifiedBy),
+	}
+	var localClose string
+	if local.CloseDate != nil {
+		localClose = local.CloseDate.Format(time.DateOnly)
+	}
+	var remoteRef string
+	if remote.PayoutReference != nil {
+		remoteRef = *remote.PayoutReference
+	}
+	c.Changes = fieldChanges(
+		FieldChange{"Name", local.Name, remote.Name},
+		FieldChange{"Amount", local.Amount.String(), remote.Amount.String()},
+		FieldChange{"Close Date", localClose, remote.CloseDate.Format(time.DateOnly)},
+		FieldChange{"Payout Reference", local.Ref, remoteRef},
+	)
+	return c, true
+}
+
+// invoiceConflict reports a conflict if the remote invoice was updated after the local
+// copy.
+func invoiceConflict(local db.WRInvoice, remote xero.Invoice) (Conflict, bool) {
+
+	if local.Updated == nil || !modifiedSince(*local.Updated, remote.Updated.Time) {
+		return Conflict{}, false
+	}
+	var localRef string
+	if local.Reference != nil {
+		localRef = *local.Reference
+	}
+	return Conflict{
+		RecordType:     "invoice",
+		ID:             local.ID,
+		Name:           local.InvoiceNumber,
+		LocalModified:  *local.Updated,
+		RemoteModified: remote.Updated.Time,
+		Changes: fieldChanges(
+			FieldChange{"Invoice Number", local.InvoiceNumber, remote.InvoiceNumber},
+			FieldChange{"Reference", localRef, remote.Reference},
+			FieldChange{"Contact", local.Contact, string(remote.Contact)},
+			FieldChange{"Status", local.Status, remote.Status},
+			FieldChange{"Total", local.Total.String(), remote.Total.String()},
+		),
+	}, true
+}
+
+// fieldChanges returns the changes whose local and remote values differ.
+func fieldChanges(changes ...FieldChange) []FieldChange {
+	var differ []FieldChange
+	for _, c := range changes {
+		if c.Local != c.Remote {
+			differ = append(differ, c)
+		}
+	}
+	return differ
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// mockConflictSalesforceClient returns donations last modified at modified, with the
+// payout reference ref.
+type mockConflictSalesforceClient struct {
+	mockLinkSalesforceClient
+	modified time.Time
+	ref      string
+}
+
+func (m *mockConflictSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	var donations []salesforce.Donation
+	for _, id := range ids {
+		donations = append(donations, salesforce.Donation{CoreFields: salesforce.CoreFields{
+			ID:               id,
+			Name:             "Example Corp Q1 Donation",
+			Amount:           money.FromFloat(500),
+			CloseDate:        salesforce.SalesforceDate{Time: time.Date(2025, 4, 8, 0, 0, 0, 0, time.UTC)},
+			LastModifiedDate: salesforce.SalesforceTime{Time: m.modified},
+			LastModifiedBy:   "Another User",
+			PayoutReference:  &m.ref,
+		}})
+	}
+	return donations, nil
+}
+
+// mockConflictXeroClient can retrieve a single invoice, last updated at updated.
+type mockConflictXeroClient struct {
+	mockXeroClient
+	updated time.Time
+}
+
+func (m *mockConflictXeroClient) GetInvoiceByID(ctx context.Context, invoiceID string) (xero.Invoice, error) {
+	return xero.Invoice{
+		InvoiceID:     invoiceID,
+		InvoiceNumber: "INV-2025-102",
+		Reference:     "edited in xero",
+		Contact:       "Generous Individual",
+		Status:        "PAID",
+		Total:         money.FromFloat(196.50),
+		Updated:       xero.XeroDateTime{Time: m.updated},
+	}, nil
+}
+
+// TestLinkActionConflicts tests that links are refused for records changed remotely
+// since they were last refreshed.
+func TestLinkActionConflicts(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	refreshed := time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC)
+	if _, err := testDB.ExecContext(ctx, "UPDATE donations SET last_modified_date = ? WHERE id = 'sf-opp-001'", refreshed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.ExecContext(ctx, "UPDATE invoices SET updated_at = ? WHERE id = 'inv-002'", refreshed); err != nil {
+		t.Fatal(err)
+	}
+
+	sfClient := &mockConflictSalesforceClient{
+		mockLinkSalesforceClient: mockLinkSalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}},
+		modified:                 refreshed,
+		ref:                      "INV-2025-101",
+	}
+	xeroClient := &mockConflictXeroClient{mockXeroClient: mockXeroClient{log: logger}, updated: refreshed}
+	action := LinkAction{
+		IDRefs:    []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}},
+		InvoiceID: "inv-002",
+		Reference: "INV-2025-102",
+	}
+
+	// A donation edited in Salesforce is not overwritten.
+	sfClient.modified, sfClient.ref = refreshed.Add(time.Hour), "EDITED-REF"
+	err := reconciler.LinkActionRun(ctx, sfClient, xeroClient, action, dataStartDate, time.Time{})
+	e, ok := errors.AsType[ErrConflict](err)
+	if !ok {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if got, want := len(e.Conflicts), 1; got != want {
+		t.Fatalf("got %d conflicts want %d", got, want)
+	}
+	c := e.Conflicts[0]
+	if c.RecordType != "donation" || c.ModifiedBy != "Another User" || len(c.Changes) != 1 {
+		t.Fatalf("unexpected conflict %+v", c)
+	}
+	if got, want := c.Changes[0], (FieldChange{"Payout Reference", "INV-2025-101", "EDITED-REF"}); got != want {
+		t.Errorf("got change %+v want %+v", got, want)
+	}
+	if len(sfClient.updates) != 0 {
+		t.Error("expected no salesforce update for a conflicting link")
+	}
+	actions, err := reconciler.PendingActionsGet(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("expected no recorded actions, got %d", len(actions))
+	}
+
+	// An invoice edited in Xero is not overwritten.
+	sfClient.modified, sfClient.ref = refreshed, "INV-2025-101"
+	xeroClient.updated = refreshed.Add(time.Minute)
+	err = reconciler.LinkActionRun(ctx, sfClient, xeroClient, action, dataStartDate, time.Time{})
+	if e, ok = errors.AsType[ErrConflict](err); !ok || len(e.Conflicts) != 1 || e.Conflicts[0].RecordType != "invoice" {
+		t.Fatalf("expected an invoice conflict, got %v", err)
+	}
+	if got, want := e.Conflicts[0].Changes, []FieldChange{{"Reference", "", "edited in xero"}}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got changes %+v want %+v", got, want)
+	}
+
+	// Unchanged records are linked.
+	xeroClient.updated = refreshed
+	if err := reconciler.LinkActionRun(ctx, sfClient, xeroClient, action, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sfClient.updates), 1; got != want {
+		t.Errorf("got %d salesforce updates want %d", got, want)
+	}
+}
+
+// TestXeroClientCapabilities checks that the Xero client can retrieve single records,
+// which the conflict checks and single record refreshes rely on.
+func TestXeroClientCapabilities(t *testing.T) {
+	var client any = &xero.Client{}
+	if _, ok := client.(XeroInvoiceGetter); !ok {
+		t.Error("xero.Client does not implement XeroInvoiceGetter")
+	}
+	if _, ok := client.(XeroBankTransactionGetter); !ok {
+		t.Error("xero.Client does not implement XeroBankTransactionGetter")
+	}
+}
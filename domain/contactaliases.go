@@ -0,0 +1,67 @@
+package domain
+
+// contactaliases.go merges the inconsistent names under which a contact appears, such
+// as "Stripe Payments UK" and "STRIPE", into a canonical contact. The records of the
+// merged names are listed with the contact and matched by its account rules.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// ContactAliasesGet retrieves the contact names merged into the contact with contactID,
+// and the names of invoices and bank transactions which may be further aliases.
+func (r *Reconciler) ContactAliasesGet(ctx context.Context, contactID string) ([]db.ContactAlias, []db.ContactAliasCandidate, error) {
+	aliases, err := r.db.ContactAliasesGet(ctx, contactID)
+	if err != nil {
+		return nil, nil, ErrSystem{
+			Detail: "db.ContactAliasesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the contact aliases",
+		}
+	}
+	candidates, err := r.db.ContactAliasCandidatesGet(ctx, contactID)
+	if err != nil {
+		return nil, nil, ErrSystem{
+			Detail: "db.ContactAliasCandidatesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the possible contact aliases",
+		}
+	}
+	return aliases, candidates, nil
+}
+
+// ContactAliasAdd merges the contact name into the contact with contactID, returning a
+// usage error if the merge is invalid.
+func (r *Reconciler) ContactAliasAdd(ctx context.Context, contactID, name string) error {
+	err := r.db.ContactAliasUpsert(ctx, contactID, name)
+	if e, ok := errors.AsType[db.ErrValidation](err); ok {
+		return ErrUsage{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The %s %s", e.Field, e.Msg),
+		}
+	}
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.ContactAliasUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the contact alias",
+		}
+	}
+	return nil
+}
+
+// ContactAliasRemove removes the contact alias with the normalised name alias.
+func (r *Reconciler) ContactAliasRemove(ctx context.Context, alias string) error {
+	if err := r.db.ContactAliasDelete(ctx, alias); err != nil {
+		return ErrSystem{
+			Detail: "db.ContactAliasDelete error",
+			Err:    err,
+			Msg:    "A problem was encountered removing the contact alias",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestContactAliases(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	ctx := context.Background()
+
+	if err := reconciler.ContactAliasAdd(ctx, "con-jg", "Enthuse"); err != nil {
+		t.Fatal(err)
+	}
+	aliases, _, err := reconciler.ContactAliasesGet(ctx, "con-jg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aliases) != 1 || aliases[0].Alias != "enthuse" {
+		t.Fatalf("unexpected aliases %+v", aliases)
+	}
+	if err := reconciler.ContactAliasAdd(ctx, "con-jg", "JustGiving Ltd"); !errors.As(err, new(ErrUsage)) {
+		t.Errorf("expected a usage error merging the contact's own name, got %v", err)
+	}
+	if err := reconciler.ContactAliasRemove(ctx, "enthuse"); err != nil {
+		t.Fatal(err)
+	}
+	if aliases, _, err = reconciler.ContactAliasesGet(ctx, "con-jg"); err != nil || len(aliases) != 0 {
+		t.Errorf("unexpected aliases %+v after removal (err %v)", aliases, err)
+	}
+}
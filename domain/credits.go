@@ -0,0 +1,65 @@
+package domain
+
+// credits.go retrieves the Xero bank transactions together with the overpayments and
+// prepayments received, as payouts sometimes arrive as an overpayment or prepayment
+// rather than as an invoice payment or a received bank transaction. The overpayments
+// and prepayments are stored as bank transactions of their types, RECEIVE-OVERPAYMENT
+// and RECEIVE-PREPAYMENT, so that they are listed, linked and reconciled with the bank
+// transactions.
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+)
+
+// xeroBankTransactionsGet retrieves the bank transactions, overpayments and prepayments
+// dated from fromDate and modified since ifModifiedSince. The overpayments and
+// prepayments are only retrieved if the xeroClient is a XeroCreditGetter.
+func xeroBankTransactionsGet(
+	ctx context.Context,
+	xeroClient XeroClient,
+	fromDate time.Time,
+	ifModifiedSince time.Time,
+	accountsRegexp *regexp.Regexp,
+) ([]xero.BankTransaction, error) {
+
+	transactions, err := xeroClient.GetBankTransactions(ctx, fromDate, ifModifiedSince, accountsRegexp)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "xero GetBankTransactions error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero bank transactions",
+		}
+	}
+
+	getter, ok := xeroClient.(XeroCreditGetter)
+	if !ok {
+		return transactions, nil
+	}
+	overpayments, err := getter.GetOverpayments(ctx, fromDate, ifModifiedSince, accountsRegexp)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "xero GetOverpayments error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero overpayments",
+		}
+	}
+	for _, op := range overpayments {
+		transactions = append(transactions, op.BankTransaction())
+	}
+	prepayments, err := getter.GetPrepayments(ctx, fromDate, ifModifiedSince, accountsRegexp)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "xero GetPrepayments error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero prepayments",
+		}
+	}
+	for _, pp := range prepayments {
+		transactions = append(transactions, pp.BankTransaction())
+	}
+	return transactions, nil
+}
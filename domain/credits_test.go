@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// mockCreditXeroClient returns an overpayment and a prepayment received, or fails to
+// retrieve the prepayments if prepaymentsErr is set.
+type mockCreditXeroClient struct {
+	mockXeroClient
+	prepaymentsErr error
+}
+
+func (m *mockCreditXeroClient) GetOverpayments(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Overpayment, error) {
+	return []xero.Overpayment{{
+		OverpaymentID: "op-001",
+		Type:          "RECEIVE-OVERPAYMENT",
+		Contact:       "Wilson Periodicals",
+		Date:          xero.XeroDateTime{Time: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)},
+		Status:        "AUTHORISED",
+		Total:         money.FromFloat(50),
+		LineItems:     []xero.LineItem{{LineItemID: "li-op-001", AccountCode: "5301", Quantity: 1, UnitAmount: money.FromFloat(50), LineAmount: money.FromFloat(50)}},
+	}}, nil
+}
+
+func (m *mockCreditXeroClient) GetPrepayments(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Prepayment, error) {
+	if m.prepaymentsErr != nil {
+		return nil, m.prepaymentsErr
+	}
+	return []xero.Prepayment{{
+		PrepaymentID: "pp-001",
+		Type:         "RECEIVE-PREPAYMENT",
+		Reference:    "Gala 2026 table",
+		Date:         xero.XeroDateTime{Time: time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC)},
+		Status:       "AUTHORISED",
+		Total:        money.FromFloat(250),
+		LineItems:    []xero.LineItem{{LineItemID: "li-pp-001", AccountCode: "5501", Quantity: 1, UnitAmount: money.FromFloat(250), LineAmount: money.FromFloat(250)}},
+	}}, nil
+}
+
+// TestCreditsRefresh tests that the overpayments and prepayments received are stored
+// as bank transactions of their types.
+func TestCreditsRefresh(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	xeroClient := &mockCreditXeroClient{mockXeroClient: mockXeroClient{log: logger}}
+	results, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Now(), regexp.MustCompile("."), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.TransactionsNo, 3; got != want {
+		t.Errorf("got %d bank transactions want %d", got, want)
+	}
+
+	var types []string
+	if err := testDB.Select(&types, "SELECT type FROM bank_transactions WHERE id IN ('op-001', 'pp-001') ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != "RECEIVE-OVERPAYMENT" || types[1] != "RECEIVE-PREPAYMENT" {
+		t.Errorf("got stored credit types %v", types)
+	}
+	var lineItems int
+	if err := testDB.Get(&lineItems, "SELECT count(*) FROM bank_transaction_line_items WHERE transaction_id IN ('op-001', 'pp-001')"); err != nil || lineItems != 2 {
+		t.Errorf("got %d credit line items, %v want 2", lineItems, err)
+	}
+
+	xeroClient.prepaymentsErr = errors.New("connection reset")
+	_, err = reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Now(), regexp.MustCompile("."), false)
+	if e, ok := errors.AsType[ErrSystem](err); !ok || e.Detail != "xero GetPrepayments error" {
+		t.Errorf("expected a prepayments retrieval error, got %v", err)
+	}
+}
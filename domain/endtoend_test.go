@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/mockapi"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// TestEndToEnd runs a full sync, link, write-back and incremental sync cycle of the
+// reconciler with the real api clients against the mock Xero and Salesforce apis, which
+// page their listings with small page sizes. The mock api fixtures have no donation
+// accounts, so the Xero records are not filtered by account.
+func TestEndToEnd(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	cfg, err := config.Load("../config/config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis, err := mockapi.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis.Configure(cfg)
+	apis.SetPageSizes(4, 7)
+	ctx := apis.WithClient(t.Context())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tok, err := cfg.Xero.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroToken, err := token.NewExtendedToken(token.XeroToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroClient, err := xero.NewClient(ctx, logger, cfg.DonationAccountCodesAsRegex(), xeroToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err = cfg.Salesforce.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfToken, err := token.NewExtendedToken(token.SalesforceToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfClient, err := salesforce.NewClient(ctx, cfg, logger, sfToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reconciler := NewReconciler(testDB, logger)
+
+	// Full sync.
+	refreshed := time.Now().Add(-time.Second)
+	xeroResults, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, cfg.DataStartDate, time.Time{}, nil, true)
+	if err != nil {
+		t.Fatalf("xero refresh error: %v", err)
+	}
+	if xeroResults.InvoicesNo == 0 || xeroResults.TransactionsNo == 0 || xeroResults.AccountsNo == 0 {
+		t.Fatalf("xero refresh got %+v", xeroResults)
+	}
+	sfResults, err := reconciler.SalesforceRecordsRefresh(ctx, sfClient, cfg.DataStartDate, time.Time{})
+	if err != nil {
+		t.Fatalf("salesforce refresh error: %v", err)
+	}
+	if sfResults.RecordsNo == 0 {
+		t.Fatalf("salesforce refresh got %+v", sfResults)
+	}
+
+	// Link a donation to an invoice, writing the reference back to both apis.
+	invoices, err := xeroClient.GetInvoices(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	donations, err := sfClient.GetOpportunities(ctx, cfg.DataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) == 0 || len(donations) == 0 {
+		t.Fatalf("got %d invoices and %d donations", len(invoices), len(donations))
+	}
+	invoiceID, donationID := invoices[0].InvoiceID, donations[0].ID
+	reference := "E2E-" + invoices[0].InvoiceNumber
+	action := LinkAction{
+		IDRefs:    []salesforce.IDRef{{ID: donationID, Ref: reference}},
+		InvoiceID: invoiceID,
+		Reference: reference,
+	}
+	if err := reconciler.LinkActionRun(ctx, sfClient, xeroClient, action, cfg.DataStartDate, refreshed); err != nil {
+		t.Fatalf("link error: %v", err)
+	}
+
+	remoteInvoice, err := xeroClient.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := remoteInvoice.Reference; got != reference {
+		t.Errorf("remote invoice reference got %q want %q", got, reference)
+	}
+	remoteDonations, err := sfClient.GetOpportunitiesByID(ctx, []string{donationID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remoteDonations) != 1 || remoteDonations[0].PayoutReference == nil || *remoteDonations[0].PayoutReference != reference {
+		t.Errorf("remote donations got %+v", remoteDonations)
+	}
+	localInvoice, _, err := testDB.InvoiceWRGet(ctx, invoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if localInvoice.Reference == nil || *localInvoice.Reference != reference {
+		t.Errorf("local invoice reference got %v want %q", localInvoice.Reference, reference)
+	}
+	localDonations, err := testDB.DonationRefsGet(ctx, []string{donationID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(localDonations) != 1 || localDonations[0].Ref != reference {
+		t.Errorf("local donations got %+v", localDonations)
+	}
+
+	// An incremental sync retrieves only the records changed by the link.
+	xeroResults, err = reconciler.XeroRecordsRefresh(ctx, xeroClient, cfg.DataStartDate, refreshed, nil, false)
+	if err != nil {
+		t.Fatalf("incremental xero refresh error: %v", err)
+	}
+	if got, want := xeroResults.InvoicesNo, 1; got != want {
+		t.Errorf("incremental xero refresh invoices got %d want %d", got, want)
+	}
+	if got, want := xeroResults.TransactionsNo, 0; got != want {
+		t.Errorf("incremental xero refresh transactions got %d want %d", got, want)
+	}
+	sfResults, err = reconciler.SalesforceRecordsRefresh(ctx, sfClient, cfg.DataStartDate, refreshed)
+	if err != nil {
+		t.Fatalf("incremental salesforce refresh error: %v", err)
+	}
+	if got, want := sfResults.RecordsNo, 1; got != want {
+		t.Errorf("incremental salesforce refresh records got %d want %d", got, want)
+	}
+
+	// Unlink the donation.
+	refreshed = time.Now()
+	action = LinkAction{IDRefs: []salesforce.IDRef{{ID: donationID}}}
+	if err := reconciler.LinkActionRun(ctx, sfClient, xeroClient, action, cfg.DataStartDate, refreshed); err != nil {
+		t.Fatalf("unlink error: %v", err)
+	}
+	remoteDonations, err = sfClient.GetOpportunitiesByID(ctx, []string{donationID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remoteDonations) != 1 || (remoteDonations[0].PayoutReference != nil && *remoteDonations[0].PayoutReference != "") {
+		t.Errorf("unlinked remote donations got %+v", remoteDonations)
+	}
+	localDonations, err = testDB.DonationRefsGet(ctx, []string{donationID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(localDonations) != 1 || localDonations[0].Ref != "" {
+		t.Errorf("unlinked local donations got %+v", localDonations)
+	}
+}
@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// GiftAidFields are the names of the Salesforce donation fields holding Gift Aid
+// eligibility and the donor details required by HMRC. The Title is optional.
+type GiftAidFields struct {
+	Eligible  string
+	Title     string
+	FirstName string
+	LastName  string
+	House     string
+	Postcode  string
+}
+
+// GiftAidDonation is a row of an HMRC Gift Aid schedule.
+type GiftAidDonation struct {
+	DonationID string
+	Title      string
+	FirstName  string
+	LastName   string
+	House      string
+	Postcode   string
+	Date       time.Time
+	Amount     money.Amount
+}
+
+// GiftAidExclusion is a donation marked as eligible for Gift Aid which cannot be
+// claimed, such as when the donor's address is missing.
+type GiftAidExclusion struct {
+	DonationID string
+	Name       string
+	Amount     money.Amount
+	Reason     string
+}
+
+// GiftAidClaim is a Gift Aid claim for a period, with the eligible donations which
+// could not be included.
+type GiftAidClaim struct {
+	DateFrom   time.Time
+	DateTo     time.Time
+	Donations  []GiftAidDonation
+	Total      money.Amount
+	Excluded   []GiftAidExclusion
+	Ineligible int
+}
+
+// HMRC schedule field length limits.
+const (
+	giftAidTitleLen = 4
+	giftAidNameLen  = 35
+	giftAidHouseLen = 40
+)
+
+// ukPostcode matches a UK postcode, with or without the space before the inward code.
+var ukPostcode = regexp.MustCompile(`^([A-Z]{1,2}[0-9][A-Z0-9]?) ?([0-9][A-Z]{2})$`)
+
+// houseNumber matches a leading house number in an address line, such as "12a".
+var houseNumber = regexp.MustCompile(`^(\d+[A-Za-z]?)\b`)
+
+// GiftAidClaimGet retrieves the Gift Aid claim for donations in the period from to to
+// which are linked to invoices or bank transactions with income in the accountCodes,
+// using the donation fields to determine eligibility and the donor details.
+func (r *Reconciler) GiftAidClaimGet(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	accountCodes *regexp.Regexp,
+	fields GiftAidFields,
+) (*GiftAidClaim, error) {
+
+	if to.Before(from) {
+		return nil, ErrUsage{
+			Detail: "GiftAidClaimGet date error",
+			Msg:    "The claim end date must not be before the start date",
+		}
+	}
+	if accountCodes == nil || fields.Eligible == "" {
+		return nil, ErrUsage{
+			Detail: "GiftAidClaimGet configuration error",
+			Msg:    "Gift Aid claims are not configured",
+		}
+	}
+
+	donations, err := r.db.GiftAidDonationsGet(ctx, from, to, accountCodes.String())
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.GiftAidDonationsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Gift Aid donations",
+		}
+	}
+
+	claim := &GiftAidClaim{DateFrom: from, DateTo: to}
+	for _, d := range donations {
+		values := map[string]any{}
+		if d.AdditionalFields != "" {
+			if err := json.Unmarshal([]byte(d.AdditionalFields), &values); err != nil {
+				return nil, ErrSystem{
+					Detail: fmt.Sprintf("donation %s additional fields decoding error", d.ID),
+					Err:    err,
+					Msg:    "A problem was encountered reading the donation details",
+				}
+			}
+		}
+		field := func(name string) string {
+			return giftAidFieldValue(values, name)
+		}
+		if !giftAidEligible(field(fields.Eligible)) {
+			claim.Ineligible++
+			continue
+		}
+
+		gad := GiftAidDonation{
+			DonationID: d.ID,
+			Title:      truncateRunes(field(fields.Title), giftAidTitleLen),
+			FirstName:  truncateRunes(field(fields.FirstName), giftAidNameLen),
+			LastName:   truncateRunes(field(fields.LastName), giftAidNameLen),
+			House:      giftAidHouse(field(fields.House)),
+			Date:       d.CloseDate,
+			Amount:     d.Amount,
+		}
+		postcode, postcodeOK := giftAidPostcode(field(fields.Postcode))
+		gad.Postcode = postcode
+
+		var reason string
+		switch {
+		case gad.FirstName == "" || gad.LastName == "":
+			reason = "donor name missing"
+		case gad.House == "":
+			reason = "house name or number missing"
+		case postcode == "":
+			reason = "postcode missing"
+		case !postcodeOK:
+			reason = fmt.Sprintf("invalid postcode %q", postcode)
+		case d.Amount <= 0:
+			reason = "amount is not positive"
+		}
+		if reason != "" {
+			claim.Excluded = append(claim.Excluded, GiftAidExclusion{
+				DonationID: d.ID,
+				Name:       d.Name,
+				Amount:     d.Amount,
+				Reason:     reason,
+			})
+			continue
+		}
+		claim.Donations = append(claim.Donations, gad)
+		claim.Total += d.Amount
+	}
+	return claim, nil
+}
+
+// giftAidFieldValue returns the named field from the donation additional fields as a
+// trimmed string. Field names are matched case insensitively.
+func giftAidFieldValue(values map[string]any, name string) string {
+	if name == "" {
+		return ""
+	}
+	v, ok := values[name]
+	if !ok {
+		for k, kv := range values {
+			if strings.EqualFold(k, name) {
+				v, ok = kv, true
+				break
+			}
+		}
+	}
+	if !ok || v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+// giftAidEligible reports if a Gift Aid eligibility field value is true, such as for
+// a Salesforce checkbox or a "Yes" picklist value.
+func giftAidEligible(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "yes", "y", "1":
+		return true
+	}
+	return false
+}
+
+// giftAidHouse returns the house name or number from the first line of an address,
+// using the leading house number if there is one.
+func giftAidHouse(address string) string {
+	line, _, _ := strings.Cut(address, "\n")
+	line = strings.TrimSpace(line)
+	if m := houseNumber.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return truncateRunes(strings.TrimRight(line, ","), giftAidHouseLen)
+}
+
+// giftAidPostcode normalises a UK postcode to upper case with a single space before
+// the inward code, reporting if it is valid.
+func giftAidPostcode(postcode string) (string, bool) {
+	pc := strings.ToUpper(strings.Join(strings.Fields(postcode), " "))
+	m := ukPostcode.FindStringSubmatch(pc)
+	if m == nil {
+		return pc, false
+	}
+	return m[1] + " " + m[2], true
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,152 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestGiftAidHelpers(t *testing.T) {
+
+	postcodes := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"sw1a 1aa", "SW1A 1AA", true},
+		{"SW1A1AA", "SW1A 1AA", true},
+		{" m1  1ae ", "M1 1AE", true},
+		{"B33 8TH", "B33 8TH", true},
+		{"12345", "12345", false},
+		{"", "", false},
+	}
+	for _, tt := range postcodes {
+		got, ok := giftAidPostcode(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("postcode %q got %q %t want %q %t", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	houses := []struct {
+		in   string
+		want string
+	}{
+		{"12 High Street", "12"},
+		{"12a High Street\nLondon", "12a"},
+		{"Rose Cottage, Mill Lane", "Rose Cottage, Mill Lane"},
+		{"The Old Rectory,\nChurch Road", "The Old Rectory"},
+		{"", ""},
+	}
+	for _, tt := range houses {
+		if got := giftAidHouse(tt.in); got != tt.want {
+			t.Errorf("house %q got %q want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for v, want := range map[string]bool{"true": true, "Yes": true, "1": true, "false": false, "": false, "No": false} {
+		if got := giftAidEligible(v); got != want {
+			t.Errorf("eligible %q got %t want %t", v, got, want)
+		}
+	}
+}
+
+// TestGiftAidClaimGet tests building a Gift Aid claim from donations linked to an
+// invoice in the test database.
+func TestGiftAidClaimGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	closeDate := time.Date(2025, 4, 9, 0, 0, 0, 0, time.UTC)
+
+	donation := func(id string, amount float64, fields map[string]any) salesforce.Donation {
+		return salesforce.Donation{
+			CoreFields: salesforce.CoreFields{
+				ID:              id,
+				Name:            "Gift Aid test " + id,
+				Amount:          money.FromFloat(amount),
+				CloseDate:       salesforce.SalesforceDate{Time: closeDate},
+				PayoutReference: new("INV-2025-101"),
+			},
+			AdditionalFields: fields,
+		}
+	}
+	err := testDB.UpsertDonations(ctx, []salesforce.Donation{
+		donation("ga-001", 25, map[string]any{
+			"GiftAid": true, "Title": "Mrs", "FirstName": "Jane", "LastName": "Smith",
+			"Street": "12 High Street", "Postcode": "sw1a1aa",
+		}),
+		donation("ga-002", 10, map[string]any{
+			"GiftAid": true, "FirstName": "John", "LastName": "Doe",
+			"Street": "Rose Cottage", "Postcode": "",
+		}),
+		donation("ga-003", 15, map[string]any{
+			"GiftAid": false, "FirstName": "Ann", "LastName": "Other",
+			"Street": "1 Main Road", "Postcode": "M1 1AE",
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := GiftAidFields{
+		Eligible:  "GiftAid",
+		Title:     "Title",
+		FirstName: "FirstName",
+		LastName:  "LastName",
+		House:     "Street",
+		Postcode:  "Postcode",
+	}
+	claim, err := reconciler.GiftAidClaimGet(ctx, from, to, regexp.MustCompile("^(55)"), fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []GiftAidDonation{
+		{"ga-001", "Mrs", "Jane", "Smith", "12", "SW1A 1AA", closeDate, money.FromFloat(25)},
+	}
+	if diff := cmp.Diff(want, claim.Donations); diff != "" {
+		t.Errorf("unexpected donations (-want +got):\n%s", diff)
+	}
+	if got, want := claim.Total, money.FromFloat(25); got != want {
+		t.Errorf("total got %.2f want %.2f", got, want)
+	}
+	wantExcluded := []GiftAidExclusion{
+		{"ga-002", "Gift Aid test ga-002", money.FromFloat(10), "postcode missing"},
+	}
+	if diff := cmp.Diff(wantExcluded, claim.Excluded); diff != "" {
+		t.Errorf("unexpected exclusions (-want +got):\n%s", diff)
+	}
+	// The ineligible donation and the test data donations without Gift Aid fields.
+	if got, want := claim.Ineligible, 17; got != want {
+		t.Errorf("ineligible got %d want %d", got, want)
+	}
+
+	// Other account codes have no Gift Aid donations.
+	claim, err = reconciler.GiftAidClaimGet(ctx, from, to, regexp.MustCompile("^(99)"), fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claim.Donations) != 0 || len(claim.Excluded) != 0 {
+		t.Errorf("expected an empty claim, got %+v", claim)
+	}
+
+	// A missing eligibility field is a usage error.
+	_, err = reconciler.GiftAidClaimGet(ctx, from, to, regexp.MustCompile("^(55)"), GiftAidFields{})
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
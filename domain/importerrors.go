@@ -0,0 +1,139 @@
+package domain
+
+// importerrors.go stores the refreshed records which fail as a batch one at a time, so
+// that a single record which cannot be upserted, such as one referring to a missing
+// record, is quarantined as an import error rather than failing the whole refresh. The
+// import errors may then be reviewed, and retried or discarded.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// upsertRecords upserts the records of recordType from source with upsert, returning
+// the records which were stored and the number quarantined. If the batch upsert fails
+// each record is upserted on its own, and those which fail are recorded as import
+// errors keyed by the record id. The batch error is returned if no record could be
+// stored, as the problem is then not with the records.
+func upsertRecords[T any](
+	ctx context.Context,
+	r *Reconciler,
+	source, recordType string,
+	records []T,
+	id func(T) string,
+	upsert func(context.Context, []T) error,
+) ([]T, int, error) {
+
+	batchErr := upsert(ctx, records)
+	if batchErr == nil {
+		return records, 0, nil
+	}
+	r.log.Warn("batch upsert failed, upserting records singly", "source", source, "record", recordType, "records", len(records), "error", batchErr)
+
+	var stored []T
+	var failed []db.ImportError
+	for _, rec := range records {
+		err := upsert(ctx, []T{rec})
+		if err == nil {
+			stored = append(stored, rec)
+			continue
+		}
+		payload, jerr := json.Marshal(rec)
+		if jerr != nil {
+			payload = fmt.Appendf(nil, "%q", fmt.Sprintf("%+v", rec))
+		}
+		failed = append(failed, db.ImportError{
+			Source:     source,
+			RecordType: recordType,
+			RecordID:   id(rec),
+			Error:      err.Error(),
+			Payload:    string(payload),
+		})
+		r.log.Warn("record could not be upserted", "source", source, "record", recordType, "id", id(rec), "error", err)
+	}
+	if len(stored) == 0 {
+		return nil, 0, batchErr
+	}
+	if err := r.db.ImportErrorsUpsert(ctx, failed); err != nil {
+		return stored, len(failed), fmt.Errorf("could not record the records which failed to upsert: %w", err)
+	}
+	return stored, len(failed), nil
+}
+
+// ImportErrorsGet retrieves the most recent import errors.
+func (r *Reconciler) ImportErrorsGet(ctx context.Context) ([]db.ImportError, error) {
+	importErrors, err := r.db.ImportErrorsGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{Detail: "db.ImportErrorsGet error", Err: err, Msg: "A problem was encountered retrieving the import errors"}
+	}
+	return importErrors, nil
+}
+
+// importErrorGet retrieves the import error with id.
+func (r *Reconciler) importErrorGet(ctx context.Context, id int64) (db.ImportError, error) {
+	ie, err := r.db.ImportErrorGet(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ie, ErrNotFound{Detail: "ImportErrorGet error", Msg: fmt.Sprintf("import error %d was not found", id)}
+	}
+	if err != nil {
+		return ie, ErrSystem{Detail: "db.ImportErrorGet error", Err: err, Msg: "A problem was encountered retrieving the import error"}
+	}
+	return ie, nil
+}
+
+// retriableRecordTypes are the record types of the import errors which may be retried,
+// being those which can be retrieved singly by RecordRefresh.
+var retriableRecordTypes = []string{"invoice", "bank-transaction", "donation"}
+
+// ImportErrorRetry retrieves the record of the import error with id again and upserts
+// it, deleting the import error if the record is stored. Invoices and bank transactions
+// require the xeroClient, and donations the sfClient. Accounts and contacts, and
+// records without an id, cannot be retrieved singly and are instead retried by the next
+// refresh.
+func (r *Reconciler) ImportErrorRetry(ctx context.Context, xeroClient XeroClient, sfClient SalesforceClient, id int64) error {
+
+	ie, err := r.importErrorGet(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !slices.Contains(retriableRecordTypes, ie.RecordType) || ie.RecordID == "":
+		return ErrUsage{
+			Detail: fmt.Sprintf("import error %d %s %q cannot be retried", id, ie.RecordType, ie.RecordID),
+			Msg:    fmt.Sprintf("The %s cannot be retrieved on its own and is retried by the next refresh", ie.RecordType),
+		}
+	case ie.Source == "xero" && xeroClient == nil:
+		return ErrUsage{
+			Detail: "no xero client",
+			Msg:    "Xero must be connected to retry a Xero record",
+		}
+	case ie.Source == "salesforce" && sfClient == nil:
+		return ErrUsage{
+			Detail: "no salesforce client",
+			Msg:    "Salesforce must be connected to retry a Salesforce record",
+		}
+	}
+
+	if err := r.RecordRefresh(ctx, xeroClient, sfClient, ie.RecordType, ie.RecordID); err != nil {
+		return err
+	}
+	return r.ImportErrorDiscard(ctx, id)
+}
+
+// ImportErrorDiscard deletes the import error with id, leaving its record unstored
+// until it is retrieved again by a refresh.
+func (r *Reconciler) ImportErrorDiscard(ctx context.Context, id int64) error {
+	if _, err := r.importErrorGet(ctx, id); err != nil {
+		return err
+	}
+	if err := r.db.ImportErrorDelete(ctx, id); err != nil {
+		return ErrSystem{Detail: "db.ImportErrorDelete error", Err: err, Msg: "A problem was encountered deleting the import error"}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// mockUpsertFailureXeroClient returns invoices which fail to upsert as a batch, as the
+// line item of inv-002 is already that of inv-q1, and retrieves inv-002 singly with its
+// own line items.
+type mockUpsertFailureXeroClient struct {
+	mockRecordXeroClient
+}
+
+func (m *mockUpsertFailureXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
+	invoice := func(id, lineItemID string) xero.Invoice {
+		return xero.Invoice{
+			InvoiceID: id,
+			Type:      "ACCREC",
+			Status:    "PAID",
+			Date:      xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+			Total:     money.FromFloat(10),
+			LineItems: []xero.LineItem{{LineItemID: lineItemID, AccountCode: "5301", Quantity: 1, UnitAmount: money.FromFloat(10), LineAmount: money.FromFloat(10)}},
+		}
+	}
+	return []xero.Invoice{
+		invoice("inv-q1", "li-q1"),
+		invoice("inv-002", "li-q1"),
+		invoice("inv-q2", "li-q2"),
+	}, nil
+}
+
+// TestImportErrorsUpsertFailure tests that the records which fail to upsert are
+// quarantined as import errors while the others are stored, and that the import errors
+// may be retried or discarded.
+func TestImportErrorsUpsertFailure(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	xeroClient := &mockUpsertFailureXeroClient{mockRecordXeroClient{mockXeroClient: mockXeroClient{log: logger}}}
+	results, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Now(), regexp.MustCompile("."), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.InvoicesNo != 2 || results.RejectedNo != 1 {
+		t.Errorf("got %d invoices and %d rejected want 2 and 1", results.InvoicesNo, results.RejectedNo)
+	}
+	var n int
+	if err := testDB.Get(&n, "SELECT count(*) FROM invoice_line_items WHERE invoice_id IN ('inv-q1', 'inv-q2')"); err != nil || n != 2 {
+		t.Errorf("got %d line items of the stored invoices, %v want 2", n, err)
+	}
+
+	importErrors, err := reconciler.ImportErrorsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(importErrors) != 1 || importErrors[0].RecordID != "inv-002" || importErrors[0].Error == "" {
+		t.Fatalf("unexpected import errors %+v", importErrors)
+	}
+	id := importErrors[0].ID
+
+	// Retrying requires the Xero client.
+	if err := reconciler.ImportErrorRetry(ctx, nil, nil, id); !errors.As(err, new(ErrUsage)) {
+		t.Errorf("expected a usage error retrying without a xero client, got %v", err)
+	}
+	if err := reconciler.ImportErrorRetry(ctx, xeroClient, nil, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.Get(&n, "SELECT count(*) FROM invoice_line_items WHERE invoice_id = 'inv-002'"); err != nil || n != 2 {
+		t.Errorf("got %d line items of the retried invoice, %v want 2", n, err)
+	}
+	if err := reconciler.ImportErrorDiscard(ctx, id); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected the retried import error to be deleted, got %v", err)
+	}
+
+	// A contact cannot be retried singly, but may be discarded.
+	if err := testDB.ImportErrorsUpsert(ctx, []db.ImportError{{Source: "xero", RecordType: "contact", RecordID: "cId-9", Error: "failed", Payload: "{}"}}); err != nil {
+		t.Fatal(err)
+	}
+	importErrors, err = reconciler.ImportErrorsGet(ctx)
+	if err != nil || len(importErrors) != 1 {
+		t.Fatalf("got import errors %+v, %v want 1", importErrors, err)
+	}
+	id = importErrors[0].ID
+	if err := reconciler.ImportErrorRetry(ctx, xeroClient, nil, id); !errors.As(err, new(ErrUsage)) {
+		t.Errorf("expected a usage error retrying a contact, got %v", err)
+	}
+	if err := reconciler.ImportErrorDiscard(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if importErrors, err = reconciler.ImportErrorsGet(ctx); err != nil || len(importErrors) != 0 {
+		t.Errorf("got import errors %+v, %v want none", importErrors, err)
+	}
+}
@@ -0,0 +1,277 @@
+package domain
+
+// imports.go stages imports for review. The rows of an import are validated and staged
+// with their errors, and only the accepted rows are committed to the live tables once
+// the import is confirmed.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/donorimport"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// ImportReview is a staged import with its rows for review.
+type ImportReview struct {
+	Batch db.ImportBatch
+	Rows  []ImportReviewRow
+}
+
+// ImportReviewRow is a row of a staged import. The Error is empty for accepted rows.
+type ImportReviewRow struct {
+	RowNo    int
+	RecordID string
+	Values   []string
+	Error    string
+}
+
+// ImportCommitResult reports the commit of an import. Rows are skipped where they
+// could not be committed, such as imported donations whose ids are held by donations of
+// another source.
+type ImportCommitResult struct {
+	Batch     db.ImportBatch
+	Committed int
+	Skipped   int
+}
+
+// stagedDonation is the payload of an accepted row of a donations import.
+type stagedDonation struct {
+	ID              string
+	ImportID        string
+	Name            string
+	Amount          money.Amount
+	CloseDate       time.Time
+	PayoutReference *string
+	CreatedBy       string
+	CreatedDate     time.Time
+}
+
+// stagedRow returns the import row numbered rowNo with its values and, if rowErr is
+// nil, its json encoded payload. Otherwise the row is rejected with rowErr.
+func stagedRow(rowNo int, recordID string, vals []string, payload any, rowErr error) (db.ImportRow, error) {
+	row := db.ImportRow{RowNo: rowNo, RecordID: recordID}
+	v, err := json.Marshal(vals)
+	if err != nil {
+		return row, fmt.Errorf("row %d values encoding error: %w", rowNo, err)
+	}
+	row.Vals = string(v)
+	if rowErr != nil {
+		e := rowErr.Error()
+		row.Error = &e
+		return row, nil
+	}
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return row, fmt.Errorf("row %d payload encoding error: %w", rowNo, err)
+	}
+	pl := string(p)
+	row.Payload = &pl
+	return row, nil
+}
+
+// DonationsStage validates the rows of a sheet of donations exported from a CRM other
+// than Salesforce, using the mapping of its columns to the donation fields, and stages
+// them for review, returning the id of the import batch. Once committed the accepted
+// donations are upserted tagged with source.
+func (r *Reconciler) DonationsStage(ctx context.Context, source string, sheet *donorimport.Sheet, mapping donorimport.Mapping) (int64, error) {
+
+	if err := donorimport.ValidSource(source); err != nil {
+		return 0, ErrUsage{Detail: "DonationsStage source error", Msg: fmt.Sprintf("The source is invalid: %v", err)}
+	}
+	if err := mapping.Validate(sheet.Headers); err != nil {
+		return 0, ErrUsage{Detail: "DonationsStage mapping error", Msg: fmt.Sprintf("The column mapping is invalid: %v", err)}
+	}
+	if len(sheet.Rows) == 0 {
+		return 0, ErrUsage{Detail: "DonationsStage error", Msg: "The file contains no donations"}
+	}
+
+	converted := sheet.Convert(mapping, source, time.Now())
+	rows := make([]db.ImportRow, len(converted))
+	for i, c := range converted {
+		var err error
+		rows[i], err = stagedRow(c.Number, c.ID, c.Values, stagedDonation{
+			ID:              c.Donation.ID,
+			ImportID:        c.ID,
+			Name:            c.Donation.Name,
+			Amount:          c.Donation.Amount,
+			CloseDate:       c.Donation.CloseDate.Time,
+			PayoutReference: c.Donation.PayoutReference,
+			CreatedBy:       string(c.Donation.CreatedBy),
+			CreatedDate:     c.Donation.CreatedDate.Time,
+		}, c.Err)
+		if err != nil {
+			return 0, ErrSystem{Detail: "DonationsStage encoding error", Err: err, Msg: "The donations could not be staged"}
+		}
+	}
+
+	id, err := r.db.ImportBatchCreate(ctx, db.ImportDonations, source, sheet.FileName, rows)
+	if err != nil {
+		return 0, ErrSystem{Detail: "db.ImportBatchCreate error", Err: err, Msg: "The donations could not be staged"}
+	}
+	r.log.Info(fmt.Sprintf("DonationsStage: staged %d %s donations from %s as batch %d", len(rows), source, sheet.FileName, id))
+	return id, nil
+}
+
+// ImportBatchesGet retrieves the most recent imports.
+func (r *Reconciler) ImportBatchesGet(ctx context.Context) ([]db.ImportBatch, error) {
+	batches, err := r.db.ImportBatchesGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{Detail: "db.ImportBatchesGet error", Err: err, Msg: "A problem was encountered retrieving the imports"}
+	}
+	return batches, nil
+}
+
+// importBatchGet retrieves the import batch with id.
+func (r *Reconciler) importBatchGet(ctx context.Context, id int64) (db.ImportBatch, error) {
+	batch, err := r.db.ImportBatchGet(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return batch, ErrNotFound{Detail: "ImportBatchGet error", Msg: fmt.Sprintf("import %d was not found", id)}
+	}
+	if err != nil {
+		return batch, ErrSystem{Detail: "db.ImportBatchGet error", Err: err, Msg: "A problem was encountered retrieving the import"}
+	}
+	return batch, nil
+}
+
+// ImportReviewGet retrieves the import with id and its rows for review.
+func (r *Reconciler) ImportReviewGet(ctx context.Context, id int64) (*ImportReview, error) {
+
+	batch, err := r.importBatchGet(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.ImportRowsGet(ctx, id)
+	if err != nil {
+		return nil, ErrSystem{Detail: "db.ImportRowsGet error", Err: err, Msg: "A problem was encountered retrieving the import rows"}
+	}
+
+	review := &ImportReview{Batch: batch, Rows: make([]ImportReviewRow, len(rows))}
+	for i, row := range rows {
+		review.Rows[i] = ImportReviewRow{RowNo: row.RowNo, RecordID: row.RecordID}
+		if err := json.Unmarshal([]byte(row.Vals), &review.Rows[i].Values); err != nil {
+			return nil, ErrSystem{Detail: "import row values decoding error", Err: err, Msg: "The import rows could not be read"}
+		}
+		if row.Error != nil {
+			review.Rows[i].Error = *row.Error
+		}
+	}
+	return review, nil
+}
+
+// ImportCommit commits the accepted rows of the staged import with id to the live
+// tables, marking the import as committed with them.
+func (r *Reconciler) ImportCommit(ctx context.Context, id int64) (*ImportCommitResult, error) {
+
+	var result *ImportCommitResult
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		batch, err := r.importBatchGet(ctx, id)
+		if err != nil {
+			return err
+		}
+		if batch.Status != "staged" {
+			return ErrUsage{Detail: "ImportCommit error", Msg: fmt.Sprintf("The import has been %s", batch.Status)}
+		}
+		if batch.Accepted == 0 {
+			return ErrUsage{Detail: "ImportCommit error", Msg: "The import has no accepted rows to commit"}
+		}
+		rows, err := r.db.ImportRowsGet(ctx, id)
+		if err != nil {
+			return ErrSystem{Detail: "db.ImportRowsGet error", Err: err, Msg: "A problem was encountered retrieving the import rows"}
+		}
+
+		var payloads [][]byte
+		for _, row := range rows {
+			if row.Payload != nil {
+				payloads = append(payloads, []byte(*row.Payload))
+			}
+		}
+
+		var committed int
+		switch batch.Kind {
+		case db.ImportDonations:
+			committed, err = r.commitDonations(ctx, batch.Target, payloads)
+		case db.ImportPayoutItems:
+			committed, err = r.commitPayoutItems(ctx, batch.Target, payloads)
+		case db.ImportStatementLines:
+			committed, err = r.commitStatementLines(ctx, batch.Target, payloads)
+		default:
+			err = fmt.Errorf("unknown import kind %q", batch.Kind)
+		}
+		if err != nil {
+			return ErrSystem{Detail: "ImportCommit error", Err: err, Msg: "A problem was encountered committing the import"}
+		}
+
+		if _, err := r.db.ImportBatchResolve(ctx, id, "committed"); err != nil {
+			return ErrSystem{Detail: "db.ImportBatchResolve error", Err: err, Msg: "A problem was encountered marking the import as committed"}
+		}
+		r.log.Info(fmt.Sprintf("ImportCommit: committed %d of %d rows of import %d", committed, len(payloads), id))
+		result = &ImportCommitResult{Batch: batch, Committed: committed, Skipped: len(payloads) - committed}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// commitDonations upserts the staged donations tagged with source.
+func (r *Reconciler) commitDonations(ctx context.Context, source string, payloads [][]byte) (int, error) {
+	donations := make([]salesforce.Donation, len(payloads))
+	for i, p := range payloads {
+		var d stagedDonation
+		if err := json.Unmarshal(p, &d); err != nil {
+			return 0, fmt.Errorf("staged donation decoding error: %w", err)
+		}
+		donations[i] = salesforce.Donation{
+			CoreFields: salesforce.CoreFields{
+				ID:               d.ID,
+				Name:             d.Name,
+				Amount:           d.Amount,
+				CloseDate:        salesforce.SalesforceDate{Time: d.CloseDate},
+				CreatedDate:      salesforce.SalesforceTime{Time: d.CreatedDate},
+				LastModifiedDate: salesforce.SalesforceTime{Time: time.Now()},
+				CreatedBy:        salesforce.FlattenedName(d.CreatedBy),
+				LastModifiedBy:   salesforce.FlattenedName(d.CreatedBy),
+				PayoutReference:  d.PayoutReference,
+			},
+			AdditionalFields: map[string]any{"ImportID": d.ImportID},
+		}
+	}
+	return r.db.ImportDonations(ctx, source, donations)
+}
+
+// commitPayoutItems replaces the report items of the payout with reference with the
+// staged items.
+func (r *Reconciler) commitPayoutItems(ctx context.Context, reference string, payloads [][]byte) (int, error) {
+	items := make([]db.PayoutItem, len(payloads))
+	for i, p := range payloads {
+		if err := json.Unmarshal(p, &items[i]); err != nil {
+			return 0, fmt.Errorf("staged payout item decoding error: %w", err)
+		}
+	}
+	return r.db.PayoutItemsReplace(ctx, reference, items)
+}
+
+// ImportDiscard discards the staged import with id, so that it may not be committed.
+func (r *Reconciler) ImportDiscard(ctx context.Context, id int64) error {
+
+	batch, err := r.importBatchGet(ctx, id)
+	if err != nil {
+		return err
+	}
+	ok, err := r.db.ImportBatchResolve(ctx, id, "discarded")
+	if err != nil {
+		return ErrSystem{Detail: "db.ImportBatchResolve error", Err: err, Msg: "A problem was encountered discarding the import"}
+	}
+	if !ok {
+		return ErrUsage{Detail: "ImportDiscard error", Msg: fmt.Sprintf("The import has been %s", batch.Status)}
+	}
+	return nil
+}
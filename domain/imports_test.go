@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rorycl/reconciler/internal/donorimport"
+)
+
+// TestDonationsStage tests staging a sheet of donations, reviewing its accepted and
+// rejected rows, and committing only the accepted rows.
+func TestDonationsStage(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	sheet := &donorimport.Sheet{
+		FileName: "export.csv",
+		Headers:  []string{"Ref", "Donor", "Gift Amount", "Gift Date", "Batch"},
+		Rows: [][]string{
+			{"1001", "Jane Smith", "20.00", "2025-04-14", "JG-PAYOUT-2025-04-15"},
+			{"1002", "John Smith", "ten", "15/04/2025", ""},
+			{"1003", "Jo Smith", "5.00", "15/04/2025", ""},
+		},
+	}
+	mapping := donorimport.Mapping{"ID": 0, "Name": 1, "Amount": 2, "CloseDate": 3, "PayoutReference": 4}
+
+	_, err := reconciler.DonationsStage(ctx, "salesforce", sheet, mapping)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error for a reserved source, got %v", err)
+	}
+	_, err = reconciler.DonationsStage(ctx, "crm", sheet, donorimport.Mapping{"ID": 0})
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error for an incomplete mapping, got %v", err)
+	}
+
+	id, err := reconciler.DonationsStage(ctx, "crm", sheet, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := reconciler.ImportReviewGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Batch.Accepted != 2 || review.Batch.Rejected != 1 {
+		t.Fatalf("got %d accepted and %d rejected rows", review.Batch.Accepted, review.Batch.Rejected)
+	}
+	if got, want := review.Rows[1].Error, `row 3 Amount: invalid amount: "ten"`; got != want {
+		t.Errorf("row error got %q want %q", got, want)
+	}
+
+	countImported := func() int {
+		var n int
+		if err := testDB.GetContext(ctx, &n, "SELECT count(*) FROM donations WHERE source = 'crm'"); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if got := countImported(); got != 0 {
+		t.Errorf("got %d donations before commit", got)
+	}
+
+	result, err := reconciler.ImportCommit(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Committed != 2 || countImported() != 2 {
+		t.Errorf("got %d committed and %d imported donations want 2", result.Committed, countImported())
+	}
+
+	// A committed import may be neither committed again nor discarded.
+	if _, err := reconciler.ImportCommit(ctx, id); !errors.As(err, &ErrUsage{}) {
+		t.Errorf("expected a usage error committing again, got %v", err)
+	}
+	if err := reconciler.ImportDiscard(ctx, id); !errors.As(err, &ErrUsage{}) {
+		t.Errorf("expected a usage error discarding, got %v", err)
+	}
+	if _, err := reconciler.ImportReviewGet(ctx, id+1); !errors.As(err, &ErrNotFound{}) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package domain
+
+// lineitems.go retrieves the line items of the invoices and bank transactions of a
+// listing for export, one row for each line item, so that the records may be analysed
+// by account code outside the app.
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// LineItemsExportGet retrieves the line items of the invoices, or the bank transactions
+// if recordType is "bank-transaction", found by the search terms of the listing, in
+// the listing order, with only those assigned to assignedTo if it is not empty. All the
+// records found are included, not only a page of them.
+func (r *Reconciler) LineItemsExportGet(
+	ctx context.Context,
+	recordType string,
+	status string,
+	from time.Time,
+	to time.Time,
+	search string,
+	assignedTo string,
+	sort db.SortOrder,
+) ([]db.LineItemExport, error) {
+
+	var ids []string
+	switch recordType {
+	case "invoice":
+		invoices, err := r.db.InvoicesGet(ctx, status, from, to, search, assignedTo, sort, -1, 0)
+		if err != nil {
+			return nil, ErrSystem{
+				Detail: "db.InvoicesGet error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the invoices to export",
+			}
+		}
+		for _, inv := range invoices {
+			ids = append(ids, inv.InvoiceID)
+		}
+	case "bank-transaction":
+		transactions, err := r.db.BankTransactionsGet(ctx, status, from, to, search, assignedTo, sort, -1, 0)
+		if err != nil {
+			return nil, ErrSystem{
+				Detail: "db.BankTransactionsGet error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the bank transactions to export",
+			}
+		}
+		for _, trn := range transactions {
+			ids = append(ids, trn.ID)
+		}
+	default:
+		return nil, ErrUsage{
+			Detail: "LineItemsExportGet error",
+			Msg:    fmt.Sprintf("line items cannot be exported for %q records", recordType),
+		}
+	}
+
+	items, err := r.db.LineItemsExportGet(ctx, recordType, ids)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.LineItemsExportGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the line items to export",
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,482 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/linking"
+)
+
+// The steps of a link action.
+const (
+	linkStepSalesforce = "salesforce"
+	linkStepXero       = "xero"
+	linkStepLocal      = "local"
+)
+
+// LinkAction is a link or unlink action, which updates the payout references of
+// donations in Salesforce, optionally writes the payout reference to a Xero invoice,
+// and then refreshes the local records. The previous references are recorded when the
+// action is started so that it may be reversed. An unlink action has empty refs.
+type LinkAction struct {
+	Action            string // "link" or "unlink", set from the IDRefs
+	IDRefs            []salesforce.IDRef
+	PreviousRefs      []salesforce.IDRef
+	InvoiceID         string // the Xero invoice to update, if any
+	Reference         string
+	PreviousReference string
+	Override          string `json:"-"` // the reason for overriding any period locks
+}
+
+// Description describes the action for the pending actions page.
+func (a LinkAction) Description() string {
+	if a.Action == "unlink" {
+		return fmt.Sprintf("unlink %d donations", len(a.IDRefs))
+	}
+	desc := fmt.Sprintf("link %d donations to %s", len(a.IDRefs), a.IDRefs[0].Ref)
+	if a.InvoiceID != "" {
+		desc += " and update the Xero invoice reference"
+	}
+	return desc
+}
+
+// LinkActionRun records and runs a link action. The references of the donations are
+// updated in Salesforce, the reference of the Xero invoice is updated if an InvoiceID
+// is given, and the local records are then refreshed. If a step fails the action is
+// left failed at that step, to be retried or reversed from the pending actions page.
+// If the salesforce step failed as Salesforce could not be reached, the updates are
+// also queued to be retried by OutboxRetry and ErrQueued is returned. The xeroClient
+// may be nil if no invoice is to be updated. ErrConflict is returned, and nothing
+// written, if the records have been changed remotely since they were last refreshed.
+// ErrLocked is returned if the records are dated in a locked period, unless the action
+// has an Override reason.
+func (r *Reconciler) LinkActionRun(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	action LinkAction,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) error {
+
+	if len(action.IDRefs) == 0 {
+		return ErrUsage{
+			Detail: "LinkActionRun error",
+			Msg:    "no records were provided to link/unlink",
+		}
+	}
+	if action.InvoiceID != "" && action.Reference == "" {
+		return ErrUsage{
+			Detail: "LinkActionRun error",
+			Msg:    "a reference must be provided to update the invoice reference",
+		}
+	}
+	action.Action = "unlink"
+	for _, idRef := range action.IDRefs {
+		if idRef.Ref != "" {
+			action.Action = "link"
+			break
+		}
+	}
+
+	// Record the references replaced by the action.
+	ids := make([]string, len(action.IDRefs))
+	for i, idRef := range action.IDRefs {
+		ids[i] = idRef.ID
+	}
+	donations, err := r.db.DonationRefsGet(ctx, ids)
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.DonationRefsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the current donation references",
+		}
+	}
+	for _, d := range donations {
+		action.PreviousRefs = append(action.PreviousRefs, salesforce.IDRef{ID: d.ID, Ref: d.Ref})
+	}
+	var invoice *db.WRInvoice
+	if action.InvoiceID != "" {
+		inv, _, err := r.db.InvoiceWRGet(ctx, action.InvoiceID)
+		if err != nil {
+			return ErrSystem{
+				Detail: "db.InvoiceWRGet error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the current invoice reference",
+			}
+		}
+		if inv.Reference != nil {
+			action.PreviousReference = *inv.Reference
+		}
+		invoice = &inv
+	}
+
+	// Refuse to change records in locked periods without an override.
+	refs := []string{action.Reference, action.PreviousReference}
+	for _, idRef := range append(action.IDRefs, action.PreviousRefs...) {
+		refs = append(refs, idRef.Ref)
+	}
+	overridden, err := r.periodLockCheck(ctx, ids, []string{action.InvoiceID}, refs, action.Override)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to overwrite changes made in Xero or Salesforce since the last refresh.
+	conflicts, err := r.linkActionConflicts(ctx, sfClient, xeroClient, action, donations, invoice)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return ErrConflict{Conflicts: conflicts}
+	}
+
+	payload, err := json.Marshal(action)
+	if err != nil {
+		return ErrSystem{
+			Detail: "LinkAction encoding error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the link action",
+		}
+	}
+
+	// The action is recorded with the overrides of any period locks.
+	var id int64
+	err = r.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.periodLockOverride(ctx, overridden, action.Description(), action.Override); err != nil {
+			return err
+		}
+		id, err = r.db.PendingActionCreate(ctx, action.Action, action.Description(), string(payload), linkStepSalesforce)
+		if err != nil {
+			return ErrSystem{
+				Detail: "db.PendingActionCreate error",
+				Err:    err,
+				Msg:    "A problem was encountered recording the link action",
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	steps := r.linkActionSteps(id, action, sfClient, xeroClient, dataStartDate, lastRefreshed)
+	if err := linking.Run(ctx, steps, "", r.linkActionRecorder(id)); err != nil {
+		return r.linkActionFailed(ctx, id, action, err)
+	}
+	return nil
+}
+
+// PendingActionsGet retrieves the pending link actions, most recent first. If all is
+// false only the open actions, those pending or failed, are returned.
+func (r *Reconciler) PendingActionsGet(ctx context.Context, all bool) ([]db.PendingAction, error) {
+	actions, err := r.db.PendingActionsGet(ctx, all)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.PendingActionsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the pending actions",
+		}
+	}
+	return actions, nil
+}
+
+// PendingActionRetry retries an open link action from the step at which it failed or
+// was interrupted. As for LinkActionRun, the Salesforce updates are queued if
+// Salesforce cannot be reached. The period locks are not checked again, as they were
+// checked, or overridden, when the action was started.
+func (r *Reconciler) PendingActionRetry(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	id int64,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) error {
+
+	pending, action, err := r.openLinkAction(ctx, id)
+	if err != nil {
+		return err
+	}
+	steps := r.linkActionSteps(id, action, sfClient, xeroClient, dataStartDate, lastRefreshed)
+	if err := linking.Run(ctx, steps, pending.Step, r.linkActionRecorder(id)); err != nil {
+		return r.linkActionFailed(ctx, id, action, err)
+	}
+	return nil
+}
+
+// PendingActionCompensate reverses an open link action, restoring the previous
+// references in Salesforce and Xero and then refreshing the local records. Any queued
+// Salesforce updates of the action are discarded. The period locks are not checked, so
+// that an action started before its period was locked may still be reversed.
+func (r *Reconciler) PendingActionCompensate(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	id int64,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) error {
+
+	pending, action, err := r.openLinkAction(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := r.db.SalesforceOutboxClear(ctx, id); err != nil {
+		return ErrSystem{
+			Detail: "db.SalesforceOutboxClear error",
+			Err:    err,
+			Msg:    "A problem was encountered removing the queued salesforce updates",
+		}
+	}
+	steps := r.linkActionSteps(id, action, sfClient, xeroClient, dataStartDate, lastRefreshed)
+	if err := linking.Compensate(ctx, steps, pending.Step, r.linkActionRecorder(id)); err != nil {
+		return linkActionError(id, action.Action, err)
+	}
+	return r.linkLocalRefresh(ctx, sfClient, dataStartDate, lastRefreshed)
+}
+
+// openLinkAction retrieves a link action which is pending or failed.
+func (r *Reconciler) openLinkAction(ctx context.Context, id int64) (db.PendingAction, LinkAction, error) {
+
+	var action LinkAction
+	pending, err := r.db.PendingActionGet(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return pending, action, ErrNotFound{
+			Detail: "PendingActionGet error",
+			Msg:    fmt.Sprintf("pending action %d was not found", id),
+		}
+	}
+	if err != nil {
+		return pending, action, ErrSystem{
+			Detail: "db.PendingActionGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the pending action",
+		}
+	}
+	if pending.Status != string(linking.StatusPending) && pending.Status != string(linking.StatusFailed) {
+		return pending, action, ErrUsage{
+			Detail: "PendingActionGet error",
+			Msg:    fmt.Sprintf("pending action %d is %s and cannot be changed", id, pending.Status),
+		}
+	}
+	if err := json.Unmarshal([]byte(pending.Payload), &action); err != nil {
+		return pending, action, ErrSystem{
+			Detail: "LinkAction decoding error",
+			Err:    err,
+			Msg:    fmt.Sprintf("The record of pending action %d could not be read", id),
+		}
+	}
+	return pending, action, nil
+}
+
+// linkActionSteps returns the steps of the link action with id. The xero step is only
+// included if the action updates a Xero invoice. The Salesforce and Xero updates are
+// idempotent, so that a retried step does not update the remote records twice.
+func (r *Reconciler) linkActionSteps(
+	id int64,
+	action LinkAction,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) []linking.Step {
+
+	steps := []linking.Step{{
+		Name: linkStepSalesforce,
+		Run: func(ctx context.Context) error {
+			return r.opportunityRefsMutation(ctx, sfClient, id, mutationRun, action.IDRefs)
+		},
+		Compensate: func(ctx context.Context) error {
+			return r.opportunityRefsMutation(ctx, sfClient, id, mutationCompensate, action.PreviousRefs)
+		},
+	}}
+
+	if action.InvoiceID != "" {
+		xeroConnected := func() error {
+			if xeroClient == nil {
+				return ErrUsage{
+					Detail: "LinkAction xero step error",
+					Msg:    "Xero must be connected to update the invoice reference",
+				}
+			}
+			return nil
+		}
+		steps = append(steps, linking.Step{
+			Name: linkStepXero,
+			Run: func(ctx context.Context) error {
+				if err := xeroConnected(); err != nil {
+					return err
+				}
+				return r.invoiceRefMutation(ctx, xeroClient, id, mutationRun, action.InvoiceID, action.Reference)
+			},
+			Compensate: func(ctx context.Context) error {
+				if err := xeroConnected(); err != nil {
+					return err
+				}
+				return r.invoiceRefMutation(ctx, xeroClient, id, mutationCompensate, action.InvoiceID, action.PreviousReference)
+			},
+		})
+	}
+
+	return append(steps, linking.Step{
+		Name: linkStepLocal,
+		Run: func(ctx context.Context) error {
+			return r.linkLocalRefresh(ctx, sfClient, dataStartDate, lastRefreshed)
+		},
+	})
+}
+
+// linkActionRecorder records the progress of the link action with id. Any queued
+// Salesforce updates of the action are removed with the progress once the salesforce
+// step has completed or the action is closed.
+func (r *Reconciler) linkActionRecorder(id int64) linking.Recorder {
+	return func(ctx context.Context, step string, status linking.Status, err error) error {
+		var lastError string
+		if err != nil {
+			lastError = err.Error()
+		}
+		return r.db.WithTx(ctx, func(ctx context.Context) error {
+			if err := r.db.PendingActionUpdate(ctx, id, step, string(status), lastError); err != nil {
+				return err
+			}
+			if step != linkStepSalesforce || status == linking.StatusDone || status == linking.StatusCompensated {
+				return r.db.SalesforceOutboxClear(ctx, id)
+			}
+			return nil
+		})
+	}
+}
+
+// linkActionFailed reports the failure of the link action with id. If the salesforce
+// step failed as Salesforce could not be reached, its updates are queued to be retried
+// and ErrQueued returned.
+func (r *Reconciler) linkActionFailed(ctx context.Context, id int64, action LinkAction, err error) error {
+	stepErr, ok := errors.AsType[linking.StepError](err)
+	if !ok || stepErr.Step != linkStepSalesforce || !apistatus.Unavailable(err) {
+		return linkActionError(id, action.Action, err)
+	}
+	if qErr := r.db.SalesforceOutboxQueue(ctx, id, action.IDRefs); qErr != nil {
+		return ErrSystem{
+			Detail: "db.SalesforceOutboxQueue error",
+			Err:    errors.Join(err, qErr),
+			Msg:    fmt.Sprintf("Salesforce could not be reached and the %s action, recorded as pending action %d, could not be queued", action.Action, id),
+		}
+	}
+	r.log.Warn(fmt.Sprintf("pending action %d queued as salesforce is unreachable: %v", id, err))
+	return ErrQueued{
+		ID:  id,
+		Err: err,
+		Msg: fmt.Sprintf(
+			"Salesforce could not be reached; the %s action has been queued as pending action %d and will be sent when Salesforce is available",
+			action.Action, id,
+		),
+	}
+}
+
+// linkActionError reports the failure of a link action, which is left open on the
+// pending actions page.
+func linkActionError(id int64, action string, err error) error {
+	stepErr, ok := errors.AsType[linking.StepError](err)
+	if !ok {
+		return ErrSystem{
+			Detail: fmt.Sprintf("pending action %d error", id),
+			Err:    err,
+			Msg:    "A problem was encountered recording the progress of the link action",
+		}
+	}
+	return ErrSystem{
+		Detail: fmt.Sprintf("pending action %d %s step error", id, stepErr.Step),
+		Err:    err,
+		Msg: fmt.Sprintf(
+			"The %s step of the %s action failed and has been recorded as pending action %d to be retried or reversed",
+			stepErr.Step, action, id,
+		),
+	}
+}
+
+// opportunityRefsUpdate updates the payout references of donations in Salesforce,
+// reporting an error if any donation could not be updated.
+func opportunityRefsUpdate(ctx context.Context, sfClient SalesforceClient, idRefs []salesforce.IDRef) error {
+
+	if len(idRefs) == 0 {
+		return nil
+	}
+
+	// Update the donations. If it is an unlink action, update the dfk with "", else
+	// the actual dfk from the bank transaction or invoice. The form contents (many
+	// salesforce IDs given the same DFK reference) must be translated to
+	// a slice of salesforce.IDRef, hence the use of `salesforce.IDRef`s. The update is
+	// all or none, so that a link of many donations is not partially applied.
+	results, err := sfClient.BatchUpdateOpportunityRefs(ctx, idRefs, true)
+	if err != nil {
+		return ErrSystem{
+			Detail: "BatchUpdateOpportunityRefs error",
+			Err:    err,
+			Msg:    "A problem was encountered batch updating salesforce references",
+		}
+	}
+
+	// The results are in the order of the records updated.
+	var failures []string
+	for i, result := range results {
+		if result.Success {
+			continue
+		}
+		id := result.ID
+		if id == "" && i < len(idRefs) {
+			id = idRefs[i].ID
+		}
+		var msgs []string
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		failures = append(failures, fmt.Sprintf("%s (%s)", id, strings.Join(msgs, "; ")))
+	}
+	if len(failures) > 0 {
+		return ErrSystem{
+			Detail: "BatchUpdateOpportunityRefs failures",
+			Err:    fmt.Errorf("records not updated: %s", strings.Join(failures, ", ")),
+			Msg:    fmt.Sprintf("%d of %d salesforce records could not be updated", len(failures), len(idRefs)),
+		}
+	}
+	return nil
+}
+
+// linkLocalRefresh upserts the updated donations and brings the donation links up to
+// date, together.
+func (r *Reconciler) linkLocalRefresh(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) error {
+
+	// The refresh window is rough; double upserts shouldn't be a major issue.
+	r.log.Info(fmt.Sprintf("GetOpportunities %s %s", dataStartDate.Format(time.DateTime), lastRefreshed.Format(time.DateTime)))
+	updatedDonations, err := sfClient.GetOpportunities(ctx, dataStartDate, lastRefreshed)
+	if err != nil {
+		return ErrSystem{
+			Detail: "GetOpportunities error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving updated salesforce records",
+		}
+	}
+	return r.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.db.UpsertDonations(ctx, updatedDonations); err != nil {
+			return ErrSystem{
+				Detail: "UpsertDonations error",
+				Err:    err,
+				Msg:    "A problem was encountered upserting updated salesforce records",
+			}
+		}
+		return r.donationLinksSync(ctx)
+	})
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// mockLinkSalesforceClient records the references sent in each batch update, failing
+// the updates while fail is set.
+type mockLinkSalesforceClient struct {
+	mockSalesforceClient
+	fail    bool
+	updates [][]salesforce.IDRef
+}
+
+func (m *mockLinkSalesforceClient) BatchUpdateOpportunityRefs(ctx context.Context, idRefs []salesforce.IDRef, allOrNone bool) (salesforce.CollectionsUpdateResponse, error) {
+	m.updates = append(m.updates, idRefs)
+	var response salesforce.CollectionsUpdateResponse
+	for _, idRef := range idRefs {
+		result := salesforce.SaveResult{ID: idRef.ID, Success: !m.fail}
+		if m.fail {
+			result.Errors = []salesforce.ErrorDetail{{StatusCode: "UNABLE_TO_LOCK_ROW", Message: "unable to obtain exclusive access"}}
+		}
+		response = append(response, result)
+	}
+	return response, nil
+}
+
+// TestLinkActions tests recording, retrying and compensating link actions.
+func TestLinkActions(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	openActions := func() []string {
+		t.Helper()
+		actions, err := reconciler.PendingActionsGet(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var open []string
+		for _, a := range actions {
+			open = append(open, a.Step+" "+a.Status)
+		}
+		return open
+	}
+
+	// A failed Salesforce update leaves the action failed at the salesforce step.
+	sfClient := &mockLinkSalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}, fail: true}
+	idRefs := []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}}
+	err := reconciler.LinkActionRun(ctx, sfClient, nil, LinkAction{IDRefs: idRefs}, dataStartDate, time.Time{})
+	if _, ok := errors.AsType[ErrSystem](err); !ok {
+		t.Fatalf("expected ErrSystem, got %v", err)
+	}
+	if got, want := openActions(), []string{"salesforce failed"}; !slices.Equal(got, want) {
+		t.Fatalf("open actions got %v want %v", got, want)
+	}
+	actions, err := reconciler.PendingActionsGet(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failedID := actions[0].ID
+
+	// The failed action may be retried.
+	sfClient.fail = false
+	if err := reconciler.PendingActionRetry(ctx, sfClient, nil, failedID, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := openActions(); len(got) != 0 {
+		t.Errorf("expected no open actions after retry, got %v", got)
+	}
+	if err := reconciler.PendingActionRetry(ctx, sfClient, nil, failedID, dataStartDate, time.Time{}); err == nil {
+		t.Error("expected a done action not to be retried")
+	}
+
+	// An action updating the Xero invoice fails at the xero step without a Xero client,
+	// and is compensated by restoring the previous Salesforce references.
+	sfClient.updates = nil
+	action := LinkAction{IDRefs: idRefs, InvoiceID: "inv-002", Reference: "INV-2025-102"}
+	err = reconciler.LinkActionRun(ctx, sfClient, nil, action, dataStartDate, time.Time{})
+	if e, ok := errors.AsType[ErrSystem](err); !ok || e.Detail == "" {
+		t.Fatalf("expected ErrSystem, got %v", err)
+	}
+	if got, want := openActions(), []string{"xero failed"}; !slices.Equal(got, want) {
+		t.Fatalf("open actions got %v want %v", got, want)
+	}
+	actions, err = reconciler.PendingActionsGet(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroID := actions[0].ID
+
+	// Compensation of the xero step also needs a Xero client.
+	if err := reconciler.PendingActionCompensate(ctx, sfClient, nil, xeroID, dataStartDate, time.Time{}); err == nil {
+		t.Fatal("expected compensation without a Xero client to fail")
+	}
+	xeroClient := &mockXeroClient{log: logger}
+	if err := reconciler.PendingActionCompensate(ctx, sfClient, xeroClient, xeroID, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := openActions(); len(got) != 0 {
+		t.Errorf("expected no open actions after compensation, got %v", got)
+	}
+	if got, want := xeroClient.getCount, 1; got != want {
+		t.Errorf("got %d xero updates want %d", got, want)
+	}
+	// The link was sent, and then the previous reference restored.
+	if got, want := len(sfClient.updates), 2; got != want {
+		t.Fatalf("got %d salesforce updates want %d", got, want)
+	}
+	if got, want := sfClient.updates[1], []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-101"}}; !slices.Equal(got, want) {
+		t.Errorf("compensation got refs %v want %v", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package domain
+
+// linkmappings.go exports the donation links as a json document of link mappings, and
+// imports such a document into a fresh database, so that the local database can be
+// rebuilt from scratch, such as after a schema reset, without losing the
+// reconciliation work recorded in the links.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// LinkMappingsVersion is the version of the link mappings document format.
+const LinkMappingsVersion = 1
+
+// LinkMappings is the json document of the exported donation links.
+type LinkMappings struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Links      []db.LinkMapping `json:"links"`
+}
+
+// LinkMappingsExport writes all the donation links to w as a json LinkMappings
+// document.
+func (r *Reconciler) LinkMappingsExport(ctx context.Context, w io.Writer) error {
+	links, err := r.db.LinkMappingsGet(ctx)
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.LinkMappingsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the donation links",
+		}
+	}
+	doc := LinkMappings{
+		Version:    LinkMappingsVersion,
+		ExportedAt: time.Now().UTC().Truncate(time.Second),
+		Links:      links,
+	}
+	if doc.Links == nil {
+		doc.Links = []db.LinkMapping{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return ErrSystem{
+			Detail: "LinkMappings encoding error",
+			Err:    err,
+			Msg:    "A problem was encountered writing the donation links",
+		}
+	}
+	return nil
+}
+
+// LinkMappingsImport reads a json LinkMappings document from rd and records its links,
+// replacing any existing link of each donation to the same record. The number of links
+// imported and the number in the document are returned; links of donations, invoices
+// or bank transactions not in the database are not imported. A usage error is returned
+// if the document cannot be read or has an unknown version.
+func (r *Reconciler) LinkMappingsImport(ctx context.Context, rd io.Reader) (imported, total int, err error) {
+
+	var doc LinkMappings
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return 0, 0, ErrUsage{
+			Detail: fmt.Sprintf("LinkMappings decoding error: %v", err),
+			Msg:    "The file is not a valid link mappings document",
+		}
+	}
+	if doc.Version != LinkMappingsVersion {
+		return 0, 0, ErrUsage{
+			Detail: fmt.Sprintf("LinkMappings version %d", doc.Version),
+			Msg:    fmt.Sprintf("The link mappings document version %d is not supported, expected version %d", doc.Version, LinkMappingsVersion),
+		}
+	}
+
+	imported, err = r.db.LinkMappingsImport(ctx, doc.Links)
+	if e, ok := errors.AsType[db.ErrValidation](err); ok {
+		return 0, len(doc.Links), ErrUsage{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The %s %s", e.Field, e.Msg),
+		}
+	}
+	if err != nil {
+		return 0, len(doc.Links), ErrSystem{
+			Detail: "db.LinkMappingsImport error",
+			Err:    err,
+			Msg:    "A problem was encountered importing the donation links",
+		}
+	}
+	return imported, len(doc.Links), nil
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestLinkMappings tests that the donation links exported from one database are
+// restored by importing them into a fresh database.
+func TestLinkMappings(t *testing.T) {
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+	reconciler := NewReconciler(testDB, logger)
+	if err := testDB.DonationSplitUpsert(ctx, "sf-opp-018", "invoice", "inv-unrec-04", money.FromFloat(10)); err != nil {
+		t.Fatal(err)
+	}
+	var exported bytes.Buffer
+	if err := reconciler.LinkMappingsExport(ctx, &exported); err != nil {
+		t.Fatal(err)
+	}
+	var want LinkMappings
+	if err := json.Unmarshal(exported.Bytes(), &want); err != nil {
+		t.Fatal(err)
+	}
+	if want.Version != LinkMappingsVersion || len(want.Links) == 0 {
+		t.Fatalf("unexpected export %+v", want)
+	}
+
+	freshDB, closeFreshDB := setupRefreshTestDB(t)
+	t.Cleanup(closeFreshDB)
+	fresh := NewReconciler(freshDB, logger)
+	imported, total, err := fresh.LinkMappingsImport(ctx, &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != len(want.Links) || total != len(want.Links) {
+		t.Errorf("imported %d of %d want %d", imported, total, len(want.Links))
+	}
+	var reexported bytes.Buffer
+	if err := fresh.LinkMappingsExport(ctx, &reexported); err != nil {
+		t.Fatal(err)
+	}
+	var got LinkMappings
+	if err := json.Unmarshal(reexported.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.Links, got.Links); diff != "" {
+		t.Errorf("restored links differ (-want +got):\n%s", diff)
+	}
+
+	invalid := []struct {
+		name string
+		doc  string
+	}{
+		{"not json", "links"},
+		{"unknown field", `{"version":1,"records":[]}`},
+		{"unknown version", `{"version":2,"links":[]}`},
+		{"invalid record type", `{"version":1,"links":[{"donation_id":"sf-opp-001","record_type":"payment","record_id":"inv-001"}]}`},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := fresh.LinkMappingsImport(ctx, strings.NewReader(tt.doc))
+			if !errors.As(err, new(ErrUsage)) {
+				t.Errorf("expected a usage error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package domain
+
+// linkverify.go checks the donation links against the remote systems, re-fetching the
+// linked Salesforce donations and Xero invoices and bank transactions to report where
+// the remote references no longer match the links, such as after edits made directly
+// in Salesforce or Xero which a refresh has not yet picked up.
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/rorycl/reconciler/internal/apistatus"
+)
+
+// LinkMismatch is a donation link which no longer matches a remote record. Source is
+// "salesforce" if the donation differs and "xero" if the linked invoice or bank
+// transaction differs. Remote is the remote payout reference of the donation, or the
+// invoice number or bank transaction reference of the record, and Missing is set if the
+// remote record was not found.
+type LinkMismatch struct {
+	DonationID   string
+	DonationName string
+	RecordType   string
+	RecordID     string
+	Reference    string // the payout reference of the link, empty for a split
+	Source       string
+	Remote       string
+	Missing      bool
+}
+
+// LinkVerifyResults reports the number of links, donations and records checked, the
+// number of records which could not be checked as the Xero client cannot retrieve
+// single records, and the mismatched links.
+type LinkVerifyResults struct {
+	LinksNo     int
+	DonationsNo int
+	RecordsNo   int
+	UncheckedNo int
+	Mismatches  []LinkMismatch
+}
+
+// LinksVerify re-fetches the Salesforce donations and Xero invoices and bank
+// transactions of the donation links, or of a random sample of sample links if sample
+// is positive, and reports the links whose remote references no longer match. A link
+// matches if its donation has the payout reference of the link and its record the
+// invoice number or reference of the link. Splits, which have no payout reference,
+// only require the remote records to exist.
+func (r *Reconciler) LinksVerify(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	sample int,
+) (*LinkVerifyResults, error) {
+
+	results := &LinkVerifyResults{}
+	if sample <= 0 {
+		sample = -1
+	}
+	links, err := r.db.LinkChecksGet(ctx, sample)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "db.LinkChecksGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the donation links",
+		}
+	}
+	results.LinksNo = len(links)
+	if len(links) == 0 {
+		return results, nil
+	}
+
+	// Retrieve the donations together, and the records one at a time.
+	seen := map[string]bool{}
+	var ids []string
+	for _, l := range links {
+		if !seen[l.DonationID] {
+			seen[l.DonationID] = true
+			ids = append(ids, l.DonationID)
+		}
+	}
+	donations, err := sfClient.GetOpportunitiesByID(ctx, ids)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "salesforce GetOpportunitiesByID error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Salesforce records",
+		}
+	}
+	results.DonationsNo = len(ids)
+	donationRefs := map[string]*string{}
+	for _, d := range donations {
+		ref := ""
+		if d.PayoutReference != nil {
+			ref = *d.PayoutReference
+		}
+		donationRefs[d.ID] = &ref
+	}
+
+	// The records which could be checked have an entry in recordRefs, which is nil if
+	// the record was not found.
+	recordRefs := map[string]*string{}
+	seen = map[string]bool{}
+	for _, l := range links {
+		key := l.RecordType + " " + l.RecordID
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ref, ok, err := r.remoteRecordRef(ctx, xeroClient, l.RecordType, l.RecordID)
+		if err != nil {
+			return results, err
+		}
+		if !ok {
+			results.UncheckedNo++
+			continue
+		}
+		results.RecordsNo++
+		recordRefs[key] = ref
+	}
+
+	for _, l := range links {
+		mismatch := func(source string, remote *string) {
+			m := LinkMismatch{
+				DonationID:   l.DonationID,
+				DonationName: l.DonationName,
+				RecordType:   l.RecordType,
+				RecordID:     l.RecordID,
+				Reference:    l.Reference,
+				Source:       source,
+				Missing:      remote == nil,
+			}
+			if remote != nil {
+				m.Remote = *remote
+			}
+			results.Mismatches = append(results.Mismatches, m)
+		}
+		remote := donationRefs[l.DonationID]
+		if remote == nil || (l.Reference != "" && *remote != l.Reference) {
+			mismatch("salesforce", remote)
+		}
+		remote, ok := recordRefs[l.RecordType+" "+l.RecordID]
+		if !ok {
+			continue
+		}
+		if remote == nil || (l.Reference != "" && *remote != l.Reference) {
+			mismatch("xero", remote)
+		}
+	}
+	r.log.Info("verified donation links", "links", results.LinksNo, "mismatches", len(results.Mismatches))
+	return results, nil
+}
+
+// remoteRecordRef retrieves the invoice number of the Xero invoice, or the reference of
+// the bank transaction, with id. A nil reference is returned if the record is not
+// found, and ok is false if the xeroClient cannot retrieve single records of the type.
+func (r *Reconciler) remoteRecordRef(ctx context.Context, xeroClient XeroClient, recordType, id string) (ref *string, ok bool, err error) {
+
+	notFound := func(err error) bool {
+		e, ok := errors.AsType[apistatus.ErrRemoteAPI](err)
+		return ok && e.StatusCode == http.StatusNotFound
+	}
+
+	switch recordType {
+	case "invoice":
+		getter, ok := xeroClient.(XeroInvoiceGetter)
+		if !ok {
+			return nil, false, nil
+		}
+		invoice, err := getter.GetInvoiceByID(ctx, id)
+		if notFound(err) {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, true, ErrSystem{
+				Detail: "xero GetInvoiceByID error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the Xero invoice",
+			}
+		}
+		return &invoice.InvoiceNumber, true, nil
+	case "bank-transaction":
+		getter, ok := xeroClient.(XeroBankTransactionGetter)
+		if !ok {
+			return nil, false, nil
+		}
+		transaction, err := getter.GetBankTransactionByID(ctx, id)
+		if notFound(err) {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, true, ErrSystem{
+				Detail: "xero GetBankTransactionByID error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the Xero bank transaction",
+			}
+		}
+		return &transaction.Reference, true, nil
+	}
+	return nil, false, nil
+}
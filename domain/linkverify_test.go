@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/apistatus"
+)
+
+// mockVerifySalesforceClient finds the donations with refs, with those payout
+// references.
+type mockVerifySalesforceClient struct {
+	mockSalesforceClient
+	refs map[string]string
+}
+
+func (m *mockVerifySalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	var donations []salesforce.Donation
+	for _, id := range ids {
+		if ref, ok := m.refs[id]; ok {
+			donations = append(donations, salesforce.Donation{CoreFields: salesforce.CoreFields{ID: id, PayoutReference: &ref}})
+		}
+	}
+	return donations, nil
+}
+
+// mockVerifyXeroClient finds the invoices and bank transactions with refs, with those
+// invoice numbers or references.
+type mockVerifyXeroClient struct {
+	mockXeroClient
+	refs map[string]string
+}
+
+func (m *mockVerifyXeroClient) GetInvoiceByID(ctx context.Context, id string) (xero.Invoice, error) {
+	ref, ok := m.refs[id]
+	if !ok {
+		return xero.Invoice{}, apistatus.ErrRemoteAPI{API: "xero", StatusCode: http.StatusNotFound}
+	}
+	return xero.Invoice{InvoiceID: id, InvoiceNumber: ref}, nil
+}
+
+func (m *mockVerifyXeroClient) GetBankTransactionByID(ctx context.Context, id string) (xero.BankTransaction, error) {
+	ref, ok := m.refs[id]
+	if !ok {
+		return xero.BankTransaction{}, apistatus.ErrRemoteAPI{API: "xero", StatusCode: http.StatusNotFound}
+	}
+	return xero.BankTransaction{BankTransactionID: id, Reference: ref}, nil
+}
+
+// TestLinksVerify tests reporting the donation links no longer matching the remote
+// records.
+func TestLinksVerify(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	if _, _, err := testDB.DonationLinksSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The remote records match the local records, apart from a changed payout
+	// reference, a deleted donation and a deleted bank transaction.
+	sfClient := &mockVerifySalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}, refs: map[string]string{}}
+	xeroClient := &mockVerifyXeroClient{mockXeroClient: mockXeroClient{log: logger}, refs: map[string]string{}}
+	for query, refs := range map[string]map[string]string{
+		"SELECT id, COALESCE(payout_reference_dfk, '') FROM donations":                                                              sfClient.refs,
+		"SELECT id, COALESCE(invoice_number, '') FROM invoices UNION ALL SELECT id, COALESCE(reference, '') FROM bank_transactions": xeroClient.refs,
+	} {
+		rows, err := testDB.QueryContext(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var id, ref string
+			if err := rows.Scan(&id, &ref); err != nil {
+				t.Fatal(err)
+			}
+			refs[id] = ref
+		}
+		_ = rows.Close()
+	}
+
+	results, err := reconciler.LinksVerify(ctx, sfClient, xeroClient, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.LinksNo == 0 || results.RecordsNo == 0 || results.UncheckedNo != 0 || len(results.Mismatches) != 0 {
+		t.Fatalf("unexpected results for matching records %+v", results)
+	}
+
+	sfClient.refs["sf-opp-001"] = "INV-CHANGED"
+	delete(sfClient.refs, "sf-opp-002")
+	delete(xeroClient.refs, "bt-001")
+
+	links, err := testDB.LinkChecksGet(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{}
+	for _, l := range links {
+		switch {
+		case l.DonationID == "sf-opp-001" && l.Reference != "":
+			want["salesforce changed"]++
+		case l.DonationID == "sf-opp-002":
+			want["salesforce missing"]++
+		}
+		if l.RecordID == "bt-001" {
+			want["xero missing"]++
+		}
+	}
+	if len(want) != 3 {
+		t.Fatalf("test data lacks the expected links: %v", want)
+	}
+
+	results, err = reconciler.LinksVerify(ctx, sfClient, xeroClient, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, m := range results.Mismatches {
+		switch {
+		case m.Source == "salesforce" && m.Missing:
+			got["salesforce missing"]++
+		case m.Source == "salesforce" && m.Remote == "INV-CHANGED":
+			got["salesforce changed"]++
+		case m.Source == "xero" && m.Missing && m.RecordID == "bt-001":
+			got["xero missing"]++
+		default:
+			t.Errorf("unexpected mismatch %+v", m)
+		}
+	}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("%s got %d mismatches want %d", k, got[k], n)
+		}
+	}
+
+	// A sample checks only some links, and records are unchecked without the
+	// capability to retrieve single records.
+	results, err = reconciler.LinksVerify(ctx, sfClient, &mockXeroClient{log: logger}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.LinksNo != 1 || results.RecordsNo != 0 || results.UncheckedNo != 1 {
+		t.Errorf("unexpected sample results %+v", results)
+	}
+}
@@ -0,0 +1,169 @@
+package domain
+
+// locks.go closes periods, such as a financial year whose accounts have been
+// finalised, so that the links, unlinks and write-backs of records dated in them are
+// refused unless a reason is given to override the lock for a correction. Locking,
+// unlocking and overriding are recorded as audit events.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// auditEventsShown is the number of audit events shown with the period locks.
+const auditEventsShown = 50
+
+// PeriodLocksGet retrieves the period locks, latest period first.
+func (r *Reconciler) PeriodLocksGet(ctx context.Context) ([]db.PeriodLock, error) {
+	locks, err := r.db.PeriodLocksGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.PeriodLocksGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the period locks",
+		}
+	}
+	return locks, nil
+}
+
+// AuditEventsGet retrieves the latest audit events of the period locks.
+func (r *Reconciler) AuditEventsGet(ctx context.Context) ([]db.AuditEvent, error) {
+	events, err := r.db.AuditEventsGet(ctx, auditEventsShown)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.AuditEventsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the audit events",
+		}
+	}
+	return events, nil
+}
+
+// PeriodLocksEnabled reports if periods may be locked. The locks and their audit events
+// are kept in the database, so periods may not be locked in an in-memory database,
+// which is lost when the app closes or the profile is changed, other than the test
+// database.
+func (r *Reconciler) PeriodLocksEnabled() bool {
+	return !r.DBIsInMemory() || r.db.TestData()
+}
+
+// PeriodLockAdd closes the period from dateFrom to dateTo inclusive, returning a usage
+// error if the dates are invalid or periods may not be locked. The lock is recorded as
+// an audit event, and the reconciliation state of the period recorded as a period
+// snapshot, with the lock.
+func (r *Reconciler) PeriodLockAdd(ctx context.Context, dateFrom, dateTo time.Time, reason string) error {
+	if !r.PeriodLocksEnabled() {
+		return ErrUsage{
+			Detail: fmt.Sprintf("period locks disabled for in-memory database %s", r.db.Path),
+			Msg:    "Periods cannot be locked while the data is held in memory, as the locks would be lost when the app closes",
+		}
+	}
+	return r.WithTx(ctx, func(ctx context.Context) error {
+		id, err := r.db.PeriodLockInsert(ctx, dateFrom, dateTo, reason)
+		if e, ok := errors.AsType[db.ErrValidation](err); ok {
+			return ErrUsage{
+				Detail: err.Error(),
+				Msg:    fmt.Sprintf("The %s %s", e.Field, e.Msg),
+			}
+		}
+		if err != nil {
+			return ErrSystem{
+				Detail: "db.PeriodLockInsert error",
+				Err:    err,
+				Msg:    "A problem was encountered locking the period",
+			}
+		}
+		lock := db.PeriodLock{DateFrom: dateFrom, DateTo: dateTo}
+		if err := r.auditEventAdd(ctx, db.AuditLock, id, "locked "+lock.String(), reason); err != nil {
+			return err
+		}
+		return r.periodSnapshotRecord(ctx, id, dateFrom, dateTo)
+	})
+}
+
+// PeriodLockRemove opens the period of a period lock again, recording the unlock and
+// its reason as an audit event with the unlock.
+func (r *Reconciler) PeriodLockRemove(ctx context.Context, id int64, reason string) error {
+	return r.WithTx(ctx, func(ctx context.Context) error {
+		lock, err := r.db.PeriodLockGet(ctx, id)
+		if _, ok := errors.AsType[db.ErrNotFound](err); ok {
+			return ErrNotFound{
+				Detail: err.Error(),
+				Msg:    "The period lock was not found",
+			}
+		}
+		if err != nil {
+			return ErrSystem{
+				Detail: "db.PeriodLockGet error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the period lock",
+			}
+		}
+		if err := r.db.PeriodLockDelete(ctx, id); err != nil {
+			return ErrSystem{
+				Detail: "db.PeriodLockDelete error",
+				Err:    err,
+				Msg:    "A problem was encountered unlocking the period",
+			}
+		}
+		return r.auditEventAdd(ctx, db.AuditUnlock, id, "unlocked "+lock.String(), reason)
+	})
+}
+
+// auditEventAdd records an audit event of the period lock with id.
+func (r *Reconciler) auditEventAdd(ctx context.Context, event string, id int64, description, reason string) error {
+	if err := r.db.AuditEventInsert(ctx, event, id, description, reason); err != nil {
+		return ErrSystem{
+			Detail: "db.AuditEventInsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the audit event",
+		}
+	}
+	return nil
+}
+
+// periodLockCheck returns ErrLocked if any of the donations with donationIDs, the
+// invoices or bank transactions with recordIDs, or those with an invoice number or
+// reference in refs are dated in a locked period. If an override reason is given the
+// change is allowed and the locks it overrides are returned, to be recorded by
+// periodLockOverride with the change.
+func (r *Reconciler) periodLockCheck(ctx context.Context, donationIDs, recordIDs, refs []string, override string) ([]db.PeriodLock, error) {
+	locks, err := r.db.PeriodLocksCovering(ctx, donationIDs, recordIDs, refs)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.PeriodLocksCovering error",
+			Err:    err,
+			Msg:    "A problem was encountered checking the period locks",
+		}
+	}
+	if len(locks) == 0 {
+		return nil, nil
+	}
+	if strings.TrimSpace(override) == "" {
+		periods := make([]string, len(locks))
+		for i, l := range locks {
+			periods[i] = l.String()
+		}
+		return nil, ErrLocked{
+			Locks: locks,
+			Msg:   fmt.Sprintf("The records are in a locked period (%s) and may only be changed by overriding the lock with a reason", strings.Join(periods, ", ")),
+		}
+	}
+	return locks, nil
+}
+
+// periodLockOverride records the change described by description as an override of
+// each of locks, with the override reason.
+func (r *Reconciler) periodLockOverride(ctx context.Context, locks []db.PeriodLock, description, override string) error {
+	for _, l := range locks {
+		if err := r.auditEventAdd(ctx, db.AuditOverride, l.ID, description, override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+)
+
+// TestPeriodLocks tests that links and splits of records in locked periods are refused
+// unless overridden, and that the locks and overrides are audited.
+func TestPeriodLocks(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	april := func(day int) time.Time {
+		return time.Date(2025, 4, day, 0, 0, 0, 0, time.UTC)
+	}
+	if err := reconciler.PeriodLockAdd(ctx, april(30), april(1), "year end"); !errors.As(err, new(ErrUsage)) {
+		t.Fatalf("expected a usage error for reversed dates, got %v", err)
+	}
+	if err := reconciler.PeriodLockAdd(ctx, april(1), april(30), "year end"); err != nil {
+		t.Fatal(err)
+	}
+	locks, err := reconciler.PeriodLocksGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 {
+		t.Fatalf("got %d locks want 1", len(locks))
+	}
+
+	// sf-opp-001 closed on 2025-04-08, so may only be linked with an override.
+	sfClient := &mockLinkSalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}}
+	action := LinkAction{IDRefs: []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}}}
+	err = reconciler.LinkActionRun(ctx, sfClient, nil, action, dataStartDate, time.Time{})
+	if e, ok := errors.AsType[ErrLocked](err); !ok || len(e.Locks) != 1 {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(sfClient.updates) != 0 {
+		t.Fatalf("expected no updates of a locked donation, got %v", sfClient.updates)
+	}
+	action.Override = "correction agreed with the auditors"
+	if err := reconciler.LinkActionRun(ctx, sfClient, nil, action, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sfClient.updates) != 1 {
+		t.Fatalf("expected the overridden link to be made, got %v", sfClient.updates)
+	}
+
+	// inv-unrec-04 is dated 2025-04-25.
+	err = reconciler.DonationSplitUpsert(ctx, "invoice", "inv-unrec-04", "sf-opp-018", money.FromFloat(20))
+	if _, ok := errors.AsType[ErrLocked](err); !ok {
+		t.Fatalf("expected ErrLocked for a split, got %v", err)
+	}
+
+	if err := reconciler.PeriodLockRemove(ctx, locks[0].ID, "accounts reopened"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.PeriodLockRemove(ctx, locks[0].ID, ""); !errors.As(err, new(ErrNotFound)) {
+		t.Errorf("expected not found removing a removed lock, got %v", err)
+	}
+	if err := reconciler.DonationSplitUpsert(ctx, "invoice", "inv-unrec-04", "sf-opp-018", money.FromFloat(20)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := reconciler.AuditEventsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Event+" "+e.Reason)
+	}
+	want := []string{
+		db.AuditUnlock + " accounts reopened",
+		db.AuditOverride + " correction agreed with the auditors",
+		db.AuditLock + " year end",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("audit events got %v want %v", got, want)
+	}
+}
+
+// TestPeriodLockRollback tests that a period lock added in a transaction which fails
+// is rolled back with its audit event and period snapshot.
+func TestPeriodLockRollback(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	reconciler := NewReconciler(testDB, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	errFail := errors.New("fail")
+	err := reconciler.WithTx(ctx, func(ctx context.Context) error {
+		from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+		if err := reconciler.PeriodLockAdd(ctx, from, to, "year end"); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("got error %v want %v", err, errFail)
+	}
+
+	locks, err := reconciler.PeriodLocksGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := reconciler.AuditEventsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 || len(events) != 0 {
+		t.Errorf("got %d locks and %d audit events after rollback want none", len(locks), len(events))
+	}
+}
+
+// TestPeriodLocksInMemory tests that periods may not be locked in an in-memory
+// database other than the test database, as the locks would be lost when it closes.
+func TestPeriodLocksInMemory(t *testing.T) {
+
+	sqlFS, err := mounts.NewFileMount("sql", db.SQLEmbeddedFS, "../db/sql")
+	if err != nil {
+		t.Fatalf("mount error: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	memDB, err := db.NewConnection(db.MemoryPath("locks"), sqlFS, "^(53|55|57)", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = memDB.Close() })
+
+	ctx := t.Context()
+	reconciler := NewReconciler(memDB, logger)
+	if reconciler.PeriodLocksEnabled() {
+		t.Error("expected period locks to be disabled for an in-memory database")
+	}
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	if err := reconciler.PeriodLockAdd(ctx, from, to, "year end"); !errors.As(err, new(ErrUsage)) {
+		t.Fatalf("expected a usage error locking a period in memory, got %v", err)
+	}
+	locks, err := reconciler.PeriodLocksGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("got %d locks want none", len(locks))
+	}
+}
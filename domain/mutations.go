@@ -0,0 +1,170 @@
+package domain
+
+// mutations.go makes the Salesforce and Xero updates of link actions idempotent. Each
+// update is recorded by a key scoped to the pending action, its step and whether it is
+// run or compensated, so that a retried step does not update the remote records again
+// if the update already succeeded, or was made before the action was interrupted.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/idempotency"
+)
+
+// The phases of a link action step.
+const (
+	mutationRun        = "run"
+	mutationCompensate = "compensate"
+)
+
+// mutationStore is the idempotency.Store of the outbound mutations in the database.
+type mutationStore struct {
+	db *db.DB
+}
+
+// Get retrieves the record of the mutation with key.
+func (s mutationStore) Get(ctx context.Context, key string) (idempotency.Record, error) {
+	m, err := s.db.OutboundMutationGet(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return idempotency.Record{}, idempotency.ErrNotRecorded
+	}
+	if err != nil {
+		return idempotency.Record{}, err
+	}
+	return idempotency.Record{
+		Key:       m.Key,
+		Target:    m.Target,
+		Operation: m.Operation,
+		Status:    idempotency.Status(m.Status),
+		Result:    m.Result,
+	}, nil
+}
+
+// Save records the status of a mutation.
+func (s mutationStore) Save(ctx context.Context, r idempotency.Record) error {
+	return s.db.OutboundMutationSave(ctx, db.OutboundMutation{
+		Key:       r.Key,
+		Target:    r.Target,
+		Operation: r.Operation,
+		Status:    string(r.Status),
+		Result:    r.Result,
+	})
+}
+
+// runMutation runs the mutation, reporting errors recording the mutation as system
+// errors. Errors of the mutation itself are returned as they are.
+func (r *Reconciler) runMutation(ctx context.Context, m idempotency.Mutation) error {
+	_, sent, err := idempotency.Run(ctx, mutationStore{r.db}, m)
+	if err != nil && !sent {
+		return ErrSystem{
+			Detail: "outbound mutation error",
+			Err:    err,
+			Msg:    "A problem was encountered checking or recording the update",
+		}
+	}
+	if err == nil && !sent {
+		r.log.Info("outbound mutation already made", "target", m.Target, "operation", m.Operation)
+	}
+	return err
+}
+
+// mutationResult returns the json encoding of v as the result of a mutation.
+func mutationResult(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// opportunityRefsMutation updates the payout references of donations in Salesforce for
+// the phase of the salesforce step of the pending action with id, unless the update has
+// already been made.
+func (r *Reconciler) opportunityRefsMutation(ctx context.Context, sfClient SalesforceClient, id int64, phase string, idRefs []salesforce.IDRef) error {
+
+	if len(idRefs) == 0 {
+		return nil
+	}
+	key, err := idempotency.Key(id, linkStepSalesforce, phase, idRefs)
+	if err != nil {
+		return ErrSystem{Detail: "outbound mutation key error", Err: err, Msg: "A problem was encountered recording the update"}
+	}
+	result := mutationResult(map[string]int{"Records": len(idRefs)})
+
+	return r.runMutation(ctx, idempotency.Mutation{
+		Key:       key,
+		Target:    linkStepSalesforce,
+		Operation: "update donation references",
+		Do: func(ctx context.Context) (string, error) {
+			return result, opportunityRefsUpdate(ctx, sfClient, idRefs)
+		},
+		Applied: func(ctx context.Context) (bool, string, error) {
+			ids := make([]string, len(idRefs))
+			for i, idRef := range idRefs {
+				ids[i] = idRef.ID
+			}
+			donations, err := sfClient.GetOpportunitiesByID(ctx, ids)
+			if err != nil {
+				return false, "", err
+			}
+			refs := map[string]string{}
+			for _, d := range donations {
+				if d.PayoutReference != nil {
+					refs[d.ID] = *d.PayoutReference
+				} else {
+					refs[d.ID] = ""
+				}
+			}
+			for _, idRef := range idRefs {
+				if ref, ok := refs[idRef.ID]; !ok || ref != idRef.Ref {
+					return false, "", nil
+				}
+			}
+			return true, result, nil
+		},
+	})
+}
+
+// invoiceRefMutation writes reference to the Xero invoice with invoiceID for the phase
+// of the xero step of the pending action with id, unless the update has already been
+// made. The updated invoice is upserted.
+func (r *Reconciler) invoiceRefMutation(ctx context.Context, xeroClient XeroClient, id int64, phase, invoiceID, reference string) error {
+
+	key, err := idempotency.Key(id, linkStepXero, phase, invoiceID, reference)
+	if err != nil {
+		return ErrSystem{Detail: "outbound mutation key error", Err: err, Msg: "A problem was encountered recording the update"}
+	}
+	result := mutationResult(map[string]string{"InvoiceID": invoiceID, "Reference": reference})
+
+	m := idempotency.Mutation{
+		Key:       key,
+		Target:    linkStepXero,
+		Operation: "update invoice reference",
+		Do: func(ctx context.Context) (string, error) {
+			if phase == mutationRun {
+				return result, r.InvoiceReferenceUpdate(ctx, xeroClient, invoiceID, reference)
+			}
+			invoice, err := xeroClient.UpdateInvoiceReference(ctx, invoiceID, reference)
+			if err != nil {
+				return "", err
+			}
+			return result, r.db.InvoicesUpsert(ctx, []xero.Invoice{invoice})
+		},
+	}
+	if getter, ok := xeroClient.(XeroInvoiceGetter); ok {
+		m.Applied = func(ctx context.Context) (bool, string, error) {
+			invoice, err := getter.GetInvoiceByID(ctx, invoiceID)
+			if err != nil {
+				return false, "", err
+			}
+			if invoice.Reference != reference {
+				return false, "", nil
+			}
+			return true, result, r.db.InvoicesUpsert(ctx, []xero.Invoice{invoice})
+		}
+	}
+	return r.runMutation(ctx, m)
+}
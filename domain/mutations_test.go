@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/idempotency"
+)
+
+// TestLinkActionMutations tests that a retried link action step does not update
+// Salesforce again once the update has succeeded.
+func TestLinkActionMutations(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	sfClient := &mockLinkSalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}}
+	idRefs := []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}}
+	if err := reconciler.LinkActionRun(ctx, sfClient, nil, LinkAction{IDRefs: idRefs}, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	actions, err := reconciler.PendingActionsGet(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := actions[0].ID
+
+	// An action interrupted at the salesforce step after the update succeeded is
+	// retried without updating Salesforce again.
+	if err := testDB.PendingActionUpdate(ctx, id, linkStepSalesforce, "pending", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.PendingActionRetry(ctx, sfClient, nil, id, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sfClient.updates), 1; got != want {
+		t.Errorf("got %d salesforce updates want %d", got, want)
+	}
+
+	// An update started but not recorded as succeeded, and not found to have been
+	// made in Salesforce, is sent again.
+	key, err := idempotency.Key(id, linkStepSalesforce, mutationRun, idRefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := db.OutboundMutation{Key: key, Target: linkStepSalesforce, Operation: "update donation references", Status: "started"}
+	if err := testDB.OutboundMutationSave(ctx, started); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.PendingActionUpdate(ctx, id, linkStepSalesforce, "pending", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.PendingActionRetry(ctx, sfClient, nil, id, dataStartDate, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sfClient.updates), 2; got != want {
+		t.Errorf("got %d salesforce updates want %d", got, want)
+	}
+	m, err := testDB.OutboundMutationGet(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Status, "succeeded"; got != want {
+		t.Errorf("mutation status got %s want %s", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// OrphanResults reports the number of local donations checked against Salesforce, and
+// the number found to be deleted or otherwise missing there.
+type OrphanResults struct {
+	CheckedNo int
+	DeletedNo int
+	MissingNo int
+}
+
+// DonationOrphansCheck compares the ids of the local donations closing on or after
+// dataStartDate with Salesforce, flagging those no longer found as orphans and clearing
+// the flags of any found again. Orphans are "deleted" if the Salesforce client reports
+// them in the recycle bin, and otherwise "missing", such as records purged, merged into
+// another record or no longer matched by the Salesforce query.
+func (r *Reconciler) DonationOrphansCheck(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+) (*OrphanResults, error) {
+
+	results := &OrphanResults{}
+
+	ids, err := r.db.DonationIDsGet(ctx, dataStartDate)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "db.DonationIDsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the donation ids",
+		}
+	}
+	results.CheckedNo = len(ids)
+
+	var missing []string
+	if len(ids) > 0 {
+		donations, err := sfClient.GetOpportunitiesByID(ctx, ids)
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "salesforce GetOpportunitiesByID error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the Salesforce records",
+			}
+		}
+		found := map[string]bool{}
+		for _, d := range donations {
+			found[d.ID] = true
+		}
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+	}
+
+	var deleted []string
+	if getter, ok := sfClient.(SalesforceDeletedGetter); ok && len(missing) > 0 {
+		deleted, err = getter.GetDeletedOpportunityIDs(ctx, missing)
+		if err != nil {
+			return results, ErrSystem{
+				Detail: "salesforce GetDeletedOpportunityIDs error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the deleted Salesforce records",
+			}
+		}
+	}
+
+	orphans := map[string]string{}
+	for _, id := range missing {
+		if slices.Contains(deleted, id) {
+			orphans[id] = "deleted"
+			results.DeletedNo++
+		} else {
+			orphans[id] = "missing"
+			results.MissingNo++
+		}
+	}
+	if err := r.db.DonationOrphansRecord(ctx, orphans); err != nil {
+		return results, ErrSystem{
+			Detail: "db.DonationOrphansRecord error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the orphaned donations",
+		}
+	}
+	r.log.Info("checked donations for orphans", "records", results.CheckedNo, "deleted", results.DeletedNo, "missing", results.MissingNo)
+	return results, nil
+}
+
+// DonationOrphansGet retrieves the donations flagged as orphaned.
+func (r *Reconciler) DonationOrphansGet(ctx context.Context) ([]db.DonationOrphan, error) {
+	orphans, err := r.db.DonationOrphansGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.DonationOrphansGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the orphaned donations",
+		}
+	}
+	return orphans, nil
+}
+
+// DonationOrphansRemove removes the local donations with the given ids, which must be
+// flagged as orphaned, returning the number removed. The links made from their payout
+// references are dropped with them.
+func (r *Reconciler) DonationOrphansRemove(ctx context.Context, ids []string) (int, error) {
+
+	var removed int
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		orphans, err := r.DonationOrphansGet(ctx)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !slices.ContainsFunc(orphans, func(o db.DonationOrphan) bool { return o.ID == id }) {
+				return ErrUsage{
+					Detail: fmt.Sprintf("donation %s is not orphaned", id),
+					Msg:    fmt.Sprintf("donation %s is not flagged as orphaned and cannot be removed", id),
+				}
+			}
+		}
+
+		removed, err = r.db.DeleteDonations(ctx, ids)
+		if err != nil {
+			return ErrSystem{
+				Detail: "db.DeleteDonations error",
+				Err:    err,
+				Msg:    "A problem was encountered removing the orphaned donations",
+			}
+		}
+		return r.donationLinksSync(ctx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.log.Info("removed orphaned donations", "records", removed)
+	return removed, nil
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// mockOrphanSalesforceClient finds all donations except those missing, and reports
+// those deleted as being in the recycle bin.
+type mockOrphanSalesforceClient struct {
+	mockSalesforceClient
+	missing []string
+	deleted []string
+}
+
+func (m *mockOrphanSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	var donations []salesforce.Donation
+	for _, id := range ids {
+		if !slices.Contains(m.missing, id) {
+			donations = append(donations, salesforce.Donation{CoreFields: salesforce.CoreFields{ID: id}})
+		}
+	}
+	return donations, nil
+}
+
+func (m *mockOrphanSalesforceClient) GetDeletedOpportunityIDs(ctx context.Context, ids []string) ([]string, error) {
+	var deleted []string
+	for _, id := range ids {
+		if slices.Contains(m.deleted, id) {
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+// TestDonationOrphans tests flagging and removing donations no longer in Salesforce.
+func TestDonationOrphans(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	sfClient := &mockOrphanSalesforceClient{
+		mockSalesforceClient: mockSalesforceClient{log: logger},
+		missing:              []string{"sf-opp-001", "sf-opp-002"},
+		deleted:              []string{"sf-opp-001"},
+	}
+	results, err := reconciler.DonationOrphansCheck(ctx, sfClient, dataStartDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.CheckedNo == 0 || results.DeletedNo != 1 || results.MissingNo != 1 {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	orphans, err := reconciler.DonationOrphansGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(orphans), 2; got != want {
+		t.Fatalf("got %d orphans want %d", got, want)
+	}
+
+	// Only orphans may be removed.
+	_, err = reconciler.DonationOrphansRemove(ctx, []string{"sf-opp-001", "sf-opp-003"})
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Fatalf("expected ErrUsage, got %v", err)
+	}
+	removed, err := reconciler.DonationOrphansRemove(ctx, []string{"sf-opp-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("got %d removed want 1", removed)
+	}
+
+	// A donation found again is no longer an orphan.
+	sfClient.missing = nil
+	if _, err := reconciler.DonationOrphansCheck(ctx, sfClient, dataStartDate); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, err = reconciler.DonationOrphansGet(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("expected no orphans, got %+v", orphans)
+	}
+}
@@ -0,0 +1,153 @@
+package domain
+
+// outbox.go retries the link actions whose Salesforce updates were queued as Salesforce
+// could not be reached when they were made.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/linking"
+)
+
+// outboxRetryLimit is the most queued actions retried at once.
+const outboxRetryLimit = 100
+
+// OutboxResults reports a retry of the queued link actions.
+type OutboxResults struct {
+	Completed int // actions completed
+	Failed    int // actions which failed for reasons other than Salesforce being unreachable
+	Queued    int // actions still queued
+}
+
+// OutboxRetry retries the open link actions with queued Salesforce updates, oldest
+// first, up to outboxRetryLimit actions at a time. Before the updates are sent the donations are checked for changes made in
+// Salesforce since they were last refreshed; an action with changed donations is left
+// failed with the changes as its error, to be retried or reversed from the pending
+// actions page, as is an action which fails other than by Salesforce being unreachable.
+// The retry stops at the first action for which Salesforce is still unreachable.
+func (r *Reconciler) OutboxRetry(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) (OutboxResults, error) {
+
+	var results OutboxResults
+	ids, err := r.db.SalesforceOutboxActions(ctx, outboxRetryLimit)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "db.SalesforceOutboxActions error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the queued salesforce updates",
+		}
+	}
+
+	for i, id := range ids {
+		err := r.outboxActionRetry(ctx, sfClient, xeroClient, id, dataStartDate, lastRefreshed)
+		if _, ok := errors.AsType[ErrQueued](err); ok || apistatus.Unavailable(err) {
+			results.Queued = len(ids) - i
+			r.log.Info(fmt.Sprintf("salesforce unreachable, %d queued actions not retried: %v", results.Queued, err))
+			return results, nil
+		}
+		if err != nil {
+			results.Failed++
+			r.log.Warn(fmt.Sprintf("queued pending action %d failed: %v", id, err))
+			if err := r.db.SalesforceOutboxClear(ctx, id); err != nil {
+				return results, ErrSystem{
+					Detail: "db.SalesforceOutboxClear error",
+					Err:    err,
+					Msg:    "A problem was encountered removing the queued salesforce updates",
+				}
+			}
+			continue
+		}
+		results.Completed++
+		r.log.Info(fmt.Sprintf("queued pending action %d completed", id))
+	}
+	return results, nil
+}
+
+// OutboxActionsGet returns the ids of the open link actions with queued Salesforce
+// updates, oldest first, up to outboxRetryLimit actions.
+func (r *Reconciler) OutboxActionsGet(ctx context.Context) ([]int64, error) {
+	ids, err := r.db.SalesforceOutboxActions(ctx, outboxRetryLimit)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.SalesforceOutboxActions error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the queued salesforce updates",
+		}
+	}
+	return ids, nil
+}
+
+// outboxActionRetry checks the donations of the queued action with id for remote
+// changes and retries the action. Donations already holding the queued reference in
+// Salesforce were updated before Salesforce became unreachable, and are not taken to
+// have been changed.
+func (r *Reconciler) outboxActionRetry(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	xeroClient XeroClient,
+	id int64,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) error {
+
+	pending, action, err := r.openLinkAction(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	queued := map[string]string{}
+	ids := make([]string, len(action.IDRefs))
+	for i, idRef := range action.IDRefs {
+		queued[idRef.ID] = idRef.Ref
+		ids[i] = idRef.ID
+	}
+	donations, err := r.db.DonationRefsGet(ctx, ids)
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.DonationRefsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the current donation references",
+		}
+	}
+	found, err := donationConflicts(ctx, sfClient, donations)
+	if err != nil {
+		return err
+	}
+	var conflicts []Conflict
+	for _, c := range found {
+		if !conflictApplied(c, queued[c.ID]) {
+			conflicts = append(conflicts, c)
+		}
+	}
+	if len(conflicts) > 0 {
+		err := ErrConflict{Conflicts: conflicts}
+		if rErr := r.db.PendingActionUpdate(ctx, id, pending.Step, string(linking.StatusFailed), err.Error()); rErr != nil {
+			return errors.Join(err, rErr)
+		}
+		return err
+	}
+
+	steps := r.linkActionSteps(id, action, sfClient, xeroClient, dataStartDate, lastRefreshed)
+	if err := linking.Run(ctx, steps, pending.Step, r.linkActionRecorder(id)); err != nil {
+		return r.linkActionFailed(ctx, id, action, err)
+	}
+	return nil
+}
+
+// conflictApplied reports if the only change of the donation conflict c is its payout
+// reference having been set to ref.
+func conflictApplied(c Conflict, ref string) bool {
+	if c.Deleted || len(c.Changes) != 1 {
+		return false
+	}
+	return c.Changes[0].Field == "Payout Reference" && c.Changes[0].Remote == ref
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// mockOfflineSalesforceClient fails the calls checking and updating donations as
+// Salesforce being unreachable while offline is set.
+type mockOfflineSalesforceClient struct {
+	mockLinkSalesforceClient
+	offline bool
+}
+
+func (m *mockOfflineSalesforceClient) unreachable() error {
+	return &url.Error{Op: "Post", URL: "https://example.my.salesforce.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+}
+
+func (m *mockOfflineSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	if m.offline {
+		return nil, m.unreachable()
+	}
+	return m.mockLinkSalesforceClient.GetOpportunitiesByID(ctx, ids)
+}
+
+func (m *mockOfflineSalesforceClient) BatchUpdateOpportunityRefs(ctx context.Context, idRefs []salesforce.IDRef, allOrNone bool) (salesforce.CollectionsUpdateResponse, error) {
+	if m.offline {
+		return nil, m.unreachable()
+	}
+	return m.mockLinkSalesforceClient.BatchUpdateOpportunityRefs(ctx, idRefs, allOrNone)
+}
+
+// TestOutboxRetry tests queueing the updates of a link action made while Salesforce is
+// unreachable, and sending them once it is reachable again.
+func TestOutboxRetry(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	queued := func() []bool {
+		t.Helper()
+		actions, err := reconciler.PendingActionsGet(ctx, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var q []bool
+		for _, a := range actions {
+			q = append(q, a.Queued)
+		}
+		return q
+	}
+
+	sfClient := &mockOfflineSalesforceClient{
+		mockLinkSalesforceClient: mockLinkSalesforceClient{mockSalesforceClient: mockSalesforceClient{log: logger}},
+		offline:                  true,
+	}
+	idRefs := []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}}
+	err := reconciler.LinkActionRun(ctx, sfClient, nil, LinkAction{IDRefs: idRefs}, dataStartDate, time.Time{})
+	if _, ok := errors.AsType[ErrQueued](err); !ok {
+		t.Fatalf("expected ErrQueued, got %v", err)
+	}
+	if diff := cmp.Diff([]bool{true}, queued()); diff != "" {
+		t.Fatalf("queued actions mismatch (-want +got):\n%s", diff)
+	}
+
+	// Nothing is sent while Salesforce is unreachable.
+	results, err := reconciler.OutboxRetry(ctx, sfClient, nil, dataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(OutboxResults{Queued: 1}, results); diff != "" {
+		t.Errorf("offline results mismatch (-want +got):\n%s", diff)
+	}
+
+	// The queued updates are sent once Salesforce is reachable.
+	sfClient.offline = false
+	results, err = reconciler.OutboxRetry(ctx, sfClient, nil, dataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(OutboxResults{Completed: 1}, results); diff != "" {
+		t.Errorf("online results mismatch (-want +got):\n%s", diff)
+	}
+	if got := queued(); len(got) != 0 {
+		t.Errorf("expected no open actions after the retry, got %v", got)
+	}
+	if diff := cmp.Diff([][]salesforce.IDRef{idRefs}, sfClient.updates); diff != "" {
+		t.Errorf("updates mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConflictApplied(t *testing.T) {
+	refChange := Conflict{ID: "a", Changes: []FieldChange{{"Payout Reference", "INV-1", "INV-2"}}}
+	tests := []struct {
+		name     string
+		conflict Conflict
+		ref      string
+		want     bool
+	}{
+		{"applied", refChange, "INV-2", true},
+		{"other reference", refChange, "INV-3", false},
+		{"other changes", Conflict{Changes: append(refChange.Changes, FieldChange{"Amount", "1.00", "2.00"})}, "INV-2", false},
+		{"deleted", Conflict{Deleted: true}, "", false},
+	}
+	for _, tt := range tests {
+		if got := conflictApplied(tt.conflict, tt.ref); got != tt.want {
+			t.Errorf("%s got %t want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/payoutcsv"
+	"github.com/rorycl/reconciler/internal/subsetsum"
+)
+
+// PayoutBatch is a platform payout, such as a JustGiving, Stripe or Enthuse payout,
+// received as a bank transaction bundling many donations less the platform fees. The
+// donations are those linked by the bank transaction reference. The Variance is the
+// donation total of the bank transaction less the linked donations total.
+//
+// The Candidates are the unlinked donations suggested for the payout which together do
+// not exceed the variance, chosen in order of their suggestion score.
+//
+// The Items are those of the platform payout report imported for the payout, if any,
+// each matched to a donation where one has the same amount. The total of the unmatched
+// items explains the variance where the Salesforce donations are incomplete.
+type PayoutBatch struct {
+	Transaction          db.WRTransaction
+	Reference            string
+	LineItems            []ViewLineItem
+	FeeLineItems         []ViewLineItem
+	Donations            []ViewDonation
+	DonationsTotal       money.Amount
+	FeesTotal            money.Amount
+	Variance             money.Amount
+	Candidates           []db.LinkSuggestion
+	CandidatesTotal      money.Amount
+	Combinations         []PayoutCombination
+	CombinationsComplete bool
+	Items                []db.PayoutItem
+	ItemsGross           money.Amount
+	ItemsFees            money.Amount
+	ItemsNet             money.Amount
+	UnmatchedTotal       money.Amount
+}
+
+// PayoutBatchGet retrieves the payout batch of a bank transaction. Candidate donations
+// must have a suggestion score of at least minScore.
+func (r *Reconciler) PayoutBatchGet(ctx context.Context, transactionID string, minScore float64) (*PayoutBatch, error) {
+
+	transaction, lineItems, err := r.TransactionDetailGet(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	batch := &PayoutBatch{
+		Transaction: transaction,
+		LineItems:   lineItems,
+	}
+	if transaction.Reference != nil {
+		batch.Reference = *transaction.Reference
+	}
+
+	// Fees are the line items outside the donation account codes, such as the platform
+	// fees deducted from the payout.
+	for _, li := range lineItems {
+		if li.DonationAmount == 0 && li.LineAmount != 0 {
+			batch.FeeLineItems = append(batch.FeeLineItems, li)
+			batch.FeesTotal += li.LineAmount
+		}
+	}
+
+	if batch.Reference != "" {
+		// A limit of -1 returns all rows. The close dates of linked donations are not
+		// restricted.
+		batch.Donations, err = r.DonationsGet(
+			ctx,
+			time.Time{},
+			time.Now().AddDate(1, 0, 0),
+			"Linked",
+			batch.Reference,
+			"",
+			"",
+			db.SortOrder{},
+			-1,
+			0,
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	for _, d := range batch.Donations {
+		batch.DonationsTotal += d.Amount
+	}
+
+	if batch.Reference != "" {
+		batch.Items, err = r.db.PayoutItemsGet(ctx, batch.Reference)
+		if err != nil {
+			return nil, ErrSystem{
+				Detail: "db.PayoutItemsGet error",
+				Err:    err,
+				Msg:    "A problem was encountered retrieving the payout report items",
+			}
+		}
+	}
+	for _, item := range batch.Items {
+		batch.ItemsGross += item.Gross
+		batch.ItemsFees += item.Fee
+		batch.ItemsNet += item.Net
+		if item.DonationID == nil {
+			batch.UnmatchedTotal += item.Gross
+		}
+	}
+	batch.Variance = transaction.DonationTotal - batch.DonationsTotal
+
+	// Suggestions are retrieved for the records dated on the day of the transaction.
+	day := transaction.Date.Truncate(24 * time.Hour)
+	suggestions, err := r.LinkSuggestionsGet(ctx, day, day.AddDate(0, 0, 1), minScore)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	batch.Candidates, batch.CandidatesTotal = payoutCandidates(suggestions, transactionID, batch.Variance)
+
+	if batch.Reference != "" && batch.Variance > 0 {
+		batch.Combinations, batch.CombinationsComplete, err = r.payoutCombinations(ctx, transaction, batch.Variance)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return batch, nil
+}
+
+// PayoutCombination is a combination of candidate donations making up the variance of
+// a payout batch. Difference is the Total less the variance.
+type PayoutCombination struct {
+	Donations  []db.LinkSuggestion
+	Total      money.Amount
+	Difference money.Amount
+}
+
+// DonationIDs returns the ids of the donations of the combination.
+func (c PayoutCombination) DonationIDs() []string {
+	ids := make([]string, len(c.Donations))
+	for i, d := range c.Donations {
+		ids[i] = d.DonationID
+	}
+	return ids
+}
+
+// payoutCombinationLimits bound the search for the combinations of candidate donations
+// making up the variance of a payout, which grows exponentially with the number of
+// candidates. Only the payoutCombinationCandidates best scoring candidates are
+// searched.
+var (
+	payoutCombinationLimits     = subsetsum.Limits{MaxItems: 8, MaxSteps: 200_000, MaxResults: 5}
+	payoutCombinationCandidates = 40
+)
+
+// payoutCombinations finds the best combinations of the candidate donations of the
+// bank transaction which sum to the variance within the reconciliation tolerance of
+// the transaction's donation total, and whether the search was complete.
+func (r *Reconciler) payoutCombinations(ctx context.Context, transaction db.WRTransaction, variance money.Amount) ([]PayoutCombination, bool, error) {
+
+	candidates, err := r.DonationCandidatesGet(ctx, "bank-transaction", transaction.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(candidates) > payoutCombinationCandidates {
+		candidates = candidates[:payoutCombinationCandidates]
+	}
+	tolerance, err := r.ToleranceGet(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	allowed := max(tolerance.Amount, money.FromFloat(transaction.DonationTotal.Abs().Float()*tolerance.Percent/100))
+
+	// The candidates are ordered by score, so that better scoring combinations are
+	// preferred where they are otherwise equal.
+	amounts := make([]money.Amount, len(candidates))
+	for i, c := range candidates {
+		amounts[i] = c.DonationAmount
+	}
+	results, complete := subsetsum.Find(amounts, variance, allowed, payoutCombinationLimits)
+
+	combinations := make([]PayoutCombination, len(results))
+	for i, res := range results {
+		combinations[i] = PayoutCombination{Total: res.Total, Difference: res.Difference}
+		for _, j := range res.Indices {
+			combinations[i].Donations = append(combinations[i].Donations, candidates[j])
+		}
+	}
+	return combinations, complete, nil
+}
+
+// payoutCandidates chooses the suggested donations for the bank transaction, taking
+// the highest scoring first, which together do not exceed the outstanding amount. The
+// suggestions are ordered by descending score for each record.
+func payoutCandidates(suggestions []db.LinkSuggestion, transactionID string, outstanding money.Amount) ([]db.LinkSuggestion, money.Amount) {
+	var candidates []db.LinkSuggestion
+	var total money.Amount
+	for _, s := range suggestions {
+		if s.Typer != "bank-transaction" || s.RecordID != transactionID {
+			continue
+		}
+		if total+s.DonationAmount > outstanding {
+			continue
+		}
+		candidates = append(candidates, s)
+		total += s.DonationAmount
+	}
+	return candidates, total
+}
+
+// PayoutCandidatesLink links the remaining candidate donations of a payout batch to
+// its bank transaction. Candidate donations must have a suggestion score of at least
+// minScore.
+func (r *Reconciler) PayoutCandidatesLink(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	transactionID string,
+	minScore float64,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) (*SuggestionDecisionResults, error) {
+
+	batch, err := r.PayoutBatchGet(ctx, transactionID, minScore)
+	if err != nil {
+		return nil, err
+	}
+	if len(batch.Candidates) == 0 {
+		return nil, ErrUsage{
+			Detail: "PayoutCandidatesLink error",
+			Msg:    "There are no candidate donations to link to this payout",
+		}
+	}
+	decisions := make([]SuggestionDecision, len(batch.Candidates))
+	for i, c := range batch.Candidates {
+		decisions[i] = SuggestionDecision{
+			Typer:      c.Typer,
+			RecordID:   c.RecordID,
+			DonationID: c.DonationID,
+			Accept:     true,
+		}
+	}
+	return r.LinkSuggestionDecisionsApply(ctx, sfClient, decisions, dataStartDate, lastRefreshed)
+}
+
+// PayoutCombinationLink links the donations with donationIDs, which must be one of the
+// combinations of candidate donations of the payout batch, to its bank transaction.
+func (r *Reconciler) PayoutCombinationLink(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	transactionID string,
+	donationIDs []string,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) (*SuggestionDecisionResults, error) {
+
+	batch, err := r.PayoutBatchGet(ctx, transactionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	wanted := slices.Sorted(slices.Values(donationIDs))
+	for _, c := range batch.Combinations {
+		if !slices.Equal(slices.Sorted(slices.Values(c.DonationIDs())), wanted) {
+			continue
+		}
+		decisions := make([]SuggestionDecision, len(c.Donations))
+		for i, d := range c.Donations {
+			decisions[i] = SuggestionDecision{
+				Typer:      d.Typer,
+				RecordID:   d.RecordID,
+				DonationID: d.DonationID,
+				Accept:     true,
+			}
+		}
+		return r.LinkSuggestionDecisionsApply(ctx, sfClient, decisions, dataStartDate, lastRefreshed)
+	}
+	return nil, ErrUsage{
+		Detail: "PayoutCombinationLink error",
+		Msg:    "The donations are no longer a combination making up the payout; review the payout again",
+	}
+}
+
+// PayoutItemsStage stages the items of a platform payout report for review, returning
+// the id of the import batch. Once committed the items are recorded against the bank
+// transaction of the payout, replacing any items imported before. The report must be
+// for a single payout; items repeating the reference of an earlier item are rejected.
+func (r *Reconciler) PayoutItemsStage(ctx context.Context, transactionID, platform, fileName string, items []payoutcsv.Item) (int64, error) {
+
+	transaction, _, err := r.TransactionDetailGet(ctx, transactionID)
+	if err != nil {
+		return 0, err
+	}
+	if transaction.Reference == nil || *transaction.Reference == "" {
+		return 0, ErrUsage{
+			Detail: "PayoutItemsStage error",
+			Msg:    "The bank transaction has no reference, so a payout report cannot be recorded for it",
+		}
+	}
+	if len(items) == 0 {
+		return 0, ErrUsage{
+			Detail: "PayoutItemsStage error",
+			Msg:    "The payout report contains no items",
+		}
+	}
+
+	payoutIDs := map[string]bool{}
+	for _, item := range items {
+		if item.PayoutID != "" {
+			payoutIDs[item.PayoutID] = true
+		}
+	}
+	if len(payoutIDs) > 1 {
+		return 0, ErrUsage{
+			Detail: "PayoutItemsStage error",
+			Msg:    fmt.Sprintf("The payout report covers %d payouts; export the report of a single payout", len(payoutIDs)),
+		}
+	}
+
+	seen := map[string]bool{}
+	rows := make([]db.ImportRow, len(items))
+	for i, item := range items {
+		var rowErr error
+		if seen[item.Ref] {
+			rowErr = fmt.Errorf("duplicate reference %q", item.Ref)
+		}
+		seen[item.Ref] = true
+
+		vals := []string{item.Ref, item.Date.Format(time.DateOnly), item.Name, item.Gross.String(), item.Fee.String(), item.Net.String()}
+		rows[i], err = stagedRow(i+2, item.Ref, vals, db.PayoutItem{
+			Platform:         platform,
+			PlatformPayoutID: item.PayoutID,
+			ItemRef:          item.Ref,
+			Date:             item.Date,
+			Name:             item.Name,
+			Description:      item.Description,
+			Gross:            item.Gross,
+			Fee:              item.Fee,
+			Net:              item.Net,
+		}, rowErr)
+		if err != nil {
+			return 0, ErrSystem{
+				Detail: "PayoutItemsStage encoding error",
+				Err:    err,
+				Msg:    "A problem was encountered staging the payout report items",
+			}
+		}
+	}
+
+	id, err := r.db.ImportBatchCreate(ctx, db.ImportPayoutItems, *transaction.Reference, fileName, rows)
+	if err != nil {
+		return 0, ErrSystem{
+			Detail: "db.ImportBatchCreate error",
+			Err:    err,
+			Msg:    "A problem was encountered staging the payout report items",
+		}
+	}
+	r.log.Info("staged payout report", "platform", platform, "reference", *transaction.Reference, "items", len(items), "batch", id)
+	return id, nil
+}
@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/payoutcsv"
+)
+
+// TestPayoutBatchGet tests retrieving the linked donations, fees and candidate
+// donations of payouts.
+func TestPayoutBatchGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	batch, err := reconciler.PayoutBatchGet(ctx, "bt-001", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(batch.Donations), 12; got != want {
+		t.Errorf("got %d donations want %d", got, want)
+	}
+	if got, want := batch.DonationsTotal, money.FromFloat(355); got != want {
+		t.Errorf("donations total got %s want %s", got, want)
+	}
+	if got, want := batch.FeesTotal, money.FromFloat(-17.75); got != want || len(batch.FeeLineItems) != 1 {
+		t.Errorf("fees total got %s (%d items) want %s", got, len(batch.FeeLineItems), want)
+	}
+	if batch.Variance != 0 || len(batch.Candidates) != 0 {
+		t.Errorf("expected no variance or candidates, got %s and %d", batch.Variance, len(batch.Candidates))
+	}
+
+	batch, err = reconciler.PayoutBatchGet(ctx, "bt-unrec-04", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := batch.Variance, money.FromFloat(150); got != want {
+		t.Errorf("variance got %s want %s", got, want)
+	}
+	if len(batch.Candidates) == 0 {
+		t.Fatal("expected candidates")
+	}
+	if batch.CandidatesTotal > batch.Variance {
+		t.Errorf("candidates total %s exceeds the variance %s", batch.CandidatesTotal, batch.Variance)
+	}
+	if len(batch.Combinations) != 1 || !batch.CombinationsComplete {
+		t.Fatalf("expected one complete combination, got %d (complete %t)", len(batch.Combinations), batch.CombinationsComplete)
+	}
+	if got, want := batch.Combinations[0].DonationIDs(), []string{"sf-opp-017"}; !slices.Equal(got, want) {
+		t.Errorf("combination got %v want %v", got, want)
+	}
+
+	_, err = reconciler.PayoutBatchGet(ctx, "bt-99999", 0)
+	if _, ok := errors.AsType[ErrNotFound](err); !ok {
+		t.Errorf("expected ErrNotFound, got %T %v", err, err)
+	}
+}
+
+// TestPayoutCandidatesLink tests linking the candidate donations of a payout. The
+// Salesforce API client is mocked.
+func TestPayoutCandidatesLink(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	batch, err := reconciler.PayoutBatchGet(ctx, "bt-unrec-04", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msc := &mockSalesforceClient{log: logger}
+	results, err := reconciler.PayoutCandidatesLink(ctx, msc, "bt-unrec-04", 0, dataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.Linked, len(batch.Candidates); got != want {
+		t.Errorf("got %d linked want %d", got, want)
+	}
+
+	// The fully linked payout has no candidates.
+	_, err = reconciler.PayoutCandidatesLink(ctx, msc, "bt-001", 0, dataStartDate, time.Time{})
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage, got %T %v", err, err)
+	}
+}
+
+// TestPayoutCombinationLink tests linking a combination of candidate donations making
+// up a payout. The Salesforce API client is mocked.
+func TestPayoutCombinationLink(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	msc := &mockSalesforceClient{log: logger}
+
+	// Donations which are not a combination making up the payout are refused.
+	_, err := reconciler.PayoutCombinationLink(ctx, msc, "bt-unrec-04", []string{"sf-opp-018", "sf-opp-019"}, dataStartDate, time.Time{})
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage, got %T %v", err, err)
+	}
+
+	results, err := reconciler.PayoutCombinationLink(ctx, msc, "bt-unrec-04", []string{"sf-opp-017"}, dataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := results.Linked, 1; got != want {
+		t.Errorf("got %d linked want %d", got, want)
+	}
+}
+
+// TestPayoutItemsStage tests staging a payout report against a bank transaction,
+// committing it and matching its items to the linked donations.
+func TestPayoutItemsStage(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	// bt-002 has donations of 100.00 and 150.00 linked out of 500.00.
+	date := time.Date(2025, 4, 18, 0, 0, 0, 0, time.UTC)
+	items := []payoutcsv.Item{
+		{Ref: "txn_1", PayoutID: "po_1", Date: date, Gross: money.FromFloat(100), Fee: money.FromFloat(2), Net: money.FromFloat(98)},
+		{Ref: "txn_2", PayoutID: "po_1", Date: date, Gross: money.FromFloat(150), Fee: money.FromFloat(3), Net: money.FromFloat(147)},
+		{Ref: "txn_3", PayoutID: "po_1", Date: date, Gross: money.FromFloat(250), Fee: money.FromFloat(5), Net: money.FromFloat(245)},
+		{Ref: "txn_1", PayoutID: "po_1", Date: date, Gross: money.FromFloat(100), Fee: money.FromFloat(2), Net: money.FromFloat(98)},
+	}
+	id, err := reconciler.PayoutItemsStage(ctx, "bt-002", payoutcsv.Stripe, "report.csv", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := reconciler.ImportReviewGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Batch.Accepted != 3 || review.Batch.Rejected != 1 || review.Rows[3].Error != `duplicate reference "txn_1"` {
+		t.Errorf("unexpected review %+v", review)
+	}
+
+	// The items are only recorded once committed.
+	batch, err := reconciler.PayoutBatchGet(ctx, "bt-002", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Items) != 0 {
+		t.Errorf("got %d items before commit", len(batch.Items))
+	}
+	result, err := reconciler.ImportCommit(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Committed, 3; got != want {
+		t.Errorf("got %d items committed want %d", got, want)
+	}
+
+	batch, err = reconciler.PayoutBatchGet(ctx, "bt-002", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(batch.Items), 3; got != want {
+		t.Fatalf("got %d items want %d", got, want)
+	}
+	if got, want := batch.ItemsGross, money.FromFloat(500); got != want {
+		t.Errorf("items gross got %s want %s", got, want)
+	}
+	if got, want := batch.ItemsFees, money.FromFloat(10); got != want {
+		t.Errorf("items fees got %s want %s", got, want)
+	}
+	if got, want := batch.UnmatchedTotal, batch.Variance; got != want {
+		t.Errorf("unmatched items total %s does not explain the variance %s", got, want)
+	}
+
+	// A report of several payouts is refused.
+	items[2].PayoutID = "po_2"
+	_, err = reconciler.PayoutItemsStage(ctx, "bt-002", payoutcsv.Stripe, "report.csv", items)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage, got %T %v", err, err)
+	}
+}
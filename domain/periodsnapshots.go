@@ -0,0 +1,232 @@
+package domain
+
+// periodsnapshots.go records the reconciliation state of a period when it is locked,
+// and compares it with the current state so that anything changed after the close,
+// such as a record unlinked or reconciled by an override, can be reviewed.
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// The changes of a record since a period snapshot.
+const (
+	SnapshotReconciled   = "reconciled"   // reconciled after the close
+	SnapshotUnreconciled = "unreconciled" // no longer reconciled
+	SnapshotChanged      = "changed"      // still reconciled, with changed values
+)
+
+// PeriodSnapshotChange is a record whose reconciliation changed after a period
+// snapshot. Before is the donation total at the close, if the record was reconciled,
+// and After the current donation total, if it is reconciled.
+type PeriodSnapshotChange struct {
+	RecordType string
+	ID         string
+	Ref        string
+	Change     string
+	Before     money.Amount
+	After      money.Amount
+}
+
+// PeriodSnapshotComparison compares a period snapshot with the current state of its
+// period.
+type PeriodSnapshotComparison struct {
+	Snapshot db.PeriodSnapshot
+	Current  db.PeriodSnapshot
+	Changes  []PeriodSnapshotChange
+}
+
+// Changed reports if the period has changed since the snapshot.
+func (c *PeriodSnapshotComparison) Changed() bool {
+	s, n := c.Snapshot, c.Current
+	return len(c.Changes) > 0 ||
+		s.Invoices != n.Invoices || s.InvoicesReconciled != n.InvoicesReconciled ||
+		s.BankTransactions != n.BankTransactions || s.BankTransactionsReconciled != n.BankTransactionsReconciled ||
+		s.DonationTotal != n.DonationTotal || s.ReconciledTotal != n.ReconciledTotal
+}
+
+// PeriodSnapshotsGet retrieves the period snapshots, latest first.
+func (r *Reconciler) PeriodSnapshotsGet(ctx context.Context) ([]db.PeriodSnapshot, error) {
+	snapshots, err := r.db.PeriodSnapshotsGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.PeriodSnapshotsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the period snapshots",
+		}
+	}
+	return snapshots, nil
+}
+
+// PeriodSnapshotCompare compares the period snapshot with id with the current state of
+// its period.
+func (r *Reconciler) PeriodSnapshotCompare(ctx context.Context, id int64) (*PeriodSnapshotComparison, error) {
+	snapshot, err := r.db.PeriodSnapshotGet(ctx, id)
+	if _, ok := errors.AsType[db.ErrNotFound](err); ok {
+		return nil, ErrNotFound{
+			Detail: err.Error(),
+			Msg:    "The period snapshot was not found",
+		}
+	}
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.PeriodSnapshotGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the period snapshot",
+		}
+	}
+	current, err := r.periodSnapshotTake(ctx, snapshot.DateFrom, snapshot.DateTo)
+	if err != nil {
+		return nil, err
+	}
+	current.PeriodLockID = snapshot.PeriodLockID
+	return &PeriodSnapshotComparison{
+		Snapshot: snapshot,
+		Current:  current,
+		Changes:  periodSnapshotChanges(snapshot.Records, current.Records),
+	}, nil
+}
+
+// periodSnapshotRecord records the period snapshot of the period of the lock with id.
+func (r *Reconciler) periodSnapshotRecord(ctx context.Context, id int64, dateFrom, dateTo time.Time) error {
+	snapshot, err := r.periodSnapshotTake(ctx, dateFrom, dateTo)
+	if err != nil {
+		return err
+	}
+	snapshot.PeriodLockID = id
+	if _, err := r.db.PeriodSnapshotInsert(ctx, snapshot); err != nil {
+		return ErrSystem{
+			Detail: "db.PeriodSnapshotInsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the period snapshot",
+		}
+	}
+	return nil
+}
+
+// periodSnapshotTake returns the current reconciliation state of the invoices and bank
+// transactions dated from dateFrom to dateTo.
+func (r *Reconciler) periodSnapshotTake(ctx context.Context, dateFrom, dateTo time.Time) (db.PeriodSnapshot, error) {
+
+	snapshot := db.PeriodSnapshot{DateFrom: dateFrom, DateTo: dateTo}
+	sysErr := func(detail string, err error) error {
+		return ErrSystem{
+			Detail: detail,
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the reconciliation state of the period",
+		}
+	}
+
+	invoices, err := r.db.InvoicesGet(ctx, "All", dateFrom, dateTo, "", "", db.SortOrder{}, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return snapshot, sysErr("db.InvoicesGet error", err)
+	}
+	transactions, err := r.db.BankTransactionsGet(ctx, "All", dateFrom, dateTo, "", "", db.SortOrder{}, -1, 0)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return snapshot, sysErr("db.BankTransactionsGet error", err)
+	}
+
+	// The values of each reconciled record which are hashed, less its donations.
+	values := map[string][]string{}
+	var refs []string
+	reconciled := func(recordType, id, ref string, date time.Time, status string, total, donationTotal, crmsTotal money.Amount) {
+		snapshot.ReconciledTotal += donationTotal
+		snapshot.Records = append(snapshot.Records, db.PeriodSnapshotRecord{
+			RecordType:    recordType,
+			ID:            id,
+			Ref:           ref,
+			DonationTotal: donationTotal,
+		})
+		values[recordType+id] = []string{recordType, id, ref, date.Format("2006-01-02"), status, total.String(), donationTotal.String(), crmsTotal.String()}
+		refs = append(refs, ref)
+	}
+	for _, i := range invoices {
+		snapshot.Invoices++
+		snapshot.DonationTotal += i.DonationTotal
+		if i.IsReconciled {
+			snapshot.InvoicesReconciled++
+			reconciled("invoice", i.InvoiceID, i.InvoiceNumber, i.Date, i.Status, i.Total, i.DonationTotal, i.CRMSTotal)
+		}
+	}
+	for _, b := range transactions {
+		snapshot.BankTransactions++
+		snapshot.DonationTotal += b.DonationTotal
+		if b.IsReconciled {
+			snapshot.BankTransactionsReconciled++
+			reconciled("bank-transaction", b.ID, b.Reference, b.Date, b.Status, b.Total, b.DonationTotal, b.CRMSTotal)
+		}
+	}
+
+	// The donations counted against each reconciled record are included in its hash,
+	// so that relinking donations with the same total is a change.
+	donations, err := r.db.PayoutDonationsGet(ctx, refs)
+	if err != nil {
+		return snapshot, sysErr("db.PayoutDonationsGet error", err)
+	}
+	byRef := map[string][]string{}
+	for _, d := range donations {
+		byRef[d.Ref] = append(byRef[d.Ref], d.DonationID+"="+d.Amount.String())
+	}
+	for i, rec := range snapshot.Records {
+		v := append(values[rec.RecordType+rec.ID], byRef[rec.Ref]...)
+		sum := sha256.Sum256([]byte(strings.Join(v, "\x1f")))
+		snapshot.Records[i].Hash = hex.EncodeToString(sum[:8])
+	}
+	slices.SortFunc(snapshot.Records, func(a, b db.PeriodSnapshotRecord) int {
+		return cmp.Or(cmp.Compare(a.RecordType, b.RecordType), cmp.Compare(a.ID, b.ID))
+	})
+	return snapshot, nil
+}
+
+// periodSnapshotChanges returns the changes from the reconciled records before to
+// those after, ordered by record type and id.
+func periodSnapshotChanges(before, after db.PeriodSnapshotRecords) []PeriodSnapshotChange {
+	key := func(rec db.PeriodSnapshotRecord) string {
+		return fmt.Sprintf("%s %s", rec.RecordType, rec.ID)
+	}
+	current := map[string]db.PeriodSnapshotRecord{}
+	for _, rec := range after {
+		current[key(rec)] = rec
+	}
+
+	var changes []PeriodSnapshotChange
+	for _, b := range before {
+		a, ok := current[key(b)]
+		delete(current, key(b))
+		change := PeriodSnapshotChange{RecordType: b.RecordType, ID: b.ID, Ref: b.Ref, Before: b.DonationTotal}
+		switch {
+		case !ok:
+			change.Change = SnapshotUnreconciled
+		case a.Hash != b.Hash:
+			change.Change = SnapshotChanged
+			change.Ref, change.After = a.Ref, a.DonationTotal
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	for _, a := range current {
+		changes = append(changes, PeriodSnapshotChange{
+			RecordType: a.RecordType,
+			ID:         a.ID,
+			Ref:        a.Ref,
+			Change:     SnapshotReconciled,
+			After:      a.DonationTotal,
+		})
+	}
+	slices.SortFunc(changes, func(a, b PeriodSnapshotChange) int {
+		return cmp.Or(cmp.Compare(a.RecordType, b.RecordType), cmp.Compare(a.ID, b.ID))
+	})
+	return changes
+}
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/db"
+)
+
+// TestPeriodSnapshots tests that locking a period records a snapshot, and that the
+// changes made to the period afterwards are shown by comparing it.
+func TestPeriodSnapshots(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	if err := reconciler.PeriodLockAdd(ctx, from, to, "year end"); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := reconciler.PeriodSnapshotsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots want 1", len(snapshots))
+	}
+	s := snapshots[0]
+	if s.Invoices == 0 || s.InvoicesReconciled == 0 || len(s.Records) != s.InvoicesReconciled+s.BankTransactionsReconciled {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+
+	comparison, err := reconciler.PeriodSnapshotCompare(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comparison.Changed() {
+		t.Fatalf("expected no changes, got %+v", comparison.Changes)
+	}
+
+	// Change the total of a reconciled record after the close.
+	changed := s.Records[len(s.Records)-1]
+	table := map[string]string{"invoice": "invoices", "bank-transaction": "bank_transactions"}[changed.RecordType]
+	if _, err := testDB.ExecContext(ctx, "UPDATE "+table+" SET total = total + 1 WHERE id = ?", changed.ID); err != nil {
+		t.Fatal(err)
+	}
+	testDB.InvalidateCache()
+	comparison, err = reconciler.PeriodSnapshotCompare(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PeriodSnapshotChange{{
+		RecordType: changed.RecordType,
+		ID:         changed.ID,
+		Ref:        changed.Ref,
+		Change:     SnapshotChanged,
+		Before:     changed.DonationTotal,
+		After:      changed.DonationTotal,
+	}}
+	if diff := cmp.Diff(want, comparison.Changes); diff != "" || !comparison.Changed() {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+
+	if _, err := reconciler.PeriodSnapshotCompare(ctx, s.ID+1); err == nil {
+		t.Error("expected an error comparing a missing snapshot")
+	}
+}
+
+func TestPeriodSnapshotChanges(t *testing.T) {
+
+	before := db.PeriodSnapshotRecords{
+		{RecordType: "invoice", ID: "inv-1", Ref: "INV-1", DonationTotal: 100, Hash: "a"},
+		{RecordType: "invoice", ID: "inv-2", Ref: "INV-2", DonationTotal: 200, Hash: "b"},
+		{RecordType: "invoice", ID: "inv-3", Ref: "INV-3", DonationTotal: 300, Hash: "c"},
+	}
+	after := db.PeriodSnapshotRecords{
+		{RecordType: "bank-transaction", ID: "bt-1", Ref: "BT-1", DonationTotal: 50, Hash: "d"},
+		{RecordType: "invoice", ID: "inv-1", Ref: "INV-1", DonationTotal: 100, Hash: "a"},
+		{RecordType: "invoice", ID: "inv-3", Ref: "INV-3", DonationTotal: 250, Hash: "e"},
+	}
+	want := []PeriodSnapshotChange{
+		{RecordType: "bank-transaction", ID: "bt-1", Ref: "BT-1", Change: SnapshotReconciled, After: 50},
+		{RecordType: "invoice", ID: "inv-2", Ref: "INV-2", Change: SnapshotUnreconciled, Before: 200},
+		{RecordType: "invoice", ID: "inv-3", Ref: "INV-3", Change: SnapshotChanged, Before: 300, After: 250},
+	}
+	if diff := cmp.Diff(want, periodSnapshotChanges(before, after)); diff != "" {
+		t.Errorf("unexpected changes (-want +got):\n%s", diff)
+	}
+}
@@ -4,6 +4,7 @@ package domain
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
@@ -11,13 +12,16 @@ import (
 	"time"
 
 	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
 	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 // Reconciler represents the main domain operations of the system.
 type Reconciler struct {
-	db  *db.DB
-	log *slog.Logger
+	db    *db.DB
+	log   *slog.Logger
+	syncs syncGuard // the running refreshes
 }
 
 // NewReconciler creates a new Reconciler.
@@ -38,40 +42,170 @@ func (r *Reconciler) DBPath() string {
 	return r.db.Path
 }
 
+// WithTx runs fn in a database transaction, so that the changes made by the Reconciler
+// methods fn calls with the context it is passed are all made or, if fn returns an
+// error, none are. The error of fn is returned unchanged. A WithTx nested within fn
+// may fail without the outer transaction failing. fn should not call Xero or
+// Salesforce, as other changes wait until the transaction ends.
+func (r *Reconciler) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var fnErr error
+	err := r.db.WithTx(ctx, func(ctx context.Context) error {
+		fnErr = fn(ctx)
+		return fnErr
+	})
+	if err != nil && fnErr == nil {
+		return ErrSystem{
+			Detail: "db.WithTx error",
+			Err:    err,
+			Msg:    "A problem was encountered saving the changes",
+		}
+	}
+	return err
+}
+
+// SQLReload re-reads the sql files and prepares the database statements again, for
+// use in development mode. Other reconciler operations must not run during the
+// reload.
+func (r *Reconciler) SQLReload() error {
+	return r.db.ReloadStatements()
+}
+
+// SlowQueriesGet returns the recent slow database queries and the slow query
+// threshold, which is zero if slow query recording is not enabled.
+func (r *Reconciler) SlowQueriesGet() ([]db.SlowQuery, time.Duration) {
+	return r.db.SlowQueries()
+}
+
+// PoolStatsGet returns the database connection pool and write contention statistics.
+func (r *Reconciler) PoolStatsGet() db.PoolStats {
+	return r.db.PoolStats()
+}
+
+// DataVersionGet returns an identifier of the current state of the database, which
+// changes with every write, and the time of the last write.
+func (r *Reconciler) DataVersionGet() (string, time.Time) {
+	return r.db.DataVersion()
+}
+
+// CacheStatsGet returns the database query cache statistics.
+func (r *Reconciler) CacheStatsGet() db.CacheStats {
+	return r.db.CacheStats()
+}
+
+// SnapshotExport writes a read-only snapshot of the Reconciler database to path.
+func (r *Reconciler) SnapshotExport(ctx context.Context, path string) error {
+	if err := r.db.ExportSnapshot(ctx, path); err != nil {
+		return ErrSystem{
+			Detail: "db.ExportSnapshot error",
+			Err:    err,
+			Msg:    "A problem was encountered exporting the database snapshot",
+		}
+	}
+	return nil
+}
+
+// SeedDemoData adds generated demonstration records to the Reconciler database.
+func (r *Reconciler) SeedDemoData(ctx context.Context, opts db.SeedOptions) (db.SeedResult, error) {
+	result, err := r.db.SeedDemoData(ctx, opts)
+	if err != nil {
+		return result, ErrSystem{
+			Detail: "db.SeedDemoData error",
+			Err:    err,
+			Msg:    "A problem was encountered generating the demonstration data",
+		}
+	}
+	return result, nil
+}
+
+// SnapshotImport replaces the Reconciler database contents with those of the snapshot
+// at path.
+func (r *Reconciler) SnapshotImport(ctx context.Context, path string) error {
+	err := r.db.ImportSnapshot(ctx, path)
+	if errors.Is(err, db.ErrInvalidSnapshot) {
+		return ErrUsage{
+			Detail: fmt.Sprintf("db.ImportSnapshot error: %v", err),
+			Msg:    "The file is not a valid reconciler database snapshot",
+		}
+	}
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.ImportSnapshot error",
+			Err:    err,
+			Msg:    "A problem was encountered importing the database snapshot",
+		}
+	}
+	return nil
+}
+
 // Close closes the Reconciler database. Other reconciler operations should cease after
 // Close is called.
 func (r *Reconciler) Close() error {
 	return r.db.Close()
 }
 
-// InvoicesGet retrieves the invoices relating to the search terms.
+// InvoicesGet retrieves the invoices relating to the search terms, only those assigned
+// to assignedTo if it is not empty.
 func (r *Reconciler) InvoicesGet(
 	ctx context.Context,
 	status string,
 	from time.Time,
 	to time.Time,
 	search string,
+	assignedTo string,
+	sort db.SortOrder,
 	pageLen int,
 	offset int,
 ) ([]db.Invoice, error) {
-	return r.db.InvoicesGet(ctx, status, from, to, search, pageLen, offset)
+	return r.db.InvoicesGet(ctx, status, from, to, search, assignedTo, sort, pageLen, offset)
 }
 
-// TransactionsGet retrieves the bank transactions relating to the search terms.
+// TransactionsGet retrieves the bank transactions relating to the search terms, only
+// those assigned to assignedTo if it is not empty.
 func (r *Reconciler) TransactionsGet(
 	ctx context.Context,
 	status string,
 	from time.Time,
 	to time.Time,
 	search string,
+	assignedTo string,
+	sort db.SortOrder,
 	pageLen int,
 	offset int,
 ) ([]db.BankTransaction, error) {
-	return r.db.BankTransactionsGet(ctx, status, from, to, search, pageLen, offset)
+	return r.db.BankTransactionsGet(ctx, status, from, to, search, assignedTo, sort, pageLen, offset)
 }
 
-// DonationsGet retrieves the donations relating to the search terms, converting them to
-// de-pointered objects.
+// InvoiceNeighbours retrieves the unreconciled invoices either side of the invoice with
+// id in the invoices listing with the search terms, for stepping through the listing.
+func (r *Reconciler) InvoiceNeighbours(
+	ctx context.Context,
+	id string,
+	from time.Time,
+	to time.Time,
+	search string,
+	assignedTo string,
+	sort db.SortOrder,
+) (db.Neighbours, error) {
+	return r.db.InvoiceNeighbours(ctx, id, from, to, search, assignedTo, sort)
+}
+
+// TransactionNeighbours retrieves the unreconciled bank transactions either side of the
+// bank transaction with id in the bank transactions listing with the search terms, for
+// stepping through the listing.
+func (r *Reconciler) TransactionNeighbours(
+	ctx context.Context,
+	id string,
+	from time.Time,
+	to time.Time,
+	search string,
+	assignedTo string,
+	sort db.SortOrder,
+) (db.Neighbours, error) {
+	return r.db.BankTransactionNeighbours(ctx, id, from, to, search, assignedTo, sort)
+}
+
+// DonationsGet retrieves the donations relating to the search terms, only those
+// assigned to assignedTo if it is not empty, converting them to de-pointered objects.
 func (r *Reconciler) DonationsGet(
 	ctx context.Context,
 	from time.Time,
@@ -79,11 +213,13 @@ func (r *Reconciler) DonationsGet(
 	linkage string,
 	payoutReference string,
 	search string,
+	assignedTo string,
+	sort db.SortOrder,
 	pageLen int,
 	offset int,
 ) ([]ViewDonation, error) {
 
-	donations, err := r.db.DonationsGet(ctx, from, to, linkage, payoutReference, search, pageLen, offset)
+	donations, err := r.db.DonationsGet(ctx, from, to, linkage, payoutReference, search, assignedTo, sort, pageLen, offset)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, ErrSystem{
 			Detail: "db.DonationsGet error",
@@ -95,6 +231,20 @@ func (r *Reconciler) DonationsGet(
 
 }
 
+// Search retrieves up to limit invoices, bank transactions and donations matching all
+// of the words in text using the full-text search index, most relevant first.
+func (r *Reconciler) Search(ctx context.Context, text string, limit int) ([]db.SearchResult, error) {
+	results, err := r.db.Search(ctx, text, limit)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.Search error",
+			Err:    err,
+			Msg:    "A problem was encountered searching the records",
+		}
+	}
+	return results, err // percolate sql.ErrNoRows if necessary.
+}
+
 // InvoiceDetailGet retrieves an invoice and its related line items which are returned
 // as de-pointered objects.
 func (r *Reconciler) InvoiceDetailGet(
@@ -106,15 +256,15 @@ func (r *Reconciler) InvoiceDetailGet(
 	var lineItems []db.WRLineItem
 	var err error
 	invoice, lineItems, err = r.db.InvoiceWRGet(ctx, invoiceID)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return invoice, nil, ErrSystem{
 			Detail: "db.InvoiceWRGet error",
 			Err:    err,
 			Msg:    "A problem was encountered retrieving the invoice details",
 		}
 	}
-	if err == sql.ErrNoRows {
-		return invoice, nil, ErrUsage{
+	if errors.Is(err, sql.ErrNoRows) {
+		return invoice, nil, ErrNotFound{
 			Detail: "db.InvoiceWRGet not found error",
 			Msg:    "The requested invoice was not found",
 		}
@@ -134,15 +284,15 @@ func (r *Reconciler) TransactionDetailGet(
 	var lineItems []db.WRLineItem
 	var err error
 	transaction, lineItems, err = r.db.BankTransactionWRGet(ctx, transactionID)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return transaction, nil, ErrSystem{
 			Detail: "db.BankTransactionWRGet error",
 			Err:    err,
 			Msg:    "A problem was encountered retrieving the transaction details",
 		}
 	}
-	if err == sql.ErrNoRows {
-		return transaction, nil, ErrUsage{
+	if errors.Is(err, sql.ErrNoRows) {
+		return transaction, nil, ErrNotFound{
 			Detail: "db.BankTransactionWRGet not found error",
 			Msg:    "The requested transaction was not found",
 		}
@@ -152,6 +302,433 @@ func (r *Reconciler) TransactionDetailGet(
 
 }
 
+// XeroShortCodeGet returns the Xero organisation short code used for deep links into
+// the Xero web application. An empty string is returned if the organisation record
+// has not yet been retrieved from Xero.
+func (r *Reconciler) XeroShortCodeGet(ctx context.Context) (string, error) {
+	org, err := r.db.OrganisationGet(ctx)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", ErrSystem{
+			Detail: "db.OrganisationGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero organisation record",
+		}
+	}
+	return org.ShortCode, nil
+}
+
+// SalesforceInstanceURLGet returns the Salesforce instance url used for deep links
+// into Salesforce. An empty string is returned if the url has not been recorded.
+func (r *Reconciler) SalesforceInstanceURLGet(ctx context.Context) (string, error) {
+	instanceURL, err := r.db.SalesforceInstanceURLGet(ctx)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", ErrSystem{
+			Detail: "db.SalesforceInstanceURLGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Salesforce instance url",
+		}
+	}
+	return instanceURL, nil
+}
+
+// SalesforceInstanceURLUpsert records the Salesforce instance url.
+func (r *Reconciler) SalesforceInstanceURLUpsert(ctx context.Context, instanceURL string) error {
+	if err := r.db.SalesforceInstanceURLUpsert(ctx, instanceURL); err != nil {
+		return ErrSystem{
+			Detail: "db.SalesforceInstanceURLUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the Salesforce instance url",
+		}
+	}
+	return nil
+}
+
+// ToleranceGet returns the reconciliation tolerance.
+func (r *Reconciler) ToleranceGet(ctx context.Context) (db.Tolerance, error) {
+	tolerance, err := r.db.ToleranceGet(ctx)
+	if err != nil {
+		return tolerance, ErrSystem{
+			Detail: "db.ToleranceGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the reconciliation tolerance",
+		}
+	}
+	return tolerance, nil
+}
+
+// ToleranceUpsert records the reconciliation tolerance, returning a usage error if it
+// is invalid.
+func (r *Reconciler) ToleranceUpsert(ctx context.Context, tolerance db.Tolerance) error {
+	if err := tolerance.Validate(); err != nil {
+		return ErrUsage{
+			Detail: fmt.Sprintf("invalid tolerance: %v", err),
+			Msg:    "The tolerance amount may not be negative and the percentage must be between 0 and 100",
+		}
+	}
+	if err := r.db.ToleranceUpsert(ctx, tolerance); err != nil {
+		return ErrSystem{
+			Detail: "db.ToleranceUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the reconciliation tolerance",
+		}
+	}
+	return nil
+}
+
+// AccountsGet returns the Xero accounts with their donation account selection.
+func (r *Reconciler) AccountsGet(ctx context.Context) ([]db.Account, error) {
+	accounts, err := r.db.AccountsGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.AccountsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the accounts",
+		}
+	}
+	return accounts, nil
+}
+
+// DonationAccountsSet selects the accounts with codes as the donation accounts in place
+// of the configured donation account prefixes, returning a usage error if no account
+// is selected.
+func (r *Reconciler) DonationAccountsSet(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return ErrUsage{
+			Detail: "no donation accounts selected",
+			Msg:    "At least one account must be selected as a donation account",
+		}
+	}
+	if err := r.db.DonationAccountsSet(ctx, codes); err != nil {
+		return ErrSystem{
+			Detail: "db.DonationAccountsSet error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the donation accounts",
+		}
+	}
+	return nil
+}
+
+// DonationAccountsReset clears the donation accounts selection so that the configured
+// donation account prefixes apply.
+func (r *Reconciler) DonationAccountsReset(ctx context.Context) error {
+	if err := r.db.DonationAccountsSet(ctx, nil); err != nil {
+		return ErrSystem{
+			Detail: "db.DonationAccountsSet error",
+			Err:    err,
+			Msg:    "A problem was encountered clearing the donation accounts",
+		}
+	}
+	return nil
+}
+
+// DonationAccountsSelected returns the codes of the accounts selected as donation
+// accounts, which is empty if the configured donation account prefixes apply.
+func (r *Reconciler) DonationAccountsSelected() []string {
+	return r.db.DonationAccountsSelected()
+}
+
+// DonationAccountsRegexp returns the regular expression matching the codes of the
+// selected donation accounts, or configured if no accounts have been selected, such as
+// for filtering the records retrieved from Xero.
+func (r *Reconciler) DonationAccountsRegexp(configured *regexp.Regexp) *regexp.Regexp {
+	codes := r.db.DonationAccountsSelected()
+	if len(codes) == 0 {
+		return configured
+	}
+	return regexp.MustCompile(db.AccountCodesRegex(codes))
+}
+
+// AccountRulesGet returns the rules classifying line items by their description or
+// contact, in the order they are applied.
+func (r *Reconciler) AccountRulesGet(ctx context.Context) ([]db.AccountRule, error) {
+	rules, err := r.db.AccountRulesGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.AccountRulesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the account rules",
+		}
+	}
+	return rules, nil
+}
+
+// AccountRuleAdd adds a rule setting the account code of line items with a
+// description or contact matching pattern, returning a usage error if it is invalid.
+func (r *Reconciler) AccountRuleAdd(ctx context.Context, field, pattern, accountCode string) error {
+	err := r.db.AccountRuleInsert(ctx, field, pattern, accountCode)
+	if e, ok := errors.AsType[db.ErrValidation](err); ok {
+		return ErrUsage{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The %s %s", e.Field, e.Msg),
+		}
+	}
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.AccountRuleInsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the account rule",
+		}
+	}
+	return nil
+}
+
+// AccountRuleDelete removes an account rule.
+func (r *Reconciler) AccountRuleDelete(ctx context.Context, id int64) error {
+	if err := r.db.AccountRuleDelete(ctx, id); err != nil {
+		return ErrSystem{
+			Detail: "db.AccountRuleDelete error",
+			Err:    err,
+			Msg:    "A problem was encountered removing the account rule",
+		}
+	}
+	return nil
+}
+
+// donationLinksSync updates the donation links made from payout references after
+// donations, invoices or bank transactions are upserted or deleted.
+func (r *Reconciler) donationLinksSync(ctx context.Context) error {
+	removed, added, err := r.db.DonationLinksSync(ctx)
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.DonationLinksSync error",
+			Err:    err,
+			Msg:    "A problem was encountered updating the donation links",
+		}
+	}
+	r.log.Info("synchronised donation links", "removed", removed, "added", added)
+	return nil
+}
+
+// DonationSplitsGet retrieves the donation splits allocated to an invoice or bank
+// transaction.
+func (r *Reconciler) DonationSplitsGet(ctx context.Context, typer, id string) ([]db.DonationSplit, error) {
+	splits, err := r.db.DonationSplitsGet(ctx, typer, id)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.DonationSplitsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the donation splits",
+		}
+	}
+	return splits, nil
+}
+
+// DonationSplitUpsert allocates part of a donation to an invoice or bank transaction,
+// returning a usage error if the donation cannot be split as requested and ErrLocked
+// if the donation or record is dated in a locked period.
+func (r *Reconciler) DonationSplitUpsert(ctx context.Context, typer, id, donationID string, amount money.Amount) error {
+	return r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.periodLockCheck(ctx, []string{donationID}, []string{id}, nil, ""); err != nil {
+			return err
+		}
+		err := r.db.DonationSplitUpsert(ctx, donationID, typer, id, amount)
+		switch {
+		case errors.Is(err, db.ErrInvalidSplit):
+			return ErrUsage{
+				Detail: err.Error(),
+				Msg:    "The donation could not be split as its splits may not exceed the donation amount",
+			}
+		case err != nil:
+			return ErrSystem{
+				Detail: "db.DonationSplitUpsert error",
+				Err:    err,
+				Msg:    "A problem was encountered recording the donation split",
+			}
+		}
+		return nil
+	})
+}
+
+// DonationSplitDelete removes a donation split from an invoice or bank transaction,
+// returning ErrLocked if the record is dated in a locked period.
+func (r *Reconciler) DonationSplitDelete(ctx context.Context, typer, id string, splitID int64) error {
+	return r.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := r.periodLockCheck(ctx, nil, []string{id}, nil, ""); err != nil {
+			return err
+		}
+		if err := r.db.DonationSplitDelete(ctx, splitID, typer, id); err != nil {
+			return ErrSystem{
+				Detail: "db.DonationSplitDelete error",
+				Err:    err,
+				Msg:    "A problem was encountered removing the donation split",
+			}
+		}
+		return nil
+	})
+}
+
+// AnnotationsGet retrieves the notes and flags of an invoice, bank transaction or
+// donation, flags first.
+func (r *Reconciler) AnnotationsGet(ctx context.Context, typer, id string) ([]db.Annotation, error) {
+	annotations, err := r.db.AnnotationsGet(ctx, typer, id)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.AnnotationsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the notes",
+		}
+	}
+	return annotations, nil
+}
+
+// AnnotationAdd adds a note, or a flag if flag is set, to an invoice, bank transaction
+// or donation, returning a usage error if the note is empty or too long and a not
+// found error if the record does not exist.
+func (r *Reconciler) AnnotationAdd(ctx context.Context, typer, id, note string, flag bool) error {
+	err := r.db.AnnotationInsert(ctx, typer, id, note, flag)
+	if e, ok := errors.AsType[db.ErrValidation](err); ok {
+		return ErrUsage{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The note %s", e.Msg),
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The %s to note was not found", strings.ReplaceAll(typer, "-", " ")),
+		}
+	}
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.AnnotationInsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the note",
+		}
+	}
+	return nil
+}
+
+// AnnotationDelete removes a note or flag from an invoice, bank transaction or
+// donation.
+func (r *Reconciler) AnnotationDelete(ctx context.Context, typer, id string, annotationID int64) error {
+	if err := r.db.AnnotationDelete(ctx, annotationID, typer, id); err != nil {
+		return ErrSystem{
+			Detail: "db.AnnotationDelete error",
+			Err:    err,
+			Msg:    "A problem was encountered removing the note",
+		}
+	}
+	return nil
+}
+
+// AssignmentGet retrieves the team member to whom an invoice, bank transaction or
+// donation is assigned, which is empty if the record is not assigned.
+func (r *Reconciler) AssignmentGet(ctx context.Context, typer, id string) (string, error) {
+	assignment, err := r.db.AssignmentGet(ctx, typer, id)
+	if err != nil {
+		return "", ErrSystem{
+			Detail: "db.AssignmentGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the assignment",
+		}
+	}
+	return assignment.AssignedTo, nil
+}
+
+// AssignmentSet assigns an invoice, bank transaction or donation to the team member
+// assignedTo, or unassigns it if assignedTo is empty.
+func (r *Reconciler) AssignmentSet(ctx context.Context, typer, id, assignedTo string) error {
+	err := r.db.AssignmentUpsert(ctx, typer, id, assignedTo)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound{
+			Detail: err.Error(),
+			Msg:    fmt.Sprintf("The %s to assign was not found", strings.ReplaceAll(typer, "-", " ")),
+		}
+	}
+	if err != nil {
+		return ErrSystem{
+			Detail: "db.AssignmentUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the assignment",
+		}
+	}
+	return nil
+}
+
+// SavedSearchesGet retrieves the saved searches for a listing page, with the default
+// first.
+func (r *Reconciler) SavedSearchesGet(ctx context.Context, page string) ([]db.SavedSearch, error) {
+	searches, err := r.db.SavedSearchesGet(ctx, page)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.SavedSearchesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the saved searches",
+		}
+	}
+	return searches, nil
+}
+
+// SavedSearchUpsert saves the filter url parameters of a listing page under a name,
+// optionally as the default for the page.
+func (r *Reconciler) SavedSearchUpsert(ctx context.Context, page, name, params string, isDefault bool) error {
+	err := r.db.SavedSearchUpsert(ctx, page, name, params, isDefault)
+	switch {
+	case errors.Is(err, db.ErrInvalidSavedSearch):
+		return ErrUsage{
+			Detail: err.Error(),
+			Msg:    "Please provide a name for the saved search",
+		}
+	case err != nil:
+		return ErrSystem{
+			Detail: "db.SavedSearchUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered saving the search",
+		}
+	}
+	return nil
+}
+
+// SavedSearchDelete removes a saved search from a listing page.
+func (r *Reconciler) SavedSearchDelete(ctx context.Context, page string, id int64) error {
+	if err := r.db.SavedSearchDelete(ctx, page, id); err != nil {
+		return ErrSystem{
+			Detail: "db.SavedSearchDelete error",
+			Err:    err,
+			Msg:    "A problem was encountered removing the saved search",
+		}
+	}
+	return nil
+}
+
+// ContactDetailGet retrieves a contact and the invoices and bank transactions
+// associated with it.
+func (r *Reconciler) ContactDetailGet(
+	ctx context.Context,
+	contactID string,
+) (db.Contact, []db.ContactRecord, error) {
+
+	contact, err := r.db.ContactGet(ctx, contactID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return contact, nil, ErrSystem{
+			Detail: "db.ContactGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the contact details",
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return contact, nil, ErrNotFound{
+			Detail: "db.ContactGet not found error",
+			Msg:    "The requested contact was not found",
+		}
+	}
+	records, err := r.db.ContactRecordsGet(ctx, contactID)
+	if err != nil && err != sql.ErrNoRows {
+		return contact, nil, ErrSystem{
+			Detail: "db.ContactRecordsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the contact records",
+		}
+	}
+	return contact, records, nil
+}
+
 // InvoiceOrBankTransactionInfoGet returns the DFK (Invoice ID or Bank Transaction
 // Reference) and Date from an invoice or Bank Transaction identified by ID (a uuid).
 func (r *Reconciler) InvoiceOrBankTransactionInfoGet(ctx context.Context, typer string, id string) (string, time.Time, error) {
@@ -161,8 +738,8 @@ func (r *Reconciler) InvoiceOrBankTransactionInfoGet(ctx context.Context, typer
 	case "invoice":
 		invoice, _, err := r.db.InvoiceWRGet(ctx, id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				return "", rt, ErrUsage{
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", rt, ErrNotFound{
 					Detail: "InvoiceWRGet error",
 					Msg:    fmt.Sprintf("Invoice %q could not be found", id),
 				}
@@ -178,8 +755,8 @@ func (r *Reconciler) InvoiceOrBankTransactionInfoGet(ctx context.Context, typer
 	case "bank-transaction":
 		transaction, _, err := r.db.BankTransactionWRGet(ctx, id)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				return "", rt, ErrUsage{
+			if errors.Is(err, sql.ErrNoRows) {
+				return "", rt, ErrNotFound{
 					Detail: "BankTransactionWRGet error",
 					Msg:    fmt.Sprintf("Transaction %q could not be found", id),
 				}
@@ -202,7 +779,8 @@ func (r *Reconciler) InvoiceOrBankTransactionInfoGet(ctx context.Context, typer
 }
 
 // DonationsLinkUnlink links or unlinks donations over the API and then updates the
-// local record store accordingly.
+// local record store accordingly. The action is recorded as a pending action; see
+// LinkActionRun.
 func (r *Reconciler) DonationsLinkUnlink(
 	ctx context.Context,
 	sfClient SalesforceClient, // see types.go
@@ -210,43 +788,34 @@ func (r *Reconciler) DonationsLinkUnlink(
 	dataStartDate time.Time,
 	lastRefreshed time.Time,
 ) error {
+	return r.LinkActionRun(ctx, sfClient, nil, LinkAction{IDRefs: idRefs}, dataStartDate, lastRefreshed)
+}
 
-	if len(idRefs) == 0 {
-		return ErrUsage{
-			Detail: "LinkUnlinkDonations error",
-			Msg:    "no records were provided to link/unlink",
-		}
-	}
+// InvoiceReferenceUpdate writes reference, normally the payout reference of the
+// donations linked to the invoice, to the Reference field of the Xero invoice with
+// invoiceID, and upserts the updated invoice.
+func (r *Reconciler) InvoiceReferenceUpdate(ctx context.Context, xeroClient XeroClient, invoiceID, reference string) error {
 
-	// Update the donations. If it is an unlink action, update the dfk with "", else
-	// the actual dfk from the bank transaction or invoice. The form contents (many
-	// salesforce IDs given the same DFK reference) must be translated to
-	// a slice of salesforce.IDRef, hence the use of `salesforce.IDRef`s.
-	_, err := sfClient.BatchUpdateOpportunityRefs(ctx, idRefs, false)
-	if err != nil {
-		return ErrSystem{
-			Detail: "BatchUpdateOpportunityRefs error",
-			Err:    err,
-			Msg:    "A problem was encountered batch updating salesforce references",
+	if invoiceID == "" || reference == "" {
+		return ErrUsage{
+			Detail: "InvoiceReferenceUpdate error",
+			Msg:    "an invoice and reference must be provided to update the invoice reference",
 		}
 	}
 
-	// Upsert the updated opportunities.
-	// The refresh window is rough; double upserts shouldn't be a major issue.
-	r.log.Info(fmt.Sprintf("GetOpportunities %s %s", dataStartDate.Format(time.DateTime), lastRefreshed.Format(time.DateTime)))
-	updatedDonations, err := sfClient.GetOpportunities(ctx, dataStartDate, lastRefreshed)
+	invoice, err := xeroClient.UpdateInvoiceReference(ctx, invoiceID, reference)
 	if err != nil {
 		return ErrSystem{
-			Detail: "GetOpportunities error",
+			Detail: "UpdateInvoiceReference error",
 			Err:    err,
-			Msg:    "A problem was encountered retrieving updated salesforce records",
+			Msg:    "A problem was encountered updating the Xero invoice reference",
 		}
 	}
-	if err := r.db.UpsertDonations(ctx, updatedDonations); err != nil {
+	if err := r.db.InvoicesUpsert(ctx, []xero.Invoice{invoice}); err != nil {
 		return ErrSystem{
-			Detail: "UpsertDonations error",
+			Detail: "InvoicesUpsert error",
 			Err:    err,
-			Msg:    "A problem was encountered upserting updated salesforce records",
+			Msg:    "A problem was encountered upserting the updated Xero invoice",
 		}
 	}
 	return nil
@@ -254,17 +823,29 @@ func (r *Reconciler) DonationsLinkUnlink(
 
 // RefreshXeroResults reports the organisation ShortCode and number of accounts
 // retrieved and upserted in AccountsNo (when doing a full refresh), together with the
-// number of invoices and bank transactions retrieved and upserted.
+// number of invoices and bank transactions retrieved and upserted. RejectedNo is the
+// number of records which failed validation or could not be upserted, and were
+// recorded as import errors, and Warnings reports the records with missing or unreadable dates.
+// Attached reports that the results are those of a refresh which was already running,
+// which started at Started.
 type RefreshXeroResults struct {
 	FullRefresh    bool
+	Attached       bool
+	Started        time.Time
 	ShortCode      string
 	AccountsNo     int
+	ContactsNo     int
 	InvoicesNo     int // the filtered invoices
 	TransactionsNo int // the filtered transactions
+	RejectedNo     int
+	Warnings       []SyncWarning
 }
 
 // XeroRecordsRefresh retrieves remote records and updates the local store accordingly.
-// If a fullRefresh is not required, not all remote records are retrieved.
+// If a fullRefresh is not required, not all remote records are retrieved. If a Xero
+// refresh with the same parameters is already running, its results are returned once
+// it finishes, marked as Attached, rather than the records being refreshed again, while
+// a refresh with other parameters is waited for before this one runs.
 func (r *Reconciler) XeroRecordsRefresh(
 	ctx context.Context,
 	xeroClient XeroClient,
@@ -274,9 +855,40 @@ func (r *Reconciler) XeroRecordsRefresh(
 	fullRefresh bool,
 ) (*RefreshXeroResults, error) {
 
-	results := &RefreshXeroResults{
+	key := fmt.Sprintf("%s %s %s %t", dataStartDate, lastRefresh, accountsRegexp, fullRefresh)
+	res, attached, err := r.syncs.run(ctx, "xero", key, func(ctx context.Context) (any, error) {
+		return r.xeroRecordsRefresh(ctx, xeroClient, dataStartDate, lastRefresh, accountsRegexp, fullRefresh)
+	})
+	results, _ := res.(*RefreshXeroResults)
+	if results == nil {
+		results = &RefreshXeroResults{FullRefresh: fullRefresh}
+	}
+	if attached {
+		shared := *results
+		shared.Attached = true
+		results = &shared
+	}
+	return results, err
+}
+
+// xeroRecordsRefresh refreshes the Xero records for XeroRecordsRefresh.
+func (r *Reconciler) xeroRecordsRefresh(
+	ctx context.Context,
+	xeroClient XeroClient,
+	dataStartDate time.Time,
+	lastRefresh time.Time,
+	accountsRegexp *regexp.Regexp,
+	fullRefresh bool,
+) (results *RefreshXeroResults, err error) {
+
+	results = &RefreshXeroResults{
 		FullRefresh: fullRefresh,
+		Started:     time.Now(),
 	}
+	run := r.syncRunStart(ctx, "xero", fullRefresh, xeroClient)
+	defer func() {
+		run.finish(ctx, err)
+	}()
 
 	// Organisation.
 	if fullRefresh {
@@ -309,7 +921,15 @@ func (r *Reconciler) XeroRecordsRefresh(
 				Msg:    "A problem was encountered retrieving the Xero accounts records",
 			}
 		}
-		if err := r.db.AccountsUpsert(ctx, accounts); err != nil {
+		fetched := len(accounts)
+		accounts, rejected, err := validRecords(ctx, r, "xero", "account", accounts, validateAccount)
+		results.RejectedNo += rejected
+		if err != nil {
+			return results, err
+		}
+		accounts, failed, err := upsertRecords(ctx, r, "xero", "account", accounts, func(a xero.Account) string { return a.AccountID }, r.db.AccountsUpsert)
+		results.RejectedNo += failed
+		if err != nil {
 			return results, ErrSystem{
 				Detail: "xero GetOrganisation error",
 				Err:    err,
@@ -317,19 +937,25 @@ func (r *Reconciler) XeroRecordsRefresh(
 			}
 		}
 		results.AccountsNo = len(accounts)
+		run.entity(ctx, "account", fetched, len(accounts), rejected+failed)
+		results.Warnings = append(results.Warnings, xeroDateWarnings("account", accounts)...)
 		r.log.Info("retrieved and upserted accounts", "records", results.AccountsNo)
 	}
 
 	// Bank Transactions
-	transactions, err := xeroClient.GetBankTransactions(ctx, dataStartDate, lastRefresh, accountsRegexp)
+	transactions, err := xeroBankTransactionsGet(ctx, xeroClient, dataStartDate, lastRefresh, accountsRegexp)
 	if err != nil {
-		return results, ErrSystem{
-			Detail: "xero GetBankTransactions error",
-			Err:    err,
-			Msg:    "A problem was encountered retrieving the Xero bank transactions",
-		}
+		return results, err
+	}
+	fetched := len(transactions)
+	transactions, rejected, err := validRecords(ctx, r, "xero", "bank-transaction", transactions, validateBankTransaction)
+	results.RejectedNo += rejected
+	if err != nil {
+		return results, err
 	}
-	if err = r.db.BankTransactionsUpsert(ctx, transactions); err != nil {
+	transactions, failed, err := upsertRecords(ctx, r, "xero", "bank-transaction", transactions, func(bt xero.BankTransaction) string { return bt.BankTransactionID }, r.db.BankTransactionsUpsert)
+	results.RejectedNo += failed
+	if err != nil {
 		return results, ErrSystem{
 			Detail: "xero BankTransactionsUpsert error",
 			Err:    err,
@@ -337,6 +963,8 @@ func (r *Reconciler) XeroRecordsRefresh(
 		}
 	}
 	results.TransactionsNo = len(transactions)
+	run.entity(ctx, "bank-transaction", fetched, len(transactions), rejected+failed)
+	results.Warnings = append(results.Warnings, xeroDateWarnings("bank transaction", transactions)...)
 	r.log.Info("retrieved and upserted bank transactions", "records", results.TransactionsNo)
 
 	// Invoices
@@ -348,7 +976,15 @@ func (r *Reconciler) XeroRecordsRefresh(
 			Msg:    "A problem was encountered retrieving the Xero invoices",
 		}
 	}
-	if err := r.db.InvoicesUpsert(ctx, invoices); err != nil {
+	fetched = len(invoices)
+	invoices, rejected, err = validRecords(ctx, r, "xero", "invoice", invoices, validateInvoice)
+	results.RejectedNo += rejected
+	if err != nil {
+		return results, err
+	}
+	invoices, failed, err = upsertRecords(ctx, r, "xero", "invoice", invoices, func(inv xero.Invoice) string { return inv.InvoiceID }, r.db.InvoicesUpsert)
+	results.RejectedNo += failed
+	if err != nil {
 		return results, ErrSystem{
 			Detail: "xero InvoicesUpsert error",
 			Err:    err,
@@ -356,19 +992,66 @@ func (r *Reconciler) XeroRecordsRefresh(
 		}
 	}
 	results.InvoicesNo = len(invoices)
+	run.entity(ctx, "invoice", fetched, len(invoices), rejected+failed)
+	results.Warnings = append(results.Warnings, xeroDateWarnings("invoice", invoices)...)
 	r.log.Info("retrieved and upserted invoices", "records", results.InvoicesNo)
 
+	// Contacts
+	contacts, err := xeroClient.GetContacts(ctx, lastRefresh)
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "xero GetContacts error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero contacts",
+		}
+	}
+	fetched = len(contacts)
+	contacts, rejected, err = validRecords(ctx, r, "xero", "contact", contacts, validateContact)
+	results.RejectedNo += rejected
+	if err != nil {
+		return results, err
+	}
+	contacts, failed, err = upsertRecords(ctx, r, "xero", "contact", contacts, func(c xero.Contact) string { return c.ContactID }, r.db.ContactsUpsert)
+	results.RejectedNo += failed
+	if err != nil {
+		return results, ErrSystem{
+			Detail: "xero ContactsUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered upserting the Xero contacts",
+		}
+	}
+	results.ContactsNo = len(contacts)
+	run.entity(ctx, "contact", fetched, len(contacts), rejected+failed)
+	results.Warnings = append(results.Warnings, xeroDateWarnings("contact", contacts)...)
+	r.log.Info("retrieved and upserted contacts", "records", results.ContactsNo)
+	r.logSyncWarnings(results.Warnings)
+
+	if err := r.donationLinksSync(ctx); err != nil {
+		return results, err
+	}
 	return results, nil
 }
 
 // RefreshSalesforceResults reports the refresh status and number of records retrieved
-// and upserted as the result of a SalesforceRecordsRefresh call.
+// and upserted as the result of a SalesforceRecordsRefresh call, the number of records
+// which failed validation or could not be upserted, and were recorded as import
+// errors, in RejectedNo, and the donations with missing or unreadable dates in
+// Warnings. Attached reports that the results are those of a refresh which was already
+// running, which started at Started.
 type RefreshSalesforceResults struct {
 	FullRefresh bool
+	Attached    bool
+	Started     time.Time
 	RecordsNo   int
+	RejectedNo  int
+	Warnings    []SyncWarning
 }
 
-// SalesforceRecordsRefresh retrieves remote records and updates the local store accordingly.
+// SalesforceRecordsRefresh retrieves remote records and updates the local store
+// accordingly. If a Salesforce refresh with the same parameters is already running,
+// its results are returned once it finishes, marked as Attached, rather than the
+// records being refreshed again, while a refresh with other parameters is waited for
+// before this one runs.
 func (r *Reconciler) SalesforceRecordsRefresh(
 	ctx context.Context,
 	sfClient SalesforceClient,
@@ -376,12 +1059,47 @@ func (r *Reconciler) SalesforceRecordsRefresh(
 	lastRefresh time.Time,
 ) (*RefreshSalesforceResults, error) {
 
-	results := &RefreshSalesforceResults{
+	key := fmt.Sprintf("%s %s", dataStartDate, lastRefresh)
+	res, attached, err := r.syncs.run(ctx, "salesforce", key, func(ctx context.Context) (any, error) {
+		return r.salesforceRecordsRefresh(ctx, sfClient, dataStartDate, lastRefresh)
+	})
+	results, _ := res.(*RefreshSalesforceResults)
+	if results == nil {
+		results = &RefreshSalesforceResults{FullRefresh: lastRefresh.IsZero()}
+	}
+	if attached {
+		shared := *results
+		shared.Attached = true
+		results = &shared
+	}
+	return results, err
+}
+
+// salesforceRecordsRefresh refreshes the Salesforce records for SalesforceRecordsRefresh.
+func (r *Reconciler) salesforceRecordsRefresh(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	dataStartDate time.Time,
+	lastRefresh time.Time,
+) (results *RefreshSalesforceResults, err error) {
+
+	results = &RefreshSalesforceResults{
 		FullRefresh: lastRefresh.IsZero(),
+		Started:     time.Now(),
 	}
+	run := r.syncRunStart(ctx, "salesforce", results.FullRefresh, sfClient)
+	defer func() {
+		run.finish(ctx, err)
+	}()
 
 	// Donations.
 	donations, err := sfClient.GetOpportunities(ctx, dataStartDate, lastRefresh)
+	if e, ok := errors.AsType[salesforce.ErrTooManyRecords](err); ok {
+		return results, ErrUsage{
+			Detail: "salesforce GetOpportunities error",
+			Msg:    fmt.Sprintf("The Salesforce query matched %d records, more than the maximum of %d set by salesforce.max_records", e.Total, e.Max),
+		}
+	}
 	if err != nil {
 		return results, ErrSystem{
 			Detail: "salesforce GetOpportunities error",
@@ -389,8 +1107,15 @@ func (r *Reconciler) SalesforceRecordsRefresh(
 			Msg:    "A problem was encountered retrieving the Salesforce records",
 		}
 	}
+	fetched := len(donations)
+	donations, results.RejectedNo, err = validRecords(ctx, r, "salesforce", "donation", donations, validateDonation)
+	if err != nil {
+		return results, err
+	}
 
-	if err := r.db.UpsertDonations(ctx, donations); err != nil {
+	donations, failed, err := upsertRecords(ctx, r, "salesforce", "donation", donations, func(d salesforce.Donation) string { return d.ID }, r.db.UpsertDonations)
+	results.RejectedNo += failed
+	if err != nil {
 		return results, ErrSystem{
 			Detail: "salesforce UpsertDonations error",
 			Err:    err,
@@ -398,8 +1123,14 @@ func (r *Reconciler) SalesforceRecordsRefresh(
 		}
 	}
 	results.RecordsNo = len(donations)
+	run.entity(ctx, "donation", fetched, len(donations), results.RejectedNo)
+	results.Warnings = salesforceDateWarnings(donations)
 	r.log.Info("retrieved and upserted donations", "records", results.RecordsNo)
+	r.logSyncWarnings(results.Warnings)
 
+	if err := r.donationLinksSync(ctx); err != nil {
+		return results, err
+	}
 	return results, nil
 
 }
@@ -6,15 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/rorycl/reconciler/apiclients/salesforce"
 	"github.com/rorycl/reconciler/apiclients/xero"
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
 	mounts "github.com/rorycl/reconciler/internal/mounts"
 )
 
@@ -37,12 +40,41 @@ func (mxc *mockXeroClient) GetAccounts(ctx context.Context, ifModifiedSince time
 func (mxc *mockXeroClient) GetBankTransactions(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.BankTransaction, error) {
 	mxc.getCount++
 	mxc.log.Info(fmt.Sprintf("GetBankTransactions %d", mxc.getCount))
-	return []xero.BankTransaction{{BankTransactionID: fmt.Sprintf("btId-%d", mxc.getCount)}}, nil
+	return []xero.BankTransaction{{
+		BankTransactionID: fmt.Sprintf("btId-%d", mxc.getCount),
+		Date:              xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}}, nil
 }
 func (mxc *mockXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
 	mxc.getCount++
 	mxc.log.Info(fmt.Sprintf("Invoices %d", mxc.getCount))
-	return []xero.Invoice{{InvoiceID: fmt.Sprintf("iId-%d", mxc.getCount)}}, nil
+	return []xero.Invoice{{
+		InvoiceID: fmt.Sprintf("iId-%d", mxc.getCount),
+		Date:      xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}}, nil
+}
+func (mxc *mockXeroClient) GetContacts(ctx context.Context, ifModifiedSince time.Time) ([]xero.Contact, error) {
+	mxc.getCount++
+	mxc.log.Info(fmt.Sprintf("Contacts %d", mxc.getCount))
+	return []xero.Contact{{ContactID: fmt.Sprintf("cId-%d", mxc.getCount), Updated: xero.XeroDateTime{Invalid: "yesterday"}}}, nil
+}
+func (mxc *mockXeroClient) UpdateInvoiceReference(ctx context.Context, invoiceID, reference string) (xero.Invoice, error) {
+	mxc.getCount++
+	mxc.log.Info(fmt.Sprintf("UpdateInvoiceReference %d", mxc.getCount))
+	return xero.Invoice{
+		InvoiceID:     invoiceID,
+		Type:          "ACCREC",
+		InvoiceNumber: "INV-2025-102",
+		Contact:       "Updated Contact",
+		Date:          xero.XeroDateTime{Time: time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC)},
+		Updated:       xero.XeroDateTime{Time: time.Now().UTC()},
+		Status:        "PAID",
+		Reference:     reference,
+		Total:         money.FromFloat(100),
+		LineItems: []xero.LineItem{
+			{LineItemID: "li-" + invoiceID, Description: "Donation", AccountCode: "5301", Quantity: 1, UnitAmount: money.FromFloat(100), LineAmount: money.FromFloat(100)},
+		},
+	}, nil
 }
 
 // mockXeroErrorClient raises an error for GetOrganisation.
@@ -126,6 +158,10 @@ func TestReconcilerRefreshXeroRecords(t *testing.T) {
 	if shortCode != "BCD-1" {
 		t.Errorf("shortcode got %s want %s", shortCode, "BCD-1")
 	}
+	shortCode, err = reconciler.XeroShortCodeGet(ctx)
+	if err != nil || shortCode != "BCD-1" {
+		t.Errorf("XeroShortCodeGet got %q, %v want %q", shortCode, err, "BCD-1")
+	}
 	if results.ShortCode != "BCD-1" {
 		t.Errorf("results xero-shortcode got %s want %s", results.ShortCode, "BCD-1")
 	}
@@ -133,6 +169,17 @@ func TestReconcilerRefreshXeroRecords(t *testing.T) {
 		t.Errorf("got %t want %t for full refresh", got, want)
 	}
 
+	// The mocked contact has an unreadable updated date.
+	if got, want := results.RejectedNo, 0; got != want {
+		t.Errorf("got %d rejected records want %d", got, want)
+	}
+	wantWarnings := []SyncWarning{
+		{Source: "xero", Record: "contact", ID: "cId-5", Field: "UpdatedDateUTC", Value: "yesterday"},
+	}
+	if diff := cmp.Diff(wantWarnings, results.Warnings); diff != "" {
+		t.Errorf("warnings mismatch (-want +got):\n%s", diff)
+	}
+
 	// Run a partial update, checking only bank transactions and invoices are updated.
 	xeroClient.getCount = 10
 
@@ -159,6 +206,14 @@ func TestReconcilerRefreshXeroRecords(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to get row from invoices: %v", err)
 	}
+	if got, want := results.ContactsNo, 1; got != want {
+		t.Errorf("got %d want %d for contacts", got, want)
+	}
+	var contactID string
+	err = testDB.Get(&contactID, "SELECT id FROM contacts WHERE id = 'cId-13'")
+	if err != nil {
+		t.Fatalf("failed to get row from contacts: %v", err)
+	}
 
 	xeroErrorClient := &mockXeroErrorClient{
 		mockXeroClient: mockXeroClient{log: slog.Default()},
@@ -192,7 +247,32 @@ type mockSalesforceClient struct {
 func (msc *mockSalesforceClient) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error) {
 	msc.getCount++
 	msc.log.Info(fmt.Sprintf("GetOpportunities %d", msc.getCount))
-	return []salesforce.Donation{{CoreFields: salesforce.CoreFields{ID: fmt.Sprintf("ID-%d", msc.getCount)}}}, nil
+	return []salesforce.Donation{{CoreFields: salesforce.CoreFields{
+		ID:        fmt.Sprintf("ID-%d", msc.getCount),
+		CloseDate: salesforce.SalesforceDate{Time: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}}}, nil
+}
+
+func (msc *mockSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	var donations []salesforce.Donation
+	for _, id := range ids {
+		donations = append(donations, salesforce.Donation{CoreFields: salesforce.CoreFields{
+			ID:        id,
+			CloseDate: salesforce.SalesforceDate{Time: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		}})
+	}
+	return donations, nil
+}
+
+func (msc *mockSalesforceClient) PreviewOpportunities(ctx context.Context, fromDate time.Time, limit int) ([]salesforce.Donation, error) {
+	return []salesforce.Donation{{
+		CoreFields:       salesforce.CoreFields{ID: "ID-preview", Name: "preview"},
+		AdditionalFields: map[string]any{"Stage": "Closed Won"},
+	}}, nil
+}
+
+func (msc *mockSalesforceClient) ValidateFieldMappings(ctx context.Context) ([]string, error) {
+	return nil, nil
 }
 
 func (msc *mockSalesforceClient) BatchUpdateOpportunityRefs(ctx context.Context, idRefs []salesforce.IDRef, allOrNone bool) (salesforce.CollectionsUpdateResponse, error) {
@@ -240,6 +320,9 @@ func TestReconcilerRefreshSalesforceRecords(t *testing.T) {
 	if got, want := results.RecordsNo, 1; got != want {
 		t.Errorf("got %d want %d records", got, want)
 	}
+	if results.RejectedNo != 0 || len(results.Warnings) != 0 {
+		t.Errorf("got %d rejected records and warnings %v want none", results.RejectedNo, results.Warnings)
+	}
 
 }
 
@@ -306,6 +389,19 @@ func TestReconcilerDBComponents(t *testing.T) {
 	if got, want := reconciler.DBPath(), reconciler.db.Path; got != want {
 		t.Errorf("db path got %s want %s", got, want)
 	}
+
+	ctx := context.Background()
+	snapshot := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := reconciler.SnapshotExport(ctx, snapshot); err != nil {
+		t.Fatalf("snapshot export error: %v", err)
+	}
+	if err := reconciler.SnapshotImport(ctx, snapshot); err != nil {
+		t.Fatalf("snapshot import error: %v", err)
+	}
+	if _, ok := errors.AsType[ErrUsage](reconciler.SnapshotImport(ctx, "reconciler_test.go")); !ok {
+		t.Error("expected a usage error importing an invalid snapshot")
+	}
+
 	err := reconciler.Close()
 	if err != nil {
 		t.Fatalf("unexpected reconciler close error: %v", err)
@@ -332,9 +428,11 @@ func TestReconcilerDBListings(t *testing.T) {
 					"All",
 					time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 					time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
-					"", // search
-					20, // pagelen
-					0,  // offset
+					"",             // search
+					"",             // assignee
+					db.SortOrder{}, // sort
+					20,             // pagelen
+					0,              // offset
 				)
 				return len(recs), err
 			},
@@ -349,6 +447,8 @@ func TestReconcilerDBListings(t *testing.T) {
 					time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 					time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
 					"no search results", // search
+					"",                  // assignee
+					db.SortOrder{},      // sort
 					20,                  // pagelen
 					0,                   // offset
 				)
@@ -363,9 +463,11 @@ func TestReconcilerDBListings(t *testing.T) {
 					"All",
 					time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 					time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
-					"", // search
-					20, // pagelen
-					0,  // offset
+					"",             // search
+					"",             // assignee
+					db.SortOrder{}, // sort
+					20,             // pagelen
+					0,              // offset
 				)
 				return len(recs), err
 			},
@@ -380,6 +482,8 @@ func TestReconcilerDBListings(t *testing.T) {
 					time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 					time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
 					"no transactions found", // search
+					"",                      // assignee
+					db.SortOrder{},          // sort
 					20,                      // pagelen
 					0,                       // offset
 				)
@@ -393,11 +497,13 @@ func TestReconcilerDBListings(t *testing.T) {
 					t.Context(),
 					time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
 					time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
-					"All", // linkage
-					"",    // payout reference
-					"",    // search
-					20,    // pagelen
-					0,     // offset
+					"All",          // linkage
+					"",             // payout reference
+					"",             // search
+					"",             // assignee
+					db.SortOrder{}, // sort
+					20,             // pagelen
+					0,              // offset
 				)
 				return len(recs), err
 			},
@@ -413,6 +519,8 @@ func TestReconcilerDBListings(t *testing.T) {
 					"All",          // linkage
 					"no ref found", // payout reference
 					"",             // search
+					"",             // assignee
+					db.SortOrder{}, // sort
 					20,             // pagelen
 					0,              // offset
 				)
@@ -474,7 +582,7 @@ func TestReconcilerDBDetail(t *testing.T) {
 				_, _, err := reconciler.InvoiceDetailGet(t.Context(), "inv-does-not-exist")
 				return "", err
 			},
-			expectedErr: ErrUsage{Msg: "The requested invoice was not found"},
+			expectedErr: ErrNotFound{Msg: "The requested invoice was not found"},
 		},
 		{
 			proc: func() (string, error) {
@@ -492,7 +600,204 @@ func TestReconcilerDBDetail(t *testing.T) {
 				_, _, err := reconciler.TransactionDetailGet(t.Context(), "bt-does-not-exist")
 				return "", err
 			},
-			expectedErr: ErrUsage{Msg: "The requested transaction was not found"},
+			expectedErr: ErrNotFound{Msg: "The requested transaction was not found"},
+		},
+		{
+			proc: func() (string, error) {
+				c, records, err := reconciler.ContactDetailGet(t.Context(), "con-jg")
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s %d", c.Name, len(records)), err
+			},
+			expectedInfo: "JustGiving 4",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				_, _, err := reconciler.ContactDetailGet(t.Context(), "con-does-not-exist")
+				return "", err
+			},
+			expectedErr: ErrNotFound{Msg: "The requested contact was not found"},
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.SalesforceInstanceURLUpsert(t.Context(), "https://example.my.salesforce.com"); err != nil {
+					return "", err
+				}
+				return reconciler.SalesforceInstanceURLGet(t.Context())
+			},
+			expectedInfo: "https://example.my.salesforce.com",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.ToleranceUpsert(t.Context(), db.Tolerance{Percent: 2.5}); err != nil {
+					return "", err
+				}
+				tolerance, err := reconciler.ToleranceGet(t.Context())
+				return fmt.Sprintf("%s %g", tolerance.Amount, tolerance.Percent), err
+			},
+			expectedInfo: "0.00 2.5",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.ToleranceUpsert(t.Context(), db.Tolerance{Percent: 200})
+			},
+			expectedErr: ErrUsage{Msg: "The tolerance amount may not be negative and the percentage must be between 0 and 100"},
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.DonationSplitUpsert(t.Context(), "invoice", "inv-unrec-04", "sf-opp-018", money.FromFloat(20)); err != nil {
+					return "", err
+				}
+				splits, err := reconciler.DonationSplitsGet(t.Context(), "invoice", "inv-unrec-04")
+				if err != nil || len(splits) != 1 {
+					return "", err
+				}
+				if err := reconciler.DonationSplitDelete(t.Context(), "invoice", "inv-unrec-04", splits[0].ID); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s %s of %s", splits[0].DonationID, splits[0].Amount, splits[0].DonationAmount), nil
+			},
+			expectedInfo: "sf-opp-018 20.00 of 50.00",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.DonationSplitUpsert(t.Context(), "invoice", "inv-unrec-04", "sf-opp-018", money.FromFloat(50.01))
+			},
+			expectedErr: ErrUsage{Msg: "The donation could not be split as its splits may not exceed the donation amount"},
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.AnnotationAdd(t.Context(), "donation", "sf-opp-018", "query with fundraising team", true); err != nil {
+					return "", err
+				}
+				annotations, err := reconciler.AnnotationsGet(t.Context(), "donation", "sf-opp-018")
+				if err != nil || len(annotations) != 1 {
+					return "", err
+				}
+				if err := reconciler.AnnotationDelete(t.Context(), "donation", "sf-opp-018", annotations[0].ID); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s %t", annotations[0].Note, annotations[0].Flag), nil
+			},
+			expectedInfo: "query with fundraising team true",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.AnnotationAdd(t.Context(), "invoice", "inv-002", " ", false)
+			},
+			expectedErr: ErrUsage{Msg: "The note may not be empty"},
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.AnnotationAdd(t.Context(), "bank-transaction", "bt-does-not-exist", "a note", false)
+			},
+			expectedErr: ErrNotFound{Msg: "The bank transaction to note was not found"},
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.AssignmentSet(t.Context(), "invoice", "inv-002", "Alex"); err != nil {
+					return "", err
+				}
+				assignedTo, err := reconciler.AssignmentGet(t.Context(), "invoice", "inv-002")
+				if err != nil {
+					return "", err
+				}
+				if err := reconciler.AssignmentSet(t.Context(), "invoice", "inv-002", ""); err != nil {
+					return "", err
+				}
+				return assignedTo, nil
+			},
+			expectedInfo: "Alex",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.AssignmentSet(t.Context(), "donation", "sf-opp-does-not-exist", "Alex")
+			},
+			expectedErr: ErrNotFound{Msg: "The donation to assign was not found"},
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.AccountRuleAdd(t.Context(), "contact", "paypal giving fund", "5501"); err != nil {
+					return "", err
+				}
+				rules, err := reconciler.AccountRulesGet(t.Context())
+				if err != nil || len(rules) != 1 {
+					return "", err
+				}
+				if err := reconciler.AccountRuleDelete(t.Context(), rules[0].ID); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s %s %s", rules[0].Field, rules[0].Pattern, rules[0].AccountCode), nil
+			},
+			expectedInfo: "contact paypal giving fund 5501",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.DonationAccountsSet(t.Context(), []string{"5501"}); err != nil {
+					return "", err
+				}
+				matched := reconciler.DonationAccountsRegexp(regexp.MustCompile("^57")).MatchString("5501")
+				if err := reconciler.DonationAccountsReset(t.Context()); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%t %d", matched, len(reconciler.DonationAccountsSelected())), nil
+			},
+			expectedInfo: "true 0",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.DonationAccountsSet(t.Context(), nil)
+			},
+			expectedErr: ErrUsage{Msg: "At least one account must be selected as a donation account"},
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.AccountRuleAdd(t.Context(), "description", "giving [", "5501")
+			},
+			expectedErr: ErrUsage{Msg: "The rule pattern is not a valid regular expression: error parsing regexp: missing closing ]: `[`"},
+		},
+		{
+			proc: func() (string, error) {
+				results, err := reconciler.Search(t.Context(), "spring campaign", 10)
+				if err != nil || len(results) != 1 {
+					return "", err
+				}
+				return results[0].RecordType + " " + results[0].RecordID, nil
+			},
+			expectedInfo: "bank-transaction bt-001",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				if err := reconciler.SavedSearchUpsert(t.Context(), "donations", "Unlinked", "status=NotLinked", true); err != nil {
+					return "", err
+				}
+				searches, err := reconciler.SavedSearchesGet(t.Context(), "donations")
+				if err != nil || len(searches) != 1 {
+					return "", err
+				}
+				if err := reconciler.SavedSearchDelete(t.Context(), "donations", searches[0].ID); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s %s %t", searches[0].Name, searches[0].Params, searches[0].IsDefault), nil
+			},
+			expectedInfo: "Unlinked status=NotLinked true",
+			expectedErr:  nil,
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.SavedSearchUpsert(t.Context(), "donations", "", "status=NotLinked", false)
+			},
+			expectedErr: ErrUsage{Msg: "Please provide a name for the saved search"},
 		},
 		{
 			proc: func() (string, error) {
@@ -521,7 +826,7 @@ func TestReconcilerDBDetail(t *testing.T) {
 				_, _, err := reconciler.InvoiceOrBankTransactionInfoGet(t.Context(), "bank-transaction", "invalid")
 				return "", err
 			},
-			expectedErr: ErrUsage{Msg: "Transaction \"invalid\" could not be found"},
+			expectedErr: ErrNotFound{Msg: "Transaction \"invalid\" could not be found"},
 		},
 		{
 			proc: func() (string, error) {
@@ -530,6 +835,26 @@ func TestReconcilerDBDetail(t *testing.T) {
 			},
 			expectedErr: ErrSystem{Msg: "InvoiceOrBankTransactionInfoGet error"},
 		},
+		{
+			proc: func() (string, error) {
+				xeroClient := &mockXeroClient{log: slog.Default()}
+				if err := reconciler.InvoiceReferenceUpdate(t.Context(), xeroClient, "inv-002", "PAYOUT-102"); err != nil {
+					return "", err
+				}
+				i, _, err := reconciler.InvoiceDetailGet(t.Context(), "inv-002")
+				if err != nil || i.Reference == nil {
+					return "", err
+				}
+				return *i.Reference, nil
+			},
+			expectedInfo: "PAYOUT-102",
+		},
+		{
+			proc: func() (string, error) {
+				return "", reconciler.InvoiceReferenceUpdate(t.Context(), &mockXeroClient{log: slog.Default()}, "inv-002", "")
+			},
+			expectedErr: ErrUsage{Msg: "an invoice and reference must be provided to update the invoice reference"},
+		},
 	}
 
 	for ii, tt := range tests {
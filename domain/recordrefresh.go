@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rorycl/reconciler/apiclients/xero"
+)
+
+// RecordRefresh retrieves a single invoice, bank transaction or donation, identified by
+// typer ("invoice", "bank-transaction" or "donation") and id, and upserts it so that a
+// remote correction is picked up without a full refresh. A donation no longer found in
+// Salesforce is removed. Invoices and bank transactions require the xeroClient, and
+// donations the sfClient.
+func (r *Reconciler) RecordRefresh(
+	ctx context.Context,
+	xeroClient XeroClient,
+	sfClient SalesforceClient,
+	typer string,
+	id string,
+) error {
+
+	var refresh func(context.Context) error
+	source := "xero"
+	switch typer {
+	case "invoice":
+		refresh = func(ctx context.Context) error { return r.invoiceRefresh(ctx, xeroClient, id) }
+	case "bank-transaction":
+		refresh = func(ctx context.Context) error { return r.bankTransactionRefresh(ctx, xeroClient, id) }
+	case "donation":
+		source = "salesforce"
+		refresh = func(ctx context.Context) error { return r.donationRefresh(ctx, sfClient, id) }
+	default:
+		return ErrUsage{
+			Detail: fmt.Sprintf("invalid record type %q", typer),
+			Msg:    fmt.Sprintf("%s records cannot be refreshed", typer),
+		}
+	}
+
+	// The record is not upserted during a refresh of its source.
+	_, _, err := r.syncs.run(ctx, source, "", func(ctx context.Context) (any, error) {
+		if err := refresh(ctx); err != nil {
+			return nil, err
+		}
+		r.log.Info("refreshed record", "type", typer, "id", id)
+		return nil, r.donationLinksSync(ctx)
+	})
+	return err
+}
+
+// invoiceRefresh retrieves and upserts a single invoice.
+func (r *Reconciler) invoiceRefresh(ctx context.Context, xeroClient XeroClient, id string) error {
+	getter, ok := xeroClient.(XeroInvoiceGetter)
+	if !ok {
+		return ErrUsage{
+			Detail: "xero client cannot retrieve a single invoice",
+			Msg:    "The Xero client cannot refresh a single invoice",
+		}
+	}
+	invoice, err := getter.GetInvoiceByID(ctx, id)
+	if err != nil {
+		return ErrSystem{
+			Detail: "xero GetInvoiceByID error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero invoice",
+		}
+	}
+	_, rejected, err := validRecords(ctx, r, "xero", "invoice", []xero.Invoice{invoice}, validateInvoice)
+	if err != nil {
+		return err
+	}
+	if rejected > 0 {
+		return errRecordRejected("invoice")
+	}
+	if err := r.db.InvoicesUpsert(ctx, []xero.Invoice{invoice}); err != nil {
+		return ErrSystem{
+			Detail: "xero InvoicesUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered upserting the Xero invoice",
+		}
+	}
+	return nil
+}
+
+// bankTransactionRefresh retrieves and upserts a single bank transaction.
+func (r *Reconciler) bankTransactionRefresh(ctx context.Context, xeroClient XeroClient, id string) error {
+	getter, ok := xeroClient.(XeroBankTransactionGetter)
+	if !ok {
+		return ErrUsage{
+			Detail: "xero client cannot retrieve a single bank transaction",
+			Msg:    "The Xero client cannot refresh a single bank transaction",
+		}
+	}
+	transaction, err := getter.GetBankTransactionByID(ctx, id)
+	if err != nil {
+		return ErrSystem{
+			Detail: "xero GetBankTransactionByID error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero bank transaction",
+		}
+	}
+	_, rejected, err := validRecords(ctx, r, "xero", "bank-transaction", []xero.BankTransaction{transaction}, validateBankTransaction)
+	if err != nil {
+		return err
+	}
+	if rejected > 0 {
+		return errRecordRejected("bank transaction")
+	}
+	if err := r.db.BankTransactionsUpsert(ctx, []xero.BankTransaction{transaction}); err != nil {
+		return ErrSystem{
+			Detail: "xero BankTransactionsUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered upserting the Xero bank transaction",
+		}
+	}
+	return nil
+}
+
+// donationRefresh retrieves and upserts a single donation, or removes it if it is no
+// longer in Salesforce.
+func (r *Reconciler) donationRefresh(ctx context.Context, sfClient SalesforceClient, id string) error {
+	if sfClient == nil {
+		return ErrUsage{
+			Detail: "no salesforce client",
+			Msg:    "Salesforce must be connected to refresh a donation",
+		}
+	}
+	donations, err := sfClient.GetOpportunitiesByID(ctx, []string{id})
+	if err != nil {
+		return ErrSystem{
+			Detail: "salesforce GetOpportunitiesByID error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Salesforce record",
+		}
+	}
+	if len(donations) == 0 {
+		if _, err := r.db.DeleteDonations(ctx, []string{id}); err != nil {
+			return ErrSystem{
+				Detail: "salesforce DeleteDonations error",
+				Err:    err,
+				Msg:    "A problem was encountered removing the deleted Salesforce record",
+			}
+		}
+		r.log.Info("removed donation no longer in salesforce", "id", id)
+		return nil
+	}
+	_, rejected, err := validRecords(ctx, r, "salesforce", "donation", donations, validateDonation)
+	if err != nil {
+		return err
+	}
+	if rejected > 0 {
+		return errRecordRejected("donation")
+	}
+	if err := r.db.UpsertDonations(ctx, donations); err != nil {
+		return ErrSystem{
+			Detail: "salesforce UpsertDonations error",
+			Err:    err,
+			Msg:    "A problem was encountered upserting the Salesforce record",
+		}
+	}
+	return nil
+}
+
+// errRecordRejected reports a refreshed record of recordType which failed validation.
+func errRecordRejected(recordType string) error {
+	return ErrUsage{
+		Detail: recordType + " failed validation",
+		Msg:    fmt.Sprintf("The refreshed %s failed validation and was recorded as an import error rather than stored", recordType),
+	}
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// mockRecordXeroClient can retrieve a single invoice or bank transaction, each with the
+// reference ref.
+type mockRecordXeroClient struct {
+	mockXeroClient
+	ref string
+}
+
+func (m *mockRecordXeroClient) GetInvoiceByID(ctx context.Context, invoiceID string) (xero.Invoice, error) {
+	return xero.Invoice{
+		InvoiceID:     invoiceID,
+		Type:          "ACCREC",
+		InvoiceNumber: "INV-2025-102",
+		Contact:       "Generous Individual",
+		Date:          xero.XeroDateTime{Time: time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC)},
+		Status:        "PAID",
+		Reference:     m.ref,
+		Total:         money.FromFloat(196.50),
+		LineItems: []xero.LineItem{
+			{LineItemID: "inv-li-002a", Description: "Pledged donation via Stripe", AccountCode: "5301", Quantity: 1, UnitAmount: money.FromFloat(200), LineAmount: money.FromFloat(200)},
+			{LineItemID: "inv-li-002b", Description: "Stripe processing fee", AccountCode: "429", Quantity: 1, UnitAmount: money.FromFloat(-3.50), LineAmount: money.FromFloat(-3.50)},
+		},
+	}, nil
+}
+
+func (m *mockRecordXeroClient) GetBankTransactionByID(ctx context.Context, uuid string) (xero.BankTransaction, error) {
+	return xero.BankTransaction{
+		BankTransactionID: uuid,
+		Type:              "RECEIVE",
+		Reference:         m.ref,
+		Date:              xero.XeroDateTime{Time: time.Date(2025, 4, 20, 9, 0, 0, 0, time.UTC)},
+		Status:            "AUTHORISED",
+		Total:             money.FromFloat(490),
+		IsReconciled:      true,
+		Contact:           "Stripe",
+		LineItems: []xero.LineItem{
+			{LineItemID: "bt-li-002a", Description: "Stripe Payout", AccountCode: "5501", Quantity: 1, UnitAmount: money.FromFloat(500), LineAmount: money.FromFloat(500)},
+			{LineItemID: "bt-li-002b", Description: "Stripe Platform Fee", AccountCode: "429", Quantity: 1, UnitAmount: money.FromFloat(-10), LineAmount: money.FromFloat(-10)},
+		},
+	}, nil
+}
+
+// mockDeletedSalesforceClient finds no donations, as if they were deleted in Salesforce.
+type mockDeletedSalesforceClient struct {
+	mockSalesforceClient
+}
+
+func (m *mockDeletedSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	return nil, nil
+}
+
+// TestRecordRefresh tests refreshing single records from the remote systems.
+func TestRecordRefresh(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	xeroClient := &mockRecordXeroClient{mockXeroClient: mockXeroClient{log: logger}, ref: "corrected in xero"}
+
+	if err := reconciler.RecordRefresh(ctx, xeroClient, nil, "invoice", "inv-002"); err != nil {
+		t.Fatal(err)
+	}
+	invoice, _, err := testDB.InvoiceWRGet(ctx, "inv-002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Reference == nil || *invoice.Reference != "corrected in xero" {
+		t.Errorf("invoice reference not refreshed, got %v", invoice.Reference)
+	}
+
+	if err := reconciler.RecordRefresh(ctx, xeroClient, nil, "bank-transaction", "bt-002"); err != nil {
+		t.Fatal(err)
+	}
+	transaction, _, err := testDB.BankTransactionWRGet(ctx, "bt-002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Reference == nil || *transaction.Reference != "corrected in xero" {
+		t.Errorf("bank transaction reference not refreshed, got %v", transaction.Reference)
+	}
+
+	// A client without the single record capability cannot refresh a record.
+	err = reconciler.RecordRefresh(ctx, &mockXeroClient{log: logger}, nil, "invoice", "inv-002")
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage, got %v", err)
+	}
+
+	// A donation no longer in Salesforce is removed.
+	sfClient := &mockDeletedSalesforceClient{mockSalesforceClient{log: logger}}
+	if err := reconciler.RecordRefresh(ctx, nil, sfClient, "donation", "sf-opp-002"); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := testDB.DonationRefsGet(ctx, []string{"sf-opp-002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("expected the deleted donation to be removed, got %+v", refs)
+	}
+
+	err = reconciler.RecordRefresh(ctx, xeroClient, sfClient, "contact", "c-001")
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage for an invalid record type, got %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// PeriodReport is a period-end reconciliation summary, with the donation totals for
+// each account code split by reconciliation status and the donations in the period
+// which are not linked to an invoice or bank transaction.
+type PeriodReport struct {
+	Organisation      string
+	DateFrom          time.Time
+	DateTo            time.Time
+	Generated         time.Time
+	AccountTotals     []db.AccountTotal
+	Total             money.Amount
+	ReconciledTotal   money.Amount
+	UnreconciledTotal money.Amount
+	UnlinkedDonations []ViewDonation
+	UnlinkedTotal     money.Amount
+}
+
+// PeriodReportGet retrieves the reconciliation summary for the period from to to.
+func (r *Reconciler) PeriodReportGet(ctx context.Context, from time.Time, to time.Time) (*PeriodReport, error) {
+
+	if to.Before(from) {
+		return nil, ErrUsage{
+			Detail: "PeriodReportGet date error",
+			Msg:    "The report end date must not be before the start date",
+		}
+	}
+
+	report := &PeriodReport{
+		DateFrom:  from,
+		DateTo:    to,
+		Generated: time.Now(),
+	}
+
+	org, err := r.db.OrganisationGet(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.OrganisationGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the Xero organisation record",
+		}
+	}
+	report.Organisation = org.Name
+
+	report.AccountTotals, err = r.db.AccountTotalsGet(ctx, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.AccountTotalsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the account code totals",
+		}
+	}
+	for _, at := range report.AccountTotals {
+		report.Total += at.Total
+		report.ReconciledTotal += at.ReconciledTotal
+		report.UnreconciledTotal += at.UnreconciledTotal
+	}
+
+	// A limit of -1 returns all rows.
+	donations, err := r.db.DonationsGet(ctx, from, to, "NotLinked", "", "", "", db.SortOrder{}, -1, 0)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.DonationsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the unlinked donations",
+		}
+	}
+	report.UnlinkedDonations = newViewDonations(donations)
+	for _, d := range report.UnlinkedDonations {
+		report.UnlinkedTotal += d.Amount
+	}
+	return report, nil
+}
+
+// AgingBucketNames are the names of the age buckets of an AgingReport, in order.
+var AgingBucketNames = []string{"0–30 days", "31–60 days", "61–90 days", "Over 90 days"}
+
+// AgingBucket summarises the unlinked donations and unreconciled invoices and bank
+// transactions in an age bucket.
+type AgingBucket struct {
+	Name           string
+	DonationsNo    int
+	DonationsTotal money.Amount
+	RecordsNo      int
+	RecordsTotal   money.Amount
+}
+
+// AgingReport buckets the unlinked donations and unreconciled invoices and bank
+// transactions dated from DateFrom by their age at AsAt, so that stale items can be
+// prioritised. Items are listed oldest first.
+type AgingReport struct {
+	DateFrom       time.Time
+	AsAt           time.Time
+	Buckets        []AgingBucket
+	Items          []db.AgingItem
+	DonationsTotal money.Amount
+	RecordsTotal   money.Amount
+}
+
+// AgingReportGet retrieves the aging report for items dated from from as at asAt.
+func (r *Reconciler) AgingReportGet(ctx context.Context, from time.Time, asAt time.Time) (*AgingReport, error) {
+
+	if asAt.Before(from) {
+		return nil, ErrUsage{
+			Detail: "AgingReportGet date error",
+			Msg:    "The report date must not be before the start date",
+		}
+	}
+
+	report := &AgingReport{
+		DateFrom: from,
+		AsAt:     asAt,
+		Buckets:  make([]AgingBucket, len(AgingBucketNames)),
+	}
+	for i, name := range AgingBucketNames {
+		report.Buckets[i].Name = name
+	}
+
+	items, err := r.db.AgingItemsGet(ctx, from, asAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.AgingItemsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the unlinked and unreconciled items",
+		}
+	}
+	report.Items = items
+
+	for _, item := range items {
+		if item.Bucket < 0 || item.Bucket >= len(report.Buckets) {
+			return nil, ErrSystem{
+				Detail: "AgingReportGet bucket error",
+				Err:    fmt.Errorf("invalid bucket %d for %s %s", item.Bucket, item.ItemType, item.ID),
+				Msg:    "A problem was encountered grouping the items by age",
+			}
+		}
+		b := &report.Buckets[item.Bucket]
+		if item.ItemType == "donation" {
+			b.DonationsNo++
+			b.DonationsTotal += item.Amount
+			report.DonationsTotal += item.Amount
+		} else {
+			b.RecordsNo++
+			b.RecordsTotal += item.Amount
+			report.RecordsTotal += item.Amount
+		}
+	}
+	return report, nil
+}
+
+// AccountBreakdown is the monthly donation totals of an account code with their linked
+// donation totals. An account code reconciles cleanly if all its invoices and bank
+// transactions are reconciled.
+type AccountBreakdown struct {
+	AccountCode     string
+	AccountName     string
+	Months          []db.AccountMonth
+	RecordCount     int
+	ReconciledCount int
+	Total           money.Amount
+	LinkedTotal     money.Amount
+	Difference      money.Amount
+}
+
+// Clean reports if all the invoices and bank transactions of the account code are
+// reconciled.
+func (a AccountBreakdown) Clean() bool {
+	return a.RecordCount == a.ReconciledCount
+}
+
+// AccountBreakdownReport compares the donation income of each account code by month
+// with the linked donations, so that the income streams which do not reconcile can be
+// found.
+type AccountBreakdownReport struct {
+	DateFrom    time.Time
+	DateTo      time.Time
+	Accounts    []AccountBreakdown
+	Total       money.Amount
+	LinkedTotal money.Amount
+	Difference  money.Amount
+}
+
+// AccountBreakdownReportGet retrieves the account code breakdown report for the period
+// from to to.
+func (r *Reconciler) AccountBreakdownReportGet(ctx context.Context, from time.Time, to time.Time) (*AccountBreakdownReport, error) {
+
+	if to.Before(from) {
+		return nil, ErrUsage{
+			Detail: "AccountBreakdownReportGet date error",
+			Msg:    "The report end date must not be before the start date",
+		}
+	}
+
+	report := &AccountBreakdownReport{
+		DateFrom: from,
+		DateTo:   to,
+	}
+
+	months, err := r.db.AccountMonthsGet(ctx, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.AccountMonthsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the account code totals by month",
+		}
+	}
+
+	// The months are ordered by account code.
+	for _, m := range months {
+		if n := len(report.Accounts); n == 0 || report.Accounts[n-1].AccountCode != m.AccountCode {
+			report.Accounts = append(report.Accounts, AccountBreakdown{
+				AccountCode: m.AccountCode,
+				AccountName: m.AccountName,
+			})
+		}
+		a := &report.Accounts[len(report.Accounts)-1]
+		a.Months = append(a.Months, m)
+		a.RecordCount += m.RecordCount
+		a.ReconciledCount += m.ReconciledCount
+		a.Total += m.Total
+		a.LinkedTotal += m.LinkedTotal
+		a.Difference = a.Total - a.LinkedTotal
+		report.Total += m.Total
+		report.LinkedTotal += m.LinkedTotal
+	}
+	report.Difference = report.Total - report.LinkedTotal
+	return report, nil
+}
+
+// PayoutGroupsReport compares the donations of each payout reference with the invoices
+// and bank transactions sharing the reference, so that references found only in
+// Salesforce or only in Xero can be found. The numbers of groups of each status are of
+// all the groups, even if only the unmatched groups are reported.
+type PayoutGroupsReport struct {
+	DateFrom         time.Time
+	DateTo           time.Time
+	Groups           []db.PayoutGroup
+	MatchedNo        int
+	DiffersNo        int
+	SalesforceOnlyNo int
+	XeroOnlyNo       int
+}
+
+// PayoutGroupsReportGet retrieves the payout reference groups report for the period
+// from to to, reporting only the groups which are not matched if unmatched is set.
+func (r *Reconciler) PayoutGroupsReportGet(ctx context.Context, from time.Time, to time.Time, unmatched bool) (*PayoutGroupsReport, error) {
+
+	if to.Before(from) {
+		return nil, ErrUsage{
+			Detail: "PayoutGroupsReportGet date error",
+			Msg:    "The report end date must not be before the start date",
+		}
+	}
+
+	report := &PayoutGroupsReport{
+		DateFrom: from,
+		DateTo:   to,
+	}
+
+	groups, err := r.db.PayoutGroupsGet(ctx, from, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.PayoutGroupsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving the payout reference totals",
+		}
+	}
+
+	for _, g := range groups {
+		switch g.Status() {
+		case "matched":
+			report.MatchedNo++
+			if unmatched {
+				continue
+			}
+		case "differs":
+			report.DiffersNo++
+		case "salesforce":
+			report.SalesforceOnlyNo++
+		case "xero":
+			report.XeroOnlyNo++
+		}
+		report.Groups = append(report.Groups, g)
+	}
+	return report, nil
+}
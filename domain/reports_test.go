@@ -0,0 +1,207 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestPeriodReportGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	report, err := reconciler.PeriodReportGet(ctx, from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(report.AccountTotals), 3; got != want {
+		t.Errorf("account totals got %d want %d", got, want)
+	}
+	if got, want := report.Total, money.FromFloat(7360); got != want {
+		t.Errorf("total got %.2f want %.2f", got, want)
+	}
+	if got, want := report.ReconciledTotal+report.UnreconciledTotal, report.Total; got != want {
+		t.Errorf("reconciled and unreconciled got %.2f want %.2f", got, want)
+	}
+	if len(report.UnlinkedDonations) == 0 {
+		t.Error("expected unlinked donations")
+	}
+	var unlinked money.Amount
+	for _, d := range report.UnlinkedDonations {
+		if d.IsLinked {
+			t.Errorf("donation %s is linked", d.ID)
+		}
+		unlinked += d.Amount
+	}
+	if got, want := report.UnlinkedTotal, unlinked; got != want {
+		t.Errorf("unlinked total got %.2f want %.2f", got, want)
+	}
+
+	// Out of range periods give an empty report.
+	report, err = reconciler.PeriodReportGet(ctx, to.AddDate(2, 0, 0), to.AddDate(3, 0, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.AccountTotals) != 0 || len(report.UnlinkedDonations) != 0 || report.Total != 0 {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+
+	// Reversed dates are a usage error.
+	_, err = reconciler.PeriodReportGet(ctx, to, from)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestAgingReportGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	asAt := time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	report, err := reconciler.AgingReportGet(ctx, from, asAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(report.Buckets), len(AgingBucketNames); got != want {
+		t.Fatalf("buckets got %d want %d", got, want)
+	}
+	var itemsNo int
+	var donations, records money.Amount
+	for _, b := range report.Buckets {
+		itemsNo += b.DonationsNo + b.RecordsNo
+		donations += b.DonationsTotal
+		records += b.RecordsTotal
+	}
+	if got, want := itemsNo, len(report.Items); got != want || got == 0 {
+		t.Errorf("bucketed items got %d want %d", got, want)
+	}
+	if donations != report.DonationsTotal || records != report.RecordsTotal {
+		t.Errorf("bucket totals %s %s do not match report totals %s %s", donations, records, report.DonationsTotal, report.RecordsTotal)
+	}
+	if report.Buckets[0].DonationsNo+report.Buckets[0].RecordsNo != 0 {
+		t.Errorf("expected no items of 30 days or less, got %+v", report.Buckets[0])
+	}
+
+	// Reversed dates are a usage error.
+	_, err = reconciler.AgingReportGet(ctx, asAt, from)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestAccountBreakdownReportGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	report, err := reconciler.AccountBreakdownReportGet(ctx, from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(report.Accounts), 3; got != want {
+		t.Fatalf("accounts got %d want %d", got, want)
+	}
+	if got, want := report.Total, money.FromFloat(7360); got != want {
+		t.Errorf("total got %s want %s", got, want)
+	}
+	var total, linked money.Amount
+	for _, a := range report.Accounts {
+		var monthsTotal money.Amount
+		for _, m := range a.Months {
+			if m.AccountCode != a.AccountCode {
+				t.Errorf("month %s of %s in account %s", m.Month, m.AccountCode, a.AccountCode)
+			}
+			monthsTotal += m.Total
+		}
+		if monthsTotal != a.Total {
+			t.Errorf("%s months total %s want %s", a.AccountCode, monthsTotal, a.Total)
+		}
+		if a.Difference != a.Total-a.LinkedTotal {
+			t.Errorf("%s difference %s is not total less linked", a.AccountCode, a.Difference)
+		}
+		if a.Clean() {
+			t.Errorf("%s unexpectedly reconciles cleanly", a.AccountCode)
+		}
+		total += a.Total
+		linked += a.LinkedTotal
+	}
+	if total != report.Total || linked != report.LinkedTotal {
+		t.Errorf("account totals %s %s do not match report totals %s %s", total, linked, report.Total, report.LinkedTotal)
+	}
+
+	// Out of range periods give an empty report.
+	report, err = reconciler.AccountBreakdownReportGet(ctx, to.AddDate(2, 0, 0), to.AddDate(3, 0, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Accounts) != 0 || report.Total != 0 {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+
+	// Reversed dates are a usage error.
+	_, err = reconciler.AccountBreakdownReportGet(ctx, to, from)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+}
+
+func TestPayoutGroupsReportGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	ctx := context.Background()
+
+	from := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	report, err := reconciler.PayoutGroupsReportGet(ctx, from, to, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	all := report.MatchedNo + report.DiffersNo + report.SalesforceOnlyNo + report.XeroOnlyNo
+	if report.MatchedNo == 0 || len(report.Groups) != all {
+		t.Fatalf("unexpected report counts %+v for %d groups", report, len(report.Groups))
+	}
+
+	unmatched, err := reconciler.PayoutGroupsReportGet(ctx, from, to, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := len(unmatched.Groups), all-report.MatchedNo; got != want {
+		t.Errorf("unmatched groups got %d want %d", got, want)
+	}
+	for _, g := range unmatched.Groups {
+		if g.Status() == "matched" {
+			t.Errorf("unexpected matched group %+v", g)
+		}
+	}
+
+	if _, err := reconciler.PayoutGroupsReportGet(ctx, to, from, false); !errors.As(err, new(ErrUsage)) {
+		t.Errorf("expected a usage error for reversed dates, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package domain
+
+// statements.go compares imported bank statements with Xero and the CRM. Each line of
+// money received on a statement is matched to a Xero bank transaction, and the
+// donations of the transaction in Xero to those recorded in the CRM, so that money
+// received which never reached Xero, or reached it without its donations, is visible.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/statementcsv"
+)
+
+// statementMatchDays is the number of days either side of a statement line in which a
+// Xero bank transaction may match it, allowing for the clearing of payments.
+const statementMatchDays = 5
+
+// bankAccountMaxLength is the maximum length of the name of the bank account of an
+// imported statement.
+const bankAccountMaxLength = 100
+
+// StatementReport matches the lines of money received on the imported bank statements
+// to the Xero bank transactions and their donations. The numbers of lines of each
+// status are of all the lines, even if only the unmatched lines are reported. The
+// MissingTotal is the money received without a matching Xero bank transaction.
+type StatementReport struct {
+	DateFrom     time.Time
+	DateTo       time.Time
+	Lines        []db.StatementMatch
+	MatchedNo    int
+	DiffersNo    int
+	MissingNo    int
+	MissingTotal money.Amount
+}
+
+// statementLineRef returns the reference of a statement line, being its id in the
+// statement or else one made of its date and amount and the number of earlier lines of
+// the statement with the same date and amount, so that re-importing a statement, or
+// importing an overlapping one, updates the lines imported before.
+func statementLineRef(line statementcsv.Line, seen map[string]int) string {
+	if line.ID != "" {
+		return line.ID
+	}
+	key := line.Date.Format(time.DateOnly) + "/" + line.Amount.String()
+	seen[key]++
+	return fmt.Sprintf("%s/%d", key, seen[key])
+}
+
+// StatementLinesStage stages the lines of a bank statement of bankAccount for review,
+// returning the id of the import batch. Once committed the lines are recorded against
+// the bank account, updating the lines with the same references imported before. Lines
+// repeating the id of an earlier line are rejected.
+func (r *Reconciler) StatementLinesStage(ctx context.Context, bankAccount, fileName string, lines []statementcsv.Line) (int64, error) {
+
+	bankAccount = strings.TrimSpace(bankAccount)
+	if bankAccount == "" {
+		return 0, ErrUsage{Detail: "StatementLinesStage error", Msg: "The bank account of the statement must be provided"}
+	}
+	if len(bankAccount) > bankAccountMaxLength {
+		return 0, ErrUsage{Detail: "StatementLinesStage error", Msg: fmt.Sprintf("The bank account name may not be longer than %d characters", bankAccountMaxLength)}
+	}
+	if len(lines) == 0 {
+		return 0, ErrUsage{Detail: "StatementLinesStage error", Msg: "The bank statement contains no lines"}
+	}
+
+	seenIDs := map[string]bool{}
+	seenKeys := map[string]int{}
+	rows := make([]db.ImportRow, len(lines))
+	for i, line := range lines {
+		var rowErr error
+		if line.ID != "" {
+			if seenIDs[line.ID] {
+				rowErr = fmt.Errorf("duplicate transaction id %q", line.ID)
+			}
+			seenIDs[line.ID] = true
+		}
+		ref := statementLineRef(line, seenKeys)
+
+		vals := []string{line.Date.Format(time.DateOnly), line.Amount.String(), line.Description, line.Reference}
+		var err error
+		rows[i], err = stagedRow(i+2, ref, vals, db.StatementLine{
+			LineRef:     ref,
+			Date:        line.Date,
+			Amount:      line.Amount,
+			Description: line.Description,
+			Reference:   line.Reference,
+		}, rowErr)
+		if err != nil {
+			return 0, ErrSystem{Detail: "StatementLinesStage encoding error", Err: err, Msg: "A problem was encountered staging the bank statement"}
+		}
+	}
+
+	id, err := r.db.ImportBatchCreate(ctx, db.ImportStatementLines, bankAccount, fileName, rows)
+	if err != nil {
+		return 0, ErrSystem{Detail: "db.ImportBatchCreate error", Err: err, Msg: "A problem was encountered staging the bank statement"}
+	}
+	r.log.Info("staged bank statement", "bank account", bankAccount, "lines", len(lines), "batch", id)
+	return id, nil
+}
+
+// commitStatementLines records the staged statement lines against the bank account.
+func (r *Reconciler) commitStatementLines(ctx context.Context, bankAccount string, payloads [][]byte) (int, error) {
+	lines := make([]db.StatementLine, len(payloads))
+	for i, p := range payloads {
+		if err := json.Unmarshal(p, &lines[i]); err != nil {
+			return 0, fmt.Errorf("staged statement line decoding error: %w", err)
+		}
+	}
+	return r.db.StatementLinesUpsert(ctx, bankAccount, lines)
+}
+
+// StatementReportGet retrieves the bank statement report for the period from to to,
+// reporting only the lines which are not matched if unmatched is set.
+func (r *Reconciler) StatementReportGet(ctx context.Context, from time.Time, to time.Time, unmatched bool) (*StatementReport, error) {
+
+	if to.Before(from) {
+		return nil, ErrUsage{
+			Detail: "StatementReportGet date error",
+			Msg:    "The report end date must not be before the start date",
+		}
+	}
+
+	matches, err := r.db.StatementMatchesGet(ctx, from, to, statementMatchDays)
+	if err != nil {
+		return nil, ErrSystem{
+			Detail: "db.StatementMatchesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered matching the bank statement lines",
+		}
+	}
+
+	report := &StatementReport{
+		DateFrom: from,
+		DateTo:   to,
+	}
+	for _, m := range matches {
+		switch m.Status() {
+		case "matched":
+			report.MatchedNo++
+			if unmatched {
+				continue
+			}
+		case "differs":
+			report.DiffersNo++
+		case "missing":
+			report.MissingNo++
+			report.MissingTotal += m.Amount
+		}
+		report.Lines = append(report.Lines, m)
+	}
+	return report, nil
+}
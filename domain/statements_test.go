@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/statementcsv"
+)
+
+// TestStatementLinesStage tests staging a bank statement, committing it and matching
+// its lines of money received to the Xero bank transactions.
+func TestStatementLinesStage(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	// bt-001 of 337.25 on 15/04 is reconciled while bt-002 of 490.00 on 20/04 is not.
+	date := func(day int) time.Time { return time.Date(2025, 4, day, 0, 0, 0, 0, time.UTC) }
+	lines := []statementcsv.Line{
+		{Date: date(15), Amount: money.FromFloat(337.25), Description: "JUSTGIVING"},
+		{Date: date(20), Amount: money.FromFloat(490), Description: "STRIPE"},
+		{Date: date(21), Amount: money.FromFloat(42), Description: "CHEQUE"},
+		{Date: date(21), Amount: money.FromFloat(42), Description: "CHEQUE"},
+		{Date: date(22), Amount: money.FromFloat(-12), Description: "CARD FEE"},
+		{ID: "t-1", Date: date(23), Amount: money.FromFloat(5), Description: "INTEREST"},
+		{ID: "t-1", Date: date(23), Amount: money.FromFloat(5), Description: "INTEREST"},
+	}
+
+	_, err := reconciler.StatementLinesStage(ctx, " ", "statement.csv", lines)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage for a missing bank account, got %T %v", err, err)
+	}
+
+	id, err := reconciler.StatementLinesStage(ctx, "Current Account", "statement.csv", lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	review, err := reconciler.ImportReviewGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Batch.Accepted != 6 || review.Batch.Rejected != 1 || review.Rows[6].Error != `duplicate transaction id "t-1"` {
+		t.Errorf("unexpected review %+v", review)
+	}
+	if got, want := review.Rows[3].RecordID, "2025-04-21/42.00/2"; got != want {
+		t.Errorf("repeated line reference got %q want %q", got, want)
+	}
+
+	// The lines are only recorded once committed.
+	report, err := reconciler.StatementReportGet(ctx, date(1), date(30), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Lines) != 0 {
+		t.Errorf("got %d lines before commit", len(report.Lines))
+	}
+	result, err := reconciler.ImportCommit(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Committed, 6; got != want {
+		t.Errorf("got %d lines committed want %d", got, want)
+	}
+
+	report, err = reconciler.StatementReportGet(ctx, date(1), date(30), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MatchedNo != 1 || report.DiffersNo != 1 || report.MissingNo != 3 {
+		t.Errorf("got %d matched %d differing %d missing lines want 1, 1 and 3", report.MatchedNo, report.DiffersNo, report.MissingNo)
+	}
+	if got, want := len(report.Lines), 4; got != want {
+		t.Errorf("got %d unmatched lines want %d", got, want)
+	}
+	if got, want := report.MissingTotal, money.FromFloat(89); got != want {
+		t.Errorf("missing total got %s want %s", got, want)
+	}
+
+	_, err = reconciler.StatementReportGet(ctx, date(30), date(1), false)
+	if _, ok := errors.AsType[ErrUsage](err); !ok {
+		t.Errorf("expected ErrUsage for reversed dates, got %T %v", err, err)
+	}
+}
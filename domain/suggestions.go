@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+)
+
+// SuggestionDecision records the outcome of a review of a link suggestion, typically
+// re-imported from an offline review of a suggestions export. Typer is "invoice" or
+// "bank-transaction" and RecordID is the invoice or bank transaction uuid.
+type SuggestionDecision struct {
+	Typer      string
+	RecordID   string
+	DonationID string
+	Accept     bool
+}
+
+// SuggestionDecisionResults reports the number of accepted suggestions which were
+// linked and the number of rejected suggestions which were ignored.
+type SuggestionDecisionResults struct {
+	Linked   int
+	Rejected int
+}
+
+// LinkSuggestionsGet retrieves the suggested donation links for unreconciled invoices
+// and bank transactions with a score of at least minScore.
+func (r *Reconciler) LinkSuggestionsGet(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+	minScore float64,
+) ([]db.LinkSuggestion, error) {
+
+	suggestions, err := r.db.LinkSuggestionsGet(ctx, from, to, minScore)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.LinkSuggestionsGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving link suggestions",
+		}
+	}
+	return suggestions, err // percolate sql.ErrNoRows if necessary.
+}
+
+// DonationCandidatesGet retrieves the candidate donations for linking to the invoice or
+// bank transaction with id, chosen by date window and amount proximity. No candidates
+// is not an error.
+func (r *Reconciler) DonationCandidatesGet(ctx context.Context, typer, id string) ([]db.LinkSuggestion, error) {
+
+	candidates, err := r.db.DonationCandidatesGet(ctx, typer, id)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, ErrSystem{
+			Detail: "db.DonationCandidatesGet error",
+			Err:    err,
+			Msg:    "A problem was encountered retrieving candidate donations",
+		}
+	}
+	return candidates, nil
+}
+
+// LinkSuggestionDecisionsApply links the donations in the accepted decisions to their
+// invoice or bank transaction. Rejected decisions are counted but otherwise ignored.
+// A donation may only be accepted for one record.
+func (r *Reconciler) LinkSuggestionDecisionsApply(
+	ctx context.Context,
+	sfClient SalesforceClient,
+	decisions []SuggestionDecision,
+	dataStartDate time.Time,
+	lastRefreshed time.Time,
+) (*SuggestionDecisionResults, error) {
+
+	results := &SuggestionDecisionResults{}
+
+	dfks := map[string]string{}     // typer/id to dfk
+	accepted := map[string]string{} // donation id to typer/id
+	idRefs := []salesforce.IDRef{}
+
+	for _, d := range decisions {
+		if !d.Accept {
+			results.Rejected++
+			continue
+		}
+		key := d.Typer + "/" + d.RecordID
+		if prev, ok := accepted[d.DonationID]; ok {
+			if prev == key {
+				continue
+			}
+			return results, ErrUsage{
+				Detail: "LinkSuggestionDecisionsApply error",
+				Msg:    fmt.Sprintf("donation %s was accepted for both %s and %s", d.DonationID, prev, key),
+			}
+		}
+		accepted[d.DonationID] = key
+
+		dfk, ok := dfks[key]
+		if !ok {
+			var err error
+			dfk, _, err = r.InvoiceOrBankTransactionInfoGet(ctx, d.Typer, d.RecordID)
+			if err != nil {
+				return results, err
+			}
+			if dfk == "" {
+				return results, ErrUsage{
+					Detail: "LinkSuggestionDecisionsApply error",
+					Msg:    fmt.Sprintf("%s %s has no reference and cannot be linked", d.Typer, d.RecordID),
+				}
+			}
+			dfks[key] = dfk
+		}
+		idRefs = append(idRefs, salesforce.IDRef{ID: d.DonationID, Ref: dfk})
+	}
+
+	if len(idRefs) == 0 {
+		return results, nil
+	}
+
+	if err := r.DonationsLinkUnlink(ctx, sfClient, idRefs, dataStartDate, lastRefreshed); err != nil {
+		return results, err
+	}
+	results.Linked = len(idRefs)
+	r.log.Info("applied link suggestion decisions", "linked", results.Linked, "rejected", results.Rejected)
+	return results, nil
+}
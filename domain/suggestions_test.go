@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestLinkSuggestionDecisionsApply tests applying accepted and rejected suggestions.
+// The test database is used, but the Salesforce API client is mocked.
+func TestLinkSuggestionDecisionsApply(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	suggestions, err := reconciler.LinkSuggestionsGet(ctx, dataStartDate, dataStartDate.AddDate(1, 0, 0), 0.85)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(suggestions), 3; got != want {
+		t.Fatalf("got %d suggestions want %d", got, want)
+	}
+
+	tests := []struct {
+		name         string
+		decisions    []SuggestionDecision
+		wantLinked   int
+		wantRejected int
+		wantUsageErr bool
+		wantNotFound bool
+	}{
+		{
+			name: "accept and reject",
+			decisions: []SuggestionDecision{
+				{Typer: "invoice", RecordID: "inv-unrec-04", DonationID: "sf-opp-018", Accept: true},
+				{Typer: "invoice", RecordID: "inv-unrec-04", DonationID: "sf-opp-019", Accept: false},
+				{Typer: "bank-transaction", RecordID: "bt-unrec-04", DonationID: "sf-opp-017", Accept: true},
+			},
+			wantLinked:   2,
+			wantRejected: 1,
+		},
+		{
+			name: "reject only",
+			decisions: []SuggestionDecision{
+				{Typer: "invoice", RecordID: "inv-unrec-04", DonationID: "sf-opp-019", Accept: false},
+			},
+			wantLinked:   0,
+			wantRejected: 1,
+		},
+		{
+			name: "donation accepted twice",
+			decisions: []SuggestionDecision{
+				{Typer: "invoice", RecordID: "inv-unrec-04", DonationID: "sf-opp-018", Accept: true},
+				{Typer: "bank-transaction", RecordID: "bt-unrec-01", DonationID: "sf-opp-018", Accept: true},
+			},
+			wantUsageErr: true,
+		},
+		{
+			name: "invoice not found",
+			decisions: []SuggestionDecision{
+				{Typer: "invoice", RecordID: "inv-99999", DonationID: "sf-opp-018", Accept: true},
+			},
+			wantNotFound: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msc := &mockSalesforceClient{log: logger}
+			results, err := reconciler.LinkSuggestionDecisionsApply(ctx, msc, tt.decisions, dataStartDate, time.Time{})
+			if tt.wantUsageErr {
+				if _, ok := errors.AsType[ErrUsage](err); !ok {
+					t.Fatalf("expected ErrUsage, got %T %v", err, err)
+				}
+				return
+			}
+			if tt.wantNotFound {
+				if _, ok := errors.AsType[ErrNotFound](err); !ok {
+					t.Fatalf("expected ErrNotFound, got %T %v", err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := results.Linked, tt.wantLinked; got != want {
+				t.Errorf("got %d linked want %d", got, want)
+			}
+			if got, want := results.Rejected, tt.wantRejected; got != want {
+				t.Errorf("got %d rejected want %d", got, want)
+			}
+		})
+	}
+}
+
+// TestDonationCandidatesGet tests retrieving the candidate donations of a record, where
+// no candidates is not an error.
+func TestDonationCandidatesGet(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+
+	candidates, err := reconciler.DonationCandidatesGet(ctx, "invoice", "inv-unrec-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) == 0 {
+		t.Error("expected candidates for inv-unrec-04")
+	}
+
+	candidates, err = reconciler.DonationCandidatesGet(ctx, "invoice", "inv-unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(candidates), 0; got != want {
+		t.Errorf("got %d candidates want %d", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package domain
+
+// syncguard.go runs one refresh of the records of each source at a time. A refresh
+// started while another of the same source runs, such as one made from a second
+// browser tab or by another user, does not interleave its upserts with those of the
+// running refresh. It shares the results of the running refresh if it was made with
+// the same parameters, or otherwise waits for it to finish and then runs itself. The
+// backfills, single record refreshes and Salesforce change events, which also upsert
+// records and sync the donation links, are run in the same way.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// syncJob is a running refresh of the records of a source.
+type syncJob struct {
+	key     string // the parameters of the refresh
+	done    chan struct{}
+	results any
+	err     error
+}
+
+// syncGuard records the running refresh of each source. The zero value is ready for use.
+type syncGuard struct {
+	mu   sync.Mutex
+	jobs map[string]*syncJob
+	// waiting, if set, is called when a request waits for a running refresh.
+	waiting func(source string)
+}
+
+// run runs fn as the refresh of source with the parameters described by key. If a
+// refresh of source with the same key is running, its results and error are returned
+// once it finishes, with attached true. If a refresh of source with another key is
+// running, run waits for it to finish before running fn. A refresh with an empty key,
+// such as that of a single record, is never shared.
+//
+// fn is run with a context detached from the cancellation of ctx, so that a refresh
+// shared by several requests is not cancelled by the request which started it going
+// away. ctx.Err() is returned if ctx is done while waiting, leaving the refresh to
+// finish.
+func (g *syncGuard) run(ctx context.Context, source, key string, fn func(context.Context) (any, error)) (results any, attached bool, err error) {
+
+	g.mu.Lock()
+	for {
+		job, ok := g.jobs[source]
+		if !ok {
+			break
+		}
+		g.mu.Unlock()
+		if g.waiting != nil {
+			g.waiting(source)
+		}
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		if key != "" && job.key == key {
+			return job.results, true, job.err
+		}
+		g.mu.Lock()
+	}
+	if g.jobs == nil {
+		g.jobs = map[string]*syncJob{}
+	}
+	job := &syncJob{key: key, done: make(chan struct{})}
+	g.jobs[source] = job
+	g.mu.Unlock()
+
+	jobCtx := context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				job.results, job.err = nil, fmt.Errorf("%s refresh panic: %v", source, p)
+			}
+			g.mu.Lock()
+			delete(g.jobs, source)
+			g.mu.Unlock()
+			close(job.done)
+		}()
+		job.results, job.err = fn(jobCtx)
+	}()
+
+	select {
+	case <-job.done:
+		return job.results, false, job.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
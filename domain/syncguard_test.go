@@ -0,0 +1,222 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+)
+
+// mockBlockingSalesforceClient signals that it is retrieving the donations on started,
+// and waits for release before returning them.
+type mockBlockingSalesforceClient struct {
+	mockSalesforceClient
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *mockBlockingSalesforceClient) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error) {
+	m.started <- struct{}{}
+	<-m.release
+	return m.mockSalesforceClient.GetOpportunities(ctx, fromDate, ifModifiedSince)
+}
+
+// newSyncGuardTest returns a reconciler whose Salesforce refreshes block in the
+// returned client until it is released, and a channel receiving each wait for a
+// running refresh.
+func newSyncGuardTest(t *testing.T) (*Reconciler, *mockBlockingSalesforceClient, chan string) {
+	t.Helper()
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	reconciler := NewReconciler(testDB, slog.Default())
+	waited := make(chan string, 4)
+	reconciler.syncs.waiting = func(source string) { waited <- source }
+	sfClient := &mockBlockingSalesforceClient{
+		mockSalesforceClient: mockSalesforceClient{log: slog.Default()},
+		started:              make(chan struct{}, 2),
+		release:              make(chan struct{}),
+	}
+	return reconciler, sfClient, waited
+}
+
+// TestSyncGuard tests that a refresh made while another refresh of the same source is
+// running waits for it and shares its results, rather than upserting the records again.
+func TestSyncGuard(t *testing.T) {
+
+	ctx := t.Context()
+	reconciler, sfClient, waited := newSyncGuardTest(t)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	var results [2]*RefreshSalesforceResults
+	var errs [2]error
+	refresh := func(i int) {
+		defer wg.Done()
+		results[i], errs[i] = reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, time.Time{})
+	}
+	wg.Add(2)
+	go refresh(0)
+	<-sfClient.started
+	go refresh(1)
+
+	// Wait for the second refresh to attach to the first.
+	if got, want := <-waited, "salesforce"; got != want {
+		t.Fatalf("waited for %s want %s", got, want)
+	}
+	// A refresh of another source is not held up.
+	xeroClient := &mockXeroClient{log: slog.Default()}
+	if _, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Time{}, nil, true); err != nil {
+		t.Fatalf("xero refresh error: %v", err)
+	}
+
+	close(sfClient.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("refresh %d error: %v", i, err)
+		}
+	}
+	if got, want := sfClient.getCount, 1; got != want {
+		t.Errorf("got %d donation retrievals want %d", got, want)
+	}
+	if results[0].Attached || !results[1].Attached {
+		t.Errorf("attached got %t, %t want false, true", results[0].Attached, results[1].Attached)
+	}
+	if got, want := results[1].RecordsNo, results[0].RecordsNo; got != want || got != 1 {
+		t.Errorf("attached refresh records got %d want %d", got, want)
+	}
+	if !results[1].Started.Equal(results[0].Started) {
+		t.Errorf("attached refresh started %s want %s", results[1].Started, results[0].Started)
+	}
+
+	// Once finished, a further refresh runs afresh.
+	sfClient.release = make(chan struct{})
+	close(sfClient.release)
+	result, err := reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Attached || sfClient.getCount != 2 {
+		t.Errorf("further refresh attached %t with %d retrievals", result.Attached, sfClient.getCount)
+	}
+}
+
+// TestSyncGuardParameters tests that a refresh made with other parameters while a
+// refresh of the same source is running waits for it, and then runs itself.
+func TestSyncGuardParameters(t *testing.T) {
+
+	ctx := t.Context()
+	reconciler, sfClient, waited := newSyncGuardTest(t)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	var results [2]*RefreshSalesforceResults
+	var errs [2]error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		results[0], errs[0] = reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, dataStartDate.AddDate(0, 1, 0))
+	}()
+	<-sfClient.started
+	go func() {
+		defer wg.Done()
+		results[1], errs[1] = reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, time.Time{})
+	}()
+	<-waited
+	close(sfClient.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("refresh %d error: %v", i, err)
+		}
+	}
+	if results[0].Attached || results[1].Attached {
+		t.Errorf("attached got %t, %t want false, false", results[0].Attached, results[1].Attached)
+	}
+	if !results[1].FullRefresh || results[0].FullRefresh {
+		t.Errorf("full refresh got %t, %t want false, true", results[0].FullRefresh, results[1].FullRefresh)
+	}
+	if got, want := sfClient.getCount, 2; got != want {
+		t.Errorf("got %d donation retrievals want %d", got, want)
+	}
+}
+
+// TestSyncGuardCancel tests that the refresh shared by several requests is not
+// cancelled when the request which started it is.
+func TestSyncGuardCancel(t *testing.T) {
+
+	reconciler, sfClient, waited := newSyncGuardTest(t)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	firstCtx, cancel := context.WithCancel(t.Context())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := reconciler.SalesforceRecordsRefresh(firstCtx, sfClient, dataStartDate, time.Time{})
+		firstErr <- err
+	}()
+	<-sfClient.started
+
+	var result *RefreshSalesforceResults
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err = reconciler.SalesforceRecordsRefresh(t.Context(), sfClient, dataStartDate, time.Time{})
+	}()
+	<-waited
+
+	cancel()
+	if got := <-firstErr; !errors.Is(got, context.Canceled) {
+		t.Errorf("cancelled refresh error got %v want %v", got, context.Canceled)
+	}
+	close(sfClient.release)
+	<-done
+	if err != nil {
+		t.Fatalf("attached refresh error: %v", err)
+	}
+	if !result.Attached || result.RecordsNo != 1 {
+		t.Errorf("attached refresh got attached %t with %d records", result.Attached, result.RecordsNo)
+	}
+}
+
+// TestSyncGuardRecordRefresh tests that a single record refresh waits for a running
+// refresh of its source.
+func TestSyncGuardRecordRefresh(t *testing.T) {
+
+	ctx := t.Context()
+	reconciler, sfClient, waited := newSyncGuardTest(t)
+	dataStartDate := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, time.Time{})
+		refreshed <- err
+	}()
+	<-sfClient.started
+
+	recordRefreshed := make(chan error, 1)
+	go func() {
+		recordRefreshed <- reconciler.RecordRefresh(ctx, nil, sfClient, "donation", "ID-1")
+	}()
+	<-waited
+	select {
+	case err := <-recordRefreshed:
+		t.Fatalf("record refreshed during the refresh: %v", err)
+	default:
+	}
+
+	close(sfClient.release)
+	if err := <-refreshed; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-recordRefreshed; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package domain
+
+// syncruns.go records the statistics of each refresh of the Xero and Salesforce
+// records as a sync run: the numbers of each type of record fetched, inserted, updated,
+// skipped and failed, and the api requests made, so that problems such as a refresh
+// retrieving no records are noticeable.
+
+import (
+	"context"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// syncRecordTypes are the types of record counted by a sync run.
+var syncRecordTypes = []string{"account", "contact", "invoice", "bank-transaction", "donation"}
+
+// syncRun collects the statistics of a refresh.
+type syncRun struct {
+	r       *Reconciler
+	run     db.SyncRun
+	counter APICallCounter // nil if the client does not count its requests
+	calls   int            // the requests counted before the refresh
+	skipped map[string]int // the skipped records counted before the refresh
+	counts  map[string]int // the stored records before the latest upsert
+}
+
+// syncRunStart starts collecting the statistics of a refresh of the records of source,
+// "xero" or "salesforce", using client.
+func (r *Reconciler) syncRunStart(ctx context.Context, source string, fullRefresh bool, client any) *syncRun {
+	s := &syncRun{
+		r: r,
+		run: db.SyncRun{
+			Source:      source,
+			FullRefresh: fullRefresh,
+			StartedAt:   time.Now(),
+		},
+		skipped: map[string]int{},
+	}
+	if c, ok := client.(APICallCounter); ok {
+		s.counter = c
+		s.calls = c.APICalls()
+		for _, recordType := range syncRecordTypes {
+			s.skipped[recordType] = c.RecordsSkipped(recordType)
+		}
+	}
+	s.counts = s.recordCounts(ctx)
+	return s
+}
+
+// recordCounts returns the number of stored records of each type, or nil if these
+// could not be counted.
+func (s *syncRun) recordCounts(ctx context.Context) map[string]int {
+	counts, err := s.r.db.SyncRecordCountsGet(ctx, s.run.Source)
+	if err != nil {
+		s.r.log.Warn("could not count the stored records for the sync statistics", "source", s.run.Source, "error", err)
+		return nil
+	}
+	return counts
+}
+
+// entity records the statistics of the records of recordType, of which fetched were
+// retrieved and not skipped, stored were upserted and failed were recorded as import
+// errors. The stored records which were not previously stored are counted as inserted.
+func (s *syncRun) entity(ctx context.Context, recordType string, fetched, stored, failed int) {
+	e := db.SyncRunEntity{
+		RecordType: recordType,
+		Fetched:    fetched,
+		Failed:     failed,
+	}
+	if s.counter != nil {
+		e.Skipped = s.counter.RecordsSkipped(recordType) - s.skipped[recordType]
+		e.Fetched += e.Skipped
+	}
+	counts := s.recordCounts(ctx)
+	if counts != nil && s.counts != nil {
+		e.Inserted = min(max(counts[recordType]-s.counts[recordType], 0), stored)
+	}
+	e.Updated = stored - e.Inserted
+	s.counts = counts
+	s.run.Entities = append(s.run.Entities, e)
+}
+
+// finish records the sync run, with the error which stopped the refresh, if any. A
+// failure to record the sync run is logged rather than failing the refresh.
+func (s *syncRun) finish(ctx context.Context, err error) {
+	s.run.FinishedAt = time.Now()
+	if s.counter != nil {
+		s.run.APICalls = s.counter.APICalls() - s.calls
+	}
+	if err != nil {
+		s.run.Error = err.Error()
+	}
+	// The sync run is recorded even if the refresh was cancelled.
+	if _, err := s.r.db.SyncRunInsert(context.WithoutCancel(ctx), s.run); err != nil {
+		s.r.log.Warn("could not record the sync run", "source", s.run.Source, "error", err)
+	}
+}
+
+// SyncRunsGet retrieves the most recent sync runs.
+func (r *Reconciler) SyncRunsGet(ctx context.Context) ([]db.SyncRun, error) {
+	runs, err := r.db.SyncRunsGet(ctx)
+	if err != nil {
+		return nil, ErrSystem{Detail: "db.SyncRunsGet error", Err: err, Msg: "A problem was encountered retrieving the sync history"}
+	}
+	return runs, nil
+}
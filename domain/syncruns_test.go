@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
+)
+
+// mockCountingXeroClient counts its requests, and returns an existing and a new invoice
+// having skipped three others.
+type mockCountingXeroClient struct {
+	mockXeroClient
+	skipped int
+}
+
+func (m *mockCountingXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
+	m.getCount++
+	m.skipped += 3
+	date := xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}
+	return []xero.Invoice{
+		{InvoiceID: "inv-002", Date: date},
+		{InvoiceID: "inv-sync-new", Date: date},
+	}, nil
+}
+
+func (m *mockCountingXeroClient) APICalls() int {
+	return m.getCount
+}
+
+func (m *mockCountingXeroClient) RecordsSkipped(recordType string) int {
+	if recordType == "invoice" {
+		return m.skipped
+	}
+	return 0
+}
+
+// mockSalesforceErrorClient fails to retrieve the donations.
+type mockSalesforceErrorClient struct {
+	mockSalesforceClient
+}
+
+func (m *mockSalesforceErrorClient) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error) {
+	return nil, errors.New("connection reset")
+}
+
+// TestSyncRuns tests that the statistics of each refresh are recorded.
+func TestSyncRuns(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	xeroClient := &mockCountingXeroClient{mockXeroClient: mockXeroClient{log: logger}}
+	if _, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Now(), regexp.MustCompile("."), false); err != nil {
+		t.Fatal(err)
+	}
+	sfClient := &mockSalesforceErrorClient{mockSalesforceClient{log: logger}}
+	if _, err := reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, time.Time{}); err == nil {
+		t.Fatal("expected a salesforce refresh error")
+	}
+
+	runs, err := reconciler.SyncRunsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("got %d sync runs want 2", len(runs))
+	}
+
+	sfRun, xeroRun := runs[0], runs[1]
+	if sfRun.Source != "salesforce" || !sfRun.FullRefresh || sfRun.Error == "" || len(sfRun.Entities) != 0 {
+		t.Errorf("unexpected salesforce sync run %+v", sfRun)
+	}
+
+	if xeroRun.Source != "xero" || xeroRun.FullRefresh || xeroRun.Error != "" || xeroRun.APICalls != 3 {
+		t.Errorf("unexpected xero sync run %+v", xeroRun)
+	}
+	want := db.SyncRunEntities{
+		{RecordType: "bank-transaction", Fetched: 1, Inserted: 1},
+		{RecordType: "invoice", Fetched: 5, Inserted: 1, Updated: 1, Skipped: 3},
+		{RecordType: "contact", Fetched: 1, Inserted: 1},
+	}
+	if diff := cmp.Diff(want, xeroRun.Entities); diff != "" {
+		t.Errorf("xero sync run entities mismatch (-want +got):\n%s", diff)
+	}
+}
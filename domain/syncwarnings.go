@@ -0,0 +1,86 @@
+package domain
+
+// syncwarnings.go reports the dates of the records retrieved by a refresh which are
+// missing or could not be parsed, and so are stored as the zero time, so that these are
+// surfaced in the refresh results rather than silently zeroed.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+)
+
+// SyncWarning reports a date of a refreshed record which is missing or could not be
+// parsed. Source is "xero" or "salesforce", Record the type of record, such as
+// "invoice", and Field the remote name of the date. Value is the remote value which
+// could not be parsed, or empty if the date is missing.
+type SyncWarning struct {
+	Source string
+	Record string
+	ID     string
+	Field  string
+	Value  string
+}
+
+// String describes the warning.
+func (w SyncWarning) String() string {
+	if w.Value == "" {
+		return fmt.Sprintf("%s %s %s has no %s", w.Source, w.Record, w.ID, w.Field)
+	}
+	return fmt.Sprintf("%s %s %s has an unreadable %s %q", w.Source, w.Record, w.ID, w.Field, w.Value)
+}
+
+// xeroDateWarnings returns the date warnings of the Xero records of type record.
+func xeroDateWarnings[T interface{ DateWarnings() []xero.DateWarning }](record string, records []T) []SyncWarning {
+	var warnings []SyncWarning
+	for _, rec := range records {
+		for _, dw := range rec.DateWarnings() {
+			warnings = append(warnings, SyncWarning{Source: "xero", Record: record, ID: dw.ID, Field: dw.Field, Value: dw.Value})
+		}
+	}
+	return warnings
+}
+
+// salesforceDateWarnings returns the date warnings of the Salesforce donations.
+func salesforceDateWarnings(donations []salesforce.Donation) []SyncWarning {
+	var warnings []SyncWarning
+	for _, d := range donations {
+		for _, dw := range d.DateWarnings() {
+			warnings = append(warnings, SyncWarning{Source: "salesforce", Record: "donation", ID: dw.ID, Field: dw.Field, Value: dw.Value})
+		}
+	}
+	return warnings
+}
+
+// logSyncWarnings logs the warnings.
+func (r *Reconciler) logSyncWarnings(warnings []SyncWarning) {
+	for _, w := range warnings {
+		r.log.Warn("refreshed record date warning", "source", w.Source, "record", w.Record, "id", w.ID, "field", w.Field, "value", w.Value)
+	}
+}
+
+// syncWarningsShown is the maximum number of warnings described by SyncWarningsMessage.
+const syncWarningsShown = 5
+
+// SyncWarningsMessage describes the warnings for a refresh message, returning an empty
+// string if there are none. Only the first few warnings are described, the others
+// being available in the log.
+func SyncWarningsMessage(warnings []SyncWarning) string {
+	if len(warnings) == 0 {
+		return ""
+	}
+	var descriptions []string
+	for _, w := range warnings[:min(len(warnings), syncWarningsShown)] {
+		descriptions = append(descriptions, w.String())
+	}
+	msg := fmt.Sprintf("%d refreshed records have missing or unreadable dates: %s", len(warnings), strings.Join(descriptions, "; "))
+	if len(warnings) == 1 {
+		msg = "1 refreshed record has a missing or unreadable date: " + descriptions[0]
+	}
+	if more := len(warnings) - len(descriptions); more > 0 {
+		msg += fmt.Sprintf(" and %d more (see the log)", more)
+	}
+	return msg + "."
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSyncWarningsMessage(t *testing.T) {
+
+	warning := func(i int) SyncWarning {
+		return SyncWarning{Source: "xero", Record: "invoice", ID: fmt.Sprintf("inv-%d", i), Field: "DateString", Value: "soon"}
+	}
+	var warnings []SyncWarning
+	for i := range 7 {
+		warnings = append(warnings, warning(i))
+	}
+
+	tests := []struct {
+		name     string
+		warnings []SyncWarning
+		want     string
+	}{
+		{"none", nil, ""},
+		{
+			"one",
+			[]SyncWarning{{Source: "salesforce", Record: "donation", ID: "006A", Field: "CloseDate"}},
+			"1 refreshed record has a missing or unreadable date: salesforce donation 006A has no CloseDate.",
+		},
+		{
+			"two",
+			warnings[:2],
+			`2 refreshed records have missing or unreadable dates: xero invoice inv-0 has an unreadable DateString "soon"; xero invoice inv-1 has an unreadable DateString "soon".`,
+		},
+		{
+			"more",
+			warnings,
+			`7 refreshed records have missing or unreadable dates: xero invoice inv-0 has an unreadable DateString "soon"; ` +
+				`xero invoice inv-1 has an unreadable DateString "soon"; xero invoice inv-2 has an unreadable DateString "soon"; ` +
+				`xero invoice inv-3 has an unreadable DateString "soon"; xero invoice inv-4 has an unreadable DateString "soon" ` +
+				`and 2 more (see the log).`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SyncWarningsMessage(tt.warnings); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
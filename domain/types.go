@@ -8,10 +8,12 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/rorycl/reconciler/apiclients/salesforce"
 	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
 )
 
 // XeroClient is an interface to the capabilities of a xero API client.
@@ -20,12 +22,58 @@ type XeroClient interface {
 	GetAccounts(ctx context.Context, ifModifiedSince time.Time) ([]xero.Account, error)
 	GetBankTransactions(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.BankTransaction, error)
 	GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error)
+	GetContacts(ctx context.Context, ifModifiedSince time.Time) ([]xero.Contact, error)
+	UpdateInvoiceReference(ctx context.Context, invoiceID, reference string) (xero.Invoice, error)
 }
 
 // SalesforceClient is an interface to the capabilities of a saleforce API client.
 type SalesforceClient interface {
 	BatchUpdateOpportunityRefs(ctx context.Context, idRefs []salesforce.IDRef, allOrNone bool) (salesforce.CollectionsUpdateResponse, error)
 	GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error)
+	GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error)
+	PreviewOpportunities(ctx context.Context, fromDate time.Time, limit int) ([]salesforce.Donation, error)
+	ValidateFieldMappings(ctx context.Context) ([]string, error)
+}
+
+// XeroInvoiceGetter is an optional capability of a XeroClient to retrieve a single
+// invoice, used to check that an invoice has not been changed in Xero before its
+// reference is updated, and to refresh a single invoice.
+type XeroInvoiceGetter interface {
+	GetInvoiceByID(ctx context.Context, invoiceID string) (xero.Invoice, error)
+}
+
+// XeroBankTransactionGetter is an optional capability of a XeroClient to retrieve a
+// single bank transaction, used to refresh a single bank transaction.
+type XeroBankTransactionGetter interface {
+	GetBankTransactionByID(ctx context.Context, uuid string) (xero.BankTransaction, error)
+}
+
+// XeroCreditGetter is an optional capability of a XeroClient to retrieve the
+// overpayments and prepayments received, which are reconciled as bank transactions.
+type XeroCreditGetter interface {
+	GetOverpayments(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Overpayment, error)
+	GetPrepayments(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Prepayment, error)
+}
+
+// SalesforceSubscriber is an optional capability of a SalesforceClient to subscribe to
+// Salesforce record change events.
+type SalesforceSubscriber interface {
+	SubscribeChanges(ctx context.Context, replayID int64, handle func(salesforce.ChangeEvent) error) error
+}
+
+// SalesforceDeletedGetter is an optional capability of a SalesforceClient to report
+// which records are deleted, used to distinguish deleted from otherwise missing
+// orphaned donations.
+type SalesforceDeletedGetter interface {
+	GetDeletedOpportunityIDs(ctx context.Context, ids []string) ([]string, error)
+}
+
+// APICallCounter is an optional capability of a XeroClient or SalesforceClient to
+// report the number of api requests it has made, and the number of records of a type
+// it retrieved but skipped as unwanted, recorded in the sync statistics.
+type APICallCounter interface {
+	APICalls() int
+	RecordsSkipped(recordType string) int
 }
 
 // ErrUsage is an error in usage
@@ -49,3 +97,63 @@ type ErrSystem struct {
 func (e ErrSystem) Error() string {
 	return fmt.Sprintf("%s: %s: %v", e.Detail, e.Msg, e.Err)
 }
+
+func (e ErrSystem) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotFound reports that a requested record does not exist.
+type ErrNotFound struct {
+	Detail string
+	Msg    string // user facing message
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("%s: %s", e.Detail, e.Msg)
+}
+
+// ErrConflict reports records changed in Xero or Salesforce since they were last
+// refreshed, which would be overwritten by writing a reference back to them.
+type ErrConflict struct {
+	Conflicts []Conflict
+}
+
+func (e ErrConflict) Error() string {
+	ids := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		ids[i] = c.RecordType + " " + c.ID
+	}
+	return fmt.Sprintf("records changed remotely since the last refresh: %s", strings.Join(ids, ", "))
+}
+
+// ErrQueued reports that the Salesforce updates of the link action with ID were queued
+// to be retried as Salesforce could not be reached.
+type ErrQueued struct {
+	ID  int64
+	Err error
+	Msg string // user facing message
+}
+
+func (e ErrQueued) Error() string {
+	return fmt.Sprintf("pending action %d queued: %v", e.ID, e.Err)
+}
+
+func (e ErrQueued) Unwrap() error {
+	return e.Err
+}
+
+// ErrLocked reports that records dated in the closed periods of Locks would be changed
+// by a link, unlink or write-back. The change may be made as a correction by giving a
+// reason to override the locks, which is recorded as an audit event.
+type ErrLocked struct {
+	Locks []db.PeriodLock
+	Msg   string // user facing message
+}
+
+func (e ErrLocked) Error() string {
+	periods := make([]string, len(e.Locks))
+	for i, l := range e.Locks {
+		periods[i] = l.String()
+	}
+	return fmt.Sprintf("records in locked periods: %s", strings.Join(periods, ", "))
+}
@@ -0,0 +1,185 @@
+package domain
+
+// validation.go validates the records retrieved from Xero and Salesforce before they
+// are upserted, quarantining the records which fail validation, such as for a missing
+// id, a missing date or one outside the range of sane dates, or an implausible amount,
+// as import errors rather than storing garbage rows.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// minRecordDate is the earliest sane date of a record.
+var minRecordDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// maxRecordYearsAhead is the number of years after today of the latest sane date of a
+// record, allowing for future dated invoices and pledges.
+const maxRecordYearsAhead = 10
+
+// maxRecordAmount is the largest sane amount of a record, or of one of its line items.
+const maxRecordAmount = money.Amount(1_000_000_000_00)
+
+// recordProblems collects the validation problems of a record.
+type recordProblems []string
+
+// add adds a problem.
+func (p *recordProblems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// id checks that the id field is not empty.
+func (p *recordProblems) id(field, id string) {
+	if strings.TrimSpace(id) == "" {
+		p.add("no %s", field)
+	}
+}
+
+// date checks that the required date field is present and within the range of sane
+// dates. invalid is the value of the date which could not be parsed, if any.
+func (p *recordProblems) date(field string, t time.Time, invalid string) {
+	maxDate := time.Now().AddDate(maxRecordYearsAhead, 0, 0)
+	switch {
+	case invalid != "":
+		p.add("unreadable %s %q", field, invalid)
+	case t.IsZero():
+		p.add("no %s", field)
+	case t.Before(minRecordDate) || t.After(maxDate):
+		p.add("%s %s outside the range %s to %s", field, t.Format(time.DateOnly), minRecordDate.Format(time.DateOnly), maxDate.Format(time.DateOnly))
+	}
+}
+
+// amount checks that the amount is within the sane range.
+func (p *recordProblems) amount(field string, a money.Amount) {
+	if a.Abs() > maxRecordAmount {
+		p.add("%s %s exceeds the maximum of %s", field, a, maxRecordAmount)
+	}
+}
+
+// number checks that the number, such as a currency rate, is finite and not negative.
+func (p *recordProblems) number(field string, f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+		p.add("%s %v is not a valid number", field, f)
+	}
+}
+
+// lineItems checks the ids and amounts of the line items of an invoice or bank
+// transaction.
+func (p *recordProblems) lineItems(items []xero.LineItem) {
+	for i, li := range items {
+		p.id(fmt.Sprintf("LineItemID for line item %d", i+1), li.LineItemID)
+		p.amount(fmt.Sprintf("line item %d LineAmount", i+1), li.LineAmount)
+		p.amount(fmt.Sprintf("line item %d UnitAmount", i+1), li.UnitAmount)
+		if math.IsNaN(li.Quantity) || math.IsInf(li.Quantity, 0) {
+			p.add("line item %d Quantity %v is not a valid number", i+1, li.Quantity)
+		}
+	}
+}
+
+// validateInvoice returns the validation problems of a Xero invoice.
+func validateInvoice(inv xero.Invoice) (string, []string) {
+	var p recordProblems
+	p.id("InvoiceID", inv.InvoiceID)
+	p.date("DateString", inv.Date.Time, inv.Date.Invalid)
+	p.amount("Total", inv.Total)
+	p.amount("AmountPaid", inv.AmountPaid)
+	p.number("CurrencyRate", inv.CurrencyRate)
+	p.lineItems(inv.LineItems)
+	return inv.InvoiceID, p
+}
+
+// validateBankTransaction returns the validation problems of a Xero bank transaction.
+func validateBankTransaction(bt xero.BankTransaction) (string, []string) {
+	var p recordProblems
+	p.id("BankTransactionID", bt.BankTransactionID)
+	p.date("DateString", bt.Date.Time, bt.Date.Invalid)
+	p.amount("Total", bt.Total)
+	p.number("CurrencyRate", bt.CurrencyRate)
+	p.lineItems(bt.LineItems)
+	return bt.BankTransactionID, p
+}
+
+// validateAccount returns the validation problems of a Xero account.
+func validateAccount(acc xero.Account) (string, []string) {
+	var p recordProblems
+	p.id("AccountID", acc.AccountID)
+	return acc.AccountID, p
+}
+
+// validateContact returns the validation problems of a Xero contact.
+func validateContact(c xero.Contact) (string, []string) {
+	var p recordProblems
+	p.id("ContactID", c.ContactID)
+	return c.ContactID, p
+}
+
+// validateDonation returns the validation problems of a Salesforce donation.
+func validateDonation(d salesforce.Donation) (string, []string) {
+	var p recordProblems
+	p.id("Id", d.ID)
+	p.date("CloseDate", d.CloseDate.Time, d.CloseDate.Invalid)
+	p.amount("Amount", d.Amount)
+	return d.ID, p
+}
+
+// validRecords returns the records of recordType from source which pass validation,
+// recording those which fail as import errors, and the number of records which failed.
+func validRecords[T any](
+	ctx context.Context,
+	r *Reconciler,
+	source, recordType string,
+	records []T,
+	validate func(T) (string, []string),
+) ([]T, int, error) {
+
+	var valid []T
+	var rejected []db.ImportError
+	for _, rec := range records {
+		id, problems := validate(rec)
+		if len(problems) == 0 {
+			valid = append(valid, rec)
+			continue
+		}
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			payload = fmt.Appendf(nil, "%q", fmt.Sprintf("%+v", rec))
+		}
+		rejected = append(rejected, db.ImportError{
+			Source:     source,
+			RecordType: recordType,
+			RecordID:   id,
+			Error:      strings.Join(problems, "; "),
+			Payload:    string(payload),
+		})
+		r.log.Warn("record failed validation", "source", source, "record", recordType, "id", id, "problems", strings.Join(problems, "; "))
+	}
+	if err := r.db.ImportErrorsUpsert(ctx, rejected); err != nil {
+		return valid, len(rejected), ErrSystem{
+			Detail: "db.ImportErrorsUpsert error",
+			Err:    err,
+			Msg:    "A problem was encountered recording the records which failed validation",
+		}
+	}
+	return valid, len(rejected), nil
+}
+
+// RejectedRecordsMessage describes the number of refreshed records which failed
+// validation or could not be upserted for a refresh message, returning an empty string if there are none.
+func RejectedRecordsMessage(n int) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return "1 refreshed record failed validation or could not be stored, and was recorded as an import error."
+	}
+	return fmt.Sprintf("%d refreshed records failed validation or could not be stored, and were recorded as import errors.", n)
+}
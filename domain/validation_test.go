@@ -0,0 +1,198 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestValidateRecords(t *testing.T) {
+
+	date := xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name     string
+		validate func() (string, []string)
+		want     []string
+	}{
+		{
+			"valid invoice",
+			func() (string, []string) {
+				return validateInvoice(xero.Invoice{InvoiceID: "inv-1", Date: date, Total: money.FromFloat(10), CurrencyRate: 1})
+			},
+			nil,
+		},
+		{
+			"invoice without id or date",
+			func() (string, []string) { return validateInvoice(xero.Invoice{}) },
+			[]string{"no InvoiceID", "no DateString"},
+		},
+		{
+			"invoice with unreadable date",
+			func() (string, []string) {
+				return validateInvoice(xero.Invoice{InvoiceID: "inv-1", Date: xero.XeroDateTime{Invalid: "soon"}})
+			},
+			[]string{`unreadable DateString "soon"`},
+		},
+		{
+			"invoice dated 1900",
+			func() (string, []string) {
+				return validateInvoice(xero.Invoice{InvoiceID: "inv-1", Date: xero.XeroDateTime{Time: time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)}})
+			},
+			[]string{"DateString 1900-01-01 outside the range"},
+		},
+		{
+			"bank transaction with implausible amounts",
+			func() (string, []string) {
+				return validateBankTransaction(xero.BankTransaction{
+					BankTransactionID: "bt-1",
+					Date:              date,
+					Total:             maxRecordAmount + 1,
+					CurrencyRate:      math.NaN(),
+					LineItems:         []xero.LineItem{{LineAmount: -maxRecordAmount - 1, Quantity: math.Inf(1)}},
+				})
+			},
+			[]string{
+				"Total 1000000000.01 exceeds the maximum",
+				"CurrencyRate NaN is not a valid number",
+				"no LineItemID for line item 1",
+				"line item 1 LineAmount -1000000000.01 exceeds the maximum",
+				"line item 1 Quantity +Inf is not a valid number",
+			},
+		},
+		{
+			"account without id",
+			func() (string, []string) { return validateAccount(xero.Account{Code: "5301"}) },
+			[]string{"no AccountID"},
+		},
+		{
+			"contact without id",
+			func() (string, []string) { return validateContact(xero.Contact{ContactID: " "}) },
+			[]string{"no ContactID"},
+		},
+		{
+			"donation dated far ahead",
+			func() (string, []string) {
+				return validateDonation(salesforce.Donation{CoreFields: salesforce.CoreFields{
+					ID:        "006A",
+					CloseDate: salesforce.SalesforceDate{Time: time.Now().AddDate(20, 0, 0)},
+				}})
+			},
+			[]string{"CloseDate " + time.Now().AddDate(20, 0, 0).Format(time.DateOnly) + " outside the range"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := tt.validate()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got problems %q want %q", got, tt.want)
+			}
+			for i, problem := range got {
+				if !strings.HasPrefix(problem, tt.want[i]) {
+					t.Errorf("problem %d got %q want prefix %q", i, problem, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// mockInvalidXeroClient returns an invoice which fails validation together with a
+// valid invoice.
+type mockInvalidXeroClient struct {
+	mockXeroClient
+}
+
+func (m *mockInvalidXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
+	return []xero.Invoice{
+		{InvoiceID: "inv-valid", Date: xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}},
+		{InvoiceID: "inv-invalid", Date: xero.XeroDateTime{Invalid: "31/06/2025"}},
+	}, nil
+}
+
+// mockInvalidSalesforceClient returns a donation without an id.
+type mockInvalidSalesforceClient struct {
+	mockSalesforceClient
+}
+
+func (m *mockInvalidSalesforceClient) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error) {
+	return []salesforce.Donation{{CoreFields: salesforce.CoreFields{
+		Name:      "no id",
+		CloseDate: salesforce.SalesforceDate{Time: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}}}, nil
+}
+
+// TestRefreshQuarantine tests that the refreshed records which fail validation are
+// recorded as import errors rather than upserted.
+func TestRefreshQuarantine(t *testing.T) {
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	ctx := t.Context()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	reconciler := NewReconciler(testDB, logger)
+	dataStartDate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	xeroClient := &mockInvalidXeroClient{mockXeroClient{log: logger}}
+	xeroResults, err := reconciler.XeroRecordsRefresh(ctx, xeroClient, dataStartDate, time.Now(), regexp.MustCompile("."), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xeroResults.InvoicesNo != 1 || xeroResults.RejectedNo != 1 {
+		t.Errorf("got %d invoices and %d rejected want 1 and 1", xeroResults.InvoicesNo, xeroResults.RejectedNo)
+	}
+	var n int
+	if err := testDB.Get(&n, "SELECT count(*) FROM invoices WHERE id IN ('inv-valid', 'inv-invalid')"); err != nil || n != 1 {
+		t.Errorf("got %d invoices, %v want only the valid invoice", n, err)
+	}
+
+	sfResults, err := reconciler.SalesforceRecordsRefresh(ctx, &mockInvalidSalesforceClient{mockSalesforceClient{log: logger}}, dataStartDate, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sfResults.RecordsNo != 0 || sfResults.RejectedNo != 1 {
+		t.Errorf("got %d donations and %d rejected want 0 and 1", sfResults.RecordsNo, sfResults.RejectedNo)
+	}
+
+	importErrors, err := testDB.ImportErrorsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, ie := range importErrors {
+		got[ie.Source+" "+ie.RecordType+" "+ie.RecordID] = ie.Error
+		if !strings.HasPrefix(ie.Payload, "{") {
+			t.Errorf("unexpected payload %q", ie.Payload)
+		}
+	}
+	want := map[string]string{
+		"xero invoice inv-invalid": `unreadable DateString "31/06/2025"`,
+		"salesforce donation ":     "no Id",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("import error %q got %q want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestRejectedRecordsMessage(t *testing.T) {
+	for n, want := range map[int]string{
+		0: "",
+		1: "1 refreshed record failed validation or could not be stored, and was recorded as an import error.",
+		3: "3 refreshed records failed validation or could not be stored, and were recorded as import errors.",
+	} {
+		if got := RejectedRecordsMessage(n); got != want {
+			t.Errorf("%d got %q want %q", n, got, want)
+		}
+	}
+}
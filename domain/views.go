@@ -6,6 +6,7 @@ import (
 	"html/template"
 
 	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 // ViewDonation  is a view version of the db.Donations type,
@@ -13,7 +14,7 @@ import (
 type ViewDonation struct {
 	ID              string
 	Name            string
-	Amount          float64
+	Amount          money.Amount
 	CloseDateStr    string
 	PayoutReference any // string or specific web-safe template.HTML
 	CreatedDateStr  string
@@ -23,7 +24,14 @@ type ViewDonation struct {
 	IsLinked        bool
 	LinkID          string
 	LinkTyper       string
+	LinkedBy        string
+	LinkedDateStr   string
+	RemotePending   bool
+	Annotations     int
+	Flagged         bool
+	AssignedTo      string
 	RowCount        int
+	SumAmount       money.Amount
 }
 
 // newViewDonations maps db.Donation records to a slice of ViewDonation.
@@ -36,7 +44,13 @@ func newViewDonations(donations []db.Donation) []ViewDonation {
 		dv[i].IsLinked = d.IsLinked
 		dv[i].LinkID = d.LinkID
 		dv[i].LinkTyper = d.LinkTyper
+		dv[i].LinkedBy = d.LinkedBy
+		dv[i].RemotePending = d.RemotePending
+		dv[i].Annotations = d.Annotations
+		dv[i].Flagged = d.Flagged
+		dv[i].AssignedTo = d.AssignedTo
 		dv[i].RowCount = d.RowCount
+		dv[i].SumAmount = d.SumAmount
 		// de-pointer
 		if d.PayoutReference == nil {
 			dv[i].PayoutReference = template.HTML("&mdash;") // fixme; should not be in domain logic
@@ -52,6 +66,9 @@ func newViewDonations(donations []db.Donation) []ViewDonation {
 		if d.ModifiedDate != nil {
 			dv[i].ModifiedDateStr = d.ModifiedDate.Format("02/01/2006")
 		}
+		if d.LinkedAt != nil {
+			dv[i].LinkedDateStr = d.LinkedAt.Format("02/01/2006")
+		}
 		if d.CreatedName != nil {
 			dv[i].CreatedName = *d.CreatedName
 		}
@@ -68,9 +85,9 @@ type ViewLineItem struct {
 	AccountCode    string
 	AccountName    string
 	Description    string
-	TaxAmount      float64
-	LineAmount     float64
-	DonationAmount float64
+	TaxAmount      money.Amount
+	LineAmount     money.Amount
+	DonationAmount money.Amount
 }
 
 // newViewLineItems converts a slice of WRLineItem to a slice of
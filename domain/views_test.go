@@ -8,6 +8,7 @@ import (
 	"github.com/rorycl/reconciler/db"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
 )
 
 /*
@@ -27,7 +28,7 @@ func TestViewDonation(t *testing.T) {
 		{
 			ID:              "id123",
 			Name:            "name1",
-			Amount:          123.4,
+			Amount:          money.FromFloat(123.4),
 			CloseDate:       new(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)),
 			PayoutReference: new("payout ref"),
 			CreatedDate:     new(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)),
@@ -42,7 +43,7 @@ func TestViewDonation(t *testing.T) {
 		{
 			ID:              "id_with_missing_fields",
 			Name:            "name1",
-			Amount:          123.4,
+			Amount:          money.FromFloat(123.4),
 			CloseDate:       nil,
 			PayoutReference: nil,
 			CreatedDate:     nil,
@@ -60,7 +61,7 @@ func TestViewDonation(t *testing.T) {
 		{
 			ID:              "id123",
 			Name:            "name1",
-			Amount:          123.4,
+			Amount:          money.FromFloat(123.4),
 			CloseDateStr:    "01/02/2026",
 			PayoutReference: "payout ref",
 			CreatedDateStr:  "01/01/2026",
@@ -75,7 +76,7 @@ func TestViewDonation(t *testing.T) {
 		{
 			ID:              "id_with_missing_fields",
 			Name:            "name1",
-			Amount:          123.4,
+			Amount:          money.FromFloat(123.4),
 			CloseDateStr:    "",
 			PayoutReference: template.HTML("&mdash;"),
 			CreatedDateStr:  "",
@@ -102,9 +103,9 @@ func TestViewLineItem(t *testing.T) {
 			AccountCode:    new("accode"),
 			AccountName:    new("acname"),
 			Description:    new("desc"),
-			TaxAmount:      new(123.4),
-			LineAmount:     new(0.25),
-			DonationAmount: new(0.20),
+			TaxAmount:      new(money.FromFloat(123.4)),
+			LineAmount:     new(money.FromFloat(0.25)),
+			DonationAmount: new(money.FromFloat(0.20)),
 		},
 		{
 			AccountCode:    nil,
@@ -121,9 +122,9 @@ func TestViewLineItem(t *testing.T) {
 			AccountCode:    "accode",
 			AccountName:    "acname",
 			Description:    "desc",
-			TaxAmount:      123.4,
-			LineAmount:     0.25,
-			DonationAmount: 0.2,
+			TaxAmount:      money.FromFloat(123.4),
+			LineAmount:     money.FromFloat(0.25),
+			DonationAmount: money.FromFloat(0.2),
 		},
 		{
 			AccountCode:    "",
@@ -0,0 +1,106 @@
+// package apistatus records the status of the connections to the Xero and Salesforce
+// apis: the time of the last successful call and the rate limit headroom reported by
+// the api in its response headers.
+//
+// The api clients are made afresh for each request, so each client package records its
+// calls in a package Recorder which outlives the clients.
+package apistatus
+
+import (
+	"sync"
+	"time"
+)
+
+// Limit is a rate limit reported by an api, such as the calls remaining in the day.
+type Limit struct {
+	Period    string // the period of the limit, such as "minute" or "day"
+	Remaining int
+	Max       int
+}
+
+// Status is the status of an api connection.
+type Status struct {
+	Account     string    // the Xero tenant ID or Salesforce instance url
+	TokenExpiry time.Time // the expiry of the access token
+	LastCall    time.Time // the time of the last successful call, or the zero time
+	Limits      []Limit   // the rate limits reported by the last successful call
+}
+
+// Recorder records the successful calls to an api. The zero value is ready to use.
+type Recorder struct {
+	mu       sync.Mutex
+	lastCall time.Time
+	limits   []Limit
+}
+
+// Record records a successful call at t with the rate limits reported by its response.
+// Limits not reported by the response are kept from earlier calls.
+func (r *Recorder) Record(t time.Time, limits ...Limit) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCall = t
+	for _, l := range limits {
+		replaced := false
+		for i := range r.limits {
+			if r.limits[i].Period == l.Period {
+				r.limits[i] = l
+				replaced = true
+			}
+		}
+		if !replaced {
+			r.limits = append(r.limits, l)
+		}
+	}
+}
+
+// Status returns the status of the connection with account and token expiry.
+func (r *Recorder) Status(account string, tokenExpiry time.Time) Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Status{
+		Account:     account,
+		TokenExpiry: tokenExpiry,
+		LastCall:    r.lastCall,
+		Limits:      append([]Limit(nil), r.limits...),
+	}
+}
+
+// Counter counts the requests made by an api client, and the records of each type it
+// retrieved but skipped, such as those filtered out by account code, for the sync
+// statistics. The zero value is ready to use.
+type Counter struct {
+	mu      sync.Mutex
+	calls   int
+	skipped map[string]int
+}
+
+// Call counts a request.
+func (c *Counter) Call() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+}
+
+// Skip counts n skipped records of recordType.
+func (c *Counter) Skip(recordType string, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.skipped == nil {
+		c.skipped = map[string]int{}
+	}
+	c.skipped[recordType] += n
+}
+
+// Calls returns the number of requests counted.
+func (c *Counter) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+// Skipped returns the number of skipped records of recordType counted.
+func (c *Counter) Skipped(recordType string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skipped[recordType]
+}
@@ -0,0 +1,116 @@
+package apistatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecorder(t *testing.T) {
+
+	var r Recorder
+	expiry := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if diff := cmp.Diff(Status{Account: "tenant", TokenExpiry: expiry}, r.Status("tenant", expiry)); diff != "" {
+		t.Errorf("initial status mismatch (-want +got):\n%s", diff)
+	}
+
+	first := time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)
+	r.Record(first, Limit{"minute", 59, 60}, Limit{"day", 4999, 5000})
+	second := first.Add(time.Minute)
+	r.Record(second, Limit{"minute", 58, 60})
+
+	want := Status{
+		Account:     "tenant",
+		TokenExpiry: expiry,
+		LastCall:    second,
+		Limits:      []Limit{{"minute", 58, 60}, {"day", 4999, 5000}},
+	}
+	got := r.Status("tenant", expiry)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("status mismatch (-want +got):\n%s", diff)
+	}
+
+	// The limits of a status are a copy.
+	got.Limits[0].Remaining = 0
+	if r.Status("", time.Time{}).Limits[0].Remaining != 58 {
+		t.Error("status limits share the recorder's limits")
+	}
+}
+
+func TestCounter(t *testing.T) {
+	var c Counter
+	c.Call()
+	c.Call()
+	c.Skip("invoice", 3)
+	c.Skip("invoice", 1)
+	if got, want := c.Calls(), 2; got != want {
+		t.Errorf("calls got %d want %d", got, want)
+	}
+	if got, want := c.Skipped("invoice"), 4; got != want {
+		t.Errorf("skipped invoices got %d want %d", got, want)
+	}
+	if got := c.Skipped("bank-transaction"); got != 0 {
+		t.Errorf("skipped bank transactions got %d want 0", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-5", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("Retry-After", tt.header)
+		}
+		if got := RetryAfter(h, now); got != tt.want {
+			t.Errorf("retry after %q got %s want %s", tt.header, got, tt.want)
+		}
+	}
+
+	err := ErrRateLimited{API: "xero", Limit: "minute", RetryAfter: 30 * time.Second}
+	if got, want := err.Error(), "xero api rate limit exceeded (minute), retry after 30s"; got != want {
+		t.Errorf("error got %q want %q", got, want)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+
+	dialErr := &url.Error{Op: "Post", URL: "https://example.my.salesforce.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other", errors.New("decoding error"), false},
+		{"network", fmt.Errorf("failed to execute request: %w", dialErr), true},
+		{"timeout", fmt.Errorf("wrapped: %w", context.DeadlineExceeded), true},
+		{"cancelled", fmt.Errorf("wrapped: %w", context.Canceled), false},
+		{"unavailable", ErrRemoteAPI{API: "salesforce", StatusCode: http.StatusServiceUnavailable}, true},
+		{"bad request", ErrRemoteAPI{API: "salesforce", StatusCode: http.StatusBadRequest}, false},
+		{"rate limited", fmt.Errorf("wrapped: %w", ErrRateLimited{API: "salesforce"}), true},
+	}
+	for _, tt := range tests {
+		if got := Unavailable(tt.err); got != tt.want {
+			t.Errorf("%s got %t want %t", tt.name, got, tt.want)
+		}
+	}
+}
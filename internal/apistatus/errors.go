@@ -0,0 +1,88 @@
+package apistatus
+
+// errors.go sets out the errors of unsuccessful api calls, shared by the api clients so
+// that callers can report remote failures and rate limiting alike for both platforms.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRemoteAPI reports an unsuccessful response from an api.
+type ErrRemoteAPI struct {
+	API        string // "xero" or "salesforce"
+	StatusCode int
+	Code       string // the api error code or type, if reported
+	Message    string
+}
+
+func (e ErrRemoteAPI) Error() string {
+	code := ""
+	if e.Code != "" {
+		code = " " + e.Code
+	}
+	return fmt.Sprintf("%s api error (status %d%s): %s", e.API, e.StatusCode, code, e.Message)
+}
+
+// ErrRateLimited reports that an api refused a call as a rate limit was exceeded. The
+// call may be retried after RetryAfter, if known.
+type ErrRateLimited struct {
+	API        string
+	Limit      string // the limit exceeded, if reported
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	msg := fmt.Sprintf("%s api rate limit exceeded", e.API)
+	if e.Limit != "" {
+		msg += " (" + e.Limit + ")"
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(", retry after %s", e.RetryAfter)
+	}
+	return msg
+}
+
+// RetryAfter parses the Retry-After header of h, which is either a number of seconds
+// or a date, returning zero if it is missing or invalid.
+func RetryAfter(h http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// Unavailable reports whether err shows that an api could not be reached or is
+// temporarily unable to handle calls, so that the call may succeed if retried later.
+// These are network errors other than the cancellation of the caller's context, the
+// gateway and service unavailable statuses, and exceeded rate limits.
+func Unavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if _, ok := errors.AsType[ErrRateLimited](err); ok {
+		return true
+	}
+	if e, ok := errors.AsType[ErrRemoteAPI](err); ok {
+		switch e.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	_, ok := errors.AsType[net.Error](err)
+	return ok
+}
@@ -0,0 +1,181 @@
+// package backup writes rotated database backups to a directory. Backups are database
+// snapshots named by their creation time, of which the most recent are kept. Backups
+// can be made on demand or on a schedule with Run.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// nameLayout is the time layout of backup file names.
+const nameLayout = "20060102-150405.000"
+
+// validName matches backup file names.
+var validName = regexp.MustCompile(`^reconciler-\d{8}-\d{6}\.\d{3}\.db$`)
+
+// Snapshotter writes a database snapshot to a new file at path.
+type Snapshotter func(ctx context.Context, path string) error
+
+// Backup describes a backup file.
+type Backup struct {
+	Name string
+	Time time.Time
+	Size int64
+}
+
+// Manager writes backups to a directory, keeping the most recent.
+type Manager struct {
+	dir      string
+	keep     int
+	snapshot Snapshotter
+	log      *slog.Logger
+	mu       sync.Mutex // serialises backups and restores
+	now      func() time.Time
+}
+
+// New returns a Manager writing backups to dir, which is created if necessary, and
+// keeping the most recent keep backups.
+func New(dir string, keep int, snapshot Snapshotter, logger *slog.Logger) (*Manager, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("backups to keep must be at least 1, got %d", keep)
+	}
+	if snapshot == nil {
+		return nil, errors.New("backup snapshotter is nil")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create backup directory: %w", err)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{
+		dir:      dir,
+		keep:     keep,
+		snapshot: snapshot,
+		log:      logger,
+		now:      time.Now,
+	}, nil
+}
+
+// Dir returns the backup directory.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create writes a new backup, removing the oldest backups beyond the number to keep.
+func (m *Manager) Create(ctx context.Context) (Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Backup names are unique to the millisecond.
+	t := m.now().UTC().Truncate(time.Millisecond)
+	var name, path string
+	for {
+		name = "reconciler-" + t.Format(nameLayout) + ".db"
+		path = filepath.Join(m.dir, name)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		t = t.Add(time.Millisecond)
+	}
+	if err := m.snapshot(ctx, path); err != nil {
+		return Backup{}, fmt.Errorf("backup %s failed: %w", name, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("backup %s not found: %w", name, err)
+	}
+	m.log.Info(fmt.Sprintf("wrote backup %s", path))
+
+	backups, err := m.list()
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) > m.keep {
+		for _, old := range backups[m.keep:] {
+			if err := os.Remove(filepath.Join(m.dir, old.Name)); err != nil {
+				m.log.Error(fmt.Sprintf("could not remove old backup %s: %v", old.Name, err))
+				continue
+			}
+			m.log.Info(fmt.Sprintf("removed old backup %s", old.Name))
+		}
+	}
+	return Backup{Name: name, Time: t, Size: info.Size()}, nil
+}
+
+// List returns the backups, newest first.
+func (m *Manager) List() ([]Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.list()
+}
+
+func (m *Manager) list() ([]Backup, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read backup directory: %w", err)
+	}
+	var backups []Backup
+	for _, e := range entries {
+		if e.IsDir() || !validName.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		t, err := time.Parse(nameLayout, e.Name()[len("reconciler-"):len(e.Name())-len(".db")])
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Name: e.Name(), Time: t, Size: info.Size()})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.Time.Compare(a.Time) })
+	return backups, nil
+}
+
+// Restore runs restore with the path of the named backup, serialised with the
+// creation of backups.
+func (m *Manager) Restore(ctx context.Context, name string, restore func(ctx context.Context, path string) error) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("backup %s not found: %w", name, err)
+	}
+	if err := restore(ctx, path); err != nil {
+		return err
+	}
+	m.log.Info(fmt.Sprintf("restored backup %s", path))
+	return nil
+}
+
+// Run creates a backup every interval until the context is cancelled. Errors are
+// logged.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Create(ctx); err != nil {
+				m.log.Error(fmt.Sprintf("scheduled backup error: %v", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackups(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	snapshot := func(_ context.Context, path string) error {
+		return os.WriteFile(path, []byte(path), 0o400)
+	}
+	m, err := New(dir, 3, snapshot, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other files in the directory are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	for i := range 5 {
+		m.now = func() time.Time { return start.Add(time.Duration(i) * time.Hour) }
+		if _, err := m.Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Backups made within the same millisecond have distinct names.
+	m.keep = 10
+	for range 2 {
+		if _, err := m.Create(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.keep = 3
+	if _, err := m.Create(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(backups), 3; got != want {
+		t.Fatalf("backups got %d want %d", got, want)
+	}
+	if got, want := backups[0].Name, "reconciler-20260331-160000.003.db"; got != want {
+		t.Errorf("newest backup got %q want %q", got, want)
+	}
+	if got, want := backups[2].Time, start.Add(4*time.Hour+time.Millisecond); !got.Equal(want) {
+		t.Errorf("oldest backup time got %s want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("expected other files to be kept: %v", err)
+	}
+
+	var restored string
+	restore := func(_ context.Context, path string) error {
+		restored = path
+		return nil
+	}
+	if err := m.Restore(ctx, backups[1].Name, restore); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := restored, filepath.Join(dir, backups[1].Name); got != want {
+		t.Errorf("restored got %q want %q", got, want)
+	}
+	for _, name := range []string{"../reconciler-20260331-160000.000.db", "notes.txt", "reconciler-20200101-000000.000.db"} {
+		if err := m.Restore(ctx, name, restore); err == nil {
+			t.Errorf("expected an error restoring %q", name)
+		}
+	}
+
+	failing := func(context.Context, string) error { return errors.New("snapshot failed") }
+	if _, err := New(dir, 0, failing, nil); err == nil {
+		t.Error("expected an error keeping no backups")
+	}
+	m.snapshot = failing
+	if _, err := m.Create(ctx); err == nil {
+		t.Error("expected a snapshot error")
+	}
+}
@@ -0,0 +1,66 @@
+// package csvsafe writes CSV files which may be opened safely in a spreadsheet.
+//
+// The names, references and descriptions of the records come from Xero and Salesforce,
+// and a cell starting with "=", "+", "-" or "@" is run as a formula by Excel and other
+// spreadsheets when the file is opened. Such cells are prefixed with a single quote so
+// that they are shown as text. Cells which are numbers, such as negative amounts, are
+// written unchanged.
+package csvsafe
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// Writer is a csv.Writer which neutralises the cells of the records it writes.
+type Writer struct {
+	*csv.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{csv.NewWriter(w)}
+}
+
+// Write writes a single record to w after neutralising its cells.
+func (w *Writer) Write(record []string) error {
+	return w.Writer.Write(Record(record))
+}
+
+// WriteAll writes multiple records to w after neutralising their cells, and flushes
+// the output.
+func (w *Writer) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Record returns a copy of record with each cell neutralised.
+func Record(record []string) []string {
+	safe := make([]string, len(record))
+	for i, cell := range record {
+		safe[i] = Cell(cell)
+	}
+	return safe
+}
+
+// Cell returns the cell prefixed with a single quote if a spreadsheet would read it as
+// a formula.
+func Cell(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return s
+		}
+		return "'" + s
+	}
+	return s
+}
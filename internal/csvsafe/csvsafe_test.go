@@ -0,0 +1,43 @@
+package csvsafe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"Jane Giver", "Jane Giver"},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+44 20 7946 0000", "'+44 20 7946 0000"},
+		{"-cmd", "'-cmd"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"-12.50", "-12.50"},
+		{"+3", "+3"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := Cell(tt.in); got != tt.want {
+			t.Errorf("Cell(%q) got %q want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	if err := w.Write([]string{"name", "amount"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteAll([][]string{{"=1+1", "-2.00"}, {"Jane", "3.00"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "name,amount\n'=1+1,-2.00\nJane,3.00\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,287 @@
+// package donorimport reads donations exported as CSV or XLSX from a CRM other than
+// Salesforce. The columns of an export vary from one CRM to another, so the columns of
+// a Sheet are mapped to the salesforce.CoreFields by a Mapping, from which each row is
+// validated and converted to a salesforce.Donation for upsert with the Salesforce
+// donations.
+//
+// The ids of imported donations are prefixed with the source tag of the import, so that
+// they cannot collide with Salesforce ids or the ids of another import.
+package donorimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrUnsupportedFile reports a file which is neither a CSV nor an XLSX file.
+var ErrUnsupportedFile = errors.New("only csv and xlsx files are supported")
+
+// Sheet is the header and rows of an uploaded file.
+type Sheet struct {
+	FileName string
+	Headers  []string
+	Rows     [][]string
+}
+
+// Field is a salesforce.CoreFields field which may be mapped to a column.
+type Field struct {
+	Name     string
+	Label    string
+	Required bool
+}
+
+// Fields are the fields which may be mapped, in the order shown for mapping. The
+// created and modified dates of imported donations are the time of import.
+var Fields = []Field{
+	{"ID", "Donation id", true},
+	{"Name", "Donor name", true},
+	{"Amount", "Amount", true},
+	{"CloseDate", "Date", true},
+	{"PayoutReference", "Payout reference", false},
+	{"CreatedBy", "Created by", false},
+}
+
+// Mapping maps the name of each mapped Field to the index of its column.
+type Mapping map[string]int
+
+// validSource matches a valid source tag.
+var validSource = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}$`)
+
+// validID matches a valid donation id, which must be usable in a url path.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// dateLayouts are the date formats accepted for the CloseDate, including the default
+// date format of excel cells.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2/1/2006",
+	"2/1/2006 15:04",
+	"01-02-06",
+}
+
+// ValidSource reports if source is a valid source tag, being up to 31 lower case
+// letters, digits and hyphens. The tag "salesforce" is reserved.
+func ValidSource(source string) error {
+	if source == "salesforce" {
+		return errors.New(`the source "salesforce" is reserved`)
+	}
+	if !validSource.MatchString(source) {
+		return fmt.Errorf("invalid source %q: use up to 31 lower case letters, digits and hyphens", source)
+	}
+	return nil
+}
+
+// Read reads a CSV or XLSX file, the type being determined by the extension of
+// fileName. The first row is the header; empty rows are skipped. Only the first sheet
+// of an XLSX file is read.
+func Read(r io.Reader, fileName string) (*Sheet, error) {
+	var (
+		records [][]string
+		err     error
+	)
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		records, err = cr.ReadAll()
+	case ".xlsx":
+		records, err = readXLSX(r)
+	default:
+		return nil, ErrUnsupportedFile
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := &Sheet{FileName: filepath.Base(fileName)}
+	for _, record := range records {
+		if !slices.ContainsFunc(record, func(s string) bool { return strings.TrimSpace(s) != "" }) {
+			continue
+		}
+		if sheet.Headers == nil {
+			for _, h := range record {
+				sheet.Headers = append(sheet.Headers, strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+			}
+			continue
+		}
+		sheet.Rows = append(sheet.Rows, record)
+	}
+	if sheet.Headers == nil {
+		return nil, errors.New("the file is empty")
+	}
+	return sheet, nil
+}
+
+// readXLSX reads the rows of the first sheet of an XLSX file.
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not open xlsx file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	sheetName := f.GetSheetName(0)
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("could not read sheet %q: %w", sheetName, err)
+	}
+	return rows, nil
+}
+
+// normalise returns the comparable form of a column heading or field label.
+func normalise(s string) string {
+	s = strings.ToLower(strings.NewReplacer("_", " ", "-", " ").Replace(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Suggest suggests a mapping of the headers, mapping each field to the first column
+// whose heading matches the name or label of the field, ignoring case and separators.
+func Suggest(headers []string) Mapping {
+	m := Mapping{}
+	for _, f := range Fields {
+		for i, h := range headers {
+			h = normalise(h)
+			if h == normalise(f.Name) || h == normalise(f.Label) {
+				m[f.Name] = i
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Validate reports an error if a required field is not mapped or a field is mapped to
+// a column not in headers.
+func (m Mapping) Validate(headers []string) error {
+	for _, f := range Fields {
+		i, ok := m[f.Name]
+		if !ok {
+			if f.Required {
+				return fmt.Errorf("the %s field must be mapped to a column", strings.ToLower(f.Label))
+			}
+			continue
+		}
+		if i < 0 || i >= len(headers) {
+			return fmt.Errorf("the %s field is mapped to an unknown column", strings.ToLower(f.Label))
+		}
+	}
+	return nil
+}
+
+// RowError reports an invalid value in a row of a Sheet. Rows are numbered from 2, the
+// header being row 1.
+type RowError struct {
+	Row   int
+	Field string
+	Err   error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d %s: %v", e.Row, e.Field, e.Err)
+}
+
+// Row is a row of a Sheet converted to a donation. The ID is the id of the row, without
+// the source prefix. An invalid row has a RowError as its Err, and an incomplete
+// Donation.
+type Row struct {
+	Number   int
+	Values   []string
+	ID       string
+	Donation salesforce.Donation
+	Err      error
+}
+
+// Convert converts the rows of the sheet to donations using the mapping, giving the
+// ids the source prefix and the created and modified dates of now. Rows with invalid
+// values or repeating the id of an earlier row report a RowError.
+func (s *Sheet) Convert(m Mapping, source string, now time.Time) []Row {
+	var (
+		rows = make([]Row, len(s.Rows))
+		seen = map[string]bool{}
+	)
+	for n, record := range s.Rows {
+		rows[n] = convert(record, n+2, m, source, now, seen)
+	}
+	return rows
+}
+
+// convert converts the record at row number to a donation, recording its id in seen.
+func convert(record []string, number int, m Mapping, source string, now time.Time, seen map[string]bool) Row {
+	field := func(name string) string {
+		if i, ok := m[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	row := Row{Number: number, Values: record, ID: field("ID")}
+	fail := func(name string, err error) Row {
+		row.Err = RowError{Row: number, Field: name, Err: err}
+		return row
+	}
+
+	switch {
+	case row.ID == "":
+		return fail("ID", errors.New("empty id"))
+	case !validID.MatchString(row.ID):
+		return fail("ID", fmt.Errorf("invalid id %q", row.ID))
+	case seen[row.ID]:
+		return fail("ID", fmt.Errorf("duplicate id %q", row.ID))
+	}
+	seen[row.ID] = true
+
+	name := field("Name")
+	if name == "" {
+		return fail("Name", errors.New("empty name"))
+	}
+	amount, err := money.Parse(strings.NewReplacer("£", "", "$", "", "€", "", ",", "").Replace(field("Amount")))
+	if err != nil {
+		return fail("Amount", err)
+	}
+	closeDate, err := parseDate(field("CloseDate"))
+	if err != nil {
+		return fail("CloseDate", err)
+	}
+
+	row.Donation = salesforce.Donation{
+		CoreFields: salesforce.CoreFields{
+			ID:               source + "-" + row.ID,
+			Name:             name,
+			Amount:           amount,
+			CloseDate:        salesforce.SalesforceDate{Time: closeDate},
+			CreatedDate:      salesforce.SalesforceTime{Time: now},
+			LastModifiedDate: salesforce.SalesforceTime{Time: now},
+			CreatedBy:        salesforce.FlattenedName(field("CreatedBy")),
+			LastModifiedBy:   salesforce.FlattenedName(field("CreatedBy")),
+		},
+		AdditionalFields: map[string]any{"ImportID": row.ID},
+	}
+	if ref := field("PayoutReference"); ref != "" {
+		row.Donation.PayoutReference = &ref
+	}
+	return row
+}
+
+// parseDate parses a date in any of the dateLayouts.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
@@ -0,0 +1,127 @@
+package donorimport
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestRead(t *testing.T) {
+
+	csv := "\ufeffGift ID,Donor Name,Amount,Date\n\n1001,Jane Smith,£20.00,14/04/2025\n,,,\n"
+	sheet, err := Read(strings.NewReader(csv), "/tmp/export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Sheet{
+		FileName: "export.csv",
+		Headers:  []string{"Gift ID", "Donor Name", "Amount", "Date"},
+		Rows:     [][]string{{"1001", "Jane Smith", "£20.00", "14/04/2025"}},
+	}
+	if diff := cmp.Diff(want, sheet); diff != "" {
+		t.Errorf("csv sheet mismatch (-want +got):\n%s", diff)
+	}
+
+	f := excelize.NewFile()
+	for i, row := range [][]any{{"Gift ID", "Donor Name", "Amount", "Date"}, {"1001", "Jane Smith", "£20.00", "14/04/2025"}} {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow("Sheet1", cell, &row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	sheet, err = Read(&buf, "export.XLSX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.FileName = "export.XLSX"
+	if diff := cmp.Diff(want, sheet); diff != "" {
+		t.Errorf("xlsx sheet mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := Read(strings.NewReader(""), "export.txt"); !errors.Is(err, ErrUnsupportedFile) {
+		t.Errorf("expected ErrUnsupportedFile, got %v", err)
+	}
+	if _, err := Read(strings.NewReader(""), "export.csv"); err == nil {
+		t.Error("expected an error for an empty file")
+	}
+}
+
+func TestSuggestAndValidate(t *testing.T) {
+
+	headers := []string{"donation_id", "Donor Name", "AMOUNT", "Gift Date", "Payout-Reference"}
+	m := Suggest(headers)
+	if diff := cmp.Diff(Mapping{"ID": 0, "Name": 1, "Amount": 2, "PayoutReference": 4}, m); diff != "" {
+		t.Errorf("suggestion mismatch (-want +got):\n%s", diff)
+	}
+	if err := m.Validate(headers); err == nil || !strings.Contains(err.Error(), "date") {
+		t.Errorf("expected an unmapped date error, got %v", err)
+	}
+	m["CloseDate"] = 5
+	if err := m.Validate(headers); err == nil || !strings.Contains(err.Error(), "unknown column") {
+		t.Errorf("expected an unknown column error, got %v", err)
+	}
+	m["CloseDate"] = 3
+	if err := m.Validate(headers); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+
+	sheet := &Sheet{
+		Headers: []string{"ID", "Name", "Amount", "Date", "Payout"},
+		Rows: [][]string{
+			{"1001", "Jane Smith", "1,020.50", "2025-04-14", "JG-1"},
+			{"1002", "John Smith", "5", "3/4/2025", ""},
+			{"1001", "Jane Smith", "20", "2025-04-14", ""},
+			{"10 03", "Bad Id", "20", "2025-04-14", ""},
+			{"1004", "Bad Amount", "ten", "2025-04-14", ""},
+			{"1005", "Bad Date", "10", "April", ""},
+		},
+	}
+	now := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	rows := sheet.Convert(Mapping{"ID": 0, "Name": 1, "Amount": 2, "CloseDate": 3, "PayoutReference": 4}, "crm", now)
+
+	if got, want := len(rows), len(sheet.Rows); got != want {
+		t.Fatalf("got %d rows want %d", got, want)
+	}
+	d := rows[0].Donation
+	if rows[0].Err != nil || d.ID != "crm-1001" || d.Amount != money.FromFloat(1020.50) || d.PayoutReference == nil || *d.PayoutReference != "JG-1" {
+		t.Errorf("unexpected row %+v", rows[0])
+	}
+	d = rows[1].Donation
+	if got, want := d.CloseDate.Time, time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("close date got %s want %s", got, want)
+	}
+	if rows[1].Err != nil || d.PayoutReference != nil || !d.CreatedDate.Equal(now) {
+		t.Errorf("unexpected row %+v", rows[1])
+	}
+
+	var got []string
+	for _, row := range rows[2:] {
+		if row.Err == nil {
+			t.Errorf("row %d has no error", row.Number)
+			continue
+		}
+		got = append(got, row.Err.Error())
+	}
+	want := []string{
+		`row 4 ID: duplicate id "1001"`,
+		`row 5 ID: invalid id "10 03"`,
+		`row 6 Amount: invalid amount: "ten"`,
+		`row 7 CloseDate: invalid date "April"`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("row errors mismatch (-want +got):\n%s", diff)
+	}
+}
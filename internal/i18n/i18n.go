@@ -0,0 +1,234 @@
+// package i18n provides the message catalogues of the web templates and locale-aware
+// date and money formatting. Each locale is an embedded json file in the locales
+// directory named by its language tag, such as "en-GB.json". Messages missing from a
+// locale fall back to the DefaultLocale, and then to the message key itself.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// DefaultLocale is the locale used if none is configured or a locale is not known.
+const DefaultLocale = "en-GB"
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// Locale is a message catalogue with the date and number formats of a locale.
+type Locale struct {
+	Tag  string `json:"-"`
+	Name string `json:"name"` // the name of the locale in its own language
+
+	// DateFormat and DateTimeFormat are Go time layouts. The "Jan" month name in
+	// LongDateFormat is replaced with the locale's month abbreviation.
+	DateFormat     string   `json:"date_format"`
+	DateTimeFormat string   `json:"datetime_format"`
+	LongDateFormat string   `json:"long_date_format"`
+	Months         []string `json:"months"`
+
+	// Decimal and Thousands are the number separators. The currency symbol is placed
+	// after the amount if SymbolAfter is set, separated by SymbolSpace.
+	Decimal     string `json:"decimal"`
+	Thousands   string `json:"thousands"`
+	SymbolAfter bool   `json:"symbol_after"`
+	SymbolSpace string `json:"symbol_space"`
+
+	Messages map[string]string `json:"messages"`
+}
+
+// locales loads the embedded locales once, keyed by tag. The locales are part of the
+// program, so an invalid locale file is a programming error.
+var locales = sync.OnceValue(func() map[string]*Locale {
+	files, err := fs.Glob(localesFS, "locales/*.json")
+	if err != nil {
+		panic(err)
+	}
+	loaded := map[string]*Locale{}
+	for _, f := range files {
+		b, err := localesFS.ReadFile(f)
+		if err != nil {
+			panic(err)
+		}
+		l := &Locale{Tag: strings.TrimSuffix(path.Base(f), ".json")}
+		if err := json.Unmarshal(b, l); err != nil {
+			panic(fmt.Sprintf("locale %s: %v", f, err))
+		}
+		if len(l.Months) != 12 {
+			panic(fmt.Sprintf("locale %s: %d month names, not 12", f, len(l.Months)))
+		}
+		loaded[l.Tag] = l
+	}
+	if _, ok := loaded[DefaultLocale]; !ok {
+		panic("default locale " + DefaultLocale + " not found")
+	}
+	return loaded
+})
+
+// Supported reports whether the locale with tag is available.
+func Supported(tag string) bool {
+	_, ok := locales()[tag]
+	return ok
+}
+
+// Get returns the locale with tag, or the default locale if it is not available.
+func Get(tag string) *Locale {
+	if l, ok := locales()[tag]; ok {
+		return l
+	}
+	return locales()[DefaultLocale]
+}
+
+// Locales returns the available locales in tag order.
+func Locales() []*Locale {
+	var all []*Locale
+	for _, l := range locales() {
+		all = append(all, l)
+	}
+	slices.SortFunc(all, func(a, b *Locale) int { return strings.Compare(a.Tag, b.Tag) })
+	return all
+}
+
+// T returns the message with key, formatted with args if provided.
+func (l *Locale) T(key string, args ...any) string {
+	msg, ok := l.Messages[key]
+	if !ok {
+		msg, ok = locales()[DefaultLocale].Messages[key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// FormatDate formats t as a short numeric date. The zero time is formatted as an
+// empty string.
+func (l *Locale) FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(l.DateFormat)
+}
+
+// FormatDateTime formats t as a numeric date and time.
+func (l *Locale) FormatDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(l.DateTimeFormat)
+}
+
+// FormatLongDate formats t with the locale's month abbreviation.
+func (l *Locale) FormatLongDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	const month = "\x00"
+	s := t.Format(strings.Replace(l.LongDateFormat, "Jan", month, 1))
+	return strings.Replace(s, month, l.Months[t.Month()-1], 1)
+}
+
+// FormatNumber formats the amount with two decimal places and the locale's separators.
+func (l *Locale) FormatNumber(a money.Amount) string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+	}
+	whole, minor, _ := strings.Cut(a.Abs().String(), ".")
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(l.Thousands)
+		}
+		grouped.WriteRune(r)
+	}
+	return sign + grouped.String() + l.Decimal + minor
+}
+
+// FormatMoney formats the amount as FormatNumber with the currency symbol placed for
+// the locale.
+func (l *Locale) FormatMoney(a money.Amount, symbol string) string {
+	n := l.FormatNumber(a)
+	if l.SymbolAfter {
+		return n + l.SymbolSpace + symbol
+	}
+	if abs, ok := strings.CutPrefix(n, "-"); ok {
+		return "-" + symbol + l.SymbolSpace + abs
+	}
+	return symbol + l.SymbolSpace + n
+}
+
+// currencySymbols are the symbols of the commonly used currencies, keyed by ISO 4217
+// code. Other currencies are shown with their code.
+var currencySymbols = map[string]string{
+	"GBP": "£",
+	"EUR": "€",
+	"USD": "$",
+}
+
+// FormatCurrency formats the amount in the currency with the ISO 4217 code, using the
+// currency symbol if known and otherwise the code separated by a space.
+func (l *Locale) FormatCurrency(a money.Amount, code string) string {
+	if symbol, ok := currencySymbols[code]; ok {
+		return l.FormatMoney(a, symbol)
+	}
+	if l.SymbolAfter {
+		return l.FormatNumber(a) + " " + code
+	}
+	return code + " " + l.FormatNumber(a)
+}
+
+// durationUnits are the units of FormatDuration, largest first, with the message keys
+// of their singular and plural forms. Months and years are approximate.
+var durationUnits = []struct {
+	size             time.Duration
+	singular, plural string
+}{
+	{365 * 24 * time.Hour, "duration.year", "duration.years"},
+	{30 * 24 * time.Hour, "duration.month", "duration.months"},
+	{24 * time.Hour, "duration.day", "duration.days"},
+	{time.Hour, "duration.hour", "duration.hours"},
+	{time.Minute, "duration.minute", "duration.minutes"},
+	{time.Second, "duration.second", "duration.seconds"},
+}
+
+// FormatDuration formats d in its largest whole unit, such as "3 days". Negative
+// durations are formatted as positive ones.
+func (l *Locale) FormatDuration(d time.Duration) string {
+	d = d.Abs()
+	for _, u := range durationUnits {
+		if n := int(d / u.size); n > 0 || u.size == time.Second {
+			if n == 1 {
+				return l.T(u.singular, n)
+			}
+			return l.T(u.plural, n)
+		}
+	}
+	return ""
+}
+
+// FormatAgo formats the time t relative to now, such as "3 days ago" or "in 2 hours".
+// Times within a minute of now are formatted as "just now".
+func (l *Locale) FormatAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d.Abs() < time.Minute:
+		return l.T("duration.now")
+	case d > 0:
+		return l.T("duration.ago", l.FormatDuration(d))
+	default:
+		return l.T("duration.in", l.FormatDuration(d))
+	}
+}
@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestCatalogues checks that every locale translates the messages of the default
+// locale, and no others.
+func TestCatalogues(t *testing.T) {
+	want := slices.Sorted(maps.Keys(Get(DefaultLocale).Messages))
+	for _, l := range Locales() {
+		if got := slices.Sorted(maps.Keys(l.Messages)); !slices.Equal(got, want) {
+			t.Errorf("locale %s messages differ from %s", l.Tag, DefaultLocale)
+		}
+	}
+	if len(Locales()) < 2 {
+		t.Errorf("expected at least two locales, got %d", len(Locales()))
+	}
+}
+
+func TestLocale(t *testing.T) {
+
+	if !Supported("fr-FR") || Supported("xx-XX") {
+		t.Error("unexpected locale support")
+	}
+	if got, want := Get("xx-XX").Tag, DefaultLocale; got != want {
+		t.Errorf("got fallback locale %s want %s", got, want)
+	}
+
+	date := time.Date(2025, 2, 3, 14, 5, 6, 0, time.UTC)
+	tests := []struct {
+		tag      string
+		msg      string
+		env      string
+		missing  string
+		date     string
+		dateTime string
+		longDate string
+		money    string
+		negative string
+	}{
+		{"en-GB", "Invoices", "Salesforce sandbox", "no.such.key", "03/02/2025", "03/02/2025 14:05:06", "03 Feb 2025", "£1,234,567.89", "-£5.00"},
+		{"fr-FR", "Factures", "Salesforce sandbox", "no.such.key", "03/02/2025", "03/02/2025 14:05:06", "03 févr. 2025", "1\u202f234\u202f567,89\u00a0£", "-5,00\u00a0£"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			l := Get(tt.tag)
+			if got := l.T("nav.invoices"); got != tt.msg {
+				t.Errorf("got message %q want %q", got, tt.msg)
+			}
+			if got := l.T("app.sfEnvironment", "sandbox"); got != tt.env {
+				t.Errorf("got formatted message %q want %q", got, tt.env)
+			}
+			if got := l.T("no.such.key"); got != tt.missing {
+				t.Errorf("got missing message %q want %q", got, tt.missing)
+			}
+			if got := l.FormatDate(date); got != tt.date {
+				t.Errorf("got date %q want %q", got, tt.date)
+			}
+			if got := l.FormatDateTime(date); got != tt.dateTime {
+				t.Errorf("got date time %q want %q", got, tt.dateTime)
+			}
+			if got := l.FormatLongDate(date); got != tt.longDate {
+				t.Errorf("got long date %q want %q", got, tt.longDate)
+			}
+			if got := l.FormatMoney(money.FromFloat(1234567.89), "£"); got != tt.money {
+				t.Errorf("got money %q want %q", got, tt.money)
+			}
+			if got := l.FormatMoney(money.FromFloat(-5), "£"); got != tt.negative {
+				t.Errorf("got negative money %q want %q", got, tt.negative)
+			}
+			if got := l.FormatDate(time.Time{}); got != "" {
+				t.Errorf("got zero date %q", got)
+			}
+		})
+	}
+}
+
+func TestFormatCurrency(t *testing.T) {
+	en, fr := Get("en-GB"), Get("fr-FR")
+	a := money.FromFloat(1234.5)
+	tests := []struct {
+		l    *Locale
+		code string
+		want string
+	}{
+		{en, "GBP", "£1,234.50"},
+		{en, "EUR", "€1,234.50"},
+		{en, "CHF", "CHF 1,234.50"},
+		{fr, "EUR", "1 234,50 €"},
+		{fr, "CHF", "1 234,50 CHF"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.FormatCurrency(a, tt.code); got != tt.want {
+			t.Errorf("%s %s got %q want %q", tt.l.Tag, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	en, fr := Get("en-GB"), Get("fr-FR")
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		l    *Locale
+		t    time.Time
+		want string
+	}{
+		{en, now.Add(-20 * time.Second), "just now"},
+		{en, now.Add(-90 * time.Second), "1 minute ago"},
+		{en, now.Add(-3 * time.Hour), "3 hours ago"},
+		{en, now.Add(-3 * 24 * time.Hour), "3 days ago"},
+		{en, now.Add(-70 * 24 * time.Hour), "2 months ago"},
+		{en, now.Add(-800 * 24 * time.Hour), "2 years ago"},
+		{en, now.Add(2 * time.Hour), "in 2 hours"},
+		{fr, now.Add(-3 * 24 * time.Hour), "il y a 3 jours"},
+		{fr, now.Add(-24 * time.Hour), "il y a 1 jour"},
+		{fr, now.Add(5 * time.Minute), "dans 5 minutes"},
+	}
+	for _, tt := range tests {
+		if got := tt.l.FormatAgo(tt.t, now); got != tt.want {
+			t.Errorf("%s %s got %q want %q", tt.l.Tag, now.Sub(tt.t), got, tt.want)
+		}
+	}
+	if got, want := en.FormatDuration(45*time.Second), "45 seconds"; got != want {
+		t.Errorf("duration got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+// package idempotency guards the outbound mutations of remote platforms, such as the
+// updates of Salesforce and Xero references, against being made twice. Each mutation
+// is recorded by a key hashed from the mutation before it is made and again with its
+// result afterwards. A retried mutation which already succeeded returns the recorded
+// result without being made again, and one which was interrupted or failed after
+// being sent, such as by a timeout or crash, is first checked against the remote
+// platform.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Status is the status of a mutation.
+type Status string
+
+const (
+	StatusStarted   Status = "started"   // the mutation is being made or was interrupted
+	StatusSucceeded Status = "succeeded" // the mutation was made
+	StatusFailed    Status = "failed"    // the mutation reported an error
+)
+
+// ErrNotRecorded is returned by a Store for a mutation which has not been recorded.
+var ErrNotRecorded = errors.New("mutation not recorded")
+
+// Record is the record of a mutation. The Result is set for succeeded mutations.
+type Record struct {
+	Key       string
+	Target    string
+	Operation string
+	Status    Status
+	Result    string
+}
+
+// Store records mutations. Get returns an error matching ErrNotRecorded for a key which
+// has not been recorded.
+type Store interface {
+	Get(ctx context.Context, key string) (Record, error)
+	Save(ctx context.Context, r Record) error
+}
+
+// Key returns the key of a mutation, being the hex encoded sha256 hash of the json
+// encoding of parts. The parts should scope the mutation, such as by the id of the
+// action making it, so that the same change made intentionally again is not taken to
+// be a retry.
+func Key(parts ...any) (string, error) {
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return "", fmt.Errorf("idempotency key encoding error: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Mutation is an outbound mutation of Target. Do makes the mutation, returning its
+// result. Applied, which may be nil, reports whether a mutation which was started but
+// not recorded as succeeded has nevertheless been made on the remote platform,
+// returning its result if so.
+type Mutation struct {
+	Key       string
+	Target    string
+	Operation string
+	Do        func(ctx context.Context) (string, error)
+	Applied   func(ctx context.Context) (bool, string, error)
+}
+
+// Run makes the mutation m unless it has already been made, returning its result and
+// whether the mutation was sent by this call. A mutation is sent again if it failed or
+// was interrupted and is not reported as Applied.
+func Run(ctx context.Context, store Store, m Mutation) (string, bool, error) {
+
+	rec, err := store.Get(ctx, m.Key)
+	switch {
+	case errors.Is(err, ErrNotRecorded):
+		rec = Record{Key: m.Key, Target: m.Target, Operation: m.Operation}
+	case err != nil:
+		return "", false, fmt.Errorf("mutation %s record error: %w", m.Operation, err)
+	case rec.Status == StatusSucceeded:
+		return rec.Result, false, nil
+	case m.Applied != nil:
+		// The mutation was interrupted or failed, possibly after being made remotely.
+		applied, result, err := m.Applied(ctx)
+		if err != nil {
+			return "", false, fmt.Errorf("mutation %s check error: %w", m.Operation, err)
+		}
+		if applied {
+			rec.Status, rec.Result = StatusSucceeded, result
+			if err := store.Save(ctx, rec); err != nil {
+				return "", false, fmt.Errorf("mutation %s record error: %w", m.Operation, err)
+			}
+			return result, false, nil
+		}
+	}
+
+	rec.Status, rec.Result = StatusStarted, ""
+	if err := store.Save(ctx, rec); err != nil {
+		return "", false, fmt.Errorf("mutation %s record error: %w", m.Operation, err)
+	}
+	result, doErr := m.Do(ctx)
+	rec.Status, rec.Result = StatusSucceeded, result
+	if doErr != nil {
+		rec.Status, rec.Result = StatusFailed, ""
+	}
+	if err := store.Save(ctx, rec); err != nil {
+		return "", doErr == nil, errors.Join(doErr, fmt.Errorf("mutation %s record error: %w", m.Operation, err))
+	}
+	if doErr != nil {
+		return "", true, doErr
+	}
+	return result, true, nil
+}
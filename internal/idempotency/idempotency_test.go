@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// memStore is an in-memory Store.
+type memStore map[string]Record
+
+func (m memStore) Get(_ context.Context, key string) (Record, error) {
+	r, ok := m[key]
+	if !ok {
+		return r, ErrNotRecorded
+	}
+	return r, nil
+}
+
+func (m memStore) Save(_ context.Context, r Record) error {
+	m[r.Key] = r
+	return nil
+}
+
+func TestKey(t *testing.T) {
+	k1, err := Key(1, "salesforce", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, _ := Key(1, "salesforce", []string{"a", "b"})
+	k3, _ := Key(2, "salesforce", []string{"a", "b"})
+	if k1 != k2 {
+		t.Error("expected equal keys for equal parts")
+	}
+	if k1 == k3 {
+		t.Error("expected different keys for different parts")
+	}
+	if len(k1) != 64 {
+		t.Errorf("key length got %d want 64", len(k1))
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	ctx := t.Context()
+	store := memStore{}
+
+	var calls int
+	fail := errors.New("timeout")
+	var doErr error
+	remote := false // the remote platform has the change
+	m := Mutation{
+		Key:       "k",
+		Target:    "salesforce",
+		Operation: "update refs",
+		Do: func(context.Context) (string, error) {
+			calls++
+			if doErr != nil {
+				return "", doErr
+			}
+			remote = true
+			return "ok", nil
+		},
+		Applied: func(context.Context) (bool, string, error) {
+			return remote, "checked", nil
+		},
+	}
+
+	// A failed mutation is recorded as failed.
+	doErr = fail
+	if _, sent, err := Run(ctx, store, m); !errors.Is(err, fail) || !sent {
+		t.Fatalf("expected a sent failure, got %t %v", sent, err)
+	}
+	if got, want := store["k"].Status, StatusFailed; got != want {
+		t.Errorf("status got %s want %s", got, want)
+	}
+
+	// The failed mutation is retried as it was not applied remotely.
+	doErr = nil
+	result, sent, err := Run(ctx, store, m)
+	if err != nil || !sent || result != "ok" {
+		t.Fatalf("retry got %q %t %v", result, sent, err)
+	}
+
+	// A succeeded mutation is not made again.
+	result, sent, err = Run(ctx, store, m)
+	if err != nil || sent || result != "ok" {
+		t.Fatalf("repeat got %q %t %v", result, sent, err)
+	}
+	if got, want := calls, 2; got != want {
+		t.Errorf("calls got %d want %d", got, want)
+	}
+
+	// An interrupted mutation which was applied remotely is recorded as succeeded
+	// without being made again.
+	store["k"] = Record{Key: "k", Target: "salesforce", Operation: "update refs", Status: StatusStarted}
+	result, sent, err = Run(ctx, store, m)
+	if err != nil || sent || result != "checked" {
+		t.Fatalf("interrupted got %q %t %v", result, sent, err)
+	}
+	if got, want := store["k"].Status, StatusSucceeded; got != want {
+		t.Errorf("status got %s want %s", got, want)
+	}
+	if got, want := calls, 2; got != want {
+		t.Errorf("calls got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+// package linking orchestrates link actions, which update Salesforce, Xero and the
+// local database in turn, as a sequence of idempotent steps. The progress of an action
+// is recorded before and after each step so that an action which fails part way
+// through may be retried from the failed step, or have its completed steps reversed by
+// their compensation steps.
+package linking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Status is the status of an action.
+type Status string
+
+const (
+	StatusPending     Status = "pending"     // the action is running or was interrupted
+	StatusFailed      Status = "failed"      // a step failed and may be retried
+	StatusDone        Status = "done"        // all steps completed
+	StatusCompensated Status = "compensated" // the completed steps were reversed
+)
+
+// ErrUnknownStep reports a step name which is not one of the steps of an action.
+var ErrUnknownStep = errors.New("unknown step")
+
+// Step is one step of an action. Run must be idempotent so that it may be retried
+// after a failure. Compensate, if not nil, reverses a completed Run and must also be
+// idempotent.
+type Step struct {
+	Name       string
+	Run        func(context.Context) error
+	Compensate func(context.Context) error
+}
+
+// Recorder records the progress of an action, being the step about to be run or which
+// failed, the status of the action and any error.
+type Recorder func(ctx context.Context, step string, status Status, err error) error
+
+// StepError is the error of a failed step.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+// Error returns the step name with the step error.
+func (e StepError) Error() string {
+	return fmt.Sprintf("step %s: %v", e.Step, e.Err)
+}
+
+// Unwrap returns the step error.
+func (e StepError) Unwrap() error {
+	return e.Err
+}
+
+// stepIndex returns the index of the step named from, or 0 for an empty name.
+func stepIndex(steps []Step, from string) (int, error) {
+	if from == "" {
+		return 0, nil
+	}
+	i := slices.IndexFunc(steps, func(s Step) bool { return s.Name == from })
+	if i < 0 {
+		return 0, fmt.Errorf("%w %q", ErrUnknownStep, from)
+	}
+	return i, nil
+}
+
+// Run runs the steps of an action in order, starting from the step named from or from
+// the first step if from is empty. Each step is recorded as pending before it is run.
+// If a step fails the action is recorded as failed at that step and a StepError
+// returned, otherwise the action is recorded as done at the last step.
+func Run(ctx context.Context, steps []Step, from string, record Recorder) error {
+
+	start, err := stepIndex(steps, from)
+	if err != nil {
+		return err
+	}
+	for _, s := range steps[start:] {
+		if err := record(ctx, s.Name, StatusPending, nil); err != nil {
+			return fmt.Errorf("could not record step %s: %w", s.Name, err)
+		}
+		if err := s.Run(ctx); err != nil {
+			stepErr := StepError{Step: s.Name, Err: err}
+			if recErr := record(ctx, s.Name, StatusFailed, stepErr); recErr != nil {
+				return errors.Join(stepErr, fmt.Errorf("could not record failed step %s: %w", s.Name, recErr))
+			}
+			return stepErr
+		}
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	return record(ctx, steps[len(steps)-1].Name, StatusDone, nil)
+}
+
+// Compensate reverses the steps of an action up to and including the step named
+// failed, running their compensation steps in reverse order. The failed step is
+// compensated since it may have partly completed, such as a batch update which
+// failed for some records, or have completed before the action was interrupted. If a
+// compensation step fails the action is recorded as failed at the failed step, so
+// that compensation may be tried again, and a StepError returned, otherwise the action
+// is recorded as compensated.
+func Compensate(ctx context.Context, steps []Step, failed string, record Recorder) error {
+
+	end, err := stepIndex(steps, failed)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	for _, s := range slices.Backward(steps[:end+1]) {
+		if s.Compensate == nil {
+			continue
+		}
+		if err := s.Compensate(ctx); err != nil {
+			stepErr := StepError{Step: s.Name, Err: fmt.Errorf("compensation failed: %w", err)}
+			if recErr := record(ctx, failed, StatusFailed, stepErr); recErr != nil {
+				return errors.Join(stepErr, fmt.Errorf("could not record failed compensation: %w", recErr))
+			}
+			return stepErr
+		}
+	}
+	return record(ctx, steps[end].Name, StatusCompensated, nil)
+}
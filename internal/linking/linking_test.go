@@ -0,0 +1,118 @@
+package linking
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// progress records the steps run and compensated and the recorded progress of an
+// action.
+type progress struct {
+	ran         []string
+	compensated []string
+	recorded    []string
+	failAt      string
+	failComp    string
+}
+
+func (p *progress) steps() []Step {
+	var steps []Step
+	for _, name := range []string{"salesforce", "xero", "local"} {
+		steps = append(steps, Step{
+			Name: name,
+			Run: func(context.Context) error {
+				p.ran = append(p.ran, name)
+				if name == p.failAt {
+					return errors.New("run failed")
+				}
+				return nil
+			},
+			Compensate: func(context.Context) error {
+				p.compensated = append(p.compensated, name)
+				if name == p.failComp {
+					return errors.New("compensation failed")
+				}
+				return nil
+			},
+		})
+	}
+	return steps
+}
+
+func (p *progress) record(_ context.Context, step string, status Status, _ error) error {
+	p.recorded = append(p.recorded, step+" "+string(status))
+	return nil
+}
+
+func TestRun(t *testing.T) {
+
+	ctx := context.Background()
+
+	p := &progress{}
+	if err := Run(ctx, p.steps(), "", p.record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.ran, []string{"salesforce", "xero", "local"}; !slices.Equal(got, want) {
+		t.Errorf("ran got %v want %v", got, want)
+	}
+	if got, want := p.recorded[len(p.recorded)-1], "local done"; got != want {
+		t.Errorf("last record got %q want %q", got, want)
+	}
+
+	// A failed step stops the action and is recorded as failed.
+	p = &progress{failAt: "xero"}
+	err := Run(ctx, p.steps(), "", p.record)
+	stepErr, ok := errors.AsType[StepError](err)
+	if !ok || stepErr.Step != "xero" {
+		t.Fatalf("expected xero StepError, got %v", err)
+	}
+	if got, want := p.ran, []string{"salesforce", "xero"}; !slices.Equal(got, want) {
+		t.Errorf("ran got %v want %v", got, want)
+	}
+	if got, want := p.recorded, []string{"salesforce pending", "xero pending", "xero failed"}; !slices.Equal(got, want) {
+		t.Errorf("recorded got %v want %v", got, want)
+	}
+
+	// A retry runs from the failed step.
+	p.failAt, p.ran = "", nil
+	if err := Run(ctx, p.steps(), "xero", p.record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.ran, []string{"xero", "local"}; !slices.Equal(got, want) {
+		t.Errorf("retry ran got %v want %v", got, want)
+	}
+
+	if err := Run(ctx, p.steps(), "none", p.record); !errors.Is(err, ErrUnknownStep) {
+		t.Errorf("expected ErrUnknownStep, got %v", err)
+	}
+}
+
+func TestCompensate(t *testing.T) {
+
+	ctx := context.Background()
+
+	// The steps up to and including the failed step are compensated, in reverse.
+	p := &progress{}
+	if err := Compensate(ctx, p.steps(), "xero", p.record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.compensated, []string{"xero", "salesforce"}; !slices.Equal(got, want) {
+		t.Errorf("compensated got %v want %v", got, want)
+	}
+	if got, want := p.recorded, []string{"xero compensated"}; !slices.Equal(got, want) {
+		t.Errorf("recorded got %v want %v", got, want)
+	}
+
+	// A failed compensation leaves the action failed at the failed step.
+	p = &progress{failComp: "salesforce"}
+	err := Compensate(ctx, p.steps(), "local", p.record)
+	stepErr, ok := errors.AsType[StepError](err)
+	if !ok || stepErr.Step != "salesforce" {
+		t.Fatalf("expected salesforce StepError, got %v", err)
+	}
+	if got, want := p.recorded, []string{"local failed"}; !slices.Equal(got, want) {
+		t.Errorf("recorded got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+// package logfile provides a log file which is rotated when it reaches a maximum size.
+// On rotation the file is renamed with the suffix ".1", earlier rotated files having
+// their suffixes incremented, and the oldest files beyond the number of backups kept are
+// removed.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is a size-rotated log file, safe for concurrent use.
+type File struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens the log file at path for appending, creating it if necessary. The file is
+// rotated when a write would take it beyond maxSize bytes, keeping maxBackups rotated
+// files.
+func Open(path string, maxSize int64, maxBackups int) (*File, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("log file maximum size %d is not positive", maxSize)
+	}
+	if maxBackups < 0 {
+		return nil, fmt.Errorf("log file backups %d may not be negative", maxBackups)
+	}
+	f := &File{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at the path, recording its current size.
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not stat log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write writes p to the file, first rotating the file if p would take it beyond the
+// maximum size. A write larger than the maximum size is written to an empty file.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, shifts the rotated files and opens a new file.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("could not close log file: %w", err)
+	}
+	f.file = nil
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove log file: %w", err)
+		}
+		return f.open()
+	}
+	_ = os.Remove(backupName(f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupName(f.path, i), backupName(f.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, backupName(f.path, 1)); err != nil {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+	return f.open()
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// backupName returns the name of the nth rotated file of path.
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,59 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileRotation(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "reconciler.log")
+	f, err := Open(path, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each line is over half the maximum size, so is written to a new file.
+	for _, line := range []string{"first   line\n", "second  line\n", "third   line\n", "fourth  line\n", "fifth   line\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		path:        "fifth   line\n",
+		path + ".1": "fourth  line\n",
+		path + ".2": "third   line\n",
+	} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s got %q want %q", filepath.Base(name), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, got err %v", err)
+	}
+
+	// Reopening appends to the existing file.
+	f, err = Open(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte("sixth\n"))
+	_ = f.Close()
+	got, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(got), "fifth   line\nsixth\n") {
+		t.Errorf("reopened file got %q", got)
+	}
+	if _, err := f.Write([]byte("closed")); err == nil {
+		t.Error("expected an error writing to a closed file")
+	}
+}
@@ -0,0 +1,659 @@
+// package mockapi serves canned Xero and Salesforce api responses from embedded
+// fixtures, so that the reconciler can be demonstrated and tested without credentials
+// for either platform.
+//
+// The Server is not reached over the network. Its Client sends each request directly to
+// the Server, whatever the host of the request url, so the api clients and OAuth2 token
+// exchanges work unchanged when given the Client in their context with WithClient. Only
+// the OAuth2 authorization page is visited by a browser, and is served by the web
+// server at AuthorizePath.
+//
+// Updates to the references of invoices, bank transactions and donations are kept in
+// memory, so later calls return the updated records, and only the records modified
+// since the If-Modified-Since time of a Xero listing, or the LastModifiedDate condition
+// of a SOQL query, are returned. Listings are paged as by the platforms, with page sizes
+// which may be reduced by SetPageSizes to exercise the paging of the api clients.
+package mockapi
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"golang.org/x/oauth2"
+)
+
+// AuthorizePath is the path of the OAuth2 authorization page of both platforms.
+const AuthorizePath = "/mock/authorize"
+
+// TenantID is the Xero tenant of the mock organisation.
+const TenantID = "00000000-0000-4000-8000-000000000001"
+
+// xeroPageSize is the number of records in each page of a Xero listing.
+const xeroPageSize = 100
+
+// salesforcePageSize is the number of records in each batch of a SOQL query result.
+const salesforcePageSize = 2000
+
+//go:embed fixtures/*.json
+var fixturesFS embed.FS
+
+// record is a json object of a fixture.
+type record = map[string]any
+
+// Server is a mock of the Xero and Salesforce apis.
+type Server struct {
+	mux *http.ServeMux
+
+	mu               sync.Mutex
+	organisations    []record
+	accounts         []record
+	contacts         []record
+	invoices         []record
+	bankTransactions []record
+	overpayments     []record
+	prepayments      []record
+	opportunities    []record
+	calls            int
+
+	xeroPageSize       int
+	salesforcePageSize int
+	queryResults       map[string][]record // the remaining records of a query, by locator
+	queryLocators      int
+}
+
+// New returns a Server loaded with the embedded fixtures.
+func New() (*Server, error) {
+	s := &Server{
+		xeroPageSize:       xeroPageSize,
+		salesforcePageSize: salesforcePageSize,
+		queryResults:       map[string][]record{},
+	}
+	for _, f := range []struct {
+		file, key string
+		records   *[]record
+	}{
+		{"organisations.json", "Organisations", &s.organisations},
+		{"accounts.json", "Accounts", &s.accounts},
+		{"contacts.json", "Contacts", &s.contacts},
+		{"invoices.json", "Invoices", &s.invoices},
+		{"bank_transactions.json", "BankTransactions", &s.bankTransactions},
+		{"overpayments.json", "Overpayments", &s.overpayments},
+		{"prepayments.json", "Prepayments", &s.prepayments},
+		{"opportunities.json", "records", &s.opportunities},
+	} {
+		var err error
+		*f.records, err = loadFixture(f.file, f.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+AuthorizePath, s.handleAuthorize)
+
+	// Xero, at login.xero.com, identity.xero.com and api.xero.com.
+	mux.HandleFunc("POST /connect/token", s.handleXeroToken)
+	mux.HandleFunc("POST /connect/revocation", s.handleRevoke)
+	mux.HandleFunc("GET /connections", s.authorized(s.handleXeroConnections))
+	mux.HandleFunc("GET /api.xro/2.0/Organisation", s.authorized(s.handleXeroList("Organisations", &s.organisations)))
+	mux.HandleFunc("GET /api.xro/2.0/Accounts", s.authorized(s.handleXeroList("Accounts", &s.accounts)))
+	mux.HandleFunc("GET /api.xro/2.0/Contacts", s.authorized(s.handleXeroList("Contacts", &s.contacts)))
+	mux.HandleFunc("GET /api.xro/2.0/Invoices", s.authorized(s.handleXeroList("Invoices", &s.invoices)))
+	mux.HandleFunc("GET /api.xro/2.0/Invoices/{id}", s.authorized(s.handleXeroGet("Invoices", "InvoiceID", &s.invoices)))
+	mux.HandleFunc("POST /api.xro/2.0/Invoices/{id}", s.authorized(s.handleXeroUpdate("Invoices", "InvoiceID", &s.invoices)))
+	mux.HandleFunc("GET /api.xro/2.0/BankTransactions", s.authorized(s.handleXeroList("BankTransactions", &s.bankTransactions)))
+	mux.HandleFunc("GET /api.xro/2.0/BankTransactions/{id}", s.authorized(s.handleXeroGet("BankTransactions", "BankTransactionID", &s.bankTransactions)))
+	mux.HandleFunc("POST /api.xro/2.0/BankTransactions", s.authorized(s.handleXeroUpdate("BankTransactions", "BankTransactionID", &s.bankTransactions)))
+	mux.HandleFunc("GET /api.xro/2.0/Overpayments", s.authorized(s.handleXeroList("Overpayments", &s.overpayments)))
+	mux.HandleFunc("GET /api.xro/2.0/Prepayments", s.authorized(s.handleXeroList("Prepayments", &s.prepayments)))
+
+	// Salesforce, at the login domain and the instance, which are the same host.
+	mux.HandleFunc("POST /services/oauth2/token", s.handleSalesforceToken)
+	mux.HandleFunc("POST /services/oauth2/revoke", s.handleRevoke)
+	mux.HandleFunc("GET /services/data/{version}/query", s.authorized(s.handleSalesforceQuery(false)))
+	mux.HandleFunc("GET /services/data/{version}/queryAll", s.authorized(s.handleSalesforceQuery(true)))
+	mux.HandleFunc("GET /services/data/{version}/query/{locator}", s.authorized(s.handleSalesforceQueryMore))
+	mux.HandleFunc("PATCH /services/data/{version}/composite/sobjects", s.authorized(s.handleSalesforceUpdate))
+	mux.HandleFunc("POST /services/data/{version}/composite/graph", s.authorized(s.handleSalesforceGraph))
+	mux.HandleFunc("GET /services/data/{version}/sobjects/{object}/describe", s.authorized(s.handleSalesforceDescribe))
+
+	s.mux = mux
+	return s, nil
+}
+
+// loadFixture returns the records under key in the fixture file.
+func loadFixture(file, key string) ([]record, error) {
+	b, err := fixturesFS.ReadFile("fixtures/" + file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixture %s: %w", file, err)
+	}
+	var fixture map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		return nil, fmt.Errorf("could not decode fixture %s: %w", file, err)
+	}
+	var records []record
+	if err := json.Unmarshal(fixture[key], &records); err != nil {
+		return nil, fmt.Errorf("could not decode %s in fixture %s: %w", key, file, err)
+	}
+	return records, nil
+}
+
+// Configure points the OAuth2 authorization urls of cfg at the AuthorizePath of the web
+// server and turns off the Salesforce change subscription, which is not mocked. The
+// client credentials are not used.
+func (s *Server) Configure(cfg *config.Config) {
+	authURL := fmt.Sprintf("http://%s%s", cfg.Web.ListenAddress, AuthorizePath)
+	cfg.Xero.OAuth2Config.Endpoint.AuthURL = authURL
+	cfg.Salesforce.OAuth2Config.Endpoint.AuthURL = authURL
+	cfg.Salesforce.SubscribeChanges = false
+}
+
+// SetPageSizes sets the number of records in each page of a Xero listing and each
+// batch of a SOQL query result.
+func (s *Server) SetPageSizes(xero, salesforce int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.xeroPageSize = max(xero, 1)
+	s.salesforcePageSize = max(salesforce, 1)
+}
+
+// ServeHTTP serves the mock apis.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Client returns an http client which sends its requests to the Server.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: transport{s}}
+}
+
+// WithClient returns a copy of ctx carrying the Client of the Server, which is used by
+// the oauth2 package, and so the api clients, in place of the default http client.
+func (s *Server) WithClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, s.Client())
+}
+
+// transport is an http.RoundTripper serving requests with a handler in-process.
+type transport struct {
+	handler http.Handler
+}
+
+// RoundTrip serves req with the handler, returning the recorded response.
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer func() {
+			_ = req.Body.Close()
+		}()
+	}
+	rec := &recorder{header: http.Header{}}
+	t.handler.ServeHTTP(rec, req)
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.code, http.StatusText(rec.code)),
+		StatusCode:    rec.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.header,
+		Body:          io.NopCloser(bytes.NewReader(rec.body.Bytes())),
+		ContentLength: int64(rec.body.Len()),
+		Request:       req,
+	}, nil
+}
+
+// recorder is an http.ResponseWriter recording a response.
+type recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// writeJSON writes v as a json response with status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// authorized only passes on requests with a bearer token, counting the calls for the
+// rate limit headers.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeJSON(w, http.StatusUnauthorized, []record{{"errorCode": "INVALID_SESSION_ID", "message": "Session expired or invalid"}})
+			return
+		}
+		s.mu.Lock()
+		s.calls++
+		calls := s.calls
+		s.mu.Unlock()
+		w.Header().Set("X-MinLimit-Remaining", "60")
+		w.Header().Set("X-DayLimit-Remaining", strconv.Itoa(max(5000-calls, 0)))
+		w.Header().Set("Sforce-Limit-Info", fmt.Sprintf("api-usage=%d/15000", calls))
+		next(w, r)
+	}
+}
+
+// handleAuthorize approves any OAuth2 authorization request, redirecting to the
+// redirect uri with a code and the request's state.
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := url.Values{"code": {"mock-code"}, "state": {q.Get("state")}}
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusSeeOther)
+}
+
+// tokenResponse returns the fields of a token response for an authorization code or
+// refresh token grant.
+func tokenResponse(w http.ResponseWriter, r *http.Request, provider string) (record, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return nil, false
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code", "refresh_token":
+	default:
+		writeJSON(w, http.StatusBadRequest, record{"error": "unsupported_grant_type"})
+		return nil, false
+	}
+	now := time.Now()
+	return record{
+		"access_token":  fmt.Sprintf("mock-%s-access-%d", provider, now.UnixNano()),
+		"refresh_token": fmt.Sprintf("mock-%s-refresh", provider),
+		"token_type":    "Bearer",
+	}, true
+}
+
+// handleXeroToken issues Xero tokens, which expire after 30 minutes.
+func (s *Server) handleXeroToken(w http.ResponseWriter, r *http.Request) {
+	tok, ok := tokenResponse(w, r, "xero")
+	if !ok {
+		return
+	}
+	tok["expires_in"] = 1800
+	writeJSON(w, http.StatusOK, tok)
+}
+
+// handleSalesforceToken issues Salesforce tokens, with an instance url on the host of
+// the login domain so that it is in the configured Salesforce environment.
+func (s *Server) handleSalesforceToken(w http.ResponseWriter, r *http.Request) {
+	tok, ok := tokenResponse(w, r, "salesforce")
+	if !ok {
+		return
+	}
+	host := r.URL.Host
+	if host == "" {
+		host = r.Host
+	}
+	tok["instance_url"] = "https://" + host
+	tok["issued_at"] = strconv.FormatInt(time.Now().UnixMilli(), 10)
+	tok["scope"] = "refresh_token api"
+	writeJSON(w, http.StatusOK, tok)
+}
+
+// handleRevoke accepts any token revocation.
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleXeroConnections lists the single mock Xero tenant.
+func (s *Server) handleXeroConnections(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	name, _ := s.organisations[0]["Name"].(string)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, []record{{
+		"id":         "00000000-0000-4000-8000-000000000002",
+		"tenantId":   TenantID,
+		"tenantType": "ORGANISATION",
+		"tenantName": name,
+	}})
+}
+
+// xeroType matches the record type condition of a Xero "where" parameter.
+var xeroType = regexp.MustCompile(`Type=="(\w+)"`)
+
+// xeroDate matches the milliseconds of a Xero json date, such as /Date(1744711200000+0000)/.
+var xeroDate = regexp.MustCompile(`/Date\((\d+)`)
+
+// xeroModified reports if the record was updated after since. Records without an
+// UpdatedDateUTC are reported as modified.
+func xeroModified(rec record, since time.Time) bool {
+	updated, _ := rec["UpdatedDateUTC"].(string)
+	m := xeroDate.FindStringSubmatch(updated)
+	if m == nil {
+		return true
+	}
+	ms, _ := strconv.ParseInt(m[1], 10, 64)
+	return time.UnixMilli(ms).After(since)
+}
+
+// handleXeroList serves a Xero listing under key, filtered by the type in the "where"
+// parameter and the If-Modified-Since header and paged by the "page" parameter if
+// provided.
+func (s *Server) handleXeroList(key string, records *[]record) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var since time.Time
+		if h := r.Header.Get("If-Modified-Since"); h != "" {
+			var err error
+			if since, err = http.ParseTime(h); err != nil {
+				http.Error(w, "invalid If-Modified-Since header", http.StatusBadRequest)
+				return
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		listing := []record{}
+		for _, rec := range *records {
+			if m := xeroType.FindStringSubmatch(q.Get("where")); m != nil && rec["Type"] != m[1] {
+				continue
+			}
+			if !since.IsZero() && !xeroModified(rec, since) {
+				continue
+			}
+			listing = append(listing, rec)
+		}
+		if p := q.Get("page"); p != "" {
+			page, err := strconv.Atoi(p)
+			if err != nil || page < 1 {
+				http.Error(w, "invalid page", http.StatusBadRequest)
+				return
+			}
+			start := min((page-1)*s.xeroPageSize, len(listing))
+			listing = listing[start:min(start+s.xeroPageSize, len(listing))]
+		}
+		writeJSON(w, http.StatusOK, record{key: listing})
+	}
+}
+
+// find returns the record with the id field, or nil.
+func find(records []record, idField, id string) record {
+	for _, rec := range records {
+		if rec[idField] == id {
+			return rec
+		}
+	}
+	return nil
+}
+
+// handleXeroGet serves the Xero record with the id in the path.
+func (s *Server) handleXeroGet(key, idField string, records *[]record) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		rec := find(*records, idField, r.PathValue("id"))
+		if rec == nil {
+			http.Error(w, "The resource you're looking for cannot be found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, record{key: []record{rec}})
+	}
+}
+
+// handleXeroUpdate updates the references of the Xero records in the request body,
+// returning the updated records.
+func (s *Server) handleXeroUpdate(key, idField string, records *[]record) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body map[string][]record
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		updated := []record{}
+		for _, u := range body[key] {
+			id, _ := u[idField].(string)
+			rec := find(*records, idField, id)
+			if rec == nil {
+				http.Error(w, fmt.Sprintf("%s %s not found", idField, id), http.StatusNotFound)
+				return
+			}
+			rec["Reference"] = u["Reference"]
+			rec["UpdatedDateUTC"] = fmt.Sprintf("/Date(%d+0000)/", time.Now().UnixMilli())
+			updated = append(updated, rec)
+		}
+		writeJSON(w, http.StatusOK, record{key: updated})
+	}
+}
+
+var (
+	// soqlIDs matches the record ids condition of a SOQL query.
+	soqlIDs = regexp.MustCompile(`Id IN \(([^)]*)\)`)
+	// soqlModified matches the LastModifiedDate condition of a SOQL query.
+	soqlModified = regexp.MustCompile(`LastModifiedDate > (\S+)`)
+	// soqlLimit matches the LIMIT clause of a SOQL query.
+	soqlLimit = regexp.MustCompile(`LIMIT (\d+)`)
+)
+
+// salesforceTime is the layout of the LastModifiedDate of an opportunity.
+const salesforceTime = "2006-01-02T15:04:05.000-0700"
+
+// handleSalesforceQuery serves a SOQL query of the opportunities, filtered by the ids
+// and LastModifiedDate conditions and limited by the LIMIT clause of the query if
+// provided. Other conditions are ignored. No records are deleted, so queryAll, used
+// only to find deleted records, returns no records.
+func (s *Server) handleSalesforceQuery(all bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		soql := r.URL.Query().Get("q")
+		var since time.Time
+		if m := soqlModified.FindStringSubmatch(soql); m != nil {
+			var err error
+			if since, err = time.Parse(time.RFC3339, m[1]); err != nil {
+				writeJSON(w, http.StatusBadRequest, []record{{"errorCode": "MALFORMED_QUERY", "message": err.Error()}})
+				return
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		records := []record{}
+		if !all {
+			var ids map[string]bool
+			if m := soqlIDs.FindStringSubmatch(soql); m != nil {
+				ids = map[string]bool{}
+				for id := range strings.SplitSeq(m[1], ",") {
+					ids[strings.Trim(id, "' ")] = true
+				}
+			}
+			for _, rec := range s.opportunities {
+				if id, _ := rec["Id"].(string); ids != nil && !ids[id] {
+					continue
+				}
+				if modified, _ := rec["LastModifiedDate"].(string); !since.IsZero() {
+					t, err := time.Parse(salesforceTime, modified)
+					if err == nil && !t.After(since) {
+						continue
+					}
+				}
+				records = append(records, rec)
+			}
+			if m := soqlLimit.FindStringSubmatch(soql); m != nil {
+				limit, _ := strconv.Atoi(m[1])
+				records = records[:min(limit, len(records))]
+			}
+		}
+		s.writeQueryBatch(w, r.PathValue("version"), len(records), records)
+	}
+}
+
+// handleSalesforceQueryMore serves the next batch of the records of a query, by the
+// locator of the nextRecordsUrl of the previous batch.
+func (s *Server) handleSalesforceQueryMore(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	locator := r.PathValue("locator")
+	records, ok := s.queryResults[locator]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, []record{{"errorCode": "INVALID_QUERY_LOCATOR", "message": "invalid query locator"}})
+		return
+	}
+	delete(s.queryResults, locator)
+	s.writeQueryBatch(w, r.PathValue("version"), len(records), records)
+}
+
+// writeQueryBatch writes the first batch of the records of a query with totalSize
+// records, keeping the remaining records for the nextRecordsUrl of the batch. The
+// caller holds s.mu.
+func (s *Server) writeQueryBatch(w http.ResponseWriter, version string, totalSize int, records []record) {
+	if len(records) <= s.salesforcePageSize {
+		writeJSON(w, http.StatusOK, record{"totalSize": totalSize, "done": true, "records": records})
+		return
+	}
+	s.queryLocators++
+	locator := fmt.Sprintf("01g%015d-%d", s.queryLocators, s.salesforcePageSize)
+	s.queryResults[locator] = records[s.salesforcePageSize:]
+	writeJSON(w, http.StatusOK, record{
+		"totalSize":      totalSize,
+		"done":           false,
+		"nextRecordsUrl": fmt.Sprintf("/services/data/%s/query/%s", version, locator),
+		"records":        records[:s.salesforcePageSize],
+	})
+}
+
+// collectionUpdate is the body of an sObject collection update request.
+type collectionUpdate struct {
+	AllOrNone bool     `json:"allOrNone"`
+	Records   []record `json:"records"`
+}
+
+// handleSalesforceUpdate updates the opportunities in an sObject collection request.
+func (s *Server) handleSalesforceUpdate(w http.ResponseWriter, r *http.Request) {
+	var body collectionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, []record{{"errorCode": "JSON_PARSER_ERROR", "message": err.Error()}})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results, _ := s.updateOpportunities(body.Records, body.AllOrNone, true)
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleSalesforceGraph updates the opportunities in the sObject collection nodes of a
+// composite graph request, updating none of them unless all can be updated.
+func (s *Server) handleSalesforceGraph(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Graphs []struct {
+			GraphID          string `json:"graphId"`
+			CompositeRequest []struct {
+				ReferenceID string           `json:"referenceId"`
+				Body        collectionUpdate `json:"body"`
+			} `json:"compositeRequest"`
+		} `json:"graphs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, http.StatusBadRequest, []record{{"errorCode": "JSON_PARSER_ERROR", "message": err.Error()}})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	graphs := []record{}
+	for _, g := range body.Graphs {
+		// Check every node before applying any of them.
+		ok := true
+		for _, node := range g.CompositeRequest {
+			if _, nodeOK := s.updateOpportunities(node.Body.Records, true, false); !nodeOK {
+				ok = false
+			}
+		}
+		nodes := []record{}
+		for _, node := range g.CompositeRequest {
+			results, _ := s.updateOpportunities(node.Body.Records, true, ok)
+			nodes = append(nodes, record{"body": results, "httpHeaders": record{}, "httpStatusCode": http.StatusOK, "referenceId": node.ReferenceID})
+		}
+		graphs = append(graphs, record{"graphId": g.GraphID, "graphResponse": record{"compositeResponse": nodes}, "isSuccessful": ok})
+	}
+	writeJSON(w, http.StatusOK, record{"graphs": graphs})
+}
+
+// updateOpportunities updates the opportunities with the field values of updates,
+// returning the save result of each and whether all could be updated. Nothing is
+// updated unless apply is set, or if allOrNone is set and any update would fail. The
+// caller holds s.mu.
+func (s *Server) updateOpportunities(updates []record, allOrNone, apply bool) ([]record, bool) {
+	var missing int
+	for _, u := range updates {
+		id, _ := u["id"].(string)
+		if find(s.opportunities, "Id", id) == nil {
+			missing++
+		}
+	}
+	apply = apply && (missing == 0 || !allOrNone)
+
+	results := []record{}
+	for _, u := range updates {
+		id, _ := u["id"].(string)
+		rec := find(s.opportunities, "Id", id)
+		if rec == nil {
+			results = append(results, record{"id": id, "success": false, "errors": []record{{
+				"statusCode": "ENTITY_IS_DELETED",
+				"message":    "entity is deleted",
+				"fields":     []string{},
+			}}})
+			continue
+		}
+		if allOrNone && missing > 0 {
+			results = append(results, record{"id": id, "success": false, "errors": []record{{
+				"statusCode": "ALL_OR_NONE_OPERATION_ROLLED_BACK",
+				"message":    "Record rolled back because not all records were valid and the request was using AllOrNone header",
+				"fields":     []string{},
+			}}})
+			continue
+		}
+		if apply {
+			for field, value := range u {
+				if field != "id" && field != "attributes" {
+					rec[field] = value
+				}
+			}
+			rec["LastModifiedDate"] = time.Now().UTC().Format(salesforceTime)
+		}
+		results = append(results, record{"id": id, "success": true, "errors": []record{}})
+	}
+	return results, missing == 0
+}
+
+// handleSalesforceDescribe serves the description of an sObject.
+func (s *Server) handleSalesforceDescribe(w http.ResponseWriter, r *http.Request) {
+	b, err := fixturesFS.ReadFile("fixtures/describe_" + strings.ToLower(r.PathValue("object")) + ".json")
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, []record{{"errorCode": "NOT_FOUND", "message": "The requested resource does not exist"}})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
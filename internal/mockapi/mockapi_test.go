@@ -0,0 +1,307 @@
+package mockapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/apiclients/xero"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// TestMockAPIs runs a login, sync and reference update cycle with the api clients
+// against the mock apis.
+func TestMockAPIs(t *testing.T) {
+
+	cfg, err := config.Load("../../config/config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Configure(cfg)
+	ctx := s.WithClient(context.Background())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Xero.
+	tok, err := cfg.Xero.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroToken, err := token.NewExtendedToken(token.XeroToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroClient, err := xero.NewClient(ctx, logger, cfg.DonationAccountCodesAsRegex(), xeroToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := xeroToken.TenantID, TenantID; got != want {
+		t.Errorf("tenant got %q want %q", got, want)
+	}
+	invoices, err := xeroClient.GetInvoices(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) == 0 {
+		t.Fatal("no invoices")
+	}
+	for _, inv := range invoices {
+		if inv.Type != "ACCREC" {
+			t.Fatalf("invoice %s type got %q want ACCREC", inv.InvoiceID, inv.Type)
+		}
+	}
+	transactions, err := xeroClient.GetBankTransactions(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) == 0 {
+		t.Fatal("no bank transactions")
+	}
+	overpayments, err := xeroClient.GetOverpayments(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prepayments, err := xeroClient.GetPrepayments(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overpayments) == 0 || len(prepayments) == 0 {
+		t.Fatalf("got %d overpayments and %d prepayments", len(overpayments), len(prepayments))
+	}
+	if _, err := xeroClient.UpdateInvoiceReference(ctx, invoices[0].InvoiceID, "DEMO-REF"); err != nil {
+		t.Fatal(err)
+	}
+	invoice, err := xeroClient.GetInvoiceByID(ctx, invoices[0].InvoiceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := invoice.Reference, "DEMO-REF"; got != want {
+		t.Errorf("invoice reference got %q want %q", got, want)
+	}
+
+	// Salesforce.
+	tok, err = cfg.Salesforce.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfToken, err := token.NewExtendedToken(token.SalesforceToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfClient, err := salesforce.NewClient(ctx, cfg, logger, sfToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems, err := sfClient.ValidateFieldMappings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("unexpected field mapping problems %v", problems)
+	}
+	donations, err := sfClient.GetOpportunities(ctx, cfg.DataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(donations), 34; got != want {
+		t.Fatalf("donations got %d want %d", got, want)
+	}
+	id := donations[0].ID
+	if _, err := sfClient.BatchUpdateOpportunityRefs(ctx, []salesforce.IDRef{{ID: id, Ref: "DEMO-REF"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := sfClient.GetOpportunitiesByID(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || updated[0].PayoutReference == nil || *updated[0].PayoutReference != "DEMO-REF" {
+		t.Errorf("updated donations got %+v", updated)
+	}
+
+	// An atomic update of more than 200 records, made as a composite graph, updates
+	// none of the records if any is missing.
+	idRefs := make([]salesforce.IDRef, 201)
+	for i := range idRefs {
+		idRefs[i] = salesforce.IDRef{ID: donations[i%len(donations)].ID, Ref: "GRAPH-REF"}
+	}
+	idRefs[200].ID = "006000000000000AAA"
+	if _, err := sfClient.BatchUpdateOpportunityRefs(ctx, idRefs, true); err == nil {
+		t.Error("expected a graph update error for a missing record")
+	}
+	updated, err = sfClient.GetOpportunitiesByID(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || *updated[0].PayoutReference != "DEMO-REF" {
+		t.Errorf("failed graph update applied %+v", updated)
+	}
+	idRefs[200].ID = id
+	if _, err := sfClient.BatchUpdateOpportunityRefs(ctx, idRefs, true); err != nil {
+		t.Fatalf("graph update error: %v", err)
+	}
+	updated, err = sfClient.GetOpportunitiesByID(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || *updated[0].PayoutReference != "GRAPH-REF" {
+		t.Errorf("graph update not applied %+v", updated)
+	}
+
+	deleted, err := sfClient.GetDeletedOpportunityIDs(ctx, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("unexpected deleted ids %v", deleted)
+	}
+	preview, err := sfClient.PreviewOpportunities(ctx, cfg.DataStartDate, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(preview), 5; got != want {
+		t.Errorf("preview got %d want %d", got, want)
+	}
+
+	// Logout.
+	for _, et := range []*token.ExtendedToken{xeroToken, sfToken} {
+		oauthCfg := cfg.Xero.OAuth2Config
+		if et.Type == token.SalesforceToken {
+			oauthCfg = cfg.Salesforce.OAuth2Config
+		}
+		if err := et.Revoke(ctx, oauthCfg); err != nil {
+			t.Errorf("%s revoke error: %v", et.Type, err)
+		}
+	}
+}
+
+// TestMockAPIsAuthorize tests the authorization page and the refusal of unauthorized
+// api calls.
+func TestMockAPIsAuthorize(t *testing.T) {
+
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := url.Values{"redirect_uri": {"http://localhost:8080/xero/callback"}, "state": {"abc"}}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", AuthorizePath+"?"+params.Encode(), nil))
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("authorize status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "http://localhost:8080/xero/callback?code=mock-code&state=abc"; got != want {
+		t.Errorf("authorize location got %q want %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", AuthorizePath, nil))
+	if got, want := rec.Code, http.StatusBadRequest; got != want {
+		t.Errorf("authorize without redirect status got %d want %d", got, want)
+	}
+
+	resp, err := s.Client().Get("https://api.xero.com/api.xro/2.0/Invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusUnauthorized; got != want {
+		t.Errorf("unauthorized status got %d want %d", got, want)
+	}
+}
+
+// TestMockAPIsPagingAndModified tests that the api clients read every page of the
+// listings with small page sizes, and that only records modified since a time are
+// returned.
+func TestMockAPIsPagingAndModified(t *testing.T) {
+
+	cfg, err := config.Load("../../config/config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Configure(cfg)
+	ctx := s.WithClient(context.Background())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tok, err := cfg.Xero.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroToken, err := token.NewExtendedToken(token.XeroToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xeroClient, err := xero.NewClient(ctx, logger, cfg.DonationAccountCodesAsRegex(), xeroToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err = cfg.Salesforce.OAuth2Config.Exchange(ctx, "mock-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfToken, err := token.NewExtendedToken(token.SalesforceToken, tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfClient, err := salesforce.NewClient(ctx, cfg, logger, sfToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allInvoices, err := xeroClient.GetInvoices(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetPageSizes(3, 5)
+	invoices, err := xeroClient.GetInvoices(ctx, cfg.DataStartDate, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(invoices), len(allInvoices); got != want || got <= 3 {
+		t.Errorf("paged invoices got %d want %d", got, want)
+	}
+	donations, err := sfClient.GetOpportunities(ctx, cfg.DataStartDate, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(donations), 34; got != want {
+		t.Errorf("paged donations got %d want %d", got, want)
+	}
+
+	// Only the records updated after since are returned.
+	since := time.Now().Add(-time.Second)
+	if _, err := xeroClient.UpdateInvoiceReference(ctx, invoices[0].InvoiceID, "MODIFIED-REF"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sfClient.BatchUpdateOpportunityRefs(ctx, []salesforce.IDRef{{ID: donations[0].ID, Ref: "MODIFIED-REF"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	invoices, err = xeroClient.GetInvoices(ctx, cfg.DataStartDate, since, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invoices) != 1 || invoices[0].Reference != "MODIFIED-REF" {
+		t.Errorf("modified invoices got %+v", invoices)
+	}
+	donations, err = sfClient.GetOpportunities(ctx, cfg.DataStartDate, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(donations) != 1 || donations[0].PayoutReference == nil || *donations[0].PayoutReference != "MODIFIED-REF" {
+		t.Errorf("modified donations got %+v", donations)
+	}
+
+}
@@ -0,0 +1,193 @@
+// package money provides a decimal-safe monetary amount held as an integer number of
+// minor currency units (pence, cents). Amounts from the Xero and Salesforce APIs and
+// from the database are converted on the way in, so that the summing and comparison
+// of totals is exact.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary amount in minor currency units. The zero value is zero.
+type Amount int64
+
+// ErrInvalidAmount reports a value which cannot be parsed as an amount.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// FromFloat converts a float in major currency units to an Amount, as ParseFloat, for
+// amounts known to fit, such as constants. It panics if f is not a valid amount.
+func FromFloat(f float64) Amount {
+	a, err := ParseFloat(f)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// ParseFloat converts a float in major currency units to an Amount, rounding half away
+// from zero. The shortest decimal representation of f is used so that, for example,
+// 1.005 rounds to 1.01. ErrInvalidAmount is returned if f is not finite or does not
+// fit in an Amount.
+func ParseFloat(f float64) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%w: %g", ErrInvalidAmount, f)
+	}
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// Parse parses a decimal string in major currency units, such as "-12.345", rounding
+// half away from zero to the nearest minor unit.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty", ErrInvalidAmount)
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+		return ParseFloat(f)
+	}
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+	}
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	frac += "000"
+	minor, _ := strconv.ParseInt(frac[:2], 10, 64)
+	if frac[2] >= '5' {
+		minor++
+	}
+	if units > (math.MaxInt64-minor)/100 {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+	}
+	a := units*100 + minor
+	if negative {
+		a = -a
+	}
+	return Amount(a), nil
+}
+
+// Float returns the amount in major currency units.
+func (a Amount) Float() float64 {
+	return float64(a) / 100
+}
+
+// Abs returns the absolute value of the amount.
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Within reports whether a and b differ by no more than tolerance.
+func (a Amount) Within(b, tolerance Amount) bool {
+	return (a - b).Abs() <= tolerance
+}
+
+// String returns the amount in major currency units with two decimal places.
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign = "-"
+	}
+	abs := a.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// Format implements fmt.Formatter so that amounts may be formatted with the float
+// verbs, for example "%.2f" in templates, as well as with %s and %v. %d formats the
+// amount in minor units.
+func (a Amount) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), a.Float())
+	case 'd':
+		_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), int64(a))
+	default:
+		_, _ = fmt.Fprintf(f, fmt.FormatString(f, 's'), a.String())
+	}
+}
+
+// MarshalJSON encodes the amount as a JSON number in major currency units.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number in major currency units without passing through
+// a float. A null leaves the amount unchanged.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	v, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value implements driver.Valuer, storing the amount in major currency units to match
+// the REAL columns of the database.
+func (a Amount) Value() (driver.Value, error) {
+	return a.Float(), nil
+}
+
+// Scan implements sql.Scanner, reading an amount in major currency units. A null is
+// read as zero.
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case float64:
+		p, err := ParseFloat(v)
+		if err != nil {
+			return err
+		}
+		*a = p
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return fmt.Errorf("%w: %d is out of range", ErrInvalidAmount, v)
+		}
+		*a = Amount(v * 100)
+	case []byte:
+		return a.Scan(string(v))
+	case string:
+		p, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*a = p
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+	return nil
+}
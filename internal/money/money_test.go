@@ -0,0 +1,167 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  bool
+	}{
+		{in: "0", want: 0},
+		{in: "12", want: 1200},
+		{in: "12.3", want: 1230},
+		{in: "12.34", want: 1234},
+		{in: "-12.34", want: -1234},
+		{in: "+0.5", want: 50},
+		{in: ".25", want: 25},
+		{in: "1.005", want: 101},
+		{in: "1.0049", want: 100},
+		{in: "-1.005", want: -101},
+		{in: "1.5e2", want: 15000},
+		{in: "", err: true},
+		{in: ".", err: true},
+		{in: "1.2.3", err: true},
+		{in: "£12", err: true},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "-92233720368547758.07", want: -math.MaxInt64},
+		{in: "92233720368547758.08", err: true},
+		{in: "92233720368547758.075", err: true},
+		{in: "1e18", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidAmount) {
+					t.Fatalf("expected ErrInvalidAmount, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloatSums(t *testing.T) {
+	// 0.1 + 0.2 is not 0.3 in floating point.
+	var total Amount
+	for range 3 {
+		total += FromFloat(0.1)
+	}
+	if total != FromFloat(0.3) {
+		t.Errorf("got %s want 0.30", total)
+	}
+	if got := FromFloat(0.1 + 0.2); got != 30 {
+		t.Errorf("got %d want 30", got)
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e17, -1e17} {
+		if _, err := ParseFloat(f); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("%g: expected ErrInvalidAmount, got %v", f, err)
+		}
+	}
+	if got, err := ParseFloat(1e15); err != nil || got != 1e17 {
+		t.Errorf("got %d, %v want %d", got, err, int64(1e17))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected FromFloat to panic for an out of range amount")
+		}
+	}()
+	_ = FromFloat(1e17)
+}
+
+func TestFormat(t *testing.T) {
+	a := Amount(-123456)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"%s", "-1234.56"},
+		{"%v", "-1234.56"},
+		{"%.2f", "-1234.56"},
+		{"£%.2f", "£-1234.56"},
+		{"%10.1f", "   -1234.6"},
+		{"%d", "-123456"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, a); got != tt.want {
+			t.Errorf("%s: got %q want %q", tt.format, got, tt.want)
+		}
+	}
+	if got, want := Amount(5).String(), "0.05"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Total  Amount
+		Amount Amount
+		Null   Amount
+	}
+	if err := json.Unmarshal([]byte(`{"Total": 169.755, "Amount": -2, "Null": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Total != 16976 || v.Amount != -200 || v.Null != 0 {
+		t.Errorf("unexpected values %+v", v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"Total":169.76,"Amount":-2.00,"Null":0.00}`; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  any
+		want Amount
+	}{
+		{nil, 0},
+		{0.1 + 0.2, 30},
+		{int64(20), 2000},
+		{[]byte("12.5"), 1250},
+		{"7.99", 799},
+	}
+	for _, tt := range tests {
+		var a Amount = 1
+		if err := a.Scan(tt.src); err != nil {
+			t.Fatal(err)
+		}
+		if a != tt.want {
+			t.Errorf("%v: got %d want %d", tt.src, a, tt.want)
+		}
+	}
+	var a Amount
+	for _, src := range []any{true, int64(math.MaxInt64 / 10), 1e20, math.NaN()} {
+		if err := a.Scan(src); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("%v: expected ErrInvalidAmount, got %v", src, err)
+		}
+	}
+	if v, _ := Amount(1999).Value(); v != 19.99 {
+		t.Errorf("value got %v", v)
+	}
+}
+
+func TestWithin(t *testing.T) {
+	if !Amount(1000).Within(1001, 1) || Amount(1000).Within(1002, 1) {
+		t.Error("unexpected Within result")
+	}
+}
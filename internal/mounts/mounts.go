@@ -1,7 +1,9 @@
 // package mounts provides abstracted filemounts to use as fs.FS filesystems in a
 // program. The package allows either the embedded file system to be used or, when
 // specified, the path to a directory on disk. The package takes care of mounting the
-// filesystem at the same level, something that does not happen by default.
+// filesystem at the same level, something that does not happen by default. An overlay
+// mount uses the embedded file system with individual files shadowed by those of a
+// directory on disk.
 package internal
 
 import (
@@ -10,6 +12,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -101,6 +104,96 @@ func NewFileMount(mountName string, embeddedFS fs.FS, dirPath string) (*FileMoun
 	}, nil
 }
 
+// NewOverlayMount mounts the embedded fs.FS at mountName, as NewFileMount does, with
+// the files in the directory at overrideDir shadowing the embedded files of the same
+// name. Files present only in the embedded fs remain available, so that individual
+// files may be customised without copying the whole directory. If overrideDir is ""
+// the mount is the same as the embedded mount.
+func NewOverlayMount(mountName string, embeddedFS fs.FS, overrideDir string) (*FileMount, error) {
+	base, err := NewFileMount(mountName, embeddedFS, "")
+	if err != nil {
+		return nil, err
+	}
+	if overrideDir == "" {
+		return base, nil
+	}
+	override, err := NewFileMount(mountName, embeddedFS, overrideDir)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMount{
+		mountName,
+		overlayFS{override: override.FS, base: base.FS},
+	}, nil
+}
+
+// Overrides lists the files of the override directory of an overlay mount which
+// shadow files of the embedded fs. It returns nil for other mounts.
+func (fm FileMount) Overrides() []string {
+	o, ok := fm.FS.(overlayFS)
+	if !ok {
+		return nil
+	}
+	var names []string
+	_ = fs.WalkDir(o.override, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if _, err := fs.Stat(o.base, path); err == nil {
+			names = append(names, path)
+		}
+		return nil
+	})
+	return names
+}
+
+// overlayFS is an fs.FS in which the files of override shadow those of base.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+// Open opens name from the override fs if it is a file there, otherwise from the base
+// fs, falling back to the override fs for directories only found there.
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if s, err := fs.Stat(o.override, name); err == nil && !s.IsDir() {
+		return o.override.Open(name)
+	}
+	f, err := o.base.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.override.Open(name)
+	}
+	return f, err
+}
+
+// ReadDir lists the entries of the directory name in both filesystems, preferring the
+// entries of the override fs. The entries are sorted by name.
+func (o overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	baseEntries, baseErr := fs.ReadDir(o.base, name)
+	overrideEntries, overrideErr := fs.ReadDir(o.override, name)
+	if baseErr != nil && overrideErr != nil {
+		return nil, baseErr
+	}
+	byName := map[string]fs.DirEntry{}
+	for _, e := range baseEntries {
+		byName[e.Name()] = e
+	}
+	for _, e := range overrideEntries {
+		if b, ok := byName[e.Name()]; ok && b.IsDir() != e.IsDir() {
+			continue
+		}
+		byName[e.Name()] = e
+	}
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for _, e := range byName {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
 // Materialize outputs the data in fm.FS recursively to the filesystem starting at
 // root plus mount name. For example running:
 //
@@ -165,3 +165,56 @@ func TestMounts(t *testing.T) {
 		})
 	}
 }
+
+func TestOverlayMount(t *testing.T) {
+
+	fm, err := NewOverlayMount("testdata/dirA", testdataDirA, "testdata/override")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The override shadows the embedded file, and the embedded files remain.
+	for name, want := range map[string]string{
+		"a":      "override a\n",
+		"c":      "c\n",
+		"dirB/b": "b\n",
+		"dirB/d": "extra\n",
+	} {
+		data, err := fs.ReadFile(fm.FS, name)
+		if err != nil {
+			t.Fatalf("could not read %q: %v", name, err)
+		}
+		if got := string(data); got != want {
+			t.Errorf("%q got %q want %q", name, got, want)
+		}
+	}
+
+	entries, err := fs.ReadDir(fm.FS, "dirB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if diff := cmp.Diff([]string{"b", "d"}, names); diff != "" {
+		t.Errorf("unexpected dirB entries:\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string{"a"}, fm.Overrides()); diff != "" {
+		t.Errorf("unexpected overrides:\n%s", diff)
+	}
+
+	// Without an override directory the mount is the embedded mount.
+	plain, err := NewOverlayMount("testdata/dirA", testdataDirA, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := plain.Overrides(); got != nil {
+		t.Errorf("expected no overrides, got %v", got)
+	}
+
+	if _, err := NewOverlayMount("testdata/dirA", testdataDirA, "./doesNotExist"); err == nil {
+		t.Error("expected error for a missing override directory")
+	}
+}
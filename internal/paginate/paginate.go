@@ -0,0 +1,146 @@
+// package paginate paginates the listing pages. A Page reports the page number, number
+// of pages and the previous and next pages of a listing of a number of records shown a
+// page length at a time, the database offset of the page, and a window of the page
+// numbers around the current page, with the first and last pages and ellipses marking
+// the pages left out. The urls of the pages keep the query of the listing, with the
+// page number replaced.
+package paginate
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"strconv"
+)
+
+// WindowSize is the number of pages either side of the current page in a Window.
+const WindowSize = 2
+
+// ErrInvalidPageNo reports a page number beyond the last page.
+type ErrInvalidPageNo struct {
+	PageNo     int
+	TotalPages int
+}
+
+func (e ErrInvalidPageNo) Error() string {
+	return fmt.Sprintf("invalid page number: %d (total pages: %d)", e.PageNo, e.TotalPages)
+}
+
+// Page is a page of a listing.
+type Page struct {
+	pageLen   int
+	queryVals url.Values
+
+	PageNo   int
+	Pages    int
+	Next     int // 0 means no next page
+	Previous int // 0 means no previous page
+}
+
+// Link is a link to a page in a Window. An Ellipsis marks the pages left out of the
+// window, and has no page.
+type Link struct {
+	PageNo   int
+	URL      string
+	Current  bool
+	Ellipsis bool
+}
+
+// Offset returns the database offset of the (1-based) page pageNo of pageLen records.
+// Page numbers below 1 are taken to be the first page.
+func Offset(pageNo, pageLen int) int {
+	return (max(pageNo, 1) - 1) * max(pageLen, 0)
+}
+
+// New calculates the pagination of a listing of totalRecords records shown pageLen at
+// a time, at page currentPage, with the query of the listing url. A page length below
+// 1 is taken to be 1, and a current page below 1 to be the first page. ErrInvalidPageNo
+// is returned for a page beyond the last page. An empty listing has one page.
+func New(pageLen, totalRecords, currentPage int, query url.Values) (*Page, error) {
+
+	if pageLen <= 0 {
+		pageLen = 1
+	}
+
+	totalPages := 1
+	if totalRecords > 0 {
+		totalPages = ((totalRecords - 1) / pageLen) + 1
+	}
+
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if currentPage > totalPages {
+		return nil, ErrInvalidPageNo{PageNo: currentPage, TotalPages: totalPages}
+	}
+	p := &Page{
+		pageLen:   pageLen,
+		queryVals: query,
+		PageNo:    currentPage,
+		Pages:     totalPages,
+	}
+	if p.PageNo > 1 {
+		p.Previous = p.PageNo - 1
+	}
+	if p.PageNo < p.Pages {
+		p.Next = p.PageNo + 1
+	}
+	return p, nil
+}
+
+// Offset returns the database offset of the page.
+func (p *Page) Offset() int {
+	return Offset(p.PageNo, p.pageLen)
+}
+
+// URL returns the url query string of page pageNo.
+func (p *Page) URL(pageNo int) string {
+	newQuery := make(url.Values, len(p.queryVals))
+	maps.Copy(newQuery, p.queryVals)
+	newQuery.Set("page", strconv.Itoa(pageNo))
+	return "?" + newQuery.Encode()
+}
+
+// NextURL returns the URL for the next page. Returns empty string if no next page.
+func (p *Page) NextURL() string {
+	if p.Next == 0 {
+		return ""
+	}
+	return p.URL(p.Next)
+}
+
+// PreviousURL returns the URL for the previous page. Returns empty string if no prev page.
+func (p *Page) PreviousURL() string {
+	if p.Previous == 0 {
+		return ""
+	}
+	return p.URL(p.Previous)
+}
+
+// Window returns the links to the first and last pages and the pages within WindowSize
+// of the current page, in page order, with an ellipsis in place of each run of pages
+// left out. A run of a single page is shown rather than an ellipsis.
+func (p *Page) Window() []Link {
+	from, to := max(p.PageNo-WindowSize, 1), min(p.PageNo+WindowSize, p.Pages)
+	if from <= 3 {
+		from = 1
+	}
+	if to >= p.Pages-2 {
+		to = p.Pages
+	}
+	link := func(pageNo int) Link {
+		return Link{PageNo: pageNo, URL: p.URL(pageNo), Current: pageNo == p.PageNo}
+	}
+
+	var links []Link
+	if from > 1 {
+		links = append(links, link(1), Link{Ellipsis: true})
+	}
+	for pageNo := from; pageNo <= to; pageNo++ {
+		links = append(links, link(pageNo))
+	}
+	if to < p.Pages {
+		links = append(links, Link{Ellipsis: true}, link(p.Pages))
+	}
+	return links
+}
@@ -1,16 +1,17 @@
-package web
+package paginate
 
 import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
-func TestPagination(t *testing.T) {
+func TestNew(t *testing.T) {
 
 	tests := []struct {
 		name         string
@@ -19,7 +20,7 @@ func TestPagination(t *testing.T) {
 		totalRecords int
 		currentPage  int
 
-		pagination  *Pagination
+		pagination  *Page
 		nextURL     string
 		previousURL string
 		err         error
@@ -30,7 +31,7 @@ func TestPagination(t *testing.T) {
 			pageLen:      5,
 			totalRecords: 13,
 			currentPage:  2,
-			pagination: &Pagination{
+			pagination: &Page{
 				PageNo:   2,
 				Pages:    3,
 				Next:     3,
@@ -45,7 +46,7 @@ func TestPagination(t *testing.T) {
 			pageLen:      5,
 			totalRecords: 5,
 			currentPage:  1,
-			pagination: &Pagination{
+			pagination: &Page{
 				PageNo:   1,
 				Pages:    1,
 				Next:     0,
@@ -60,7 +61,7 @@ func TestPagination(t *testing.T) {
 			pageLen:      -5,
 			totalRecords: 5,
 			currentPage:  1,
-			pagination: &Pagination{
+			pagination: &Page{
 				PageNo:   1,
 				Pages:    5, // default pagelen 5
 				Next:     2,
@@ -86,7 +87,7 @@ func TestPagination(t *testing.T) {
 			if err != nil {
 				t.Fatalf("could not parse inputURL: %v", err)
 			}
-			pg, err := NewPagination(tt.pageLen, tt.totalRecords, tt.currentPage, parsedURL.Query())
+			pg, err := New(tt.pageLen, tt.totalRecords, tt.currentPage, parsedURL.Query())
 			if err != nil {
 				if !errors.Is(err, tt.err) {
 					t.Fatalf("could not parse inputURL: %v", err)
@@ -98,7 +99,7 @@ func TestPagination(t *testing.T) {
 				t.Fatalf("expected error: %v", tt.err)
 			}
 
-			if diff := cmp.Diff(pg, tt.pagination, cmpopts.IgnoreUnexported(Pagination{})); diff != "" {
+			if diff := cmp.Diff(pg, tt.pagination, cmpopts.IgnoreUnexported(Page{})); diff != "" {
 				t.Errorf("pagination struct error %v", diff)
 			}
 
@@ -111,3 +112,78 @@ func TestPagination(t *testing.T) {
 		})
 	}
 }
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		pageNo, pageLen, want int
+	}{
+		{1, 25, 0},
+		{2, 25, 25},
+		{4, 10, 30},
+		{0, 25, 0},
+		{-1, 25, 0},
+	}
+	for _, tt := range tests {
+		if got := Offset(tt.pageNo, tt.pageLen); got != tt.want {
+			t.Errorf("Offset(%d, %d) got %d want %d", tt.pageNo, tt.pageLen, got, tt.want)
+		}
+	}
+	p, err := New(10, 95, 4, url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Offset(), 30; got != want {
+		t.Errorf("page offset got %d want %d", got, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+
+	// window returns the window as a string, such as "1 … 4 [5] 6 … 10".
+	window := func(links []Link) string {
+		var s []string
+		for _, l := range links {
+			switch {
+			case l.Ellipsis:
+				s = append(s, "…")
+			case l.Current:
+				s = append(s, fmt.Sprintf("[%d]", l.PageNo))
+			default:
+				s = append(s, fmt.Sprint(l.PageNo))
+			}
+		}
+		return strings.Join(s, " ")
+	}
+
+	tests := []struct {
+		pages, pageNo int
+		want          string
+	}{
+		{1, 1, "[1]"},
+		{3, 2, "1 [2] 3"},
+		{10, 1, "[1] 2 3 … 10"},
+		{10, 3, "1 2 [3] 4 5 … 10"},
+		{10, 4, "1 2 3 [4] 5 6 … 10"},
+		{10, 5, "1 2 3 4 [5] 6 7 … 10"},
+		{10, 6, "1 … 4 5 [6] 7 8 9 10"},
+		{20, 10, "1 … 8 9 [10] 11 12 … 20"},
+		{10, 8, "1 … 6 7 [8] 9 10"},
+		{10, 10, "1 … 8 9 [10]"},
+		{6, 3, "1 2 [3] 4 5 6"},
+	}
+	for _, tt := range tests {
+		p, err := New(1, tt.pages, tt.pageNo, url.Values{"status": {"All"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		links := p.Window()
+		if got := window(links); got != tt.want {
+			t.Errorf("window of page %d of %d got %q want %q", tt.pageNo, tt.pages, got, tt.want)
+		}
+		for _, l := range links {
+			if want := fmt.Sprintf("?page=%d&status=All", l.PageNo); !l.Ellipsis && l.URL != want {
+				t.Errorf("link url got %q want %q", l.URL, want)
+			}
+		}
+	}
+}
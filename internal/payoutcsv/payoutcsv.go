@@ -0,0 +1,228 @@
+// package payoutcsv reads the payout reports exported as CSV by the Stripe and
+// JustGiving donation platforms. A payout report itemises the donations paid out in a
+// single bank transfer with the fee deducted from each, so that the composition of a
+// payout can be explained where the Salesforce donations are incomplete.
+//
+// The platform is detected from the column headings, which are matched ignoring case
+// and the difference between spaces and underscores. Only the columns needed for the
+// Items are read; others are ignored.
+package payoutcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// The supported platforms.
+const (
+	Stripe     = "stripe"
+	JustGiving = "justgiving"
+)
+
+// ErrUnknownFormat reports a CSV file which is not a recognised payout report.
+var ErrUnknownFormat = errors.New("unrecognised payout report")
+
+// Item is a donation in a payout report. The Fee is the positive amount deducted from
+// the Gross amount, leaving the Net amount paid out.
+type Item struct {
+	Ref         string // the platform's id for the donation or balance transaction
+	PayoutID    string // the platform's id for the payout, if reported
+	Date        time.Time
+	Name        string
+	Description string
+	Gross       money.Amount
+	Fee         money.Amount
+	Net         money.Amount
+}
+
+// columns lists the alternative headings of each column of a platform's reports, in
+// order of preference. Empty alternatives mark optional columns.
+type columns struct {
+	ref, payoutID, date, name, firstName, lastName, description, gross, fee, net []string
+}
+
+// format is the column headings of the reports of a platform. The detect headings
+// identify the platform.
+type format struct {
+	platform string
+	detect   []string
+	cols     columns
+}
+
+// formats are the formats of the supported platforms.
+var formats = []format{
+	{
+		platform: Stripe,
+		detect:   []string{"balance transaction id", "automatic payout id", "reporting category"},
+		cols: columns{
+			ref:         []string{"balance transaction id", "source id", "id"},
+			payoutID:    []string{"automatic payout id", "transfer"},
+			date:        []string{"created utc", "created (utc)", "created"},
+			name:        []string{"customer name", "customer description", "customer email"},
+			description: []string{"description"},
+			gross:       []string{"gross", "amount"},
+			fee:         []string{"fee"},
+			net:         []string{"net"},
+		},
+	},
+	{
+		platform: JustGiving,
+		detect:   []string{"donation ref", "donation reference", "donation id"},
+		cols: columns{
+			ref:         []string{"donation ref", "donation reference", "donation id"},
+			payoutID:    []string{"payment reference", "payout reference", "payment id"},
+			date:        []string{"donation date", "date"},
+			name:        []string{"donor display name", "donor name"},
+			firstName:   []string{"donor first name"},
+			lastName:    []string{"donor last name"},
+			description: []string{"message", "donation message", "page title"},
+			gross:       []string{"donation amount", "amount"},
+			fee:         []string{"transaction fee", "fee", "fees"},
+			net:         []string{"net amount", "net donation", "net"},
+		},
+	},
+}
+
+// dateLayouts are the date formats of the reports.
+var dateLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"02/01/2006",
+}
+
+// normalise returns the comparable form of a column heading.
+func normalise(heading string) string {
+	heading = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(heading, "\ufeff")))
+	return strings.Join(strings.Fields(strings.ReplaceAll(heading, "_", " ")), " ")
+}
+
+// index returns the index of the first of the alternative headings found in header, or
+// -1.
+func index(header []string, alternatives []string) int {
+	for _, a := range alternatives {
+		if i := slices.Index(header, a); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Read reads a payout report, returning its platform and items. Rows without a
+// reference, such as totals, are skipped. An error reports the row at fault.
+func Read(r io.Reader) (string, []Item, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = normalise(h)
+	}
+
+	f := slices.IndexFunc(formats, func(f format) bool {
+		return index(header, f.detect) >= 0
+	})
+	if f < 0 {
+		return "", nil, ErrUnknownFormat
+	}
+	platform, cols := formats[f].platform, formats[f].cols
+
+	idx := map[string]int{
+		"ref":         index(header, cols.ref),
+		"payout id":   index(header, cols.payoutID),
+		"date":        index(header, cols.date),
+		"name":        index(header, cols.name),
+		"first name":  index(header, cols.firstName),
+		"last name":   index(header, cols.lastName),
+		"description": index(header, cols.description),
+		"gross":       index(header, cols.gross),
+		"fee":         index(header, cols.fee),
+		"net":         index(header, cols.net),
+	}
+	for _, required := range []string{"ref", "date", "gross"} {
+		if idx[required] < 0 {
+			return platform, nil, fmt.Errorf("%s payout report has no %s column", platform, required)
+		}
+	}
+
+	var items []Item
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return platform, nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		field := func(name string) string {
+			if i := idx[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		item := Item{
+			Ref:         field("ref"),
+			PayoutID:    field("payout id"),
+			Name:        field("name"),
+			Description: field("description"),
+		}
+		if item.Ref == "" {
+			continue
+		}
+		if item.Name == "" {
+			item.Name = strings.TrimSpace(field("first name") + " " + field("last name"))
+		}
+		if item.Date, err = parseDate(field("date")); err != nil {
+			return platform, nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		if item.Gross, err = parseAmount(field("gross")); err != nil {
+			return platform, nil, fmt.Errorf("row %d gross: %w", row, err)
+		}
+		if s := field("fee"); s != "" {
+			if item.Fee, err = parseAmount(s); err != nil {
+				return platform, nil, fmt.Errorf("row %d fee: %w", row, err)
+			}
+			item.Fee = item.Fee.Abs()
+		}
+		if s := field("net"); s != "" {
+			if item.Net, err = parseAmount(s); err != nil {
+				return platform, nil, fmt.Errorf("row %d net: %w", row, err)
+			}
+		} else {
+			item.Net = item.Gross - item.Fee
+		}
+		items = append(items, item)
+	}
+	return platform, items, nil
+}
+
+// parseDate parses a report date in any of the dateLayouts.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseAmount parses a report amount, which may have a currency symbol and thousands
+// separators.
+func parseAmount(s string) (money.Amount, error) {
+	s = strings.NewReplacer("£", "", "$", "", "€", "", ",", "").Replace(s)
+	return money.Parse(s)
+}
@@ -0,0 +1,84 @@
+package payoutcsv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestRead(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		csv      string
+		platform string
+		items    []Item
+		err      string
+	}{
+		{
+			name: "stripe",
+			csv: "balance_transaction_id,created_utc,currency,gross,fee,net,reporting_category,customer_name,description,automatic_payout_id\n" +
+				"txn_001,2025-04-18 10:22:33,gbp,100.00,1.70,98.30,charge,Online Donor,Donation,po_001\n" +
+				"txn_002,2025-04-19 08:00:00,gbp,\"1,150.00\",15.25,1134.75,charge,Social Donor,,po_001\n",
+			platform: Stripe,
+			items: []Item{
+				{"txn_001", "po_001", time.Date(2025, 4, 18, 10, 22, 33, 0, time.UTC), "Online Donor", "Donation", money.FromFloat(100), money.FromFloat(1.70), money.FromFloat(98.30)},
+				{"txn_002", "po_001", time.Date(2025, 4, 19, 8, 0, 0, 0, time.UTC), "Social Donor", "", money.FromFloat(1150), money.FromFloat(15.25), money.FromFloat(1134.75)},
+			},
+		},
+		{
+			name: "justgiving without net amounts",
+			csv: "Donation Ref,Donation Date,Donor First Name,Donor Last Name,Donation Amount,Transaction Fee,Payment Reference\n" +
+				"JG-1001,15/04/2025 09:30,Jane,Smith,£20.00,-0.50,JG-PAYOUT-2025-04-15\n" +
+				",,,,£20.00,,\n",
+			platform: JustGiving,
+			items: []Item{
+				{"JG-1001", "JG-PAYOUT-2025-04-15", time.Date(2025, 4, 15, 9, 30, 0, 0, time.UTC), "Jane Smith", "", money.FromFloat(20), money.FromFloat(0.50), money.FromFloat(19.50)},
+			},
+		},
+		{
+			name: "unknown format",
+			csv:  "date,amount\n2025-04-01,10.00\n",
+			err:  ErrUnknownFormat.Error(),
+		},
+		{
+			name:     "invalid date",
+			csv:      "Donation Ref,Donation Date,Donation Amount\nJG-1,April,10.00\n",
+			platform: JustGiving,
+			err:      `row 2: invalid date "April"`,
+		},
+		{
+			name:     "missing amount column",
+			csv:      "balance_transaction_id,created_utc,fee\ntxn_001,2025-04-18,1.00\n",
+			platform: Stripe,
+			err:      "stripe payout report has no gross column",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platform, items, err := Read(strings.NewReader(tt.csv))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got err %v want %s", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if platform != tt.platform {
+				t.Errorf("platform got %q want %q", platform, tt.platform)
+			}
+			if diff := cmp.Diff(tt.items, items); diff != "" {
+				t.Errorf("unexpected items (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, _, err := Read(strings.NewReader("")); err == nil || errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected a header error for an empty file, got %v", err)
+	}
+}
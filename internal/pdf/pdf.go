@@ -0,0 +1,187 @@
+// package pdf provides a minimal PDF writer for simple text documents such as reports,
+// using the standard PDF Type 1 fonts so that no fonts need to be embedded.
+//
+// Coordinates are in points (1/72 inch) from the top left of the page, unlike PDF
+// itself which measures from the bottom left.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page dimensions in points.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is one of the standard Type 1 fonts supported by the writer.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	Courier
+)
+
+// fontNames are the PDF base font names, in Font order.
+var fontNames = []string{"Helvetica", "Helvetica-Bold", "Courier"}
+
+// courierWidth is the width of each Courier glyph per point of font size.
+const courierWidth = 0.6
+
+// Document is a PDF document made up of pages of text and lines.
+type Document struct {
+	width, height float64
+	pages         []*bytes.Buffer
+	current       *bytes.Buffer
+}
+
+// New returns a new document with the page size width and height in points.
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// AddPage adds a new page to the document, which becomes the current page.
+func (d *Document) AddPage() {
+	d.current = new(bytes.Buffer)
+	d.pages = append(d.pages, d.current)
+}
+
+// PageCount returns the number of pages in the document.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// Height returns the page height.
+func (d *Document) Height() float64 {
+	return d.height
+}
+
+// Width returns the page width.
+func (d *Document) Width() float64 {
+	return d.width
+}
+
+// Text writes text on the current page with its baseline at x, y.
+func (d *Document) Text(x, y float64, font Font, size float64, text string) {
+	if d.current == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.current, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n",
+		font, size, x, d.height-y, escape(text))
+}
+
+// TextRight writes Courier text on the current page, right aligned to x.
+func (d *Document) TextRight(x, y, size float64, text string) {
+	d.Text(x-TextWidth(Courier, size, text), y, Courier, size, text)
+}
+
+// Line draws a line on the current page.
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	if d.current == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.current, "%.2f w %.2f %.2f m %.2f %.2f l S\n",
+		width, x1, d.height-y1, x2, d.height-y2)
+}
+
+// TextWidth returns the width of text in points. Only Courier widths are exact;
+// proportional font widths are estimated.
+func TextWidth(font Font, size float64, text string) float64 {
+	n := float64(len([]rune(text)))
+	if font == Courier {
+		return n * courierWidth * size
+	}
+	return n * 0.5 * size
+}
+
+// WriteTo writes the document to w in PDF format.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// Objects are numbered from 1: the catalog, the page tree, the fonts, then a
+	// page and content stream object for each page.
+	var objects []string
+	fontBase := 3
+	pageBase := fontBase + len(fontNames)
+
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageBase+2*i))
+	}
+	var fonts []string
+	for i := range fontNames {
+		fonts = append(fonts, fmt.Sprintf("/F%d %d 0 R", i, fontBase+i))
+	}
+
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+	)
+	for _, name := range fontNames {
+		objects = append(objects, fmt.Sprintf(
+			"<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	for i, p := range d.pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+				d.width, d.height, strings.Join(fonts, " "), pageBase+2*i+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()),
+		)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// winAnsi maps the characters outside Latin-1 which are in the WinAnsi encoding.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+// escape encodes text in the WinAnsi encoding and escapes it for use in a PDF string.
+// Characters which cannot be encoded are replaced with "?".
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		var c byte
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			c = byte(r)
+		case winAnsi[r] != 0:
+			c = winAnsi[r]
+		default:
+			c = '?'
+		}
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"(a) b\\c", `\(a\) b\\c`},
+		{"£10 – café", "\xa310 \x96 caf\xe9"},
+		{"line\nbreak", "line break"},
+		{"日本", "??"},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("test_%d", i), func(t *testing.T) {
+			if got := escape(tt.in); got != tt.want {
+				t.Errorf("got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+
+	doc := New(A4Width, A4Height)
+	doc.Text(50, 50, HelveticaBold, 14, "Reconciliation (report)")
+	doc.Line(50, 55, 545, 55, 0.5)
+	doc.TextRight(545, 70, 9, "1,234.56")
+	doc.AddPage()
+	doc.Text(50, 50, Helvetica, 9, "page 2")
+
+	if got, want := doc.PageCount(), 2; got != want {
+		t.Fatalf("page count got %d want %d", got, want)
+	}
+
+	var buf bytes.Buffer
+	n, err := doc.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(buf.Len()); got != want {
+		t.Errorf("written bytes got %d want %d", got, want)
+	}
+	out := buf.String()
+
+	for _, s := range []string{
+		"%PDF-1.4\n",
+		"/Count 2",
+		"/BaseFont /Helvetica-Bold",
+		`BT /F1 14.00 Tf 50.00 791.89 Td (Reconciliation \(report\)) Tj ET`,
+		// right aligned Courier text: 8 glyphs * 0.6 * 9pt = 43.2pt
+		"BT /F2 9.00 Tf 501.80 771.89 Td (1,234.56) Tj ET",
+		"(page 2) Tj",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output does not contain %q", s)
+		}
+	}
+	if !strings.HasSuffix(out, "%%EOF\n") {
+		t.Error("output does not end with the EOF marker")
+	}
+
+	// The xref offsets should each point to the start of their object.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	if m == nil {
+		t.Fatal("startxref not found")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point to xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xref:], -1)
+	if got, want := len(entries), 2+3+2*2; got != want {
+		t.Fatalf("xref entries got %d want %d", got, want)
+	}
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(e[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[offset:], want) {
+			t.Errorf("xref entry %d offset %d does not point to %q", i+1, offset, want)
+		}
+	}
+
+	// Stream lengths should match the stream contents.
+	for _, sm := range regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`).FindAllStringSubmatch(out, -1) {
+		length, _ := strconv.Atoi(sm[1])
+		if got := len(sm[2]); got != length {
+			t.Errorf("stream length got %d want %d", got, length)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// package requestid carries the id of a web request in its context, so that the logs of
+// the api clients and database queries made for a request can be traced to it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Header is the http header reporting the request id.
+const Header = "X-Request-ID"
+
+// maxLen is the maximum length of a request id accepted from a client.
+const maxLen = 64
+
+type ctxKey struct{}
+
+// New returns a new random request id.
+func New() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // rand.Read never returns an error.
+	return hex.EncodeToString(b)
+}
+
+// Valid reports if id may be used as a request id, being a non-empty string of at most
+// 64 letters, digits, dashes and underscores.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithID returns a copy of ctx carrying the request id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request id carried by ctx, or an empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Logger returns logger with a "request_id" attribute if ctx carries a request id, or
+// otherwise logger itself.
+func Logger(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if id := FromContext(ctx); id != "" {
+		return logger.With("request_id", id)
+	}
+	return logger
+}
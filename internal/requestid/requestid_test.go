@@ -0,0 +1,49 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+
+	id := New()
+	if !Valid(id) || len(id) != 16 {
+		t.Errorf("invalid new id %q", id)
+	}
+	for _, tt := range []struct {
+		id    string
+		valid bool
+	}{
+		{"abc-123_DEF", true},
+		{"", false},
+		{"has space", false},
+		{"<script>", false},
+		{strings.Repeat("a", 65), false},
+	} {
+		if got := Valid(tt.id); got != tt.valid {
+			t.Errorf("valid %q got %t want %t", tt.id, got, tt.valid)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+
+	Logger(context.Background(), logger).Info("without")
+	if strings.Contains(buf.String(), "request_id") {
+		t.Errorf("unexpected request id in %q", buf.String())
+	}
+	buf.Reset()
+
+	ctx := WithID(context.Background(), "req-1")
+	if got := FromContext(ctx); got != "req-1" {
+		t.Errorf("id got %q want %q", got, "req-1")
+	}
+	Logger(ctx, logger).Info("with")
+	if !strings.Contains(buf.String(), "request_id=req-1") {
+		t.Errorf("request id not logged in %q", buf.String())
+	}
+}
@@ -0,0 +1,171 @@
+// package statementcsv reads bank statements exported as CSV by online banking, so
+// that the money received can be compared with the bank transactions recorded in Xero.
+//
+// Banks differ in the headings and layout of their exports. The columns are found by
+// their headings, which are matched ignoring case and the difference between spaces and
+// underscores. The amount of a line is read either from a single signed amount column
+// or from separate money in and money out columns. Dates are read day first, as is the
+// practice of UK banks.
+package statementcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// ErrUnknownFormat reports a CSV file which is not a recognised bank statement.
+var ErrUnknownFormat = errors.New("unrecognised bank statement")
+
+// Line is a line of a bank statement. The Amount is positive for money received and
+// negative for money paid out. The ID is the bank's id for the line, if exported.
+type Line struct {
+	ID          string
+	Date        time.Time
+	Amount      money.Amount
+	Description string
+	Reference   string
+}
+
+// The alternative headings of each column, in order of preference.
+var (
+	idCols          = []string{"transaction id", "fitid", "unique id", "id"}
+	dateCols        = []string{"date", "transaction date", "posting date", "posted date", "value date"}
+	descriptionCols = []string{"description", "transaction description", "details", "narrative", "payee", "name", "memo"}
+	referenceCols   = []string{"reference", "payment reference", "ref"}
+	amountCols      = []string{"amount", "transaction amount", "value"}
+	creditCols      = []string{"paid in", "money in", "credit", "credit amount", "in"}
+	debitCols       = []string{"paid out", "money out", "debit", "debit amount", "out"}
+)
+
+// dateLayouts are the date formats of the statements.
+var dateLayouts = []string{
+	"02/01/2006",
+	"2/1/2006",
+	"02/01/06",
+	"2006-01-02",
+	"02-01-2006",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"02-Jan-2006",
+	"02 Jan 06",
+}
+
+// normalise returns the comparable form of a column heading.
+func normalise(heading string) string {
+	heading = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(heading, "\ufeff")))
+	return strings.Join(strings.Fields(strings.ReplaceAll(heading, "_", " ")), " ")
+}
+
+// index returns the index of the first of the alternative headings found in header, or
+// -1.
+func index(header []string, alternatives []string) int {
+	for _, a := range alternatives {
+		if i := slices.Index(header, a); i >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Read reads a bank statement, returning its lines. Rows without a date or an amount,
+// such as opening and closing balances, are skipped. An error reports the row at fault.
+func Read(r io.Reader) ([]Line, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+	for i, h := range header {
+		header[i] = normalise(h)
+	}
+
+	idx := map[string]int{
+		"id":          index(header, idCols),
+		"date":        index(header, dateCols),
+		"description": index(header, descriptionCols),
+		"reference":   index(header, referenceCols),
+		"amount":      index(header, amountCols),
+		"credit":      index(header, creditCols),
+		"debit":       index(header, debitCols),
+	}
+	if idx["date"] < 0 || (idx["amount"] < 0 && idx["credit"] < 0) {
+		return nil, ErrUnknownFormat
+	}
+
+	var lines []Line
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		field := func(name string) string {
+			if i := idx[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		date := field("date")
+		amount, credit, debit := field("amount"), field("credit"), field("debit")
+		if date == "" || (amount == "" && credit == "" && debit == "") {
+			continue
+		}
+
+		line := Line{
+			ID:          field("id"),
+			Description: field("description"),
+			Reference:   field("reference"),
+		}
+		if line.Date, err = parseDate(date); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		switch {
+		case amount != "":
+			if line.Amount, err = parseAmount(amount); err != nil {
+				return nil, fmt.Errorf("row %d amount: %w", row, err)
+			}
+		case credit != "":
+			if line.Amount, err = parseAmount(credit); err != nil {
+				return nil, fmt.Errorf("row %d money in: %w", row, err)
+			}
+			line.Amount = line.Amount.Abs()
+		default:
+			if line.Amount, err = parseAmount(debit); err != nil {
+				return nil, fmt.Errorf("row %d money out: %w", row, err)
+			}
+			line.Amount = -line.Amount.Abs()
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// parseDate parses a statement date in any of the dateLayouts.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// parseAmount parses a statement amount, which may have a currency symbol and
+// thousands separators.
+func parseAmount(s string) (money.Amount, error) {
+	s = strings.NewReplacer("£", "", "$", "", "€", "", ",", "").Replace(s)
+	return money.Parse(s)
+}
@@ -0,0 +1,81 @@
+package statementcsv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestRead(t *testing.T) {
+
+	date := func(day int) time.Time { return time.Date(2025, 4, day, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name  string
+		csv   string
+		lines []Line
+		err   string
+	}{
+		{
+			name: "signed amounts",
+			csv: "Date,Transaction Type,Description,Reference,Amount,Balance\n" +
+				"15/04/2025,CR,JUSTGIVING,JG-PAYOUT-2025-04-15,337.25,1337.25\n" +
+				"16/04/2025,DD,ENERGY CO,,\"-1,012.00\",325.25\n",
+			lines: []Line{
+				{"", date(15), money.FromFloat(337.25), "JUSTGIVING", "JG-PAYOUT-2025-04-15"},
+				{"", date(16), money.FromFloat(-1012), "ENERGY CO", ""},
+			},
+		},
+		{
+			name: "money in and out columns",
+			csv: "\ufeffTransaction_ID,Posted Date,Details,Paid In,Paid Out\n" +
+				"t-1,2025-04-20,STRIPE PAYMENTS,£490.00,\n" +
+				"t-2,2025-04-21,CARD FEE,,£2.50\n" +
+				",,Closing balance,,\n",
+			lines: []Line{
+				{"t-1", date(20), money.FromFloat(490), "STRIPE PAYMENTS", ""},
+				{"t-2", date(21), money.FromFloat(-2.50), "CARD FEE", ""},
+			},
+		},
+		{
+			name: "unknown format",
+			csv:  "Donation Ref,Gross\nJG-1,10.00\n",
+			err:  ErrUnknownFormat.Error(),
+		},
+		{
+			name: "invalid date",
+			csv:  "Date,Amount\nApril,10.00\n",
+			err:  `row 2: invalid date "April"`,
+		},
+		{
+			name: "invalid amount",
+			csv:  "Date,Amount\n01/04/2025,ten\n",
+			err:  "row 2 amount",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Read(strings.NewReader(tt.csv))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v want %q", err, tt.err)
+				}
+				if tt.err == ErrUnknownFormat.Error() && !errors.Is(err, ErrUnknownFormat) {
+					t.Errorf("expected ErrUnknownFormat, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.lines, lines); diff != "" {
+				t.Errorf("lines mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// package subsetsum finds the combinations of amounts which sum to a target amount
+// within a tolerance, such as the donations making up a platform payout. The search
+// is a depth first search over the amounts, largest first, pruning combinations which
+// exceed the target or cannot reach it. As the number of combinations grows
+// exponentially with the number of amounts, the search is bounded by Limits and
+// reports if it was cut short.
+package subsetsum
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// Limits bound a search. MaxItems is the largest number of amounts in a combination,
+// MaxSteps the number of search steps after which the search stops and MaxResults the
+// number of combinations returned. A zero value is unbounded.
+type Limits struct {
+	MaxItems   int
+	MaxSteps   int
+	MaxResults int
+}
+
+// Combination is a combination of amounts, identified by their ascending indices in
+// the amounts searched. Difference is the Total less the target.
+type Combination struct {
+	Indices    []int
+	Total      money.Amount
+	Difference money.Amount
+}
+
+// better reports if the combination a is better than b, being closer to the target,
+// then having fewer amounts, then having earlier amounts. Callers may therefore order
+// the amounts by preference.
+func better(a, b Combination) bool {
+	if c := cmp.Compare(a.Difference.Abs(), b.Difference.Abs()); c != 0 {
+		return c < 0
+	}
+	if c := cmp.Compare(len(a.Indices), len(b.Indices)); c != 0 {
+		return c < 0
+	}
+	return slices.Compare(a.Indices, b.Indices) < 0
+}
+
+// Find returns the best combinations of the positive amounts which sum to target
+// within tolerance, best first, and whether the search was complete. Zero and negative
+// amounts are not included in combinations.
+func Find(amounts []money.Amount, target, tolerance money.Amount, limits Limits) ([]Combination, bool) {
+
+	// Search the positive amounts largest first, so that combinations exceeding the
+	// target are pruned early.
+	var order []int
+	for i, a := range amounts {
+		if a > 0 {
+			order = append(order, i)
+		}
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		return cmp.Compare(amounts[j], amounts[i])
+	})
+
+	// remaining[k] is the sum of the amounts from position k in the search order.
+	remaining := make([]money.Amount, len(order)+1)
+	for k := len(order) - 1; k >= 0; k-- {
+		remaining[k] = remaining[k+1] + amounts[order[k]]
+	}
+
+	var (
+		results  []Combination
+		chosen   []int
+		steps    int
+		complete = true
+	)
+
+	add := func(total money.Amount) {
+		c := Combination{
+			Indices:    slices.Sorted(slices.Values(chosen)),
+			Total:      total,
+			Difference: total - target,
+		}
+		i, _ := slices.BinarySearchFunc(results, c, func(e, c Combination) int {
+			if better(e, c) {
+				return -1
+			}
+			return 1
+		})
+		results = slices.Insert(results, i, c)
+		if limits.MaxResults > 0 && len(results) > limits.MaxResults {
+			results = results[:limits.MaxResults]
+		}
+	}
+
+	var search func(k int, total money.Amount)
+	search = func(k int, total money.Amount) {
+		if !complete {
+			return
+		}
+		steps++
+		if limits.MaxSteps > 0 && steps > limits.MaxSteps {
+			complete = false
+			return
+		}
+		if len(chosen) > 0 && total.Within(target, tolerance) {
+			add(total)
+		}
+		if k == len(order) || (limits.MaxItems > 0 && len(chosen) == limits.MaxItems) {
+			return
+		}
+		// Prune if even all the remaining amounts cannot reach the target.
+		if total+remaining[k] < target-tolerance {
+			return
+		}
+		for j := k; j < len(order); j++ {
+			next := total + amounts[order[j]]
+			if next > target+tolerance {
+				continue
+			}
+			if total+remaining[j] < target-tolerance {
+				return
+			}
+			chosen = append(chosen, order[j])
+			search(j+1, next)
+			chosen = chosen[:len(chosen)-1]
+		}
+	}
+	search(0, 0)
+
+	return results, complete
+}
@@ -0,0 +1,96 @@
+package subsetsum
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestFind(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		amounts   []money.Amount
+		target    money.Amount
+		tolerance money.Amount
+		limits    Limits
+		want      [][]int
+		complete  bool
+	}{
+		{
+			name:     "exact combinations, fewest amounts first",
+			amounts:  []money.Amount{1000, 2500, 1500, 500, 3500, 500},
+			target:   3500,
+			want:     [][]int{{4}, {0, 1}, {1, 3, 5}, {0, 2, 3, 5}},
+			complete: true,
+		},
+		{
+			name:      "within tolerance, closest first",
+			amounts:   []money.Amount{1000, 2002, 995},
+			target:    3000,
+			tolerance: 5,
+			want:      [][]int{{0, 1}, {1, 2}},
+			complete:  true,
+		},
+		{
+			name:     "no combination",
+			amounts:  []money.Amount{1000, 2000},
+			target:   1500,
+			complete: true,
+		},
+		{
+			name:     "zero and negative amounts ignored",
+			amounts:  []money.Amount{0, -500, 1500, 1500},
+			target:   1500,
+			want:     [][]int{{2}, {3}},
+			complete: true,
+		},
+		{
+			name:     "limited items",
+			amounts:  []money.Amount{1000, 1000, 1000, 3000},
+			target:   3000,
+			limits:   Limits{MaxItems: 2},
+			want:     [][]int{{3}},
+			complete: true,
+		},
+		{
+			name:     "limited results",
+			amounts:  []money.Amount{1000, 2500, 1500, 500, 3500, 500},
+			target:   3500,
+			limits:   Limits{MaxResults: 2},
+			want:     [][]int{{4}, {0, 1}},
+			complete: true,
+		},
+		{
+			name:     "limited steps",
+			amounts:  []money.Amount{100, 100, 100, 100, 100, 100, 100, 100},
+			target:   800,
+			limits:   Limits{MaxSteps: 5},
+			complete: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, complete := Find(tt.amounts, tt.target, tt.tolerance, tt.limits)
+			if complete != tt.complete {
+				t.Errorf("complete got %t want %t", complete, tt.complete)
+			}
+			var got [][]int
+			for _, c := range results {
+				var total money.Amount
+				for _, i := range c.Indices {
+					total += tt.amounts[i]
+				}
+				if total != c.Total || c.Difference != total-tt.target {
+					t.Errorf("combination %v total %s difference %s for sum %s", c.Indices, c.Total, c.Difference, total)
+				}
+				got = append(got, c.Indices)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("unexpected combinations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a Store for command line clients which saves OAuth2 tokens to a json
+// file, so that a login can be reused by later commands such as scheduled jobs.
+// Other values, such as the login state and PKCE verifier, are only held in memory.
+// The context arguments are ignored.
+//
+// The file holds credentials and is written with owner-only permissions.
+type FileStore struct {
+	*MemoryStore
+	path string
+
+	mu  sync.Mutex
+	err error // the last save error
+}
+
+// tokenFileVersion is the version of the token file format.
+const tokenFileVersion = 1
+
+// tokenFile is the format of the token file, holding the tokens keyed by provider
+// name with their metadata, such as the Xero tenant ID and Salesforce instance url.
+// Token files saved before the format was versioned hold only the tokens keyed by
+// session name, and are migrated when loaded.
+type tokenFile struct {
+	Version int                       `json:"version"`
+	Tokens  map[string]*ExtendedToken `json:"tokens"`
+}
+
+// NewFileStore returns a FileStore saving tokens to path, loading any tokens already
+// saved there. A token file in the earlier unversioned format is rewritten in the
+// current format.
+func NewFileStore(path string) (*FileStore, error) {
+	fs := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read token file: %w", err)
+	}
+	tokens, migrated, err := decodeTokenFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode token file %s: %w", path, err)
+	}
+	for _, typer := range []TokenType{XeroToken, SalesforceToken} {
+		if et, ok := tokens[typer]; ok {
+			fs.MemoryStore.Put(context.Background(), typer.SessionName(), et)
+		}
+	}
+	if migrated {
+		fs.save(context.Background())
+		if err := fs.Err(); err != nil {
+			return nil, fmt.Errorf("could not migrate token file %s: %w", path, err)
+		}
+	}
+	return fs, nil
+}
+
+// decodeTokenFile decodes the tokens in a token file, reporting if the file is in the
+// earlier unversioned format.
+func decodeTokenFile(b []byte) (map[TokenType]*ExtendedToken, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, false, err
+	}
+
+	// The unversioned format is keyed by session name rather than provider name.
+	keyName := TokenType.String
+	file := tokenFile{}
+	_, versioned := fields["version"]
+	if versioned {
+		if err := json.Unmarshal(b, &file); err != nil {
+			return nil, false, err
+		}
+		if file.Version > tokenFileVersion {
+			return nil, false, fmt.Errorf("token file version %d is newer than supported version %d", file.Version, tokenFileVersion)
+		}
+	} else {
+		keyName = TokenType.SessionName
+		if err := json.Unmarshal(b, &file.Tokens); err != nil {
+			return nil, false, err
+		}
+	}
+
+	tokens := map[TokenType]*ExtendedToken{}
+	for _, typer := range []TokenType{XeroToken, SalesforceToken} {
+		if et, ok := file.Tokens[keyName(typer)]; ok && et != nil && et.Token != nil {
+			et.Type = typer
+			tokens[typer] = et
+		}
+	}
+	return tokens, !versioned, nil
+}
+
+// Path returns the path of the token file.
+func (f *FileStore) Path() string {
+	return f.path
+}
+
+// isTokenKey reports if key is the key of a saved token.
+func isTokenKey(key string) bool {
+	return key == XeroToken.SessionName() || key == SalesforceToken.SessionName()
+}
+
+// Put stores val under key, saving the token file if val is a token.
+func (f *FileStore) Put(ctx context.Context, key string, val any) {
+	f.MemoryStore.Put(ctx, key, val)
+	if isTokenKey(key) {
+		f.save(ctx)
+	}
+}
+
+// Remove deletes key from the store, saving the token file if key is a token.
+func (f *FileStore) Remove(ctx context.Context, key string) {
+	f.MemoryStore.Remove(ctx, key)
+	if isTokenKey(key) {
+		f.save(ctx)
+	}
+}
+
+// Err returns the error from the last save of the token file, if any. ValueStorer
+// methods cannot return errors, so this should be checked after a login or refresh.
+func (f *FileStore) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// save writes the tokens to the token file, replacing it atomically.
+func (f *FileStore) save(ctx context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file := tokenFile{Version: tokenFileVersion, Tokens: map[string]*ExtendedToken{}}
+	for _, typer := range []TokenType{XeroToken, SalesforceToken} {
+		if et, ok := f.ExtendedToken(ctx, typer); ok {
+			file.Tokens[typer.String()] = et
+		}
+	}
+	b, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		f.err = fmt.Errorf("could not encode tokens: %w", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		f.err = fmt.Errorf("could not make token file directory: %w", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".tokens-*")
+	if err != nil {
+		f.err = fmt.Errorf("could not create temporary token file: %w", err)
+		return
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name()) // no-op after rename
+	}()
+	// CreateTemp files are created with 0600 permissions.
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		f.err = fmt.Errorf("could not write token file: %w", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		f.err = fmt.Errorf("could not close token file: %w", err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		f.err = fmt.Errorf("could not replace token file: %w", err)
+		return
+	}
+	f.err = nil
+}
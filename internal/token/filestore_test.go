@@ -0,0 +1,157 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestFileStore(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config", "tokens.json")
+
+	fs, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error for missing token file: %v", err)
+	}
+	var _ Store = fs
+
+	// Non-token values are not saved.
+	fs.Put(ctx, "state", "abc")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no token file after non-token put, got %v", err)
+	}
+
+	et := &ExtendedToken{
+		Type: XeroToken,
+		Token: &oauth2.Token{
+			AccessToken:  "access",
+			RefreshToken: "refresh",
+			Expiry:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		TenantID: "tenant",
+	}
+	fs.Put(ctx, XeroToken.SessionName(), et)
+	if err := fs.Err(); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("token file not saved: %v", err)
+	}
+	if got, want := info.Mode().Perm(), os.FileMode(0o600); got != want {
+		t.Errorf("token file mode got %v want %v", got, want)
+	}
+
+	// Reload the tokens from the file.
+	fs2, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	got, ok := fs2.ExtendedToken(ctx, XeroToken)
+	if !ok {
+		t.Fatal("expected xero token after reload")
+	}
+	if got.Token.RefreshToken != "refresh" || got.Type != XeroToken {
+		t.Errorf("unexpected reloaded token %#v", got)
+	}
+	if got, want := got.TenantID, "tenant"; got != want {
+		t.Errorf("tenant id got %q want %q", got, want)
+	}
+	if _, ok := fs2.ExtendedToken(ctx, SalesforceToken); ok {
+		t.Error("unexpected salesforce token after reload")
+	}
+	if got := fs2.GetString(ctx, "state"); got != "" {
+		t.Errorf("unexpected state after reload %q", got)
+	}
+
+	// Removing a token saves the file.
+	fs2.Remove(ctx, XeroToken.SessionName())
+	fs3, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reload error: %v", err)
+	}
+	if _, ok := fs3.ExtendedToken(ctx, XeroToken); ok {
+		t.Error("expected no xero token after remove")
+	}
+
+	// Corrupt files are reported.
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("expected error for corrupt token file")
+	}
+
+	// Files of a later version are refused.
+	if err := os.WriteFile(path, []byte(`{"version": 99, "tokens": {}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path); err == nil {
+		t.Error("expected error for later token file version")
+	}
+}
+
+func TestFileStoreMigration(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	// An unversioned token file keyed by session name.
+	legacy := `{
+  "salesforce-session": {
+    "type": 2,
+    "token": {"access_token": "sf-access", "refresh_token": "sf-refresh", "expiry": "2026-01-01T00:00:00Z"},
+    "instance_url": "https://example.my.salesforce.com"
+  },
+  "xero-session": {
+    "type": 1,
+    "token": {"access_token": "xero-access", "refresh_token": "xero-refresh", "expiry": "2026-01-01T00:00:00Z"}
+  }
+}`
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("migration error: %v", err)
+	}
+	sf, ok := fs.ExtendedToken(ctx, SalesforceToken)
+	if !ok {
+		t.Fatal("expected salesforce token after migration")
+	}
+	if got, want := sf.InstanceURL, "https://example.my.salesforce.com"; got != want {
+		t.Errorf("instance url got %q want %q", got, want)
+	}
+	if _, ok := fs.ExtendedToken(ctx, XeroToken); !ok {
+		t.Error("expected xero token after migration")
+	}
+
+	// The file is rewritten in the current format.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file tokenFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := file.Version, tokenFileVersion; got != want {
+		t.Errorf("version got %d want %d", got, want)
+	}
+	for _, name := range []string{"xero", "salesforce"} {
+		if et, ok := file.Tokens[name]; !ok || et.Token == nil {
+			t.Errorf("migrated file has no %s token", name)
+		}
+	}
+	if got, want := file.Tokens["xero"].Token.RefreshToken, "xero-refresh"; got != want {
+		t.Errorf("xero refresh token got %q want %q", got, want)
+	}
+}
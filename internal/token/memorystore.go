@@ -0,0 +1,59 @@
+package token
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a concurrency-safe in-memory Store for single-user clients,
+// such as the terminal interface, which do not have a web session. The context
+// arguments are ignored.
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// NewMemoryStore returns a new, empty, MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: map[string]any{}}
+}
+
+// Put stores val under key.
+func (m *MemoryStore) Put(_ context.Context, key string, val any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = val
+}
+
+// Remove deletes key from the store.
+func (m *MemoryStore) Remove(_ context.Context, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+}
+
+// Get returns the value stored under key, or nil if it is not present.
+func (m *MemoryStore) Get(_ context.Context, key string) any {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[key]
+}
+
+// GetString returns the string stored under key, or "" if the key is not present or
+// does not hold a string.
+func (m *MemoryStore) GetString(ctx context.Context, key string) string {
+	s, _ := m.Get(ctx, key).(string)
+	return s
+}
+
+// ExtendedToken returns the token stored for typer, if any. TokenWebClient stores
+// tokens as pointers, but values are also accepted.
+func (m *MemoryStore) ExtendedToken(ctx context.Context, typer TokenType) (*ExtendedToken, bool) {
+	switch et := m.Get(ctx, typer.SessionName()).(type) {
+	case *ExtendedToken:
+		return et, et != nil
+	case ExtendedToken:
+		return &et, true
+	}
+	return nil, false
+}
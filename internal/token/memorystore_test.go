@@ -0,0 +1,48 @@
+package token
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestMemoryStore(t *testing.T) {
+
+	ctx := context.Background()
+	ms := NewMemoryStore()
+
+	// MemoryStore must satisfy Store.
+	var _ Store = ms
+
+	ms.Put(ctx, "state", "abc")
+	if got, want := ms.GetString(ctx, "state"), "abc"; got != want {
+		t.Errorf("GetString got %q want %q", got, want)
+	}
+	ms.Remove(ctx, "state")
+	if got := ms.GetString(ctx, "state"); got != "" {
+		t.Errorf("GetString after remove got %q", got)
+	}
+
+	ms.Put(ctx, "number", 1)
+	if got := ms.GetString(ctx, "number"); got != "" {
+		t.Errorf("GetString of non-string got %q", got)
+	}
+
+	if _, ok := ms.ExtendedToken(ctx, XeroToken); ok {
+		t.Error("expected no xero token")
+	}
+
+	// Pointer and value tokens are both accepted.
+	ms.Put(ctx, XeroToken.SessionName(), &ExtendedToken{Type: XeroToken, Token: &oauth2.Token{AccessToken: "x"}})
+	ms.Put(ctx, SalesforceToken.SessionName(), ExtendedToken{Type: SalesforceToken, Token: &oauth2.Token{AccessToken: "s"}})
+	for _, tt := range []TokenType{XeroToken, SalesforceToken} {
+		et, ok := ms.ExtendedToken(ctx, tt)
+		if !ok {
+			t.Fatalf("expected %s token", tt)
+		}
+		if et.Type != tt {
+			t.Errorf("token type got %s want %s", et.Type, tt)
+		}
+	}
+}
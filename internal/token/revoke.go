@@ -0,0 +1,87 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// revocationPaths are the names of the token revocation endpoints of the platforms,
+// which replace the final "token" element of the token endpoint url, such as
+// https://identity.xero.com/connect/token and
+// https://login.salesforce.com/services/oauth2/token.
+var revocationPaths = map[TokenType]string{
+	XeroToken:       "revocation",
+	SalesforceToken: "revoke",
+}
+
+// revocationURL returns the url of the token revocation endpoint from the token
+// endpoint url of the oauth2 config.
+func (tt TokenType) revocationURL(config *oauth2.Config) (string, error) {
+	name, ok := revocationPaths[tt]
+	if !ok {
+		return "", fmt.Errorf("token type %d invalid", tt)
+	}
+	if config == nil {
+		return "", fmt.Errorf("no %s oauth2 config", tt)
+	}
+	base, ok := strings.CutSuffix(config.Endpoint.TokenURL, "/token")
+	if !ok {
+		return "", fmt.Errorf("cannot derive %s revocation url from token url %q", tt, config.Endpoint.TokenURL)
+	}
+	return base + "/" + name, nil
+}
+
+// Revoke revokes the token with the platform, so that neither its access token nor its
+// refresh token can be used again. Revoking the refresh token also revokes the access
+// tokens issued with it. The platform http client may be provided in the context in the
+// same way as for oauth2, with the oauth2.HTTPClient context key.
+//
+// The token should be removed from local storage whether or not revocation succeeds.
+func (et *ExtendedToken) Revoke(ctx context.Context, config *oauth2.Config) error {
+
+	if et == nil || et.Token == nil {
+		return fmt.Errorf("no token to revoke")
+	}
+	revokeURL, err := et.Type.revocationURL(config)
+	if err != nil {
+		return err
+	}
+
+	tok := et.Token.RefreshToken
+	if tok == "" {
+		tok = et.Token.AccessToken
+	}
+	form := url.Values{"token": {tok}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("could not make %s revocation request: %w", et.Type, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Xero authenticates revocation requests with the client credentials.
+	if et.Type == XeroToken {
+		req.SetBasicAuth(config.ClientID, config.ClientSecret)
+	}
+
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c != nil {
+		client = c
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s revocation request failed: %w", et.Type, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s revocation failed (status %d): %s", et.Type, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
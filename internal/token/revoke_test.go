@@ -0,0 +1,87 @@
+package token
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestRevoke(t *testing.T) {
+
+	type revocation struct {
+		path, token, user string
+	}
+	var got revocation
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		got = revocation{r.URL.Path, r.PostFormValue("token"), user}
+		if r.PostFormValue("token") == "unknown" {
+			http.Error(w, `{"error": "invalid_token"}`, http.StatusBadRequest)
+		}
+	}
+	mux.HandleFunc("POST /connect/revocation", handler)
+	mux.HandleFunc("POST /services/oauth2/revoke", handler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+	xeroCfg := &oauth2.Config{ClientID: "client", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/connect/token"}}
+	sfCfg := &oauth2.Config{ClientID: "sf-client", Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/services/oauth2/token"}}
+
+	tests := []struct {
+		name    string
+		et      *ExtendedToken
+		cfg     *oauth2.Config
+		want    revocation
+		wantErr bool
+	}{
+		{
+			name: "xero refresh token",
+			et:   &ExtendedToken{Type: XeroToken, Token: &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}},
+			cfg:  xeroCfg,
+			want: revocation{"/connect/revocation", "refresh", "client"},
+		},
+		{
+			name: "salesforce access token",
+			et:   &ExtendedToken{Type: SalesforceToken, Token: &oauth2.Token{AccessToken: "access"}},
+			cfg:  sfCfg,
+			want: revocation{"/services/oauth2/revoke", "access", ""},
+		},
+		{
+			name:    "refused",
+			et:      &ExtendedToken{Type: SalesforceToken, Token: &oauth2.Token{AccessToken: "unknown"}},
+			cfg:     sfCfg,
+			want:    revocation{"/services/oauth2/revoke", "unknown", ""},
+			wantErr: true,
+		},
+		{
+			name:    "no token",
+			et:      &ExtendedToken{Type: XeroToken},
+			cfg:     xeroCfg,
+			wantErr: true,
+		},
+		{
+			name:    "unknown token url",
+			et:      &ExtendedToken{Type: XeroToken, Token: &oauth2.Token{AccessToken: "access"}},
+			cfg:     &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/authorize"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = revocation{}
+			err := tt.et.Revoke(ctx, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got revocation %+v want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -46,8 +46,16 @@ func (tt TokenType) SessionName() string {
 type ExtendedToken struct {
 	Type        TokenType     `json:"type"`
 	Token       *oauth2.Token `json:"token"`
-	InstanceURL string        `json:"instance_url"` // only relevant to Salesforce Tokens
-	TenantID    string        `json:"-"`
+	InstanceURL string        `json:"instance_url,omitempty"` // only relevant to Salesforce Tokens
+	TenantID    string        `json:"tenant_id,omitempty"`    // only relevant to Xero Tokens
+}
+
+// Expiry returns the expiry of the access token, or the zero time if there is no token.
+func (et *ExtendedToken) Expiry() time.Time {
+	if et == nil || et.Token == nil {
+		return time.Time{}
+	}
+	return et.Token.Expiry
 }
 
 // NewExtendedToken creates a new ExtendedToken, running any ancillary checks and/or
@@ -23,6 +23,14 @@ type ValueStorer interface {
 	GetString(ctx context.Context, key string) string
 }
 
+// Store is a ValueStorer which can also retrieve the tokens it holds, used by clients
+// without a web session, such as the terminal interface and command line subcommands.
+// MemoryStore and FileStore are Stores.
+type Store interface {
+	ValueStorer
+	ExtendedToken(ctx context.Context, typer TokenType) (*ExtendedToken, bool)
+}
+
 // ErrTokenWebClient is an error raised by a TokenWebClient web handler.
 type ErrTokenWebClient struct {
 	Context string
@@ -40,6 +48,7 @@ type TokenWebClient struct {
 	typer    TokenType
 	oauthCfg *oauth2.Config
 	vs       ValueStorer
+	tenantID string // the Xero tenant of new tokens, if set
 }
 
 // NewTokenWebClient creates a new TokenWebClient.
@@ -64,6 +73,12 @@ func NewTokenWebClient(
 	}, nil
 }
 
+// SetTenantID sets the Xero tenant ID of the tokens of later logins, so that the
+// tenant is not found from the organisations authorised by the login.
+func (twc *TokenWebClient) SetTenantID(tenantID string) {
+	twc.tenantID = tenantID
+}
+
 func (twc *TokenWebClient) stateKey() string {
 	return fmt.Sprintf("%s-%s", twc.typer, "state")
 }
@@ -210,6 +225,7 @@ func (twc *TokenWebClient) WebLoginCallBack(redirURL string) func(w http.Respons
 				Msg:     "internal token registration error",
 			}
 		}
+		et.TenantID = twc.tenantID
 		twc.vs.Put(ctx, twc.SessionKey(), et)
 
 		// Success. Redirect to the redirection url.
@@ -100,6 +100,7 @@ func TestAuthWebLoginAndCallback(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewTokenWebHander error: %v", err)
 	}
+	twc.SetTenantID("configured-tenant")
 
 	// Initialise an error checker handler consumer.
 	ec := new(errChecker)
@@ -216,6 +217,9 @@ func TestAuthWebLoginAndCallback(t *testing.T) {
 	if got, want := sessionToken.Token.AccessToken, mockAccessToken; got != want {
 		t.Errorf("saved accessToken: got %q want %q", got, want)
 	}
+	if got, want := sessionToken.TenantID, "configured-tenant"; got != want {
+		t.Errorf("saved tenantID: got %q want %q", got, want)
+	}
 
 	if ec.errCount != 0 {
 		t.Errorf("expected 0 handler error count, got %d", ec.errCount)
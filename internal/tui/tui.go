@@ -0,0 +1,729 @@
+// package tui provides a keyboard-driven terminal interface for reconciling
+// unreconciled Xero invoices and bank transactions with Salesforce donations.
+//
+// The interface uses github.com/charmbracelet/bubbletea. It is deliberately plain:
+// output is unstyled text, the cursor and selections are shown with text markers
+// rather than colour, and every screen has a status line and a key help line, so that
+// it works over SSH, in basic terminals and with screen readers.
+//
+// All data access and actions are provided by a Service, which in production wraps
+// the same domain.Reconciler used by the web app.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// statusPollInterval is the interval at which the connection status is checked while
+// waiting for the user to connect to Xero and Salesforce.
+var statusPollInterval = 2 * time.Second
+
+// Status reports the connection status of the api platforms together with the urls to
+// visit in a browser to connect to each.
+type Status struct {
+	XeroConnected       bool
+	SalesforceConnected bool
+	XeroURL             string
+	SalesforceURL       string
+}
+
+// Connected reports if both platforms are connected.
+func (s Status) Connected() bool {
+	return s.XeroConnected && s.SalesforceConnected
+}
+
+// Item is an unreconciled invoice or bank transaction.
+type Item struct {
+	Type        string // "invoice" or "bank-transaction"
+	ID          string
+	Reference   string // the invoice number or bank transaction reference
+	Date        time.Time
+	Contact     string
+	Total       money.Amount
+	Outstanding money.Amount // the donation total not yet linked to donations
+}
+
+// Line is an invoice or bank transaction line item.
+type Line struct {
+	AccountCode    string
+	Description    string
+	LineAmount     money.Amount
+	DonationAmount money.Amount
+}
+
+// Donation is a Salesforce donation.
+type Donation struct {
+	ID        string
+	Name      string
+	Amount    money.Amount
+	CloseDate string
+}
+
+// Detail is an invoice or bank transaction with its line items, the unlinked
+// donations which are candidates for linking to it and the donations already linked to
+// it.
+type Detail struct {
+	Item       Item
+	Lines      []Line
+	Candidates []Donation
+	Linked     []Donation
+}
+
+// Service provides the data and actions used by the terminal interface.
+type Service interface {
+	// Status reports the api connection status.
+	Status(ctx context.Context) Status
+	// Refresh refreshes the local records from the api platforms, returning a short
+	// summary.
+	Refresh(ctx context.Context) (string, error)
+	// Items returns the unreconciled invoices and bank transactions.
+	Items(ctx context.Context) ([]Item, error)
+	// Detail returns the detail of an item.
+	Detail(ctx context.Context, item Item) (Detail, error)
+	// Link links the donations to the item.
+	Link(ctx context.Context, item Item, donationIDs []string) error
+	// Unlink removes the links of the donations.
+	Unlink(ctx context.Context, donationIDs []string) error
+}
+
+// Run runs the terminal interface until the user quits or ctx is cancelled.
+func Run(ctx context.Context, svc Service, in io.Reader, out io.Writer) error {
+	program := tea.NewProgram(
+		New(ctx, svc),
+		tea.WithContext(ctx),
+		tea.WithInput(in),
+		tea.WithOutput(out),
+	)
+	_, err := program.Run()
+	return err
+}
+
+// screen is a terminal interface screen.
+type screen int
+
+const (
+	connectScreen screen = iota
+	listScreen
+	detailScreen
+)
+
+// Messages returned by commands.
+type (
+	statusMsg    Status
+	pollMsg      struct{}
+	refreshedMsg struct {
+		summary string
+		err     error
+	}
+	itemsMsg struct {
+		items []Item
+		err   error
+	}
+	detailMsg struct {
+		detail  Detail
+		err     error
+		keepMsg bool // keep the current status message
+	}
+	linkedMsg struct {
+		count  int
+		unlink bool
+		err    error
+	}
+)
+
+// Model is the bubbletea model for the terminal interface.
+type Model struct {
+	ctx     context.Context
+	svc     Service
+	screen  screen
+	status  Status
+	busy    bool   // a command is in progress
+	msg     string // the status line message
+	summary string // the last refresh summary
+	height  int
+
+	// list screen
+	items   []Item
+	ignored map[string]bool // items ignored for this session, keyed by ID
+	marked  map[string]bool // items marked for bulk actions, keyed by ID
+	cursor  int
+
+	// detail screen
+	detail      Detail
+	dCursor     int
+	selected    map[string]bool // selected candidate donation IDs
+	focusLinked bool            // the linked donations have the focus
+	lCursor     int
+	lSelected   map[string]bool // selected linked donation IDs
+	showHelp    bool
+}
+
+// New returns a new Model.
+func New(ctx context.Context, svc Service) Model {
+	return Model{
+		ctx:       ctx,
+		svc:       svc,
+		screen:    connectScreen,
+		ignored:   map[string]bool{},
+		marked:    map[string]bool{},
+		selected:  map[string]bool{},
+		lSelected: map[string]bool{},
+		msg:       "Checking connections.",
+	}
+}
+
+// Init checks the connection status.
+func (m Model) Init() tea.Cmd {
+	return m.checkStatus
+}
+
+func (m Model) checkStatus() tea.Msg {
+	return statusMsg(m.svc.Status(m.ctx))
+}
+
+func (m Model) poll() tea.Cmd {
+	return tea.Tick(statusPollInterval, func(time.Time) tea.Msg { return pollMsg{} })
+}
+
+func (m Model) refresh() tea.Msg {
+	summary, err := m.svc.Refresh(m.ctx)
+	return refreshedMsg{summary, err}
+}
+
+func (m Model) loadItems() tea.Msg {
+	items, err := m.svc.Items(m.ctx)
+	return itemsMsg{items, err}
+}
+
+func (m Model) loadDetail(item Item, keepMsg bool) tea.Cmd {
+	return func() tea.Msg {
+		detail, err := m.svc.Detail(m.ctx, item)
+		return detailMsg{detail, err, keepMsg}
+	}
+}
+
+func (m Model) link(item Item, ids []string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.svc.Link(m.ctx, item, ids)
+		return linkedMsg{count: len(ids), err: err}
+	}
+}
+
+func (m Model) unlink(ids []string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.svc.Unlink(m.ctx, ids)
+		return linkedMsg{count: len(ids), unlink: true, err: err}
+	}
+}
+
+// visibleItems returns the items which have not been ignored.
+func (m Model) visibleItems() []Item {
+	items := make([]Item, 0, len(m.items))
+	for _, it := range m.items {
+		if !m.ignored[it.ID] {
+			items = append(items, it)
+		}
+	}
+	return items
+}
+
+// selectedIDs returns the selected candidate donation IDs in display order.
+func (m Model) selectedIDs() []string {
+	return selectedIn(m.detail.Candidates, m.selected)
+}
+
+// selectedLinkedIDs returns the selected linked donation IDs in display order.
+func (m Model) selectedLinkedIDs() []string {
+	return selectedIn(m.detail.Linked, m.lSelected)
+}
+
+// selectedIn returns the IDs of the donations in selected, in order.
+func selectedIn(donations []Donation, selected map[string]bool) []string {
+	var ids []string
+	for _, d := range donations {
+		if selected[d.ID] {
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids
+}
+
+// pane returns the donations, cursor and selections of the focused detail pane.
+func (m *Model) pane() ([]Donation, *int, map[string]bool) {
+	if m.focusLinked {
+		return m.detail.Linked, &m.lCursor, m.lSelected
+	}
+	return m.detail.Candidates, &m.dCursor, m.selected
+}
+
+// Update handles messages and key presses.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		return m, nil
+
+	case statusMsg:
+		m.status = Status(msg)
+		if m.screen != connectScreen {
+			return m, nil
+		}
+		if m.status.Connected() {
+			m.msg = "Connected. Press r to retrieve records."
+			return m, nil
+		}
+		m.msg = "Waiting for connections."
+		return m, m.poll()
+
+	case pollMsg:
+		if m.screen != connectScreen || m.status.Connected() {
+			return m, nil
+		}
+		return m, m.checkStatus
+
+	case refreshedMsg:
+		if msg.err != nil {
+			m.busy = false
+			m.msg = fmt.Sprintf("Refresh failed: %v", msg.err)
+			return m, nil
+		}
+		m.summary = msg.summary
+		return m, m.loadItems
+
+	case itemsMsg:
+		m.busy = false
+		if msg.err != nil {
+			m.msg = fmt.Sprintf("Could not load records: %v", msg.err)
+			return m, nil
+		}
+		m.items = msg.items
+		m.screen = listScreen
+		m.msg = strings.TrimSpace(fmt.Sprintf("%s %d unreconciled records.", m.summary, len(m.visibleItems())))
+		m.summary = ""
+		if n := len(m.visibleItems()); m.cursor >= n {
+			m.cursor = max(n-1, 0)
+		}
+		return m, nil
+
+	case detailMsg:
+		m.busy = false
+		if msg.err != nil {
+			m.msg = fmt.Sprintf("Could not load record: %v", msg.err)
+			return m, nil
+		}
+		m.detail = msg.detail
+		m.dCursor, m.lCursor = 0, 0
+		m.selected = map[string]bool{}
+		m.lSelected = map[string]bool{}
+		if len(m.detail.Linked) == 0 {
+			m.focusLinked = false
+		}
+		m.screen = detailScreen
+		if !msg.keepMsg {
+			m.msg = fmt.Sprintf("%d candidate donations, %d linked.", len(m.detail.Candidates), len(m.detail.Linked))
+		}
+		return m, nil
+
+	case linkedMsg:
+		if msg.err != nil {
+			m.busy = false
+			if msg.unlink {
+				m.msg = fmt.Sprintf("Unlinking failed: %v", msg.err)
+			} else {
+				m.msg = fmt.Sprintf("Linking failed: %v", msg.err)
+			}
+			return m, nil
+		}
+		if msg.unlink {
+			m.msg = fmt.Sprintf("Unlinked %d donations from %s.", msg.count, m.detail.Item.Reference)
+		} else {
+			m.msg = fmt.Sprintf("Linked %d donations to %s.", msg.count, m.detail.Item.Reference)
+		}
+		return m, m.loadDetail(m.detail.Item, true)
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	return m, nil
+}
+
+// handleKey handles key presses for each screen.
+func (m Model) handleKey(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+
+	k := key.String()
+
+	// Global keys.
+	switch k {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "?":
+		m.showHelp = !m.showHelp
+		return m, nil
+	}
+	if m.busy {
+		m.msg = "Please wait."
+		return m, nil
+	}
+
+	switch m.screen {
+
+	case connectScreen:
+		switch k {
+		case "c":
+			m.msg = "Checking connections."
+			return m, m.checkStatus
+		case "r":
+			if !m.status.Connected() {
+				m.msg = "Connect to both Xero and Salesforce before retrieving records."
+				return m, nil
+			}
+			m.busy = true
+			m.msg = "Retrieving records."
+			return m, m.refresh
+		}
+
+	case listScreen:
+		items := m.visibleItems()
+		switch k {
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(items)-1 {
+				m.cursor++
+			}
+		case "home", "g":
+			m.cursor = 0
+		case "end", "G":
+			m.cursor = max(len(items)-1, 0)
+		case "enter":
+			if len(items) == 0 {
+				return m, nil
+			}
+			m.busy = true
+			m.msg = "Loading record."
+			return m, m.loadDetail(items[m.cursor], false)
+		case " ", "x":
+			if len(items) == 0 {
+				return m, nil
+			}
+			id := items[m.cursor].ID
+			m.marked[id] = !m.marked[id]
+			if !m.marked[id] {
+				delete(m.marked, id)
+			}
+			m.msg = fmt.Sprintf("%d records marked.", len(m.marked))
+		case "i":
+			if len(items) == 0 {
+				return m, nil
+			}
+			// Ignore the marked records, or the current record if none are marked.
+			if len(m.marked) > 0 {
+				n := len(m.marked)
+				for id := range m.marked {
+					m.ignored[id] = true
+				}
+				m.marked = map[string]bool{}
+				m.msg = fmt.Sprintf("Ignored %d records for this session.", n)
+			} else {
+				it := items[m.cursor]
+				m.ignored[it.ID] = true
+				m.msg = fmt.Sprintf("Ignored %s for this session.", it.Reference)
+			}
+			if n := len(m.visibleItems()); m.cursor >= n {
+				m.cursor = max(n-1, 0)
+			}
+		case "u":
+			m.ignored = map[string]bool{}
+			m.msg = "Ignored records restored."
+		case "r":
+			m.busy = true
+			m.msg = "Refreshing records."
+			return m, m.refresh
+		}
+
+	case detailScreen:
+		donations, cursor, selected := m.pane()
+		switch k {
+		case "esc", "backspace", "left", "h":
+			// Reload the items since linking may have reconciled this one.
+			m.screen = listScreen
+			m.busy = true
+			m.msg = "Loading records."
+			return m, m.loadItems
+		case "tab":
+			if len(m.detail.Linked) == 0 {
+				m.msg = "No donations are linked to this record."
+				return m, nil
+			}
+			m.focusLinked = !m.focusLinked
+		case "up", "k":
+			if *cursor > 0 {
+				*cursor--
+			}
+		case "down", "j":
+			if *cursor < len(donations)-1 {
+				*cursor++
+			}
+		case " ", "x":
+			if len(donations) == 0 {
+				return m, nil
+			}
+			id := donations[*cursor].ID
+			selected[id] = !selected[id]
+			if !selected[id] {
+				delete(selected, id)
+			}
+			m.msg = fmt.Sprintf("%d donations selected.", len(selected))
+		case "a":
+			// Select all the donations in the pane, or clear the selection if all
+			// are already selected.
+			if len(selected) == len(donations) {
+				clear(selected)
+			} else {
+				for _, d := range donations {
+					selected[d.ID] = true
+				}
+			}
+			m.msg = fmt.Sprintf("%d donations selected.", len(selected))
+		case "u":
+			ids := m.selectedLinkedIDs()
+			if len(ids) == 0 {
+				m.msg = "Select one or more linked donations with tab and space before unlinking."
+				return m, nil
+			}
+			m.busy = true
+			m.msg = fmt.Sprintf("Unlinking %d donations.", len(ids))
+			return m, m.unlink(ids)
+		case "l":
+			ids := m.selectedIDs()
+			if len(ids) == 0 {
+				m.msg = "Select one or more donations with space before linking."
+				return m, nil
+			}
+			m.busy = true
+			m.msg = fmt.Sprintf("Linking %d donations.", len(ids))
+			return m, m.link(m.detail.Item, ids)
+		case "i":
+			m.ignored[m.detail.Item.ID] = true
+			m.screen = listScreen
+			if n := len(m.visibleItems()); m.cursor >= n {
+				m.cursor = max(n-1, 0)
+			}
+			m.msg = fmt.Sprintf("Ignored %s for this session.", m.detail.Item.Reference)
+		}
+	}
+
+	return m, nil
+}
+
+// Key help for each screen.
+var screenHelp = map[screen]string{
+	connectScreen: "Keys: c check connections, r retrieve records, ? help, q quit",
+	listScreen:    "Keys: up/down move, enter open, space mark, i ignore, u restore ignored, r refresh, ? help, q quit",
+	detailScreen:  "Keys: up/down move, tab switch list, space select, a select all, l link, u unlink, i ignore, esc back, ? help, q quit",
+}
+
+// Longer help for each screen.
+var screenHelpDetail = map[screen]string{
+	connectScreen: `Open each connection url in a browser to log in. If this program is running on
+a remote machine, forward the listening port first, for example with
+"ssh -L 8080:localhost:8080 host". Connections are checked automatically.`,
+	listScreen: `Each line shows an unreconciled invoice or bank transaction: the type, date,
+reference, contact, total and the donation amount not yet linked. Press enter to
+see the record and candidate donations. Ignoring hides a record for this session
+only; mark several records with space to ignore them together.`,
+	detailScreen: `Candidate donations are unlinked donations dated from six weeks before to two
+weeks after the record. Select one or more with space, or all with a, then press l
+to link them to this record in Salesforce. Press tab to move to the donations
+already linked to the record, where u unlinks the selected donations.`,
+}
+
+// View renders the current screen.
+func (m Model) View() string {
+
+	var b strings.Builder
+
+	switch m.screen {
+	case connectScreen:
+		m.viewConnect(&b)
+	case listScreen:
+		m.viewList(&b)
+	case detailScreen:
+		m.viewDetail(&b)
+	}
+
+	fmt.Fprintf(&b, "\nStatus: %s\n", m.msg)
+	fmt.Fprintln(&b, screenHelp[m.screen])
+	if m.showHelp {
+		fmt.Fprintf(&b, "\n%s\n", screenHelpDetail[m.screen])
+	}
+	return b.String()
+}
+
+// connected describes a connection status.
+func connected(ok bool) string {
+	if ok {
+		return "connected"
+	}
+	return "not connected"
+}
+
+func (m Model) viewConnect(b *strings.Builder) {
+	fmt.Fprintln(b, "Reconciler: connect")
+	fmt.Fprintln(b)
+	fmt.Fprintf(b, "Xero: %s\n", connected(m.status.XeroConnected))
+	if !m.status.XeroConnected {
+		fmt.Fprintf(b, "  connect at %s\n", m.status.XeroURL)
+	}
+	fmt.Fprintf(b, "Salesforce: %s\n", connected(m.status.SalesforceConnected))
+	if !m.status.SalesforceConnected {
+		fmt.Fprintf(b, "  connect at %s\n", m.status.SalesforceURL)
+	}
+}
+
+// window returns the start and end of a window of at most size rows around cursor.
+func window(cursor, length, size int) (int, int) {
+	if size <= 0 || length <= size {
+		return 0, length
+	}
+	start := max(cursor-size/2, 0)
+	end := start + size
+	if end > length {
+		end = length
+		start = end - size
+	}
+	return start, end
+}
+
+// rows returns the number of list rows that fit the terminal, allowing for the
+// headings, status and help lines. Zero means no limit.
+func (m Model) rows(reserved int) int {
+	if m.height == 0 {
+		return 0
+	}
+	return max(m.height-reserved, 3)
+}
+
+// marker returns the cursor marker for a row.
+func marker(current bool) string {
+	if current {
+		return ">"
+	}
+	return " "
+}
+
+// typeLabel returns a short label for an item type.
+func typeLabel(typer string) string {
+	if typer == "bank-transaction" {
+		return "bank"
+	}
+	return "invoice"
+}
+
+func (m Model) viewList(b *strings.Builder) {
+	items := m.visibleItems()
+	fmt.Fprintf(b, "Reconciler: unreconciled records (%d)\n\n", len(items))
+	if len(items) == 0 {
+		fmt.Fprintln(b, "No unreconciled records.")
+		return
+	}
+	start, end := window(m.cursor, len(items), m.rows(8))
+	for i := start; i < end; i++ {
+		it := items[i]
+		mark := " "
+		if m.marked[it.ID] {
+			mark = "*"
+		}
+		fmt.Fprintf(b, "%s%s %-7s %s  %-20s %-24s %10s  outstanding %10s\n",
+			marker(i == m.cursor),
+			mark,
+			typeLabel(it.Type),
+			it.Date.Format(time.DateOnly),
+			truncate(it.Reference, 20),
+			truncate(it.Contact, 24),
+			it.Total,
+			it.Outstanding,
+		)
+	}
+	fmt.Fprintf(b, "Record %d of %d.\n", m.cursor+1, len(items))
+}
+
+func (m Model) viewDetail(b *strings.Builder) {
+	it := m.detail.Item
+	fmt.Fprintf(b, "Reconciler: %s %s\n\n", typeLabel(it.Type), it.Reference)
+	fmt.Fprintf(b, "Date: %s\n", it.Date.Format(time.DateOnly))
+	fmt.Fprintf(b, "Contact: %s\n", it.Contact)
+	fmt.Fprintf(b, "Total: %s\n", it.Total)
+	fmt.Fprintf(b, "Outstanding: %s\n", it.Outstanding)
+
+	fmt.Fprintf(b, "\nLine items (%d)\n", len(m.detail.Lines))
+	for _, li := range m.detail.Lines {
+		fmt.Fprintf(b, "  %-8s %-40s %10s  donation %10s\n",
+			li.AccountCode,
+			truncate(li.Description, 40),
+			li.LineAmount,
+			li.DonationAmount,
+		)
+	}
+
+	// Share the rows between the candidate and linked donations.
+	size := m.rows(17 + len(m.detail.Lines))
+	if size > 0 && len(m.detail.Linked) > 0 {
+		size = max(size/2, 3)
+	}
+
+	fmt.Fprintf(b, "\nCandidate donations (%d, %d selected)\n", len(m.detail.Candidates), len(m.selected))
+	viewDonations(b, m.detail.Candidates, m.selected, m.dCursor, !m.focusLinked, size, "No candidate donations.")
+	if len(m.detail.Linked) > 0 {
+		fmt.Fprintf(b, "\nLinked donations (%d, %d selected)\n", len(m.detail.Linked), len(m.lSelected))
+		viewDonations(b, m.detail.Linked, m.lSelected, m.lCursor, m.focusLinked, size, "")
+	}
+}
+
+// viewDonations renders a list of donations with their selections, showing the
+// cursor if focused.
+func viewDonations(b *strings.Builder, donations []Donation, selected map[string]bool, cursor int, focused bool, size int, empty string) {
+	if len(donations) == 0 {
+		fmt.Fprintf(b, "  %s\n", empty)
+		return
+	}
+	start, end := window(cursor, len(donations), size)
+	for i := start; i < end; i++ {
+		d := donations[i]
+		check := "[ ]"
+		if selected[d.ID] {
+			check = "[x]"
+		}
+		fmt.Fprintf(b, "%s %s %s  %-40s %10s\n",
+			marker(focused && i == cursor),
+			check,
+			d.CloseDate,
+			truncate(d.Name, 40),
+			d.Amount,
+		)
+	}
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "~"
+}
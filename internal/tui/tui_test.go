@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// fakeService is a test Service.
+type fakeService struct {
+	status    Status
+	items     []Item
+	linked    map[string][]string // item ID to donation IDs
+	linkErr   error
+	unlinkErr error
+}
+
+func (f *fakeService) Status(ctx context.Context) Status { return f.status }
+
+func (f *fakeService) Refresh(ctx context.Context) (string, error) {
+	return "Retrieved 2 records.", nil
+}
+
+func (f *fakeService) Items(ctx context.Context) ([]Item, error) {
+	var items []Item
+	for _, it := range f.items {
+		if _, ok := f.linked[it.ID]; !ok {
+			items = append(items, it)
+		}
+	}
+	return items, nil
+}
+
+func (f *fakeService) Detail(ctx context.Context, item Item) (Detail, error) {
+	var linked []Donation
+	for _, id := range f.linked[item.ID] {
+		linked = append(linked, Donation{ID: id, Name: "linked " + id, Amount: money.FromFloat(10), CloseDate: "2025-03-01"})
+	}
+	return Detail{
+		Linked: linked,
+		Item:   item,
+		Lines:  []Line{{AccountCode: "5301", Description: "donation", LineAmount: money.FromFloat(100), DonationAmount: money.FromFloat(100)}},
+		Candidates: []Donation{
+			{ID: "d1", Name: "first", Amount: money.FromFloat(60), CloseDate: "2025-04-01"},
+			{ID: "d2", Name: "second", Amount: money.FromFloat(40), CloseDate: "2025-04-02"},
+			{ID: "d3", Name: "third", Amount: money.FromFloat(10), CloseDate: "2025-04-03"},
+		},
+	}, nil
+}
+
+func (f *fakeService) Link(ctx context.Context, item Item, donationIDs []string) error {
+	if f.linkErr != nil {
+		return f.linkErr
+	}
+	f.linked[item.ID] = append(f.linked[item.ID], donationIDs...)
+	return nil
+}
+
+func (f *fakeService) Unlink(ctx context.Context, donationIDs []string) error {
+	if f.unlinkErr != nil {
+		return f.unlinkErr
+	}
+	for itemID, ids := range f.linked {
+		ids = slices.DeleteFunc(ids, func(id string) bool { return slices.Contains(donationIDs, id) })
+		if len(ids) == 0 {
+			delete(f.linked, itemID)
+			continue
+		}
+		f.linked[itemID] = ids
+	}
+	return nil
+}
+
+// update sends msg to the model, running any resulting command (other than ticks and
+// quit) and feeding its message back to the model.
+func update(t *testing.T, m Model, msg tea.Msg) Model {
+	t.Helper()
+	model, cmd := m.Update(msg)
+	m = model.(Model)
+	if cmd == nil {
+		return m
+	}
+	next := cmd()
+	switch next.(type) {
+	case nil, pollMsg, tea.QuitMsg:
+		return m
+	}
+	return update(t, m, next)
+}
+
+// key sends key presses to the model.
+func key(t *testing.T, m Model, keys ...string) Model {
+	t.Helper()
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "tab":
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		m = update(t, m, msg)
+	}
+	return m
+}
+
+func TestModel(t *testing.T) {
+
+	statusPollInterval = time.Millisecond
+
+	svc := &fakeService{
+		items: []Item{
+			{Type: "invoice", ID: "i1", Reference: "INV-001", Date: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Contact: "A Donor", Total: money.FromFloat(100), Outstanding: money.FromFloat(100)},
+			{Type: "bank-transaction", ID: "b1", Reference: "BT-001", Date: time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC), Contact: "Payouts", Total: money.FromFloat(50), Outstanding: money.FromFloat(50)},
+			{Type: "invoice", ID: "i2", Reference: "INV-002", Date: time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC), Contact: "B Donor", Total: money.FromFloat(20), Outstanding: money.FromFloat(20)},
+		},
+		linked: map[string][]string{},
+	}
+
+	m := New(context.Background(), svc)
+	m = update(t, m, m.Init()())
+	if got, want := m.msg, "Waiting for connections."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+
+	// Records cannot be retrieved until connected.
+	m = key(t, m, "r")
+	if m.screen != connectScreen {
+		t.Fatal("expected to remain on the connect screen")
+	}
+	svc.status = Status{XeroConnected: true, SalesforceConnected: true}
+	m = key(t, m, "c")
+	if !m.status.Connected() {
+		t.Fatal("expected connected status")
+	}
+
+	// Retrieve records and move to the list.
+	m = key(t, m, "r")
+	if m.screen != listScreen {
+		t.Fatalf("expected list screen, got %d", m.screen)
+	}
+	if got, want := m.msg, "Retrieved 2 records. 3 unreconciled records."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	if !strings.Contains(m.View(), "INV-001") {
+		t.Errorf("expected INV-001 in view:\n%s", m.View())
+	}
+	if !strings.Contains(m.View(), "    100.00  outstanding     100.00") {
+		t.Errorf("expected the amounts of INV-001 in view:\n%s", m.View())
+	}
+
+	// Ignore the first item.
+	m = key(t, m, "i")
+	if got := len(m.visibleItems()); got != 2 {
+		t.Errorf("expected 2 visible items after ignore, got %d", got)
+	}
+	if strings.Contains(m.View(), "2025-04-01  INV-001") {
+		t.Errorf("did not expect INV-001 in the listing:\n%s", m.View())
+	}
+
+	// Open the second item (INV-002), select two donations and link them.
+	m = key(t, m, "down", "enter")
+	if m.screen != detailScreen || m.detail.Item.ID != "i2" {
+		t.Fatalf("expected detail screen for i2, got %d %q", m.screen, m.detail.Item.ID)
+	}
+	m = key(t, m, "l")
+	if got, want := m.msg, "Select one or more donations with space before linking."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	m = key(t, m, " ", "down", "down", " ")
+	if got, want := m.selectedIDs(), []string{"d1", "d3"}; !slices.Equal(got, want) {
+		t.Errorf("selected got %v want %v", got, want)
+	}
+	if !strings.Contains(m.View(), "[x] 2025-04-03") {
+		t.Errorf("expected selection marker in view:\n%s", m.View())
+	}
+	m = key(t, m, "l")
+	if got, want := svc.linked["i2"], []string{"d1", "d3"}; !slices.Equal(got, want) {
+		t.Errorf("linked got %v want %v", got, want)
+	}
+	if got, want := m.msg, "Linked 2 donations to INV-002."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	if len(m.selected) != 0 {
+		t.Error("expected selections to be cleared after linking")
+	}
+
+	// Link errors are reported.
+	svc.linkErr = errors.New("api down")
+	m = key(t, m, " ", "l")
+	if got, want := m.msg, "Linking failed: api down"; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+
+	// Going back reloads the list, which no longer has the linked item.
+	m = key(t, m, "esc")
+	if m.screen != listScreen {
+		t.Fatalf("expected list screen, got %d", m.screen)
+	}
+	if got, want := m.msg, "1 unreconciled records."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+
+	// Restore ignored items.
+	m = key(t, m, "u")
+	if got := len(m.visibleItems()); got != 2 {
+		t.Errorf("expected 2 visible items after restore, got %d", got)
+	}
+}
+
+func TestModelBulkActions(t *testing.T) {
+
+	svc := &fakeService{
+		status: Status{XeroConnected: true, SalesforceConnected: true},
+		items: []Item{
+			{Type: "invoice", ID: "i1", Reference: "INV-001", Date: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Total: money.FromFloat(100), Outstanding: money.FromFloat(100)},
+			{Type: "invoice", ID: "i2", Reference: "INV-002", Date: time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC), Total: money.FromFloat(50), Outstanding: money.FromFloat(50)},
+			{Type: "invoice", ID: "i3", Reference: "INV-003", Date: time.Date(2025, 4, 3, 0, 0, 0, 0, time.UTC), Total: money.FromFloat(20), Outstanding: money.FromFloat(20)},
+		},
+		linked: map[string][]string{},
+	}
+
+	m := New(context.Background(), svc)
+	m = update(t, m, m.Init()())
+	m = key(t, m, "r")
+
+	// Mark the first two records and ignore them together.
+	m = key(t, m, " ", "down", " ")
+	if !strings.Contains(m.View(), ">* invoice 2025-04-02  INV-002") {
+		t.Errorf("expected mark in view:\n%s", m.View())
+	}
+	m = key(t, m, "i")
+	if got, want := m.msg, "Ignored 2 records for this session."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	if items := m.visibleItems(); len(items) != 1 || items[0].ID != "i3" {
+		t.Fatalf("unexpected visible items %v", items)
+	}
+
+	// Select all the candidates and link them.
+	m = key(t, m, "enter", "a")
+	if got, want := m.selectedIDs(), []string{"d1", "d2", "d3"}; !slices.Equal(got, want) {
+		t.Errorf("selected got %v want %v", got, want)
+	}
+	m = key(t, m, "a")
+	if len(m.selectedIDs()) != 0 {
+		t.Error("expected select all to clear a full selection")
+	}
+	m = key(t, m, "a", "l")
+	if got, want := svc.linked["i3"], []string{"d1", "d2", "d3"}; !slices.Equal(got, want) {
+		t.Errorf("linked got %v want %v", got, want)
+	}
+	if !strings.Contains(m.View(), "Linked donations (3, 0 selected)") {
+		t.Errorf("expected linked donations in view:\n%s", m.View())
+	}
+
+	// Unlinking requires a selection of linked donations.
+	m = key(t, m, "u")
+	if got, want := m.msg, "Select one or more linked donations with tab and space before unlinking."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+
+	// Switch to the linked donations and unlink two of them.
+	m = key(t, m, "tab", " ", "down", " ")
+	if got, want := m.selectedLinkedIDs(), []string{"d1", "d2"}; !slices.Equal(got, want) {
+		t.Errorf("linked selected got %v want %v", got, want)
+	}
+	svc.unlinkErr = errors.New("api down")
+	m = key(t, m, "u")
+	if got, want := m.msg, "Unlinking failed: api down"; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	svc.unlinkErr = nil
+	m = key(t, m, "u")
+	if got, want := m.msg, "Unlinked 2 donations from INV-003."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+	if got, want := svc.linked["i3"], []string{"d3"}; !slices.Equal(got, want) {
+		t.Errorf("linked after unlink got %v want %v", got, want)
+	}
+
+	// Unlinking the last donation returns the focus to the candidates.
+	m = key(t, m, "tab", "tab", " ", "u")
+	if _, ok := svc.linked["i3"]; ok {
+		t.Errorf("expected no linked donations, got %v", svc.linked["i3"])
+	}
+	if m.focusLinked {
+		t.Error("expected focus on the candidates with no linked donations")
+	}
+	m = key(t, m, "tab")
+	if got, want := m.msg, "No donations are linked to this record."; got != want {
+		t.Errorf("status got %q want %q", got, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		cursor, length, size int
+		start, end           int
+	}{
+		{0, 5, 0, 0, 5},
+		{0, 5, 10, 0, 5},
+		{0, 20, 5, 0, 5},
+		{10, 20, 5, 8, 13},
+		{19, 20, 5, 15, 20},
+	}
+	for _, tt := range tests {
+		start, end := window(tt.cursor, tt.length, tt.size)
+		if start != tt.start || end != tt.end {
+			t.Errorf("window(%d, %d, %d) got %d,%d want %d,%d", tt.cursor, tt.length, tt.size, start, end, tt.start, tt.end)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+)
+
+// accountBreakdownHeaders are the column headings of the account code breakdown CSV
+// file.
+var accountBreakdownHeaders = []string{
+	"Account code",
+	"Account name",
+	"Month",
+	"Records",
+	"Reconciled records",
+	"Total",
+	"Linked donations",
+	"Difference",
+	"Reconciled total",
+}
+
+// WriteAccountBreakdownCSV writes a row for each account code and month of the account
+// code breakdown report to w as a CSV file.
+func WriteAccountBreakdownCSV(w io.Writer, report *domain.AccountBreakdownReport) error {
+	cw := csvsafe.NewWriter(w)
+	if err := cw.Write(accountBreakdownHeaders); err != nil {
+		return fmt.Errorf("account breakdown csv header write error: %w", err)
+	}
+	var rows [][]string
+	for _, a := range report.Accounts {
+		for _, m := range a.Months {
+			rows = append(rows, []string{
+				m.AccountCode,
+				m.AccountName,
+				m.Month,
+				strconv.Itoa(m.RecordCount),
+				strconv.Itoa(m.ReconciledCount),
+				m.Total.String(),
+				m.LinkedTotal.String(),
+				m.Difference().String(),
+				m.ReconciledTotal.String(),
+			})
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("account breakdown csv write error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestWriteAccountBreakdownCSV(t *testing.T) {
+	report := &domain.AccountBreakdownReport{
+		Accounts: []domain.AccountBreakdown{
+			{
+				AccountCode: "5501",
+				AccountName: "General Giving",
+				Months: []db.AccountMonth{
+					{AccountCode: "5501", AccountName: "General Giving", Month: "2025-04", RecordCount: 8, Total: money.FromFloat(2365), LinkedTotal: money.FromFloat(1000), ReconciledCount: 1, ReconciledTotal: money.FromFloat(200)},
+					{AccountCode: "5501", AccountName: "General Giving", Month: "2025-05", RecordCount: 3, Total: money.FromFloat(2800)},
+				},
+			},
+			{
+				AccountCode: "5701",
+				AccountName: "Spring Campaign, 2025",
+				Months: []db.AccountMonth{
+					{AccountCode: "5701", AccountName: "Spring Campaign, 2025", Month: "2025-04", RecordCount: 2, Total: money.FromFloat(405), LinkedTotal: money.FromFloat(155), ReconciledCount: 1, ReconciledTotal: money.FromFloat(155)},
+				},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteAccountBreakdownCSV(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	want := "Account code,Account name,Month,Records,Reconciled records,Total,Linked donations,Difference,Reconciled total\n" +
+		"5501,General Giving,2025-04,8,1,2365.00,1000.00,1365.00,200.00\n" +
+		"5501,General Giving,2025-05,3,0,2800.00,0.00,2800.00,0.00\n" +
+		"5701,\"Spring Campaign, 2025\",2025-04,2,1,405.00,155.00,250.00,155.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
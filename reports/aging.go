@@ -0,0 +1,48 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+)
+
+// agingHeaders are the column headings of the aging report CSV file.
+var agingHeaders = []string{
+	"Age bucket",
+	"Age in days",
+	"Type",
+	"ID",
+	"Reference",
+	"Name",
+	"Date",
+	"Amount",
+}
+
+// WriteAgingCSV writes the items of the aging report to w as a CSV file, oldest first.
+// The amount of an invoice or bank transaction is the amount not yet reconciled.
+func WriteAgingCSV(w io.Writer, report *domain.AgingReport) error {
+	cw := csvsafe.NewWriter(w)
+	if err := cw.Write(agingHeaders); err != nil {
+		return fmt.Errorf("aging csv header write error: %w", err)
+	}
+	rows := make([][]string, len(report.Items))
+	for i, item := range report.Items {
+		rows[i] = []string{
+			report.Buckets[item.Bucket].Name,
+			strconv.Itoa(item.AgeDays),
+			item.ItemType,
+			item.ID,
+			item.Reference,
+			item.Name,
+			item.Date.Format("2006-01-02"),
+			item.Amount.String(),
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("aging csv write error: %w", err)
+	}
+	return nil
+}
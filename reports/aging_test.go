@@ -0,0 +1,47 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestWriteAgingCSV(t *testing.T) {
+	report := &domain.AgingReport{
+		Buckets: []domain.AgingBucket{{Name: "0–30 days"}, {Name: "31–60 days"}},
+		Items: []db.AgingItem{
+			{
+				ItemType:  "invoice",
+				ID:        "inv-001",
+				Reference: "INV-2025-101",
+				Name:      "Example Corp, Ltd",
+				Date:      time.Date(2025, 4, 10, 10, 0, 0, 0, time.UTC),
+				Amount:    money.FromFloat(-50),
+				AgeDays:   45,
+				Bucket:    1,
+			},
+			{
+				ItemType: "donation",
+				ID:       "sf-opp-017",
+				Name:     "Online Donation",
+				Date:     time.Date(2025, 5, 16, 0, 0, 0, 0, time.UTC),
+				Amount:   money.FromFloat(150),
+				AgeDays:  9,
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteAgingCSV(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	want := "Age bucket,Age in days,Type,ID,Reference,Name,Date,Amount\n" +
+		"31–60 days,45,invoice,inv-001,INV-2025-101,\"Example Corp, Ltd\",2025-04-10,-50.00\n" +
+		"0–30 days,9,donation,sf-opp-017,,Online Donation,2025-05-16,150.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
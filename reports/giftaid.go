@@ -0,0 +1,127 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+)
+
+// giftAidHeaders are the column headings of the HMRC Gift Aid schedule spreadsheet.
+var giftAidHeaders = []string{
+	"Title",
+	"First name or initial",
+	"Last name",
+	"House name or number",
+	"Postcode",
+	"Aggregated donations",
+	"Sponsored event",
+	"Donation date",
+	"Amount",
+}
+
+// giftAidRows returns the schedule rows for a claim. Donations are not aggregated or
+// from sponsored events, so these columns are left empty. Dates are in the HMRC
+// DD/MM/YY format.
+func giftAidRows(claim *domain.GiftAidClaim) [][]string {
+	rows := make([][]string, len(claim.Donations))
+	for i, d := range claim.Donations {
+		rows[i] = []string{
+			d.Title,
+			d.FirstName,
+			d.LastName,
+			d.House,
+			d.Postcode,
+			"",
+			"",
+			d.Date.Format("02/01/06"),
+			fmt.Sprintf("%.2f", d.Amount),
+		}
+	}
+	return rows
+}
+
+// WriteGiftAidCSV writes the Gift Aid claim to w as a CSV file in the HMRC schedule
+// format.
+func WriteGiftAidCSV(w io.Writer, claim *domain.GiftAidClaim) error {
+	cw := csvsafe.NewWriter(w)
+	if err := cw.Write(giftAidHeaders); err != nil {
+		return fmt.Errorf("gift aid csv header write error: %w", err)
+	}
+	if err := cw.WriteAll(giftAidRows(claim)); err != nil {
+		return fmt.Errorf("gift aid csv write error: %w", err)
+	}
+	return nil
+}
+
+// odsManifest is the OpenDocument manifest for a spreadsheet with a single content
+// file.
+const odsManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:version="1.2" manifest:media-type="application/vnd.oasis.opendocument.spreadsheet"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+// WriteGiftAidODS writes the Gift Aid claim to w as an OpenDocument spreadsheet in
+// the HMRC schedule format. Amounts are written as numbers and other values as text.
+func WriteGiftAidODS(w io.Writer, claim *domain.GiftAidClaim) error {
+
+	var content bytes.Buffer
+	content.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" office:version="1.2">
+<office:body><office:spreadsheet><table:table table:name="Schedule">
+`)
+	cell := func(v string, numeric bool) error {
+		if numeric {
+			fmt.Fprintf(&content, `<table:table-cell office:value-type="float" office:value="%s"><text:p>`, v)
+		} else {
+			content.WriteString(`<table:table-cell office:value-type="string"><text:p>`)
+		}
+		if err := xml.EscapeText(&content, []byte(v)); err != nil {
+			return err
+		}
+		content.WriteString("</text:p></table:table-cell>")
+		return nil
+	}
+	amountCol := len(giftAidHeaders) - 1
+	for i, row := range append([][]string{giftAidHeaders}, giftAidRows(claim)...) {
+		content.WriteString("<table:table-row>")
+		for j, v := range row {
+			if err := cell(v, i > 0 && j == amountCol); err != nil {
+				return fmt.Errorf("gift aid ods cell error: %w", err)
+			}
+		}
+		content.WriteString("</table:table-row>\n")
+	}
+	content.WriteString("</table:table></office:spreadsheet></office:body></office:document-content>\n")
+
+	// The mimetype file must be first and uncompressed.
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name   string
+		method uint16
+		data   []byte
+	}{
+		{"mimetype", zip.Store, []byte("application/vnd.oasis.opendocument.spreadsheet")},
+		{"META-INF/manifest.xml", zip.Deflate, []byte(odsManifest)},
+		{"content.xml", zip.Deflate, content.Bytes()},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: f.method})
+		if err != nil {
+			return fmt.Errorf("gift aid ods %s create error: %w", f.name, err)
+		}
+		if _, err := fw.Write(f.data); err != nil {
+			return fmt.Errorf("gift aid ods %s write error: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("gift aid ods close error: %w", err)
+	}
+	return nil
+}
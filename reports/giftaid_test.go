@@ -0,0 +1,88 @@
+package reports
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+var testGiftAidClaim = &domain.GiftAidClaim{
+	Donations: []domain.GiftAidDonation{
+		{
+			DonationID: "ga-001",
+			Title:      "Mrs",
+			FirstName:  "Jane",
+			LastName:   "Smith & Co",
+			House:      "12",
+			Postcode:   "SW1A 1AA",
+			Date:       time.Date(2025, 4, 9, 0, 0, 0, 0, time.UTC),
+			Amount:     money.FromFloat(25),
+		},
+	},
+}
+
+func TestWriteGiftAidCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGiftAidCSV(&buf, testGiftAidClaim); err != nil {
+		t.Fatal(err)
+	}
+	want := "Title,First name or initial,Last name,House name or number,Postcode,Aggregated donations,Sponsored event,Donation date,Amount\n" +
+		"Mrs,Jane,Smith & Co,12,SW1A 1AA,,,09/04/25,25.00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteGiftAidODS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGiftAidODS(&buf, testGiftAidClaim); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := zr.File[0].Name, "mimetype"; got != want {
+		t.Fatalf("first file got %s want %s", got, want)
+	}
+	if got, want := zr.File[0].Method, zip.Store; got != want {
+		t.Errorf("mimetype method got %d want %d", got, want)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+	if got, want := files["mimetype"], "application/vnd.oasis.opendocument.spreadsheet"; got != want {
+		t.Errorf("mimetype got %q want %q", got, want)
+	}
+	if !strings.Contains(files["META-INF/manifest.xml"], `manifest:full-path="content.xml"`) {
+		t.Error("manifest does not list content.xml")
+	}
+	for _, s := range []string{
+		"<text:p>House name or number</text:p>",
+		"<text:p>Smith &amp; Co</text:p>",
+		"<text:p>09/04/25</text:p>",
+		`office:value-type="float" office:value="25.00"`,
+	} {
+		if !strings.Contains(files["content.xml"], s) {
+			t.Errorf("content does not contain %q", s)
+		}
+	}
+}
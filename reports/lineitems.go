@@ -0,0 +1,150 @@
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+	"github.com/xuri/excelize/v2"
+)
+
+// lineItemsHeaders are the column headings of the line item exports.
+var lineItemsHeaders = []string{
+	"Type",
+	"ID",
+	"Invoice number",
+	"Reference",
+	"Date",
+	"Contact",
+	"Status",
+	"Currency",
+	"Record total",
+	"Account code",
+	"Account name",
+	"Description",
+	"Quantity",
+	"Unit amount",
+	"Tax amount",
+	"Line amount",
+	"Donation account",
+}
+
+// lineItemsSheet is the name of the worksheet of the line items XLSX export.
+const lineItemsSheet = "Line items"
+
+// yesNo returns "yes" for true and "no" for false.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// WriteLineItemsCSV writes a row for each line item of the invoices or bank
+// transactions to w as a CSV file.
+func WriteLineItemsCSV(w io.Writer, items []db.LineItemExport) error {
+	cw := csvsafe.NewWriter(w)
+	if err := cw.Write(lineItemsHeaders); err != nil {
+		return fmt.Errorf("line items csv header write error: %w", err)
+	}
+	rows := make([][]string, len(items))
+	for i, li := range items {
+		rows[i] = []string{
+			li.RecordType,
+			li.RecordID,
+			li.Number,
+			li.Reference,
+			li.Date.Format("2006-01-02"),
+			li.Contact,
+			li.Status,
+			li.CurrencyCode,
+			li.Total.String(),
+			li.AccountCode,
+			li.AccountName,
+			li.Description,
+			strconv.FormatFloat(li.Quantity, 'f', -1, 64),
+			li.UnitAmount.String(),
+			li.TaxAmount.String(),
+			li.LineAmount.String(),
+			yesNo(li.DonationAccount),
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("line items csv write error: %w", err)
+	}
+	return nil
+}
+
+// WriteLineItemsXLSX writes a row for each line item of the invoices or bank
+// transactions to w as an Excel workbook, with the dates and amounts as numbers so that
+// the line items may be pivoted by account code.
+func WriteLineItemsXLSX(w io.Writer, items []db.LineItemExport) error {
+
+	f := excelize.NewFile()
+	defer func() {
+		_ = f.Close()
+	}()
+	if err := f.SetSheetName("Sheet1", lineItemsSheet); err != nil {
+		return fmt.Errorf("line items xlsx sheet error: %w", err)
+	}
+	dateStyle, err := f.NewStyle(&excelize.Style{NumFmt: 14}) // the locale date format
+	if err != nil {
+		return fmt.Errorf("line items xlsx date style error: %w", err)
+	}
+	amountStyle, err := f.NewStyle(&excelize.Style{NumFmt: 4}) // #,##0.00
+	if err != nil {
+		return fmt.Errorf("line items xlsx amount style error: %w", err)
+	}
+
+	sw, err := f.NewStreamWriter(lineItemsSheet)
+	if err != nil {
+		return fmt.Errorf("line items xlsx stream error: %w", err)
+	}
+	headers := make([]any, len(lineItemsHeaders))
+	for i, h := range lineItemsHeaders {
+		headers[i] = h
+	}
+	if err := sw.SetRow("A1", headers); err != nil {
+		return fmt.Errorf("line items xlsx header write error: %w", err)
+	}
+	amount := func(v float64) excelize.Cell {
+		return excelize.Cell{StyleID: amountStyle, Value: v}
+	}
+	for i, li := range items {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return fmt.Errorf("line items xlsx cell error: %w", err)
+		}
+		row := []any{
+			li.RecordType,
+			li.RecordID,
+			li.Number,
+			li.Reference,
+			excelize.Cell{StyleID: dateStyle, Value: li.Date},
+			li.Contact,
+			li.Status,
+			li.CurrencyCode,
+			amount(li.Total.Float()),
+			li.AccountCode,
+			li.AccountName,
+			li.Description,
+			li.Quantity,
+			amount(li.UnitAmount.Float()),
+			amount(li.TaxAmount.Float()),
+			amount(li.LineAmount.Float()),
+			yesNo(li.DonationAccount),
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			return fmt.Errorf("line items xlsx row %d write error: %w", i+1, err)
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("line items xlsx flush error: %w", err)
+	}
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("line items xlsx write error: %w", err)
+	}
+	return nil
+}
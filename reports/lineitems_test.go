@@ -0,0 +1,57 @@
+package reports
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/xuri/excelize/v2"
+)
+
+// testLineItems are the line items of an invoice with a donation and a fee.
+var testLineItems = []db.LineItemExport{
+	{RecordType: "invoice", RecordID: "inv-002", Number: "INV-2025-102", Date: time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC), Contact: "Generous Individual", Status: "PAID", CurrencyCode: "GBP", Total: money.FromFloat(196.50), AccountCode: "5301", AccountName: "Fundraising Dinners", Description: "Pledged donation, via Stripe", Quantity: 1, UnitAmount: money.FromFloat(200), LineAmount: money.FromFloat(200), DonationAccount: true},
+	{RecordType: "invoice", RecordID: "inv-002", Number: "INV-2025-102", Date: time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC), Contact: "Generous Individual", Status: "PAID", CurrencyCode: "GBP", Total: money.FromFloat(196.50), AccountCode: "429", AccountName: "Platform Fees", Description: "-Stripe processing fee", Quantity: 1, UnitAmount: money.FromFloat(-3.50), LineAmount: money.FromFloat(-3.50)},
+}
+
+func TestWriteLineItemsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLineItemsCSV(&buf, testLineItems); err != nil {
+		t.Fatal(err)
+	}
+	want := "Type,ID,Invoice number,Reference,Date,Contact,Status,Currency,Record total,Account code,Account name,Description,Quantity,Unit amount,Tax amount,Line amount,Donation account\n" +
+		"invoice,inv-002,INV-2025-102,,2025-04-12,Generous Individual,PAID,GBP,196.50,5301,Fundraising Dinners,\"Pledged donation, via Stripe\",1,200.00,0.00,200.00,yes\n" +
+		"invoice,inv-002,INV-2025-102,,2025-04-12,Generous Individual,PAID,GBP,196.50,429,Platform Fees,'-Stripe processing fee,1,-3.50,0.00,-3.50,no\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteLineItemsXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLineItemsXLSX(&buf, testLineItems); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := f.GetRows(lineItemsSheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rows), 3; got != want {
+		t.Fatalf("got %d rows want %d", got, want)
+	}
+	if got, want := rows[0][9], "Account code"; got != want {
+		t.Errorf("header got %q want %q", got, want)
+	}
+	// The amounts are numbers, and the date an Excel serial date.
+	for col, want := range map[int]string{4: "45759", 9: "429", 15: "-3.5", 16: "no"} {
+		if got := rows[2][col]; got != want {
+			t.Errorf("column %d got %q want %q", col, got, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// package reports renders reports for trustees, auditors and HMRC, such as the period
+// reconciliation report and Gift Aid claim schedules.
+package reports
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/pdf"
+)
+
+// Page layout in points.
+const (
+	marginLeft   = 50.0
+	marginRight  = pdf.A4Width - 50.0
+	marginTop    = 60.0
+	marginBottom = pdf.A4Height - 60.0
+	lineHeight   = 14.0
+	fontSize     = 9.0
+)
+
+// periodPDF lays out a period report, adding pages as needed.
+type periodPDF struct {
+	doc    *pdf.Document
+	report *domain.PeriodReport
+	y      float64
+}
+
+// WritePeriodPDF writes the period reconciliation report to w as a PDF document,
+// ending with a sign-off section for the preparer and approver.
+func WritePeriodPDF(w io.Writer, report *domain.PeriodReport) error {
+	p := &periodPDF{
+		doc:    pdf.New(pdf.A4Width, pdf.A4Height),
+		report: report,
+	}
+	p.newPage()
+
+	p.header()
+	p.accountTotals()
+	p.unlinkedDonations()
+	p.signOff()
+
+	_, err := p.doc.WriteTo(w)
+	if err != nil {
+		return fmt.Errorf("failed to write period report: %w", err)
+	}
+	return nil
+}
+
+// newPage starts a new page with a footer.
+func (p *periodPDF) newPage() {
+	p.doc.AddPage()
+	p.y = marginTop
+	footer := fmt.Sprintf("Reconciliation report %s  |  page %d",
+		p.period(), p.doc.PageCount())
+	p.doc.Text(marginLeft, marginBottom+30, pdf.Helvetica, 7, footer)
+}
+
+// need starts a new page if there is not room for height points on the current page.
+func (p *periodPDF) need(height float64) {
+	if p.y+height > marginBottom {
+		p.newPage()
+	}
+}
+
+// period describes the report period.
+func (p *periodPDF) period() string {
+	return fmt.Sprintf("%s to %s",
+		p.report.DateFrom.Format("02/01/2006"),
+		p.report.DateTo.Format("02/01/2006"),
+	)
+}
+
+func (p *periodPDF) header() {
+	p.doc.Text(marginLeft, p.y, pdf.HelveticaBold, 16, "Period Reconciliation Report")
+	p.y += 24
+	if p.report.Organisation != "" {
+		p.doc.Text(marginLeft, p.y, pdf.HelveticaBold, 11, p.report.Organisation)
+		p.y += lineHeight + 2
+	}
+	p.doc.Text(marginLeft, p.y, pdf.Helvetica, fontSize, "Period: "+p.period())
+	p.y += lineHeight
+	p.doc.Text(marginLeft, p.y, pdf.Helvetica, fontSize,
+		"Generated: "+p.report.Generated.Format("02/01/2006 15:04"))
+	p.y += lineHeight * 2
+}
+
+// section writes a section heading.
+func (p *periodPDF) section(title string) {
+	p.need(lineHeight * 4)
+	p.doc.Text(marginLeft, p.y, pdf.HelveticaBold, 12, title)
+	p.y += lineHeight + 4
+}
+
+// rule draws a horizontal line across the page below the current line.
+func (p *periodPDF) rule() {
+	p.doc.Line(marginLeft, p.y-10, marginRight, p.y-10, 0.5)
+}
+
+// Account totals column positions; amounts are right aligned.
+const (
+	colCode         = marginLeft
+	colName         = marginLeft + 45
+	colRecords      = marginLeft + 250
+	colTotal        = marginLeft + 320
+	colReconciled   = marginLeft + 410
+	colUnreconciled = marginRight
+)
+
+func (p *periodPDF) accountTotals() {
+	p.section("Donations by account code")
+
+	if len(p.report.AccountTotals) == 0 {
+		p.doc.Text(marginLeft, p.y, pdf.Helvetica, fontSize, "No donation income was recorded in the period.")
+		p.y += lineHeight * 2
+		return
+	}
+
+	p.doc.Text(colCode, p.y, pdf.HelveticaBold, fontSize, "Code")
+	p.doc.Text(colName, p.y, pdf.HelveticaBold, fontSize, "Account")
+	p.textRight(colRecords, pdf.HelveticaBold, "Records")
+	p.textRight(colTotal, pdf.HelveticaBold, "Total")
+	p.textRight(colReconciled, pdf.HelveticaBold, "Reconciled")
+	p.textRight(colUnreconciled, pdf.HelveticaBold, "Unreconciled")
+	p.y += 4
+	p.rule()
+	p.y += lineHeight
+
+	for _, at := range p.report.AccountTotals {
+		p.need(lineHeight)
+		p.doc.Text(colCode, p.y, pdf.Helvetica, fontSize, at.AccountCode)
+		p.doc.Text(colName, p.y, pdf.Helvetica, fontSize, truncate(at.AccountName, 36))
+		p.doc.TextRight(colRecords, p.y, fontSize, fmt.Sprint(at.RecordCount))
+		p.doc.TextRight(colTotal, p.y, fontSize, formatAmount(at.Total))
+		p.doc.TextRight(colReconciled, p.y, fontSize, formatAmount(at.ReconciledTotal))
+		p.doc.TextRight(colUnreconciled, p.y, fontSize, formatAmount(at.UnreconciledTotal))
+		p.y += lineHeight
+	}
+
+	p.need(lineHeight)
+	p.rule()
+	p.doc.Text(colCode, p.y, pdf.HelveticaBold, fontSize, "Total")
+	p.doc.TextRight(colTotal, p.y, fontSize, formatAmount(p.report.Total))
+	p.doc.TextRight(colReconciled, p.y, fontSize, formatAmount(p.report.ReconciledTotal))
+	p.doc.TextRight(colUnreconciled, p.y, fontSize, formatAmount(p.report.UnreconciledTotal))
+	p.y += lineHeight * 2
+}
+
+// Unlinked donation column positions; amounts are right aligned.
+const (
+	colDate      = marginLeft
+	colDonation  = marginLeft + 60
+	colReference = marginLeft + 300
+	colAmount    = marginRight
+)
+
+func (p *periodPDF) unlinkedDonations() {
+	p.section(fmt.Sprintf("Unlinked donations (%d)", len(p.report.UnlinkedDonations)))
+
+	if len(p.report.UnlinkedDonations) == 0 {
+		p.doc.Text(marginLeft, p.y, pdf.Helvetica, fontSize, "All donations in the period are linked.")
+		p.y += lineHeight * 2
+		return
+	}
+
+	heading := func() {
+		p.doc.Text(colDate, p.y, pdf.HelveticaBold, fontSize, "Date")
+		p.doc.Text(colDonation, p.y, pdf.HelveticaBold, fontSize, "Donation")
+		p.doc.Text(colReference, p.y, pdf.HelveticaBold, fontSize, "Payout reference")
+		p.textRight(colAmount, pdf.HelveticaBold, "Amount")
+		p.y += 4
+		p.rule()
+		p.y += lineHeight
+	}
+	heading()
+
+	for _, d := range p.report.UnlinkedDonations {
+		if p.y+lineHeight > marginBottom {
+			p.newPage()
+			heading()
+		}
+		reference := "-"
+		if s, ok := d.PayoutReference.(string); ok && s != "" {
+			reference = s
+		}
+		p.doc.Text(colDate, p.y, pdf.Helvetica, fontSize, d.CloseDateStr)
+		p.doc.Text(colDonation, p.y, pdf.Helvetica, fontSize, truncate(d.Name, 44))
+		p.doc.Text(colReference, p.y, pdf.Helvetica, fontSize, truncate(reference, 28))
+		p.doc.TextRight(colAmount, p.y, fontSize, formatAmount(d.Amount))
+		p.y += lineHeight
+	}
+
+	p.need(lineHeight)
+	p.rule()
+	p.doc.Text(colDate, p.y, pdf.HelveticaBold, fontSize, "Total")
+	p.doc.TextRight(colAmount, p.y, fontSize, formatAmount(p.report.UnlinkedTotal))
+	p.y += lineHeight * 2
+}
+
+// signOff adds signature lines for the preparer and approver of the report.
+func (p *periodPDF) signOff() {
+	p.need(lineHeight * 12)
+	p.section("Sign-off")
+	for _, role := range []string{"Prepared by", "Reviewed and approved by"} {
+		p.y += lineHeight * 2
+		p.doc.Text(marginLeft, p.y, pdf.Helvetica, fontSize, role)
+		p.doc.Line(marginLeft+120, p.y+2, marginLeft+330, p.y+2, 0.5)
+		p.doc.Text(marginLeft+345, p.y, pdf.Helvetica, fontSize, "Date")
+		p.doc.Line(marginLeft+370, p.y+2, marginRight, p.y+2, 0.5)
+		p.y += lineHeight * 1.5
+		p.doc.Text(marginLeft+120, p.y, pdf.Helvetica, 7, "Name and signature")
+	}
+}
+
+// textRight right aligns a heading at x. Headings use a proportional font, so the
+// position is approximate.
+func (p *periodPDF) textRight(x float64, font pdf.Font, text string) {
+	p.doc.Text(x-pdf.TextWidth(font, fontSize, text), p.y, font, fontSize, text)
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// formatAmount formats an amount to two decimal places with thousands separators.
+func formatAmount(a money.Amount) string {
+	s := a.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, c := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + "." + frac
+}
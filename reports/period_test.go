@@ -0,0 +1,85 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0.00"},
+		{12.5, "12.50"},
+		{1234.567, "1,234.57"},
+		{-1234567.1, "-1,234,567.10"},
+		{100000, "100,000.00"},
+	}
+	for _, tt := range tests {
+		if got := formatAmount(money.FromFloat(tt.in)); got != tt.want {
+			t.Errorf("formatAmount(%v) got %q want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWritePeriodPDF(t *testing.T) {
+
+	report := &domain.PeriodReport{
+		Organisation: "Demo Charity",
+		DateFrom:     time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:       time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Generated:    time.Date(2026, 4, 2, 10, 30, 0, 0, time.UTC),
+		AccountTotals: []db.AccountTotal{
+			{AccountCode: "5501", AccountName: "General Giving", RecordCount: 11, Total: money.FromFloat(5165), ReconciledTotal: money.FromFloat(200), UnreconciledTotal: money.FromFloat(4965)},
+		},
+		Total:             money.FromFloat(5165),
+		ReconciledTotal:   money.FromFloat(200),
+		UnreconciledTotal: money.FromFloat(4965),
+		UnlinkedTotal:     0,
+	}
+	// Enough unlinked donations to need a second page.
+	for i := range 60 {
+		report.UnlinkedDonations = append(report.UnlinkedDonations, domain.ViewDonation{
+			ID:              fmt.Sprintf("sf-%03d", i),
+			Name:            fmt.Sprintf("Donation %d", i),
+			Amount:          money.FromFloat(10),
+			CloseDateStr:    "01/05/2025",
+			PayoutReference: "",
+		})
+		report.UnlinkedTotal += money.FromFloat(10)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePeriodPDF(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, s := range []string{
+		"%PDF-1.4",
+		"/Count 2",
+		"(Period Reconciliation Report)",
+		"(Demo Charity)",
+		"(Period: 01/04/2025 to 31/03/2026)",
+		"(General Giving)",
+		"(5,165.00)",
+		"(4,965.00)",
+		"(Unlinked donations \\(60\\))",
+		"(Donation 59)",
+		"(600.00)",
+		"(Reviewed and approved by)",
+		"(Reconciliation report 01/04/2025 to 31/03/2026  |  page 2)",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("report does not contain %q", s)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package web
+
+// accounts.go shows the Xero accounts and selects those treated as donation accounts,
+// in place of the configured donation account prefixes.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// accountRow is an account on the accounts settings page, which is included if it is a
+// donation account.
+type accountRow struct {
+	db.Account
+	Included bool
+}
+
+// handleAccounts shows the Xero accounts with a form to select the donation accounts.
+func (web *WebApp) handleAccounts() appHandler {
+
+	name := "settings-accounts.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-accounts.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		accounts, err := web.reconciler.AccountsGet(ctx)
+		if err != nil {
+			return err
+		}
+		// Without a selection the accounts matching the configured prefixes are
+		// included.
+		selected := len(web.reconciler.DonationAccountsSelected()) > 0
+		configured := web.settings().AccountsRegexp
+		rows := make([]accountRow, len(accounts))
+		for i, a := range accounts {
+			included := configured != nil && configured.MatchString(a.Code)
+			if selected {
+				included = a.DonationAccount != nil && *a.DonationAccount
+			}
+			rows[i] = accountRow{Account: a, Included: included}
+		}
+		data := map[string]any{
+			"PageTitle":   "Donation Accounts",
+			"CurrentPage": "settings-accounts",
+			"Accounts":    rows,
+			"Selected":    selected,
+			"Prefixes":    web.settings().DonationAccountPrefixes,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleAccountsUpdate selects the accounts with the "code" form values as the
+// donation accounts, or clears the selection if the "reset" form value is set.
+func (web *WebApp) handleAccountsUpdate() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/settings/accounts", http.StatusSeeOther)
+			return nil
+		}
+
+		if r.PostFormValue("reset") != "" {
+			if err := web.reconciler.DonationAccountsReset(ctx); err != nil {
+				return err
+			}
+			return redirect("The donation accounts are set by the configured account prefixes.")
+		}
+
+		err := web.reconciler.DonationAccountsSet(ctx, r.PostForm["code"])
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return err
+		}
+		return redirect("The donation accounts were updated. Refresh the Xero records to retrieve the records of newly included accounts.")
+	}
+}
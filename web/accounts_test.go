@@ -0,0 +1,69 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestAccounts tests showing and selecting the donation accounts.
+func TestAccounts(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:                    config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:              config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DonationAccountPrefixes: []string{"55"},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a selection the accounts matching the prefixes are included.
+	req := httptest.NewRequest("GET", "/settings/accounts", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAccounts())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `value="5501" checked`) || strings.Contains(body, `value="9999" checked`) {
+		t.Error("expected only the account matching the prefixes to be included")
+	}
+
+	tests := []struct {
+		form   url.Values
+		sets   int
+		resets int
+	}{
+		{url.Values{"code": {"5501", "9999"}}, 1, 0},
+		{url.Values{}, 2, 0},
+		{url.Values{"reset": {"1"}}, 2, 1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/settings/accounts", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAccountsUpdate())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%v: status got %d want %d", tt.form, got, want)
+		}
+		if reconcilerMock.donationAccountsSet != tt.sets || reconcilerMock.donationAccountsReset != tt.resets {
+			t.Errorf("%v: sets %d resets %d want %d %d", tt.form, reconcilerMock.donationAccountsSet, reconcilerMock.donationAccountsReset, tt.sets, tt.resets)
+		}
+	}
+}
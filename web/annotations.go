@@ -0,0 +1,148 @@
+package web
+
+// annotations.go adds notes and flags to invoices, bank transactions and donations.
+// Invoice and bank transaction notes are shown on their detail pages, while donations,
+// which have no detail page, have their notes shown on a page of their own.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// annotationsURL returns the url of the page showing the notes of a record, being the
+// detail page of an invoice or bank transaction.
+func annotationsURL(typer, id string) string {
+	if typer == "donation" {
+		return fmt.Sprintf("/annotations/%s/%s", typer, id)
+	}
+	return fmt.Sprintf("/%s/%s", typer, id)
+}
+
+// handleAnnotations shows the notes and flags of a record, with a form to add a note.
+// The target is "/annotations/{{ .Typer }}/{{ .ID }}".
+func (web *WebApp) handleAnnotations() appHandler {
+
+	name := "annotations.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"partial-annotations.html",
+		"partial-assignment.html",
+		"annotations.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+
+		annotations, err := web.reconciler.AnnotationsGet(ctx, typer, id)
+		if err != nil {
+			return err
+		}
+		assignedTo, err := web.reconciler.AssignmentGet(ctx, typer, id)
+		if err != nil {
+			return err
+		}
+		// The page links back to the listing of a donation or the detail page of
+		// another record.
+		backURL := fmt.Sprintf("/%s/%s", typer, id)
+		if typer == "donation" {
+			backURL = "/donations"
+		}
+		data := struct {
+			PageTitle   string
+			CurrentPage string
+			Typer       string
+			ID          string
+			BackURL     string
+			Annotations []db.Annotation
+			AssignedTo  string
+			Message     string
+		}{
+			PageTitle:   fmt.Sprintf("Notes on %s %s", strings.ReplaceAll(typer, "-", " "), id),
+			CurrentPage: "annotations",
+			Typer:       typer,
+			ID:          id,
+			BackURL:     backURL,
+			Annotations: annotations,
+			AssignedTo:  assignedTo,
+			Message:     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleAnnotationAdd adds the "note" form value to a record, as a flag if the "flag"
+// form value is set, redirecting to the page showing the record's notes.
+// The target is "/annotations/{{ .Typer }}/{{ .ID }}".
+func (web *WebApp) handleAnnotationAdd() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, annotationsURL(typer, id), http.StatusSeeOther)
+			return nil
+		}
+
+		flag := r.PostFormValue("flag") != ""
+		err = web.reconciler.AnnotationAdd(ctx, typer, id, r.PostFormValue("note"), flag)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if err != nil {
+			return err
+		}
+		if flag {
+			return redirect("The flag was added.")
+		}
+		return redirect("The note was added.")
+	}
+}
+
+// handleAnnotationDelete removes a note or flag from a record, redirecting to the page
+// showing the record's notes.
+// The target is "/annotations/{{ .Typer }}/{{ .ID }}/{{ .AnnotationID }}/delete".
+func (web *WebApp) handleAnnotationDelete() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id", "annotation")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+		annotationID, err := strconv.ParseInt(vars["annotation"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid note id %q", vars["annotation"]), http.StatusBadRequest}
+		}
+
+		if err := web.reconciler.AnnotationDelete(ctx, typer, id, annotationID); err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", "The note was removed.")
+		http.Redirect(w, r, annotationsURL(typer, id), http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestAnnotations tests showing, adding and removing notes and flags.
+func TestAnnotations(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		typer    string
+		id       string
+		form     url.Values
+		location string
+		adds     int
+	}{
+		{"invoice", "inv-001", url.Values{"note": {"query with fundraising team"}, "flag": {"true"}}, "/invoice/inv-001", 1},
+		{"bank-transaction", "bt-001", url.Values{"note": {""}}, "/bank-transaction/bt-001", 2},
+		{"donation", "sf-opp-017", url.Values{"note": {"donor asked for a receipt"}}, "/annotations/donation/sf-opp-017", 3},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/annotations/"+tt.typer+"/"+tt.id, strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"type": tt.typer, "id": tt.id})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAnnotationAdd())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%s: status got %d want %d", tt.typer, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%s: location got %q want %q", tt.typer, got, want)
+		}
+		if got, want := reconcilerMock.annotationAdd, tt.adds; got != want {
+			t.Errorf("%s: adds got %d want %d", tt.typer, got, want)
+		}
+	}
+
+	vars := map[string]string{"type": "donation", "id": "sf-opp-017"}
+	req := httptest.NewRequest("GET", "/annotations/donation/sf-opp-017", nil)
+	req = mux.SetURLVars(req, vars)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAnnotations())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("page status got %d want %d", got, want)
+	}
+	if !strings.Contains(rec.Body.String(), "There are no notes on this record") {
+		t.Error("page does not show the empty notes")
+	}
+
+	vars["annotation"] = "1"
+	req = httptest.NewRequest("POST", "/annotations/donation/sf-opp-017/1/delete", nil)
+	req = mux.SetURLVars(req, vars)
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAnnotationDelete())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Errorf("delete status got %d want %d", got, want)
+	}
+	if got, want := reconcilerMock.annotationDelete, 1; got != want {
+		t.Errorf("deletes got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package web
+
+// assets.go serves the static assets with versioned urls, so that browsers may cache
+// an asset indefinitely yet fetch it again as soon as it changes. The version is a hash
+// of the asset's contents, added to its url by the asset template func.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// assetVersionLen is the number of hex characters of the content hash used as the
+// version of an asset.
+const assetVersionLen = 12
+
+// assetVersion returns the version of the static asset at name, or an empty string if
+// the asset cannot be read. Versions are cached other than in development, when the
+// assets may be changed on disk.
+func (web *WebApp) assetVersion(name string) string {
+	if v, ok := web.assetVersions.Load(name); ok && !web.inDevelopment {
+		return v.(string)
+	}
+	if web.staticFS == nil {
+		return ""
+	}
+	b, err := fs.ReadFile(web.staticFS, name)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	v := hex.EncodeToString(sum[:])[:assetVersionLen]
+	web.assetVersions.Store(name, v)
+	return v
+}
+
+// assetURL returns the url of the static asset at name, with its version if known. It
+// is provided to the templates as the asset func, for example
+// {{ asset "css/output.css" }}.
+func (web *WebApp) assetURL(name string) string {
+	u := "/static/" + name
+	if v := web.assetVersion(name); v != "" {
+		u += "?v=" + v
+	}
+	return u
+}
+
+// staticHandler serves the static assets. Requests for the current version of an asset
+// may be cached indefinitely, while other requests are revalidated on each use.
+func (web *WebApp) staticHandler() http.Handler {
+	fileServer := http.StripPrefix("/static/", http.FileServerFS(web.staticFS))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/static/")
+		if v := r.URL.Query().Get("v"); v != "" && v == web.assetVersion(name) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
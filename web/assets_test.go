@@ -0,0 +1,58 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestAssets tests the versioned static asset urls and their caching.
+func TestAssets(t *testing.T) {
+
+	staticFS := fstest.MapFS{
+		"css/output.css": {Data: []byte("body { color: black; }")},
+	}
+	webApp := &WebApp{staticFS: staticFS}
+
+	u := webApp.assetURL("css/output.css")
+	version, ok := strings.CutPrefix(u, "/static/css/output.css?v=")
+	if !ok || len(version) != assetVersionLen {
+		t.Fatalf("unexpected asset url %q", u)
+	}
+	if got, want := webApp.assetURL("css/missing.css"), "/static/css/missing.css"; got != want {
+		t.Errorf("missing asset url got %q want %q", got, want)
+	}
+
+	// Versions are cached, other than in development.
+	staticFS["css/output.css"] = &fstest.MapFile{Data: []byte("body { color: white; }")}
+	if got := webApp.assetURL("css/output.css"); got != u {
+		t.Errorf("cached asset url got %q want %q", got, u)
+	}
+	webApp.inDevelopment = true
+	if got := webApp.assetURL("css/output.css"); got == u {
+		t.Errorf("development asset url %q not updated", got)
+	}
+	webApp.inDevelopment = false
+	u = webApp.assetURL("css/output.css")
+
+	tests := []struct {
+		url          string
+		cacheControl string
+	}{
+		{u, "public, max-age=31536000, immutable"},
+		{"/static/css/output.css?v=stale", "no-cache"},
+		{"/static/css/output.css", "no-cache"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		webApp.staticHandler().ServeHTTP(rec, httptest.NewRequest("GET", tt.url, nil))
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Errorf("%s status got %d want %d", tt.url, got, want)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s cache control got %q want %q", tt.url, got, tt.cacheControl)
+		}
+	}
+}
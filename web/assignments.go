@@ -0,0 +1,112 @@
+package web
+
+// assignments.go assigns unreconciled invoices, bank transactions and donations to the
+// team members configured as web.users, so that a small team can split the
+// reconciliation work. Each user chooses which team member they are for their session,
+// which sets the records shown by the "Mine" filter of the listings.
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// userSessionKey is the session key of the team member chosen by the user.
+const userSessionKey = "user"
+
+// users returns the names of the configured team members.
+func (web *WebApp) users() []string {
+	if web.cfg == nil {
+		return nil
+	}
+	return web.cfg.Web.Users
+}
+
+// currentUser returns the team member chosen for the session, or an empty string if
+// none has been chosen or the chosen member is no longer configured.
+func (web *WebApp) currentUser(ctx context.Context) string {
+	user := web.sessions.GetString(ctx, userSessionKey)
+	if !slices.Contains(web.users(), user) {
+		return ""
+	}
+	return user
+}
+
+// assignedTo returns the assignee to which a listing is restricted, being the team
+// member of the session if mine is set, or otherwise an empty string for any
+// assignment. A listing is not restricted if no team member has been chosen.
+func (web *WebApp) assignedTo(ctx context.Context, mine bool) string {
+	if !mine {
+		return ""
+	}
+	return web.currentUser(ctx)
+}
+
+// userTemplateFuncs returns the template funcs listing the team members and reporting
+// the team member of the session.
+func (web *WebApp) userTemplateFuncs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"users":       web.users,
+		"currentUser": func() string { return web.currentUser(ctx) },
+	}
+}
+
+// handleUser sets the team member of the session to the "user" form value, or clears
+// it for an empty value, returning to the referring page of this site or otherwise to
+// the home page.
+// The target is "/user".
+func (web *WebApp) handleUser() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		if err := r.ParseForm(); err != nil {
+			return errUsage{"could not read the form", http.StatusBadRequest}
+		}
+		user := r.PostForm.Get("user")
+		if user != "" && !slices.Contains(web.users(), user) {
+			return errUsage{fmt.Sprintf("invalid user %q", user), http.StatusBadRequest}
+		}
+		web.sessions.Put(ctx, userSessionKey, user)
+
+		http.Redirect(w, r, refererTarget(r, "/home"), http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handleAssignment assigns a record to the team member of the "assigned-to" form value,
+// or unassigns it for an empty value, redirecting to the page showing the record.
+// The target is "/assignments/{{ .Typer }}/{{ .ID }}".
+func (web *WebApp) handleAssignment() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+
+		assignee := r.PostFormValue("assigned-to")
+		if assignee != "" && !slices.Contains(web.users(), assignee) {
+			return errUsage{fmt.Sprintf("invalid assignee %q", assignee), http.StatusBadRequest}
+		}
+		if err := web.reconciler.AssignmentSet(ctx, typer, id, assignee); err != nil {
+			return err
+		}
+		record := strings.ReplaceAll(typer, "-", " ")
+		msg := fmt.Sprintf("The %s was assigned to %s.", record, assignee)
+		if assignee == "" {
+			msg = fmt.Sprintf("The %s was unassigned.", record)
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, annotationsURL(typer, id), http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestAssignments tests choosing the team member of the session and assigning records.
+func TestAssignments(t *testing.T) {
+
+	cfg := &config.Config{
+		Web:        config.WebConfig{Users: []string{"Alex", "Sam"}},
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(user string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"user": {user}}
+		req := httptest.NewRequest("POST", "/user", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleUser())).ServeHTTP(rec, req)
+		return rec
+	}
+	if got, want := post("Jo").Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid user status got %d want %d", got, want)
+	}
+	rec := post("Sam")
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+
+	// The Mine filter restricts the listings to the team member of the session.
+	for _, tt := range []struct {
+		mine    bool
+		cookies []*http.Cookie
+		want    string
+	}{
+		{false, rec.Result().Cookies(), ""},
+		{true, nil, ""},
+		{true, rec.Result().Cookies(), "Sam"},
+	} {
+		req := httptest.NewRequest("GET", "/invoices", nil)
+		for _, c := range tt.cookies {
+			req.AddCookie(c)
+		}
+		var got string
+		webApp.sessions.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = webApp.assignedTo(r.Context(), tt.mine)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("mine %t with %d cookies got %q want %q", tt.mine, len(tt.cookies), got, tt.want)
+		}
+	}
+
+	// The donation notes page shows the assignment form.
+	req := httptest.NewRequest("GET", "/annotations/donation/sf-opp-017", nil)
+	req = mux.SetURLVars(req, map[string]string{"type": "donation", "id": "sf-opp-017"})
+	page := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAnnotations())).ServeHTTP(page, req)
+	if !strings.Contains(page.Body.String(), `<option value="Sam">Sam</option>`) {
+		t.Errorf("notes page does not show the assignment form:\n%s", page.Body.String())
+	}
+
+	tests := []struct {
+		typer    string
+		id       string
+		assignee string
+		status   int
+		location string
+		sets     int
+	}{
+		{"invoice", "inv-001", "Alex", http.StatusSeeOther, "/invoice/inv-001", 1},
+		{"bank-transaction", "bt-001", "", http.StatusSeeOther, "/bank-transaction/bt-001", 2},
+		{"donation", "sf-opp-017", "Sam", http.StatusSeeOther, "/annotations/donation/sf-opp-017", 3},
+		{"invoice", "inv-001", "Jo", http.StatusBadRequest, "", 3},
+	}
+	for _, tt := range tests {
+		form := url.Values{"assigned-to": {tt.assignee}}
+		req := httptest.NewRequest("POST", "/assignments/"+tt.typer+"/"+tt.id, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"type": tt.typer, "id": tt.id})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAssignment())).ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.status; got != want {
+			t.Errorf("%s %q: status got %d want %d", tt.typer, tt.assignee, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%s %q: location got %q want %q", tt.typer, tt.assignee, got, want)
+		}
+		if got, want := reconcilerMock.assignmentSet, tt.sets; got != want {
+			t.Errorf("%s %q: sets got %d want %d", tt.typer, tt.assignee, got, want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package web
+
+// backfill.go retrieves the records of one type over a historical date range in the
+// background, such as when an organisation extends how far back it reconciles, with
+// the progress of the backfill polled by the backfill page.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// backfillStatus is the state of the running or most recent backfill.
+type backfillStatus struct {
+	RecordType string
+	DateFrom   time.Time
+	DateTo     time.Time
+	Started    time.Time
+	Finished   time.Time // zero while the backfill runs
+	Progress   *salesforce.QueryProgress
+	Results    *domain.BackfillResults
+	Error      string
+}
+
+// Running reports if the backfill is running.
+func (b backfillStatus) Running() bool {
+	return b.Finished.IsZero()
+}
+
+// handleBackfill shows the backfill form and the status of the running or most recent
+// backfill.
+func (web *WebApp) handleBackfill() appHandler {
+
+	name := "backfill.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"backfill.html",
+		"partial-backfill-progress.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		dataStartDate := web.settings().DataStartDate
+		data := map[string]any{
+			"PageTitle":     "Backfill",
+			"CurrentPage":   "sync",
+			"RecordTypes":   domain.BackfillRecordTypes,
+			"DataStartDate": dataStartDate,
+			"DateFrom":      dataStartDate.AddDate(-1, 0, 0),
+			"DateTo":        dataStartDate.AddDate(0, 0, -1),
+			"Backfill":      web.backfillStatus(),
+			"Message":       web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleBackfillStart starts a backfill of the records of the "record-type" form value
+// dated from the "date-from" to the "date-to" form values, unless one is running.
+func (web *WebApp) handleBackfillStart() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/backfill", http.StatusSeeOther)
+			return nil
+		}
+
+		recordType := r.PostFormValue("record-type")
+		if !slices.Contains(domain.BackfillRecordTypes, recordType) {
+			return redirect(fmt.Sprintf("Records of type %q cannot be backfilled.", recordType))
+		}
+		var dates [2]time.Time
+		for i, field := range []string{"date-from", "date-to"} {
+			v := strings.TrimSpace(r.PostFormValue(field))
+			d, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return redirect(fmt.Sprintf("The date %q is not a valid date.", v))
+			}
+			dates[i] = d
+		}
+		if dates[1].Before(dates[0]) {
+			return redirect("The backfill requires a start date on or before its end date.")
+		}
+
+		// The tokens are taken from the session as the backfill outlives the request.
+		typer, platform := token.XeroToken, "Xero"
+		if recordType == "donation" {
+			typer, platform = token.SalesforceToken, "Salesforce"
+		}
+		tok, err := web.getValidTokenFromSession(ctx, typer)
+		if err != nil {
+			return redirect(fmt.Sprintf("Please reconnect to %s to backfill its records.", platform))
+		}
+		if !web.startBackfill(recordType, dates[0], dates[1], tok) {
+			return redirect("A backfill is already running.")
+		}
+		return redirect("The backfill was started.")
+	}
+}
+
+// handleBackfillProgress serves the htmx partial /backfill/progress reporting the status
+// of the running or most recent backfill, which is polled by the backfill page while
+// the backfill runs.
+func (web *WebApp) handleBackfillProgress() appHandler {
+
+	name := "partial-backfill-progress"
+	templates := web.parseTemplates("partial-backfill-progress.html")
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return web.render(w, r, templates, name, map[string]any{"Backfill": web.backfillStatus()})
+	}
+}
+
+// backfillStatus returns a copy of the status of the running or most recent backfill,
+// or nil if there has been none.
+func (web *WebApp) backfillStatus() *backfillStatus {
+	web.backfillMu.Lock()
+	defer web.backfillMu.Unlock()
+	if web.backfill == nil {
+		return nil
+	}
+	b := *web.backfill
+	return &b
+}
+
+// startBackfill starts backfilling the records of recordType dated from dateFrom to
+// dateTo with tok, the Xero token for bank transactions and invoices or the Salesforce
+// token for donations, reporting false if a backfill is already running.
+func (web *WebApp) startBackfill(recordType string, dateFrom, dateTo time.Time, tok *token.ExtendedToken) bool {
+
+	web.backfillMu.Lock()
+	defer web.backfillMu.Unlock()
+	if web.backfill != nil && web.backfill.Running() {
+		return false
+	}
+	web.backfill = &backfillStatus{
+		RecordType: recordType,
+		DateFrom:   dateFrom,
+		DateTo:     dateTo,
+		Started:    time.Now(),
+	}
+
+	go func() {
+		// The backfill outlives the request which starts it.
+		ctx := context.Background()
+		results, err := web.runBackfill(ctx, recordType, dateFrom, dateTo, tok)
+
+		web.backfillMu.Lock()
+		defer web.backfillMu.Unlock()
+		web.backfill.Finished = time.Now()
+		web.backfill.Progress = nil
+		web.backfill.Results = results
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			web.backfill.Error = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			web.backfill.Error = e.Msg
+		} else if err != nil {
+			web.log.Error(fmt.Sprintf("backfill error: %v", err))
+			web.backfill.Error = err.Error()
+		}
+	}()
+	return true
+}
+
+// runBackfill runs a backfill, recording the progress of the Salesforce query.
+func (web *WebApp) runBackfill(ctx context.Context, recordType string, dateFrom, dateTo time.Time, tok *token.ExtendedToken) (*domain.BackfillResults, error) {
+
+	var xeroClient domain.XeroClient
+	var sfClient domain.SalesforceClient
+	var err error
+	if recordType == "donation" {
+		sfClient, err = web.newSFClient(ctx, web.cfg, web.log, tok)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create salesforce client: %w", err)
+		}
+		ctx = salesforce.WithProgress(ctx, func(p salesforce.QueryProgress) {
+			web.backfillMu.Lock()
+			defer web.backfillMu.Unlock()
+			web.backfill.Progress = &p
+		})
+	} else {
+		xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), tok)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create xero client: %w", err)
+		}
+	}
+	return web.reconciler.Backfill(ctx, xeroClient, sfClient, recordType, dateFrom, dateTo, web.donationAccountsRegexp())
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+// TestBackfill tests the backfill page and running a backfill in the background.
+func TestBackfill(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/backfill", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleBackfill())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+	}
+	for _, want := range []string{`action="/backfill"`, `value="invoice"`, `name="date-from"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("backfill page does not contain %q", want)
+		}
+	}
+
+	// An invalid date is reported without starting a backfill.
+	form := url.Values{"record-type": {"invoice"}, "date-from": {"2024-13-01"}, "date-to": {"2024-12-31"}}
+	req = httptest.NewRequest("POST", "/backfill", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleBackfillStart())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+	}
+	if webApp.backfillStatus() != nil {
+		t.Error("expected no backfill to be started with an invalid date")
+	}
+
+	dateFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dateTo := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	if !webApp.startBackfill("invoice", dateFrom, dateTo, &token.ExtendedToken{}) {
+		t.Fatal("expected the backfill to start")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for webApp.backfillStatus().Running() {
+		if time.Now().After(deadline) {
+			t.Fatal("the backfill did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := mock.backfill, 1; got != want {
+		t.Errorf("got %d backfills want %d", got, want)
+	}
+
+	req = httptest.NewRequest("GET", "/backfill/progress", nil)
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleBackfillProgress())).ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "The backfill completed, storing 2 records") || strings.Contains(body, "hx-get") {
+		t.Errorf("unexpected finished backfill progress\n%s", body)
+	}
+
+	// Only one backfill runs at a time.
+	webApp.backfill.Finished = time.Time{}
+	if webApp.startBackfill("donation", dateFrom, dateTo, &token.ExtendedToken{}) {
+		t.Error("expected the backfill not to start while another runs")
+	}
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleBackfillProgress())).ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `hx-get="/backfill/progress"`) {
+		t.Errorf("expected the running backfill progress to be polled\n%s", rec.Body.String())
+	}
+}
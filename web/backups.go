@@ -0,0 +1,93 @@
+package web
+
+// backups.go lists the database backups, made on a schedule or before each data
+// refresh if configured, and allows a backup to be made or restored.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/backup"
+)
+
+// handleBackups shows the database backups, newest first.
+func (web *WebApp) handleBackups() appHandler {
+
+	name := "settings-backups.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-backups.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var backups []backup.Backup
+		var dir string
+		if web.backups != nil {
+			var err error
+			backups, err = web.backups.List()
+			if err != nil {
+				return errInternal{"failed to list backups", err}
+			}
+			dir = web.backups.Dir()
+		}
+		data := map[string]any{
+			"PageTitle":   "Backups",
+			"CurrentPage": "settings-backups",
+			"Enabled":     web.backups != nil,
+			"Directory":   dir,
+			"Settings":    web.cfg.Backups,
+			"Backups":     backups,
+			"Message":     web.sessions.PopString(r.Context(), "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleBackupCreate makes a backup.
+func (web *WebApp) handleBackupCreate() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if web.backups == nil {
+			return errUsage{"backups are not configured", http.StatusNotFound}
+		}
+		b, err := web.backups.Create(r.Context())
+		if err != nil {
+			return errInternal{"failed to make backup", err}
+		}
+		web.sessions.Put(r.Context(), "message", fmt.Sprintf("Backup %s was made.", b.Name))
+		http.Redirect(w, r, "/settings/backups", http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handleBackupRestore replaces the reconciler data with that of the named backup.
+func (web *WebApp) handleBackupRestore() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		if web.backups == nil {
+			return errUsage{"backups are not configured", http.StatusNotFound}
+		}
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/settings/backups", http.StatusSeeOther)
+			return nil
+		}
+
+		name := r.PostFormValue("name")
+		err := web.backups.Restore(ctx, name, web.reconciler.SnapshotImport)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if err != nil {
+			return redirect(fmt.Sprintf("The backup could not be restored: %v", err))
+		}
+		return redirect(fmt.Sprintf("Backup %s was restored.", name))
+	}
+}
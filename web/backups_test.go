@@ -0,0 +1,69 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestBackupHandlers tests making and restoring a backup.
+func TestBackupHandlers(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		Backups:    config.BackupConfig{Directory: t.TempDir(), Keep: 2},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(h appHandler, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/settings/backups", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(h)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	for range 3 {
+		if rec := post(webApp.handleBackupCreate(), nil); rec.Code != http.StatusSeeOther {
+			t.Fatalf("backup got status %d", rec.Code)
+		}
+	}
+	if got, want := reconcilerMock.snapshotExport, 3; got != want {
+		t.Errorf("snapshot exports got %d want %d", got, want)
+	}
+	backups, err := webApp.backups.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(backups), 2; got != want {
+		t.Fatalf("backups got %d want %d", got, want)
+	}
+
+	post(webApp.handleBackupRestore(), url.Values{"name": {backups[1].Name}})
+	if got, want := reconcilerMock.snapshotImport, 1; got != want {
+		t.Errorf("snapshot imports got %d want %d", got, want)
+	}
+	post(webApp.handleBackupRestore(), url.Values{"name": {"../../etc/passwd"}})
+	if got, want := reconcilerMock.snapshotImport, 1; got != want {
+		t.Errorf("snapshot imports after invalid restore got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestDetailDonationCandidates tests the candidate donations listed for linking on the
+// link tab of the invoice and bank transaction detail pages.
+func TestDetailDonationCandidates(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler appHandler
+		target  string
+		vars    map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "invoice link",
+			handler: webApp.handleInvoiceDetail(),
+			target:  "/invoice/inv-001/link?status=NotLinked&date-from=2025-04-01&date-to=2026-03-31",
+			vars:    map[string]string{"id": "inv-001", "action": "link"},
+			want:    []string{`id="donation-candidates"`, `value="sf-candidate-01"`, "Candidate Donation", "0.75"},
+		},
+		{
+			// The mock bank transaction has no reference and cannot be linked.
+			name:    "bank transaction without reference",
+			handler: webApp.handleBankTransactionDetail(),
+			target:  "/bank-transaction/bt-001/link?status=NotLinked&date-from=2025-04-01&date-to=2026-03-31",
+			vars:    map[string]string{"id": "bt-001", "action": "link"},
+			notWant: []string{`id="donation-candidates"`},
+		},
+		{
+			name:    "invoice unlink",
+			handler: webApp.handleInvoiceDetail(),
+			target:  "/invoice/inv-001/unlink?status=Linked&date-from=2025-04-01&date-to=2026-03-31",
+			vars:    map[string]string{"id": "inv-001", "action": "unlink"},
+			notWant: []string{`id="donation-candidates"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", tt.target, nil), tt.vars)
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(tt.handler)).ServeHTTP(rec, req)
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Fatalf("status got %d want %d", got, want)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(body, s) {
+					t.Errorf("body unexpectedly contains %q", s)
+				}
+			}
+		})
+	}
+	if got, want := mock.donationCandidatesGet, 2; got != want {
+		t.Errorf("got %d donation candidates calls want %d", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package web
+
+// conditional.go answers conditional GET requests for the listing results with 304 Not
+// Modified, so that browser reloads and htmx requests for unchanged results do not run
+// the listing queries again. The validators are derived from the version of the
+// database, which changes with every write, and the session state shown in the results.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notModified sets the ETag and Last-Modified headers of a response depending on the
+// data version, the request url and the session state in parts, reporting true after
+// writing a 304 Not Modified response if the request preconditions show the client
+// already has the response. The If-Modified-Since header is only considered without an
+// If-None-Match header.
+func (web *WebApp) notModified(w http.ResponseWriter, r *http.Request, parts ...any) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	version, modified := web.reconciler.DataVersionGet()
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", version, r.URL.RequestURI())
+	for _, p := range parts {
+		fmt.Fprintf(hash, "%v\n", p)
+	}
+	etag := `W/"` + hex.EncodeToString(hash.Sum(nil)[:12]) + `"`
+	modified = modified.UTC().Truncate(time.Second)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatch(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.After(ims) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch reports if the If-None-Match header value inm matches etag, using the weak
+// comparison.
+func etagMatch(inm, etag string) bool {
+	if strings.TrimSpace(inm) == "*" {
+		return true
+	}
+	for tag := range strings.SplitSeq(inm, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package web
+
+// connections.go reports the status of the Xero and Salesforce connections on the
+// /connect page and logs out of a provider by revoking its token, so that a different
+// organisation or user can be connected.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+// connectionStatus returns the status reported by client, if it has the capability,
+// or otherwise the status known from the token alone.
+func connectionStatus(client any, account string, et *token.ExtendedToken) apistatus.Status {
+	if cs, ok := client.(connectionStatuser); ok {
+		return cs.ConnectionStatus()
+	}
+	return apistatus.Status{Account: account, TokenExpiry: et.Expiry()}
+}
+
+// xeroConnectionStatus returns the status of the Xero connection with the valid token
+// et. The tenant ID found by the client is kept in the session token so that it is not
+// looked up again.
+func (web *WebApp) xeroConnectionStatus(ctx context.Context, et *token.ExtendedToken) apistatus.Status {
+	client, err := web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), et)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("xero connection status client error: %v", err))
+		return connectionStatus(nil, et.TenantID, et)
+	}
+	web.sessions.Put(ctx, token.XeroToken.SessionName(), *et)
+	return connectionStatus(client, et.TenantID, et)
+}
+
+// sfConnectionStatus returns the status of the Salesforce connection with the valid
+// token et.
+func (web *WebApp) sfConnectionStatus(ctx context.Context, et *token.ExtendedToken) apistatus.Status {
+	client, err := web.newSFClient(ctx, web.cfg, web.log, et)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("salesforce connection status client error: %v", err))
+		return connectionStatus(nil, et.InstanceURL, et)
+	}
+	return connectionStatus(client, et.InstanceURL, et)
+}
+
+// oauth2Config returns the oauth2 config of the provider of typer.
+func (web *WebApp) oauth2Config(typer token.TokenType) *oauth2.Config {
+	if typer == token.SalesforceToken {
+		return web.cfg.Salesforce.OAuth2Config
+	}
+	return web.cfg.Xero.OAuth2Config
+}
+
+// revokeToken revokes the session token of typer with its provider, if there is one,
+// and removes it from the session. The token is removed even if revocation fails.
+func (web *WebApp) revokeToken(ctx context.Context, typer token.TokenType) error {
+	et, ok := web.sessions.Get(ctx, typer.SessionName()).(token.ExtendedToken)
+	if !ok {
+		return nil
+	}
+	defer web.sessions.Remove(ctx, typer.SessionName())
+	if err := et.Revoke(ctx, web.oauth2Config(typer)); err != nil {
+		return err
+	}
+	web.log.Info(fmt.Sprintf("%s token revoked", typer))
+	return nil
+}
+
+// handleProviderLogout revokes the token of the provider in the url and removes it from
+// the session, so that a new login is needed, and redirects to the connect page.
+// The target is "/logout/{provider}".
+func (web *WebApp) handleProviderLogout() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "provider")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		var typer token.TokenType
+		switch vars["provider"] {
+		case "xero":
+			typer = token.XeroToken
+		case "salesforce":
+			typer = token.SalesforceToken
+		default:
+			return errUsage{fmt.Sprintf("invalid provider %q", vars["provider"]), http.StatusBadRequest}
+		}
+
+		msg := fmt.Sprintf("Logged out of %s.", providerNames[typer])
+		if err := web.revokeToken(ctx, typer); err != nil {
+			web.log.Warn(fmt.Sprintf("%s token revocation error: %v", typer, err))
+			msg = fmt.Sprintf("Logged out of %s, but %s could not be told to revoke the login.", providerNames[typer], providerNames[typer])
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/connect", http.StatusSeeOther)
+		return nil
+	}
+}
+
+// providerNames are the display names of the api providers.
+var providerNames = map[token.TokenType]string{
+	token.XeroToken:       "Xero",
+	token.SalesforceToken: "Salesforce",
+}
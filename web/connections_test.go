@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+// statusClient is a client with the connectionStatuser capability.
+type statusClient struct {
+	status apistatus.Status
+}
+
+func (sc statusClient) ConnectionStatus() apistatus.Status {
+	return sc.status
+}
+
+func TestConnectionStatus(t *testing.T) {
+
+	expiry := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	et := &token.ExtendedToken{Type: token.XeroToken, Token: &oauth2.Token{Expiry: expiry}, TenantID: "tenant"}
+
+	// A client without the capability reports the token status.
+	want := apistatus.Status{Account: "tenant", TokenExpiry: expiry}
+	if diff := cmp.Diff(want, connectionStatus(&mockXeroClient{}, "tenant", et)); diff != "" {
+		t.Errorf("token status mismatch (-want +got):\n%s", diff)
+	}
+
+	reported := apistatus.Status{
+		Account:     "tenant",
+		TokenExpiry: expiry,
+		LastCall:    expiry.Add(-time.Minute),
+		Limits:      []apistatus.Limit{{Period: "day", Remaining: 10, Max: 5000}},
+	}
+	if diff := cmp.Diff(reported, connectionStatus(statusClient{reported}, "tenant", et)); diff != "" {
+		t.Errorf("client status mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestProviderLogout(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	// The revocation endpoints, refusing to revoke the salesforce token.
+	var revoked []string
+	endpoints := http.NewServeMux()
+	endpoints.HandleFunc("POST /connect/revocation", func(w http.ResponseWriter, r *http.Request) {
+		revoked = append(revoked, r.PostFormValue("token"))
+	})
+	endpoints.HandleFunc("POST /services/oauth2/revoke", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unsupported_token_type", http.StatusBadRequest)
+	})
+	server := httptest.NewServer(endpoints)
+	defer server.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+	webApp := &WebApp{
+		log:      logger,
+		sessions: sessionStore,
+		cfg: &config.Config{
+			Xero: config.XeroConfig{OAuth2Config: &oauth2.Config{
+				Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/connect/token"},
+			}},
+			Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{
+				Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/services/oauth2/token"},
+			}},
+		},
+	}
+	for _, typer := range []token.TokenType{token.XeroToken, token.SalesforceToken} {
+		webApp.sessions.Put(ctx, typer.SessionName(), token.ExtendedToken{
+			Type:  typer,
+			Token: &oauth2.Token{AccessToken: "access", RefreshToken: typer.String() + "-refresh", Expiry: time.Now().Add(time.Hour)},
+		})
+	}
+
+	post := func(provider string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/logout/"+provider, nil)
+		req = mux.SetURLVars(req, map[string]string{"provider": provider})
+		rec := httptest.NewRecorder()
+		webApp.ErrorChecker(webApp.handleProviderLogout()).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got, want := post("myob").Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid provider status got %d want %d", got, want)
+	}
+
+	rec := post("xero")
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/connect"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+	if got, want := webApp.sessions.PopString(ctx, "message"), "Logged out of Xero."; got != want {
+		t.Errorf("message got %q want %q", got, want)
+	}
+	if diff := cmp.Diff([]string{"xero-refresh"}, revoked); diff != "" {
+		t.Errorf("revoked tokens mismatch (-want +got):\n%s", diff)
+	}
+	if webApp.sessions.Exists(ctx, token.XeroToken.SessionName()) {
+		t.Error("xero token not removed from the session")
+	}
+	if !webApp.sessions.Exists(ctx, token.SalesforceToken.SessionName()) {
+		t.Error("salesforce token removed from the session")
+	}
+
+	// A token refused by the provider is still removed.
+	post("salesforce")
+	if got, want := webApp.sessions.PopString(ctx, "message"), "Logged out of Salesforce, but Salesforce could not be told to revoke the login."; got != want {
+		t.Errorf("message got %q want %q", got, want)
+	}
+	if webApp.sessions.Exists(ctx, token.SalesforceToken.SessionName()) {
+		t.Error("salesforce token not removed from the session")
+	}
+}
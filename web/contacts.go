@@ -0,0 +1,105 @@
+package web
+
+// contacts.go provides the contact detail page, listing the invoices and bank
+// transactions for a Xero contact such as a donor or payment platform, with the
+// contact names merged into the contact as aliases.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// handleContact serves the detail page at /contact/<id> for a single contact.
+func (web *WebApp) handleContact() appHandler {
+
+	name := "contact.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"contact.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+
+		contact, records, err := web.reconciler.ContactDetailGet(ctx, vars["id"])
+		if err != nil {
+			return err
+		}
+		aliases, candidates, err := web.reconciler.ContactAliasesGet(ctx, vars["id"])
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Contact",
+			"CurrentPage": "contact",
+			"ID":          vars["id"],
+			"Contact":     contact,
+			"Records":     records,
+			"Aliases":     aliases,
+			"Candidates":  candidates,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleContactAliasAdd merges the contact name of the "name" form value into the
+// contact.
+// The target is "/contact/{{ .ID }}/aliases".
+func (web *WebApp) handleContactAliasAdd() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/contact/"+vars["id"], http.StatusSeeOther)
+			return nil
+		}
+
+		err = web.reconciler.ContactAliasAdd(ctx, vars["id"], r.PostFormValue("name"))
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return err
+		}
+		return redirect("The contact name was merged into this contact.")
+	}
+}
+
+// handleContactAliasRemove removes the contact alias of the "alias" form value.
+// The target is "/contact/{{ .ID }}/aliases/remove".
+func (web *WebApp) handleContactAliasRemove() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		if err := web.reconciler.ContactAliasRemove(ctx, r.PostFormValue("alias")); err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", "The contact alias was removed.")
+		http.Redirect(w, r, "/contact/"+vars["id"], http.StatusSeeOther)
+		return nil
+	}
+}
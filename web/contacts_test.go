@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestContactAliases tests listing the aliases of a contact and merging and
+// removing contact names.
+func TestContactAliases(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(method, target string, form url.Values, handler appHandler) *httptest.ResponseRecorder {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, target, body)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": "con-stripe"})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(handler)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("GET", "/contact/con-stripe", nil, webApp.handleContact())
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("contact status got %d want %d", got, want)
+	}
+	for _, want := range []string{"Stripe Payments UK", `value="STRIPE"`, `action="/contact/con-stripe/aliases"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("contact page does not contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		form    url.Values
+		handler appHandler
+		counter *int
+	}{
+		{"add", "/contact/con-stripe/aliases", url.Values{"name": {"STRIPE"}}, webApp.handleContactAliasAdd(), &mock.contactAliasAdd},
+		{"add invalid", "/contact/con-stripe/aliases", url.Values{"name": {""}}, webApp.handleContactAliasAdd(), &mock.contactAliasAdd},
+		{"remove", "/contact/con-stripe/aliases/remove", url.Values{"alias": {"stripe"}}, webApp.handleContactAliasRemove(), &mock.contactAliasRemove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := *tt.counter
+			rec := serve("POST", tt.target, tt.form, tt.handler)
+			if got, want := rec.Code, http.StatusSeeOther; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if got, want := rec.Header().Get("Location"), "/contact/con-stripe"; got != want {
+				t.Errorf("location got %q want %q", got, want)
+			}
+			if *tt.counter != before+1 {
+				t.Errorf("the reconciler was not called")
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package web
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html/template"
 	"net/http"
 )
 
@@ -17,7 +22,7 @@ func preventCSRF(next http.Handler) http.Handler {
 
 // enforceCSRF wraps preventCSRF and ensure that any browser or agent that does not
 // support the CSRF protection headers is rejected.
-func enforceCSRF(next http.Handler) http.Handler {
+func (web *WebApp) enforceCSRF(next http.Handler) http.Handler {
 
 	standardCSRF := preventCSRF(next)
 
@@ -31,7 +36,7 @@ func enforceCSRF(next http.Handler) http.Handler {
 
 		// Reject if browser/agent does not support Sec-Fetch-Site or Origin.
 		if r.Header.Get("Sec-Fetch-Site") == "" && r.Header.Get("Origin") == "" {
-			log.Printf("Rejected request from %s: missing Sec-Fetch-Site and/or Origin headers", r.RemoteAddr)
+			web.log.Warn("rejected request missing Sec-Fetch-Site and Origin headers", "ip", r.RemoteAddr, "method", r.Method, "uri", r.URL.RequestURI())
 			http.Error(w, "Agent or browser not supported.", http.StatusForbidden)
 			return
 		}
@@ -40,3 +45,78 @@ func enforceCSRF(next http.Handler) http.Handler {
 		standardCSRF.ServeHTTP(w, r)
 	})
 }
+
+// csrfTokenSessionKey is the session key for the per-session CSRF token.
+const csrfTokenSessionKey = "csrf-token"
+
+// csrfTokenHeader is the request header in which htmx sends the CSRF token. The header
+// is set for all htmx requests by the hx-headers attribute in base.html.
+const csrfTokenHeader = "X-CSRF-Token"
+
+// csrfTokenField is the form field name for the CSRF token in non-htmx forms.
+const csrfTokenField = "csrf-token"
+
+// csrfToken returns the CSRF token for the current session, creating and storing one
+// if it does not yet exist.
+func (web *WebApp) csrfToken(ctx context.Context) string {
+	tok := web.sessions.GetString(ctx, csrfTokenSessionKey)
+	if tok != "" {
+		return tok
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // rand.Read never returns an error.
+	tok = base64.RawURLEncoding.EncodeToString(b)
+	web.sessions.Put(ctx, csrfTokenSessionKey, tok)
+	return tok
+}
+
+// csrfTemplateFuncs returns the request-specific template funcs for embedding the CSRF
+// token in templates, either as a raw token (for hx-headers) or as a hidden input field.
+func (web *WebApp) csrfTemplateFuncs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"csrfToken": func() string {
+			return web.csrfToken(ctx)
+		},
+		"csrfField": func() template.HTML {
+			return template.HTML(fmt.Sprintf(
+				`<input type="hidden" name="%s" value="%s">`,
+				csrfTokenField,
+				template.HTMLEscapeString(web.csrfToken(ctx)),
+			))
+		},
+	}
+}
+
+// verifyCSRFToken is middleware that checks that data-changing requests carry the CSRF
+// token stored in the session, either in the X-CSRF-Token header or the csrf-token form
+// field. The form field is removed after verification so that it is not seen by form
+// decoders. This middleware must run inside the session LoadAndSave middleware.
+func (web *WebApp) verifyCSRFToken(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// Ignore non-data changing methods.
+		if r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" || r.Method == "TRACE" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionToken := web.sessions.GetString(r.Context(), csrfTokenSessionKey)
+
+		formToken := r.PostFormValue(csrfTokenField)
+		requestToken := r.Header.Get(csrfTokenHeader)
+		if requestToken == "" {
+			requestToken = formToken
+		}
+		r.PostForm.Del(csrfTokenField)
+		r.Form.Del(csrfTokenField)
+
+		if sessionToken == "" || subtle.ConstantTimeCompare([]byte(sessionToken), []byte(requestToken)) != 1 {
+			web.log.Warn(fmt.Sprintf("Rejected %s request to %s from %s: invalid CSRF token", r.Method, r.URL.Path, r.RemoteAddr))
+			http.Error(w, "CSRF token check failed", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,9 +4,16 @@
 package web
 
 import (
+	"context"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/alexedwards/scs/v2"
 )
 
 // httptestNewRequest works around https://go.dev/issue/73151.
@@ -25,7 +32,7 @@ var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 // This is modified from net/http.csrf_test.go's TestCrossOriginProtectionSecFetchSite
 func TestEnforceCSRF(t *testing.T) {
 
-	handler := enforceCSRF(okHandler)
+	handler := (&WebApp{log: slog.New(slog.NewTextHandler(io.Discard, nil))}).enforceCSRF(okHandler)
 	// handler := preventCSRF(okHandler)
 
 	tests := []struct {
@@ -91,3 +98,70 @@ func TestEnforceCSRF(t *testing.T) {
 		})
 	}
 }
+
+// TestVerifyCSRFToken tests the session-based CSRF token middleware.
+func TestVerifyCSRFToken(t *testing.T) {
+
+	sessionStore := scs.New()
+	webApp := &WebApp{
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		sessions: sessionStore,
+	}
+
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+	validToken := webApp.csrfToken(ctx)
+	if got, want := webApp.csrfToken(ctx), validToken; got != want {
+		t.Fatalf("token not stable for session: got %q want %q", got, want)
+	}
+
+	// formHandler reports an error if the csrf token form field is passed through.
+	formHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.PostForm[csrfTokenField]; ok {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := webApp.verifyCSRFToken(formHandler)
+
+	tests := []struct {
+		name           string
+		method         string
+		header         string
+		formToken      string
+		expectedStatus int
+	}{
+		{"GET allowed", "GET", "", "", http.StatusOK},
+		{"POST without token", "POST", "", "", http.StatusForbidden},
+		{"POST with header token", "POST", validToken, "", http.StatusOK},
+		{"POST with form token", "POST", "", validToken, http.StatusOK},
+		{"POST with invalid header token", "POST", "invalid", "", http.StatusForbidden},
+		{"POST with invalid form token", "POST", "", "invalid", http.StatusForbidden},
+		{"PUT with header token", "PUT", validToken, "", http.StatusOK},
+		{"DELETE without token", "DELETE", "", "", http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := url.Values{"donation-ids": {"abc"}}
+			if tc.formToken != "" {
+				body.Set(csrfTokenField, tc.formToken)
+			}
+			req := httptest.NewRequestWithContext(ctx, tc.method, "/", strings.NewReader(body.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.header != "" {
+				req.Header.Set(csrfTokenHeader, tc.header)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("got status %d, want %d", w.Code, tc.expectedStatus)
+			}
+		})
+	}
+}
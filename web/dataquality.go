@@ -0,0 +1,193 @@
+package web
+
+// dataquality.go reports local data which no longer agrees with the remote systems: the
+// donations deleted or merged in Salesforce, which may be removed, and the donation
+// links whose remote references no longer match after edits made directly in
+// Salesforce or Xero.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// linkVerifySample is the default number of donation links verified, as each linked
+// Xero record is retrieved separately.
+const linkVerifySample = 50
+
+// handleDataQuality shows the donations flagged as orphaned by the last check.
+func (web *WebApp) handleDataQuality() appHandler {
+
+	name := "data-quality.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"data-quality.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		orphans, err := web.reconciler.DonationOrphansGet(ctx)
+		if err != nil {
+			return errInternal{"failed to retrieve orphaned donations", err}
+		}
+		data := map[string]any{
+			"PageTitle":   "Data Quality",
+			"CurrentPage": "data-quality",
+			"Orphans":     orphans,
+			"Sample":      linkVerifySample,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleLinksVerify re-fetches the remote records of a sample of the donation links,
+// of the size of the "sample" query value or all links if it is 0, and shows the links
+// which no longer match on the data quality page. As verification only reads the remote
+// records it is a GET.
+// The target is "/data-quality/links/verify".
+func (web *WebApp) handleLinksVerify() appHandler {
+
+	name := "data-quality.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"data-quality.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		sample := linkVerifySample
+		if v := r.URL.Query().Get("sample"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return errUsage{fmt.Sprintf("invalid sample size %q", v), http.StatusBadRequest}
+			}
+			sample = n
+		}
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			web.log.Info("sfToken empty, redirecting to connect")
+			http.Redirect(w, r, "/connect", http.StatusSeeOther)
+			return nil
+		}
+		xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken)
+		if err != nil {
+			web.log.Info("xeroToken empty, redirecting to connect")
+			http.Redirect(w, r, "/connect", http.StatusSeeOther)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for the link verification", err}
+		}
+		xeroClient, err := web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken)
+		if err != nil {
+			return errInternal{"failed to create xero client for the link verification", err}
+		}
+
+		results, err := web.reconciler.LinksVerify(ctx, sfClient, xeroClient, sample)
+		var msg string
+		if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+			results = nil
+		} else if err != nil {
+			return errInternal{"failed to verify the donation links", err}
+		}
+
+		orphans, err := web.reconciler.DonationOrphansGet(ctx)
+		if err != nil {
+			return errInternal{"failed to retrieve orphaned donations", err}
+		}
+		data := map[string]any{
+			"PageTitle":   "Data Quality",
+			"CurrentPage": "data-quality",
+			"Orphans":     orphans,
+			"Sample":      sample,
+			"Verify":      results,
+			"Message":     msg,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleDonationOrphansCheck compares the local donations with Salesforce, flagging
+// those no longer found, and redirects to the data quality page.
+// The target is "/data-quality/orphans/check".
+func (web *WebApp) handleDonationOrphansCheck() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			web.log.Info("sfToken empty, redirecting to connect")
+			http.Redirect(w, r, "/connect", http.StatusSeeOther)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for the orphan check", err}
+		}
+
+		results, err := web.reconciler.DonationOrphansCheck(ctx, sfClient, web.settings().DataStartDate)
+		var msg string
+		if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return errInternal{"failed to check for orphaned donations", err}
+		} else {
+			msg = fmt.Sprintf(
+				"%d donations were checked: %d are deleted and %d are missing in Salesforce.",
+				results.CheckedNo, results.DeletedNo, results.MissingNo,
+			)
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/data-quality", http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handleDonationOrphansRemove removes the selected orphaned donations from the local
+// database, and redirects to the data quality page.
+// The target is "/data-quality/orphans/remove".
+func (web *WebApp) handleDonationOrphansRemove() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		if err := r.ParseForm(); err != nil {
+			return errUsage{"could not parse form", http.StatusBadRequest}
+		}
+		ids := r.Form["donation-ids"]
+
+		msg := "No donations were selected."
+		if len(ids) > 0 {
+			removed, err := web.reconciler.DonationOrphansRemove(ctx, ids)
+			if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+				msg = e.Msg
+			} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+				web.log.Error(err.Error(), "detail", e.Detail)
+				msg = e.Msg
+			} else if err != nil {
+				return errInternal{"failed to remove orphaned donations", err}
+			} else {
+				msg = fmt.Sprintf("%d orphaned donations were removed.", removed)
+			}
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/data-quality", http.StatusSeeOther)
+		return nil
+	}
+}
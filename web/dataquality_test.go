@@ -0,0 +1,156 @@
+package web
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestDonationOrphans(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	webApp := &WebApp{
+		reconciler: domain.NewReconciler(testDB, logger),
+		log:        logger,
+		sessions:   sessionStore,
+		cfg: &config.Config{
+			DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		newSFClient: NewMockSFClient,
+	}
+	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), token.ExtendedToken{
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example.com",
+		Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	})
+
+	post := func(h appHandler, form url.Values) string {
+		t.Helper()
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		writer := httptest.NewRecorder()
+		webApp.ErrorChecker(h).ServeHTTP(writer, req)
+		if got, want := writer.Code, http.StatusSeeOther; got != want {
+			t.Errorf("got code %d want %d", got, want)
+		}
+		return webApp.sessions.PopString(ctx, "message")
+	}
+
+	// The mock client finds every donation.
+	msg := post(webApp.handleDonationOrphansCheck(), nil)
+	if !strings.HasSuffix(msg, ": 0 are deleted and 0 are missing in Salesforce.") {
+		t.Errorf("unexpected check message %q", msg)
+	}
+
+	if err := testDB.DonationOrphansRecord(ctx, map[string]string{"sf-opp-001": "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ids     []string
+		message string
+	}{
+		{nil, "No donations were selected."},
+		{[]string{"sf-opp-002"}, "donation sf-opp-002 is not flagged as orphaned and cannot be removed"},
+		{[]string{"sf-opp-001"}, "1 orphaned donations were removed."},
+	}
+	for _, tt := range tests {
+		if got, want := post(webApp.handleDonationOrphansRemove(), url.Values{"donation-ids": tt.ids}), tt.message; got != want {
+			t.Errorf("got message %q want %q", got, want)
+		}
+	}
+}
+
+// TestLinksVerify tests verifying the donation links, which needs both tokens.
+func TestLinksVerify(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := webApp.sessions.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		webApp.ErrorChecker(webApp.handleLinksVerify()).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Without the tokens the user is sent to connect.
+	rec := get("/data-quality/links/verify")
+	if got, want := rec.Header().Get("Location"), "/connect"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+
+	sfToken := token.ExtendedToken{
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example.com",
+		Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	}
+	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), sfToken)
+	xeroToken := sfToken
+	xeroToken.Type = token.XeroToken
+	xeroToken.TenantID = "tenant-1"
+	webApp.sessions.Put(ctx, token.XeroToken.SessionName(), xeroToken)
+
+	rec = get("/data-quality/links/verify?sample=10")
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+	}
+	for _, want := range []string{"Mismatched Donation", "INV-CHANGED", `id="verify-summary"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+	if got, want := mock.linksVerify, 1; got != want {
+		t.Errorf("got %d verifications want %d", got, want)
+	}
+
+	rec = get("/data-quality/links/verify?sample=many")
+	if got, want := rec.Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid sample status got %d want %d", got, want)
+	}
+}
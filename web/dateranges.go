@@ -0,0 +1,85 @@
+package web
+
+// dateranges.go resolves the date range presets of the listing search forms, such as
+// "this-fy" or "last-90-days", into dates. The presets are resolved when the form is
+// validated, so that a listing url or saved search with a preset always shows the
+// current period.
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// dateRangePresets are the date range presets offered by the search forms. Other
+// relative expressions such as "last-30-days", "last-6-weeks" or "last-3-months" are
+// also accepted.
+var dateRangePresets = []string{"this-fy", "last-fy", "last-90-days", "this-month"}
+
+// relativeDateRange matches the relative date range expressions.
+var relativeDateRange = regexp.MustCompile(`^last-([0-9]{1,3})-(days|weeks|months)$`)
+
+// dateRangeOption is an option of the search form date range select. Label is the
+// message key of a preset and empty for other expressions.
+type dateRangeOption struct {
+	Value string
+	Label string
+}
+
+// dateRangeOptions returns the date range select options, with the current range
+// included if it is not a preset.
+func dateRangeOptions(current string) []dateRangeOption {
+	options := []dateRangeOption{{"", "range.custom"}}
+	for _, p := range dateRangePresets {
+		options = append(options, dateRangeOption{p, "range." + p})
+	}
+	if current != "" && !slices.Contains(dateRangePresets, current) {
+		options = append(options, dateRangeOption{current, ""})
+	}
+	return options
+}
+
+// resolveDateRange returns the dates from and to of the date range expression
+// relative to today, with financial years starting on the month and day of fyStart.
+// Both dates are inclusive.
+func resolveDateRange(expr string, today, fyStart time.Time) (time.Time, time.Time, error) {
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	today = day(today.Year(), today.Month(), today.Day())
+
+	thisFY := day(today.Year(), fyStart.Month(), fyStart.Day())
+	if thisFY.After(today) {
+		thisFY = thisFY.AddDate(-1, 0, 0)
+	}
+	thisMonth := day(today.Year(), today.Month(), 1)
+
+	switch expr {
+	case "this-fy":
+		return thisFY, thisFY.AddDate(1, 0, -1), nil
+	case "last-fy":
+		return thisFY.AddDate(-1, 0, 0), thisFY.AddDate(0, 0, -1), nil
+	case "this-month":
+		return thisMonth, thisMonth.AddDate(0, 1, -1), nil
+	}
+
+	m := relativeDateRange.FindStringSubmatch(expr)
+	if m == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown date range %q", expr)
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n < 1 {
+		return time.Time{}, time.Time{}, fmt.Errorf("date range %q is empty", expr)
+	}
+	switch m[2] {
+	case "days":
+		return today.AddDate(0, 0, -n), today, nil
+	case "weeks":
+		return today.AddDate(0, 0, -7*n), today, nil
+	default:
+		return today.AddDate(0, -n, 0), today, nil
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestResolveDateRange(t *testing.T) {
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	fyStart := date(2024, time.April, 1)
+
+	tests := []struct {
+		expr     string
+		today    time.Time
+		dateFrom time.Time
+		dateTo   time.Time
+		isErr    bool
+	}{
+		{"this-fy", date(2025, time.October, 16), date(2025, time.April, 1), date(2026, time.March, 31), false},
+		{"this-fy", date(2026, time.March, 31), date(2025, time.April, 1), date(2026, time.March, 31), false},
+		{"this-fy", date(2026, time.April, 1), date(2026, time.April, 1), date(2027, time.March, 31), false},
+		{"last-fy", date(2025, time.October, 16), date(2024, time.April, 1), date(2025, time.March, 31), false},
+		{"last-90-days", date(2025, time.October, 16), date(2025, time.July, 18), date(2025, time.October, 16), false},
+		{"this-month", date(2024, time.February, 10), date(2024, time.February, 1), date(2024, time.February, 29), false},
+		{"last-2-weeks", date(2025, time.October, 16), date(2025, time.October, 2), date(2025, time.October, 16), false},
+		{"last-6-months", date(2025, time.October, 16), date(2025, time.April, 16), date(2025, time.October, 16), false},
+		{"last-0-days", date(2025, time.October, 16), time.Time{}, time.Time{}, true},
+		{"last-fortnight", date(2025, time.October, 16), time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			dateFrom, dateTo, err := resolveDateRange(tt.expr, tt.today.Add(15*time.Hour), fyStart)
+			if (err != nil) != tt.isErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !dateFrom.Equal(tt.dateFrom) || !dateTo.Equal(tt.dateTo) {
+				t.Errorf("got %s to %s want %s to %s",
+					dateFrom.Format(time.DateOnly), dateTo.Format(time.DateOnly),
+					tt.dateFrom.Format(time.DateOnly), tt.dateTo.Format(time.DateOnly),
+				)
+			}
+		})
+	}
+}
+
+// TestSearchFormDateRange shows a date range preset replacing the dates of a form and
+// being kept in its url, so that pagination and saved searches keep the preset.
+func TestSearchFormDateRange(t *testing.T) {
+
+	today := time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC)
+	form := NewSearchDonationsForm(new(time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)), nil, today)
+	r := newRequest(t, "http://127.0.0.1:8080/donations?range=this-fy&date-from=2020-01-01&date-to=2020-02-01&page=2")
+	if err := form.DecodeURLParams(r.URL.Query()); err != nil {
+		t.Fatal(err)
+	}
+	validator := NewValidator()
+	form.Validate(validator)
+	if !validator.Valid() {
+		t.Fatalf("unexpected validation errors %v", validator.Errors)
+	}
+
+	if form.DateFrom.Month() != time.January || form.DateFrom.Day() != 1 || form.DateFrom.Year() != today.Year() {
+		t.Errorf("unexpected date from %s", form.DateFrom)
+	}
+	if form.DateTo.Month() != time.December || form.DateTo.Day() != 31 {
+		t.Errorf("unexpected date to %s", form.DateTo)
+	}
+	params, err := form.AsURLParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values.Get("range"), "this-fy"; got != want {
+		t.Errorf("range parameter got %q want %q", got, want)
+	}
+
+	if got, want := len(form.DateRanges()), len(dateRangePresets)+1; got != want {
+		t.Errorf("got %d date range options want %d", got, want)
+	}
+	form.Range = "last-30-days"
+	if options := form.DateRanges(); options[len(options)-1] != (dateRangeOption{"last-30-days", ""}) {
+		t.Errorf("expected the current range as the last option, got %v", options)
+	}
+}
@@ -0,0 +1,36 @@
+package web
+
+// debugqueries.go lists the recent slow database queries, if slow query recording is
+// enabled by the database.slow_query_threshold setting, the database connection and
+// write contention statistics and the query cache statistics.
+
+import (
+	"net/http"
+)
+
+// handleDebugQueries shows the recent slow queries, newest first, with their
+// redacted arguments, the connection statistics and the query cache statistics.
+func (web *WebApp) handleDebugQueries() appHandler {
+
+	name := "debug-queries.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"debug-queries.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		queries, threshold := web.reconciler.SlowQueriesGet()
+		data := map[string]any{
+			"PageTitle":   "Slow Queries",
+			"CurrentPage": "debug-queries",
+			"Enabled":     threshold > 0,
+			"Threshold":   threshold,
+			"Queries":     queries,
+			"Pool":        web.reconciler.PoolStatsGet(),
+			"Cache":       web.reconciler.CacheStatsGet(),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
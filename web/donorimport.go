@@ -0,0 +1,215 @@
+package web
+
+// donorimport.go imports donations from a CRM other than Salesforce. An uploaded CSV or
+// XLSX export is held in the session while its columns are mapped to the donation
+// fields, after which its rows are validated and staged for review. The accepted
+// donations are upserted, tagged with the source of the import, once the import is
+// committed from the review page.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/donorimport"
+)
+
+// donorImportMaxUploadSize is the maximum size of an uploaded donations file.
+const donorImportMaxUploadSize = 10 << 20
+
+// donorImportPreviewRows is the number of rows of an upload shown for mapping.
+const donorImportPreviewRows = 5
+
+// donorImportSessionKey is the session key of the pending upload.
+const donorImportSessionKey = "donor-import"
+
+// donorImport is an uploaded file pending import.
+type donorImport struct {
+	Source string
+	Sheet  *donorimport.Sheet
+}
+
+// donorImportGet returns the pending upload held in the session, if any.
+func (web *WebApp) donorImportGet(r *http.Request) (*donorImport, error) {
+	s := web.sessions.GetString(r.Context(), donorImportSessionKey)
+	if s == "" {
+		return nil, nil
+	}
+	var pending donorImport
+	if err := json.Unmarshal([]byte(s), &pending); err != nil {
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// addMappingData adds the preview rows of the pending upload and the column selected
+// for each field by mapping to the page data. The selected columns are strings, being
+// empty for unmapped fields.
+func (pending *donorImport) addMappingData(data map[string]any, mapping donorimport.Mapping) {
+	selected := map[string]string{}
+	for _, f := range donorimport.Fields {
+		if i, ok := mapping[f.Name]; ok {
+			selected[f.Name] = strconv.Itoa(i)
+		}
+	}
+	data["Selected"] = selected
+	data["Preview"] = pending.Sheet.Rows[:min(len(pending.Sheet.Rows), donorImportPreviewRows)]
+}
+
+// handleDonorImport serves the /import/donations page, showing the upload form or, for
+// a pending upload, the column mapping form.
+func (web *WebApp) handleDonorImport() appHandler {
+
+	name := "import-donations.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"import-donations.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		pending, err := web.donorImportGet(r)
+		if err != nil {
+			web.sessions.Remove(ctx, donorImportSessionKey)
+			return errInternal{"failed to read the pending donations import", err}
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Import Donations",
+			"CurrentPage": "import",
+			"Fields":      donorimport.Fields,
+			"Pending":     pending,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		if pending != nil {
+			pending.addMappingData(data, donorimport.Suggest(pending.Sheet.Headers))
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleDonorImportUpload serves the /import/donations/upload endpoint, which reads an
+// uploaded CSV or XLSX file and holds it in the session for mapping.
+func (web *WebApp) handleDonorImportUpload() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/import/donations", http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, donorImportMaxUploadSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The file could not be read: %v", err))
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		source := strings.TrimSpace(strings.ToLower(r.PostFormValue("source")))
+		if err := donorimport.ValidSource(source); err != nil {
+			return redirect(fmt.Sprintf("The source is invalid: %v", err))
+		}
+
+		sheet, err := donorimport.Read(file, header.Filename)
+		if err != nil {
+			return redirect(fmt.Sprintf("The file is invalid: %v", err))
+		}
+		if len(sheet.Rows) == 0 {
+			return redirect("The file contains no donations.")
+		}
+
+		pending, err := json.Marshal(donorImport{Source: source, Sheet: sheet})
+		if err != nil {
+			return errInternal{"failed to hold the donations import", err}
+		}
+		web.sessions.Put(ctx, donorImportSessionKey, string(pending))
+		return redirect(fmt.Sprintf("%d rows were read from %s; map the columns to import the donations.", len(sheet.Rows), sheet.FileName))
+	}
+}
+
+// handleDonorImportMap serves the /import/donations/map endpoint, which stages the
+// pending upload for review using the column mapping of the form. Each field is mapped by the
+// "map-<field name>" value, being the index of its column or empty if unmapped.
+func (web *WebApp) handleDonorImportMap() appHandler {
+
+	name := "import-donations.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"import-donations.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/import/donations", http.StatusSeeOther)
+			return nil
+		}
+
+		pending, err := web.donorImportGet(r)
+		if err != nil || pending == nil {
+			web.sessions.Remove(ctx, donorImportSessionKey)
+			return redirect("There is no file to import; upload the file again.")
+		}
+
+		mapping := donorimport.Mapping{}
+		for _, f := range donorimport.Fields {
+			v := r.PostFormValue("map-" + f.Name)
+			if v == "" {
+				continue
+			}
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return errUsage{fmt.Sprintf("invalid column %q for %s", v, f.Name), http.StatusBadRequest}
+			}
+			mapping[f.Name] = i
+		}
+
+		id, err := web.reconciler.DonationsStage(ctx, pending.Source, pending.Sheet, mapping)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			data := map[string]any{
+				"PageTitle":   "Import Donations",
+				"CurrentPage": "import",
+				"Fields":      donorimport.Fields,
+				"Pending":     pending,
+				"Message":     e.Msg,
+			}
+			pending.addMappingData(data, mapping)
+			return web.render(w, r, templates, name, data)
+		}
+		if err != nil {
+			return err
+		}
+
+		web.sessions.Remove(ctx, donorImportSessionKey)
+		web.sessions.Put(ctx, "message", fmt.Sprintf("The donations of %s were staged; review the rows and commit the accepted donations to import them.", pending.Sheet.FileName))
+		http.Redirect(w, r, fmt.Sprintf("/imports/%d", id), http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handleDonorImportCancel serves the /import/donations/cancel endpoint, which discards
+// the pending upload.
+func (web *WebApp) handleDonorImportCancel() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		web.sessions.Remove(r.Context(), donorImportSessionKey)
+		http.Redirect(w, r, "/import/donations", http.StatusSeeOther)
+		return nil
+	}
+}
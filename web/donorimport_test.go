@@ -0,0 +1,107 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestDonorImport tests uploading a donations file, mapping its columns and staging it
+// for review, carrying the session between the requests.
+func TestDonorImport(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cookies []*http.Cookie
+	serve := func(h appHandler, req *http.Request) *httptest.ResponseRecorder {
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(h)).ServeHTTP(rec, req)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return rec
+	}
+	mapForm := func(dateColumn string) *http.Request {
+		form := url.Values{"map-ID": {"0"}, "map-Name": {"1"}, "map-Amount": {"2"}, "map-CloseDate": {dateColumn}}
+		req := httptest.NewRequest("POST", "/import/donations/map", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("source", "Beacon")
+	fw, err := mw.CreateFormFile("file", "export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write([]byte("Gift ID,Donor,Amount,Gift Date\n1001,Jane Smith,20.00,2025-04-14\n1002,John Smith,5.00,2025-04-15\n"))
+	_ = mw.Close()
+	req := httptest.NewRequest("POST", "/import/donations/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	if got, want := serve(webApp.handleDonorImportUpload(), req).Code, http.StatusSeeOther; got != want {
+		t.Fatalf("upload status got %d want %d", got, want)
+	}
+
+	rec := serve(webApp.handleDonorImport(), httptest.NewRequest("GET", "/import/donations", nil))
+	for _, s := range []string{"2 rows were read from export.csv", `name="map-CloseDate"`, "Jane Smith", `<option value="2" selected>Amount</option>`} {
+		if !strings.Contains(rec.Body.String(), s) {
+			t.Errorf("mapping page does not contain %q", s)
+		}
+	}
+
+	// A mapping without the date fails, showing the mapping form again.
+	rec = serve(webApp.handleDonorImportMap(), mapForm(""))
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("invalid mapping status got %d want %d", got, want)
+	}
+	if !strings.Contains(rec.Body.String(), "date field must be mapped") {
+		t.Error("invalid mapping page does not report the error")
+	}
+
+	rec = serve(webApp.handleDonorImportMap(), mapForm("3"))
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("stage status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/imports/2"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+	if got, want := mock.donationsStage, 2; got != want {
+		t.Errorf("got %d stage calls want %d", got, want)
+	}
+
+	// The pending upload is discarded once staged.
+	rec = serve(webApp.handleDonorImport(), httptest.NewRequest("GET", "/import/donations", nil))
+	if !strings.Contains(rec.Body.String(), `action="/import/donations/upload"`) {
+		t.Error("upload form not shown after staging")
+	}
+}
@@ -1,49 +1,114 @@
 package web
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/rorycl/reconciler/db"
 	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/apistatus"
 	"github.com/rorycl/reconciler/internal/token"
 )
 
 // ErrorChecker wraps appHandler (handlers that return an error). This allows error
 // reporting to be centralised, and the appHandlers to be simplified by avoiding error
-// handling boilerplate.
+// handling boilerplate. Errors are reported by writeError.
 func (web *WebApp) ErrorChecker(h appHandler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := h(w, r)
 		if err != nil {
+			// The request timed out or the browser disconnected, so the error is most
+			// likely the cancellation of a query or api call.
+			if ctxErr := r.Context().Err(); ctxErr != nil {
+				if errors.Is(ctxErr, context.DeadlineExceeded) {
+					web.writeError(w, r, err, http.StatusGatewayTimeout, "the request took too long to complete", slog.LevelWarn, "timeout", web.cfg.Web.RequestTimeout)
+					return
+				}
+				web.log.Info(err.Error(), "cancelled", true, "method", r.Method, "uri", r.URL.RequestURI())
+				return
+			}
 			// OAuth2 web flow error.
 			if e, isErr := errors.AsType[token.ErrTokenWebClient](err); isErr {
-				web.log.Info(err.Error(), "context", e.Context, "method", r.Method, "uri", r.URL.RequestURI())
-				http.Error(w, e.Msg, http.StatusBadRequest) // not sure about best error type
+				web.writeError(w, r, err, http.StatusBadRequest, e.Msg, slog.LevelInfo, "context", e.Context) // not sure about best error type
+				return
+			}
+			// Xero or Salesforce refused the call as an api rate limit was exceeded.
+			if e, isErr := errors.AsType[apistatus.ErrRateLimited](err); isErr {
+				msg := fmt.Sprintf("the %s api rate limit was exceeded, please try again later", e.API)
+				if e.RetryAfter > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Round(time.Second).Seconds())))
+					msg = fmt.Sprintf("the %s api rate limit was exceeded, please try again in %s", e.API, e.RetryAfter.Round(time.Second))
+				}
+				web.writeError(w, r, err, http.StatusTooManyRequests, msg, slog.LevelWarn)
+				return
+			}
+			// A requested record does not exist.
+			if e, isErr := errors.AsType[domain.ErrNotFound](err); isErr {
+				web.writeError(w, r, err, http.StatusNotFound, e.Msg, slog.LevelInfo, "detail", e.Detail)
+				return
+			}
+			if e, isErr := errors.AsType[db.ErrNotFound](err); isErr {
+				web.writeError(w, r, err, http.StatusNotFound, fmt.Sprintf("the requested %s was not found", e.Kind), slog.LevelInfo)
+				return
+			}
+			// Records changed in Xero or Salesforce since the last refresh, which a link
+			// would overwrite. The error page lists the changes.
+			if e, isErr := errors.AsType[domain.ErrConflict](err); isErr {
+				web.writeError(w, r, err, http.StatusConflict, conflictMessage(e), slog.LevelWarn)
+				return
+			}
+			// Salesforce could not be reached, and the updates of a link were queued to
+			// be retried. The local changes were made, so this is not reported as an
+			// error.
+			if e, isErr := errors.AsType[domain.ErrQueued](err); isErr {
+				web.log.Warn(err.Error(), "pending_action", e.ID, "method", r.Method, "uri", r.URL.RequestURI())
+				web.writeQueued(w, r, e)
+				return
+			}
+			// Xero or Salesforce reported an error, reported with the message of a
+			// wrapping domain system error if there is one.
+			if e, isErr := errors.AsType[apistatus.ErrRemoteAPI](err); isErr {
+				msg := fmt.Sprintf("the %s api reported an error: %s", e.API, e.Message)
+				if se, isSys := errors.AsType[domain.ErrSystem](err); isSys {
+					msg = se.Msg
+				}
+				web.writeError(w, r, err, http.StatusBadGateway, msg, slog.LevelError, "api", e.API, "api_status", e.StatusCode)
+				return
+			}
+			// Invalid parameters rejected by the database layer.
+			if e, isErr := errors.AsType[db.ErrValidation](err); isErr {
+				web.writeError(w, r, err, http.StatusBadRequest, e.Error(), slog.LevelInfo)
+				return
+			}
+			// Records in a locked period.
+			if e, isErr := errors.AsType[domain.ErrLocked](err); isErr {
+				web.writeError(w, r, err, http.StatusLocked, e.Msg, slog.LevelInfo)
 				return
 			}
 			// Domain system error.
 			if e, isErr := errors.AsType[domain.ErrSystem](err); isErr {
-				web.log.Error(err.Error(), "detail", e.Detail, "method", r.Method, "uri", r.URL.RequestURI())
-				http.Error(w, e.Msg, http.StatusInternalServerError)
+				web.writeError(w, r, err, http.StatusInternalServerError, e.Msg, slog.LevelError, "detail", e.Detail)
 				return
 			}
 			// Domain usage error.
 			if e, isErr := errors.AsType[domain.ErrUsage](err); isErr {
-				web.log.Info(err.Error(), "detail", e.Detail, "method", r.Method, "uri", r.URL.RequestURI())
-				http.Error(w, e.Msg, http.StatusBadRequest)
+				web.writeError(w, r, err, http.StatusBadRequest, e.Msg, slog.LevelInfo, "detail", e.Detail)
 				return
 			}
 			// Web internal error.
 			if e, isErr := errors.AsType[errInternal](err); isErr {
-				web.log.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
-				http.Error(w, e.msg, http.StatusInternalServerError)
+				web.writeError(w, r, err, http.StatusInternalServerError, e.msg, slog.LevelError)
 				return
 			}
 			// Web usage error.
 			if e, isErr := errors.AsType[errUsage](err); isErr {
-				web.log.Info(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
-				http.Error(w, e.msg, e.status)
+				web.writeError(w, r, err, e.status, e.msg, slog.LevelInfo)
 				return
 			}
 			// Web htmx client error.
@@ -59,10 +124,23 @@ func (web *WebApp) ErrorChecker(h appHandler) http.Handler {
 				return
 			}
 			// Fall through error.
-			web.log.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
-			http.Error(w, "an unknown error occurred", http.StatusInternalServerError)
+			web.writeError(w, r, err, http.StatusInternalServerError, "an unknown error occurred", slog.LevelError)
 			return
 
 		}
 	})
 }
+
+// conflictMessage returns the user facing message of a conflict, naming the changed
+// records.
+func conflictMessage(e domain.ErrConflict) string {
+	records := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		name := c.Name
+		if name == "" {
+			name = c.ID
+		}
+		records[i] = c.RecordType + " " + name
+	}
+	return fmt.Sprintf("Nothing was changed, as these records were changed in Xero or Salesforce since the last refresh: %s. Refresh the data and try again.", strings.Join(records, ", "))
+}
@@ -0,0 +1,151 @@
+package web
+
+// errorpage.go renders the errors reported by ErrorChecker. Browser requests are shown
+// an error page, requests accepting json are sent an RFC 7807 problem+json document and
+// htmx requests are sent plain text. Each error is reported with a correlation ID, being
+// the request id, which is shown to the user and logged with the error so that a
+// reported problem can be found in the logs.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/requestid"
+)
+
+// problem is an RFC 7807 problem details document, extended with the correlation ID.
+type problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	CorrelationID string `json:"correlation_id"`
+}
+
+// correlationID returns the id identifying an error of the request in the logs, being
+// the request id or, for a request without one, a new id.
+func correlationID(r *http.Request) string {
+	if id := requestid.FromContext(r.Context()); id != "" {
+		return id
+	}
+	return requestid.New()
+}
+
+// errorChain returns the types of err and the errors it wraps, outermost first.
+func errorChain(err error) []string {
+	var chain []string
+	for err != nil {
+		chain = append(chain, fmt.Sprintf("%T", err))
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, ee := range e.Unwrap() {
+				chain = append(chain, errorChain(ee)...)
+			}
+			err = nil
+		default:
+			err = nil
+		}
+	}
+	return chain
+}
+
+// wantsProblemJSON reports if the request accepts json rather than html, as an api
+// client would.
+func wantsProblemJSON(r *http.Request) bool {
+	wantsJSON := false
+	for part := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/html":
+			return false
+		case "application/json", "application/problem+json":
+			wantsJSON = true
+		}
+	}
+	return wantsJSON
+}
+
+// writeError logs err with the correlation ID at the given level, together with the
+// chain of errors it wraps, and responds with the status and user facing msg in the
+// form suited to the request.
+func (web *WebApp) writeError(w http.ResponseWriter, r *http.Request, err error, status int, msg string, level slog.Level, attrs ...any) {
+
+	id := correlationID(r)
+	attrs = append(attrs,
+		"correlation_id", id,
+		"status", status,
+		"chain", errorChain(err),
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+	)
+	web.log.Log(r.Context(), level, err.Error(), attrs...)
+
+	switch {
+	case wantsProblemJSON(r):
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:          "about:blank",
+			Title:         http.StatusText(status),
+			Status:        status,
+			Detail:        msg,
+			Instance:      r.URL.Path,
+			CorrelationID: id,
+		})
+	case r.Header.Get("HX-Request") == "true":
+		http.Error(w, fmt.Sprintf("%s (reference %s)", msg, id), status)
+	default:
+		if err := web.writeErrorPage(w, r, err, status, msg, id); err != nil {
+			web.log.Error(fmt.Sprintf("error page render error: %v", err), "correlation_id", id)
+			http.Error(w, fmt.Sprintf("%s (reference %s)", msg, id), status)
+		}
+	}
+}
+
+// writeErrorPage renders the error page, listing the changed records if cause is a
+// conflict. Nothing is written if the page cannot be rendered, such as for a WebApp not
+// made by New.
+func (web *WebApp) writeErrorPage(w http.ResponseWriter, r *http.Request, cause error, status int, msg, id string) error {
+	if web.errorTemplates == nil {
+		return errors.New("no error page template")
+	}
+	tpl, err := web.errorTemplates.Clone()
+	if err != nil {
+		return err
+	}
+	tpl.Funcs(web.requestTemplateFuncs(r.Context()))
+
+	data := struct {
+		Status        int
+		StatusText    string
+		Message       string
+		CorrelationID string
+		Conflicts     []domain.Conflict
+	}{status, http.StatusText(status), msg, id, nil}
+	if e, ok := errors.AsType[domain.ErrConflict](cause); ok {
+		data.Conflicts = e.Conflicts
+	}
+
+	buf := new(bytes.Buffer)
+	if err := tpl.ExecuteTemplate(buf, "error.html", data); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+	return nil
+}
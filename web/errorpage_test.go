@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestWriteError tests that errors are reported as an error page, problem+json or plain
+// text according to the request, with a correlation ID matching the logged error.
+func TestWriteError(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := func(w http.ResponseWriter, r *http.Request) error {
+		return domain.ErrNotFound{Detail: "db.InvoiceWRGet not found error", Msg: "The requested invoice was not found"}
+	}
+	handler := webApp.sessions.LoadAndSave(webApp.ErrorChecker(failing))
+	idRegexp := regexp.MustCompile(`correlation_id=([0-9a-f]{16})`)
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		contentType string
+		body        []string
+	}{
+		{
+			name:        "browser",
+			headers:     map[string]string{"Accept": "text/html,application/xhtml+xml,*/*;q=0.8"},
+			contentType: "text/html; charset=utf-8",
+			body:        []string{"<html", "The requested invoice was not found", `id="correlation-id"`},
+		},
+		{
+			name:        "htmx",
+			headers:     map[string]string{"HX-Request": "true"},
+			contentType: "text/plain; charset=utf-8",
+			body:        []string{"The requested invoice was not found (reference "},
+		},
+		{
+			name:        "api",
+			headers:     map[string]string{"Accept": "application/json"},
+			contentType: "application/problem+json",
+			body:        []string{`"status":404`, `"title":"Not Found"`, `"instance":"/invoice/inv-x"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", "/invoice/inv-x", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusNotFound; got != want {
+				t.Fatalf("status got %d want %d", got, want)
+			}
+			if got, want := rec.Header().Get("Content-Type"), tt.contentType; got != want {
+				t.Errorf("content type got %q want %q", got, want)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.body {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q:\n%s", s, body)
+				}
+			}
+			m := idRegexp.FindStringSubmatch(logs.String())
+			if m == nil {
+				t.Fatalf("no correlation id logged:\n%s", logs.String())
+			}
+			if !strings.Contains(body, m[1]) {
+				t.Errorf("body does not contain the logged correlation id %s", m[1])
+			}
+			if !strings.Contains(logs.String(), "chain=[domain.ErrNotFound]") {
+				t.Errorf("error chain not logged:\n%s", logs.String())
+			}
+		})
+	}
+
+	// The problem document decodes to the RFC 7807 members.
+	req := httptest.NewRequest("GET", "/invoice/inv-x", nil)
+	req.Header.Set("Accept", "application/problem+json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != "about:blank" || p.Detail != "The requested invoice was not found" || p.CorrelationID == "" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
+
+// TestErrorChain tests listing the types of a chain of wrapped errors.
+func TestErrorChain(t *testing.T) {
+	err := domain.ErrSystem{Detail: "d", Err: errors.Join(errInternal{"m", errors.New("x")}), Msg: "m"}
+	got := strings.Join(errorChain(err), " ")
+	want := "domain.ErrSystem *errors.joinError web.errInternal"
+	if got != want {
+		t.Errorf("chain got %q want %q", got, want)
+	}
+}
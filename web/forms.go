@@ -1,12 +1,13 @@
 package web
 
 import (
+	"cmp"
 	"fmt"
-	"log"
 	"reflect"
 	"time"
 
 	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
 
 	"github.com/google/go-querystring/query"
 	"github.com/gorilla/schema"
@@ -75,16 +76,43 @@ func validMuxVars(vars map[string]string, keys ...string) (map[string]string, er
 // Forms
 // ------------------------------------------------------------------------------
 
+// sortDirectionToggle returns "desc" if sort is by column in ascending order, the
+// default direction, and otherwise "asc".
+func sortDirectionToggle(sort db.SortOrder, column string) string {
+	current := cmp.Or(sort.Column, db.SortColumns[0])
+	if current == column && sort.Direction != "desc" {
+		return "desc"
+	}
+	return "asc"
+}
+
+// sortIndicator returns an up or down arrow if sort is by column.
+func sortIndicator(sort db.SortOrder, column string) string {
+	if cmp.Or(sort.Column, db.SortColumns[0]) != column {
+		return ""
+	}
+	if sort.Direction == "desc" {
+		return "▼"
+	}
+	return "▲"
+}
+
 // SearchForm represents the URL query parameter filters (invoices, bank
 // transactions, and so on.)
 type SearchForm struct {
 	ReconciliationStatus string    `schema:"status" url:"status"`
+	Range                string    `schema:"range" url:"range,omitempty"` // a date range preset
 	DateFrom             time.Time `schema:"date-from" url:"date-from" layout:"2006-01-02"`
 	DateTo               time.Time `schema:"date-to" url:"date-to" layout:"2006-01-02"`
 	SearchString         string    `schema:"search" url:"search"`
+	Mine                 bool      `schema:"mine" url:"mine,omitempty"` // assigned to the user
+	Sort                 string    `schema:"sort" url:"sort,omitempty"`
+	Direction            string    `schema:"dir" url:"dir,omitempty"`
 	Page                 int       `schema:"page" url:"page"`
 	Refresh              bool      `schema:"refresh" url:"-"`
 	Reset                bool      `schema:"reset" url:"-"`
+	fyStart              time.Time // the start of a financial year, for the Range
+	today                time.Time // today's date, for the Range
 }
 
 // AsURLParams encodes a SearchForm as parameters for after the "?" in a url
@@ -96,11 +124,11 @@ func (s *SearchForm) AsURLParams() (string, error) {
 	return v.Encode(), nil
 }
 
-// defaultDateToAndFrom sets the default dateFrom and dateTo dates.
+// defaultDateToAndFrom sets the default dateFrom and dateTo dates, relative to the year
+// of today.
 // Todo: set this from settings.
-func defaultDateToAndFrom(s, e *time.Time) (time.Time, time.Time) {
-	now := time.Now().UTC()
-	year := now.Year()
+func defaultDateToAndFrom(s, e *time.Time, today time.Time) (time.Time, time.Time) {
+	year := today.Year()
 	var df, dt time.Time
 	if s == nil {
 		df = time.Date(year-1, time.April, 1, 0, 0, 0, 0, time.UTC)
@@ -115,15 +143,19 @@ func defaultDateToAndFrom(s, e *time.Time) (time.Time, time.Time) {
 	return df, dt
 }
 
-// NewSearchForm creates a SearchForm with defaults.
-func NewSearchForm(startDate, endDate *time.Time) *SearchForm {
-	dateFrom, dateTo := defaultDateToAndFrom(startDate, endDate)
+// NewSearchForm creates a SearchForm with defaults. The financial years of the date
+// range presets start on the month and day of the default start date, and the presets
+// are resolved relative to today, which is the date in the organisation timezone.
+func NewSearchForm(startDate, endDate *time.Time, today time.Time) *SearchForm {
+	dateFrom, dateTo := defaultDateToAndFrom(startDate, endDate, today)
 	return &SearchForm{
 		ReconciliationStatus: "NotReconciled",
 		DateFrom:             dateFrom,
 		DateTo:               dateTo,
 		Page:                 1, // 1-based pagination.
 		Refresh:              false,
+		fyStart:              dateFrom,
+		today:                today,
 	}
 }
 
@@ -136,17 +168,59 @@ func (f *SearchForm) Validate(v *Validator) {
 	allowedStatus := map[string]bool{"All": true, "Reconciled": true, "NotReconciled": true}
 	v.Check(allowedStatus[f.ReconciliationStatus], "status", "Invalid status value provided.")
 
+	// A date range preset replaces the dates.
+	if f.Range != "" {
+		dateFrom, dateTo, err := resolveDateRange(f.Range, f.today, f.fyStart)
+		v.Check(err == nil, "range", "Invalid date range provided.")
+		if err == nil {
+			f.DateFrom, f.DateTo = dateFrom, dateTo
+		}
+	}
+
 	v.Check(!f.DateTo.Before(f.DateFrom), "date-to", "End date cannot be before the start date.")
 	v.Check(!f.DateFrom.IsZero(), "date-from", "From date must be provided.")
+	v.Check(f.SortOrder().Validate() == nil, "sort", "Invalid sort order provided.")
 
 	if f.Page < 1 {
 		f.Page = 1
 	}
 }
 
-// Offset calculates the database offset for (1-based) pagination.
-func (f *SearchForm) Offset(pageLen int) int {
-	return (f.Page - 1) * pageLen
+// SortOrder returns the sort column and direction of the form.
+func (f *SearchForm) SortOrder() db.SortOrder {
+	return db.SortOrder{Column: f.Sort, Direction: f.Direction}
+}
+
+// SortURL returns the url parameters, after the "?", which sort the results by
+// column from the first page, reversing the direction if the results are already
+// sorted by column.
+func (f *SearchForm) SortURL(column string) (string, error) {
+	sf := *f
+	sf.Sort, sf.Direction, sf.Page = column, sortDirectionToggle(f.SortOrder(), column), 1
+	return sf.AsURLParams()
+}
+
+// ExportURL returns the url of the line item export at path of the records found by
+// the form, in format.
+func (f *SearchForm) ExportURL(path, format string) (string, error) {
+	sf := *f
+	sf.Page = 1
+	params, err := sf.AsURLParams()
+	if err != nil {
+		return "", err
+	}
+	return path + "?format=" + format + "&" + params, nil
+}
+
+// DateRanges returns the options of the date range select.
+func (f *SearchForm) DateRanges() []dateRangeOption {
+	return dateRangeOptions(f.Range)
+}
+
+// SortIndicator returns an arrow showing the sort direction if the results are sorted
+// by column.
+func (f *SearchForm) SortIndicator(column string) string {
+	return sortIndicator(f.SortOrder(), column)
 }
 
 // DecodeURLParams decodes a url query into a form.
@@ -164,13 +238,19 @@ func (f *SearchForm) DecodeURLParams(urlQuery map[string][]string) error {
 // donations.
 type SearchDonationsForm struct {
 	LinkageStatus   string    `schema:"status" url:"status"`
+	Range           string    `schema:"range" url:"range,omitempty"` // a date range preset
 	DateFrom        time.Time `schema:"date-from" url:"date-from" layout:"2006-01-02"`
 	DateTo          time.Time `schema:"date-to" url:"date-to" layout:"2006-01-02"`
 	PayoutReference string    `schema:"payout-reference" url:"payout-reference"`
 	SearchString    string    `schema:"search" url:"search"`
+	Mine            bool      `schema:"mine" url:"mine,omitempty"` // assigned to the user
+	Sort            string    `schema:"sort" url:"sort,omitempty"`
+	Direction       string    `schema:"dir" url:"dir,omitempty"`
 	Page            int       `schema:"page" url:"page"`
 	Refresh         bool      `schema:"refresh" url:"-"`
 	Reset           bool      `schema:"reset" url:"-"`
+	fyStart         time.Time // the start of a financial year, for the Range
+	today           time.Time // today's date, for the Range
 }
 
 // AsURLParams encodes a SearchForm as parameters for after the "?" in a url
@@ -182,15 +262,19 @@ func (s *SearchDonationsForm) AsURLParams() (string, error) {
 	return v.Encode(), nil
 }
 
-// NewSearchDonationsForm creates a SearchDonationsForm with defaults.
-func NewSearchDonationsForm(startDate, endDate *time.Time) *SearchDonationsForm {
-	dateFrom, dateTo := defaultDateToAndFrom(startDate, endDate)
+// NewSearchDonationsForm creates a SearchDonationsForm with defaults. The financial
+// years of the date range presets start on the month and day of the default start
+// date, and the presets are resolved relative to today.
+func NewSearchDonationsForm(startDate, endDate *time.Time, today time.Time) *SearchDonationsForm {
+	dateFrom, dateTo := defaultDateToAndFrom(startDate, endDate, today)
 	return &SearchDonationsForm{
 		LinkageStatus: "NotLinked",
 		DateFrom:      dateFrom,
 		DateTo:        dateTo,
 		Page:          1, // 1-based pagination.
 		Refresh:       false,
+		fyStart:       dateFrom,
+		today:         today,
 	}
 }
 
@@ -203,17 +287,47 @@ func (f *SearchDonationsForm) Validate(v *Validator) {
 	allowedStatus := map[string]bool{"All": true, "Linked": true, "NotLinked": true}
 	v.Check(allowedStatus[f.LinkageStatus], "status", "Invalid status value provided.")
 
+	// A date range preset replaces the dates.
+	if f.Range != "" {
+		dateFrom, dateTo, err := resolveDateRange(f.Range, f.today, f.fyStart)
+		v.Check(err == nil, "range", "Invalid date range provided.")
+		if err == nil {
+			f.DateFrom, f.DateTo = dateFrom, dateTo
+		}
+	}
+
 	v.Check(!f.DateFrom.IsZero(), "date-from", "From date must be provided.")
 	v.Check(!f.DateTo.Before(f.DateFrom), "date-to", "End date cannot be before the start date.")
+	v.Check(f.SortOrder().Validate() == nil, "sort", "Invalid sort order provided.")
 
 	if f.Page < 1 {
 		f.Page = 1
 	}
 }
 
-// Offset calculates the database offset for (1-based) pagination.
-func (f *SearchDonationsForm) Offset(pageLen int) int {
-	return (f.Page - 1) * pageLen
+// SortOrder returns the sort column and direction of the form.
+func (f *SearchDonationsForm) SortOrder() db.SortOrder {
+	return db.SortOrder{Column: f.Sort, Direction: f.Direction}
+}
+
+// SortURL returns the url parameters, after the "?", which sort the results by
+// column from the first page, reversing the direction if the results are already
+// sorted by column.
+func (f *SearchDonationsForm) SortURL(column string) (string, error) {
+	sf := *f
+	sf.Sort, sf.Direction, sf.Page = column, sortDirectionToggle(f.SortOrder(), column), 1
+	return sf.AsURLParams()
+}
+
+// DateRanges returns the options of the date range select.
+func (f *SearchDonationsForm) DateRanges() []dateRangeOption {
+	return dateRangeOptions(f.Range)
+}
+
+// SortIndicator returns an arrow showing the sort direction if the results are sorted
+// by column.
+func (f *SearchDonationsForm) SortIndicator(column string) string {
+	return sortIndicator(f.SortOrder(), column)
 }
 
 // DecodeURLParams decodes a url query into the form.
@@ -234,6 +348,11 @@ type LinkOrUnlinkForm struct {
 	ID          string   `schema:"id"`     // the invoice id or bank-transaction reference
 	Action      string   `schema:"action"` // "link" or "unlink"
 	DonationIDs []string `schema:"donation-ids"`
+	// UpdateReference requests the payout reference be written to the Xero invoice
+	// when linking donations to an invoice.
+	UpdateReference bool `schema:"update-reference"`
+	// OverrideReason overrides the locks of closed periods for a correction.
+	OverrideReason string `schema:"override-reason"`
 }
 
 // AsSalesforceIDRefs expands a form into a slice of salesforce.IDRef suitable for
@@ -274,7 +393,7 @@ func CheckLinkOrUnlinkForm(postData map[string][]string, routeVars map[string]st
 // Validate valides the link or unlink form.
 func (f *LinkOrUnlinkForm) Validate(v *Validator) {
 	if f == nil {
-		log.Println("no LinkOrUnlinkForm received")
+		v.AddError("form", "No link or unlink form was received.")
 		return
 	}
 
@@ -295,6 +414,81 @@ func (f *LinkOrUnlinkForm) Validate(v *Validator) {
 	}
 	v.Check(err == nil, "donation-ids", errStr)
 
+	v.Check(!f.UpdateReference || (f.Typer == "invoice" && f.Action == "link"), "update-reference", "Only invoice references may be updated, when linking.")
+}
+
+// ReportPeriodForm represents the URL query parameters for a period report. The
+// Format is the export format for reports with more than one format.
+type ReportPeriodForm struct {
+	DateFrom time.Time `schema:"date-from" url:"date-from" layout:"2006-01-02"`
+	DateTo   time.Time `schema:"date-to" url:"date-to" layout:"2006-01-02"`
+	Format   string    `schema:"format" url:"format,omitempty"`
+}
+
+// NewReportPeriodForm creates a ReportPeriodForm for the period from startDate to
+// today.
+func NewReportPeriodForm(startDate, today time.Time) *ReportPeriodForm {
+	return &ReportPeriodForm{
+		DateFrom: startDate,
+		DateTo:   today,
+	}
+}
+
+// Validate checks ReportPeriodForm fields and populates Validator with any errors.
+func (f *ReportPeriodForm) Validate(v *Validator) {
+	v.Check(!f.DateFrom.IsZero(), "date-from", "From date must be provided.")
+	v.Check(!f.DateTo.IsZero(), "date-to", "To date must be provided.")
+	v.Check(!f.DateTo.Before(f.DateFrom), "date-to", "End date cannot be before the start date.")
+}
+
+// AsURLParams encodes a ReportPeriodForm as parameters for after the "?" in a url.
+func (f *ReportPeriodForm) AsURLParams() (string, error) {
+	v, err := query.Values(f)
+	if err != nil {
+		return "", err // unlikely
+	}
+	return v.Encode(), nil
+}
+
+// DecodeURLParams decodes a url query into the form.
+func (f *ReportPeriodForm) DecodeURLParams(urlQuery map[string][]string) error {
+	return decodeURLParams(urlQuery, f)
+}
+
+// PayoutGroupsForm represents the URL query parameters for the payout references and
+// bank statement reports. Only the references or statement lines which are not matched
+// are shown if Unmatched is set.
+type PayoutGroupsForm struct {
+	DateFrom  time.Time `schema:"date-from" url:"date-from" layout:"2006-01-02"`
+	DateTo    time.Time `schema:"date-to" url:"date-to" layout:"2006-01-02"`
+	Unmatched bool      `schema:"unmatched" url:"unmatched,omitempty"`
+}
+
+// NewPayoutGroupsForm creates a PayoutGroupsForm for the unmatched references of the
+// period from startDate to today.
+func NewPayoutGroupsForm(startDate, today time.Time) *PayoutGroupsForm {
+	period := NewReportPeriodForm(startDate, today)
+	return &PayoutGroupsForm{
+		DateFrom:  period.DateFrom,
+		DateTo:    period.DateTo,
+		Unmatched: true,
+	}
+}
+
+// Validate checks PayoutGroupsForm fields and populates Validator with any errors.
+func (f *PayoutGroupsForm) Validate(v *Validator) {
+	v.Check(!f.DateFrom.IsZero(), "date-from", "From date must be provided.")
+	v.Check(!f.DateTo.IsZero(), "date-to", "To date must be provided.")
+	v.Check(!f.DateTo.Before(f.DateFrom), "date-to", "End date cannot be before the start date.")
+}
+
+// DecodeURLParams decodes a url query into the form. A query with dates but without
+// unmatched shows all the references, as an unchecked checkbox is not submitted.
+func (f *PayoutGroupsForm) DecodeURLParams(urlQuery map[string][]string) error {
+	if _, ok := urlQuery["date-from"]; ok {
+		f.Unmatched = false
+	}
+	return decodeURLParams(urlQuery, f)
 }
 
 // ------------------------------------------------------------------------------
@@ -302,7 +496,7 @@ func (f *LinkOrUnlinkForm) Validate(v *Validator) {
 // ------------------------------------------------------------------------------
 
 // newSchemaDecoder creates a new schema.Decoder instance and registers
-// a custom converter for the time.Time type.
+// a custom converter for the time.Time type. Dates are parsed as UTC dates.
 func newSchemaDecoder() *schema.Decoder {
 	decoder := schema.NewDecoder()
 
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/rorycl/reconciler/internal/paginate"
 )
 
 func newRequest(t *testing.T, urlString string) *http.Request {
@@ -26,6 +28,7 @@ func TestSearchForm(t *testing.T) {
 	defaultDateFrom, defaultDateTo := defaultDateToAndFrom(
 		new(time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)),
 		nil,
+		time.Now().UTC(),
 	)
 
 	tests := []struct {
@@ -184,6 +187,25 @@ func TestSearchForm(t *testing.T) {
 			fieldNameForError: "ReconciliationStatus",
 			fieldNameIsError:  false,
 		},
+		{
+			name:     "invalid range",
+			inputURL: "http://127.0.0.1:8080/invoices/?range=next-fy&date-from=2025-06-01&date-to=2025-07-01",
+			searchForm: &SearchForm{
+				ReconciliationStatus: "NotReconciled",
+				Range:                "next-fy",
+				DateFrom:             time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC),
+				DateTo:               time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC),
+				Page:                 1, // 1-based pagination.
+			},
+			err: nil,
+			validationErrs: &Validator{
+				Errors: map[string]string{
+					"range": "Invalid date range provided.",
+				},
+			},
+			fieldNameForError: "range",
+			fieldNameIsError:  true,
+		},
 	}
 
 	for ii, tt := range tests {
@@ -191,7 +213,7 @@ func TestSearchForm(t *testing.T) {
 
 			simulatedRequest := newRequest(t, tt.inputURL)
 
-			form := NewSearchForm(new(defaultDateFrom), new(defaultDateTo))
+			form := NewSearchForm(new(defaultDateFrom), new(defaultDateTo), time.Now().UTC())
 			if err := form.DecodeURLParams(simulatedRequest.URL.Query()); err != nil {
 				if tt.err != err {
 					t.Fatalf("unexpected error: %v", err)
@@ -202,7 +224,7 @@ func TestSearchForm(t *testing.T) {
 			validator := NewValidator()
 			form.Validate(validator)
 
-			if diff := cmp.Diff(form, tt.searchForm); diff != "" {
+			if diff := cmp.Diff(form, tt.searchForm, cmpopts.IgnoreUnexported(SearchForm{})); diff != "" {
 				t.Errorf("unexpected searchform diff %s", diff)
 			}
 
@@ -217,7 +239,7 @@ func TestSearchForm(t *testing.T) {
 			}
 
 			if tt.offSetPageLen > 0 {
-				if got, want := form.Offset(tt.offSetPageLen), tt.offSetResult; got != want {
+				if got, want := paginate.Offset(form.Page, tt.offSetPageLen), tt.offSetResult; got != want {
 					t.Errorf("offset got %d want %d", got, want)
 				}
 			}
@@ -268,6 +290,45 @@ func TestAsURLParams(t *testing.T) {
 	}
 }
 
+// TestSortURL tests the sort links toggle the direction of the current sort column.
+func TestSortURL(t *testing.T) {
+
+	sf := &SearchForm{
+		ReconciliationStatus: "All",
+		DateFrom:             time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		DateTo:               time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		Page:                 3,
+	}
+
+	tests := []struct {
+		sort, direction string
+		column          string
+		want            string
+		indicator       string
+	}{
+		{"", "", "date", "date-from=2025-06-01&date-to=2025-07-01&dir=desc&page=1&search=&sort=date&status=All", "▲"},
+		{"", "", "amount", "date-from=2025-06-01&date-to=2025-07-01&dir=asc&page=1&search=&sort=amount&status=All", ""},
+		{"amount", "asc", "amount", "date-from=2025-06-01&date-to=2025-07-01&dir=desc&page=1&search=&sort=amount&status=All", "▲"},
+		{"amount", "desc", "amount", "date-from=2025-06-01&date-to=2025-07-01&dir=asc&page=1&search=&sort=amount&status=All", "▼"},
+		{"amount", "desc", "contact", "date-from=2025-06-01&date-to=2025-07-01&dir=asc&page=1&search=&sort=contact&status=All", ""},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("test_%d", i), func(t *testing.T) {
+			sf.Sort, sf.Direction = tt.sort, tt.direction
+			got, err := sf.SortURL(tt.column)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s want %s", got, tt.want)
+			}
+			if got, want := sf.SortIndicator(tt.column), tt.indicator; got != want {
+				t.Errorf("indicator got %q want %q", got, want)
+			}
+		})
+	}
+}
+
 // TestValidMuxVars tests the validMuxVars function.
 func TestValidMuxVars(t *testing.T) {
 
@@ -477,7 +538,7 @@ func TestSearchDonationsForm(t *testing.T) {
 	for ii, tt := range tests {
 		t.Run(fmt.Sprintf("%d_%s", ii, tt.name), func(t *testing.T) {
 
-			form := NewSearchDonationsForm(tt.dateFrom, tt.dateTo)
+			form := NewSearchDonationsForm(tt.dateFrom, tt.dateTo, time.Now().UTC())
 			defaultURL, err := form.AsURLParams()
 			if err != nil {
 				t.Fatal(err) // should never happen on default
@@ -514,10 +575,46 @@ func TestSearchDonationsForm(t *testing.T) {
 				t.Errorf("after encoding url mismatch:\n%s\n%s", got, want)
 			}
 
-			if got, want := form.Offset(pageLen), tt.offset; got != want {
+			if got, want := paginate.Offset(form.Page, pageLen), tt.offset; got != want {
 				t.Errorf("offset got %d want %d", got, want)
 			}
 
 		})
 	}
 }
+
+// TestPayoutGroupsForm tests that the payout references report defaults to the
+// unmatched references, and shows all of them when submitted without unmatched.
+func TestPayoutGroupsForm(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query     string
+		unmatched bool
+		isErr     bool
+	}{
+		{"", true, false},
+		{"date-from=2025-04-01&date-to=2026-03-31", false, false},
+		{"date-from=2025-04-01&date-to=2026-03-31&unmatched=true", true, false},
+		{"date-from=2026-04-01&date-to=2025-03-31", false, true},
+	}
+	for ii, tt := range tests {
+		t.Run(fmt.Sprintf("%d_%s", ii, tt.query), func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			form := NewPayoutGroupsForm(start, time.Now().UTC())
+			if err := form.DecodeURLParams(query); err != nil {
+				t.Fatal(err)
+			}
+			validator := NewValidator()
+			form.Validate(validator)
+			if got, want := !validator.Valid(), tt.isErr; got != want {
+				t.Fatalf("got validation error %t want %t: %v", got, want, validator.Errors)
+			}
+			if got, want := form.Unmatched, tt.unmatched; got != want {
+				t.Errorf("unmatched got %t want %t", got, want)
+			}
+		})
+	}
+}
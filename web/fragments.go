@@ -0,0 +1,314 @@
+package web
+
+// fragments.go serves the results of the listing pages alone, being the results table
+// and pagination, so that a search on a listing page can replace its results in place
+// rather than reloading the page. Each fragment honours the same search form as its
+// page, and the browser location is updated to the equivalent page url. Unchanged
+// results are answered with 304 Not Modified, as set out in conditional.go.
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/paginate"
+)
+
+// resultsPageURL decodes the search form of a fragment request, returning the url of
+// the listing page at thisURL with the same filters.
+func resultsPageURL(form formURLer, r *http.Request, thisURL string) (string, error) {
+	if err := form.DecodeURLParams(r.URL.Query()); err != nil {
+		return "", errUsage{fmt.Sprintf("invalid search parameters: %v", err), http.StatusBadRequest}
+	}
+	params, err := form.AsURLParams()
+	if err != nil {
+		return "", errInternal{"could not encode search parameters", err}
+	}
+	return thisURL + "?" + params, nil
+}
+
+// setFragmentHeaders sets the headers of a results fragment. The fragment depends on the
+// session, so may only be cached privately and must be revalidated. A valid search also
+// pushes the url of the equivalent page to the browser history.
+func setFragmentHeaders(w http.ResponseWriter, pageURL string) {
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "Cookie, HX-Request")
+	if pageURL != "" {
+		w.Header().Set("HX-Push-Url", pageURL)
+	}
+}
+
+// resultsNotModified reports if a 304 Not Modified response was written for a fragment
+// request for unchanged results. The results depend on the display preferences, locale
+// and user of the session and the reloadable settings as well as the data, and on the
+// date, against which the date range presets are resolved.
+func (web *WebApp) resultsNotModified(w http.ResponseWriter, r *http.Request, prefs Preferences) bool {
+	settings := web.settings()
+	return web.notModified(w, r,
+		prefs,
+		web.locale(r.Context()).Tag,
+		web.currentUser(r.Context()),
+		settings.DataStartDate,
+		settings.AccountCodes,
+		web.today().Format(time.DateOnly),
+	)
+}
+
+// handleInvoicesResults serves the results of the /invoices page.
+// The target is "/invoices/results".
+func (web *WebApp) handleInvoicesResults() appHandler {
+
+	thisURL := "/invoices"
+	name := "partial-invoices-results"
+	tpls := []string{
+		"partial-listing-params.html",
+		"partial-invoices-results.html",
+		"partial-pagination.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+		pageURL, err := resultsPageURL(form, r, thisURL)
+		if err != nil {
+			return err
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+
+		prefs := web.listingPreferences(ctx, "invoices")
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
+
+		data := struct {
+			Invoices     []db.Invoice
+			Form         *SearchForm
+			Validator    *Validator
+			Pagination   *paginate.Page
+			Preferences  Preferences
+			SearchParams string
+			Fragment     bool
+		}{
+			Form:         form,
+			Validator:    validator,
+			Pagination:   pagination,
+			Preferences:  prefs,
+			SearchParams: r.URL.RawQuery,
+			Fragment:     true,
+		}
+
+		if !validator.Valid() {
+			setFragmentHeaders(w, "")
+			return web.render(w, r, templates, name, data)
+		}
+
+		// The results are not queried again if the client has them already.
+		web.sessions.Put(ctx, thisURL, pageURL)
+		setFragmentHeaders(w, pageURL)
+		if web.resultsNotModified(w, r, prefs) {
+			return nil
+		}
+
+		data.Invoices, err = web.reconciler.InvoicesGet(
+			ctx,
+			form.ReconciliationStatus,
+			form.DateFrom,
+			form.DateTo,
+			form.SearchString,
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		recordsNo := 1
+		if len(data.Invoices) > 0 {
+			recordsNo = data.Invoices[0].RowCount
+		}
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
+		if err != nil {
+			return err
+		}
+
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleBankTransactionsResults serves the results of the /bank-transactions page.
+// The target is "/bank-transactions/results".
+func (web *WebApp) handleBankTransactionsResults() appHandler {
+
+	thisURL := "/bank-transactions"
+	name := "partial-bank-transactions-results"
+	tpls := []string{
+		"partial-listing-params.html",
+		"partial-bank-transactions-results.html",
+		"partial-pagination.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+		pageURL, err := resultsPageURL(form, r, thisURL)
+		if err != nil {
+			return err
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+
+		prefs := web.listingPreferences(ctx, "bank-transactions")
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
+
+		data := struct {
+			BankTransactions []db.BankTransaction
+			Form             *SearchForm
+			Validator        *Validator
+			Pagination       *paginate.Page
+			Preferences      Preferences
+			SearchParams     string
+			Fragment         bool
+		}{
+			Form:         form,
+			Validator:    validator,
+			Pagination:   pagination,
+			Preferences:  prefs,
+			SearchParams: r.URL.RawQuery,
+			Fragment:     true,
+		}
+
+		if !validator.Valid() {
+			setFragmentHeaders(w, "")
+			return web.render(w, r, templates, name, data)
+		}
+
+		// The results are not queried again if the client has them already.
+		web.sessions.Put(ctx, thisURL, pageURL)
+		setFragmentHeaders(w, pageURL)
+		if web.resultsNotModified(w, r, prefs) {
+			return nil
+		}
+
+		data.BankTransactions, err = web.reconciler.TransactionsGet(
+			ctx,
+			form.ReconciliationStatus,
+			form.DateFrom,
+			form.DateTo,
+			form.SearchString,
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		recordsNo := 1
+		if len(data.BankTransactions) > 0 {
+			recordsNo = data.BankTransactions[0].RowCount
+		}
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
+		if err != nil {
+			return err
+		}
+
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleDonationsResults serves the results of the /donations page.
+// The target is "/donations/results".
+func (web *WebApp) handleDonationsResults() appHandler {
+
+	thisURL := "/donations"
+	name := "partial-donations-searchresults"
+	tpls := []string{
+		"partial-listing-params.html",
+		"partial-donations-searchresults.html",
+		"partial-pagination.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		form := NewSearchDonationsForm(&web.settings().DataStartDate, nil, web.today())
+		pageURL, err := resultsPageURL(form, r, thisURL)
+		if err != nil {
+			return err
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+
+		prefs := web.listingPreferences(ctx, "donations")
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
+
+		data := struct {
+			ViewDonations []domain.ViewDonation
+			Form          *SearchDonationsForm
+			ID            string // needed to match the invoice/bank transaction struct
+			Typer         string
+			Validator     *Validator
+			Pagination    *paginate.Page
+			Preferences   Preferences
+			SearchParams  string
+			Fragment      bool
+		}{
+			Form:         form,
+			Typer:        "donations",
+			Validator:    validator,
+			Pagination:   pagination,
+			Preferences:  prefs,
+			SearchParams: r.URL.RawQuery,
+			Fragment:     true,
+		}
+
+		if !validator.Valid() {
+			setFragmentHeaders(w, "")
+			return web.render(w, r, templates, name, data)
+		}
+
+		// The results are not queried again if the client has them already.
+		web.sessions.Put(ctx, thisURL, pageURL)
+		setFragmentHeaders(w, pageURL)
+		if web.resultsNotModified(w, r, prefs) {
+			return nil
+		}
+
+		data.ViewDonations, err = web.reconciler.DonationsGet(
+			ctx,
+			form.DateFrom,
+			form.DateTo,
+			form.LinkageStatus,
+			form.PayoutReference,
+			form.SearchString,
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
+		)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		recordsNo := 1
+		if len(data.ViewDonations) > 0 {
+			recordsNo = data.ViewDonations[0].RowCount
+		}
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
+		if err != nil {
+			web.log.Error(fmt.Sprintf("pagination error: %v", err))
+		}
+
+		return web.render(w, r, templates, name, data)
+	}
+}
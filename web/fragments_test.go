@@ -0,0 +1,177 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestListingResults tests rendering the results of the listing pages alone.
+func TestListingResults(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler appHandler
+		query   string
+		pushURL string
+		body    []string
+	}{
+		{
+			name:    "invoices",
+			handler: webApp.handleInvoicesResults(),
+			query:   "status=All&date-from=2025-04-01&date-to=2026-03-31&sort=amount&dir=desc",
+			pushURL: "/invoices?date-from=2025-04-01&date-to=2026-03-31&dir=desc&page=1&search=&sort=amount&status=All",
+			body:    []string{`id="listing-results"`, `id="saved-search-params"`, `id="preferences-params"`, `hx-swap-oob="true"`},
+		},
+		{
+			name:    "bank transactions",
+			handler: webApp.handleBankTransactionsResults(),
+			query:   "status=All&date-from=2025-04-01&date-to=2026-03-31&search=online",
+			pushURL: "/bank-transactions?date-from=2025-04-01&date-to=2026-03-31&page=1&search=online&status=All",
+			body:    []string{`id="listing-results"`, `id="saved-search-params"`},
+		},
+		{
+			name:    "donations",
+			handler: webApp.handleDonationsResults(),
+			query:   "status=Linked&date-from=2025-04-01&date-to=2026-03-31",
+			pushURL: "/donations?date-from=2025-04-01&date-to=2026-03-31&page=1&payout-reference=&search=&status=Linked",
+			body:    []string{`id="listing-results"`, `id="donations-link-search"`, `id="preferences-params"`},
+		},
+		{
+			name:    "invalid dates",
+			handler: webApp.handleInvoicesResults(),
+			query:   "status=All&date-from=2026-03-31&date-to=2025-04-01",
+			pushURL: "",
+			body:    []string{`id="listing-results"`, "text-red-700"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/results?"+tt.query, nil)
+			req.Header.Set("HX-Request", "true")
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(tt.handler)).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if got, want := rec.Header().Get("HX-Push-Url"), tt.pushURL; got != want {
+				t.Errorf("push url got %q want %q", got, want)
+			}
+			if got, want := rec.Header().Get("Cache-Control"), "private, no-cache"; got != want {
+				t.Errorf("cache control got %q want %q", got, want)
+			}
+			body := rec.Body.String()
+			if strings.Contains(body, "<html") {
+				t.Error("fragment contains the page layout")
+			}
+			for _, s := range tt.body {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q", s)
+				}
+			}
+		})
+	}
+}
+
+// TestListingResultsNotModified tests that unchanged listing results are answered with
+// 304 Not Modified without being queried again.
+func TestListingResultsNotModified(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleInvoicesResults()))
+	target := "/invoices/results?status=All&date-from=2025-04-01&date-to=2026-03-31"
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve("", "")
+	if got, want := first.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("unexpected etag %q", etag)
+	}
+	if got, want := first.Header().Get("Last-Modified"), "Fri, 02 Jan 2026 03:04:05 GMT"; got != want {
+		t.Errorf("last modified got %q want %q", got, want)
+	}
+	queried := mock.invoicesGet
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"matching etag", "If-None-Match", etag, http.StatusNotModified},
+		{"one of several etags", "If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"other etag", "If-None-Match", `W/"other"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Fri, 02 Jan 2026 03:04:05 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Fri, 02 Jan 2026 03:04:04 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := mock.invoicesGet
+			rec := serve(tt.header, tt.value)
+			if got, want := rec.Code, tt.status; got != want {
+				t.Fatalf("status got %d want %d", got, want)
+			}
+			queries := mock.invoicesGet - before
+			if tt.status == http.StatusNotModified && queries != 0 {
+				t.Errorf("unchanged results were queried %d times", queries)
+			}
+			if tt.status == http.StatusOK && queries != 1 {
+				t.Errorf("changed results were queried %d times", queries)
+			}
+		})
+	}
+	if queried == 0 {
+		t.Error("the first request did not query the invoices")
+	}
+}
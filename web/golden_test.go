@@ -0,0 +1,173 @@
+package web
+
+// golden_test.go renders the pages served with the reconciliationMock data, and the
+// template previews, comparing them with the golden files in testdata/golden. After an
+// intended template change, update the golden files with
+//
+//	go test ./web -run TestGolden -update
+//
+// and review the changes to them with git diff.
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the rendered templates")
+
+// goldenPages are the pages rendered to golden files, with dates given, so that the
+// pages do not depend on the date of the test.
+var goldenPages = []string{
+	"/connect",
+	"/invoices?status=All&date-from=2025-04-01&date-to=2026-03-31",
+	"/bank-transactions?status=All&date-from=2025-04-01&date-to=2026-03-31",
+	"/donations?status=All&date-from=2025-04-01&date-to=2026-03-31",
+	"/invoice/inv-001",
+	"/bank-transaction/bt-001",
+	"/contact/con-jg",
+	"/payout/bt-001",
+	"/imports",
+	"/imports/2",
+	"/reports/statements?date-from=2025-04-01&date-to=2026-03-31",
+	"/pending-actions",
+	"/settings/reconciliation",
+}
+
+// goldenReplacements replace the parts of the rendered pages which differ between
+// requests or test runs.
+var goldenReplacements = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(name="csrf-token" value=")[^"]*`), "${1}CSRF"},
+	{regexp.MustCompile(`("X-CSRF-Token": ")[^"]*`), "${1}CSRF"},
+	{regexp.MustCompile(`(nonce=")[^"]*`), "${1}NONCE"},
+	{regexp.MustCompile(`("inlineScriptNonce": ")[^"]*`), "${1}NONCE"},
+	{regexp.MustCompile(`(/static/[^"?]+)\?v=[^"]*`), "${1}?v=VERSION"},
+}
+
+// goldenNormalise removes the parts of a rendered page which differ between requests,
+// and today's date, which is shown by some pages.
+func goldenNormalise(body string) string {
+	for _, r := range goldenReplacements {
+		body = r.re.ReplaceAllString(body, r.repl)
+	}
+	today := time.Now()
+	for _, layout := range []string{time.DateOnly, "02/01/2006", "2 Jan 2006"} {
+		body = strings.ReplaceAll(body, today.Format(layout), "TODAY")
+	}
+	return goldenTimeToday.ReplaceAllString(body, "TODAY TIME")
+}
+
+// goldenTimeToday matches the times of today, once today's date has been replaced.
+var goldenTimeToday = regexp.MustCompile(`TODAY \d{2}:\d{2}(:\d{2})?`)
+
+// goldenFileName returns the golden file name of the page or preview at path.
+func goldenFileName(path string) string {
+	name := regexp.MustCompile(`[^A-Za-z0-9.]+`).ReplaceAllString(strings.TrimPrefix(path, "/"), "-")
+	return filepath.Join("testdata", "golden", strings.TrimSuffix(strings.Trim(name, "-"), ".html")+".html")
+}
+
+// TestGolden renders each golden page and template preview, failing if it differs from
+// its golden file, or updating the golden file if the -update flag is given.
+func TestGolden(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:                    config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:              config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate:           time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		DonationAccountPrefixes: []string{"53", "55", "57"},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	staticFS, err := mounts.NewFileMount("static", StaticEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, staticFS, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webApp.SetInDevelopment()
+	ts := httptest.NewServer(webApp.routes())
+	t.Cleanup(ts.Close)
+
+	paths := append([]string{}, goldenPages...)
+	paths = append(paths, "/__preview")
+	for _, name := range previewNames() {
+		paths = append(paths, "/__preview/"+name)
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+
+			resp, err := ts.Client().Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 200 {
+				t.Fatalf("got status %d want 200: %s", resp.StatusCode, body)
+			}
+			got := goldenNormalise(string(body))
+
+			file := goldenFileName(path)
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("golden file error, run the test with -update to write it: %v", err)
+			}
+			if diff := goldenDiff(string(want), got); diff != "" {
+				t.Errorf("%s differs from %s, run the test with -update if intended:\n%s", path, file, diff)
+			}
+		})
+	}
+}
+
+// goldenDiff reports the first line at which got differs from want, with the lines
+// around it, or an empty string if they are the same.
+func goldenDiff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+	context := func(lines []string) string {
+		from, to := max(0, i-2), min(len(lines), i+3)
+		return strings.Join(lines[from:to], "\n")
+	}
+	return "line " + strconv.Itoa(i+1) + "\n--- want\n" + context(wantLines) + "\n+++ got\n" + context(gotLines)
+}
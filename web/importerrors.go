@@ -0,0 +1,121 @@
+package web
+
+// importerrors.go lists the refreshed records which failed validation or could not be
+// stored, and allows each to be retried or discarded.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// handleImportErrors shows the most recent import errors.
+func (web *WebApp) handleImportErrors() appHandler {
+
+	name := "import-errors.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"import-errors.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		importErrors, err := web.reconciler.ImportErrorsGet(ctx)
+		if err != nil {
+			return errInternal{"failed to retrieve import errors", err}
+		}
+		data := map[string]any{
+			"PageTitle":    "Import Errors",
+			"CurrentPage":  "data-quality",
+			"ImportErrors": importErrors,
+			"Message":      web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleImportErrorRetry retrieves the record of an import error again and stores it.
+// The Xero and Salesforce clients are only provided if connected, as each record
+// requires only one of them.
+// The target is "/import-errors/{{ .ID }}/retry".
+func (web *WebApp) handleImportErrorRetry() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		id, err := importErrorID(r)
+		if err != nil {
+			return err
+		}
+
+		var xeroClient domain.XeroClient
+		if xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken); err == nil {
+			xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken)
+			if err != nil {
+				return errInternal{"failed to create xero client for the import error retry", err}
+			}
+		}
+		var sfClient domain.SalesforceClient
+		if sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken); err == nil {
+			sfClient, err = web.newSFClient(ctx, web.cfg, web.log, sfToken)
+			if err != nil {
+				return errInternal{"failed to create salesforce client for the import error retry", err}
+			}
+		}
+
+		err = web.reconciler.ImportErrorRetry(ctx, xeroClient, sfClient, id)
+		return web.importErrorRedirect(w, r, err, fmt.Sprintf("Import error %d was retried and the record stored.", id))
+	}
+}
+
+// handleImportErrorDiscard discards an import error.
+// The target is "/import-errors/{{ .ID }}/discard".
+func (web *WebApp) handleImportErrorDiscard() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id, err := importErrorID(r)
+		if err != nil {
+			return err
+		}
+		err = web.reconciler.ImportErrorDiscard(r.Context(), id)
+		return web.importErrorRedirect(w, r, err, fmt.Sprintf("Import error %d was discarded.", id))
+	}
+}
+
+// importErrorID returns the import error id in the url.
+func importErrorID(r *http.Request) (int64, error) {
+	vars, err := validMuxVars(mux.Vars(r), "id")
+	if err != nil {
+		return 0, errUsage{err.Error(), http.StatusBadRequest}
+	}
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		return 0, errUsage{fmt.Sprintf("invalid import error id %q", vars["id"]), http.StatusBadRequest}
+	}
+	return id, nil
+}
+
+// importErrorRedirect redirects to the import errors page with a message reporting the
+// outcome of a retry or discard, being msg if err is nil.
+func (web *WebApp) importErrorRedirect(w http.ResponseWriter, r *http.Request, err error, msg string) error {
+	if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+		msg = e.Msg + "."
+	} else if e, ok := errors.AsType[domain.ErrNotFound](err); ok {
+		msg = e.Msg + "."
+	} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+		web.log.Error(err.Error(), "detail", e.Detail)
+		msg = e.Msg + "."
+	} else if err != nil {
+		return errInternal{"failed to update the import error", err}
+	}
+	web.sessions.Put(r.Context(), "message", msg)
+	http.Redirect(w, r, "/import-errors", http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestImportErrors tests listing the import errors and retrying or discarding one.
+func TestImportErrors(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/import-errors", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleImportErrors())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+	}
+	for _, want := range []string{"inv-001", `action="/import-errors/1/retry"`, `action="/import-errors/1/discard"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("import errors page does not contain %q", want)
+		}
+	}
+
+	r := mux.NewRouter()
+	r.Handle("/import-errors/{id:[0-9]+}/retry", webApp.ErrorChecker(webApp.handleImportErrorRetry()))
+	r.Handle("/import-errors/{id:[0-9]+}/discard", webApp.ErrorChecker(webApp.handleImportErrorDiscard()))
+	for _, action := range []string{"retry", "discard"} {
+		req := httptest.NewRequest("POST", "/import-errors/1/"+action, nil)
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(r).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("%s status got %d want %d\n%s", action, got, want, rec.Body.String())
+		}
+		if got, want := rec.Header().Get("Location"), "/import-errors"; got != want {
+			t.Errorf("%s location got %q want %q", action, got, want)
+		}
+	}
+	if mock.importErrorsGet != 1 || mock.importErrorRetry != 1 || mock.importErrorDiscard != 1 {
+		t.Errorf("got %d gets, %d retries and %d discards want 1 of each", mock.importErrorsGet, mock.importErrorRetry, mock.importErrorDiscard)
+	}
+}
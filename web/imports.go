@@ -0,0 +1,149 @@
+package web
+
+// imports.go serves the review of staged imports. Imports, such as donations exported
+// from another CRM or platform payout reports, are staged with the validation errors of
+// their rows, and only the accepted rows are committed to the live tables once the
+// import is confirmed.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// importID returns the import batch id of the url.
+func importID(r *http.Request) (int64, error) {
+	vars, err := validMuxVars(mux.Vars(r), "id")
+	if err != nil {
+		return 0, errUsage{err.Error(), http.StatusBadRequest}
+	}
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		return 0, errUsage{fmt.Sprintf("invalid import id %q", vars["id"]), http.StatusBadRequest}
+	}
+	return id, nil
+}
+
+// handleImports serves the /imports page listing the recent imports.
+func (web *WebApp) handleImports() appHandler {
+
+	name := "imports.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"imports.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		batches, err := web.reconciler.ImportBatchesGet(ctx)
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"PageTitle":   "Imports",
+			"CurrentPage": "import",
+			"Batches":     batches,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleImport serves the /imports/{id} page for reviewing the accepted and rejected
+// rows of an import.
+func (web *WebApp) handleImport() appHandler {
+
+	name := "import.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"import.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		id, err := importID(r)
+		if err != nil {
+			return err
+		}
+		review, err := web.reconciler.ImportReviewGet(ctx, id)
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"PageTitle":   "Import Review",
+			"CurrentPage": "import",
+			"Review":      review,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleImportCommit serves the /imports/{id}/commit endpoint, which commits the
+// accepted rows of a staged import.
+func (web *WebApp) handleImportCommit() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		id, err := importID(r)
+		if err != nil {
+			return err
+		}
+
+		result, err := web.reconciler.ImportCommit(ctx, id)
+		var msg string
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return err
+		} else {
+			msg = fmt.Sprintf("%d rows were committed.", result.Committed)
+			if result.Skipped > 0 && result.Batch.Kind == db.ImportDonations {
+				msg += fmt.Sprintf(" %d donations were skipped as their ids are held by donations of another source.", result.Skipped)
+			}
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, fmt.Sprintf("/imports/%d", id), http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handleImportDiscard serves the /imports/{id}/discard endpoint, which discards a
+// staged import.
+func (web *WebApp) handleImportDiscard() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		id, err := importID(r)
+		if err != nil {
+			return err
+		}
+
+		msg := "The import was discarded."
+		err = web.reconciler.ImportDiscard(ctx, id)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, fmt.Sprintf("/imports/%d", id), http.StatusSeeOther)
+		return nil
+	}
+}
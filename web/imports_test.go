@@ -0,0 +1,77 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestImportReview tests reviewing a staged import and committing or discarding it.
+func TestImportReview(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(h appHandler, method, path, id string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(method, path, nil), map[string]string{"id": id})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(h)).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(webApp.handleImport(), "GET", "/imports/2", "2")
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("review status got %d want %d", got, want)
+	}
+	for _, s := range []string{`row 3 Amount: invalid amount: &#34;ten&#34;`, "Commit 1 accepted rows", `action="/imports/2/discard"`} {
+		if !strings.Contains(rec.Body.String(), s) {
+			t.Errorf("review page does not contain %q", s)
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		handler appHandler
+		count   *int
+	}{
+		{"commit", webApp.handleImportCommit(), &mock.importCommit},
+		{"discard", webApp.handleImportDiscard(), &mock.importDiscard},
+	} {
+		rec := serve(tt.handler, "POST", "/imports/2/"+tt.name, "2")
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("%s status got %d want %d", tt.name, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), "/imports/2"; got != want {
+			t.Errorf("%s location got %q want %q", tt.name, got, want)
+		}
+		if *tt.count != 1 {
+			t.Errorf("%s called %d times", tt.name, *tt.count)
+		}
+	}
+
+	if got, want := serve(webApp.handleImport(), "GET", "/imports/x", "x").Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid id status got %d want %d", got, want)
+	}
+}
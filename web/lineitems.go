@@ -0,0 +1,90 @@
+package web
+
+// lineitems.go serves the line item exports of the invoice and bank transaction
+// listings, with one row for each line item of the records found by the search terms
+// of the listing, as CSV or as an Excel workbook, so that the records may be analysed
+// by account code outside the app.
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/rorycl/reconciler/reports"
+)
+
+// handleLineItemsExport serves the /invoices/export and /bank-transactions/export
+// endpoints, which download the line items of the records of the listing of
+// recordType, being "invoice" or "bank-transaction", as CSV, or as an Excel workbook
+// if the format is "xlsx". All the records found are exported, not only the current
+// page.
+func (web *WebApp) handleLineItemsExport(recordType string) appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		// The format is not one of the search terms of the listing.
+		urlQuery := maps.Clone(r.URL.Query())
+		format := urlQuery.Get("format")
+		urlQuery.Del("format")
+
+		write, contentType := reports.WriteLineItemsCSV, "text/csv"
+		switch format {
+		case "", "csv":
+			format = "csv"
+		case "xlsx":
+			write, contentType = reports.WriteLineItemsXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		default:
+			return errUsage{fmt.Sprintf("invalid line item export format %q", format), http.StatusBadRequest}
+		}
+
+		form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+		if err := form.DecodeURLParams(urlQuery); err != nil {
+			return errUsage{fmt.Sprintf("invalid export parameters: %v", err), http.StatusBadRequest}
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+		if !validator.Valid() {
+			var msgs []string
+			for _, m := range validator.Errors {
+				msgs = append(msgs, m)
+			}
+			return errUsage{strings.Join(msgs, " "), http.StatusBadRequest}
+		}
+
+		items, err := web.reconciler.LineItemsExportGet(
+			ctx,
+			recordType,
+			form.ReconciliationStatus,
+			form.DateFrom,
+			form.DateTo,
+			form.SearchString,
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+		)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := write(&buf, items); err != nil {
+			return errInternal{"failed to write line item export", err}
+		}
+
+		fileName := fmt.Sprintf("%s-line-items-%s-%s.%s",
+			recordType,
+			form.DateFrom.Format("20060102"),
+			form.DateTo.Format("20060102"),
+			format,
+		)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("line item export write error: %v", err))
+		}
+		return nil
+	}
+}
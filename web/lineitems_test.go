@@ -0,0 +1,90 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestLineItemsExport tests downloading the line items of a listing in each format.
+func TestLineItemsExport(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		wantStatus      int
+		wantContentType string
+		wantFileName    string
+	}{
+		{
+			name:            "csv",
+			url:             "/invoices/export?status=All&date-from=2025-04-01&date-to=2026-03-31",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv",
+			wantFileName:    "invoice-line-items-20250401-20260331.csv",
+		},
+		{
+			name:            "xlsx",
+			url:             "/invoices/export?format=xlsx&status=All&date-from=2025-04-01&date-to=2026-03-31",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			wantFileName:    "invoice-line-items-20250401-20260331.xlsx",
+		},
+		{
+			name:       "invalid format",
+			url:        "/invoices/export?format=pdf&status=All",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid dates",
+			url:        "/invoices/export?status=All&date-from=2026-03-31&date-to=2025-04-01",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleLineItemsExport("invoice"))).ServeHTTP(rec, req)
+			if got, want := rec.Code, tt.wantStatus; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got, want := rec.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("content type got %q want %q", got, want)
+			}
+			if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, tt.wantFileName) {
+				t.Errorf("content disposition %q does not name %s", got, tt.wantFileName)
+			}
+			if tt.name == "csv" && !strings.Contains(rec.Body.String(), "INV-001") {
+				t.Errorf("export does not contain the line item: %s", rec.Body.String())
+			}
+		})
+	}
+}
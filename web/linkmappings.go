@@ -0,0 +1,77 @@
+package web
+
+// linkmappings.go provides the download of the donation links as a json document of
+// link mappings, and the import of such a document, so that the reconciliation work
+// recorded in the links is kept when the database is rebuilt from scratch.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rorycl/reconciler/domain"
+)
+
+// linkMappingsMaxUploadSize is the maximum size of an uploaded link mappings file.
+const linkMappingsMaxUploadSize = 64 << 20
+
+// handleLinkMappingsExport downloads the donation links as a json document.
+func (web *WebApp) handleLinkMappingsExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		// The document is written to a buffer so that an error can still be reported.
+		var buf bytes.Buffer
+		if err := web.reconciler.LinkMappingsExport(r.Context(), &buf); err != nil {
+			return err
+		}
+
+		fileName := fmt.Sprintf("reconciler-links-%s.json", time.Now().Format("20060102-150405"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("link mappings write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// handleLinkMappingsImport imports the donation links of an uploaded link mappings
+// document, reporting the outcome on the reports page.
+func (web *WebApp) handleLinkMappingsImport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/reports", http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, linkMappingsMaxUploadSize)
+		upload, _, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The links file could not be read: %v", err))
+		}
+		defer func() {
+			_ = upload.Close()
+		}()
+
+		imported, total, err := web.reconciler.LinkMappingsImport(ctx, upload)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return err
+		}
+		msg := fmt.Sprintf("Imported %d links.", imported)
+		if imported < total {
+			msg = fmt.Sprintf("Imported %d of %d links. The others are of donations, invoices or bank transactions not in the database.", imported, total)
+		}
+		return redirect(msg)
+	}
+}
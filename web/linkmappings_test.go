@@ -0,0 +1,84 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestLinkMappings tests downloading the donation links and importing an uploaded
+// links file.
+func TestLinkMappings(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/links/export", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleLinkMappingsExport())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("export status got %d want %d", got, want)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="reconciler-links-`) {
+		t.Errorf("unexpected content disposition %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), `"donation_id":"sf-opp-001"`) {
+		t.Errorf("export does not contain the link: %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"valid", `{"version":1,"links":[{"donation_id":"sf-opp-001"},{"donation_id":"sf-opp-002"}]}`},
+		{"invalid", `links`},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, err := mw.CreateFormFile("file", "links.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fw.Write([]byte(tt.content))
+			_ = mw.Close()
+
+			req := httptest.NewRequest("POST", "/links/import", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleLinkMappingsImport())).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusSeeOther; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if got, want := rec.Header().Get("Location"), "/reports"; got != want {
+				t.Errorf("location got %q want %q", got, want)
+			}
+			if got, want := mock.linkMappingsImport, i+1; got != want {
+				t.Errorf("got %d imports want %d", got, want)
+			}
+		})
+	}
+}
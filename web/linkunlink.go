@@ -17,8 +17,17 @@ import (
 // However .ID is the bank-transaction or invoice UUID and the linking DFK info is
 // the bank-transaction *reference* or invoice *invoice-number*. The DFK (and record
 // date) is therefore retrieved using the `getInvoiceOrBankTransactionDetails` method.
+//
+// If the donations or invoice have been changed remotely since the last refresh,
+// nothing is written and the changes are shown instead. If Salesforce cannot be
+// reached the updates are queued, to be retried in the background, and the pending
+// actions page shown. If the records are dated in a locked period, nothing is written
+// and a form shown to override the locks with an "override-reason".
 func (web *WebApp) handleDonationsLinkUnlink() appHandler {
 
+	conflictTemplates := web.parseTemplates("partial-link-conflicts.html")
+	lockedTemplates := web.parseTemplates("partial-period-locked.html")
+
 	return (func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
@@ -68,6 +77,12 @@ func (web *WebApp) handleDonationsLinkUnlink() appHandler {
 						err: e,
 					}
 				}
+				if e, ok := errors.AsType[domain.ErrNotFound](err); ok {
+					return errHTMX{
+						msg: e.Msg,
+						err: e,
+					}
+				}
 				return errInternal{
 					msg: fmt.Sprintf("%T error: unexpected InvoiceOrBankTransactionInfoGet error", err),
 					err: fmt.Errorf("link/unlink InvoiceOrBankTransactionInfoGet error: %w", err),
@@ -96,20 +111,64 @@ func (web *WebApp) handleDonationsLinkUnlink() appHandler {
 			return errInternal{"failed to create salesforce client for linking/unlinking", err}
 		}
 
+		// Optionally write the payout reference of the linked donations to the Xero
+		// invoice.
+		action := domain.LinkAction{IDRefs: form.AsSalesforceIDRefs(dfk), Override: form.OverrideReason}
+		var xeroClient domain.XeroClient
+		if form.UpdateReference && web.cfg.Xero.WriteInvoiceReferences {
+			xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken)
+			if err != nil {
+				return errHTMX{"Xero is not connected to update the invoice reference.", err}
+			}
+			xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken)
+			if err != nil {
+				return errInternal{"failed to create xero client for updating the invoice reference", err}
+			}
+			action.InvoiceID, action.Reference = form.ID, dfk
+		}
+
 		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
 
-		// Run the Link/Unlink batch opportunity update and then upsert the results.
-		err = web.reconciler.DonationsLinkUnlink(
+		// Run the Link/Unlink batch opportunity update, and any invoice reference
+		// update, and then upsert the results. A failed action is recorded for retry
+		// or reversal on the pending actions page.
+		err = web.reconciler.LinkActionRun(
 			ctx,
 			sfClient,
-			form.AsSalesforceIDRefs(dfk),
-			web.cfg.DataStartDate,
+			xeroClient,
+			action,
+			web.settings().DataStartDate,
 			sfLastRefresh.Add(refreshDurationWindow),
 		)
+		if e, ok := errors.AsType[domain.ErrConflict](err); ok {
+			web.log.Warn(e.Error(), "action", form.Action, "type", form.Typer, "id", form.ID)
+			return web.render(w, r, conflictTemplates, "partial-link-conflicts", e)
+		}
+		if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+			web.log.Warn(e.Error(), "action", form.Action, "type", form.Typer, "id", form.ID)
+			data := map[string]any{
+				"Locks":           e.Locks,
+				"Typer":           form.Typer,
+				"ID":              form.ID,
+				"Action":          form.Action,
+				"DonationIDs":     form.DonationIDs,
+				"UpdateReference": form.UpdateReference,
+			}
+			return web.render(w, r, lockedTemplates, "partial-period-locked", data)
+		}
+		// Salesforce could not be reached, and the updates are queued to be retried.
+		if e, ok := errors.AsType[domain.ErrQueued](err); ok {
+			web.log.Warn(e.Error(), "action", form.Action, "type", form.Typer, "id", form.ID)
+			web.writeQueued(w, r, e)
+			return nil
+		}
+		if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			return errHTMX{e.Msg, e}
+		}
 		if err != nil {
 			return err
 		}
-		web.log.Info("Successful donation opertions", "action", form.Action, "records", len(form.DonationIDs))
+		web.log.Info("Successful donation opertions", "action", form.Action, "records", len(form.DonationIDs), "invoice reference", action.Reference)
 
 		// Redirect to the originator.
 		// Todo: set focus to either the "find" or "linked" donations tab.
@@ -17,6 +17,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/domain"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
 	"github.com/rorycl/reconciler/internal/token"
 	"golang.org/x/oauth2"
 )
@@ -45,18 +46,26 @@ func TestLinkUnlink(t *testing.T) {
 	// replace with interface
 	reconciler := domain.NewReconciler(testDB, logger)
 
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	webApp := &WebApp{
 		reconciler:     reconciler,
 		log:            logger,
 		sessions:       sessionStore,
 		accountsRegexp: regexp.MustCompile(".*"),
+		templateFS:     templatesFS,
 		cfg: &config.Config{
 			DataStartDate:           time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
 			DonationAccountPrefixes: []string{"53", "55", "57"},
+			Xero:                    config.XeroConfig{WriteInvoiceReferences: true},
 		},
 
 		// client factory funcs
-		newSFClient: NewMockSFClient,
+		newSFClient:   NewMockSFClient,
+		newXeroClient: NewMockXeroClient,
 	}
 
 	// Add salesforce token.
@@ -69,6 +78,10 @@ func TestLinkUnlink(t *testing.T) {
 		},
 	}
 	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), validToken)
+	validXeroToken := validToken
+	validXeroToken.Type = token.XeroToken
+	validXeroToken.TenantID = "tenant-1"
+	webApp.sessions.Put(ctx, token.XeroToken.SessionName(), validXeroToken)
 
 	tests := []struct {
 		name         string
@@ -120,6 +133,28 @@ func TestLinkUnlink(t *testing.T) {
 			expectedCode: 200,
 			expectedBody: "Invoice \"inv-99999\" could not be found",
 		},
+		{
+			name: "update bank transaction reference",
+			rq: httptest.NewRequestWithContext(
+				ctx,
+				http.MethodPost,
+				"/donations/bank-transaction/bt-001/link",
+				strings.NewReader("donation-ids=0015A00002CrA9PQAV&update-reference=true"),
+			),
+			expectedCode: 200,
+			expectedBody: "invalid data was received",
+		},
+		{
+			name: "link and update invoice reference",
+			rq: httptest.NewRequestWithContext(
+				ctx,
+				http.MethodPost,
+				"/donations/invoice/inv-002/link",
+				strings.NewReader("donation-ids=0015A00002CrA9PQAV&update-reference=true"),
+			),
+			expectedCode: 200,
+			expectedBody: "",
+		},
 	}
 
 	for ii, tt := range tests {
@@ -152,3 +187,57 @@ func TestLinkUnlink(t *testing.T) {
 	}
 
 }
+
+// TestLinkConflictsRender tests showing the records which blocked a link because they
+// were changed remotely.
+func TestLinkConflictsRender(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflict := domain.ErrConflict{Conflicts: []domain.Conflict{
+		{
+			RecordType:     "donation",
+			ID:             "sf-opp-001",
+			Name:           "Example Corp Q1 Donation",
+			LocalModified:  time.Date(2025, 5, 1, 9, 0, 0, 0, time.UTC),
+			RemoteModified: time.Date(2025, 5, 2, 10, 30, 0, 0, time.UTC),
+			ModifiedBy:     "Another User",
+			Changes:        []domain.FieldChange{{Field: "Payout Reference", Local: "INV-2025-101", Remote: "<EDITED>"}},
+		},
+		{RecordType: "invoice", ID: "inv-002", Name: "INV-2025-102", Deleted: true},
+	}}
+
+	templates := webApp.parseTemplates("partial-link-conflicts.html")
+	h := func(w http.ResponseWriter, r *http.Request) error {
+		return webApp.render(w, r, templates, "partial-link-conflicts", conflict)
+	}
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(h)).ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		"Nothing was changed",
+		"by Another User",
+		"02/05/2025 10:30:00",
+		"INV-2025-101",
+		"&lt;EDITED&gt;",
+		"INV-2025-102</a>",
+		"has been deleted",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package web
+
+// locale.go determines the locale of each request, which sets the message catalogue
+// and the date and money formats of the templates. The locale is the one chosen for the
+// session, or otherwise the configured default.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rorycl/reconciler/internal/i18n"
+)
+
+// localeSessionKey is the session key of the locale chosen by the user.
+const localeSessionKey = "locale"
+
+// locale returns the locale of the session, or the configured locale.
+func (web *WebApp) locale(ctx context.Context) *i18n.Locale {
+	if tag := web.sessions.GetString(ctx, localeSessionKey); i18n.Supported(tag) {
+		return i18n.Get(tag)
+	}
+	return i18n.Get(web.cfg.Web.Locale)
+}
+
+// handleLocale sets the locale of the session to the "locale" form value, returning to
+// the referring page of this site or otherwise to the home page.
+// The target is "/locale".
+func (web *WebApp) handleLocale() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		if err := r.ParseForm(); err != nil {
+			return errUsage{"could not read the form", http.StatusBadRequest}
+		}
+		tag := r.PostForm.Get("locale")
+		if !i18n.Supported(tag) {
+			return errUsage{fmt.Sprintf("invalid locale %q", tag), http.StatusBadRequest}
+		}
+		web.sessions.Put(ctx, localeSessionKey, tag)
+
+		http.Redirect(w, r, refererTarget(r, "/home"), http.StatusSeeOther)
+		return nil
+	}
+}
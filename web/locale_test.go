@@ -0,0 +1,98 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/money"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestLocale tests choosing the locale of the session and the locale template funcs.
+func TestLocale(t *testing.T) {
+
+	cfg := &config.Config{
+		Web:        config.WebConfig{Locale: "en-GB"},
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(locale, referer string) *httptest.ResponseRecorder {
+		t.Helper()
+		form := url.Values{"locale": {locale}}
+		req := httptest.NewRequest("POST", "/locale", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Referer", referer)
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleLocale())).ServeHTTP(rec, req)
+		return rec
+	}
+
+	if got, want := post("xx-XX", "").Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid locale status got %d want %d", got, want)
+	}
+	if got, want := post("fr-FR", "https://example.org/invoices").Header().Get("Location"), "/home"; got != want {
+		t.Errorf("offsite referer location got %q want %q", got, want)
+	}
+	rec := post("fr-FR", "http://example.com/donations?page=2")
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/donations?page=2"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+
+	// Render the locale template funcs with and without the session cookie.
+	tpl := template.Must(template.New("").Funcs(webApp.templateFuncs).Parse(
+		`{{ locale }}|{{ t "nav.invoices" }}|{{ formatDate .Date }}|{{ formatLongDate .Date }}|{{ formatMoney .Amount }}|{{ formatMoney .Float }}`,
+	))
+	data := map[string]any{
+		"Date":   time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC),
+		"Amount": money.FromFloat(1234.5),
+		"Float":  -2.25,
+	}
+	render := func(cookies []*http.Cookie) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/invoices", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		var buf bytes.Buffer
+		webApp.sessions.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tpl, err := tpl.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tpl.Funcs(webApp.requestTemplateFuncs(r.Context())).Execute(&buf, data); err != nil {
+				t.Fatal(err)
+			}
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		return buf.String()
+	}
+
+	if got, want := render(nil), "en-GB|Invoices|04/08/2025|04 Aug 2025|£1,234.50|-£2.25"; got != want {
+		t.Errorf("default locale got %q want %q", got, want)
+	}
+	if got, want := render(rec.Result().Cookies()), "fr-FR|Factures|04/08/2025|04 août 2025|1 234,50 £|-2,25 £"; got != want {
+		t.Errorf("session locale got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package web
+
+// locks.go shows, adds and removes the period locks which close periods, such as a
+// financial year whose accounts have been finalised, to links, unlinks and
+// write-backs, together with the audit events of the locks and their overrides, and
+// compares the snapshots taken of periods when they were locked with the periods now.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// handlePeriodLocks shows the period locks, period snapshots and audit events with a
+// form to lock a period.
+func (web *WebApp) handlePeriodLocks() appHandler {
+
+	name := "settings-period-locks.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-period-locks.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		locks, err := web.reconciler.PeriodLocksGet(ctx)
+		if err != nil {
+			return err
+		}
+		snapshots, err := web.reconciler.PeriodSnapshotsGet(ctx)
+		if err != nil {
+			return err
+		}
+		events, err := web.reconciler.AuditEventsGet(ctx)
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"PageTitle":    "Period Locks",
+			"CurrentPage":  "settings-period-locks",
+			"Locks":        locks,
+			"LocksEnabled": web.reconciler.PeriodLocksEnabled(),
+			"Snapshots":    snapshots,
+			"Events":       events,
+			"Message":      web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handlePeriodLockAdd locks the period from the "date-from" to the "date-to" form
+// values, with the "reason" form value.
+func (web *WebApp) handlePeriodLockAdd() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/settings/period-locks", http.StatusSeeOther)
+			return nil
+		}
+
+		var dates [2]time.Time
+		for i, field := range []string{"date-from", "date-to"} {
+			v := strings.TrimSpace(r.PostFormValue(field))
+			d, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return redirect(fmt.Sprintf("The date %q is not a valid date.", v))
+			}
+			dates[i] = d
+		}
+
+		err := web.reconciler.PeriodLockAdd(ctx, dates[0], dates[1], r.PostFormValue("reason"))
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return err
+		}
+		return redirect("The period was locked.")
+	}
+}
+
+// handlePeriodLockDelete unlocks a period, with the "reason" form value.
+// The target is "/settings/period-locks/{{ .ID }}/delete".
+func (web *WebApp) handlePeriodLockDelete() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "lock")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		id, err := strconv.ParseInt(vars["lock"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid period lock id %q", vars["lock"]), http.StatusBadRequest}
+		}
+		if err := web.reconciler.PeriodLockRemove(ctx, id, r.PostFormValue("reason")); err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", "The period was unlocked.")
+		http.Redirect(w, r, "/settings/period-locks", http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handlePeriodSnapshot compares a period snapshot, taken when its period was locked,
+// with the current state of the period.
+// The target is "/settings/period-snapshots/{{ .ID }}".
+func (web *WebApp) handlePeriodSnapshot() appHandler {
+
+	name := "settings-period-snapshot.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-period-snapshot.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		vars, err := validMuxVars(mux.Vars(r), "snapshot")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		id, err := strconv.ParseInt(vars["snapshot"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid period snapshot id %q", vars["snapshot"]), http.StatusBadRequest}
+		}
+		comparison, err := web.reconciler.PeriodSnapshotCompare(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"PageTitle":   "Period Snapshot",
+			"CurrentPage": "settings-period-locks",
+			"Comparison":  comparison,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
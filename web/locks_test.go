@@ -0,0 +1,232 @@
+package web
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+// TestPeriodLocks tests showing, adding and removing period locks.
+func TestPeriodLocks(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/settings/period-locks", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePeriodLocks())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`action="/settings/period-locks" method="post"`, `action="/settings/period-locks/1/delete"`, "2024-25 accounts finalised", "locked 2024-04-01 to 2025-03-31", `href="/settings/period-snapshots/1"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		form url.Values
+		adds int
+	}{
+		{url.Values{"date-from": {"2024-04-01"}, "date-to": {"2025-03-31"}, "reason": {"year end"}}, 1},
+		{url.Values{"date-from": {"2025-03-31"}, "date-to": {"2024-04-01"}}, 2},
+		{url.Values{"date-from": {"2024-04-01"}, "date-to": {"31/03/2025"}}, 2},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/settings/period-locks", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePeriodLockAdd())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%v: status got %d want %d", tt.form, got, want)
+		}
+		if got, want := reconcilerMock.periodLockAdd, tt.adds; got != want {
+			t.Errorf("%v: adds got %d want %d", tt.form, got, want)
+		}
+	}
+
+	form := url.Values{"reason": {"accounts reopened"}}
+	req = httptest.NewRequest("POST", "/settings/period-locks/1/delete", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = mux.SetURLVars(req, map[string]string{"lock": "1"})
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePeriodLockDelete())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Errorf("delete status got %d want %d", got, want)
+	}
+	if got, want := reconcilerMock.periodLockRemove, 1; got != want {
+		t.Errorf("removes got %d want %d", got, want)
+	}
+
+	// Periods may not be locked in an in-memory database, so the form to lock a period
+	// is replaced by a notice.
+	reconcilerMock.periodLocksDisabled = true
+	req = httptest.NewRequest("GET", "/settings/period-locks", nil)
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePeriodLocks())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	body = rec.Body.String()
+	if !strings.Contains(body, `id="locks-disabled"`) {
+		t.Error("body does not contain the period locks disabled notice")
+	}
+	if strings.Contains(body, `action="/settings/period-locks" method="post"`) {
+		t.Error("body contains the form to lock a period")
+	}
+}
+
+// TestPeriodSnapshot tests comparing a period snapshot with the period now.
+func TestPeriodSnapshot(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id       string
+		status   int
+		wantBody string
+	}{
+		{"1", http.StatusOK, "no longer reconciled"},
+		{"2", http.StatusNotFound, "The period snapshot was not found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/settings/period-snapshots/"+tt.id, nil)
+		req = mux.SetURLVars(req, map[string]string{"snapshot": tt.id})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePeriodSnapshot())).ServeHTTP(rec, req)
+		if got, want := rec.Code, tt.status; got != want {
+			t.Errorf("snapshot %s: status got %d want %d", tt.id, got, want)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.wantBody) {
+			t.Errorf("snapshot %s: body does not contain %q", tt.id, tt.wantBody)
+		}
+	}
+}
+
+// TestLinkPeriodLocked tests that linking donations to an invoice in a locked period
+// shows the override form, and that the link is made with an override reason.
+func TestLinkPeriodLocked(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reconciler := domain.NewReconciler(testDB, logger)
+	webApp := &WebApp{
+		reconciler:     reconciler,
+		log:            logger,
+		sessions:       sessionStore,
+		accountsRegexp: regexp.MustCompile(".*"),
+		templateFS:     templatesFS,
+		cfg: &config.Config{
+			DataStartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		},
+		newSFClient: NewMockSFClient,
+	}
+	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), token.ExtendedToken{
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example.com",
+		Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	})
+
+	// inv-002 is dated 2025-04-12.
+	from, to := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 30, 0, 0, 0, 0, time.UTC)
+	if err := reconciler.PeriodLockAdd(ctx, from, to, "year end"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		wantBody string
+	}{
+		{
+			name:     "locked",
+			form:     url.Values{"donation-ids": {"0015A00002CrA9PQAV"}},
+			wantBody: `name="override-reason"`,
+		},
+		{
+			name:     "overridden",
+			form:     url.Values{"donation-ids": {"0015A00002CrA9PQAV"}, "override-reason": {"correction"}},
+			wantBody: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/donations/invoice/inv-002/link", strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = mux.SetURLVars(req, map[string]string{"type": "invoice", "id": "inv-002", "action": "link"})
+			rec := httptest.NewRecorder()
+			webApp.ErrorChecker(webApp.handleDonationsLinkUnlink()).ServeHTTP(rec, req)
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Fatalf("got code %d want %d", got, want)
+			}
+			body := rec.Body.String()
+			if (tt.wantBody == "" && body != "") || !strings.Contains(body, tt.wantBody) {
+				t.Errorf("got body %q want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	events, err := reconciler.AuditEventsGet(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Reason != "correction" {
+		t.Errorf("expected a lock and an override event, got %+v", events)
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+// mockapis.go runs the web app against the mock Xero and Salesforce apis, for
+// demonstrations without credentials for either platform.
+
+import (
+	"net/http"
+
+	"github.com/rorycl/reconciler/internal/mockapi"
+)
+
+// SetMockAPIs sets the web app to use the mock apis in place of the Xero and Salesforce
+// platforms. The configuration should already have been set up with
+// mockapi.Server.Configure. This has no effect if called after the server has started.
+func (web *WebApp) SetMockAPIs(apis *mockapi.Server) {
+	if web.started {
+		web.log.Error("SetMockAPIs called after the server started")
+	}
+	web.mockAPIs = apis
+	web.log.Warn("******************************************")
+	web.log.Warn("          Warning: MOCK API mode          ")
+	web.log.Warn("  ** Xero and Salesforce data is canned ** ")
+	web.log.Warn("******************************************")
+}
+
+// withMockAPIs sends the api calls of each request to the mock apis, if set.
+func (web *WebApp) withMockAPIs(next http.Handler) http.Handler {
+	if web.mockAPIs == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(web.mockAPIs.WithClient(r.Context())))
+	})
+}
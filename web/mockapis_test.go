@@ -0,0 +1,84 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/mockapi"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+)
+
+// TestMockAPIsLogin tests logging in to Xero through the mock apis.
+func TestMockAPIsLogin(t *testing.T) {
+
+	cfg, err := config.Load("../config/config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis, err := mockapi.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apis.Configure(cfg)
+
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webApp.SetMockAPIs(apis)
+	handler := webApp.routes()
+
+	// get requests target, keeping the session cookie.
+	var cookies []*http.Cookie
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return rec
+	}
+	// follow returns the target of a redirect.
+	follow := func(rec *httptest.ResponseRecorder, wantPath string) string {
+		t.Helper()
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+		}
+		u, err := url.Parse(rec.Header().Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := u.Path, wantPath; got != want {
+			t.Fatalf("redirect path got %q want %q", got, want)
+		}
+		return u.RequestURI()
+	}
+
+	authorize := follow(get("/xero/init"), mockapi.AuthorizePath)
+	callback := follow(get(authorize), cfg.Web.XeroCallBack)
+	connect := follow(get(callback), "/connect")
+
+	rec := get(connect)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("connect status got %d want %d", got, want)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, mockapi.TenantID) {
+		t.Error("connect page does not show the mock xero tenant")
+	}
+}
@@ -0,0 +1,40 @@
+package web
+
+// neighbours.go steps through the unreconciled invoices and bank transactions of a
+// listing from their detail pages, using the filters of the listing page last used in
+// the session.
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/rorycl/reconciler/db"
+)
+
+// listingNeighbours returns the unreconciled records either side of the record with id
+// in the "invoices" or "bank-transactions" listing page, with the filters of the page
+// last used in the session, or its default filters. No neighbours are returned if the
+// filters are not valid.
+func (web *WebApp) listingNeighbours(ctx context.Context, page, id string) (db.Neighbours, error) {
+
+	form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+	if savedURL := web.sessions.GetString(ctx, "/"+page); savedURL != "" {
+		u, err := url.Parse(savedURL)
+		if err != nil {
+			return db.Neighbours{}, nil
+		}
+		if err := form.DecodeURLParams(u.Query()); err != nil {
+			return db.Neighbours{}, nil
+		}
+	}
+	validator := NewValidator()
+	if form.Validate(validator); !validator.Valid() {
+		return db.Neighbours{}, nil
+	}
+
+	neighbours := web.reconciler.InvoiceNeighbours
+	if page == "bank-transactions" {
+		neighbours = web.reconciler.TransactionNeighbours
+	}
+	return neighbours(ctx, id, form.DateFrom, form.DateTo, form.SearchString, web.assignedTo(ctx, form.Mine), form.SortOrder())
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestDetailNeighbours tests the links to the unreconciled records either side of an
+// invoice or bank transaction in its listing.
+func TestDetailNeighbours(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler appHandler
+		target  string
+		vars    map[string]string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "invoice",
+			handler: webApp.handleInvoiceDetail(),
+			target:  "/invoice/inv-001/link?status=NotLinked&date-from=2025-04-01&date-to=2026-03-31",
+			vars:    map[string]string{"id": "inv-001", "action": "link"},
+			want:    []string{`href="/invoice/inv-prev"`, `href="/invoice/inv-next"`, "2 of 3 unreconciled"},
+		},
+		{
+			name:    "bank transaction",
+			handler: webApp.handleBankTransactionDetail(),
+			target:  "/bank-transaction/bt-001/link?status=NotLinked&date-from=2025-04-01&date-to=2026-03-31",
+			vars:    map[string]string{"id": "bt-001", "action": "link"},
+			want:    []string{`href="/bank-transaction/bt-next"`, "1 of 2 unreconciled"},
+			notWant: []string{`rel="prev"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", tt.target, nil), tt.vars)
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(tt.handler)).ServeHTTP(rec, req)
+			if got, want := rec.Code, http.StatusOK; got != want {
+				t.Fatalf("status got %d want %d", got, want)
+			}
+			body := rec.Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("body does not contain %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(body, s) {
+					t.Errorf("body unexpectedly contains %q", s)
+				}
+			}
+		})
+	}
+	if mock.invoiceNeighbours != 1 || mock.transactionNeighbours != 1 {
+		t.Errorf("neighbours got %d invoice and %d bank transaction calls", mock.invoiceNeighbours, mock.transactionNeighbours)
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+// outbox.go retries the link and unlink updates queued while Salesforce could not be
+// reached, in the background until the queue is empty.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// startOutbox starts retrying the queued Salesforce updates every
+// salesforce.outbox_retry_interval if the retries are not already running. The updates
+// are sent with the provided tokens; the xeroToken may be nil, in which case queued
+// actions updating a Xero invoice fail at the xero step to be retried from the pending
+// actions page. The retries stop once the queue is empty or the Salesforce token can
+// no longer be refreshed, and are restarted by the next queued action or refresh.
+func (web *WebApp) startOutbox(sfToken, xeroToken *token.ExtendedToken, lastRefreshed time.Time) {
+
+	web.outboxMu.Lock()
+	defer web.outboxMu.Unlock()
+	if web.outboxCancel != nil {
+		return
+	}
+
+	// The retries outlive the request which starts them.
+	ctx, cancel := context.WithCancel(context.Background())
+	web.outboxCancel = cancel
+
+	go func() {
+		web.log.Info("salesforce outbox retries started")
+		defer func() {
+			web.outboxMu.Lock()
+			defer web.outboxMu.Unlock()
+			cancel()
+			web.outboxCancel = nil
+			web.log.Info("salesforce outbox retries stopped")
+		}()
+
+		interval := web.cfg.Salesforce.OutboxRetryInterval
+		if interval <= 0 {
+			interval = config.DefaultOutboxRetryInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			results, err := web.outboxRetry(ctx, sfToken, xeroToken, lastRefreshed)
+			if err != nil {
+				web.log.Error(fmt.Sprintf("salesforce outbox retry error: %v", err))
+				return
+			}
+			web.log.Info("salesforce outbox retried", "completed", results.Completed, "failed", results.Failed, "queued", results.Queued)
+			if results.Queued == 0 {
+				return
+			}
+		}
+	}()
+}
+
+// outboxRetry retries the queued Salesforce updates once, refreshing the tokens if
+// needed.
+func (web *WebApp) outboxRetry(ctx context.Context, sfToken, xeroToken *token.ExtendedToken, lastRefreshed time.Time) (domain.OutboxResults, error) {
+
+	if _, err := sfToken.ReuseOrRefresh(ctx, web.cfg.Salesforce.OAuth2Config); err != nil {
+		return domain.OutboxResults{}, fmt.Errorf("salesforce token error: %w", err)
+	}
+	sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+	if err != nil {
+		return domain.OutboxResults{}, fmt.Errorf("salesforce client error: %w", err)
+	}
+	var xeroClient domain.XeroClient
+	if xeroToken != nil {
+		if _, err := xeroToken.ReuseOrRefresh(ctx, web.cfg.Xero.OAuth2Config); err != nil {
+			web.log.Warn(fmt.Sprintf("salesforce outbox xero token error: %v", err))
+		} else if xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken); err != nil {
+			return domain.OutboxResults{}, fmt.Errorf("xero client error: %w", err)
+		}
+	}
+	return web.reconciler.OutboxRetry(ctx, sfClient, xeroClient, web.settings().DataStartDate, lastRefreshed)
+}
+
+// stopOutbox stops the retries of the queued Salesforce updates, if they are running.
+func (web *WebApp) stopOutbox() {
+	web.outboxMu.Lock()
+	defer web.outboxMu.Unlock()
+	if web.outboxCancel != nil {
+		web.outboxCancel()
+	}
+}
+
+// optionalXeroToken returns the valid Xero token of the session, or nil if Xero is not
+// connected.
+func (web *WebApp) optionalXeroToken(ctx context.Context) *token.ExtendedToken {
+	xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken)
+	if err != nil {
+		return nil
+	}
+	return xeroToken
+}
+
+// writeQueued reports the link action of e, whose Salesforce updates were queued as
+// Salesforce could not be reached. The local changes were made, so the request is not
+// failed but redirected to the pending actions page with the message of e, and the
+// retries of the queued updates are started.
+func (web *WebApp) writeQueued(w http.ResponseWriter, r *http.Request, e domain.ErrQueued) {
+
+	ctx := r.Context()
+	if sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken); err == nil {
+		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
+		web.startOutbox(sfToken, web.optionalXeroToken(ctx), sfLastRefresh.Add(refreshDurationWindow))
+	}
+	web.sessions.Put(ctx, "message", e.Msg)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/pending-actions")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/pending-actions", http.StatusSeeOther)
+}
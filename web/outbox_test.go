@@ -0,0 +1,56 @@
+package web
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestStartOutbox(t *testing.T) {
+
+	reconcilerMock := &reconciliationMock{}
+	webApp := &WebApp{
+		log:        slog.Default(),
+		reconciler: reconcilerMock,
+		cfg: &config.Config{
+			Salesforce: config.SalesforceConfig{
+				OAuth2Config:        &oauth2.Config{},
+				OutboxRetryInterval: time.Millisecond,
+			},
+		},
+		newSFClient: NewMockSFClient,
+	}
+	et := &token.ExtendedToken{
+		Type:  token.SalesforceToken,
+		Token: &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	}
+
+	// running reports if the retries are running.
+	running := func() bool {
+		webApp.outboxMu.Lock()
+		defer webApp.outboxMu.Unlock()
+		return webApp.outboxCancel != nil
+	}
+
+	// The mock reports an empty queue after the first retry, after which the retries
+	// stop and can be started again.
+	for range 2 {
+		webApp.startOutbox(et, nil, time.Time{})
+		deadline := time.Now().Add(time.Second)
+		for running() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if running() {
+			t.Fatal("expected the retries to have stopped")
+		}
+	}
+	webApp.outboxMu.Lock()
+	defer webApp.outboxMu.Unlock()
+	if got, want := reconcilerMock.outboxRetry, 2; got != want {
+		t.Errorf("retries got %d want %d", got, want)
+	}
+}
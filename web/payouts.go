@@ -0,0 +1,219 @@
+package web
+
+// payouts.go serves the payout view of a bank transaction. Platforms such as
+// JustGiving, Stripe and Enthuse pay many donations out in a single bank transaction
+// less their fees, so the view lists the donations linked to the payout reference
+// together with the fee line items and the variance, and allows the remaining
+// candidate donations, or a combination of them making up the variance, to be linked
+// in one step. The payout report of the platform may
+// be imported to itemise the payout where the Salesforce donations are incomplete.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/payoutcsv"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// payoutReportMaxUploadSize is the maximum size of an uploaded payout report.
+const payoutReportMaxUploadSize = 10 << 20
+
+// handlePayout serves the /payout/{id} page for the bank transaction id.
+func (web *WebApp) handlePayout() appHandler {
+
+	name := "payout.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"payout.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+
+		batch, err := web.reconciler.PayoutBatchGet(ctx, vars["id"], suggestionsMinScore)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Payout",
+			"CurrentPage": "bank-transactions",
+			"Batch":       batch,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handlePayoutLink serves the /payout/{id}/link endpoint, which links the remaining
+// candidate donations of the payout to the bank transaction id.
+func (web *WebApp) handlePayoutLink() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		transactionID := vars["id"]
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			http.Redirect(w, r, "/connect", http.StatusFound)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for payout linking", err}
+		}
+		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
+
+		results, err := web.reconciler.PayoutCandidatesLink(
+			ctx,
+			sfClient,
+			transactionID,
+			suggestionsMinScore,
+			web.settings().DataStartDate,
+			sfLastRefresh.Add(refreshDurationWindow),
+		)
+		var msg string
+		if _, ok := errors.AsType[domain.ErrQueued](err); ok {
+			return err // reported by ErrorChecker
+		} else if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+			msg = e.Msg + "."
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return err
+		} else {
+			msg = fmt.Sprintf("%d candidate donations were linked to the payout.", results.Linked)
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/payout/"+transactionID, http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handlePayoutCombination serves the /payout/{id}/combination endpoint, which links
+// the combination of candidate donations with the posted "donation-ids" to the bank
+// transaction id.
+func (web *WebApp) handlePayoutCombination() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		transactionID := vars["id"]
+
+		if err := r.ParseForm(); err != nil {
+			return errUsage{"invalid form data", http.StatusBadRequest}
+		}
+		donationIDs := r.PostForm["donation-ids"]
+		if len(donationIDs) == 0 {
+			return errUsage{"no donations were provided", http.StatusBadRequest}
+		}
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			http.Redirect(w, r, "/connect", http.StatusFound)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for payout linking", err}
+		}
+		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
+
+		results, err := web.reconciler.PayoutCombinationLink(
+			ctx,
+			sfClient,
+			transactionID,
+			donationIDs,
+			web.settings().DataStartDate,
+			sfLastRefresh.Add(refreshDurationWindow),
+		)
+		var msg string
+		if _, ok := errors.AsType[domain.ErrQueued](err); ok {
+			return err // reported by ErrorChecker
+		} else if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+			msg = e.Msg + "."
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return err
+		} else {
+			msg = fmt.Sprintf("%d donations making up the payout were linked.", results.Linked)
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/payout/"+transactionID, http.StatusSeeOther)
+		return nil
+	}
+}
+
+// handlePayoutImport serves the /payout/{id}/import endpoint, which stages the items of
+// an uploaded Stripe or JustGiving payout report against the bank transaction id for
+// review before they are recorded.
+func (web *WebApp) handlePayoutImport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		transactionID := vars["id"]
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/payout/"+transactionID, http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, payoutReportMaxUploadSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The payout report could not be read: %v", err))
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		platform, items, err := payoutcsv.Read(file)
+		if err != nil {
+			return redirect(fmt.Sprintf("The payout report is invalid: %v", err))
+		}
+
+		id, err := web.reconciler.PayoutItemsStage(ctx, transactionID, platform, header.Filename, items)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", fmt.Sprintf("The %s payout report was staged; review its items and commit them to record them against the payout.", platform))
+		http.Redirect(w, r, fmt.Sprintf("/imports/%d", id), http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+// TestPayoutImport tests uploading payout reports, which are only staged for review if
+// they can be read.
+func TestPayoutImport(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		csv      string
+		staged   int
+		location string
+	}{
+		{
+			name:     "justgiving report",
+			csv:      "Donation Ref,Donation Date,Donation Amount,Transaction Fee\nJG-1001,15/04/2025,20.00,0.50\n",
+			staged:   1,
+			location: "/imports/1",
+		},
+		{
+			name:     "unknown report",
+			csv:      "date,amount\n2025-04-15,20.00\n",
+			staged:   0,
+			location: "/payout/bt-001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.payoutItemsStage = 0
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, err := mw.CreateFormFile("file", "payout.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fw.Write([]byte(tt.csv))
+			_ = mw.Close()
+
+			req := httptest.NewRequest("POST", "/payout/bt-001/import", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req = mux.SetURLVars(req, map[string]string{"id": "bt-001"})
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePayoutImport())).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusSeeOther; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if got, want := rec.Header().Get("Location"), tt.location; got != want {
+				t.Errorf("location got %q want %q", got, want)
+			}
+			if got, want := mock.payoutItemsStage, tt.staged; got != want {
+				t.Errorf("got %d staged imports want %d", got, want)
+			}
+		})
+	}
+}
+
+// TestPayoutCombination tests linking a combination of candidate donations making up a
+// payout, which must be a combination found for the payout. The test database is used
+// with the Salesforce API client mocked.
+func TestPayoutCombination(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	webApp := &WebApp{
+		reconciler: domain.NewReconciler(testDB, logger),
+		log:        logger,
+		sessions:   sessionStore,
+		cfg: &config.Config{
+			DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		newSFClient: NewMockSFClient,
+	}
+	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), token.ExtendedToken{
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example.com",
+		Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	})
+
+	tests := []struct {
+		ids     []string
+		message string
+	}{
+		{[]string{"sf-opp-018", "sf-opp-019"}, "The donations are no longer a combination making up the payout; review the payout again"},
+		{[]string{"sf-opp-017"}, "1 donations making up the payout were linked."},
+	}
+	for _, tt := range tests {
+		form := url.Values{"donation-ids": tt.ids}
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/payout/bt-unrec-04/combination", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"id": "bt-unrec-04"})
+		rec := httptest.NewRecorder()
+		webApp.ErrorChecker(webApp.handlePayoutCombination()).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Fatalf("got code %d want %d", got, want)
+		}
+		if got, want := webApp.sessions.PopString(ctx, "message"), tt.message; got != want {
+			t.Errorf("got message %q want %q", got, want)
+		}
+	}
+}
+
+// TestPayoutLinkErrors tests the reporting of the errors of linking the candidate
+// donations of a payout, which are reported by ErrorChecker.
+func TestPayoutLinkErrors(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(webApp.stopOutbox)
+
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantBody     []string
+		wantLocation string
+		wantMessage  string
+	}{
+		{
+			name: "queued",
+			err: domain.ErrQueued{
+				ID: 7,
+				Err: domain.ErrSystem{
+					Detail: "BatchUpdateOpportunityRefs error",
+					Err:    apistatus.ErrRemoteAPI{API: "salesforce", StatusCode: 503, Message: "unavailable"},
+					Msg:    "A problem was encountered batch updating salesforce references",
+				},
+				Msg: "Salesforce could not be reached; the link action has been queued as pending action 7",
+			},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/pending-actions",
+			wantMessage:  "Salesforce could not be reached; the link action has been queued as pending action 7",
+		},
+		{
+			name: "conflict",
+			err: domain.ErrConflict{Conflicts: []domain.Conflict{{
+				RecordType: "donation",
+				ID:         "sf-opp-017",
+				Name:       "Online Donation 17",
+				Changes:    []domain.FieldChange{{Field: "Amount", Local: "20.00", Remote: "25.00"}},
+			}}},
+			wantStatus: http.StatusConflict,
+			wantBody:   []string{"donation Online Donation 17", "Last Refreshed", "25.00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.payoutLinkErr = tt.err
+
+			ctx, err := webApp.sessions.Load(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), token.ExtendedToken{
+				Type:        token.SalesforceToken,
+				InstanceURL: "https://example.com",
+				Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+			})
+
+			req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/payout/bt-001/link", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "bt-001"})
+			rec := httptest.NewRecorder()
+			webApp.ErrorChecker(webApp.handlePayoutLink()).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tt.wantStatus; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body does not contain %q\n%s", want, rec.Body.String())
+				}
+			}
+			if got, want := rec.Header().Get("Location"), tt.wantLocation; got != want {
+				t.Errorf("location got %q want %q", got, want)
+			}
+			if got, want := webApp.sessions.PopString(ctx, "message"), tt.wantMessage; got != want {
+				t.Errorf("message got %q want %q", got, want)
+			}
+		})
+	}
+}
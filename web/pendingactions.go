@@ -0,0 +1,124 @@
+package web
+
+// pendingactions.go lists the link actions which failed or were interrupted part way
+// through, and allows each to be retried or reversed.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// handlePendingActions shows the open link actions, or all link actions if the "all"
+// url parameter is set.
+func (web *WebApp) handlePendingActions() appHandler {
+
+	name := "pending-actions.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"pending-actions.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		all := r.URL.Query().Get("all") != ""
+		actions, err := web.reconciler.PendingActionsGet(ctx, all)
+		if err != nil {
+			return errInternal{"failed to retrieve pending actions", err}
+		}
+		data := map[string]any{
+			"PageTitle":   "Pending Actions",
+			"CurrentPage": "pending-actions",
+			"All":         all,
+			"Actions":     actions,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handlePendingActionRetry retries an open link action from the step at which it
+// failed or was interrupted.
+// The target is "/pending-actions/{{ .ID }}/retry".
+func (web *WebApp) handlePendingActionRetry() appHandler {
+	return web.pendingActionHandler("retried", web.reconciler.PendingActionRetry)
+}
+
+// handlePendingActionCompensate reverses an open link action, restoring the previous
+// references.
+// The target is "/pending-actions/{{ .ID }}/compensate".
+func (web *WebApp) handlePendingActionCompensate() appHandler {
+	return web.pendingActionHandler("reversed", web.reconciler.PendingActionCompensate)
+}
+
+// pendingActionFunc is the signature of the reconciler pending action methods.
+type pendingActionFunc func(ctx context.Context, sfClient domain.SalesforceClient, xeroClient domain.XeroClient, id int64, dataStartDate, lastRefreshed time.Time) error
+
+// pendingActionHandler runs fn for the pending action in the url, redirecting to the
+// pending actions page with a message reporting the outcome. A Xero client is only
+// provided if Xero is connected, as only actions updating an invoice reference need
+// one.
+func (web *WebApp) pendingActionHandler(done string, fn pendingActionFunc) appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid pending action id %q", vars["id"]), http.StatusBadRequest}
+		}
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			web.log.Info("sfToken empty, redirecting to connect")
+			http.Redirect(w, r, "/connect", http.StatusSeeOther)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for the pending action", err}
+		}
+		var xeroClient domain.XeroClient
+		if xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken); err == nil {
+			xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken)
+			if err != nil {
+				return errInternal{"failed to create xero client for the pending action", err}
+			}
+		}
+
+		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
+		err = fn(ctx, sfClient, xeroClient, id, web.settings().DataStartDate, sfLastRefresh.Add(refreshDurationWindow))
+
+		msg := fmt.Sprintf("Pending action %d was %s.", id, done)
+		if e, ok := errors.AsType[domain.ErrQueued](err); ok {
+			web.log.Warn(err.Error())
+			web.startOutbox(sfToken, web.optionalXeroToken(ctx), sfLastRefresh.Add(refreshDurationWindow))
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrNotFound](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return errInternal{"failed to run the pending action", err}
+		}
+		web.sessions.Put(ctx, "message", msg)
+		http.Redirect(w, r, "/pending-actions", http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestPendingActionRetry(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	reconciler := domain.NewReconciler(testDB, logger)
+	webApp := &WebApp{
+		reconciler:     reconciler,
+		log:            logger,
+		sessions:       sessionStore,
+		accountsRegexp: regexp.MustCompile(".*"),
+		cfg: &config.Config{
+			DataStartDate:           time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			DonationAccountPrefixes: []string{"53", "55", "57"},
+		},
+		newSFClient:   NewMockSFClient,
+		newXeroClient: NewMockXeroClient,
+	}
+
+	// An invoice reference update without a Xero client fails at the xero step.
+	action := domain.LinkAction{
+		IDRefs:    []salesforce.IDRef{{ID: "sf-opp-001", Ref: "INV-2025-102"}},
+		InvoiceID: "inv-002",
+		Reference: "INV-2025-102",
+	}
+	sfClient, err := NewMockSFClient(ctx, webApp.cfg, logger, &token.ExtendedToken{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reconciler.LinkActionRun(ctx, sfClient, nil, action, webApp.cfg.DataStartDate, time.Time{}); err == nil {
+		t.Fatal("expected the link action to fail")
+	}
+
+	for _, tt := range []token.TokenType{token.SalesforceToken, token.XeroToken} {
+		webApp.sessions.Put(ctx, tt.SessionName(), token.ExtendedToken{
+			Type:        tt,
+			InstanceURL: "https://example.com",
+			TenantID:    "tenant-1",
+			Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+		})
+	}
+
+	retry := func(id string) string {
+		t.Helper()
+		r := mux.NewRouter()
+		r.Handle("/pending-actions/{id:[0-9]+}/retry", webApp.ErrorChecker(webApp.handlePendingActionRetry()))
+		writer := httptest.NewRecorder()
+		r.ServeHTTP(writer, httptest.NewRequestWithContext(ctx, http.MethodPost, "/pending-actions/"+id+"/retry", nil))
+		if got, want := writer.Code, http.StatusSeeOther; got != want {
+			t.Errorf("got code %d want %d", got, want)
+		}
+		return webApp.sessions.PopString(ctx, "message")
+	}
+
+	if got, want := retry("1"), "Pending action 1 was retried."; got != want {
+		t.Errorf("got message %q want %q", got, want)
+	}
+	if got, want := retry("1"), "pending action 1 is done and cannot be changed"; got != want {
+		t.Errorf("got message %q want %q", got, want)
+	}
+	if got, want := retry("99"), "pending action 99 was not found"; got != want {
+		t.Errorf("got message %q want %q", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package web
+
+// preferences.go holds the display preferences of the listing pages, being the page
+// length and the visible columns, in the session of each user.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+)
+
+// pageLens are the page lengths which may be chosen for the listing pages. The default
+// is the configured web.page_length.
+var pageLens = config.PageLengths
+
+// listingColumns are the columns of each listing page which may be hidden. The first
+// column of each listing, the invoice number, contact or donation name, is always
+// shown.
+var listingColumns = map[string][]string{
+	"invoices":          {"date", "contact", "status", "total", "donations", "variance", "reconciled"},
+	"bank-transactions": {"date", "reference", "status", "total", "donations", "variance", "reconciled"},
+	"donations":         {"date", "payout-reference", "amount", "linked"},
+}
+
+// Preferences are the display preferences of a listing page.
+type Preferences struct {
+	Page    string
+	PageLen int
+	Hidden  []string
+}
+
+// PageLens returns the page lengths which may be chosen.
+func (p Preferences) PageLens() []int {
+	return pageLens
+}
+
+// Columns returns the columns of the listing page which may be hidden.
+func (p Preferences) Columns() []string {
+	return listingColumns[p.Page]
+}
+
+// Show reports if column is visible.
+func (p Preferences) Show(column string) bool {
+	return !slices.Contains(p.Hidden, column)
+}
+
+// preferencesKeys returns the session keys of the page length and hidden columns of a
+// listing page.
+func preferencesKeys(page string) (pageLenKey, hiddenKey string) {
+	return "preferences-page-len:" + page, "preferences-hidden:" + page
+}
+
+// listingPreferences returns the display preferences of a listing page from the
+// session, with the configured page length if none has been chosen.
+func (web *WebApp) listingPreferences(ctx context.Context, page string) Preferences {
+	pageLenKey, hiddenKey := preferencesKeys(page)
+	p := Preferences{
+		Page:    page,
+		PageLen: web.sessions.GetInt(ctx, pageLenKey),
+	}
+	if !slices.Contains(pageLens, p.PageLen) {
+		p.PageLen = web.settings().PageLength
+	}
+	if !slices.Contains(pageLens, p.PageLen) {
+		p.PageLen = pageLens[0]
+	}
+	if hidden := web.sessions.GetString(ctx, hiddenKey); hidden != "" {
+		p.Hidden = strings.Split(hidden, ",")
+	}
+	return p
+}
+
+// handlePreferences saves the display preferences of a listing page, being the
+// "page-len" form value and the visible "columns" form values, redirecting to the
+// first page of the listing with the filters in the "params" form value.
+// The target is "/preferences/{{ .CurrentPage }}".
+func (web *WebApp) handlePreferences() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "page")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		page := vars["page"]
+		columns, ok := listingColumns[page]
+		if !ok {
+			return errUsage{fmt.Sprintf("invalid listing page %q", page), http.StatusBadRequest}
+		}
+
+		if err := r.ParseForm(); err != nil {
+			return errUsage{"could not read the form", http.StatusBadRequest}
+		}
+		pageLen, err := strconv.Atoi(r.PostForm.Get("page-len"))
+		if err != nil || !slices.Contains(pageLens, pageLen) {
+			return errUsage{fmt.Sprintf("invalid page length %q", r.PostForm.Get("page-len")), http.StatusBadRequest}
+		}
+		var hidden []string
+		for _, c := range columns {
+			if !slices.Contains(r.PostForm["columns"], c) {
+				hidden = append(hidden, c)
+			}
+		}
+
+		pageLenKey, hiddenKey := preferencesKeys(page)
+		web.sessions.Put(ctx, pageLenKey, pageLen)
+		web.sessions.Put(ctx, hiddenKey, strings.Join(hidden, ","))
+
+		// Return to the first page of the listing with the current filters, if these are
+		// valid.
+		target := "/" + page
+		if values, err := url.ParseQuery(r.PostFormValue("params")); err == nil && len(values) > 0 {
+			values.Del("page")
+			form := web.savedSearchForm(page)
+			validator := NewValidator()
+			if err := form.DecodeURLParams(values); err == nil {
+				if form.Validate(validator); validator.Valid() {
+					if params, err := form.AsURLParams(); err == nil {
+						target += "?" + params
+					}
+				}
+			}
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestPreferences tests saving the display preferences of a listing page in the
+// session.
+func TestPreferences(t *testing.T) {
+
+	cfg := &config.Config{
+		Web:        config.WebConfig{PageLength: 25},
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/preferences/invoices", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"page": "invoices"})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handlePreferences())).ServeHTTP(rec, req)
+		return rec
+	}
+
+	// An invalid page length is refused.
+	if got, want := post(url.Values{"page-len": {"1000"}}).Code, http.StatusBadRequest; got != want {
+		t.Errorf("invalid page length status got %d want %d", got, want)
+	}
+
+	rec := post(url.Values{
+		"page-len": {"50"},
+		"columns":  {"date", "contact", "total", "reconciled", "unknown"},
+		"params":   {"status=All&date-from=2025-04-01&date-to=2026-03-31&page=4"},
+	})
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/invoices?date-from=2025-04-01&date-to=2026-03-31&page=1&search=&status=All"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+
+	// Read the preferences back with the session cookie.
+	var got, donations Preferences
+	req := httptest.NewRequest("GET", "/invoices", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	webApp.sessions.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = webApp.listingPreferences(r.Context(), "invoices")
+		donations = webApp.listingPreferences(r.Context(), "donations")
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	want := Preferences{Page: "invoices", PageLen: 50, Hidden: []string{"status", "donations", "variance"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("preferences mismatch (-want +got):\n%s", diff)
+	}
+	if !got.Show("date") || got.Show("status") {
+		t.Errorf("unexpected visible columns %v", got.Hidden)
+	}
+
+	// Preferences default to the configured page length with all columns shown.
+	if donations.PageLen != cfg.Web.PageLength || len(donations.Hidden) != 0 {
+		t.Errorf("unexpected default preferences %+v", donations)
+	}
+}
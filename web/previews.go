@@ -0,0 +1,206 @@
+package web
+
+// previews.go renders templates with fixture data in development mode, at
+// /__preview/{template}, so that the effect of template changes may be seen without
+// the data needed to reach the pages or partials showing them. The templates are parsed
+// afresh for each preview. The same previews are rendered to golden files by the tests.
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// templatePreview is the fixture data with which a template is previewed, and the
+// template files to parse for it. A template named as a file, such as "error.html", is
+// a page, while other templates, being partials, are shown within the preview page.
+type templatePreview struct {
+	files []string
+	data  func() any
+}
+
+// previewTime is the fixed time of the preview fixtures, so that the golden files of
+// the previews do not change over time.
+var previewTime = time.Date(2025, 5, 14, 10, 30, 0, 0, time.UTC)
+
+// templatePreviews are the previews by template name.
+var templatePreviews = map[string]templatePreview{
+	"error.html": {
+		files: []string{"base.html", "error.html", "partial-link-conflicts.html"},
+		data: func() any {
+			return map[string]any{
+				"Status":        http.StatusNotFound,
+				"StatusText":    http.StatusText(http.StatusNotFound),
+				"Message":       "The invoice was not found",
+				"CorrelationID": "preview-correlation-id",
+			}
+		},
+	},
+	"annotations.html": {
+		files: []string{"base.html", "nav.html", "partial-annotations.html", "partial-assignment.html", "annotations.html"},
+		data: func() any {
+			return map[string]any{
+				"PageTitle":   "Notes on donation sf-opp-001",
+				"CurrentPage": "annotations",
+				"Typer":       "donation",
+				"ID":          "sf-opp-001",
+				"BackURL":     "/donations",
+				"Annotations": previewAnnotations(),
+				"AssignedTo":  "",
+				"Message":     "",
+			}
+		},
+	},
+	"partial-annotations": {
+		files: []string{"partial-annotations.html"},
+		data: func() any {
+			return map[string]any{
+				"Typer":       "invoice",
+				"ID":          "inv-001",
+				"Annotations": previewAnnotations(),
+			}
+		},
+	},
+	"partial-assignment": {
+		files: []string{"partial-assignment.html"},
+		data: func() any {
+			return map[string]any{
+				"Typer":      "bank-transaction",
+				"ID":         "bt-001",
+				"AssignedTo": "",
+			}
+		},
+	},
+	"partial-donation-splits": {
+		files: []string{"partial-donation-splits.html"},
+		data: func() any {
+			return map[string]any{
+				"Typer": "invoice",
+				"ID":    "inv-001",
+				"Splits": []db.DonationSplit{{
+					ID:             1,
+					DonationID:     "sf-opp-001",
+					DonationName:   "Spring Appeal Gift",
+					DonationAmount: money.FromFloat(250),
+					RecordType:     "invoice",
+					RecordID:       "inv-001",
+					Amount:         money.FromFloat(100),
+					Allocated:      money.FromFloat(175.50),
+					LinkedAt:       previewTime,
+				}},
+			}
+		},
+	},
+	"partial-link-conflicts": {
+		files: []string{"partial-link-conflicts.html"},
+		data: func() any {
+			return map[string]any{
+				"Conflicts": []domain.Conflict{
+					{
+						RecordType:     "invoice",
+						ID:             "inv-001",
+						Name:           "INV-2025-101",
+						LocalModified:  previewTime.Add(-48 * time.Hour),
+						RemoteModified: previewTime,
+						ModifiedBy:     "Finance Team",
+						Changes:        []domain.FieldChange{{Field: "Reference", Local: "", Remote: "JG-2025-05"}},
+					},
+					{
+						RecordType:     "donation",
+						ID:             "sf-opp-002",
+						Name:           "Marathon Sponsorship",
+						LocalModified:  previewTime.Add(-48 * time.Hour),
+						RemoteModified: previewTime,
+						Deleted:        true,
+					},
+				},
+			}
+		},
+	},
+}
+
+// previewAnnotations are the notes of the annotation previews.
+func previewAnnotations() []db.Annotation {
+	return []db.Annotation{
+		{ID: 2, Note: "Query with fundraising team", Flag: true, CreatedAt: previewTime},
+		{ID: 1, Note: "Gift Aid declaration received", CreatedAt: previewTime.Add(-24 * time.Hour)},
+	}
+}
+
+// previewNames returns the names of the previewed templates, in name order.
+func previewNames() []string {
+	names := make([]string, 0, len(templatePreviews))
+	for name := range templatePreviews {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// renderPreview renders the preview of the template with name. Partials are shown
+// within the preview page.
+func (web *WebApp) renderPreview(w http.ResponseWriter, r *http.Request, name string) error {
+
+	preview, ok := templatePreviews[name]
+	if !ok {
+		return errUsage{fmt.Sprintf("template %q has no preview", name), http.StatusNotFound}
+	}
+	templates, err := template.New("").Funcs(web.templateFuncs).ParseFS(web.templateFS, preview.files...)
+	if err != nil {
+		return errInternal{"could not parse the preview templates", err}
+	}
+	if strings.HasSuffix(name, ".html") {
+		return web.render(w, r, templates, name, preview.data())
+	}
+
+	tpl, err := templates.Clone()
+	if err != nil {
+		return errInternal{"could not clone the preview templates", err}
+	}
+	tpl.Funcs(web.requestTemplateFuncs(r.Context()))
+	buf := new(bytes.Buffer)
+	if err := tpl.ExecuteTemplate(buf, name, preview.data()); err != nil {
+		return errInternal{fmt.Sprintf("could not render the %s preview", name), err}
+	}
+	return web.renderPreviewPage(w, r, name, template.HTML(buf.String()))
+}
+
+// renderPreviewPage renders the preview page, listing the previews and showing the
+// rendered partial with name, if any.
+func (web *WebApp) renderPreviewPage(w http.ResponseWriter, r *http.Request, name string, rendered template.HTML) error {
+	templates, err := template.New("").Funcs(web.templateFuncs).ParseFS(web.templateFS, "base.html", "preview.html")
+	if err != nil {
+		return errInternal{"could not parse the preview page templates", err}
+	}
+	data := map[string]any{
+		"PageTitle": "Template Previews",
+		"Names":     previewNames(),
+		"Name":      name,
+		"Rendered":  rendered,
+	}
+	return web.render(w, r, templates, "preview.html", data)
+}
+
+// handlePreviews lists the templates which may be previewed.
+func (web *WebApp) handlePreviews() appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return web.renderPreviewPage(w, r, "", "")
+	}
+}
+
+// handlePreview renders the preview of the template named by the "template" route
+// variable.
+func (web *WebApp) handlePreview() appHandler {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		return web.renderPreview(w, r, mux.Vars(r)["template"])
+	}
+}
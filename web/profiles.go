@@ -0,0 +1,65 @@
+package web
+
+// profiles.go serves the switching of the organisation profile of the configuration
+// file. Each profile has its own database and API connections, so a switch closes the
+// web server and the database, and the program starts again with the chosen profile.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// SetProfiles sets the names of the configured profiles, the current profile and the
+// func called to switch to another profile. The switch func is expected to shut the
+// web server down.
+func (web *WebApp) SetProfiles(profiles []string, current string, switchProfile func(profile string)) {
+	web.profiles = profiles
+	web.profile = current
+	web.switchProfile = switchProfile
+}
+
+// Shutdown gracefully shuts the web server down, so that StartServer returns
+// http.ErrServerClosed.
+func (web *WebApp) Shutdown(ctx context.Context) error {
+	return web.server.Shutdown(ctx)
+}
+
+// handleProfileSwitch serves the /profile endpoint, which switches to the posted
+// profile.
+func (web *WebApp) handleProfileSwitch() appHandler {
+
+	name := "profile.html"
+	tpls := []string{
+		"base.html",
+		"profile.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		profile := r.PostFormValue("profile")
+		if web.switchProfile == nil || !slices.Contains(web.profiles, profile) {
+			return errUsage{fmt.Sprintf("invalid profile %q", profile), http.StatusBadRequest}
+		}
+		if profile == web.profile {
+			http.Redirect(w, r, "/connect", http.StatusSeeOther)
+			return nil
+		}
+
+		// Stop any change subscription and outbox retries, and switch once the page has
+		// been written.
+		web.stopSFSubscription()
+		web.stopOutbox()
+		web.log.Info(fmt.Sprintf("switching to profile %s", profile))
+		data := map[string]any{
+			"Profile": profile,
+		}
+		if err := web.render(w, r, templates, name, data); err != nil {
+			return err
+		}
+		go web.switchProfile(profile)
+		return nil
+	}
+}
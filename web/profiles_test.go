@@ -0,0 +1,81 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestProfileSwitch tests that posting another profile to /profile calls the switch
+// func, and that unknown profiles are refused.
+func TestProfileSwitch(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switched := make(chan string, 1)
+	webApp.SetProfiles([]string{"main", "trading"}, "main", func(profile string) {
+		switched <- profile
+	})
+	handler := webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleProfileSwitch()))
+
+	post := func(profile string) *httptest.ResponseRecorder {
+		form := url.Values{"profile": {profile}}
+		req := httptest.NewRequest("POST", "/profile", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		profile  string
+		status   int
+		switched bool
+	}{
+		{"trading", http.StatusOK, true},
+		{"main", http.StatusSeeOther, false},
+		{"charity", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			rec := post(tt.profile)
+			if got, want := rec.Code, tt.status; got != want {
+				t.Fatalf("status got %d want %d", got, want)
+			}
+			if !tt.switched {
+				return
+			}
+			if !strings.Contains(rec.Body.String(), "<b>"+tt.profile+"</b> profile") {
+				t.Errorf("unexpected body:\n%s", rec.Body.String())
+			}
+			select {
+			case got := <-switched:
+				if got != tt.profile {
+					t.Errorf("switched to %q want %q", got, tt.profile)
+				}
+			case <-time.After(time.Second):
+				t.Error("timed out waiting for the profile switch")
+			}
+		})
+	}
+}
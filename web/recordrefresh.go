@@ -0,0 +1,93 @@
+package web
+
+// recordrefresh.go refreshes a single invoice, bank transaction or donation from Xero
+// or Salesforce, so that a remote correction can be picked up without a full refresh.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// recordRefreshReturn matches the detail pages a donation refresh may return to.
+var recordRefreshReturn = regexp.MustCompile(`^/(?:invoice|bank-transaction)/[A-Za-z0-9_-]+(?:/(?:link|unlink))?$`)
+
+// handleRecordRefresh refreshes a single record and redirects to its detail page with
+// a message reporting the outcome. As donations have no detail page, a donation
+// refresh redirects to the invoice or bank transaction detail page in the "return"
+// form value, or to the donations listing. HTMX requests are redirected using the
+// HX-Redirect header.
+// The target is "/refresh/{type}/{id}".
+func (web *WebApp) handleRecordRefresh() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+
+		redirect := func(url string) {
+			if r.Header.Get("HX-Request") != "" {
+				w.Header().Set("HX-Redirect", url)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			http.Redirect(w, r, url, http.StatusSeeOther)
+		}
+
+		returnURL := fmt.Sprintf("/%s/%s", typer, id)
+		var xeroClient domain.XeroClient
+		var sfClient domain.SalesforceClient
+		if typer == "donation" {
+			returnURL = "/donations"
+			if u := r.FormValue("return"); recordRefreshReturn.MatchString(u) {
+				returnURL = u
+			}
+			sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+			if err != nil {
+				web.log.Info("sfToken empty, redirecting to connect")
+				redirect("/connect")
+				return nil
+			}
+			sfClient, err = web.newSFClient(ctx, web.cfg, web.log, sfToken)
+			if err != nil {
+				return errInternal{"failed to create salesforce client for the record refresh", err}
+			}
+		} else {
+			xeroToken, err := web.getValidTokenFromSession(ctx, token.XeroToken)
+			if err != nil {
+				web.log.Info("xeroToken empty, redirecting to connect")
+				redirect("/connect")
+				return nil
+			}
+			xeroClient, err = web.newXeroClient(ctx, web.log, web.donationAccountsRegexp(), xeroToken)
+			if err != nil {
+				return errInternal{"failed to create xero client for the record refresh", err}
+			}
+		}
+
+		err = web.reconciler.RecordRefresh(ctx, xeroClient, sfClient, typer, id)
+
+		msg := fmt.Sprintf("The %s was refreshed.", strings.ReplaceAll(typer, "-", " "))
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			msg = e.Msg
+		} else if e, ok := errors.AsType[domain.ErrSystem](err); ok {
+			web.log.Error(err.Error(), "detail", e.Detail)
+			msg = e.Msg
+		} else if err != nil {
+			return errInternal{"failed to refresh the record", err}
+		}
+		web.sessions.Put(ctx, "message", msg)
+		redirect(returnURL)
+		return nil
+	}
+}
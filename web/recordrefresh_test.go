@@ -0,0 +1,122 @@
+package web
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
+)
+
+func TestRecordRefresh(t *testing.T) {
+
+	gob.Register(time.Time{})
+	gob.Register(token.ExtendedToken{})
+
+	testDB, closeDB := setupRefreshTestDB(t)
+	t.Cleanup(closeDB)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	webApp := &WebApp{
+		reconciler:     domain.NewReconciler(testDB, logger),
+		log:            logger,
+		sessions:       sessionStore,
+		accountsRegexp: regexp.MustCompile(".*"),
+		cfg: &config.Config{
+			DataStartDate:           time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			DonationAccountPrefixes: []string{"53", "55", "57"},
+		},
+		newSFClient:   NewMockSFClient,
+		newXeroClient: NewMockXeroClient,
+	}
+	webApp.sessions.Put(ctx, token.SalesforceToken.SessionName(), token.ExtendedToken{
+		Type:        token.SalesforceToken,
+		InstanceURL: "https://example.com",
+		Token:       &oauth2.Token{AccessToken: "valid-token", Expiry: time.Now().Add(time.Hour)},
+	})
+
+	refresh := func(path, returnURL string, htmx bool) *httptest.ResponseRecorder {
+		t.Helper()
+		r := mux.NewRouter()
+		r.Handle("/refresh/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}", webApp.ErrorChecker(webApp.handleRecordRefresh()))
+		form := url.Values{"return": {returnURL}}
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		writer := httptest.NewRecorder()
+		r.ServeHTTP(writer, req)
+		return writer
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		returnURL string
+		htmx      bool
+		location  string
+		message   string
+	}{
+		{
+			name:      "donation",
+			path:      "/refresh/donation/sf-opp-001",
+			returnURL: "/invoice/inv-001/unlink",
+			htmx:      true,
+			location:  "/invoice/inv-001/unlink",
+			message:   "The donation was refreshed.",
+		},
+		{
+			name:      "donation invalid return",
+			path:      "/refresh/donation/sf-opp-001",
+			returnURL: "https://example.com/invoice/inv-001",
+			location:  "/donations",
+			message:   "The donation was refreshed.",
+		},
+		{
+			name:     "invoice without xero",
+			path:     "/refresh/invoice/inv-001",
+			location: "/connect",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := refresh(tt.path, tt.returnURL, tt.htmx)
+			location := writer.Header().Get("Location")
+			wantCode := http.StatusSeeOther
+			if tt.htmx {
+				location = writer.Header().Get("HX-Redirect")
+				wantCode = http.StatusOK
+			}
+			if got, want := writer.Code, wantCode; got != want {
+				t.Errorf("got code %d want %d", got, want)
+			}
+			if got, want := location, tt.location; got != want {
+				t.Errorf("got redirect %q want %q", got, want)
+			}
+			if got, want := webApp.sessions.PopString(ctx, "message"), tt.message; got != want {
+				t.Errorf("got message %q want %q", got, want)
+			}
+		})
+	}
+}
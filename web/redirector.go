@@ -3,6 +3,8 @@ package web
 import (
 	"context"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/alexedwards/scs/v2"
 )
@@ -59,3 +61,30 @@ func redirectCheck(
 
 	return currentURL, false, nil
 }
+
+// restoreListingURL redirects a request for the listing page at thisURL without url
+// parameters to the url of the page last used in the session, so that returning to the
+// page from a detail page restores its filters. Otherwise the url of the request is
+// saved. It reports if a redirect was made.
+func restoreListingURL(ctx context.Context, sessions *scs.SessionManager, w http.ResponseWriter, r *http.Request, thisURL string) bool {
+	if r.URL.RawQuery != "" {
+		sessions.Put(ctx, thisURL, thisURL+"?"+r.URL.RawQuery)
+		return false
+	}
+	savedURL := sessions.GetString(ctx, thisURL)
+	if savedURL == "" {
+		return false
+	}
+	http.Redirect(w, r, savedURL, http.StatusSeeOther)
+	return true
+}
+
+// refererTarget returns the path and query of the referring page of a request if it is
+// a page of this site, such as to return to after a form post, or otherwise fallback.
+func refererTarget(r *http.Request, fallback string) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Host != r.Host || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") {
+		return fallback
+	}
+	return u.RequestURI()
+}
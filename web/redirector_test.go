@@ -4,6 +4,8 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,7 +13,10 @@ import (
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
 	"github.com/rorycl/reconciler/internal/token"
+	"golang.org/x/oauth2"
 )
 
 func TestRedirectCheck(t *testing.T) {
@@ -34,7 +39,7 @@ func TestRedirectCheck(t *testing.T) {
 	}{
 		{
 			name:             "ok search form",
-			form:             NewSearchForm(startDate, nil),
+			form:             NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:              "/invoices?date-from=2025-06-01&date-to=2025-07-01&search=search_string&page=1",
 			thisURL:          "/invoices",
 			expectedURL:      "/invoices?date-from=2025-06-01&date-to=2025-07-01&page=1&search=search_string&status=NotReconciled",
@@ -43,7 +48,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "reset search form 1",
-			form:             NewSearchForm(startDate, nil),
+			form:             NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:              "/invoices?reset=true&date-from=2025-06-01&date-to=2025-05-01&search=search_string&page=1",
 			thisURL:          "/invoices",
 			expectedURL:      "/invoices?date-from=2026-02-01&date-to=2027-04-01&page=1&search=&status=NotReconciled",
@@ -52,7 +57,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "reset search form 2",
-			form:             NewSearchForm(startDate, nil),
+			form:             NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:              "/invoices?reset=true",
 			thisURL:          "/invoices",
 			expectedURL:      "/invoices?date-from=2026-02-01&date-to=2027-04-01&page=1&search=&status=NotReconciled",
@@ -61,7 +66,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "naked search form empty session",
-			form:             NewSearchForm(startDate, nil),
+			form:             NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:              "/invoices",
 			thisURL:          "/invoices",
 			expectedURL:      "/invoices?date-from=2026-02-01&date-to=2027-04-01&page=1&search=&status=NotReconciled",
@@ -70,7 +75,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "naked search form loaded session",
-			form:             NewSearchForm(startDate, nil),
+			form:             NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:              "/invoices",
 			sessionURL:       "/invoices?date-from=2025-06-01&date-to=2025-07-01&page=1&search=search_string&status=NotReconciled",
 			thisURL:          "/invoices",
@@ -80,7 +85,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:        "invalid search form",
-			form:        NewSearchForm(startDate, nil),
+			form:        NewSearchForm(startDate, nil, time.Now().UTC()),
 			url:         "/invoices?x&n42=true&&",
 			sessionURL:  "/invoices?date-from=2025-31-31&date-to=2025-07-01&page=1&search=search_string&status=NotReconciled",
 			thisURL:     "/invoices",
@@ -88,7 +93,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "donations form ok",
-			form:             NewSearchDonationsForm(startDate, endDate),
+			form:             NewSearchDonationsForm(startDate, endDate, time.Now().UTC()),
 			url:              `/donations?date-from=2025-06-01&date-to=2025-07-01&page=1&payout-reference=payout-ref&search=search+string&status=All`,
 			thisURL:          "/donations",
 			expectedURL:      "/donations?date-from=2025-06-01&date-to=2025-07-01&page=1&payout-reference=payout-ref&search=search+string&status=All",
@@ -97,7 +102,7 @@ func TestRedirectCheck(t *testing.T) {
 		},
 		{
 			name:             "donations reset ok",
-			form:             NewSearchDonationsForm(startDate, endDate),
+			form:             NewSearchDonationsForm(startDate, endDate, time.Now().UTC()),
 			url:              `/donations?reset=true&date-from=2025-06-01&date-to=2025-07-01&page=1&payout-reference=payout-ref&search=search+string&status=All`,
 			thisURL:          "/donations",
 			expectedURL:      "/donations?date-from=2026-02-01&date-to=2026-03-01&page=1&payout-reference=&search=&status=NotLinked",
@@ -141,3 +146,64 @@ func TestRedirectCheck(t *testing.T) {
 		})
 	}
 }
+
+// TestRestoreListingURL shows a listing page requested without parameters, such as
+// from the navigation or a detail page, restoring the last filters of the session.
+func TestRestoreListingURL(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cookies []*http.Cookie
+	serve := func(h appHandler, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(h)).ServeHTTP(rec, req)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		handler  appHandler
+		target   string
+		status   int
+		location string
+	}{
+		{"search without a previous search", webApp.handleSearch(), "/search", http.StatusOK, ""},
+		{"search", webApp.handleSearch(), "/search?q=spring+gift", http.StatusOK, ""},
+		{"search restored", webApp.handleSearch(), "/search", http.StatusSeeOther, "/search?q=spring+gift"},
+		{"invoices", webApp.handleInvoices(), "/invoices?status=All&range=this-fy&date-from=2025-04-01&date-to=2026-03-31&search=x&page=1", http.StatusOK, ""},
+		{"invoices restored", webApp.handleInvoices(), "/invoices", http.StatusSeeOther, "/invoices?date-from=2025-04-01&date-to=2026-03-31&page=1&range=this-fy&search=x&status=All"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.handler, tt.target)
+		if got, want := rec.Code, tt.status; got != want {
+			t.Fatalf("%s: status got %d want %d", tt.name, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%s: location got %q want %q", tt.name, got, want)
+		}
+	}
+	if got, want := mock.search, 1; got != want {
+		t.Errorf("searches got %d want %d", got, want)
+	}
+}
@@ -3,12 +3,18 @@ package web
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
+	"github.com/rorycl/reconciler/apiclients/salesforce"
 	"github.com/rorycl/reconciler/domain"
 	"github.com/rorycl/reconciler/internal/token"
 )
 
+// refreshWarningsSessionKey is the session key for the description of the refreshed
+// records with missing or unreadable dates, shown on the refresh page.
+const refreshWarningsSessionKey = "refresh-warnings"
+
 // refreshXeroRecords retrieves the Xero organisation details, accounts, bank
 // transactions and invoices (depending on lastRefresh) and inserts these in the
 // database. If lastRefresh is time.IsZero, all records are provided. Otherwise, only
@@ -18,8 +24,8 @@ import (
 // needed to update session information.
 func (web *WebApp) refreshXeroRecords(ctx context.Context) (*domain.RefreshXeroResults, error) {
 
-	dataStartDate := web.cfg.DataStartDate
-	accountsRegexp := web.cfg.DonationAccountCodesAsRegex()
+	dataStartDate := web.settings().DataStartDate
+	accountsRegexp := web.donationAccountsRegexp()
 
 	sessionRefreshKey := "xero-refreshed-datetime"
 	updateStart := time.Now()
@@ -38,7 +44,7 @@ func (web *WebApp) refreshXeroRecords(ctx context.Context) (*domain.RefreshXeroR
 	}
 
 	// Connect the Xero client.
-	xeroClient, err := web.newXeroClient(ctx, web.log, web.cfg.DonationAccountCodesAsRegex(), xeroToken)
+	xeroClient, err := web.newXeroClient(ctx, web.log, accountsRegexp, xeroToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create xero client: %w", err)
 	}
@@ -58,7 +64,11 @@ func (web *WebApp) refreshXeroRecords(ctx context.Context) (*domain.RefreshXeroR
 	if err != nil {
 		return nil, err
 	}
-	// Update the session key
+	// Update the session key, from the start of the running refresh if this request
+	// waited for it.
+	if results.Attached {
+		updateStart = results.Started
+	}
 	web.sessions.Put(ctx, sessionRefreshKey, updateStart)
 
 	return results, nil
@@ -69,7 +79,7 @@ func (web *WebApp) refreshXeroRecords(ctx context.Context) (*domain.RefreshXeroR
 // those modified since lastRefresh.
 func (web *WebApp) refreshSalesforceRecords(ctx context.Context) (*domain.RefreshSalesforceResults, error) {
 
-	dataStartDate := web.cfg.DataStartDate
+	dataStartDate := web.settings().DataStartDate
 
 	sessionRefreshKey := "sf-refreshed-datetime"
 	updateStart := time.Now()
@@ -92,14 +102,73 @@ func (web *WebApp) refreshSalesforceRecords(ctx context.Context) (*domain.Refres
 		return nil, fmt.Errorf("failed to create salesforce client: %w", err)
 	}
 
-	// Run the Salesforce refresher.
-	results, err := web.reconciler.SalesforceRecordsRefresh(ctx, sfClient, dataStartDate, lastRefresh)
+	// Run the Salesforce refresher, recording the progress of the query for
+	// /refresh/progress. A request made while a refresh is running waits for it, and
+	// leaves its progress to be reported.
+	if web.startSFProgress() {
+		defer web.setSFProgress(nil)
+	}
+	queryCtx := salesforce.WithProgress(ctx, func(p salesforce.QueryProgress) {
+		web.setSFProgress(&p)
+	})
+	results, err := web.reconciler.SalesforceRecordsRefresh(queryCtx, sfClient, dataStartDate, lastRefresh)
 	if err != nil {
 		return nil, err
 	}
+	if results.Attached {
+		updateStart = results.Started
+	}
 
-	// Update the session key
+	// Update the session key and record the instance url for deep links.
 	web.sessions.Put(ctx, sessionRefreshKey, updateStart)
+	web.saveSFInstanceURL(ctx, sfToken.InstanceURL)
+	web.startSFSubscription(sfToken)
+	web.startOutbox(sfToken, web.optionalXeroToken(ctx), updateStart.Add(refreshDurationWindow))
 
 	return results, nil
 }
+
+// startSFProgress records the start of a Salesforce refresh, unless one is already
+// running, reporting if it was recorded.
+func (web *WebApp) startSFProgress() bool {
+	web.sfProgressMu.Lock()
+	defer web.sfProgressMu.Unlock()
+	if web.sfProgress != nil {
+		return false
+	}
+	web.sfProgress = &salesforce.QueryProgress{}
+	return true
+}
+
+// setSFProgress records the progress of the running Salesforce refresh, or clears it
+// if p is nil.
+func (web *WebApp) setSFProgress(p *salesforce.QueryProgress) {
+	web.sfProgressMu.Lock()
+	defer web.sfProgressMu.Unlock()
+	web.sfProgress = p
+}
+
+// handleRefreshProgress serves the htmx partial /refresh/progress reporting the number
+// of Salesforce records retrieved by the running refresh, which is polled by the
+// refresh page while the refresh runs.
+func (web *WebApp) handleRefreshProgress() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		web.sfProgressMu.Lock()
+		p := web.sfProgress
+		web.sfProgressMu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch {
+		case p == nil:
+			return nil
+		case p.Page == 0:
+			_, err := fmt.Fprint(w, "Retrieving Salesforce records...")
+			return err
+		default:
+			_, err := fmt.Fprintf(w, "Retrieved %d of %d Salesforce records (page %d).", p.Fetched, p.Total, p.Page)
+			return err
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/gob"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"regexp"
 	"testing"
 	"time"
@@ -39,12 +41,28 @@ func (mxc *mockXeroClient) GetAccounts(ctx context.Context, ifModifiedSince time
 func (mxc *mockXeroClient) GetBankTransactions(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.BankTransaction, error) {
 	mxc.getCount++
 	mxc.log.Info(fmt.Sprintf("GetBankTransactions %d", mxc.getCount))
-	return []xero.BankTransaction{{BankTransactionID: fmt.Sprintf("btId-%d", mxc.getCount)}}, nil
+	return []xero.BankTransaction{{
+		BankTransactionID: fmt.Sprintf("btId-%d", mxc.getCount),
+		Date:              xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}}, nil
 }
 func (mxc *mockXeroClient) GetInvoices(ctx context.Context, fromDate time.Time, ifModifiedSince time.Time, accountsRegexp *regexp.Regexp) ([]xero.Invoice, error) {
 	mxc.getCount++
 	mxc.log.Info(fmt.Sprintf("Invoices %d", mxc.getCount))
-	return []xero.Invoice{{InvoiceID: fmt.Sprintf("iId-%d", mxc.getCount)}}, nil
+	return []xero.Invoice{{
+		InvoiceID: fmt.Sprintf("iId-%d", mxc.getCount),
+		Date:      xero.XeroDateTime{Time: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}}, nil
+}
+func (mxc *mockXeroClient) GetContacts(ctx context.Context, ifModifiedSince time.Time) ([]xero.Contact, error) {
+	mxc.getCount++
+	mxc.log.Info(fmt.Sprintf("Contacts %d", mxc.getCount))
+	return []xero.Contact{{ContactID: fmt.Sprintf("cId-%d", mxc.getCount)}}, nil
+}
+func (mxc *mockXeroClient) UpdateInvoiceReference(ctx context.Context, invoiceID, reference string) (xero.Invoice, error) {
+	mxc.getCount++
+	mxc.log.Info(fmt.Sprintf("UpdateInvoiceReference %d", mxc.getCount))
+	return xero.Invoice{InvoiceID: invoiceID, Reference: reference}, nil
 }
 
 // not good for parallel tests.
@@ -190,7 +208,32 @@ type mockSalesforceClient struct {
 func (msc *mockSalesforceClient) GetOpportunities(ctx context.Context, fromDate, ifModifiedSince time.Time) ([]salesforce.Donation, error) {
 	msc.getCount++
 	msc.log.Info(fmt.Sprintf("GetOpportunities %d", msc.getCount))
-	return []salesforce.Donation{{CoreFields: salesforce.CoreFields{ID: fmt.Sprintf("ID-%d", msc.getCount)}}}, nil
+	return []salesforce.Donation{{CoreFields: salesforce.CoreFields{
+		ID:        fmt.Sprintf("ID-%d", msc.getCount),
+		CloseDate: salesforce.SalesforceDate{Time: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}}}, nil
+}
+
+func (msc *mockSalesforceClient) GetOpportunitiesByID(ctx context.Context, ids []string) ([]salesforce.Donation, error) {
+	var donations []salesforce.Donation
+	for _, id := range ids {
+		donations = append(donations, salesforce.Donation{CoreFields: salesforce.CoreFields{
+			ID:        id,
+			CloseDate: salesforce.SalesforceDate{Time: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		}})
+	}
+	return donations, nil
+}
+
+func (msc *mockSalesforceClient) PreviewOpportunities(ctx context.Context, fromDate time.Time, limit int) ([]salesforce.Donation, error) {
+	return []salesforce.Donation{{
+		CoreFields:       salesforce.CoreFields{ID: "ID-preview", Name: "preview"},
+		AdditionalFields: map[string]any{"Stage": "Closed Won"},
+	}}, nil
+}
+
+func (msc *mockSalesforceClient) ValidateFieldMappings(ctx context.Context) ([]string, error) {
+	return nil, nil
 }
 
 func (msc *mockSalesforceClient) BatchUpdateOpportunityRefs(ctx context.Context, idRefs []salesforce.IDRef, allOrNone bool) (salesforce.CollectionsUpdateResponse, error) {
@@ -264,3 +307,35 @@ func TestRefreshSalesforceRecords(t *testing.T) {
 	}
 
 }
+
+// TestRefreshProgress tests the reporting of the progress of a Salesforce refresh.
+func TestRefreshProgress(t *testing.T) {
+
+	webApp := &WebApp{log: slog.Default()}
+
+	get := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/refresh/progress", nil)
+		if err := webApp.handleRefreshProgress()(rec, req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rec.Body.String()
+	}
+
+	if got := get(); got != "" {
+		t.Errorf("no refresh got %q want empty", got)
+	}
+	webApp.setSFProgress(&salesforce.QueryProgress{})
+	if got, want := get(), "Retrieving Salesforce records..."; got != want {
+		t.Errorf("refresh started got %q want %q", got, want)
+	}
+	webApp.setSFProgress(&salesforce.QueryProgress{Page: 2, Fetched: 4000, Total: 5123})
+	if got, want := get(), "Retrieved 4000 of 5123 Salesforce records (page 2)."; got != want {
+		t.Errorf("refresh progress got %q want %q", got, want)
+	}
+	webApp.setSFProgress(nil)
+	if got := get(); got != "" {
+		t.Errorf("refresh finished got %q want empty", got)
+	}
+}
@@ -0,0 +1,408 @@
+package web
+
+// reports.go serves the reports page, the period reconciliation report, a PDF summary
+// for trustees and auditors, Gift Aid claim schedules for HMRC, and the aging and
+// account code breakdown reports.
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/reports"
+)
+
+// handleReports serves the /reports page, which allows the period for reports to be
+// chosen.
+func (web *WebApp) handleReports() appHandler {
+
+	name := "reports.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"reports.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		data := map[string]any{
+			"PageTitle":      "Reports",
+			"CurrentPage":    "reports",
+			"Form":           NewReportPeriodForm(web.settings().DataStartDate, web.today()),
+			"GiftAidEnabled": web.cfg.GiftAid.Enabled(),
+			"Message":        web.sessions.PopString(r.Context(), "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// reportPeriodForm decodes and validates the report period url parameters, which
+// default to the data start date to today.
+func (web *WebApp) reportPeriodForm(r *http.Request) (*ReportPeriodForm, error) {
+	form := NewReportPeriodForm(web.settings().DataStartDate, web.today())
+	if err := form.DecodeURLParams(r.URL.Query()); err != nil {
+		return nil, errUsage{fmt.Sprintf("invalid report parameters: %v", err), http.StatusBadRequest}
+	}
+	validator := NewValidator()
+	form.Validate(validator)
+	if !validator.Valid() {
+		var msgs []string
+		for _, m := range validator.Errors {
+			msgs = append(msgs, m)
+		}
+		return nil, errUsage{strings.Join(msgs, " "), http.StatusBadRequest}
+	}
+	return form, nil
+}
+
+// handleReportPeriod serves the /reports/period endpoint, which downloads the period
+// reconciliation report as a PDF. The period defaults to the data start date to today.
+func (web *WebApp) handleReportPeriod() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+
+		report, err := web.reconciler.PeriodReportGet(r.Context(), form.DateFrom, form.DateTo)
+		if err != nil {
+			return err
+		}
+
+		// Render to a buffer so that failures can still be reported as an error page.
+		var buf bytes.Buffer
+		if err := reports.WritePeriodPDF(&buf, report); err != nil {
+			return errInternal{"failed to render period report", err}
+		}
+
+		fileName := fmt.Sprintf("reconciliation-report-%s-%s.pdf",
+			form.DateFrom.Format("20060102"),
+			form.DateTo.Format("20060102"),
+		)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("period report write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// giftAidFields returns the configured Gift Aid donation fields.
+func (web *WebApp) giftAidFields() domain.GiftAidFields {
+	g := web.cfg.GiftAid
+	return domain.GiftAidFields{
+		Eligible:  g.EligibleField,
+		Title:     g.TitleField,
+		FirstName: g.FirstNameField,
+		LastName:  g.LastNameField,
+		House:     g.HouseField,
+		Postcode:  g.PostcodeField,
+	}
+}
+
+// handleGiftAid serves the /reports/gift-aid page, which summarises the Gift Aid claim
+// for a period, lists the eligible donations which cannot be claimed and links to the
+// claim schedule exports.
+func (web *WebApp) handleGiftAid() appHandler {
+
+	name := "gift-aid.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"gift-aid.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		data := map[string]any{
+			"PageTitle":   "Gift Aid",
+			"CurrentPage": "reports",
+			"Enabled":     web.cfg.GiftAid.Enabled(),
+		}
+		if !web.cfg.GiftAid.Enabled() {
+			data["Form"] = NewReportPeriodForm(web.settings().DataStartDate, web.today())
+			return web.render(w, r, templates, name, data)
+		}
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		claim, err := web.reconciler.GiftAidClaimGet(
+			r.Context(),
+			form.DateFrom,
+			form.DateTo,
+			web.cfg.GiftAid.AccountCodesAsRegex(),
+			web.giftAidFields(),
+		)
+		if err != nil {
+			return err
+		}
+
+		exportURL := func(format string) (string, error) {
+			f := *form
+			f.Format = format
+			params, err := f.AsURLParams()
+			return "/reports/gift-aid/export?" + params, err
+		}
+		odsURL, err := exportURL("ods")
+		if err != nil {
+			return errInternal{"failed to encode gift aid export url", err}
+		}
+		csvURL, err := exportURL("csv")
+		if err != nil {
+			return errInternal{"failed to encode gift aid export url", err}
+		}
+
+		data["Form"] = form
+		data["Claim"] = claim
+		data["ODSURL"] = odsURL
+		data["CSVURL"] = csvURL
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleGiftAidExport serves the /reports/gift-aid/export endpoint, which downloads
+// the Gift Aid claim schedule for a period in the HMRC format as an OpenDocument
+// spreadsheet, or as CSV if the format is "csv".
+func (web *WebApp) handleGiftAidExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		if !web.cfg.GiftAid.Enabled() {
+			return errUsage{"gift aid claims are not configured", http.StatusNotFound}
+		}
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		write, contentType := reports.WriteGiftAidODS, "application/vnd.oasis.opendocument.spreadsheet"
+		switch form.Format {
+		case "", "ods":
+			form.Format = "ods"
+		case "csv":
+			write, contentType = reports.WriteGiftAidCSV, "text/csv"
+		default:
+			return errUsage{fmt.Sprintf("invalid gift aid export format %q", form.Format), http.StatusBadRequest}
+		}
+
+		claim, err := web.reconciler.GiftAidClaimGet(
+			r.Context(),
+			form.DateFrom,
+			form.DateTo,
+			web.cfg.GiftAid.AccountCodesAsRegex(),
+			web.giftAidFields(),
+		)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := write(&buf, claim); err != nil {
+			return errInternal{"failed to write gift aid schedule", err}
+		}
+
+		fileName := fmt.Sprintf("gift-aid-schedule-%s-%s.%s",
+			form.DateFrom.Format("20060102"),
+			form.DateTo.Format("20060102"),
+			form.Format,
+		)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("gift aid schedule write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// handleAging serves the /reports/aging page, which buckets the unlinked donations and
+// the unreconciled invoices and bank transactions by their age at the end of the
+// report period so that the oldest items may be dealt with first.
+func (web *WebApp) handleAging() appHandler {
+
+	name := "aging.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"aging.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		report, err := web.reconciler.AgingReportGet(r.Context(), form.DateFrom, form.DateTo)
+		if err != nil {
+			return err
+		}
+		params, err := form.AsURLParams()
+		if err != nil {
+			return errInternal{"failed to encode aging export url", err}
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Aging Report",
+			"CurrentPage": "reports",
+			"Form":        form,
+			"Report":      report,
+			"CSVURL":      "/reports/aging/export?" + params,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleAgingExport serves the /reports/aging/export endpoint, which downloads the
+// aging report items as CSV.
+func (web *WebApp) handleAgingExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		report, err := web.reconciler.AgingReportGet(r.Context(), form.DateFrom, form.DateTo)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := reports.WriteAgingCSV(&buf, report); err != nil {
+			return errInternal{"failed to write aging report", err}
+		}
+
+		fileName := fmt.Sprintf("aging-report-%s.csv", form.DateTo.Format("20060102"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("aging report write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// handleAccountBreakdown serves the /reports/accounts page, which compares the donation
+// income of each account code by month with the linked donations, so that the income
+// streams which do not reconcile cleanly can be seen.
+func (web *WebApp) handleAccountBreakdown() appHandler {
+
+	name := "reports-accounts.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"reports-accounts.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		report, err := web.reconciler.AccountBreakdownReportGet(r.Context(), form.DateFrom, form.DateTo)
+		if err != nil {
+			return err
+		}
+		params, err := form.AsURLParams()
+		if err != nil {
+			return errInternal{"failed to encode account breakdown export url", err}
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Account Code Breakdown",
+			"CurrentPage": "reports",
+			"Form":        form,
+			"Report":      report,
+			"CSVURL":      "/reports/accounts/export?" + params,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handlePayoutGroups serves the /reports/payouts endpoint, which compares the donations
+// of each payout reference with the invoices and bank transactions sharing the
+// reference, by default showing only the references which are not matched.
+func (web *WebApp) handlePayoutGroups() appHandler {
+
+	name := "reports-payouts.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"reports-payouts.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form := NewPayoutGroupsForm(web.settings().DataStartDate, web.today())
+		if err := form.DecodeURLParams(r.URL.Query()); err != nil {
+			return errUsage{fmt.Sprintf("invalid report parameters: %v", err), http.StatusBadRequest}
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+		if !validator.Valid() {
+			var msgs []string
+			for _, m := range validator.Errors {
+				msgs = append(msgs, m)
+			}
+			return errUsage{strings.Join(msgs, " "), http.StatusBadRequest}
+		}
+
+		report, err := web.reconciler.PayoutGroupsReportGet(r.Context(), form.DateFrom, form.DateTo, form.Unmatched)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Payout References",
+			"CurrentPage": "reports",
+			"Form":        form,
+			"Report":      report,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleAccountBreakdownExport serves the /reports/accounts/export endpoint, which
+// downloads the account code breakdown by month as CSV.
+func (web *WebApp) handleAccountBreakdownExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form, err := web.reportPeriodForm(r)
+		if err != nil {
+			return err
+		}
+		report, err := web.reconciler.AccountBreakdownReportGet(r.Context(), form.DateFrom, form.DateTo)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := reports.WriteAccountBreakdownCSV(&buf, report); err != nil {
+			return errInternal{"failed to write account breakdown report", err}
+		}
+
+		fileName := fmt.Sprintf("account-breakdown-%s-%s.csv",
+			form.DateFrom.Format("20060102"),
+			form.DateTo.Format("20060102"),
+		)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := buf.WriteTo(w); err != nil {
+			web.log.Error(fmt.Sprintf("account breakdown report write error: %v", err))
+		}
+		return nil
+	}
+}
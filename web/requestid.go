@@ -0,0 +1,24 @@
+package web
+
+// requestid.go gives each request an id, carried in the request context so that the
+// logs of the request, its database queries and its Xero and Salesforce api calls can be
+// traced to a single user action. The id is also reported to the user with any error.
+
+import (
+	"net/http"
+
+	"github.com/rorycl/reconciler/internal/requestid"
+)
+
+// withRequestID sets the id of each request, being the id in the X-Request-ID header of
+// the request if valid, or otherwise a new id. The id is set in the response header.
+func (web *WebApp) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/internal/requestid"
+)
+
+// TestRequestID tests that each request is given an id, reported in the response
+// header and used as the correlation id of errors.
+func TestRequestID(t *testing.T) {
+
+	webApp := &WebApp{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	var seen string
+	failing := func(w http.ResponseWriter, r *http.Request) error {
+		seen = requestid.FromContext(r.Context())
+		return errors.New("failed")
+	}
+	handler := webApp.withRequestID(webApp.ErrorChecker(failing))
+
+	tests := []struct {
+		name   string
+		header string
+		want   string // the expected id, or empty for a new id
+	}{
+		{"client id", "proxy-1234", "proxy-1234"},
+		{"no id", "", ""},
+		{"invalid id", "bad id\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set(requestid.Header, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(requestid.Header)
+			if tt.want != "" && id != tt.want {
+				t.Errorf("id got %q want %q", id, tt.want)
+			}
+			if !requestid.Valid(id) {
+				t.Errorf("invalid id %q", id)
+			}
+			if seen != id {
+				t.Errorf("context id %q does not match header id %q", seen, id)
+			}
+			if !strings.Contains(rec.Body.String(), "reference "+id) {
+				t.Errorf("error does not report id %q: %q", id, rec.Body.String())
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/internal/mockapi"
 )
 
 // routes connects all of the endpoints and provides middleware.
@@ -19,8 +20,7 @@ func (web *WebApp) routes() http.Handler {
 	r := mux.NewRouter()
 
 	// Mount and publish the static routes.
-	fs := http.FileServerFS(web.staticFS)
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
+	r.PathPrefix("/static/").Handler(web.staticHandler())
 
 	// handleApp converts an appHandler to a *mux.Route
 	handleApp := func(router *mux.Router, path string, h appHandler) *mux.Route {
@@ -33,8 +33,13 @@ func (web *WebApp) routes() http.Handler {
 
 	handleApp(r, "/", web.handleRoot()).Methods("GET") // synonym for /connect
 	handleApp(r, "/connect", web.handleConnect()).Methods("GET")
+	handleApp(r, "/profile", web.handleProfileSwitch()).Methods("POST")
 	handleApp(r, "/logout", web.handleLogout()).Methods("GET")
-	handleApp(r, "/logout/confirmed", web.handleLogoutConfirmed()).Methods("GET")
+	handleApp(r, "/logout/confirmed", web.handleLogoutConfirmed()).Methods("POST")
+	handleApp(r, "/logout/{provider:(?:xero|salesforce)}", web.handleProviderLogout()).Methods("POST")
+	handleApp(r, "/locale", web.handleLocale()).Methods("POST")
+	handleApp(r, "/theme", web.handleThemeToggle()).Methods("POST")
+	handleApp(r, "/user", web.handleUser()).Methods("POST")
 
 	// Xero OAuth2 init and callback (the callback route is configured in web.cfg).
 	handleApp(r, "/xero/init", web.xeroWebClient.InitiateWebLogin()).Methods("GET")
@@ -48,6 +53,11 @@ func (web *WebApp) routes() http.Handler {
 		"/connect", // connect is the callback redirect address on success.
 	)).Methods("GET")
 
+	// The authorization page of the mock apis, which stands in for the platform logins.
+	if web.mockAPIs != nil {
+		r.Handle(mockapi.AuthorizePath, web.mockAPIs).Methods("GET")
+	}
+
 	/****************************************************************************************
 	// protected routes
 	****************************************************************************************/
@@ -59,12 +69,26 @@ func (web *WebApp) routes() http.Handler {
 	// Refresh is the data refresh page.
 	handleApp(protected, "/refresh", web.handleRefresh()).Methods("GET")
 	handleApp(protected, "/refresh/update", web.handleRefreshUpdates()).Methods("GET")
+	handleApp(protected, "/refresh/progress", web.handleRefreshProgress()).Methods("GET")
+	handleApp(protected, "/sync", web.handleSyncRuns()).Methods("GET")
+	handleApp(protected, "/backfill", web.handleBackfill()).Methods("GET")
+	handleApp(protected, "/backfill", web.handleBackfillStart()).Methods("POST")
+	handleApp(protected, "/backfill/progress", web.handleBackfillProgress()).Methods("GET")
 
 	// Main listing pages.
 	handleApp(protected, "/home", web.handleHome()).Methods("GET") // redirect to handleInvoices.
 	handleApp(protected, "/invoices", web.handleInvoices()).Methods("GET")
 	handleApp(protected, "/bank-transactions", web.handleBankTransactions()).Methods("GET")
 	handleApp(protected, "/donations", web.handleDonations()).Methods("GET")
+
+	// Listing results fragments, replacing the results of the listing pages in place.
+	handleApp(protected, "/invoices/results", web.handleInvoicesResults()).Methods("GET")
+	handleApp(protected, "/bank-transactions/results", web.handleBankTransactionsResults()).Methods("GET")
+	handleApp(protected, "/donations/results", web.handleDonationsResults()).Methods("GET")
+
+	// Line item exports of the listings.
+	handleApp(protected, "/invoices/export", web.handleLineItemsExport("invoice")).Methods("GET")
+	handleApp(protected, "/bank-transactions/export", web.handleLineItemsExport("bank-transaction")).Methods("GET")
 	// Todo: consider adding campaigns page
 
 	// Detail pages.
@@ -73,17 +97,148 @@ func (web *WebApp) routes() http.Handler {
 	handleApp(protected, "/invoice/{id:[A-Za-z0-9_-]+}/{action:link|unlink}", web.handleInvoiceDetail()).Methods("GET")
 	handleApp(protected, "/bank-transaction/{id:[A-Za-z0-9_-]+}", web.handleBankTransactionDetail()).Methods("GET")
 	handleApp(protected, "/bank-transaction/{id:[A-Za-z0-9_-]+}/{action:link|unlink}", web.handleBankTransactionDetail()).Methods("GET")
+	handleApp(protected, "/contact/{id:[A-Za-z0-9_-]+}", web.handleContact()).Methods("GET")
+	handleApp(protected, "/contact/{id:[A-Za-z0-9_-]+}/aliases", web.handleContactAliasAdd()).Methods("POST")
+	handleApp(protected, "/contact/{id:[A-Za-z0-9_-]+}/aliases/remove", web.handleContactAliasRemove()).Methods("POST")
+	handleApp(protected, "/payout/{id:[A-Za-z0-9_-]+}", web.handlePayout()).Methods("GET")
+	handleApp(protected, "/payout/{id:[A-Za-z0-9_-]+}/link", web.handlePayoutLink()).Methods("POST")
+	handleApp(protected, "/payout/{id:[A-Za-z0-9_-]+}/combination", web.handlePayoutCombination()).Methods("POST")
+	handleApp(protected, "/payout/{id:[A-Za-z0-9_-]+}/import", web.handlePayoutImport()).Methods("POST")
+
+	// Full-text search across invoices, bank transactions and donations.
+	handleApp(protected, "/search", web.handleSearch()).Methods("GET")
+
+	// Single record refresh.
+	handleApp(protected, "/refresh/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}", web.handleRecordRefresh()).Methods("POST")
 
 	// Donation linking/unlinking.
 	handleApp(protected, "/donations/{type:(?:invoice|bank-transaction)}/{id}/{action}", web.handleDonationsLinkUnlink()).Methods("POST")
 
+	// Link actions which failed part way through, to be retried or reversed.
+	handleApp(protected, "/pending-actions", web.handlePendingActions()).Methods("GET")
+	handleApp(protected, "/pending-actions/{id:[0-9]+}/retry", web.handlePendingActionRetry()).Methods("POST")
+	handleApp(protected, "/pending-actions/{id:[0-9]+}/compensate", web.handlePendingActionCompensate()).Methods("POST")
+
+	// Data quality, listing donations deleted or merged in Salesforce.
+	handleApp(protected, "/data-quality", web.handleDataQuality()).Methods("GET")
+	handleApp(protected, "/data-quality/orphans/check", web.handleDonationOrphansCheck()).Methods("POST")
+	handleApp(protected, "/data-quality/orphans/remove", web.handleDonationOrphansRemove()).Methods("POST")
+	handleApp(protected, "/data-quality/links/verify", web.handleLinksVerify()).Methods("GET")
+
+	// Refreshed records which failed validation or could not be stored, to be retried
+	// or discarded.
+	handleApp(protected, "/import-errors", web.handleImportErrors()).Methods("GET")
+	handleApp(protected, "/import-errors/{id:[0-9]+}/retry", web.handleImportErrorRetry()).Methods("POST")
+	handleApp(protected, "/import-errors/{id:[0-9]+}/discard", web.handleImportErrorDiscard()).Methods("POST")
+
+	// Donations imported from a CRM other than Salesforce.
+	handleApp(protected, "/import/donations", web.handleDonorImport()).Methods("GET")
+	handleApp(protected, "/import/donations/upload", web.handleDonorImportUpload()).Methods("POST")
+	handleApp(protected, "/import/donations/map", web.handleDonorImportMap()).Methods("POST")
+	handleApp(protected, "/import/donations/cancel", web.handleDonorImportCancel()).Methods("POST")
+
+	// Staged imports, reviewed before they are committed.
+	handleApp(protected, "/imports", web.handleImports()).Methods("GET")
+	handleApp(protected, "/imports/{id:[0-9]+}", web.handleImport()).Methods("GET")
+	handleApp(protected, "/imports/{id:[0-9]+}/commit", web.handleImportCommit()).Methods("POST")
+	handleApp(protected, "/imports/{id:[0-9]+}/discard", web.handleImportDiscard()).Methods("POST")
+
+	// Donation splits across invoices and bank transactions.
+	handleApp(protected, "/splits/{type:(?:invoice|bank-transaction)}/{id:[A-Za-z0-9_-]+}", web.handleDonationSplitUpsert()).Methods("POST")
+	handleApp(protected, "/splits/{type:(?:invoice|bank-transaction)}/{id:[A-Za-z0-9_-]+}/{split:[0-9]+}/delete", web.handleDonationSplitDelete()).Methods("POST")
+
+	// Notes and flags on invoices, bank transactions and donations.
+	handleApp(protected, "/annotations/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}", web.handleAnnotations()).Methods("GET")
+	handleApp(protected, "/annotations/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}", web.handleAnnotationAdd()).Methods("POST")
+	handleApp(protected, "/annotations/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}/{annotation:[0-9]+}/delete", web.handleAnnotationDelete()).Methods("POST")
+
+	// Assignment of records to team members.
+	handleApp(protected, "/assignments/{type:(?:invoice|bank-transaction|donation)}/{id:[A-Za-z0-9_-]+}", web.handleAssignment()).Methods("POST")
+
+	// Saved searches of the listing pages.
+	handleApp(protected, "/searches/{page:(?:invoices|bank-transactions|donations)}", web.handleSavedSearchUpsert()).Methods("POST")
+	handleApp(protected, "/searches/{page:(?:invoices|bank-transactions|donations)}/{id:[0-9]+}/delete", web.handleSavedSearchDelete()).Methods("POST")
+
+	// Display preferences of the listing pages.
+	handleApp(protected, "/preferences/{page:(?:invoices|bank-transactions|donations)}", web.handlePreferences()).Methods("POST")
+
+	// Link suggestions, with CSV export and import of reviewed decisions.
+	handleApp(protected, "/suggestions", web.handleSuggestions()).Methods("GET")
+	handleApp(protected, "/suggestions/export", web.handleSuggestionsExport()).Methods("GET")
+	handleApp(protected, "/suggestions/import", web.handleSuggestionsImport()).Methods("POST")
+
+	// Period-end reports.
+	handleApp(protected, "/reports", web.handleReports()).Methods("GET")
+	handleApp(protected, "/reports/period", web.handleReportPeriod()).Methods("GET")
+	handleApp(protected, "/reports/gift-aid", web.handleGiftAid()).Methods("GET")
+	handleApp(protected, "/reports/gift-aid/export", web.handleGiftAidExport()).Methods("GET")
+	handleApp(protected, "/reports/aging", web.handleAging()).Methods("GET")
+	handleApp(protected, "/reports/aging/export", web.handleAgingExport()).Methods("GET")
+	handleApp(protected, "/reports/accounts", web.handleAccountBreakdown()).Methods("GET")
+	handleApp(protected, "/reports/accounts/export", web.handleAccountBreakdownExport()).Methods("GET")
+	handleApp(protected, "/reports/payouts", web.handlePayoutGroups()).Methods("GET")
+	handleApp(protected, "/reports/statements", web.handleStatements()).Methods("GET")
+	handleApp(protected, "/reports/statements/import", web.handleStatementImport()).Methods("POST")
+
+	// Database snapshots.
+	handleApp(protected, "/snapshot/export", web.handleSnapshotExport()).Methods("GET")
+	handleApp(protected, "/snapshot/import", web.handleSnapshotImport()).Methods("POST")
+
+	// Donation link mappings.
+	handleApp(protected, "/links/export", web.handleLinkMappingsExport()).Methods("GET")
+	handleApp(protected, "/links/import", web.handleLinkMappingsImport()).Methods("POST")
+
+	// Database backups.
+	handleApp(protected, "/settings/backups", web.handleBackups()).Methods("GET")
+	handleApp(protected, "/settings/backups", web.handleBackupCreate()).Methods("POST")
+	handleApp(protected, "/settings/backups/restore", web.handleBackupRestore()).Methods("POST")
+
+	// Reconciliation tolerance.
+	handleApp(protected, "/settings/reconciliation", web.handleTolerance()).Methods("GET")
+	handleApp(protected, "/settings/reconciliation", web.handleToleranceUpdate()).Methods("POST")
+
+	// Donation accounts.
+	handleApp(protected, "/settings/accounts", web.handleAccounts()).Methods("GET")
+	handleApp(protected, "/settings/accounts", web.handleAccountsUpdate()).Methods("POST")
+
+	// Account rules.
+	handleApp(protected, "/settings/rules", web.handleAccountRules()).Methods("GET")
+	handleApp(protected, "/settings/rules", web.handleAccountRuleAdd()).Methods("POST")
+	handleApp(protected, "/settings/rules/{rule:[0-9]+}/delete", web.handleAccountRuleDelete()).Methods("POST")
+
+	// Period locks.
+	handleApp(protected, "/settings/period-locks", web.handlePeriodLocks()).Methods("GET")
+	handleApp(protected, "/settings/period-locks", web.handlePeriodLockAdd()).Methods("POST")
+	handleApp(protected, "/settings/period-locks/{lock:[0-9]+}/delete", web.handlePeriodLockDelete()).Methods("POST")
+	handleApp(protected, "/settings/period-snapshots/{snapshot:[0-9]+}", web.handlePeriodSnapshot()).Methods("GET")
+
+	// Salesforce field mapping check and query preview.
+	handleApp(protected, "/settings/salesforce/preview", web.handleSalesforcePreview()).Methods("GET")
+
+	// Recent slow database queries.
+	handleApp(protected, "/debug/queries", web.handleDebugQueries()).Methods("GET")
+
+	// Template previews with fixture data, in development mode.
+	if web.inDevelopment {
+		handleApp(protected, "/__preview", web.handlePreviews()).Methods("GET")
+		handleApp(protected, "/__preview/{template}", web.handlePreview()).Methods("GET")
+	}
+
 	/****************************************************************************************
 	// global middleware
 	****************************************************************************************/
 
-	// Chain the desired middleware.
+	// Chain the desired middleware. Note that the CSRF token check relies on the
+	// session, so is run by the router inside the session middleware.
 	r.Use(handlers.RecoveryHandler(handlers.PrintRecoveryStack(true)))
+	if web.inDevelopment {
+		r.Use(web.sqlReloadLock)
+	}
+	r.Use(web.verifyCSRFToken)
+	r.Use(web.withMockAPIs)
+	r.Use(web.withRequestTimeout)
 	sessionMiddleWare := web.sessions.LoadAndSave(r)
-	csrfMiddlware := enforceCSRF(sessionMiddleWare)
-	return web.slogMiddleware(csrfMiddlware)
+	csrfMiddlware := web.enforceCSRF(sessionMiddleWare)
+	securityMiddleware := web.securityHeaders(csrfMiddlware)
+	return web.withRequestID(web.slogMiddleware(securityMiddleware))
 }
@@ -0,0 +1,89 @@
+package web
+
+// rules.go shows, adds and removes the account rules which classify invoice and bank
+// transaction line items by their description or contact, such as treating "PayPal
+// Giving Fund" as the general giving account.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// handleAccountRules shows the account rules with a form to add a rule.
+func (web *WebApp) handleAccountRules() appHandler {
+
+	name := "settings-rules.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-rules.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		rules, err := web.reconciler.AccountRulesGet(r.Context())
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"PageTitle":   "Account Rules",
+			"CurrentPage": "settings-rules",
+			"Rules":       rules,
+			"Message":     web.sessions.PopString(r.Context(), "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleAccountRuleAdd adds an account rule from the "field", "pattern" and
+// "account-code" form values.
+func (web *WebApp) handleAccountRuleAdd() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/settings/rules", http.StatusSeeOther)
+			return nil
+		}
+
+		err := web.reconciler.AccountRuleAdd(ctx, r.PostFormValue("field"), r.PostFormValue("pattern"), r.PostFormValue("account-code"))
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return err
+		}
+		return redirect("The account rule was added and applied.")
+	}
+}
+
+// handleAccountRuleDelete removes an account rule.
+// The target is "/settings/rules/{{ .ID }}/delete".
+func (web *WebApp) handleAccountRuleDelete() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "rule")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		id, err := strconv.ParseInt(vars["rule"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid rule id %q", vars["rule"]), http.StatusBadRequest}
+		}
+		if err := web.reconciler.AccountRuleDelete(ctx, id); err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", "The account rule was removed.")
+		http.Redirect(w, r, "/settings/rules", http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestAccountRules tests showing, adding and removing account rules.
+func TestAccountRules(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/settings/rules", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAccountRules())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "paypal giving fund") || !strings.Contains(body, `action="/settings/rules/1/delete"`) {
+		t.Error("rule not shown")
+	}
+
+	tests := []struct {
+		form url.Values
+		adds int
+	}{
+		{url.Values{"field": {"contact"}, "pattern": {"paypal giving fund"}, "account-code": {"5501"}}, 1},
+		{url.Values{"field": {"contact"}, "pattern": {""}, "account-code": {"5501"}}, 2},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/settings/rules", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAccountRuleAdd())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%v: status got %d want %d", tt.form, got, want)
+		}
+		if got, want := reconcilerMock.accountRuleAdd, tt.adds; got != want {
+			t.Errorf("%v: adds got %d want %d", tt.form, got, want)
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/settings/rules/1/delete", nil)
+	req = mux.SetURLVars(req, map[string]string{"rule": "1"})
+	rec = httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleAccountRuleDelete())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Errorf("delete status got %d want %d", got, want)
+	}
+	if got, want := reconcilerMock.accountRuleDelete, 1; got != want {
+		t.Errorf("deletes got %d want %d", got, want)
+	}
+}
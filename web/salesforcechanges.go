@@ -0,0 +1,63 @@
+package web
+
+// salesforcechanges.go runs the optional Salesforce change subscription, which keeps
+// the donation records current between data refreshes.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// startSFSubscription starts the Salesforce change subscription in the background if
+// it is configured and not already running. The subscription is made with the
+// provided token and stops if the token expires; it is restarted after the next
+// refresh.
+func (web *WebApp) startSFSubscription(sfToken *token.ExtendedToken) {
+	if !web.cfg.Salesforce.SubscribeChanges {
+		return
+	}
+
+	web.sfSubscriptionMu.Lock()
+	defer web.sfSubscriptionMu.Unlock()
+	if web.sfSubscriptionCancel != nil {
+		return
+	}
+
+	// The subscription outlives the request which starts it.
+	ctx, cancel := context.WithCancel(context.Background())
+	sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+	if err != nil {
+		cancel()
+		web.log.Error(fmt.Sprintf("salesforce subscription client error: %v", err))
+		return
+	}
+	web.sfSubscriptionCancel = cancel
+
+	go func() {
+		web.log.Info("salesforce change subscription started")
+		err := web.reconciler.SalesforceChangesSubscribe(ctx, sfClient, web.settings().DataStartDate, func(r domain.SalesforceChangeResults) {
+			web.log.Info("salesforce change applied", "change", r.ChangeType, "upserted", r.UpsertedNo, "deleted", r.DeletedNo)
+		})
+		if err != nil {
+			web.log.Error(fmt.Sprintf("salesforce change subscription error: %v", err))
+		}
+		web.log.Info("salesforce change subscription stopped")
+
+		web.sfSubscriptionMu.Lock()
+		defer web.sfSubscriptionMu.Unlock()
+		cancel()
+		web.sfSubscriptionCancel = nil
+	}()
+}
+
+// stopSFSubscription stops the Salesforce change subscription, if it is running.
+func (web *WebApp) stopSFSubscription() {
+	web.sfSubscriptionMu.Lock()
+	defer web.sfSubscriptionMu.Unlock()
+	if web.sfSubscriptionCancel != nil {
+		web.sfSubscriptionCancel()
+	}
+}
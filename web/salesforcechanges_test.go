@@ -0,0 +1,54 @@
+package web
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+func TestStartSFSubscription(t *testing.T) {
+
+	reconcilerMock := &reconciliationMock{}
+	webApp := &WebApp{
+		log:         slog.Default(),
+		reconciler:  reconcilerMock,
+		cfg:         &config.Config{},
+		newSFClient: NewMockSFClient,
+	}
+	et := &token.ExtendedToken{Type: token.SalesforceToken}
+
+	// running reports if the subscription is running.
+	running := func() bool {
+		webApp.sfSubscriptionMu.Lock()
+		defer webApp.sfSubscriptionMu.Unlock()
+		return webApp.sfSubscriptionCancel != nil
+	}
+
+	// The subscription is not started unless configured.
+	webApp.startSFSubscription(et)
+	if running() {
+		t.Fatal("did not expect the subscription to be started")
+	}
+
+	// The mock subscription returns immediately, after which the subscription can be
+	// started again.
+	webApp.cfg.Salesforce.SubscribeChanges = true
+	for range 2 {
+		webApp.startSFSubscription(et)
+		deadline := time.Now().Add(time.Second)
+		for running() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if running() {
+			t.Fatal("expected the subscription to have stopped")
+		}
+	}
+	webApp.sfSubscriptionMu.Lock()
+	defer webApp.sfSubscriptionMu.Unlock()
+	if got, want := reconcilerMock.salesforceChangesSubscribe, 2; got != want {
+		t.Errorf("subscriptions got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package web
+
+// salesforcelinks.go provides deep links from donations into Salesforce Lightning.
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sync"
+)
+
+// sfInstanceURLSessionKey is the session key for the Salesforce instance url.
+const sfInstanceURLSessionKey = "salesforce-instance-url"
+
+// saveSFInstanceURL records the Salesforce instance url provided with the Salesforce
+// OAuth2 token in the session and the database, so that deep links are available
+// before the user next connects to Salesforce.
+func (web *WebApp) saveSFInstanceURL(ctx context.Context, instanceURL string) {
+	if instanceURL == "" || web.sessions.GetString(ctx, sfInstanceURLSessionKey) == instanceURL {
+		return
+	}
+	web.sessions.Put(ctx, sfInstanceURLSessionKey, instanceURL)
+	if err := web.reconciler.SalesforceInstanceURLUpsert(ctx, instanceURL); err != nil {
+		web.log.Error(fmt.Sprintf("salesforce instance url save error: %v", err))
+	}
+}
+
+// sfInstanceURL returns the Salesforce instance url, first from the session and
+// otherwise from the database, in which case it is cached in the session. An empty
+// string is returned if the instance url is not known.
+func (web *WebApp) sfInstanceURL(ctx context.Context) string {
+	if u := web.sessions.GetString(ctx, sfInstanceURLSessionKey); u != "" {
+		return u
+	}
+	u, err := web.reconciler.SalesforceInstanceURLGet(ctx)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("salesforce instance url retrieval error: %v", err))
+		return ""
+	}
+	// The database may have been used with a different Salesforce environment.
+	if u != "" {
+		if err := web.cfg.Salesforce.CheckInstanceURL(u); err != nil {
+			web.log.Warn(fmt.Sprintf("stored salesforce instance url ignored: %v", err))
+			return ""
+		}
+	}
+	if u != "" {
+		web.sessions.Put(ctx, sfInstanceURLSessionKey, u)
+	}
+	return u
+}
+
+// sfRecordURL returns the Lightning url of a record of the Salesforce object, such as
+// Opportunity, or an empty string if the instance url is not known.
+func sfRecordURL(instanceURL, object, id string) string {
+	if instanceURL == "" || object == "" || id == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/lightning/r/%s/%s/view", instanceURL, url.PathEscape(object), url.PathEscape(id))
+}
+
+// sfTemplateFuncs returns the request-specific template funcs for rendering deep links
+// to the records of the queried Salesforce object. The instance url is only looked up
+// if a link is rendered.
+func (web *WebApp) sfTemplateFuncs(ctx context.Context) template.FuncMap {
+	instanceURL := sync.OnceValue(func() string {
+		return web.sfInstanceURL(ctx)
+	})
+	return template.FuncMap{
+		"sfRecordURL": func(id string) string {
+			return sfRecordURL(instanceURL(), web.cfg.Salesforce.QueryObject(), id)
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package web
+
+import "testing"
+
+func TestSFRecordURL(t *testing.T) {
+	tests := []struct {
+		instanceURL string
+		object      string
+		id          string
+		want        string
+	}{
+		{"", "Opportunity", "006Qy00000A1b2C", ""},
+		{"https://example.my.salesforce.com", "Opportunity", "", ""},
+		{"https://example.my.salesforce.com", "", "006Qy00000A1b2C", ""},
+		{"https://example.my.salesforce.com", "Opportunity", "006Qy00000A1b2C", "https://example.my.salesforce.com/lightning/r/Opportunity/006Qy00000A1b2C/view"},
+		{"https://example.my.salesforce.com", "npsp__Payment__c", "a01Qy00000A1b2C", "https://example.my.salesforce.com/lightning/r/npsp__Payment__c/a01Qy00000A1b2C/view"},
+	}
+	for _, tt := range tests {
+		if got := sfRecordURL(tt.instanceURL, tt.object, tt.id); got != tt.want {
+			t.Errorf("sfRecordURL(%q, %q, %q) got %q want %q", tt.instanceURL, tt.object, tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package web
+
+// salesforcesettings.go shows the configured Salesforce environment, checks the
+// Salesforce field mapping configuration against the connected Salesforce org and
+// provides a preview of the configured query.
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// Session keys recording the outcome of the Salesforce field mapping check, which is
+// run once a session after connecting to Salesforce.
+const (
+	sfMappingsCheckedSessionKey = "salesforce-mappings-checked"
+	sfMappingProblemsSessionKey = "salesforce-mapping-problems"
+)
+
+// sfEnvironmentTemplateFuncs returns the template funcs for showing the configured
+// Salesforce environment, so that users can see if they are working with a sandbox or
+// production org.
+func (web *WebApp) sfEnvironmentTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"sfEnvironment": func() string { return web.cfg.Salesforce.Environment },
+	}
+}
+
+// sfPreviewLimit is the number of records shown in the Salesforce query preview.
+const sfPreviewLimit = 5
+
+// checkSFFieldMappings validates the configured Salesforce field mappings the first
+// time it is called in a session with a valid Salesforce token, returning any
+// problems found. Problems are recorded in the session and logged. If the check
+// cannot be made it is retried on the next call.
+func (web *WebApp) checkSFFieldMappings(ctx context.Context, sfToken *token.ExtendedToken) []string {
+	if web.sessions.GetBool(ctx, sfMappingsCheckedSessionKey) {
+		if p := web.sessions.GetString(ctx, sfMappingProblemsSessionKey); p != "" {
+			return strings.Split(p, "\n")
+		}
+		return nil
+	}
+	sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("salesforce field mapping check client error: %v", err))
+		return nil
+	}
+	problems, err := sfClient.ValidateFieldMappings(ctx)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("salesforce field mapping check error: %v", err))
+		return nil
+	}
+	for _, p := range problems {
+		web.log.Error(fmt.Sprintf("salesforce field mapping problem: %s", p))
+	}
+	web.sessions.Put(ctx, sfMappingsCheckedSessionKey, true)
+	web.sessions.Put(ctx, sfMappingProblemsSessionKey, strings.Join(problems, "\n"))
+	return problems
+}
+
+// sfPreviewRow is a donation shown in the Salesforce query preview, with the values of
+// the mapped fields in column order.
+type sfPreviewRow struct {
+	ID        string
+	Name      string
+	Amount    money.Amount
+	CloseDate string
+	Values    []string
+}
+
+// handleSalesforcePreview serves the /settings/salesforce/preview page, which checks
+// the configured field mappings and shows the first few records returned by the
+// configured query with their mapped fields.
+func (web *WebApp) handleSalesforcePreview() appHandler {
+
+	name := "salesforce-preview.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"salesforce-preview.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			http.Redirect(w, r, "/connect", http.StatusFound)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for preview", err}
+		}
+
+		// Always re-run the check here, so that the result reflects any changes made
+		// in Salesforce since connecting.
+		problems, err := sfClient.ValidateFieldMappings(ctx)
+		if err != nil {
+			return errInternal{"failed to validate salesforce field mappings", err}
+		}
+		web.sessions.Put(ctx, sfMappingsCheckedSessionKey, true)
+		web.sessions.Put(ctx, sfMappingProblemsSessionKey, strings.Join(problems, "\n"))
+
+		// Query errors, such as those for fields missing in Salesforce, are shown on
+		// the page rather than treated as a failure.
+		columns := slices.Sorted(maps.Values(web.cfg.Salesforce.FieldMappings))
+		var rows []sfPreviewRow
+		var previewError string
+		donations, err := sfClient.PreviewOpportunities(ctx, web.settings().DataStartDate, sfPreviewLimit)
+		if err != nil {
+			web.log.Error(fmt.Sprintf("salesforce preview error: %v", err))
+			previewError = err.Error()
+		}
+		for _, d := range donations {
+			row := sfPreviewRow{
+				ID:        d.ID,
+				Name:      d.Name,
+				Amount:    d.Amount,
+				CloseDate: d.CloseDate.Format("02/01/2006"),
+			}
+			for _, c := range columns {
+				var v string
+				if av, ok := d.AdditionalFields[c]; ok && av != nil {
+					v = fmt.Sprint(av)
+				}
+				row.Values = append(row.Values, v)
+			}
+			rows = append(rows, row)
+		}
+
+		mappings := web.cfg.Salesforce.FieldMappings
+		data := map[string]any{
+			"PageTitle":        "Salesforce Preview",
+			"CurrentPage":      "salesforce-preview",
+			"Query":            web.cfg.Salesforce.Query,
+			"Fields":           slices.Sorted(maps.Keys(mappings)),
+			"Mappings":         mappings,
+			"LinkingObject":    web.cfg.Salesforce.LinkingObject,
+			"LinkingFieldName": web.cfg.Salesforce.LinkingFieldName,
+			"Problems":         problems,
+			"Columns":          columns,
+			"Rows":             rows,
+			"PreviewError":     previewError,
+			"Limit":            sfPreviewLimit,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/rorycl/reconciler/config"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// mockMappingsSFClient is a mock Salesforce client reporting field mapping problems.
+type mockMappingsSFClient struct {
+	mockSalesforceClient
+	validateCount int
+}
+
+func (m *mockMappingsSFClient) ValidateFieldMappings(ctx context.Context) ([]string, error) {
+	m.validateCount++
+	return []string{"field mapping StageName (Stage): field StageName not found on Opportunity"}, nil
+}
+
+func TestCheckSFFieldMappings(t *testing.T) {
+
+	sessionStore := scs.New()
+	ctx, err := sessionStore.Load(context.Background(), "")
+	if err != nil {
+		t.Fatalf("could not load session store: %v", err)
+	}
+
+	client := &mockMappingsSFClient{mockSalesforceClient: mockSalesforceClient{log: slog.Default()}}
+	webApp := &WebApp{
+		log:      slog.Default(),
+		sessions: sessionStore,
+		cfg:      &config.Config{},
+		newSFClient: func(ctx context.Context, cfg *config.Config, logger *slog.Logger, et *token.ExtendedToken) (domain.SalesforceClient, error) {
+			return client, nil
+		},
+	}
+
+	et := &token.ExtendedToken{Type: token.SalesforceToken, InstanceURL: "https://example.com"}
+	want := []string{"field mapping StageName (Stage): field StageName not found on Opportunity"}
+
+	// The check is only made once a session.
+	for range 2 {
+		if got := webApp.checkSFFieldMappings(ctx, et); !slices.Equal(got, want) {
+			t.Errorf("problems got %v want %v", got, want)
+		}
+	}
+	if got, want := client.validateCount, 1; got != want {
+		t.Errorf("validate count got %d want %d", got, want)
+	}
+	if !webApp.sessions.GetBool(ctx, sfMappingsCheckedSessionKey) {
+		t.Error("expected the check to be recorded in the session")
+	}
+}
@@ -0,0 +1,50 @@
+package web
+
+// search.go provides the full-text search of invoices, bank transactions and donations.
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+)
+
+// searchLen is the maximum number of full-text search results shown.
+const searchLen = 100
+
+// handleSearch serves the /search page, listing the invoices, bank transactions and
+// donations matching the "q" url parameter, most relevant first. The page without
+// parameters shows the last search of the session.
+func (web *WebApp) handleSearch() appHandler {
+
+	name := "search.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"search.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		if restoreListingURL(r.Context(), web.sessions, w, r, "/search") {
+			return nil
+		}
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		data := map[string]any{
+			"PageTitle":   "Search",
+			"CurrentPage": "search",
+			"Query":       query,
+			"Limit":       searchLen,
+		}
+		if query == "" {
+			return web.render(w, r, templates, name, data)
+		}
+
+		results, err := web.reconciler.Search(r.Context(), query, searchLen)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		data["Results"] = results
+		return web.render(w, r, templates, name, data)
+	}
+}
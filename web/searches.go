@@ -0,0 +1,134 @@
+package web
+
+// searches.go saves and removes the filter presets, or saved searches, of the invoices,
+// bank transactions and donations listing pages.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+)
+
+// searchFormer is a listing page search form which can be validated.
+type searchFormer interface {
+	formURLer
+	Validate(*Validator)
+}
+
+// savedSearchForm returns the default search form of a listing page.
+func (web *WebApp) savedSearchForm(page string) searchFormer {
+	if page == "donations" {
+		return NewSearchDonationsForm(&web.settings().DataStartDate, nil, web.today())
+	}
+	return NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+}
+
+// savedSearchDefaultURL returns the url of the default saved search of a listing page
+// when the page is requested without filters and no filters for the page are held in
+// the session, or an empty string otherwise.
+func (web *WebApp) savedSearchDefaultURL(ctx context.Context, r *http.Request, page string) (string, error) {
+	thisURL := "/" + page
+	if r.URL.RawQuery != "" || web.sessions.GetString(ctx, thisURL) != "" {
+		return "", nil
+	}
+	searches, err := web.reconciler.SavedSearchesGet(ctx, page)
+	if err != nil {
+		return "", err
+	}
+	if len(searches) == 0 || !searches[0].IsDefault {
+		return "", nil
+	}
+	return thisURL + "?" + searches[0].Params, nil
+}
+
+// handleSavedSearchUpsert saves the filters in the "params" form value of a listing
+// page under the "name" form value, as the page default if the "default" form value
+// is "true", redirecting to the page with the saved filters.
+// The target is "/searches/{{ .CurrentPage }}".
+func (web *WebApp) handleSavedSearchUpsert() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "page")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		page := vars["page"]
+
+		redirect := func(msg, params string) error {
+			web.sessions.Put(ctx, "message", msg)
+			target := "/" + page
+			if params != "" {
+				target += "?" + params
+			}
+			http.Redirect(w, r, target, http.StatusSeeOther)
+			return nil
+		}
+
+		// Decode and validate the filters with the page's search form, dropping the page
+		// number, so that only valid filters are saved.
+		values, err := url.ParseQuery(r.PostFormValue("params"))
+		if err != nil {
+			return redirect("The search filters could not be read.", "")
+		}
+		values.Del("page")
+		form := web.savedSearchForm(page)
+		if err := form.DecodeURLParams(values); err != nil {
+			return redirect("The search filters could not be read.", "")
+		}
+		validator := NewValidator()
+		if form.Validate(validator); !validator.Valid() {
+			return redirect("The search filters are not valid and were not saved.", "")
+		}
+		params, err := form.AsURLParams()
+		if err != nil {
+			return errInternal{"failed to encode saved search", err}
+		}
+
+		name := strings.TrimSpace(r.PostFormValue("name"))
+		isDefault := r.PostFormValue("default") == "true"
+		err = web.reconciler.SavedSearchUpsert(ctx, page, name, params, isDefault)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg, params)
+		}
+		if err != nil {
+			return errInternal{"failed to save search", err}
+		}
+		return redirect(fmt.Sprintf("The search %q was saved.", name), params)
+	}
+}
+
+// handleSavedSearchDelete removes a saved search from a listing page, redirecting to
+// the page.
+// The target is "/searches/{{ .CurrentPage }}/{{ .ID }}/delete".
+func (web *WebApp) handleSavedSearchDelete() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "page", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		page := vars["page"]
+		id, err := strconv.ParseInt(vars["id"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid saved search id %q", vars["id"]), http.StatusBadRequest}
+		}
+
+		if err := web.reconciler.SavedSearchDelete(ctx, page, id); err != nil {
+			return errInternal{"failed to remove saved search", err}
+		}
+		web.sessions.Put(ctx, "message", "The saved search was removed.")
+		http.Redirect(w, r, "/"+page, http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestSavedSearches tests saving and removing the saved searches of listing pages.
+func TestSavedSearches(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		page     string
+		form     url.Values
+		location string
+		upserts  int
+	}{
+		{
+			page:     "invoices",
+			form:     url.Values{"name": {"All"}, "params": {"status=All&date-from=2025-04-01&date-to=2026-03-31&search=x&page=3"}},
+			location: "/invoices?date-from=2025-04-01&date-to=2026-03-31&page=1&search=x&status=All",
+			upserts:  1,
+		},
+		{
+			page:     "donations",
+			form:     url.Values{"name": {"Unlinked"}, "params": {"status=NotLinked&date-from=2025-04-01&date-to=2026-03-31&payout-reference=INV-1"}, "default": {"true"}},
+			location: "/donations?date-from=2025-04-01&date-to=2026-03-31&page=1&payout-reference=INV-1&search=&status=NotLinked",
+			upserts:  2,
+		},
+		{
+			page:     "bank-transactions",
+			form:     url.Values{"name": {"Bad"}, "params": {"date-from=April"}},
+			location: "/bank-transactions",
+			upserts:  2,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/searches/"+tt.page, strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, map[string]string{"page": tt.page})
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleSavedSearchUpsert())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%s: status got %d want %d", tt.page, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), tt.location; got != want {
+			t.Errorf("%s: location got %q want %q", tt.page, got, want)
+		}
+		if got, want := reconcilerMock.savedSearchUpsert, tt.upserts; got != want {
+			t.Errorf("%s: upserts got %d want %d", tt.page, got, want)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/searches/invoices/1/delete", nil)
+	req = mux.SetURLVars(req, map[string]string{"page": "invoices", "id": "1"})
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleSavedSearchDelete())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Errorf("delete status got %d want %d", got, want)
+	}
+	if got, want := reconcilerMock.savedSearchDelete, 1; got != want {
+		t.Errorf("deletes got %d want %d", got, want)
+	}
+}
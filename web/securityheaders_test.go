@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+
+	var gotNonce string
+	nonceHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotNonce = cspNonce(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		headers config.SecurityHeadersConfig
+		want    map[string]string
+	}{
+		{
+			name: "defaults",
+			headers: config.SecurityHeadersConfig{
+				ContentSecurityPolicy: config.DefaultContentSecurityPolicy,
+				FrameOptions:          config.DefaultFrameOptions,
+				ReferrerPolicy:        config.DefaultReferrerPolicy,
+				PermissionsPolicy:     config.DefaultPermissionsPolicy,
+			},
+			want: map[string]string{
+				"X-Frame-Options":        "DENY",
+				"Referrer-Policy":        "same-origin",
+				"Permissions-Policy":     config.DefaultPermissionsPolicy,
+				"X-Content-Type-Options": "nosniff",
+			},
+		},
+		{
+			name: "custom",
+			headers: config.SecurityHeadersConfig{
+				ContentSecurityPolicy: "default-src 'self'; script-src 'nonce-{nonce}'",
+				FrameOptions:          "SAMEORIGIN",
+			},
+			want: map[string]string{
+				"X-Frame-Options":    "SAMEORIGIN",
+				"Referrer-Policy":    "",
+				"Permissions-Policy": "",
+			},
+		},
+		{
+			name: "disabled",
+			headers: config.SecurityHeadersConfig{
+				Disabled:              true,
+				ContentSecurityPolicy: config.DefaultContentSecurityPolicy,
+				FrameOptions:          config.DefaultFrameOptions,
+			},
+			want: map[string]string{
+				"Content-Security-Policy": "",
+				"X-Frame-Options":         "",
+				"X-Content-Type-Options":  "",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotNonce = ""
+			webApp := &WebApp{
+				cfg: &config.Config{Web: config.WebConfig{SecurityHeaders: tt.headers}},
+			}
+			w := httptest.NewRecorder()
+			webApp.securityHeaders(nonceHandler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			for k, v := range tt.want {
+				if got := w.Header().Get(k); got != v {
+					t.Errorf("header %s got %q want %q", k, got, v)
+				}
+			}
+			if tt.headers.Disabled {
+				if gotNonce != "" {
+					t.Errorf("expected no nonce when disabled, got %q", gotNonce)
+				}
+				return
+			}
+			if gotNonce == "" {
+				t.Fatal("expected nonce in request context")
+			}
+			csp := w.Header().Get("Content-Security-Policy")
+			if !strings.Contains(csp, "'nonce-"+gotNonce+"'") {
+				t.Errorf("csp %q does not contain nonce %q", csp, gotNonce)
+			}
+		})
+	}
+}
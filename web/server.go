@@ -26,11 +26,24 @@ package web
 // templates/partial-donations-searchform.html
 //	- donations search form
 // templates/partial-donations-searchresults.html
-//	- donations search results
+//	- donations search results, also rendered by /donations/results
 // templates/partial-donations-tabs.html
 //	- donations tabs (linked and search)
 // templates/partial-listingTabs.html
 //	- donations tab headers
+// templates/partial-invoices-results.html
+//	- invoices results table and pagination, also rendered by /invoices/results
+// templates/partial-bank-transactions-results.html
+//	- bank transactions results table and pagination, also rendered by
+//	  /bank-transactions/results
+// templates/partial-saved-searches.html
+//	- saved searches dropdown of the listing pages
+// templates/partial-preferences.html
+//	- page length and column preferences dropdown of the listing pages
+// templates/partial-listing-params.html
+//	- out of band update of the saved search and preferences filters
+// templates/partial-link-conflicts.html
+//	- remotely changed records blocking a link or unlink
 
 import (
 	"bytes"
@@ -46,11 +59,20 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rorycl/reconciler/apiclients/salesforce"
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/db"
 	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/backup"
+	"github.com/rorycl/reconciler/internal/mockapi"
+	"github.com/rorycl/reconciler/internal/paginate"
 	"github.com/rorycl/reconciler/internal/token"
 
 	"github.com/alexedwards/scs/v2"
@@ -58,9 +80,6 @@ import (
 	"golang.org/x/oauth2"
 )
 
-// pageLen is the number of items to show in a page listing.
-const pageLen = 15
-
 // missingTransactionReference indicates a missing transaction reference, which should
 // not be used for linking.
 const missingTransactionReference = "missing reference"
@@ -91,8 +110,11 @@ type WebApp struct {
 	server         *http.Server
 	sessions       *scs.SessionManager
 	accountsRegexp *regexp.Regexp
-	logoutDuration time.Duration // time to pause when logging out.
-	started        bool          // the server has been started.
+	logoutDuration time.Duration      // time to pause when logging out.
+	templateFuncs  template.FuncMap   // the funcs registered with the parsed templates.
+	errorTemplates *template.Template // the error page, nil if not made by New.
+	assetVersions  sync.Map           // the cached static asset versions, keyed by name.
+	started        bool               // the server has been started.
 
 	// Xero and Salesforce client factory funcs allow the passing in of funcs that make a client that meets
 	// the domain.XeroClient and domain.SalesforceClient interfaces.
@@ -103,8 +125,39 @@ type WebApp struct {
 	xeroWebClient *token.TokenWebClient
 	sfWebClient   *token.TokenWebClient
 
+	// the optional database backups, nil if not configured
+	backups *backup.Manager
+
+	// the optional Salesforce change subscription
+	sfSubscriptionMu     sync.Mutex
+	sfSubscriptionCancel context.CancelFunc
+
+	// the retries of the Salesforce updates queued while Salesforce was unreachable
+	outboxMu     sync.Mutex
+	outboxCancel context.CancelFunc
+
+	// the progress of the running Salesforce refresh, nil if none is running
+	sfProgressMu sync.Mutex
+	sfProgress   *salesforce.QueryProgress
+
+	// the running or most recent backfill, nil if there has been none
+	backfillMu sync.Mutex
+	backfill   *backfillStatus
+
+	// the mock apis, used in place of Xero and Salesforce if set
+	mockAPIs *mockapi.Server
+
+	// the settings reloaded from the configuration file, nil if not reloaded
+	reloaded atomic.Pointer[config.Settings]
+
+	// the configured profiles, the current profile and the func switching profile
+	profiles      []string
+	profile       string
+	switchProfile func(profile string)
+
 	// in development mode
 	inDevelopment bool
+	sqlReloadMu   sync.RWMutex // held by requests, and exclusively by sql reloads
 }
 
 // New initialises a WebApp. An error type is returned for future use.
@@ -120,11 +173,12 @@ func New(
 
 	// Add settings for the http server.
 	// The timeout settings are intended to allow the API data refresh handler to run
-	// without interruption.
+	// without interruption. The write timeout outlasts the request timeout, so that
+	// timed out requests can report an error.
 	server := &http.Server{
 		Addr:              config.Web.ListenAddress,
 		ReadHeaderTimeout: time.Duration(300 * time.Second),
-		WriteTimeout:      time.Duration(300 * time.Second),
+		WriteTimeout:      max(300*time.Second, config.Web.RequestTimeout+30*time.Second),
 		MaxHeaderBytes:    1 << 17, // 125k ish
 	}
 
@@ -156,6 +210,8 @@ func New(
 		accountsRegexp: accountsRegexp,
 		logoutDuration: logoutDuration,
 	}
+	webApp.templateFuncs = webApp.newTemplateFuncs()
+	webApp.errorTemplates = webApp.parseTemplates("base.html", "error.html", "partial-link-conflicts.html")
 
 	// Client factory funcs. The default is to attach the full API clients.
 	if xeroClientFunc == nil {
@@ -187,8 +243,18 @@ func New(
 	if err != nil {
 		return nil, fmt.Errorf("could not make xero web oauth2 client: %v", err)
 	}
+	xeroWebClient.SetTenantID(config.Xero.TenantID)
 	webApp.xeroWebClient = xeroWebClient
 
+	// Optional database backups.
+	if config.Backups.Enabled() {
+		backups, err := backup.New(config.Backups.Directory, config.Backups.Keep, reconciler.SnapshotExport, logger)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialise backups: %w", err)
+		}
+		webApp.backups = backups
+	}
+
 	return webApp, nil
 }
 
@@ -224,9 +290,38 @@ func (web *WebApp) RestartRoutes() {
 	web.server.Handler = restartHandler
 }
 
+// ReloadSQL re-prepares the database statements from the sql files. This should only
+// be used in development mode, so that sql changes take effect without restarting the
+// server and losing the session. Requests are paused during the reload; see
+// sqlReloadLock.
+func (web *WebApp) ReloadSQL() {
+	if !web.inDevelopment {
+		web.log.Error("reloading sql only operates in development mode")
+		return
+	}
+	web.sqlReloadMu.Lock()
+	defer web.sqlReloadMu.Unlock()
+	if err := web.reconciler.SQLReload(); err != nil {
+		web.log.Error(fmt.Sprintf("sql reload error: %v", err))
+	}
+}
+
+// sqlReloadLock prevents requests from running queries while the database statements
+// are reloaded in development mode.
+func (web *WebApp) sqlReloadLock(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		web.sqlReloadMu.RLock()
+		defer web.sqlReloadMu.RUnlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
 // StartServer starts a WebApp.
 func (web *WebApp) StartServer() error {
 	web.server.Handler = web.routes()
+	if web.backups != nil && web.cfg.Backups.Interval > 0 {
+		go web.backups.Run(context.Background(), web.cfg.Backups.Interval)
+	}
 	// Print to the console, regardless of the log level.
 	fmt.Printf("Starting server on %s\n", web.cfg.Web.ListenAddress)
 	web.started = true
@@ -282,14 +377,14 @@ func (web *WebApp) handleConnect() appHandler {
 		"base.html",
 		"connect.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
 
 		var xeroTokenValid, sfTokenValid bool
-		_, err := web.getValidTokenFromSession(ctx, token.XeroToken)
+		xeroTok, err := web.getValidTokenFromSession(ctx, token.XeroToken)
 		if err == nil {
 			xeroTokenValid = true
 		}
@@ -297,14 +392,29 @@ func (web *WebApp) handleConnect() appHandler {
 		if err == nil {
 			sfTokenValid = true
 		}
+		var sfMappingProblems []string
+		var xeroStatus, sfStatus *apistatus.Status
+		if xeroTokenValid {
+			status := web.xeroConnectionStatus(ctx, xeroTok)
+			xeroStatus = &status
+		}
 		if sfTokenValid {
-			web.sessions.Put(ctx, "salesforce-instance-url", sfTok.InstanceURL)
+			web.saveSFInstanceURL(ctx, sfTok.InstanceURL)
+			sfMappingProblems = web.checkSFFieldMappings(ctx, sfTok)
+			status := web.sfConnectionStatus(ctx, sfTok)
+			sfStatus = &status
 		}
 
 		data := map[string]any{
-			"Organisation":     web.cfg.Organisation,
-			"XeroTokenIsValid": xeroTokenValid,
-			"SFTokenIsValid":   sfTokenValid,
+			"Organisation":      web.cfg.Organisation,
+			"Profiles":          web.profiles,
+			"Profile":           web.profile,
+			"XeroTokenIsValid":  xeroTokenValid,
+			"SFTokenIsValid":    sfTokenValid,
+			"SFMappingProblems": sfMappingProblems,
+			"XeroStatus":        xeroStatus,
+			"SFStatus":          sfStatus,
+			"Message":           web.sessions.PopString(ctx, "message"),
 		}
 		return web.render(w, r, templates, name, data)
 	}
@@ -318,34 +428,67 @@ func (web *WebApp) handleLogout() appHandler {
 		"base.html",
 		"logout.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		queued, err := web.reconciler.OutboxActionsGet(ctx)
+		if err != nil {
+			return err
+		}
 		data := map[string]any{
 			"MemoryDatabase": web.reconciler.DBIsInMemory(),
 			"DBName":         web.reconciler.DBPath(),
+			"QueuedActions":  queued,
+			"Message":        web.sessions.PopString(ctx, "message"),
 		}
 		return web.render(w, r, templates, name, data)
 	}
 }
 
-// handleLogoutConfirmed serves the /logout/confirmed endpoint, which clears the session
-// and redirects to /connect.
+// handleLogoutConfirmed serves the POST /logout/confirmed endpoint, which revokes the
+// tokens, clears the session and stops the app. It is only served to POST requests, so
+// that the CSRF token is checked before the tokens are revoked. The logout is refused
+// while Salesforce updates are queued, unless the form confirms that they may be
+// discarded.
 func (web *WebApp) handleLogoutConfirmed() appHandler {
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
 
-		// Clear the session.
-		err := web.sessions.Clear(ctx)
+		// The queued Salesforce updates are lost with the database, so the logout is
+		// refused unless their loss was confirmed.
+		queued, err := web.reconciler.OutboxActionsGet(ctx)
+		if err != nil {
+			return err
+		}
+		if len(queued) > 0 && r.PostFormValue("discard-queued") != "true" {
+			web.sessions.Put(ctx, "message", fmt.Sprintf("%d pending actions have Salesforce updates which have not been sent. Retry them, or confirm that they may be discarded.", len(queued)))
+			http.Redirect(w, r, "/logout", http.StatusSeeOther)
+			return nil
+		}
+		if len(queued) > 0 {
+			web.log.Warn(fmt.Sprintf("logout discarded the queued salesforce updates of pending actions %v", queued))
+		}
+
+		// Revoke the tokens so that they cannot be used again, then clear the session.
+		for _, typer := range []token.TokenType{token.XeroToken, token.SalesforceToken} {
+			if err := web.revokeToken(ctx, typer); err != nil {
+				web.log.Warn(fmt.Sprintf("%s token revocation error: %v", typer, err))
+			}
+		}
+		err = web.sessions.Clear(ctx)
 		if err != nil {
 			web.log.Error(fmt.Sprintf("Sesssion clear error: %v", err))
 		} else {
 			web.log.Info("Session cleared")
 		}
 
-		// Close the database and kill the session.
+		// Stop any change subscription and outbox retries, close the database and kill
+		// the session.
+		web.stopSFSubscription()
+		web.stopOutbox()
 		_ = web.reconciler.Close()
 		time.Sleep(web.logoutDuration)
 		web.log.Info("Logout completed")
@@ -368,11 +511,7 @@ func (web *WebApp) handleRefresh() appHandler {
 		"base.html",
 		"refresh.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
-
-	// Configuration start date.
-	dataStartDate := web.cfg.DataStartDate
-	accountCodes := web.cfg.DonationAccountPrefixes
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 		ctx := r.Context()
@@ -391,12 +530,15 @@ func (web *WebApp) handleRefresh() appHandler {
 			}
 		}
 
+		settings := web.settings()
 		data := map[string]any{
-			"DataStartDate":        dataStartDate,
+			"DataStartDate":        settings.DataStartDate,
 			"Refreshed":            refreshed,
 			"LastRefresh":          lastRefresh,
-			"DonationAccountCodes": accountCodes,
+			"DonationAccountCodes": settings.DonationAccountPrefixes,
+			"SelectedAccounts":     web.reconciler.DonationAccountsSelected(),
 			"Message":              web.sessions.PopString(ctx, "message"),
+			"Warnings":             web.sessions.PopString(ctx, refreshWarningsSessionKey),
 		}
 		return web.render(w, r, templates, name, data)
 	}
@@ -410,6 +552,14 @@ func (web *WebApp) handleRefreshUpdates() appHandler {
 
 		ctx := r.Context()
 
+		// Back up the existing records first, if configured. A backup failure does
+		// not prevent the refresh.
+		if web.backups != nil && web.cfg.Backups.BeforeRefresh {
+			if _, err := web.backups.Create(ctx); err != nil {
+				web.log.Error(fmt.Sprintf("backup before refresh failed: %v", err))
+			}
+		}
+
 		// Retrieve and upsert the Xero records.
 		results, err := web.refreshXeroRecords(ctx)
 		if err != nil {
@@ -423,20 +573,32 @@ func (web *WebApp) handleRefreshUpdates() appHandler {
 
 		// Set the xero shortcode in the session if a full refresh occurred.
 		if results.FullRefresh && results.ShortCode != "" {
-			web.sessions.Put(ctx, "xero-shortcode", results.ShortCode)
+			web.sessions.Put(ctx, xeroShortCodeSessionKey, results.ShortCode)
 		}
 
 		// Retrieve and upsert the Salesforce records.
-		_, err = web.refreshSalesforceRecords(ctx)
+		sfResults, err := web.refreshSalesforceRecords(ctx)
 		if err != nil {
 			// Todo: report errors to client.
 			web.log.Error(fmt.Sprintf("failed to refresh Salesforce records: %v", err))
+			if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+				web.sessions.Put(ctx, "message", e.Msg)
+			}
 			http.Redirect(w, r, "/refresh", http.StatusFound)
 			return nil
 		}
 		web.log.Info("Refresh successfully completed.")
 
-		// Redirect to invoices
+		// Report any records which failed validation or have missing or unreadable
+		// dates on the refresh page, otherwise redirect to invoices.
+		msg := strings.TrimSpace(domain.RejectedRecordsMessage(results.RejectedNo+sfResults.RejectedNo) + " " +
+			domain.SyncWarningsMessage(slices.Concat(results.Warnings, sfResults.Warnings)))
+		if msg != "" {
+			web.sessions.Put(ctx, refreshWarningsSessionKey, msg)
+			w.Header().Set("HX-Redirect", "/refresh")
+			w.WriteHeader(http.StatusOK)
+			return nil
+		}
 		w.Header().Set("HX-Redirect", "/invoices")
 		w.WriteHeader(http.StatusOK)
 		return nil
@@ -461,17 +623,32 @@ func (web *WebApp) handleInvoices() appHandler {
 		"base.html",
 		"nav.html",
 		"partial-listingTabs.html",
+		"partial-saved-searches.html",
+		"partial-preferences.html",
+		"partial-listing-params.html",
+		"partial-invoices-results.html",
+		"partial-pagination.html",
 		"invoices.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
-	dataStartDate := web.cfg.DataStartDate
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
 
 		// Initialise url parameter form and derive url.
-		form := NewSearchForm(&web.cfg.DataStartDate, nil)
+		form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+
+		// Redirect a request without filters to the default saved search, if any.
+		savedURL, err := web.savedSearchDefaultURL(ctx, r, "invoices")
+		if err != nil {
+			return err
+		}
+		if savedURL != "" {
+			web.log.Info(fmt.Sprintf("redirecting to saved search %s", savedURL))
+			http.Redirect(w, r, savedURL, http.StatusSeeOther)
+			return nil
+		}
 
 		// Check if a redirection is needed.
 		derivedURL, redirect, err := redirectCheck(ctx, form, web.sessions, r, thisURL)
@@ -506,8 +683,11 @@ func (web *WebApp) handleInvoices() appHandler {
 			return nil
 		}
 
+		// Get the display preferences of the listing.
+		prefs := web.listingPreferences(ctx, "invoices")
+
 		// Initialise pagination for default state.
-		pagination, _ := NewPagination(pageLen, 1, form.Page, r.URL.Query())
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
 
 		// Prepare data for the template, allowing passing of validation
 		// errors back to the template if necessary.
@@ -516,22 +696,36 @@ func (web *WebApp) handleInvoices() appHandler {
 			Invoices      []db.Invoice
 			Form          *SearchForm
 			Validator     *Validator
-			Pagination    *Pagination
+			Pagination    *paginate.Page
+			Preferences   Preferences
 			CurrentPage   string
-			ShortCode     string
 			DataStartDate time.Time
 			LastRefreshed time.Duration
+			SavedSearches []db.SavedSearch
+			SearchParams  string
+			ResultsURL    string
+			Fragment      bool
+			Message       string
 		}{
 			PageTitle:     "Invoices",
 			Form:          form,
 			Validator:     validator,
 			Pagination:    pagination,
+			Preferences:   prefs,
 			CurrentPage:   "invoices",
-			ShortCode:     web.sessions.GetString(ctx, "xero-shortcode"),
-			DataStartDate: dataStartDate,
+			ResultsURL:    thisURL + "/results",
+			DataStartDate: web.settings().DataStartDate,
 			LastRefreshed: lastRefreshed,
 		}
 
+		// Load the saved searches of the page.
+		data.SavedSearches, err = web.reconciler.SavedSearchesGet(ctx, "invoices")
+		if err != nil {
+			return err
+		}
+		data.SearchParams = r.URL.RawQuery
+		data.Message = web.sessions.PopString(ctx, "message")
+
 		// Render template with errors and return if the form is invalid.
 		if !validator.Valid() {
 			return web.render(w, r, templates, name, data)
@@ -543,8 +737,10 @@ func (web *WebApp) handleInvoices() appHandler {
 			form.DateFrom,
 			form.DateTo,
 			form.SearchString,
-			pageLen,
-			form.Offset(pageLen),
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
 		)
 		if err != nil && err != sql.ErrNoRows {
 			return err
@@ -562,7 +758,7 @@ func (web *WebApp) handleInvoices() appHandler {
 		} else {
 			recordsNo = data.Invoices[0].RowCount
 		}
-		data.Pagination, err = NewPagination(pageLen, recordsNo, form.Page, r.URL.Query())
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
 		if err != nil {
 			return err
 		}
@@ -583,17 +779,32 @@ func (web *WebApp) handleBankTransactions() appHandler {
 		"base.html",
 		"nav.html",
 		"partial-listingTabs.html",
+		"partial-saved-searches.html",
+		"partial-preferences.html",
+		"partial-listing-params.html",
+		"partial-bank-transactions-results.html",
+		"partial-pagination.html",
 		"bank-transactions.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
-	dataStartDate := web.cfg.DataStartDate
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
 
 		// Initialise url parameter form.
-		form := NewSearchForm(&web.cfg.DataStartDate, nil)
+		form := NewSearchForm(&web.settings().DataStartDate, nil, web.today())
+
+		// Redirect a request without filters to the default saved search, if any.
+		savedURL, err := web.savedSearchDefaultURL(ctx, r, "bank-transactions")
+		if err != nil {
+			return err
+		}
+		if savedURL != "" {
+			web.log.Info(fmt.Sprintf("redirecting to saved search %s", savedURL))
+			http.Redirect(w, r, savedURL, http.StatusSeeOther)
+			return nil
+		}
 
 		// Check if a redirection is needed.
 		derivedURL, redirect, err := redirectCheck(ctx, form, web.sessions, r, thisURL)
@@ -628,8 +839,11 @@ func (web *WebApp) handleBankTransactions() appHandler {
 			return nil
 		}
 
+		// Get the display preferences of the listing.
+		prefs := web.listingPreferences(ctx, "bank-transactions")
+
 		// Initialise pagination for default state.
-		pagination, _ := NewPagination(pageLen, 1, form.Page, r.URL.Query())
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
 
 		// Prepare data for the template, allowing passing of validation
 		// errors back to the template if necessary.
@@ -638,20 +852,36 @@ func (web *WebApp) handleBankTransactions() appHandler {
 			BankTransactions []db.BankTransaction
 			Form             *SearchForm
 			Validator        *Validator
-			Pagination       *Pagination
+			Pagination       *paginate.Page
+			Preferences      Preferences
 			CurrentPage      string
 			DataStartDate    time.Time
 			LastRefreshed    time.Duration
+			SavedSearches    []db.SavedSearch
+			SearchParams     string
+			ResultsURL       string
+			Fragment         bool
+			Message          string
 		}{
 			PageTitle:     "Bank Transactions",
 			Form:          form,
 			Validator:     validator,
 			Pagination:    pagination,
+			Preferences:   prefs,
 			CurrentPage:   "bank-transactions",
-			DataStartDate: dataStartDate,
+			ResultsURL:    thisURL + "/results",
+			DataStartDate: web.settings().DataStartDate,
 			LastRefreshed: lastRefreshed,
 		}
 
+		// Load the saved searches of the page.
+		data.SavedSearches, err = web.reconciler.SavedSearchesGet(ctx, "bank-transactions")
+		if err != nil {
+			return err
+		}
+		data.SearchParams = r.URL.RawQuery
+		data.Message = web.sessions.PopString(ctx, "message")
+
 		// Render template with errors and return if the form is invalid.
 		if !validator.Valid() {
 			return web.render(w, r, templates, name, data)
@@ -663,8 +893,10 @@ func (web *WebApp) handleBankTransactions() appHandler {
 			form.DateFrom,
 			form.DateTo,
 			form.SearchString,
-			pageLen,
-			form.Offset(pageLen),
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
 		)
 		if err != nil && err != sql.ErrNoRows {
 			return err
@@ -682,7 +914,7 @@ func (web *WebApp) handleBankTransactions() appHandler {
 		} else {
 			recordsNo = data.BankTransactions[0].RowCount
 		}
-		data.Pagination, err = NewPagination(pageLen, recordsNo, form.Page, r.URL.Query())
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
 		if err != nil {
 			return err
 		}
@@ -703,19 +935,33 @@ func (web *WebApp) handleDonations() appHandler {
 		"base.html",
 		"nav.html",
 		"partial-listingTabs.html",
+		"partial-saved-searches.html",
+		"partial-preferences.html",
 		"partial-donations-searchform.html",
+		"partial-listing-params.html",
 		"partial-donations-searchresults.html",
+		"partial-pagination.html",
 		"donations.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
-	dataStartDate := web.cfg.DataStartDate
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
 		ctx := r.Context()
 
 		// Initialise url parameter form.
-		form := NewSearchDonationsForm(&web.cfg.DataStartDate, nil)
+		form := NewSearchDonationsForm(&web.settings().DataStartDate, nil, web.today())
+
+		// Redirect a request without filters to the default saved search, if any.
+		savedURL, err := web.savedSearchDefaultURL(ctx, r, "donations")
+		if err != nil {
+			return err
+		}
+		if savedURL != "" {
+			web.log.Info(fmt.Sprintf("redirecting to saved search %s", savedURL))
+			http.Redirect(w, r, savedURL, http.StatusSeeOther)
+			return nil
+		}
 
 		// Check if a redirection is needed.
 		derivedURL, redirect, err := redirectCheck(ctx, form, web.sessions, r, thisURL)
@@ -750,11 +996,11 @@ func (web *WebApp) handleDonations() appHandler {
 			return nil
 		}
 
-		// Initialise pagination for default state.
-		pagination, _ := NewPagination(pageLen, 1, form.Page, r.URL.Query())
+		// Get the display preferences of the listing.
+		prefs := web.listingPreferences(ctx, "donations")
 
-		// Get salesforce instance url from the session (via OAuth2 token)
-		instanceURL := web.sessions.GetString(ctx, "salesforce-instance-url")
+		// Initialise pagination for default state.
+		pagination, _ := paginate.New(prefs.PageLen, 1, form.Page, r.URL.Query())
 
 		// Prepare data for the template, allowing passing of validation
 		// errors back to the template if necessary.
@@ -765,12 +1011,17 @@ func (web *WebApp) handleDonations() appHandler {
 			ID            string // needed to match the invoice/bank transaction struct
 			Typer         string
 			Validator     *Validator
-			Pagination    *Pagination
+			Pagination    *paginate.Page
+			Preferences   Preferences
 			CurrentPage   string
 			GetURL        string
-			SFInstanceURL string
 			DataStartDate time.Time
 			LastRefreshed time.Duration
+			SavedSearches []db.SavedSearch
+			SearchParams  string
+			ResultsURL    string
+			Fragment      bool
+			Message       string
 		}{
 			PageTitle:     "Donations",
 			Form:          form,
@@ -778,13 +1029,22 @@ func (web *WebApp) handleDonations() appHandler {
 			Typer:         "donations",
 			Validator:     validator,
 			Pagination:    pagination,
+			Preferences:   prefs,
 			CurrentPage:   "donations",
 			GetURL:        "/donations",
-			SFInstanceURL: instanceURL,
-			DataStartDate: dataStartDate,
+			ResultsURL:    thisURL + "/results",
+			DataStartDate: web.settings().DataStartDate,
 			LastRefreshed: lastRefreshed,
 		}
 
+		// Load the saved searches of the page.
+		data.SavedSearches, err = web.reconciler.SavedSearchesGet(ctx, "donations")
+		if err != nil {
+			return err
+		}
+		data.SearchParams = r.URL.RawQuery
+		data.Message = web.sessions.PopString(ctx, "message")
+
 		// Render template with errors and return if the form is invalid.
 		if !validator.Valid() {
 			return web.render(w, r, templates, name, data)
@@ -797,8 +1057,10 @@ func (web *WebApp) handleDonations() appHandler {
 			form.LinkageStatus,
 			form.PayoutReference,
 			form.SearchString,
-			pageLen,
-			form.Offset(pageLen),
+			web.assignedTo(ctx, form.Mine),
+			form.SortOrder(),
+			prefs.PageLen,
+			paginate.Offset(form.Page, prefs.PageLen),
 		)
 		if err != nil && err != sql.ErrNoRows {
 			return err
@@ -816,7 +1078,7 @@ func (web *WebApp) handleDonations() appHandler {
 		} else {
 			recordsNo = data.ViewDonations[0].RowCount
 		}
-		data.Pagination, err = NewPagination(pageLen, recordsNo, form.Page, r.URL.Query())
+		data.Pagination, err = paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
 		if err != nil {
 			web.log.Error(fmt.Sprintf("pagination error: %v", err))
 		}
@@ -840,11 +1102,18 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 		"partial-listingTabs.html",
 		"partial-donations-tabs.html",
 		"partial-donations-linked.html",
+		"partial-pagination.html",
 		"partial-donations-searchform.html",
+		"partial-listing-params.html",
 		"partial-donations-searchresults.html",
+		"partial-donation-splits.html",
+		"partial-annotations.html",
+		"partial-assignment.html",
+		"partial-neighbours.html",
+		"partial-donation-candidates.html",
 		"invoice.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
@@ -873,11 +1142,29 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 			return err
 		}
 
+		// Get the donation splits allocated to the invoice.
+		splits, err := web.reconciler.DonationSplitsGet(ctx, "invoice", invoiceID)
+		if err != nil {
+			return err
+		}
+
+		// Get the notes and flags of the invoice.
+		annotations, err := web.reconciler.AnnotationsGet(ctx, "invoice", invoiceID)
+		if err != nil {
+			return err
+		}
+
+		// Get the team member to whom the invoice is assigned.
+		assignedTo, err := web.reconciler.AssignmentGet(ctx, "invoice", invoiceID)
+		if err != nil {
+			return err
+		}
+
 		// Determine the dates for retrieving donations.
 		startDate, endDate := donationSearchTimeSpan(invoice.Date)
 
 		// Initialise url parameter form and derive default url.
-		form := NewSearchDonationsForm(&startDate, &endDate)
+		form := NewSearchDonationsForm(&startDate, &endDate, web.today())
 
 		// Derive the default url.
 		urlParams, err := form.AsURLParams()
@@ -910,7 +1197,7 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 			return err
 		}
 		if action == "unlink" {
-			form.DateFrom = web.cfg.DataStartDate
+			form.DateFrom = web.settings().DataStartDate
 			form.DateTo = time.Now().AddDate(1, 0, 0)
 			form.LinkageStatus = "Linked"
 			form.PayoutReference = invoice.InvoiceNumber
@@ -928,6 +1215,9 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 		validator := NewValidator()
 		form.Validate(validator)
 
+		// Get the display preferences of the donations listing.
+		prefs := web.listingPreferences(ctx, "donations")
+
 		// Get the donations if the form is valid
 		var viewDonations []domain.ViewDonation
 		if validator.Valid() {
@@ -938,8 +1228,10 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 				form.LinkageStatus,
 				form.PayoutReference,
 				form.SearchString,
-				pageLen,
-				form.Offset(pageLen),
+				"",
+				form.SortOrder(),
+				prefs.PageLen,
+				paginate.Offset(form.Page, prefs.PageLen),
 			)
 			if err != nil && err != sql.ErrNoRows {
 				return err
@@ -957,45 +1249,87 @@ func (web *WebApp) handleInvoiceDetail() appHandler {
 		}
 
 		// Todo: fix page number (here 1)
-		pagination, err := NewPagination(pageLen, recordsNo, form.Page, r.URL.Query())
+		pagination, err := paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
 		if err != nil {
 			web.log.Error(fmt.Sprintf("pagination error: %v", err))
 		}
 
+		// Get the unreconciled invoices either side of the invoice in the listing.
+		neighbours, err := web.listingNeighbours(ctx, "invoices", invoiceID)
+		if err != nil {
+			return err
+		}
+
+		// Get the candidate donations for linking to the invoice on the link tab.
+		var candidates []db.LinkSuggestion
+		if action == "link" {
+			candidates, err = web.reconciler.DonationCandidatesGet(ctx, "invoice", invoiceID)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Prepare data for the template.
 		data := struct {
-			PageTitle     string
-			Invoice       db.WRInvoice
-			LineItems     []domain.ViewLineItem
-			ID            string
-			DFK           string // for Invoices, this is the Invoice Number
-			Typer         string
-			ShortCode     string
-			CurrentPage   string
-			TabFocus      string
-			SFInstanceURL string
+			PageTitle   string
+			Invoice     db.WRInvoice
+			LineItems   []domain.ViewLineItem
+			ID          string
+			DFK         string // for Invoices, this is the Invoice Number
+			Typer       string
+			CurrentPage string
+			TabFocus    string
 
 			// Donation data
 			ViewDonations []domain.ViewDonation
 			Form          *SearchDonationsForm
 			Validator     *Validator
-			Pagination    *Pagination
+			Pagination    *paginate.Page
+			Preferences   Preferences
+
+			// Donation splits
+			Splits  []db.DonationSplit
+			Message string
+
+			// Notes and flags, and the assignee
+			Annotations []db.Annotation
+			AssignedTo  string
+
+			// WriteReferences allows the invoice reference to be updated on linking.
+			WriteReferences bool
+
+			// The unreconciled invoices either side in the listing.
+			Neighbours db.Neighbours
+
+			// The candidate donations for linking.
+			Candidates []db.LinkSuggestion
 		}{
-			PageTitle:     fmt.Sprintf("Invoice %s", invoiceID),
-			Invoice:       invoice,
-			LineItems:     viewLineItems,
-			ID:            invoice.ID,
-			DFK:           invoice.InvoiceNumber,
-			Typer:         "invoice",
-			ShortCode:     web.sessions.GetString(ctx, "xero-shortcode"),
-			CurrentPage:   "invoice-detail",
-			TabFocus:      action,
-			SFInstanceURL: web.sessions.GetString(ctx, "salesforce-instance-url"),
+			PageTitle:   fmt.Sprintf("Invoice %s", invoiceID),
+			Invoice:     invoice,
+			LineItems:   viewLineItems,
+			ID:          invoice.ID,
+			DFK:         invoice.InvoiceNumber,
+			Typer:       "invoice",
+			CurrentPage: "invoice-detail",
+			TabFocus:    action,
 
 			ViewDonations: viewDonations,
 			Form:          form,
 			Validator:     validator,
 			Pagination:    pagination,
+			Preferences:   prefs,
+
+			Splits:  splits,
+			Message: web.sessions.PopString(ctx, "message"),
+
+			Annotations: annotations,
+			AssignedTo:  assignedTo,
+
+			WriteReferences: web.cfg.Xero.WriteInvoiceReferences,
+
+			Neighbours: neighbours,
+
+			Candidates: candidates,
 		}
 
 		web.log.Debug(fmt.Sprintf("invoiceDetail: about to complete: %s", thisURL))
@@ -1015,11 +1349,18 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 		"partial-listingTabs.html",
 		"partial-donations-tabs.html",
 		"partial-donations-linked.html",
+		"partial-pagination.html",
 		"partial-donations-searchform.html",
+		"partial-listing-params.html",
 		"partial-donations-searchresults.html",
+		"partial-donation-splits.html",
+		"partial-annotations.html",
+		"partial-assignment.html",
+		"partial-neighbours.html",
+		"partial-donation-candidates.html",
 		"bank-transaction.html",
 	}
-	templates := template.Must(template.ParseFS(web.templateFS, tpls...))
+	templates := web.parseTemplates(tpls...)
 
 	return func(w http.ResponseWriter, r *http.Request) error {
 
@@ -1048,11 +1389,29 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 			return err
 		}
 
+		// Get the donation splits allocated to the transaction.
+		splits, err := web.reconciler.DonationSplitsGet(ctx, "bank-transaction", transactionID)
+		if err != nil {
+			return err
+		}
+
+		// Get the notes and flags of the transaction.
+		annotations, err := web.reconciler.AnnotationsGet(ctx, "bank-transaction", transactionID)
+		if err != nil {
+			return err
+		}
+
+		// Get the team member to whom the bank transaction is assigned.
+		assignedTo, err := web.reconciler.AssignmentGet(ctx, "bank-transaction", transactionID)
+		if err != nil {
+			return err
+		}
+
 		// Determine the dates for retrieving donations.
 		startDate, endDate := donationSearchTimeSpan(transaction.Date)
 
 		// Initialise url parameter form and derive default url.
-		form := NewSearchDonationsForm(&startDate, &endDate)
+		form := NewSearchDonationsForm(&startDate, &endDate, web.today())
 
 		// Derive the default url.
 		urlParams, err := form.AsURLParams()
@@ -1093,7 +1452,7 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 		}
 
 		if action == "unlink" {
-			form.DateFrom = web.cfg.DataStartDate
+			form.DateFrom = web.settings().DataStartDate
 			form.DateTo = time.Now().AddDate(1, 0, 0)
 			form.LinkageStatus = "Linked"
 			form.PayoutReference = DFK
@@ -1112,6 +1471,9 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 		validator := NewValidator()
 		form.Validate(validator)
 
+		// Get the display preferences of the donations listing.
+		prefs := web.listingPreferences(ctx, "donations")
+
 		// Get the donations if the form is valid
 		var viewDonations []domain.ViewDonation
 		if validator.Valid() {
@@ -1122,8 +1484,10 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 				form.LinkageStatus,
 				form.PayoutReference,
 				form.SearchString,
-				pageLen,
-				form.Offset(pageLen),
+				"",
+				form.SortOrder(),
+				prefs.PageLen,
+				paginate.Offset(form.Page, prefs.PageLen),
 			)
 			if err != nil && err != sql.ErrNoRows {
 				return err
@@ -1141,45 +1505,83 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 		}
 
 		// Todo: fix page number (here 1)
-		pagination, err := NewPagination(pageLen, recordsNo, form.Page, r.URL.Query())
+		pagination, err := paginate.New(prefs.PageLen, recordsNo, form.Page, r.URL.Query())
 		if err != nil {
 			web.log.Error(fmt.Sprintf("pagination error: %v", err))
 		}
 
+		// Get the unreconciled bank transactions either side of the transaction in the
+		// listing.
+		neighbours, err := web.listingNeighbours(ctx, "bank-transactions", transactionID)
+		if err != nil {
+			return err
+		}
+
+		// Get the candidate donations for linking to the bank transaction on the link tab.
+		var candidates []db.LinkSuggestion
+		if action == "link" {
+			candidates, err = web.reconciler.DonationCandidatesGet(ctx, "bank-transaction", transactionID)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Prepare data for the template.
 		data := struct {
-			PageTitle     string
-			Transaction   db.WRTransaction
-			LineItems     []domain.ViewLineItem
-			ID            string
-			DFK           string // for transactions, this is the Reference
-			Typer         string
-			ShortCode     string
-			CurrentPage   string
-			TabFocus      string
-			SFInstanceURL string
+			PageTitle   string
+			Transaction db.WRTransaction
+			LineItems   []domain.ViewLineItem
+			ID          string
+			DFK         string // for transactions, this is the Reference
+			Typer       string
+			CurrentPage string
+			TabFocus    string
 
 			// Donation data
 			ViewDonations []domain.ViewDonation
 			Form          *SearchDonationsForm
 			Validator     *Validator
-			Pagination    *Pagination
+			Pagination    *paginate.Page
+			Preferences   Preferences
+
+			// Donation splits
+			Splits  []db.DonationSplit
+			Message string
+
+			// Notes and flags, and the assignee
+			Annotations []db.Annotation
+			AssignedTo  string
+
+			// The unreconciled bank transactions either side in the listing.
+			Neighbours db.Neighbours
+
+			// The candidate donations for linking.
+			Candidates []db.LinkSuggestion
 		}{
-			PageTitle:     fmt.Sprintf("Bank Transaction %s", transaction.ID),
-			Transaction:   transaction,
-			LineItems:     viewLineItems,
-			ID:            transaction.ID,
-			DFK:           DFK,
-			Typer:         "bank-transaction",
-			ShortCode:     web.sessions.GetString(ctx, "xero-shortcode"),
-			CurrentPage:   "transaction-detail",
-			TabFocus:      action,
-			SFInstanceURL: web.sessions.GetString(ctx, "salesforce-instance-url"),
+			PageTitle:   fmt.Sprintf("Bank Transaction %s", transaction.ID),
+			Transaction: transaction,
+			LineItems:   viewLineItems,
+			ID:          transaction.ID,
+			DFK:         DFK,
+			Typer:       "bank-transaction",
+			CurrentPage: "transaction-detail",
+			TabFocus:    action,
 
 			ViewDonations: viewDonations,
 			Form:          form,
 			Validator:     validator,
 			Pagination:    pagination,
+			Preferences:   prefs,
+
+			Splits:  splits,
+			Message: web.sessions.PopString(ctx, "message"),
+
+			Annotations: annotations,
+			AssignedTo:  assignedTo,
+
+			Neighbours: neighbours,
+
+			Candidates: candidates,
 		}
 
 		web.log.Debug(fmt.Sprintf("transactionDetail: about to complete: %s", thisURL))
@@ -1192,10 +1594,29 @@ func (web *WebApp) handleBankTransactionDetail() appHandler {
 // Helpers
 /* -------------------------------------------------------------------------- */
 
-// render renders the specified template.
-func (web *WebApp) render(w http.ResponseWriter, r *http.Request, template *template.Template, filename string, data any) error {
+// parseTemplates parses the provided templates from the template filesystem with the
+// shared template funcs. Template funcs that rely on request data, such as the CSRF
+// helpers and the locale, are bound to the request in render.
+func (web *WebApp) parseTemplates(tpls ...string) *template.Template {
+	funcs := web.templateFuncs
+	if funcs == nil { // the WebApp was not made by New
+		funcs = web.newTemplateFuncs()
+	}
+	tpl := template.New("").Funcs(funcs)
+	return template.Must(tpl.ParseFS(web.templateFS, tpls...))
+}
+
+// render renders the specified template. The templates are cloned to allow the request
+// specific template funcs to be bound for this request.
+func (web *WebApp) render(w http.ResponseWriter, r *http.Request, templates *template.Template, filename string, data any) error {
+	tpl, err := templates.Clone()
+	if err != nil {
+		return err
+	}
+	tpl.Funcs(web.requestTemplateFuncs(r.Context()))
+
 	buf := new(bytes.Buffer)
-	err := template.ExecuteTemplate(buf, filename, data)
+	err = tpl.ExecuteTemplate(buf, filename, data)
 	if err != nil {
 		return err
 	}
@@ -2,23 +2,31 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/rorycl/reconciler/apiclients/salesforce"
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/db"
 	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/donorimport"
+	"github.com/rorycl/reconciler/internal/money"
 	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"github.com/rorycl/reconciler/internal/payoutcsv"
+	"github.com/rorycl/reconciler/internal/statementcsv"
 	"github.com/rorycl/reconciler/internal/token"
 
 	"golang.org/x/oauth2"
@@ -26,54 +34,533 @@ import (
 
 type reconciliationMock struct {
 	donationsGet                    int
-	donationsLinkUnlink             int
+	linkActionRun                   int
 	invoiceDetailGet                int
 	invoicesGet                     int
+	invoiceNeighbours               int
 	transactionDetailGet            int
 	transactionsGet                 int
+	lineItemsExportGet              int
+	transactionNeighbours           int
 	invoiceOrBankTransactionInfoGet int
+	contactDetailGet                int
+	contactAliasesGet               int
+	contactAliasAdd                 int
+	contactAliasRemove              int
+	xeroShortCodeGet                int
+	salesforceInstanceURLGet        int
+	salesforceInstanceURLUpsert     int
+	toleranceGet                    int
+	toleranceUpsert                 int
+	accountsGet                     int
+	donationAccountsSet             int
+	donationAccountsReset           int
+	accountRulesGet                 int
+	accountRuleAdd                  int
+	accountRuleDelete               int
+	periodLocksGet                  int
+	periodLockAdd                   int
+	periodLockRemove                int
+	auditEventsGet                  int
+	periodSnapshotsGet              int
+	periodSnapshotCompare           int
+	donationSplitsGet               int
+	donationSplitUpsert             int
+	donationSplitDelete             int
+	annotationsGet                  int
+	annotationAdd                   int
+	annotationDelete                int
+	assignmentGet                   int
+	assignmentSet                   int
+	search                          int
+	savedSearchesGet                int
+	savedSearchUpsert               int
+	savedSearchDelete               int
+	pendingActionsGet               int
+	pendingActionRetry              int
+	pendingActionCompensate         int
+	outboxRetry                     int
+	outboxActionsGet                int
+	donationOrphansCheck            int
+	linksVerify                     int
+	donationOrphansGet              int
+	donationOrphansRemove           int
+	linkSuggestionsGet              int
+	donationCandidatesGet           int
+	linkSuggestionDecisionsApply    int
+	periodReportGet                 int
+	giftAidClaimGet                 int
+	agingReportGet                  int
+	payoutBatchGet                  int
+	payoutCandidatesLink            int
+	payoutCombinationLink           int
+	payoutItemsStage                int
+	statementLinesStage             int
+	statementReportGet              int
+	donationsStage                  int
+	importBatchesGet                int
+	importReviewGet                 int
+	importCommit                    int
+	importDiscard                   int
+	accountBreakdownReportGet       int
+	payoutGroupsReportGet           int
 	xeroRecordsRefresh              int
 	salesforceRecordsRefresh        int
+	salesforceChangesSubscribe      int
+	recordRefresh                   int
+	syncRunsGet                     int
+	backfill                        int
+	importErrorsGet                 int
+	importErrorRetry                int
+	importErrorDiscard              int
 	dbIsInMemory                    int
 	dbPath                          int
+	sqlReload                       int
+	slowQueriesGet                  int
+	snapshotExport                  int
+	snapshotImport                  int
+	linkMappingsExport              int
+	linkMappingsImport              int
 	closeCalled                     int
+
+	payoutLinkErr       error   // returned by PayoutCandidatesLink
+	outboxActions       []int64 // returned by OutboxActionsGet
+	periodLocksDisabled bool    // negated by PeriodLocksEnabled
 }
 
-func (r *reconciliationMock) DonationsGet(context.Context, time.Time, time.Time, string, string, string, int, int) ([]domain.ViewDonation, error) {
+func (r *reconciliationMock) DonationsGet(context.Context, time.Time, time.Time, string, string, string, string, db.SortOrder, int, int) ([]domain.ViewDonation, error) {
 	r.donationsGet++
 	return nil, nil
 }
-func (r *reconciliationMock) DonationsLinkUnlink(context.Context, domain.SalesforceClient, []salesforce.IDRef, time.Time, time.Time) error {
-	r.donationsLinkUnlink++
+func (r *reconciliationMock) LinkActionRun(context.Context, domain.SalesforceClient, domain.XeroClient, domain.LinkAction, time.Time, time.Time) error {
+	r.linkActionRun++
 	return nil
 }
 func (r *reconciliationMock) InvoiceDetailGet(context.Context, string) (db.WRInvoice, []domain.ViewLineItem, error) {
 	r.invoiceDetailGet++
 	return db.WRInvoice{}, nil, nil
 }
-func (r *reconciliationMock) InvoicesGet(context.Context, string, time.Time, time.Time, string, int, int) ([]db.Invoice, error) {
+func (r *reconciliationMock) InvoicesGet(context.Context, string, time.Time, time.Time, string, string, db.SortOrder, int, int) ([]db.Invoice, error) {
 	r.invoicesGet++
 	return nil, nil
 }
+func (r *reconciliationMock) InvoiceNeighbours(context.Context, string, time.Time, time.Time, string, string, db.SortOrder) (db.Neighbours, error) {
+	r.invoiceNeighbours++
+	return db.Neighbours{Previous: "inv-prev", Next: "inv-next", Position: 2, Count: 3}, nil
+}
+func (r *reconciliationMock) LineItemsExportGet(context.Context, string, string, time.Time, time.Time, string, string, db.SortOrder) ([]db.LineItemExport, error) {
+	r.lineItemsExportGet++
+	return []db.LineItemExport{{RecordType: "invoice", RecordID: "inv-001", Number: "INV-001", AccountCode: "5501"}}, nil
+}
 func (r *reconciliationMock) TransactionDetailGet(context.Context, string) (db.WRTransaction, []domain.ViewLineItem, error) {
 	r.transactionDetailGet++
 	return db.WRTransaction{}, nil, nil
 }
-func (r *reconciliationMock) TransactionsGet(context.Context, string, time.Time, time.Time, string, int, int) ([]db.BankTransaction, error) {
+func (r *reconciliationMock) TransactionsGet(context.Context, string, time.Time, time.Time, string, string, db.SortOrder, int, int) ([]db.BankTransaction, error) {
 	r.transactionsGet++
 	return nil, nil
 }
+func (r *reconciliationMock) TransactionNeighbours(context.Context, string, time.Time, time.Time, string, string, db.SortOrder) (db.Neighbours, error) {
+	r.transactionNeighbours++
+	return db.Neighbours{Next: "bt-next", Position: 1, Count: 2}, nil
+}
 func (r *reconciliationMock) InvoiceOrBankTransactionInfoGet(context.Context, string, string) (string, time.Time, error) {
 	r.invoiceOrBankTransactionInfoGet++
 	return "", time.Time{}, nil
 }
+func (r *reconciliationMock) XeroShortCodeGet(context.Context) (string, error) {
+	r.xeroShortCodeGet++
+	return "", nil
+}
+func (r *reconciliationMock) SalesforceInstanceURLGet(context.Context) (string, error) {
+	r.salesforceInstanceURLGet++
+	return "", nil
+}
+func (r *reconciliationMock) SalesforceInstanceURLUpsert(context.Context, string) error {
+	r.salesforceInstanceURLUpsert++
+	return nil
+}
+func (r *reconciliationMock) ToleranceGet(context.Context) (db.Tolerance, error) {
+	r.toleranceGet++
+	return db.Tolerance{}, nil
+}
+func (r *reconciliationMock) ToleranceUpsert(context.Context, db.Tolerance) error {
+	r.toleranceUpsert++
+	return nil
+}
+func (r *reconciliationMock) AccountsGet(context.Context) ([]db.Account, error) {
+	r.accountsGet++
+	selected := true
+	return []db.Account{
+		{ID: "acc-5501", Code: "5501", Name: "General Giving", Type: "REVENUE", Status: "ACTIVE"},
+		{ID: "acc-9999", Code: "9999", Name: "Arbitrary", Type: "LIABILITY", Status: "ACTIVE", DonationAccount: &selected},
+	}, nil
+}
+func (r *reconciliationMock) DonationAccountsSet(_ context.Context, codes []string) error {
+	r.donationAccountsSet++
+	if len(codes) == 0 {
+		return domain.ErrUsage{Msg: "At least one account must be selected as a donation account"}
+	}
+	return nil
+}
+func (r *reconciliationMock) DonationAccountsReset(context.Context) error {
+	r.donationAccountsReset++
+	return nil
+}
+func (r *reconciliationMock) DonationAccountsSelected() []string {
+	return nil
+}
+func (r *reconciliationMock) DonationAccountsRegexp(configured *regexp.Regexp) *regexp.Regexp {
+	return configured
+}
+func (r *reconciliationMock) AccountRulesGet(context.Context) ([]db.AccountRule, error) {
+	r.accountRulesGet++
+	return []db.AccountRule{{ID: 1, Field: "contact", Pattern: "paypal giving fund", AccountCode: "5501", LineItems: 3}}, nil
+}
+func (r *reconciliationMock) AccountRuleAdd(_ context.Context, _, pattern, _ string) error {
+	r.accountRuleAdd++
+	if pattern == "" {
+		return domain.ErrUsage{Msg: "The rule pattern may not be empty"}
+	}
+	return nil
+}
+func (r *reconciliationMock) AccountRuleDelete(context.Context, int64) error {
+	r.accountRuleDelete++
+	return nil
+}
+func (r *reconciliationMock) PeriodLocksGet(context.Context) ([]db.PeriodLock, error) {
+	r.periodLocksGet++
+	from, to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	return []db.PeriodLock{{ID: 1, DateFrom: from, DateTo: to, Reason: "2024-25 accounts finalised"}}, nil
+}
+func (r *reconciliationMock) PeriodLocksEnabled() bool {
+	return !r.periodLocksDisabled
+}
+func (r *reconciliationMock) PeriodLockAdd(_ context.Context, from, to time.Time, _ string) error {
+	r.periodLockAdd++
+	if to.Before(from) {
+		return domain.ErrUsage{Msg: "The period lock end date may not be before the start date"}
+	}
+	return nil
+}
+func (r *reconciliationMock) PeriodLockRemove(context.Context, int64, string) error {
+	r.periodLockRemove++
+	return nil
+}
+func (r *reconciliationMock) AuditEventsGet(context.Context) ([]db.AuditEvent, error) {
+	r.auditEventsGet++
+	return []db.AuditEvent{{ID: 1, Event: db.AuditLock, Description: "locked 2024-04-01 to 2025-03-31", Reason: "2024-25 accounts finalised"}}, nil
+}
+func (r *reconciliationMock) PeriodSnapshotsGet(context.Context) ([]db.PeriodSnapshot, error) {
+	r.periodSnapshotsGet++
+	from, to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	return []db.PeriodSnapshot{{ID: 1, PeriodLockID: 1, DateFrom: from, DateTo: to, Invoices: 2, InvoicesReconciled: 1}}, nil
+}
+func (r *reconciliationMock) PeriodSnapshotCompare(_ context.Context, id int64) (*domain.PeriodSnapshotComparison, error) {
+	r.periodSnapshotCompare++
+	if id != 1 {
+		return nil, domain.ErrNotFound{Msg: "The period snapshot was not found"}
+	}
+	from, to := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	return &domain.PeriodSnapshotComparison{
+		Snapshot: db.PeriodSnapshot{ID: 1, DateFrom: from, DateTo: to, Invoices: 2, InvoicesReconciled: 1},
+		Current:  db.PeriodSnapshot{DateFrom: from, DateTo: to, Invoices: 2},
+		Changes: []domain.PeriodSnapshotChange{
+			{RecordType: "invoice", ID: "inv-001", Ref: "INV-2025-101", Change: domain.SnapshotUnreconciled, Before: money.FromFloat(500)},
+		},
+	}, nil
+}
+func (r *reconciliationMock) DonationSplitsGet(context.Context, string, string) ([]db.DonationSplit, error) {
+	r.donationSplitsGet++
+	return nil, nil
+}
+func (r *reconciliationMock) DonationSplitUpsert(context.Context, string, string, string, money.Amount) error {
+	r.donationSplitUpsert++
+	return nil
+}
+func (r *reconciliationMock) DonationSplitDelete(context.Context, string, string, int64) error {
+	r.donationSplitDelete++
+	return nil
+}
+func (r *reconciliationMock) AnnotationsGet(context.Context, string, string) ([]db.Annotation, error) {
+	r.annotationsGet++
+	return nil, nil
+}
+func (r *reconciliationMock) AnnotationAdd(_ context.Context, _, _, note string, _ bool) error {
+	r.annotationAdd++
+	if strings.TrimSpace(note) == "" {
+		return domain.ErrUsage{Msg: "The note may not be empty"}
+	}
+	return nil
+}
+func (r *reconciliationMock) AnnotationDelete(context.Context, string, string, int64) error {
+	r.annotationDelete++
+	return nil
+}
+func (r *reconciliationMock) AssignmentGet(context.Context, string, string) (string, error) {
+	r.assignmentGet++
+	return "", nil
+}
+func (r *reconciliationMock) AssignmentSet(context.Context, string, string, string) error {
+	r.assignmentSet++
+	return nil
+}
+func (r *reconciliationMock) Search(context.Context, string, int) ([]db.SearchResult, error) {
+	r.search++
+	return []db.SearchResult{{RecordType: "invoice", RecordID: "inv-001", Reference: "INV-001"}}, nil
+}
+func (r *reconciliationMock) SavedSearchesGet(context.Context, string) ([]db.SavedSearch, error) {
+	r.savedSearchesGet++
+	return nil, nil
+}
+func (r *reconciliationMock) SavedSearchUpsert(context.Context, string, string, string, bool) error {
+	r.savedSearchUpsert++
+	return nil
+}
+func (r *reconciliationMock) SavedSearchDelete(context.Context, string, int64) error {
+	r.savedSearchDelete++
+	return nil
+}
+func (r *reconciliationMock) ContactDetailGet(context.Context, string) (db.Contact, []db.ContactRecord, error) {
+	r.contactDetailGet++
+	return db.Contact{}, nil, nil
+}
+func (r *reconciliationMock) ContactAliasesGet(context.Context, string) ([]db.ContactAlias, []db.ContactAliasCandidate, error) {
+	r.contactAliasesGet++
+	aliases := []db.ContactAlias{{Alias: "stripe payments uk", Name: "Stripe Payments UK", ContactID: "con-stripe", ContactName: "Stripe", Records: 2}}
+	candidates := []db.ContactAliasCandidate{{Alias: "stripe", Name: "STRIPE"}}
+	return aliases, candidates, nil
+}
+func (r *reconciliationMock) ContactAliasAdd(_ context.Context, _, name string) error {
+	r.contactAliasAdd++
+	if name == "" {
+		return domain.ErrUsage{Msg: "The alias is required"}
+	}
+	return nil
+}
+func (r *reconciliationMock) ContactAliasRemove(context.Context, string) error {
+	r.contactAliasRemove++
+	return nil
+}
+func (r *reconciliationMock) PendingActionsGet(context.Context, bool) ([]db.PendingAction, error) {
+	r.pendingActionsGet++
+	return nil, nil
+}
+func (r *reconciliationMock) PendingActionRetry(context.Context, domain.SalesforceClient, domain.XeroClient, int64, time.Time, time.Time) error {
+	r.pendingActionRetry++
+	return nil
+}
+
+func (r *reconciliationMock) SyncRunsGet(context.Context) ([]db.SyncRun, error) {
+	r.syncRunsGet++
+	started := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	return []db.SyncRun{{
+		ID:         1,
+		Source:     "salesforce",
+		StartedAt:  started,
+		FinishedAt: started.Add(2 * time.Second),
+		APICalls:   1,
+		Entities:   db.SyncRunEntities{{RecordType: "donation"}},
+	}}, nil
+}
+func (r *reconciliationMock) Backfill(ctx context.Context, xeroClient domain.XeroClient, sfClient domain.SalesforceClient, recordType string, dateFrom, dateTo time.Time, accountsRegexp *regexp.Regexp) (*domain.BackfillResults, error) {
+	r.backfill++
+	return &domain.BackfillResults{RecordType: recordType, RecordsNo: 2}, nil
+}
+func (r *reconciliationMock) ImportErrorsGet(context.Context) ([]db.ImportError, error) {
+	r.importErrorsGet++
+	return []db.ImportError{{ID: 1, Source: "xero", RecordType: "invoice", RecordID: "inv-001", Error: "failed", Payload: "{}"}}, nil
+}
+func (r *reconciliationMock) ImportErrorRetry(context.Context, domain.XeroClient, domain.SalesforceClient, int64) error {
+	r.importErrorRetry++
+	return nil
+}
+func (r *reconciliationMock) ImportErrorDiscard(context.Context, int64) error {
+	r.importErrorDiscard++
+	return nil
+}
+
+func (r *reconciliationMock) OutboxRetry(context.Context, domain.SalesforceClient, domain.XeroClient, time.Time, time.Time) (domain.OutboxResults, error) {
+	r.outboxRetry++
+	return domain.OutboxResults{}, nil
+}
+func (r *reconciliationMock) OutboxActionsGet(context.Context) ([]int64, error) {
+	r.outboxActionsGet++
+	return r.outboxActions, nil
+}
+func (r *reconciliationMock) PendingActionCompensate(context.Context, domain.SalesforceClient, domain.XeroClient, int64, time.Time, time.Time) error {
+	r.pendingActionCompensate++
+	return nil
+}
+func (r *reconciliationMock) DonationOrphansCheck(context.Context, domain.SalesforceClient, time.Time) (*domain.OrphanResults, error) {
+	r.donationOrphansCheck++
+	return &domain.OrphanResults{}, nil
+}
+func (r *reconciliationMock) LinksVerify(context.Context, domain.SalesforceClient, domain.XeroClient, int) (*domain.LinkVerifyResults, error) {
+	r.linksVerify++
+	return &domain.LinkVerifyResults{
+		LinksNo: 2, DonationsNo: 2, RecordsNo: 2,
+		Mismatches: []domain.LinkMismatch{
+			{DonationID: "sf-opp-001", DonationName: "Mismatched Donation", RecordType: "invoice", RecordID: "inv-001", Reference: "INV-001", Source: "salesforce", Remote: "INV-CHANGED"},
+		},
+	}, nil
+}
+func (r *reconciliationMock) DonationOrphansGet(context.Context) ([]db.DonationOrphan, error) {
+	r.donationOrphansGet++
+	return nil, nil
+}
+func (r *reconciliationMock) DonationOrphansRemove(context.Context, []string) (int, error) {
+	r.donationOrphansRemove++
+	return 0, nil
+}
+func (r *reconciliationMock) LinkSuggestionsGet(context.Context, time.Time, time.Time, float64) ([]db.LinkSuggestion, error) {
+	r.linkSuggestionsGet++
+	return nil, nil
+}
+func (r *reconciliationMock) DonationCandidatesGet(_ context.Context, typer, id string) ([]db.LinkSuggestion, error) {
+	r.donationCandidatesGet++
+	return []db.LinkSuggestion{
+		{Typer: typer, RecordID: id, DonationID: "sf-candidate-01", DonationName: "Candidate Donation", Score: 0.75},
+	}, nil
+}
+func (r *reconciliationMock) LinkSuggestionDecisionsApply(context.Context, domain.SalesforceClient, []domain.SuggestionDecision, time.Time, time.Time) (*domain.SuggestionDecisionResults, error) {
+	r.linkSuggestionDecisionsApply++
+	return &domain.SuggestionDecisionResults{}, nil
+}
+func (r *reconciliationMock) PeriodReportGet(_ context.Context, from, to time.Time) (*domain.PeriodReport, error) {
+	r.periodReportGet++
+	return &domain.PeriodReport{DateFrom: from, DateTo: to}, nil
+}
+func (r *reconciliationMock) GiftAidClaimGet(_ context.Context, from, to time.Time, _ *regexp.Regexp, _ domain.GiftAidFields) (*domain.GiftAidClaim, error) {
+	r.giftAidClaimGet++
+	return &domain.GiftAidClaim{DateFrom: from, DateTo: to}, nil
+}
+func (r *reconciliationMock) AgingReportGet(_ context.Context, from, asAt time.Time) (*domain.AgingReport, error) {
+	r.agingReportGet++
+	return &domain.AgingReport{DateFrom: from, AsAt: asAt}, nil
+}
+func (r *reconciliationMock) PayoutBatchGet(_ context.Context, id string, _ float64) (*domain.PayoutBatch, error) {
+	r.payoutBatchGet++
+	now := time.Now()
+	reference := "JG-PAYOUT-2025-04-15"
+	return &domain.PayoutBatch{
+		Transaction: db.WRTransaction{ID: id, Reference: &reference},
+		Reference:   reference,
+		Donations:   []domain.ViewDonation{{ID: "sf-opp-003", Name: "Anonymous Donor", Amount: money.FromFloat(20)}},
+		Candidates: []db.LinkSuggestion{
+			{Typer: "bank-transaction", RecordID: id, DonationID: "sf-opp-019", DonationName: "Social Media Donation 2", DonationCloseDate: &now},
+		},
+		Combinations: []domain.PayoutCombination{
+			{Donations: []db.LinkSuggestion{
+				{Typer: "bank-transaction", RecordID: id, DonationID: "sf-opp-019", DonationName: "Social Media Donation 2", DonationCloseDate: &now},
+			}},
+		},
+		CombinationsComplete: true,
+		Items: []db.PayoutItem{
+			{ItemRef: "JG-1001", Date: now, Name: "Jane Smith", Gross: money.FromFloat(20), Fee: money.FromFloat(0.5), Net: money.FromFloat(19.5)},
+		},
+	}, nil
+}
+func (r *reconciliationMock) PayoutCandidatesLink(context.Context, domain.SalesforceClient, string, float64, time.Time, time.Time) (*domain.SuggestionDecisionResults, error) {
+	r.payoutCandidatesLink++
+	if r.payoutLinkErr != nil {
+		return nil, r.payoutLinkErr
+	}
+	return &domain.SuggestionDecisionResults{}, nil
+}
+func (r *reconciliationMock) PayoutCombinationLink(_ context.Context, _ domain.SalesforceClient, _ string, donationIDs []string, _, _ time.Time) (*domain.SuggestionDecisionResults, error) {
+	r.payoutCombinationLink++
+	return &domain.SuggestionDecisionResults{Linked: len(donationIDs)}, nil
+}
+func (r *reconciliationMock) PayoutItemsStage(_ context.Context, _, _, _ string, items []payoutcsv.Item) (int64, error) {
+	r.payoutItemsStage++
+	return 1, nil
+}
+func (r *reconciliationMock) StatementLinesStage(_ context.Context, _, _ string, lines []statementcsv.Line) (int64, error) {
+	r.statementLinesStage++
+	return 3, nil
+}
+func (r *reconciliationMock) StatementReportGet(_ context.Context, from, to time.Time, unmatched bool) (*domain.StatementReport, error) {
+	r.statementReportGet++
+	transactionID, reference := "bt-002", "STRIPE-PAYOUT-2025-04-20"
+	return &domain.StatementReport{
+		DateFrom: from,
+		DateTo:   to,
+		Lines: []db.StatementMatch{
+			{StatementLine: db.StatementLine{BankAccount: "Current Account", Date: from, Amount: money.FromFloat(42), Description: "CHEQUE DEPOSIT"}},
+			{
+				StatementLine:        db.StatementLine{BankAccount: "Current Account", Date: from, Amount: money.FromFloat(490), Description: "STRIPE"},
+				TransactionID:        &transactionID,
+				TransactionReference: &reference,
+				DonationTotal:        money.FromFloat(500),
+			},
+		},
+		DiffersNo:    1,
+		MissingNo:    1,
+		MissingTotal: money.FromFloat(42),
+	}, nil
+}
+func (r *reconciliationMock) DonationsStage(_ context.Context, source string, sheet *donorimport.Sheet, mapping donorimport.Mapping) (int64, error) {
+	r.donationsStage++
+	if err := mapping.Validate(sheet.Headers); err != nil {
+		return 0, domain.ErrUsage{Msg: err.Error()}
+	}
+	return 2, nil
+}
+func (r *reconciliationMock) ImportBatchesGet(context.Context) ([]db.ImportBatch, error) {
+	r.importBatchesGet++
+	return []db.ImportBatch{
+		{ID: 2, Kind: db.ImportDonations, Target: "crm", FileName: "export.csv", Status: "staged", CreatedAt: time.Now(), Accepted: 1, Rejected: 1},
+	}, nil
+}
+func (r *reconciliationMock) ImportReviewGet(_ context.Context, id int64) (*domain.ImportReview, error) {
+	r.importReviewGet++
+	return &domain.ImportReview{
+		Batch: db.ImportBatch{ID: id, Kind: db.ImportDonations, Target: "crm", FileName: "export.csv", Status: "staged", CreatedAt: time.Now(), Accepted: 1, Rejected: 1},
+		Rows: []domain.ImportReviewRow{
+			{RowNo: 2, RecordID: "1001", Values: []string{"1001", "Jane Smith", "20.00", "2025-04-14"}},
+			{RowNo: 3, RecordID: "1002", Values: []string{"1002", "John Smith", "ten", "2025-04-15"}, Error: `row 3 Amount: invalid amount: "ten"`},
+		},
+	}, nil
+}
+func (r *reconciliationMock) ImportCommit(_ context.Context, id int64) (*domain.ImportCommitResult, error) {
+	r.importCommit++
+	return &domain.ImportCommitResult{Batch: db.ImportBatch{ID: id, Kind: db.ImportDonations, Target: "crm"}, Committed: 1}, nil
+}
+func (r *reconciliationMock) ImportDiscard(context.Context, int64) error {
+	r.importDiscard++
+	return nil
+}
+func (r *reconciliationMock) AccountBreakdownReportGet(_ context.Context, from, to time.Time) (*domain.AccountBreakdownReport, error) {
+	r.accountBreakdownReportGet++
+	return &domain.AccountBreakdownReport{DateFrom: from, DateTo: to}, nil
+}
+func (r *reconciliationMock) PayoutGroupsReportGet(_ context.Context, from, to time.Time, unmatched bool) (*domain.PayoutGroupsReport, error) {
+	r.payoutGroupsReportGet++
+	return &domain.PayoutGroupsReport{
+		DateFrom: from,
+		DateTo:   to,
+		Groups: []db.PayoutGroup{
+			{Reference: "SF-ONLY-REF", Date: "2025-06-01", DonationCount: 1, DonationTotal: money.FromFloat(25)},
+		},
+		SalesforceOnlyNo: 1,
+	}, nil
+}
 func (r *reconciliationMock) SalesforceRecordsRefresh(context.Context, domain.SalesforceClient, time.Time, time.Time) (*domain.RefreshSalesforceResults, error) {
 	r.salesforceRecordsRefresh++
-	return nil, nil
+	return &domain.RefreshSalesforceResults{}, nil
+}
+func (r *reconciliationMock) SalesforceChangesSubscribe(context.Context, domain.SalesforceClient, time.Time, func(domain.SalesforceChangeResults)) error {
+	r.salesforceChangesSubscribe++
+	return nil
 }
 func (r *reconciliationMock) XeroRecordsRefresh(context.Context, domain.XeroClient, time.Time, time.Time, *regexp.Regexp, bool) (*domain.RefreshXeroResults, error) {
 	r.xeroRecordsRefresh++
-	return nil, nil
+	return &domain.RefreshXeroResults{}, nil
+}
+func (r *reconciliationMock) RecordRefresh(context.Context, domain.XeroClient, domain.SalesforceClient, string, string) error {
+	r.recordRefresh++
+	return nil
 }
 func (r *reconciliationMock) DBIsInMemory() bool {
 	r.dbIsInMemory++
@@ -83,6 +570,46 @@ func (r *reconciliationMock) DBPath() string {
 	r.dbPath++
 	return ""
 }
+func (r *reconciliationMock) SQLReload() error {
+	r.sqlReload++
+	return nil
+}
+func (r *reconciliationMock) SlowQueriesGet() ([]db.SlowQuery, time.Duration) {
+	r.slowQueriesGet++
+	return nil, 0
+}
+func (r *reconciliationMock) DataVersionGet() (string, time.Time) {
+	return "1.0", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+}
+
+func (r *reconciliationMock) CacheStatsGet() db.CacheStats {
+	return db.CacheStats{}
+}
+
+func (r *reconciliationMock) PoolStatsGet() db.PoolStats {
+	return db.PoolStats{Writes: 12, WriteWaits: 2}
+}
+func (r *reconciliationMock) SnapshotExport(_ context.Context, path string) error {
+	r.snapshotExport++
+	return os.WriteFile(path, []byte("SQLite format 3\x00"), 0o400)
+}
+func (r *reconciliationMock) SnapshotImport(context.Context, string) error {
+	r.snapshotImport++
+	return nil
+}
+func (r *reconciliationMock) LinkMappingsExport(_ context.Context, w io.Writer) error {
+	r.linkMappingsExport++
+	_, err := io.WriteString(w, `{"version":1,"links":[{"donation_id":"sf-opp-001","record_type":"invoice","record_id":"inv-001"}]}`)
+	return err
+}
+func (r *reconciliationMock) LinkMappingsImport(_ context.Context, rd io.Reader) (int, int, error) {
+	r.linkMappingsImport++
+	var doc domain.LinkMappings
+	if err := json.NewDecoder(rd).Decode(&doc); err != nil {
+		return 0, 0, domain.ErrUsage{Detail: err.Error(), Msg: "The file is not a valid link mappings document"}
+	}
+	return len(doc.Links) - 1, len(doc.Links), nil
+}
 func (r *reconciliationMock) Close() error {
 	r.closeCalled++
 	return nil
@@ -149,10 +676,20 @@ func TestWebAppAndShutdown(t *testing.T) {
 	webApp.RestartRoutes()
 	t.Log("routes restarted")
 
+	// SQL reloading only operates in development mode.
+	webApp.ReloadSQL()
+	if got := reconcilerMock.sqlReload; got != 0 {
+		t.Errorf("sql reload outside development mode called %d times", got)
+	}
+
 	webApp.SetInDevelopment()
 	if !webApp.inDevelopment {
 		t.Error("inDevelopment false after SetInDevelopment")
 	}
+	webApp.ReloadSQL()
+	if got, want := reconcilerMock.sqlReload, 1; got != want {
+		t.Errorf("sql reload got %d want %d", got, want)
+	}
 
 	go func() {
 		<-time.After(50 * time.Millisecond)
@@ -185,6 +722,7 @@ func TestServerHandlers(t *testing.T) {
 			},
 		},
 		Salesforce: config.SalesforceConfig{
+			Environment: config.SalesforceSandbox,
 			OAuth2Config: &oauth2.Config{
 				RedirectURL: "/sf/callback",
 				Endpoint: oauth2.Endpoint{
@@ -194,6 +732,14 @@ func TestServerHandlers(t *testing.T) {
 				Scopes: []string{"api", "refresh_token"},
 			},
 		},
+		GiftAid: config.GiftAidConfig{
+			AccountPrefixes: []string{"55"},
+			EligibleField:   "GiftAid",
+			FirstNameField:  "FirstName",
+			LastNameField:   "LastName",
+			HouseField:      "Street",
+			PostcodeField:   "Postcode",
+		},
 		DataStartDate:           time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
 		DonationAccountPrefixes: []string{"53", "55", "57"},
 	}
@@ -226,6 +772,11 @@ func TestServerHandlers(t *testing.T) {
 	defer ts.Close()
 
 	client := ts.Client()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Jar = jar
 
 	paths := []string{
 		"/connect",
@@ -233,10 +784,46 @@ func TestServerHandlers(t *testing.T) {
 		"/invoices",
 		"/bank-transactions",
 		"/donations",
+		"/invoices?status=All&date-from=2025-04-01&date-to=2026-03-31&sort=amount&dir=desc",
+		"/bank-transactions?status=All&date-from=2025-04-01&date-to=2026-03-31&sort=contact",
+		"/donations?status=All&date-from=2025-04-01&date-to=2026-03-31&sort=status&dir=asc",
+		"/invoices/results?status=All&date-from=2025-04-01&date-to=2026-03-31",
+		"/bank-transactions/results?status=All&date-from=2025-04-01&date-to=2026-03-31",
+		"/donations/results?status=All&date-from=2025-04-01&date-to=2026-03-31",
 		"/invoice/inv-001/link",
 		"/bank-transaction/bt-001/unlink",
+		"/contact/con-jg",
+		"/payout/bt-001",
+		"/import/donations",
+		"/imports",
+		"/imports/2",
+		"/suggestions",
+		"/suggestions/export",
+		"/reports",
+		"/reports/period",
+		"/reports/period?date-from=2025-04-01&date-to=2026-03-31",
+		"/reports/gift-aid",
+		"/reports/gift-aid/export",
+		"/reports/gift-aid/export?date-from=2025-04-01&date-to=2026-03-31&format=csv",
+		"/reports/aging",
+		"/search",
+		"/search?q=spring+campaign",
+		"/reports/aging/export",
+		"/reports/accounts",
+		"/reports/accounts/export",
+		"/reports/payouts",
+		"/reports/payouts?date-from=2025-04-01&date-to=2026-03-31&unmatched=true",
+		"/reports/statements",
+		"/reports/statements?date-from=2025-04-01&date-to=2026-03-31",
+		"/settings/salesforce/preview",
+		"/debug/queries",
+		"/snapshot/export",
+		"/settings/backups",
+		"/pending-actions",
+		"/pending-actions?all=true",
+		"/data-quality",
+		"/settings/reconciliation",
 		"/logout",
-		"/logout/confirmed",
 	}
 
 	for _, path := range paths {
@@ -258,8 +845,88 @@ func TestServerHandlers(t *testing.T) {
 			t.Errorf("%s got unexpected status code %d", path, resp.StatusCode)
 			fmt.Println(string(body))
 		}
+		if strings.HasPrefix(path, "/reports/period") && resp.Header.Get("Content-Type") != "application/pdf" {
+			t.Errorf("%s got content type %q want application/pdf", path, resp.Header.Get("Content-Type"))
+		}
+		if path == "/connect" && !strings.Contains(string(body), "Salesforce sandbox") {
+			t.Errorf("%s expected the salesforce environment to be shown", path)
+		}
+		if path == "/payout/bt-001" && !strings.Contains(string(body), "Link remaining candidates") {
+			t.Errorf("%s expected the candidates to be linkable", path)
+		}
+		if path == "/payout/bt-001" && !strings.Contains(string(body), `action="/payout/bt-001/combination"`) {
+			t.Errorf("%s expected the combinations to be linkable", path)
+		}
+		if strings.HasPrefix(path, "/reports/payouts") && !strings.Contains(string(body), "SF-ONLY-REF") {
+			t.Errorf("%s expected the payout reference to be listed", path)
+		}
+		if strings.HasPrefix(path, "/reports/statements") && !strings.Contains(string(body), "CHEQUE DEPOSIT") {
+			t.Errorf("%s expected the statement line to be listed", path)
+		}
+		if strings.HasPrefix(path, "/reports/statements") && !strings.Contains(string(body), `href="/bank-transaction/bt-002"`) {
+			t.Errorf("%s expected the matched bank transaction to be linked", path)
+		}
+		if path == "/snapshot/export" && resp.Header.Get("Content-Type") != "application/vnd.sqlite3" {
+			t.Errorf("%s got content type %q want application/vnd.sqlite3", path, resp.Header.Get("Content-Type"))
+		}
+		if path == "/debug/queries" && !strings.Contains(string(body), "Slow query recording is not enabled") {
+			t.Errorf("%s expected slow query recording to be reported as disabled", path)
+		}
+		if path == "/debug/queries" && !strings.Contains(string(body), "Writes which waited") {
+			t.Errorf("%s expected the connection statistics", path)
+		}
 	}
 
+	// The logout, which revokes the tokens, is only made by a POST with the CSRF token
+	// of the session.
+	logoutPost := func(form url.Values) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/logout/confirmed", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	resp, err := client.Get(ts.URL + "/logout/confirmed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("logout GET was served")
+	}
+	if got, want := logoutPost(url.Values{}), http.StatusForbidden; got != want {
+		t.Errorf("logout without the CSRF token got status %d want %d", got, want)
+	}
+	if reconcilerMock.closeCalled != 0 {
+		t.Fatal("database closed by a logout without the CSRF token")
+	}
+	resp, err = client.Get(ts.URL + "/logout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`name="csrf-token" value="([^"]+)"`).FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("logout page has no CSRF token field:\n%s", body)
+	}
+	if got, want := logoutPost(url.Values{"csrf-token": {string(match[1])}}), http.StatusOK; got != want {
+		t.Errorf("logout got status %d want %d", got, want)
+	}
+	if reconcilerMock.closeCalled == 0 {
+		t.Error("expected the database to be closed on logout")
+	}
 }
 
 // TestDonationSearchTimeSpan tests the dates around which a donation should be searched
@@ -377,6 +1044,58 @@ func TestServerComponents(t *testing.T) {
 			wantStatus: http.StatusBadRequest, // not sure if this is appropriate
 			wantBody:   "a token error",
 		},
+		{
+			name: "Domain not found error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return domain.ErrNotFound{Detail: "not found", Msg: "The requested invoice was not found"}
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "The requested invoice was not found",
+		},
+		{
+			name: "db not found error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("lookup: %w", db.ErrNotFound{Kind: "contact", ID: "con-x"})
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   "the requested contact was not found",
+		},
+		{
+			name: "db validation error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return db.ErrValidation{Field: "sort", Msg: "unknown column"}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "invalid sort: unknown column",
+		},
+		{
+			name: "remote api error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return domain.ErrSystem{
+					Detail: "xero update",
+					Err:    apistatus.ErrRemoteAPI{API: "xero", StatusCode: 400, Message: "invalid reference"},
+					Msg:    "The invoice reference could not be updated",
+				}
+			},
+			wantStatus: http.StatusBadGateway,
+			wantBody:   "The invoice reference could not be updated",
+		},
+		{
+			name: "conflict error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return domain.ErrConflict{Conflicts: []domain.Conflict{{RecordType: "invoice", ID: "inv-001", Name: "INV-001"}}}
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   "invoice INV-001",
+		},
+		{
+			name: "rate limited error",
+			testFunc: func(w http.ResponseWriter, r *http.Request) error {
+				return fmt.Errorf("sync: %w", apistatus.ErrRateLimited{API: "xero", RetryAfter: 30 * time.Second})
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantBody:   "please try again in 30s",
+		},
 		{
 			name: "render ok",
 			testFunc: func(w http.ResponseWriter, r *http.Request) error {
@@ -429,3 +1148,63 @@ func TestServerComponents(t *testing.T) {
 		})
 	}
 }
+
+// TestLogoutQueued tests that the logout is refused while Salesforce updates are queued,
+// unless their loss is confirmed, as they are lost with the database.
+func TestLogoutQueued(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{outboxActions: []int64{3, 5}}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	webApp.logoutDuration = time.Millisecond
+	exited := false
+	Exiter = func(int) { exited = true }
+	t.Cleanup(func() { Exiter = os.Exit })
+
+	ctx, err := webApp.sessions.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(h appHandler, method string, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequestWithContext(ctx, method, "/logout", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.ErrorChecker(h).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(webApp.handleLogoutConfirmed(), http.MethodPost, url.Values{})
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/logout"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+	if mock.closeCalled != 0 || exited {
+		t.Fatal("logout made with queued updates")
+	}
+
+	rec = serve(webApp.handleLogout(), http.MethodGet, nil)
+	for _, want := range []string{"2 pending actions have Salesforce updates which have not been sent", "Unsent Salesforce updates", `name="discard-queued"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("logout page does not contain %q", want)
+		}
+	}
+
+	serve(webApp.handleLogoutConfirmed(), http.MethodPost, url.Values{"discard-queued": {"true"}})
+	if mock.closeCalled != 1 || !exited {
+		t.Error("logout not made once the loss of the queued updates was confirmed")
+	}
+}
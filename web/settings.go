@@ -0,0 +1,49 @@
+package web
+
+// settings.go holds the settings which may be reloaded from the configuration file
+// while the web server is running, such as the data start date and the donation
+// account prefixes.
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+)
+
+// settings returns the settings last reloaded, or otherwise those of the
+// configuration. The settings should not be modified.
+func (web *WebApp) settings() *config.Settings {
+	if s := web.reloaded.Load(); s != nil {
+		return s
+	}
+	s := web.cfg.Settings()
+	return &s
+}
+
+// location returns the organisation timezone, which is not reloaded, or nil for UTC.
+func (web *WebApp) location() *time.Location {
+	if web.cfg == nil {
+		return nil
+	}
+	return web.cfg.Location
+}
+
+// today returns today's date in the organisation timezone as a UTC date.
+func (web *WebApp) today() time.Time {
+	return config.Date(time.Now(), web.location())
+}
+
+// donationAccountsRegexp returns the regular expression matching the donation account
+// codes, being those of the accounts selected on the accounts settings page or
+// otherwise the configured donation account prefixes.
+func (web *WebApp) donationAccountsRegexp() *regexp.Regexp {
+	return web.reconciler.DonationAccountsRegexp(web.settings().AccountsRegexp)
+}
+
+// SettingsChanged applies the reloaded settings to later requests, implementing
+// config.Subscriber.
+func (web *WebApp) SettingsChanged(s config.Settings) {
+	web.reloaded.Store(&s)
+	web.log.Info("web settings reloaded")
+}
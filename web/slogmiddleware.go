@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/rorycl/reconciler/internal/requestid"
 )
 
 // statusRecorder is a custom ResponseWriter that tracks the status code and bytes written.
@@ -53,6 +55,7 @@ func (web *WebApp) slogMiddleware(next http.Handler) http.Handler {
 			slog.Int("status", rec.status),
 			slog.Duration("duration", duration),
 			slog.String("ip", r.RemoteAddr),
+			slog.String("request_id", requestid.FromContext(r.Context())),
 		)
 	})
 }
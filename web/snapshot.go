@@ -0,0 +1,104 @@
+package web
+
+// snapshot.go provides the download of a database snapshot and the import of a
+// snapshot to replace the reconciler data, for example to restore the data after a
+// mistaken bulk operation.
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rorycl/reconciler/domain"
+)
+
+// snapshotMaxUploadSize is the maximum size of an uploaded snapshot file.
+const snapshotMaxUploadSize = 512 << 20
+
+// handleSnapshotExport downloads a snapshot of the database.
+func (web *WebApp) handleSnapshotExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		dir, err := os.MkdirTemp("", "reconciler-snapshot-")
+		if err != nil {
+			return errInternal{"failed to create snapshot directory", err}
+		}
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+
+		path := filepath.Join(dir, "snapshot.db")
+		if err := web.reconciler.SnapshotExport(r.Context(), path); err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return errInternal{"failed to open snapshot", err}
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		fileName := fmt.Sprintf("reconciler-snapshot-%s.db", time.Now().Format("20060102-150405"))
+		w.Header().Set("Content-Type", "application/vnd.sqlite3")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if _, err := io.Copy(w, file); err != nil {
+			web.log.Error(fmt.Sprintf("snapshot write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// handleSnapshotImport replaces the reconciler data with that of an uploaded
+// snapshot, reporting the outcome on the reports page.
+func (web *WebApp) handleSnapshotImport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/reports", http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, snapshotMaxUploadSize)
+		upload, _, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The snapshot file could not be read: %v", err))
+		}
+		defer func() {
+			_ = upload.Close()
+		}()
+
+		// The upload is saved to a file so that it can be attached by sqlite.
+		file, err := os.CreateTemp("", "reconciler-snapshot-*.db")
+		if err != nil {
+			return errInternal{"failed to create snapshot file", err}
+		}
+		defer func() {
+			_ = os.Remove(file.Name())
+		}()
+		_, err = io.Copy(file, upload)
+		if cErr := file.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			return redirect(fmt.Sprintf("The snapshot file could not be saved: %v", err))
+		}
+
+		if err := web.reconciler.SnapshotImport(ctx, file.Name()); err != nil {
+			if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+				return redirect(e.Msg)
+			}
+			return err
+		}
+		return redirect("The snapshot was imported.")
+	}
+}
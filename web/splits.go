@@ -0,0 +1,94 @@
+package web
+
+// splits.go allocates parts of a donation to invoices or bank transactions, so that a
+// donation may be split across several payouts.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// handleDonationSplitUpsert allocates the "amount" form value of the donation with
+// the "donation-id" form value to the invoice or bank transaction, redirecting to its
+// detail page.
+// The target is "/splits/{{ .Typer }}/{{ .ID }}".
+func (web *WebApp) handleDonationSplitUpsert() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, fmt.Sprintf("/%s/%s", typer, id), http.StatusSeeOther)
+			return nil
+		}
+
+		donationID := strings.TrimSpace(r.PostFormValue("donation-id"))
+		if donationID == "" {
+			return redirect("Please provide the Salesforce id of the donation to split.")
+		}
+		v := strings.TrimSpace(r.PostFormValue("amount"))
+		amount, err := money.Parse(v)
+		if err != nil || amount <= 0 {
+			return redirect(fmt.Sprintf("The split amount %q is not a valid amount.", v))
+		}
+
+		err = web.reconciler.DonationSplitUpsert(ctx, typer, id, donationID, amount)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+			return redirect(e.Msg + ".")
+		}
+		if err != nil {
+			return errInternal{"failed to split donation", err}
+		}
+		return redirect(fmt.Sprintf("£%s of donation %s was allocated.", amount, donationID))
+	}
+}
+
+// handleDonationSplitDelete removes a donation split from an invoice or bank
+// transaction, redirecting to its detail page.
+// The target is "/splits/{{ .Typer }}/{{ .ID }}/{{ .SplitID }}/delete".
+func (web *WebApp) handleDonationSplitDelete() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		vars, err := validMuxVars(mux.Vars(r), "type", "id", "split")
+		if err != nil {
+			return errUsage{err.Error(), http.StatusBadRequest}
+		}
+		typer, id := vars["type"], vars["id"]
+		splitID, err := strconv.ParseInt(vars["split"], 10, 64)
+		if err != nil {
+			return errUsage{fmt.Sprintf("invalid split id %q", vars["split"]), http.StatusBadRequest}
+		}
+
+		err = web.reconciler.DonationSplitDelete(ctx, typer, id, splitID)
+		if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+			web.sessions.Put(ctx, "message", e.Msg+".")
+			http.Redirect(w, r, fmt.Sprintf("/%s/%s", typer, id), http.StatusSeeOther)
+			return nil
+		}
+		if err != nil {
+			return errInternal{"failed to remove donation split", err}
+		}
+		web.sessions.Put(ctx, "message", "The donation split was removed.")
+		http.Redirect(w, r, fmt.Sprintf("/%s/%s", typer, id), http.StatusSeeOther)
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestDonationSplits tests allocating and removing donation splits.
+func TestDonationSplits(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{"type": "invoice", "id": "inv-001"}
+	tests := []struct {
+		form    url.Values
+		upserts int
+	}{
+		{url.Values{"donation-id": {"sf-opp-017"}, "amount": {"50"}}, 1},
+		{url.Values{"donation-id": {""}, "amount": {"50"}}, 1},
+		{url.Values{"donation-id": {"sf-opp-017"}, "amount": {"fifty"}}, 1},
+		{url.Values{"donation-id": {"sf-opp-017"}, "amount": {"-5"}}, 1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/splits/invoice/inv-001", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = mux.SetURLVars(req, vars)
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleDonationSplitUpsert())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%v: status got %d want %d", tt.form, got, want)
+		}
+		if got, want := rec.Header().Get("Location"), "/invoice/inv-001"; got != want {
+			t.Errorf("%v: location got %q want %q", tt.form, got, want)
+		}
+		if got, want := reconcilerMock.donationSplitUpsert, tt.upserts; got != want {
+			t.Errorf("%v: upserts got %d want %d", tt.form, got, want)
+		}
+	}
+
+	vars["split"] = "1"
+	req := httptest.NewRequest("POST", "/splits/invoice/inv-001/1/delete", nil)
+	req = mux.SetURLVars(req, vars)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleDonationSplitDelete())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Errorf("delete status got %d want %d", got, want)
+	}
+	if got, want := reconcilerMock.donationSplitDelete, 1; got != want {
+		t.Errorf("deletes got %d want %d", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package web
+
+// statements.go serves the bank statement report, which matches the money received on
+// imported bank statements to the Xero bank transactions and compares their donations
+// with those recorded in Salesforce, so that money which never reached Xero, or reached
+// it without its donations, can be found. Bank statements exported as CSV by online
+// banking are staged for review before their lines are recorded.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/statementcsv"
+)
+
+// statementMaxUploadSize is the maximum size of an uploaded bank statement.
+const statementMaxUploadSize = 10 << 20
+
+// handleStatements serves the /reports/statements page, by default showing only the
+// statement lines which are not matched.
+func (web *WebApp) handleStatements() appHandler {
+
+	name := "reports-statements.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"reports-statements.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		form := NewPayoutGroupsForm(web.settings().DataStartDate, web.today())
+		if err := form.DecodeURLParams(r.URL.Query()); err != nil {
+			return errUsage{fmt.Sprintf("invalid report parameters: %v", err), http.StatusBadRequest}
+		}
+		validator := NewValidator()
+		form.Validate(validator)
+		if !validator.Valid() {
+			var msgs []string
+			for _, m := range validator.Errors {
+				msgs = append(msgs, m)
+			}
+			return errUsage{strings.Join(msgs, " "), http.StatusBadRequest}
+		}
+
+		report, err := web.reconciler.StatementReportGet(r.Context(), form.DateFrom, form.DateTo, form.Unmatched)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Bank Statements",
+			"CurrentPage": "reports",
+			"Form":        form,
+			"Report":      report,
+			"Message":     web.sessions.PopString(r.Context(), "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleStatementImport serves the /reports/statements/import endpoint, which stages
+// the lines of an uploaded bank statement of the posted "bank-account" for review
+// before they are recorded.
+func (web *WebApp) handleStatementImport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/reports/statements", http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, statementMaxUploadSize)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The bank statement could not be read: %v", err))
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		lines, err := statementcsv.Read(file)
+		if err != nil {
+			return redirect(fmt.Sprintf("The bank statement is invalid: %v", err))
+		}
+
+		id, err := web.reconciler.StatementLinesStage(ctx, r.FormValue("bank-account"), header.Filename, lines)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if err != nil {
+			return err
+		}
+		web.sessions.Put(ctx, "message", "The bank statement was staged; review its lines and commit them to record them.")
+		http.Redirect(w, r, fmt.Sprintf("/imports/%d", id), http.StatusSeeOther)
+		return nil
+	}
+}
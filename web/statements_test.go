@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestStatementImport tests uploading bank statements, which are only staged for
+// review if they can be read.
+func TestStatementImport(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:          config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce:    config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+		DataStartDate: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		csv      string
+		staged   int
+		location string
+	}{
+		{
+			name:     "bank statement",
+			csv:      "Date,Description,Amount\n15/04/2025,JUSTGIVING,337.25\n",
+			staged:   1,
+			location: "/imports/3",
+		},
+		{
+			name:     "unknown statement",
+			csv:      "Donation Ref,Gross\nJG-1001,20.00\n",
+			staged:   0,
+			location: "/reports/statements",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.statementLinesStage = 0
+
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			_ = mw.WriteField("bank-account", "Current Account")
+			fw, err := mw.CreateFormFile("file", "statement.csv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = fw.Write([]byte(tt.csv))
+			_ = mw.Close()
+
+			req := httptest.NewRequest("POST", "/reports/statements/import", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleStatementImport())).ServeHTTP(rec, req)
+
+			if got, want := rec.Code, http.StatusSeeOther; got != want {
+				t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+			}
+			if got, want := rec.Header().Get("Location"), tt.location; got != want {
+				t.Errorf("location got %q want %q", got, want)
+			}
+			if got, want := mock.statementLinesStage, tt.staged; got != want {
+				t.Errorf("got %d staged imports want %d", got, want)
+			}
+		})
+	}
+}
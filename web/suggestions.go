@@ -0,0 +1,265 @@
+package web
+
+// suggestions.go provides the link suggestion page together with the export of
+// suggestions to CSV for offline review and the re-import of the reviewed decisions.
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rorycl/reconciler/apiclients/salesforce"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/csvsafe"
+	"github.com/rorycl/reconciler/internal/token"
+)
+
+// suggestionsMinScore is the minimum score of a link suggestion to show or export.
+const suggestionsMinScore = 0.5
+
+// suggestionsMaxUploadSize is the maximum size of an uploaded suggestions file.
+const suggestionsMaxUploadSize = 10 << 20
+
+// suggestionsCSVHeader is the header of the suggestions CSV export. The decision column
+// is left empty for completion by the reviewer.
+var suggestionsCSVHeader = []string{
+	"type",
+	"record_id",
+	"record_reference",
+	"record_date",
+	"record_contact",
+	"outstanding",
+	"donation_id",
+	"donation_name",
+	"donation_amount",
+	"donation_close_date",
+	"score",
+	"decision",
+}
+
+// handleSuggestions serves the /suggestions page.
+func (web *WebApp) handleSuggestions() appHandler {
+
+	name := "suggestions.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"suggestions.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		suggestions, err := web.reconciler.LinkSuggestionsGet(ctx, web.settings().DataStartDate, web.today(), suggestionsMinScore)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		data := map[string]any{
+			"PageTitle":   "Link Suggestions",
+			"CurrentPage": "suggestions",
+			"Suggestions": suggestions,
+			"MinScore":    suggestionsMinScore,
+			"Message":     web.sessions.PopString(ctx, "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleSuggestionsExport serves the /suggestions/export CSV download.
+func (web *WebApp) handleSuggestionsExport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		suggestions, err := web.reconciler.LinkSuggestionsGet(r.Context(), web.settings().DataStartDate, web.today(), suggestionsMinScore)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		fileName := fmt.Sprintf("link-suggestions-%s.csv", time.Now().Format("20060102-1504"))
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		if err := writeSuggestionsCSV(w, suggestions); err != nil {
+			web.log.Error(fmt.Sprintf("suggestions csv write error: %v", err))
+		}
+		return nil
+	}
+}
+
+// handleSuggestionsImport serves the /suggestions/import endpoint, which applies the
+// decisions in an uploaded reviewed suggestions CSV file.
+func (web *WebApp) handleSuggestionsImport() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/suggestions", http.StatusSeeOther)
+			return nil
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, suggestionsMaxUploadSize)
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return redirect(fmt.Sprintf("The suggestions file could not be read: %v", err))
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+
+		decisions, err := readSuggestionDecisions(file)
+		if err != nil {
+			return redirect(fmt.Sprintf("The suggestions file is invalid: %v", err))
+		}
+		if len(decisions) == 0 {
+			return redirect("The suggestions file contained no decisions.")
+		}
+
+		sfToken, err := web.getValidTokenFromSession(ctx, token.SalesforceToken)
+		if err != nil {
+			http.Redirect(w, r, "/connect", http.StatusFound)
+			return nil
+		}
+		sfClient, err := web.newSFClient(ctx, web.cfg, web.log, sfToken)
+		if err != nil {
+			return errInternal{"failed to create salesforce client for suggestions import", err}
+		}
+		sfLastRefresh := web.sessions.GetTime(ctx, "sf-refreshed-datetime")
+
+		results, err := web.reconciler.LinkSuggestionDecisionsApply(
+			ctx,
+			sfClient,
+			decisions,
+			web.settings().DataStartDate,
+			sfLastRefresh.Add(refreshDurationWindow),
+		)
+		if err != nil {
+			if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+				return redirect(e.Msg)
+			}
+			if e, ok := errors.AsType[domain.ErrLocked](err); ok {
+				return redirect(e.Msg + ".")
+			}
+			return err
+		}
+		return redirect(fmt.Sprintf("%d suggestions were linked and %d rejected.", results.Linked, results.Rejected))
+	}
+}
+
+// writeSuggestionsCSV writes the link suggestions to w in CSV format.
+func writeSuggestionsCSV(w io.Writer, suggestions []db.LinkSuggestion) error {
+	cw := csvsafe.NewWriter(w)
+	if err := cw.Write(suggestionsCSVHeader); err != nil {
+		return err
+	}
+	for _, s := range suggestions {
+		var contact, closeDate string
+		if s.RecordContact != nil {
+			contact = *s.RecordContact
+		}
+		if s.DonationCloseDate != nil {
+			closeDate = s.DonationCloseDate.Format("2006-01-02")
+		}
+		err := cw.Write([]string{
+			s.Typer,
+			s.RecordID,
+			s.RecordRef,
+			s.RecordDate.Format("2006-01-02"),
+			contact,
+			s.Outstanding.String(),
+			s.DonationID,
+			s.DonationName,
+			s.DonationAmount.String(),
+			closeDate,
+			strconv.FormatFloat(s.Score, 'f', 2, 64),
+			"",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readSuggestionDecisions reads a reviewed suggestions CSV file. Only the type,
+// record_id, donation_id and decision columns are required. Rows with an empty
+// decision are skipped. Accepted decisions are "accept", "accepted", "yes" or "y" and
+// rejected decisions "reject", "rejected", "no" or "n", in any case.
+func readSuggestionDecisions(r io.Reader) ([]domain.SuggestionDecision, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+	cols := map[string]int{}
+	for _, c := range []string{"type", "record_id", "donation_id", "decision"} {
+		idx := slices.IndexFunc(header, func(h string) bool {
+			return strings.EqualFold(strings.TrimSpace(h), c)
+		})
+		if idx < 0 {
+			return nil, fmt.Errorf("column %q not found", c)
+		}
+		cols[c] = idx
+	}
+	maxCol := slices.Max([]int{cols["type"], cols["record_id"], cols["donation_id"], cols["decision"]})
+
+	decisions := []domain.SuggestionDecision{}
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		if len(record) <= maxCol {
+			return nil, fmt.Errorf("row %d: too few columns", row)
+		}
+
+		var accept bool
+		switch strings.ToLower(strings.TrimSpace(record[cols["decision"]])) {
+		case "":
+			continue
+		case "accept", "accepted", "yes", "y":
+			accept = true
+		case "reject", "rejected", "no", "n":
+			accept = false
+		default:
+			return nil, fmt.Errorf("row %d: unknown decision %q", row, record[cols["decision"]])
+		}
+
+		d := domain.SuggestionDecision{
+			Typer:      strings.TrimSpace(record[cols["type"]]),
+			RecordID:   strings.TrimSpace(record[cols["record_id"]]),
+			DonationID: strings.TrimSpace(record[cols["donation_id"]]),
+			Accept:     accept,
+		}
+		if d.Typer != "invoice" && d.Typer != "bank-transaction" {
+			return nil, fmt.Errorf("row %d: invalid type %q", row, d.Typer)
+		}
+		if d.RecordID == "" {
+			return nil, fmt.Errorf("row %d: empty record_id", row)
+		}
+		if err := salesforce.IDsValid(d.DonationID); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, nil
+}
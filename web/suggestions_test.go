@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+func TestSuggestionsCSVRoundTrip(t *testing.T) {
+
+	contact := "Small Pledge"
+	closeDate := time.Date(2025, 4, 17, 0, 0, 0, 0, time.UTC)
+	suggestions := []db.LinkSuggestion{
+		{
+			Typer:             "invoice",
+			RecordID:          "inv-unrec-04",
+			RecordRef:         "INV-2025-106",
+			RecordDate:        time.Date(2025, 4, 25, 13, 0, 0, 0, time.UTC),
+			RecordContact:     &contact,
+			Outstanding:       money.FromFloat(50),
+			DonationID:        "0015A00002CrA9PQAV",
+			DonationName:      "Online Donation, 3",
+			DonationAmount:    money.FromFloat(50),
+			DonationCloseDate: &closeDate,
+			Score:             0.92,
+		},
+		{
+			Typer:          "bank-transaction",
+			RecordID:       "bt-unrec-04",
+			RecordRef:      "JG-PAYOUT-2025-04-29",
+			RecordDate:     time.Date(2025, 4, 29, 14, 0, 0, 0, time.UTC),
+			Outstanding:    money.FromFloat(150),
+			DonationID:     "0055A000006vN9PQAU",
+			DonationName:   "=Online Donation 2", // neutralised as a formula
+			DonationAmount: money.FromFloat(150),
+			Score:          0.88,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeSuggestionsCSV(&buf, suggestions); err != nil {
+		t.Fatal(err)
+	}
+	want := `type,record_id,record_reference,record_date,record_contact,outstanding,donation_id,donation_name,donation_amount,donation_close_date,score,decision
+invoice,inv-unrec-04,INV-2025-106,2025-04-25,Small Pledge,50.00,0015A00002CrA9PQAV,"Online Donation, 3",50.00,2025-04-17,0.92,
+bank-transaction,bt-unrec-04,JG-PAYOUT-2025-04-29,2025-04-29,,150.00,0055A000006vN9PQAU,'=Online Donation 2,150.00,,0.88,
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Fatalf("csv export mismatch (-want +got):\n%s", diff)
+	}
+
+	// An export without decisions has no decisions.
+	decisions, err := readSuggestionDecisions(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(decisions), 0; got != want {
+		t.Errorf("got %d decisions want %d", got, want)
+	}
+
+	// Complete the decision column.
+	reviewed := strings.Replace(buf.String(), "0.92,\n", "0.92,Accepted\n", 1)
+	reviewed = strings.Replace(reviewed, "0.88,\n", "0.88, no\n", 1)
+	decisions, err = readSuggestionDecisions(strings.NewReader(reviewed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDecisions := []domain.SuggestionDecision{
+		{Typer: "invoice", RecordID: "inv-unrec-04", DonationID: "0015A00002CrA9PQAV", Accept: true},
+		{Typer: "bank-transaction", RecordID: "bt-unrec-04", DonationID: "0055A000006vN9PQAU", Accept: false},
+	}
+	if diff := cmp.Diff(wantDecisions, decisions); diff != "" {
+		t.Errorf("decisions mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestReadSuggestionDecisionsErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		csv  string
+		err  string
+	}{
+		{"empty", "", "could not read header"},
+		{"missing column", "type,record_id,donation_id\n", `column "decision" not found`},
+		{"bad decision", "type,record_id,donation_id,decision\ninvoice,inv-1,0015A00002CrA9PQAV,maybe\n", `row 2: unknown decision "maybe"`},
+		{"bad type", "type,record_id,donation_id,decision\ncontact,inv-1,0015A00002CrA9PQAV,y\n", `row 2: invalid type "contact"`},
+		{"bad donation id", "type,record_id,donation_id,decision\ninvoice,inv-1,sf-opp-1,y\n", "row 2:"},
+		{"short row", "type,record_id,donation_id,decision\ninvoice,inv-1\n", "row 2: too few columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readSuggestionDecisions(strings.NewReader(tt.csv))
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %q want %q", err, tt.err)
+			}
+		})
+	}
+}
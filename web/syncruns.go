@@ -0,0 +1,35 @@
+package web
+
+// syncruns.go shows the history of the refreshes of the Xero and Salesforce records,
+// with the numbers of records fetched, inserted, updated, skipped and failed, so that
+// problems such as a refresh retrieving no records are noticeable.
+
+import (
+	"net/http"
+)
+
+// handleSyncRuns shows the most recent sync runs.
+func (web *WebApp) handleSyncRuns() appHandler {
+
+	name := "sync.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"sync.html",
+		"partial-sync-entity.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		runs, err := web.reconciler.SyncRunsGet(r.Context())
+		if err != nil {
+			return errInternal{"failed to retrieve sync runs", err}
+		}
+		data := map[string]any{
+			"PageTitle":   "Sync History",
+			"CurrentPage": "sync",
+			"Runs":        runs,
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
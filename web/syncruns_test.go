@@ -0,0 +1,50 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestSyncRuns tests the sync history page, which highlights a type of record of which
+// none were fetched.
+func TestSyncRuns(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mock := &reconciliationMock{}
+	webApp, err := New(cfg, mock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/sync", nil)
+	rec := httptest.NewRecorder()
+	webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleSyncRuns())).ServeHTTP(rec, req)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("status got %d want %d\n%s", got, want, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"salesforce", ">2s<", `title="No records were fetched">donation<`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("sync history page does not contain %q", want)
+		}
+	}
+	if got, want := mock.syncRunsGet, 1; got != want {
+		t.Errorf("got %d sync runs gets want %d", got, want)
+	}
+}
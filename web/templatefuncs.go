@@ -0,0 +1,129 @@
+package web
+
+// templatefuncs.go is the library of funcs shared by the web templates. Funcs which
+// depend on the request, such as the csrf token or the locale, are registered when the
+// templates are parsed and bound to each request by render.
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/i18n"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// baseCurrency is the ISO 4217 code of the organisation's currency.
+const baseCurrency = "GBP"
+
+// newTemplateFuncs returns the template funcs registered with the templates when they
+// are parsed. The request funcs are bound to the background context as placeholders,
+// since they are only called once rebound by render.
+func (web *WebApp) newTemplateFuncs() template.FuncMap {
+	funcs := web.requestTemplateFuncs(context.Background())
+	maps.Copy(funcs, web.sfEnvironmentTemplateFuncs())
+	funcs["locales"] = i18n.Locales
+	funcs["asset"] = web.assetURL
+	return funcs
+}
+
+// requestTemplateFuncs returns the template funcs bound to the request context.
+func (web *WebApp) requestTemplateFuncs(ctx context.Context) template.FuncMap {
+	funcs := template.FuncMap{
+		"cspNonce": func() string { return cspNonce(ctx) },
+	}
+	maps.Copy(funcs, web.csrfTemplateFuncs(ctx))
+	maps.Copy(funcs, web.xeroTemplateFuncs(ctx))
+	maps.Copy(funcs, web.sfTemplateFuncs(ctx))
+	maps.Copy(funcs, web.themeTemplateFuncs(ctx))
+	maps.Copy(funcs, web.userTemplateFuncs(ctx))
+	maps.Copy(funcs, formatTemplateFuncs(sync.OnceValue(func() *i18n.Locale {
+		return web.locale(ctx)
+	}), time.Now, web.location()))
+	return funcs
+}
+
+// formatTemplateFuncs returns the funcs for translating messages and for formatting
+// money, dates and durations in the locale returned by l, which is only looked up when
+// first used.
+//
+// formatMoney formats a money.Amount, *money.Amount or float64 in the base currency, or
+// in the currency with the ISO 4217 code given as a second argument. formatDate,
+// formatDateTime and formatLongDate format a time.Time or *time.Time, with nil or zero
+// times formatted as empty strings. Dates, being UTC dates, are formatted as they are,
+// and times in the organisation timezone loc, if set. humanizeDuration formats a
+// time.Time relative to now, such as "3 days ago", or a time.Duration, such as "3
+// days".
+func formatTemplateFuncs(l func() *i18n.Locale, now func() time.Time, loc *time.Location) template.FuncMap {
+	date := func(format func(*i18n.Locale, time.Time) string, inLocation bool) func(any) (string, error) {
+		return func(v any) (string, error) {
+			t, err := templateTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+			if inLocation && loc != nil {
+				t = t.In(loc)
+			}
+			return format(l(), t), nil
+		}
+	}
+	return template.FuncMap{
+		"locale": func() string { return l().Tag },
+		"t": func(key string, args ...any) string {
+			return l().T(key, args...)
+		},
+		"formatDate":     date((*i18n.Locale).FormatDate, false),
+		"formatDateTime": date((*i18n.Locale).FormatDateTime, true),
+		"formatLongDate": date((*i18n.Locale).FormatLongDate, false),
+		"formatMoney": func(v any, currency ...string) (string, error) {
+			code := baseCurrency
+			if len(currency) > 0 && currency[0] != "" {
+				code = currency[0]
+			}
+			switch a := v.(type) {
+			case money.Amount:
+				return l().FormatCurrency(a, code), nil
+			case *money.Amount:
+				if a == nil {
+					return "", nil
+				}
+				return l().FormatCurrency(*a, code), nil
+			case float64:
+				m, err := money.ParseFloat(a)
+				if err != nil {
+					return "", err
+				}
+				return l().FormatCurrency(m, code), nil
+			}
+			return "", fmt.Errorf("cannot format %T as money", v)
+		},
+		"humanizeDuration": func(v any) (string, error) {
+			if d, ok := v.(time.Duration); ok {
+				return l().FormatDuration(d), nil
+			}
+			t, err := templateTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+			return l().FormatAgo(t, now()), nil
+		},
+	}
+}
+
+// templateTime returns the time of a time.Time or *time.Time template argument, with a
+// nil time returned as the zero time.
+func templateTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, nil
+		}
+		return *t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot format %T as a time", v)
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/internal/i18n"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// TestFormatTemplateFuncs tests the money, date and duration template funcs, with
+// times shown in the New York timezone.
+func TestFormatTemplateFuncs(t *testing.T) {
+
+	now := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	amount := money.FromFloat(-1234.5)
+	date := time.Date(2025, 6, 7, 9, 30, 0, 0, time.UTC)
+	day := time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var noAmount *money.Amount
+	var noDate *time.Time
+
+	tests := []struct {
+		name   string
+		locale string
+		tpl    string
+		data   any
+		want   string
+		isErr  bool
+	}{
+		{"money", "en-GB", `{{ formatMoney . }}`, money.FromFloat(1234567.8), "£1,234,567.80", false},
+		{"money pointer", "en-GB", `{{ formatMoney . }}`, &amount, "-£1,234.50", false},
+		{"money nil", "en-GB", `{{ formatMoney . }}`, noAmount, "", false},
+		{"money float", "en-GB", `{{ formatMoney . }}`, 12.5, "£12.50", false},
+		{"money currency", "en-GB", `{{ formatMoney . "USD" }}`, 12.5, "$12.50", false},
+		{"money currency code", "en-GB", `{{ formatMoney . "NZD" }}`, 12.5, "NZD 12.50", false},
+		{"money fr", "fr-FR", `{{ formatMoney . }}`, money.FromFloat(1234.5), "1 234,50 £", false},
+		{"money invalid", "en-GB", `{{ formatMoney . }}`, "12.50", "", true},
+		{"date", "en-GB", `{{ formatDate . }}`, date, "07/06/2025", false},
+		{"date nil", "en-GB", `{{ formatDate . }}`, noDate, "", false},
+		{"date utc", "en-GB", `{{ formatDate . }}`, day, "07/06/2025", false},
+		{"date time", "en-GB", `{{ formatDateTime . }}`, &date, "07/06/2025 05:30:00", false},
+		{"long date fr", "fr-FR", `{{ formatLongDate . }}`, date, "07 juin 2025", false},
+		{"date invalid", "en-GB", `{{ formatDate . }}`, "2025-06-07", "", true},
+		{"ago", "en-GB", `{{ humanizeDuration . }}`, date, "3 days ago", false},
+		{"ago fr", "fr-FR", `{{ humanizeDuration . }}`, date, "il y a 3 jours", false},
+		{"ago zero", "en-GB", `{{ humanizeDuration . }}`, time.Time{}, "", false},
+		{"duration", "en-GB", `{{ humanizeDuration . }}`, 90 * time.Minute, "1 hour", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			funcs := formatTemplateFuncs(
+				func() *i18n.Locale { return i18n.Get(tt.locale) },
+				func() time.Time { return now },
+				newYork,
+			)
+			tpl := template.Must(template.New("").Funcs(funcs).Parse(tt.tpl))
+			var buf bytes.Buffer
+			err := tpl.Execute(&buf, tt.data)
+			if got, want := err != nil, tt.isErr; got != want {
+				t.Fatalf("error got %v want error %t", err, want)
+			}
+			if got := buf.String(); err == nil && got != tt.want {
+				t.Errorf("got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package web
+
+// theme.go provides the light and dark themes. The theme is the one toggled for the
+// session, or otherwise the configured default, and is set as the data-theme attribute
+// of each page for the theme stylesheet.
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/rorycl/reconciler/config"
+)
+
+// themeSessionKey is the session key of the theme toggled by the user.
+const themeSessionKey = "theme"
+
+// theme returns the theme of the session, or the configured theme.
+func (web *WebApp) theme(ctx context.Context) string {
+	switch theme := web.sessions.GetString(ctx, themeSessionKey); theme {
+	case config.ThemeLight, config.ThemeDark:
+		return theme
+	}
+	if web.cfg.Web.Theme == config.ThemeDark {
+		return config.ThemeDark
+	}
+	return config.ThemeLight
+}
+
+// themeTemplateFuncs returns the template func reporting the theme of the request.
+func (web *WebApp) themeTemplateFuncs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"theme": func() string { return web.theme(ctx) },
+	}
+}
+
+// handleThemeToggle switches the theme of the session between light and dark,
+// returning to the referring page of this site or otherwise to the home page.
+// The target is "/theme".
+func (web *WebApp) handleThemeToggle() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		theme := config.ThemeDark
+		if web.theme(ctx) == config.ThemeDark {
+			theme = config.ThemeLight
+		}
+		web.sessions.Put(ctx, themeSessionKey, theme)
+		http.Redirect(w, r, refererTarget(r, "/home"), http.StatusSeeOther)
+		return nil
+	}
+}
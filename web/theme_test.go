@@ -0,0 +1,78 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestThemeToggle tests toggling the theme of the session from the configured theme.
+func TestThemeToggle(t *testing.T) {
+
+	cfg := &config.Config{
+		Web:        config.WebConfig{Theme: config.ThemeDark},
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cookies []*http.Cookie
+	theme := func() string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/invoices", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		var got string
+		webApp.sessions.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = webApp.theme(r.Context())
+		})).ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+	toggle := func() *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/theme", nil)
+		req.Header.Set("Referer", "http://example.com/invoices?page=2")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleThemeToggle())).ServeHTTP(rec, req)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		return rec
+	}
+
+	if got, want := theme(), config.ThemeDark; got != want {
+		t.Errorf("configured theme got %q want %q", got, want)
+	}
+	rec := toggle()
+	if got, want := rec.Code, http.StatusSeeOther; got != want {
+		t.Fatalf("status got %d want %d", got, want)
+	}
+	if got, want := rec.Header().Get("Location"), "/invoices?page=2"; got != want {
+		t.Errorf("location got %q want %q", got, want)
+	}
+	if got, want := theme(), config.ThemeLight; got != want {
+		t.Errorf("toggled theme got %q want %q", got, want)
+	}
+	toggle()
+	if got, want := theme(), config.ThemeDark; got != want {
+		t.Errorf("toggled back theme got %q want %q", got, want)
+	}
+}
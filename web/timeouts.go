@@ -0,0 +1,24 @@
+package web
+
+// timeouts.go limits the time taken to serve each request. The request context is
+// passed through the handlers to the database queries and the Xero and Salesforce api
+// calls, which stop when the request times out or the browser disconnects.
+
+import (
+	"context"
+	"net/http"
+)
+
+// withRequestTimeout cancels the context of each request after the configured request
+// timeout.
+func (web *WebApp) withRequestTimeout(next http.Handler) http.Handler {
+	timeout := web.cfg.Web.RequestTimeout
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestRequestTimeout tests that handlers are cancelled after the request timeout, and
+// that cancelled requests are reported as timeouts.
+func TestRequestTimeout(t *testing.T) {
+
+	cfg := &config.Config{
+		Web:        config.WebConfig{RequestTimeout: 20 * time.Millisecond},
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, &reconciliationMock{}, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// slow waits for the request to be cancelled, as a long query would.
+	slow := func(w http.ResponseWriter, r *http.Request) error {
+		select {
+		case <-r.Context().Done():
+			return r.Context().Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+	handler := webApp.withRequestTimeout(webApp.ErrorChecker(slow))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if got, want := rec.Code, http.StatusGatewayTimeout; got != want {
+		t.Errorf("status got %d want %d", got, want)
+	}
+
+	// A request from a disconnected browser is not answered.
+	req := httptest.NewRequest("GET", "/slow", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Body.Len() != 0 {
+		t.Errorf("unexpected response to a cancelled request %q", rec.Body.String())
+	}
+}
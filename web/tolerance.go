@@ -0,0 +1,83 @@
+package web
+
+// tolerance.go shows and sets the reconciliation tolerance, the difference allowed
+// between the donation total of an invoice or bank transaction and its linked
+// donations for the record to be reconciled.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rorycl/reconciler/db"
+	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/money"
+)
+
+// handleTolerance shows the reconciliation tolerance settings form.
+func (web *WebApp) handleTolerance() appHandler {
+
+	name := "settings-reconciliation.html"
+	tpls := []string{
+		"base.html",
+		"nav.html",
+		"settings-reconciliation.html",
+	}
+	templates := web.parseTemplates(tpls...)
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		tolerance, err := web.reconciler.ToleranceGet(r.Context())
+		if err != nil {
+			return errInternal{"failed to get tolerance", err}
+		}
+		data := map[string]any{
+			"PageTitle":   "Reconciliation Tolerance",
+			"CurrentPage": "settings-reconciliation",
+			"Tolerance":   tolerance,
+			"Message":     web.sessions.PopString(r.Context(), "message"),
+		}
+		return web.render(w, r, templates, name, data)
+	}
+}
+
+// handleToleranceUpdate sets the reconciliation tolerance from the "amount" and
+// "percent" form values. Empty values are zero.
+func (web *WebApp) handleToleranceUpdate() appHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+
+		ctx := r.Context()
+		redirect := func(msg string) error {
+			web.sessions.Put(ctx, "message", msg)
+			http.Redirect(w, r, "/settings/reconciliation", http.StatusSeeOther)
+			return nil
+		}
+
+		var tolerance db.Tolerance
+		if v := strings.TrimSpace(r.PostFormValue("amount")); v != "" {
+			amount, err := money.Parse(v)
+			if err != nil {
+				return redirect(fmt.Sprintf("The tolerance amount %q is not a valid amount.", v))
+			}
+			tolerance.Amount = amount
+		}
+		if v := strings.TrimSpace(r.PostFormValue("percent")); v != "" {
+			percent, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return redirect(fmt.Sprintf("The tolerance percentage %q is not a valid number.", v))
+			}
+			tolerance.Percent = percent
+		}
+
+		err := web.reconciler.ToleranceUpsert(ctx, tolerance)
+		if e, ok := errors.AsType[domain.ErrUsage](err); ok {
+			return redirect(e.Msg)
+		}
+		if err != nil {
+			return errInternal{"failed to set tolerance", err}
+		}
+		return redirect("The reconciliation tolerance was updated.")
+	}
+}
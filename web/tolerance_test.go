@@ -0,0 +1,56 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rorycl/reconciler/config"
+	mounts "github.com/rorycl/reconciler/internal/mounts"
+	"golang.org/x/oauth2"
+)
+
+// TestToleranceUpdate tests setting the reconciliation tolerance.
+func TestToleranceUpdate(t *testing.T) {
+
+	cfg := &config.Config{
+		Xero:       config.XeroConfig{OAuth2Config: &oauth2.Config{}},
+		Salesforce: config.SalesforceConfig{OAuth2Config: &oauth2.Config{}},
+	}
+	templatesFS, err := mounts.NewFileMount("templates", TemplatesEmbeddedFS, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconcilerMock := &reconciliationMock{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	webApp, err := New(cfg, reconcilerMock, logger, nil, templatesFS, NewMockXeroClient, NewMockSFClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		form    url.Values
+		upserts int
+	}{
+		{url.Values{"amount": {"0.50"}, "percent": {"2.5"}}, 1},
+		{url.Values{"amount": {""}, "percent": {""}}, 2},
+		{url.Values{"amount": {"fifty pence"}}, 2},
+		{url.Values{"percent": {"1%"}}, 2},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/settings/reconciliation", strings.NewReader(tt.form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		webApp.sessions.LoadAndSave(webApp.ErrorChecker(webApp.handleToleranceUpdate())).ServeHTTP(rec, req)
+		if got, want := rec.Code, http.StatusSeeOther; got != want {
+			t.Errorf("%v: status got %d want %d", tt.form, got, want)
+		}
+		if got, want := reconcilerMock.toleranceUpsert, tt.upserts; got != want {
+			t.Errorf("%v: upserts got %d want %d", tt.form, got, want)
+		}
+	}
+}
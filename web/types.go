@@ -6,6 +6,7 @@ package web
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"regexp"
@@ -16,6 +17,11 @@ import (
 	"github.com/rorycl/reconciler/config"
 	"github.com/rorycl/reconciler/db"
 	"github.com/rorycl/reconciler/domain"
+	"github.com/rorycl/reconciler/internal/apistatus"
+	"github.com/rorycl/reconciler/internal/donorimport"
+	"github.com/rorycl/reconciler/internal/money"
+	"github.com/rorycl/reconciler/internal/payoutcsv"
+	"github.com/rorycl/reconciler/internal/statementcsv"
 	"github.com/rorycl/reconciler/internal/token"
 )
 
@@ -35,6 +41,12 @@ func newDefaultSalesforceClient(ctx context.Context, cfg *config.Config, logger
 // sfClientMaker is the signature of newSalesforceClient.
 type sfClientMaker func(ctx context.Context, cfg *config.Config, logger *slog.Logger, et *token.ExtendedToken) (domain.SalesforceClient, error)
 
+// connectionStatuser is an optional capability of a domain.XeroClient or
+// domain.SalesforceClient to report the status of its api connection.
+type connectionStatuser interface {
+	ConnectionStatus() apistatus.Status
+}
+
 // appHandler is a type of handler that returns an error. All normal web handlers are
 // appHandlers and are wrapped by ErrorChecker which centralises error reporting.
 type appHandler func(http.ResponseWriter, *http.Request) error
@@ -73,24 +85,131 @@ func (eh errHTMX) Error() string {
 }
 
 // reconcilerer is the snappy name of an interface matching the main methods of
-// domain.Reconciler.
+// domain.Reconciler. The WebApp depends on the database only through it, so that the
+// handlers may be tested with a fake, such as the reconciliationMock of the tests,
+// without a database. Only the tests of flows through the domain, such as refreshes
+// and link actions, use an in-memory database with a domain.Reconciler.
 type reconcilerer interface {
 	// Donations.
-	DonationsGet(context.Context, time.Time, time.Time, string, string, string, int, int) ([]domain.ViewDonation, error)
-	DonationsLinkUnlink(context.Context, domain.SalesforceClient, []salesforce.IDRef, time.Time, time.Time) error
+	DonationsGet(context.Context, time.Time, time.Time, string, string, string, string, db.SortOrder, int, int) ([]domain.ViewDonation, error)
+	LinkActionRun(context.Context, domain.SalesforceClient, domain.XeroClient, domain.LinkAction, time.Time, time.Time) error
 	// Invoices.
 	InvoiceDetailGet(context.Context, string) (db.WRInvoice, []domain.ViewLineItem, error)
-	InvoicesGet(context.Context, string, time.Time, time.Time, string, int, int) ([]db.Invoice, error)
+	InvoicesGet(context.Context, string, time.Time, time.Time, string, string, db.SortOrder, int, int) ([]db.Invoice, error)
+	InvoiceNeighbours(context.Context, string, time.Time, time.Time, string, string, db.SortOrder) (db.Neighbours, error)
 	// Transactions (bank transactions).
 	TransactionDetailGet(context.Context, string) (db.WRTransaction, []domain.ViewLineItem, error)
-	TransactionsGet(context.Context, string, time.Time, time.Time, string, int, int) ([]db.BankTransaction, error)
+	TransactionsGet(context.Context, string, time.Time, time.Time, string, string, db.SortOrder, int, int) ([]db.BankTransaction, error)
+	TransactionNeighbours(context.Context, string, time.Time, time.Time, string, string, db.SortOrder) (db.Neighbours, error)
+	// Line items of the invoices or bank transactions of a listing, for export.
+	LineItemsExportGet(context.Context, string, string, time.Time, time.Time, string, string, db.SortOrder) ([]db.LineItemExport, error)
 	// Detail summary for an Invoice or Bank Transaction.
 	InvoiceOrBankTransactionInfoGet(context.Context, string, string) (string, time.Time, error)
+	// Xero organisation short code for deep links.
+	XeroShortCodeGet(context.Context) (string, error)
+	// Salesforce instance url for deep links.
+	SalesforceInstanceURLGet(context.Context) (string, error)
+	SalesforceInstanceURLUpsert(context.Context, string) error
+	// Reconciliation tolerance.
+	ToleranceGet(context.Context) (db.Tolerance, error)
+	ToleranceUpsert(context.Context, db.Tolerance) error
+	// Donation accounts selection.
+	AccountsGet(context.Context) ([]db.Account, error)
+	DonationAccountsSet(context.Context, []string) error
+	DonationAccountsReset(context.Context) error
+	DonationAccountsSelected() []string
+	DonationAccountsRegexp(*regexp.Regexp) *regexp.Regexp
+	// Account rules classifying line items.
+	AccountRulesGet(context.Context) ([]db.AccountRule, error)
+	AccountRuleAdd(context.Context, string, string, string) error
+	AccountRuleDelete(context.Context, int64) error
+	// Period locks and their audit events.
+	PeriodLocksGet(context.Context) ([]db.PeriodLock, error)
+	PeriodLocksEnabled() bool
+	PeriodLockAdd(context.Context, time.Time, time.Time, string) error
+	PeriodLockRemove(context.Context, int64, string) error
+	AuditEventsGet(context.Context) ([]db.AuditEvent, error)
+	PeriodSnapshotsGet(context.Context) ([]db.PeriodSnapshot, error)
+	PeriodSnapshotCompare(context.Context, int64) (*domain.PeriodSnapshotComparison, error)
+	// Donation splits.
+	DonationSplitsGet(context.Context, string, string) ([]db.DonationSplit, error)
+	DonationSplitUpsert(context.Context, string, string, string, money.Amount) error
+	DonationSplitDelete(context.Context, string, string, int64) error
+	// Notes and flags.
+	AnnotationsGet(context.Context, string, string) ([]db.Annotation, error)
+	AnnotationAdd(context.Context, string, string, string, bool) error
+	AnnotationDelete(context.Context, string, string, int64) error
+	// Assignment of records to team members.
+	AssignmentGet(context.Context, string, string) (string, error)
+	AssignmentSet(context.Context, string, string, string) error
+	// Full-text search.
+	Search(context.Context, string, int) ([]db.SearchResult, error)
+	// Saved searches.
+	SavedSearchesGet(context.Context, string) ([]db.SavedSearch, error)
+	SavedSearchUpsert(context.Context, string, string, string, bool) error
+	SavedSearchDelete(context.Context, string, int64) error
+	// Contacts.
+	ContactDetailGet(context.Context, string) (db.Contact, []db.ContactRecord, error)
+	ContactAliasesGet(context.Context, string) ([]db.ContactAlias, []db.ContactAliasCandidate, error)
+	ContactAliasAdd(context.Context, string, string) error
+	ContactAliasRemove(context.Context, string) error
+	// Pending link actions.
+	PendingActionsGet(context.Context, bool) ([]db.PendingAction, error)
+	PendingActionRetry(context.Context, domain.SalesforceClient, domain.XeroClient, int64, time.Time, time.Time) error
+	PendingActionCompensate(context.Context, domain.SalesforceClient, domain.XeroClient, int64, time.Time, time.Time) error
+	OutboxRetry(context.Context, domain.SalesforceClient, domain.XeroClient, time.Time, time.Time) (domain.OutboxResults, error)
+	OutboxActionsGet(context.Context) ([]int64, error)
+	// Orphaned donations.
+	DonationOrphansCheck(context.Context, domain.SalesforceClient, time.Time) (*domain.OrphanResults, error)
+	LinksVerify(context.Context, domain.SalesforceClient, domain.XeroClient, int) (*domain.LinkVerifyResults, error)
+	DonationOrphansGet(context.Context) ([]db.DonationOrphan, error)
+	DonationOrphansRemove(context.Context, []string) (int, error)
+	// Link suggestions.
+	LinkSuggestionsGet(context.Context, time.Time, time.Time, float64) ([]db.LinkSuggestion, error)
+	DonationCandidatesGet(context.Context, string, string) ([]db.LinkSuggestion, error)
+	LinkSuggestionDecisionsApply(context.Context, domain.SalesforceClient, []domain.SuggestionDecision, time.Time, time.Time) (*domain.SuggestionDecisionResults, error)
+	// Payouts.
+	PayoutBatchGet(context.Context, string, float64) (*domain.PayoutBatch, error)
+	PayoutCandidatesLink(context.Context, domain.SalesforceClient, string, float64, time.Time, time.Time) (*domain.SuggestionDecisionResults, error)
+	PayoutCombinationLink(context.Context, domain.SalesforceClient, string, []string, time.Time, time.Time) (*domain.SuggestionDecisionResults, error)
+	PayoutItemsStage(context.Context, string, string, string, []payoutcsv.Item) (int64, error)
+	// Bank statements, matched to the bank transactions.
+	StatementLinesStage(context.Context, string, string, []statementcsv.Line) (int64, error)
+	StatementReportGet(context.Context, time.Time, time.Time, bool) (*domain.StatementReport, error)
+	// Staged imports, including donations imported from other CRMs.
+	DonationsStage(context.Context, string, *donorimport.Sheet, donorimport.Mapping) (int64, error)
+	ImportBatchesGet(context.Context) ([]db.ImportBatch, error)
+	ImportReviewGet(context.Context, int64) (*domain.ImportReview, error)
+	ImportCommit(context.Context, int64) (*domain.ImportCommitResult, error)
+	ImportDiscard(context.Context, int64) error
+	// Reports.
+	PeriodReportGet(context.Context, time.Time, time.Time) (*domain.PeriodReport, error)
+	GiftAidClaimGet(context.Context, time.Time, time.Time, *regexp.Regexp, domain.GiftAidFields) (*domain.GiftAidClaim, error)
+	AgingReportGet(context.Context, time.Time, time.Time) (*domain.AgingReport, error)
+	AccountBreakdownReportGet(context.Context, time.Time, time.Time) (*domain.AccountBreakdownReport, error)
+	PayoutGroupsReportGet(context.Context, time.Time, time.Time, bool) (*domain.PayoutGroupsReport, error)
 	// Data refresh.
 	SalesforceRecordsRefresh(context.Context, domain.SalesforceClient, time.Time, time.Time) (*domain.RefreshSalesforceResults, error)
+	SalesforceChangesSubscribe(context.Context, domain.SalesforceClient, time.Time, func(domain.SalesforceChangeResults)) error
 	XeroRecordsRefresh(context.Context, domain.XeroClient, time.Time, time.Time, *regexp.Regexp, bool) (*domain.RefreshXeroResults, error)
+	RecordRefresh(context.Context, domain.XeroClient, domain.SalesforceClient, string, string) error
+	SyncRunsGet(context.Context) ([]db.SyncRun, error)
+	Backfill(context.Context, domain.XeroClient, domain.SalesforceClient, string, time.Time, time.Time, *regexp.Regexp) (*domain.BackfillResults, error)
+	// Import errors, the refreshed records which failed validation or could not be stored.
+	ImportErrorsGet(context.Context) ([]db.ImportError, error)
+	ImportErrorRetry(context.Context, domain.XeroClient, domain.SalesforceClient, int64) error
+	ImportErrorDiscard(context.Context, int64) error
 	// Database.
 	DBIsInMemory() bool
 	DBPath() string
+	SQLReload() error
+	SlowQueriesGet() ([]db.SlowQuery, time.Duration)
+	PoolStatsGet() db.PoolStats
+	CacheStatsGet() db.CacheStats
+	DataVersionGet() (string, time.Time)
+	SnapshotExport(context.Context, string) error
+	SnapshotImport(context.Context, string) error
+	LinkMappingsExport(context.Context, io.Writer) error
+	LinkMappingsImport(context.Context, io.Reader) (int, int, error)
 	Close() error
 }
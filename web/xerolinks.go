@@ -0,0 +1,71 @@
+package web
+
+// xerolinks.go provides deep links from invoices, bank transactions and contacts into
+// the Xero web application.
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sync"
+)
+
+// xeroShortCodeSessionKey is the session key for the Xero organisation short code.
+const xeroShortCodeSessionKey = "xero-shortcode"
+
+// xeroBaseURL is the base url of the Xero web application.
+const xeroBaseURL = "https://go.xero.com"
+
+// xeroShortCode returns the Xero organisation short code, first from the session and
+// otherwise from the database, in which case it is cached in the session. An empty
+// string is returned if the organisation record has not been retrieved from Xero.
+func (web *WebApp) xeroShortCode(ctx context.Context) string {
+	if sc := web.sessions.GetString(ctx, xeroShortCodeSessionKey); sc != "" {
+		return sc
+	}
+	sc, err := web.reconciler.XeroShortCodeGet(ctx)
+	if err != nil {
+		web.log.Error(fmt.Sprintf("xero short code retrieval error: %v", err))
+		return ""
+	}
+	if sc != "" {
+		web.sessions.Put(ctx, xeroShortCodeSessionKey, sc)
+	}
+	return sc
+}
+
+// xeroDeepLink returns a url to the provided Xero application path. If the
+// organisation short code is known the url is routed through the Xero organisation
+// login page, which selects the correct organisation for users with access to more
+// than one.
+func xeroDeepLink(shortCode, path string) string {
+	if shortCode == "" {
+		return xeroBaseURL + path
+	}
+	return fmt.Sprintf(
+		"%s/organisationlogin/default.aspx?shortcode=%s&redirecturl=%s",
+		xeroBaseURL,
+		url.QueryEscape(shortCode),
+		url.QueryEscape(path),
+	)
+}
+
+// xeroTemplateFuncs returns the request-specific template funcs for rendering deep
+// links to Xero records. The short code is only looked up if a link is rendered.
+func (web *WebApp) xeroTemplateFuncs(ctx context.Context) template.FuncMap {
+	shortCode := sync.OnceValue(func() string {
+		return web.xeroShortCode(ctx)
+	})
+	return template.FuncMap{
+		"xeroInvoiceURL": func(id string) string {
+			return xeroDeepLink(shortCode(), "/AccountsReceivable/View.aspx?InvoiceID="+url.QueryEscape(id))
+		},
+		"xeroBankTransactionURL": func(id string) string {
+			return xeroDeepLink(shortCode(), "/Bank/ViewTransaction.aspx?bankTransactionID="+url.QueryEscape(id))
+		},
+		"xeroContactURL": func(id string) string {
+			return xeroDeepLink(shortCode(), "/Contacts/View/"+url.PathEscape(id))
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package web
+
+import "testing"
+
+func TestXeroDeepLink(t *testing.T) {
+	tests := []struct {
+		shortCode string
+		path      string
+		want      string
+	}{
+		{
+			shortCode: "",
+			path:      "/Bank/ViewTransaction.aspx?bankTransactionID=bt-001",
+			want:      "https://go.xero.com/Bank/ViewTransaction.aspx?bankTransactionID=bt-001",
+		},
+		{
+			shortCode: "!xkJ7d",
+			path:      "/AccountsReceivable/View.aspx?InvoiceID=inv-001",
+			want:      "https://go.xero.com/organisationlogin/default.aspx?shortcode=%21xkJ7d&redirecturl=%2FAccountsReceivable%2FView.aspx%3FInvoiceID%3Dinv-001",
+		},
+	}
+	for _, tt := range tests {
+		if got := xeroDeepLink(tt.shortCode, tt.path); got != tt.want {
+			t.Errorf("xeroDeepLink(%q, %q) got %q want %q", tt.shortCode, tt.path, got, tt.want)
+		}
+	}
+}